@@ -267,6 +267,7 @@ STANDARD_BINARIES:=./istioctl/cmd/istioctl \
   ./pilot/cmd/pilot-discovery \
   ./pkg/test/echo/cmd/client \
   ./pkg/test/echo/cmd/server \
+  ./pkg/test/otlp/cmd/otlp-sink \
   ./operator/cmd/operator \
   ./cni/cmd/istio-cni \
   ./cni/cmd/istio-cni-taint \
@@ -469,9 +470,15 @@ test: racetest ## Runs all unit tests
 BENCH_TARGETS ?= ./pilot/...
 
 .PHONY: racetest
-racetest: $(JUNIT_REPORT)
+racetest: $(JUNIT_REPORT) envoy-conformance-test
 	go test ${GOBUILDFLAGS} ${T} -race ./... 2>&1 | tee >($(JUNIT_REPORT) > $(JUNIT_OUT))
 
+# Replays the configuration corpus against the Envoy binary downloaded by init, or set with ENVOY_PATH.
+# It runs as part of racetest, so config-compat regressions against a new Envoy fail presubmit.
+.PHONY: envoy-conformance-test
+envoy-conformance-test: $(JUNIT_REPORT) init
+	go test ${GOBUILDFLAGS} ${T} -tags=conformance ./pilot/test/envoyconformance/... 2>&1 | tee >($(JUNIT_REPORT) > $(ARTIFACTS)/junit-envoy-conformance.xml)
+
 .PHONY: benchtest
 benchtest: $(JUNIT_REPORT) ## Runs all benchmarks
 	prow/benchtest.sh run $(BENCH_TARGETS)
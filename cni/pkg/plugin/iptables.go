@@ -31,6 +31,9 @@ import (
 var dryRunFilePath = env.RegisterStringVar("DRY_RUN_FILE_PATH", "",
 	"If provided, CNI will dry run iptables rule apply, and print the applied rules to the given file.")
 
+var dryRunPlanFilePath = env.RegisterStringVar("DRY_RUN_PLAN_FILE_PATH", "",
+	"If provided, CNI will write the plan of the applied iptables rules, as JSON, to the given file.")
+
 // getNs is a unit test override variable for interface create.
 var getNs = ns.GetNS
 
@@ -58,8 +61,11 @@ func (ipt *iptables) Program(podName, netns string, rdrct *Redirect) error {
 	drf := dryRunFilePath.Get()
 	viper.Set(constants.DryRun, drf != "")
 	viper.Set(constants.OutputPath, drf)
+	viper.Set(constants.PlanOutputPath, dryRunPlanFilePath.Get())
 	viper.Set(constants.RedirectDNS, rdrct.dnsRedirect)
 	viper.Set(constants.CaptureAllDNS, rdrct.dnsRedirect)
+	viper.Set(constants.RedirectIPv6, rdrct.ipv6Redirect)
+	viper.Set(constants.AdditionalIPs, rdrct.additionalIPs)
 	viper.Set(constants.DropInvalid, rdrct.invalidDrop)
 
 	netNs, err := getNs(netns)
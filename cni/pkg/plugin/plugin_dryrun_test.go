@@ -31,7 +31,9 @@ import (
 
 	"istio.io/api/annotation"
 	"istio.io/istio/pilot/cmd/pilot-agent/options"
+	"istio.io/istio/pilot/pkg/model"
 	diff "istio.io/istio/pilot/test/util"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/test/env"
 	"istio.io/istio/tools/istio-iptables/pkg/cmd"
 )
@@ -148,6 +150,59 @@ func TestIPTablesRuleGeneration(t *testing.T) {
 			},
 			golden: filepath.Join(env.IstioSrc, "cni/pkg/plugin/testdata/dns.txt.golden"),
 		},
+		{
+			name: "DNS annotation",
+			input: &PodInfo{
+				Containers:     []string{"test", "istio-proxy"},
+				InitContainers: map[string]struct{}{"istio-validate": {}},
+				Annotations: map[string]string{
+					annotation.SidecarStatus.Name:                 "true",
+					constants.SidecarTrafficRedirectDNSAnnotation: "true",
+				},
+				ProxyEnvironments: map[string]string{},
+			},
+			golden: filepath.Join(env.IstioSrc, "cni/pkg/plugin/testdata/dns.txt.golden"),
+		},
+		{
+			// The proxy environment, which the agent follows, wins over a conflicting annotation.
+			name: "DNS annotation conflict",
+			input: &PodInfo{
+				Containers:     []string{"test", "istio-proxy"},
+				InitContainers: map[string]struct{}{"istio-validate": {}},
+				Annotations: map[string]string{
+					annotation.SidecarStatus.Name:                 "true",
+					constants.SidecarTrafficRedirectDNSAnnotation: "true",
+				},
+				ProxyEnvironments: map[string]string{options.DNSCaptureByAgent.Name: "false"},
+			},
+			golden: filepath.Join(env.IstioSrc, "cni/pkg/plugin/testdata/basic.txt.golden"),
+		},
+		{
+			name: "IPv6",
+			input: &PodInfo{
+				Containers:     []string{"test", "istio-proxy"},
+				InitContainers: map[string]struct{}{"istio-validate": {}},
+				Annotations: map[string]string{
+					annotation.SidecarStatus.Name:                  "true",
+					constants.SidecarTrafficRedirectIPv6Annotation: "true",
+				},
+				ProxyEnvironments: map[string]string{},
+			},
+			golden: filepath.Join(env.IstioSrc, "cni/pkg/plugin/testdata/ipv6.txt.golden"),
+		},
+		{
+			name: "additional-ipv6",
+			input: &PodInfo{
+				Containers:     []string{"test", "istio-proxy"},
+				InitContainers: map[string]struct{}{"istio-validate": {}},
+				Annotations: map[string]string{
+					annotation.SidecarStatus.Name: "true",
+					model.AdditionalIPsAnnotation: "10.1.0.5,fd00::5",
+				},
+				ProxyEnvironments: map[string]string{},
+			},
+			golden: filepath.Join(env.IstioSrc, "cni/pkg/plugin/testdata/ipv6.txt.golden"),
+		},
 		{
 			name: "invalid-drop",
 			input: &PodInfo{
@@ -162,7 +217,6 @@ func TestIPTablesRuleGeneration(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// TODO(bianpengyuan): How do we test ipv6 rules?
 			getKubePodInfo = generateMockK8sPodInfoFunc(tt.input)
 			getNs = generateMockGetNsFunc(sandboxDirectory)
 			tmpDir := t.TempDir()
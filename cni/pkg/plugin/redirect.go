@@ -23,6 +23,8 @@ import (
 
 	"istio.io/api/annotation"
 	"istio.io/istio/pilot/cmd/pilot-agent/options"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/tools/istio-iptables/pkg/cmd"
 	"istio.io/pkg/log"
 )
@@ -52,6 +54,11 @@ var (
 
 	kubevirtInterfacesKey = annotation.SidecarTrafficKubevirtInterfaces.Name
 
+	redirectDNSKey  = constants.SidecarTrafficRedirectDNSAnnotation
+	redirectIPv6Key = constants.SidecarTrafficRedirectIPv6Annotation
+
+	additionalIPsKey = model.AdditionalIPsAnnotation
+
 	annotationRegistry = map[string]*annotationParam{
 		"inject":               {injectAnnotationKey, "", alwaysValidFunc},
 		"status":               {sidecarStatusKey, "", alwaysValidFunc},
@@ -63,6 +70,9 @@ var (
 		"excludeInboundPorts":  {excludeInboundPortsKey, defaultRedirectExcludePort, validatePortList},
 		"excludeOutboundPorts": {excludeOutboundPortsKey, defaultRedirectExcludePort, validatePortList},
 		"kubevirtInterfaces":   {kubevirtInterfacesKey, defaultKubevirtInterfaces, alwaysValidFunc},
+		"redirectDNS":          {redirectDNSKey, "", validateBool},
+		"redirectIPv6":         {redirectIPv6Key, "", validateBool},
+		"additionalIPs":        {additionalIPsKey, "", validateIPList},
 	}
 )
 
@@ -79,7 +89,12 @@ type Redirect struct {
 	kubevirtInterfaces   string
 	excludeInterfaces    string
 	dnsRedirect          bool
-	invalidDrop          bool
+	// ipv6Redirect forces the redirection of IPv6 traffic on ("true") or off ("false"). When empty, it is enabled
+	// when the pod IP or one of the additionalIPs is an IPv6 address.
+	ipv6Redirect string
+	// additionalIPs are the comma separated IPs of the secondary interfaces declared by the pod.
+	additionalIPs string
+	invalidDrop   bool
 }
 
 type annotationValidationFunc func(value string) error
@@ -94,6 +109,11 @@ func alwaysValidFunc(value string) error {
 	return nil
 }
 
+func validateBool(value string) error {
+	_, err := strconv.ParseBool(value)
+	return err
+}
+
 // validateInterceptionMode validates the interceptionMode annotation
 func validateInterceptionMode(mode string) error {
 	switch mode {
@@ -116,6 +136,17 @@ func validateCIDRList(cidrs string) error {
 	return nil
 }
 
+func validateIPList(ips string) error {
+	if len(ips) > 0 {
+		for _, ip := range strings.Split(ips, ",") {
+			if net.ParseIP(strings.TrimSpace(ip)) == nil {
+				return fmt.Errorf("failed parsing ip '%s'", ip)
+			}
+		}
+	}
+	return nil
+}
+
 func splitPorts(portsString string) []string {
 	return strings.Split(portsString, ",")
 }
@@ -250,13 +281,44 @@ func NewRedirect(pi *PodInfo) (*Redirect, error) {
 		return nil, fmt.Errorf("annotation value error for value %s; annotationFound = %t: %v",
 			"kubevirtInterfaces", isFound, valErr)
 	}
-	if v, found := pi.ProxyEnvironments[options.DNSCaptureByAgent.Name]; found {
+	dnsCaptureEnv, dnsCaptureEnvFound := pi.ProxyEnvironments[options.DNSCaptureByAgent.Name]
+	if dnsCaptureEnvFound {
 		// parse and set the bool value of dnsRedirect
-		redir.dnsRedirect, valErr = strconv.ParseBool(v)
+		redir.dnsRedirect, valErr = strconv.ParseBool(dnsCaptureEnv)
 		if valErr != nil {
 			log.Warnf("cannot parse DNS capture environment variable %v", valErr)
 		}
 	}
+	isFound, redirectDNS, valErr := getAnnotationOrDefault("redirectDNS", pi.Annotations)
+	if valErr != nil {
+		return nil, fmt.Errorf("annotation value error for value %s; annotationFound = %t: %v",
+			"redirectDNS", isFound, valErr)
+	}
+	if isFound {
+		dnsRedirect, _ := strconv.ParseBool(redirectDNS)
+		if dnsCaptureEnvFound && dnsRedirect != redir.dnsRedirect {
+			// The agent only answers the captured DNS queries when its own setting is enabled, so follow it rather
+			// than break name resolution in the pod.
+			log.Warnf("annotation %s=%s conflicts with the %s=%s environment variable of the proxy, which is used instead",
+				redirectDNSKey, redirectDNS, options.DNSCaptureByAgent.Name, dnsCaptureEnv)
+		} else {
+			redir.dnsRedirect = dnsRedirect
+		}
+	}
+	isFound, redir.ipv6Redirect, valErr = getAnnotationOrDefault("redirectIPv6", pi.Annotations)
+	if valErr != nil {
+		return nil, fmt.Errorf("annotation value error for value %s; annotationFound = %t: %v",
+			"redirectIPv6", isFound, valErr)
+	}
+	if isFound {
+		ipv6Redirect, _ := strconv.ParseBool(redir.ipv6Redirect)
+		redir.ipv6Redirect = strconv.FormatBool(ipv6Redirect)
+	}
+	isFound, redir.additionalIPs, valErr = getAnnotationOrDefault("additionalIPs", pi.Annotations)
+	if valErr != nil {
+		return nil, fmt.Errorf("annotation value error for value %s; annotationFound = %t: %v",
+			"additionalIPs", isFound, valErr)
+	}
 	if v, found := pi.ProxyEnvironments[cmd.InvalidDropByIptables.Name]; found {
 		// parse and set the bool value of invalidDrop
 		redir.invalidDrop, valErr = strconv.ParseBool(v)
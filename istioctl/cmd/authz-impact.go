@@ -0,0 +1,179 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/istioctl/pkg/authz"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+func impactCmd() *cobra.Command {
+	var flowsFile, currentFile string
+	var proposedFiles []string
+	cmd := &cobra.Command{
+		Use:   "impact",
+		Short: "Reports the recorded flows whose authorization changes with proposed AuthorizationPolicies.",
+		Long: `Impact replays recorded flows against the current and the proposed AuthorizationPolicies, and reports the flows
+which would newly be denied or allowed, before the proposed policies are applied.
+
+The proposed policies are the current policies, read from the cluster or from a file, with the policies of the files
+added or replacing the policies of the same name. The flows are JSON lines, recorded from the access logs or derived
+from the metrics of the workloads, with the fields:
+
+  sourcePrincipal, sourceNamespace, sourceIP, requestPrincipal, destinationNamespace,
+  destinationLabels, destinationPort, host, method, path, headers
+
+Flows without a method are TCP connections.`,
+		Example: `  # Report the flows whose authorization changes with the policies of deny-all.yaml
+  istioctl x authz impact -f deny-all.yaml --flows flows.jsonl
+
+  # Compare with the current policies of a file instead of the cluster
+  istioctl x authz impact -f deny-all.yaml --current current.yaml --flows flows.jsonl`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("impact takes no arguments")
+			}
+			if flowsFile == "" || len(proposedFiles) == 0 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("impact requires proposed policies and flows")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := readFile(flowsFile)
+			if err != nil {
+				return err
+			}
+			flows, err := authz.ReadFlows(bytes.NewReader(data))
+			if err != nil {
+				return err
+			}
+
+			var current []config.Config
+			if currentFile != "" {
+				current, err = readAuthorizationPolicies(currentFile)
+			} else {
+				current, err = listAuthorizationPolicies()
+			}
+			if err != nil {
+				return err
+			}
+			proposed := append([]config.Config{}, current...)
+			for _, f := range proposedFiles {
+				policies, err := readAuthorizationPolicies(f)
+				if err != nil {
+					return err
+				}
+				proposed = replaceConfigs(proposed, policies)
+			}
+
+			currentPolicies, err := authz.NewPolicies(istioNamespace, current)
+			if err != nil {
+				return err
+			}
+			proposedPolicies, err := authz.NewPolicies(istioNamespace, proposed)
+			if err != nil {
+				return err
+			}
+			authz.PrintImpact(cmd.OutOrStdout(), authz.Compare(currentPolicies, proposedPolicies, flows))
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringSliceVarP(&proposedFiles, "file", "f", nil, "The files with the proposed AuthorizationPolicies")
+	cmd.PersistentFlags().StringVar(&currentFile, "current", "",
+		"The file with the current AuthorizationPolicies, read from the cluster if not set")
+	cmd.PersistentFlags().StringVar(&flowsFile, "flows", "", "The file with the recorded flows as JSON lines, or - for stdin")
+	return cmd
+}
+
+// readAuthorizationPolicies returns the AuthorizationPolicies of the file. Policies without a namespace are in the
+// namespace of the command.
+func readAuthorizationPolicies(filename string) ([]config.Config, error) {
+	data, err := readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	configs, _, err := crd.ParseInputs(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", filename, err)
+	}
+	var policies []config.Config
+	for _, cfg := range configs {
+		if cfg.GroupVersionKind != gvk.AuthorizationPolicy {
+			continue
+		}
+		if cfg.Namespace == "" {
+			cfg.Namespace = handlers.HandleNamespace(namespace, defaultNamespace)
+		}
+		policies = append(policies, cfg)
+	}
+	return policies, nil
+}
+
+// listAuthorizationPolicies returns the AuthorizationPolicies of the cluster.
+func listAuthorizationPolicies() ([]config.Config, error) {
+	client, err := kubeClient(kubeconfig, configContext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create k8s client: %w", err)
+	}
+	list, err := client.Istio().SecurityV1beta1().AuthorizationPolicies(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list authorization policies: %v", err)
+	}
+	policies := make([]config.Config, 0, len(list.Items))
+	for i := range list.Items {
+		p := &list.Items[i]
+		policies = append(policies, config.Config{
+			Meta: config.Meta{
+				GroupVersionKind:  gvk.AuthorizationPolicy,
+				Name:              p.Name,
+				Namespace:         p.Namespace,
+				Labels:            p.Labels,
+				Annotations:       p.Annotations,
+				CreationTimestamp: p.CreationTimestamp.Time,
+			},
+			Spec: &p.Spec,
+		})
+	}
+	return policies, nil
+}
+
+// replaceConfigs returns the configs with the updates, replacing the configs of the same name.
+func replaceConfigs(configs, updates []config.Config) []config.Config {
+	for _, u := range updates {
+		replaced := false
+		for i, cfg := range configs {
+			if cfg.Name == u.Name && cfg.Namespace == u.Namespace {
+				configs[i] = u
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			configs = append(configs, u)
+		}
+	}
+	return configs
+}
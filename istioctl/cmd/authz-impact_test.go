@@ -0,0 +1,45 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAuthzImpact(t *testing.T) {
+	out, err := runTestCmd(t, strings.Split("x authz impact -f testdata/authz-impact/proposed.yaml "+
+		"--current testdata/authz-impact/current.yaml --flows testdata/authz-impact/flows.jsonl", " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	want := [][]string{
+		{"FLOW", "CURRENT", "PROPOSED"},
+		{"sa/productpage", "POST reviews/reviews/1", "ALLOW", "DENY", "no ALLOW policy matched"},
+		{"sa/ratings", "GET details/admin/stats", "DENY", "ALLOW", "no ALLOW policy applied"},
+		{"sa/ratings", "GET reviews/reviews/2", "ALLOW", "DENY", "no ALLOW policy matched"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got:\n%s", len(want), out)
+	}
+	for i, fields := range want {
+		for _, f := range fields {
+			if !strings.Contains(lines[i], f) {
+				t.Errorf("expected %q in line %q", f, lines[i])
+			}
+		}
+	}
+}
@@ -149,6 +149,7 @@ func AuthZ() *cobra.Command {
 	}
 
 	cmd.AddCommand(checkCmd)
+	cmd.AddCommand(impactCmd())
 	cmd.Long += "\n\n" + ExperimentalMsg
 	return cmd
 }
@@ -0,0 +1,123 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	envoy_corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/istioctl/pkg/multixds"
+	"istio.io/istio/pilot/pkg/networking/egressaudit"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func externalDependenciesCmd() *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	var centralOpts clioptions.CentralControlPlaneOptions
+	var reportNamespace string
+
+	cmd := &cobra.Command{
+		Use:   "external-dependencies",
+		Short: "Reports the destinations outside of the registry that workloads send traffic to",
+		Long: `
+Reports, for each workload, the destinations outside of the service registry it sent traffic to, with the
+number of requests and connections. These destinations need a ServiceEntry before moving the outbound
+traffic policy to REGISTRY_ONLY.
+
+Traffic allowed by ALLOW_ANY is reported with the ALLOW_ANY policy. With PILOT_OUTBOUND_TRAFFIC_AUDIT, the
+traffic of REGISTRY_ONLY workloads is not blocked but reported with the REGISTRY_ONLY policy.
+
+Traffic is read by Istiod from Prometheus, configured with PILOT_OUTBOUND_AUDIT_PROMETHEUS_ADDRESS.
+`,
+		Example: `  # Report the external dependencies of all workloads
+  istioctl experimental external-dependencies
+
+  # Report the external dependencies of the workloads in the foo namespace
+  istioctl experimental external-dependencies --report-namespace foo`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			kubeClient, err := kubeClientWithRevision(kubeconfig, configContext, opts.Revision)
+			if err != nil {
+				return err
+			}
+			xdsRequest := xdsapi.DiscoveryRequest{
+				ResourceNames: []string{"external_dependencies"},
+				Node: &envoy_corev3.Node{
+					Id: "debug~0.0.0.0~istioctl~cluster.local",
+				},
+				TypeUrl: v3.DebugType,
+			}
+			xdsResponses, err := multixds.AllRequestAndProcessXds(&xdsRequest, centralOpts, istioNamespace, "", "", kubeClient)
+			if err != nil {
+				return err
+			}
+			return printExternalDependencies(c.OutOrStdout(), xdsResponses, reportNamespace)
+		},
+	}
+
+	opts.AttachControlPlaneFlags(cmd)
+	centralOpts.AttachControlPlaneFlags(cmd)
+	cmd.Flags().StringVar(&reportNamespace, "report-namespace", "", "Only report the workloads in this namespace")
+	cmd.Long += "\n\n" + ExperimentalMsg
+	return cmd
+}
+
+// printExternalDependencies merges the reports of all Istiod instances, which read the same traffic, and prints
+// them as a table.
+func printExternalDependencies(w io.Writer, responses map[string]*xdsapi.DiscoveryResponse, namespace string) error {
+	seen := map[egressaudit.Dependency]struct{}{}
+	var deps []egressaudit.Dependency
+	for _, response := range responses {
+		for _, resource := range response.Resources {
+			report := egressaudit.Report{}
+			if err := json.Unmarshal(resource.Value, &report); err != nil {
+				return fmt.Errorf("failed to parse external dependencies report: %v", err)
+			}
+			if report.TrafficSource == "" {
+				_, _ = fmt.Fprintln(w, "warning: no traffic source configured, set PILOT_OUTBOUND_AUDIT_PROMETHEUS_ADDRESS")
+			}
+			if report.Error != "" {
+				_, _ = fmt.Fprintf(w, "warning: failed to read traffic from %s: %s\n", report.TrafficSource, report.Error)
+			}
+			for _, dep := range report.Dependencies {
+				if _, f := seen[dep]; f || (namespace != "" && dep.SourceNamespace != namespace) {
+					continue
+				}
+				seen[dep] = struct{}{}
+				deps = append(deps, dep)
+			}
+		}
+	}
+	egressaudit.Sort(deps)
+
+	tw := new(tabwriter.Writer).Init(w, 0, 8, 3, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAMESPACE\tWORKLOAD\tDESTINATION\tPOLICY\tREQUESTS\tCONNECTIONS")
+	for _, d := range deps {
+		policy := "ALLOW_ANY"
+		if d.Audited {
+			policy = "REGISTRY_ONLY"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%.0f\t%.0f\n",
+			d.SourceNamespace, d.SourceWorkload, d.Destination, policy, d.Requests, d.Connections)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,70 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/networking/egressaudit"
+)
+
+func TestPrintExternalDependencies(t *testing.T) {
+	response := func(deps ...egressaudit.Dependency) *xdsapi.DiscoveryResponse {
+		b, err := json.Marshal(egressaudit.Report{TrafficSource: "fake", Dependencies: deps})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &xdsapi.DiscoveryResponse{Resources: []*anypb.Any{{Value: b}}}
+	}
+	github := egressaudit.Dependency{
+		SourceWorkload: "reviews-v1", SourceNamespace: "default", Destination: "api.github.com", Requests: 12,
+	}
+	stripe := egressaudit.Dependency{
+		SourceWorkload: "payments", SourceNamespace: "foo", Destination: "api.stripe.com", Audited: true, Connections: 3,
+	}
+	responses := map[string]*xdsapi.DiscoveryResponse{
+		"istiod-1": response(github, stripe),
+		"istiod-2": response(github),
+	}
+
+	out := &bytes.Buffer{}
+	if err := printExternalDependencies(out, responses, ""); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header and 2 deduplicated dependencies, got:\n%s", out.String())
+	}
+	if !strings.Contains(lines[1], "api.github.com") || !strings.Contains(lines[1], "ALLOW_ANY") {
+		t.Fatalf("expected api.github.com to be allowed, got %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "api.stripe.com") || !strings.Contains(lines[2], "REGISTRY_ONLY") {
+		t.Fatalf("expected api.stripe.com to be audited, got %q", lines[2])
+	}
+
+	out.Reset()
+	if err := printExternalDependencies(out, responses, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "api.github.com") || !strings.Contains(out.String(), "api.stripe.com") {
+		t.Fatalf("expected only the foo namespace, got:\n%s", out.String())
+	}
+}
@@ -15,22 +15,19 @@
 package cmd
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
-	"net/url"
 	"regexp"
 	"sort"
 	"strings"
-	"sync"
 
 	"github.com/spf13/cobra"
 
 	"istio.io/istio/istioctl/pkg/clioptions"
 	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pkg/istiodctl"
 	"istio.io/pkg/log"
 )
 
@@ -46,7 +43,7 @@ var (
 )
 
 type resetState struct {
-	client *ControlzClient
+	client *istiodctl.Client
 }
 
 func (rs *resetState) run() (string, error) {
@@ -54,19 +51,19 @@ func (rs *resetState) run() (string, error) {
 		defaultOutputLevel     = "info"
 		defaultStackTraceLevel = "none"
 	)
-	allScopes, err := rs.client.GetScopes()
+	allScopes, err := rs.client.Scopes(context.TODO())
 	if err != nil {
 		return "", fmt.Errorf("could not get all scopes: %v", err)
 	}
-	var defaultScopes []*ScopeInfo
+	var defaultScopes []*istiodctl.ScopeInfo
 	for _, scope := range allScopes {
-		defaultScopes = append(defaultScopes, &ScopeInfo{
+		defaultScopes = append(defaultScopes, &istiodctl.ScopeInfo{
 			Name:            scope.Name,
 			OutputLevel:     defaultOutputLevel,
 			StackTraceLevel: defaultStackTraceLevel,
 		})
 	}
-	err = rs.client.PutScopes(defaultScopes)
+	err = rs.client.SetScopes(context.TODO(), defaultScopes)
 	if err != nil {
 		return "", err
 	}
@@ -75,7 +72,7 @@ func (rs *resetState) run() (string, error) {
 }
 
 type logLevelState struct {
-	client         *ControlzClient
+	client         *istiodctl.Client
 	outputLogLevel string
 }
 
@@ -84,7 +81,7 @@ func (ll *logLevelState) run() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	err = ll.client.PutScopes(scopeInfos)
+	err = ll.client.SetScopes(context.TODO(), scopeInfos)
 	if err != nil {
 		return "", err
 	}
@@ -92,7 +89,7 @@ func (ll *logLevelState) run() (string, error) {
 }
 
 type stackTraceLevelState struct {
-	client          *ControlzClient
+	client          *istiodctl.Client
 	stackTraceLevel string
 }
 
@@ -101,7 +98,7 @@ func (stl *stackTraceLevelState) run() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	err = stl.client.PutScopes(scopeInfos)
+	err = stl.client.SetScopes(context.TODO(), scopeInfos)
 	if err != nil {
 		return "", err
 	}
@@ -109,7 +106,7 @@ func (stl *stackTraceLevelState) run() (string, error) {
 }
 
 type getAllLogLevelsState struct {
-	client       *ControlzClient
+	client       *istiodctl.Client
 	outputFormat string
 }
 
@@ -118,7 +115,7 @@ func (ga *getAllLogLevelsState) run() (string, error) {
 		ScopeName string `json:"scope_name"`
 		LogLevel  string `json:"log_level"`
 	}
-	allScopes, err := ga.client.GetScopes()
+	allScopes, err := ga.client.Scopes(context.TODO())
 	sort.Slice(allScopes, func(i, j int) bool {
 		return allScopes[i].Name < allScopes[j].Name
 	})
@@ -158,7 +155,7 @@ func (id *istiodConfigLog) execute() (string, error) {
 	return output, err
 }
 
-func chooseClientFlag(ctrzClient *ControlzClient, reset bool, outputLogLevel, stackTraceLevel, outputFormat string) *istiodConfigLog {
+func chooseClientFlag(ctrzClient *istiodctl.Client, reset bool, outputLogLevel, stackTraceLevel, outputFormat string) *istiodConfigLog {
 	if reset {
 		return &istiodConfigLog{state: &resetState{ctrzClient}}
 	} else if outputLogLevel != "" {
@@ -179,14 +176,6 @@ func chooseClientFlag(ctrzClient *ControlzClient, reset bool, outputLogLevel, st
 	}
 }
 
-type ScopeInfo struct {
-	Name            string `json:"name"`
-	Description     string `json:"description,omitempty"`
-	OutputLevel     string `json:"output_level,omitempty"`
-	StackTraceLevel string `json:"stack_trace_level,omitempty"`
-	LogCallers      bool   `json:"log_callers,omitempty"`
-}
-
 type ScopeLevelPair struct {
 	scope    string
 	logLevel string
@@ -210,15 +199,15 @@ func newScopeLevelPair(slp, validationPattern string) (*ScopeLevelPair, error) {
 	return s, nil
 }
 
-func newScopeInfosFromScopeLevelPairs(scopeLevelPairs string) ([]*ScopeInfo, error) {
+func newScopeInfosFromScopeLevelPairs(scopeLevelPairs string) ([]*istiodctl.ScopeInfo, error) {
 	slParis := strings.Split(scopeLevelPairs, ",")
-	var scopeInfos []*ScopeInfo
+	var scopeInfos []*istiodctl.ScopeInfo
 	for _, slp := range slParis {
 		sl, err := newScopeLevelPair(slp, validationPattern)
 		if err != nil {
 			return nil, err
 		}
-		si := &ScopeInfo{
+		si := &istiodctl.ScopeInfo{
 			Name:        sl.scope,
 			OutputLevel: sl.logLevel,
 		}
@@ -243,15 +232,15 @@ func newScopeStackTraceLevelPair(sslp, validationPattern string) (*scopeStackTra
 	return ss, nil
 }
 
-func newScopeInfosFromScopeStackTraceLevelPairs(scopeStackTraceLevelPairs string) ([]*ScopeInfo, error) {
+func newScopeInfosFromScopeStackTraceLevelPairs(scopeStackTraceLevelPairs string) ([]*istiodctl.ScopeInfo, error) {
 	sslPairs := strings.Split(scopeStackTraceLevelPairs, ",")
-	var scopeInfos []*ScopeInfo
+	var scopeInfos []*istiodctl.ScopeInfo
 	for _, sslp := range sslPairs {
 		slp, err := newScopeStackTraceLevelPair(sslp, validationPattern)
 		if err != nil {
 			return nil, err
 		}
-		si := &ScopeInfo{
+		si := &istiodctl.ScopeInfo{
 			Name:            slp.scope,
 			StackTraceLevel: slp.logLevel,
 		}
@@ -260,98 +249,6 @@ func newScopeInfosFromScopeStackTraceLevelPairs(scopeStackTraceLevelPairs string
 	return scopeInfos, nil
 }
 
-type ControlzClient struct {
-	baseURL    *url.URL
-	httpClient *http.Client
-}
-
-func (c *ControlzClient) GetScopes() ([]*ScopeInfo, error) {
-	var scopeInfos []*ScopeInfo
-	resp, err := c.httpClient.Get(c.baseURL.String())
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request not successful %s", resp.Status)
-	}
-
-	err = json.NewDecoder(resp.Body).Decode(&scopeInfos)
-	if err != nil {
-		return nil, fmt.Errorf("cannot deserialize response %s", err)
-	}
-	return scopeInfos, nil
-}
-
-func (c *ControlzClient) PutScope(scope *ScopeInfo) error {
-	var jsonScopeInfo bytes.Buffer
-	err := json.NewEncoder(&jsonScopeInfo).Encode(scope)
-	if err != nil {
-		return fmt.Errorf("cannot serialize scope %+v", *scope)
-	}
-	req, err := http.NewRequest(http.MethodPut, c.baseURL.String()+"/"+scope.Name, &jsonScopeInfo)
-	if err != nil {
-		return err
-	}
-	defer req.Body.Close()
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusAccepted {
-		return fmt.Errorf("cannot update resource %s, got status %s", scope.Name, resp.Status)
-	}
-	return nil
-}
-
-func (c *ControlzClient) PutScopes(scopes []*ScopeInfo) error {
-	ch := make(chan struct {
-		err       error
-		scopeName string
-	}, len(scopes))
-	var wg sync.WaitGroup
-	for _, scope := range scopes {
-		wg.Add(1)
-		go func(si *ScopeInfo) {
-			defer wg.Done()
-			err := c.PutScope(si)
-			ch <- struct {
-				err       error
-				scopeName string
-			}{err: err, scopeName: si.Name}
-		}(scope)
-	}
-	wg.Wait()
-	close(ch)
-	for result := range ch {
-		if result.err != nil {
-			return fmt.Errorf("failed updating Scope %s: %v", result.scopeName, result.err)
-		}
-	}
-	return nil
-}
-
-func (c *ControlzClient) GetScope(scope string) (*ScopeInfo, error) {
-	var s ScopeInfo
-	resp, err := http.Get(c.baseURL.String() + "/" + scope)
-	if err != nil {
-		return &s, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return &s, fmt.Errorf("request not successful %s: ", resp.Status)
-	}
-
-	err = json.NewDecoder(resp.Body).Decode(&s)
-	if err != nil {
-		return &s, fmt.Errorf("cannot deserialize response: %s", err)
-	}
-	return &s, nil
-}
-
 var (
 	istiodLabelSelector = ""
 	istiodReset         = false
@@ -432,14 +329,7 @@ func istiodLogCmd() *cobra.Command {
 				return fmt.Errorf("could not start port forwarder for ControlZ %s: %v", podName, err)
 			}
 
-			ctrlzClient := &ControlzClient{
-				baseURL: &url.URL{
-					Scheme: "http",
-					Host:   portForwarder.Address(),
-					Path:   "scopej",
-				},
-				httpClient: &http.Client{},
-			}
+			ctrlzClient := istiodctl.NewClient(istiodctl.Options{ControlZAddress: portForwarder.Address()})
 			istiodConfigCmd := chooseClientFlag(ctrlzClient, istiodReset, outputLogLevel, stackTraceLevel, outputFormat)
 			output, err := istiodConfigCmd.execute()
 			if output != "" {
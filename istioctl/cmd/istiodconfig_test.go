@@ -22,6 +22,8 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+
+	"istio.io/istio/pkg/istiodctl"
 )
 
 func TestCtlPlaneConfig(t *testing.T) {
@@ -135,15 +137,10 @@ func Test_newScopeStackTraceLevelPair(t *testing.T) {
 }
 
 func Test_chooseClientFlag(t *testing.T) {
-	url, _ := url.Parse("http://localhost/scopej/resource")
-
-	ctrzClient := &ControlzClient{
-		baseURL:    url,
-		httpClient: &http.Client{},
-	}
+	ctrzClient := istiodctl.NewClient(istiodctl.Options{ControlZAddress: "localhost"})
 
 	type args struct {
-		ctrzClient      *ControlzClient
+		ctrzClient      *istiodctl.Client
 		reset           bool
 		outputLogLevel  string
 		stackTraceLevel string
@@ -239,10 +236,7 @@ func Test_flagState_run(t *testing.T) {
 	server, url := setupHTTPServer()
 	defer server.Close()
 
-	ctrzClientNoScopejHandler := &ControlzClient{
-		baseURL:    url,
-		httpClient: &http.Client{},
-	}
+	ctrzClientNoScopejHandler := istiodctl.NewClient(istiodctl.Options{ControlZAddress: url.Host})
 	tests := []struct {
 		name    string
 		state   flagState
@@ -0,0 +1,121 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	envoy_corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/istioctl/pkg/multixds"
+	"istio.io/istio/pilot/pkg/security/authn/mtlsreport"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func mtlsReportCmd() *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	var centralOpts clioptions.CentralControlPlaneOptions
+	var reportNamespace string
+
+	cmd := &cobra.Command{
+		Use:   "mtls-report",
+		Short: "Reports which workloads can safely move from PERMISSIVE to STRICT mTLS",
+		Long: `
+Reports, for each workload connected to Istiod, the mTLS mode its PeerAuthentication policies resolve to,
+and whether it only received mTLS inbound traffic. Workloads in PERMISSIVE mode that only received mTLS
+traffic are safe to move to STRICT.
+
+Inbound traffic is read by Istiod from Prometheus, configured with PILOT_MTLS_REPORT_PROMETHEUS_ADDRESS.
+Without it, only the mTLS mode of workloads is reported.
+`,
+		Example: `  # Report mTLS compatibility of all workloads
+  istioctl experimental mtls-report
+
+  # Report mTLS compatibility of the workloads in the foo namespace
+  istioctl experimental mtls-report --report-namespace foo`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			kubeClient, err := kubeClientWithRevision(kubeconfig, configContext, opts.Revision)
+			if err != nil {
+				return err
+			}
+			xdsRequest := xdsapi.DiscoveryRequest{
+				ResourceNames: []string{"mtls_compatibility"},
+				Node: &envoy_corev3.Node{
+					Id: "debug~0.0.0.0~istioctl~cluster.local",
+				},
+				TypeUrl: v3.DebugType,
+			}
+			xdsResponses, err := multixds.AllRequestAndProcessXds(&xdsRequest, centralOpts, istioNamespace, "", "", kubeClient)
+			if err != nil {
+				return err
+			}
+			return printMTLSReport(c.OutOrStdout(), xdsResponses, reportNamespace)
+		},
+	}
+
+	opts.AttachControlPlaneFlags(cmd)
+	centralOpts.AttachControlPlaneFlags(cmd)
+	cmd.Flags().StringVar(&reportNamespace, "report-namespace", "", "Only report the workloads in this namespace")
+	cmd.Long += "\n\n" + ExperimentalMsg
+	return cmd
+}
+
+// printMTLSReport merges the reports of all Istiod instances, as each only knows about the workloads
+// connected to it, and prints them as a table.
+func printMTLSReport(w io.Writer, responses map[string]*xdsapi.DiscoveryResponse, namespace string) error {
+	seen := map[mtlsreport.WorkloadKey]struct{}{}
+	var workloads []mtlsreport.WorkloadStatus
+	for _, response := range responses {
+		for _, resource := range response.Resources {
+			report := mtlsreport.Report{}
+			if err := json.Unmarshal(resource.Value, &report); err != nil {
+				return fmt.Errorf("failed to parse mTLS report: %v", err)
+			}
+			if report.Error != "" {
+				_, _ = fmt.Fprintf(w, "warning: failed to read traffic from %s: %s\n", report.TrafficSource, report.Error)
+			}
+			for _, status := range report.Workloads {
+				key := mtlsreport.WorkloadKey{Name: status.Name, Namespace: status.Namespace}
+				if _, f := seen[key]; f || (namespace != "" && status.Namespace != namespace) {
+					continue
+				}
+				seen[key] = struct{}{}
+				workloads = append(workloads, status)
+			}
+		}
+	}
+	sort.Slice(workloads, func(i, j int) bool {
+		if workloads[i].Namespace != workloads[j].Namespace {
+			return workloads[i].Namespace < workloads[j].Namespace
+		}
+		return workloads[i].Name < workloads[j].Name
+	})
+
+	tw := new(tabwriter.Writer).Init(w, 0, 8, 3, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAMESPACE\tWORKLOAD\tMODE\tMTLS\tPLAINTEXT\tSTRICT\tREASON")
+	for _, s := range workloads {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%.0f\t%.0f\t%s\t%s\n",
+			s.Namespace, s.Name, s.Mode, s.Traffic.MTLS, s.Traffic.Plaintext, s.Recommendation, s.Reason)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,68 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/security/authn/mtlsreport"
+)
+
+func TestPrintMTLSReport(t *testing.T) {
+	response := func(workloads ...mtlsreport.WorkloadStatus) *xdsapi.DiscoveryResponse {
+		b, err := json.Marshal(mtlsreport.Report{Workloads: workloads})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &xdsapi.DiscoveryResponse{Resources: []*anypb.Any{{Value: b}}}
+	}
+	reviews := mtlsreport.WorkloadStatus{
+		Name: "reviews-v1", Namespace: "default", Mode: "PERMISSIVE",
+		Traffic: mtlsreport.TrafficStats{MTLS: 12}, Recommendation: mtlsreport.SafeForStrict,
+	}
+	ratings := mtlsreport.WorkloadStatus{
+		Name: "ratings-v1", Namespace: "foo", Mode: "STRICT", Recommendation: mtlsreport.AlreadyStrict,
+	}
+	responses := map[string]*xdsapi.DiscoveryResponse{
+		"istiod-1": response(reviews, ratings),
+		"istiod-2": response(reviews),
+	}
+
+	out := &bytes.Buffer{}
+	if err := printMTLSReport(out, responses, ""); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header and 2 deduplicated workloads, got:\n%s", out.String())
+	}
+	if !strings.Contains(lines[1], "reviews-v1") || !strings.Contains(lines[1], "SAFE") {
+		t.Fatalf("expected reviews-v1 to be safe, got %q", lines[1])
+	}
+
+	out.Reset()
+	if err := printMTLSReport(out, responses, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "reviews-v1") || !strings.Contains(out.String(), "ratings-v1") {
+		t.Fatalf("expected only the foo namespace, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/istioctl/pkg/recommend"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pkg/config/constants"
+)
+
+// recommendSidecarStatsPath are the Istio standard metrics counting the requests and TCP connections, which the
+// default stats matcher of the proxies always includes.
+const recommendSidecarStatsPath = "stats/prometheus?filter=^istio_(requests|tcp_connections_opened)_total$"
+
+func recommendSidecarCmd() *cobra.Command {
+	var (
+		name     string
+		selector string
+		domain   string
+		files    []string
+	)
+	cmd := &cobra.Command{
+		Use:   "recommend-sidecar",
+		Short: "Recommends a Sidecar resource from the traffic observed by the proxies of a workload",
+		Long: `Recommends a Sidecar resource restricting the egress of a workload to the services it actually sent traffic to.
+
+The destinations are read from the Istio standard metrics of the running pods selected by the labels, or offline
+from files containing the Prometheus stats of the proxies or access logs. The metrics require telemetry to be enabled,
+as it is by default. The recommended Sidecar selects the workloads with the labels.
+Destinations unknown to the mesh, sent to the passthrough cluster, are not covered by the Sidecar.`,
+		Example: `  # Recommend a Sidecar from the stats of the reviews pods
+  istioctl experimental recommend-sidecar -l app=reviews -n default
+
+  # Recommend a Sidecar from Prometheus stats and access logs collected earlier
+  istioctl experimental recommend-sidecar -l app=reviews -n default -f stats.txt -f access.log`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 {
+				return fmt.Errorf("recommend-sidecar takes no arguments")
+			}
+			if selector == "" {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("recommend-sidecar requires a label selector")
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			workloadLabels, err := labels.ConvertSelectorToLabelsMap(selector)
+			if err != nil {
+				return fmt.Errorf("invalid label selector %q: %v", selector, err)
+			}
+			if name == "" {
+				if name = workloadLabels["app"]; name == "" {
+					return fmt.Errorf("--name is required when the selector has no app label")
+				}
+			}
+			ns := handlers.HandleNamespace(namespace, defaultNamespace)
+
+			deps := recommend.NewDependencies(domain)
+			if len(files) > 0 {
+				for _, f := range files {
+					data, err := readFile(f)
+					if err != nil {
+						return err
+					}
+					if err := deps.Observe(bytes.NewReader(data)); err != nil {
+						return fmt.Errorf("failed to read %s: %v", f, err)
+					}
+				}
+			} else if err := observePods(deps, selector, ns); err != nil {
+				return err
+			}
+
+			sidecar, err := deps.Sidecar(name, ns, workloadLabels)
+			if err != nil {
+				return err
+			}
+			obj, err := crd.ConvertConfig(sidecar)
+			if err != nil {
+				return err
+			}
+			out, err := yaml.Marshal(obj)
+			if err != nil {
+				return err
+			}
+			if deps.Passthrough {
+				fmt.Fprintln(c.ErrOrStderr(), "warning: the workload sent traffic to destinations unknown to the mesh, "+
+					"which are not covered by the recommended Sidecar")
+			}
+			_, err = c.OutOrStdout().Write(out)
+			return err
+		},
+	}
+	cmd.PersistentFlags().StringVarP(&selector, "selector", "l", "", "Label selector of the workload")
+	cmd.PersistentFlags().StringVar(&name, "name", "", "Name of the Sidecar, defaults to the app label of the selector")
+	cmd.PersistentFlags().StringVar(&domain, "domain", constants.DefaultKubernetesDomain, "DNS domain suffix of the mesh")
+	cmd.PersistentFlags().StringSliceVarP(&files, "file", "f", nil,
+		"Prometheus stats or access logs files to read instead of the stats of the running pods, or - for stdin")
+	return cmd
+}
+
+// observePods records the destinations in the Istio standard metrics of the pods selected by the labels.
+func observePods(deps *recommend.Dependencies, selector, ns string) error {
+	client, err := kubeClient(kubeconfig, configContext)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s client: %v", err)
+	}
+	pl, err := client.PodsForSelector(context.TODO(), ns, selector)
+	if err != nil {
+		return fmt.Errorf("not able to locate pod with selector %s: %v", selector, err)
+	}
+	if len(pl.Items) < 1 {
+		return fmt.Errorf("no pods found with selector %s in namespace %s", selector, ns)
+	}
+	for _, pod := range pl.Items {
+		stats, err := client.EnvoyDo(context.TODO(), pod.Name, pod.Namespace, "GET", recommendSidecarStatsPath)
+		if err != nil {
+			return fmt.Errorf("failed to execute command on %s.%s sidecar: %v", pod.Name, pod.Namespace, err)
+		}
+		if err := deps.Observe(bytes.NewReader(stats)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecommendSidecar(t *testing.T) {
+	cases := []struct {
+		description string
+		args        string
+		want        []string
+		wantErr     string
+	}{
+		{
+			description: "missing selector",
+			args:        "experimental recommend-sidecar -n default",
+			wantErr:     "recommend-sidecar requires a label selector",
+		},
+		{
+			description: "missing name",
+			args:        "experimental recommend-sidecar -l version=v1 -n default -f testdata/recommend-sidecar/stats.txt",
+			wantErr:     "--name is required",
+		},
+		{
+			description: "stats and access logs",
+			args: "experimental recommend-sidecar -l app=reviews -n default -f testdata/recommend-sidecar/stats.txt " +
+				"-f testdata/recommend-sidecar/access.log",
+			want: []string{
+				"warning: the workload sent traffic to destinations unknown to the mesh",
+				"name: reviews",
+				"'*/api.example.com'",
+				"default/ratings.default.svc.cluster.local",
+				"frontend/productpage.frontend.svc.cluster.local",
+				"app: reviews",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			out, err := runTestCmd(t, strings.Split(c.args, " "))
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("expected error %q, got %v", c.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range c.want {
+				if !strings.Contains(out, w) {
+					t.Errorf("expected %q in output:\n%s", w, out)
+				}
+			}
+			if strings.Contains(out, "details") {
+				t.Errorf("unexpected unused dependency in output:\n%s", out)
+			}
+		})
+	}
+}
@@ -110,6 +110,7 @@ func entryCommand() *cobra.Command {
 		Example: "entry configure -f workloadgroup.yaml -o outputDir",
 	}
 	entryCmd.AddCommand(configureCommand())
+	entryCmd.AddCommand(bootstrapCommand())
 	return entryCmd
 }
 
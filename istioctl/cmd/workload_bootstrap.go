@@ -0,0 +1,670 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	networkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	clientv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istioclient "istio.io/client-go/pkg/clientset/versioned"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/pkg/util/shellescape"
+	"istio.io/pkg/version"
+)
+
+// bootstrapStep is a step of the bootstrap of a host. The steps are run in the order they are declared.
+type bootstrapStep string
+
+const (
+	stepPending    bootstrapStep = ""
+	stepConfigured bootstrapStep = "configured"
+	stepInstalled  bootstrapStep = "installed"
+	stepCopied     bootstrapStep = "copied"
+	stepStarted    bootstrapStep = "started"
+	stepRegistered bootstrapStep = "registered"
+)
+
+var bootstrapSteps = []bootstrapStep{stepPending, stepConfigured, stepInstalled, stepCopied, stepStarted, stepRegistered}
+
+func (s bootstrapStep) before(o bootstrapStep) bool {
+	for _, step := range bootstrapSteps {
+		if step == o {
+			return false
+		}
+		if step == s {
+			return true
+		}
+	}
+	return false
+}
+
+// bootstrapHost is a host listed in the --hosts file.
+type bootstrapHost struct {
+	// Address is the IP address of the workload, used as the address of its WorkloadEntry.
+	Address string `json:"address"`
+	// Name is the name of the WorkloadEntry, defaulting to the WorkloadGroup name suffixed by the address.
+	Name string `json:"name,omitempty"`
+	// SSHAddress is the host[:port] to connect to, defaulting to the address.
+	SSHAddress string `json:"sshAddress,omitempty"`
+	// User is the SSH user, defaulting to --ssh-user.
+	User string `json:"user,omitempty"`
+	// Labels are added to the labels of the WorkloadGroup template.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// readBootstrapHosts reads the YAML list of hosts of the fleet.
+func readBootstrapHosts(filename string) ([]bootstrapHost, error) {
+	f, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var hosts []bootstrapHost
+	if err := yaml.Unmarshal(f, &hosts); err != nil {
+		return nil, fmt.Errorf("failed to parse hosts file %s: %v", filename, err)
+	}
+	seen := map[string]bool{}
+	for _, h := range hosts {
+		if net.ParseIP(h.Address) == nil {
+			return nil, fmt.Errorf("invalid address %q in hosts file %s", h.Address, filename)
+		}
+		if seen[h.Address] {
+			return nil, fmt.Errorf("duplicate address %q in hosts file %s", h.Address, filename)
+		}
+		seen[h.Address] = true
+	}
+	return hosts, nil
+}
+
+// hostState is the progress of the bootstrap of a host, persisted in the state file.
+type hostState struct {
+	Step    bootstrapStep `json:"step"`
+	Error   string        `json:"error,omitempty"`
+	Updated time.Time     `json:"updated"`
+}
+
+// bootstrapState persists the progress of the bootstrap of the fleet, so an interrupted or partially failed
+// bootstrap is resumed from the last completed step of each host.
+type bootstrapState struct {
+	mu    sync.Mutex
+	path  string
+	Hosts map[string]*hostState `json:"hosts"`
+}
+
+func loadBootstrapState(path string) (*bootstrapState, error) {
+	s := &bootstrapState{path: path, Hosts: map[string]*hostState{}}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %v", path, err)
+	}
+	if s.Hosts == nil {
+		s.Hosts = map[string]*hostState{}
+	}
+	return s, nil
+}
+
+func (s *bootstrapState) step(address string) bootstrapStep {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hs, f := s.Hosts[address]; f {
+		return hs.Step
+	}
+	return stepPending
+}
+
+// update records the progress of a host and writes the state file.
+func (s *bootstrapState) update(address string, step bootstrapStep, stepErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hs := &hostState{Step: step, Updated: time.Now()}
+	if stepErr != nil {
+		hs.Error = stepErr.Error()
+	}
+	s.Hosts[address] = hs
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write through a temporary file, so an interrupted write doesn't lose the state.
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// remoteHost runs the bootstrap commands on a host.
+type remoteHost interface {
+	// Upload writes the data to the path, creating the parent directories.
+	Upload(path string, data []byte) error
+	// Run runs the shell command and returns its combined output.
+	Run(cmd string) (string, error)
+	Close() error
+}
+
+// Files of the VM artifacts generated by createConfig, by their destination on the host.
+var bootstrapFiles = map[string]string{
+	"/etc/certs/root-cert.pem":            "root-cert.pem",
+	"/var/run/secrets/tokens/istio-token": "istio-token",
+	"/var/lib/istio/envoy/cluster.env":    "cluster.env",
+	"/etc/istio/config/mesh":              "mesh.yaml",
+	"/etc/istio/config/hosts":             "hosts",
+}
+
+// fleetBootstrapper bootstraps the workloads of a WorkloadGroup on a fleet of hosts.
+type fleetBootstrapper struct {
+	wg *clientv1alpha3.WorkloadGroup
+	// configure generates the VM artifacts of a host in the directory.
+	configure func(host bootstrapHost, dir string) error
+	dial      func(host bootstrapHost) (remoteHost, error)
+	// istio registers the WorkloadEntries, nil if they are auto registered.
+	istio       istioclient.Interface
+	packageURL  string
+	skipInstall bool
+	outputDir   string
+	parallel    int
+	state       *bootstrapState
+	out         io.Writer
+	outMu       sync.Mutex
+}
+
+func (b *fleetBootstrapper) printf(format string, a ...interface{}) {
+	b.outMu.Lock()
+	defer b.outMu.Unlock()
+	fmt.Fprintf(b.out, format, a...)
+}
+
+// run bootstraps the hosts in parallel. Each host is resumed from its last completed step.
+func (b *fleetBootstrapper) run(hosts []bootstrapHost) error {
+	parallel := b.parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	work := make(chan bootstrapHost)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []string
+	)
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range work {
+				if err := b.bootstrap(host); err != nil {
+					b.printf("%s: failed: %v\n", host.Address, err)
+					mu.Lock()
+					failed = append(failed, host.Address)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, host := range hosts {
+		work <- host
+	}
+	close(work)
+	wg.Wait()
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("%d of %d hosts failed to bootstrap (%s), rerun the command to resume from the state file %s",
+			len(failed), len(hosts), strings.Join(failed, ", "), b.state.path)
+	}
+	b.printf("Bootstrapped %d hosts\n", len(hosts))
+	return nil
+}
+
+// bootstrap runs the remaining steps of a host, recording each completed step in the state file.
+func (b *fleetBootstrapper) bootstrap(host bootstrapHost) error {
+	current := b.state.step(host.Address)
+	if current == stepRegistered {
+		b.printf("%s: already bootstrapped, skipping\n", host.Address)
+		return nil
+	}
+	// The token generated by a previous run may have expired, so a resumed host which was not started yet is
+	// configured again, and the refreshed configuration is copied, before reconnecting to it.
+	refresh := current != stepPending && current.before(stepStarted)
+	if refresh && stepInstalled.before(current) {
+		current = stepInstalled
+	}
+	dir := filepath.Join(b.outputDir, host.Address)
+	var remote remoteHost
+	defer func() {
+		if remote != nil {
+			_ = remote.Close()
+		}
+	}()
+	steps := []struct {
+		step bootstrapStep
+		run  func() error
+	}{
+		{stepConfigured, func() error {
+			if err := os.MkdirAll(dir, filePerms); err != nil {
+				return err
+			}
+			return b.configure(host, dir)
+		}},
+		{stepInstalled, func() error {
+			if b.skipInstall {
+				return nil
+			}
+			return b.install(remote)
+		}},
+		{stepCopied, func() error { return b.copy(remote, dir) }},
+		{stepStarted, func() error { return b.start(remote) }},
+		{stepRegistered, func() error { return b.register(host) }},
+	}
+	for _, s := range steps {
+		if !current.before(s.step) && !(refresh && s.step == stepConfigured) {
+			continue
+		}
+		if remote == nil && s.step != stepConfigured && s.step != stepRegistered {
+			var err error
+			if remote, err = b.dial(host); err != nil {
+				err = fmt.Errorf("failed to connect: %v", err)
+				_ = b.state.update(host.Address, current, err)
+				return err
+			}
+		}
+		if err := s.run(); err != nil {
+			err = fmt.Errorf("%s step failed: %v", s.step, err)
+			if serr := b.state.update(host.Address, current, err); serr != nil {
+				return fmt.Errorf("%v (failed to write state file: %v)", err, serr)
+			}
+			return err
+		}
+		if current.before(s.step) {
+			current = s.step
+		}
+		if err := b.state.update(host.Address, current, nil); err != nil {
+			return fmt.Errorf("failed to write state file: %v", err)
+		}
+		b.printf("%s: %s\n", host.Address, current)
+	}
+	return nil
+}
+
+func (b *fleetBootstrapper) install(remote remoteHost) error {
+	pkg := "/tmp/" + path.Base(b.packageURL)
+	installer := "dpkg -i"
+	if strings.HasSuffix(pkg, ".rpm") {
+		installer = "rpm -Uvh --replacepkgs"
+	}
+	cmd := fmt.Sprintf("curl -fsSL -o %s %s && sudo %s %s",
+		shellescape.Quote(pkg), shellescape.Quote(b.packageURL), installer, shellescape.Quote(pkg))
+	if out, err := remote.Run(cmd); err != nil {
+		return fmt.Errorf("%v: %s", err, out)
+	}
+	return nil
+}
+
+func (b *fleetBootstrapper) copy(remote remoteHost, dir string) error {
+	dests := make([]string, 0, len(bootstrapFiles))
+	for dest := range bootstrapFiles {
+		dests = append(dests, dest)
+	}
+	sort.Strings(dests)
+	for _, dest := range dests {
+		data, err := os.ReadFile(filepath.Join(dir, bootstrapFiles[dest]))
+		if err != nil {
+			return err
+		}
+		if err := remote.Upload(dest, data); err != nil {
+			return fmt.Errorf("failed to upload %s: %v", dest, err)
+		}
+	}
+	// Only append the host entries missing from /etc/hosts, so the step can be rerun.
+	cmd := "sudo sh -c 'grep -vxF -f /etc/hosts /etc/istio/config/hosts >> /etc/hosts || true' && " +
+		"sudo mkdir -p /etc/istio/proxy && " +
+		"sudo chown -R istio-proxy /var/lib/istio /etc/certs /etc/istio/proxy /etc/istio/config /var/run/secrets"
+	if out, err := remote.Run(cmd); err != nil {
+		return fmt.Errorf("%v: %s", err, out)
+	}
+	return nil
+}
+
+func (b *fleetBootstrapper) start(remote remoteHost) error {
+	// Restart rather than start, so a rerun picks up the new configuration.
+	if out, err := remote.Run("sudo systemctl enable istio && sudo systemctl restart istio"); err != nil {
+		return fmt.Errorf("%v: %s", err, out)
+	}
+	return nil
+}
+
+// register creates or updates the WorkloadEntry of the host from the WorkloadGroup template.
+func (b *fleetBootstrapper) register(host bootstrapHost) error {
+	if b.istio == nil {
+		return nil
+	}
+	template := b.wg.Spec.Template
+	labels := map[string]string{}
+	annotations := map[string]string{}
+	if b.wg.Spec.Metadata != nil {
+		for k, v := range b.wg.Spec.Metadata.Labels {
+			labels[k] = v
+		}
+		for k, v := range b.wg.Spec.Metadata.Annotations {
+			annotations[k] = v
+		}
+	}
+	for k, v := range host.Labels {
+		labels[k] = v
+	}
+	we := &clientv1alpha3.WorkloadEntry{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        workloadEntryName(b.wg.Name, host),
+			Namespace:   b.wg.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: networkingv1alpha3.WorkloadEntry{
+			Address:        host.Address,
+			Ports:          template.Ports,
+			Labels:         labels,
+			Network:        template.Network,
+			Locality:       template.Locality,
+			Weight:         template.Weight,
+			ServiceAccount: template.ServiceAccount,
+		},
+	}
+	client := b.istio.NetworkingV1alpha3().WorkloadEntries(b.wg.Namespace)
+	existing, err := client.Get(context.TODO(), we.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		_, err = client.Create(context.TODO(), we, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	we.ResourceVersion = existing.ResourceVersion
+	_, err = client.Update(context.TODO(), we, metav1.UpdateOptions{})
+	return err
+}
+
+func workloadEntryName(group string, host bootstrapHost) string {
+	if host.Name != "" {
+		return host.Name
+	}
+	return group + "-" + strings.NewReplacer(".", "-", ":", "-").Replace(host.Address)
+}
+
+// sshRemoteHost runs the bootstrap commands over SSH.
+type sshRemoteHost struct {
+	client *ssh.Client
+}
+
+func (r *sshRemoteHost) Upload(dest string, data []byte) error {
+	session, err := r.client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	session.Stdin = bytes.NewReader(data)
+	cmd := fmt.Sprintf("sudo mkdir -p %s && sudo tee %s > /dev/null", shellescape.Quote(path.Dir(dest)), shellescape.Quote(dest))
+	if out, err := session.CombinedOutput(cmd); err != nil {
+		return fmt.Errorf("%v: %s", err, out)
+	}
+	return nil
+}
+
+func (r *sshRemoteHost) Run(cmd string) (string, error) {
+	session, err := r.client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	out, err := session.CombinedOutput(cmd)
+	return string(out), err
+}
+
+func (r *sshRemoteHost) Close() error {
+	return r.client.Close()
+}
+
+type sshOptions struct {
+	user                  string
+	keyFile               string
+	knownHostsFile        string
+	insecureIgnoreHostKey bool
+	timeout               time.Duration
+}
+
+// sshDialer returns the function connecting to the hosts. Keys are read from the key file, if set, and the
+// SSH agent, if running.
+func sshDialer(opts sshOptions) (func(host bootstrapHost) (remoteHost, error), error) {
+	var auth []ssh.AuthMethod
+	if opts.keyFile != "" {
+		key, err := os.ReadFile(opts.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SSH key %s: %v", opts.keyFile, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to the SSH agent: %v", err)
+		}
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("expecting an SSH key through --ssh-key or the SSH agent")
+	}
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if !opts.insecureIgnoreHostKey {
+		var err error
+		if hostKeyCallback, err = knownhosts.New(opts.knownHostsFile); err != nil {
+			return nil, fmt.Errorf("failed to read known hosts %s: %v", opts.knownHostsFile, err)
+		}
+	}
+	return func(host bootstrapHost) (remoteHost, error) {
+		user := host.User
+		if user == "" {
+			user = opts.user
+		}
+		addr := host.SSHAddress
+		if addr == "" {
+			addr = host.Address
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "22")
+		}
+		client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+			User:            user,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         opts.timeout,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &sshRemoteHost{client: client}, nil
+	}, nil
+}
+
+func defaultSidecarPackageURL() string {
+	return fmt.Sprintf("https://storage.googleapis.com/istio-release/releases/%s/deb/istio-sidecar.deb", version.Info.Version)
+}
+
+func bootstrapCommand() *cobra.Command {
+	var (
+		opts        clioptions.ControlPlaneOptions
+		sshOpts     sshOptions
+		hostsFile   string
+		stateFile   string
+		packageURL  string
+		skipInstall bool
+		parallel    int
+	)
+	home, _ := os.UserHomeDir()
+
+	bootstrapCmd := &cobra.Command{
+		Use:   "bootstrap",
+		Short: "Onboards a fleet of VMs or bare-metal hosts into the mesh over SSH",
+		Long: `Onboards a fleet of VMs or bare-metal hosts into the mesh over SSH, from a WorkloadGroup artifact.
+For each host, bootstrap generates the configuration files as configure does, installs the sidecar package,
+copies the configuration files, starts the istio service and registers the WorkloadEntry of the host,
+unless --autoregister is set. Hosts are bootstrapped in parallel. The progress of each host is recorded in
+the state file, so rerunning the command resumes the bootstrap from the last completed step of each host.
+The configuration of a resumed host which was not started yet is generated again, so its token is fresh.
+
+The hosts file is a YAML list of hosts:
+
+  - address: 10.0.0.1           # IP address of the workload
+    sshAddress: vm-1.example:22 # optional, defaults to the address
+    user: ubuntu                # optional, defaults to --ssh-user
+    name: vm-1                  # optional, the name of the WorkloadEntry
+    labels:                     # optional, added to the WorkloadGroup labels
+      zone: a`,
+		Example: `  # bootstrap the hosts using a local WorkloadGroup artifact
+  bootstrap -f workloadgroup.yaml --hosts hosts.yaml -o config --ssh-user ubuntu --ssh-key ~/.ssh/id_rsa
+
+  # bootstrap the hosts using the API server, 50 at a time
+  bootstrap --name foo --namespace bar --hosts hosts.yaml -o config --parallel 50`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if filename == "" && (name == "" || namespace == "") {
+				return fmt.Errorf("expecting a WorkloadGroup artifact file or the name and namespace of an existing WorkloadGroup")
+			}
+			if outputDir == "" {
+				return fmt.Errorf("expecting an output directory")
+			}
+			if hostsFile == "" {
+				return fmt.Errorf("expecting a hosts file")
+			}
+			if packageURL == "" && !skipInstall {
+				return fmt.Errorf("expecting a sidecar package URL")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hosts, err := readBootstrapHosts(hostsFile)
+			if err != nil {
+				return err
+			}
+			kubeClient, err := kubeClientWithRevision(kubeconfig, configContext, opts.Revision)
+			if err != nil {
+				return err
+			}
+			wg := &clientv1alpha3.WorkloadGroup{}
+			if filename != "" {
+				if err := readWorkloadGroup(filename, wg); err != nil {
+					return err
+				}
+			} else {
+				wg, err = kubeClient.Istio().NetworkingV1alpha3().WorkloadGroups(namespace).Get(context.Background(), name, metav1.GetOptions{})
+				if err != nil {
+					return fmt.Errorf("workloadgroup %s not found in namespace %s: %v", name, namespace, err)
+				}
+			}
+			if !validateFlagIsSetManuallyOrNot(cmd, "clusterID") {
+				clusterName, err := extractClusterIDFromInjectionConfig(kubeClient)
+				if err != nil {
+					return fmt.Errorf("failed to automatically determine the --clusterID: %v", err)
+				}
+				if clusterName != "" {
+					clusterID = clusterName
+				}
+			}
+			if err := os.MkdirAll(outputDir, filePerms); err != nil {
+				return err
+			}
+			if stateFile == "" {
+				stateFile = filepath.Join(outputDir, "bootstrap-state.json")
+			}
+			state, err := loadBootstrapState(stateFile)
+			if err != nil {
+				return err
+			}
+			dial, err := sshDialer(sshOpts)
+			if err != nil {
+				return err
+			}
+			b := &fleetBootstrapper{
+				wg: wg,
+				configure: func(host bootstrapHost, dir string) error {
+					return createConfig(kubeClient, wg, clusterID, ingressIP, host.Address, "", dir, io.Discard)
+				},
+				dial:        dial,
+				packageURL:  packageURL,
+				skipInstall: skipInstall,
+				outputDir:   outputDir,
+				parallel:    parallel,
+				state:       state,
+				out:         cmd.OutOrStdout(),
+			}
+			if !autoRegister {
+				b.istio = kubeClient.Istio()
+			}
+			return b.run(hosts)
+		},
+	}
+	bootstrapCmd.PersistentFlags().StringVarP(&filename, "file", "f", "", "filename of the WorkloadGroup artifact. Leave this field empty if using the API server")
+	bootstrapCmd.PersistentFlags().StringVar(&name, "name", "", "The name of the workload group")
+	bootstrapCmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", "", "The namespace that the workload instances belongs to")
+	bootstrapCmd.PersistentFlags().StringVarP(&outputDir, "output", "o", "", "Output directory for the generated files of each host")
+	bootstrapCmd.PersistentFlags().StringVar(&hostsFile, "hosts", "", "The YAML file listing the hosts to bootstrap")
+	bootstrapCmd.PersistentFlags().StringVar(&stateFile, "state-file", "",
+		"The file recording the progress of each host, used to resume the bootstrap (default: bootstrap-state.json in the output directory)")
+	bootstrapCmd.PersistentFlags().IntVar(&parallel, "parallel", 10, "The number of hosts bootstrapped in parallel")
+	bootstrapCmd.PersistentFlags().StringVar(&packageURL, "package-url", defaultSidecarPackageURL(),
+		"The URL of the istio-sidecar .deb or .rpm package installed on the hosts")
+	bootstrapCmd.PersistentFlags().BoolVar(&skipInstall, "skip-install", false, "Skips the installation of the sidecar package, already installed on the hosts")
+	bootstrapCmd.PersistentFlags().StringVar(&clusterID, "clusterID", "", "The ID used to identify the cluster")
+	bootstrapCmd.PersistentFlags().Int64Var(&tokenDuration, "tokenDuration", 3600, "The token duration in seconds (default: 1 hour)")
+	bootstrapCmd.PersistentFlags().StringVar(&ingressIP, "ingressIP", "", "IP address of the ingress gateway")
+	bootstrapCmd.PersistentFlags().BoolVar(&autoRegister, "autoregister", false,
+		"Creates the WorkloadEntries upon connection to istiod (if enabled in pilot), instead of registering them")
+	bootstrapCmd.PersistentFlags().BoolVar(&dnsCapture, "capture-dns", true, "Enables the capture of outgoing DNS packets on port 53, redirecting to istio-agent")
+	bootstrapCmd.PersistentFlags().StringVar(&sshOpts.user, "ssh-user", os.Getenv("USER"), "The SSH user, for the hosts not setting one")
+	bootstrapCmd.PersistentFlags().StringVar(&sshOpts.keyFile, "ssh-key", "", "The SSH private key file. Keys of the SSH agent are also used")
+	bootstrapCmd.PersistentFlags().StringVar(&sshOpts.knownHostsFile, "ssh-known-hosts", filepath.Join(home, ".ssh", "known_hosts"),
+		"The known hosts file verifying the host keys")
+	bootstrapCmd.PersistentFlags().BoolVar(&sshOpts.insecureIgnoreHostKey, "ssh-insecure-ignore-host-key", false,
+		"Skips the verification of the host keys. Insecure, only use in test environments")
+	bootstrapCmd.PersistentFlags().DurationVar(&sshOpts.timeout, "ssh-timeout", 30*time.Second, "The timeout of the SSH connections")
+	opts.AttachControlPlaneFlags(bootstrapCmd)
+	return bootstrapCmd
+}
@@ -0,0 +1,212 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkingv1alpha3 "istio.io/api/networking/v1alpha3"
+	clientv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+)
+
+type fakeRemoteHost struct {
+	mu       sync.Mutex
+	uploads  map[string]string
+	commands []string
+	// failOn fails the commands containing it.
+	failOn string
+}
+
+func (f *fakeRemoteHost) Upload(path string, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.uploads[path] = string(data)
+	return nil
+}
+
+func (f *fakeRemoteHost) Run(cmd string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failOn != "" && strings.Contains(cmd, f.failOn) {
+		return "error output", errors.New("exit status 1")
+	}
+	f.commands = append(f.commands, cmd)
+	return "", nil
+}
+
+func (f *fakeRemoteHost) Close() error {
+	return nil
+}
+
+func TestWorkloadEntryBootstrap(t *testing.T) {
+	dir := t.TempDir()
+	hostsFile := filepath.Join(dir, "hosts.yaml")
+	if err := os.WriteFile(hostsFile, []byte(`
+- address: 10.0.0.1
+- address: 10.0.0.2
+  name: vm-2
+  labels:
+    zone: b
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := readBootstrapHosts(hostsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remotes := map[string]*fakeRemoteHost{
+		"10.0.0.1": {uploads: map[string]string{}},
+		"10.0.0.2": {uploads: map[string]string{}, failOn: "systemctl"},
+	}
+	var mu sync.Mutex
+	configured := map[string]int{}
+	istio := istiofake.NewSimpleClientset()
+	newBootstrapper := func(state *bootstrapState) *fleetBootstrapper {
+		return &fleetBootstrapper{
+			wg: &clientv1alpha3.WorkloadGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"},
+				Spec: networkingv1alpha3.WorkloadGroup{
+					Metadata: &networkingv1alpha3.WorkloadGroup_ObjectMeta{Labels: map[string]string{"app": "foo"}},
+					Template: &networkingv1alpha3.WorkloadEntry{ServiceAccount: "vm-serviceaccount", Ports: map[string]uint32{"http": 8080}},
+				},
+			},
+			configure: func(host bootstrapHost, dir string) error {
+				mu.Lock()
+				configured[host.Address]++
+				run := configured[host.Address]
+				mu.Unlock()
+				for _, f := range bootstrapFiles {
+					data := fmt.Sprintf("%s of %s #%d", f, host.Address, run)
+					if err := os.WriteFile(filepath.Join(dir, f), []byte(data), 0o644); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			dial: func(host bootstrapHost) (remoteHost, error) {
+				return remotes[host.Address], nil
+			},
+			istio:      istio,
+			packageURL: "https://example.com/istio-sidecar.rpm",
+			outputDir:  dir,
+			parallel:   2,
+			state:      state,
+			out:        &bytes.Buffer{},
+		}
+	}
+
+	stateFile := filepath.Join(dir, "state.json")
+	state, err := loadBootstrapState(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := newBootstrapper(state).run(hosts); err == nil || !strings.Contains(err.Error(), "1 of 2 hosts failed to bootstrap (10.0.0.2)") {
+		t.Fatalf("expected the second host to fail, got %v", err)
+	}
+
+	// The first host is fully bootstrapped.
+	first := remotes["10.0.0.1"]
+	if first.uploads["/var/lib/istio/envoy/cluster.env"] != "cluster.env of 10.0.0.1 #1" || len(first.uploads) != len(bootstrapFiles) {
+		t.Fatalf("unexpected uploads %v", first.uploads)
+	}
+	if len(first.commands) != 3 || !strings.Contains(first.commands[0], "rpm -Uvh") {
+		t.Fatalf("unexpected commands %v", first.commands)
+	}
+	we, err := istio.NetworkingV1alpha3().WorkloadEntries("bar").Get(context.TODO(), "foo-10-0-0-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if we.Spec.Address != "10.0.0.1" || we.Spec.ServiceAccount != "vm-serviceaccount" || we.Spec.Labels["app"] != "foo" {
+		t.Fatalf("unexpected workload entry %v", we.Spec)
+	}
+
+	// The progress of the failed host is persisted.
+	state, err = loadBootstrapState(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Hosts["10.0.0.1"].Step != stepRegistered {
+		t.Fatalf("expected the first host to be registered, got %+v", state.Hosts["10.0.0.1"])
+	}
+	if hs := state.Hosts["10.0.0.2"]; hs.Step != stepCopied || !strings.Contains(hs.Error, "started step failed") {
+		t.Fatalf("expected the second host to fail to start, got %+v", hs)
+	}
+
+	// Rerunning resumes the failed host, with a refreshed token as it was not started, and skips the bootstrapped host.
+	remotes["10.0.0.2"].failOn = ""
+	if err := newBootstrapper(state).run(hosts); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"10.0.0.1": 1, "10.0.0.2": 2}; !reflect.DeepEqual(configured, want) {
+		t.Fatalf("expected the resumed host to be configured again, got %v", configured)
+	}
+	if len(first.commands) != 3 {
+		t.Fatalf("expected the bootstrapped host to be skipped, got %v", first.commands)
+	}
+	second := remotes["10.0.0.2"]
+	if token := second.uploads["/var/run/secrets/tokens/istio-token"]; token != "istio-token of 10.0.0.2 #2" {
+		t.Fatalf("expected the refreshed token to be copied, got %q", token)
+	}
+	// The package is not installed again, only the configuration is copied again before starting.
+	if cmds := second.commands; len(cmds) != 4 || !strings.Contains(cmds[0], "rpm -Uvh") ||
+		strings.Contains(cmds[2], "rpm -Uvh") || !strings.Contains(cmds[3], "systemctl") {
+		t.Fatalf("unexpected commands %v", cmds)
+	}
+	we, err = istio.NetworkingV1alpha3().WorkloadEntries("bar").Get(context.TODO(), "vm-2", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if we.Spec.Labels["zone"] != "b" || we.Spec.Labels["app"] != "foo" {
+		t.Fatalf("unexpected workload entry labels %v", we.Spec.Labels)
+	}
+}
+
+func TestReadBootstrapHostsInvalid(t *testing.T) {
+	cases := map[string]string{
+		"invalid address":   "- address: vm-1",
+		"duplicate address": "- address: 10.0.0.1\n- address: 10.0.0.1",
+	}
+	for name, hosts := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := filepath.Join(t.TempDir(), "hosts.yaml")
+			if err := os.WriteFile(f, []byte(hosts), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := readBootstrapHosts(f); err == nil || !strings.Contains(err.Error(), name) {
+				t.Fatalf("expected %s error, got %v", name, err)
+			}
+		})
+	}
+}
+
+func TestWorkloadEntryBootstrapInvalidArgs(t *testing.T) {
+	cmd := []string{"x", "workload", "entry", "bootstrap", "-f", "workloadgroup.yaml", "-o", "out"}
+	if output, err := runTestCmd(t, cmd); err == nil || !strings.Contains(output, "expecting a hosts file") {
+		t.Fatalf("expected missing hosts file error, got %v: %s", err, output)
+	}
+}
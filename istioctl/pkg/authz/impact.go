@@ -0,0 +1,407 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"istio.io/api/annotation"
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	authpb "istio.io/api/security/v1beta1"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// Flow is a request, or a TCP connection if it has no method, observed by a workload. Flows are read from JSON lines,
+// recorded from the access logs or derived from the metrics of the workloads.
+type Flow struct {
+	SourcePrincipal      string            `json:"sourcePrincipal,omitempty"`
+	SourceNamespace      string            `json:"sourceNamespace,omitempty"`
+	SourceIP             string            `json:"sourceIP,omitempty"`
+	RequestPrincipal     string            `json:"requestPrincipal,omitempty"`
+	DestinationNamespace string            `json:"destinationNamespace"`
+	DestinationLabels    map[string]string `json:"destinationLabels,omitempty"`
+	DestinationPort      int               `json:"destinationPort,omitempty"`
+	Host                 string            `json:"host,omitempty"`
+	Method               string            `json:"method,omitempty"`
+	Path                 string            `json:"path,omitempty"`
+	Headers              map[string]string `json:"headers,omitempty"`
+}
+
+func (f Flow) String() string {
+	source := f.SourcePrincipal
+	if source == "" {
+		source = f.SourceIP
+	}
+	if source == "" {
+		source = "unknown"
+	}
+	destination := fmt.Sprintf("%s:%d", f.DestinationNamespace, f.DestinationPort)
+	if len(f.DestinationLabels) > 0 {
+		destination = fmt.Sprintf("%s[%s]:%d", f.DestinationNamespace, labels.Instance(f.DestinationLabels), f.DestinationPort)
+	}
+	if f.Method == "" {
+		return fmt.Sprintf("%s -> %s TCP", source, destination)
+	}
+	return fmt.Sprintf("%s -> %s %s %s%s", source, destination, f.Method, f.Host, f.Path)
+}
+
+// ReadFlows reads the flows from JSON lines. Empty lines are skipped.
+func ReadFlows(r io.Reader) ([]Flow, error) {
+	var flows []Flow
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var f Flow
+		if err := json.Unmarshal([]byte(text), &f); err != nil {
+			return nil, fmt.Errorf("invalid flow at line %d: %v", line, err)
+		}
+		flows = append(flows, f)
+	}
+	return flows, scanner.Err()
+}
+
+// Decision is the outcome of the authorization of a flow.
+type Decision string
+
+const (
+	Allowed Decision = "ALLOW"
+	Denied  Decision = "DENY"
+	// Unknown decisions depend on an external authorizer, or on conditions which cannot be evaluated from the flow.
+	Unknown Decision = "UNKNOWN"
+)
+
+// Result is the decision for a flow, and the policy which made it.
+type Result struct {
+	Decision Decision
+	// Policy is the namespace/name of the deciding policy, empty for the default decision.
+	Policy string
+	Reason string
+}
+
+// NewPolicies returns the authorization policies of the configs, for a mesh with the root namespace.
+func NewPolicies(rootNamespace string, configs []config.Config) (*model.AuthorizationPolicies, error) {
+	store := memory.Make(collection.SchemasFor(collections.IstioSecurityV1Beta1Authorizationpolicies))
+	for _, cfg := range configs {
+		if _, err := store.Create(cfg); err != nil {
+			return nil, fmt.Errorf("invalid authorization policy %s/%s: %v", cfg.Namespace, cfg.Name, err)
+		}
+	}
+	return model.GetAuthorizationPolicies(&model.Environment{
+		IstioConfigStore: model.MakeIstioStore(store),
+		Watcher:          mesh.NewFixedWatcher(&meshconfig.MeshConfig{RootNamespace: rootNamespace}),
+	})
+}
+
+// Evaluate returns the decision of the policies for the flow. The policies are evaluated like the proxies do: emergency
+// lockdown policies first, then CUSTOM, DENY and ALLOW policies. Dry-run and AUDIT policies do not change decisions.
+func Evaluate(policies *model.AuthorizationPolicies, f Flow) Result {
+	applied := policies.ListAuthorizationPolicies(f.DestinationNamespace, labels.Collection{f.DestinationLabels})
+
+	var lockdownAllow []model.AuthorizationPolicy
+	for _, p := range applied.Lockdown {
+		if p.Spec.GetAction() == authpb.AuthorizationPolicy_ALLOW {
+			lockdownAllow = append(lockdownAllow, p)
+		}
+	}
+	if r, matched := firstMatch(applied.Lockdown, f, authpb.AuthorizationPolicy_DENY); matched {
+		return r
+	}
+	if len(lockdownAllow) > 0 {
+		r, matched := firstMatch(lockdownAllow, f, authpb.AuthorizationPolicy_ALLOW)
+		if !matched {
+			return Result{Decision: Denied, Reason: "no emergency lockdown ALLOW policy matched"}
+		}
+		if r.Decision == Unknown {
+			return r
+		}
+	}
+	if r, matched := firstMatch(applied.Custom, f, authpb.AuthorizationPolicy_CUSTOM); matched {
+		r.Decision = Unknown
+		r.Reason = "decided by the external authorizer"
+		return r
+	}
+	if r, matched := firstMatch(applied.Deny, f, authpb.AuthorizationPolicy_DENY); matched {
+		return r
+	}
+	allow := enforced(applied.Allow)
+	if len(allow) == 0 {
+		return Result{Decision: Allowed, Reason: "no ALLOW policy applied"}
+	}
+	if r, matched := firstMatch(allow, f, authpb.AuthorizationPolicy_ALLOW); matched {
+		return r
+	}
+	return Result{Decision: Denied, Reason: "no ALLOW policy matched"}
+}
+
+// enforced returns the policies which are not in dry-run.
+func enforced(policies []model.AuthorizationPolicy) []model.AuthorizationPolicy {
+	var out []model.AuthorizationPolicy
+	for _, p := range policies {
+		if p.Annotations[annotation.IoIstioDryRun.Name] != "true" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// firstMatch returns the result of the first enforced policy with the action matching the flow. Policies whose
+// conditions cannot be evaluated match with an unknown decision.
+func firstMatch(policies []model.AuthorizationPolicy, f Flow, action authpb.AuthorizationPolicy_Action) (Result, bool) {
+	for _, p := range enforced(policies) {
+		if p.Spec.GetAction() != action {
+			continue
+		}
+		name := p.Namespace + "/" + p.Name
+		matched, err := policyMatches(p.Spec, f)
+		if err != nil {
+			return Result{Decision: Unknown, Policy: name, Reason: err.Error()}, true
+		}
+		if matched {
+			decision := Allowed
+			if action == authpb.AuthorizationPolicy_DENY {
+				decision = Denied
+			}
+			return Result{Decision: decision, Policy: name}, true
+		}
+	}
+	return Result{}, false
+}
+
+func policyMatches(spec *authpb.AuthorizationPolicy, f Flow) (bool, error) {
+	// For TCP flows, the HTTP-only fields of ALLOW rules never match, and those of DENY rules are ignored.
+	tcpMatch := spec.GetAction() == authpb.AuthorizationPolicy_DENY
+	for _, rule := range spec.GetRules() {
+		matched, err := ruleMatches(rule, f, tcpMatch)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+func ruleMatches(rule *authpb.Rule, f Flow, tcpMatch bool) (bool, error) {
+	tcp := f.Method == ""
+	httpMatch := func(values, notValues []string, v string, fold bool) bool {
+		if tcp && (len(values) > 0 || len(notValues) > 0) {
+			return tcpMatch
+		}
+		return fieldMatches(values, notValues, v, fold)
+	}
+
+	if len(rule.From) > 0 {
+		matched := false
+		for _, from := range rule.From {
+			s := from.GetSource()
+			if fieldMatches(s.GetPrincipals(), s.GetNotPrincipals(), f.SourcePrincipal, false) &&
+				fieldMatches(s.GetNamespaces(), s.GetNotNamespaces(), f.SourceNamespace, false) &&
+				ipMatches(s.GetIpBlocks(), s.GetNotIpBlocks(), f.SourceIP) &&
+				ipMatches(s.GetRemoteIpBlocks(), s.GetNotRemoteIpBlocks(), f.SourceIP) &&
+				httpMatch(s.GetRequestPrincipals(), s.GetNotRequestPrincipals(), f.RequestPrincipal, false) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	if len(rule.To) > 0 {
+		matched := false
+		for _, to := range rule.To {
+			o := to.GetOperation()
+			if httpMatch(o.GetHosts(), o.GetNotHosts(), f.Host, true) &&
+				fieldMatches(o.GetPorts(), o.GetNotPorts(), strconv.Itoa(f.DestinationPort), false) &&
+				httpMatch(o.GetMethods(), o.GetNotMethods(), f.Method, false) &&
+				httpMatch(o.GetPaths(), o.GetNotPaths(), f.Path, false) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	for _, when := range rule.When {
+		v, httpOnly, err := conditionValue(when.GetKey(), f)
+		if err != nil {
+			return false, err
+		}
+		if httpOnly && tcp {
+			if !tcpMatch {
+				return false, nil
+			}
+			continue
+		}
+		if !fieldMatches(when.GetValues(), when.GetNotValues(), v, false) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// conditionValue returns the value of the condition key for the flow, and whether the key only applies to HTTP.
+func conditionValue(key string, f Flow) (string, bool, error) {
+	if strings.HasPrefix(key, "request.headers[") && strings.HasSuffix(key, "]") {
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "request.headers["), "]")
+		for k, v := range f.Headers {
+			if strings.EqualFold(k, name) {
+				return v, true, nil
+			}
+		}
+		return "", true, nil
+	}
+	switch key {
+	case "source.ip", "remote.ip":
+		return f.SourceIP, false, nil
+	case "source.namespace":
+		return f.SourceNamespace, false, nil
+	case "source.principal":
+		return f.SourcePrincipal, false, nil
+	case "request.auth.principal":
+		return f.RequestPrincipal, true, nil
+	case "destination.port":
+		return strconv.Itoa(f.DestinationPort), false, nil
+	}
+	return "", false, fmt.Errorf("condition %s cannot be evaluated from the flow", key)
+}
+
+// fieldMatches returns true if the value matches one of the values, if any, and none of the excluded values.
+func fieldMatches(values, notValues []string, v string, fold bool) bool {
+	if fold {
+		v = strings.ToLower(v)
+	}
+	match := func(patterns []string) bool {
+		for _, p := range patterns {
+			if fold {
+				p = strings.ToLower(p)
+			}
+			if stringMatches(p, v) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(values) > 0 && !match(values) {
+		return false
+	}
+	return !match(notValues)
+}
+
+// stringMatches matches the value with the exact, prefix ("abc*"), suffix ("*abc") or presence ("*") pattern.
+func stringMatches(pattern, v string) bool {
+	switch {
+	case pattern == "*":
+		return v != ""
+	case strings.HasPrefix(pattern, "*"):
+		return strings.HasSuffix(v, strings.TrimPrefix(pattern, "*"))
+	case strings.HasSuffix(pattern, "*"):
+		return strings.HasPrefix(v, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == v
+}
+
+// ipMatches returns true if the IP is in one of the blocks, if any, and none of the excluded blocks.
+func ipMatches(blocks, notBlocks []string, v string) bool {
+	ip := net.ParseIP(v)
+	match := func(blocks []string) bool {
+		for _, b := range blocks {
+			if !strings.Contains(b, "/") {
+				if ip != nil && ip.Equal(net.ParseIP(b)) {
+					return true
+				}
+				continue
+			}
+			if _, cidr, err := net.ParseCIDR(b); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(blocks) > 0 && !match(blocks) {
+		return false
+	}
+	return !match(notBlocks)
+}
+
+// Impact is a distinct flow whose decision changes with the proposed policies.
+type Impact struct {
+	Flow     Flow
+	Count    int
+	Current  Result
+	Proposed Result
+}
+
+// Compare evaluates the flows against the current and the proposed policies, and returns the distinct flows whose
+// decision changes, the most frequent first.
+func Compare(current, proposed *model.AuthorizationPolicies, flows []Flow) []Impact {
+	counts := map[string]int{}
+	distinct := map[string]Flow{}
+	for _, f := range flows {
+		// Maps are marshaled with sorted keys, so equal flows have the same key.
+		key, _ := json.Marshal(f)
+		counts[string(key)]++
+		distinct[string(key)] = f
+	}
+	var impacts []Impact
+	for key, f := range distinct {
+		c, p := Evaluate(current, f), Evaluate(proposed, f)
+		if c.Decision != p.Decision {
+			impacts = append(impacts, Impact{Flow: f, Count: counts[key], Current: c, Proposed: p})
+		}
+	}
+	sort.Slice(impacts, func(i, j int) bool {
+		if impacts[i].Count != impacts[j].Count {
+			return impacts[i].Count > impacts[j].Count
+		}
+		return impacts[i].Flow.String() < impacts[j].Flow.String()
+	})
+	return impacts
+}
+
+// PrintImpact prints the flows whose decision changes.
+func PrintImpact(writer io.Writer, impacts []Impact) {
+	if len(impacts) == 0 {
+		_, _ = fmt.Fprintln(writer, "No observed flow changes decision with the proposed policies.")
+		return
+	}
+	w := new(tabwriter.Writer).Init(writer, 0, 8, 1, ' ', 0)
+	_, _ = fmt.Fprintln(w, "FLOW\tCOUNT\tCURRENT\tPROPOSED\tDECIDED BY")
+	for _, i := range impacts {
+		policy := i.Proposed.Policy
+		if policy == "" {
+			policy = i.Proposed.Reason
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", i.Flow, i.Count, i.Current.Decision, i.Proposed.Decision, policy)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,136 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"testing"
+
+	"istio.io/api/annotation"
+	authpb "istio.io/api/security/v1beta1"
+	typev1beta1 "istio.io/api/type/v1beta1"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+func policy(namespace, name string, spec *authpb.AuthorizationPolicy) config.Config {
+	return config.Config{
+		Meta: config.Meta{GroupVersionKind: gvk.AuthorizationPolicy, Name: name, Namespace: namespace},
+		Spec: spec,
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	denyAdmin := policy("foo", "deny-admin", &authpb.AuthorizationPolicy{
+		Action: authpb.AuthorizationPolicy_DENY,
+		Rules:  []*authpb.Rule{{To: []*authpb.Rule_To{{Operation: &authpb.Operation{Paths: []string{"/admin*"}}}}}},
+	})
+	allowFrontend := policy("foo", "allow-frontend", &authpb.AuthorizationPolicy{
+		Selector: &typev1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "backend"}},
+		Rules: []*authpb.Rule{{
+			From: []*authpb.Rule_From{{Source: &authpb.Source{Namespaces: []string{"frontend"}}}},
+			To:   []*authpb.Rule_To{{Operation: &authpb.Operation{Methods: []string{"GET"}, Ports: []string{"8080"}}}},
+		}},
+	})
+	allowInternal := policy("foo", "allow-internal", &authpb.AuthorizationPolicy{
+		Rules: []*authpb.Rule{{From: []*authpb.Rule_From{{Source: &authpb.Source{IpBlocks: []string{"10.0.0.0/8"}}}}}},
+	})
+	denyHeader := policy("istio-system", "deny-header", &authpb.AuthorizationPolicy{
+		Action: authpb.AuthorizationPolicy_DENY,
+		Rules:  []*authpb.Rule{{When: []*authpb.Condition{{Key: "request.headers[X-Block]", Values: []string{"true"}}}}},
+	})
+	dryRun := policy("foo", "dry-run", &authpb.AuthorizationPolicy{
+		Action: authpb.AuthorizationPolicy_DENY,
+		Rules:  []*authpb.Rule{{}},
+	})
+	dryRun.Annotations = map[string]string{annotation.IoIstioDryRun.Name: "true"}
+	unsupported := policy("foo", "unsupported", &authpb.AuthorizationPolicy{
+		Action: authpb.AuthorizationPolicy_DENY,
+		Rules:  []*authpb.Rule{{When: []*authpb.Condition{{Key: "experimental.envoy.filters.a.b[c]", Values: []string{"d"}}}}},
+	})
+
+	get := Flow{
+		SourceNamespace:      "frontend",
+		SourceIP:             "192.168.0.1",
+		DestinationNamespace: "foo",
+		DestinationLabels:    map[string]string{"app": "backend"},
+		DestinationPort:      8080,
+		Method:               "GET",
+		Path:                 "/api",
+	}
+	post := get
+	post.Method = "POST"
+	admin := get
+	admin.Path = "/admin/users"
+	blocked := get
+	blocked.Headers = map[string]string{"x-block": "true"}
+	internal := post
+	internal.SourceIP = "10.1.2.3"
+	tcp := get
+	tcp.Method, tcp.Path = "", ""
+
+	cases := []struct {
+		name     string
+		policies []config.Config
+		flow     Flow
+		want     Decision
+		policy   string
+	}{
+		{name: "no policies", flow: post, want: Allowed},
+		{name: "allow matched", policies: []config.Config{allowFrontend}, flow: get, want: Allowed, policy: "foo/allow-frontend"},
+		{name: "allow not matched", policies: []config.Config{allowFrontend}, flow: post, want: Denied},
+		{name: "allow ip block", policies: []config.Config{allowFrontend, allowInternal}, flow: internal, want: Allowed, policy: "foo/allow-internal"},
+		{name: "deny before allow", policies: []config.Config{allowFrontend, denyAdmin}, flow: admin, want: Denied, policy: "foo/deny-admin"},
+		{name: "root namespace deny", policies: []config.Config{denyHeader}, flow: blocked, want: Denied, policy: "istio-system/deny-header"},
+		{name: "tcp ignores http deny fields", policies: []config.Config{denyAdmin}, flow: tcp, want: Denied, policy: "foo/deny-admin"},
+		{name: "tcp never matches http allow fields", policies: []config.Config{allowFrontend}, flow: tcp, want: Denied},
+		{name: "dry run", policies: []config.Config{dryRun}, flow: get, want: Allowed},
+		{name: "unsupported condition", policies: []config.Config{unsupported}, flow: get, want: Unknown, policy: "foo/unsupported"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			policies, err := NewPolicies("istio-system", tt.policies)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Evaluate(policies, tt.flow)
+			if got.Decision != tt.want || got.Policy != tt.policy {
+				t.Fatalf("expected %s by %q, got %s by %q (%s)", tt.want, tt.policy, got.Decision, got.Policy, got.Reason)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	current, err := NewPolicies("istio-system", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposed, err := NewPolicies("istio-system", []config.Config{policy("foo", "deny-post", &authpb.AuthorizationPolicy{
+		Action: authpb.AuthorizationPolicy_DENY,
+		Rules:  []*authpb.Rule{{To: []*authpb.Rule_To{{Operation: &authpb.Operation{Methods: []string{"POST"}}}}}},
+	})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := Flow{DestinationNamespace: "foo", Method: "GET", Path: "/"}
+	post := Flow{DestinationNamespace: "foo", Method: "POST", Path: "/", Headers: map[string]string{"a": "1", "b": "2"}}
+	impacts := Compare(current, proposed, []Flow{get, post, post, get})
+	if len(impacts) != 1 {
+		t.Fatalf("expected 1 impacted flow, got %v", impacts)
+	}
+	if i := impacts[0]; i.Count != 2 || i.Current.Decision != Allowed || i.Proposed.Decision != Denied {
+		t.Fatalf("unexpected impact %+v", i)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package recommend recommends configurations from the traffic observed by the proxies.
+package recommend
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+var (
+	// metricRegexp matches the request and TCP connection counters of the Istio standard metrics, in the Prometheus
+	// output of the Envoy stats. Unlike the cluster stats, they are not excluded by the default stats matcher.
+	metricRegexp = regexp.MustCompile(`^(istio_requests_total|istio_tcp_connections_opened_total)\{(.*)\} (\S+)$`)
+	labelRegexp  = regexp.MustCompile(`(\w+)="((?:[^"\\]|\\.)*)"`)
+	// clusterRegexp matches the outbound clusters in the access logs, whatever their format.
+	clusterRegexp = regexp.MustCompile(`outbound\|\d+\|[^|\s"]*\|[^\s",]+|` + passthroughCluster)
+)
+
+const (
+	passthroughCluster = "PassthroughCluster"
+	// unknown is the value of the labels of the Istio standard metrics which could not be determined.
+	unknown = "unknown"
+)
+
+// Dependencies are the egress dependencies of a workload, observed in the stats or the access logs of its proxies.
+type Dependencies struct {
+	domain string
+	hosts  sets.Set
+	// Passthrough is true if the proxies sent traffic to destinations unknown to the mesh, which are not covered by
+	// the egress hosts of the recommended Sidecar.
+	Passthrough bool
+}
+
+// NewDependencies returns empty Dependencies, for a mesh with the domain suffix.
+func NewDependencies(domain string) *Dependencies {
+	if domain == "" {
+		domain = constants.DefaultKubernetesDomain
+	}
+	return &Dependencies{domain: domain, hosts: sets.NewSet()}
+}
+
+// Observe records the destinations of the Istio standard metrics, in the Prometheus format of the Envoy stats, or
+// of the access logs read from the reader. Metrics are only recorded when reported by the source workload with a
+// counter which is not zero, access logs lines when they reference an outbound cluster.
+func (d *Dependencies) Observe(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "istio_") {
+			if match := metricRegexp.FindStringSubmatch(line); match != nil {
+				d.observeMetric(match[2], match[3])
+			}
+			continue
+		}
+		for _, cluster := range clusterRegexp.FindAllString(line, -1) {
+			d.observeCluster(cluster)
+		}
+	}
+	return scanner.Err()
+}
+
+func (d *Dependencies) observeMetric(labels, value string) {
+	if count, err := strconv.ParseFloat(value, 64); err != nil || count <= 0 {
+		return
+	}
+	l := map[string]string{}
+	for _, match := range labelRegexp.FindAllStringSubmatch(labels, -1) {
+		l[match[1]] = match[2]
+	}
+	if l["reporter"] != "source" {
+		return
+	}
+	if l["destination_service_name"] == passthroughCluster {
+		d.Passthrough = true
+		return
+	}
+	if svc := l["destination_service"]; svc != "" && svc != unknown {
+		d.hosts.Insert(d.egressHost(host.Name(svc)))
+	}
+}
+
+func (d *Dependencies) observeCluster(cluster string) {
+	if cluster == passthroughCluster {
+		d.Passthrough = true
+		return
+	}
+	direction, _, hostname, _ := model.ParseSubsetKey(cluster)
+	if direction != model.TrafficDirectionOutbound || hostname == "" {
+		return
+	}
+	d.hosts.Insert(d.egressHost(hostname))
+}
+
+// egressHost returns the host in the namespace/dnsName format of the Sidecar egress listeners. The namespace of
+// Kubernetes services is known from their name, other services may be exported from any namespace.
+func (d *Dependencies) egressHost(hostname host.Name) string {
+	name := string(hostname)
+	if strings.HasSuffix(name, ".svc."+d.domain) {
+		parts := strings.Split(strings.TrimSuffix(name, ".svc."+d.domain), ".")
+		if len(parts) == 2 {
+			return parts[1] + "/" + name
+		}
+	}
+	return "*/" + name
+}
+
+// Hosts returns the observed egress hosts, sorted.
+func (d *Dependencies) Hosts() []string {
+	return d.hosts.SortedList()
+}
+
+// Sidecar returns the Sidecar restricting the egress of the workloads selected by the labels to the observed
+// dependencies.
+func (d *Dependencies) Sidecar(name, namespace string, selector map[string]string) (config.Config, error) {
+	if d.hosts.Empty() {
+		return config.Config{}, fmt.Errorf("no egress dependencies observed for %s.%s", name, namespace)
+	}
+	sidecar := &networking.Sidecar{
+		Egress: []*networking.IstioEgressListener{{Hosts: d.Hosts()}},
+	}
+	if len(selector) > 0 {
+		sidecar.WorkloadSelector = &networking.WorkloadSelector{Labels: selector}
+	}
+	return config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.Sidecar,
+			Name:             name,
+			Namespace:        namespace,
+		},
+		Spec: sidecar,
+	}, nil
+}
@@ -0,0 +1,99 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recommend
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+func TestDependencies(t *testing.T) {
+	cases := []struct {
+		name            string
+		input           string
+		wantHosts       []string
+		wantPassthrough bool
+	}{
+		{
+			name: "stats",
+			input: `# TYPE istio_requests_total counter
+istio_requests_total{reporter="source",destination_service="ratings.default.svc.cluster.local",destination_service_name="ratings"} 4
+istio_requests_total{reporter="source",destination_service="details.default.svc.cluster.local",destination_service_name="details"} 0
+istio_requests_total{reporter="destination",destination_service="productpage.default.svc.cluster.local",destination_service_name="productpage"} 10
+istio_requests_total{reporter="source",destination_service="unknown",destination_service_name="unknown"} 2
+# TYPE istio_tcp_connections_opened_total counter
+istio_tcp_connections_opened_total{reporter="source",destination_service="reviews.default.svc.cluster.local",destination_service_name="reviews"} 1
+istio_tcp_connections_opened_total{reporter="source",destination_service="api.example.com",destination_service_name="api.example.com"} 3`,
+			wantHosts: []string{
+				"*/api.example.com", "default/ratings.default.svc.cluster.local",
+				"default/reviews.default.svc.cluster.local",
+			},
+		},
+		{
+			name: "passthrough stats",
+			input: `istio_tcp_connections_opened_total{reporter="source",destination_service="unknown",` +
+				`destination_service_name="PassthroughCluster"} 1`,
+			wantHosts:       []string{},
+			wantPassthrough: true,
+		},
+		{
+			name: "access logs",
+			input: `[2022-01-10T10:00:00.000Z] "GET / HTTP/1.1" 200 - outbound|80||httpbin.org 10.0.0.4:36478
+{"upstream_cluster":"outbound|9080||ratings.prod.svc.cluster.local","response_code":200}
+[2022-01-10T10:00:00.000Z] "- - -" 0 - PassthroughCluster 10.0.0.4:51200`,
+			wantHosts:       []string{"*/httpbin.org", "prod/ratings.prod.svc.cluster.local"},
+			wantPassthrough: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDependencies("")
+			if err := d.Observe(strings.NewReader(tt.input)); err != nil {
+				t.Fatal(err)
+			}
+			if got := d.Hosts(); !reflect.DeepEqual(got, tt.wantHosts) {
+				t.Fatalf("expected hosts %v, got %v", tt.wantHosts, got)
+			}
+			if d.Passthrough != tt.wantPassthrough {
+				t.Fatalf("expected passthrough %v, got %v", tt.wantPassthrough, d.Passthrough)
+			}
+		})
+	}
+}
+
+func TestSidecar(t *testing.T) {
+	d := NewDependencies("")
+	if _, err := d.Sidecar("reviews", "default", nil); err == nil {
+		t.Fatal("expected an error without dependencies")
+	}
+	stats := `istio_requests_total{reporter="source",destination_service="ratings.default.svc.cluster.local"} 4`
+	if err := d.Observe(strings.NewReader(stats)); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := d.Sidecar("reviews", "default", map[string]string{"app": "reviews"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sidecar := cfg.Spec.(*networking.Sidecar)
+	if got := sidecar.Egress[0].Hosts; !reflect.DeepEqual(got, []string{"default/ratings.default.svc.cluster.local"}) {
+		t.Fatalf("unexpected egress hosts %v", got)
+	}
+	if got := sidecar.WorkloadSelector.GetLabels()["app"]; got != "reviews" {
+		t.Fatalf("unexpected workload selector %v", sidecar.WorkloadSelector)
+	}
+}
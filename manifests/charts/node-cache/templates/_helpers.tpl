@@ -0,0 +1,41 @@
+{{- define "node-cache.name" -}}
+{{- if eq .Release.Name "RELEASE-NAME" -}}
+  {{- .Values.name | default "istio-node-cache" -}}
+{{- else -}}
+  {{- .Values.name | default .Release.Name | default "istio-node-cache" -}}
+{{- end -}}
+{{- end }}
+
+{{/*
+Create chart name and version as used by the chart label.
+*/}}
+{{- define "node-cache.chart" -}}
+{{- printf "%s-%s" .Chart.Name .Chart.Version | replace "+" "_" | trunc 63 | trimSuffix "-" }}
+{{- end }}
+
+{{- define "node-cache.labels" -}}
+helm.sh/chart: {{ include "node-cache.chart" . }}
+{{ include "node-cache.selectorLabels" . }}
+{{- if .Chart.AppVersion }}
+app.kubernetes.io/version: {{ .Chart.AppVersion | quote }}
+{{- end }}
+app.kubernetes.io/managed-by: {{ .Release.Service }}
+app.kubernetes.io/name: {{ include "node-cache.name" . }}
+{{- range $key, $val := .Values.labels }}
+{{- if not (eq $key "app") }}
+{{ $key | quote }}: {{ $val | quote }}
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{- define "node-cache.selectorLabels" -}}
+app: {{ .Values.labels.app | default (include "node-cache.name" .) | quote }}
+{{- end }}
+
+{{- define "node-cache.serviceAccountName" -}}
+{{- if .Values.serviceAccount.create }}
+{{- .Values.serviceAccount.name | default (include "node-cache.name" .)    }}
+{{- else }}
+{{- .Values.serviceAccount.name | default "default" }}
+{{- end }}
+{{- end }}
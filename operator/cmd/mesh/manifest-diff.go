@@ -22,7 +22,10 @@ import (
 	"github.com/spf13/cobra"
 
 	"istio.io/istio/operator/pkg/compare"
+	"istio.io/istio/operator/pkg/helmreconciler"
+	"istio.io/istio/operator/pkg/manifest"
 	"istio.io/istio/operator/pkg/util"
+	"istio.io/istio/operator/pkg/util/clog"
 )
 
 // YAMLSuffix is the suffix of a YAML file.
@@ -45,6 +48,25 @@ type manifestDiffArgs struct {
 	// The format of each renaming pair is A->B, all renaming pairs are comma separated.
 	// e.g. Service:*:istio-pilot->Service:*:istio-control - rename istio-pilot service into istio-control
 	renameResources string
+
+	// live compares the manifest generated from inFilenames and set to the live objects in the cluster, instead of
+	// comparing two files or directories.
+	live bool
+	// inFilenames is an array of paths to the input IstioOperator CR files.
+	inFilenames []string
+	// set is a string with element format "path=value" where path is an IstioOperator path and the value is a
+	// value to set the node at that path to.
+	set []string
+	// manifestsPath is a path to a charts and profiles directory in the local filesystem, or URL with a release tgz.
+	manifestsPath string
+	// revision is the Istio control plane revision the command targets.
+	revision string
+	// force proceeds even if there are validation errors.
+	force bool
+	// kubeConfigPath is the path to kube config file.
+	kubeConfigPath string
+	// context is the cluster context in the kube config.
+	context string
 }
 
 func addManifestDiffFlags(cmd *cobra.Command, diffArgs *manifestDiffArgs) {
@@ -64,6 +86,16 @@ func addManifestDiffFlags(cmd *cobra.Command, diffArgs *manifestDiffArgs) {
 		"Rename resources before comparison.\n"+
 			"The format of each renaming pair is A->B, all renaming pairs are comma separated.\n"+
 			"e.g. Service:*:istiod->Service:*:istio-control - rename istiod service into istio-control")
+	cmd.PersistentFlags().BoolVar(&diffArgs.live, "live", false,
+		"Compare the manifest generated from the --filename and --set flags to the live objects in the cluster.\n"+
+			"Fields defaulted by the cluster are ignored, and objects which would be pruned are reported as missing in B.")
+	cmd.PersistentFlags().StringSliceVarP(&diffArgs.inFilenames, "filename", "f", nil, filenameFlagHelpStr)
+	cmd.PersistentFlags().StringArrayVarP(&diffArgs.set, "set", "s", nil, setFlagHelpStr)
+	cmd.PersistentFlags().StringVarP(&diffArgs.manifestsPath, "manifests", "d", "", ManifestsFlagHelpStr)
+	cmd.PersistentFlags().StringVar(&diffArgs.revision, "revision", "", revisionFlagHelpStr)
+	cmd.PersistentFlags().BoolVar(&diffArgs.force, "force", false, ForceFlagHelpStr)
+	cmd.PersistentFlags().StringVarP(&diffArgs.kubeConfigPath, "kubeconfig", "c", "", KubeConfigFlagHelpStr)
+	cmd.PersistentFlags().StringVar(&diffArgs.context, "context", "", ContextFlagHelpStr)
 }
 
 func manifestDiffCmd(rootArgs *RootArgs, diffArgs *manifestDiffArgs) *cobra.Command {
@@ -73,8 +105,21 @@ func manifestDiffCmd(rootArgs *RootArgs, diffArgs *manifestDiffArgs) *cobra.Comm
 		Long: "The diff subcommand compares manifests from two files or directories. The output is a list of\n" +
 			"changed paths with the value changes shown as OLD-VALUE -> NEW-VALUE.\n" +
 			"List order changes are shown as [OLD-INDEX->NEW-INDEX], with ? used where a list item is added or\n" +
-			"removed.",
+			"removed.\n" +
+			"With --live, the manifest generated from the --filename and --set flags is compared to the live objects\n" +
+			"in the cluster, to preview the changes an install or upgrade would make.",
+		Example: `  # Compare two generated manifests
+  istioctl manifest diff manifest1.yaml manifest2.yaml
+
+  # Preview the changes an upgrade to the configuration in iop.yaml would make to the cluster
+  istioctl manifest diff --live -f iop.yaml`,
 		Args: func(cmd *cobra.Command, args []string) error {
+			if diffArgs.live {
+				if len(args) != 0 {
+					return fmt.Errorf("diff --live accepts no positional arguments, got %#v", args)
+				}
+				return nil
+			}
 			if len(args) != 2 {
 				return fmt.Errorf("diff requires two files or directories")
 			}
@@ -83,6 +128,17 @@ func manifestDiffCmd(rootArgs *RootArgs, diffArgs *manifestDiffArgs) *cobra.Comm
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var err error
 			var equal bool
+			if diffArgs.live {
+				l := clog.NewConsoleLogger(cmd.OutOrStdout(), cmd.ErrOrStderr(), installerScope)
+				equal, err = compareManifestsWithLive(rootArgs, diffArgs, l)
+				if err != nil {
+					return err
+				}
+				if !equal {
+					os.Exit(1)
+				}
+				return nil
+			}
 			if diffArgs.compareDir {
 				equal, err = compareManifestsFromDirs(rootArgs, diffArgs.verbose, args[0], args[1],
 					diffArgs.renameResources, diffArgs.selectResources, diffArgs.ignoreResources)
@@ -137,6 +193,46 @@ func compareManifestsFromFiles(rootArgs *RootArgs, args []string, verbose bool,
 	return true, nil
 }
 
+// compareManifestsWithLive compares the manifest generated from the diff args to the live objects in the cluster.
+func compareManifestsWithLive(rootArgs *RootArgs, diffArgs *manifestDiffArgs, l clog.Logger) (bool, error) {
+	initLogsOrExit(rootArgs)
+
+	kubeClient, client, err := KubernetesClients(diffArgs.kubeConfigPath, diffArgs.context, l)
+	if err != nil {
+		return false, err
+	}
+	setFlags := applyFlagAliases(diffArgs.set, diffArgs.manifestsPath, diffArgs.revision)
+	_, iop, err := manifest.GenerateConfig(diffArgs.inFilenames, setFlags, diffArgs.force, kubeClient, l)
+	if err != nil {
+		return false, fmt.Errorf("generate config: %v", err)
+	}
+	reconciler, err := helmreconciler.NewHelmReconciler(client, kubeClient, iop, &helmreconciler.Options{DryRun: true, Log: l})
+	if err != nil {
+		return false, err
+	}
+	manifests, err := reconciler.RenderCharts()
+	if err != nil {
+		return false, fmt.Errorf("failed to render manifests: %v", err)
+	}
+	live, rendered, err := reconciler.LiveManifests(manifests)
+	if err != nil {
+		return false, fmt.Errorf("failed to read the live objects: %v", err)
+	}
+
+	diff, err := compare.ManifestDiffWithRenameSelectIgnore(live, rendered, diffArgs.renameResources, diffArgs.selectResources,
+		diffArgs.ignoreResources, diffArgs.verbose)
+	if err != nil {
+		return false, err
+	}
+	if diff != "" {
+		fmt.Printf("Differences between the live objects (A) and the generated manifests (B) are:\n%s\n", diff)
+		return false, nil
+	}
+
+	fmt.Println("Live objects are identical to the generated manifests")
+	return true, nil
+}
+
 func yamlFileFilter(path string) bool {
 	return filepath.Ext(path) == YAMLSuffix
 }
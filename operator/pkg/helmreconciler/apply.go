@@ -81,6 +81,18 @@ func (h *HelmReconciler) ApplyManifest(manifest name.Manifest, serverSideApply b
 		changedObjectKeys = append(changedObjectKeys, oh)
 	}
 
+	// Components with a health-gated rollout are rolled back to the configuration of their objects before the upgrade.
+	policy, err := h.rolloutPolicy(manifest.Name)
+	if err != nil {
+		return nil, 0, err
+	}
+	var previous map[string]previousObject
+	if policy != nil && len(changedObjects) > 0 && !h.opts.DryRun {
+		if previous, err = h.previousObjects(changedObjects); err != nil {
+			return nil, 0, err
+		}
+	}
+
 	var plog *progress.ManifestLog
 	if len(changedObjectKeys) > 0 {
 		plog = h.opts.ProgressLog.NewComponent(cname)
@@ -101,6 +113,11 @@ func (h *HelmReconciler) ApplyManifest(manifest name.Manifest, serverSideApply b
 			if err := h.applyLabelsAndAnnotations(obju, cname); err != nil {
 				return nil, 0, err
 			}
+			if policy != nil {
+				if err := policy.applyStrategy(obju); err != nil {
+					return nil, 0, err
+				}
+			}
 			if err := h.ApplyObject(obj.UnstructuredObject(), serverSideApply); err != nil {
 				scope.Error(err.Error())
 				errs = util.AppendErr(errs, err)
@@ -127,20 +144,33 @@ func (h *HelmReconciler) ApplyManifest(manifest name.Manifest, serverSideApply b
 	}
 
 	if len(changedObjectKeys) > 0 {
-		if len(errs) != 0 {
+		var err error
+		switch {
+		case len(errs) != 0 && previous != nil:
+			err = h.rollback(manifest.Name, changedObjects, previous, serverSideApply, errs.ToError())
+		case len(errs) != 0:
 			plog.ReportError(util.ToString(errs.Dedup(), "\n"))
 			return processedObjects, 0, errs.ToError()
+		case previous != nil:
+			if verr := h.verifyRollout(manifest.Name, processedObjects, policy); verr != nil {
+				err = h.rollback(manifest.Name, changedObjects, previous, serverSideApply, verr)
+			}
+		default:
+			if werr := WaitForResources(processedObjects, h.kubeClient, h.opts.WaitTimeout, h.opts.DryRun, plog); werr != nil {
+				err = fmt.Errorf("failed to wait for resource: %v", werr)
+			}
 		}
-
-		err := WaitForResources(processedObjects, h.kubeClient,
-			h.opts.WaitTimeout, h.opts.DryRun, plog)
 		if err != nil {
-			werr := fmt.Errorf("failed to wait for resource: %v", err)
-			plog.ReportError(werr.Error())
-			return processedObjects, 0, werr
+			if previous != nil {
+				// The objects are not in their upgraded state anymore, the next reconciliation applies them again.
+				for _, obj := range changedObjects {
+					delete(objectCache.Cache, obj.Hash())
+				}
+			}
+			plog.ReportError(err.Error())
+			return processedObjects, 0, err
 		}
 		plog.ReportFinished()
-
 	}
 	return processedObjects, deployedObjects, nil
 }
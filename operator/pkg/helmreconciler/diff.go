@@ -0,0 +1,218 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreconciler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	errors2 "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	klabels "k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/object"
+)
+
+// LiveManifests returns the manifests to compare to preview the changes applying the given manifests would make to
+// the cluster. The live manifest holds the live objects of the manifests and the objects the apply would prune. The
+// fields of the live objects which are neither in the manifests nor in the configuration last applied to them, such as
+// the fields defaulted by the API server and the status, are left out. The rendered manifest holds the objects of the
+// manifests, as they would be applied. Objects only in live would be pruned, objects only in rendered would be created.
+func (h *HelmReconciler) LiveManifests(manifests name.ManifestMap) (live string, rendered string, err error) {
+	var liveObjs, renderedObjs object.K8sObjects
+	consolidated := manifests.Consolidated()
+	for cname, manifest := range consolidated {
+		objs, err := object.ParseK8sObjectsFromYAMLManifest(manifest)
+		if err != nil {
+			return "", "", err
+		}
+		policy, err := h.rolloutPolicy(name.ComponentName(cname))
+		if err != nil {
+			return "", "", err
+		}
+		for _, obj := range objs {
+			desired := obj.UnstructuredObject().DeepCopy()
+			if err := h.applyLabelsAndAnnotations(desired, cname); err != nil {
+				return "", "", err
+			}
+			if policy != nil {
+				if err := policy.applyStrategy(desired); err != nil {
+					return "", "", err
+				}
+			}
+			renderedObjs = append(renderedObjs, object.NewK8sObject(desired, nil, nil))
+
+			current := &unstructured.Unstructured{}
+			current.SetGroupVersionKind(desired.GroupVersionKind())
+			err := h.client.Get(context.TODO(), client.ObjectKeyFromObject(desired), current)
+			if errors2.IsNotFound(err) || meta.IsNoMatchError(err) {
+				continue
+			}
+			if err != nil {
+				return "", "", fmt.Errorf("failed to read %s: %v", obj.Hash(), err)
+			}
+			liveObjs = append(liveObjs, object.NewK8sObject(liveProjection(current, desired), nil, nil))
+		}
+	}
+
+	// Mirror Prune, which removes the objects of the rendered components which are not in their manifest.
+	err = h.runForAllTypes(func(labels map[string]string, objects *unstructured.UnstructuredList) error {
+		for cname, manifest := range consolidated {
+			hashes := object.AllObjectHashes(manifest)
+			selector := klabels.Set(h.addComponentLabels(labels, cname)).AsSelectorPreValidated()
+			for i := range objects.Items {
+				o := &objects.Items[i]
+				if !selector.Matches(klabels.Set(o.GetLabels())) || hashes[object.NewK8sObject(o, nil, nil).Hash()] {
+					continue
+				}
+				liveObjs = append(liveObjs, object.NewK8sObject(liveProjection(o, nil), nil, nil))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, objs := range []object.K8sObjects{liveObjs, renderedObjs} {
+		sort.Slice(objs, func(i, j int) bool { return objs[i].Hash() < objs[j].Hash() })
+	}
+	if live, err = liveObjs.YAMLManifest(); err != nil {
+		return "", "", err
+	}
+	if rendered, err = renderedObjs.YAMLManifest(); err != nil {
+		return "", "", err
+	}
+	return live, rendered, nil
+}
+
+// liveProjection returns the fields of the live object which are in the desired object, if any, or in the
+// configuration last applied to it. Without either, only the metadata set by the API server and the status are
+// removed.
+func liveProjection(current, desired *unstructured.Unstructured) *unstructured.Unstructured {
+	var masks []interface{}
+	if desired != nil {
+		masks = append(masks, desired.Object)
+	}
+	if applied := current.GetAnnotations()[corev1.LastAppliedConfigAnnotation]; applied != "" {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(applied), &m); err == nil {
+			masks = append(masks, m)
+		}
+	}
+	if len(masks) == 0 {
+		out := current.DeepCopy()
+		for _, f := range [][]string{
+			{"status"}, {"metadata", "managedFields"}, {"metadata", "uid"}, {"metadata", "resourceVersion"},
+			{"metadata", "generation"}, {"metadata", "creationTimestamp"}, {"metadata", "selfLink"},
+		} {
+			unstructured.RemoveNestedField(out.Object, f...)
+		}
+		return out
+	}
+	out := project(current.Object, masks).(map[string]interface{})
+	unstructured.RemoveNestedField(out, "metadata", "annotations", corev1.LastAppliedConfigAnnotation)
+	if annotations, _, _ := unstructured.NestedMap(out, "metadata", "annotations"); len(annotations) == 0 {
+		unstructured.RemoveNestedField(out, "metadata", "annotations")
+	}
+	return &unstructured.Unstructured{Object: out}
+}
+
+// project returns the fields of node which are set in any of the masks. The items of lists are matched by name when
+// they have one, otherwise by index. Items in none of the masks are kept, since they are not defaulted.
+func project(node interface{}, masks []interface{}) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		var maps []map[string]interface{}
+		for _, m := range masks {
+			if mm, ok := m.(map[string]interface{}); ok {
+				maps = append(maps, mm)
+			}
+		}
+		if len(maps) == 0 {
+			return node
+		}
+		out := make(map[string]interface{}, len(n))
+		for k, v := range n {
+			var sub []interface{}
+			for _, mm := range maps {
+				if mv, ok := mm[k]; ok {
+					sub = append(sub, mv)
+				}
+			}
+			if len(sub) > 0 {
+				out[k] = project(v, sub)
+			}
+		}
+		return out
+	case []interface{}:
+		var lists [][]interface{}
+		for _, m := range masks {
+			if ml, ok := m.([]interface{}); ok {
+				lists = append(lists, ml)
+			}
+		}
+		if len(lists) == 0 {
+			return node
+		}
+		out := make([]interface{}, 0, len(n))
+		for i, v := range n {
+			var sub []interface{}
+			for _, ml := range lists {
+				if mv := matchingItem(ml, v, i); mv != nil {
+					sub = append(sub, mv)
+				}
+			}
+			if len(sub) == 0 {
+				out = append(out, v)
+				continue
+			}
+			out = append(out, project(v, sub))
+		}
+		return out
+	}
+	return node
+}
+
+// matchingItem returns the item of list corresponding to item v at index i of another list, or nil.
+func matchingItem(list []interface{}, v interface{}, i int) interface{} {
+	if name, ok := itemName(v); ok {
+		for _, mv := range list {
+			if mname, ok := itemName(mv); ok && mname == name {
+				return mv
+			}
+		}
+		return nil
+	}
+	if i < len(list) {
+		return list[i]
+	}
+	return nil
+}
+
+func itemName(v interface{}) (string, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	name, ok := m["name"].(string)
+	return name, ok
+}
@@ -0,0 +1,142 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreconciler
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"istio.io/istio/operator/pkg/compare"
+	"istio.io/istio/operator/pkg/name"
+)
+
+func TestLiveManifests(t *testing.T) {
+	TestMode = true
+	defer func() { TestMode = false }()
+
+	cl := &fakeClientWrapper{fake.NewClientBuilder().Build()}
+	h := rolloutReconciler(cl, nil)
+	installed, err := os.ReadFile("testdata/configmap.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pruned := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: pruned
+  namespace: istio-system
+`
+	if _, _, err := h.ApplyManifest(name.Manifest{
+		Name:    name.PilotComponentName,
+		Content: string(installed) + "\n---\n" + pruned,
+	}, false); err != nil {
+		t.Fatal(err)
+	}
+	// Fields set by the cluster, rather than by the installer, are ignored.
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"})
+	if err := cl.Get(context.TODO(), client.ObjectKey{Namespace: "istio-system", Name: "config"}, live); err != nil {
+		t.Fatal(err)
+	}
+	if err := unstructured.SetNestedField(live.Object, "defaulted", "data", "defaulted"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.Update(context.TODO(), live); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := os.ReadFile("testdata/configmap-changed.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: created
+  namespace: istio-system
+`
+	liveManifest, rendered, err := h.LiveManifests(name.ManifestMap{
+		name.PilotComponentName: {string(changed), created},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := compare.ManifestDiff(liveManifest, rendered, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, l := range strings.Split(diff, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			got = append(got, l)
+		}
+	}
+	want := []string{
+		"Object ConfigMap:istio-system:config has diffs:",
+		"data:",
+		"field: one -> two",
+		"new: <empty> -> new (ADDED)",
+		"Object ConfigMap:istio-system:created is missing in A:",
+		"Object ConfigMap:istio-system:pruned is missing in B:",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got diff:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestProject(t *testing.T) {
+	live := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas":             int64(1),
+			"revisionHistoryLimit": int64(10),
+			"containers": []interface{}{
+				map[string]interface{}{"name": "sidecar", "image": "proxy", "imagePullPolicy": "Always"},
+				map[string]interface{}{"name": "app", "image": "app:2", "terminationMessagePath": "/dev/termination-log"},
+			},
+			"args": []interface{}{"a", "b"},
+		},
+		"status": map[string]interface{}{"replicas": int64(1)},
+	}
+	desired := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"containers": []interface{}{
+				map[string]interface{}{"name": "app", "image": "app:3"},
+			},
+			"args": []interface{}{"a"},
+		},
+	}
+	want := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"containers": []interface{}{
+				map[string]interface{}{"name": "sidecar", "image": "proxy", "imagePullPolicy": "Always"},
+				map[string]interface{}{"name": "app", "image": "app:2"},
+			},
+			"args": []interface{}{"a", "b"},
+		},
+	}
+	if got := project(live, []interface{}{desired}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -62,6 +62,8 @@ type HelmReconciler struct {
 	// dependencyWaitCh is a map of signaling channels. A parent with children ch1...chN will signal
 	// dependencyWaitCh[ch1]...dependencyWaitCh[chN] when it's completely installed.
 	dependencyWaitCh map[name.ComponentName]chan struct{}
+	// rolloutChecks overrides the health checks of the components with a health-gated rollout, for tests.
+	rolloutChecks func(name.ComponentName) []rolloutCheck
 
 	// The fields below are for metrics and reporting
 	countLock     *sync.Mutex
@@ -0,0 +1,349 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreconciler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	corev1 "k8s.io/api/core/v1"
+	errors2 "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	networking "istio.io/api/networking/v1alpha3"
+	clientnetworking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/istioctl/pkg/xds"
+	istioV1Alpha1 "istio.io/istio/operator/pkg/apis/istio/v1alpha1"
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/object"
+	"istio.io/istio/operator/pkg/util"
+	pilotxds "istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/kube"
+)
+
+const (
+	// RolloutComponentsAnnotation lists, comma separated, the components of an IstioOperator upgraded with a
+	// health-gated rollout. Their previous configuration is restored if they are not healthy within the
+	// rollout window. Pilot, Cni, IngressGateways and EgressGateways are supported.
+	RolloutComponentsAnnotation = "install.istio.io/rollout-components"
+	// RolloutMaxSurgeAnnotation sets the maxSurge of the rolling update of the Deployments and DaemonSets
+	// of the components with a health-gated rollout.
+	RolloutMaxSurgeAnnotation = "install.istio.io/rollout-max-surge"
+	// RolloutMaxUnavailableAnnotation sets the maxUnavailable of the rolling update of the Deployments and
+	// DaemonSets of the components with a health-gated rollout.
+	RolloutMaxUnavailableAnnotation = "install.istio.io/rollout-max-unavailable"
+	// RolloutWindowAnnotation is the duration within which the components with a health-gated rollout must
+	// become ready and pass their health checks. Defaults to defaultRolloutWindow.
+	RolloutWindowAnnotation = "install.istio.io/rollout-window"
+
+	defaultRolloutWindow = 5 * time.Minute
+	// rolloutCheckInterval is the interval between the health checks of a component being rolled out.
+	rolloutCheckInterval = 5 * time.Second
+	// maxWebhookLatency is the longest a validation request to istiod may take after an upgrade.
+	maxWebhookLatency = 3 * time.Second
+	// xdsPlaintextPort is the port of istiod serving xDS without authentication.
+	xdsPlaintextPort = 15010
+)
+
+var rolloutComponents = map[name.ComponentName]bool{
+	name.PilotComponentName:   true,
+	name.CNIComponentName:     true,
+	name.IngressComponentName: true,
+	name.EgressComponentName:  true,
+}
+
+// rolloutPolicy is the health-gated rollout of a component.
+type rolloutPolicy struct {
+	maxSurge       *intstr.IntOrString
+	maxUnavailable *intstr.IntOrString
+	window         time.Duration
+}
+
+// rolloutCheck verifies the health of a component after it is upgraded.
+type rolloutCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// rolloutPolicy returns the rollout policy of a component, or nil if it is not upgraded with a health-gated
+// rollout.
+func (h *HelmReconciler) rolloutPolicy(c name.ComponentName) (*rolloutPolicy, error) {
+	annotations := h.iop.GetAnnotations()
+	enabled := false
+	for _, rc := range strings.Split(annotations[RolloutComponentsAnnotation], ",") {
+		rc := name.ComponentName(strings.TrimSpace(rc))
+		if rc == "" {
+			continue
+		}
+		if !rolloutComponents[rc] {
+			return nil, fmt.Errorf("invalid %s annotation: health-gated rollout is not supported for component %q",
+				RolloutComponentsAnnotation, rc)
+		}
+		enabled = enabled || rc == c
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	p := &rolloutPolicy{window: defaultRolloutWindow}
+	if v := annotations[RolloutMaxSurgeAnnotation]; v != "" {
+		surge := intstr.Parse(v)
+		p.maxSurge = &surge
+	}
+	if v := annotations[RolloutMaxUnavailableAnnotation]; v != "" {
+		unavailable := intstr.Parse(v)
+		p.maxUnavailable = &unavailable
+	}
+	if v := annotations[RolloutWindowAnnotation]; v != "" {
+		window, err := time.ParseDuration(v)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid %s annotation %q", RolloutWindowAnnotation, v)
+		}
+		p.window = window
+	}
+	return p, nil
+}
+
+// applyStrategy sets the maxSurge and maxUnavailable of the rolling update of a Deployment or DaemonSet.
+func (p *rolloutPolicy) applyStrategy(obj *unstructured.Unstructured) error {
+	var strategy []string
+	switch obj.GetKind() {
+	case name.DeploymentStr:
+		strategy = []string{"spec", "strategy"}
+	case name.DaemonSetStr:
+		strategy = []string{"spec", "updateStrategy"}
+	default:
+		return nil
+	}
+	if p.maxSurge == nil && p.maxUnavailable == nil {
+		return nil
+	}
+	if err := unstructured.SetNestedField(obj.Object, "RollingUpdate", append(strategy, "type")...); err != nil {
+		return err
+	}
+	for field, v := range map[string]*intstr.IntOrString{"maxSurge": p.maxSurge, "maxUnavailable": p.maxUnavailable} {
+		if v == nil {
+			continue
+		}
+		var value interface{} = v.StrVal
+		if v.Type == intstr.Int {
+			value = int64(v.IntVal)
+		}
+		if err := unstructured.SetNestedField(obj.Object, value, append(strategy, "rollingUpdate", field)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// previousObject is the state of an object before it is upgraded.
+type previousObject struct {
+	// existed is false if the object is created by the upgrade.
+	existed bool
+	// applied is the configuration last applied by the installer, nil if unknown.
+	applied *unstructured.Unstructured
+}
+
+// previousObjects reads the configuration last applied to the objects about to be upgraded, keyed by object hash.
+func (h *HelmReconciler) previousObjects(objs object.K8sObjects) (map[string]previousObject, error) {
+	out := make(map[string]previousObject, len(objs))
+	for _, obj := range objs {
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		err := h.client.Get(context.TODO(), client.ObjectKeyFromObject(obj.UnstructuredObject()), live)
+		if errors2.IsNotFound(err) {
+			out[obj.Hash()] = previousObject{}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s before upgrading it: %v", obj.Hash(), err)
+		}
+		prev := previousObject{existed: true}
+		if applied := live.GetAnnotations()[corev1.LastAppliedConfigAnnotation]; applied != "" {
+			o, err := object.ParseJSONToK8sObject([]byte(applied))
+			if err == nil {
+				prev.applied = o.UnstructuredObject()
+			}
+		}
+		out[obj.Hash()] = prev
+	}
+	return out, nil
+}
+
+// verifyRollout waits for the upgraded objects of a component to become ready and pass the health checks of the
+// component within the rollout window.
+func (h *HelmReconciler) verifyRollout(c name.ComponentName, objs object.K8sObjects, p *rolloutPolicy) error {
+	deadline := time.Now().Add(p.window)
+	if err := WaitForResources(objs, h.kubeClient, p.window, h.opts.DryRun, nil); err != nil {
+		return err
+	}
+	checks := h.checksFor(c)
+	if len(checks) == 0 {
+		return nil
+	}
+	var lastErr error
+	err := wait.PollImmediate(rolloutCheckInterval, time.Until(deadline), func() (bool, error) {
+		lastErr = nil
+		for _, rc := range checks {
+			ctx, cancel := context.WithTimeout(context.Background(), rolloutCheckInterval)
+			err := rc.check(ctx)
+			cancel()
+			if err != nil {
+				lastErr = fmt.Errorf("%s check failed: %v", rc.name, err)
+				scope.Infof("component %s is not healthy yet: %v", c, lastErr)
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil && lastErr != nil {
+		return lastErr
+	}
+	return err
+}
+
+// rollback restores the configuration of the objects of a component before its upgrade, which failed with cause,
+// and deletes the objects created by the upgrade. It returns the error reporting the failed upgrade.
+func (h *HelmReconciler) rollback(c name.ComponentName, objs object.K8sObjects, previous map[string]previousObject,
+	serverSideApply bool, cause error) error {
+	scope.Errorf("Upgrade of component %s failed, rolling back: %v", c, cause)
+	var errs util.Errors
+	var restored object.K8sObjects
+	for _, obj := range objs {
+		prev, f := previous[obj.Hash()]
+		if !f {
+			continue
+		}
+		switch {
+		case prev.applied != nil:
+			scope.Infof("Rolling back %s of component %s", obj.Hash(), c)
+			if err := h.ApplyObject(prev.applied, serverSideApply); err != nil {
+				errs = util.AppendErr(errs, err)
+				continue
+			}
+			restored = append(restored, object.NewK8sObject(prev.applied, nil, nil))
+		case !prev.existed && obj.Kind != name.NamespaceStr && obj.Kind != name.CRDStr:
+			// Namespaces and CRDs are kept, deleting them would delete their contents.
+			scope.Infof("Deleting %s of component %s created by the failed upgrade", obj.Hash(), c)
+			if err := h.client.Delete(context.TODO(), obj.UnstructuredObject(), client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil &&
+				!errors2.IsNotFound(err) {
+				errs = util.AppendErr(errs, err)
+			}
+		default:
+			scope.Warnf("Cannot roll back %s of component %s, its previous configuration is unknown", obj.Hash(), c)
+		}
+	}
+	if len(errs) == 0 {
+		errs = util.AppendErr(errs, WaitForResources(restored, h.kubeClient, h.opts.WaitTimeout, h.opts.DryRun, nil))
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("upgrade of component %s failed: %v; rollback failed: %v", c, cause, errs.ToError())
+	}
+	return fmt.Errorf("upgrade of component %s failed and was rolled back: %v", c, cause)
+}
+
+// checksFor returns the health checks of a component, in addition to the readiness of its workloads.
+func (h *HelmReconciler) checksFor(c name.ComponentName) []rolloutCheck {
+	if h.rolloutChecks != nil {
+		return h.rolloutChecks(c)
+	}
+	if c != name.PilotComponentName {
+		return nil
+	}
+	namespace := istioV1Alpha1.Namespace(h.iop.Spec)
+	revision := h.iop.Spec.Revision
+	return []rolloutCheck{
+		{name: "xDS reachability", check: func(ctx context.Context) error {
+			return xdsReachable(ctx, h.kubeClient, namespace, revision)
+		}},
+		{name: "webhook latency", check: func(ctx context.Context) error {
+			return webhookLatency(ctx, h.kubeClient, namespace, revision)
+		}},
+	}
+}
+
+// xdsReachable checks that all running istiod pods of a revision serve xDS.
+func xdsReachable(ctx context.Context, client kube.Client, namespace, revision string) error {
+	if revision == "" {
+		revision = "default"
+	}
+	pods, err := client.Kube().CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=istiod,istio.io/rev=" + revision,
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("no running istiod pod for revision %s", revision)
+	}
+	for _, pod := range pods.Items {
+		addr := fmt.Sprintf("%s:%d", pod.Status.PodIP, xdsPlaintextPort)
+		// Outside of the cluster, the pods are reached through port forwarding.
+		if ec, ok := client.(kube.ExtendedClient); ok {
+			fw, err := ec.NewPortForwarder(pod.Name, pod.Namespace, "localhost", 0, xdsPlaintextPort)
+			if err != nil {
+				return err
+			}
+			if err := fw.Start(); err != nil {
+				return err
+			}
+			defer fw.Close()
+			addr = fw.Address()
+		}
+		timeout := rolloutCheckInterval
+		if d, ok := ctx.Deadline(); ok {
+			timeout = time.Until(d)
+		}
+		_, err := xds.GetXdsResponse(&discovery.DiscoveryRequest{TypeUrl: pilotxds.TypeURLConnect}, namespace, "default",
+			clioptions.CentralControlPlaneOptions{Xds: addr, Timeout: timeout, Plaintext: true}, nil)
+		if err != nil {
+			return fmt.Errorf("istiod pod %s: %v", pod.Name, err)
+		}
+	}
+	return nil
+}
+
+// webhookLatency checks that the validation webhook of a revision responds within maxWebhookLatency, with a dry
+// run creation of a Sidecar.
+func webhookLatency(ctx context.Context, client kube.Client, namespace, revision string) error {
+	sidecar := &clientnetworking.Sidecar{
+		ObjectMeta: metav1.ObjectMeta{Name: "istio-rollout-check", Namespace: namespace},
+		Spec: networking.Sidecar{
+			Egress: []*networking.IstioEgressListener{{Hosts: []string{"./*"}}},
+		},
+	}
+	if revision != "" {
+		sidecar.Labels = map[string]string{"istio.io/rev": revision}
+	}
+	start := time.Now()
+	_, err := client.Istio().NetworkingV1alpha3().Sidecars(namespace).Create(ctx, sidecar,
+		metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil && !errors2.IsAlreadyExists(err) {
+		return err
+	}
+	if latency := time.Since(start); latency > maxWebhookLatency {
+		return fmt.Errorf("validation took %v, more than %v", latency, maxWebhookLatency)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helmreconciler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	errors2 "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	v1alpha12 "istio.io/api/operator/v1alpha1"
+	"istio.io/istio/operator/pkg/apis/istio/v1alpha1"
+	"istio.io/istio/operator/pkg/name"
+	"istio.io/istio/operator/pkg/util/progress"
+)
+
+func rolloutReconciler(cl client.Client, annotations map[string]string) *HelmReconciler {
+	return &HelmReconciler{
+		client: cl,
+		opts:   &Options{ProgressLog: progress.NewLog()},
+		iop: &v1alpha1.IstioOperator{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        "test-rollout",
+				Namespace:   "istio-system",
+				Annotations: annotations,
+			},
+			Spec: &v1alpha12.IstioOperatorSpec{},
+		},
+		countLock:     &sync.Mutex{},
+		prunedKindSet: map[schema.GroupKind]struct{}{},
+	}
+}
+
+func TestRolloutPolicy(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		component   name.ComponentName
+		want        string
+		wantErr     string
+	}{
+		{
+			name:      "no rollout",
+			component: name.PilotComponentName,
+		},
+		{
+			name:        "other component",
+			annotations: map[string]string{RolloutComponentsAnnotation: "Cni"},
+			component:   name.PilotComponentName,
+		},
+		{
+			name: "rollout",
+			annotations: map[string]string{
+				RolloutComponentsAnnotation:     "Pilot, IngressGateways",
+				RolloutMaxSurgeAnnotation:       "50%",
+				RolloutMaxUnavailableAnnotation: "0",
+				RolloutWindowAnnotation:         "2m",
+			},
+			component: name.IngressComponentName,
+			want:      "50% 0 2m0s",
+		},
+		{
+			name:        "default window",
+			annotations: map[string]string{RolloutComponentsAnnotation: "Pilot"},
+			component:   name.PilotComponentName,
+			want:        "<nil> <nil> 5m0s",
+		},
+		{
+			name:        "unsupported component",
+			annotations: map[string]string{RolloutComponentsAnnotation: "Base"},
+			component:   name.PilotComponentName,
+			wantErr:     `health-gated rollout is not supported for component "Base"`,
+		},
+		{
+			name:        "invalid window",
+			annotations: map[string]string{RolloutComponentsAnnotation: "Pilot", RolloutWindowAnnotation: "soon"},
+			component:   name.PilotComponentName,
+			wantErr:     "invalid install.istio.io/rollout-window annotation",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := rolloutReconciler(nil, tt.annotations).rolloutPolicy(tt.component)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if p != nil {
+				got = fmt.Sprintf("%v %v %v", intOrStringValue(p.maxSurge), intOrStringValue(p.maxUnavailable), p.window)
+			}
+			if got != tt.want {
+				t.Fatalf("got policy %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func intOrStringValue(v *intstr.IntOrString) string {
+	if v == nil {
+		return "<nil>"
+	}
+	return v.String()
+}
+
+func TestRolloutApplyStrategy(t *testing.T) {
+	p, err := rolloutReconciler(nil, map[string]string{
+		RolloutComponentsAnnotation:     "Cni",
+		RolloutMaxSurgeAnnotation:       "1",
+		RolloutMaxUnavailableAnnotation: "25%",
+	}).rolloutPolicy(name.CNIComponentName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for kind, strategy := range map[string]string{name.DeploymentStr: "strategy", name.DaemonSetStr: "updateStrategy"} {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{"kind": kind}}
+		if err := p.applyStrategy(obj); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]interface{}{
+			"type":          "RollingUpdate",
+			"rollingUpdate": map[string]interface{}{"maxSurge": int64(1), "maxUnavailable": "25%"},
+		}
+		if got, _, _ := unstructured.NestedMap(obj.Object, "spec", strategy); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: got strategy %v, want %v", kind, got, want)
+		}
+	}
+}
+
+func TestRolloutRollback(t *testing.T) {
+	TestMode = true
+	defer func() { TestMode = false }()
+
+	cl := &fakeClientWrapper{fake.NewClientBuilder().Build()}
+	h := rolloutReconciler(cl, map[string]string{
+		RolloutComponentsAnnotation: "Pilot",
+		RolloutWindowAnnotation:     "100ms",
+	})
+	var checked []name.ComponentName
+	h.rolloutChecks = func(c name.ComponentName) []rolloutCheck {
+		checked = append(checked, c)
+		return []rolloutCheck{{name: "test", check: func(context.Context) error {
+			return errors.New("unreachable")
+		}}}
+	}
+	// The state before the upgrade, as applied by a previous installation.
+	if err := h.ApplyObject(loadData(t, "testdata/configmap.yaml").UnstructuredObject(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := os.ReadFile("testdata/configmap-changed.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: created
+  namespace: istio-system
+data:
+  field: one
+`
+	_, _, err = h.ApplyManifest(name.Manifest{
+		Name:    name.PilotComponentName,
+		Content: string(changed) + "\n---\n" + created,
+	}, false)
+	if err == nil || !strings.Contains(err.Error(), "upgrade of component Pilot failed and was rolled back: test check failed: unreachable") {
+		t.Fatalf("expected the upgrade to be rolled back, got %v", err)
+	}
+	if !reflect.DeepEqual(checked, []name.ComponentName{name.PilotComponentName}) {
+		t.Fatalf("unexpected checked components %v", checked)
+	}
+
+	restored := &unstructured.Unstructured{}
+	restored.SetGroupVersionKind(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"})
+	if err := cl.Get(context.TODO(), client.ObjectKey{Namespace: "istio-system", Name: "config"}, restored); err != nil {
+		t.Fatal(err)
+	}
+	if field, _, _ := unstructured.NestedString(restored.Object, "data", "field"); field != "one" {
+		t.Fatalf("expected the previous configuration to be restored, got %v", restored.Object["data"])
+	}
+	err = cl.Get(context.TODO(), client.ObjectKey{Namespace: "istio-system", Name: "created"}, &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1", "kind": "ConfigMap",
+	}})
+	if !errors2.IsNotFound(err) {
+		t.Fatalf("expected the object created by the upgrade to be deleted, got %v", err)
+	}
+
+	// The rolled back objects are applied again by the next reconciliation.
+	h.rolloutChecks = func(name.ComponentName) []rolloutCheck { return nil }
+	processed, _, err := h.ApplyManifest(name.Manifest{
+		Name:    name.PilotComponentName,
+		Content: string(changed) + "\n---\n" + created,
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(processed) != 2 {
+		t.Fatalf("expected both objects to be applied again, got %v", processed.Keys())
+	}
+}
@@ -32,6 +32,9 @@ var DefaultValuesValidations = map[string]ValidatorFunc{
 
 // CheckValues validates the values in the given tree, which follows the Istio values.yaml schema.
 func CheckValues(root interface{}) util.Errors {
+	if errs := CheckValuesSchema(root); len(errs) != 0 {
+		return errs
+	}
 	vs, err := yaml.Marshal(root)
 	if err != nil {
 		return util.Errors{err}
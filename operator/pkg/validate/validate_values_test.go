@@ -139,7 +139,7 @@ global:
   proxy:
     foo: "bar"
 `,
-			wantErrs: makeErrors([]string{`unknown field "foo" in v1alpha1.ProxyConfig`}),
+			wantErrs: makeErrors([]string{`global.proxy.foo: unknown field, not defined in v1alpha1.ProxyConfig`}),
 		},
 		{
 			desc: "unknown field",
@@ -147,7 +147,7 @@ global:
 cni:
   foo: "bar"
 `,
-			wantErrs: makeErrors([]string{`unknown field "foo" in v1alpha1.CNIConfig`}),
+			wantErrs: makeErrors([]string{`cni.foo: unknown field, not defined in v1alpha1.CNIConfig`}),
 		},
 	}
 
@@ -0,0 +1,280 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/jsonpb"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/operator/pkg/apis/istio/v1alpha1"
+	"istio.io/istio/operator/pkg/util"
+)
+
+// ValuesErrorReason is the reason why values do not match the values schema.
+type ValuesErrorReason string
+
+const (
+	// UnknownField is the reason for keys which are not defined in the values schema.
+	UnknownField ValuesErrorReason = "UnknownField"
+	// InvalidType is the reason for values which do not have the type defined in the values schema.
+	InvalidType ValuesErrorReason = "InvalidType"
+)
+
+// ValuesError is a mismatch between the values and the values schema, at the path of the offending key.
+type ValuesError struct {
+	Path   util.Path
+	Reason ValuesErrorReason
+	// Message describes the mismatch, without the path.
+	Message string
+}
+
+func (e *ValuesError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+var (
+	valuesType            = reflect.TypeOf(v1alpha1.Values{})
+	jsonpbUnmarshalerType = reflect.TypeOf((*jsonpb.JSONPBUnmarshaler)(nil)).Elem()
+)
+
+// wellKnownType is implemented by the protobuf well-known types, which have a special JSON representation.
+type wellKnownType interface {
+	XXX_WellKnownType() string
+}
+
+// enum is implemented by the protobuf enums, which are represented in JSON by their name or number.
+type enum interface {
+	EnumDescriptor() ([]byte, []int)
+}
+
+// CheckValuesSchema validates the values in the given tree against the Istio values schema, defined by the Values
+// type. Unlike unmarshalling, which stops at the first error, it returns a ValuesError for each offending key.
+func CheckValuesSchema(root interface{}) util.Errors {
+	// Round trip the tree through JSON, so that it only holds the JSON types.
+	vs, err := yaml.Marshal(root)
+	if err != nil {
+		return util.Errors{err}
+	}
+	jb, err := yaml.YAMLToJSON(vs)
+	if err != nil {
+		return util.Errors{err}
+	}
+	var tree interface{}
+	if err := json.Unmarshal(jb, &tree); err != nil {
+		return util.Errors{err}
+	}
+	return checkValuesSchema(valuesType, tree, nil)
+}
+
+func checkValuesSchema(t reflect.Type, node interface{}, path util.Path) (errs util.Errors) {
+	if node == nil {
+		return nil
+	}
+	if t.Implements(jsonpbUnmarshalerType) || reflect.PtrTo(t).Implements(jsonpbUnmarshalerType) {
+		// Types unmarshalling themselves accept their own representations, which are checked when unmarshalling.
+		return nil
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if wkt, ok := reflect.New(t).Interface().(wellKnownType); ok {
+		return checkWellKnownType(wkt.XXX_WellKnownType(), node, path)
+	}
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*enum)(nil)).Elem()) {
+		if _, ok := node.(string); ok {
+			return nil
+		}
+		return checkJSONType(node, path, "a string or an integer", isInteger)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return invalidType(node, path, "an object")
+		}
+		fields := jsonFields(t)
+		for _, k := range sortedKeys(m) {
+			f, ok := fields[k]
+			if !ok {
+				errs = append(errs, &ValuesError{
+					Path:    appendPath(path, k),
+					Reason:  UnknownField,
+					Message: fmt.Sprintf("unknown field, not defined in %s", t),
+				})
+				continue
+			}
+			errs = util.AppendErrs(errs, checkValuesSchema(f.Type, m[k], appendPath(path, k)))
+		}
+	case reflect.Map:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return invalidType(node, path, "an object")
+		}
+		for _, k := range sortedKeys(m) {
+			errs = util.AppendErrs(errs, checkValuesSchema(t.Elem(), m[k], appendPath(path, k)))
+		}
+	case reflect.Slice:
+		l, ok := node.([]interface{})
+		if !ok {
+			return invalidType(node, path, "a list")
+		}
+		for i, v := range l {
+			errs = util.AppendErrs(errs, checkValuesSchema(t.Elem(), v, indexPath(path, i)))
+		}
+	case reflect.String:
+		return checkJSONType(node, path, "a string", func(v interface{}) bool {
+			_, ok := v.(string)
+			return ok
+		})
+	case reflect.Bool:
+		return checkJSONType(node, path, "a boolean", isBool)
+	case reflect.Int32, reflect.Int64, reflect.Uint32, reflect.Uint64:
+		return checkJSONType(node, path, "an integer", isInteger)
+	case reflect.Float32, reflect.Float64:
+		return checkJSONType(node, path, "a number", isNumber)
+	}
+	// Interfaces hold any value.
+	return errs
+}
+
+func checkWellKnownType(name string, node interface{}, path util.Path) util.Errors {
+	switch name {
+	case "BoolValue":
+		return checkJSONType(node, path, "a boolean", isBool)
+	case "Int32Value", "Int64Value", "UInt32Value", "UInt64Value":
+		return checkJSONType(node, path, "an integer", isInteger)
+	case "FloatValue", "DoubleValue":
+		return checkJSONType(node, path, "a number", isNumber)
+	case "StringValue", "Duration", "Timestamp":
+		return checkJSONType(node, path, "a string", func(v interface{}) bool {
+			_, ok := v.(string)
+			return ok
+		})
+	case "Struct":
+		if _, ok := node.(map[string]interface{}); !ok {
+			return invalidType(node, path, "an object")
+		}
+	}
+	return nil
+}
+
+// jsonFields returns the fields of the struct type t by the names accepted in JSON, which are both the original
+// protobuf field name and its JSON name.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if strings.HasPrefix(f.Name, "XXX_") {
+			continue
+		}
+		for _, opt := range strings.Split(f.Tag.Get("protobuf"), ",") {
+			if strings.HasPrefix(opt, "name=") {
+				fields[strings.TrimPrefix(opt, "name=")] = f
+			} else if strings.HasPrefix(opt, "json=") {
+				fields[strings.TrimPrefix(opt, "json=")] = f
+			}
+		}
+	}
+	return fields
+}
+
+func checkJSONType(node interface{}, path util.Path, want string, ok func(interface{}) bool) util.Errors {
+	if ok(node) {
+		return nil
+	}
+	return invalidType(node, path, want)
+}
+
+func invalidType(node interface{}, path util.Path, want string) util.Errors {
+	got := "a " + reflect.TypeOf(node).String()
+	switch v := node.(type) {
+	case string:
+		got = fmt.Sprintf("string %q", v)
+	case bool:
+		got = fmt.Sprintf("boolean %v", v)
+	case float64:
+		got = fmt.Sprintf("number %v", v)
+	case map[string]interface{}:
+		got = "an object"
+	case []interface{}:
+		got = "a list"
+	}
+	return util.Errors{&ValuesError{
+		Path:    path,
+		Reason:  InvalidType,
+		Message: fmt.Sprintf("expected %s, got %s", want, got),
+	}}
+}
+
+func isBool(v interface{}) bool {
+	_, ok := v.(bool)
+	return ok
+}
+
+// isNumber and isInteger also accept numbers in strings, like the protobuf JSON mapping.
+func isNumber(v interface{}) bool {
+	switch n := v.(type) {
+	case float64:
+		return true
+	case string:
+		_, err := strconv.ParseFloat(n, 64)
+		return err == nil
+	}
+	return false
+}
+
+func isInteger(v interface{}) bool {
+	switch n := v.(type) {
+	case float64:
+		return n == math.Trunc(n)
+	case string:
+		_, err := strconv.ParseInt(n, 10, 64)
+		return err == nil
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func appendPath(path util.Path, key string) util.Path {
+	out := make(util.Path, 0, len(path)+1)
+	return append(append(out, path...), key)
+}
+
+// indexPath returns the path of the i-th element of the list at path, like a.b[1].
+func indexPath(path util.Path, i int) util.Path {
+	out := append(util.Path{}, path...)
+	if len(out) == 0 {
+		return util.Path{fmt.Sprintf("[%d]", i)}
+	}
+	out[len(out)-1] = fmt.Sprintf("%s[%d]", out[len(out)-1], i)
+	return out
+}
@@ -0,0 +1,114 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/operator/pkg/util"
+)
+
+func TestCheckValuesSchema(t *testing.T) {
+	tests := []struct {
+		desc     string
+		yamlStr  string
+		wantErrs util.Errors
+	}{
+		{
+			desc: "valid",
+			yamlStr: `
+global:
+  hub: docker.io/istio
+  logAsJson: true
+  proxy:
+    holdApplicationUntilProxyStarts: true
+    lifecycle:
+      preStop: {}
+  imagePullSecrets: [a, b]
+pilot:
+  replicaCount: 2
+  autoscaleMin: "1"
+  rollingMaxSurge: 50%
+  env:
+    FOO: bar
+meshConfig:
+  anything: goes
+gateways:
+  istio-ingressgateway:
+    ports:
+    - port: 80
+      name: http
+`,
+		},
+		{
+			desc: "all errors",
+			yamlStr: `
+global:
+  proxy:
+    foo: bar
+  hub: 1
+pilot:
+  replicaCount: two
+  enabled: 1
+gateways:
+  istio-ingressgateway:
+    ports:
+    - port: 80
+      bogus: 1
+cni:
+  cniBinDir: [a]
+unknown: {}
+`,
+			wantErrs: makeErrors([]string{
+				`cni.cniBinDir: expected a string, got a list`,
+				`gateways.istio-ingressgateway.ports[0].bogus: unknown field, not defined in v1alpha1.PortsConfig`,
+				`global.hub: expected a string, got number 1`,
+				`global.proxy.foo: unknown field, not defined in v1alpha1.ProxyConfig`,
+				`pilot.enabled: expected a boolean, got number 1`,
+				`pilot.replicaCount: expected an integer, got string "two"`,
+				`unknown: unknown field, not defined in v1alpha1.Values`,
+			}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			root := make(map[string]interface{})
+			if err := yaml.Unmarshal([]byte(tt.yamlStr), &root); err != nil {
+				t.Fatal(err)
+			}
+			errs := CheckValuesSchema(root)
+			if !util.EqualErrors(errs, tt.wantErrs) {
+				t.Errorf("got errors:\n%s\nwant:\n%s", errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestValuesError(t *testing.T) {
+	errs := CheckValuesSchema(map[string]interface{}{"global": map[string]interface{}{"foo": "bar"}})
+	if len(errs) != 1 {
+		t.Fatalf("expected one error, got %v", errs)
+	}
+	var verr *ValuesError
+	if !errors.As(errs[0], &verr) {
+		t.Fatalf("expected a ValuesError, got %T", errs[0])
+	}
+	if verr.Reason != UnknownField || verr.Path.String() != "global.foo" {
+		t.Fatalf("unexpected error %+v", verr)
+	}
+}
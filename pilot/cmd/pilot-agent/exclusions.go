@@ -0,0 +1,69 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/network"
+	"istio.io/istio/pkg/bootstrap"
+	"istio.io/istio/pkg/config/constants"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+	"istio.io/istio/tools/istio-iptables/pkg/exclusions"
+	"istio.io/pkg/filewatcher"
+	"istio.io/pkg/log"
+)
+
+// initTrafficExclusions creates the reconciler of the traffic interception exclusions, and keeps
+// it in sync with the exclusion annotations of the pod.
+func initTrafficExclusions(ctx context.Context, proxy *model.Proxy, proxyConfig *meshconfig.ProxyConfig) *exclusions.Reconciler {
+	reconciler := exclusions.NewReconciler(&dep.RealDependencies{},
+		proxyConfig.InterceptionMode == meshconfig.ProxyConfig_TPROXY, network.IsIPv6Proxy(proxy.IPAddresses))
+	go watchTrafficExclusionAnnotations(ctx, reconciler, constants.PodInfoAnnotationsPath)
+	return reconciler
+}
+
+func watchTrafficExclusionAnnotations(ctx context.Context, reconciler *exclusions.Reconciler, path string) {
+	update := func() {
+		annotations, err := bootstrap.ReadPodAnnotations(path)
+		if err != nil {
+			log.Warnf("failed to read pod annotations for traffic exclusions: %v", err)
+			return
+		}
+		if err := reconciler.Set(exclusions.SourceAnnotations, exclusions.FromAnnotations(annotations)); err != nil {
+			log.Errorf("failed to update traffic exclusions from pod annotations: %v", err)
+		}
+	}
+	update()
+
+	watcher := filewatcher.NewWatcher()
+	defer watcher.Close()
+	if err := watcher.Add(path); err != nil {
+		log.Warnf("failed to watch pod annotations %s, traffic exclusions will only be updated through the API: %v", path, err)
+		return
+	}
+	for {
+		select {
+		case <-watcher.Events(path):
+			update()
+		case err := <-watcher.Errors(path):
+			log.Warnf("error watching pod annotations %s: %v", path, err)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -39,6 +39,7 @@ import (
 	"istio.io/istio/security/pkg/stsservice/tokenmanager"
 	cleaniptables "istio.io/istio/tools/istio-clean-iptables/pkg/cmd"
 	iptables "istio.io/istio/tools/istio-iptables/pkg/cmd"
+	"istio.io/istio/tools/istio-iptables/pkg/exclusions"
 	iptableslog "istio.io/istio/tools/istio-iptables/pkg/log"
 	"istio.io/pkg/collateral"
 	"istio.io/pkg/log"
@@ -127,6 +128,9 @@ var (
 				proxyConfig.ProxyBootstrapTemplatePath = templateFile
 			}
 
+			if outlierLogPath == "" {
+				outlierLogPath = options.OutlierLogPathEnv
+			}
 			envoyOptions := envoy.ProxyConfig{
 				LogLevel:          proxyLogLevel,
 				ComponentLogLevel: proxyComponentLogLevel,
@@ -140,9 +144,14 @@ var (
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
 
+			var trafficExclusions *exclusions.Reconciler
+			if options.DynamicTrafficExclusions {
+				trafficExclusions = initTrafficExclusions(ctx, proxy, proxyConfig)
+			}
+
 			// If a status port was provided, start handling status probes.
 			if proxyConfig.StatusPort > 0 {
-				if err := initStatusServer(ctx, proxy, proxyConfig, agentOptions.EnvoyPrometheusPort, agent); err != nil {
+				if err := initStatusServer(ctx, proxy, proxyConfig, agentOptions.EnvoyPrometheusPort, agent, trafficExclusions); err != nil {
 					return err
 				}
 			}
@@ -187,7 +196,7 @@ func init() {
 	proxyCmd.PersistentFlags().StringVar(&templateFile, "templateFile", "",
 		"Go template bootstrap config")
 	proxyCmd.PersistentFlags().StringVar(&outlierLogPath, "outlierLogPath", "",
-		"The log path for outlier detection")
+		"The log path for outlier detection. Defaults to the OUTLIER_LOG_PATH environment variable")
 
 	// Attach the Istio logging options to the command.
 	loggingOptions.AttachCobraFlags(rootCmd)
@@ -207,10 +216,12 @@ func init() {
 }
 
 func initStatusServer(ctx context.Context, proxy *model.Proxy, proxyConfig *meshconfig.ProxyConfig,
-	envoyPrometheusPort int, agent *istio_agent.Agent) error {
+	envoyPrometheusPort int, agent *istio_agent.Agent, trafficExclusions *exclusions.Reconciler) error {
 	o := options.NewStatusServerOptions(proxy, proxyConfig, agent)
 	o.EnvoyPrometheusPort = envoyPrometheusPort
 	o.Context = ctx
+	o.TrafficExclusions = trafficExclusions
+	o.OutlierLogPath = outlierLogPath
 	statusServer, err := status.NewServer(*o)
 	if err != nil {
 		return err
@@ -0,0 +1,199 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	ocprom "contrib.go.opencensus.io/exporter/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+	"go.opencensus.io/stats/view"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	istiogrpc "istio.io/istio/pilot/pkg/grpc"
+	"istio.io/istio/pkg/cmd"
+	istiokeepalive "istio.io/istio/pkg/keepalive"
+	"istio.io/istio/pkg/nodecache"
+	"istio.io/istio/security/pkg/server/ca/authenticate"
+	"istio.io/pkg/log"
+	"istio.io/pkg/version"
+)
+
+var (
+	nodeCacheDiscoveryAddress string
+	nodeCachePort             int
+	nodeCacheMonitoringPort   int
+	nodeCacheRootCert         string
+	nodeCacheIstiodSAN        string
+	nodeCacheCertChain        string
+	nodeCacheKey              string
+	nodeCacheTTL              time.Duration
+
+	nodeCacheCmd = &cobra.Command{
+		Use:               "node-cache",
+		Short:             "Node-local xDS cache, sharing the streams of the proxies on the node to Istiod",
+		PersistentPreRunE: configureLogging,
+		RunE: func(c *cobra.Command, args []string) error {
+			cmd.PrintFlags(c.Flags())
+			log.Infof("Version %s", version.Info.String())
+
+			roots, err := nodeCacheRoots()
+			if err != nil {
+				return err
+			}
+			creds, err := nodeCacheCredentials(roots)
+			if err != nil {
+				return err
+			}
+			serverCreds, err := nodeCacheServerCredentials(roots)
+			if err != nil {
+				return err
+			}
+			server, err := nodecache.NewServer(nodecache.Options{
+				IstiodAddress: nodeCacheDiscoveryAddress,
+				DialOptions:   []grpc.DialOption{grpc.WithTransportCredentials(creds)},
+				CacheTTL:      nodeCacheTTL,
+				Authenticator: &authenticate.ClientCertAuthenticator{},
+			})
+			if err != nil {
+				return err
+			}
+			grpcServer := grpc.NewServer(append(istiogrpc.ServerOptions(istiokeepalive.DefaultOption()), grpc.Creds(serverCreds))...)
+			server.Register(grpcServer)
+
+			listener, err := net.Listen("tcp", fmt.Sprintf(":%d", nodeCachePort))
+			if err != nil {
+				return fmt.Errorf("failed to listen on port %d: %v", nodeCachePort, err)
+			}
+			if err := serveNodeCacheMetrics(); err != nil {
+				return err
+			}
+
+			stop := make(chan struct{})
+			go server.Run(stop)
+			go func() {
+				if err := grpcServer.Serve(listener); err != nil {
+					log.Errorf("node cache stopped serving: %v", err)
+				}
+			}()
+			log.Infof("Node cache listening on %s, connecting to %s", listener.Addr(), nodeCacheDiscoveryAddress)
+
+			// On SIGINT or SIGTERM, stop serving and close the connection to Istiod.
+			cmd.WaitSignal(stop)
+			grpcServer.Stop()
+			return nil
+		},
+	}
+)
+
+func nodeCacheRoots() (*x509.CertPool, error) {
+	rootCert, err := os.ReadFile(nodeCacheRootCert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read root certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(rootCert) {
+		return nil, fmt.Errorf("failed to parse root certificate %s", nodeCacheRootCert)
+	}
+	return pool, nil
+}
+
+// nodeCacheServerCredentials returns the mTLS credentials the proxies connect with. Proxies present their workload
+// certificate, so the node cache only forwards the token of a proxy on a stream authenticated as the same workload.
+func nodeCacheServerCredentials(roots *x509.CertPool) (credentials.TransportCredentials, error) {
+	if nodeCacheCertChain == "" || nodeCacheKey == "" {
+		return nil, fmt.Errorf("--certChain and --key are required to serve the proxies over mTLS")
+	}
+	cert, err := tls.LoadX509KeyPair(nodeCacheCertChain, nodeCacheKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the node cache certificate: %v", err)
+	}
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    roots,
+		MinVersion:   tls.VersionTLS12,
+	}), nil
+}
+
+// nodeCacheCredentials returns the TLS credentials of the connection to Istiod. The node cache does not present a
+// client certificate: each stream is authenticated by the token of its proxy.
+func nodeCacheCredentials(pool *x509.CertPool) (credentials.TransportCredentials, error) {
+	serverName := nodeCacheIstiodSAN
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(nodeCacheDiscoveryAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid discovery address %s: %v", nodeCacheDiscoveryAddress, err)
+		}
+		serverName = host
+	}
+	return credentials.NewTLS(&tls.Config{
+		RootCAs:    pool,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}), nil
+}
+
+func serveNodeCacheMetrics() error {
+	if nodeCacheMonitoringPort == 0 {
+		return nil
+	}
+	exporter, err := ocprom.NewExporter(ocprom.Options{Registry: prometheus.DefaultRegisterer.(*prometheus.Registry)})
+	if err != nil {
+		return fmt.Errorf("could not set up prometheus exporter: %v", err)
+	}
+	view.RegisterExporter(exporter)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", nodeCacheMonitoringPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen on monitoring port %d: %v", nodeCacheMonitoringPort, err)
+	}
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Errorf("monitoring server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+func init() {
+	nodeCacheCmd.PersistentFlags().StringVar(&nodeCacheDiscoveryAddress, "discoveryAddress", "istiod.istio-system.svc:15012",
+		"Address of the Istiod xDS server")
+	nodeCacheCmd.PersistentFlags().IntVar(&nodeCachePort, "port", 15013,
+		"Port to serve the xDS streams of the proxies on the node")
+	nodeCacheCmd.PersistentFlags().IntVar(&nodeCacheMonitoringPort, "monitoringPort", 15014,
+		"Port to serve Prometheus metrics on, or 0 to disable")
+	nodeCacheCmd.PersistentFlags().StringVar(&nodeCacheRootCert, "rootCert", "./var/run/secrets/istio/root-cert.pem",
+		"Path of the root certificate used to verify Istiod and the workload certificates of the proxies")
+	nodeCacheCmd.PersistentFlags().StringVar(&nodeCacheCertChain, "certChain", "./var/run/secrets/node-cache/tls.crt",
+		"Path of the certificate chain the node cache serves the proxies with")
+	nodeCacheCmd.PersistentFlags().StringVar(&nodeCacheKey, "key", "./var/run/secrets/node-cache/tls.key",
+		"Path of the private key of the node cache certificate")
+	nodeCacheCmd.PersistentFlags().StringVar(&nodeCacheIstiodSAN, "istiodSAN", "",
+		"Override the name used to verify the Istiod certificate. Defaults to the host of the discovery address")
+	nodeCacheCmd.PersistentFlags().DurationVar(&nodeCacheTTL, "cacheTTL", 5*time.Minute,
+		"How long the configuration of a disconnected proxy is kept, to be served if it reconnects while Istiod is unavailable")
+
+	rootCmd.AddCommand(nodeCacheCmd)
+}
@@ -15,8 +15,10 @@
 package options
 
 import (
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
@@ -57,6 +59,12 @@ func NewAgentOptions(proxy *model.Proxy, cfg *meshconfig.ProxyConfig) *istioagen
 		ProxyNamespace:              PodNamespaceVar.Get(),
 		ProxyDomain:                 proxy.DNSDomain,
 		IstiodSAN:                   istiodSAN.Get(),
+		XDSAffinityRebalance:        xdsAffinityRebalanceEnv,
+		XDSCompression:              xdsCompressionEnv,
+	}
+	if hostIP := hostIPVar.Get(); hostIP != "" && xdsNodeCachePortEnv != 0 {
+		o.XDSNodeCacheAddress = net.JoinHostPort(hostIP, strconv.Itoa(xdsNodeCachePortEnv))
+		o.XDSNodeCacheSAN = xdsNodeCacheSANEnv
 	}
 	extractXDSHeadersFromEnv(o)
 	return o
@@ -77,9 +77,18 @@ var (
 		"The grace period ratio for the cert rotation, by default 0.5.").Get()
 	pkcs8KeysEnv = env.RegisterBoolVar("PKCS8_KEY", false,
 		"Whether to generate PKCS#8 private keys").Get()
-	eccSigAlgEnv        = env.RegisterStringVar("ECC_SIGNATURE_ALGORITHM", "", "The type of ECC signature algorithm to use when generating private keys").Get()
+	eccSigAlgEnv  = env.RegisterStringVar("ECC_SIGNATURE_ALGORITHM", "", "The type of ECC signature algorithm to use when generating private keys").Get()
+	rsaKeySizeEnv = env.RegisterIntVar("RSA_KEY_SIZE", 2048,
+		"The size of the RSA private key generated for the workload certificate").Get()
 	fileMountedCertsEnv = env.RegisterBoolVar("FILE_MOUNTED_CERTS", false, "").Get()
-	credFetcherTypeEnv  = env.RegisterStringVar("CREDENTIAL_FETCHER_TYPE", security.JWT,
+	spireSocketEnv      = env.RegisterStringVar("SPIRE_AGENT_SOCKET", "",
+		"The path of the SPIRE agent socket. If set, workload certificates and the trust bundle are fetched "+
+			"from the SPIRE agent over SDS, instead of from the CA.").Get()
+	spireSVIDNameEnv = env.RegisterStringVar("SPIRE_SVID_NAME", security.WorkloadKeyCertResourceName,
+		"The name of the SVID in the SPIRE agent used as the workload certificate.").Get()
+	spireBundleNameEnv = env.RegisterStringVar("SPIRE_BUNDLE_NAME", security.RootCertReqResourceName,
+		"The name of the trust bundle in the SPIRE agent used as the root certificate.").Get()
+	credFetcherTypeEnv = env.RegisterStringVar("CREDENTIAL_FETCHER_TYPE", security.JWT,
 		"The type of the credential fetcher. Currently supported types include GoogleComputeEngine").Get()
 	credIdentityProvider = env.RegisterStringVar("CREDENTIAL_IDENTITY_PROVIDER", "GoogleComputeEngine",
 		"The identity provider for credential. Currently default supported identity provider is GoogleComputeEngine").Get()
@@ -106,6 +115,11 @@ var (
 	enableBootstrapXdsEnv = env.RegisterBoolVar("BOOTSTRAP_XDS_AGENT", false,
 		"If set to true, agent retrieves the bootstrap configuration prior to starting Envoy").Get()
 
+	// OutlierLogPathEnv is the outlier detection event log path, if not set through the flag.
+	OutlierLogPathEnv = env.RegisterStringVar("OUTLIER_LOG_PATH", "",
+		"The file to which Envoy logs the outlier detection events, if the --outlierLogPath flag is not set. "+
+			"The events are aggregated into metrics and served on the /debug/outliers endpoint of the status port.").Get()
+
 	envoyStatusPortEnv = env.RegisterIntVar("ENVOY_STATUS_PORT", 15021,
 		"Envoy health status port value").Get()
 	envoyPrometheusPortEnv = env.RegisterIntVar("ENVOY_PROMETHEUS_PORT", 15090,
@@ -134,4 +148,27 @@ var (
 	exitOnZeroActiveConnectionsEnv = env.RegisterBoolVar("EXIT_ON_ZERO_ACTIVE_CONNECTIONS",
 		false,
 		"When set to true, terminates proxy when number of active connections become zero during draining").Get()
+
+	DynamicTrafficExclusions = env.RegisterBoolVar("ISTIO_AGENT_DYNAMIC_TRAFFIC_EXCLUSIONS", false,
+		"If enabled, the agent updates the traffic interception exclusions at runtime when the "+
+			"traffic.sidecar.istio.io exclusion annotations change, or through the /traffic/exclusions "+
+			"endpoint of the status port. This requires the proxy to run with the NET_ADMIN capability.").Get()
+
+	hostIPVar = env.RegisterStringVar("HOST_IP", "", "The IP address of the node running the proxy")
+
+	xdsNodeCachePortEnv = env.RegisterIntVar("XDS_NODE_CACHE_PORT", 0,
+		"If set, the agent connects to the node-local xDS cache listening on this port of the node (HOST_IP), "+
+			"instead of connecting to Istiod directly. The agent authenticates to the node cache with its workload certificate.").Get()
+
+	xdsNodeCacheSANEnv = env.RegisterStringVar("XDS_NODE_CACHE_SAN", "istio-node-cache.istio-system.svc",
+		"The name used to verify the certificate of the node-local xDS cache.").Get()
+
+	xdsAffinityRebalanceEnv = env.RegisterBoolVar("XDS_AFFINITY_REBALANCE", false,
+		"If set to true, the agent honors the affinity hints of Istiod, enabled with PILOT_XDS_AFFINITY_HINT_CAPACITY, "+
+			"by reconnecting to another Istiod replica when the one it is connected to is over capacity.").Get()
+
+	xdsCompressionEnv = env.RegisterStringVar("XDS_COMPRESSION", "",
+		"If set to gzip or zstd, the agent compresses the xDS payloads exchanged with Istiod with this algorithm, "+
+			"reducing the bandwidth of large configurations at the cost of CPU. It is only used once Istiod "+
+			"advertised it, from the next connection, and the agent falls back to no compression otherwise.").Get()
 )
@@ -0,0 +1,267 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package exemplars records the traced requests Envoy sends through its access log service, and adds
+// them as OpenMetrics exemplars to the request duration histograms scraped from Envoy.
+package exemplars
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	accesslogdata "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+	"google.golang.org/grpc"
+
+	"istio.io/istio/pkg/uds"
+	"istio.io/pkg/log"
+)
+
+const (
+	// requestDurationBucket is the name of the buckets of the request duration histogram.
+	requestDurationBucket = "istio_request_duration_milliseconds_bucket"
+
+	// exemplarsPerSeries is the number of recent requests kept for each series.
+	exemplarsPerSeries = 32
+)
+
+// Exemplar is a traced request.
+type Exemplar struct {
+	TraceID string
+	// Value is the duration of the request in milliseconds.
+	Value     float64
+	Timestamp time.Time
+}
+
+// key identifies the series an exemplar belongs to. The access logs do not carry all the labels of the
+// request duration histogram, so the exemplars are matched with the labels that can be derived from them.
+type key struct {
+	reporter           string
+	destinationService string
+	responseCode       string
+}
+
+// Store holds the most recent exemplars of each series.
+type Store struct {
+	mu        sync.RWMutex
+	exemplars map[key][]Exemplar
+}
+
+// NewStore creates an empty store.
+func NewStore() *Store {
+	return &Store{exemplars: map[key][]Exemplar{}}
+}
+
+func (s *Store) record(k key, e Exemplar) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	es := append(s.exemplars[k], e)
+	if len(es) > exemplarsPerSeries {
+		es = es[len(es)-exemplarsPerSeries:]
+	}
+	s.exemplars[k] = es
+}
+
+// latest returns the most recent exemplar of the series in the (lower, upper] bucket.
+func (s *Store) latest(k key, lower, upper float64) (Exemplar, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	es := s.exemplars[k]
+	for i := len(es) - 1; i >= 0; i-- {
+		if es[i].Value > lower && es[i].Value <= upper {
+			return es[i], true
+		}
+	}
+	return Exemplar{}, false
+}
+
+// StreamAccessLogs implements the Envoy access log service, recording the traced requests.
+func (s *Store) StreamAccessLogs(stream accesslog.AccessLogService_StreamAccessLogsServer) error {
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		for _, entry := range msg.GetHttpLogs().GetLogEntry() {
+			if k, e, ok := fromAccessLog(entry); ok {
+				s.record(k, e)
+			}
+		}
+	}
+}
+
+var _ accesslog.AccessLogServiceServer = &Store{}
+
+func fromAccessLog(entry *accesslogdata.HTTPAccessLogEntry) (key, Exemplar, bool) {
+	traceID := traceID(entry.GetRequest().GetRequestHeaders())
+	common := entry.GetCommonProperties()
+	if traceID == "" || common.GetTimeToLastDownstreamTxByte() == nil {
+		return key{}, Exemplar{}, false
+	}
+	k := key{
+		reporter:     "source",
+		responseCode: strconv.Itoa(int(entry.GetResponse().GetResponseCode().GetValue())),
+	}
+	// Clusters are named direction|port|subset|hostname.
+	parts := strings.Split(common.GetUpstreamCluster(), "|")
+	if parts[0] == "inbound" {
+		k.reporter = "destination"
+	} else if len(parts) == 4 {
+		k.destinationService = parts[3]
+	}
+	e := Exemplar{
+		TraceID:   traceID,
+		Value:     float64(common.GetTimeToLastDownstreamTxByte().AsDuration()) / float64(time.Millisecond),
+		Timestamp: time.Now(),
+	}
+	if common.GetStartTime() != nil {
+		e.Timestamp = common.GetStartTime().AsTime().Add(common.GetTimeToLastDownstreamTxByte().AsDuration())
+	}
+	return k, e, true
+}
+
+// traceID returns the ID of the trace of a sampled request, from the W3C or B3 headers.
+func traceID(headers map[string]string) string {
+	if tp := strings.Split(headers["traceparent"], "-"); len(tp) == 4 {
+		// version-traceid-parentid-flags, the last bit of the flags is the sampled flag.
+		if flags, err := strconv.ParseUint(tp[3], 16, 8); err == nil && flags&1 == 1 {
+			return tp[1]
+		}
+		return ""
+	}
+	if id := headers["x-b3-traceid"]; id != "" && headers["x-b3-sampled"] != "0" {
+		return id
+	}
+	return ""
+}
+
+// Annotate adds exemplars to the buckets of the request duration histograms of the Envoy metrics, which
+// must be in the Prometheus text format. The result is only valid in the OpenMetrics format.
+func (s *Store) Annotate(metrics []byte) []byte {
+	var out bytes.Buffer
+	out.Grow(len(metrics))
+	var series string
+	lower := math.Inf(-1)
+	for len(metrics) > 0 {
+		line := metrics
+		if i := bytes.IndexByte(metrics, '\n'); i >= 0 {
+			line, metrics = metrics[:i], metrics[i+1:]
+		} else {
+			metrics = nil
+		}
+		out.Write(line)
+		if bytes.HasPrefix(line, []byte(requestDurationBucket+"{")) {
+			if labels, ok := parseLabels(line[len(requestDurationBucket):]); ok {
+				upper, err := strconv.ParseFloat(labels["le"], 64)
+				if current := seriesOf(line); current != series {
+					series, lower = current, math.Inf(-1)
+				}
+				if err == nil {
+					k := key{reporter: labels["reporter"], responseCode: labels["response_code"]}
+					if k.reporter == "source" {
+						k.destinationService = labels["destination_service"]
+					}
+					if e, f := s.latest(k, lower, upper); f {
+						fmt.Fprintf(&out, " # {trace_id=%q} %s %.3f", e.TraceID,
+							strconv.FormatFloat(e.Value, 'f', -1, 64), float64(e.Timestamp.UnixNano())/1e9)
+					}
+					lower = upper
+				}
+			}
+		}
+		if metrics != nil {
+			out.WriteByte('\n')
+		}
+	}
+	return out.Bytes()
+}
+
+// seriesOf returns the labels of a bucket line, except the le label.
+func seriesOf(line []byte) string {
+	end := bytes.LastIndexByte(line, '}')
+	if end < 0 {
+		return ""
+	}
+	labels := string(line[:end])
+	if i := strings.LastIndex(labels, "le=\""); i >= 0 {
+		labels = labels[:i]
+	}
+	return labels
+}
+
+// parseLabels parses the labels of a sample, in the {name="value",...} form.
+func parseLabels(b []byte) (map[string]string, bool) {
+	if len(b) == 0 || b[0] != '{' {
+		return nil, false
+	}
+	labels := map[string]string{}
+	i := 1
+	for i < len(b) && b[i] != '}' {
+		eq := bytes.IndexByte(b[i:], '=')
+		if eq < 0 || i+eq+1 >= len(b) || b[i+eq+1] != '"' {
+			return nil, false
+		}
+		name := string(b[i : i+eq])
+		i += eq + 2
+		var value strings.Builder
+		for ; i < len(b) && b[i] != '"'; i++ {
+			if b[i] == '\\' && i+1 < len(b) {
+				i++
+				if b[i] == 'n' {
+					value.WriteByte('\n')
+					continue
+				}
+			}
+			value.WriteByte(b[i])
+		}
+		if i >= len(b) {
+			return nil, false
+		}
+		labels[name] = value.String()
+		i++
+		if i < len(b) && b[i] == ',' {
+			i++
+		}
+	}
+	if i >= len(b) {
+		return nil, false
+	}
+	return labels, true
+}
+
+// Serve runs the access log service on the unix socket at path, until the context is done.
+func (s *Store) Serve(ctx context.Context, path string) error {
+	l, err := uds.NewListener(path)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer()
+	accesslog.RegisterAccessLogServiceServer(server, s)
+	go func() {
+		<-ctx.Done()
+		server.Stop()
+	}()
+	go func() {
+		if err := server.Serve(l); err != nil {
+			log.Warnf("exemplars access log service stopped: %v", err)
+		}
+	}()
+	return nil
+}
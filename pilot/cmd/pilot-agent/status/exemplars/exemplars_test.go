@@ -0,0 +1,122 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exemplars
+
+import (
+	"testing"
+	"time"
+
+	accesslogdata "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func accessLog(cluster string, code uint32, duration time.Duration, headers map[string]string) *accesslogdata.HTTPAccessLogEntry {
+	return &accesslogdata.HTTPAccessLogEntry{
+		CommonProperties: &accesslogdata.AccessLogCommon{
+			UpstreamCluster:            cluster,
+			StartTime:                  timestamppb.New(time.Unix(1650000000, 0)),
+			TimeToLastDownstreamTxByte: durationpb.New(duration),
+		},
+		Request:  &accesslogdata.HTTPRequestProperties{RequestHeaders: headers},
+		Response: &accesslogdata.HTTPResponseProperties{ResponseCode: wrapperspb.UInt32(code)},
+	}
+}
+
+func TestFromAccessLog(t *testing.T) {
+	cases := []struct {
+		name  string
+		entry *accesslogdata.HTTPAccessLogEntry
+		key   key
+		trace string
+	}{
+		{
+			name: "outbound w3c",
+			entry: accessLog("outbound|9080||reviews.default.svc.cluster.local", 200, 30*time.Millisecond,
+				map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}),
+			key:   key{reporter: "source", destinationService: "reviews.default.svc.cluster.local", responseCode: "200"},
+			trace: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:  "inbound b3",
+			entry: accessLog("inbound|8080||", 503, time.Second, map[string]string{"x-b3-traceid": "80f198ee56343ba8", "x-b3-sampled": "1"}),
+			key:   key{reporter: "destination", responseCode: "503"},
+			trace: "80f198ee56343ba8",
+		},
+		{
+			name: "w3c not sampled",
+			entry: accessLog("inbound|8080||", 200, time.Second,
+				map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"}),
+		},
+		{
+			name:  "b3 not sampled",
+			entry: accessLog("inbound|8080||", 200, time.Second, map[string]string{"x-b3-traceid": "80f198ee56343ba8", "x-b3-sampled": "0"}),
+		},
+		{
+			name:  "no trace",
+			entry: accessLog("inbound|8080||", 200, time.Second, nil),
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			k, e, ok := fromAccessLog(tt.entry)
+			if ok != (tt.trace != "") {
+				t.Fatalf("expected exemplar %v, got %v", tt.trace != "", ok)
+			}
+			if !ok {
+				return
+			}
+			if k != tt.key || e.TraceID != tt.trace {
+				t.Fatalf("got %+v %+v, want %+v %v", k, e, tt.key, tt.trace)
+			}
+		})
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	s := NewStore()
+	for _, entry := range []*accesslogdata.HTTPAccessLogEntry{
+		accessLog("outbound|9080||reviews.default.svc.cluster.local", 200, 30*time.Millisecond,
+			map[string]string{"x-b3-traceid": "slow"}),
+		accessLog("outbound|9080||reviews.default.svc.cluster.local", 200, 3*time.Millisecond,
+			map[string]string{"x-b3-traceid": "fast"}),
+		accessLog("outbound|9080||ratings.default.svc.cluster.local", 200, 3*time.Millisecond,
+			map[string]string{"x-b3-traceid": "ratings"}),
+	} {
+		k, e, _ := fromAccessLog(entry)
+		s.record(k, e)
+	}
+
+	in := `# TYPE istio_request_duration_milliseconds histogram
+istio_request_duration_milliseconds_bucket{reporter="source",destination_service="reviews.default.svc.cluster.local",response_code="200",le="1"} 0
+istio_request_duration_milliseconds_bucket{reporter="source",destination_service="reviews.default.svc.cluster.local",response_code="200",le="5"} 1
+istio_request_duration_milliseconds_bucket{reporter="source",destination_service="reviews.default.svc.cluster.local",response_code="200",le="50"} 2
+istio_request_duration_milliseconds_bucket{reporter="source",destination_service="reviews.default.svc.cluster.local",response_code="200",le="+Inf"} 2
+istio_request_duration_milliseconds_bucket{reporter="source",destination_service="reviews.default.svc.cluster.local",response_code="503",le="5"} 1
+istio_request_duration_milliseconds_sum{reporter="source",destination_service="reviews.default.svc.cluster.local",response_code="200"} 33
+`
+	want := `# TYPE istio_request_duration_milliseconds histogram
+istio_request_duration_milliseconds_bucket{reporter="source",destination_service="reviews.default.svc.cluster.local",response_code="200",le="1"} 0
+istio_request_duration_milliseconds_bucket{reporter="source",destination_service="reviews.default.svc.cluster.local",response_code="200",le="5"} 1 # {trace_id="fast"} 3 1650000000.003
+istio_request_duration_milliseconds_bucket{reporter="source",destination_service="reviews.default.svc.cluster.local",response_code="200",le="50"} 2 # {trace_id="slow"} 30 1650000000.030
+istio_request_duration_milliseconds_bucket{reporter="source",destination_service="reviews.default.svc.cluster.local",response_code="200",le="+Inf"} 2
+istio_request_duration_milliseconds_bucket{reporter="source",destination_service="reviews.default.svc.cluster.local",response_code="503",le="5"} 1
+istio_request_duration_milliseconds_sum{reporter="source",destination_service="reviews.default.svc.cluster.local",response_code="200"} 33
+`
+	if got := string(s.Annotate([]byte(in))); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
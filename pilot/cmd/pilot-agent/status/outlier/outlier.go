@@ -0,0 +1,294 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package outlier aggregates the outlier detection events Envoy logs, so that the endpoints which keep being
+// ejected, and why, are surfaced through metrics and a debug endpoint.
+package outlier
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	clusterdata "github.com/envoyproxy/go-control-plane/envoy/data/cluster/v3"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"istio.io/pkg/log"
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	serviceTag  = monitoring.MustCreateLabel("destination_service")
+	subsetTag   = monitoring.MustCreateLabel("destination_subset")
+	typeTag     = monitoring.MustCreateLabel("ejection_type")
+	enforcedTag = monitoring.MustCreateLabel("enforced")
+	clusterTag  = monitoring.MustCreateLabel("cluster_name")
+
+	ejections = monitoring.NewSum(
+		"outlier_ejections_total",
+		"The total number of endpoints ejected by outlier detection.",
+		monitoring.WithLabels(serviceTag, subsetTag, typeTag, enforcedTag),
+	)
+
+	ejectedEndpoints = monitoring.NewGauge(
+		"outlier_ejected_endpoints",
+		"The number of endpoints of a cluster currently ejected by outlier detection.",
+		monitoring.WithLabels(clusterTag, serviceTag, subsetTag),
+	)
+)
+
+// defaultRetention is the time after which the endpoints without any new event are forgotten, as Envoy does not
+// log the endpoints removed from a cluster.
+const defaultRetention = time.Hour
+
+func init() {
+	monitoring.MustRegister(ejections, ejectedEndpoints)
+}
+
+// Endpoint is the outlier detection history of an endpoint.
+type Endpoint struct {
+	Address string `json:"address"`
+	// Ejected is whether the endpoint is currently ejected.
+	Ejected bool `json:"ejected"`
+	// Ejections is the number of times the endpoint was ejected, by ejection type.
+	Ejections map[string]int `json:"ejections"`
+	// LastEjectionType is the reason of the last ejection of the endpoint.
+	LastEjectionType string     `json:"lastEjectionType,omitempty"`
+	LastEjection     *time.Time `json:"lastEjection,omitempty"`
+	LastUnejection   *time.Time `json:"lastUnejection,omitempty"`
+
+	// lastEvent is the time the last event of the endpoint was recorded.
+	lastEvent time.Time
+}
+
+// Cluster is the outlier detection history of the endpoints of a cluster.
+type Cluster struct {
+	Name      string      `json:"name"`
+	Service   string      `json:"service"`
+	Subset    string      `json:"subset,omitempty"`
+	Endpoints []*Endpoint `json:"endpoints"`
+}
+
+// Aggregator records the outlier detection events of the clusters.
+type Aggregator struct {
+	mu       sync.RWMutex
+	clusters map[string]map[string]*Endpoint
+	// retention is the time after which the endpoints without any new event are forgotten.
+	retention time.Duration
+}
+
+func NewAggregator() *Aggregator {
+	return &Aggregator{clusters: map[string]map[string]*Endpoint{}, retention: defaultRetention}
+}
+
+// Record records an outlier detection event, as logged by Envoy in JSON.
+func (a *Aggregator) Record(line []byte) error {
+	event := &clusterdata.OutlierDetectionEvent{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(line, event); err != nil {
+		return fmt.Errorf("invalid outlier detection event: %v", err)
+	}
+	timestamp := time.Now()
+	if event.GetTimestamp() != nil {
+		timestamp = event.GetTimestamp().AsTime()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	endpoints := a.clusters[event.GetClusterName()]
+	if endpoints == nil {
+		endpoints = map[string]*Endpoint{}
+		a.clusters[event.GetClusterName()] = endpoints
+	}
+	ep := endpoints[event.GetUpstreamUrl()]
+	if ep == nil {
+		ep = &Endpoint{Address: event.GetUpstreamUrl(), Ejections: map[string]int{}}
+		endpoints[event.GetUpstreamUrl()] = ep
+	}
+	ep.lastEvent = time.Now()
+	service, subset := serviceAndSubset(event.GetClusterName())
+	switch event.GetAction() {
+	case clusterdata.Action_EJECT:
+		// the ejections which are not enforced are only logged by Envoy, the endpoint remains in use
+		ep.Ejected = event.GetEnforced()
+		ep.Ejections[event.GetType().String()]++
+		ep.LastEjectionType = event.GetType().String()
+		ep.LastEjection = &timestamp
+		ejections.With(serviceTag.Value(service), subsetTag.Value(subset), typeTag.Value(event.GetType().String()),
+			enforcedTag.Value(fmt.Sprint(event.GetEnforced()))).Increment()
+	case clusterdata.Action_UNEJECT:
+		ep.Ejected = false
+		ep.LastUnejection = &timestamp
+	}
+	recordEjected(event.GetClusterName(), endpoints)
+	return nil
+}
+
+// recordEjected records the number of ejected endpoints of the cluster.
+func recordEjected(cluster string, endpoints map[string]*Endpoint) {
+	ejected := 0
+	for _, ep := range endpoints {
+		if ep.Ejected {
+			ejected++
+		}
+	}
+	service, subset := serviceAndSubset(cluster)
+	ejectedEndpoints.With(clusterTag.Value(cluster), serviceTag.Value(service), subsetTag.Value(subset)).
+		Record(float64(ejected))
+}
+
+// expire forgets the endpoints without any event for longer than the retention, such as the endpoints removed from
+// their cluster, ejected or not.
+func (a *Aggregator) expire(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for cluster, endpoints := range a.clusters {
+		expired := false
+		for addr, ep := range endpoints {
+			if now.Sub(ep.lastEvent) > a.retention {
+				delete(endpoints, addr)
+				expired = true
+			}
+		}
+		if !expired {
+			continue
+		}
+		recordEjected(cluster, endpoints)
+		if len(endpoints) == 0 {
+			delete(a.clusters, cluster)
+		}
+	}
+}
+
+// Clusters returns the outlier detection history of the endpoints of each cluster, sorted by name.
+func (a *Aggregator) Clusters() []Cluster {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	out := make([]Cluster, 0, len(a.clusters))
+	for name, endpoints := range a.clusters {
+		c := Cluster{Name: name, Endpoints: make([]*Endpoint, 0, len(endpoints))}
+		c.Service, c.Subset = serviceAndSubset(name)
+		for _, ep := range endpoints {
+			cp := *ep
+			cp.lastEvent = time.Time{}
+			cp.Ejections = make(map[string]int, len(ep.Ejections))
+			for t, n := range ep.Ejections {
+				cp.Ejections[t] = n
+			}
+			c.Endpoints = append(c.Endpoints, &cp)
+		}
+		sort.Slice(c.Endpoints, func(i, j int) bool { return c.Endpoints[i].Address < c.Endpoints[j].Address })
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// serviceAndSubset returns the service and subset of a cluster named direction|port|subset|hostname, or the
+// name of the cluster as the service otherwise.
+func serviceAndSubset(cluster string) (string, string) {
+	parts := strings.Split(cluster, "|")
+	if len(parts) != 4 {
+		return cluster, ""
+	}
+	return parts[3], parts[2]
+}
+
+// Tail records the events appended to the file at path, polled at the given interval, until the context is done.
+// The file may not exist yet, as Envoy creates it on start, and is reopened once truncated or replaced. The
+// endpoints without any event for longer than the retention are expired along.
+func (a *Aggregator) Tail(ctx context.Context, path string, interval time.Duration) {
+	t := &tailer{path: path, record: a.Record}
+	defer t.close()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		t.poll()
+		a.expire(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type tailer struct {
+	path   string
+	record func(line []byte) error
+
+	file *os.File
+	info os.FileInfo
+	// offset is the offset of the complete lines read from the file
+	offset  int64
+	reader  *bufio.Reader
+	partial []byte
+}
+
+// poll records the complete lines appended to the file since the last poll.
+func (t *tailer) poll() {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("failed reading outlier detection events from %s: %v", t.path, err)
+		}
+		t.close()
+		return
+	}
+	if t.file != nil && (!os.SameFile(info, t.info) || info.Size() < t.offset) {
+		t.close()
+	}
+	if t.file == nil {
+		f, err := os.Open(t.path)
+		if err != nil {
+			log.Warnf("failed reading outlier detection events from %s: %v", t.path, err)
+			return
+		}
+		t.file, t.info, t.reader = f, info, bufio.NewReader(f)
+	}
+	for {
+		line, err := t.reader.ReadBytes('\n')
+		if err != nil {
+			// keep the incomplete line until the rest of it is written
+			t.partial = append(t.partial, line...)
+			if err != io.EOF {
+				log.Warnf("failed reading outlier detection events from %s: %v", t.path, err)
+			}
+			return
+		}
+		t.offset += int64(len(t.partial) + len(line))
+		if len(t.partial) > 0 {
+			line = append(t.partial, line...)
+			t.partial = nil
+		}
+		if line = bytes.TrimSpace(line); len(line) == 0 {
+			continue
+		}
+		if err := t.record(line); err != nil {
+			log.Warnf("failed recording outlier detection event %q: %v", line, err)
+		}
+	}
+}
+
+func (t *tailer) close() {
+	if t.file != nil {
+		_ = t.file.Close()
+	}
+	t.file, t.info, t.reader, t.offset, t.partial = nil, nil, nil, 0, nil
+}
@@ -0,0 +1,177 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package outlier
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const (
+	reviewsV1 = "outbound|9080|v1|reviews.default.svc.cluster.local"
+	ratings   = "outbound|9080||ratings.default.svc.cluster.local"
+)
+
+func event(cluster, addr, action, ejectionType string, enforced bool) string {
+	e := `{"type":"` + ejectionType + `","timestamp":"2022-04-15T05:20:00Z","cluster_name":"` + cluster +
+		`","upstream_url":"` + addr + `","action":"` + action + `","num_ejections":1`
+	if enforced {
+		e += `,"enforced":true`
+	}
+	return e + `,"eject_consecutive_event":{}}` + "\n"
+}
+
+func TestAggregator(t *testing.T) {
+	a := NewAggregator()
+	for _, e := range []string{
+		event(reviewsV1, "10.0.0.1:9080", "EJECT", "CONSECUTIVE_5XX", true),
+		event(reviewsV1, "10.0.0.1:9080", "UNEJECT", "CONSECUTIVE_5XX", true),
+		event(reviewsV1, "10.0.0.1:9080", "EJECT", "CONSECUTIVE_GATEWAY_FAILURE", true),
+		event(reviewsV1, "10.0.0.2:9080", "EJECT", "CONSECUTIVE_5XX", true),
+		event(ratings, "10.0.0.3:9080", "EJECT", "SUCCESS_RATE", false),
+	} {
+		if err := a.Record([]byte(e)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Record([]byte(`{"action":"EXPLODE"}`)); err == nil {
+		t.Fatalf("expected an error recording an invalid event")
+	}
+
+	ts := time.Date(2022, 4, 15, 5, 20, 0, 0, time.UTC)
+	want := []Cluster{
+		{
+			Name:    reviewsV1,
+			Service: "reviews.default.svc.cluster.local",
+			Subset:  "v1",
+			Endpoints: []*Endpoint{
+				{
+					Address:          "10.0.0.1:9080",
+					Ejected:          true,
+					Ejections:        map[string]int{"CONSECUTIVE_5XX": 1, "CONSECUTIVE_GATEWAY_FAILURE": 1},
+					LastEjectionType: "CONSECUTIVE_GATEWAY_FAILURE",
+					LastEjection:     &ts,
+					LastUnejection:   &ts,
+				},
+				{
+					Address:          "10.0.0.2:9080",
+					Ejected:          true,
+					Ejections:        map[string]int{"CONSECUTIVE_5XX": 1},
+					LastEjectionType: "CONSECUTIVE_5XX",
+					LastEjection:     &ts,
+				},
+			},
+		},
+		{
+			Name:    ratings,
+			Service: "ratings.default.svc.cluster.local",
+			Endpoints: []*Endpoint{
+				{
+					Address:          "10.0.0.3:9080",
+					Ejections:        map[string]int{"SUCCESS_RATE": 1},
+					LastEjectionType: "SUCCESS_RATE",
+					LastEjection:     &ts,
+				},
+			},
+		},
+	}
+	if got := a.Clusters(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got clusters %+v, want %+v", got, want)
+	}
+}
+
+func TestAggregatorExpire(t *testing.T) {
+	a := NewAggregator()
+	for _, e := range []string{
+		event(reviewsV1, "10.0.0.1:9080", "EJECT", "CONSECUTIVE_5XX", true),
+		event(ratings, "10.0.0.3:9080", "EJECT", "SUCCESS_RATE", true),
+	} {
+		if err := a.Record([]byte(e)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.expire(time.Now())
+	if got := len(a.Clusters()); got != 2 {
+		t.Fatalf("got %d clusters before the retention, want 2", got)
+	}
+
+	later := time.Now().Add(defaultRetention / 2)
+	if err := a.Record([]byte(event(ratings, "10.0.0.4:9080", "EJECT", "SUCCESS_RATE", true))); err != nil {
+		t.Fatal(err)
+	}
+	a.clusters[ratings]["10.0.0.4:9080"].lastEvent = later
+
+	a.expire(time.Now().Add(defaultRetention + time.Minute))
+	got := a.Clusters()
+	if len(got) != 1 || got[0].Name != ratings {
+		t.Fatalf("got clusters %+v, want only %v", got, ratings)
+	}
+	if len(got[0].Endpoints) != 1 || got[0].Endpoints[0].Address != "10.0.0.4:9080" {
+		t.Fatalf("got endpoints %+v, want only 10.0.0.4:9080", got[0].Endpoints)
+	}
+}
+
+func TestTailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outlier.log")
+	var recorded []string
+	tl := &tailer{path: path, record: func(line []byte) error {
+		recorded = append(recorded, string(line))
+		return nil
+	}}
+	defer tl.close()
+	write := func(flag int, s string) {
+		t.Helper()
+		f, err := os.OpenFile(path, flag|os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(lines ...string) {
+		t.Helper()
+		tl.poll()
+		if !reflect.DeepEqual(recorded, lines) {
+			t.Fatalf("got lines %q, want %q", recorded, lines)
+		}
+		recorded = nil
+	}
+
+	// the file does not exist yet
+	expect()
+
+	write(os.O_APPEND, "{\"a\":1}\n{\"b\"")
+	expect(`{"a":1}`)
+
+	// the incomplete line is recorded once complete
+	write(os.O_APPEND, ":2}\n")
+	expect(`{"b":2}`)
+
+	// the file is read from the start once truncated
+	write(os.O_TRUNC, "{\"c\":3}\n")
+	expect(`{"c":3}`)
+
+	// the file is read from the start once replaced
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	expect()
+	write(os.O_APPEND, "{\"d\":4}\n{\"e\":5}\n")
+	expect(`{"d":4}`, `{"e":5}`)
+}
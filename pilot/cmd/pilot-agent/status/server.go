@@ -48,12 +48,15 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"istio.io/istio/pilot/cmd/pilot-agent/metrics"
+	"istio.io/istio/pilot/cmd/pilot-agent/status/exemplars"
 	"istio.io/istio/pilot/cmd/pilot-agent/status/grpcready"
+	"istio.io/istio/pilot/cmd/pilot-agent/status/outlier"
 	"istio.io/istio/pilot/cmd/pilot-agent/status/ready"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config"
 	dnsProto "istio.io/istio/pkg/dns/proto"
 	"istio.io/istio/pkg/kube/apimirror"
+	"istio.io/istio/tools/istio-iptables/pkg/exclusions"
 	"istio.io/pkg/env"
 	"istio.io/pkg/log"
 )
@@ -63,6 +66,10 @@ const (
 	readyPath = "/healthz/ready"
 	// quitPath is to notify the pilot agent to quit.
 	quitPath = "/quitquitquit"
+	// trafficExclusionsPath is to get and update the traffic interception exclusions at runtime.
+	trafficExclusionsPath = "/traffic/exclusions"
+	// outliersPath is to get the outlier detection history of the endpoints of the clusters.
+	outliersPath = "/debug/outliers"
 	// KubeAppProberEnvName is the name of the command line flag for pilot agent to pass app prober config.
 	// The json encoded string to pass app HTTP probe information from injector(istioctl or webhook).
 	// For example, ISTIO_KUBE_APP_PROBERS='{"/app-health/httpbin/livez":{"httpGet":{"path": "/hello", "port": 8080}}.
@@ -125,6 +132,14 @@ type Options struct {
 	FetchDNS            func() *dnsProto.NameTable
 	NoEnvoy             bool
 	GRPCBootstrap       string
+	// TrafficExclusions, if set, allows updating the traffic interception exclusions at runtime.
+	TrafficExclusions *exclusions.Reconciler
+	// ExemplarsSocketPath, if set, is the socket on which Envoy sends the traced requests recorded as exemplars
+	// of the request duration histograms.
+	ExemplarsSocketPath string
+	// OutlierLogPath, if set, is the file to which Envoy logs the outlier detection events, aggregated into metrics
+	// and served on /debug/outliers.
+	OutlierLogPath string
 }
 
 // Server provides an endpoint for handling status probes.
@@ -140,6 +155,11 @@ type Server struct {
 	envoyStatsPort        int
 	fetchDNS              func() *dnsProto.NameTable
 	upstreamLocalAddress  *net.TCPAddr
+	trafficExclusions     *exclusions.Reconciler
+	exemplars             *exemplars.Store
+	exemplarsSocketPath   string
+	outliers              *outlier.Aggregator
+	outlierLogPath        string
 }
 
 func init() {
@@ -187,6 +207,15 @@ func NewServer(config Options) (*Server, error) {
 		envoyStatsPort:        config.EnvoyPrometheusPort,
 		fetchDNS:              config.FetchDNS,
 		upstreamLocalAddress:  upstreamLocalAddress,
+		trafficExclusions:     config.TrafficExclusions,
+		exemplarsSocketPath:   config.ExemplarsSocketPath,
+		outlierLogPath:        config.OutlierLogPath,
+	}
+	if config.ExemplarsSocketPath != "" {
+		s.exemplars = exemplars.NewStore()
+	}
+	if config.OutlierLogPath != "" {
+		s.outliers = outlier.NewAggregator()
 	}
 	if LegacyLocalhostProbeDestination.Get() {
 		s.appProbersDestination = "localhost"
@@ -333,6 +362,18 @@ func (s *Server) Run(ctx context.Context) {
 	mux.HandleFunc("/debug/pprof/symbol", s.handlePprofSymbol)
 	mux.HandleFunc("/debug/pprof/trace", s.handlePprofTrace)
 	mux.HandleFunc("/debug/ndsz", s.handleNdsz)
+	if s.trafficExclusions != nil {
+		mux.HandleFunc(trafficExclusionsPath, s.handleTrafficExclusions)
+	}
+	if s.exemplars != nil {
+		if err := s.exemplars.Serve(ctx, s.exemplarsSocketPath); err != nil {
+			log.Warnf("failed to serve exemplars, request duration histograms will not carry exemplars: %v", err)
+		}
+	}
+	if s.outliers != nil {
+		mux.HandleFunc(outliersPath, s.handleOutliers)
+		go s.outliers.Tail(ctx, s.outlierLogPath, time.Second)
+	}
 
 	l, err := net.Listen("tcp", fmt.Sprintf(":%d", s.statusPort))
 	if err != nil {
@@ -487,10 +528,19 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 			metrics.AppScrapeErrors.Increment()
 		}
 		format = negotiateMetricsFormat(contentType)
+	} else if s.exemplars != nil {
+		// Without app metrics, use OpenMetrics if accepted so the exemplars can be exposed
+		format = expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		if format != expfmt.FmtOpenMetrics {
+			format = expfmt.FmtText
+		}
 	} else {
 		// Without app metrics format use a default
 		format = expfmt.FmtText
 	}
+	if s.exemplars != nil && format == expfmt.FmtOpenMetrics {
+		envoy = s.exemplars.Annotate(envoy)
+	}
 
 	if agent, err = scrapeAgentMetrics(); err != nil {
 		log.Errorf("failed scraping agent metrics: %v", err)
@@ -514,6 +564,10 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 		log.Errorf("failed to write application metrics: %v", err)
 		metrics.AppScrapeErrors.Increment()
 	}
+	if s.prometheus == nil && format == expfmt.FmtOpenMetrics {
+		// Terminate the exposition, as there are no app metrics to do so
+		_, _ = w.Write([]byte("# EOF\n"))
+	}
 }
 
 func negotiateMetricsFormat(contentType string) expfmt.Format {
@@ -812,6 +866,58 @@ func (s *Server) handleNdsz(w http.ResponseWriter, r *http.Request) {
 	writeJSONProto(w, nametable)
 }
 
+// handleTrafficExclusions returns the traffic interception exclusions, and replaces the ones set
+// through this API on PUT. The exclusions from the pod annotations are kept.
+func (s *Server) handleTrafficExclusions(w http.ResponseWriter, r *http.Request) {
+	if !isRequestFromLocalhost(r) {
+		http.Error(w, "Only requests from localhost are allowed", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var e exclusions.Exclusions
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			http.Error(w, fmt.Sprintf("invalid exclusions: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := e.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.trafficExclusions.Set(exclusions.SourceAPI, e); err != nil {
+			log.Errorf("failed to update traffic exclusions: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	b, err := json.Marshal(s.trafficExclusions.Get())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
+// handleOutliers returns the outlier detection history of the endpoints of the clusters.
+func (s *Server) handleOutliers(w http.ResponseWriter, r *http.Request) {
+	if !isRequestFromLocalhost(r) {
+		http.Error(w, "Only requests from localhost are allowed", http.StatusForbidden)
+		return
+	}
+	b, err := json.Marshal(s.outliers.Clusters())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
 // writeJSONProto writes a protobuf to a json payload, handling content type, marshaling, and errors
 func writeJSONProto(w http.ResponseWriter, obj interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -45,6 +45,8 @@ import (
 	"istio.io/istio/pkg/kube/apimirror"
 	"istio.io/istio/pkg/test/env"
 	"istio.io/istio/pkg/test/util/retry"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+	"istio.io/istio/tools/istio-iptables/pkg/exclusions"
 	"istio.io/pkg/log"
 )
 
@@ -1320,6 +1322,111 @@ func TestHandleQuit(t *testing.T) {
 	}
 }
 
+func TestHandleTrafficExclusions(t *testing.T) {
+	reconciler := exclusions.NewReconciler(&dep.StdoutStubDependencies{}, false, false)
+	if err := reconciler.Set(exclusions.SourceAnnotations, exclusions.Exclusions{InboundPorts: []string{"9090"}}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(Options{StatusPort: 15020, TrafficExclusions: reconciler})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		remoteAddr string
+		expected   int
+		response   string
+	}{
+		{
+			name:       "should return the exclusions",
+			method:     "GET",
+			remoteAddr: "127.0.0.1",
+			expected:   http.StatusOK,
+			response:   `{"inboundPorts":["9090"]}`,
+		},
+		{
+			name:       "should update the exclusions",
+			method:     "PUT",
+			body:       `{"inboundPorts":["8080"],"outboundIPRanges":["10.0.0.0/8"]}`,
+			remoteAddr: "127.0.0.1",
+			expected:   http.StatusOK,
+			response:   `{"inboundPorts":["8080","9090"],"outboundIPRanges":["10.0.0.0/8"]}`,
+		},
+		{
+			name:       "should reject invalid exclusions",
+			method:     "PUT",
+			body:       `{"outboundPorts":["http"]}`,
+			remoteAddr: "127.0.0.1",
+			expected:   http.StatusBadRequest,
+		},
+		{
+			name:       "should require GET or PUT method",
+			method:     "POST",
+			remoteAddr: "127.0.0.1",
+			expected:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:     "should require localhost",
+			method:   "GET",
+			expected: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "/traffic/exclusions", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr + ":15020"
+			}
+
+			resp := httptest.NewRecorder()
+			s.handleTrafficExclusions(resp, req)
+			if resp.Code != tt.expected {
+				t.Fatalf("Expected response code %v got %v", tt.expected, resp.Code)
+			}
+			if tt.response != "" && resp.Body.String() != tt.response {
+				t.Fatalf("Expected response %v got %v", tt.response, resp.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleOutliers(t *testing.T) {
+	s, err := NewServer(Options{StatusPort: 15020, OutlierLogPath: "/var/log/outlier.log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.outliers.Record([]byte(`{"type":"CONSECUTIVE_5XX","timestamp":"2022-04-15T05:20:00Z",` +
+		`"cluster_name":"outbound|9080||reviews.default.svc.cluster.local","upstream_url":"10.0.0.1:9080",` +
+		`"action":"EJECT","num_ejections":1,"enforced":true}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, outliersPath, nil)
+	req.RemoteAddr = "127.0.0.1:15020"
+	resp := httptest.NewRecorder()
+	s.handleOutliers(resp, req)
+	expected := `[{"name":"outbound|9080||reviews.default.svc.cluster.local","service":"reviews.default.svc.cluster.local",` +
+		`"endpoints":[{"address":"10.0.0.1:9080","ejected":true,"ejections":{"CONSECUTIVE_5XX":1},` +
+		`"lastEjectionType":"CONSECUTIVE_5XX","lastEjection":"2022-04-15T05:20:00Z"}]}]`
+	if resp.Code != http.StatusOK || resp.Body.String() != expected {
+		t.Fatalf("Expected response %v got %v %v", expected, resp.Code, resp.Body.String())
+	}
+
+	req.RemoteAddr = "10.0.0.2:15020"
+	resp = httptest.NewRecorder()
+	s.handleOutliers(resp, req)
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("Expected response code %v got %v", http.StatusForbidden, resp.Code)
+	}
+}
+
 func TestAdditionalProbes(t *testing.T) {
 	rp := readyProbe{}
 	urp := unreadyProbe{}
@@ -0,0 +1,517 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acme
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/kube/controllers"
+	istiolog "istio.io/pkg/log"
+)
+
+var log = istiolog.RegisterScope("acme", "ACME gateway certificates", 0)
+
+const (
+	// ManagedLabel marks the secrets and VirtualServices managed by the ACME controller. Secrets without
+	// it are never overwritten.
+	ManagedLabel = "networking.istio.io/acme-managed"
+
+	// Timeout of issuing a single certificate, including solving the challenges.
+	obtainTimeout = 5 * time.Minute
+)
+
+// CertificateIssuer issues certificates for a set of domains.
+type CertificateIssuer interface {
+	// Obtain returns the PEM encoded certificate chain and private key of a certificate for the domains.
+	Obtain(ctx context.Context, domains []string) ([]byte, []byte, error)
+}
+
+// Options configures the Controller.
+type Options struct {
+	// RenewBefore is how long before its expiry a certificate is renewed.
+	RenewBefore time.Duration
+	// ResyncPeriod is the interval at which all Gateways are checked for certificates to renew. Failed
+	// issuances are retried at this interval as well, to stay within the rate limits of the ACME CA.
+	ResyncPeriod time.Duration
+	// HTTP01Host and HTTP01Port are the istiod service the Gateways route the HTTP-01 challenges to.
+	HTTP01Host string
+	HTTP01Port uint32
+}
+
+// Controller provisions and renews the certificates of the SIMPLE TLS servers of the Gateways annotated
+// with constants.GatewayACMEChallengeAnnotation, and stores them in the secrets referenced by their
+// credentialName. The secrets are written to the namespaces of the gateway workloads selected by the
+// Gateway, where the gateways read their credentials from.
+type Controller struct {
+	opts     Options
+	store    model.ConfigStore
+	client   kubernetes.Interface
+	issuers  map[string]CertificateIssuer
+	recorder record.EventRecorder
+
+	mu sync.Mutex
+	// queue is only set while the controller is running.
+	queue *controllers.Queue
+}
+
+// NewController creates a Controller. Gateways are read from, and the VirtualServices routing the
+// HTTP-01 challenges written to, the given store. issuers are keyed by the challenge type they solve.
+func NewController(store model.ConfigStore, client kubernetes.Interface, issuers map[string]CertificateIssuer, opts Options) *Controller {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	return &Controller{
+		opts:     opts,
+		store:    store,
+		client:   client,
+		issuers:  issuers,
+		recorder: broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "istiod-acme"}),
+	}
+}
+
+// GatewayHandler is the event handler of the Gateways, queuing the annotated Gateways while the controller
+// is running.
+func (c *Controller) GatewayHandler(_, curr config.Config, event model.Event) {
+	if event == model.EventDelete || curr.Annotations[constants.GatewayACMEChallengeAnnotation] == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.queue != nil {
+		c.queue.Add(types.NamespacedName{Namespace: curr.Namespace, Name: curr.Name})
+	}
+}
+
+// Run runs the controller until stop is closed. It is meant to be run by the leader only.
+func (c *Controller) Run(stop <-chan struct{}) {
+	q := controllers.NewQueue("acme", controllers.WithReconciler(c.Reconcile))
+	c.mu.Lock()
+	c.queue = &q
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.queue = nil
+		c.mu.Unlock()
+	}()
+
+	go q.Run(stop)
+	c.resync()
+	ticker := time.NewTicker(c.opts.ResyncPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.resync()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// resync queues all the annotated Gateways, to renew their expiring certificates.
+func (c *Controller) resync() {
+	gateways, err := c.store.List(gvk.Gateway, metav1.NamespaceAll)
+	if err != nil {
+		log.Errorf("failed to list Gateways: %v", err)
+		return
+	}
+	for _, gw := range gateways {
+		c.GatewayHandler(config.Config{}, gw, model.EventUpdate)
+	}
+}
+
+// Reconcile issues the missing, expiring and outdated certificates of a Gateway.
+func (c *Controller) Reconcile(key types.NamespacedName) error {
+	cfg := c.store.Get(gvk.Gateway, key.Name, key.Namespace)
+	if cfg == nil {
+		return nil
+	}
+	challenge := cfg.Annotations[constants.GatewayACMEChallengeAnnotation]
+	if challenge == "" {
+		return nil
+	}
+	issuer := c.issuers[challenge]
+	if issuer == nil {
+		c.recorder.Eventf(gatewayRef(cfg), v1.EventTypeWarning, "UnsupportedChallenge",
+			"ACME challenge %q is not supported or not configured", challenge)
+		return nil
+	}
+	gw := cfg.Spec.(*networking.Gateway)
+	if challenge == ChallengeHTTP01 && !hasHTTPServer(gw) {
+		c.recorder.Eventf(gatewayRef(cfg), v1.EventTypeWarning, "UnsupportedChallenge",
+			"ACME challenge %s requires an HTTP server without httpsRedirect on the Gateway", challenge)
+		return nil
+	}
+	certs := certificateDomains(gw, challenge)
+	if len(certs) == 0 {
+		return nil
+	}
+	namespaces, err := c.workloadNamespaces(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to list the workloads of Gateway %s/%s: %v", cfg.Namespace, cfg.Name, err)
+	}
+	if len(namespaces) == 0 {
+		c.recorder.Eventf(gatewayRef(cfg), v1.EventTypeWarning, "NoGatewayWorkload",
+			"no gateway workload matches the selector of the Gateway, certificates are not issued")
+		return nil
+	}
+
+	var errs *multierror.Error
+	names := make([]string, 0, len(certs))
+	for name := range certs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, ns := range namespaces {
+		for _, name := range names {
+			if err := c.reconcileCertificate(cfg, challenge, issuer, ns, name, certs[name]); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("certificate %s/%s: %v", ns, name, err))
+			}
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// workloadNamespaces returns the namespaces of the pods selected by the Gateway. Gateways look up the secret
+// referenced by credentialName in the namespace of the gateway workload, which is usually not the namespace
+// of the Gateway.
+func (c *Controller) workloadNamespaces(gw *config.Config) ([]string, error) {
+	selector := gw.Spec.(*networking.Gateway).GetSelector()
+	if len(selector) == 0 {
+		return nil, nil
+	}
+	namespace := metav1.NamespaceAll
+	if features.ScopeGatewayToNamespace {
+		namespace = gw.Namespace
+	}
+	pods, err := c.client.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: klabels.SelectorFromSet(selector).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]struct{}{}
+	var out []string
+	for _, pod := range pods.Items {
+		if _, f := seen[pod.Namespace]; !f {
+			seen[pod.Namespace] = struct{}{}
+			out = append(out, pod.Namespace)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func (c *Controller) reconcileCertificate(gw *config.Config, challenge string, issuer CertificateIssuer,
+	namespace, name string, domains []string,
+) error {
+	ctx, cancel := context.WithTimeout(context.Background(), obtainTimeout)
+	defer cancel()
+	secrets := c.client.CoreV1().Secrets(namespace)
+	secretKey := namespace + "/" + name
+
+	existing, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+	if err != nil {
+		existing = nil
+	}
+	if existing != nil && existing.Labels[ManagedLabel] != "true" {
+		log.Debugf("skipping secret %s, it is not managed by the ACME controller", secretKey)
+		return nil
+	}
+	var current *x509.Certificate
+	if existing != nil {
+		current = parseLeaf(existing.Data[v1.TLSCertKey])
+	}
+	if current != nil {
+		expirySeconds.With(secretTag.Value(secretKey)).Record(float64(current.NotAfter.Unix()))
+		if sameDomains(current.DNSNames, domains) && time.Until(current.NotAfter) > c.opts.RenewBefore {
+			return nil
+		}
+	}
+
+	if challenge == ChallengeHTTP01 {
+		if shadowing := c.shadowingRoute(gw, domains); shadowing != "" {
+			c.recorder.Eventf(gatewayRef(gw), v1.EventTypeWarning, "HTTP01ChallengeShadowed",
+				"HTTP-01 challenges for %v are routed by VirtualService %s, certificate %s can't be validated", domains, shadowing, name)
+			return fmt.Errorf("HTTP-01 challenges are routed by VirtualService %s", shadowing)
+		}
+		if err := c.createHTTP01Route(gw, domains); err != nil {
+			return fmt.Errorf("failed to route HTTP-01 challenges: %v", err)
+		}
+		defer c.deleteHTTP01Route(gw)
+	}
+	log.Infof("issuing certificate %s for %v", secretKey, domains)
+	chain, key, err := issuer.Obtain(ctx, domains)
+	if err != nil {
+		renewals.With(resultTag.Value("failure")).Increment()
+		c.recorder.Eventf(gatewayRef(gw), v1.EventTypeWarning, "CertificateIssuanceFailed",
+			"failed to issue certificate %s for %v: %v", name, domains, err)
+		if current != nil && time.Until(current.NotAfter) <= c.opts.RenewBefore {
+			c.recorder.Eventf(gatewayRef(gw), v1.EventTypeWarning, "CertificateExpiring",
+				"certificate %s expires at %s", name, current.NotAfter.UTC().Format(time.RFC3339))
+		}
+		return err
+	}
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{ManagedLabel: "true"},
+		},
+		Type: v1.SecretTypeTLS,
+		Data: map[string][]byte{v1.TLSCertKey: chain, v1.TLSPrivateKeyKey: key},
+	}
+	if existing == nil {
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+	} else {
+		secret.ResourceVersion = existing.ResourceVersion
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		renewals.With(resultTag.Value("failure")).Increment()
+		return fmt.Errorf("failed to write secret: %v", err)
+	}
+	renewals.With(resultTag.Value("success")).Increment()
+	if issued := parseLeaf(chain); issued != nil {
+		expirySeconds.With(secretTag.Value(secretKey)).Record(float64(issued.NotAfter.Unix()))
+	}
+	c.recorder.Eventf(gatewayRef(gw), v1.EventTypeNormal, "CertificateIssued", "issued certificate %s for %v", name, domains)
+	return nil
+}
+
+// http01RouteName is the name of the VirtualService routing the HTTP-01 challenges of a Gateway to istiod.
+func http01RouteName(gateway string) string {
+	return "acme-http01-" + gateway
+}
+
+// createHTTP01Route routes the HTTP-01 challenges of the domains, received by the Gateway, to istiod.
+// VirtualServices bound to the same Gateway host are merged by creation time, so the challenge route must
+// only be created once shadowingRoute found no earlier route for the challenge path.
+func (c *Controller) createHTTP01Route(gw *config.Config, domains []string) error {
+	vs := config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.VirtualService,
+			Name:             http01RouteName(gw.Name),
+			Namespace:        gw.Namespace,
+			Labels:           map[string]string{ManagedLabel: "true"},
+		},
+		Spec: &networking.VirtualService{
+			Hosts:    domains,
+			Gateways: []string{gw.Name},
+			Http: []*networking.HTTPRoute{{
+				Match: []*networking.HTTPMatchRequest{{
+					Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: HTTP01ChallengePath}},
+				}},
+				Route: []*networking.HTTPRouteDestination{{
+					Destination: &networking.Destination{
+						Host: c.opts.HTTP01Host,
+						Port: &networking.PortSelector{Number: c.opts.HTTP01Port},
+					},
+				}},
+			}},
+		},
+	}
+	if existing := c.store.Get(gvk.VirtualService, vs.Name, vs.Namespace); existing != nil {
+		vs.ResourceVersion = existing.ResourceVersion
+		_, err := c.store.Update(vs)
+		return err
+	}
+	_, err := c.store.Create(vs)
+	return err
+}
+
+// shadowingRoute returns the namespace/name of a VirtualService bound to the Gateway that routes the
+// HTTP-01 challenges of any of the domains, or "" if the challenge route would not be shadowed.
+func (c *Controller) shadowingRoute(gw *config.Config, domains []string) string {
+	vss, err := c.store.List(gvk.VirtualService, metav1.NamespaceAll)
+	if err != nil {
+		log.Warnf("failed to list VirtualServices: %v", err)
+		return ""
+	}
+	sort.Slice(vss, func(i, j int) bool {
+		return vss[i].Namespace+"/"+vss[i].Name < vss[j].Namespace+"/"+vss[j].Name
+	})
+	for _, cfg := range vss {
+		if cfg.Name == http01RouteName(gw.Name) && cfg.Namespace == gw.Namespace {
+			continue
+		}
+		vs := cfg.Spec.(*networking.VirtualService)
+		if bindsGateway(vs, cfg.Namespace, gw) && matchesDomains(vs.Hosts, domains) && routesChallenges(vs.Http) {
+			return cfg.Namespace + "/" + cfg.Name
+		}
+	}
+	return ""
+}
+
+func (c *Controller) deleteHTTP01Route(gw *config.Config) {
+	if err := c.store.Delete(gvk.VirtualService, http01RouteName(gw.Name), gw.Namespace, nil); err != nil {
+		log.Warnf("failed to delete the HTTP-01 challenge route of Gateway %s/%s: %v", gw.Namespace, gw.Name, err)
+	}
+}
+
+// certificateDomains returns the domains of each credentialName of the SIMPLE TLS servers of a Gateway.
+// Wildcard domains can only be validated through DNS-01, and are skipped for the other challenges.
+func certificateDomains(gw *networking.Gateway, challenge string) map[string][]string {
+	domains := map[string]map[string]struct{}{}
+	for _, s := range gw.GetServers() {
+		tls := s.GetTls()
+		if tls.GetMode() != networking.ServerTLSSettings_SIMPLE || tls.GetCredentialName() == "" {
+			continue
+		}
+		for _, host := range s.GetHosts() {
+			if idx := strings.Index(host, "/"); idx >= 0 {
+				host = host[idx+1:]
+			}
+			if host == "*" || (strings.HasPrefix(host, "*") && challenge != ChallengeDNS01) {
+				continue
+			}
+			if domains[tls.GetCredentialName()] == nil {
+				domains[tls.GetCredentialName()] = map[string]struct{}{}
+			}
+			domains[tls.GetCredentialName()][host] = struct{}{}
+		}
+	}
+	out := make(map[string][]string, len(domains))
+	for name, hosts := range domains {
+		for host := range hosts {
+			out[name] = append(out[name], host)
+		}
+		sort.Strings(out[name])
+	}
+	return out
+}
+
+// hasHTTPServer returns whether the Gateway has an HTTP server the challenges can be served on. Servers
+// redirecting to HTTPS are skipped, as the certificate is not available yet.
+func hasHTTPServer(gw *networking.Gateway) bool {
+	for _, s := range gw.GetServers() {
+		if strings.EqualFold(s.GetPort().GetProtocol(), "HTTP") && !s.GetTls().GetHttpsRedirect() {
+			return true
+		}
+	}
+	return false
+}
+
+// bindsGateway returns whether the VirtualService in the namespace is bound to the Gateway.
+func bindsGateway(vs *networking.VirtualService, namespace string, gw *config.Config) bool {
+	for _, name := range vs.Gateways {
+		if idx := strings.Index(name, "/"); idx >= 0 {
+			if name[:idx] == gw.Namespace && name[idx+1:] == gw.Name {
+				return true
+			}
+		} else if namespace == gw.Namespace && name == gw.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesDomains(hosts []string, domains []string) bool {
+	for _, h := range hosts {
+		for _, d := range domains {
+			if host.Name(h).Matches(host.Name(d)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// routesChallenges returns whether any of the routes matches all the requests for the HTTP-01 challenge path.
+func routesChallenges(routes []*networking.HTTPRoute) bool {
+	for _, r := range routes {
+		if len(r.Match) == 0 {
+			return true
+		}
+		for _, m := range r.Match {
+			if len(m.Headers) > 0 || len(m.QueryParams) > 0 || m.Method != nil || m.Scheme != nil ||
+				m.Authority != nil || m.Port != 0 || len(m.SourceLabels) > 0 || len(m.Gateways) > 0 ||
+				len(m.WithoutHeaders) > 0 || m.SourceNamespace != "" {
+				continue
+			}
+			if m.Uri == nil {
+				return true
+			}
+			if p, ok := m.Uri.MatchType.(*networking.StringMatch_Prefix); ok && strings.HasPrefix(HTTP01ChallengePath, p.Prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameDomains(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	sort.Strings(a)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func parseLeaf(chain []byte) *x509.Certificate {
+	block, _ := pem.Decode(chain)
+	if block == nil {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	return cert
+}
+
+func gatewayRef(gw *config.Config) *v1.ObjectReference {
+	return &v1.ObjectReference{
+		Kind:       gvk.Gateway.Kind,
+		APIVersion: gvk.Gateway.GroupVersion(),
+		Name:       gw.Name,
+		Namespace:  gw.Namespace,
+		UID:        types.UID(gw.UID),
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acme
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+type fakeIssuer struct {
+	ttl    time.Duration
+	err    error
+	calls  [][]string
+	onCall func(domains []string)
+}
+
+func (f *fakeIssuer) Obtain(_ context.Context, domains []string) ([]byte, []byte, error) {
+	f.calls = append(f.calls, domains)
+	if f.onCall != nil {
+		f.onCall(domains)
+	}
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	return util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         strings.Join(domains, ","),
+		NotBefore:    time.Now(),
+		TTL:          f.ttl,
+		IsSelfSigned: true,
+		IsServer:     true,
+		ECSigAlg:     util.EcdsaSigAlg,
+	})
+}
+
+func setupController(t *testing.T, issuer *fakeIssuer, objects ...*v1.Secret) (*Controller, model.ConfigStoreCache, *fake.Clientset, *record.FakeRecorder) {
+	store := memory.NewController(memory.Make(collections.Pilot))
+	client := fake.NewSimpleClientset(&v1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "ingressgateway",
+		Namespace: "istio-system",
+		Labels:    map[string]string{"istio": "ingressgateway"},
+	}})
+	for _, s := range objects {
+		if _, err := client.CoreV1().Secrets(s.Namespace).Create(context.TODO(), s, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := NewController(store, client, map[string]CertificateIssuer{ChallengeDNS01: issuer, ChallengeHTTP01: issuer}, Options{
+		RenewBefore:  24 * time.Hour,
+		ResyncPeriod: time.Hour,
+		HTTP01Host:   "istiod.istio-system.svc.cluster.local",
+		HTTP01Port:   15014,
+	})
+	recorder := record.NewFakeRecorder(10)
+	c.recorder = recorder
+	return c, store, client, recorder
+}
+
+func createGateway(t *testing.T, store model.ConfigStore, challenge string, servers ...*networking.Server) {
+	t.Helper()
+	if _, err := store.Create(config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.Gateway,
+			Name:             "gw",
+			Namespace:        "ns",
+			Annotations:      map[string]string{constants.GatewayACMEChallengeAnnotation: challenge},
+		},
+		Spec: &networking.Gateway{Servers: servers, Selector: map[string]string{"istio": "ingressgateway"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tlsServer(credentialName string, mode networking.ServerTLSSettings_TLSmode, hosts ...string) *networking.Server {
+	return &networking.Server{
+		Port:  &networking.Port{Number: 443, Name: "https-" + credentialName, Protocol: "HTTPS"},
+		Hosts: hosts,
+		Tls:   &networking.ServerTLSSettings{Mode: mode, CredentialName: credentialName},
+	}
+}
+
+var httpServer = &networking.Server{
+	Port:  &networking.Port{Number: 80, Name: "http", Protocol: "HTTP"},
+	Hosts: []string{"*"},
+}
+
+var gwKey = types.NamespacedName{Namespace: "ns", Name: "gw"}
+
+func TestReconcileIssuesAndRenewsCertificates(t *testing.T) {
+	issuer := &fakeIssuer{ttl: 90 * 24 * time.Hour}
+	userSecret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "user-cert", Namespace: "istio-system"}}
+	c, store, client, recorder := setupController(t, issuer, userSecret)
+	createGateway(t, store, ChallengeDNS01,
+		tlsServer("gw-cert", networking.ServerTLSSettings_SIMPLE, "ns/b.example.com", "a.example.com", "*.example.com"),
+		tlsServer("user-cert", networking.ServerTLSSettings_SIMPLE, "user.example.com"),
+		tlsServer("mutual-cert", networking.ServerTLSSettings_MUTUAL, "mutual.example.com"))
+
+	if err := c.Reconcile(gwKey); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"*.example.com", "a.example.com", "b.example.com"}}
+	if !reflect.DeepEqual(issuer.calls, want) {
+		t.Fatalf("expected certificates for %v, got %v", want, issuer.calls)
+	}
+	// The secret is written to the namespace of the gateway workload, not of the Gateway.
+	secret, err := client.CoreV1().Secrets("istio-system").Get(context.TODO(), "gw-cert", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Type != v1.SecretTypeTLS || secret.Labels[ManagedLabel] != "true" || parseLeaf(secret.Data[v1.TLSCertKey]) == nil {
+		t.Fatalf("unexpected secret %+v", secret)
+	}
+	if e := <-recorder.Events; !strings.Contains(e, "CertificateIssued") {
+		t.Fatalf("expected CertificateIssued event, got %q", e)
+	}
+
+	// The certificate is valid for longer than RenewBefore, nothing to do.
+	if err := c.Reconcile(gwKey); err != nil {
+		t.Fatal(err)
+	}
+	if len(issuer.calls) != 1 {
+		t.Fatalf("expected the certificate not to be renewed, got %v", issuer.calls)
+	}
+
+	// A certificate expiring within RenewBefore is renewed.
+	issuer.ttl = time.Hour
+	secret.Labels[ManagedLabel] = "true"
+	secret.Data = map[string][]byte{}
+	secret.Data[v1.TLSCertKey], _, _ = issuer.Obtain(context.TODO(), want[0])
+	if _, err := client.CoreV1().Secrets("istio-system").Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	issuer.ttl = 90 * 24 * time.Hour
+	if err := c.Reconcile(gwKey); err != nil {
+		t.Fatal(err)
+	}
+	if len(issuer.calls) != 3 {
+		t.Fatalf("expected the expiring certificate to be renewed, got %v", issuer.calls)
+	}
+
+	// The secret not managed by the controller is never overwritten.
+	user, err := client.CoreV1().Secrets("istio-system").Get(context.TODO(), "user-cert", metav1.GetOptions{})
+	if err != nil || len(user.Data) != 0 {
+		t.Fatalf("expected user secret to be untouched, got %+v, %v", user, err)
+	}
+}
+
+func TestReconcileHTTP01(t *testing.T) {
+	issuer := &fakeIssuer{ttl: 90 * 24 * time.Hour}
+	c, store, _, recorder := setupController(t, issuer)
+	createGateway(t, store, ChallengeHTTP01, tlsServer("gw-cert", networking.ServerTLSSettings_SIMPLE, "a.example.com", "*.example.com"))
+
+	// HTTP-01 challenges are routed through an HTTP server of the Gateway.
+	if err := c.Reconcile(gwKey); err != nil {
+		t.Fatal(err)
+	}
+	if len(issuer.calls) != 0 {
+		t.Fatalf("expected no certificate without an HTTP server, got %v", issuer.calls)
+	}
+	if e := <-recorder.Events; !strings.Contains(e, "UnsupportedChallenge") {
+		t.Fatalf("expected UnsupportedChallenge event, got %q", e)
+	}
+
+	if err := store.Delete(gvk.Gateway, "gw", "ns", nil); err != nil {
+		t.Fatal(err)
+	}
+	createGateway(t, store, ChallengeHTTP01, httpServer,
+		tlsServer("gw-cert", networking.ServerTLSSettings_SIMPLE, "a.example.com", "*.example.com"))
+	issuer.onCall = func(domains []string) {
+		vs := store.Get(gvk.VirtualService, http01RouteName("gw"), "ns")
+		if vs == nil {
+			t.Fatal("expected the HTTP-01 challenges to be routed during issuance")
+		}
+		spec := vs.Spec.(*networking.VirtualService)
+		if !reflect.DeepEqual(spec.Hosts, domains) || spec.Gateways[0] != "gw" ||
+			spec.Http[0].Route[0].Destination.Host != "istiod.istio-system.svc.cluster.local" {
+			t.Fatalf("unexpected HTTP-01 route %+v", spec)
+		}
+	}
+	if err := c.Reconcile(gwKey); err != nil {
+		t.Fatal(err)
+	}
+	// Wildcard domains can't be validated through HTTP-01.
+	if want := [][]string{{"a.example.com"}}; !reflect.DeepEqual(issuer.calls, want) {
+		t.Fatalf("expected certificates for %v, got %v", want, issuer.calls)
+	}
+	if store.Get(gvk.VirtualService, http01RouteName("gw"), "ns") != nil {
+		t.Fatal("expected the HTTP-01 route to be removed after issuance")
+	}
+}
+
+func TestReconcileFailure(t *testing.T) {
+	issuer := &fakeIssuer{err: errors.New("rate limited")}
+	c, store, client, recorder := setupController(t, issuer)
+	createGateway(t, store, ChallengeDNS01, tlsServer("gw-cert", networking.ServerTLSSettings_SIMPLE, "a.example.com"))
+
+	if err := c.Reconcile(gwKey); err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Fatalf("expected issuance error, got %v", err)
+	}
+	if e := <-recorder.Events; !strings.Contains(e, "CertificateIssuanceFailed") {
+		t.Fatalf("expected CertificateIssuanceFailed event, got %q", e)
+	}
+	if _, err := client.CoreV1().Secrets("istio-system").Get(context.TODO(), "gw-cert", metav1.GetOptions{}); err == nil {
+		t.Fatal("expected no secret to be written")
+	}
+
+	// Gateways using a challenge without issuer are ignored.
+	c.issuers = map[string]CertificateIssuer{}
+	if err := c.Reconcile(gwKey); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-recorder.Events; !strings.Contains(e, "UnsupportedChallenge") {
+		t.Fatalf("expected UnsupportedChallenge event, got %q", e)
+	}
+}
+
+func TestReconcileHTTP01Shadowed(t *testing.T) {
+	issuer := &fakeIssuer{ttl: 90 * 24 * time.Hour}
+	c, store, _, recorder := setupController(t, issuer)
+	redirect := &networking.Server{
+		Port:  &networking.Port{Number: 80, Name: "http", Protocol: "HTTP"},
+		Hosts: []string{"*"},
+		Tls:   &networking.ServerTLSSettings{HttpsRedirect: true},
+	}
+	createGateway(t, store, ChallengeHTTP01, redirect, tlsServer("gw-cert", networking.ServerTLSSettings_SIMPLE, "a.example.com"))
+
+	// The challenges can't be served by an HTTP server redirecting to HTTPS.
+	if err := c.Reconcile(gwKey); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-recorder.Events; !strings.Contains(e, "UnsupportedChallenge") {
+		t.Fatalf("expected UnsupportedChallenge event, got %q", e)
+	}
+
+	if err := store.Delete(gvk.Gateway, "gw", "ns", nil); err != nil {
+		t.Fatal(err)
+	}
+	createGateway(t, store, ChallengeHTTP01, httpServer, tlsServer("gw-cert", networking.ServerTLSSettings_SIMPLE, "a.example.com"))
+	// A route for the challenge path created earlier takes precedence over the challenge route.
+	if _, err := store.Create(config.Config{
+		Meta: config.Meta{GroupVersionKind: gvk.VirtualService, Name: "catch-all", Namespace: "app"},
+		Spec: &networking.VirtualService{
+			Hosts:    []string{"*.example.com"},
+			Gateways: []string{"ns/gw"},
+			Http: []*networking.HTTPRoute{{
+				Match: []*networking.HTTPMatchRequest{{
+					Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: "/"}},
+				}},
+				Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: "app"}}},
+			}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reconcile(gwKey); err == nil || !strings.Contains(err.Error(), "app/catch-all") {
+		t.Fatalf("expected shadowed challenge error, got %v", err)
+	}
+	if e := <-recorder.Events; !strings.Contains(e, "HTTP01ChallengeShadowed") {
+		t.Fatalf("expected HTTP01ChallengeShadowed event, got %q", e)
+	}
+	if len(issuer.calls) != 0 {
+		t.Fatalf("expected no certificate to be requested, got %v", issuer.calls)
+	}
+}
+
+func TestReconcileWithoutWorkload(t *testing.T) {
+	issuer := &fakeIssuer{ttl: 90 * 24 * time.Hour}
+	c, store, client, recorder := setupController(t, issuer)
+	if err := client.CoreV1().Pods("istio-system").Delete(context.TODO(), "ingressgateway", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	createGateway(t, store, ChallengeDNS01, tlsServer("gw-cert", networking.ServerTLSSettings_SIMPLE, "a.example.com"))
+
+	if err := c.Reconcile(gwKey); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-recorder.Events; !strings.Contains(e, "NoGatewayWorkload") {
+		t.Fatalf("expected NoGatewayWorkload event, got %q", e)
+	}
+	if len(issuer.calls) != 0 {
+		t.Fatalf("expected no certificate to be requested, got %v", issuer.calls)
+	}
+}
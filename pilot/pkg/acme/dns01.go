@@ -0,0 +1,132 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acme
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DNSProviderSecretName is the secret in the istiod namespace holding the credentials of the DNS provider. Its
+// entries are added to the configuration of the provider.
+const DNSProviderSecretName = "istio-acme-dns-provider"
+
+// DNSProvider publishes the TXT records of DNS-01 challenges in a DNS zone.
+type DNSProvider interface {
+	// Present creates the TXT record with the given fully qualified name and value.
+	Present(ctx context.Context, fqdn, value string) error
+	// CleanUp removes the TXT record with the given fully qualified name and value.
+	CleanUp(ctx context.Context, fqdn, value string) error
+}
+
+// DNSProviderFactory creates a DNSProvider from its configuration, such as the zone and credentials.
+type DNSProviderFactory func(config map[string]string) (DNSProvider, error)
+
+var (
+	dnsProvidersMu sync.RWMutex
+	dnsProviders   = map[string]DNSProviderFactory{}
+)
+
+// RegisterDNSProvider registers a DNS provider implementation under the given name. Providers register
+// themselves from an init function, so they are only linked into the builds that need them.
+func RegisterDNSProvider(name string, factory DNSProviderFactory) {
+	dnsProvidersMu.Lock()
+	defer dnsProvidersMu.Unlock()
+	dnsProviders[name] = factory
+}
+
+// NewDNSProvider creates the DNS provider registered under the given name.
+func NewDNSProvider(name string, config map[string]string) (DNSProvider, error) {
+	dnsProvidersMu.RLock()
+	factory, f := dnsProviders[name]
+	dnsProvidersMu.RUnlock()
+	if !f {
+		return nil, fmt.Errorf("unknown ACME DNS provider %q, registered providers: %v", name, registeredDNSProviders())
+	}
+	return factory(config)
+}
+
+// LoadDNSProviderConfig returns the configuration of the DNS provider, with the entries of the DNSProviderSecretName
+// secret in the given namespace, if it exists, so that the credentials of the provider are not set in the
+// environment of istiod.
+func LoadDNSProviderConfig(ctx context.Context, client kubernetes.Interface, namespace string,
+	config map[string]string) (map[string]string, error) {
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, DNSProviderSecretName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ACME DNS provider secret: %v", err)
+	}
+	merged := make(map[string]string, len(config)+len(secret.Data))
+	for k, v := range config {
+		merged[k] = v
+	}
+	for k, v := range secret.Data {
+		merged[k] = string(v)
+	}
+	return merged, nil
+}
+
+func registeredDNSProviders() []string {
+	dnsProvidersMu.RLock()
+	defer dnsProvidersMu.RUnlock()
+	names := make([]string, 0, len(dnsProviders))
+	for name := range dnsProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DNS01Solver solves DNS-01 challenges through a DNSProvider.
+type DNS01Solver struct {
+	provider DNSProvider
+}
+
+var _ Solver = &DNS01Solver{}
+
+// NewDNS01Solver creates a DNS01Solver publishing the challenge records through the given provider.
+func NewDNS01Solver(provider DNSProvider) *DNS01Solver {
+	return &DNS01Solver{provider: provider}
+}
+
+// Type implements Solver.
+func (s *DNS01Solver) Type() string {
+	return ChallengeDNS01
+}
+
+// Present implements Solver.
+func (s *DNS01Solver) Present(ctx context.Context, domain, _, response string) error {
+	return s.provider.Present(ctx, dns01RecordName(domain), response)
+}
+
+// CleanUp implements Solver.
+func (s *DNS01Solver) CleanUp(ctx context.Context, domain, _, response string) error {
+	return s.provider.CleanUp(ctx, dns01RecordName(domain), response)
+}
+
+// dns01RecordName returns the fully qualified name of the TXT record of the DNS-01 challenge of a domain.
+// The challenge of a wildcard domain is validated on its base domain.
+func dns01RecordName(domain string) string {
+	return "_acme-challenge." + strings.TrimPrefix(domain, "*.") + "."
+}
@@ -0,0 +1,151 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acme
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// HTTP01ChallengePath is the path prefix the ACME server requests the HTTP-01 challenge responses at.
+	HTTP01ChallengePath = "/.well-known/acme-challenge/"
+
+	// HTTP01ConfigMapName is the ConfigMap in the istiod namespace holding the pending HTTP-01 challenge
+	// responses, keyed by token. It is shared by all istiod replicas, as the challenge requests routed by the
+	// Gateway can reach any of them.
+	HTTP01ConfigMapName = "istio-acme-http01-challenges"
+
+	// How long Present waits for the challenge response to be served by this istiod.
+	http01PresentTimeout = 30 * time.Second
+)
+
+// HTTP01Solver solves HTTP-01 challenges. The challenge responses are stored in the HTTP01ConfigMapName
+// ConfigMap, which every istiod replica watches and serves at HTTP01ChallengePath.
+type HTTP01Solver struct {
+	client    kubernetes.Interface
+	namespace string
+
+	mu        sync.RWMutex
+	responses map[string]string
+}
+
+var _ Solver = &HTTP01Solver{}
+
+// NewHTTP01Solver creates an HTTP01Solver storing the challenge responses in the given namespace.
+func NewHTTP01Solver(client kubernetes.Interface, namespace string) *HTTP01Solver {
+	return &HTTP01Solver{
+		client:    client,
+		namespace: namespace,
+		responses: map[string]string{},
+	}
+}
+
+// Type implements Solver.
+func (s *HTTP01Solver) Type() string {
+	return ChallengeHTTP01
+}
+
+// Present implements Solver. It returns once this istiod serves the response.
+func (s *HTTP01Solver) Present(ctx context.Context, _, token, response string) error {
+	if err := s.updateConfigMap(ctx, func(data map[string]string) {
+		data[token] = response
+	}); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, http01PresentTimeout)
+	defer cancel()
+	return wait.PollImmediateUntil(100*time.Millisecond, func() (bool, error) {
+		return s.response(token) == response, nil
+	}, ctx.Done())
+}
+
+// CleanUp implements Solver.
+func (s *HTTP01Solver) CleanUp(ctx context.Context, _, token, _ string) error {
+	return s.updateConfigMap(ctx, func(data map[string]string) {
+		delete(data, token)
+	})
+}
+
+func (s *HTTP01Solver) updateConfigMap(ctx context.Context, update func(data map[string]string)) error {
+	configMaps := s.client.CoreV1().ConfigMaps(s.namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(ctx, HTTP01ConfigMapName, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			cm = &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: HTTP01ConfigMapName, Namespace: s.namespace},
+				Data:       map[string]string{},
+			}
+			update(cm.Data)
+			_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+			if kerrors.IsAlreadyExists(err) {
+				// Retry as an update.
+				return kerrors.NewConflict(v1.Resource("configmaps"), HTTP01ConfigMapName, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		update(cm.Data)
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// Update replaces the served challenge responses with the content of the HTTP01ConfigMapName ConfigMap.
+// It is the callback of the watch of the ConfigMap.
+func (s *HTTP01Solver) Update(cm *v1.ConfigMap) {
+	responses := map[string]string{}
+	if cm != nil {
+		for token, response := range cm.Data {
+			responses[token] = response
+		}
+	}
+	s.mu.Lock()
+	s.responses = responses
+	s.mu.Unlock()
+}
+
+func (s *HTTP01Solver) response(token string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.responses[token]
+}
+
+// ServeHTTP serves the response of the challenge whose token is in the request path.
+func (s *HTTP01Solver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	token := strings.TrimPrefix(req.URL.Path, HTTP01ChallengePath)
+	response := s.response(token)
+	if token == "" || response == "" {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte(response))
+}
@@ -0,0 +1,246 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package acme provisions and renews the server certificates of Gateways through an ACME CA, such as
+// Let's Encrypt, and stores them in the secrets referenced by the credentialName of the Gateway servers.
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/acme"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ChallengeHTTP01 solves the ACME challenges by serving a token over HTTP on the Gateway itself.
+	ChallengeHTTP01 = "http-01"
+	// ChallengeDNS01 solves the ACME challenges by publishing a TXT record through a DNS provider.
+	ChallengeDNS01 = "dns-01"
+
+	// AccountSecretName is the secret in the istiod namespace holding the ACME account key.
+	AccountSecretName = "istio-acme-account"
+	accountKeyField   = "key.pem"
+)
+
+// Solver solves the ACME challenges of a single type.
+type Solver interface {
+	// Type is the ACME challenge type solved, ChallengeHTTP01 or ChallengeDNS01.
+	Type() string
+	// Present makes the response of the challenge available to the ACME server. For HTTP-01 the response is
+	// the key authorization, for DNS-01 it is the value of the TXT record.
+	Present(ctx context.Context, domain, token, response string) error
+	// CleanUp removes the response of the challenge, once it has been validated or has failed.
+	CleanUp(ctx context.Context, domain, token, response string) error
+}
+
+// Issuer obtains certificates from an ACME CA.
+type Issuer struct {
+	client     *acme.Client
+	email      string
+	accountKey func(ctx context.Context) (crypto.Signer, error)
+	solver     Solver
+
+	mu         sync.Mutex
+	registered bool
+}
+
+// NewIssuer creates an Issuer for the ACME directory. On first use, it loads the account key and registers
+// an account for it and the given email.
+func NewIssuer(directoryURL, email string, accountKey func(ctx context.Context) (crypto.Signer, error), solver Solver) *Issuer {
+	return &Issuer{
+		client:     &acme.Client{DirectoryURL: directoryURL},
+		email:      email,
+		accountKey: accountKey,
+		solver:     solver,
+	}
+}
+
+func (i *Issuer) register(ctx context.Context) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.registered {
+		return nil
+	}
+	if i.client.Key == nil {
+		key, err := i.accountKey(ctx)
+		if err != nil {
+			return err
+		}
+		i.client.Key = key
+	}
+	acct := &acme.Account{}
+	if i.email != "" {
+		acct.Contact = []string{"mailto:" + i.email}
+	}
+	if _, err := i.client.Register(ctx, acct, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return fmt.Errorf("failed to register ACME account: %v", err)
+	}
+	i.registered = true
+	return nil
+}
+
+// Obtain orders a certificate for the given domains, solving the challenges of each domain with the solver
+// of the Issuer. It returns the PEM encoded certificate chain and private key.
+func (i *Issuer) Obtain(ctx context.Context, domains []string) ([]byte, []byte, error) {
+	if err := i.register(ctx); err != nil {
+		return nil, nil, err
+	}
+	order, err := i.client.AuthorizeOrder(ctx, acme.DomainIDs(domains...))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create order: %v", err)
+	}
+	for _, u := range order.AuthzURLs {
+		if err := i.authorize(ctx, u); err != nil {
+			return nil, nil, err
+		}
+	}
+	if order, err = i.client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, nil, fmt.Errorf("order failed: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, _, err := i.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to finalize order: %v", err)
+	}
+	var chain []byte
+	for _, c := range der {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c})...)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return chain, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), nil
+}
+
+// authorize solves the challenge of the authorization at the given URL, unless it is already valid.
+func (i *Issuer) authorize(ctx context.Context, u string) error {
+	authz, err := i.client.GetAuthorization(ctx, u)
+	if err != nil {
+		return fmt.Errorf("failed to get authorization: %v", err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+	var chal *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == i.solver.Type() {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("ACME server offers no %s challenge for %s", i.solver.Type(), authz.Identifier.Value)
+	}
+
+	var response string
+	switch chal.Type {
+	case ChallengeHTTP01:
+		response, err = i.client.HTTP01ChallengeResponse(chal.Token)
+	case ChallengeDNS01:
+		response, err = i.client.DNS01ChallengeRecord(chal.Token)
+	default:
+		err = fmt.Errorf("unsupported challenge type %s", chal.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	domain := authz.Identifier.Value
+	if err := i.solver.Present(ctx, domain, chal.Token, response); err != nil {
+		return fmt.Errorf("failed to present %s challenge for %s: %v", chal.Type, domain, err)
+	}
+	defer func() {
+		if err := i.solver.CleanUp(ctx, domain, chal.Token, response); err != nil {
+			log.Warnf("failed to clean up %s challenge for %s: %v", chal.Type, domain, err)
+		}
+	}()
+	if _, err := i.client.Accept(ctx, chal); err != nil {
+		return fmt.Errorf("failed to accept %s challenge for %s: %v", chal.Type, domain, err)
+	}
+	if _, err := i.client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("authorization of %s failed: %v", domain, err)
+	}
+	return nil
+}
+
+// LoadAccountKey loads the ACME account key from the AccountSecretName secret in the given namespace,
+// creating the key and the secret if they don't exist yet, so the same account is used across restarts.
+func LoadAccountKey(ctx context.Context, client kubernetes.Interface, namespace string) (crypto.Signer, error) {
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, AccountSecretName, metav1.GetOptions{})
+	if err == nil {
+		return parseAccountKey(secret.Data[accountKeyField])
+	}
+	if !kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to read ACME account secret: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	secret = &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: AccountSecretName, Namespace: namespace},
+		Data:       map[string][]byte{accountKeyField: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})},
+	}
+	if _, err := client.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		if kerrors.IsAlreadyExists(err) {
+			// Another istiod created the account key first.
+			return LoadAccountKey(ctx, client, namespace)
+		}
+		return nil, fmt.Errorf("failed to create ACME account secret: %v", err)
+	}
+	return key, nil
+}
+
+func parseAccountKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("ACME account secret %s has no key", AccountSecretName)
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACME account key: %v", err)
+	}
+	return key, nil
+}
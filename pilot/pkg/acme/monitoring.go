@@ -0,0 +1,43 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acme
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	resultTag = monitoring.MustCreateLabel("result")
+	secretTag = monitoring.MustCreateLabel("secret")
+
+	renewals = monitoring.NewSum(
+		"pilot_acme_certificate_renewals_total",
+		"Total number of Gateway certificates issued or renewed through ACME, by result.",
+		monitoring.WithLabels(resultTag),
+	)
+
+	expirySeconds = monitoring.NewGauge(
+		"pilot_acme_certificate_expiry_seconds",
+		"The time, in seconds since epoch, at which the Gateway certificate managed through ACME expires.",
+		monitoring.WithLabels(secretTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		renewals,
+		expirySeconds,
+	)
+}
@@ -0,0 +1,183 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package route53 registers the "route53" ACME DNS provider, publishing the DNS-01 challenge records in an AWS
+// Route 53 hosted zone.
+package route53
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+
+	"istio.io/istio/pilot/pkg/acme"
+)
+
+const (
+	// Name is the name of the provider, to be set in PILOT_ACME_DNS_PROVIDER.
+	Name = "route53"
+
+	// HostedZoneID is the configuration of the ID of the hosted zone of the challenge records. It is required.
+	HostedZoneID = "hostedZoneId"
+	// Region is the configuration of the region of the Route 53 API. It defaults to the region of the environment.
+	Region = "region"
+	// AccessKeyID and SecretAccessKey are the configuration of the credentials of the provider, typically set in the
+	// acme.DNSProviderSecretName secret. They default to the credentials of the environment.
+	AccessKeyID     = "accessKeyId"
+	SecretAccessKey = "secretAccessKey"
+
+	// recordTTL is the TTL of the challenge records, short so that the ACME CA does not see stale values.
+	recordTTL = 60
+)
+
+func init() {
+	acme.RegisterDNSProvider(Name, newProvider)
+}
+
+type provider struct {
+	client route53iface.Route53API
+	zoneID string
+
+	// mu serializes the changes of the records, as the challenges of a wildcard domain and of its base domain share
+	// the same record, whose values are read, modified and written back.
+	mu sync.Mutex
+}
+
+var _ acme.DNSProvider = &provider{}
+
+func newProvider(config map[string]string) (acme.DNSProvider, error) {
+	awsConfig := &aws.Config{Region: aws.String(config[Region])}
+	if config[AccessKeyID] != "" || config[SecretAccessKey] != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentials(config[AccessKeyID], config[SecretAccessKey], "")
+	}
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %v", err)
+	}
+	return newProviderWithClient(route53.New(sess), config[HostedZoneID])
+}
+
+func newProviderWithClient(client route53iface.Route53API, zoneID string) (*provider, error) {
+	if zoneID == "" {
+		return nil, fmt.Errorf("%s is required by the %s ACME DNS provider", HostedZoneID, Name)
+	}
+	return &provider{client: client, zoneID: zoneID}, nil
+}
+
+// Present implements acme.DNSProvider.
+func (p *provider) Present(ctx context.Context, fqdn, value string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	values, err := p.values(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	quoted := strconv.Quote(value)
+	for _, v := range values {
+		if v == quoted {
+			return nil
+		}
+	}
+	return p.change(ctx, route53.ChangeActionUpsert, fqdn, append(values, quoted))
+}
+
+// CleanUp implements acme.DNSProvider.
+func (p *provider) CleanUp(ctx context.Context, fqdn, value string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	values, err := p.values(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+	quoted := strconv.Quote(value)
+	remaining := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != quoted {
+			remaining = append(remaining, v)
+		}
+	}
+	switch {
+	case len(remaining) == len(values):
+		return nil
+	case len(remaining) == 0:
+		// a deleted record set must match the existing one
+		return p.change(ctx, route53.ChangeActionDelete, fqdn, values)
+	default:
+		return p.change(ctx, route53.ChangeActionUpsert, fqdn, remaining)
+	}
+}
+
+// values returns the quoted values of the TXT record with the fully qualified name.
+func (p *provider) values(ctx context.Context, fqdn string) ([]string, error) {
+	resp, err := p.client.ListResourceRecordSetsWithContext(ctx, &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(p.zoneID),
+		StartRecordName: aws.String(fqdn),
+		StartRecordType: aws.String(route53.RRTypeTxt),
+		MaxItems:        aws.String("1"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the records %s of hosted zone %s: %v", fqdn, p.zoneID, err)
+	}
+	var values []string
+	for _, rrs := range resp.ResourceRecordSets {
+		// the listing starts at the record, which is the first one returned only if it exists
+		if !strings.EqualFold(aws.StringValue(rrs.Name), fqdn) || aws.StringValue(rrs.Type) != route53.RRTypeTxt {
+			continue
+		}
+		for _, rr := range rrs.ResourceRecords {
+			values = append(values, aws.StringValue(rr.Value))
+		}
+	}
+	return values, nil
+}
+
+// change applies the action to the TXT record with the fully qualified name and quoted values, and waits for the
+// change to be propagated to the Route 53 name servers.
+func (p *provider) change(ctx context.Context, action, fqdn string, values []string) error {
+	records := make([]*route53.ResourceRecord, 0, len(values))
+	for _, v := range values {
+		records = append(records, &route53.ResourceRecord{Value: aws.String(v)})
+	}
+	resp, err := p.client.ChangeResourceRecordSetsWithContext(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(p.zoneID),
+		ChangeBatch: &route53.ChangeBatch{
+			Comment: aws.String("Istio ACME DNS-01 challenge"),
+			Changes: []*route53.Change{{
+				Action: aws.String(action),
+				ResourceRecordSet: &route53.ResourceRecordSet{
+					Name:            aws.String(fqdn),
+					Type:            aws.String(route53.RRTypeTxt),
+					TTL:             aws.Int64(recordTTL),
+					ResourceRecords: records,
+				},
+			}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to %s the record %s of hosted zone %s: %v", strings.ToLower(action), fqdn, p.zoneID, err)
+	}
+	err = p.client.WaitUntilResourceRecordSetsChangedWithContext(ctx, &route53.GetChangeInput{Id: resp.ChangeInfo.Id})
+	if err != nil {
+		return fmt.Errorf("failed waiting for the change of the record %s of hosted zone %s: %v", fqdn, p.zoneID, err)
+	}
+	return nil
+}
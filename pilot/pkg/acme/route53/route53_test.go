@@ -0,0 +1,147 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package route53
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+
+	"istio.io/istio/pilot/pkg/acme"
+)
+
+// fakeRoute53 keeps the TXT records of a hosted zone in memory, rejecting the deletions which do not match the
+// existing record set as Route 53 does.
+type fakeRoute53 struct {
+	route53iface.Route53API
+	records map[string][]string
+	waits   int
+}
+
+func (f *fakeRoute53) ListResourceRecordSetsWithContext(_ aws.Context, in *route53.ListResourceRecordSetsInput,
+	_ ...request.Option) (*route53.ListResourceRecordSetsOutput, error) {
+	names := make([]string, 0, len(f.records))
+	for name := range f.records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := &route53.ListResourceRecordSetsOutput{}
+	for _, name := range names {
+		if name < aws.StringValue(in.StartRecordName) {
+			continue
+		}
+		rrs := &route53.ResourceRecordSet{Name: aws.String(name), Type: aws.String(route53.RRTypeTxt)}
+		for _, v := range f.records[name] {
+			rrs.ResourceRecords = append(rrs.ResourceRecords, &route53.ResourceRecord{Value: aws.String(v)})
+		}
+		out.ResourceRecordSets = append(out.ResourceRecordSets, rrs)
+		break
+	}
+	return out, nil
+}
+
+func (f *fakeRoute53) ChangeResourceRecordSetsWithContext(_ aws.Context, in *route53.ChangeResourceRecordSetsInput,
+	_ ...request.Option) (*route53.ChangeResourceRecordSetsOutput, error) {
+	for _, c := range in.ChangeBatch.Changes {
+		name := aws.StringValue(c.ResourceRecordSet.Name)
+		values := make([]string, 0, len(c.ResourceRecordSet.ResourceRecords))
+		for _, rr := range c.ResourceRecordSet.ResourceRecords {
+			values = append(values, aws.StringValue(rr.Value))
+		}
+		switch aws.StringValue(c.Action) {
+		case route53.ChangeActionUpsert:
+			f.records[name] = values
+		case route53.ChangeActionDelete:
+			if !reflect.DeepEqual(f.records[name], values) {
+				return nil, fmt.Errorf("record set %s %v does not match %v", name, values, f.records[name])
+			}
+			delete(f.records, name)
+		}
+	}
+	return &route53.ChangeResourceRecordSetsOutput{ChangeInfo: &route53.ChangeInfo{Id: aws.String("change")}}, nil
+}
+
+func (f *fakeRoute53) WaitUntilResourceRecordSetsChangedWithContext(aws.Context, *route53.GetChangeInput,
+	...request.WaiterOption) error {
+	f.waits++
+	return nil
+}
+
+func TestProvider(t *testing.T) {
+	if _, err := newProviderWithClient(&fakeRoute53{}, ""); err == nil {
+		t.Fatal("expected an error without hosted zone")
+	}
+
+	const record = "_acme-challenge.example.com."
+	fake := &fakeRoute53{records: map[string][]string{"www.example.com.": {`"other"`}}}
+	p, err := newProviderWithClient(fake, "Z123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	expect := func(want ...string) {
+		t.Helper()
+		if got := fake.records[record]; !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected values %v, got %v", want, got)
+		}
+	}
+
+	// the challenges of example.com and *.example.com share the same record
+	if err := p.Present(ctx, record, "base"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Present(ctx, record, "wildcard"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Present(ctx, record, "wildcard"); err != nil {
+		t.Fatal(err)
+	}
+	expect(`"base"`, `"wildcard"`)
+	if fake.waits != 2 {
+		t.Fatalf("expected to wait for 2 changes, got %d", fake.waits)
+	}
+
+	if err := p.CleanUp(ctx, record, "base"); err != nil {
+		t.Fatal(err)
+	}
+	expect(`"wildcard"`)
+	if err := p.CleanUp(ctx, record, "wildcard"); err != nil {
+		t.Fatal(err)
+	}
+	if _, f := fake.records[record]; f {
+		t.Fatalf("expected the record to be deleted, got %v", fake.records)
+	}
+	if err := p.CleanUp(ctx, record, "wildcard"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.records["www.example.com."]; !reflect.DeepEqual(got, []string{`"other"`}) {
+		t.Fatalf("unexpected change of another record: %v", got)
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	_, err := acme.NewDNSProvider(Name, map[string]string{Region: "us-east-1"})
+	if err == nil || !strings.Contains(err.Error(), HostedZoneID) {
+		t.Fatalf("expected the provider to be registered and require %s, got %v", HostedZoneID, err)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acme
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHTTP01Solver(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	s := NewHTTP01Solver(client, "istio-system")
+
+	// Stand in for the ConfigMap watch.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+				if cm, err := client.CoreV1().ConfigMaps("istio-system").Get(context.TODO(), HTTP01ConfigMapName, metav1.GetOptions{}); err == nil {
+					s.Update(cm)
+				}
+			}
+		}
+	}()
+
+	if err := s.Present(context.TODO(), "a.example.com", "token1", "token1.thumbprint"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Present(context.TODO(), "b.example.com", "token2", "token2.thumbprint"); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr
+	}
+	if rr := serve(HTTP01ChallengePath + "token1"); rr.Code != http.StatusOK || rr.Body.String() != "token1.thumbprint" {
+		t.Fatalf("unexpected response %d %q", rr.Code, rr.Body.String())
+	}
+	if rr := serve(HTTP01ChallengePath + "unknown"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown token not to be found, got %d", rr.Code)
+	}
+
+	if err := s.CleanUp(context.TODO(), "a.example.com", "token1", "token1.thumbprint"); err != nil {
+		t.Fatal(err)
+	}
+	cm, err := client.CoreV1().ConfigMaps("istio-system").Get(context.TODO(), HTTP01ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, f := cm.Data["token1"]; f || cm.Data["token2"] != "token2.thumbprint" {
+		t.Fatalf("expected only token1 to be removed, got %v", cm.Data)
+	}
+}
+
+type fakeDNSProvider struct {
+	records map[string]string
+}
+
+func (f *fakeDNSProvider) Present(_ context.Context, fqdn, value string) error {
+	f.records[fqdn] = value
+	return nil
+}
+
+func (f *fakeDNSProvider) CleanUp(_ context.Context, fqdn, _ string) error {
+	delete(f.records, fqdn)
+	return nil
+}
+
+func TestDNS01Solver(t *testing.T) {
+	RegisterDNSProvider("fake", func(map[string]string) (DNSProvider, error) {
+		return &fakeDNSProvider{records: map[string]string{}}, nil
+	})
+	if _, err := NewDNSProvider("unknown", nil); err == nil {
+		t.Fatal("expected unknown provider to fail")
+	}
+	p, err := NewDNSProvider("fake", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewDNS01Solver(p)
+	if err := s.Present(context.TODO(), "*.example.com", "token", "value"); err != nil {
+		t.Fatal(err)
+	}
+	records := p.(*fakeDNSProvider).records
+	if records["_acme-challenge.example.com."] != "value" {
+		t.Fatalf("expected the challenge of the wildcard to be published on its base domain, got %v", records)
+	}
+	if err := s.CleanUp(context.TODO(), "*.example.com", "token", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("expected the record to be removed, got %v", records)
+	}
+}
+
+func TestLoadDNSProviderConfig(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	config := map[string]string{"hostedZoneId": "Z123"}
+	got, err := LoadDNSProviderConfig(context.TODO(), client, "istio-system", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, config) {
+		t.Fatalf("expected the configuration without secret, got %v", got)
+	}
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: DNSProviderSecretName, Namespace: "istio-system"},
+		Data:       map[string][]byte{"secretAccessKey": []byte("secret")},
+	}
+	if _, err := client.CoreV1().Secrets("istio-system").Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = LoadDNSProviderConfig(context.TODO(), client, "istio-system", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"hostedZoneId": "Z123", "secretAccessKey": "secret"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if len(config) != 1 {
+		t.Fatalf("unexpected change of the configuration: %v", config)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"istio.io/istio/pilot/pkg/acme"
+	// Registers the Route 53 DNS provider.
+	_ "istio.io/istio/pilot/pkg/acme/route53"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/leaderelection"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/kube/configmapwatcher"
+)
+
+// acmeResyncPeriod is the interval at which the ACME controller checks all Gateways for certificates to renew.
+const acmeResyncPeriod = time.Hour
+
+// initACMEController sets up the provisioning of Gateway certificates through ACME. Every istiod serves the
+// HTTP-01 challenge responses, as the Gateways may route the challenges to any replica, while the leader
+// issues and renews the certificates.
+func (s *Server) initACMEController(args *PilotArgs) error {
+	if !features.EnableACME || s.kubeClient == nil || s.RWConfigStore == nil {
+		return nil
+	}
+	challengePort, err := acmeChallengePort(args)
+	if err != nil {
+		return err
+	}
+	accountKey := func(ctx context.Context) (crypto.Signer, error) {
+		return acme.LoadAccountKey(ctx, s.kubeClient.Kube(), args.Namespace)
+	}
+
+	http01 := acme.NewHTTP01Solver(s.kubeClient.Kube(), args.Namespace)
+	s.monitoringMux.Handle(acme.HTTP01ChallengePath, http01)
+	watcher := configmapwatcher.NewController(s.kubeClient, args.Namespace, acme.HTTP01ConfigMapName, http01.Update)
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go watcher.Run(stop)
+		return nil
+	})
+	issuers := map[string]acme.CertificateIssuer{
+		acme.ChallengeHTTP01: acme.NewIssuer(features.ACMEDirectoryURL, features.ACMEEmail, accountKey, http01),
+	}
+
+	if features.ACMEDNSProvider != "" {
+		config := map[string]string{}
+		if features.ACMEDNSProviderConfig != "" {
+			if err := json.Unmarshal([]byte(features.ACMEDNSProviderConfig), &config); err != nil {
+				return fmt.Errorf("invalid PILOT_ACME_DNS_PROVIDER_CONFIG: %v", err)
+			}
+		}
+		config, err = acme.LoadDNSProviderConfig(context.TODO(), s.kubeClient.Kube(), args.Namespace, config)
+		if err != nil {
+			return err
+		}
+		provider, err := acme.NewDNSProvider(features.ACMEDNSProvider, config)
+		if err != nil {
+			return err
+		}
+		issuers[acme.ChallengeDNS01] = acme.NewIssuer(features.ACMEDirectoryURL, features.ACMEEmail, accountKey,
+			acme.NewDNS01Solver(provider))
+	}
+
+	istiodService := "istiod"
+	if args.Revision != "" && args.Revision != "default" {
+		istiodService += "-" + args.Revision
+	}
+	controller := acme.NewController(s.RWConfigStore, s.kubeClient.Kube(), issuers, acme.Options{
+		RenewBefore:  features.ACMERenewBefore,
+		ResyncPeriod: acmeResyncPeriod,
+		HTTP01Host:   fmt.Sprintf("%s.%s.svc.%s", istiodService, args.Namespace, args.RegistryOptions.KubeOptions.DomainSuffix),
+		HTTP01Port:   challengePort,
+	})
+	s.configController.RegisterEventHandler(gvk.Gateway, controller.GatewayHandler)
+	s.addTerminatingStartFunc(func(stop <-chan struct{}) error {
+		leaderelection.
+			NewLeaderElection(args.Namespace, args.PodName, leaderelection.ACMEController, args.Revision, s.kubeClient).
+			AddRunFunction(controller.Run).
+			Run(stop)
+		return nil
+	})
+	return nil
+}
+
+// acmeChallengePort returns the port of the istiod service serving the HTTP-01 challenges, the monitoring
+// port, which is multiplexed on the HTTP port if no monitoring address is set.
+func acmeChallengePort(args *PilotArgs) (uint32, error) {
+	addr := args.ServerOptions.MonitoringAddr
+	if addr == "" {
+		addr = args.ServerOptions.HTTPAddr
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid monitoring address %q: %v", addr, err)
+	}
+	p, err := strconv.ParseUint(port, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid monitoring address %q: %v", addr, err)
+	}
+	return uint32(p), nil
+}
@@ -15,15 +15,25 @@
 package bootstrap
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
 	"net/url"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
 	configaggregate "istio.io/istio/pilot/pkg/config/aggregate"
 	"istio.io/istio/pilot/pkg/config/kube/crdclient"
 	"istio.io/istio/pilot/pkg/config/kube/gateway"
 	"istio.io/istio/pilot/pkg/config/kube/ingress"
 	ingressv1 "istio.io/istio/pilot/pkg/config/kube/ingressv1"
+	"istio.io/istio/pilot/pkg/config/mcp"
 	"istio.io/istio/pilot/pkg/config/memory"
 	configmonitor "istio.io/istio/pilot/pkg/config/monitor"
 	"istio.io/istio/pilot/pkg/controller/workloadentry"
@@ -31,6 +41,7 @@ import (
 	"istio.io/istio/pilot/pkg/leaderelection"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/status/distribution"
+	"istio.io/istio/pilot/pkg/status/gatewaycredentials"
 	"istio.io/istio/pkg/adsc"
 	"istio.io/istio/pkg/config/analysis/incluster"
 	"istio.io/istio/pkg/config/schema/collections"
@@ -128,6 +139,17 @@ func (s *Server) initConfigController(args *PilotArgs) error {
 		})
 	}
 
+	if features.FederationUpstream != "" {
+		// Federate the configs of the upstream istiod, in addition to the local configs.
+		dialOptions, err := s.istioMutualDialOptions(args, features.FederationUpstream)
+		if err != nil {
+			return fmt.Errorf("failed to federate %s: %v", features.FederationUpstream, err)
+		}
+		if err := s.initDeltaConfigSource(args, features.FederationUpstream, features.FederationPrefix, dialOptions); err != nil {
+			return fmt.Errorf("failed to federate %s: %v", features.FederationUpstream, err)
+		}
+	}
+
 	// Wrap the config controller with a cache.
 	aggregateConfigController, err := configaggregate.MakeCache(s.ConfigStores)
 	if err != nil {
@@ -214,6 +236,20 @@ func (s *Server) initK8SConfigStore(args *PilotArgs) error {
 		return err
 	}
 	s.XDSServer.WorkloadEntryController = workloadentry.NewController(configController, args.PodName, args.KeepaliveOptions.MaxServerConnectionAge)
+	if wle := s.XDSServer.WorkloadEntryController; wle != nil && features.WorkloadEntryAutoRegistration && s.kubeClient != nil {
+		// Only one instance needs to list all the WorkloadEntries to clean up those left by disconnected proxies.
+		wle.ElectCleanup()
+		s.addTerminatingStartFunc(func(stop <-chan struct{}) error {
+			leaderelection.
+				NewLeaderElection(args.Namespace, args.PodName, leaderelection.AutoRegistrationController, args.Revision, s.kubeClient).
+				AddRunFunction(func(leaderStop <-chan struct{}) {
+					log.Infof("Starting auto registration cleanup")
+					wle.RunPeriodicCleanup(leaderStop)
+				}).
+				Run(stop)
+			return nil
+		})
+	}
 	return nil
 }
 
@@ -240,6 +276,16 @@ func (s *Server) initConfigSources(args *PilotArgs) (err error) {
 			}
 			s.ConfigStores = append(s.ConfigStores, configController)
 		case XDS:
+			if features.EnableDeltaConfigSource {
+				dialOptions, err := s.configSourceDialOptions(args, srcAddress.Host, configSource.TlsSettings)
+				if err != nil {
+					return fmt.Errorf("invalid TLS settings of config source %s: %v", configSource.Address, err)
+				}
+				if err := s.initDeltaConfigSource(args, srcAddress.Host, "", dialOptions); err != nil {
+					return fmt.Errorf("failed to dial XDS %s %v", configSource.Address, err)
+				}
+				continue
+			}
 			xdsMCP, err := adsc.New(srcAddress.Host, &adsc.Config{
 				Namespace: args.Namespace,
 				Workload:  args.PodName,
@@ -284,6 +330,106 @@ func (s *Server) initConfigSources(args *PilotArgs) (err error) {
 	return nil
 }
 
+// initDeltaConfigSource consumes the config of a MCP-over-xDS server over delta xDS. If namePrefix is set, the
+// names of the configs are prefixed with it.
+func (s *Server) initDeltaConfigSource(args *PilotArgs, address string, namePrefix string, dialOptions []grpc.DialOption) error {
+	store := memory.Make(collections.Pilot)
+	configController := memory.NewController(store)
+	source, err := mcp.New(configController, mcp.Options{
+		Address:   address,
+		Namespace: args.Namespace,
+		Workload:  args.PodName,
+		Revision:  args.Revision,
+		Meta: model.NodeMetadata{
+			Generator: "api",
+			// To reduce transported data if upstream server supports. Especially for custom servers.
+			IstioRevision: args.Revision,
+		}.ToStruct(),
+		Schemas:        collections.Pilot,
+		DialOptions:    dialOptions,
+		ResyncInterval: features.ConfigSourceResyncInterval,
+		NamePrefix:     namePrefix,
+	})
+	if err != nil {
+		return err
+	}
+	configController.RegisterHasSyncedHandler(source.HasSynced)
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go source.Run(stop)
+		return nil
+	})
+	s.ConfigStores = append(s.ConfigStores, configController)
+	log.Infof("Started delta XDS config source %s", address)
+	return nil
+}
+
+// configSourceDialOptions returns the options to dial a config source with its TLS settings. It is dialed in
+// plaintext if the settings are not set or disabled.
+func (s *Server) configSourceDialOptions(args *PilotArgs, address string,
+	settings *networking.ClientTLSSettings) ([]grpc.DialOption, error) {
+	switch settings.GetMode() {
+	case networking.ClientTLSSettings_DISABLE:
+		return []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, nil
+	case networking.ClientTLSSettings_ISTIO_MUTUAL:
+		return s.istioMutualDialOptions(args, address)
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %v", address, err)
+	}
+	cfg := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: settings.GetInsecureSkipVerify().GetValue(), //nolint: gosec
+		MinVersion:         tls.VersionTLS12,
+	}
+	if settings.Sni != "" {
+		cfg.ServerName = settings.Sni
+	}
+	if settings.CaCertificates != "" {
+		rootCert, err := os.ReadFile(settings.CaCertificates)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(rootCert) {
+			return nil, fmt.Errorf("no certificate found in %s", settings.CaCertificates)
+		}
+	}
+	if settings.Mode == networking.ClientTLSSettings_MUTUAL {
+		cert, err := tls.LoadX509KeyPair(settings.ClientCertificate, settings.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(cfg))}, nil
+}
+
+// istioMutualDialOptions returns the options to dial the secure discovery port of an istiod, such as an upstream
+// istiod: the server is verified against the roots of the mesh, and istiod authenticates with its own certificate.
+func (s *Server) istioMutualDialOptions(args *PilotArgs, address string) ([]grpc.DialOption, error) {
+	verifier, err := s.createPeerCertVerifier(args.ServerOptions.TLSOptions)
+	if err != nil {
+		return nil, err
+	}
+	if verifier == nil {
+		return nil, fmt.Errorf("federation requires TLS, but no root certificate is configured")
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %v", address, err)
+	}
+	cfg := &tls.Config{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return s.getIstiodCertificate(nil)
+		},
+		RootCAs:    verifier.GetGeneralCertPool(),
+		ServerName: host,
+		MinVersion: tls.VersionTLS12,
+	}
+	return []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(cfg))}, nil
+}
+
 // initInprocessAnalysisController spins up an instance of Galley which serves no purpose other than
 // running Analyzers for status updates.  The Status Updater will eventually need to allow input from istiod
 // to support config distribution status as well.
@@ -336,6 +482,9 @@ func (s *Server) initStatusController(args *PilotArgs, writeStatus bool) {
 					controller := distribution.NewController(s.kubeClient.RESTConfig(), args.Namespace, s.RWConfigStore, s.statusManager)
 					s.statusReporter.SetController(controller)
 					controller.Start(stop)
+					if s.environment.GatewayCredentials != nil {
+						go gatewaycredentials.NewController(s.RWConfigStore, s.environment.GatewayCredentials, s.statusManager).Run(stop)
+					}
 				}).Run(stop)
 			return nil
 		})
@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"time"
+
+	"istio.io/istio/pilot/pkg/events"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/spiffe"
+)
+
+// lifecycleEventsWebhookTimeout is the timeout of each request to the lifecycle events webhook.
+const lifecycleEventsWebhookTimeout = 5 * time.Second
+
+// initLifecycleEvents sets up the emission of the mesh lifecycle events to the configured sinks.
+func (s *Server) initLifecycleEvents() {
+	var sinks []events.Sink
+	if features.EnableKubernetesLifecycleEvents && s.kubeClient != nil {
+		sinks = append(sinks, events.NewKubernetesSink(s.kubeClient.Kube()))
+	}
+	if features.LifecycleEventsWebhookURL != "" {
+		sinks = append(sinks, events.NewWebhookSink(features.LifecycleEventsWebhookURL, lifecycleEventsWebhookTimeout))
+	}
+	if len(sinks) == 0 {
+		return
+	}
+	emitter := events.NewEmitter(features.LifecycleEventsRateLimit, features.LifecycleEventsBurst, sinks...)
+	s.XDSServer.Events = emitter
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go emitter.Run(stop)
+		return nil
+	})
+}
+
+// emitCertificateIssued emits the event of a workload certificate signed by the istiod CA. The event is about
+// the service account of the workload.
+func (s *Server) emitCertificateIssued(identities []string) {
+	if s.XDSServer.Events == nil {
+		return
+	}
+	var obj *events.Object
+	for _, id := range identities {
+		if identity, err := spiffe.ParseIdentity(id); err == nil {
+			obj = &events.Object{Kind: "ServiceAccount", Namespace: identity.Namespace, Name: identity.ServiceAccount}
+			break
+		}
+	}
+	s.XDSServer.Events.Emitf(events.CertificateIssued, false, obj, "", "issued workload certificate for %v", identities)
+}
@@ -28,20 +28,27 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	"google.golang.org/grpc"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
+	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/api/security/v1beta1"
 	"istio.io/istio/pilot/pkg/features"
 	securityModel "istio.io/istio/pilot/pkg/security/model"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/jwt"
 	kubelib "istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/configmapwatcher"
 	"istio.io/istio/pkg/security"
 	"istio.io/istio/security/pkg/cmd"
 	"istio.io/istio/security/pkg/pki/ca"
 	"istio.io/istio/security/pkg/pki/ra"
+	"istio.io/istio/security/pkg/pki/signer"
+	// Registers the AWS KMS CA signer backend.
+	_ "istio.io/istio/security/pkg/pki/signer/awskms"
 	caserver "istio.io/istio/security/pkg/server/ca"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
 	"istio.io/pkg/env"
@@ -132,6 +139,25 @@ var (
 	caRSAKeySize = env.RegisterIntVar("CITADEL_SELF_SIGNED_CA_RSA_KEY_SIZE", 2048,
 		"Specify the RSA key size to use for self-signed Istio CA certificates.")
 
+	caSignerBackend = env.RegisterStringVar("CA_SIGNER_BACKEND", "",
+		"The name of the KMS or HSM backend holding the CA signing key, aws-kms is supported. If set, the signing "+
+			"key is not read from the cacerts Secret, which only needs the CA certificate, the cert chain and the "+
+			"root cert.").Get()
+
+	caSignerConfig = env.RegisterStringVar("CA_SIGNER_CONFIG", "",
+		"The configuration of the CA_SIGNER_BACKEND, as a JSON object of string values. The aws-kms backend "+
+			"requires the keyId of the KMS key and accepts its region.").Get()
+
+	caSignerMaxBatchSize = env.RegisterIntVar("CA_SIGNER_MAX_BATCH_SIZE", 16,
+		"The maximum number of signing operations sent to the CA_SIGNER_BACKEND in a single batch.").Get()
+
+	caSignerBatchWait = env.RegisterDurationVar("CA_SIGNER_BATCH_WAIT", 5*time.Millisecond,
+		"How long a signing operation waits for more operations to batch with, before it is sent to the "+
+			"CA_SIGNER_BACKEND.").Get()
+
+	caSignerTimeout = env.RegisterDurationVar("CA_SIGNER_TIMEOUT", 10*time.Second,
+		"The timeout of a call to the CA_SIGNER_BACKEND.").Get()
+
 	// TODO: Likely to be removed and added to mesh config
 	externalCaType = env.RegisterStringVar("EXTERNAL_CA", "",
 		"External CA Integration Type. Permitted Values are ISTIOD_RA_KUBERNETES_API or "+
@@ -142,6 +168,14 @@ var (
 		"Kubernates CA Signer type. Valid from Kubernates 1.18").Get()
 )
 
+const (
+	// workloadCertPolicyConfigMap is the ConfigMap, in the istiod namespace, holding the per namespace and
+	// per WorkloadGroup workload cert TTL and key policies.
+	workloadCertPolicyConfigMap = "istio-ca-workload-cert-policy"
+	// workloadCertPolicyKey is the key of the policies in workloadCertPolicyConfigMap.
+	workloadCertPolicyKey = "policies"
+)
+
 // EnableCA returns whether CA functionality is enabled in istiod.
 // This is a central consistent endpoint to get whether CA functionality is
 // enabled in istiod. EnableCA() is called in multiple places.
@@ -153,7 +187,7 @@ func (s *Server) EnableCA() bool {
 // Protected by installer options: the CA will be started only if the JWT token in /var/run/secrets
 // is mounted. If it is missing - for example old versions of K8S that don't support such tokens -
 // we will not start the cert-signing server, since pods will have no way to authenticate.
-func (s *Server) RunCA(grpc *grpc.Server, ca caserver.CertificateAuthority, opts *caOptions) {
+func (s *Server) RunCA(grpc *grpc.Server, ca caserver.CertificateAuthority, opts *caOptions, stop <-chan struct{}) {
 	iss := trustedIssuer.Get()
 	aud := audience.Get()
 
@@ -197,11 +231,59 @@ func (s *Server) RunCA(grpc *grpc.Server, ca caserver.CertificateAuthority, opts
 		}
 	}
 
+	s.watchWorkloadCertPolicies(caServer, opts.Namespace, stop)
+	caServer.CertificateIssued = s.emitCertificateIssued
+
 	caServer.Register(grpc)
 
 	log.Info("Istiod CA has started")
 }
 
+// watchWorkloadCertPolicies loads the per namespace and per WorkloadGroup workload cert policies from the
+// workloadCertPolicyConfigMap ConfigMap in the istiod namespace, and keeps the CA server up to date.
+func (s *Server) watchWorkloadCertPolicies(caServer *caserver.Server, namespace string, stop <-chan struct{}) {
+	if s.kubeClient == nil {
+		return
+	}
+	caServer.WorkloadGroupServiceAccount = s.workloadGroupServiceAccount
+	c := configmapwatcher.NewController(s.kubeClient, namespace, workloadCertPolicyConfigMap, func(cm *v1.ConfigMap) {
+		if cm == nil {
+			caServer.SetWorkloadCertPolicies(nil)
+			return
+		}
+		policies, err := caserver.ParseWorkloadCertPolicies([]byte(cm.Data[workloadCertPolicyKey]), maxWorkloadCertTTL.Get())
+		if err != nil {
+			// Keep the last known policies in case there's a misconfiguration issue.
+			log.Errorf("failed to read workload cert policies from ConfigMap %s: %v", workloadCertPolicyConfigMap, err)
+			return
+		}
+		log.Infof("loaded workload cert policies for %d namespaces and %d WorkloadGroups",
+			len(policies.Namespaces), len(policies.WorkloadGroups))
+		caServer.SetWorkloadCertPolicies(policies)
+	})
+	go c.Run(stop)
+}
+
+// workloadGroupServiceAccount returns the service account of the template of a WorkloadGroup, or "" if the
+// WorkloadGroup does not exist.
+func (s *Server) workloadGroupServiceAccount(namespace, name string) string {
+	if s.configController == nil {
+		return ""
+	}
+	cfg := s.configController.Get(gvk.WorkloadGroup, name, namespace)
+	if cfg == nil {
+		return ""
+	}
+	wg, ok := cfg.Spec.(*networking.WorkloadGroup)
+	if !ok {
+		return ""
+	}
+	if sa := wg.GetTemplate().GetServiceAccount(); sa != "" {
+		return sa
+	}
+	return "default"
+}
+
 // detectAuthEnv will use the JWT token that is mounted in istiod to set the default audience
 // and trust domain for Istiod, if not explicitly defined.
 // K8S will use the same kind of tokens for the pods, and the value in istiod's own token is
@@ -406,7 +488,13 @@ func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *caOptions) (
 		// In Istiod, it is possible to provide one via "cacerts" secret in both cases, for consistency.
 		rootCertFile = ""
 	}
-	if _, err := os.Stat(signingKeyFile); err != nil {
+	if caSignerBackend != "" {
+		log.Infof("Use local CA certificate with the %s CA signer backend", caSignerBackend)
+		caOpts, err = createSignerIstioCAOptions(rootCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create an istiod CA: %v", err)
+		}
+	} else if _, err := os.Stat(signingKeyFile); err != nil {
 		// The user-provided certs are missing - create a self-signed cert.
 		if client != nil {
 			log.Info("Use self-signed certificate as the CA certificate")
@@ -465,6 +553,37 @@ func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *caOptions) (
 	return istioCA, nil
 }
 
+// createSignerIstioCAOptions creates the options of a CA whose signing key is held by the CA_SIGNER_BACKEND.
+func createSignerIstioCAOptions(rootCertFile string) (*ca.IstioCAOptions, error) {
+	if rootCertFile == "" {
+		return nil, fmt.Errorf("%s is required with a CA signer backend", ca.RootCertFile)
+	}
+	config := map[string]string{}
+	if caSignerConfig != "" {
+		if err := json.Unmarshal([]byte(caSignerConfig), &config); err != nil {
+			return nil, fmt.Errorf("invalid CA_SIGNER_CONFIG: %v", err)
+		}
+	}
+	backend, err := signer.NewBackend(caSignerBackend, config)
+	if err != nil {
+		return nil, err
+	}
+	caSigner := signer.NewBatchSigner(backend, signer.BatchOptions{
+		MaxBatchSize: caSignerMaxBatchSize,
+		MaxWait:      caSignerBatchWait,
+		Timeout:      caSignerTimeout,
+	})
+	signingCertFile := path.Join(LocalCertDir.Get(), ca.CACertFile)
+	certChainFile := path.Join(LocalCertDir.Get(), ca.CertChainFile)
+	caOpts, err := ca.NewPluggedCertSignerIstioCAOptions(certChainFile, signingCertFile, rootCertFile, caSigner,
+		workloadCertTTL.Get(), maxWorkloadCertTTL.Get())
+	if err != nil {
+		_ = caSigner.Close()
+		return nil, err
+	}
+	return caOpts, nil
+}
+
 // createIstioRA initializes the Istio RA signing functionality.
 // the caOptions defines the external provider
 // ca cert can come from three sources, order matters:
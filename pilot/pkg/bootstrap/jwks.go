@@ -0,0 +1,97 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/atomic"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/pkg/log"
+)
+
+// jwksPrefetcher prefetches the JWKS of all the issuers referenced by RequestAuthentication policies
+// once the config caches are synced, so an unreachable JWKS endpoint is surfaced at startup.
+type jwksPrefetcher struct {
+	done atomic.Bool
+	// listErr is set if the RequestAuthentication policies could not be listed.
+	listErr atomic.Error
+}
+
+// initJwksPrefetch adds the JWKS prefetch to the istiod readiness.
+func (s *Server) initJwksPrefetch() {
+	if !features.EnableJwksPrefetch {
+		return
+	}
+	s.jwksPrefetcher = &jwksPrefetcher{}
+	s.addReadinessProbe("jwks prefetch", s.jwksPrefetchReady)
+}
+
+// prefetchJwks fetches the JWKS of all the issuers referenced by RequestAuthentication policies. It must
+// be called after the config caches are synced.
+func (s *Server) prefetchJwks() {
+	if s.jwksPrefetcher == nil {
+		return
+	}
+	defer s.jwksPrefetcher.done.Store(true)
+	if s.configController == nil || s.XDSServer.JwtKeyResolver == nil {
+		return
+	}
+	configs, err := s.configController.List(gvk.RequestAuthentication, metav1.NamespaceAll)
+	if err != nil {
+		s.jwksPrefetcher.listErr.Store(err)
+		log.Errorf("failed to list RequestAuthentication for JWKS prefetch: %v", err)
+		return
+	}
+	var rules []*v1beta1.JWTRule
+	for _, cfg := range configs {
+		rules = append(rules, cfg.Spec.(*v1beta1.RequestAuthentication).GetJwtRules()...)
+	}
+	start := time.Now()
+	if err := s.XDSServer.JwtKeyResolver.Prefetch(rules); err != nil {
+		log.Warnf("failed to prefetch JWKS, JWT policies of the affected issuers reject all tokens: %v", err)
+		return
+	}
+	log.Infof("prefetched JWKS of %d JWT rules in %v", len(rules), time.Since(start))
+}
+
+// jwksPrefetchReady reports whether the JWKS prefetch has completed. If features.JwksPrefetchRequired is
+// set, it also requires every cached JWKS to have been fetched successfully the last time it was fetched,
+// which recovers as the JWKS refresh job fetches the failed issuers again.
+func (s *Server) jwksPrefetchReady() (bool, error) {
+	if !s.jwksPrefetcher.done.Load() {
+		return false, fmt.Errorf("JWKS prefetch has not completed")
+	}
+	if !features.JwksPrefetchRequired {
+		return true, nil
+	}
+	if err := s.jwksPrefetcher.listErr.Load(); err != nil {
+		return false, err
+	}
+	if s.XDSServer.JwtKeyResolver == nil {
+		return true, nil
+	}
+	for _, st := range s.XDSServer.JwtKeyResolver.Status() {
+		if st.Error != "" {
+			return false, fmt.Errorf("failed to fetch JWKS of issuer %q: %s", st.Issuer, st.Error)
+		}
+	}
+	return true, nil
+}
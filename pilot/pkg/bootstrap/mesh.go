@@ -16,9 +16,13 @@ package bootstrap
 
 import (
 	"encoding/json"
+	"net"
 	"os"
+	"sort"
+	"strconv"
 
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/leaderelection"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/mesh/kubemesh"
 	"istio.io/pkg/filewatcher"
@@ -117,6 +121,51 @@ func (s *Server) initMeshNetworks(args *PilotArgs, fileWatcher filewatcher.FileW
 	}
 }
 
+// initMeshNetworksResources merges the mesh networks of the MeshNetworks resources of the Istio namespace into the
+// mesh networks, if enabled.
+func (s *Server) initMeshNetworksResources(args *PilotArgs) {
+	if !features.EnableMeshNetworksResources || s.kubeClient == nil {
+		return
+	}
+	log.Infof("initializing mesh networks from the %s resources of namespace %s", kubemesh.MeshNetworksResource.Resource, args.Namespace)
+	s.meshNetworksResources = kubemesh.NewNetworksResourceWatcher(s.kubeClient, args.Namespace, s.environment.NetworksWatcher, s.internalStop)
+	s.environment.NetworksWatcher = s.meshNetworksResources
+}
+
+// initMeshNetworksStatus reports the resolved gateways of the networks in the status of the MeshNetworks resources.
+// The status is only written by the status leader.
+func (s *Server) initMeshNetworksStatus(args *PilotArgs) {
+	if s.meshNetworksResources == nil {
+		return
+	}
+	report := func() {
+		gateways := map[string][]string{}
+		for _, gw := range s.environment.NetworkManager.AllGateways() {
+			nw := string(gw.Network)
+			gateways[nw] = append(gateways[nw], net.JoinHostPort(gw.Addr, strconv.Itoa(int(gw.Port))))
+		}
+		for _, addrs := range gateways {
+			sort.Strings(addrs)
+		}
+		s.meshNetworksResources.SetResolvedGateways(gateways)
+	}
+	s.environment.NetworkManager.AppendNetworkGatewayHandler(report)
+	report()
+	s.addTerminatingStartFunc(func(stop <-chan struct{}) error {
+		leaderelection.
+			NewLeaderElection(args.Namespace, args.PodName, leaderelection.StatusController, args.Revision, s.kubeClient).
+			AddRunFunction(func(leaderStop <-chan struct{}) {
+				log.Infof("Starting mesh networks status writer")
+				s.meshNetworksResources.SetStatusWrite(true)
+				<-leaderStop
+				log.Infof("Stopping mesh networks status writer")
+				s.meshNetworksResources.SetStatusWrite(false)
+			}).
+			Run(stop)
+		return nil
+	})
+}
+
 func getMeshConfigMapName(revision string) string {
 	name := defaultMeshConfigMapName
 	if revision == "" || revision == "default" {
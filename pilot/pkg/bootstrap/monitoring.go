@@ -24,6 +24,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"go.opencensus.io/stats/view"
 
+	"istio.io/istio/pkg/http/middleware"
 	"istio.io/pkg/log"
 	"istio.io/pkg/monitoring"
 	"istio.io/pkg/version"
@@ -91,7 +92,7 @@ func startMonitor(addr string, mux *http.ServeMux) (*monitor, error) {
 	if addr != "" {
 		m.monitoringServer = &http.Server{
 			Addr:        listener.Addr().String(),
-			Handler:     mux,
+			Handler:     middleware.Wrap("monitoring", mux, 0),
 			IdleTimeout: 90 * time.Second, // matches http.DefaultTransport keep-alive timeout
 			ReadTimeout: 30 * time.Second,
 		}
@@ -46,7 +46,10 @@ import (
 	istiogrpc "istio.io/istio/pilot/pkg/grpc"
 	"istio.io/istio/pilot/pkg/keycertbundle"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/egressaudit"
 	"istio.io/istio/pilot/pkg/networking/plugin"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/security/authn/mtlsreport"
 	"istio.io/istio/pilot/pkg/server"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
 	kubecontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
@@ -61,8 +64,10 @@ import (
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/mesh/kubemesh"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/http/middleware"
 	istiokeepalive "istio.io/istio/pkg/keepalive"
 	kubelib "istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/inject"
@@ -118,6 +123,9 @@ type Server struct {
 
 	kubeClient kubelib.Client
 
+	// meshNetworksResources merges the mesh networks of the MeshNetworks resources, if enabled.
+	meshNetworksResources *kubemesh.NetworksResourceWatcher
+
 	multiclusterController *multicluster.Controller
 
 	configController  model.ConfigStoreCache
@@ -173,6 +181,8 @@ type Server struct {
 	// Note: this is still best effort; a process can die at any time.
 	readinessProbes map[string]readinessProbe
 
+	jwksPrefetcher *jwksPrefetcher
+
 	// duration used for graceful shutdown.
 	shutdownDuration time.Duration
 
@@ -221,6 +231,29 @@ func NewServer(args *PilotArgs, initFuncs ...func(*Server)) (*Server, error) {
 	// Initialize workload Trust Bundle before XDS Server
 	e.TrustBundle = s.workloadTrustBundle
 	s.XDSServer = xds.NewDiscoveryServer(e, args.Plugins, args.PodName, args.Namespace, args.RegistryOptions.KubeOptions.ClusterAliases)
+	if features.MTLSReportPrometheusAddress != "" {
+		source, err := mtlsreport.NewPrometheusSource(features.MTLSReportPrometheusAddress, features.MTLSReportWindow)
+		if err != nil {
+			return nil, fmt.Errorf("error initializing mTLS report traffic source: %v", err)
+		}
+		s.XDSServer.MTLSTrafficSource = source
+	}
+	if err := util.ValidateMeshTLSPolicy(); err != nil {
+		return nil, err
+	}
+	if _, err := model.ParseConnectionLimits(features.ConnectionLimits); err != nil {
+		return nil, fmt.Errorf("invalid PILOT_CONNECTION_LIMITS: %v", err)
+	}
+	if err := s.XDSServer.InitAuditLog(features.XDSAuditLog); err != nil {
+		return nil, fmt.Errorf("error initializing xDS audit log: %v", err)
+	}
+	if features.OutboundAuditPrometheusAddress != "" {
+		source, err := egressaudit.NewPrometheusSource(features.OutboundAuditPrometheusAddress, features.OutboundAuditWindow)
+		if err != nil {
+			return nil, fmt.Errorf("error initializing external dependencies traffic source: %v", err)
+		}
+		s.XDSServer.ExternalTrafficSource = source
+	}
 
 	prometheus.EnableHandlingTimeHistogram()
 
@@ -236,11 +269,13 @@ func NewServer(args *PilotArgs, initFuncs ...func(*Server)) (*Server, error) {
 	spiffe.SetTrustDomain(s.environment.Mesh().GetTrustDomain())
 
 	s.initMeshNetworks(args, s.fileWatcher)
+	s.initMeshNetworksResources(args)
 	s.initMeshHandlers()
 	s.environment.Init()
 	if err := s.environment.InitNetworksManager(s.XDSServer); err != nil {
 		return nil, err
 	}
+	s.initMeshNetworksStatus(args)
 
 	// Options based on the current 'defaults' in istio.
 	caOpts := &caOptions{
@@ -313,6 +348,12 @@ func NewServer(args *PilotArgs, initFuncs ...func(*Server)) (*Server, error) {
 	// This should be called only after controllers are initialized.
 	s.initRegistryEventHandlers()
 
+	if err := s.initACMEController(args); err != nil {
+		return nil, fmt.Errorf("error initializing ACME controller: %v", err)
+	}
+
+	s.initLifecycleEvents()
+
 	s.initDiscoveryService(args)
 
 	s.initSDSServer()
@@ -361,6 +402,7 @@ func NewServer(args *PilotArgs, initFuncs ...func(*Server)) (*Server, error) {
 	s.addReadinessProbe("discovery", func() (bool, error) {
 		return s.XDSServer.IsServerReady(), nil
 	})
+	s.initJwksPrefetch()
 
 	return s, nil
 }
@@ -424,6 +466,7 @@ func (s *Server) Start(stop <-chan struct{}) error {
 	}
 	// Inform Discovery Server so that it can start accepting connections.
 	s.XDSServer.CachesSynced()
+	go s.prefetchJwks()
 
 	// Race condition - if waitForCache is too fast and we run this as a startup function,
 	// the grpc server would be started before CA is registered. Listening should be last.
@@ -464,6 +507,7 @@ func (s *Server) Start(stop <-chan struct{}) error {
 		// gRPC then all future non-GRPC HTTP2 requests will match the gRPC server and fail. The major
 		// downside of multiplexing by using gRPC's ServeHTTP is that we are using the golang HTTP2
 		// stack. This means a lot of features on the gRPC server (keepalives, etc) do not apply.
+		httpHandler := s.httpServer.Handler
 		multiplexHandler := h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// If we detect gRPC, serve using grpcServer
 			if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("content-type"), "application/grpc") {
@@ -471,7 +515,7 @@ func (s *Server) Start(stop <-chan struct{}) error {
 				return
 			}
 			// Otherwise, this is meant for the standard HTTP server
-			s.httpMux.ServeHTTP(w, r)
+			httpHandler.ServeHTTP(w, r)
 		}), h2s)
 		s.httpServer.Handler = multiplexHandler
 	}
@@ -522,20 +566,10 @@ func (s *Server) initSDSServer() {
 		log.Warnf("skipping Kubernetes credential reader; PILOT_ENABLE_XDS_IDENTITY_CHECK must be set to true for this feature.")
 	} else {
 		creds := kubecredentials.NewMulticluster(s.clusterID)
-		creds.AddSecretHandler(func(name string, namespace string) {
-			s.XDSServer.ConfigUpdate(&model.PushRequest{
-				Full: false,
-				ConfigsUpdated: map[model.ConfigKey]struct{}{
-					{
-						Kind:      gvk.Secret,
-						Name:      name,
-						Namespace: namespace,
-					}: {},
-				},
-				Reason: []model.TriggerReason{model.SecretTrigger},
-			})
-		})
-		s.XDSServer.Generators[v3.SecretType] = xds.NewSecretGen(creds, s.XDSServer.Cache, s.clusterID)
+		creds.AddSecretHandler(s.XDSServer.SecretUpdated)
+		secretGen := xds.NewSecretGen(creds, s.XDSServer.Cache, s.clusterID)
+		s.XDSServer.Generators[v3.SecretType] = secretGen
+		s.environment.GatewayCredentials = secretGen
 		s.multiclusterController.AddHandler(creds)
 	}
 }
@@ -607,8 +641,9 @@ func (s *Server) istiodReadyHandler(w http.ResponseWriter, _ *http.Request) {
 // initIstiodAdminServer initializes monitoring, debug and readiness end points.
 func (s *Server) initIstiodAdminServer(args *PilotArgs, whc func() map[string]string) error {
 	s.httpServer = &http.Server{
-		Addr:        args.ServerOptions.HTTPAddr,
-		Handler:     s.httpMux,
+		Addr: args.ServerOptions.HTTPAddr,
+		// Debug handlers, such as profiles, may take longer than any timeout.
+		Handler:     middleware.Wrap("http", s.httpMux, 0),
 		IdleTimeout: 90 * time.Second, // matches http.DefaultTransport keep-alive timeout
 		ReadTimeout: 30 * time.Second,
 	}
@@ -668,6 +703,11 @@ func (s *Server) initDiscoveryService(args *PilotArgs) {
 func (s *Server) waitForShutdown(stop <-chan struct{}) {
 	go func() {
 		<-stop
+		// Spread the reconnections of the proxies to the other replicas before stopping the gRPC servers, which would
+		// close all the connections at once.
+		if features.XDSDrainDuration > 0 {
+			s.XDSServer.Drain(features.XDSDrainDuration)
+		}
 		close(s.internalStop)
 		_ = s.fileWatcher.Close()
 
@@ -731,6 +771,7 @@ func (s *Server) initGrpcServer(options *istiokeepalive.Options) {
 		prometheus.UnaryServerInterceptor,
 	}
 	grpcOptions := istiogrpc.ServerOptions(options, interceptors...)
+	grpcOptions = append(grpcOptions, grpc.StatsHandler(xds.NewCompressionStatsHandler()))
 	s.grpcServer = grpc.NewServer(grpcOptions...)
 	s.XDSServer.Register(s.grpcServer)
 	reflection.Register(s.grpcServer)
@@ -753,12 +794,13 @@ func (s *Server) initSecureDiscoveryService(args *PilotArgs) error {
 		return nil
 	}
 	log.Info("initializing secure discovery service")
+	verifier := &peerCertVerifierHolder{verifier: peerCertVerifier}
 	cfg := &tls.Config{
 		GetCertificate: s.getIstiodCertificate,
 		ClientAuth:     tls.VerifyClientCertIfGiven,
 		ClientCAs:      peerCertVerifier.GetGeneralCertPool(),
 		VerifyPeerCertificate: func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
-			err := peerCertVerifier.VerifyPeerCert(rawCerts, verifiedChains)
+			err := verifier.get().VerifyPeerCert(rawCerts, verifiedChains)
 			if err != nil {
 				log.Infof("Could not verify certificate: %v", err)
 			}
@@ -767,6 +809,9 @@ func (s *Server) initSecureDiscoveryService(args *PilotArgs) error {
 		MinVersion:   tls.VersionTLS12,
 		CipherSuites: args.ServerOptions.TLSOptions.CipherSuits,
 	}
+	if err := s.watchSpireTrustBundle(args.ServerOptions.TLSOptions, cfg, verifier); err != nil {
+		return err
+	}
 
 	tlsCreds := credentials.NewTLS(cfg)
 
@@ -777,7 +822,7 @@ func (s *Server) initSecureDiscoveryService(args *PilotArgs) error {
 		prometheus.UnaryServerInterceptor,
 	}
 	opts := istiogrpc.ServerOptions(args.KeepaliveOptions, interceptors...)
-	opts = append(opts, grpc.Creds(tlsCreds))
+	opts = append(opts, grpc.Creds(tlsCreds), grpc.StatsHandler(xds.NewCompressionStatsHandler()))
 
 	s.secureGrpcServer = grpc.NewServer(opts...)
 	s.XDSServer.Register(s.secureGrpcServer)
@@ -900,6 +945,11 @@ func (s *Server) initRegistryEventHandlers() {
 				}: {}},
 				Reason: []model.TriggerReason{model.ConfigUpdate},
 			}
+			if rootNamespace := s.environment.Mesh().GetRootNamespace(); model.IsEmergencyLockdownPolicy(curr, rootNamespace) ||
+				model.IsEmergencyLockdownPolicy(prev, rootNamespace) {
+				log.Infof("emergency lockdown policy %s changed, triggering priority push", curr.Key())
+				pushReq.Priority = true
+			}
 			s.XDSServer.ConfigUpdate(pushReq)
 		}
 		schemas := collections.Pilot.All()
@@ -1012,7 +1062,8 @@ func (s *Server) initIstiodCerts(args *PilotArgs, host string) error {
 
 // createPeerCertVerifier creates a SPIFFE certificate verifier with the current istiod configuration.
 func (s *Server) createPeerCertVerifier(tlsOptions TLSOptions) (*spiffe.PeerCertVerifier, error) {
-	if tlsOptions.CaCertFile == "" && s.CA == nil && features.SpiffeBundleEndpoints == "" && !s.isDisableCa() {
+	if tlsOptions.CaCertFile == "" && s.CA == nil && features.SpiffeBundleEndpoints == "" && features.SpireTrustBundlePath == "" &&
+		!s.isDisableCa() {
 		// Running locally without configured certs - no TLS mode
 		return nil, nil
 	}
@@ -1054,6 +1105,10 @@ func (s *Server) createPeerCertVerifier(tlsOptions TLSOptions) (*spiffe.PeerCert
 		peerCertVerifier.AddMappings(certMap)
 	}
 
+	if err := addSpireTrustBundle(peerCertVerifier); err != nil {
+		return nil, err
+	}
+
 	return peerCertVerifier, nil
 }
 
@@ -1165,10 +1220,10 @@ func (s *Server) startCA(caOpts *caOptions) {
 		// Start the RA server if configured, else start the CA server
 		if s.RA != nil {
 			log.Infof("Starting RA")
-			s.RunCA(grpcServer, s.RA, caOpts)
+			s.RunCA(grpcServer, s.RA, caOpts, stop)
 		} else if s.CA != nil {
 			log.Infof("Starting IstioD CA")
-			s.RunCA(grpcServer, s.CA, caOpts)
+			s.RunCA(grpcServer, s.CA, caOpts, stop)
 		}
 		return nil
 	})
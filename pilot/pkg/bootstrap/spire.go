@@ -0,0 +1,127 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/pkg/log"
+)
+
+// peerCertVerifierHolder holds the peer cert verifier of the secure discovery service, which is rebuilt
+// when the SPIRE trust bundle changes.
+type peerCertVerifierHolder struct {
+	mu       sync.RWMutex
+	verifier *spiffe.PeerCertVerifier
+}
+
+func (h *peerCertVerifierHolder) get() *spiffe.PeerCertVerifier {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.verifier
+}
+
+func (h *peerCertVerifierHolder) set(v *spiffe.PeerCertVerifier) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.verifier = v
+}
+
+// addSpireTrustBundle adds the SPIRE trust bundle, if configured, to the peer cert verifier, so proxies
+// presenting SVIDs issued by SPIRE are authenticated.
+func addSpireTrustBundle(v *spiffe.PeerCertVerifier) error {
+	if features.SpireTrustBundlePath == "" {
+		return nil
+	}
+	bundle, err := os.ReadFile(features.SpireTrustBundlePath)
+	if err != nil {
+		return fmt.Errorf("failed to read SPIRE trust bundle: %v", err)
+	}
+	if block, _ := pem.Decode(bundle); block == nil {
+		// The file may be partially written, do not replace the trust bundle with an empty one
+		return fmt.Errorf("SPIRE trust bundle %s has no certificates", features.SpireTrustBundlePath)
+	}
+	trustDomain := features.SpireTrustDomain
+	if trustDomain == "" {
+		trustDomain = spiffe.GetTrustDomain()
+	}
+	if err := v.AddMappingFromPEM(trustDomain, bundle); err != nil {
+		return fmt.Errorf("invalid SPIRE trust bundle: %v", err)
+	}
+	return nil
+}
+
+// watchSpireTrustBundle rebuilds the peer cert verifier of the secure discovery service when the SPIRE
+// trust bundle changes. As the client CAs of a TLS config are fixed, cfg is set up to serve a config with
+// the current client CAs to each connection.
+func (s *Server) watchSpireTrustBundle(tlsOptions TLSOptions, cfg *tls.Config, holder *peerCertVerifierHolder) error {
+	path := features.SpireTrustBundlePath
+	if path == "" {
+		return nil
+	}
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := cfg.Clone()
+		c.GetConfigForClient = nil
+		c.ClientCAs = holder.get().GetGeneralCertPool()
+		// The config returned here replaces the one set up by gRPC, so negotiate HTTP/2 as well.
+		c.NextProtos = []string{"h2"}
+		return c, nil
+	}
+
+	log.Infof("adding watcher for SPIRE trust bundle %s", path)
+	if err := s.fileWatcher.Add(path); err != nil {
+		return fmt.Errorf("could not watch %v: %v", path, err)
+	}
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go func() {
+			var reloadTimerC <-chan time.Time
+			for {
+				select {
+				case <-reloadTimerC:
+					reloadTimerC = nil
+					s.reloadPeerCertVerifier(tlsOptions, holder)
+				case <-s.fileWatcher.Events(path):
+					if reloadTimerC == nil {
+						reloadTimerC = time.After(watchDebounceDelay)
+					}
+				case err := <-s.fileWatcher.Errors(path):
+					log.Errorf("error watching %v: %v", path, err)
+				case <-stop:
+					return
+				}
+			}
+		}()
+		return nil
+	})
+	return nil
+}
+
+// reloadPeerCertVerifier rebuilds the peer cert verifier. On failure, the previous verifier is kept.
+func (s *Server) reloadPeerCertVerifier(tlsOptions TLSOptions, holder *peerCertVerifierHolder) {
+	v, err := s.createPeerCertVerifier(tlsOptions)
+	if err != nil {
+		log.Errorf("failed to reload SPIRE trust bundle, keeping the previous one: %v", err)
+		return
+	}
+	log.Infof("reloaded SPIRE trust bundle %s", features.SpireTrustBundlePath)
+	holder.set(v)
+}
@@ -0,0 +1,117 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func genSpireCA(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	cert, key, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         "spiffe://cluster.local",
+		NotBefore:    time.Now(),
+		TTL:          time.Hour,
+		Org:          "SPIRE",
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func genSpireSVID(t *testing.T, caCert, caKey []byte) []byte {
+	t.Helper()
+	signerCert, err := util.ParsePemEncodedCertificate(caCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signerKey, err := util.ParsePemEncodedKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:       "spiffe://cluster.local/ns/foo/sa/bar",
+		NotBefore:  time.Now(),
+		TTL:        time.Hour,
+		SignerCert: signerCert,
+		SignerPriv: signerKey,
+		Org:        "SPIRE",
+		IsClient:   true,
+		IsServer:   true,
+		RSAKeySize: 2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := util.ParsePemEncodedCertificate(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Raw
+}
+
+func TestSpireTrustBundleReload(t *testing.T) {
+	oldCA, _ := genSpireCA(t)
+	newCA, newKey := genSpireCA(t)
+	svid := genSpireSVID(t, newCA, newKey)
+
+	bundle := filepath.Join(t.TempDir(), "bundle.pem")
+	if err := os.WriteFile(bundle, oldCA, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(p string) { features.SpireTrustBundlePath = p }(features.SpireTrustBundlePath)
+	features.SpireTrustBundlePath = bundle
+
+	s := &Server{}
+	v, err := s.createPeerCertVerifier(TLSOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v == nil {
+		t.Fatalf("expected a peer cert verifier with only the SPIRE trust bundle configured")
+	}
+	holder := &peerCertVerifierHolder{verifier: v}
+	if err := holder.get().VerifyPeerCert([][]byte{svid}, nil); err == nil {
+		t.Fatalf("expected SVID from the new SPIRE CA to be rejected before the trust bundle update")
+	}
+
+	// SPIRE adds the new CA to the bundle before it starts signing with it
+	if err := os.WriteFile(bundle, append(oldCA, newCA...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.reloadPeerCertVerifier(TLSOptions{}, holder)
+	if err := holder.get().VerifyPeerCert([][]byte{svid}, nil); err != nil {
+		t.Fatalf("expected SVID to be trusted after the trust bundle update: %v", err)
+	}
+
+	// An invalid bundle keeps the previous verifier
+	if err := os.WriteFile(bundle, []byte("invalid"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.reloadPeerCertVerifier(TLSOptions{}, holder)
+	if err := holder.get().VerifyPeerCert([][]byte{svid}, nil); err != nil {
+		t.Fatalf("expected the previous trust bundle to be kept: %v", err)
+	}
+}
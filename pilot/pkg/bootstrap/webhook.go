@@ -20,11 +20,17 @@ import (
 	"net/url"
 	"time"
 
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/http/middleware"
 	"istio.io/pkg/log"
 )
 
 const (
 	HTTPSHandlerReadyPath = "/httpsReady"
+
+	// maxWebhookRequestTimeout is the default timeout of the admission webhooks. The API server gives up on the
+	// slower requests, so they must time out before.
+	maxWebhookRequestTimeout = 10 * time.Second
 )
 
 // initSSecureWebhookServer handles initialization for the HTTPS webhook server.
@@ -41,9 +47,14 @@ func (s *Server) initSecureWebhookServer(args *PilotArgs) {
 	log.Info("initializing secure webhook server for istiod webhooks")
 	// create the https server for hosting the k8s injectionWebhook handlers.
 	s.httpsMux = http.NewServeMux()
+	timeout := features.WebhookRequestTimeout
+	if timeout > maxWebhookRequestTimeout {
+		log.Warnf("PILOT_WEBHOOK_REQUEST_TIMEOUT %v exceeds the webhook timeout, using %v", timeout, maxWebhookRequestTimeout)
+		timeout = maxWebhookRequestTimeout
+	}
 	s.httpsServer = &http.Server{
 		Addr:    args.ServerOptions.HTTPSAddr,
-		Handler: s.httpsMux,
+		Handler: middleware.Wrap("webhook", s.httpsMux, timeout),
 		TLSConfig: &tls.Config{
 			GetCertificate: s.getIstiodCertificate,
 			MinVersion:     tls.VersionTLS12,
@@ -17,6 +17,8 @@ package aggregate
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 	"k8s.io/client-go/tools/cache"
@@ -51,6 +53,7 @@ func makeStore(stores []model.ConfigStore, writer model.ConfigStore) (model.Conf
 	result := &store{
 		schemas: schemas,
 		stores:  storeTypes,
+		all:     stores,
 		writer:  writer,
 	}
 
@@ -87,6 +90,9 @@ type store struct {
 	// stores is a mapping from config type to a store
 	stores map[config.GroupVersionKind][]model.ConfigStore
 
+	// all are the aggregated stores
+	all []model.ConfigStore
+
 	writer model.ConfigStore
 }
 
@@ -132,6 +138,54 @@ func (cr *store) List(typ config.GroupVersionKind, namespace string) ([]config.C
 	return configs, errs.ErrorOrNil()
 }
 
+// Snapshot returns a read-only snapshot of the stores, and their versions. The stores which cannot take snapshots
+// are read directly. It returns a nil store if none of the stores can take snapshots.
+func (cr *store) Snapshot() (model.ConfigStore, string, error) {
+	snapshots := make(map[model.ConfigStore]model.ConfigStore, len(cr.all))
+	out := &store{
+		schemas: cr.schemas,
+		stores:  make(map[config.GroupVersionKind][]model.ConfigStore, len(cr.stores)),
+		all:     make([]model.ConfigStore, 0, len(cr.all)),
+	}
+	versions := make([]string, 0, len(cr.all))
+	for _, s := range cr.all {
+		snapshot, version, err := snapshotStore(s)
+		if err != nil {
+			return nil, "", err
+		}
+		snapshots[s] = snapshot
+		out.all = append(out.all, snapshot)
+		if snapshot != s {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, "", nil
+	}
+	for typ, stores := range cr.stores {
+		for _, s := range stores {
+			out.stores[typ] = append(out.stores[typ], snapshots[s])
+		}
+	}
+	return out, strings.Join(versions, ","), nil
+}
+
+// snapshotStore returns a snapshot of the store and its version, or the store itself if it cannot take snapshots.
+func snapshotStore(s model.ConfigStore) (model.ConfigStore, string, error) {
+	snapshotter, ok := s.(model.ConfigSnapshotter)
+	if !ok {
+		return s, "", nil
+	}
+	snapshot, version, err := snapshotter.Snapshot()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to snapshot the %v config store: %v", s.Schemas().Kinds(), err)
+	}
+	if snapshot == nil {
+		return s, "", nil
+	}
+	return snapshot, version, nil
+}
+
 func (cr *store) Delete(typ config.GroupVersionKind, name, namespace string, resourceVersion *string) error {
 	if cr.writer == nil {
 		return errorUnsupported
@@ -172,6 +226,10 @@ type storeCache struct {
 	caches []model.ConfigStoreCache
 }
 
+func (cr *storeCache) Snapshot() (model.ConfigStore, string, error) {
+	return cr.ConfigStore.(model.ConfigSnapshotter).Snapshot()
+}
+
 func (cr *storeCache) HasSynced() bool {
 	for _, cache := range cr.caches {
 		if !cache.HasSynced() {
@@ -15,6 +15,7 @@
 package aggregate
 
 import (
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -263,3 +264,63 @@ func schemaFor(kind, proto string) collection.Schema {
 		}.BuildNoValidate(),
 	}.MustBuild()
 }
+
+// listOnlyStore hides the Snapshot method of the store.
+type listOnlyStore struct {
+	model.ConfigStore
+}
+
+// failingSnapshotStore fails to take snapshots.
+type failingSnapshotStore struct {
+	model.ConfigStore
+}
+
+func (failingSnapshotStore) Snapshot() (model.ConfigStore, string, error) {
+	return nil, "", errors.New("informer failure")
+}
+
+func TestAggregateStoreSnapshot(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	store1 := memory.Make(collection.SchemasFor(collections.K8SGatewayApiV1Alpha2Httproutes))
+	store2 := memory.Make(collection.SchemasFor(collections.K8SGatewayApiV1Alpha2Httproutes))
+	for name, s := range map[string]model.ConfigStore{"snapshotted": store1, "live": store2} {
+		if _, err := s.Create(config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: gvk.HTTPRoute,
+				Name:             name,
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := makeStore([]model.ConfigStore{store1, listOnlyStore{store2}}, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	snapshot, version, err := store.(model.ConfigSnapshotter).Snapshot()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(version).To(gomega.Equal("1"))
+
+	g.Expect(store1.Delete(gvk.HTTPRoute, "snapshotted", "", nil)).To(gomega.Succeed())
+	g.Expect(store2.Delete(gvk.HTTPRoute, "live", "", nil)).To(gomega.Succeed())
+
+	// the store which cannot take snapshots is read directly
+	l, err := snapshot.List(gvk.HTTPRoute, "")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(l).To(gomega.HaveLen(1))
+	g.Expect(l[0].Name).To(gomega.Equal("snapshotted"))
+	_, version, err = store.(model.ConfigSnapshotter).Snapshot()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(version).To(gomega.Equal("2"))
+
+	store, err = makeStore([]model.ConfigStore{listOnlyStore{store1}}, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	snapshot, _, err = store.(model.ConfigSnapshotter).Snapshot()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(snapshot).To(gomega.BeNil())
+
+	store, err = makeStore([]model.ConfigStore{store1, failingSnapshotStore{store2}}, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	_, _, err = store.(model.ConfigSnapshotter).Snapshot()
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("informer failure")))
+}
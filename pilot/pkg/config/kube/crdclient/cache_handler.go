@@ -15,6 +15,9 @@
 package crdclient
 
 import (
+	"sync"
+
+	"go.uber.org/atomic"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/informers"
 
@@ -38,6 +41,11 @@ type cacheHandler struct {
 	informer cache.SharedIndexInformer
 	schema   collection.Schema
 	lister   func(namespace string) cache.GenericNamespaceLister
+
+	// revision is incremented by every event of the informer, after its store is updated.
+	revision     *atomic.Uint64
+	snapshotMu   sync.Mutex
+	lastSnapshot *kindSnapshot
 }
 
 func (h *cacheHandler) onEvent(old interface{}, curr interface{}, event model.Event) error {
@@ -75,6 +83,7 @@ func createCacheHandler(cl *Client, schema collection.Schema, i informers.Generi
 		client:   cl,
 		schema:   schema,
 		informer: i.Informer(),
+		revision: atomic.NewUint64(0),
 	}
 	h.lister = func(namespace string) cache.GenericNamespaceLister {
 		if schema.Resource().IsClusterScoped() {
@@ -86,6 +95,7 @@ func createCacheHandler(cl *Client, schema collection.Schema, i informers.Generi
 	i.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			incrementEvent(kind, "add")
+			h.revision.Inc()
 			if !cl.beginSync.Load() {
 				return
 			}
@@ -95,6 +105,7 @@ func createCacheHandler(cl *Client, schema collection.Schema, i informers.Generi
 		},
 		UpdateFunc: func(old, cur interface{}) {
 			incrementEvent(kind, "update")
+			h.revision.Inc()
 			if !cl.beginSync.Load() {
 				return
 			}
@@ -104,6 +115,7 @@ func createCacheHandler(cl *Client, schema collection.Schema, i informers.Generi
 		},
 		DeleteFunc: func(obj interface{}) {
 			incrementEvent(kind, "delete")
+			h.revision.Inc()
 			if !cl.beginSync.Load() {
 				return
 			}
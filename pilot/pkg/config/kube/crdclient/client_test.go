@@ -333,6 +333,58 @@ func TestClient(t *testing.T) {
 	})
 }
 
+func TestClientSnapshot(t *testing.T) {
+	store, _ := makeClient(t, collections.PilotGatewayAPI.Union(collections.Kube))
+	r := collections.IstioNetworkingV1Alpha3Serviceentries.Resource()
+	create := func(name string) {
+		t.Helper()
+		if _, err := store.Create(config.Config{
+			Meta: config.Meta{GroupVersionKind: r.GroupVersionKind(), Name: name, Namespace: "ns"},
+			Spec: &v1alpha3.ServiceEntry{Hosts: []string{name + ".example.com"}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		retry.UntilSuccessOrFail(t, func() error {
+			if store.Get(r.GroupVersionKind(), name, "ns") == nil {
+				return fmt.Errorf("%s not found", name)
+			}
+			return nil
+		}, retry.Timeout(time.Second))
+	}
+	snapshotter := store.(model.ConfigSnapshotter)
+
+	create("first")
+	snapshot, version, err := snapshotter.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, again, _ := snapshotter.Snapshot(); again != version {
+		t.Fatalf("expected the version %s without event, got %s", version, again)
+	}
+
+	create("second")
+	if got, _ := snapshot.List(r.GroupVersionKind(), "ns"); len(got) != 1 || got[0].Name != "first" {
+		t.Fatalf("expected only the first config in the snapshot, got %v", got)
+	}
+	if got := snapshot.Get(r.GroupVersionKind(), "second", "ns"); got != nil {
+		t.Fatalf("expected the second config not to be in the snapshot, got %v", got)
+	}
+	if _, err := snapshot.Create(config.Config{}); err == nil {
+		t.Fatal("expected the snapshot to be read-only")
+	}
+
+	next, nextVersion, err := snapshotter.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nextVersion == version {
+		t.Fatalf("expected the version %s to change after an event", version)
+	}
+	if got, _ := next.List(r.GroupVersionKind(), model.NamespaceAll); len(got) != 2 {
+		t.Fatalf("expected 2 configs in the new snapshot, got %v", got)
+	}
+}
+
 func createCRD(t test.Failer, client kube.Client, r resource.Schema) {
 	t.Helper()
 	crd := &v1.CustomResourceDefinition{
@@ -0,0 +1,147 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdclient
+
+import (
+	"errors"
+	"strconv"
+
+	klabels "k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collection"
+)
+
+var errReadOnlySnapshot = errors.New("config snapshots are read-only")
+
+var _ model.ConfigSnapshotter = &Client{}
+
+// Snapshot implements model.ConfigSnapshotter, reading the configs from the informers. The configs of a type are
+// only translated again if the informer of the type received an event since the previous snapshot. The version of
+// the snapshot is the number of informer events received by the client.
+func (cl *Client) Snapshot() (model.ConfigStore, string, error) {
+	out := &snapshot{
+		schemas: cl.schemas,
+		kinds:   map[config.GroupVersionKind]*kindSnapshot{},
+	}
+	var revision uint64
+	for _, h := range cl.allKinds() {
+		s, err := h.snapshot()
+		if err != nil {
+			return nil, "", err
+		}
+		out.kinds[h.schema.Resource().GroupVersionKind()] = s
+		revision += s.revision
+	}
+	return out, strconv.FormatUint(revision, 10), nil
+}
+
+// kindSnapshot is the immutable snapshot of the configs of a type.
+type kindSnapshot struct {
+	// revision is the revision of the informer of the type when the snapshot was taken.
+	revision    uint64
+	all         []config.Config
+	byNamespace map[string][]config.Config
+}
+
+// snapshot returns the snapshot of the configs of the informer, reusing the previous one if the informer did not
+// receive any event since.
+func (h *cacheHandler) snapshot() (*kindSnapshot, error) {
+	h.snapshotMu.Lock()
+	defer h.snapshotMu.Unlock()
+	// the revision is read before listing, so that an event racing with the listing invalidates the snapshot
+	revision := h.revision.Load()
+	if h.lastSnapshot != nil && h.lastSnapshot.revision == revision {
+		return h.lastSnapshot, nil
+	}
+	list, err := h.lister(model.NamespaceAll).List(klabels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	kind := h.schema.Resource().GroupVersionKind()
+	s := &kindSnapshot{
+		revision:    revision,
+		all:         make([]config.Config, 0, len(list)),
+		byNamespace: map[string][]config.Config{},
+	}
+	for _, item := range list {
+		cfg := TranslateObject(item, kind, h.client.domainSuffix)
+		if h.client.objectInRevision(&cfg) {
+			s.all = append(s.all, cfg)
+			s.byNamespace[cfg.Namespace] = append(s.byNamespace[cfg.Namespace], cfg)
+		}
+	}
+	h.lastSnapshot = s
+	return s, nil
+}
+
+// snapshot is a read-only store of the snapshots of the configs of each type.
+type snapshot struct {
+	schemas collection.Schemas
+	kinds   map[config.GroupVersionKind]*kindSnapshot
+}
+
+var _ model.ConfigStore = &snapshot{}
+
+func (s *snapshot) Schemas() collection.Schemas {
+	return s.schemas
+}
+
+func (s *snapshot) Get(typ config.GroupVersionKind, name, namespace string) *config.Config {
+	k, f := s.kinds[typ]
+	if !f {
+		return nil
+	}
+	for _, cfg := range k.byNamespace[namespace] {
+		if cfg.Name == name {
+			return &cfg
+		}
+	}
+	return nil
+}
+
+func (s *snapshot) List(typ config.GroupVersionKind, namespace string) ([]config.Config, error) {
+	k, f := s.kinds[typ]
+	if !f {
+		return nil, nil
+	}
+	configs := k.all
+	if namespace != model.NamespaceAll {
+		configs = k.byNamespace[namespace]
+	}
+	// the snapshots are shared by the pushes, which may sort the lists in place
+	return append(make([]config.Config, 0, len(configs)), configs...), nil
+}
+
+func (s *snapshot) Create(config.Config) (string, error) {
+	return "", errReadOnlySnapshot
+}
+
+func (s *snapshot) Update(config.Config) (string, error) {
+	return "", errReadOnlySnapshot
+}
+
+func (s *snapshot) UpdateStatus(config.Config) (string, error) {
+	return "", errReadOnlySnapshot
+}
+
+func (s *snapshot) Patch(config.Config, config.PatchFunc) (string, error) {
+	return "", errReadOnlySnapshot
+}
+
+func (s *snapshot) Delete(config.GroupVersionKind, string, string, *string) error {
+	return errReadOnlySnapshot
+}
@@ -0,0 +1,66 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcp
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	sourceTag     = monitoring.MustCreateLabel("source")
+	collectionTag = monitoring.MustCreateLabel("collection")
+	reasonTag     = monitoring.MustCreateLabel("reason")
+
+	collectionSynced = monitoring.NewGauge(
+		"pilot_config_source_synced",
+		"Whether the state of a collection of an xDS config source was received on the current connection (1) or not (0).",
+		monitoring.WithLabels(sourceTag, collectionTag),
+	)
+
+	collectionResources = monitoring.NewGauge(
+		"pilot_config_source_resources",
+		"Number of resources of a collection received from an xDS config source.",
+		monitoring.WithLabels(sourceTag, collectionTag),
+	)
+
+	collectionRejects = monitoring.NewSum(
+		"pilot_config_source_rejects_total",
+		"Total number of responses of an xDS config source rejected, because of invalid resources.",
+		monitoring.WithLabels(sourceTag, collectionTag),
+	)
+
+	collectionResyncs = monitoring.NewSum(
+		"pilot_config_source_resyncs_total",
+		"Total number of full resyncs of a collection of an xDS config source, by reason.",
+		monitoring.WithLabels(sourceTag, collectionTag, reasonTag),
+	)
+
+	divergedResources = monitoring.NewSum(
+		"pilot_config_source_diverged_resources_total",
+		"Total number of resources of an xDS config source which diverged from the server, such as resources "+
+			"removed by the server while disconnected, found when resyncing.",
+		monitoring.WithLabels(sourceTag, collectionTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		collectionSynced,
+		collectionResources,
+		collectionRejects,
+		collectionResyncs,
+		divergedResources,
+	)
+}
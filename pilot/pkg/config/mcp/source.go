@@ -0,0 +1,538 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mcp consumes the config collections served by a MCP-over-xDS server, such as Istiod or an external
+// config plane, into a config store.
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/gogo/protobuf/types"
+	"github.com/hashicorp/go-multierror"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	pstruct "google.golang.org/protobuf/types/known/structpb"
+	k8s "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	mcpapi "istio.io/api/mcp/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
+	mem "istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/adsc"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/pkg/log"
+)
+
+var mcpLog = log.RegisterScope("mcp", "MCP-over-xDS config source", 0)
+
+// Reasons of the full resyncs of a collection.
+const (
+	resyncDivergence = "divergence"
+	resyncPeriodic   = "periodic"
+)
+
+// errResync interrupts a stream to resync the collections.
+var errResync = errors.New("resync requested")
+
+// Options for a Source.
+type Options struct {
+	// Address of the MCP-over-xDS server.
+	Address string
+
+	// Namespace, Workload and Revision identify the client to the server, as a node.
+	Namespace string
+	Workload  string
+	Revision  string
+
+	// Meta is the metadata of the node.
+	Meta *pstruct.Struct
+
+	// Schemas are the collections consumed from the server.
+	Schemas collection.Schemas
+
+	// DialOptions are added to the default gRPC dial options.
+	DialOptions []grpc.DialOption
+
+	// ResyncInterval is the interval of the full resyncs of the collections. 0 disables the periodic resyncs.
+	ResyncInterval time.Duration
+
+	// BackoffPolicy determines the reconnection delays. Defaults to an exponential backoff.
+	BackoffPolicy backoff.BackOff
+
+	// NamePrefix, if set, prefixes the names of the configs received, followed by a dash, such as for the configs
+	// federated from an upstream istiod, so that they do not collide with the local configs.
+	NamePrefix string
+}
+
+// Source consumes the config collections of a MCP-over-xDS server over delta xDS, into a config store.
+//
+// On reconnection, the versions of the resources already received are sent to the server, so that it only sends
+// the changes. The state of the client and the server may still diverge, with servers not honoring the initial
+// versions or when the client receives the removal of a resource it does not know about: the collection is then
+// resynced in full, by subscribing without initial versions and replacing the content of the store.
+type Source struct {
+	opts   Options
+	store  model.ConfigStore
+	conn   *grpc.ClientConn
+	client discovery.AggregatedDiscoveryServiceClient
+	node   *core.Node
+
+	mu sync.RWMutex
+	// collections by type URL.
+	collections map[string]*collectionState
+
+	// resyncCh interrupts the current stream, to resync the collections marked for it.
+	resyncCh chan struct{}
+}
+
+// collectionState is the state of a collection, as received from the server.
+type collectionState struct {
+	schema collection.Schema
+	// versions of the resources received, by resource name.
+	versions map[string]string
+	// rejected holds the names of the resources received but rejected, which the server may remove.
+	rejected map[string]struct{}
+	// hasSynced is true once the state of the collection was received, and stays true.
+	hasSynced bool
+	// synced is true once the state of the collection was received on the current stream.
+	synced bool
+	// fullResync is set when the collection must be received in full on the next stream.
+	fullResync bool
+}
+
+// New returns a source consuming the collections of the server at the address into the store. The connection is
+// established by Run.
+func New(store model.ConfigStore, opts Options) (*Source, error) {
+	if opts.BackoffPolicy == nil {
+		opts.BackoffPolicy = backoff.NewExponentialBackOff()
+		// Retry forever.
+		opts.BackoffPolicy.(*backoff.ExponentialBackOff).MaxElapsedTime = 0
+	}
+	dialOptions := append(adsc.DefaultGrpcDialOptions(), opts.DialOptions...)
+	conn, err := grpc.Dial(opts.Address, dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	s := &Source{
+		opts:        opts,
+		store:       store,
+		conn:        conn,
+		client:      discovery.NewAggregatedDiscoveryServiceClient(conn),
+		collections: map[string]*collectionState{},
+		resyncCh:    make(chan struct{}, 1),
+		node: &core.Node{
+			Id: fmt.Sprintf("%s~%s~%s.%s~%s.svc.%s", model.SidecarProxy, adsc.PrivateIPIfAvailable(),
+				opts.Workload, opts.Namespace, opts.Namespace, constants.DefaultKubernetesDomain),
+			Metadata: opts.Meta,
+		},
+	}
+	for _, schema := range opts.Schemas.All() {
+		s.collections[schema.Resource().GroupVersionKind().String()] = &collectionState{
+			schema:   schema,
+			versions: map[string]string{},
+			rejected: map[string]struct{}{},
+		}
+	}
+	return s, nil
+}
+
+// HasSynced returns true once the state of all the collections was received.
+func (s *Source) HasSynced() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, st := range s.collections {
+		if !st.hasSynced {
+			return false
+		}
+	}
+	return true
+}
+
+// Run consumes the collections until stopped, reconnecting with backoff.
+func (s *Source) Run(stop <-chan struct{}) {
+	defer s.conn.Close()
+	if s.opts.ResyncInterval > 0 {
+		go s.resyncPeriodically(stop)
+	}
+	for {
+		err := s.stream(stop)
+		s.setUnsynced()
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if err == errResync {
+			continue
+		}
+		delay := s.opts.BackoffPolicy.NextBackOff()
+		mcpLog.Warnf("connection to config source %s closed, reconnecting in %v: %v", s.opts.Address, delay, err)
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (s *Source) resyncPeriodically(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.opts.ResyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			for _, st := range s.collections {
+				s.markResync(st, resyncPeriodic)
+			}
+			s.mu.Unlock()
+			s.requestResync()
+		}
+	}
+}
+
+// markResync marks a collection to be resynced in full on the next stream. The lock must be held.
+func (s *Source) markResync(st *collectionState, reason string) {
+	st.fullResync = true
+	collectionResyncs.With(sourceTag.Value(s.opts.Address), collectionTag.Value(st.schema.Name().String()),
+		reasonTag.Value(reason)).Increment()
+}
+
+// requestResync interrupts the current stream, to resync the collections marked for it.
+func (s *Source) requestResync() {
+	select {
+	case s.resyncCh <- struct{}{}:
+	default:
+	}
+}
+
+// stream consumes the collections over a delta xDS stream, until it fails, is stopped or a resync is requested.
+func (s *Source) stream(stop <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resyncing := make(chan struct{})
+	go func() {
+		select {
+		case <-stop:
+		case <-s.resyncCh:
+			close(resyncing)
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+
+	stream, err := s.client.DeltaAggregatedResources(ctx)
+	if err != nil {
+		return err
+	}
+	for i, req := range s.initialRequests() {
+		if i == 0 {
+			req.Node = s.node
+		}
+		if err := stream.Send(req); err != nil {
+			return err
+		}
+	}
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			select {
+			case <-resyncing:
+				return errResync
+			default:
+				return err
+			}
+		}
+		s.opts.BackoffPolicy.Reset()
+		ack, diverged := s.handleResponse(resp)
+		if diverged {
+			mcpLog.Infof("config source %s diverged for %s, resyncing", s.opts.Address, resp.TypeUrl)
+			return errResync
+		}
+		if err := stream.Send(ack); err != nil {
+			return err
+		}
+	}
+}
+
+// initialRequests subscribes to all the collections, with the versions already received unless the collection
+// is resynced in full.
+func (s *Source) initialRequests() []*discovery.DeltaDiscoveryRequest {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	reqs := make([]*discovery.DeltaDiscoveryRequest, 0, len(s.collections))
+	for typeURL, st := range s.collections {
+		req := &discovery.DeltaDiscoveryRequest{TypeUrl: typeURL}
+		if !st.fullResync && len(st.versions) > 0 {
+			req.InitialResourceVersions = make(map[string]string, len(st.versions))
+			for name, version := range st.versions {
+				req.InitialResourceVersions[name] = version
+			}
+		}
+		reqs = append(reqs, req)
+	}
+	sort.Slice(reqs, func(i, j int) bool {
+		return reqs[i].TypeUrl < reqs[j].TypeUrl
+	})
+	return reqs
+}
+
+// setUnsynced records the collections as not synced, once the stream is closed.
+func (s *Source) setUnsynced() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, st := range s.collections {
+		st.synced = false
+		s.recordState(st)
+	}
+}
+
+func (s *Source) recordState(st *collectionState) {
+	synced := 0.0
+	if st.synced {
+		synced = 1
+	}
+	collection := collectionTag.Value(st.schema.Name().String())
+	collectionSynced.With(sourceTag.Value(s.opts.Address), collection).Record(synced)
+	collectionResources.With(sourceTag.Value(s.opts.Address), collection).Record(float64(len(st.versions)))
+}
+
+// handleResponse applies a response to the store, and returns the ACK or NACK to send, or whether the collection
+// diverged from the server and must be resynced.
+func (s *Source) handleResponse(resp *discovery.DeltaDiscoveryResponse) (*discovery.DeltaDiscoveryRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ack := &discovery.DeltaDiscoveryRequest{TypeUrl: resp.TypeUrl, ResponseNonce: resp.Nonce}
+	st, f := s.collections[resp.TypeUrl]
+	if !f {
+		ack.ErrorDetail = &status.Status{Code: int32(codes.InvalidArgument), Message: "unknown collection " + resp.TypeUrl}
+		return ack, false
+	}
+	gvk := st.schema.Resource().GroupVersionKind()
+
+	// Removals of resources never received mean the state of the server differs from ours.
+	for _, name := range resp.RemovedResources {
+		_, rejected := st.rejected[name]
+		if _, f := st.versions[name]; !f && !rejected {
+			divergedResources.With(sourceTag.Value(s.opts.Address), collectionTag.Value(st.schema.Name().String())).Increment()
+			s.markResync(st, resyncDivergence)
+			return nil, true
+		}
+	}
+
+	var errs error
+	received := map[string]struct{}{}
+	for _, r := range resp.Resources {
+		received[r.Name] = struct{}{}
+		delete(st.rejected, r.Name)
+		cfg, err := s.convert(r)
+		if err != nil {
+			st.rejected[r.Name] = struct{}{}
+			errs = multierror.Append(errs, fmt.Errorf("%s: %v", r.Name, err))
+			continue
+		}
+		version := r.Version
+		if cfg == nil {
+			// Not in our revision.
+			s.remove(st, r.Name)
+			continue
+		}
+		if version == "" {
+			version = cfg.ResourceVersion
+		}
+		if existing, f := st.versions[r.Name]; f && version != "" && existing == version {
+			continue
+		}
+		cfg.GroupVersionKind = gvk
+		if err := s.upsert(cfg); err != nil {
+			st.rejected[r.Name] = struct{}{}
+			errs = multierror.Append(errs, fmt.Errorf("%s: %v", r.Name, err))
+			continue
+		}
+		st.versions[r.Name] = version
+	}
+	for _, name := range resp.RemovedResources {
+		s.remove(st, name)
+	}
+	if st.fullResync {
+		// The response holds the whole collection: the resources not sent were removed while we did not know.
+		for name := range st.versions {
+			if _, f := received[name]; !f {
+				divergedResources.With(sourceTag.Value(s.opts.Address), collectionTag.Value(st.schema.Name().String())).Increment()
+				s.remove(st, name)
+			}
+		}
+		st.fullResync = false
+	}
+	st.hasSynced = true
+	st.synced = true
+	s.recordState(st)
+
+	if errs != nil {
+		mcpLog.Warnf("rejecting %s from config source %s: %v", resp.TypeUrl, s.opts.Address, errs)
+		collectionRejects.With(sourceTag.Value(s.opts.Address), collectionTag.Value(st.schema.Name().String())).Increment()
+		ack.ErrorDetail = &status.Status{Code: int32(codes.InvalidArgument), Message: errs.Error()}
+	}
+	return ack, false
+}
+
+// convert returns the config of a resource, or nil if it is not in the revision.
+func (s *Source) convert(r *discovery.Resource) (*config.Config, error) {
+	if r.Resource == nil {
+		return nil, fmt.Errorf("missing resource")
+	}
+	m := &mcpapi.Resource{}
+	if err := types.UnmarshalAny(&types.Any{TypeUrl: r.Resource.TypeUrl, Value: r.Resource.Value}, m); err != nil {
+		return nil, err
+	}
+	cfg, err := adsc.MCPToConfig(m, s.opts.Revision)
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("missing metadata")
+	}
+	cfg.Name = s.localName(cfg.Name)
+	if s.opts.NamePrefix != "" {
+		s.renameReferences(cfg)
+	}
+	return cfg, nil
+}
+
+// localName returns the name in the store of a config named name by the server.
+func (s *Source) localName(name string) string {
+	if s.opts.NamePrefix == "" {
+		return name
+	}
+	return s.opts.NamePrefix + "-" + name
+}
+
+// renameReferences updates the references of a config to the other configs of the server, as they are renamed.
+func (s *Source) renameReferences(cfg *config.Config) {
+	switch spec := cfg.Spec.(type) {
+	case *networking.VirtualService:
+		s.renameGateways(spec)
+		s.renameDelegates(spec)
+	case *k8s.HTTPRouteSpec:
+		s.renameParentRefs(spec.ParentRefs)
+	case *k8s.TCPRouteSpec:
+		s.renameParentRefs(spec.ParentRefs)
+	case *k8s.TLSRouteSpec:
+		s.renameParentRefs(spec.ParentRefs)
+	}
+}
+
+// renameDelegates updates the delegate virtual services of the routes of a virtual service.
+func (s *Source) renameDelegates(vs *networking.VirtualService) {
+	for _, h := range vs.Http {
+		if h.Delegate != nil && h.Delegate.Name != "" {
+			h.Delegate.Name = s.localName(h.Delegate.Name)
+		}
+	}
+}
+
+// renameParentRefs updates the Gateways a Gateway API route is attached to.
+func (s *Source) renameParentRefs(refs []k8s.ParentRef) {
+	for i, ref := range refs {
+		if ref.Group != nil && string(*ref.Group) != gvk.KubernetesGateway.Group {
+			continue
+		}
+		if ref.Kind != nil && string(*ref.Kind) != gvk.KubernetesGateway.Kind {
+			continue
+		}
+		refs[i].Name = k8s.ObjectName(s.localName(string(ref.Name)))
+	}
+}
+
+// renameGateways updates the gateways a virtual service is bound to, as the gateways of the server are renamed.
+func (s *Source) renameGateways(vs *networking.VirtualService) {
+	rename := func(gateways []string) {
+		for i, gw := range gateways {
+			if gw == constants.IstioMeshGateway {
+				continue
+			}
+			if parts := strings.SplitN(gw, "/", 2); len(parts) == 2 {
+				gateways[i] = parts[0] + "/" + s.localName(parts[1])
+			} else {
+				gateways[i] = s.localName(gw)
+			}
+		}
+	}
+	rename(vs.Gateways)
+	for _, h := range vs.Http {
+		for _, m := range h.Match {
+			rename(m.Gateways)
+		}
+	}
+	for _, t := range vs.Tls {
+		for _, m := range t.Match {
+			rename(m.Gateways)
+		}
+	}
+	for _, t := range vs.Tcp {
+		for _, m := range t.Match {
+			rename(m.Gateways)
+		}
+	}
+}
+
+// upsert creates or updates a config in the store.
+func (s *Source) upsert(cfg *config.Config) error {
+	existing := s.store.Get(cfg.GroupVersionKind, cfg.Name, cfg.Namespace)
+	if existing == nil {
+		_, err := s.store.Create(*cfg)
+		return err
+	}
+	// Keep the version of the server, see memory.ResourceVersion.
+	if cfg.ResourceVersion != "" {
+		cfg.Annotations[mem.ResourceVersion] = cfg.ResourceVersion
+	}
+	cfg.ResourceVersion = existing.ResourceVersion
+	_, err := s.store.Update(*cfg)
+	return err
+}
+
+// remove deletes a resource, named namespace/name, from the store.
+func (s *Source) remove(st *collectionState, name string) {
+	delete(st.versions, name)
+	delete(st.rejected, name)
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) != 2 {
+		return
+	}
+	gvk := st.schema.Resource().GroupVersionKind()
+	localName := s.localName(parts[1])
+	if s.store.Get(gvk, localName, parts[0]) == nil {
+		return
+	}
+	if err := s.store.Delete(gvk, localName, parts[0], nil); err != nil {
+		mcpLog.Warnf("failed to delete %s %s from config source %s: %v", gvk.Kind, name, s.opts.Address, err)
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcp
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	k8s "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func virtualService(name, host string) config.Config {
+	return config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.VirtualService,
+			Name:             name,
+			Namespace:        "default",
+		},
+		Spec: &networking.VirtualService{
+			Hosts: []string{host},
+			Http: []*networking.HTTPRoute{{
+				Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: host}}},
+			}},
+		},
+	}
+}
+
+func newTestSource(t *testing.T, configs ...config.Config) (*xds.FakeDiscoveryServer, *Source, model.ConfigStore) {
+	return newTestSourceWithPrefix(t, "", configs...)
+}
+
+func newTestSourceWithPrefix(t *testing.T, prefix string, configs ...config.Config) (*xds.FakeDiscoveryServer, *Source, model.ConfigStore) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{Configs: configs})
+	store := memory.Make(collections.Pilot)
+	src, err := New(store, Options{
+		Address: "buffcon",
+		Meta:    model.NodeMetadata{Generator: "api"}.ToStruct(),
+		Schemas: collection.SchemasFor(collections.IstioNetworkingV1Alpha3Virtualservices),
+		DialOptions: []grpc.DialOption{
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+				return s.BufListener.Dial()
+			}),
+		},
+		NamePrefix: prefix,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	go src.Run(stop)
+	return s, src, store
+}
+
+func expectHosts(t *testing.T, store model.ConfigStore, want map[string]string) {
+	t.Helper()
+	retry.UntilOrFail(t, func() bool {
+		cfgs, _ := store.List(gvk.VirtualService, "")
+		if len(cfgs) != len(want) {
+			return false
+		}
+		for _, c := range cfgs {
+			if want[c.Name] != c.Spec.(*networking.VirtualService).Hosts[0] {
+				return false
+			}
+		}
+		return true
+	}, retry.Timeout(time.Second*10))
+}
+
+func TestSource(t *testing.T) {
+	s, src, store := newTestSource(t, virtualService("a", "a.example.com"))
+	retry.UntilOrFail(t, src.HasSynced, retry.Timeout(time.Second*10))
+	expectHosts(t, store, map[string]string{"a": "a.example.com"})
+
+	if _, err := s.Store().Create(virtualService("b", "b.example.com")); err != nil {
+		t.Fatal(err)
+	}
+	expectHosts(t, store, map[string]string{"a": "a.example.com", "b": "b.example.com"})
+
+	if err := s.Store().Delete(gvk.VirtualService, "a", "default", nil); err != nil {
+		t.Fatal(err)
+	}
+	expectHosts(t, store, map[string]string{"b": "b.example.com"})
+}
+
+func TestSourceNamePrefix(t *testing.T) {
+	vs := virtualService("a", "a.example.com")
+	spec := vs.Spec.(*networking.VirtualService)
+	spec.Gateways = []string{"mesh", "gw", "istio-system/gw"}
+	spec.Http[0].Match = []*networking.HTTPMatchRequest{{Gateways: []string{"gw"}}}
+	s, _, store := newTestSourceWithPrefix(t, "federated", vs)
+	expectHosts(t, store, map[string]string{"federated-a": "a.example.com"})
+
+	// The gateways of the server are renamed too.
+	got := store.Get(gvk.VirtualService, "federated-a", "default").Spec.(*networking.VirtualService)
+	if want := []string{"mesh", "federated-gw", "istio-system/federated-gw"}; !reflect.DeepEqual(got.Gateways, want) {
+		t.Fatalf("expected gateways %v, got %v", want, got.Gateways)
+	}
+	if want := []string{"federated-gw"}; !reflect.DeepEqual(got.Http[0].Match[0].Gateways, want) {
+		t.Fatalf("expected match gateways %v, got %v", want, got.Http[0].Match[0].Gateways)
+	}
+
+	if err := s.Store().Delete(gvk.VirtualService, "a", "default", nil); err != nil {
+		t.Fatal(err)
+	}
+	expectHosts(t, store, map[string]string{})
+}
+
+func TestSourceNamePrefixDelegates(t *testing.T) {
+	// The delegate virtual services have no hosts.
+	delegate := func(name, to string) config.Config {
+		vs := virtualService(name, "a.example.com")
+		spec := vs.Spec.(*networking.VirtualService)
+		if to == "" {
+			spec.Hosts = nil
+		}
+		if to != "" {
+			spec.Http[0] = &networking.HTTPRoute{Delegate: &networking.Delegate{Name: to, Namespace: "default"}}
+		}
+		return vs
+	}
+	_, src, store := newTestSourceWithPrefix(t, "federated",
+		delegate("root", "child"), delegate("other", "child"), delegate("child", ""))
+	retry.UntilOrFail(t, func() bool {
+		cfgs, _ := store.List(gvk.VirtualService, "")
+		return src.HasSynced() && len(cfgs) == 3
+	}, retry.Timeout(time.Second*10))
+
+	// The delegates are renamed, so that the delegation chains resolve in the store.
+	for from, to := range map[string]string{"federated-root": "federated-child", "federated-other": "federated-child"} {
+		d := store.Get(gvk.VirtualService, from, "default").Spec.(*networking.VirtualService).Http[0].Delegate
+		if d.Name != to || d.Namespace != "default" {
+			t.Fatalf("expected %s to delegate to default/%s, got %s/%s", from, to, d.Namespace, d.Name)
+		}
+		if store.Get(gvk.VirtualService, d.Name, d.Namespace) == nil {
+			t.Fatalf("expected the delegate %s of %s in the store", d.Name, from)
+		}
+	}
+}
+
+func TestRenameParentRefs(t *testing.T) {
+	src := &Source{opts: Options{NamePrefix: "federated"}}
+	mesh := k8s.Kind("Mesh")
+	gateway := k8s.Kind("Gateway")
+	cfg := &config.Config{Spec: &k8s.HTTPRouteSpec{CommonRouteSpec: k8s.CommonRouteSpec{ParentRefs: []k8s.ParentRef{
+		{Name: "gw"},
+		{Kind: &gateway, Name: "other"},
+		{Kind: &mesh, Name: "istio"},
+	}}}}
+	src.renameReferences(cfg)
+	var got []string
+	for _, ref := range cfg.Spec.(*k8s.HTTPRouteSpec).ParentRefs {
+		got = append(got, string(ref.Name))
+	}
+	if want := []string{"federated-gw", "federated-other", "istio"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected parent refs %v, got %v", want, got)
+	}
+}
+
+func TestSourceResync(t *testing.T) {
+	_, src, store := newTestSource(t, virtualService("a", "a.example.com"))
+	expectHosts(t, store, map[string]string{"a": "a.example.com"})
+
+	// A resource removed by the server while we did not know, which a full resync removes.
+	stale := virtualService("stale", "stale.example.com")
+	if _, err := store.Create(stale); err != nil {
+		t.Fatal(err)
+	}
+	src.mu.Lock()
+	st := src.collections[gvk.VirtualService.String()]
+	st.versions["default/stale"] = "1"
+	src.markResync(st, resyncPeriodic)
+	src.mu.Unlock()
+	src.requestResync()
+	expectHosts(t, store, map[string]string{"a": "a.example.com"})
+}
+
+func TestSourceDivergence(t *testing.T) {
+	store := memory.Make(collections.Pilot)
+	src, err := New(store, Options{
+		Address:     "localhost:0",
+		Schemas:     collection.SchemasFor(collections.IstioNetworkingV1Alpha3Virtualservices),
+		DialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.conn.Close()
+
+	typeURL := gvk.VirtualService.String()
+	ack, diverged := src.handleResponse(&discovery.DeltaDiscoveryResponse{TypeUrl: typeURL, Nonce: "1"})
+	if diverged || ack.ErrorDetail != nil || ack.ResponseNonce != "1" {
+		t.Fatalf("expected ACK of the initial response, got %v diverged=%v", ack, diverged)
+	}
+	if !src.HasSynced() {
+		t.Fatalf("expected synced after the initial response")
+	}
+
+	// Removing a resource we never received means we diverged from the server.
+	_, diverged = src.handleResponse(&discovery.DeltaDiscoveryResponse{
+		TypeUrl:          typeURL,
+		Nonce:            "2",
+		RemovedResources: []string{"default/unknown"},
+	})
+	if !diverged {
+		t.Fatalf("expected divergence on the removal of an unknown resource")
+	}
+	reqs := src.initialRequests()
+	if len(reqs) != 1 || reqs[0].InitialResourceVersions != nil {
+		t.Fatalf("expected a full resync subscription, got %v", reqs)
+	}
+
+	// Invalid resources are rejected.
+	ack, _ = src.handleResponse(&discovery.DeltaDiscoveryResponse{
+		TypeUrl:   typeURL,
+		Nonce:     "3",
+		Resources: []*discovery.Resource{{Name: "default/invalid"}},
+	})
+	if ack.ErrorDetail == nil {
+		t.Fatalf("expected NACK of an invalid resource")
+	}
+}
@@ -153,6 +153,14 @@ func (c *Controller) Delete(kind config.GroupVersionKind, key, namespace string,
 	return errors.New("Delete failure: config" + key + "does not exist")
 }
 
+// Snapshot returns a snapshot of the underlying store, if it can take snapshots.
+func (c *Controller) Snapshot() (model.ConfigStore, string, error) {
+	if s, ok := c.configStore.(model.ConfigSnapshotter); ok {
+		return s.Snapshot()
+	}
+	return nil, "", nil
+}
+
 func (c *Controller) List(kind config.GroupVersionKind, namespace string) ([]config.Config, error) {
 	return c.configStore.List(kind, namespace)
 }
@@ -18,6 +18,7 @@ package memory
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
@@ -64,6 +65,34 @@ type store struct {
 	data           map[config.GroupVersionKind]map[string]*sync.Map
 	skipValidation bool
 	mutex          sync.RWMutex
+	// revision is incremented by every write.
+	revision uint64
+}
+
+var _ model.ConfigSnapshotter = &store{}
+
+// Snapshot returns a copy of the store, and its revision. Writes to the copy are not visible in the store.
+func (cr *store) Snapshot() (model.ConfigStore, string, error) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+	out := &store{
+		schemas:        cr.schemas,
+		data:           make(map[config.GroupVersionKind]map[string]*sync.Map, len(cr.data)),
+		skipValidation: cr.skipValidation,
+		revision:       cr.revision,
+	}
+	for kind, namespaces := range cr.data {
+		out.data[kind] = make(map[string]*sync.Map, len(namespaces))
+		for namespace, configs := range namespaces {
+			copied := new(sync.Map)
+			configs.Range(func(key, value interface{}) bool {
+				copied.Store(key, value)
+				return true
+			})
+			out.data[kind][namespace] = copied
+		}
+	}
+	return out, strconv.FormatUint(cr.revision, 10), nil
 }
 
 func (cr *store) Schemas() collection.Schemas {
@@ -138,6 +167,7 @@ func (cr *store) Delete(kind config.GroupVersionKind, name, namespace string, re
 	}
 
 	ns.Delete(name)
+	cr.revision++
 	return nil
 }
 
@@ -173,6 +203,7 @@ func (cr *store) Create(cfg config.Config) (string, error) {
 		}
 
 		ns.Store(cfg.Name, cfg)
+		cr.revision++
 		return cfg.ResourceVersion, nil
 	}
 	return "", errAlreadyExists
@@ -212,6 +243,7 @@ func (cr *store) Update(cfg config.Config) (string, error) {
 	}
 
 	ns.Store(cfg.Name, cfg)
+	cr.revision++
 	return cfg.ResourceVersion, nil
 }
 
@@ -248,6 +280,7 @@ func (cr *store) Patch(orig config.Config, patchFn config.PatchFunc) (string, er
 	rev := time.Now().String()
 	cfg.ResourceVersion = rev
 	ns.Store(cfg.Name, cfg)
+	cr.revision++
 
 	return rev, nil
 }
@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/test/mock"
 	"istio.io/istio/pkg/config/schema/collections"
 )
@@ -31,3 +32,25 @@ func TestIstioConfig(t *testing.T) {
 	store := memory.Make(collections.Pilot)
 	mock.CheckIstioConfigTypes(store, "some-namespace", t)
 }
+
+func TestStoreSnapshot(t *testing.T) {
+	store := memory.Make(collections.Mocks)
+	cfg := mock.Make("some-namespace", 0)
+	if _, err := store.Create(cfg); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, version, err := store.(model.ConfigSnapshotter).Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Delete(cfg.GroupVersionKind, cfg.Name, cfg.Namespace, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := snapshot.Get(cfg.GroupVersionKind, cfg.Name, cfg.Namespace); got == nil {
+		t.Fatalf("expected config %s in the snapshot after its deletion", cfg.Name)
+	}
+	if _, next, _ := store.(model.ConfigSnapshotter).Snapshot(); next == version {
+		t.Fatalf("expected the version %s to change after a write", version)
+	}
+}
@@ -53,6 +53,7 @@ func init() {
 	monitoring.MustRegister(autoRegistrationUnregistrations)
 	monitoring.MustRegister(autoRegistrationDeletes)
 	monitoring.MustRegister(autoRegistrationErrors)
+	monitoring.MustRegister(autoRegistrationContested)
 }
 
 var (
@@ -80,6 +81,18 @@ var (
 		"auto_registration_errors_total",
 		"Total number of auto registration errors.",
 	)
+
+	contestReason = monitoring.MustCreateLabel("reason")
+
+	// autoRegistrationContested records the auto-registered WorkloadEntries contested by several instances:
+	// taken over from another instance which still considered the workload connected ("takeover"), left to another
+	// instance holding a live lease until it is released or expires ("held"), left to another instance with a newer
+	// connection of the workload ("stale"), or found taken over by another instance when renewing the lease ("lost").
+	autoRegistrationContested = monitoring.NewSum(
+		"auto_registration_contested_total",
+		"Total number of auto-registered WorkloadEntries contested by istiod instances.",
+		monitoring.WithLabels(contestReason),
+	)
 )
 
 const (
@@ -93,6 +106,9 @@ const (
 	ConnectedAtAnnotation = "istio.io/connectedAt"
 	// DisconnectedAtAnnotation on a WorkloadEntry stores the time in nanoseconds when the associated workload disconnected from a Pilot instance.
 	DisconnectedAtAnnotation = "istio.io/disconnectedAt"
+	// LeaseExpiresAtAnnotation on a WorkloadEntry stores the time until which the Pilot instance connected to the workload
+	// holds the lease on it. It is renewed while the workload is connected, if PILOT_WORKLOAD_ENTRY_LEASE_TTL is set.
+	LeaseExpiresAtAnnotation = "istio.io/leaseExpiresAt"
 
 	timeFormat = time.RFC3339Nano
 	// maxRetries is the number of times a service will be retried before it is dropped out of the queue.
@@ -154,10 +170,29 @@ type Controller struct {
 
 	// healthCondition is a fifo queue used for updating health check status
 	healthCondition cache.Queue
+
+	// leaseTTL is the TTL of the leases on the WorkloadEntries of the connected workloads, leases are disabled if 0.
+	leaseTTL time.Duration
+	// leases are the leases of this instance on the WorkloadEntries of the connected workloads, held or pending.
+	leases map[kubetypes.NamespacedName]*lease
+
+	// electedCleanup is set when the periodic cleanup is run by RunPeriodicCleanup, under a leader election,
+	// instead of by every instance in Run.
+	electedCleanup bool
 }
 
 type HealthStatus = v1alpha1.IstioCondition
 
+// lease is the lease of this instance on the WorkloadEntry of a connected workload.
+type lease struct {
+	// conTime is the connection time of the workload.
+	conTime time.Time
+	// proxy is set while the entry is held by another instance, until this instance takes it over.
+	proxy *model.Proxy
+	// renewAt is the time the lease is renewed, or its takeover attempted, next.
+	renewAt time.Time
+}
+
 // NewController create a controller which manages workload lifecycle and health status.
 func NewController(store model.ConfigStoreCache, instanceID string, maxConnAge time.Duration) *Controller {
 	if features.WorkloadEntryAutoRegistration || features.WorkloadEntryHealthChecks {
@@ -173,7 +208,9 @@ func NewController(store model.ConfigStoreCache, instanceID string, maxConnAge t
 			cleanupQueue:     queue.NewDelayed(),
 			queue:            workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
 			adsConnections:   map[string]uint8{},
+			leases:           map[kubetypes.NamespacedName]*lease{},
 			maxConnectionAge: maxConnAge,
+			leaseTTL:         features.WorkloadEntryLeaseTTL,
 			healthCondition:  cache.NewFIFO(keyFunc),
 		}
 	}
@@ -185,10 +222,16 @@ func (c *Controller) Run(stop <-chan struct{}) {
 		return
 	}
 	if c.store != nil && c.cleanupQueue != nil {
-		go c.periodicWorkloadEntryCleanup(stop)
+		if !c.electedCleanup {
+			go c.periodicWorkloadEntryCleanup(stop)
+		}
 		go c.cleanupQueue.Run(stop)
 	}
 
+	if c.store != nil && c.leaseTTL > 0 {
+		go c.renewLeases(stop)
+	}
+
 	for i := 0; i < workerNum; i++ {
 		go wait.Until(c.worker, time.Second, stop)
 	}
@@ -248,10 +291,34 @@ func (c *Controller) processNextWorkItem() bool {
 	return true
 }
 
-func setConnectMeta(c *config.Config, controller string, conTime time.Time) {
+func setConnectMeta(c *config.Config, controller string, conTime time.Time, leaseTTL time.Duration) {
 	c.Annotations[WorkloadControllerAnnotation] = controller
 	c.Annotations[ConnectedAtAnnotation] = conTime.Format(timeFormat)
 	delete(c.Annotations, DisconnectedAtAnnotation)
+	if leaseTTL > 0 {
+		c.Annotations[LeaseExpiresAtAnnotation] = time.Now().Add(leaseTTL).Format(timeFormat)
+	} else {
+		delete(c.Annotations, LeaseExpiresAtAnnotation)
+	}
+}
+
+// connectedToOther returns whether another instance still considers the workload of the WorkloadEntry connected to it.
+func (c *Controller) connectedToOther(wle config.Config) bool {
+	controller := wle.Annotations[WorkloadControllerAnnotation]
+	return controller != "" && controller != c.instanceID && wle.Annotations[ConnectedAtAnnotation] != ""
+}
+
+// heldByOther returns whether another instance still considers the workload of the WorkloadEntry connected to it,
+// and holds an unexpired lease on the entry if leases are enabled.
+func (c *Controller) heldByOther(wle config.Config) bool {
+	if !c.connectedToOther(wle) {
+		return false
+	}
+	if c.leaseTTL == 0 {
+		return true
+	}
+	expiry, err := time.Parse(timeFormat, wle.Annotations[LeaseExpiresAtAnnotation])
+	return err == nil && time.Now().Before(expiry)
 }
 
 func (c *Controller) RegisterWorkload(proxy *model.Proxy, conTime time.Time) error {
@@ -282,16 +349,43 @@ func (c *Controller) registerWorkload(entryName string, proxy *model.Proxy, conT
 		lastConTime, _ := time.Parse(timeFormat, wle.Annotations[ConnectedAtAnnotation])
 		// the proxy has reconnected to another pilot, not belong to this one.
 		if conTime.Before(lastConTime) {
+			autoRegistrationContested.With(contestReason.Value("stale")).Increment()
+			c.releaseLease(entryName, proxy.Metadata.Namespace, conTime)
 			return nil
 		}
-		// Try to patch, if it fails then try to create
-		_, err := c.store.Patch(*wle, func(cfg config.Config) (config.Config, kubetypes.PatchType) {
-			setConnectMeta(&cfg, c.instanceID, conTime)
-			return cfg, kubetypes.MergePatchType
-		})
+		if c.leaseTTL > 0 && c.heldByOther(*wle) {
+			// the other instance may not have noticed the disconnection of the workload yet, the entry is taken over
+			// once it releases its lease, or the lease expires
+			if !c.deferLease(entryName, proxy, conTime) {
+				log.Infof("auto-registered WorkloadEntry %s/%s is held by %s, deferring its takeover",
+					proxy.Metadata.Namespace, entryName, wle.Annotations[WorkloadControllerAnnotation])
+				autoRegistrationContested.With(contestReason.Value("held")).Increment()
+			}
+			return nil
+		}
+		if c.connectedToOther(*wle) {
+			log.Infof("taking over auto-registered WorkloadEntry %s/%s from %s", proxy.Metadata.Namespace, entryName,
+				wle.Annotations[WorkloadControllerAnnotation])
+			autoRegistrationContested.With(contestReason.Value("takeover")).Increment()
+		}
+		var err error
+		if c.leaseTTL > 0 {
+			// update instead of patch, so that the takeovers of the entry by concurrent connections of the workload
+			// conflict, and are decided again by the connection times once the workload reconnects
+			updated := wle.DeepCopy()
+			setConnectMeta(&updated, c.instanceID, conTime, c.leaseTTL)
+			_, err = c.store.Update(updated)
+		} else {
+			// Try to patch, if it fails then try to create
+			_, err = c.store.Patch(*wle, func(cfg config.Config) (config.Config, kubetypes.PatchType) {
+				setConnectMeta(&cfg, c.instanceID, conTime, c.leaseTTL)
+				return cfg, kubetypes.MergePatchType
+			})
+		}
 		if err != nil {
 			return fmt.Errorf("failed updating WorkloadEntry %s/%s err: %v", proxy.Metadata.Namespace, entryName, err)
 		}
+		c.acquireLease(entryName, proxy.Metadata.Namespace, conTime)
 		autoRegistrationUpdates.Increment()
 		log.Infof("updated auto-registered WorkloadEntry %s/%s", proxy.Metadata.Namespace, entryName)
 		return nil
@@ -305,12 +399,13 @@ func (c *Controller) registerWorkload(entryName string, proxy *model.Proxy, conT
 			proxy.ID, proxy.Metadata.Namespace, proxy.Metadata.AutoRegisterGroup)
 	}
 	entry := workloadEntryFromGroup(entryName, proxy, groupCfg)
-	setConnectMeta(entry, c.instanceID, conTime)
+	setConnectMeta(entry, c.instanceID, conTime, c.leaseTTL)
 	_, err := c.store.Create(*entry)
 	if err != nil {
 		autoRegistrationErrors.Increment()
 		return fmt.Errorf("auto-registration WorkloadEntry of %v failed: error creating WorkloadEntry: %v", proxy.ID, err)
 	}
+	c.acquireLease(entryName, proxy.Metadata.Namespace, conTime)
 	hcMessage := ""
 	if _, f := entry.Annotations[status.WorkloadEntryHealthCheckAnnotation]; f {
 		hcMessage = " with health checking enabled"
@@ -340,6 +435,7 @@ func (c *Controller) QueueUnregisterWorkload(proxy *model.Proxy, origConnect tim
 	}
 	delete(c.adsConnections, makeProxyKey(proxy))
 	c.mutex.Unlock()
+	c.releaseLease(entryName, proxy.Metadata.Namespace, origConnect)
 
 	disconTime := time.Now()
 	if err := c.unregisterWorkload(entryName, proxy, disconTime, origConnect); err != nil {
@@ -383,6 +479,7 @@ func (c *Controller) unregisterWorkload(entryName string, proxy *model.Proxy, di
 
 	wle := cfg.DeepCopy()
 	delete(wle.Annotations, ConnectedAtAnnotation)
+	delete(wle.Annotations, LeaseExpiresAtAnnotation)
 	wle.Annotations[DisconnectedAtAnnotation] = disconTime.Format(timeFormat)
 	// use update instead of patch to prevent race condition
 	if _, err := c.store.Update(wle); err != nil {
@@ -407,6 +504,106 @@ func (c *Controller) unregisterWorkload(entryName string, proxy *model.Proxy, di
 	return nil
 }
 
+// acquireLease records the lease of this instance on the WorkloadEntry of a workload connected at conTime.
+func (c *Controller) acquireLease(entryName, namespace string, conTime time.Time) {
+	c.setLease(entryName, namespace, &lease{conTime: conTime})
+}
+
+// deferLease records the pending takeover by this instance of the WorkloadEntry held by another instance, for the
+// workload connected at conTime. It returns whether the takeover was already pending.
+func (c *Controller) deferLease(entryName string, proxy *model.Proxy, conTime time.Time) bool {
+	prev := c.setLease(entryName, proxy.Metadata.Namespace, &lease{conTime: conTime, proxy: proxy})
+	return prev != nil && prev.proxy != nil && prev.conTime.Equal(conTime)
+}
+
+// setLease records the lease, unless the workload reconnected since, and returns the lease it replaced.
+func (c *Controller) setLease(entryName, namespace string, l *lease) *lease {
+	if c.leaseTTL == 0 {
+		return nil
+	}
+	key := kubetypes.NamespacedName{Namespace: namespace, Name: entryName}
+	l.renewAt = c.nextRenewal()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cur := c.leases[key]
+	if cur == nil || !l.conTime.Before(cur.conTime) {
+		c.leases[key] = l
+	}
+	return cur
+}
+
+// releaseLease stops renewing the lease on the WorkloadEntry, unless the workload reconnected after conTime.
+func (c *Controller) releaseLease(entryName, namespace string, conTime time.Time) {
+	key := kubetypes.NamespacedName{Namespace: namespace, Name: entryName}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if l, ok := c.leases[key]; ok && !l.conTime.After(conTime) {
+		delete(c.leases, key)
+	}
+}
+
+// nextRenewal returns the time of the next renewal of a lease, between a fourth and a third of the TTL from now, so
+// that the renewals of the leases acquired together are spread over time.
+func (c *Controller) nextRenewal() time.Time {
+	return time.Now().Add(wait.Jitter(c.leaseTTL/4, 1.0/3))
+}
+
+// renewLeases renews the leases of this instance on the WorkloadEntries of the connected workloads once due, and
+// attempts the pending takeovers, until stop is closed.
+func (c *Controller) renewLeases(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.leaseTTL / 12)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			due := map[kubetypes.NamespacedName]lease{}
+			c.mutex.Lock()
+			for key, l := range c.leases {
+				if now.Before(l.renewAt) {
+					continue
+				}
+				l.renewAt = c.nextRenewal()
+				due[key] = *l
+			}
+			c.mutex.Unlock()
+			for key, l := range due {
+				if l.proxy != nil {
+					if err := c.registerWorkload(key.Name, l.proxy, l.conTime); err != nil {
+						log.Warnf("failed taking over WorkloadEntry %s: %v", key, err)
+					}
+					continue
+				}
+				if err := c.renewLease(key, l.conTime); err != nil {
+					log.Warnf("failed renewing the lease on WorkloadEntry %s: %v", key, err)
+				}
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (c *Controller) renewLease(key kubetypes.NamespacedName, conTime time.Time) error {
+	cfg := c.store.Get(gvk.WorkloadEntry, key.Name, key.Namespace)
+	if cfg == nil {
+		c.releaseLease(key.Name, key.Namespace, conTime)
+		return nil
+	}
+	if cfg.Annotations[WorkloadControllerAnnotation] != c.instanceID ||
+		cfg.Annotations[ConnectedAtAnnotation] != conTime.Format(timeFormat) {
+		// the workload reconnected to another instance, which took over the entry
+		log.Infof("auto-registered WorkloadEntry %s was taken over by %s", key, cfg.Annotations[WorkloadControllerAnnotation])
+		autoRegistrationContested.With(contestReason.Value("lost")).Increment()
+		c.releaseLease(key.Name, key.Namespace, conTime)
+		return nil
+	}
+	wle := cfg.DeepCopy()
+	wle.Annotations[LeaseExpiresAtAnnotation] = time.Now().Add(c.leaseTTL).Format(timeFormat)
+	// use update instead of patch, so that the renewal does not override a concurrent takeover
+	_, err := c.store.Update(wle)
+	return err
+}
+
 // QueueWorkloadEntryHealth enqueues the associated WorkloadEntries health status.
 func (c *Controller) QueueWorkloadEntryHealth(proxy *model.Proxy, event HealthEvent) {
 	// we assume that the workload entry exists
@@ -458,6 +655,23 @@ func (c *Controller) updateWorkloadEntryHealth(obj interface{}) error {
 	return nil
 }
 
+// ElectCleanup makes the periodic cleanup of the disconnected WorkloadEntries run only by RunPeriodicCleanup,
+// which is expected to be called by the leader of the auto registration election. It must be called before Run.
+func (c *Controller) ElectCleanup() {
+	if c == nil {
+		return
+	}
+	c.electedCleanup = true
+}
+
+// RunPeriodicCleanup runs the periodic cleanup of the disconnected WorkloadEntries until stop is closed.
+func (c *Controller) RunPeriodicCleanup(stop <-chan struct{}) {
+	if c == nil || c.store == nil || c.cleanupQueue == nil {
+		return
+	}
+	c.periodicWorkloadEntryCleanup(stop)
+}
+
 // periodicWorkloadEntryCleanup checks lists all WorkloadEntry
 func (c *Controller) periodicWorkloadEntryCleanup(stopCh <-chan struct{}) {
 	if !features.WorkloadEntryAutoRegistration {
@@ -500,6 +714,11 @@ func (c *Controller) shouldCleanupEntry(wle config.Config) bool {
 	// 2. connect: but the patch is based on the old workloadentry because of the propagation latency.
 	// So in this case the `DisconnectedAtAnnotation` is still there and the cleanup procedure will go on.
 	connTime := wle.Annotations[ConnectedAtAnnotation]
+	if lease := wle.Annotations[LeaseExpiresAtAnnotation]; connTime != "" && lease != "" && c.leaseTTL > 0 {
+		// the lease is no longer renewed once the instance connected to the workload is gone
+		expiry, err := time.Parse(timeFormat, lease)
+		return err == nil && time.Since(expiry) > features.WorkloadEntryCleanupGracePeriod
+	}
 	if connTime != "" {
 		// handle workload leak when both workload/pilot down at the same time before pilot has a chance to set disconnTime
 		connAt, err := time.Parse(timeFormat, connTime)
@@ -213,6 +213,86 @@ func TestAutoregistrationLifecycle(t *testing.T) {
 	// TODO test garbage collection if pilot stops before disconnect meta is set (relies on heartbeat)
 }
 
+func TestAutoregistrationLease(t *testing.T) {
+	leaseTTL := 300 * time.Millisecond
+	c1, c2, store := setup(t)
+	c1.leaseTTL, c2.leaseTTL = leaseTTL, leaseTTL
+	stop1, stop2 := make(chan struct{}), make(chan struct{})
+	t.Cleanup(func() {
+		close(stop1)
+	})
+	go c1.Run(stop1)
+	go c2.Run(stop2)
+
+	n := fakeNode("reg1", "zone1", "subzone1")
+	p := fakeProxy("1.2.3.4", wgA, "nw1")
+	p.XdsNode = n
+	leaseExpiry := func() time.Time {
+		cfg := store.Get(gvk.WorkloadEntry, p.AutoregisteredWorkloadEntryName, p.Metadata.Namespace)
+		if cfg == nil {
+			t.Fatalf("expected WorkloadEntry to exist")
+		}
+		expiry, err := time.Parse(timeFormat, cfg.Annotations[LeaseExpiresAtAnnotation])
+		if err != nil {
+			t.Fatalf("invalid lease: %v", err)
+		}
+		return expiry
+	}
+	leases := func(c *Controller) int {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		return len(c.leases)
+	}
+
+	origConnTime := time.Now()
+	t.Run("lease renewed while connected", func(t *testing.T) {
+		c1.RegisterWorkload(p, origConnTime)
+		checkEntryOrFail(t, store, wgA, p, n, c1.instanceID)
+		expiry := leaseExpiry()
+		retry.UntilSuccessOrFail(t, func() error {
+			if !leaseExpiry().After(expiry) {
+				return fmt.Errorf("expected the lease to be renewed")
+			}
+			return nil
+		}, retry.Timeout(2*leaseTTL))
+		// the entry is not cleaned up while its lease is renewed
+		time.Sleep(leaseTTL + features.WorkloadEntryCleanupGracePeriod)
+		if c1.shouldCleanupEntry(*store.Get(gvk.WorkloadEntry, p.AutoregisteredWorkloadEntryName, p.Metadata.Namespace)) {
+			t.Fatalf("expected the connected WorkloadEntry not to be cleaned up")
+		}
+	})
+	t.Run("taken over by a newer connection", func(t *testing.T) {
+		// the workload reconnects to c2 before c1 notices the disconnection, c2 waits for the lease of c1
+		c2.RegisterWorkload(p, origConnTime.Add(time.Millisecond))
+		time.Sleep(leaseTTL / 2)
+		checkEntryOrFail(t, store, wgA, p, n, c1.instanceID)
+		// c1 notices the disconnection and releases the lease
+		c1.QueueUnregisterWorkload(p, origConnTime)
+		if leases(c1) != 0 {
+			t.Fatalf("expected c1 to release the lease")
+		}
+		retry.UntilSuccessOrFail(t, func() error {
+			cfg := store.Get(gvk.WorkloadEntry, p.AutoregisteredWorkloadEntryName, p.Metadata.Namespace)
+			if cfg == nil || cfg.Annotations[WorkloadControllerAnnotation] != c2.instanceID {
+				return fmt.Errorf("expected c2 to take over the entry")
+			}
+			return nil
+		}, retry.Timeout(2*leaseTTL))
+		checkEntryOrFail(t, store, wgA, p, n, c2.instanceID)
+		// the older connection leaves the entry to c2
+		c1.RegisterWorkload(p, origConnTime)
+		c1.QueueUnregisterWorkload(p, origConnTime)
+		checkEntryOrFail(t, store, wgA, p, n, c2.instanceID)
+	})
+	t.Run("garbage collected once the lease expires", func(t *testing.T) {
+		// c2 stops without unregistering the workload
+		close(stop2)
+		retry.UntilSuccessOrFail(t, func() error {
+			return checkNoEntry(store, wgA, p)
+		}, retry.Timeout(time.Until(time.Now().Add(21*features.WorkloadEntryCleanupGracePeriod))))
+	})
+}
+
 func TestUpdateHealthCondition(t *testing.T) {
 	stop := make(chan struct{})
 	t.Cleanup(func() {
@@ -0,0 +1,194 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package events emits structured mesh lifecycle events, such as proxies connecting or rejecting
+// configuration, to sinks like Kubernetes Events or a webhook, so they can be consumed without scraping
+// the istiod logs.
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	istiolog "istio.io/pkg/log"
+	"istio.io/pkg/monitoring"
+)
+
+var log = istiolog.RegisterScope("events", "mesh lifecycle events", 0)
+
+// Type is the type of an event.
+type Type string
+
+const (
+	// ProxyConnected is emitted when a proxy opens an xDS stream.
+	ProxyConnected Type = "ProxyConnected"
+	// ProxyDisconnected is emitted when the xDS stream of a proxy is closed.
+	ProxyDisconnected Type = "ProxyDisconnected"
+	// CertificateIssued is emitted when the istiod CA signs a workload certificate.
+	CertificateIssued Type = "CertificateIssued"
+	// ConfigRejected is emitted when a proxy rejects the configuration pushed to it.
+	ConfigRejected Type = "ConfigRejected"
+	// PushFailed is emitted when the configuration could not be pushed to a proxy.
+	PushFailed Type = "PushFailed"
+	// PushSuspended is emitted when the pushes of a type to a proxy are suspended after repeated rejections.
+	PushSuspended Type = "PushSuspended"
+	// ProxyVersionSkew is emitted when a proxy outside of the versions supported by istiod connects.
+	ProxyVersionSkew Type = "ProxyVersionSkew"
+)
+
+// queueSize is the number of events waiting to be sent before new events are dropped.
+const queueSize = 1000
+
+// Object references the Kubernetes object an event is about.
+type Object struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// Event is a mesh lifecycle event.
+type Event struct {
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	// Warning is set for the events reporting a failure.
+	Warning bool `json:"warning,omitempty"`
+	// Object is the object the event is about, if any. Events without an object are not sent to Kubernetes.
+	Object *Object `json:"object,omitempty"`
+	// Proxy is the ID of the proxy the event is about, if any.
+	Proxy   string `json:"proxy,omitempty"`
+	Message string `json:"message"`
+}
+
+// Sink receives the events.
+type Sink interface {
+	// Name identifies the sink in logs and metrics.
+	Name() string
+	Send(e Event) error
+}
+
+// Emitter rate limits the events, then sends them to all sinks in the background. A nil Emitter drops
+// all events, so callers do not need to check whether events are enabled.
+type Emitter struct {
+	sinks []Sink
+	queue chan Event
+
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[Type]*rate.Limiter
+}
+
+// NewEmitter creates an Emitter allowing, for each type, ratePerSecond events with the given burst.
+func NewEmitter(ratePerSecond float64, burst int, sinks ...Sink) *Emitter {
+	return &Emitter{
+		sinks:    sinks,
+		queue:    make(chan Event, queueSize),
+		limit:    rate.Limit(ratePerSecond),
+		burst:    burst,
+		limiters: map[Type]*rate.Limiter{},
+	}
+}
+
+// Emit queues an event, or drops it if its type is over the rate limit or the queue is full. It never blocks.
+func (e *Emitter) Emit(ev Event) {
+	if e == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	if !e.limiter(ev.Type).Allow() {
+		eventsDropped.With(typeTag.Value(string(ev.Type)), reasonTag.Value("rate_limited")).Increment()
+		return
+	}
+	select {
+	case e.queue <- ev:
+	default:
+		eventsDropped.With(typeTag.Value(string(ev.Type)), reasonTag.Value("queue_full")).Increment()
+	}
+}
+
+// Emitf queues an event with a formatted message.
+func (e *Emitter) Emitf(t Type, warning bool, obj *Object, proxy string, format string, args ...interface{}) {
+	if e == nil {
+		return
+	}
+	e.Emit(Event{Type: t, Warning: warning, Object: obj, Proxy: proxy, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e *Emitter) limiter(t Type) *rate.Limiter {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	l, f := e.limiters[t]
+	if !f {
+		l = rate.NewLimiter(e.limit, e.burst)
+		e.limiters[t] = l
+	}
+	return l
+}
+
+// Run sends the queued events to the sinks until stop is closed.
+func (e *Emitter) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case ev := <-e.queue:
+			e.send(ev)
+		}
+	}
+}
+
+func (e *Emitter) send(ev Event) {
+	for _, s := range e.sinks {
+		if err := s.Send(ev); err != nil {
+			eventsSinkErrors.With(sinkTag.Value(s.Name())).Increment()
+			log.Debugf("failed to send %s event to %s: %v", ev.Type, s.Name(), err)
+			continue
+		}
+		eventsSent.With(typeTag.Value(string(ev.Type)), sinkTag.Value(s.Name())).Increment()
+	}
+}
+
+var (
+	typeTag   = monitoring.MustCreateLabel("type")
+	sinkTag   = monitoring.MustCreateLabel("sink")
+	reasonTag = monitoring.MustCreateLabel("reason")
+
+	eventsSent = monitoring.NewSum(
+		"pilot_events_sent_total",
+		"Total number of mesh lifecycle events sent, by type and sink.",
+		monitoring.WithLabels(typeTag, sinkTag),
+	)
+
+	eventsDropped = monitoring.NewSum(
+		"pilot_events_dropped_total",
+		"Total number of mesh lifecycle events dropped by the rate limit or because the queue was full.",
+		monitoring.WithLabels(typeTag, reasonTag),
+	)
+
+	eventsSinkErrors = monitoring.NewSum(
+		"pilot_events_sink_errors_total",
+		"Total number of failures to send mesh lifecycle events, by sink.",
+		monitoring.WithLabels(sinkTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(eventsSent, eventsDropped, eventsSinkErrors)
+}
@@ -0,0 +1,108 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/record"
+)
+
+type failingSink struct{}
+
+func (failingSink) Name() string {
+	return "failing"
+}
+
+func (failingSink) Send(Event) error {
+	return errors.New("unavailable")
+}
+
+func TestEmitterRateLimit(t *testing.T) {
+	var e *Emitter
+	// A nil emitter drops the events.
+	e.Emitf(ProxyConnected, false, nil, "a", "connected")
+
+	e = NewEmitter(0, 2)
+	for i := 0; i < 3; i++ {
+		e.Emitf(ProxyConnected, false, nil, "a", "connected")
+	}
+	e.Emitf(PushFailed, true, nil, "a", "failed")
+	// The limit applies to each type.
+	if got := len(e.queue); got != 3 {
+		t.Fatalf("expected 3 queued events, got %d", got)
+	}
+	if ev := <-e.queue; ev.Time.IsZero() || ev.Message != "connected" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	received := make(chan Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var e Event
+		if err := json.Unmarshal(body, &e); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if e.Type == PushFailed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received <- e
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, time.Second)
+	want := Event{
+		Type:    ConfigRejected,
+		Time:    time.Unix(1650000000, 0).UTC(),
+		Warning: true,
+		Object:  &Object{Kind: "Pod", Namespace: "default", Name: "a"},
+		Proxy:   "a.default",
+		Message: "rejected",
+	}
+	if err := sink.Send(want); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; got.Type != want.Type || !got.Time.Equal(want.Time) || *got.Object != *want.Object || got.Message != want.Message {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if err := sink.Send(Event{Type: PushFailed}); err == nil {
+		t.Fatal("expected an error for an unavailable webhook")
+	}
+}
+
+func TestKubernetesSink(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	e := NewEmitter(100, 100, failingSink{}, &KubernetesSink{recorder: recorder})
+	e.Emitf(CertificateIssued, false, nil, "", "issued")
+	e.Emitf(ConfigRejected, true, &Object{Kind: "Pod", Namespace: "default", Name: "a"}, "a.default", "rejected %s", "CDS")
+	stop := make(chan struct{})
+	defer close(stop)
+	go e.Run(stop)
+
+	// Events without an object are not recorded, and failing sinks do not prevent sending to the others.
+	if got := <-recorder.Events; got != "Warning ConfigRejected rejected CDS" {
+		t.Fatalf("unexpected event %q", got)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// KubernetesSink records the events as Kubernetes Events of their object.
+type KubernetesSink struct {
+	recorder record.EventRecorder
+}
+
+// NewKubernetesSink creates a KubernetesSink writing the Events with the given client.
+func NewKubernetesSink(client kubernetes.Interface) *KubernetesSink {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	return &KubernetesSink{
+		recorder: broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "istiod"}),
+	}
+}
+
+// Name implements Sink.
+func (k *KubernetesSink) Name() string {
+	return "kubernetes"
+}
+
+// Send implements Sink. Events without an object are ignored.
+func (k *KubernetesSink) Send(e Event) error {
+	if e.Object == nil {
+		return nil
+	}
+	eventType := v1.EventTypeNormal
+	if e.Warning {
+		eventType = v1.EventTypeWarning
+	}
+	ref := &v1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       e.Object.Kind,
+		Namespace:  e.Object.Namespace,
+		Name:       e.Object.Name,
+	}
+	k.recorder.Event(ref, eventType, string(e.Type), e.Message)
+	return nil
+}
+
+// WebhookSink posts each event, as JSON, to a URL.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink creates a WebhookSink posting to url, with the given timeout for each event.
+func NewWebhookSink(url string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Name implements Sink.
+func (w *WebhookSink) Name() string {
+	return "webhook"
+}
+
+// Send implements Sink.
+func (w *WebhookSink) Send(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
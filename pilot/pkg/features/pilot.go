@@ -91,6 +91,14 @@ var (
 			" otherwise we'll keep delaying until things settle, up to a max of PILOT_DEBOUNCE_MAX.",
 	).Get()
 
+	ProxyDebounceMin = env.RegisterDurationVar(
+		"PILOT_PROXY_DEBOUNCE_MIN",
+		50*time.Millisecond,
+		"The shortest push debounce a proxy can request with the proxy.istio.io/push-debounce annotation. Shorter "+
+			"debounces are raised to it, since the shortest debounce of the connected proxies sets how often istiod "+
+			"computes pushes.",
+	).Get()
+
 	DebounceMax = env.RegisterDurationVar(
 		"PILOT_DEBOUNCE_MAX",
 		10*time.Second,
@@ -111,6 +119,21 @@ var (
 		"If enabled, Pilot will include unhealthy endpoints in EDS pushes and even if they are sent Envoy does not use them for load balancing.",
 	).Get()
 
+	EnableEDSEndpointDiff = env.RegisterBoolVar(
+		"PILOT_ENABLE_EDS_ENDPOINT_DIFF",
+		true,
+		"If enabled, Pilot diffs the endpoints of a service on every update, and incremental EDS pushes only include the "+
+			"clusters whose endpoints were added, removed or changed, sent as deltas to the proxies using delta xDS.",
+	).Get()
+
+	EnableDrainingEndpoints = env.RegisterBoolVar(
+		"PILOT_ENABLE_DRAINING_ENDPOINTS",
+		false,
+		"If enabled, Pilot will send endpoints of pods that are terminating or failing readiness gates as DRAINING, "+
+			"rather than removing them, so in-flight requests can complete. This can be overridden per service with the "+
+			"networking.istio.io/drainingEndpoints annotation.",
+	).Get()
+
 	// HTTP10 will add "accept_http_10" to http outbound listeners. Can also be set only for specific sidecars via meta.
 	HTTP10 = env.RegisterBoolVar(
 		"PILOT_HTTP10",
@@ -201,6 +224,14 @@ var (
 			"if headless services have a large number of pods.",
 	).Get()
 
+	EnableAdditionalWorkloadIPs = env.RegisterBoolVar(
+		"PILOT_ENABLE_ADDITIONAL_WORKLOAD_IPS",
+		false,
+		"If enabled, the IPs of the secondary interfaces of a pod, as recorded by Multus, and the IPs listed in the "+
+			"networking.istio.io/additionalIPs annotation of a WorkloadEntry are registered as secondary endpoints "+
+			"of the workload. They identify the workload, but are not load balanced to.",
+	).Get()
+
 	EnableRemoteJwks = env.RegisterBoolVar(
 		"PILOT_JWT_ENABLE_REMOTE_JWKS",
 		false,
@@ -413,6 +444,11 @@ var (
 	ValidationWebhookConfigName = env.RegisterStringVar("VALIDATION_WEBHOOK_CONFIG_NAME", "istio-istio-system",
 		"Name of the validatingwebhookconfiguration to patch. Empty will skip using cluster admin to patch.").Get()
 
+	WebhookRequestTimeout = env.RegisterDurationVar("PILOT_WEBHOOK_REQUEST_TIMEOUT", 9*time.Second,
+		"Timeout of the requests served by the HTTPS webhook server, after which they fail with a service unavailable "+
+			"error instead of an API server timeout. It is capped at 10s, the default timeout of the webhooks. "+
+			"Zero disables the timeout.").Get()
+
 	SpiffeBundleEndpoints = env.RegisterStringVar("SPIFFE_BUNDLE_ENDPOINTS", "",
 		"The SPIFFE bundle trust domain to endpoint mappings. Istiod retrieves the root certificate from each SPIFFE "+
 			"bundle endpoint and uses it to verify client certifiates from that trust domain. The endpoint must be "+
@@ -422,6 +458,15 @@ var (
 			"Use || between <trustdomain, endpoint> tuples. Use | as delimiter between trust domain and endpoint in "+
 			"each tuple. For example: foo|https://url/for/foo||bar|https://url/for/bar").Get()
 
+	// TODO: move to API
+	SpireTrustBundlePath = env.RegisterStringVar("PILOT_SPIRE_TRUST_BUNDLE", "",
+		"Path to a PEM file with the SPIRE trust bundle. If set, Istiod trusts client certificates issued by SPIRE "+
+			"when authenticating proxies on the secure XDS port. The file is watched for trust bundle rotations.").Get()
+
+	// TODO: move to API
+	SpireTrustDomain = env.RegisterStringVar("PILOT_SPIRE_TRUST_DOMAIN", "",
+		"The trust domain of the SPIRE trust bundle set with PILOT_SPIRE_TRUST_BUNDLE. Defaults to the mesh trust domain.").Get()
+
 	EnableXDSCaching = env.RegisterBoolVar("PILOT_ENABLE_XDS_CACHE", true,
 		"If true, Pilot will cache XDS responses.").Get()
 
@@ -456,6 +501,28 @@ var (
 		"The timeout to send the XDS configuration to proxies. After this timeout is reached, Pilot will discard that push.",
 	).Get()
 
+	XdsSendRateLimit = env.RegisterIntVar(
+		"PILOT_XDS_SEND_RATE_LIMIT",
+		0,
+		"If set, limits the bytes per second of the XDS responses sent to each proxy, so that large initial pushes to "+
+			"many reconnecting proxies do not saturate the network of Pilot. The wait for the limit is bounded by "+
+			"PILOT_XDS_SEND_TIMEOUT. 0 disables the limit.",
+	).Get()
+
+	XdsSendRateBurst = env.RegisterIntVar(
+		"PILOT_XDS_SEND_RATE_BURST",
+		0,
+		"The bytes which can be sent to a proxy at once when PILOT_XDS_SEND_RATE_LIMIT is set. Defaults to the "+
+			"bytes sent in one second.",
+	).Get()
+
+	XdsGlobalSendRateLimit = env.RegisterIntVar(
+		"PILOT_XDS_GLOBAL_SEND_RATE_LIMIT",
+		0,
+		"If set, limits the bytes per second of the XDS responses sent to all proxies together, in addition to "+
+			"PILOT_XDS_SEND_RATE_LIMIT. The burst is the bytes sent in one second. 0 disables the limit.",
+	).Get()
+
 	RemoteClusterTimeout = env.RegisterDurationVar(
 		"PILOT_REMOTE_CLUSTER_TIMEOUT",
 		30*time.Second,
@@ -482,6 +549,12 @@ var (
 		"The amount of time an auto-registered workload can remain disconnected from all Pilot instances before the "+
 			"associated WorkloadEntry is cleaned up.").Get()
 
+	WorkloadEntryLeaseTTL = env.RegisterDurationVar("PILOT_WORKLOAD_ENTRY_LEASE_TTL", 0,
+		"If set, the istiod instance controlling an auto-registered WorkloadEntry holds a lease on it, renewed about "+
+			"every third of the TTL, with jitter, while the workload is connected. The entries are taken over by another "+
+			"instance only through a newer connection of the workload, once the lease is released or expired, and the "+
+			"connected entries whose lease is not renewed are cleaned up after the grace period.").Get()
+
 	WorkloadEntryHealthChecks = env.RegisterBoolVar("PILOT_ENABLE_WORKLOAD_ENTRY_HEALTHCHECKS", true,
 		"Enables automatic health checks of WorkloadEntries based on the config provided in the associated WorkloadGroup").Get()
 
@@ -502,12 +575,54 @@ var (
 		"If set, the max amount of time to delay a push by. Depends on PILOT_ENABLE_FLOW_CONTROL.",
 	).Get()
 
+	// FlowControlTypeTimeouts overrides FlowControlTimeout for the types it holds, keyed by their short name such as eds.
+	FlowControlTypeTimeouts = parseTypeDurations("PILOT_FLOW_CONTROL_TYPE_TIMEOUTS", env.RegisterStringVar(
+		"PILOT_FLOW_CONTROL_TYPE_TIMEOUTS",
+		"",
+		"A comma separated list of <type>=<duration> overriding PILOT_FLOW_CONTROL_TIMEOUT for the types, named "+
+			"by their short name such as cds, eds or lds. For example `eds=5s,lds=30s`.",
+	).Get())
+
+	DeltaInitialFetchOrdering = env.RegisterBoolVar("PILOT_DELTA_INITIAL_FETCH_ORDERING", false,
+		"If enabled, the first responses of the types to a proxy connected over delta xDS are sent in the "+
+			"cds, eds, lds then rds order: the first response of a type is held until the previous types requested by "+
+			"the proxy are ACKed, or until the deadline of its stage, so that listeners do not reference clusters "+
+			"not sent yet during warm-up.").Get()
+
+	// DeltaInitialFetchStageTimeouts holds the deadline of the stages of the initial fetch ordering, keyed by the
+	// short name of their type such as lds.
+	DeltaInitialFetchStageTimeouts = parseTypeDurations("PILOT_DELTA_INITIAL_FETCH_STAGE_TIMEOUTS", env.RegisterStringVar(
+		"PILOT_DELTA_INITIAL_FETCH_STAGE_TIMEOUTS",
+		"",
+		"A comma separated list of <type>=<duration> overriding the 5s maximum time the first response of eds, lds "+
+			"or rds is held for with PILOT_DELTA_INITIAL_FETCH_ORDERING. For example `lds=10s,rds=2s`.",
+	).Get())
+
+	// PushTypeMinIntervals holds the minimum interval between two pushes of the types it holds to a proxy over
+	// delta xDS, keyed by their short name such as eds.
+	PushTypeMinIntervals = parseTypeDurations("PILOT_PUSH_TYPE_MIN_INTERVALS", env.RegisterStringVar(
+		"PILOT_PUSH_TYPE_MIN_INTERVALS",
+		"",
+		"A comma separated list of <type>=<duration> limiting the rate of the pushes of the types to each proxy "+
+			"connected over delta xDS, named by their short name such as cds, eds or lds. Pushes of a type more "+
+			"frequent than its interval are merged, and delayed until the interval has elapsed since the previous "+
+			"push. For example `lds=5s,cds=1s` leaves eds unlimited.",
+	).Get())
+
 	EnableDestinationRuleInheritance = env.RegisterBoolVar(
 		"PILOT_ENABLE_DESTINATION_RULE_INHERITANCE",
 		false,
 		"If set, workload specific DestinationRules will inherit configurations settings from mesh and namespace level rules",
 	).Get()
 
+	EnablePartialDestinationRuleIndex = env.RegisterBoolVar(
+		"PILOT_ENABLE_PARTIAL_DESTINATION_RULE_INDEX",
+		true,
+		"If enabled, a push for updated DestinationRules only rebuilds the index of the destination rules of their "+
+			"namespaces, rather than of all the destination rules of the mesh. The whole index is still rebuilt if "+
+			"PILOT_ENABLE_DESTINATION_RULE_INHERITANCE is enabled.",
+	).Get()
+
 	WasmRemoteLoadConversion = env.RegisterBoolVar("ISTIO_AGENT_ENABLE_WASM_REMOTE_LOAD_CONVERSION", true,
 		"If enabled, Istio agent will intercept ECDS resource update, downloads Wasm module, "+
 			"and replaces Wasm module remote load with downloaded local module file.").Get()
@@ -518,6 +633,20 @@ var (
 		"The interval for istiod to fetch the jwks_uri for the jwks public key.",
 	).Get()
 
+	EnableJwksPrefetch = env.RegisterBoolVar(
+		"PILOT_ENABLE_JWKS_PREFETCH",
+		false,
+		"If enabled, istiod fetches the JWKS of all the issuers referenced by RequestAuthentication policies "+
+			"at startup, and does not report ready until the fetch has completed.",
+	).Get()
+
+	JwksPrefetchRequired = env.RegisterBoolVar(
+		"PILOT_JWKS_PREFETCH_REQUIRED",
+		false,
+		"If enabled, istiod does not report ready until the JWKS of every issuer referenced by RequestAuthentication "+
+			"policies has been fetched successfully. Only takes effect if PILOT_ENABLE_JWKS_PREFETCH is enabled.",
+	).Get()
+
 	EnableInboundPassthrough = env.RegisterBoolVar(
 		"PILOT_ENABLE_INBOUND_PASSTHROUGH",
 		true,
@@ -598,6 +727,16 @@ var (
 	PrioritizedLeaderElection = env.RegisterBoolVar("PRIORITIZED_LEADER_ELECTION", true,
 		"If enabled, the default revision will steal leader locks from non-default revisions").Get()
 
+	// LeaderElectionLeaseDurations holds the lease duration of the leader elections it holds, keyed by election ID.
+	LeaderElectionLeaseDurations = parseTypeDurations("PILOT_LEADER_ELECTION_LEASE_DURATIONS", env.RegisterStringVar(
+		"PILOT_LEADER_ELECTION_LEASE_DURATIONS",
+		"",
+		"A comma separated list of <election>=<duration> overriding the 30s lease duration of the leader elections, "+
+			"named by their lease such as istio-leader or istio-gateway-deployment-leader. Shorter leases fail over "+
+			"faster to another instance, at the cost of more frequent renewals. "+
+			"For example `istio-autoregistration-leader=10s`.",
+	).Get())
+
 	EnableTLSOnSidecarIngress = env.RegisterBoolVar("ENABLE_TLS_ON_SIDECAR_INGRESS", false,
 		"If enabled, the TLS configuration on Sidecar.ingress will take effect").Get()
 
@@ -613,8 +752,369 @@ var (
 
 	VerifySDSCertificate = env.RegisterBoolVar("VERIFY_SDS_CERTIFICATE", true,
 		"If enabled, certificates fetched from SDS server will be verified before sending back to proxy.").Get()
+
+	MTLSReportPrometheusAddress = env.RegisterStringVar("PILOT_MTLS_REPORT_PROMETHEUS_ADDRESS", "",
+		"If set, the address of the Prometheus server used to read the inbound traffic of workloads for the "+
+			"mTLS compatibility report served at /debug/mtls_compatibility.").Get()
+
+	MTLSReportWindow = env.RegisterDurationVar("PILOT_MTLS_REPORT_WINDOW", time.Hour,
+		"The time window of inbound traffic considered by the mTLS compatibility report.").Get()
+
+	// TLSMinProtocolVersion and TLSCipherSuites are the mesh wide TLS policy. They are validated when istiod starts.
+	// TODO: move to API
+	TLSMinProtocolVersion = env.RegisterStringVar("PILOT_TLS_MIN_PROTOCOL_VERSION", "",
+		"If set, the minimum TLS version enforced on all TLS contexts generated by Istiod, including inbound mTLS, "+
+			"gateway servers and upstream TLS origination. Supported values are `TLSV1_2` and `TLSV1_3`. "+
+			"Can only be raised per workload with the `ISTIO_META_TLS_MIN_PROTOCOL_VERSION` proxy metadata.").Get()
+
+	TLSCipherSuites = func() []string {
+		v := env.RegisterStringVar("PILOT_TLS_CIPHER_SUITES", "",
+			"If set, a comma separated list of the cipher suites allowed on all TLS contexts generated by Istiod. "+
+				"Cipher suites configured on a resource are restricted to this list. "+
+				"Can only be restricted per workload with the `ISTIO_META_TLS_CIPHER_SUITES` proxy metadata.").Get()
+		if v == "" {
+			return nil
+		}
+		return strings.Split(v, ",")
+	}()
+
+	EnableACME = env.RegisterBoolVar("PILOT_ENABLE_ACME", false,
+		"If enabled, istiod provisions and renews through ACME the certificates of the SIMPLE TLS servers of the "+
+			"Gateways annotated with networking.istio.io/acme-challenge, and stores them in the secrets referenced "+
+			"by their credentialName.").Get()
+
+	ACMEDirectoryURL = env.RegisterStringVar("PILOT_ACME_DIRECTORY_URL", "https://acme-v02.api.letsencrypt.org/directory",
+		"The directory URL of the ACME CA issuing the Gateway certificates.").Get()
+
+	ACMEEmail = env.RegisterStringVar("PILOT_ACME_EMAIL", "",
+		"The contact email of the ACME account, used by the ACME CA for expiry and account notices.").Get()
+
+	ACMERenewBefore = env.RegisterDurationVar("PILOT_ACME_RENEW_BEFORE", 30*24*time.Hour,
+		"How long before its expiry a Gateway certificate issued through ACME is renewed.").Get()
+
+	ACMEDNSProvider = env.RegisterStringVar("PILOT_ACME_DNS_PROVIDER", "",
+		"The DNS provider solving the ACME dns-01 challenges, route53 is supported. If empty, dns-01 challenges "+
+			"are not supported.").Get()
+
+	ACMEDNSProviderConfig = env.RegisterStringVar("PILOT_ACME_DNS_PROVIDER_CONFIG", "",
+		"The configuration of the PILOT_ACME_DNS_PROVIDER, as a JSON object of string values. The credentials of "+
+			"the provider are read from the istio-acme-dns-provider secret in the istiod namespace.").Get()
+
+	GatewayMissingCredentialPolicy = env.RegisterStringVar("PILOT_GATEWAY_MISSING_CREDENTIAL_POLICY", "NONE",
+		"The default handling of the Gateway TLS servers whose credentialName secret is missing, overridden by the "+
+			"networking.istio.io/missing-credential-policy annotation of the Gateway. NONE leaves the certificate "+
+			"unavailable, KEEP_LAST_GOOD serves the last certificate read from the secret, DEFAULT_CERTIFICATE serves "+
+			"the PILOT_GATEWAY_DEFAULT_CREDENTIAL certificate and REJECT_SERVER removes the server from the Gateway.").Get()
+
+	GatewayDefaultCredential = env.RegisterStringVar("PILOT_GATEWAY_DEFAULT_CREDENTIAL", "",
+		"The secret, as namespace/name in the config cluster, serving the certificate of the Gateway TLS servers "+
+			"with the DEFAULT_CERTIFICATE missing credential policy.").Get()
+
+	MetricLabelCardinalityLimit = env.RegisterIntVar("PILOT_METRIC_LABEL_CARDINALITY_LIMIT", 100,
+		"The maximum number of distinct values of each label of the istiod metrics. Further values are recorded as "+
+			"\"other\". If 0, the number of values is not limited.").Get()
+
+	MetricLabelMaxLength = env.RegisterIntVar("PILOT_METRIC_LABEL_MAX_LENGTH", 128,
+		"The maximum length of the label values of the istiod metrics, longer values are truncated. "+
+			"If 0, the values are not truncated.").Get()
+
+	EnableXDSPushMetricsProxyLabels = env.RegisterBoolVar("PILOT_XDS_PUSH_METRICS_PROXY_LABELS", false,
+		"If enabled, the pilot_xds_pushes, pilot_proxy_convergence_time and pilot_xds_config_size_bytes metrics "+
+			"are labeled by the revision and Istio version of the proxies, so that pushes to proxies of different "+
+			"revisions can be compared. The values are bounded by PILOT_METRIC_LABEL_CARDINALITY_LIMIT.").Get()
+
+	EnableKubernetesLifecycleEvents = env.RegisterBoolVar("PILOT_ENABLE_KUBERNETES_LIFECYCLE_EVENTS", false,
+		"If enabled, mesh lifecycle events, such as proxies connecting or rejecting configuration, are recorded "+
+			"as Kubernetes Events of the pods and service accounts they are about.").Get()
+
+	LifecycleEventsWebhookURL = env.RegisterStringVar("PILOT_LIFECYCLE_EVENTS_WEBHOOK_URL", "",
+		"If set, mesh lifecycle events are posted as JSON to this URL.").Get()
+
+	LifecycleEventsRateLimit = env.RegisterFloatVar("PILOT_LIFECYCLE_EVENTS_RATE_LIMIT", 10,
+		"The number of mesh lifecycle events of each type sent per second, after a burst of "+
+			"PILOT_LIFECYCLE_EVENTS_BURST events. Further events are dropped.").Get()
+
+	LifecycleEventsBurst = env.RegisterIntVar("PILOT_LIFECYCLE_EVENTS_BURST", 100,
+		"The number of mesh lifecycle events of each type sent at once before PILOT_LIFECYCLE_EVENTS_RATE_LIMIT applies.").Get()
+
+	// CrossNetworkTunnelNetworks holds the networks reached through HTTP CONNECT tunnels. It is parsed by the
+	// NetworkManager.
+	// TODO: move to MeshNetworks API
+	CrossNetworkTunnelNetworks = env.RegisterStringVar("PILOT_CROSS_NETWORK_TUNNEL_NETWORKS", "",
+		"A comma separated list of the networks of MeshNetworks whose cross-network mTLS traffic is tunneled "+
+			"through HTTP CONNECT, for environments where only HTTP proxies cross network boundaries. Proxies of "+
+			"other networks tunnel their traffic to the network gateways of these networks, and the network "+
+			"gateways of these networks terminate the tunnels on port 15009.").Get()
+
+	// NetworkAddressTranslation holds the address translation maps of the networks whose pod IPs are only routable
+	// through a 1:1 NAT. It is parsed by the NetworkManager.
+	// TODO: move to MeshNetworks API
+	NetworkAddressTranslation = env.RegisterStringVar("PILOT_NETWORK_ADDRESS_TRANSLATION", "",
+		"A comma separated list of address translation maps, in the form <network>:<pod CIDR>=<external CIDR>, "+
+			"for networks whose pod IPs are not routable from other networks but are mapped 1:1 to externally "+
+			"routable prefixes. Proxies of other networks reach the endpoints of these networks at their translated "+
+			"address instead of through the network gateways.").Get()
+
+	EnableConfigSnapshotReads = env.RegisterBoolVar("PILOT_ENABLE_CONFIG_SNAPSHOT_READS", false,
+		"If enabled, the push context is initialized from a snapshot of the configs taken at the start of its "+
+			"initialization, rather than racing with concurrent config writes. The Kubernetes configs are read from "+
+			"the informers, and only translated again for the types updated since the previous push. Config stores "+
+			"which cannot take snapshots are read directly. A push is skipped if its snapshot cannot be taken.").Get()
+
+	XDSVersionScheme = env.RegisterStringVar("PILOT_XDS_VERSION_SCHEME", "push",
+		"The scheme of the versions of the xDS responses. If set to 'push', the version is the version of the push "+
+			"which generated the response. If set to 'monotonic', the version is a counter per type, incremented "+
+			"for each response of the type to the connection.").Get()
+
+	XDSIdleTimeout = env.RegisterDurationVar("PILOT_XDS_IDLE_TIMEOUT", 0,
+		"If set, XDS connections whose proxy did not send any request for this duration are closed as stale, such "+
+			"as connections left half-open by NAT devices, according to PILOT_XDS_IDLE_POLICY.").Get()
+
+	XDSIdlePolicy = env.RegisterStringVar("PILOT_XDS_IDLE_POLICY", "unacked",
+		"The policy of the reaper of stale XDS connections enabled by PILOT_XDS_IDLE_TIMEOUT. If set to 'unacked', "+
+			"connections are only closed if a response was not ACKed for the timeout, as proxies send no request "+
+			"without pushes. If set to 'inactive', all the connections without requests for the timeout are closed.").Get()
+
+	XDSDrainDuration = env.RegisterDurationVar("PILOT_XDS_DRAIN_DURATION", 0,
+		"If set, on shutdown istiod closes its XDS connections at random times spread over this duration, before "+
+			"stopping its gRPC servers, so that the proxies do not all reconnect to the remaining replicas at once. "+
+			"It should be lower than the termination grace period of the istiod pods.").Get()
+
+	XDSAffinityHintCapacity = env.RegisterIntVar("PILOT_XDS_AFFINITY_HINT_CAPACITY", 0,
+		"If set, the control plane identifier of the xDS responses carries an affinity hint with the load score of "+
+			"the istiod instance, its number of connected proxies divided by this capacity, and whether the proxies should "+
+			"prefer another instance because the load is above 1. Agents with XDS_AFFINITY_REBALANCE enabled reconnect "+
+			"the share of their connections in excess, to rebalance the proxies across the istiod replicas. "+
+			"0 disables the hint.").Get()
+
+	DeltaNackCircuitThreshold = env.RegisterIntVar("PILOT_DELTA_NACK_CIRCUIT_THRESHOLD", 0,
+		"The number of consecutive NACKs of a type by a proxy connected over delta xDS after which the pushes of "+
+			"the type to the proxy are suspended for PILOT_DELTA_NACK_CIRCUIT_BACKOFF, to avoid push storms against "+
+			"broken proxies. The backoff doubles each time the push attempted after it is rejected again, and the "+
+			"pushes resume on ACK. 0 disables the circuit breaker.").Get()
+
+	DeltaNackCircuitBackoff = env.RegisterDurationVar("PILOT_DELTA_NACK_CIRCUIT_BACKOFF", 30*time.Second,
+		"The initial duration the pushes of a type to a proxy are suspended for, once PILOT_DELTA_NACK_CIRCUIT_THRESHOLD "+
+			"is reached.").Get()
+
+	XDSAuditLog = env.RegisterStringVar("PILOT_XDS_AUDIT_LOG", "",
+		"If set, istiod writes a JSON record of each xDS request and response, over both SotW and delta xDS, "+
+			"with the identity of the proxy, the type URL, nonce, resource counts and generation latency. "+
+			"Either `stdout`, or the path of a file to append the records to.").Get()
+
+	EnableMeshNetworksResources = env.RegisterBoolVar("PILOT_ENABLE_MESH_NETWORKS_RESOURCES", false,
+		"If enabled, istiod merges the mesh networks of the MeshNetworks resources (install.istio.io/v1alpha1) of "+
+			"its namespace into the mesh networks configuration. The resources are reloaded on change, and report "+
+			"validation errors and the resolved gateways of their networks in their status.").Get()
+
+	EnableGatewayVHDS = env.RegisterBoolVar("PILOT_ENABLE_GATEWAY_VHDS", false,
+		"If enabled, the gateways connected over delta xDS fetch the virtual hosts of their routes on demand over "+
+			"VHDS, as requests for their hosts arrive, rather than receiving the entire route tables. It can be "+
+			"enabled for a single gateway connected over delta xDS with the ISTIO_META_ENABLE_VHDS proxy metadata.").Get()
+
+	EnableDeltaOnDemand = env.RegisterBoolVar("PILOT_ENABLE_DELTA_ON_DEMAND", false,
+		"If enabled, istiod tracks the clusters and endpoints subscribed by name over delta xDS, and releases the "+
+			"cached configuration of a service once no proxy watches it anymore. Proxies subscribing to clusters by "+
+			"name rather than by wildcard only receive the clusters they subscribed to, and only the clusters of "+
+			"these services are generated.").Get()
+
+	NetworkDetectors = func() []string {
+		v := env.RegisterStringVar("PILOT_NETWORK_DETECTORS", "",
+			"A comma separated list of the detectors assigning the Kubernetes workloads without a network to one, "+
+				"from the node they run on, in order of precedence: `node-label` reads the topology.istio.io/network "+
+				"label of the node, and `cloud` derives the network from the cloud provider of the node, such as its "+
+				"GCP project or AWS region. The network label of the workload, of the system namespace and the mesh "+
+				"networks configuration take precedence over detection.").Get()
+		if v == "" {
+			return nil
+		}
+		return strings.Split(v, ",")
+	}()
+
+	OutboundTrafficAudit = env.RegisterBoolVar("PILOT_OUTBOUND_TRAFFIC_AUDIT", false,
+		"If enabled, sidecars with the REGISTRY_ONLY outbound traffic policy, selected by a Sidecar annotated with "+
+			"sidecar.istio.io/outboundTrafficAudit: \"true\", forward the traffic to hosts not in the registry to the "+
+			"AuditPassthroughCluster instead of blocking it, so that the standard metrics report it per destination "+
+			"before enforcing REGISTRY_ONLY. The other sidecars keep blocking that traffic. The external dependencies "+
+			"of the workloads are reported at /debug/external_dependencies.").Get()
+
+	OutboundAuditPrometheusAddress = env.RegisterStringVar("PILOT_OUTBOUND_AUDIT_PROMETHEUS_ADDRESS", "",
+		"If set, the address of the Prometheus server used to read the traffic of workloads to destinations outside "+
+			"of the registry, for the external dependencies report served at /debug/external_dependencies.").Get()
+
+	OutboundAuditWindow = env.RegisterDurationVar("PILOT_OUTBOUND_AUDIT_WINDOW", 24*time.Hour,
+		"The time window of outbound traffic considered by the external dependencies report.").Get()
+
+	EnableDeltaConfigSource = env.RegisterBoolVar("PILOT_ENABLE_DELTA_CONFIG_SOURCE", false,
+		"If enabled, the xds:// config sources of the mesh config are consumed over delta xDS, resuming from the "+
+			"versions already received on reconnection and resyncing a collection in full when it diverges from "+
+			"the server, and dialed with the tlsSettings of the config source. If disabled, the config sources are "+
+			"consumed over state of the world xDS.").Get()
+
+	ConfigSourceResyncInterval = env.RegisterDurationVar("PILOT_CONFIG_SOURCE_RESYNC_INTERVAL", 0,
+		"If set, the interval at which the collections of the delta xds:// config sources are resynced in full, "+
+			"detecting the resources which diverged from the server. 0 disables the periodic resync.").Get()
+
+	PushConcurrencyCDS = env.RegisterIntVar("PILOT_PUSH_CONCURRENCY_CDS", 0,
+		"If set, limits the number of concurrent CDS pushes, on top of PILOT_PUSH_THROTTLE. 0 means no limit.").Get()
+
+	PushConcurrencyEDS = env.RegisterIntVar("PILOT_PUSH_CONCURRENCY_EDS", 0,
+		"If set, limits the number of concurrent EDS pushes, on top of PILOT_PUSH_THROTTLE. 0 means no limit. "+
+			"Incremental EDS pushes are then only limited by it, so that endpoint churn cannot starve the full "+
+			"pushes of listeners and routes.").Get()
+
+	PushConcurrencyLDS = env.RegisterIntVar("PILOT_PUSH_CONCURRENCY_LDS", 0,
+		"If set, limits the number of concurrent LDS pushes, on top of PILOT_PUSH_THROTTLE. 0 means no limit.").Get()
+
+	PushConcurrencyRDS = env.RegisterIntVar("PILOT_PUSH_CONCURRENCY_RDS", 0,
+		"If set, limits the number of concurrent RDS pushes, on top of PILOT_PUSH_THROTTLE. 0 means no limit.").Get()
+
+	MinProxyVersion = env.RegisterStringVar("PILOT_MIN_PROXY_VERSION", "",
+		"The oldest minor version of the proxies supported by this istiod, such as 1.11. Older proxies are handled "+
+			"according to PILOT_PROXY_VERSION_SKEW_POLICY. If unset, there is no minimum.").Get()
+
+	MaxProxyVersion = env.RegisterStringVar("PILOT_MAX_PROXY_VERSION", "",
+		"The newest minor version of the proxies supported by this istiod, such as 1.13. Newer proxies are handled "+
+			"according to PILOT_PROXY_VERSION_SKEW_POLICY. If unset, there is no maximum.").Get()
+
+	ProxyVersionSkewPolicy = env.RegisterStringVar("PILOT_PROXY_VERSION_SKEW_POLICY", "warn",
+		"The handling of proxies outside of PILOT_MIN_PROXY_VERSION and PILOT_MAX_PROXY_VERSION. In all modes, the "+
+			"skew is logged, counted by the pilot_xds_proxy_version_skew metric and emitted as a ProxyVersionSkew event. "+
+			"If set to 'degrade', newer proxies are also treated as the maximum version, so that they get no features "+
+			"introduced after it. If set to 'reject', their connections are rejected.").Get()
+
+	FederationUpstream = env.RegisterStringVar("PILOT_FEDERATION_UPSTREAM", "",
+		"If set, the address of the secure discovery port of an upstream istiod, such as "+
+			"istiod.istio-system.svc:15012, whose configs are consumed over delta xDS and merged with the local configs, "+
+			"for hierarchical control planes. The upstream must have a certificate signed by the roots of the mesh. The "+
+			"names of the federated configs, and the gateways virtual services are bound to, are prefixed by "+
+			"PILOT_FEDERATION_PREFIX.").Get()
+
+	FederationPrefix = env.RegisterStringVar("PILOT_FEDERATION_PREFIX", "federated",
+		"The prefix of the names of the configs federated from PILOT_FEDERATION_UPSTREAM, followed by a dash.").Get()
+
+	EnableLoadReporting = env.RegisterBoolVar("PILOT_ENABLE_LOAD_REPORTING", false,
+		"If enabled, istiod serves the Envoy Load Reporting Service (LRS), and exposes the load reported by the "+
+			"proxies with ISTIO_META_ENABLE_LOAD_REPORTING set as metrics.").Get()
+
+	LoadReportingInterval = env.RegisterDurationVar("PILOT_LOAD_REPORTING_INTERVAL", 10*time.Second,
+		"The interval at which the proxies report their load to istiod, and at which the reported load is aggregated.").Get()
+
+	EnableLoadAwareLocalityWeights = env.RegisterBoolVar("PILOT_ENABLE_LOAD_AWARE_LOCALITY_WEIGHTS", false,
+		"If enabled along with PILOT_ENABLE_LOAD_REPORTING, the weights of the localities of a service are scaled by "+
+			"the load reported for them, so that less loaded localities receive more traffic.").Get()
+
+	// NetworkGatewayDNSServers are the DNS servers resolving the hostnames of network gateways.
+	// TODO: move to MeshNetworks API
+	NetworkGatewayDNSServers = func() []string {
+		v := env.RegisterStringVar("PILOT_NETWORK_GATEWAY_DNS_SERVERS", "",
+			"A comma separated list of the DNS servers, such as 10.0.0.10:53, resolving the hostnames of network "+
+				"gateways, instead of the servers of /etc/resolv.conf. The port defaults to 53.").Get()
+		if v == "" {
+			return nil
+		}
+		return strings.Split(v, ",")
+	}()
+
+	NetworkGatewayDNSSearchDomains = func() []string {
+		v := env.RegisterStringVar("PILOT_NETWORK_GATEWAY_DNS_SEARCH_DOMAINS", "",
+			"A comma separated list of the search domains appended, in order, to the hostnames of network gateways "+
+				"which do not resolve as is.").Get()
+		if v == "" {
+			return nil
+		}
+		return strings.Split(v, ",")
+	}()
+
+	NetworkGatewayDNSTimeout = env.RegisterDurationVar("PILOT_NETWORK_GATEWAY_DNS_TIMEOUT", 5*time.Second,
+		"The timeout of each DNS query resolving the hostnames of network gateways.").Get()
+
+	NetworkGatewayDNSKeepLastKnownGood = env.RegisterBoolVar("PILOT_NETWORK_GATEWAY_DNS_KEEP_LAST_KNOWN_GOOD", false,
+		"If enabled, the network gateways keep the last addresses their hostnames resolved to while the DNS servers "+
+			"are unavailable, instead of being dropped until the hostnames resolve again.").Get()
+
+	// NetworkGatewayHealthCheck is the protocol of the probes of the network gateways.
+	// TODO: move to MeshNetworks API
+	NetworkGatewayHealthCheck = env.RegisterStringVar("PILOT_NETWORK_GATEWAY_HEALTH_CHECK", "",
+		"If set to tcp or https, istiod actively probes the addresses of the network gateways, and excludes the "+
+			"unhealthy ones from the endpoints of the remote networks, unless all the gateways of a network are "+
+			"unhealthy. The tcp probes open a connection, the https probes expect a 2xx response.").Get()
+
+	NetworkGatewayHealthCheckPort = env.RegisterIntVar("PILOT_NETWORK_GATEWAY_HEALTH_CHECK_PORT", 0,
+		"The port probed on the addresses of the network gateways. Defaults to the port of the gateways.").Get()
+
+	NetworkGatewayHealthCheckPath = env.RegisterStringVar("PILOT_NETWORK_GATEWAY_HEALTH_CHECK_PATH", "/",
+		"The path requested by the https probes of the network gateways.").Get()
+
+	NetworkGatewayHealthCheckInterval = env.RegisterDurationVar("PILOT_NETWORK_GATEWAY_HEALTH_CHECK_INTERVAL",
+		10*time.Second, "The interval between the probes of each network gateway.").Get()
+
+	NetworkGatewayHealthCheckTimeout = env.RegisterDurationVar("PILOT_NETWORK_GATEWAY_HEALTH_CHECK_TIMEOUT",
+		3*time.Second, "The timeout of each probe of the network gateways.").Get()
+
+	NetworkGatewayHealthCheckThreshold = env.RegisterIntVar("PILOT_NETWORK_GATEWAY_HEALTH_CHECK_THRESHOLD", 3,
+		"The number of consecutive failed, or successful, probes after which a network gateway becomes unhealthy, "+
+			"or healthy again.").Get()
+
+	// NetworkGatewayWeights are the weights of the network gateways, by address. They are parsed by the
+	// NetworkManager.
+	// TODO: move to MeshNetworks API
+	NetworkGatewayWeights = env.RegisterStringVar("PILOT_NETWORK_GATEWAY_WEIGHTS", "",
+		"A comma separated list of the weights of network gateways, by address or address:port, such as "+
+			"35.1.1.1=10,gw.example.com:15443=1. The cross-network traffic is spread among the gateways of a network in "+
+			"proportion to their weights, 1 by default, and a weight of 0 drains a gateway. The networking.istio.io/gatewayWeight annotation of the "+
+			"Service of a gateway takes precedence.").Get()
+
+	// ConnectionLimits are the mesh wide connection limits of the proxies. They are validated when istiod starts.
+	// TODO: move to API
+	ConnectionLimits = env.RegisterStringVar("PILOT_CONNECTION_LIMITS", "",
+		"The mesh wide limits of the request headers, connection buffers and HTTP/2 settings of the listeners and "+
+			"clusters of the proxies, as JSON, such as {\"maxRequestHeadersKb\": 96, \"perConnectionBufferLimitBytes\": "+
+			"1048576, \"http2MaxConcurrentStreams\": 1000, \"http2InitialStreamWindowSize\": 65536, "+
+			"\"http2InitialConnectionWindowSize\": 1048576}. Workloads override them field by field with the "+
+			"ISTIO_META_CONNECTION_LIMITS proxy metadata.").Get()
+
+	RootCertPropagationRules = env.RegisterStringVar("PILOT_ROOT_CERT_PROPAGATION_RULES", "",
+		"A JSON list of rules controlling which namespaces receive the istio-ca-root-cert ConfigMap. Each rule may "+
+			"select namespaces with a namespaceSelector and a revision, and either skip them or append the PEM "+
+			"certificates of trustAnchors to the root cert, such as for tenant specific trust bundles. The first "+
+			"matching rule applies, and namespaces matching no rule receive the root cert only. "+
+			"For example `[{\"namespaceSelector\":{\"matchLabels\":{\"tenant\":\"none\"}},\"skip\":true}]`.").Get()
+
+	NodeMetadataValidation = env.RegisterStringVar("PILOT_NODE_METADATA_VALIDATION", "warn",
+		"The validation of proxy node metadata against the schema at connection time. If set to 'warn', unknown "+
+			"keys, likely typos of known keys and invalid values are logged. If set to 'reject', connections from agents "+
+			"reporting a schema version are rejected on likely typos and invalid values, while older agents are only "+
+			"warned. If set to 'none', the metadata is not validated.").Get()
+
+	PushContextSizeInterval = env.RegisterDurationVar("PILOT_PUSH_CONTEXT_SIZE_INTERVAL", 5*time.Minute,
+		"The interval at which the memory retained by the indexes of the current push context is estimated and "+
+			"recorded in the pilot_push_context_size_bytes metric. 0 disables the metric.").Get()
 )
 
+// parseTypeDurations parses a comma separated list of <type>=<duration>. Invalid entries are ignored.
+// Types are lower-cased.
+func parseTypeDurations(name, v string) map[string]time.Duration {
+	if v == "" {
+		return nil
+	}
+	out := map[string]time.Duration{}
+	for _, entry := range strings.Split(v, ",") {
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			log.Warnf("ignoring invalid %s entry %q: expected <name>=<duration>", name, entry)
+			continue
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(kv[1]))
+		if err != nil || d < 0 {
+			log.Warnf("ignoring invalid %s entry %q: invalid duration", name, entry)
+			continue
+		}
+		out[strings.ToLower(strings.TrimSpace(kv[0]))] = d
+	}
+	return out
+}
+
 // EnableEndpointSliceController returns the value of the feature flag and whether it was actually specified.
 func EnableEndpointSliceController() (value bool, ok bool) {
 	return enableEndpointSliceController, endpointSliceControllerSpecified
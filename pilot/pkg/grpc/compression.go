@@ -0,0 +1,151 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+
+	"istio.io/istio/pilot/pkg/features"
+)
+
+const (
+	// CompressionGzip is the name of the gzip compression of xDS payloads.
+	CompressionGzip = gzip.Name
+	// CompressionZstd is the name of the zstd compression of xDS payloads.
+	CompressionZstd = "zstd"
+	// CompressionHeader is the header Istiod advertises the compressions of xDS payloads it supports with, in the
+	// headers of its xDS streams. Agents only compress their requests with an advertised compression.
+	CompressionHeader = "istio-xds-compression"
+)
+
+// SupportedCompressions are the compressions of xDS payloads supported by Istiod.
+var SupportedCompressions = []string{CompressionGzip, CompressionZstd}
+
+func init() {
+	// Registering the compressors is enough for the servers: they respond with the compression used by the
+	// requests of the client, so that a proxy opts in by compressing its own requests.
+	encoding.RegisterCompressor(zstdCompressor{})
+}
+
+// ValidateCompression checks that the compression of xDS payloads requested by a proxy is supported.
+func ValidateCompression(name string) error {
+	switch name {
+	case "", CompressionGzip, CompressionZstd:
+		return nil
+	}
+	return fmt.Errorf("unsupported xDS compression %q, must be one of %q or %q", name, CompressionGzip, CompressionZstd)
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdErr     error
+
+	// zstdMaxSize is the maximum size of a decompressed message. It defaults to the receive limit of Istiod.
+	zstdMaxSize = atomic.NewUint64(uint64(features.MaxRecvMsgSize))
+	// zstdDecoders pools the decoders, which are expensive to create.
+	zstdDecoders sync.Pool
+)
+
+// SetMaxDecompressedSize sets the maximum size of a zstd decompressed message. It should match the receive limit of
+// the gRPC connections the process receives compressed messages on.
+func SetMaxDecompressedSize(size int) {
+	zstdMaxSize.Store(uint64(size))
+}
+
+// zstdEncoderOnce returns the shared zstd encoder, which is safe for concurrent use when compressing whole messages.
+func zstdEncoderOnce() (*zstd.Encoder, error) {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+	})
+	return zstdEncoder, zstdErr
+}
+
+// zstdCompressor is a gRPC compressor for zstd. Messages are compressed whole, and decompressed as a stream so that
+// gRPC stops reading a message once it exceeds the receive limit.
+type zstdCompressor struct{}
+
+var _ encoding.Compressor = zstdCompressor{}
+
+func (zstdCompressor) Name() string {
+	return CompressionZstd
+}
+
+func (zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	encoder, err := zstdEncoderOnce()
+	if err != nil {
+		return nil, err
+	}
+	return &zstdWriter{encoder: encoder, w: w}, nil
+}
+
+func (zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	maxSize := zstdMaxSize.Load()
+	d, _ := zstdDecoders.Get().(*zstdDecoder)
+	if d == nil || d.maxSize != maxSize {
+		decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxSize))
+		if err != nil {
+			return nil, err
+		}
+		d = &zstdDecoder{Decoder: decoder, maxSize: maxSize}
+		// A decoder runs a goroutine until closed, so it is closed once dropped from the pool, or left unread.
+		runtime.SetFinalizer(d, func(d *zstdDecoder) {
+			d.Close()
+		})
+	}
+	if err := d.Reset(r); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// zstdDecoder is a pooled decoder, returned to the pool once the message is read.
+type zstdDecoder struct {
+	*zstd.Decoder
+	maxSize uint64
+}
+
+func (d *zstdDecoder) Read(p []byte) (int, error) {
+	n, err := d.Decoder.Read(p)
+	if err == io.EOF {
+		zstdDecoders.Put(d)
+	}
+	return n, err
+}
+
+// zstdWriter buffers a message, and writes it compressed when closed.
+type zstdWriter struct {
+	encoder *zstd.Encoder
+	w       io.Writer
+	buf     bytes.Buffer
+}
+
+func (z *zstdWriter) Write(p []byte) (int, error) {
+	return z.buf.Write(p)
+}
+
+func (z *zstdWriter) Close() error {
+	_, err := z.w.Write(z.encoder.EncodeAll(z.buf.Bytes(), nil))
+	return err
+}
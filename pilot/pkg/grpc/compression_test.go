@@ -0,0 +1,99 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc/encoding"
+
+	"istio.io/istio/pilot/pkg/features"
+)
+
+func TestCompressors(t *testing.T) {
+	msg := bytes.Repeat([]byte("outbound|80||httpbin.default.svc.cluster.local"), 100)
+	for _, name := range []string{CompressionGzip, CompressionZstd} {
+		t.Run(name, func(t *testing.T) {
+			c := encoding.GetCompressor(name)
+			if c == nil {
+				t.Fatalf("compressor %s is not registered", name)
+			}
+			var compressed bytes.Buffer
+			w, err := c.Compress(&compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(msg); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if compressed.Len() >= len(msg) {
+				t.Fatalf("expected compression, got %d bytes from %d", compressed.Len(), len(msg))
+			}
+			r, err := c.Decompress(&compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, msg) {
+				t.Fatalf("got %q after decompression, want %q", got, msg)
+			}
+		})
+	}
+}
+
+func TestZstdDecompressionLimit(t *testing.T) {
+	SetMaxDecompressedSize(1024)
+	t.Cleanup(func() {
+		SetMaxDecompressedSize(features.MaxRecvMsgSize)
+	})
+	c := encoding.GetCompressor(CompressionZstd)
+	var compressed bytes.Buffer
+	w, err := c.Compress(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(make([]byte, 10*1024*1024)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.Decompress(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(r); err == nil {
+		t.Fatalf("expected a message over the limit to be rejected, got %d bytes", len(got))
+	}
+}
+
+func TestValidateCompression(t *testing.T) {
+	for _, name := range []string{"", CompressionGzip, CompressionZstd} {
+		if err := ValidateCompression(name); err != nil {
+			t.Errorf("expected %q to be valid: %v", name, err)
+		}
+	}
+	if err := ValidateCompression("brotli"); err == nil {
+		t.Errorf("expected brotli to be rejected")
+	}
+}
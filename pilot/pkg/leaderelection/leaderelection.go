@@ -49,6 +49,10 @@ const (
 	GatewayDeploymentController = "istio-gateway-deployment-leader"
 	StatusController            = "istio-status-leader"
 	AnalyzeController           = "istio-analyze-leader"
+	// ACMEController provisions and renews the Gateway certificates through ACME.
+	ACMEController = "istio-acme-leader"
+	// AutoRegistrationController cleans up the auto registered WorkloadEntries whose proxies did not reconnect.
+	AutoRegistrationController = "istio-autoregistration-leader"
 )
 
 type LeaderElection struct {
@@ -70,7 +74,9 @@ type LeaderElection struct {
 
 	// Store as field for testing
 	le *k8sleaderelection.LeaderElector
-	mu sync.RWMutex
+	// leaderSince is the time the lease was last acquired.
+	leaderSince time.Time
+	mu          sync.RWMutex
 }
 
 // Run will start leader election, calling all runFns when we become the leader.
@@ -78,6 +84,8 @@ func (l *LeaderElection) Run(stop <-chan struct{}) {
 	if l.prioritized && l.defaultWatcher != nil {
 		go l.defaultWatcher.Run(stop)
 	}
+	register(l)
+	defer unregister(l)
 	for {
 		le, err := l.create()
 		if err != nil {
@@ -111,12 +119,17 @@ func (l *LeaderElection) create() (*k8sleaderelection.LeaderElector, error) {
 	callbacks := k8sleaderelection.LeaderCallbacks{
 		OnStartedLeading: func(ctx context.Context) {
 			log.Infof("leader election lock obtained: %v", l.electionID)
+			l.mu.Lock()
+			l.leaderSince = time.Now()
+			l.mu.Unlock()
+			recordLeading(l.electionID, true)
 			for _, f := range l.runFns {
 				go f(ctx.Done())
 			}
 		},
 		OnStoppedLeading: func() {
 			log.Infof("leader election lock lost: %v", l.electionID)
+			recordLeading(l.electionID, false)
 		},
 	}
 	lock := k8sresourcelock.ConfigMapLock{
@@ -177,12 +190,20 @@ func NewLeaderElection(namespace, name, electionID, revision string, client kube
 		prioritized:    features.PrioritizedLeaderElection,
 		defaultWatcher: watcher,
 		// Default to a 30s ttl. Overridable for tests
-		ttl:   time.Second * 30,
+		ttl:   leaseDuration(electionID),
 		cycle: atomic.NewInt32(0),
 		mu:    sync.RWMutex{},
 	}
 }
 
+// leaseDuration returns the lease duration of the election, overridden by PILOT_LEADER_ELECTION_LEASE_DURATIONS.
+func leaseDuration(electionID string) time.Duration {
+	if d, f := features.LeaderElectionLeaseDurations[electionID]; f && d > 0 {
+		return d
+	}
+	return time.Second * 30
+}
+
 func (l *LeaderElection) isLeader() bool {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
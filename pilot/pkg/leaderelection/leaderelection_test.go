@@ -127,6 +127,42 @@ func TestPrioritizedLeaderElection(t *testing.T) {
 	close(stop7)
 }
 
+func TestElections(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	watcher := &fakeDefaultWatcher{}
+	_, stop := createElection(t, "pod1", "", watcher, true, true, client)
+	_, stop2 := createElection(t, "pod2", "", watcher, true, false, client)
+
+	retry.UntilSuccessOrFail(t, func() error {
+		statuses := map[string]ElectionStatus{}
+		for _, s := range Elections() {
+			statuses[s.Identity] = s
+		}
+		if s := statuses["pod1"]; !s.Leader || s.CurrentLeader != "pod1" || s.LeaderSince.IsZero() || s.ElectionID != testLock {
+			return fmt.Errorf("unexpected status of the leader: %+v", s)
+		}
+		if s := statuses["pod2"]; s.Leader || s.CurrentLeader != "pod1" {
+			return fmt.Errorf("unexpected status of the follower: %+v", s)
+		}
+		return nil
+	}, retry.Timeout(time.Second*10))
+
+	close(stop2)
+	close(stop)
+	retry.UntilSuccessOrFail(t, func() error {
+		if n := len(Elections()); n != 0 {
+			return fmt.Errorf("expected stopped elections to be removed, got %d", n)
+		}
+		return nil
+	})
+}
+
+func TestLeaseDuration(t *testing.T) {
+	if d := leaseDuration(IngressController); d != 30*time.Second {
+		t.Fatalf("expected default lease duration, got %v", d)
+	}
+}
+
 func TestLeaderElectionConfigMapRemoved(t *testing.T) {
 	client := fake.NewSimpleClientset()
 	watcher := &fakeDefaultWatcher{}
@@ -0,0 +1,49 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leaderelection
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	electionTag = monitoring.MustCreateLabel("election")
+	eventTag    = monitoring.MustCreateLabel("event")
+
+	leaderStatus = monitoring.NewGauge(
+		"pilot_leader_election_leader",
+		"Whether this instance holds the lease of the election: 1 if it is the leader, 0 otherwise.",
+		monitoring.WithLabels(electionTag),
+	)
+
+	leaderTransitions = monitoring.NewSum(
+		"pilot_leader_election_transitions_total",
+		"Total number of leases acquired and lost by this instance, by election.",
+		monitoring.WithLabels(electionTag, eventTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(leaderStatus, leaderTransitions)
+}
+
+func recordLeading(electionID string, leading bool) {
+	event, value := "lost", 0.0
+	if leading {
+		event, value = "acquired", 1.0
+	}
+	leaderStatus.With(electionTag.Value(electionID)).Record(value)
+	leaderTransitions.With(electionTag.Value(electionID), eventTag.Value(event)).Increment()
+}
@@ -0,0 +1,93 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leaderelection
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ElectionStatus is the state of a running leader election, as exported by /debug/leaderz.
+type ElectionStatus struct {
+	ElectionID string `json:"electionId"`
+	Namespace  string `json:"namespace"`
+	// Identity is the identity of this instance in the election.
+	Identity string `json:"identity"`
+	Revision string `json:"revision,omitempty"`
+	// Leader is true if this instance holds the lease.
+	Leader bool `json:"leader"`
+	// CurrentLeader is the identity of the last observed holder of the lease.
+	CurrentLeader string        `json:"currentLeader,omitempty"`
+	LeaderSince   time.Time     `json:"leaderSince,omitempty"`
+	LeaseDuration time.Duration `json:"leaseDuration"`
+	// Cycle is the number of times the election was (re)started.
+	Cycle int32 `json:"cycle"`
+}
+
+var (
+	electionsMu sync.Mutex
+	elections   = map[*LeaderElection]struct{}{}
+)
+
+func register(l *LeaderElection) {
+	electionsMu.Lock()
+	defer electionsMu.Unlock()
+	elections[l] = struct{}{}
+}
+
+func unregister(l *LeaderElection) {
+	electionsMu.Lock()
+	defer electionsMu.Unlock()
+	delete(elections, l)
+}
+
+// Elections returns the state of all the running leader elections, sorted by election ID.
+func Elections() []ElectionStatus {
+	electionsMu.Lock()
+	out := make([]ElectionStatus, 0, len(elections))
+	for l := range elections {
+		out = append(out, l.status())
+	}
+	electionsMu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ElectionID != out[j].ElectionID {
+			return out[i].ElectionID < out[j].ElectionID
+		}
+		return out[i].Namespace < out[j].Namespace
+	})
+	return out
+}
+
+func (l *LeaderElection) status() ElectionStatus {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s := ElectionStatus{
+		ElectionID:    l.electionID,
+		Namespace:     l.namespace,
+		Identity:      l.name,
+		Revision:      l.revision,
+		LeaseDuration: l.ttl,
+		Cycle:         l.cycle.Load(),
+	}
+	if l.le != nil {
+		s.Leader = l.le.IsLeader()
+		s.CurrentLeader = l.le.GetLeader()
+	}
+	if s.Leader {
+		s.LeaderSince = l.leaderSince
+	}
+	return s
+}
@@ -16,6 +16,7 @@ package model
 
 import (
 	authpb "istio.io/api/security/v1beta1"
+	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/schema/collections"
 	istiolog "istio.io/pkg/log"
@@ -23,11 +24,25 @@ import (
 
 var authzLog = istiolog.RegisterScope("authorization", "Istio Authorization Policy", 0)
 
+// EmergencyLockdownLabel marks an AuthorizationPolicy in the root namespace as an emergency lockdown
+// policy, when set to "true". Lockdown policies are enforced ahead of all other authorization policies,
+// and changes to them are pushed without waiting for debouncing.
+const EmergencyLockdownLabel = "security.istio.io/emergency-lockdown"
+
 type AuthorizationPolicy struct {
 	Name        string                      `json:"name"`
 	Namespace   string                      `json:"namespace"`
 	Annotations map[string]string           `json:"annotations"`
 	Spec        *authpb.AuthorizationPolicy `json:"spec"`
+	// Lockdown is true for emergency lockdown policies, see EmergencyLockdownLabel.
+	Lockdown bool `json:"lockdown,omitempty"`
+}
+
+// IsEmergencyLockdownPolicy returns true if the config is an emergency lockdown AuthorizationPolicy.
+// Only policies in the root namespace can be lockdown policies.
+func IsEmergencyLockdownPolicy(cfg config.Config, rootNamespace string) bool {
+	return cfg.GroupVersionKind == collections.IstioSecurityV1Beta1Authorizationpolicies.Resource().GroupVersionKind() &&
+		rootNamespace != "" && cfg.Namespace == rootNamespace && cfg.Labels[EmergencyLockdownLabel] == "true"
 }
 
 // AuthorizationPolicies organizes AuthorizationPolicy by namespace.
@@ -57,6 +72,7 @@ func GetAuthorizationPolicies(env *Environment) (*AuthorizationPolicies, error)
 			Namespace:   config.Namespace,
 			Annotations: config.Annotations,
 			Spec:        config.Spec.(*authpb.AuthorizationPolicy),
+			Lockdown:    IsEmergencyLockdownPolicy(config, policy.RootNamespace),
 		}
 		policy.NamespaceToPolicies[config.Namespace] = append(policy.NamespaceToPolicies[config.Namespace], authzConfig)
 	}
@@ -65,10 +81,13 @@ func GetAuthorizationPolicies(env *Environment) (*AuthorizationPolicies, error)
 }
 
 type AuthorizationPoliciesResult struct {
-	Custom []AuthorizationPolicy
-	Deny   []AuthorizationPolicy
-	Allow  []AuthorizationPolicy
-	Audit  []AuthorizationPolicy
+	// Lockdown holds the emergency lockdown policies, with either the ALLOW or DENY action. They are not
+	// included in any of the other lists.
+	Lockdown []AuthorizationPolicy
+	Custom   []AuthorizationPolicy
+	Deny     []AuthorizationPolicy
+	Allow    []AuthorizationPolicy
+	Audit    []AuthorizationPolicy
 }
 
 // ListAuthorizationPolicies returns authorization policies applied to the workload in the given namespace.
@@ -92,6 +111,16 @@ func (policy *AuthorizationPolicies) ListAuthorizationPolicies(namespace string,
 			spec := config.Spec
 			selector := labels.Instance(spec.GetSelector().GetMatchLabels())
 			if workload.IsSupersetOf(selector) {
+				if config.Lockdown {
+					switch config.Spec.GetAction() {
+					case authpb.AuthorizationPolicy_ALLOW, authpb.AuthorizationPolicy_DENY:
+						ret.Lockdown = append(ret.Lockdown, config)
+					default:
+						log.Errorf("ignored emergency lockdown policy %s.%s with unsupported action: %s",
+							config.Namespace, config.Name, config.Spec.GetAction())
+					}
+					continue
+				}
 				switch config.Spec.GetAction() {
 				case authpb.AuthorizationPolicy_ALLOW:
 					ret.Allow = append(ret.Allow, config)
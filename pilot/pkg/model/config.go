@@ -195,6 +195,13 @@ type ConfigStoreCache interface {
 	HasSynced() bool
 }
 
+// ConfigSnapshotter is implemented by the config stores able to take consistent snapshots of their configs.
+type ConfigSnapshotter interface {
+	// Snapshot returns a read-only store of the configs at a point in time, and the version of the snapshot.
+	// It returns a nil store if the store does not support snapshots.
+	Snapshot() (ConfigStore, string, error)
+}
+
 // IstioConfigStore is a specialized interface to access config store using
 // Istio configuration types
 type IstioConfigStore interface {
@@ -342,6 +349,14 @@ func MakeIstioStore(store ConfigStore) IstioConfigStore {
 	return &istioConfigStore{store}
 }
 
+// Snapshot returns a snapshot of the wrapped store, if it can take snapshots.
+func (store *istioConfigStore) Snapshot() (ConfigStore, string, error) {
+	if s, ok := store.ConfigStore.(ConfigSnapshotter); ok {
+		return s.Snapshot()
+	}
+	return nil, "", nil
+}
+
 func (store *istioConfigStore) ServiceEntries() []config.Config {
 	serviceEntries, err := store.List(gvk.ServiceEntry, NamespaceAll)
 	if err != nil {
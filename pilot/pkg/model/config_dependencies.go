@@ -0,0 +1,109 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	networking "istio.io/api/networking/v1alpha3"
+	typev1beta1 "istio.io/api/type/v1beta1"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+// matchLabelsSelector is implemented by the specs selecting workloads with a type.v1beta1.WorkloadSelector, such as
+// AuthorizationPolicy or Telemetry.
+type matchLabelsSelector interface {
+	GetSelector() *typev1beta1.WorkloadSelector
+}
+
+// networkingSelector is implemented by the networking specs selecting workloads, such as Sidecar or EnvoyFilter.
+type networkingSelector interface {
+	GetWorkloadSelector() *networking.WorkloadSelector
+}
+
+// ProxyDependsOnConfig returns true if the configuration of the proxy, as computed by its SidecarScope or its
+// merged gateway, depends on the config: the proxies for which it returns false are not affected by changes of
+// the config.
+func ProxyDependsOnConfig(proxy *Proxy, cfg config.Config) bool {
+	key := ConfigKey{Kind: cfg.GroupVersionKind, Name: cfg.Name, Namespace: cfg.Namespace}
+	switch cfg.GroupVersionKind {
+	case gvk.Gateway:
+		return proxy.Type == Router && proxy.MergedGateway.hasGateway(cfg.Namespace+"/"+cfg.Name)
+	case gvk.Sidecar:
+		return proxy.Type == SidecarProxy && proxy.SidecarScope.DependsOnConfig(key)
+	case gvk.ServiceEntry:
+		// Services are keyed by hostname, and the workload selector only selects the endpoints of the services.
+		se, ok := cfg.Spec.(*networking.ServiceEntry)
+		if !ok {
+			return false
+		}
+		for _, h := range se.Hosts {
+			if proxy.SidecarScope.DependsOnConfig(ConfigKey{Kind: gvk.ServiceEntry, Name: h, Namespace: cfg.Namespace}) {
+				return true
+			}
+			for _, si := range proxy.ServiceInstances {
+				if string(si.Service.Hostname) == h && si.Service.Attributes.Namespace == cfg.Namespace {
+					return true
+				}
+			}
+		}
+		return false
+	case gvk.VirtualService:
+		if proxy.Type == Router {
+			vs, ok := cfg.Spec.(*networking.VirtualService)
+			if !ok {
+				return false
+			}
+			for _, gw := range vs.Gateways {
+				if proxy.MergedGateway.hasGateway(resolveGatewayName(gw, cfg.Meta)) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	var selector map[string]string
+	switch spec := cfg.Spec.(type) {
+	case matchLabelsSelector:
+		selector = spec.GetSelector().GetMatchLabels()
+	case networkingSelector:
+		selector = spec.GetWorkloadSelector().GetLabels()
+	default:
+		return proxy.SidecarScope.DependsOnConfig(key)
+	}
+	// Configs selecting workloads apply to the workloads of their namespace, or to all workloads in the root
+	// namespace.
+	if proxy.SidecarScope != nil && cfg.Namespace != proxy.SidecarScope.RootNamespace && cfg.Namespace != proxy.ConfigNamespace {
+		return false
+	}
+	if len(selector) > 0 && (proxy.Metadata == nil || !labels.Instance(selector).SubsetOf(proxy.Metadata.Labels)) {
+		return false
+	}
+	return true
+}
+
+// hasGateway returns true if the gateway, in ns/name format, is merged.
+func (g *MergedGateway) hasGateway(name string) bool {
+	if g == nil {
+		return false
+	}
+	for _, gw := range g.GatewayNameForServer {
+		if gw == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	security "istio.io/api/security/v1beta1"
+	typev1beta1 "istio.io/api/type/v1beta1"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+func TestProxyDependsOnConfig(t *testing.T) {
+	sidecar := &Proxy{
+		Type:            SidecarProxy,
+		ConfigNamespace: "default",
+		Metadata:        &NodeMetadata{Labels: map[string]string{"app": "a"}},
+		SidecarScope:    &SidecarScope{Namespace: "default", RootNamespace: "istio-system"},
+	}
+	sidecar.SidecarScope.AddConfigDependencies(ConfigKey{Kind: gvk.ServiceEntry, Name: "a.example.com", Namespace: "default"})
+	gateway := &Proxy{
+		Type:            Router,
+		ConfigNamespace: "istio-system",
+		Metadata:        &NodeMetadata{Labels: map[string]string{"istio": "ingressgateway"}},
+		SidecarScope:    &SidecarScope{Namespace: "istio-system", RootNamespace: "istio-system"},
+		MergedGateway: &MergedGateway{GatewayNameForServer: map[*networking.Server]string{
+			{}: "istio-system/ingress",
+		}},
+	}
+	meta := func(kind config.GroupVersionKind, namespace string) config.Meta {
+		return config.Meta{GroupVersionKind: kind, Name: "name", Namespace: namespace}
+	}
+	policy := func(namespace string, selector map[string]string) config.Config {
+		spec := &security.AuthorizationPolicy{}
+		if selector != nil {
+			spec.Selector = &typev1beta1.WorkloadSelector{MatchLabels: selector}
+		}
+		return config.Config{Meta: meta(gvk.AuthorizationPolicy, namespace), Spec: spec}
+	}
+	serviceEntry := func(namespace string, hosts ...string) config.Config {
+		return config.Config{
+			Meta: meta(gvk.ServiceEntry, namespace),
+			Spec: &networking.ServiceEntry{
+				Hosts:            hosts,
+				WorkloadSelector: &networking.WorkloadSelector{Labels: map[string]string{"app": "b"}},
+			},
+		}
+	}
+	cases := []struct {
+		name  string
+		proxy *Proxy
+		cfg   config.Config
+		want  bool
+	}{
+		{
+			name:  "gateway",
+			proxy: gateway,
+			cfg:   config.Config{Meta: config.Meta{GroupVersionKind: gvk.Gateway, Name: "ingress", Namespace: "istio-system"}},
+			want:  true,
+		},
+		{
+			name:  "gateway of other proxy",
+			proxy: gateway,
+			cfg:   config.Config{Meta: config.Meta{GroupVersionKind: gvk.Gateway, Name: "egress", Namespace: "istio-system"}},
+		},
+		{
+			name:  "gateway for sidecar",
+			proxy: sidecar,
+			cfg:   config.Config{Meta: config.Meta{GroupVersionKind: gvk.Gateway, Name: "ingress", Namespace: "istio-system"}},
+		},
+		{
+			name:  "virtual service bound to short gateway name",
+			proxy: gateway,
+			cfg: config.Config{
+				Meta: meta(gvk.VirtualService, "istio-system"),
+				Spec: &networking.VirtualService{Gateways: []string{"ingress"}},
+			},
+			want: true,
+		},
+		{
+			name:  "virtual service bound to mesh",
+			proxy: gateway,
+			cfg:   config.Config{Meta: meta(gvk.VirtualService, "istio-system"), Spec: &networking.VirtualService{}},
+		},
+		{name: "selected workload", proxy: sidecar, cfg: policy("default", map[string]string{"app": "a"}), want: true},
+		{name: "other workload", proxy: sidecar, cfg: policy("default", map[string]string{"app": "b"})},
+		{name: "namespace wide", proxy: sidecar, cfg: policy("default", nil), want: true},
+		{name: "other namespace", proxy: sidecar, cfg: policy("other", nil)},
+		{name: "root namespace", proxy: sidecar, cfg: policy("istio-system", map[string]string{"app": "a"}), want: true},
+		{
+			name:  "envoy filter for other workload",
+			proxy: sidecar,
+			cfg: config.Config{
+				Meta: meta(gvk.EnvoyFilter, "default"),
+				Spec: &networking.EnvoyFilter{WorkloadSelector: &networking.WorkloadSelector{Labels: map[string]string{"app": "b"}}},
+			},
+		},
+		{name: "imported service entry", proxy: sidecar, cfg: serviceEntry("default", "b.example.com", "a.example.com"), want: true},
+		{name: "service entry not imported", proxy: sidecar, cfg: serviceEntry("default", "b.example.com")},
+		{name: "service entry in other namespace", proxy: sidecar, cfg: serviceEntry("other", "a.example.com")},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProxyDependsOnConfig(tt.proxy, tt.cfg); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pilot/pkg/features"
+)
+
+// Bounds of the HTTP/2 settings and request header size accepted by Envoy.
+const (
+	maxRequestHeadersKbLimit = 8192
+	minHTTP2WindowSize       = 65535
+	maxHTTP2Setting          = math.MaxInt32
+)
+
+// ConnectionLimits are the limits of the request headers, connection buffers and HTTP/2 settings of the listeners and
+// clusters of a proxy. Unset fields use the defaults of Envoy.
+type ConnectionLimits struct {
+	// MaxRequestHeadersKb is the maximum size of the request headers of the HTTP connection managers, in KiB.
+	MaxRequestHeadersKb uint32 `json:"maxRequestHeadersKb,omitempty"`
+	// PerConnectionBufferLimitBytes is the soft limit of the read and write buffers of the connections of the
+	// listeners and clusters.
+	PerConnectionBufferLimitBytes uint32 `json:"perConnectionBufferLimitBytes,omitempty"`
+	// HTTP2MaxConcurrentStreams is the maximum number of concurrent streams of HTTP/2 connections.
+	HTTP2MaxConcurrentStreams uint32 `json:"http2MaxConcurrentStreams,omitempty"`
+	// HTTP2InitialStreamWindowSize is the initial flow control window of the streams of HTTP/2 connections, in bytes.
+	HTTP2InitialStreamWindowSize uint32 `json:"http2InitialStreamWindowSize,omitempty"`
+	// HTTP2InitialConnectionWindowSize is the initial flow control window of HTTP/2 connections, in bytes.
+	HTTP2InitialConnectionWindowSize uint32 `json:"http2InitialConnectionWindowSize,omitempty"`
+}
+
+// ParseConnectionLimits parses and validates connection limits in JSON, such as {"maxRequestHeadersKb": 96}.
+func ParseConnectionLimits(s string) (ConnectionLimits, error) {
+	l := ConnectionLimits{}
+	if strings.TrimSpace(s) == "" {
+		return l, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&l); err != nil {
+		return l, err
+	}
+	return l, l.Validate()
+}
+
+// Validate checks that the limits are within the bounds accepted by Envoy.
+func (l ConnectionLimits) Validate() (errs error) {
+	if l.MaxRequestHeadersKb > maxRequestHeadersKbLimit {
+		errs = multierror.Append(errs, fmt.Errorf("maxRequestHeadersKb %d must not exceed %d", l.MaxRequestHeadersKb, maxRequestHeadersKbLimit))
+	}
+	if l.HTTP2MaxConcurrentStreams > maxHTTP2Setting {
+		errs = multierror.Append(errs, fmt.Errorf("http2MaxConcurrentStreams %d must not exceed %d", l.HTTP2MaxConcurrentStreams, maxHTTP2Setting))
+	}
+	for name, v := range map[string]uint32{
+		"http2InitialStreamWindowSize":     l.HTTP2InitialStreamWindowSize,
+		"http2InitialConnectionWindowSize": l.HTTP2InitialConnectionWindowSize,
+	} {
+		if v != 0 && (v < minHTTP2WindowSize || v > maxHTTP2Setting) {
+			errs = multierror.Append(errs, fmt.Errorf("%s %d must be between %d and %d", name, v, minHTTP2WindowSize, maxHTTP2Setting))
+		}
+	}
+	return errs
+}
+
+// Merge returns the limits overridden by the fields set in the override.
+func (l ConnectionLimits) Merge(override ConnectionLimits) ConnectionLimits {
+	if override.MaxRequestHeadersKb != 0 {
+		l.MaxRequestHeadersKb = override.MaxRequestHeadersKb
+	}
+	if override.PerConnectionBufferLimitBytes != 0 {
+		l.PerConnectionBufferLimitBytes = override.PerConnectionBufferLimitBytes
+	}
+	if override.HTTP2MaxConcurrentStreams != 0 {
+		l.HTTP2MaxConcurrentStreams = override.HTTP2MaxConcurrentStreams
+	}
+	if override.HTTP2InitialStreamWindowSize != 0 {
+		l.HTTP2InitialStreamWindowSize = override.HTTP2InitialStreamWindowSize
+	}
+	if override.HTTP2InitialConnectionWindowSize != 0 {
+		l.HTTP2InitialConnectionWindowSize = override.HTTP2InitialConnectionWindowSize
+	}
+	return l
+}
+
+// HasHTTP2Settings returns whether any of the HTTP/2 settings is set.
+func (l ConnectionLimits) HasHTTP2Settings() bool {
+	return l.HTTP2MaxConcurrentStreams != 0 || l.HTTP2InitialStreamWindowSize != 0 || l.HTTP2InitialConnectionWindowSize != 0
+}
+
+// Key returns a string uniquely identifying the limits, for cache keys.
+func (l ConnectionLimits) Key() string {
+	return fmt.Sprintf("%d~%d~%d~%d~%d", l.MaxRequestHeadersKb, l.PerConnectionBufferLimitBytes,
+		l.HTTP2MaxConcurrentStreams, l.HTTP2InitialStreamWindowSize, l.HTTP2InitialConnectionWindowSize)
+}
+
+// parsedMeshConnectionLimits caches the parsed PILOT_CONNECTION_LIMITS, keyed by its raw value.
+var parsedMeshConnectionLimits atomic.Value
+
+type meshConnectionLimitsEntry struct {
+	raw    string
+	limits ConnectionLimits
+}
+
+// meshConnectionLimits returns the mesh wide connection limits of PILOT_CONNECTION_LIMITS. The value is validated
+// when istiod starts, so invalid limits are only ignored here.
+func meshConnectionLimits() ConnectionLimits {
+	raw := features.ConnectionLimits
+	if e, ok := parsedMeshConnectionLimits.Load().(meshConnectionLimitsEntry); ok && e.raw == raw {
+		return e.limits
+	}
+	limits, err := ParseConnectionLimits(raw)
+	if err != nil {
+		log.Errorf("ignoring invalid PILOT_CONNECTION_LIMITS: %v", err)
+		limits = ConnectionLimits{}
+	}
+	parsedMeshConnectionLimits.Store(meshConnectionLimitsEntry{raw: raw, limits: limits})
+	return limits
+}
+
+// ConnectionLimitsForProxy returns the mesh wide connection limits of PILOT_CONNECTION_LIMITS, overridden by the ones
+// of the metadata of the proxy. Invalid limits of the proxy are ignored.
+func ConnectionLimitsForProxy(node *Proxy) ConnectionLimits {
+	limits := meshConnectionLimits()
+	if node == nil || node.Metadata == nil || node.Metadata.ConnectionLimits == "" {
+		return limits
+	}
+	override, err := ParseConnectionLimits(node.Metadata.ConnectionLimits)
+	if err != nil {
+		log.Warnf("invalid connection limits of proxy %s: %v", node.ID, err)
+		return limits
+	}
+	return limits.Merge(override)
+}
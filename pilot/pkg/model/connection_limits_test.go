@@ -0,0 +1,78 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+)
+
+func TestParseConnectionLimits(t *testing.T) {
+	cases := []struct {
+		name  string
+		in    string
+		want  ConnectionLimits
+		valid bool
+	}{
+		{name: "empty", in: "", valid: true},
+		{
+			name:  "all",
+			in:    `{"maxRequestHeadersKb": 96, "perConnectionBufferLimitBytes": 32768, "http2MaxConcurrentStreams": 100, "http2InitialStreamWindowSize": 65536, "http2InitialConnectionWindowSize": 1048576}`,
+			want:  ConnectionLimits{96, 32768, 100, 65536, 1048576},
+			valid: true,
+		},
+		{name: "unknown field", in: `{"maxHeaders": 96}`},
+		{name: "headers too large", in: `{"maxRequestHeadersKb": 9000}`},
+		{name: "window too small", in: `{"http2InitialStreamWindowSize": 1024}`},
+		{name: "streams too large", in: `{"http2MaxConcurrentStreams": 4294967295}`},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConnectionLimits(tt.in)
+			if (err == nil) != tt.valid {
+				t.Fatalf("expected valid=%v, got %v", tt.valid, err)
+			}
+			if tt.valid && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConnectionLimitsForProxy(t *testing.T) {
+	defer func(v string) { features.ConnectionLimits = v }(features.ConnectionLimits)
+	features.ConnectionLimits = `{"maxRequestHeadersKb": 64, "http2MaxConcurrentStreams": 100}`
+	meshLimits := ConnectionLimits{MaxRequestHeadersKb: 64, HTTP2MaxConcurrentStreams: 100}
+	proxy := func(limits string) *Proxy {
+		return &Proxy{Metadata: &NodeMetadata{ConnectionLimits: limits}}
+	}
+
+	if got := ConnectionLimitsForProxy(&Proxy{Metadata: &NodeMetadata{}}); !reflect.DeepEqual(got, meshLimits) {
+		t.Errorf("expected mesh limits, got %+v", got)
+	}
+	want := ConnectionLimits{MaxRequestHeadersKb: 96, HTTP2MaxConcurrentStreams: 100, PerConnectionBufferLimitBytes: 1024}
+	if got := ConnectionLimitsForProxy(proxy(`{"maxRequestHeadersKb": 96, "perConnectionBufferLimitBytes": 1024}`)); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got := ConnectionLimitsForProxy(proxy(`{"maxRequestHeadersKb": 100000}`)); !reflect.DeepEqual(got, meshLimits) {
+		t.Errorf("expected invalid limits to be ignored, got %+v", got)
+	}
+	features.ConnectionLimits = `{"maxRequestHeadersKb": 100000}`
+	if got := ConnectionLimitsForProxy(proxy(`{"maxRequestHeadersKb": 96}`)); !reflect.DeepEqual(got, ConnectionLimits{MaxRequestHeadersKb: 96}) {
+		t.Errorf("expected the limits of the proxy with invalid mesh limits, got %+v", got)
+	}
+}
@@ -35,6 +35,7 @@ import (
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
 	istionetworking "istio.io/istio/pilot/pkg/networking"
 	"istio.io/istio/pilot/pkg/trustbundle"
 	"istio.io/istio/pkg/cluster"
@@ -91,6 +92,9 @@ type Environment struct {
 	clusterLocalServices ClusterLocalProvider
 
 	GatewayAPIController GatewayController
+
+	// GatewayCredentials reports whether the credentials referenced by gateways exist.
+	GatewayCredentials GatewayCredentials
 }
 
 func (e *Environment) Mesh() *meshconfig.MeshConfig {
@@ -303,9 +307,16 @@ type Proxy struct {
 	// XdsNode is the xDS node identifier
 	XdsNode *core.Node
 
+	// DeltaXDS is true if the proxy is connected over delta xDS.
+	DeltaXDS bool
+
 	CatchAllVirtualHost *route.VirtualHost
 
 	AutoregisteredWorkloadEntryName string
+
+	// WasmPluginPlacement is the placement of the WasmPlugins in the HTTP filters of the listeners last pushed to the
+	// proxy. WasmPlugin updates that do not change it are only pushed over ECDS.
+	WasmPluginPlacement string
 }
 
 // WatchedResource tracks an active DiscoveryRequest subscription.
@@ -336,6 +347,10 @@ type WatchedResource struct {
 
 	// LastSent tracks the time of the generated push, to determine the time it takes the client to ack.
 	LastSent time.Time
+
+	// AliasesResolved maps the aliases requested on demand over VHDS to the name of the virtual host resource sent
+	// for them, if any. It is used to remove the virtual hosts no longer resolved.
+	AliasesResolved map[string]string
 }
 
 var istioVersionRegexp = regexp.MustCompile(`^([1-9]+)\.([0-9]+)(\.([0-9]+))?`)
@@ -504,6 +519,10 @@ type NodeMetadata struct {
 	// IstioVersion specifies the Istio version associated with the proxy
 	IstioVersion string `json:"ISTIO_VERSION,omitempty"`
 
+	// SchemaVersion is the version of the node metadata schema the agent conforms to.
+	// Older agents do not send it, see NodeMetadataSchemaVersion.
+	SchemaVersion string `json:"METADATA_SCHEMA_VERSION,omitempty"`
+
 	// IstioRevision specifies the Istio revision associated with the proxy.
 	// Mostly used when istiod requests the upstream.
 	IstioRevision string `json:"ISTIO_REVISION,omitempty"`
@@ -545,6 +564,11 @@ type NodeMetadata struct {
 	// RequestedNetworkView specifies the networks that the proxy wants to see
 	RequestedNetworkView StringList `json:"REQUESTED_NETWORK_VIEW,omitempty"`
 
+	// WatchedNamespaces limits the config of a sidecar to the services and config of these namespaces, as a
+	// Sidecar importing them would. A Sidecar in the proxy namespace selecting the workload takes precedence, and
+	// the Sidecar of the root namespace is narrowed down to the hosts of these namespaces.
+	WatchedNamespaces StringList `json:"WATCHED_NAMESPACES,omitempty"`
+
 	// PodPorts defines the ports on a pod. This is used to lookup named ports.
 	PodPorts PodPortList `json:"POD_PORTS,omitempty"`
 
@@ -561,6 +585,11 @@ type NodeMetadata struct {
 	// TLSClientRootCert is the absolute path to client root cert file
 	TLSClientRootCert string `json:"TLS_CLIENT_ROOT_CERT,omitempty"`
 
+	// TLSMinProtocolVersion raises the mesh wide minimum TLS version enforced on TLS contexts for this proxy.
+	TLSMinProtocolVersion string `json:"TLS_MIN_PROTOCOL_VERSION,omitempty"`
+	// TLSCipherSuites restricts the mesh wide cipher suites allowed on TLS contexts for this proxy.
+	TLSCipherSuites StringList `json:"TLS_CIPHER_SUITES,omitempty"`
+
 	CertBaseDir string `json:"BASE,omitempty"`
 
 	// IdleTimeout specifies the idle timeout for the proxy, in duration format (10s).
@@ -578,6 +607,31 @@ type NodeMetadata struct {
 	// DNSCapture indicates whether the workload has enabled dns capture
 	DNSCapture StringBool `json:"DNS_CAPTURE,omitempty"`
 
+	// DualStack indicates whether the workload uses the IPv4 and IPv6 addresses of dual-stack services. It is
+	// enabled for the whole mesh by setting ISTIO_META_DUAL_STACK in the proxyMetadata of the mesh defaultConfig.
+	DualStack StringBool `json:"DUAL_STACK,omitempty"`
+
+	// AdaptiveConcurrency enables the adaptive concurrency filter on the inbound and gateway listeners of the
+	// workload, with its parameters as JSON. For example: {"concurrencyUpdateInterval": "100ms",
+	// "maxConcurrencyLimit": 1000, "minRTTCalcInterval": "60s", "sampleAggregatePercentile": 50}.
+	AdaptiveConcurrency string `json:"ADAPTIVE_CONCURRENCY,omitempty"`
+
+	// AdmissionControl enables the admission control filter on the inbound and gateway listeners of the workload,
+	// with its parameters as JSON. For example: {"samplingWindow": "30s", "successRateThreshold": 95,
+	// "aggression": 1.5, "rpsThreshold": 5, "maxRejectionProbability": 80}.
+	AdmissionControl string `json:"ADMISSION_CONTROL,omitempty"`
+
+	// LocalRateLimit enables the local rate limit of the requests received by the inbound listeners of the workload,
+	// with its token buckets as JSON. The clients authenticated with mTLS can be given their own token bucket by
+	// principal or namespace, the bucket with the highest priority applying. For example: {"maxTokens": 1000,
+	// "fillInterval": "1s", "clients": [{"namespace": "batch", "maxTokens": 100, "fillInterval": "1s"},
+	// {"principal": "cluster.local/ns/batch/sa/loader", "maxTokens": 10, "fillInterval": "1s", "priority": 1}]}.
+	LocalRateLimit string `json:"LOCAL_RATE_LIMIT,omitempty"`
+
+	// ConnectionLimits overrides, field by field, the mesh wide connection limits of the workload, as JSON. It is set
+	// with ISTIO_META_CONNECTION_LIMITS in the proxyMetadata of the proxy.istio.io/config annotation of the pod.
+	ConnectionLimits string `json:"CONNECTION_LIMITS,omitempty"`
+
 	// DNSAutoAllocate indicates whether the workload should have auto allocated addresses for ServiceEntry
 	// This allows resolving ServiceEntries, which is especially useful for distinguishing TCP traffic
 	// This depends on DNSCapture.
@@ -613,6 +667,13 @@ type NodeMetadata struct {
 	// redirected tcp listeners. This does not change the virtualOutbound listener.
 	OutboundListenerExactBalance StringBool `json:"OUTBOUND_LISTENER_EXACT_BALANCE,omitempty"`
 
+	// EnableLoadReporting configures Envoy to report the load of its clusters to Istiod over LRS.
+	EnableLoadReporting StringBool `json:"ENABLE_LOAD_REPORTING,omitempty"`
+
+	// EnableVHDS configures a gateway to fetch the virtual hosts of its routes on demand over VHDS, rather than
+	// receiving the entire route tables. It is ignored unless the gateway is connected over delta xDS.
+	EnableVHDS StringBool `json:"ENABLE_VHDS,omitempty"`
+
 	// Contains a copy of the raw metadata. This is needed to lookup arbitrary values.
 	// If a value is known ahead of time it should be added to the struct rather than reading from here,
 	Raw map[string]interface{} `json:"-"`
@@ -812,6 +873,11 @@ func (node *Proxy) BuildCatchAllVirtualHost() {
 			egressDestination = BuildSubsetKey(TrafficDirectionOutbound, destination.Subset, host.Name(destination.Host), int(destination.GetPort().Number))
 		}
 	}
+	if !allowAny && node.SidecarScope.OutboundTrafficAudit {
+		// forward the traffic REGISTRY_ONLY would block, separately from the passthrough traffic
+		allowAny = true
+		egressDestination = istionetworking.AuditPassthroughCluster
+	}
 	node.CatchAllVirtualHost = istionetworking.BuildCatchAllVirtualHost(allowAny, egressDestination)
 }
 
@@ -888,6 +954,29 @@ func (node *Proxy) SupportsIPv6() bool {
 	return node.ipv6Support
 }
 
+// IsDualStack returns true if proxy supports both IPv4 and IPv6 addresses.
+func (node *Proxy) IsDualStack() bool {
+	return node.ipv4Support && node.ipv6Support
+}
+
+// DualStackEnabled returns true if the proxy uses the addresses of both IP families of dual-stack services.
+func (node *Proxy) DualStackEnabled() bool {
+	return node.Metadata != nil && bool(node.Metadata.DualStack)
+}
+
+// SupportsAddress returns true if the proxy supports the IP family of addr. Addresses that are not IPs are
+// always supported, as are all addresses when the IP families of the proxy are not known.
+func (node *Proxy) SupportsAddress(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil || (!node.ipv4Support && !node.ipv6Support) {
+		return true
+	}
+	if ip.To4() != nil {
+		return node.ipv4Support
+	}
+	return node.ipv6Support
+}
+
 // ParseMetadata parses the opaque Metadata from an Envoy Node into string key-value pairs.
 // Any non-string values are ignored.
 func ParseMetadata(metadata *structpb.Struct) (*NodeMetadata, error) {
@@ -1110,6 +1199,16 @@ func (node *Proxy) IsProxylessGrpc() bool {
 	return node.Metadata != nil && node.Metadata.Generator == "grpc"
 }
 
+// VHDSEnabled returns whether the proxy is a gateway fetching the virtual hosts of its routes on demand over VHDS,
+// either configured by its metadata or by PILOT_ENABLE_GATEWAY_VHDS. VHDS is only served over delta xDS, so the
+// gateways connected over SotW xDS always receive the entire route tables.
+func (node *Proxy) VHDSEnabled() bool {
+	if node.Type != Router || node.Metadata == nil || !node.DeltaXDS {
+		return false
+	}
+	return bool(node.Metadata.EnableVHDS) || features.EnableGatewayVHDS
+}
+
 type GatewayController interface {
 	ConfigStoreCache
 	// Recompute updates the internal state of the gateway controller for a given input. This should be
@@ -22,6 +22,7 @@ import (
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/config/visibility"
 )
 
@@ -38,8 +39,10 @@ import (
 func (ps *PushContext) mergeDestinationRule(p *processedDestRules, destRuleConfig config.Config, exportToMap map[visibility.Instance]bool) {
 	rule := destRuleConfig.Spec.(*networking.DestinationRule)
 	resolvedHost := ResolveShortnameToFQDN(rule.Host, destRuleConfig.Meta)
+	key := ConfigKey{Kind: gvk.DestinationRule, Name: destRuleConfig.Name, Namespace: destRuleConfig.Namespace}
 
 	if mdr, exists := p.destRule[resolvedHost]; exists {
+		p.from[resolvedHost] = append(p.from[resolvedHost], key)
 		// Deep copy destination rule, to prevent mutate it later when merge with a new one.
 		// This can happen when there are more than one destination rule of same host in one namespace.
 		copied := mdr.DeepCopy()
@@ -88,6 +91,7 @@ func (ps *PushContext) mergeDestinationRule(p *processedDestRules, destRuleConfi
 	p.hostsMap[resolvedHost] = struct{}{}
 	p.destRule[resolvedHost] = &destRuleConfig
 	p.exportTo[resolvedHost] = exportToMap
+	p.from[resolvedHost] = []ConfigKey{key}
 }
 
 // inheritDestinationRule child config inherits settings from parent mesh/namespace
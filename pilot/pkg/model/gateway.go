@@ -25,6 +25,7 @@ import (
 	"istio.io/istio/pilot/pkg/model/credentials"
 	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/gateway"
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/config/schema/gvk"
@@ -96,6 +97,50 @@ type MergedGateway struct {
 	// Note: Secrets that are not referenced by any Gateway, but are in the same namespace as the pod, are explicitly *not*
 	// included. This ensures we don't give permission to unexpected secrets, such as the citadel root key/cert.
 	VerifiedCertificateReferences sets.Set
+
+	// MissingCredentialPolicies maps the SDS resource names of the credentials referenced by the servers to the
+	// policy applied when the credential is missing. Resources with the MissingCredentialNone policy are omitted.
+	MissingCredentialPolicies map[string]MissingCredentialPolicy
+}
+
+// MissingCredentialPolicy defines how a Gateway TLS server is handled when the secret referenced by its credentialName
+// does not exist.
+type MissingCredentialPolicy string
+
+const (
+	// MissingCredentialNone leaves the certificate unavailable, the listener of the server fails to warm.
+	MissingCredentialNone MissingCredentialPolicy = "NONE"
+	// MissingCredentialKeepLastGood keeps serving the last certificate read from the secret.
+	MissingCredentialKeepLastGood MissingCredentialPolicy = "KEEP_LAST_GOOD"
+	// MissingCredentialDefaultCertificate serves the certificate of the PILOT_GATEWAY_DEFAULT_CREDENTIAL secret.
+	MissingCredentialDefaultCertificate MissingCredentialPolicy = "DEFAULT_CERTIFICATE"
+	// MissingCredentialRejectServer removes the server from the Gateway until the secret exists.
+	MissingCredentialRejectServer MissingCredentialPolicy = "REJECT_SERVER"
+)
+
+// GetMissingCredentialPolicy returns the MissingCredentialPolicy of a Gateway, set by its
+// networking.istio.io/missing-credential-policy annotation or PILOT_GATEWAY_MISSING_CREDENTIAL_POLICY.
+func GetMissingCredentialPolicy(gw config.Config) MissingCredentialPolicy {
+	policy, f := gw.Annotations[constants.GatewayMissingCredentialPolicyAnnotation]
+	if !f {
+		policy = features.GatewayMissingCredentialPolicy
+	}
+	switch p := MissingCredentialPolicy(strings.ToUpper(policy)); p {
+	case MissingCredentialNone, MissingCredentialKeepLastGood, MissingCredentialDefaultCertificate, MissingCredentialRejectServer:
+		return p
+	default:
+		log.Warnf("unknown missing credential policy %q for gateway %s/%s, using %s", policy, gw.Namespace, gw.Name, MissingCredentialNone)
+		return MissingCredentialNone
+	}
+}
+
+// GatewayCredentials reports whether the credentials referenced by the Gateway servers exist.
+type GatewayCredentials interface {
+	// CredentialExists returns true if the credential with the given SDS resource name can be served to the proxy.
+	CredentialExists(proxy *Proxy, resourceName string) bool
+	// ConfigCredentialExists returns true if the secret referenced by the credentialName of a server of a Gateway
+	// in the namespace exists in the config cluster.
+	ConfigCredentialExists(namespace, credentialName string) bool
 }
 
 var (
@@ -141,6 +186,7 @@ func MergeGateways(gateways []gatewayWithInstances, proxy *Proxy, ps *PushContex
 	tlsServerInfo := make(map[*networking.Server]*TLSServerInfo)
 	gatewayNameForServer := make(map[*networking.Server]string)
 	verifiedCertificateReferences := sets.NewSet()
+	missingCredentialPolicies := make(map[string]MissingCredentialPolicy)
 	http3AdvertisingRoutes := make(map[string]struct{})
 	tlsHostsByPort := map[uint32]sets.Set{} // port -> host set
 	autoPassthrough := false
@@ -150,6 +196,7 @@ func MergeGateways(gateways []gatewayWithInstances, proxy *Proxy, ps *PushContex
 		gatewayConfig := gwAndInstance.gateway
 		gatewayName := gatewayConfig.Namespace + "/" + gatewayConfig.Name // Format: %s/%s
 		gatewayCfg := gatewayConfig.Spec.(*networking.Gateway)
+		missingCredentialPolicy := GetMissingCredentialPolicy(gatewayConfig)
 		log.Debugf("MergeGateways: merging gateway %q :\n%v", gatewayName, gatewayCfg)
 		snames := sets.Set{}
 		for _, s := range gatewayCfg.Servers {
@@ -167,11 +214,19 @@ func MergeGateways(gateways []gatewayWithInstances, proxy *Proxy, ps *PushContex
 				RecordRejectedConfig(gatewayName)
 				continue
 			}
+			cn := s.GetTls().GetCredentialName()
+			if cn != "" && missingCredentialPolicy == MissingCredentialRejectServer && !ps.credentialExists(proxy, credentials.ToResourceName(cn)) {
+				log.Debugf("skipping server on gateway %s, credential %s not found", gatewayName, cn)
+				RecordRejectedConfig(gatewayName)
+				continue
+			}
 			sanitizeServerHostNamespace(s, gatewayConfig.Namespace)
 			gatewayNameForServer[s] = gatewayName
 			log.Debugf("MergeGateways: gateway %q processing server %s :%v", gatewayName, s.Name, s.Hosts)
 
-			cn := s.GetTls().GetCredentialName()
+			if cn != "" && missingCredentialPolicy != MissingCredentialNone {
+				missingCredentialPolicies[credentials.ToResourceName(cn)] = missingCredentialPolicy
+			}
 			if cn != "" && proxy.VerifiedIdentity != nil {
 				rn := credentials.ToResourceName(cn)
 				parse, _ := credentials.ParseResourceName(rn, proxy.VerifiedIdentity.Namespace, "", "")
@@ -332,6 +387,7 @@ func MergeGateways(gateways []gatewayWithInstances, proxy *Proxy, ps *PushContex
 		ContainsAutoPassthroughGateways: autoPassthrough,
 		PortMap:                         getTargetPortMap(serversByRouteName),
 		VerifiedCertificateReferences:   verifiedCertificateReferences,
+		MissingCredentialPolicies:       missingCredentialPolicies,
 	}
 }
 
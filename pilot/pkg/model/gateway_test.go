@@ -16,10 +16,14 @@ package model
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 
 	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model/credentials"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/spiffe"
 )
 
 // nolint lll
@@ -182,6 +186,87 @@ func makeConfig(name, namespace, host, portName, portProtocol string, portNumber
 	return c
 }
 
+type fakeGatewayCredentials map[string]bool
+
+func (f fakeGatewayCredentials) CredentialExists(_ *Proxy, resourceName string) bool {
+	return f[resourceName]
+}
+
+func (f fakeGatewayCredentials) ConfigCredentialExists(_, credentialName string) bool {
+	return f[credentials.ToResourceName(credentialName)]
+}
+
+func TestMergeGatewaysMissingCredential(t *testing.T) {
+	gateway := func(policy string, credentialNames ...string) config.Config {
+		gw := makeConfig("gw", "istio-system", "*.example.com", "https", "HTTPS", 443, "ingressgateway", "", networking.ServerTLSSettings_SIMPLE)
+		gw.Annotations = map[string]string{constants.GatewayMissingCredentialPolicyAnnotation: policy}
+		spec := gw.Spec.(*networking.Gateway)
+		for i, cn := range credentialNames {
+			server := spec.Servers[0]
+			if i > 0 {
+				server = &networking.Server{
+					Hosts: []string{fmt.Sprintf("%d.example.org", i)},
+					Port:  &networking.Port{Name: fmt.Sprintf("https-%d", i), Number: 443, Protocol: "HTTPS"},
+					Tls:   &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_SIMPLE},
+				}
+				spec.Servers = append(spec.Servers, server)
+			}
+			server.Tls.CredentialName = cn
+		}
+		return gw
+	}
+	ps := NewPushContext()
+	ps.gatewayCredentials = fakeGatewayCredentials{"kubernetes://found": true}
+	proxy := &Proxy{VerifiedIdentity: &spiffe.Identity{Namespace: "istio-system"}}
+
+	cases := []struct {
+		name     string
+		gateway  config.Config
+		servers  int
+		policies map[string]MissingCredentialPolicy
+	}{
+		{
+			name:     "none",
+			gateway:  gateway("NONE", "found", "missing"),
+			servers:  2,
+			policies: map[string]MissingCredentialPolicy{},
+		},
+		{
+			name:     "keep last good",
+			gateway:  gateway("keep_last_good", "found", "missing"),
+			servers:  2,
+			policies: map[string]MissingCredentialPolicy{"kubernetes://found": "KEEP_LAST_GOOD", "kubernetes://missing": "KEEP_LAST_GOOD"},
+		},
+		{
+			name:     "reject server",
+			gateway:  gateway("REJECT_SERVER", "found", "missing"),
+			servers:  1,
+			policies: map[string]MissingCredentialPolicy{"kubernetes://found": "REJECT_SERVER"},
+		},
+		{
+			name:     "unknown",
+			gateway:  gateway("invalid", "missing"),
+			servers:  1,
+			policies: map[string]MissingCredentialPolicy{},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			mgw := MergeGateways([]gatewayWithInstances{{tt.gateway, true, nil}}, proxy, ps)
+			servers := 0
+			for _, ms := range mgw.MergedServers {
+				servers += len(ms.Servers)
+			}
+			if servers != tt.servers {
+				t.Errorf("expected %d servers, got %d", tt.servers, servers)
+			}
+			if !reflect.DeepEqual(mgw.MissingCredentialPolicies, tt.policies) {
+				t.Errorf("expected policies %v, got %v", tt.policies, mgw.MissingCredentialPolicies)
+			}
+		})
+	}
+}
+
 func TestParseGatewayRDSRouteName(t *testing.T) {
 	type args struct {
 		name string
@@ -35,7 +35,9 @@ import (
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_jwt "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	"github.com/hashicorp/go-multierror"
 
+	"istio.io/api/security/v1beta1"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/pkg/monitoring"
 )
@@ -104,6 +106,24 @@ type jwtPubKeyEntry struct {
 
 	// Cached item's last used time, which is set in GetPublicKey.
 	lastUsedTime time.Time
+
+	// The last time the pubKey was fetched from network, whether it succeeded or not.
+	lastFetchedTime time.Time
+
+	// The error of the last fetch, empty if the last fetch succeeded.
+	lastError string
+}
+
+// JwksStatus is the status of the cached JWKS of a single issuer.
+type JwksStatus struct {
+	Issuer  string `json:"issuer"`
+	JwksURI string `json:"jwksUri,omitempty"`
+	// LastFetched is the last time the JWKS was fetched, whether it succeeded or not.
+	LastFetched time.Time `json:"lastFetched"`
+	// LastRefreshed is the last time the JWKS was fetched successfully.
+	LastRefreshed time.Time `json:"lastRefreshed"`
+	KeyIDs        []string  `json:"keyIds,omitempty"`
+	Error         string    `json:"error,omitempty"`
 }
 
 // jwtKey is a key in the JwksResolver keyEntries map.
@@ -254,15 +274,106 @@ func (r *JwksResolver) GetPublicKey(issuer string, jwksURI string) (string, erro
 		pubKey = string(resp)
 	}
 
-	r.keyEntries.Store(key, jwtPubKeyEntry{
-		pubKey:            pubKey,
-		lastRefreshedTime: now,
-		lastUsedTime:      now,
-	})
+	e := jwtPubKeyEntry{
+		pubKey:          pubKey,
+		lastUsedTime:    now,
+		lastFetchedTime: now,
+		lastError:       errorString(err),
+	}
+	if err == nil {
+		e.lastRefreshedTime = now
+	}
+	r.keyEntries.Store(key, e)
 
 	return pubKey, err
 }
 
+// Prefetch fetches and caches the JWKS of all the given rules that don't have an inline JWKS, so that
+// an unreachable issuer is surfaced at startup instead of on the first push that needs it.
+// It returns an error listing every issuer whose JWKS could not be fetched.
+func (r *JwksResolver) Prefetch(rules []*v1beta1.JWTRule) error {
+	keys := map[jwtKey]struct{}{}
+	for _, rule := range rules {
+		if rule.GetJwks() != "" {
+			continue
+		}
+		keys[jwtKey{issuer: rule.GetIssuer(), jwksURI: rule.GetJwksUri()}] = struct{}{}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs *multierror.Error
+	for k := range keys {
+		k := k
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.GetPublicKey(k.issuer, k.jwksURI); err != nil {
+				mu.Lock()
+				errs = multierror.Append(errs, fmt.Errorf("issuer %q: %v", k.issuer, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errs.ErrorOrNil()
+}
+
+// Status returns the status of all the cached JWKS, sorted by issuer and jwks URI.
+func (r *JwksResolver) Status() []JwksStatus {
+	out := []JwksStatus{}
+	r.keyEntries.Range(func(key interface{}, value interface{}) bool {
+		k := key.(jwtKey)
+		e := value.(jwtPubKeyEntry)
+		out = append(out, JwksStatus{
+			Issuer:        k.issuer,
+			JwksURI:       k.jwksURI,
+			LastFetched:   e.lastFetchedTime,
+			LastRefreshed: e.lastRefreshedTime,
+			KeyIDs:        jwksKeyIDs(e.pubKey),
+			Error:         e.lastError,
+		})
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Issuer != out[j].Issuer {
+			return out[i].Issuer < out[j].Issuer
+		}
+		return out[i].JwksURI < out[j].JwksURI
+	})
+	return out
+}
+
+// jwksKeyIDs returns the key IDs of the given JWKS, or nil if it can't be parsed.
+func jwksKeyIDs(jwks string) []string {
+	if jwks == "" {
+		return nil
+	}
+	var parsed struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal([]byte(jwks), &parsed); err != nil {
+		return nil
+	}
+	var ids []string
+	for _, k := range parsed.Keys {
+		if k.Kid != "" {
+			ids = append(ids, k.Kid)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
 // BuildLocalJwks builds local Jwks by fetching the Jwt Public Key from the URL passed if it is empty.
 func (r *JwksResolver) BuildLocalJwks(jwksURI, jwtIssuer, jwtPubKey string) *envoy_jwt.JwtProvider_LocalJwks {
 	if jwtPubKey == "" {
@@ -423,8 +534,10 @@ func (r *JwksResolver) refresh() bool {
 		// 1) it hasn't been used for a while
 		// 2) it hasn't been refreshed successfully for a while
 		// This makes sure 2 things, we don't grow the cache infinitely and also we don't reuse a cached public key
-		// with no success refresh for too much time.
-		if now.Sub(e.lastUsedTime) >= r.evictionDuration || now.Sub(e.lastRefreshedTime) >= r.evictionDuration {
+		// with no success refresh for too much time. An item which was never fetched successfully has no public key
+		// to reuse, and is kept for the refresh to retry it while it is used.
+		if now.Sub(e.lastUsedTime) >= r.evictionDuration ||
+			(!e.lastRefreshedTime.IsZero() && now.Sub(e.lastRefreshedTime) >= r.evictionDuration) {
 			log.Infof("Removed cached JWT public key (lastRefreshed: %s, lastUsed: %s) from %q",
 				e.lastRefreshedTime, e.lastUsedTime, k.issuer)
 			r.keyEntries.Delete(k)
@@ -447,6 +560,7 @@ func (r *JwksResolver) refresh() bool {
 					hasErrors = true
 					log.Errorf("Failed to resolve Jwks from issuer %q: %v", k.issuer, err)
 					atomic.AddUint64(&r.refreshJobFetchFailedCount, 1)
+					r.recordFetchError(k, e, now, err)
 					return
 				}
 			}
@@ -456,20 +570,23 @@ func (r *JwksResolver) refresh() bool {
 				hasErrors = true
 				log.Errorf("Failed to refresh JWT public key from %q: %v", jwksURI, err)
 				atomic.AddUint64(&r.refreshJobFetchFailedCount, 1)
+				r.recordFetchError(k, e, now, err)
 				return
 			}
 			newPubKey := string(resp)
-			r.keyEntries.Store(k, jwtPubKeyEntry{
-				pubKey:            newPubKey,
-				lastRefreshedTime: now,            // update the lastRefreshedTime if we get a success response from the network.
-				lastUsedTime:      e.lastUsedTime, // keep original lastUsedTime.
-			})
 			isNewKey, err := compareJWKSResponse(oldPubKey, newPubKey)
 			if err != nil {
 				hasErrors = true
 				log.Errorf("Failed to refresh JWT public key from %q: %v", jwksURI, err)
+				r.recordFetchError(k, e, now, err)
 				return
 			}
+			r.keyEntries.Store(k, jwtPubKeyEntry{
+				pubKey:            newPubKey,
+				lastRefreshedTime: now,            // update the lastRefreshedTime if we get a success response from the network.
+				lastUsedTime:      e.lastUsedTime, // keep original lastUsedTime.
+				lastFetchedTime:   now,
+			})
 			if isNewKey {
 				hasChange = true
 				log.Infof("Updated cached JWT public key from %q", jwksURI)
@@ -492,6 +609,13 @@ func (r *JwksResolver) refresh() bool {
 	return hasErrors
 }
 
+// recordFetchError records a failed refresh of the given entry, keeping the previously fetched public key.
+func (r *JwksResolver) recordFetchError(k jwtKey, e jwtPubKeyEntry, now time.Time, err error) {
+	e.lastFetchedTime = now
+	e.lastError = err.Error()
+	r.keyEntries.Store(k, e)
+}
+
 // Close will shut down the refresher job.
 // TODO: may need to figure out the right place to call this function.
 // (right now calls it from initDiscoveryService in pkg/bootstrap/server.go).
@@ -16,12 +16,15 @@ package model
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"go.opencensus.io/stats/view"
 
+	"istio.io/api/security/v1beta1"
 	"istio.io/istio/pilot/pkg/model/test"
 	"istio.io/istio/pkg/test/util/retry"
 )
@@ -585,3 +588,66 @@ func TestCompareJWKSResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestPrefetchAndStatus(t *testing.T) {
+	r := NewJwksResolver(JwtPubKeyEvictionDuration, JwtPubKeyRefreshInterval, JwtPubKeyRefreshIntervalOnFailure, testRetryInterval)
+	defer r.Close()
+
+	ms := startMockServer(t)
+	defer ms.Stop()
+	mockCertURL := ms.URL + "/oauth2/v3/certs"
+
+	err := r.Prefetch([]*v1beta1.JWTRule{
+		{Issuer: "good", JwksUri: mockCertURL},
+		{Issuer: "good", JwksUri: mockCertURL},
+		{Issuer: "inline", Jwks: test.JwtPubKey2},
+		{Issuer: "bad", JwksUri: "http://xyz"},
+	})
+	if err == nil || !strings.Contains(err.Error(), `issuer "bad"`) {
+		t.Fatalf("expected prefetch of issuer bad to fail, got %v", err)
+	}
+	if got, want := ms.PubKeyHitNum, uint64(1); got != want {
+		t.Errorf("Mock server Hit number => expected %d but got %d", want, got)
+	}
+
+	status := r.Status()
+	if len(status) != 2 {
+		t.Fatalf("expected status of 2 issuers, got %+v", status)
+	}
+	if st := status[0]; st.Issuer != "bad" || st.Error == "" || st.KeyIDs != nil || st.LastFetched.IsZero() ||
+		!st.LastRefreshed.IsZero() {
+		t.Errorf("unexpected status of issuer bad: %+v", st)
+	}
+	if st := status[1]; st.Issuer != "good" || st.Error != "" ||
+		!reflect.DeepEqual(st.KeyIDs, []string{"fakeKey1_1", "fakeKey1_2"}) || st.LastRefreshed.IsZero() {
+		t.Errorf("unexpected status of issuer good: %+v", st)
+	}
+}
+
+func TestStatusLastRefreshedOnFailedRefresh(t *testing.T) {
+	r := NewJwksResolver(JwtPubKeyEvictionDuration, 10*time.Millisecond, 10*time.Millisecond, testRetryInterval)
+	defer r.Close()
+
+	ms := startMockServer(t)
+	defer ms.Stop()
+	// Configures the mock server to return error after the first request.
+	ms.ReturnErrorAfterFirstNumHits = 1
+	mockCertURL := ms.URL + "/oauth2/v3/certs"
+
+	if _, err := r.GetPublicKey("good", mockCertURL); err != nil {
+		t.Fatal(err)
+	}
+	refreshed := r.Status()[0].LastRefreshed
+
+	var st JwksStatus
+	retry.UntilOrFail(t, func() bool {
+		st = r.Status()[0]
+		return st.Error != ""
+	}, retry.Delay(time.Millisecond))
+	if !st.LastRefreshed.Equal(refreshed) || !st.LastFetched.After(refreshed) {
+		t.Errorf("expected the failed refresh to only update the last fetch time, got %+v", st)
+	}
+	if !reflect.DeepEqual(st.KeyIDs, []string{"fakeKey1_1", "fakeKey1_2"}) {
+		t.Errorf("expected the previous keys to be kept, got %+v", st)
+	}
+}
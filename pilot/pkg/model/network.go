@@ -15,10 +15,12 @@
 package model
 
 import (
+	"fmt"
 	"math"
 	"net"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -31,6 +33,16 @@ import (
 	"istio.io/istio/pkg/network"
 )
 
+// DetectedNetwork is the network detected for the workloads of a node without an explicit network.
+type DetectedNetwork struct {
+	// Node is the name of the node.
+	Node string `json:"node"`
+	// Network is the network detected for its workloads.
+	Network network.ID `json:"network"`
+	// Detector is the name of the detector which detected the network.
+	Detector string `json:"detector"`
+}
+
 // NetworkGateway is the gateway of a network
 type NetworkGateway struct {
 	// Network is the ID of the network where this Gateway resides.
@@ -41,6 +53,43 @@ type NetworkGateway struct {
 	Addr string
 	// gateway port
 	Port uint32
+	// Weight is the relative weight of the gateway among the gateways of its network, 1 if unset.
+	Weight uint32
+	// Drained is true if the gateway was given a weight of 0, so that no traffic is sent to it.
+	Drained bool
+	// Tunneled is true if the cross-network traffic to the gateway is tunneled through HTTP CONNECT.
+	Tunneled bool
+}
+
+// LBWeight returns the weight of the gateway, 1 if unset and 0 if drained.
+func (gw NetworkGateway) LBWeight() uint32 {
+	if gw.Drained {
+		return 0
+	}
+	if gw.Weight == 0 {
+		return 1
+	}
+	return gw.Weight
+}
+
+// CrossNetworkTunnelPort is the port of the network gateways terminating the HTTP CONNECT tunnels of
+// cross-network traffic.
+const CrossNetworkTunnelPort = 15009
+
+// tunneledNetworks returns the networks whose cross-network traffic is tunneled through HTTP CONNECT, listed in
+// features.CrossNetworkTunnelNetworks.
+func tunneledNetworks() sets.Set {
+	if features.CrossNetworkTunnelNetworks == "" {
+		return nil
+	}
+	return sets.NewSet(strings.Split(features.CrossNetworkTunnelNetworks, ",")...)
+}
+
+// TunnelAddress returns the address of the listener of the proxies tunneling traffic to the gateway. It is an
+// abstract unix domain socket, so that the endpoints of the gateway can be replaced by the listener without
+// allocating a port per gateway.
+func (gw NetworkGateway) TunnelAddress() string {
+	return UnixAddressPrefix + "@istio-tunnel/" + net.JoinHostPort(gw.Addr, strconv.Itoa(int(gw.Port)))
 }
 
 type NetworkGatewaysWatcher interface {
@@ -66,31 +115,57 @@ func (ngh *NetworkGatewaysHandler) NotifyGatewayHandlers() {
 // NewNetworkManager creates a new NetworkManager from the Environment by merging
 // together the MeshNetworks and ServiceRegistry-specific gateways.
 func NewNetworkManager(env *Environment, xdsUpdater XDSUpdater) (*NetworkManager, error) {
-	nameCache, err := newNetworkGatewayNameCache()
+	nameCache, err := newNetworkGatewayNameCache(gatewayDNSConfigFromFeatures())
+	if err != nil {
+		return nil, err
+	}
+	healthCfg, err := gatewayHealthCheckConfigFromFeatures()
+	if err != nil {
+		return nil, err
+	}
+	healthChecker := newNetworkGatewayHealthChecker()
+	healthChecker.configure(healthCfg)
+	translations, err := ParseAddressTranslations(features.NetworkAddressTranslation)
 	if err != nil {
 		return nil, err
 	}
-	mgr := &NetworkManager{env: env, NameCache: nameCache, xdsUpdater: xdsUpdater}
+	weights, err := ParseGatewayWeights(features.NetworkGatewayWeights)
+	if err != nil {
+		return nil, err
+	}
+	mgr := &NetworkManager{
+		env:           env,
+		NameCache:     nameCache,
+		healthChecker: healthChecker,
+		xdsUpdater:    xdsUpdater,
+		translations:  translations,
+		weights:       weights,
+	}
 	env.AddNetworksHandler(mgr.reloadAndPush)
 	env.AppendNetworkGatewayHandler(mgr.reloadAndPush)
 	nameCache.AppendNetworkGatewayHandler(mgr.reloadAndPush)
+	healthChecker.AppendNetworkGatewayHandler(mgr.reloadAndPush)
 	mgr.reload()
 	return mgr, nil
 }
 
 func (mgr *NetworkManager) reloadAndPush() {
 	mgr.mu.Lock()
-	defer mgr.mu.Unlock()
 	oldGateways := make(NetworkGatewaySet)
 	for _, gateway := range mgr.allGateways() {
 		oldGateways.Add(gateway)
 	}
 	changed := !mgr.reload().Equals(oldGateways)
+	mgr.mu.Unlock()
 
-	if changed && mgr.xdsUpdater != nil {
+	if !changed {
+		return
+	}
+	if mgr.xdsUpdater != nil {
 		log.Infof("gateways changed, triggering push")
 		mgr.xdsUpdater.ConfigUpdate(&PushRequest{Full: true, Reason: []TriggerReason{NetworksTrigger}})
 	}
+	mgr.NotifyGatewayHandlers()
 }
 
 func (mgr *NetworkManager) reload() NetworkGatewaySet {
@@ -128,8 +203,32 @@ func (mgr *NetworkManager) reload() NetworkGatewaySet {
 		gatewaySet[gw] = struct{}{}
 	}
 
+	// Weight the gateways without a weight from their registry, before the hostnames are resolved so that the
+	// weight of a hostname applies to all its addresses.
+	for gw := range gatewaySet {
+		if weight, ok := mgr.weightFor(gw); ok && gw.Weight == 0 && !gw.Drained {
+			delete(gatewaySet, gw)
+			gw.Weight = weight
+			gw.Drained = weight == 0
+			gatewaySet[gw] = struct{}{}
+		}
+	}
+
+	// Mark the gateways of the networks whose traffic is tunneled.
+	tunneled := tunneledNetworks()
+	for gw := range gatewaySet {
+		if tunneled.Contains(string(gw.Network)) && !gw.Tunneled {
+			delete(gatewaySet, gw)
+			gw.Tunneled = true
+			gatewaySet[gw] = struct{}{}
+		}
+	}
+
 	mgr.resolveHostnameGateways(gatewaySet)
 
+	// Exclude the unhealthy gateways, so that the traffic to remote networks is not sent to them.
+	mgr.healthChecker.healthyGateways(gatewaySet)
+
 	// Now populate the maps by network and by network+cluster.
 	byNetwork := make(map[network.ID][]NetworkGateway)
 	byNetworkAndCluster := make(map[networkAndCluster][]NetworkGateway)
@@ -139,24 +238,33 @@ func (mgr *NetworkManager) reload() NetworkGatewaySet {
 		byNetworkAndCluster[nc] = append(byNetworkAndCluster[nc], gw)
 	}
 
-	gwNum := []int{}
-	// Sort the gateways in byNetwork, and also calculate the max number
-	// of gateways per network.
+	gwWeights := []int{}
+	// Sort the gateways in byNetwork, and also collect the total weight of the gateways per network,
+	// so that the weight of an endpoint can be split exactly among its gateways.
 	for k, gws := range byNetwork {
 		byNetwork[k] = SortGateways(gws)
-		gwNum = append(gwNum, len(gws))
+		gwWeights = append(gwWeights, totalLBWeight(gws))
 	}
 
 	// Sort the gateways in byNetworkAndCluster.
 	for k, gws := range byNetworkAndCluster {
 		byNetworkAndCluster[k] = SortGateways(gws)
-		gwNum = append(gwNum, len(gws))
+		gwWeights = append(gwWeights, totalLBWeight(gws))
 	}
 
 	lcmVal := 1
 	// calculate lcm
-	for _, num := range gwNum {
-		lcmVal = lcm(lcmVal, num)
+	for _, weight := range gwWeights {
+		if weight == 0 {
+			// All the gateways are drained, no weight is split among them.
+			continue
+		}
+		lcmVal = lcm(lcmVal, weight)
+		if uint64(lcmVal) > math.MaxUint32 {
+			// The weights are clipped at the maximum value for uint32 anyway.
+			lcmVal = math.MaxUint32
+			break
+		}
 	}
 
 	mgr.lcm = uint32(lcmVal)
@@ -166,6 +274,15 @@ func (mgr *NetworkManager) reload() NetworkGatewaySet {
 	return gatewaySet
 }
 
+// totalLBWeight returns the sum of the weights of the gateways.
+func totalLBWeight(gws []NetworkGateway) int {
+	total := 0
+	for _, gw := range gws {
+		total += int(gw.LBWeight())
+	}
+	return total
+}
+
 func (mgr *NetworkManager) resolveHostnameGateways(gatewaySet map[NetworkGateway]struct{}) {
 	// filter the list of gateways to resolve
 	hostnameGateways := map[string][]NetworkGateway{}
@@ -197,24 +314,85 @@ func (mgr *NetworkManager) resolveHostnameGateways(gatewaySet map[NetworkGateway
 				// copy the base gateway to preserve the port/network, but update with the resolved IP
 				resolvedGw := gw
 				resolvedGw.Addr = resolved
+				if isSRVName(host) {
+					// SRV gateways are resolved to host:port pairs, the port of the gateway is the one learned from DNS
+					addr, port, err := net.SplitHostPort(resolved)
+					if err != nil {
+						log.Warnf("invalid address %q resolved for SRV gateway %q: %v", resolved, host, err)
+						continue
+					}
+					p, _ := strconv.Atoi(port)
+					resolvedGw.Addr = addr
+					resolvedGw.Port = uint32(p)
+				}
 				gatewaySet[resolvedGw] = struct{}{}
 			}
 		}
 	}
 }
 
+// isSRVName returns whether the gateway address is the name of SRV records, i.e. _service._proto.name,
+// in which case the port of the gateway is learned from DNS along with its addresses.
+func isSRVName(name string) bool {
+	parts := strings.SplitN(name, ".", 3)
+	return len(parts) == 3 && strings.HasPrefix(parts[0], "_") && strings.HasPrefix(parts[1], "_")
+}
+
 // NetworkManager provides gateway details for accessing remote networks.
 type NetworkManager struct {
 	env *Environment
+	// NetworkGatewaysHandler notifies the handlers once the gateways changed.
+	NetworkGatewaysHandler
 	// exported for test
-	NameCache  *networkGatewayNameCache
-	xdsUpdater XDSUpdater
+	NameCache *networkGatewayNameCache
+	// healthChecker probes the gateways.
+	healthChecker *networkGatewayHealthChecker
+	xdsUpdater    XDSUpdater
 
-	// least common multiple of gateway number of {per network, per cluster}
+	// least common multiple of the total gateway weight of {per network, per cluster}
 	mu                  sync.RWMutex
 	lcm                 uint32
 	byNetwork           map[network.ID][]NetworkGateway
 	byNetworkAndCluster map[networkAndCluster][]NetworkGateway
+
+	// translations are the address translation maps of the networks reached through a 1:1 NAT.
+	translations []AddressTranslation
+	// weights are the weights of the gateways, by address or address:port.
+	weights map[string]uint32
+}
+
+// ParseGatewayWeights parses a comma separated list of the weights of gateways, by address or address:port,
+// such as 35.1.1.1=10,gw.example.com:15443=1. A weight of 0 drains the gateway.
+func ParseGatewayWeights(s string) (map[string]uint32, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	out := map[string]uint32{}
+	for _, entry := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid gateway weight %q, must be address[:port]=weight", entry)
+		}
+		weight, err := strconv.ParseUint(kv[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight %q of gateway %s, must be a non-negative integer", kv[1], kv[0])
+		}
+		address := kv[0]
+		if host, port, err := net.SplitHostPort(address); err == nil {
+			address = net.JoinHostPort(host, port)
+		}
+		out[address] = uint32(weight)
+	}
+	return out, nil
+}
+
+// weightFor returns the weight of the gateway configured by its address and port, or else by its address.
+func (mgr *NetworkManager) weightFor(gw NetworkGateway) (uint32, bool) {
+	if weight, ok := mgr.weights[net.JoinHostPort(gw.Addr, strconv.Itoa(int(gw.Port)))]; ok {
+		return weight, true
+	}
+	weight, ok := mgr.weights[gw.Addr]
+	return weight, ok
 }
 
 func (mgr *NetworkManager) IsMultiNetworkEnabled() bool {
@@ -223,10 +401,10 @@ func (mgr *NetworkManager) IsMultiNetworkEnabled() bool {
 	}
 	mgr.mu.RLock()
 	defer mgr.mu.RUnlock()
-	return len(mgr.byNetwork) > 0
+	return len(mgr.byNetwork) > 0 || len(mgr.translations) > 0
 }
 
-// GetLBWeightScaleFactor returns the least common multiple of the number of gateways per network.
+// GetLBWeightScaleFactor returns the least common multiple of the total weight of the gateways per network.
 func (mgr *NetworkManager) GetLBWeightScaleFactor() uint32 {
 	mgr.mu.RLock()
 	defer mgr.mu.RUnlock()
@@ -365,8 +543,26 @@ func (s NetworkGatewaySet) ToArray() []NetworkGateway {
 // MinGatewayTTL is exported for testing
 var MinGatewayTTL = 30 * time.Second
 
+// gatewayDNSConfig configures the resolution of the hostnames of network gateways.
+type gatewayDNSConfig struct {
+	servers           []string
+	searchDomains     []string
+	timeout           time.Duration
+	keepLastKnownGood bool
+}
+
+func gatewayDNSConfigFromFeatures() gatewayDNSConfig {
+	return gatewayDNSConfig{
+		servers:           features.NetworkGatewayDNSServers,
+		searchDomains:     features.NetworkGatewayDNSSearchDomains,
+		timeout:           features.NetworkGatewayDNSTimeout,
+		keepLastKnownGood: features.NetworkGatewayDNSKeepLastKnownGood,
+	}
+}
+
 type networkGatewayNameCache struct {
 	NetworkGatewaysHandler
+	config gatewayDNSConfig
 	client *dnsClient
 
 	sync.Mutex
@@ -379,17 +575,12 @@ type nameCacheEntry struct {
 	timer  *time.Timer
 }
 
-func newNetworkGatewayNameCache() (*networkGatewayNameCache, error) {
-	c, err := newClient()
+func newNetworkGatewayNameCache(cfg gatewayDNSConfig) (*networkGatewayNameCache, error) {
+	c, err := newClient(cfg)
 	if err != nil {
 		return nil, err
 	}
-	return newNetworkGatewayNameCacheWithClient(c), nil
-}
-
-// newNetworkGatewayNameCacheWithClient exported for test
-func newNetworkGatewayNameCacheWithClient(c *dnsClient) *networkGatewayNameCache {
-	return &networkGatewayNameCache{client: c, cache: map[string]nameCacheEntry{}}
+	return &networkGatewayNameCache{config: cfg, client: c, cache: map[string]nameCacheEntry{}}, nil
 }
 
 // Resolve takes a list of hostnames and returns a map of names to addresses
@@ -428,11 +619,21 @@ func (n *networkGatewayNameCache) resolveFromCache(name string) []string {
 }
 
 func (n *networkGatewayNameCache) resolveAndCache(name string) []string {
-	if entry, ok := n.cache[name]; ok {
-		entry.timer.Stop()
+	old, ok := n.cache[name]
+	if ok {
+		old.timer.Stop()
 	}
 	delete(n.cache, name)
-	addrs, ttl := n.resolve(name)
+	addrs, ttl, err := n.resolve(name)
+	if err != nil {
+		// the failed resolution is retried after MinGatewayTTL
+		if n.config.keepLastKnownGood && len(old.value) > 0 {
+			log.Warnf("network gateways: failed resolving %s, keeping the last known addresses %v: %v", name, old.value, err)
+			addrs = old.value
+		} else {
+			log.Warnf("network gateways: failed resolving %s: %v", name, err)
+		}
+	}
 	// avoid excessive pushes due to small TTL
 	if ttl < MinGatewayTTL {
 		ttl = MinGatewayTTL
@@ -480,16 +681,99 @@ func stringSliceEqual(a, b []string) bool {
 	return true
 }
 
-// resolve gets all the A and AAAA records for the given name
-func (n *networkGatewayNameCache) resolve(name string) ([]string, time.Duration) {
+// resolve gets all the A and AAAA records for the given name, or the host:port pairs of the SRV records
+// if the name is the name of SRV records.
+// An error is returned if the name could not be resolved because the DNS servers are unavailable.
+func (n *networkGatewayNameCache) resolve(name string) ([]string, time.Duration, error) {
+	if isSRVName(name) {
+		return n.resolveSRV(name)
+	}
 	// TODO figure out how to query only A + AAAA
-	res := n.client.Query(new(dns.Msg).SetQuestion(dns.Fqdn(name), dns.TypeANY))
-	if res == nil || len(res.Answer) == 0 {
-		return nil, 0
+	res, err := n.client.lookup(name, dns.TypeANY)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(res.Answer) == 0 {
+		return nil, 0, nil
+	}
+	out, ttl := addressRecords(res.Answer, "")
+	sort.Strings(out)
+	return out, time.Duration(ttl), nil
+}
+
+// resolveSRV gets the SRV records for the given name and resolves each target into host:port pairs.
+// The addresses of the targets are taken from the additional section of the response when present,
+// and looked up otherwise. The TTL is the lowest of the SRV records and the addresses of their targets.
+func (n *networkGatewayNameCache) resolveSRV(name string) ([]string, time.Duration, error) {
+	res, err := n.client.lookup(name, dns.TypeSRV)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(res.Answer) == 0 {
+		return nil, 0, nil
 	}
 	ttl := uint32(math.MaxUint32)
 	var out []string
 	for _, rr := range res.Answer {
+		srv, ok := rr.(*dns.SRV)
+		if !ok {
+			continue
+		}
+		if srv.Hdr.Ttl < ttl {
+			ttl = srv.Hdr.Ttl
+		}
+		addrs, addrTTL := addressRecords(res.Extra, srv.Target)
+		if len(addrs) == 0 {
+			// the target is fully qualified, so the search domains do not apply
+			lookupAddrs, lookupTTL, err := n.resolveTarget(srv.Target)
+			if err != nil {
+				return nil, 0, err
+			}
+			addrs, addrTTL = lookupAddrs, lookupTTL
+		}
+		if len(addrs) > 0 && addrTTL < ttl {
+			ttl = addrTTL
+		}
+		port := strconv.Itoa(int(srv.Port))
+		for _, addr := range addrs {
+			out = append(out, net.JoinHostPort(addr, port))
+		}
+	}
+	if len(out) == 0 {
+		return nil, 0, nil
+	}
+	sort.Strings(out)
+	return out, time.Duration(ttl), nil
+}
+
+// resolveTarget gets the A and AAAA records of the target of an SRV record. The target is never resolved as
+// the name of SRV records itself, so records pointing to each other cannot make the resolution recurse.
+func (n *networkGatewayNameCache) resolveTarget(target string) ([]string, uint32, error) {
+	ttl := uint32(math.MaxUint32)
+	var out []string
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		res, err := n.client.lookup(target, qtype)
+		if err != nil {
+			return nil, 0, err
+		}
+		addrs, addrTTL := addressRecords(res.Answer, "")
+		if len(addrs) > 0 && addrTTL < ttl {
+			ttl = addrTTL
+		}
+		out = append(out, addrs...)
+	}
+	return out, ttl, nil
+}
+
+// addressRecords returns the addresses of the A and AAAA records, of the given name if not empty, along with
+// their lowest TTL.
+func addressRecords(rrs []dns.RR, name string) ([]string, uint32) {
+	ttl := uint32(math.MaxUint32)
+	var out []string
+	for _, rr := range rrs {
+		if name != "" && !strings.EqualFold(rr.Header().Name, name) {
+			continue
+		}
 		switch v := rr.(type) {
 		case *dns.A:
 			out = append(out, v.A.String())
@@ -504,21 +788,30 @@ func (n *networkGatewayNameCache) resolve(name string) ([]string, time.Duration)
 			ttl = nextTTL
 		}
 	}
-	sort.Strings(out)
-	return out, time.Duration(ttl)
+	return out, ttl
 }
 
 // TODO share code with pkg/dns
 type dnsClient struct {
 	*dns.Client
 	resolvConfServers []string
+	searchDomains     []string
 }
 
 // NetworkGatewayTestDNSServers if set will ignore resolv.conf and use the given DNS servers for tests.
 var NetworkGatewayTestDNSServers []string = nil
 
-func newClient() (*dnsClient, error) {
+func newClient(cfg gatewayDNSConfig) (*dnsClient, error) {
 	servers := NetworkGatewayTestDNSServers
+	if len(servers) == 0 {
+		for _, s := range cfg.servers {
+			s = strings.TrimSpace(s)
+			if _, _, err := net.SplitHostPort(s); err != nil {
+				s = net.JoinHostPort(s, "53")
+			}
+			servers = append(servers, s)
+		}
+	}
 	if len(servers) == 0 {
 		dnsConfig, err := dns.ClientConfigFromFile("/etc/resolv.conf")
 		if err != nil {
@@ -535,15 +828,42 @@ func newClient() (*dnsClient, error) {
 
 	c := &dnsClient{
 		Client: &dns.Client{
-			DialTimeout:  5 * time.Second,
-			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 5 * time.Second,
+			DialTimeout:  cfg.timeout,
+			ReadTimeout:  cfg.timeout,
+			WriteTimeout: cfg.timeout,
 		},
 	}
 	c.resolvConfServers = append(c.resolvConfServers, servers...)
+	for _, d := range cfg.searchDomains {
+		if d = strings.Trim(strings.TrimSpace(d), "."); d != "" {
+			c.searchDomains = append(c.searchDomains, d)
+		}
+	}
 	return c, nil
 }
 
+// lookup queries the records of the given type for the name, and then for the name in each of the search domains
+// until there are answers, unless the name is fully qualified. An error is returned if the DNS servers are unavailable.
+func (c *dnsClient) lookup(name string, qtype uint16) (*dns.Msg, error) {
+	names := []string{dns.Fqdn(name)}
+	if !dns.IsFqdn(name) {
+		for _, d := range c.searchDomains {
+			names = append(names, dns.Fqdn(name+"."+d))
+		}
+	}
+	var res *dns.Msg
+	for _, n := range names {
+		res = c.Query(new(dns.Msg).SetQuestion(n, qtype))
+		if res.Rcode == dns.RcodeServerFailure {
+			return nil, fmt.Errorf("DNS servers %v failed to resolve %s", c.resolvConfServers, n)
+		}
+		if len(res.Answer) > 0 {
+			break
+		}
+	}
+	return res, nil
+}
+
 func (c *dnsClient) Query(req *dns.Msg) *dns.Msg {
 	var response *dns.Msg
 	for _, upstream := range c.resolvConfServers {
@@ -0,0 +1,227 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package model
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/network"
+)
+
+// gatewayHealthCheckConfig configures the health checks of the network gateways.
+type gatewayHealthCheckConfig struct {
+	protocol  string
+	port      int
+	path      string
+	interval  time.Duration
+	timeout   time.Duration
+	threshold int
+}
+
+// gatewayHealthCheckConfigFromFeatures returns the configuration of the health checks set in the
+// PILOT_NETWORK_GATEWAY_HEALTH_CHECK* environment variables, or an error if it is invalid.
+func gatewayHealthCheckConfigFromFeatures() (gatewayHealthCheckConfig, error) {
+	cfg := gatewayHealthCheckConfig{
+		protocol:  features.NetworkGatewayHealthCheck,
+		port:      features.NetworkGatewayHealthCheckPort,
+		path:      features.NetworkGatewayHealthCheckPath,
+		interval:  features.NetworkGatewayHealthCheckInterval,
+		timeout:   features.NetworkGatewayHealthCheckTimeout,
+		threshold: features.NetworkGatewayHealthCheckThreshold,
+	}
+	switch {
+	case cfg.protocol != "" && cfg.protocol != "tcp" && cfg.protocol != "https":
+		return cfg, fmt.Errorf("invalid PILOT_NETWORK_GATEWAY_HEALTH_CHECK %q, must be tcp or https", cfg.protocol)
+	case cfg.port < 0 || cfg.port > 65535:
+		return cfg, fmt.Errorf("invalid PILOT_NETWORK_GATEWAY_HEALTH_CHECK_PORT %d", cfg.port)
+	case cfg.interval <= 0 || cfg.timeout <= 0:
+		return cfg, fmt.Errorf("the interval and timeout of the network gateway health checks must be positive")
+	case cfg.threshold < 1:
+		return cfg, fmt.Errorf("invalid PILOT_NETWORK_GATEWAY_HEALTH_CHECK_THRESHOLD %d, must be at least 1", cfg.threshold)
+	}
+	if cfg.path == "" {
+		cfg.path = "/"
+	}
+	return cfg, nil
+}
+
+// networkGatewayHealthChecker actively probes the addresses of the network gateways, so that the unhealthy ones
+// are excluded from the endpoints of the remote networks. It notifies its handlers once a gateway changed health.
+type networkGatewayHealthChecker struct {
+	NetworkGatewaysHandler
+
+	sync.Mutex
+	config gatewayHealthCheckConfig
+	// probe is nil if the health checks are disabled.
+	probe   func(addr string) error
+	targets map[NetworkGateway]*gatewayHealth
+}
+
+type gatewayHealth struct {
+	healthy bool
+	// count is the number of consecutive probes contradicting the current health
+	count int
+	timer *time.Timer
+}
+
+func newNetworkGatewayHealthChecker() *networkGatewayHealthChecker {
+	return &networkGatewayHealthChecker{targets: map[NetworkGateway]*gatewayHealth{}}
+}
+
+// configure replaces the configuration of the health checks if it changed, and then forgets the health of the
+// gateways so that they are probed again with the new configuration.
+func (hc *networkGatewayHealthChecker) configure(cfg gatewayHealthCheckConfig) {
+	if hc == nil {
+		return
+	}
+	hc.Lock()
+	defer hc.Unlock()
+	if hc.config == cfg {
+		return
+	}
+	hc.config = cfg
+	for gw, health := range hc.targets {
+		health.timer.Stop()
+		delete(hc.targets, gw)
+	}
+	switch cfg.protocol {
+	case "":
+		hc.probe = nil
+	case "tcp":
+		hc.probe = tcpProbe(cfg.timeout)
+	case "https":
+		hc.probe = httpsProbe(cfg.path, cfg.timeout)
+	}
+}
+
+func tcpProbe(timeout time.Duration) func(addr string) error {
+	return func(addr string) error {
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+func httpsProbe(path string, timeout time.Duration) func(addr string) error {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// the gateways are probed by address, their certificates are not verified
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+	}
+	return func(addr string) error {
+		resp, err := client.Get("https://" + addr + path)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// healthyGateways starts probing the new gateways of the set, stops probing the gateways which are no longer in
+// the set, and removes the unhealthy gateways from the set. The gateways are healthy until proven otherwise, and the
+// gateways of a network are all kept when none of them is healthy, as a dead network is no worse off than one
+// reached through unhealthy gateways.
+func (hc *networkGatewayHealthChecker) healthyGateways(gatewaySet NetworkGatewaySet) {
+	if hc == nil {
+		return
+	}
+	hc.Lock()
+	defer hc.Unlock()
+	if hc.probe == nil {
+		return
+	}
+
+	for gw, health := range hc.targets {
+		if _, ok := gatewaySet[gw]; !ok {
+			health.timer.Stop()
+			delete(hc.targets, gw)
+		}
+	}
+
+	healthyNetworks := map[network.ID]bool{}
+	for gw := range gatewaySet {
+		health, ok := hc.targets[gw]
+		if !ok {
+			health = &gatewayHealth{healthy: true}
+			health.timer = time.AfterFunc(hc.config.interval, hc.probeAndNotify(gw, health))
+			hc.targets[gw] = health
+		}
+		if health.healthy {
+			healthyNetworks[gw.Network] = true
+		}
+	}
+
+	for gw := range gatewaySet {
+		if !hc.targets[gw].healthy && healthyNetworks[gw.Network] {
+			delete(gatewaySet, gw)
+		}
+	}
+}
+
+// probeAndNotify is triggered via time.AfterFunc and will recursively schedule itself that way until the gateway is
+// no longer probed. It is called with the lock held.
+func (hc *networkGatewayHealthChecker) probeAndNotify(gw NetworkGateway, health *gatewayHealth) func() {
+	port := int(gw.Port)
+	if hc.config.port != 0 {
+		port = hc.config.port
+	}
+	addr := net.JoinHostPort(gw.Addr, strconv.Itoa(port))
+	probe := hc.probe
+	return func() {
+		err := probe(addr)
+
+		hc.Lock()
+		if hc.targets[gw] != health {
+			// no longer probed
+			hc.Unlock()
+			return
+		}
+		changed := false
+		if (err == nil) == health.healthy {
+			health.count = 0
+		} else if health.count++; health.count >= hc.config.threshold {
+			health.healthy = !health.healthy
+			health.count = 0
+			changed = true
+		}
+		healthy := health.healthy
+		health.timer = time.AfterFunc(hc.config.interval, hc.probeAndNotify(gw, health))
+		hc.Unlock()
+
+		if changed {
+			if healthy {
+				log.Infof("network gateways: gateway %s of network %s is healthy again", addr, gw.Network)
+			} else {
+				log.Warnf("network gateways: gateway %s of network %s is unhealthy: %v", addr, gw.Network, err)
+			}
+			hc.NotifyGatewayHandlers()
+		}
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/miekg/dns"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry/memory"
 	"istio.io/istio/pilot/pkg/util/sets"
@@ -50,7 +51,7 @@ func TestGatewayHostnames(t *testing.T) {
 	})
 
 	meshNetworks := mesh.NewFixedNetworksWatcher(nil)
-	xdsUpdater := &xds.FakeXdsUpdater{Events: make(chan xds.FakeXdsEvent, 10)}
+	xdsUpdater := &xds.FakeXdsUpdater{Events: make(chan xds.FakeXdsEvent, 10), Timeout: model.MinGatewayTTL + 5*time.Second}
 	env := &model.Environment{NetworksWatcher: meshNetworks, ServiceDiscovery: memory.NewServiceDiscovery()}
 	if err := env.InitNetworksManager(xdsUpdater); err != nil {
 		t.Fatal(err)
@@ -65,7 +66,7 @@ func TestGatewayHostnames(t *testing.T) {
 				Port: 15443,
 			}}},
 		}})
-		xdsUpdater.WaitDurationOrFail(t, model.MinGatewayTTL+5*time.Second, "xds")
+		xdsUpdater.ExpectPushFor(t, model.NetworksTrigger)
 		gws := env.NetworkManager.AllGateways()
 		if !reflect.DeepEqual(gws, []model.NetworkGateway{{Network: "nw0", Addr: "10.0.0.0", Port: 15443}}) {
 			t.Fatalf("did not get expected gws: %v", gws)
@@ -76,7 +77,7 @@ func TestGatewayHostnames(t *testing.T) {
 			t.Skip()
 		}
 		// wait for TTL + 5 to get an XDS update
-		xdsUpdater.WaitDurationOrFail(t, model.MinGatewayTTL+5*time.Second, "xds")
+		xdsUpdater.ExpectPushFor(t, model.NetworksTrigger)
 		// after the update, we should see the next gateway (10.0.0.1)
 		gws := env.NetworkManager.AllGateways()
 		if !reflect.DeepEqual(gws, []model.NetworkGateway{{Network: "nw0", Addr: "10.0.0.1", Port: 15443}}) {
@@ -85,13 +86,238 @@ func TestGatewayHostnames(t *testing.T) {
 	})
 	t.Run("forget", func(t *testing.T) {
 		meshNetworks.SetNetworks(nil)
-		xdsUpdater.WaitDurationOrFail(t, 5*time.Second, "xds")
+		xdsUpdater.ExpectPushFor(t, model.NetworksTrigger)
 		if len(env.NetworkManager.AllGateways()) > 0 {
 			t.Fatalf("expected no gateways")
 		}
 	})
 }
 
+func TestGatewaySRV(t *testing.T) {
+	origMinGatewayTTL := model.MinGatewayTTL
+	model.MinGatewayTTL = 3 * time.Second
+	t.Cleanup(func() {
+		model.MinGatewayTTL = origMinGatewayTTL
+	})
+
+	gwHost := "test.gw.istio.io"
+	srvName := "_istio-gw._tcp.istio.io"
+	loopName := "_loop._tcp.istio.io"
+	dnsServer := newFakeDNSServer(":10054", 1, sets.NewSet(gwHost))
+	dnsServer.mu.Lock()
+	dnsServer.srv[dns.Fqdn(srvName)] = dns.SRV{Target: dns.Fqdn(gwHost), Port: 15443}
+	// targets are only resolved as addresses, so records pointing to SRV names resolve to nothing
+	dnsServer.srv[dns.Fqdn(loopName)] = dns.SRV{Target: dns.Fqdn(loopName), Port: 15443}
+	dnsServer.mu.Unlock()
+	model.NetworkGatewayTestDNSServers = []string{"localhost:10054"}
+	t.Cleanup(func() {
+		model.NetworkGatewayTestDNSServers = nil
+		if err := dnsServer.Shutdown(); err != nil {
+			t.Logf("failed shutting down fake dns server")
+		}
+	})
+
+	meshNetworks := mesh.NewFixedNetworksWatcher(nil)
+	xdsUpdater := &xds.FakeXdsUpdater{Events: make(chan xds.FakeXdsEvent, 10), Timeout: model.MinGatewayTTL + 5*time.Second}
+	env := &model.Environment{NetworksWatcher: meshNetworks, ServiceDiscovery: memory.NewServiceDiscovery()}
+	if err := env.InitNetworksManager(xdsUpdater); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("initial resolution", func(t *testing.T) {
+		meshNetworks.SetNetworks(&meshconfig.MeshNetworks{Networks: map[string]*meshconfig.Network{
+			"nw0": {Gateways: []*meshconfig.Network_IstioNetworkGateway{{
+				Gw: &meshconfig.Network_IstioNetworkGateway_Address{
+					Address: srvName,
+				},
+			}}},
+			"nw1": {Gateways: []*meshconfig.Network_IstioNetworkGateway{{
+				Gw: &meshconfig.Network_IstioNetworkGateway_Address{
+					Address: loopName,
+				},
+			}}},
+		}})
+		xdsUpdater.ExpectPushFor(t, model.NetworksTrigger)
+		gws := env.NetworkManager.AllGateways()
+		if !reflect.DeepEqual(gws, []model.NetworkGateway{{Network: "nw0", Addr: "10.0.0.0", Port: 15443}}) {
+			t.Fatalf("did not get expected gws: %v", gws)
+		}
+	})
+	t.Run("re-resolve after TTL", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip()
+		}
+		dnsServer.mu.Lock()
+		dnsServer.srv[dns.Fqdn(srvName)] = dns.SRV{Target: dns.Fqdn(gwHost), Port: 15444}
+		dnsServer.mu.Unlock()
+		// wait for TTL + 5 to get an XDS update
+		xdsUpdater.ExpectPushFor(t, model.NetworksTrigger)
+		// after the update, we should see the next gateway (10.0.0.1) on the new port
+		gws := env.NetworkManager.AllGateways()
+		if !reflect.DeepEqual(gws, []model.NetworkGateway{{Network: "nw0", Addr: "10.0.0.1", Port: 15444}}) {
+			t.Fatalf("did not get expected gws: %v", gws)
+		}
+	})
+	t.Run("forget", func(t *testing.T) {
+		meshNetworks.SetNetworks(nil)
+		xdsUpdater.ExpectPushFor(t, model.NetworksTrigger)
+		if len(env.NetworkManager.AllGateways()) > 0 {
+			t.Fatalf("expected no gateways")
+		}
+	})
+}
+
+func TestGatewayHostnamesLastKnownGood(t *testing.T) {
+	origMinGatewayTTL := model.MinGatewayTTL
+	origKeepLastKnownGood := features.NetworkGatewayDNSKeepLastKnownGood
+	origSearchDomains := features.NetworkGatewayDNSSearchDomains
+	model.MinGatewayTTL = 3 * time.Second
+	features.NetworkGatewayDNSKeepLastKnownGood = true
+	features.NetworkGatewayDNSSearchDomains = []string{"example.com", "istio.io"}
+	t.Cleanup(func() {
+		model.MinGatewayTTL = origMinGatewayTTL
+		features.NetworkGatewayDNSKeepLastKnownGood = origKeepLastKnownGood
+		features.NetworkGatewayDNSSearchDomains = origSearchDomains
+	})
+
+	dnsServer := newFakeDNSServer(":10055", 1, sets.NewSet("test.gw.istio.io"))
+	model.NetworkGatewayTestDNSServers = []string{"localhost:10055"}
+	t.Cleanup(func() {
+		model.NetworkGatewayTestDNSServers = nil
+		if err := dnsServer.Shutdown(); err != nil {
+			t.Logf("failed shutting down fake dns server")
+		}
+	})
+
+	meshNetworks := mesh.NewFixedNetworksWatcher(nil)
+	xdsUpdater := &xds.FakeXdsUpdater{Events: make(chan xds.FakeXdsEvent, 10), Timeout: model.MinGatewayTTL + 5*time.Second}
+	env := &model.Environment{NetworksWatcher: meshNetworks, ServiceDiscovery: memory.NewServiceDiscovery()}
+	if err := env.InitNetworksManager(xdsUpdater); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("resolve in search domains", func(t *testing.T) {
+		meshNetworks.SetNetworks(&meshconfig.MeshNetworks{Networks: map[string]*meshconfig.Network{
+			"nw0": {Gateways: []*meshconfig.Network_IstioNetworkGateway{{
+				Gw: &meshconfig.Network_IstioNetworkGateway_Address{
+					Address: "test.gw",
+				},
+				Port: 15443,
+			}}},
+		}})
+		xdsUpdater.ExpectPushFor(t, model.NetworksTrigger)
+		gws := env.NetworkManager.AllGateways()
+		if !reflect.DeepEqual(gws, []model.NetworkGateway{{Network: "nw0", Addr: "10.0.0.0", Port: 15443}}) {
+			t.Fatalf("did not get expected gws: %v", gws)
+		}
+	})
+	t.Run("keep last known good", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip()
+		}
+		dnsServer.mu.Lock()
+		dnsServer.fail = true
+		dnsServer.mu.Unlock()
+		// the failed re-resolution after TTL keeps the gateway
+		xdsUpdater.ExpectNoPushWithin(t, model.MinGatewayTTL+2*time.Second)
+		gws := env.NetworkManager.AllGateways()
+		if !reflect.DeepEqual(gws, []model.NetworkGateway{{Network: "nw0", Addr: "10.0.0.0", Port: 15443}}) {
+			t.Fatalf("did not get expected gws: %v", gws)
+		}
+
+		dnsServer.mu.Lock()
+		dnsServer.fail = false
+		dnsServer.mu.Unlock()
+		xdsUpdater.ExpectPushFor(t, model.NetworksTrigger)
+		gws = env.NetworkManager.AllGateways()
+		if !reflect.DeepEqual(gws, []model.NetworkGateway{{Network: "nw0", Addr: "10.0.0.1", Port: 15443}}) {
+			t.Fatalf("did not get expected gws: %v", gws)
+		}
+	})
+}
+
+func TestGatewayHealthCheck(t *testing.T) {
+	origHealthCheck := features.NetworkGatewayHealthCheck
+	origInterval := features.NetworkGatewayHealthCheckInterval
+	origThreshold := features.NetworkGatewayHealthCheckThreshold
+	features.NetworkGatewayHealthCheck = "tcp"
+	features.NetworkGatewayHealthCheckInterval = 100 * time.Millisecond
+	features.NetworkGatewayHealthCheckThreshold = 2
+	t.Cleanup(func() {
+		features.NetworkGatewayHealthCheck = origHealthCheck
+		features.NetworkGatewayHealthCheckInterval = origInterval
+		features.NetworkGatewayHealthCheckThreshold = origThreshold
+	})
+
+	listen := func(addr string) (net.Listener, uint32) {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = l.Close() })
+		return l, uint32(l.Addr().(*net.TCPAddr).Port)
+	}
+	_, healthyPort := listen("127.0.0.1:0")
+	deadListener, deadPort := listen("127.0.0.1:0")
+	_ = deadListener.Close()
+	deadNetworkListener, deadNetworkPort := listen("127.0.0.1:0")
+	_ = deadNetworkListener.Close()
+
+	meshNetworks := mesh.NewFixedNetworksWatcher(nil)
+	xdsUpdater := &xds.FakeXdsUpdater{Events: make(chan xds.FakeXdsEvent, 10), Timeout: 5 * time.Second}
+	env := &model.Environment{NetworksWatcher: meshNetworks, ServiceDiscovery: memory.NewServiceDiscovery()}
+	if err := env.InitNetworksManager(xdsUpdater); err != nil {
+		t.Fatal(err)
+	}
+
+	healthy := model.NetworkGateway{Network: "nw0", Addr: "127.0.0.1", Port: healthyPort}
+	dead := model.NetworkGateway{Network: "nw0", Addr: "127.0.0.1", Port: deadPort}
+	deadNetwork := model.NetworkGateway{Network: "nw1", Addr: "127.0.0.1", Port: deadNetworkPort}
+	expectGateways := func(t *testing.T, expected ...model.NetworkGateway) {
+		t.Helper()
+		got, want := model.NetworkGatewaySet{}, model.NetworkGatewaySet{}
+		for _, gw := range env.NetworkManager.AllGateways() {
+			got.Add(gw)
+		}
+		for _, gw := range expected {
+			want.Add(gw)
+		}
+		if !got.Equals(want) {
+			t.Fatalf("did not get expected gws: %v", got.ToArray())
+		}
+	}
+	gateway := func(gw model.NetworkGateway) *meshconfig.Network_IstioNetworkGateway {
+		return &meshconfig.Network_IstioNetworkGateway{
+			Gw:   &meshconfig.Network_IstioNetworkGateway_Address{Address: gw.Addr},
+			Port: gw.Port,
+		}
+	}
+
+	t.Run("healthy until probed", func(t *testing.T) {
+		meshNetworks.SetNetworks(&meshconfig.MeshNetworks{Networks: map[string]*meshconfig.Network{
+			"nw0": {Gateways: []*meshconfig.Network_IstioNetworkGateway{gateway(healthy), gateway(dead)}},
+			"nw1": {Gateways: []*meshconfig.Network_IstioNetworkGateway{gateway(deadNetwork)}},
+		}})
+		xdsUpdater.ExpectPushFor(t, model.NetworksTrigger)
+		expectGateways(t, healthy, dead, deadNetwork)
+	})
+	t.Run("exclude unhealthy", func(t *testing.T) {
+		// the gateway of nw1 is kept, as none of the gateways of the network is healthy
+		xdsUpdater.ExpectPushFor(t, model.NetworksTrigger)
+		expectGateways(t, healthy, deadNetwork)
+	})
+	t.Run("healthy again", func(t *testing.T) {
+		listen(net.JoinHostPort(dead.Addr, fmt.Sprint(dead.Port)))
+		xdsUpdater.ExpectPushFor(t, model.NetworksTrigger)
+		expectGateways(t, healthy, dead, deadNetwork)
+	})
+	t.Run("forget", func(t *testing.T) {
+		meshNetworks.SetNetworks(nil)
+		xdsUpdater.ExpectPushFor(t, model.NetworksTrigger)
+		expectGateways(t)
+	})
+}
+
 type fakeDNSServer struct {
 	*dns.Server
 	ttl uint32
@@ -99,6 +325,10 @@ type fakeDNSServer struct {
 	mu sync.Mutex
 	// map fqdn hostname -> query count
 	hosts map[string]int
+	// map fqdn SRV name -> SRV record
+	srv map[string]dns.SRV
+	// fail makes the server answer with SERVFAIL
+	fail bool
 }
 
 func newFakeDNSServer(addr string, ttl uint32, hosts sets.Set) *fakeDNSServer {
@@ -106,6 +336,7 @@ func newFakeDNSServer(addr string, ttl uint32, hosts sets.Set) *fakeDNSServer {
 		Server: &dns.Server{Addr: addr, Net: "udp"},
 		ttl:    ttl,
 		hosts:  make(map[string]int, len(hosts)),
+		srv:    map[string]dns.SRV{},
 	}
 	s.Handler = s
 
@@ -126,6 +357,13 @@ func (s *fakeDNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	defer s.mu.Unlock()
 
 	msg := (&dns.Msg{}).SetReply(r)
+	if s.fail {
+		msg.Rcode = dns.RcodeServerFailure
+		if err := w.WriteMsg(msg); err != nil {
+			scopes.Framework.Errorf("failed writing fake DNS response: %v", err)
+		}
+		return
+	}
 	switch r.Question[0].Qtype {
 	case dns.TypeA, dns.TypeANY:
 		domain := msg.Question[0].Name
@@ -137,8 +375,47 @@ func (s *fakeDNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 				A:   net.ParseIP(fmt.Sprintf("10.0.0.%d", c)),
 			})
 		}
+	case dns.TypeSRV:
+		domain := msg.Question[0].Name
+		if srv, ok := s.srv[domain]; ok {
+			srv.Hdr = dns.RR_Header{Name: domain, Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: s.ttl}
+			msg.Answer = append(msg.Answer, &srv)
+		}
 	}
 	if err := w.WriteMsg(msg); err != nil {
 		scopes.Framework.Errorf("failed writing fake DNS response: %v", err)
 	}
 }
+
+func TestParseGatewayWeights(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want map[string]uint32
+		err  bool
+	}{
+		{name: "empty", in: ""},
+		{name: "address", in: "1.1.1.1=2", want: map[string]uint32{"1.1.1.1": 2}},
+		{
+			name: "address and port",
+			in:   "1.1.1.1:15443=2, 2001:db8::1=3, [2001:db8::2]:15443=4",
+			want: map[string]uint32{"1.1.1.1:15443": 2, "2001:db8::1": 3, "[2001:db8::2]:15443": 4},
+		},
+		{name: "missing weight", in: "1.1.1.1", err: true},
+		{name: "missing address", in: "=2", err: true},
+		{name: "invalid weight", in: "1.1.1.1=heavy", err: true},
+		{name: "zero weight", in: "1.1.1.1=0", want: map[string]uint32{"1.1.1.1": 0}},
+		{name: "negative weight", in: "1.1.1.1=-1", err: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := model.ParseGatewayWeights(tt.in)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error %v", err, tt.err)
+			}
+			if !tt.err && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got weights %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
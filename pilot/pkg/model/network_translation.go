@@ -0,0 +1,109 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"istio.io/istio/pkg/network"
+)
+
+// AddressTranslation maps the pod CIDR of a network to the externally routable prefix of its 1:1 NAT.
+type AddressTranslation struct {
+	Network network.ID
+	// From is the CIDR of the pod IPs, which are not routable from other networks.
+	From *net.IPNet
+	// To is the externally routable prefix the pod IPs are mapped to. It has the same length as From.
+	To *net.IPNet
+}
+
+// Translate returns the externally routable address of the IP, or nil if the IP is not translated.
+func (t AddressTranslation) Translate(ip net.IP) net.IP {
+	if !t.From.Contains(ip) {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil && len(t.From.IP) == net.IPv4len {
+		ip = ip4
+	}
+	out := make(net.IP, len(ip))
+	for i := range ip {
+		out[i] = t.To.IP[i] | (ip[i] &^ t.From.Mask[i])
+	}
+	return out
+}
+
+// ParseAddressTranslations parses a comma separated list of address translation maps, in the form
+// <network>:<pod CIDR>=<external CIDR>.
+func ParseAddressTranslations(s string) ([]AddressTranslation, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var out []AddressTranslation
+	for _, entry := range strings.Split(s, ",") {
+		nw, cidrs := splitPair(entry, ":")
+		from, to := splitPair(cidrs, "=")
+		if nw == "" || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid address translation %q: expected <network>:<pod CIDR>=<external CIDR>", entry)
+		}
+		_, fromNet, err := net.ParseCIDR(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address translation %q: %v", entry, err)
+		}
+		_, toNet, err := net.ParseCIDR(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address translation %q: %v", entry, err)
+		}
+		fromOnes, fromBits := fromNet.Mask.Size()
+		toOnes, toBits := toNet.Mask.Size()
+		if fromOnes != toOnes || fromBits != toBits {
+			return nil, fmt.Errorf("invalid address translation %q: the CIDRs must have the same family and length", entry)
+		}
+		out = append(out, AddressTranslation{Network: network.ID(nw), From: fromNet, To: toNet})
+	}
+	return out, nil
+}
+
+func splitPair(s, sep string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(s), sep, 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+}
+
+// TranslateAddress returns the address proxies of other networks reach the endpoint address of the network at, if
+// the network translates it.
+func (mgr *NetworkManager) TranslateAddress(nw network.ID, address string) (string, bool) {
+	if mgr == nil {
+		return "", false
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return "", false
+	}
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+	for _, t := range mgr.translations {
+		if t.Network != nw {
+			continue
+		}
+		if translated := t.Translate(ip); translated != nil {
+			return translated.String(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,75 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/network"
+)
+
+func TestParseAddressTranslations(t *testing.T) {
+	cases := []struct {
+		name  string
+		in    string
+		err   bool
+		count int
+	}{
+		{name: "empty", in: "", count: 0},
+		{name: "single", in: "network2:10.16.0.0/16=172.20.0.0/16", count: 1},
+		{name: "multiple", in: "network2:10.16.0.0/16=172.20.0.0/16, network3:fd00::/64=2001:db8::/64", count: 2},
+		{name: "missing network", in: "10.16.0.0/16=172.20.0.0/16", err: true},
+		{name: "missing external prefix", in: "network2:10.16.0.0/16", err: true},
+		{name: "invalid CIDR", in: "network2:10.16.0.0=172.20.0.0/16", err: true},
+		{name: "different lengths", in: "network2:10.16.0.0/16=172.20.0.0/24", err: true},
+		{name: "different families", in: "network2:10.16.0.0/16=2001:db8::/16", err: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAddressTranslations(tt.in)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error %v", err, tt.err)
+			}
+			if len(got) != tt.count {
+				t.Fatalf("got %d translations, want %d", len(got), tt.count)
+			}
+		})
+	}
+}
+
+func TestTranslateAddress(t *testing.T) {
+	translations, err := ParseAddressTranslations("network2:10.16.0.0/16=172.20.0.0/16,network3:fd00::/64=2001:db8::/64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &NetworkManager{translations: translations}
+	cases := []struct {
+		network string
+		address string
+		want    string
+	}{
+		{network: "network2", address: "10.16.3.4", want: "172.20.3.4"},
+		{network: "network2", address: "10.17.3.4", want: ""},
+		{network: "network1", address: "10.16.3.4", want: ""},
+		{network: "network3", address: "fd00::1:2", want: "2001:db8::1:2"},
+		{network: "network2", address: "not-an-ip", want: ""},
+	}
+	for _, tt := range cases {
+		got, ok := mgr.TranslateAddress(network.ID(tt.network), tt.address)
+		if ok != (tt.want != "") || got != tt.want {
+			t.Errorf("TranslateAddress(%s, %s) = %q, %v, want %q", tt.network, tt.address, got, ok, tt.want)
+		}
+	}
+}
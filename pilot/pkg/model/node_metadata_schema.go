@@ -0,0 +1,229 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"istio.io/istio/pilot/pkg/util/sets"
+)
+
+// NodeMetadataSchemaVersion is the version of the node metadata schema sent by the istio-agent in
+// METADATA_SCHEMA_VERSION. Agents which predate the schema do not send a version.
+const NodeMetadataSchemaVersion = "1"
+
+// legacyNodeMetadataKeys maps keys sent by older agents to the keys that replaced them.
+var legacyNodeMetadataKeys = map[string]string{
+	"CONFIG_NAMESPACE": "NAMESPACE",
+	"POD_NAME":         "NAME",
+}
+
+// knownNodeMetadataKeys is the set of the keys of BootstrapNodeMetadata, including the embedded NodeMetadata.
+var (
+	knownNodeMetadataKeys    = nodeMetadataKeys(reflect.TypeOf(BootstrapNodeMetadata{}))
+	knownNodeMetadataKeyList = knownNodeMetadataKeys.SortedList()
+)
+
+func nodeMetadataKeys(t reflect.Type) sets.Set {
+	keys := sets.NewSet()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			keys = keys.Union(nodeMetadataKeys(f.Type))
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		keys.Insert(name)
+	}
+	return keys
+}
+
+// NodeMetadataValidation is the result of the validation of node metadata against the schema.
+type NodeMetadataValidation struct {
+	// SchemaVersion is the schema version sent by the agent, empty for older agents.
+	SchemaVersion string
+	// Unknown is the sorted list of keys which are not part of the schema. Such keys are allowed,
+	// as arbitrary ISTIO_META_* variables are passed through by the agent.
+	Unknown []string
+	// Errors are the keys which are likely typos of known keys, or have invalid values.
+	Errors []error
+}
+
+// Legacy returns true if the metadata was sent by an agent which predates the schema.
+func (v NodeMetadataValidation) Legacy() bool {
+	return v.SchemaVersion == ""
+}
+
+// Err returns an error joining all the validation errors, or nil if there are none.
+func (v NodeMetadataValidation) Err() error {
+	if len(v.Errors) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(v.Errors))
+	for _, err := range v.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Errorf("invalid node metadata (schema version %q): %s", v.SchemaVersion, strings.Join(msgs, "; "))
+}
+
+// ValidateNodeMetadata validates the metadata against the node metadata schema. Typed values are already
+// checked when parsing, so this reports unknown keys, keys which are close to known keys and values which
+// parse but are not valid. Keys sent by older agents are translated by the compatibility shim first.
+func ValidateNodeMetadata(meta *NodeMetadata) NodeMetadataValidation {
+	v := NodeMetadataValidation{}
+	if meta == nil {
+		return v
+	}
+	applyLegacyNodeMetadata(meta)
+	v.SchemaVersion = meta.SchemaVersion
+	if v.SchemaVersion != "" && v.SchemaVersion != NodeMetadataSchemaVersion {
+		if _, err := strconv.Atoi(v.SchemaVersion); err != nil {
+			v.Errors = append(v.Errors, fmt.Errorf("METADATA_SCHEMA_VERSION %q is not a number", v.SchemaVersion))
+		}
+	}
+
+	keys := make([]string, 0, len(meta.Raw))
+	for k := range meta.Raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if knownNodeMetadataKeys.Contains(k) {
+			continue
+		}
+		if _, f := legacyNodeMetadataKeys[k]; f {
+			continue
+		}
+		if suggestion := closestNodeMetadataKey(k); suggestion != "" {
+			v.Errors = append(v.Errors, fmt.Errorf("unknown key %q (did you mean %q?)", k, suggestion))
+			continue
+		}
+		v.Unknown = append(v.Unknown, k)
+	}
+
+	v.Errors = append(v.Errors, validateNodeMetadataValues(meta)...)
+	return v
+}
+
+// applyLegacyNodeMetadata populates the fields replaced by legacy keys, if they are not set.
+func applyLegacyNodeMetadata(meta *NodeMetadata) {
+	if meta.Namespace == "" {
+		if ns, ok := meta.Raw["CONFIG_NAMESPACE"].(string); ok {
+			meta.Namespace = ns
+		}
+	}
+}
+
+func validateNodeMetadataValues(meta *NodeMetadata) []error {
+	var errs []error
+	switch meta.InterceptionMode {
+	case "", InterceptionNone, InterceptionTproxy, InterceptionRedirect:
+	default:
+		errs = append(errs, fmt.Errorf("INTERCEPTION_MODE %q is not one of %s, %s or %s",
+			meta.InterceptionMode, InterceptionRedirect, InterceptionTproxy, InterceptionNone))
+	}
+	for _, p := range []struct {
+		key, value string
+	}{
+		{"HTTP_PROXY_PORT", meta.HTTPProxyPort},
+		{"STS_PORT", meta.StsPort},
+	} {
+		if p.value == "" {
+			continue
+		}
+		if port, err := strconv.Atoi(p.value); err != nil || port <= 0 || port > 65535 {
+			errs = append(errs, fmt.Errorf("%s %q is not a valid port", p.key, p.value))
+		}
+	}
+	for _, p := range []struct {
+		key   string
+		value int
+	}{
+		{"ENVOY_STATUS_PORT", meta.EnvoyStatusPort},
+		{"ENVOY_PROMETHEUS_PORT", meta.EnvoyPrometheusPort},
+	} {
+		if p.value < 0 || p.value > 65535 {
+			errs = append(errs, fmt.Errorf("%s %d is not a valid port", p.key, p.value))
+		}
+	}
+	if meta.IdleTimeout != "" {
+		if _, err := time.ParseDuration(meta.IdleTimeout); err != nil {
+			errs = append(errs, fmt.Errorf("IDLE_TIMEOUT %q is not a valid duration: %v", meta.IdleTimeout, err))
+		}
+	}
+	return errs
+}
+
+// closestNodeMetadataKey returns the known key which the given key is likely a typo of, or an
+// empty string if there is none.
+func closestNodeMetadataKey(key string) string {
+	upper := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+	// The agent strips the prefix, so it is commonly duplicated in the variable name.
+	upper = strings.TrimPrefix(upper, "ISTIO_META_")
+	best, bestDistance := "", 3
+	for _, known := range knownNodeMetadataKeyList {
+		if upper == known {
+			return known
+		}
+		// Short keys are too close to each other to guess.
+		if len(known) < 6 {
+			continue
+		}
+		if d := editDistance(upper, known); d < bestDistance {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	m := a
+	if b < m {
+		m = b
+	}
+	if c < m {
+		m = c
+	}
+	return m
+}
@@ -0,0 +1,102 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model_test
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestValidateNodeMetadata(t *testing.T) {
+	cases := []struct {
+		name     string
+		metadata map[string]interface{}
+		legacy   bool
+		unknown  []string
+		errors   int
+	}{
+		{
+			name:     "valid",
+			metadata: map[string]interface{}{"METADATA_SCHEMA_VERSION": "1", "NAMESPACE": "ns", "DNS_CAPTURE": "true"},
+		},
+		{
+			name:     "arbitrary keys are allowed",
+			metadata: map[string]interface{}{"METADATA_SCHEMA_VERSION": "1", "foo": "bar"},
+			unknown:  []string{"foo"},
+		},
+		{
+			name:     "typo",
+			metadata: map[string]interface{}{"METADATA_SCHEMA_VERSION": "1", "DNS_CAPTUER": "true"},
+			errors:   1,
+		},
+		{
+			name:     "wrong case",
+			metadata: map[string]interface{}{"METADATA_SCHEMA_VERSION": "1", "dns_capture": "true"},
+			errors:   1,
+		},
+		{
+			name:     "duplicated prefix",
+			metadata: map[string]interface{}{"METADATA_SCHEMA_VERSION": "1", "ISTIO_META_DNS_CAPTURE": "true"},
+			errors:   1,
+		},
+		{
+			name: "invalid values",
+			metadata: map[string]interface{}{
+				"METADATA_SCHEMA_VERSION": "1",
+				"INTERCEPTION_MODE":       "IPVS",
+				"HTTP_PROXY_PORT":         "http",
+				"IDLE_TIMEOUT":            "1 hour",
+			},
+			errors: 3,
+		},
+		{
+			name:     "legacy agent",
+			metadata: map[string]interface{}{"CONFIG_NAMESPACE": "ns", "DNS_CAPTUER": "true"},
+			legacy:   true,
+			errors:   1,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := mapToStruct(tt.metadata)
+			if err != nil {
+				t.Fatalf("failed to setup metadata: %v", err)
+			}
+			parsed, err := model.ParseMetadata(meta)
+			if err != nil {
+				t.Fatalf("failed to parse metadata: %v", err)
+			}
+			v := model.ValidateNodeMetadata(parsed)
+			if v.Legacy() != tt.legacy {
+				t.Errorf("got legacy %v, want %v", v.Legacy(), tt.legacy)
+			}
+			if !reflect.DeepEqual(v.Unknown, tt.unknown) {
+				t.Errorf("got unknown keys %v, want %v", v.Unknown, tt.unknown)
+			}
+			if len(v.Errors) != tt.errors {
+				t.Errorf("got errors %v, want %d", v.Errors, tt.errors)
+			}
+			if (v.Err() != nil) != (tt.errors > 0) {
+				t.Errorf("got error %v, want %d errors", v.Err(), tt.errors)
+			}
+			if tt.legacy && parsed.Namespace != "ns" {
+				t.Errorf("got namespace %q from CONFIG_NAMESPACE, want ns", parsed.Namespace)
+			}
+		})
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/xds"
 	"istio.io/istio/pkg/util/gogoprotomarshal"
 	istiolog "istio.io/pkg/log"
 )
@@ -139,9 +140,37 @@ func mergeWithPrecedence(pcs ...*meshconfig.ProxyConfig) *meshconfig.ProxyConfig
 			merged.Concurrency = pcs[i].GetConcurrency()
 		}
 	}
+	mergeBootstrapCustomizations(merged, pcs...)
 	return merged
 }
 
+// mergeBootstrapCustomizations merges the bootstrap customizations of the ProxyConfigs by section, with earlier
+// items having the highest priority, rather than only keeping the one with the highest priority. Invalid
+// customizations are ignored.
+func mergeBootstrapCustomizations(merged *meshconfig.ProxyConfig, pcs ...*meshconfig.ProxyConfig) {
+	if _, f := merged.GetProxyMetadata()[xds.BootstrapCustomizationKey]; !f {
+		return
+	}
+	customization := xds.BootstrapCustomization{}
+	for i := len(pcs) - 1; i >= 0; i-- {
+		v, f := pcs[i].GetProxyMetadata()[xds.BootstrapCustomizationKey]
+		if !f {
+			continue
+		}
+		c, err := xds.ParseBootstrapCustomization(v)
+		if err != nil {
+			pclog.Warnf("ignoring the bootstrap customization of a ProxyConfig: %v", err)
+			continue
+		}
+		customization = customization.Merge(c)
+	}
+	if len(customization) == 0 {
+		delete(merged.ProxyMetadata, xds.BootstrapCustomizationKey)
+		return
+	}
+	merged.ProxyMetadata[xds.BootstrapCustomizationKey] = customization.String()
+}
+
 func toMeshConfigProxyConfig(pc *v1beta1.ProxyConfig) *meshconfig.ProxyConfig {
 	mcpc := &meshconfig.ProxyConfig{}
 	if pc.Concurrency != nil {
@@ -15,6 +15,7 @@
 package model
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -29,12 +30,41 @@ import (
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/xds"
 )
 
 var now = time.Now()
 
 const istioRootNamespace = "istio-system"
 
+const (
+	zipkinTracing = `{"http":{"name":"envoy.tracers.zipkin","typed_config":{` +
+		`"@type":"type.googleapis.com/envoy.config.trace.v3.ZipkinConfig",` +
+		`"collector_cluster":"zipkin","collector_endpoint":"/api/v2/spans"}}}`
+	datadogTracing = `{"http":{"name":"envoy.tracers.datadog","typed_config":{` +
+		`"@type":"type.googleapis.com/envoy.config.trace.v3.DatadogConfig",` +
+		`"collector_cluster":"datadog","service_name":"app"}}}`
+	statsdSinks = `[{"name":"envoy.stat_sinks.statsd","typed_config":{` +
+		`"@type":"type.googleapis.com/envoy.config.metrics.v3.StatsdSink","tcp_cluster_name":"statsd"}}]`
+)
+
+// bootstrapCustomization returns the proxy metadata with a bootstrap customization of the sections.
+func bootstrapCustomization(sections map[string]string) map[string]string {
+	c := xds.BootstrapCustomization{}
+	for section, value := range sections {
+		c[section] = json.RawMessage(value)
+	}
+	return map[string]string{xds.BootstrapCustomizationKey: c.String()}
+}
+
+// bootstrapCustomizationAnnotation returns a proxy.istio.io/config annotation with a bootstrap customization.
+func bootstrapCustomizationAnnotation(customization string) string {
+	js, _ := json.Marshal(map[string]interface{}{
+		"proxyMetadata": map[string]string{xds.BootstrapCustomizationKey: customization},
+	})
+	return string(js)
+}
+
 func TestConvertToMeshConfigProxyConfig(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -373,6 +403,43 @@ func TestEffectiveProxyConfig(t *testing.T) {
 				"A": "1",
 			}},
 		},
+		{
+			name: "bootstrap customizations merge by section",
+			configs: []config.Config{
+				newProxyConfig("ns", "test-ns",
+					&v1beta1.ProxyConfig{
+						EnvironmentVariables: bootstrapCustomization(map[string]string{"stats_sinks": statsdSinks}),
+					}),
+			},
+			defaultConfig: &meshconfig.ProxyConfig{
+				ProxyMetadata: bootstrapCustomization(map[string]string{"tracing": zipkinTracing}),
+			},
+			proxy: newMeta("test-ns", nil, map[string]string{
+				annotation.ProxyConfig.Name: bootstrapCustomizationAnnotation(`{"tracing":` + datadogTracing + `}`),
+			}),
+			expected: &meshconfig.ProxyConfig{
+				ProxyMetadata: bootstrapCustomization(map[string]string{
+					"stats_sinks": statsdSinks,
+					"tracing":     datadogTracing,
+				}),
+			},
+		},
+		{
+			name: "invalid bootstrap customizations are ignored",
+			configs: []config.Config{
+				newProxyConfig("ns", "test-ns",
+					&v1beta1.ProxyConfig{
+						EnvironmentVariables: map[string]string{xds.BootstrapCustomizationKey: `{"admin":{}}`},
+					}),
+			},
+			defaultConfig: &meshconfig.ProxyConfig{
+				ProxyMetadata: bootstrapCustomization(map[string]string{"tracing": zipkinTracing}),
+			},
+			proxy: newMeta("test-ns", nil, nil),
+			expected: &meshconfig.ProxyConfig{
+				ProxyMetadata: bootstrapCustomization(map[string]string{"tracing": zipkinTracing}),
+			},
+		},
 		{
 			name:  "no configured CR or default config",
 			proxy: newMeta("ns", nil, nil),
@@ -30,6 +30,7 @@ import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model/credentials"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/cluster"
@@ -141,7 +142,29 @@ type sidecarIndex struct {
 	// Unlike computedSidecarsByNamespace, this is *always* the output of DefaultSidecarScopeForNamespace.
 	// These are lazy-loaded. Access protected by defaultSidecarMu
 	gatewayDefaultSidecarsByNamespace map[string]*SidecarScope
-	defaultSidecarMu                  *sync.Mutex
+	// watchedNamespacesSidecars contains the scopes of the proxies watching namespaces through their metadata,
+	// by config namespace and watched namespaces. These are lazy-loaded. Access protected by defaultSidecarMu
+	watchedNamespacesSidecars map[string]*SidecarScope
+	// scopesByHash caches the computed sidecar scopes by the hash of their Sidecar and config namespace, so that
+	// the scopes of the unchanged Sidecars are not computed again. Access protected by defaultSidecarMu
+	scopesByHash map[uint64]*SidecarScope
+	// previousScopesByHash are the scopes cached by the previous push, if the configs they depend on are unchanged.
+	previousScopesByHash map[uint64]*SidecarScope
+	defaultSidecarMu     *sync.Mutex
+}
+
+// cachedScopes returns a copy of the sidecar scopes cached by the push.
+func (si *sidecarIndex) cachedScopes() map[uint64]*SidecarScope {
+	si.defaultSidecarMu.Lock()
+	defer si.defaultSidecarMu.Unlock()
+	if len(si.scopesByHash) == 0 {
+		return nil
+	}
+	out := make(map[uint64]*SidecarScope, len(si.scopesByHash))
+	for k, v := range si.scopesByHash {
+		out[k] = v
+	}
+	return out
 }
 
 func newSidecarIndex() sidecarIndex {
@@ -149,6 +172,8 @@ func newSidecarIndex() sidecarIndex {
 		sidecarsByNamespace:               map[string][]*SidecarScope{},
 		computedSidecarsByNamespace:       map[string]*SidecarScope{},
 		gatewayDefaultSidecarsByNamespace: map[string]*SidecarScope{},
+		watchedNamespacesSidecars:         map[string]*SidecarScope{},
+		scopesByHash:                      map[uint64]*SidecarScope{},
 		defaultSidecarMu:                  &sync.Mutex{},
 	}
 }
@@ -159,6 +184,12 @@ type gatewayIndex struct {
 	namespace map[string][]config.Config
 	// all contains all gateways.
 	all []config.Config
+	// rejectingMissingCredential contains the gateways with the REJECT_SERVER missing credential policy, by the
+	// name of the secrets they reference.
+	rejectingMissingCredential map[string][]ConfigKey
+	// keepingLastGoodCredential contains the names of the secrets referenced by the gateways with the
+	// KEEP_LAST_GOOD missing credential policy.
+	keepingLastGoodCredential sets.Set
 }
 
 func newGatewayIndex() gatewayIndex {
@@ -234,12 +265,19 @@ type PushContext struct {
 	// LedgerVersion is the version of the configuration ledger
 	LedgerVersion string
 
+	// ConfigSnapshotVersion is the version of the config snapshot this push context was initialized from, if
+	// PILOT_ENABLE_CONFIG_SNAPSHOT_READS is enabled.
+	ConfigSnapshotVersion string
+
 	// JwtKeyResolver holds a reference to the JWT key resolver instance.
 	JwtKeyResolver *JwksResolver
 
 	// GatewayAPIController holds a reference to the gateway API controller.
 	GatewayAPIController GatewayController
 
+	// gatewayCredentials reports whether the credentials referenced by gateways exist.
+	gatewayCredentials GatewayCredentials
+
 	// cache gateways addresses for each network
 	// this is mainly used for kubernetes multi-cluster scenario
 	networkMgr *NetworkManager
@@ -257,6 +295,8 @@ type processedDestRules struct {
 	exportTo map[host.Name]map[visibility.Instance]bool
 	// Map of dest rule host and the merged destination rules for that host
 	destRule map[host.Name]*config.Config
+	// Map of dest rule host and the keys of the destination rules merged for that host
+	from map[host.Name][]ConfigKey
 }
 
 // XDSUpdater is used for direct updates of the xDS model and incremental push.
@@ -360,6 +400,86 @@ type PushRequest struct {
 	// There should only be multiple reasons if the push request is the result of two distinct triggers, rather than
 	// classifying a single trigger as having multiple reasons.
 	Reason []TriggerReason
+
+	// Priority requests skip debouncing and are pushed to proxies ahead of other pending pushes. This is
+	// reserved for urgent changes, such as emergency lockdown authorization policies.
+	Priority bool
+
+	// EndpointsUpdated describes which endpoints of the services in ConfigsUpdated changed, allowing incremental
+	// pushes to skip the clusters of these services that include none of them. A service in ConfigsUpdated
+	// but not in EndpointsUpdated is treated as if all of its endpoints changed.
+	EndpointsUpdated map[ConfigKey]*EndpointChanges
+}
+
+// EndpointChanges describes the endpoints of a service that were added, removed or changed.
+type EndpointChanges struct {
+	// Ports are the names of the service ports of the changed endpoints.
+	Ports sets.Set
+	// Labels are the labels of the changed endpoints, both before and after the change.
+	Labels []labels.Instance
+}
+
+// Merge returns the changes of both c and other, without mutating either.
+func (c *EndpointChanges) Merge(other *EndpointChanges) *EndpointChanges {
+	if c == nil {
+		return other
+	}
+	if other == nil {
+		return c
+	}
+	merged := &EndpointChanges{
+		Ports:  c.Ports.Union(other.Ports),
+		Labels: make([]labels.Instance, 0, len(c.Labels)+len(other.Labels)),
+	}
+	merged.Labels = append(merged.Labels, c.Labels...)
+	merged.Labels = append(merged.Labels, other.Labels...)
+	return merged
+}
+
+// Affects returns whether the changes concern the endpoints of the port and subset labels of a cluster.
+func (c *EndpointChanges) Affects(port string, subset labels.Collection) bool {
+	if !c.Ports.Contains(port) {
+		return false
+	}
+	if len(subset) == 0 {
+		return true
+	}
+	for _, l := range c.Labels {
+		if subset.HasSubsetOf(l) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeEndpointsUpdated merges the endpoint changes of two requests. It must be called before their
+// ConfigsUpdated are merged.
+func mergeEndpointsUpdated(pr, other *PushRequest) map[ConfigKey]*EndpointChanges {
+	if len(pr.EndpointsUpdated) == 0 && len(other.EndpointsUpdated) == 0 {
+		return nil
+	}
+	if len(pr.ConfigsUpdated) == 0 || len(other.ConfigsUpdated) == 0 {
+		return nil
+	}
+	merged := map[ConfigKey]*EndpointChanges{}
+	unknown := map[ConfigKey]struct{}{}
+	for _, r := range []*PushRequest{pr, other} {
+		for conf := range r.ConfigsUpdated {
+			changes, f := r.EndpointsUpdated[conf]
+			if !f {
+				unknown[conf] = struct{}{}
+				continue
+			}
+			merged[conf] = merged[conf].Merge(changes)
+		}
+	}
+	for conf := range unknown {
+		delete(merged, conf)
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
 }
 
 type TriggerReason string
@@ -412,9 +532,13 @@ func (pr *PushRequest) Merge(other *PushRequest) *PushRequest {
 	// If either is full we need a full push
 	pr.Full = pr.Full || other.Full
 
+	pr.Priority = pr.Priority || other.Priority
+
 	// The other push context is presumed to be later and more up to date
 	pr.Push = other.Push
 
+	pr.EndpointsUpdated = mergeEndpointsUpdated(pr, other)
+
 	// Do not merge when any one is empty
 	if len(pr.ConfigsUpdated) == 0 || len(other.ConfigsUpdated) == 0 {
 		pr.ConfigsUpdated = nil
@@ -451,11 +575,15 @@ func (pr *PushRequest) CopyMerge(other *PushRequest) *PushRequest {
 		// If either is full we need a full push
 		Full: pr.Full || other.Full,
 
+		Priority: pr.Priority || other.Priority,
+
 		// The other push context is presumed to be later and more up to date
 		Push: other.Push,
 
 		// Merge the two reasons. Note that we shouldn't deduplicate here, or we would under count
 		Reason: reason,
+
+		EndpointsUpdated: mergeEndpointsUpdated(pr, other),
 	}
 
 	// Do not merge when any one is empty
@@ -591,6 +719,15 @@ var (
 		"Duplicate subsets across destination rules for same host",
 	)
 
+	sidecarScopeCacheReads = monitoring.NewSum(
+		"pilot_sidecar_scope_cache_reads",
+		"Total number of sidecar scope computations, by whether the scope was reused from the cache.",
+		monitoring.WithLabels(typeTag),
+	)
+
+	sidecarScopeCacheHits   = sidecarScopeCacheReads.With(typeTag.Value("hit"))
+	sidecarScopeCacheMisses = sidecarScopeCacheReads.With(typeTag.Value("miss"))
+
 	// totalVirtualServices tracks the total number of virtual service
 	totalVirtualServices = monitoring.NewGauge(
 		"pilot_virt_services",
@@ -624,7 +761,7 @@ func init() {
 	for _, m := range metrics {
 		monitoring.MustRegister(m)
 	}
-	monitoring.MustRegister(totalVirtualServices)
+	monitoring.MustRegister(totalVirtualServices, sidecarScopeCacheReads)
 }
 
 // NewPushContext creates a new PushContext structure to track push status.
@@ -906,15 +1043,30 @@ func (ps *PushContext) getSidecarScope(proxy *Proxy, workloadLabels labels.Colle
 
 	// We didn't have a Sidecar in the namespace. This means we should use the default - either an implicit
 	// default selecting everything, or pulling from the root namespace.
+	var watched []string
+	if proxy.Type == SidecarProxy {
+		watched = watchedNamespaces(proxy)
+	}
 	ps.sidecarIndex.defaultSidecarMu.Lock()
 	defer ps.sidecarIndex.defaultSidecarMu.Unlock()
+	if len(watched) > 0 {
+		// Proxies watching the same namespaces share the same scope.
+		key := proxy.ConfigNamespace + "/" + strings.Join(watched, ",")
+		if sc, f := ps.sidecarIndex.watchedNamespacesSidecars[key]; f {
+			return sc
+		}
+		sidecarConfig := watchedNamespacesSidecarConfig(ps.sidecarIndex.rootConfig, proxy.ConfigNamespace, watched)
+		computed := ps.convertToSidecarScope(sidecarConfig, proxy.ConfigNamespace)
+		ps.sidecarIndex.watchedNamespacesSidecars[key] = computed
+		return computed
+	}
 	if proxy.Type == Router {
 		sc, f := ps.sidecarIndex.gatewayDefaultSidecarsByNamespace[proxy.ConfigNamespace]
 		if f {
 			// We have already computed the scope for this namespace, just fetch it
 			return sc
 		}
-		computed := DefaultSidecarScopeForNamespace(ps, proxy.ConfigNamespace)
+		computed := ps.convertToSidecarScope(nil, proxy.ConfigNamespace)
 		ps.sidecarIndex.gatewayDefaultSidecarsByNamespace[proxy.ConfigNamespace] = computed
 		return computed
 	}
@@ -926,9 +1078,9 @@ func (ps *PushContext) getSidecarScope(proxy *Proxy, workloadLabels labels.Colle
 	// We need to compute this namespace
 	var computed *SidecarScope
 	if ps.sidecarIndex.rootConfig != nil {
-		computed = ConvertToSidecarScope(ps, ps.sidecarIndex.rootConfig, proxy.ConfigNamespace)
+		computed = ps.convertToSidecarScope(ps.sidecarIndex.rootConfig, proxy.ConfigNamespace)
 	} else {
-		computed = DefaultSidecarScopeForNamespace(ps, proxy.ConfigNamespace)
+		computed = ps.convertToSidecarScope(nil, proxy.ConfigNamespace)
 		// Even though we are a sidecar, we can store this as a gateway one since it could be used by a gateway
 		ps.sidecarIndex.gatewayDefaultSidecarsByNamespace[proxy.ConfigNamespace] = computed
 	}
@@ -936,6 +1088,33 @@ func (ps *PushContext) getSidecarScope(proxy *Proxy, workloadLabels labels.Colle
 	return computed
 }
 
+// convertToSidecarScope returns the sidecar scope of the Sidecar config, or the default scope if it is nil, in the
+// config namespace. The scope is reused if it was computed in this push or, for the same Sidecar, in the previous
+// one. It must be called while initializing the push context or holding defaultSidecarMu.
+func (ps *PushContext) convertToSidecarScope(sidecarConfig *config.Config, configNamespace string) *SidecarScope {
+	key, ok := sidecarScopeHash(sidecarConfig, configNamespace)
+	if !ok {
+		sidecarScopeCacheMisses.Increment()
+		return ConvertToSidecarScope(ps, sidecarConfig, configNamespace)
+	}
+	if sc, f := ps.sidecarIndex.scopesByHash[key]; f {
+		sidecarScopeCacheHits.Increment()
+		return sc
+	}
+	if sc, f := ps.sidecarIndex.previousScopesByHash[key]; f {
+		sidecarScopeCacheHits.Increment()
+		// The scope is unchanged, but is now part of this push.
+		reused := *sc
+		reused.Version = ps.PushVersion
+		ps.sidecarIndex.scopesByHash[key] = &reused
+		return &reused
+	}
+	sidecarScopeCacheMisses.Increment()
+	sc := ConvertToSidecarScope(ps, sidecarConfig, configNamespace)
+	ps.sidecarIndex.scopesByHash[key] = sc
+	return sc
+}
+
 // destinationRule returns a destination rule for a service name in a given namespace.
 func (ps *PushContext) destinationRule(proxyNameSpace string, service *Service) *config.Config {
 	if service == nil {
@@ -1018,6 +1197,28 @@ func (ps *PushContext) destinationRule(proxyNameSpace string, service *Service)
 	return nil
 }
 
+// destinationRuleSources returns the keys of the destination rules merged into a destination rule returned by
+// destinationRule. The merged rule keeps the name of the first one, but changes to any of them change it.
+func (ps *PushContext) destinationRuleSources(dr *config.Config) []ConfigKey {
+	h := host.Name(dr.Spec.(*networking.DestinationRule).Host)
+	candidates := []*processedDestRules{
+		ps.destinationRuleIndex.namespaceLocal[dr.Namespace],
+		ps.destinationRuleIndex.exportedByNamespace[dr.Namespace],
+	}
+	if dr.Namespace == ps.Mesh.RootNamespace {
+		candidates = append(candidates, ps.destinationRuleIndex.rootNamespaceLocal)
+	}
+	for _, p := range candidates {
+		if p == nil {
+			continue
+		}
+		if merged := p.destRule[h]; merged != nil && merged.Name == dr.Name && len(p.from[h]) > 0 {
+			return p.from[h]
+		}
+	}
+	return []ConfigKey{{Kind: gvk.DestinationRule, Name: dr.Name, Namespace: dr.Namespace}}
+}
+
 func (ps *PushContext) getExportedDestinationRuleFromNamespace(owningNamespace string, hostname host.Name, clientNamespace string) *config.Config {
 	if ps.destinationRuleIndex.exportedByNamespace[owningNamespace] != nil {
 		if specificHostname, ok := MostSpecificHostMatch(hostname,
@@ -1069,6 +1270,12 @@ func (ps *PushContext) InitContext(env *Environment, oldPushContext *PushContext
 
 	ps.Mesh = env.Mesh()
 	ps.LedgerVersion = env.Version()
+	if features.EnableConfigSnapshotReads {
+		var err error
+		if env, ps.ConfigSnapshotVersion, err = configSnapshot(env); err != nil {
+			return err
+		}
+	}
 
 	// Must be initialized first
 	// as initServiceRegistry/VirtualServices/Destrules
@@ -1087,6 +1294,7 @@ func (ps *PushContext) InitContext(env *Environment, oldPushContext *PushContext
 	}
 
 	ps.networkMgr = env.NetworkManager
+	ps.gatewayCredentials = env.GatewayCredentials
 
 	ps.clusterLocalHosts = env.ClusterLocal().GetClusterLocalHosts()
 
@@ -1094,6 +1302,26 @@ func (ps *PushContext) InitContext(env *Environment, oldPushContext *PushContext
 	return nil
 }
 
+// configSnapshot returns a copy of the environment reading a snapshot of its configs, and the version of the
+// snapshot. The environment is returned as is if its config store cannot take snapshots.
+func configSnapshot(env *Environment) (*Environment, string, error) {
+	s, ok := env.IstioConfigStore.(ConfigSnapshotter)
+	if !ok {
+		return env, "", nil
+	}
+	store, version, err := s.Snapshot()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to snapshot the configs: %v", err)
+	}
+	if store == nil {
+		return env, "", nil
+	}
+	snapshot := *env
+	snapshot.IstioConfigStore = MakeIstioStore(store)
+	log.Debugf("initializing push context from config snapshot %q", version)
+	return &snapshot, version, nil
+}
+
 func (ps *PushContext) createNewContext(env *Environment) error {
 	if err := ps.initServiceRegistry(env); err != nil {
 		return err
@@ -1215,7 +1443,11 @@ func (ps *PushContext) updateContext(
 	}
 
 	if destinationRulesChanged {
-		if err := ps.initDestinationRules(env); err != nil {
+		if namespaces, ok := ps.destinationRuleNamespaces(pushReq.ConfigsUpdated); ok {
+			if err := ps.updateDestinationRules(env, oldPushContext.destinationRuleIndex, namespaces); err != nil {
+				return err
+			}
+		} else if err := ps.initDestinationRules(env); err != nil {
 			return err
 		}
 	} else {
@@ -1280,6 +1512,11 @@ func (ps *PushContext) updateContext(
 	}
 
 	// Must be initialized in the end
+	// The scopes of the unchanged Sidecars can be reused, unless the services, virtual services, or destination
+	// rules they select changed.
+	if !servicesChanged && !virtualServicesChanged && !destinationRulesChanged {
+		ps.sidecarIndex.previousScopesByHash = oldPushContext.sidecarIndex.cachedScopes()
+	}
 	// Sidecars need to be updated if services, virtual services, destination rules, or the sidecar configs change
 	if servicesChanged || virtualServicesChanged || destinationRulesChanged || sidecarsChanged {
 		if err := ps.initSidecarScopes(env); err != nil {
@@ -1576,7 +1813,7 @@ func (ps *PushContext) initSidecarScopes(env *Environment) error {
 	ps.sidecarIndex.sidecarsByNamespace = make(map[string][]*SidecarScope, sidecarNum)
 	for i, sidecarConfig := range sidecarConfigs {
 		ps.sidecarIndex.sidecarsByNamespace[sidecarConfig.Namespace] = append(ps.sidecarIndex.sidecarsByNamespace[sidecarConfig.Namespace],
-			ConvertToSidecarScope(ps, &sidecarConfig, sidecarConfig.Namespace))
+			ps.convertToSidecarScope(&sidecarConfigs[i], sidecarConfig.Namespace))
 		if rootNSConfig == nil && sidecarConfig.Namespace == ps.Mesh.RootNamespace &&
 			sidecarConfig.Spec.(*networking.Sidecar).WorkloadSelector == nil {
 			rootNSConfig = &sidecarConfigs[i]
@@ -1610,7 +1847,70 @@ func newProcessedDestRules() *processedDestRules {
 		hosts:    make([]host.Name, 0),
 		exportTo: map[host.Name]map[visibility.Instance]bool{},
 		destRule: map[host.Name]*config.Config{},
+		from:     map[host.Name][]ConfigKey{},
+	}
+}
+
+// destinationRuleNamespaces returns the namespaces of the updated destination rules, if only the index of their
+// namespaces needs to be rebuilt.
+func (ps *PushContext) destinationRuleNamespaces(updated map[ConfigKey]struct{}) (sets.Set, bool) {
+	// The inherited mesh and namespace rules are merged in the rules of every namespace.
+	if !features.EnablePartialDestinationRuleIndex || features.EnableDestinationRuleInheritance {
+		return nil, false
+	}
+	namespaces := sets.NewSet()
+	for key := range updated {
+		if key.Kind != gvk.DestinationRule {
+			continue
+		}
+		if key.Namespace == "" {
+			return nil, false
+		}
+		namespaces.Insert(key.Namespace)
 	}
+	return namespaces, len(namespaces) > 0
+}
+
+// updateDestinationRules rebuilds the index of the destination rules of the namespaces, and reuses the index of
+// the other namespaces from the previous push.
+func (ps *PushContext) updateDestinationRules(env *Environment, previous destinationRuleIndex, namespaces sets.Set) error {
+	var destRules []config.Config
+	for ns := range namespaces {
+		configs, err := env.List(gvk.DestinationRule, ns)
+		if err != nil {
+			return err
+		}
+		// values returned from ConfigStore.List are immutable.
+		for _, c := range configs {
+			destRules = append(destRules, c.DeepCopy())
+		}
+	}
+	updated := ps.buildDestinationRuleIndex(destRules)
+
+	index := newDestinationRuleIndex()
+	for ns, rules := range previous.namespaceLocal {
+		if !namespaces.Contains(ns) {
+			index.namespaceLocal[ns] = rules
+		}
+	}
+	for ns, rules := range updated.namespaceLocal {
+		index.namespaceLocal[ns] = rules
+	}
+	for ns, rules := range previous.exportedByNamespace {
+		if !namespaces.Contains(ns) {
+			index.exportedByNamespace[ns] = rules
+		}
+	}
+	for ns, rules := range updated.exportedByNamespace {
+		index.exportedByNamespace[ns] = rules
+	}
+	index.rootNamespaceLocal = previous.rootNamespaceLocal
+	if namespaces.Contains(ps.Mesh.RootNamespace) {
+		index.rootNamespaceLocal = updated.rootNamespaceLocal
+	}
+	ps.destinationRuleIndex = index
+	log.Debugf("rebuilt the destination rule index of namespaces %v", namespaces.SortedList())
+	return nil
 }
 
 // SetDestinationRules is updates internal structures using a set of configs.
@@ -1618,6 +1918,11 @@ func newProcessedDestRules() *processedDestRules {
 // This also allows tests to inject a config without having the mock.
 // This will not work properly for Sidecars, which will precompute their destination rules on init
 func (ps *PushContext) SetDestinationRules(configs []config.Config) {
+	ps.destinationRuleIndex = ps.buildDestinationRuleIndex(configs)
+}
+
+// buildDestinationRuleIndex returns the index of the destination rules.
+func (ps *PushContext) buildDestinationRuleIndex(configs []config.Config) destinationRuleIndex {
 	// Sort by time first. So if two destination rule have top level traffic policies
 	// we take the first one.
 	sortConfigByCreationTime(configs)
@@ -1708,10 +2013,12 @@ func (ps *PushContext) SetDestinationRules(configs []config.Config) {
 	}
 	sort.Sort(host.Names(rootNamespaceLocalDestRules.hosts))
 
-	ps.destinationRuleIndex.namespaceLocal = namespaceLocalDestRules
-	ps.destinationRuleIndex.exportedByNamespace = exportedDestRulesByNamespace
-	ps.destinationRuleIndex.rootNamespaceLocal = rootNamespaceLocalDestRules
-	ps.destinationRuleIndex.inheritedByNamespace = inheritedConfigs
+	return destinationRuleIndex{
+		namespaceLocal:       namespaceLocalDestRules,
+		exportedByNamespace:  exportedDestRulesByNamespace,
+		rootNamespaceLocal:   rootNamespaceLocalDestRules,
+		inheritedByNamespace: inheritedConfigs,
+	}
 }
 
 func (ps *PushContext) initAuthorizationPolicies(env *Environment) error {
@@ -1931,9 +2238,55 @@ func (ps *PushContext) initGateways(env *Environment) error {
 	} else {
 		ps.gatewayIndex.all = gatewayConfigs
 	}
+
+	ps.gatewayIndex.rejectingMissingCredential = make(map[string][]ConfigKey)
+	ps.gatewayIndex.keepingLastGoodCredential = sets.NewSet()
+	for _, gatewayConfig := range gatewayConfigs {
+		policy := GetMissingCredentialPolicy(gatewayConfig)
+		if policy != MissingCredentialRejectServer && policy != MissingCredentialKeepLastGood {
+			continue
+		}
+		key := ConfigKey{Kind: gvk.Gateway, Name: gatewayConfig.Name, Namespace: gatewayConfig.Namespace}
+		for _, s := range gatewayConfig.Spec.(*networking.Gateway).Servers {
+			cn := s.GetTls().GetCredentialName()
+			if cn == "" {
+				continue
+			}
+			sr, err := credentials.ParseResourceName(credentials.ToResourceName(cn), gatewayConfig.Namespace, "", "")
+			if err != nil {
+				continue
+			}
+			if policy == MissingCredentialKeepLastGood {
+				ps.gatewayIndex.keepingLastGoodCredential.Insert(sr.Name)
+				continue
+			}
+			ps.gatewayIndex.rejectingMissingCredential[sr.Name] = append(ps.gatewayIndex.rejectingMissingCredential[sr.Name], key)
+		}
+	}
 	return nil
 }
 
+// GatewaysRejectingMissingCredential returns the gateways with the REJECT_SERVER missing credential policy referencing
+// a secret with the given name. Their servers are added or removed as the secret is created or deleted.
+func (ps *PushContext) GatewaysRejectingMissingCredential(secretName string) []ConfigKey {
+	return ps.gatewayIndex.rejectingMissingCredential[secretName]
+}
+
+// GatewayKeepsLastGoodCredential returns true if a gateway with the KEEP_LAST_GOOD missing credential policy
+// references a secret with the given name.
+func (ps *PushContext) GatewayKeepsLastGoodCredential(secretName string) bool {
+	return ps.gatewayIndex.keepingLastGoodCredential.Contains(secretName)
+}
+
+// credentialExists returns true if the credential with the given SDS resource name can be served to the proxy.
+// The credentials are assumed to exist when they can't be checked.
+func (ps *PushContext) credentialExists(proxy *Proxy, resourceName string) bool {
+	if ps == nil || ps.gatewayCredentials == nil || proxy.VerifiedIdentity == nil {
+		return true
+	}
+	return ps.gatewayCredentials.CredentialExists(proxy, resourceName)
+}
+
 // InternalGatewayServiceAnnotation represents the hostname of the service a gateway will use. This is
 // only used internally to transfer information from the Kubernetes Gateway API to the Istio Gateway API
 // which does not have a field to represent this.
@@ -0,0 +1,304 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"reflect"
+
+	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config"
+	"istio.io/pkg/monitoring"
+)
+
+// Names of the PushContext indexes reported by SizeEstimate.
+const (
+	ServicesIndexName              = "services"
+	VirtualServicesIndexName       = "virtualServices"
+	DestinationRulesIndexName      = "destinationRules"
+	SidecarScopesIndexName         = "sidecarScopes"
+	AuthorizationPoliciesIndexName = "authorizationPolicies"
+)
+
+const (
+	// referenceSize is the memory charged for each reference held by an index: a pointer, or a map entry.
+	referenceSize = 16
+	// stringHeaderSize is the size of a string header, charged for the strings shared with other objects.
+	stringHeaderSize = 16
+)
+
+var (
+	serviceSize             = int64(reflect.TypeOf(Service{}).Size())
+	portSize                = int64(reflect.TypeOf(Port{}).Size())
+	configSize              = int64(reflect.TypeOf(config.Config{}).Size())
+	sidecarScopeSize        = int64(reflect.TypeOf(SidecarScope{}).Size())
+	egressListenerSize      = int64(reflect.TypeOf(IstioEgressListenerWrapper{}).Size())
+	authorizationPolicySize = int64(reflect.TypeOf(AuthorizationPolicy{}).Size())
+)
+
+var (
+	indexTag = monitoring.MustCreateLabel("index")
+
+	pushContextSize = monitoring.NewGauge(
+		"pilot_push_context_size_bytes",
+		"Estimated memory retained by the indexes of the current push context, in bytes.",
+		monitoring.WithLabels(indexTag),
+	)
+
+	pushContextEntries = monitoring.NewGauge(
+		"pilot_push_context_entries",
+		"Number of entries in the indexes of the current push context.",
+		monitoring.WithLabels(indexTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(pushContextSize, pushContextEntries)
+}
+
+// IndexSize is the estimated size of an index of the PushContext.
+type IndexSize struct {
+	// Entries is the number of services, configs or scopes in the index.
+	Entries int `json:"entries"`
+	// Bytes is the estimated memory retained by the index.
+	Bytes int64 `json:"bytes"`
+}
+
+// SizeEstimate estimates the memory retained by the main indexes of the push context, by index name.
+// Each index is charged for the objects it owns, estimated from their strings and the serialized size of their
+// specs, and for a fixed cost per reference to objects owned by other indexes: the sidecar scopes, for instance,
+// are only charged for the references to the services they select. The estimate only reads the indexes, which
+// are immutable once the push context is initialized, except for the lazily computed sidecar scopes.
+func (ps *PushContext) SizeEstimate() map[string]IndexSize {
+	out := map[string]IndexSize{}
+	out[ServicesIndexName] = ps.servicesSize()
+	out[VirtualServicesIndexName] = ps.virtualServicesSize()
+	out[DestinationRulesIndexName] = ps.destinationRulesSize()
+	out[SidecarScopesIndexName] = ps.sidecarScopesSize()
+	out[AuthorizationPoliciesIndexName] = ps.authorizationPoliciesSize()
+	return out
+}
+
+// RecordSizeMetrics records the estimated size of the indexes of the push context.
+func (ps *PushContext) RecordSizeMetrics() {
+	for index, size := range ps.SizeEstimate() {
+		pushContextSize.With(indexTag.Value(index)).Record(float64(size.Bytes))
+		pushContextEntries.With(indexTag.Value(index)).Record(float64(size.Entries))
+	}
+}
+
+func stringMapSize(m map[string]string) int64 {
+	n := int64(len(m)) * referenceSize
+	for k, v := range m {
+		n += int64(len(k) + len(v))
+	}
+	return n
+}
+
+// sizer is implemented by the generated protobuf messages of the Istio API.
+type sizer interface {
+	Size() int
+}
+
+// protoSize returns the serialized size of a message, as an estimate of the memory it uses.
+func protoSize(m interface{}) int64 {
+	// The generated Size methods handle nil messages.
+	if s, ok := m.(sizer); ok {
+		return int64(s.Size())
+	}
+	return 0
+}
+
+// estimateService estimates the memory used by a service. The addresses of the service per cluster are not
+// counted, as reading them would take the lock of the service.
+func estimateService(svc *Service) int64 {
+	n := serviceSize + int64(len(svc.Hostname)+len(svc.DefaultAddress))
+	n += int64(len(svc.Attributes.Name) + len(svc.Attributes.Namespace))
+	n += stringMapSize(svc.Attributes.Labels) + stringMapSize(svc.Attributes.LabelSelectors)
+	n += int64(len(svc.Attributes.ExportTo)) * referenceSize
+	for _, port := range svc.Ports {
+		n += referenceSize + portSize + int64(len(port.Name))
+	}
+	return n
+}
+
+// estimateConfig estimates the memory used by a config.
+func estimateConfig(c *config.Config) int64 {
+	n := configSize + int64(len(c.Name)+len(c.Namespace)+len(c.Domain)+len(c.ResourceVersion))
+	n += stringMapSize(c.Labels) + stringMapSize(c.Annotations)
+	return n + protoSize(c.Spec)
+}
+
+func (ps *PushContext) servicesSize() IndexSize {
+	si := &ps.ServiceIndex
+	out := IndexSize{}
+	for _, byNamespace := range si.HostnameAndNamespace {
+		for namespace, svc := range byNamespace {
+			out.Entries++
+			out.Bytes += estimateService(svc) + referenceSize + int64(len(namespace))
+		}
+	}
+	// The other views of the index reference the same services.
+	out.Bytes += int64(len(si.public)) * referenceSize
+	for _, svcs := range si.privateByNamespace {
+		out.Bytes += int64(len(svcs)) * referenceSize
+	}
+	for _, svcs := range si.exportedToNamespace {
+		out.Bytes += int64(len(svcs)) * referenceSize
+	}
+	for _, byPort := range si.instancesByPort {
+		for _, instances := range byPort {
+			out.Bytes += int64(len(instances)) * referenceSize
+		}
+	}
+	return out
+}
+
+func (ps *PushContext) virtualServicesSize() IndexSize {
+	seen := sets.NewSet()
+	out := IndexSize{}
+	add := func(configs []config.Config) {
+		for i := range configs {
+			c := &configs[i]
+			key := c.Namespace + "/" + c.Name
+			if seen.Contains(key) {
+				// Copies of a config share its spec.
+				out.Bytes += configSize
+				continue
+			}
+			seen.Insert(key)
+			out.Bytes += estimateConfig(c)
+		}
+	}
+	vsi := &ps.virtualServiceIndex
+	for _, configs := range vsi.publicByGateway {
+		add(configs)
+	}
+	for _, byGateway := range vsi.privateByNamespaceAndGateway {
+		for _, configs := range byGateway {
+			add(configs)
+		}
+	}
+	for _, byGateway := range vsi.exportedToNamespaceByGateway {
+		for _, configs := range byGateway {
+			add(configs)
+		}
+	}
+	out.Entries = len(seen)
+	return out
+}
+
+// destinationRulesSize estimates the size of the merged destination rules, that is of the hosts with destination
+// rules in each namespace.
+func (ps *PushContext) destinationRulesSize() IndexSize {
+	seen := map[*config.Config]struct{}{}
+	keys := sets.NewSet()
+	out := IndexSize{}
+	add := func(namespace string, rules *processedDestRules) {
+		if rules == nil {
+			return
+		}
+		for hostname, rule := range rules.destRule {
+			keys.Insert(namespace + "/" + string(hostname))
+			out.Bytes += referenceSize + int64(len(hostname))
+			if _, f := seen[rule]; f {
+				continue
+			}
+			seen[rule] = struct{}{}
+			out.Bytes += estimateConfig(rule)
+		}
+		out.Bytes += int64(len(rules.hosts)+len(rules.hostsMap)+len(rules.exportTo)) * referenceSize
+	}
+	dri := &ps.destinationRuleIndex
+	for namespace, rules := range dri.namespaceLocal {
+		add(namespace, rules)
+	}
+	for namespace, rules := range dri.exportedByNamespace {
+		add(namespace, rules)
+	}
+	add(ps.Mesh.GetRootNamespace(), dri.rootNamespaceLocal)
+	out.Entries = len(keys)
+	return out
+}
+
+// sidecarScopes returns the distinct sidecar scopes of the push context. defaultSidecarMu is only held to copy
+// the lazily computed scopes.
+func (ps *PushContext) sidecarScopes() []*SidecarScope {
+	si := &ps.sidecarIndex
+	scopes := map[*SidecarScope]struct{}{}
+	for _, s := range si.sidecarsByNamespace {
+		for _, scope := range s {
+			scopes[scope] = struct{}{}
+		}
+	}
+	si.defaultSidecarMu.Lock()
+	for _, scope := range si.computedSidecarsByNamespace {
+		scopes[scope] = struct{}{}
+	}
+	for _, scope := range si.gatewayDefaultSidecarsByNamespace {
+		scopes[scope] = struct{}{}
+	}
+	for _, scope := range si.watchedNamespacesSidecars {
+		scopes[scope] = struct{}{}
+	}
+	for _, scope := range si.scopesByHash {
+		scopes[scope] = struct{}{}
+	}
+	si.defaultSidecarMu.Unlock()
+	out := make([]*SidecarScope, 0, len(scopes))
+	for scope := range scopes {
+		out = append(out, scope)
+	}
+	return out
+}
+
+// estimateSidecarScope estimates the memory used by a sidecar scope, without the services and destination rules
+// it references.
+func estimateSidecarScope(scope *SidecarScope) int64 {
+	n := sidecarScopeSize + int64(len(scope.Name)+len(scope.Namespace)+len(scope.Version))
+	n += protoSize(scope.Sidecar) + protoSize(scope.OutboundTrafficPolicy)
+	n += int64(len(scope.services)) * referenceSize
+	n += int64(len(scope.servicesByHostname)) * (referenceSize + stringHeaderSize)
+	n += int64(len(scope.destinationRules)) * (referenceSize + stringHeaderSize)
+	for _, listener := range scope.EgressListeners {
+		n += referenceSize + egressListenerSize + int64(len(listener.services))*referenceSize
+		n += int64(len(listener.virtualServices)) * configSize
+	}
+	return n
+}
+
+func (ps *PushContext) sidecarScopesSize() IndexSize {
+	scopes := ps.sidecarScopes()
+	out := IndexSize{Entries: len(scopes)}
+	for _, scope := range scopes {
+		out.Bytes += estimateSidecarScope(scope)
+	}
+	return out
+}
+
+func (ps *PushContext) authorizationPoliciesSize() IndexSize {
+	out := IndexSize{}
+	if ps.AuthzPolicies == nil {
+		return out
+	}
+	for namespace, policies := range ps.AuthzPolicies.NamespaceToPolicies {
+		out.Bytes += referenceSize + int64(len(namespace))
+		for _, policy := range policies {
+			out.Entries++
+			out.Bytes += authorizationPolicySize + int64(len(policy.Name)+len(policy.Namespace))
+			out.Bytes += stringMapSize(policy.Annotations) + protoSize(policy.Spec)
+		}
+	}
+	return out
+}
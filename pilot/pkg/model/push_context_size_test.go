@@ -0,0 +1,110 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"sync"
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	securityBeta "istio.io/api/security/v1beta1"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+func TestPushContextSizeEstimate(t *testing.T) {
+	configStore := NewFakeStore()
+	for _, cfg := range []config.Config{
+		{
+			Meta: config.Meta{Name: "vs", Namespace: "ns1", GroupVersionKind: gvk.VirtualService},
+			Spec: &networking.VirtualService{Hosts: []string{"svc1.ns1.svc.cluster.local"}},
+		},
+		{
+			Meta: config.Meta{Name: "dr", Namespace: "ns1", GroupVersionKind: gvk.DestinationRule},
+			Spec: &networking.DestinationRule{Host: "svc1.ns1.svc.cluster.local"},
+		},
+		{
+			Meta: config.Meta{Name: "sidecar", Namespace: "ns1", GroupVersionKind: gvk.Sidecar},
+			Spec: &networking.Sidecar{Egress: []*networking.IstioEgressListener{{Hosts: []string{"*/*"}}}},
+		},
+		{
+			Meta: config.Meta{Name: "authz", Namespace: "ns1", GroupVersionKind: gvk.AuthorizationPolicy},
+			Spec: &securityBeta.AuthorizationPolicy{},
+		},
+	} {
+		if _, err := configStore.Create(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env := &Environment{
+		IstioConfigStore: &istioConfigStore{ConfigStore: configStore},
+		ServiceDiscovery: &localServiceDiscovery{
+			services: []*Service{
+				{Hostname: "svc1.ns1.svc.cluster.local", Ports: allPorts, Attributes: ServiceAttributes{Namespace: "ns1"}},
+				{Hostname: "svc2.ns2.svc.cluster.local", Ports: allPorts, Attributes: ServiceAttributes{Namespace: "ns2"}},
+			},
+		},
+	}
+	m := mesh.DefaultMeshConfig()
+	env.Watcher = mesh.NewFixedWatcher(&m)
+	env.Init()
+	ps := NewPushContext()
+	if err := ps.InitContext(env, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The lazily computed default sidecar scopes are estimated safely while they are computed.
+	var wg sync.WaitGroup
+	for _, ns := range []string{"ns2", "ns3", "ns4"} {
+		wg.Add(1)
+		go func(ns string) {
+			defer wg.Done()
+			ps.getSidecarScope(&Proxy{Type: SidecarProxy, ConfigNamespace: ns, Metadata: &NodeMetadata{}}, nil)
+		}(ns)
+	}
+	ps.SizeEstimate()
+	wg.Wait()
+
+	sizes := ps.SizeEstimate()
+	wantEntries := map[string]int{
+		ServicesIndexName:              2,
+		VirtualServicesIndexName:       1,
+		DestinationRulesIndexName:      1,
+		SidecarScopesIndexName:         4,
+		AuthorizationPoliciesIndexName: 1,
+	}
+	for index, want := range wantEntries {
+		got, f := sizes[index]
+		if !f {
+			t.Fatalf("index %s not estimated", index)
+		}
+		if got.Entries != want {
+			t.Errorf("index %s: got %d entries, want %d", index, got.Entries, want)
+		}
+		if got.Bytes <= 0 {
+			t.Errorf("index %s: got %d bytes, want a positive estimate", index, got.Bytes)
+		}
+	}
+
+	// The sidecar scopes are only charged for the references to the services they select.
+	var services int64
+	for _, svc := range env.Services() {
+		services += estimateService(svc)
+	}
+	if sizes[SidecarScopesIndexName].Bytes >= services*int64(wantEntries[SidecarScopesIndexName]) {
+		t.Errorf("sidecar scopes charged for the services they select: %d bytes", sizes[SidecarScopesIndexName].Bytes)
+	}
+}
@@ -35,6 +35,7 @@ import (
 	securityBeta "istio.io/api/security/v1beta1"
 	selectorpb "istio.io/api/type/v1beta1"
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
@@ -110,6 +111,46 @@ func TestMergeUpdateRequest(t *testing.T) {
 			}: {}}},
 			PushRequest{Full: true, ConfigsUpdated: nil, Reason: nil},
 		},
+		{
+			"merge endpoint changes",
+			&PushRequest{
+				ConfigsUpdated: map[ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "a"}: {}, {Kind: gvk.ServiceEntry, Name: "b"}: {}},
+				EndpointsUpdated: map[ConfigKey]*EndpointChanges{
+					{Kind: gvk.ServiceEntry, Name: "a"}: {Ports: sets.NewSet("http"), Labels: []labels.Instance{{"app": "v1"}}},
+					{Kind: gvk.ServiceEntry, Name: "b"}: {Ports: sets.NewSet("http"), Labels: []labels.Instance{{"app": "v1"}}},
+				},
+			},
+			&PushRequest{
+				ConfigsUpdated: map[ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "a"}: {}, {Kind: gvk.ServiceEntry, Name: "c"}: {}},
+				EndpointsUpdated: map[ConfigKey]*EndpointChanges{
+					{Kind: gvk.ServiceEntry, Name: "a"}: {Ports: sets.NewSet("grpc"), Labels: []labels.Instance{{"app": "v2"}}},
+				},
+			},
+			PushRequest{
+				ConfigsUpdated: map[ConfigKey]struct{}{
+					{Kind: gvk.ServiceEntry, Name: "a"}: {},
+					{Kind: gvk.ServiceEntry, Name: "b"}: {},
+					{Kind: gvk.ServiceEntry, Name: "c"}: {},
+				},
+				// The endpoint changes of c are unknown, so all of its endpoints are considered changed.
+				EndpointsUpdated: map[ConfigKey]*EndpointChanges{
+					{Kind: gvk.ServiceEntry, Name: "a"}: {
+						Ports:  sets.NewSet("http", "grpc"),
+						Labels: []labels.Instance{{"app": "v1"}, {"app": "v2"}},
+					},
+					{Kind: gvk.ServiceEntry, Name: "b"}: {Ports: sets.NewSet("http"), Labels: []labels.Instance{{"app": "v1"}}},
+				},
+			},
+		},
+		{
+			"skip endpoint changes merge: unknown changes",
+			&PushRequest{
+				ConfigsUpdated:   map[ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "a"}: {}},
+				EndpointsUpdated: map[ConfigKey]*EndpointChanges{{Kind: gvk.ServiceEntry, Name: "a"}: {Ports: sets.NewSet("http")}},
+			},
+			&PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "a"}: {}}},
+			PushRequest{ConfigsUpdated: map[ConfigKey]struct{}{{Kind: gvk.ServiceEntry, Name: "a"}: {}}},
+		},
 	}
 
 	for _, tt := range cases {
@@ -1031,6 +1072,34 @@ func TestSidecarScope(t *testing.T) {
 			sidecar:    "istio-system/default-sidecar",
 			describe:   "gateway sidecar scope",
 		},
+		{
+			proxy: &Proxy{Type: SidecarProxy, ConfigNamespace: "nosidecar",
+				Metadata: &NodeMetadata{WatchedNamespaces: StringList{"default", "."}}},
+			collection: labels.Collection{map[string]string{"app": "bar"}},
+			sidecar:    "nosidecar/global",
+			describe:   "watched namespaces narrow the root sidecar",
+		},
+		{
+			proxy: &Proxy{Type: SidecarProxy, ConfigNamespace: "default",
+				Metadata: &NodeMetadata{WatchedNamespaces: StringList{"default"}}},
+			collection: labels.Collection{map[string]string{"app": "foo"}},
+			sidecar:    "default/foo",
+			describe:   "local sidecar takes precedence over watched namespaces",
+		},
+		{
+			proxy: &Proxy{Type: SidecarProxy, ConfigNamespace: "nosidecar",
+				Metadata: &NodeMetadata{WatchedNamespaces: StringList{"", "invalid/ns"}}},
+			collection: labels.Collection{map[string]string{"app": "bar"}},
+			sidecar:    "nosidecar/global",
+			describe:   "invalid watched namespaces are ignored",
+		},
+		{
+			proxy: &Proxy{Type: Router, ConfigNamespace: "istio-system",
+				Metadata: &NodeMetadata{WatchedNamespaces: StringList{"default"}}},
+			collection: labels.Collection{map[string]string{"app": "istio-gateway"}},
+			sidecar:    "istio-system/default-sidecar",
+			describe:   "gateways ignore watched namespaces",
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.describe, func(t *testing.T) {
@@ -1042,6 +1111,185 @@ func TestSidecarScope(t *testing.T) {
 	}
 }
 
+func TestSidecarScopeCache(t *testing.T) {
+	env := &Environment{}
+	sidecar := func(namespace, host string) config.Config {
+		return config.Config{
+			Meta: config.Meta{
+				Name:             "sidecar",
+				Namespace:        namespace,
+				GroupVersionKind: gvk.Sidecar,
+			},
+			Spec: &networking.Sidecar{
+				Egress: []*networking.IstioEgressListener{{Hosts: []string{host}}},
+			},
+		}
+	}
+	setSidecars := func(sidecars ...config.Config) {
+		configStore := NewFakeStore()
+		for _, sc := range sidecars {
+			_, _ = configStore.Create(sc)
+		}
+		env.IstioConfigStore = &istioConfigStore{ConfigStore: configStore}
+	}
+	setSidecars(sidecar("ns1", "*/*"), sidecar("ns2", "*/*"))
+	env.ServiceDiscovery = &localServiceDiscovery{
+		services: []*Service{{
+			Hostname:   "svc1",
+			Ports:      allPorts,
+			Attributes: ServiceAttributes{Namespace: "ns1"},
+		}},
+	}
+	m := mesh.DefaultMeshConfig()
+	env.Watcher = mesh.NewFixedWatcher(&m)
+	env.Init()
+
+	push := func(old *PushContext, version string, updated ConfigKey) *PushContext {
+		t.Helper()
+		ps := NewPushContext()
+		ps.PushVersion = version
+		var req *PushRequest
+		if old != nil {
+			req = &PushRequest{Full: true, ConfigsUpdated: map[ConfigKey]struct{}{updated: {}}}
+		}
+		if err := ps.InitContext(env, old, req); err != nil {
+			t.Fatal(err)
+		}
+		return ps
+	}
+	scopes := func(ps *PushContext) map[string]*SidecarScope {
+		return map[string]*SidecarScope{
+			"ns1": ps.getSidecarScope(&Proxy{Type: SidecarProxy, ConfigNamespace: "ns1"}, nil),
+			"ns2": ps.getSidecarScope(&Proxy{Type: SidecarProxy, ConfigNamespace: "ns2"}, nil),
+			"ns3": ps.getSidecarScope(&Proxy{Type: SidecarProxy, ConfigNamespace: "ns3"}, nil),
+		}
+	}
+	expectReused := func(old, updated map[string]*SidecarScope, version string, reused ...string) {
+		t.Helper()
+		for ns, sc := range updated {
+			if sc.Version != version {
+				t.Errorf("expected the scope of %s to have version %s, got %s", ns, version, sc.Version)
+			}
+			want := false
+			for _, r := range reused {
+				want = want || r == ns
+			}
+			// The reused scopes share the computed listeners.
+			if got := sc.EgressListeners[0] == old[ns].EgressListeners[0]; got != want {
+				t.Errorf("expected the scope of %s reused %v, got %v", ns, want, got)
+			}
+		}
+	}
+
+	ps1 := push(nil, "1", ConfigKey{})
+	scopes1 := scopes(ps1)
+
+	// Only the scope of the updated Sidecar is computed again.
+	setSidecars(sidecar("ns1", "*/*"), sidecar("ns2", "ns1/*"))
+	ps2 := push(ps1, "2", ConfigKey{Kind: gvk.Sidecar, Name: "sidecar", Namespace: "ns2"})
+	scopes2 := scopes(ps2)
+	expectReused(scopes1, scopes2, "2", "ns1", "ns3")
+
+	// The scopes are not reused when the services they select may have changed.
+	ps3 := push(ps2, "3", ConfigKey{Kind: gvk.ServiceEntry, Name: "se", Namespace: "ns1"})
+	expectReused(scopes2, scopes(ps3), "3")
+}
+
+func TestWatchedNamespacesSidecarScope(t *testing.T) {
+	pushContext := func(configs ...config.Config) *PushContext {
+		store := NewFakeStore()
+		for _, c := range configs {
+			if _, err := store.Create(c); err != nil {
+				t.Fatal(err)
+			}
+		}
+		env := &Environment{}
+		env.IstioConfigStore = &istioConfigStore{ConfigStore: store}
+		env.ServiceDiscovery = &localServiceDiscovery{
+			services: []*Service{
+				{Hostname: "svc1.ns1.svc.cluster.local", Ports: allPorts, Attributes: ServiceAttributes{Namespace: "ns1"}},
+				{Hostname: "svc2.ns2.svc.cluster.local", Ports: allPorts, Attributes: ServiceAttributes{Namespace: "ns2"}},
+				{Hostname: "svc3.ns3.svc.cluster.local", Ports: allPorts, Attributes: ServiceAttributes{Namespace: "ns3"}},
+			},
+		}
+		m := mesh.DefaultMeshConfig()
+		env.Watcher = mesh.NewFixedWatcher(&m)
+		env.Init()
+		ps := NewPushContext()
+		if err := ps.InitContext(env, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		return ps
+	}
+
+	proxy := func(namespaces ...string) *Proxy {
+		return &Proxy{
+			Type:            SidecarProxy,
+			ConfigNamespace: "ns1",
+			Metadata:        &NodeMetadata{WatchedNamespaces: namespaces},
+		}
+	}
+	hostnames := func(sc *SidecarScope) []string {
+		var out []string
+		for _, svc := range sc.Services() {
+			out = append(out, string(svc.Hostname))
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	t.Run("no root sidecar", func(t *testing.T) {
+		ps := pushContext()
+		sc := ps.getSidecarScope(proxy(".", "ns2"), nil)
+		want := []string{"svc1.ns1.svc.cluster.local", "svc2.ns2.svc.cluster.local"}
+		if got := hostnames(sc); !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected services %v, got %v", want, got)
+		}
+		// Proxies watching the same namespaces share the scope.
+		if other := ps.getSidecarScope(proxy("ns2", " . ", "ns2", "ns1"), nil); other != sc {
+			t.Fatalf("expected the scope to be shared")
+		}
+		if all := ps.getSidecarScope(proxy("*"), nil); len(all.Services()) != 3 {
+			t.Fatalf("expected all services, got %v", hostnames(all))
+		}
+	})
+
+	t.Run("root sidecar", func(t *testing.T) {
+		ps := pushContext(config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: gvk.Sidecar,
+				Name:             "global",
+				Namespace:        constants.IstioSystemNamespace,
+			},
+			Spec: &networking.Sidecar{
+				Egress: []*networking.IstioEgressListener{{Hosts: []string{"./*", "ns3/*"}}},
+				OutboundTrafficPolicy: &networking.OutboundTrafficPolicy{
+					Mode: networking.OutboundTrafficPolicy_REGISTRY_ONLY,
+				},
+			},
+		})
+		// Watching all namespaces does not import more than the root sidecar.
+		sc := ps.getSidecarScope(proxy("*"), nil)
+		want := []string{"svc1.ns1.svc.cluster.local", "svc3.ns3.svc.cluster.local"}
+		if got := hostnames(sc); !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected services %v, got %v", want, got)
+		}
+		if sc.OutboundTrafficPolicy.GetMode() != networking.OutboundTrafficPolicy_REGISTRY_ONLY {
+			t.Fatalf("expected the outbound traffic policy of the root sidecar, got %v", sc.OutboundTrafficPolicy)
+		}
+		if !sc.DependsOnConfig(ConfigKey{Kind: gvk.Sidecar, Name: "global", Namespace: constants.IstioSystemNamespace}) {
+			t.Fatalf("expected the scope to depend on the root sidecar")
+		}
+		want = []string{"svc3.ns3.svc.cluster.local"}
+		if got := hostnames(ps.getSidecarScope(proxy("ns2", "ns3"), nil)); !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected services %v, got %v", want, got)
+		}
+		if got := hostnames(ps.getSidecarScope(proxy("ns2"), nil)); len(got) != 0 {
+			t.Fatalf("expected no services, got %v", got)
+		}
+	})
+}
+
 func TestBestEffortInferServiceMTLSMode(t *testing.T) {
 	const partialNS string = "partial"
 	const wholeNS string = "whole"
@@ -1360,6 +1608,98 @@ func TestSetDestinationRuleInheritance(t *testing.T) {
 	}
 }
 
+func TestUpdateDestinationRules(t *testing.T) {
+	env := &Environment{}
+	destinationRule := func(name, namespace, host string, exportTo ...string) config.Config {
+		return config.Config{
+			Meta: config.Meta{
+				Name:             name,
+				Namespace:        namespace,
+				GroupVersionKind: gvk.DestinationRule,
+			},
+			Spec: &networking.DestinationRule{
+				Host:     host,
+				ExportTo: exportTo,
+				Subsets:  []*networking.Subset{{Name: name}},
+			},
+		}
+	}
+	setDestinationRules := func(destRules ...config.Config) {
+		configStore := NewFakeStore()
+		for _, dr := range destRules {
+			_, _ = configStore.Create(dr)
+		}
+		env.IstioConfigStore = &istioConfigStore{ConfigStore: configStore}
+	}
+	env.ServiceDiscovery = &localServiceDiscovery{}
+	m := mesh.DefaultMeshConfig()
+	env.Watcher = mesh.NewFixedWatcher(&m)
+	env.Init()
+
+	ns1 := destinationRule("rule1", "ns1", "svc1.ns1.svc.cluster.local")
+	ns2 := destinationRule("rule2", "ns2", "svc2.ns2.svc.cluster.local", ".")
+	root := destinationRule("root", "istio-system", "*.svc.cluster.local", ".")
+	setDestinationRules(ns1, ns2, root)
+	old := NewPushContext()
+	if err := old.InitContext(env, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		configs   []config.Config
+		updated   []ConfigKey
+		unchanged []string
+	}{
+		{
+			name:      "updated namespace",
+			configs:   []config.Config{ns1, destinationRule("rule2", "ns2", "other.ns2.svc.cluster.local"), root},
+			updated:   []ConfigKey{{Kind: gvk.DestinationRule, Name: "rule2", Namespace: "ns2"}},
+			unchanged: []string{"ns1"},
+		},
+		{
+			name:      "deleted rule",
+			configs:   []config.Config{ns1, root},
+			updated:   []ConfigKey{{Kind: gvk.DestinationRule, Name: "rule2", Namespace: "ns2"}},
+			unchanged: []string{"ns1", "istio-system"},
+		},
+		{
+			name:      "root namespace",
+			configs:   []config.Config{ns1, ns2, destinationRule("root", "istio-system", "*.svc.cluster.local")},
+			updated:   []ConfigKey{{Kind: gvk.DestinationRule, Name: "root", Namespace: "istio-system"}},
+			unchanged: []string{"ns1", "ns2"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			setDestinationRules(tt.configs...)
+			updated := map[ConfigKey]struct{}{}
+			for _, key := range tt.updated {
+				updated[key] = struct{}{}
+			}
+			partial := NewPushContext()
+			if err := partial.InitContext(env, old, &PushRequest{Full: true, ConfigsUpdated: updated}); err != nil {
+				t.Fatal(err)
+			}
+			full := NewPushContext()
+			if err := full.InitContext(env, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			diff := cmp.Diff(full.destinationRuleIndex, partial.destinationRuleIndex,
+				cmp.AllowUnexported(destinationRuleIndex{}, processedDestRules{}))
+			if diff != "" {
+				t.Fatalf("the partially rebuilt index differs from the rebuilt one: %v", diff)
+			}
+			for _, ns := range tt.unchanged {
+				if partial.destinationRuleIndex.namespaceLocal[ns] != old.destinationRuleIndex.namespaceLocal[ns] {
+					t.Errorf("expected the index of %s to be reused", ns)
+				}
+			}
+		})
+	}
+}
+
 func TestSetDestinationRuleMerging(t *testing.T) {
 	ps := NewPushContext()
 	ps.exportToDefaults.destinationRule = map[visibility.Instance]bool{visibility.Public: true}
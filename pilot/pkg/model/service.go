@@ -24,6 +24,7 @@ package model
 
 import (
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"time"
@@ -32,6 +33,7 @@ import (
 	"github.com/mitchellh/copystructure"
 
 	"istio.io/api/label"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/networking"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pilot/pkg/util/sets"
@@ -174,6 +176,12 @@ const (
 
 	// IstioCanonicalServiceRevisionLabelName is the name of label for the Istio Canonical Service revision for a workload instance.
 	IstioCanonicalServiceRevisionLabelName = "service.istio.io/canonical-revision"
+
+	// AdditionalIPsAnnotation is a comma separated list of the IPs of the secondary interfaces of a WorkloadEntry.
+	// They are registered as secondary endpoints of the workload when PILOT_ENABLE_ADDITIONAL_WORKLOAD_IPS is
+	// enabled. The secondary IPs of pods are read from the network status written by Multus instead, but a pod may
+	// declare them with this annotation so that the inbound IPv6 traffic of its secondary interfaces is captured.
+	AdditionalIPsAnnotation = "networking.istio.io/additionalIPs"
 )
 
 // Port represents a network port where a service is listening for
@@ -277,6 +285,8 @@ type WorkloadInstance struct {
 	PortMap  map[string]uint32 `json:"portMap,omitempty"`
 	// Can only be selected by service entry of DNS type.
 	DNSServiceEntryOnly bool `json:"dnsServiceEntryOnly,omitempty"`
+	// AdditionalIPs are the IPs of the secondary interfaces of the workload.
+	AdditionalIPs []string `json:"additionalIPs,omitempty"`
 }
 
 // DeepCopy creates a copy of WorkloadInstance.
@@ -291,6 +301,8 @@ func (instance *WorkloadInstance) DeepCopy() *WorkloadInstance {
 		Kind:      instance.Kind,
 		PortMap:   pmap,
 		Endpoint:  instance.Endpoint.DeepCopy(),
+		// AdditionalIPs is never mutated in place, so it can be shared.
+		AdditionalIPs: instance.AdditionalIPs,
 	}
 }
 
@@ -333,6 +345,9 @@ func WorkloadInstancesEqual(first, second *WorkloadInstance) bool {
 	if !portMapEquals(first.PortMap, second.PortMap) {
 		return false
 	}
+	if !stringSliceEqual(first.AdditionalIPs, second.AdditionalIPs) {
+		return false
+	}
 	return true
 }
 
@@ -393,6 +408,9 @@ const (
 	Healthy HealthStatus = 0
 	// Unhealthy.
 	UnHealthy HealthStatus = 1
+	// Draining. The endpoint is terminating or failing readiness gates; in-flight requests should be
+	// completed, but no new requests should be sent to it.
+	Draining HealthStatus = 2
 )
 
 // IstioEndpoint defines a network address (IP:port) associated with an instance of the
@@ -469,6 +487,11 @@ type IstioEndpoint struct {
 
 	// Indicatesthe endpoint health status.
 	HealthStatus HealthStatus
+
+	// Secondary is set for the endpoints of the additional IPs of a workload, such as the addresses of its
+	// secondary interfaces. They identify the workload, but are often not routable from the rest of the mesh,
+	// so they are not load balanced to.
+	Secondary bool
 }
 
 // GetLoadBalancingWeight returns the weight for this endpoint, normalized to always be > 0.
@@ -562,6 +585,18 @@ type ServiceAttributes struct {
 	// The port that the user provides in the meshNetworks config is the service port.
 	// We translate that to the appropriate node port here.
 	ClusterExternalPorts map[cluster.ID]map[uint32]uint32
+
+	// DrainingEndpoints indicates whether endpoints of this service which are terminating or failing
+	// readiness gates should be sent as draining, rather than removed.
+	DrainingEndpoints bool
+
+	// NetworkGatewayWeight is the relative weight of the gateways of the service, if it is a network gateway,
+	// among the gateways of their network. The gateways have a weight of 1 if unset.
+	NetworkGatewayWeight uint32
+
+	// NetworkGatewayDrained is true if the gateways of the service, if it is a network gateway, were given
+	// a weight of 0, so that no traffic is sent to them.
+	NetworkGatewayDrained bool
 }
 
 // DeepCopy creates a deep copy of ServiceAttributes, but skips internal mutexes.
@@ -778,6 +813,23 @@ func (s *Service) GetAddressForProxy(node *Proxy) string {
 	return s.DefaultAddress
 }
 
+// GetAllAddressesForProxy returns the addresses of a Service specific to the cluster where the node resides:
+// the address returned by GetAddressForProxy, followed by the other VIPs of the cluster that the node supports
+// when it has dual stack enabled. For a dual-stack service, these are the cluster IPs of its other IP family.
+func (s *Service) GetAllAddressesForProxy(node *Proxy) []string {
+	addr := s.GetAddressForProxy(node)
+	addresses := []string{addr}
+	if !node.DualStackEnabled() || node.Metadata.ClusterID == "" {
+		return addresses
+	}
+	for _, vip := range s.ClusterVIPs.GetAddressesFor(node.Metadata.ClusterID) {
+		if vip != addr && node.SupportsAddress(vip) {
+			addresses = append(addresses, vip)
+		}
+	}
+	return addresses
+}
+
 // getAllAddresses returns a Service's all addresses.
 func (s *Service) getAllAddresses() []string {
 	var addresses []string
@@ -851,6 +903,29 @@ func (ep *IstioEndpoint) DeepCopy() *IstioEndpoint {
 	return copyInternal(ep).(*IstioEndpoint)
 }
 
+// AdditionalWorkloadIPs returns the valid IPs of the secondary interfaces of a workload, without its primary
+// address and without duplicates. It returns nil if PILOT_ENABLE_ADDITIONAL_WORKLOAD_IPS is disabled.
+func AdditionalWorkloadIPs(ips []string, primary string) []string {
+	if !features.EnableAdditionalWorkloadIPs {
+		return nil
+	}
+	var out []string
+	seen := sets.NewSet(primary)
+	for _, addr := range ips {
+		ip := net.ParseIP(strings.TrimSpace(addr))
+		if ip == nil {
+			continue
+		}
+		addr = ip.String()
+		if seen.Contains(addr) {
+			continue
+		}
+		seen.Insert(addr)
+		out = append(out, addr)
+	}
+	return out
+}
+
 func copyInternal(v interface{}) interface{} {
 	copied, err := copystructure.Copy(v)
 	if err != nil {
@@ -15,8 +15,10 @@
 package model
 
 import (
+	"reflect"
 	"testing"
 
+	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
 )
@@ -35,6 +37,41 @@ func TestGetByPort(t *testing.T) {
 	}
 }
 
+func TestGetAllAddressesForProxy(t *testing.T) {
+	svc := &Service{
+		DefaultAddress: "10.0.0.1",
+		ClusterVIPs: AddressMap{
+			Addresses: map[cluster.ID][]string{
+				"c1": {"10.0.0.1", "fd00::1"},
+				"c2": {"10.0.0.2", "fd00::2"},
+			},
+		},
+	}
+	proxy := func(dualStack bool, clusterID cluster.ID, ips ...string) *Proxy {
+		p := &Proxy{IPAddresses: ips, Metadata: &NodeMetadata{ClusterID: clusterID, DualStack: StringBool(dualStack)}}
+		p.DiscoverIPVersions()
+		return p
+	}
+	cases := []struct {
+		name  string
+		proxy *Proxy
+		want  []string
+	}{
+		{"disabled", proxy(false, "c1", "1.1.1.1", "2001:db8::1"), []string{"10.0.0.1"}},
+		{"dual-stack proxy", proxy(true, "c1", "1.1.1.1", "2001:db8::1"), []string{"10.0.0.1", "fd00::1"}},
+		{"other cluster", proxy(true, "c2", "1.1.1.1", "2001:db8::1"), []string{"10.0.0.2", "fd00::2"}},
+		{"ipv4 only proxy", proxy(true, "c1", "1.1.1.1"), []string{"10.0.0.1"}},
+		{"unknown cluster", proxy(true, "", "1.1.1.1", "2001:db8::1"), []string{"10.0.0.1"}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := svc.GetAllAddressesForProxy(tt.proxy); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func BenchmarkParseSubsetKey(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		ParseSubsetKey("outbound|80|v1|example.com")
@@ -15,15 +15,21 @@
 package model
 
 import (
+	"crypto/md5"
+	"encoding/binary"
 	"encoding/json"
 	"sort"
 	"strings"
 
+	gogojsonpb "github.com/gogo/protobuf/jsonpb"
+
 	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/config/schema/gvk"
 )
@@ -32,6 +38,8 @@ const (
 	wildcardNamespace = "*"
 	currentNamespace  = "."
 	wildcardService   = host.Name("*")
+	// noneNamespace cannot be the name of a namespace, so hosts in it match nothing.
+	noneNamespace = "~"
 )
 
 var (
@@ -98,12 +106,19 @@ type SidecarScope struct {
 	// CDS, we simply have to find the matching service and return the
 	// destination rule.
 	destinationRules map[host.Name]*config.Config
+	// destinationRuleSources contains the keys of the destination rules merged into the destination rule of each
+	// hostname.
+	destinationRuleSources map[host.Name][]ConfigKey
 
 	// OutboundTrafficPolicy defines the outbound traffic policy for this sidecar.
 	// If OutboundTrafficPolicy is ALLOW_ANY traffic to unknown destinations will
 	// be forwarded.
 	OutboundTrafficPolicy *networking.OutboundTrafficPolicy
 
+	// OutboundTrafficAudit is set when the Sidecar opted into the outbound traffic audit, so that traffic blocked by
+	// REGISTRY_ONLY is forwarded to the AuditPassthroughCluster instead.
+	OutboundTrafficAudit bool
+
 	// Set of known configs this sidecar depends on.
 	// This field will be used to determine the config/resource scope
 	// which means which config changes will affect the proxies within this scope.
@@ -167,6 +182,122 @@ type IstioEgressListenerWrapper struct {
 
 const defaultSidecar = "default-sidecar"
 
+// watchedNamespacesSidecar is the name of the Sidecar built from the WATCHED_NAMESPACES proxy metadata.
+const watchedNamespacesSidecar = "watched-namespaces"
+
+// watchedNamespaces returns the sorted namespaces the proxy watches through its metadata, or nil if it does not
+// watch any. Besides namespace names, "." stands for the proxy namespace and "*" for all namespaces. Invalid
+// entries are ignored.
+func watchedNamespaces(proxy *Proxy) []string {
+	if proxy.Metadata == nil || len(proxy.Metadata.WatchedNamespaces) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(proxy.Metadata.WatchedNamespaces))
+	out := make([]string, 0, len(proxy.Metadata.WatchedNamespaces))
+	for _, ns := range proxy.Metadata.WatchedNamespaces {
+		ns = strings.TrimSpace(ns)
+		if ns == currentNamespace {
+			ns = proxy.ConfigNamespace
+		}
+		if ns != wildcardNamespace && !labels.IsDNS1123Label(ns) {
+			continue
+		}
+		if _, f := seen[ns]; f {
+			continue
+		}
+		seen[ns] = struct{}{}
+		out = append(out, ns)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	sort.Strings(out)
+	return out
+}
+
+// watchedNamespacesSidecarConfig returns the Sidecar of a proxy in the config namespace watching the given
+// namespaces. The Sidecar of the root namespace, if any, is narrowed down to the hosts of the watched namespaces,
+// keeping its other settings, so that watching namespaces never imports more than the root Sidecar does.
+func watchedNamespacesSidecarConfig(root *config.Config, configNamespace string, namespaces []string) *config.Config {
+	if root == nil {
+		hosts := make([]string, 0, len(namespaces))
+		for _, ns := range namespaces {
+			hosts = append(hosts, ns+"/*")
+		}
+		return &config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: gvk.Sidecar,
+				Name:             watchedNamespacesSidecar,
+				Namespace:        configNamespace,
+			},
+			Spec: &networking.Sidecar{
+				Egress: []*networking.IstioEgressListener{{Hosts: hosts}},
+			},
+		}
+	}
+
+	rootSidecar := root.Spec.(*networking.Sidecar)
+	egress := rootSidecar.Egress
+	if len(egress) == 0 {
+		egress = []*networking.IstioEgressListener{{Hosts: []string{"*/*"}}}
+	}
+	sidecar := *rootSidecar
+	sidecar.Egress = make([]*networking.IstioEgressListener, 0, len(egress))
+	for _, e := range egress {
+		listener := *e
+		listener.Hosts = watchedHosts(e.Hosts, configNamespace, namespaces)
+		if len(listener.Hosts) == 0 {
+			// The listener does not import anything the proxy watches. Dropping it would fall back to
+			// importing everything if it was the only one, so make it import nothing instead.
+			listener.Hosts = []string{noneNamespace + "/*"}
+		}
+		sidecar.Egress = append(sidecar.Egress, &listener)
+	}
+	// The scope is named after the root Sidecar so that it depends on it.
+	cfg := root.DeepCopy()
+	cfg.Spec = &sidecar
+	return &cfg
+}
+
+// watchedHosts returns the egress hosts in the namespace/dnsName format that are in the watched namespaces.
+func watchedHosts(hosts []string, configNamespace string, namespaces []string) []string {
+	watchesAll := len(namespaces) > 0 && namespaces[0] == wildcardNamespace
+	watches := func(ns string) bool {
+		if watchesAll {
+			return true
+		}
+		i := sort.SearchStrings(namespaces, ns)
+		return i < len(namespaces) && namespaces[i] == ns
+	}
+	var out []string
+	for _, h := range hosts {
+		parts := strings.SplitN(h, "/", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		ns, dnsName := parts[0], parts[1]
+		switch ns {
+		case wildcardNamespace:
+			if watchesAll {
+				out = append(out, h)
+				continue
+			}
+			for _, w := range namespaces {
+				out = append(out, w+"/"+dnsName)
+			}
+		case currentNamespace:
+			if watches(configNamespace) {
+				out = append(out, h)
+			}
+		default:
+			if watches(ns) {
+				out = append(out, h)
+			}
+		}
+	}
+	return out
+}
+
 // DefaultSidecarScopeForNamespace is a sidecar scope object with a default catch all egress listener
 // that matches the default Istio behavior: a sidecar has listeners for all services in the mesh
 // We use this scope when the user has not set any sidecar Config for a given config namespace.
@@ -180,15 +311,16 @@ func DefaultSidecarScopeForNamespace(ps *PushContext, configNamespace string) *S
 	defaultEgressListener.virtualServices = ps.VirtualServicesForGateway(configNamespace, constants.IstioMeshGateway)
 
 	out := &SidecarScope{
-		Name:               defaultSidecar,
-		Namespace:          configNamespace,
-		EgressListeners:    []*IstioEgressListenerWrapper{defaultEgressListener},
-		services:           defaultEgressListener.services,
-		destinationRules:   make(map[host.Name]*config.Config),
-		servicesByHostname: make(map[host.Name]*Service, len(defaultEgressListener.services)),
-		configDependencies: make(map[uint64]struct{}),
-		RootNamespace:      ps.Mesh.RootNamespace,
-		Version:            ps.PushVersion,
+		Name:                   defaultSidecar,
+		Namespace:              configNamespace,
+		EgressListeners:        []*IstioEgressListenerWrapper{defaultEgressListener},
+		services:               defaultEgressListener.services,
+		destinationRules:       make(map[host.Name]*config.Config),
+		destinationRuleSources: make(map[host.Name][]ConfigKey),
+		servicesByHostname:     make(map[host.Name]*Service, len(defaultEgressListener.services)),
+		configDependencies:     make(map[uint64]struct{}),
+		RootNamespace:          ps.Mesh.RootNamespace,
+		Version:                ps.PushVersion,
 	}
 
 	// Now that we have all the services that sidecars using this scope (in
@@ -208,6 +340,7 @@ func DefaultSidecarScopeForNamespace(ps *PushContext, configNamespace string) *S
 		out.servicesByHostname[s.Hostname] = s
 		if dr := ps.destinationRule(configNamespace, s); dr != nil {
 			out.destinationRules[s.Hostname] = dr
+			out.destinationRuleSources[s.Hostname] = ps.destinationRuleSources(dr)
 		}
 		out.AddConfigDependencies(ConfigKey{
 			Kind:      gvk.ServiceEntry,
@@ -216,12 +349,8 @@ func DefaultSidecarScopeForNamespace(ps *PushContext, configNamespace string) *S
 		})
 	}
 
-	for _, dr := range out.destinationRules {
-		out.AddConfigDependencies(ConfigKey{
-			Kind:      gvk.DestinationRule,
-			Name:      dr.Name,
-			Namespace: dr.Namespace,
-		})
+	for _, sources := range out.destinationRuleSources {
+		out.AddConfigDependencies(sources...)
 	}
 
 	for _, el := range out.EgressListeners {
@@ -248,6 +377,32 @@ func DefaultSidecarScopeForNamespace(ps *PushContext, configNamespace string) *S
 	return out
 }
 
+// sidecarScopeHash returns the hash of the Sidecar config, or of the default scope if it is nil, in the config
+// namespace. Scopes with the same hash are identical as long as the configs they select are unchanged. It returns
+// false if the Sidecar cannot be hashed.
+func sidecarScopeHash(sidecarConfig *config.Config, configNamespace string) (uint64, bool) {
+	hash := md5.New()
+	hash.Write([]byte(configNamespace))
+	if sidecarConfig != nil {
+		for _, v := range []string{sidecarConfig.Name, sidecarConfig.Namespace} {
+			hash.Write([]byte{0})
+			hash.Write([]byte(v))
+		}
+		hash.Write([]byte{0})
+		// Unlike the binary encoding, the JSON encoding sorts the labels of the workload selector.
+		spec, err := (&gogojsonpb.Marshaler{}).MarshalToString(sidecarConfig.Spec.(*networking.Sidecar))
+		if err != nil {
+			return 0, false
+		}
+		hash.Write([]byte(spec))
+		hash.Write([]byte{0})
+		hash.Write([]byte(sidecarConfig.Annotations[constants.SidecarOutboundTrafficAuditAnnotation]))
+	}
+	var tmp [md5.Size]byte
+	sum := hash.Sum(tmp[:0])
+	return binary.BigEndian.Uint64(sum), true
+}
+
 // ConvertToSidecarScope converts from Sidecar config to SidecarScope object
 func ConvertToSidecarScope(ps *PushContext, sidecarConfig *config.Config, configNamespace string) *SidecarScope {
 	if sidecarConfig == nil {
@@ -256,12 +411,13 @@ func ConvertToSidecarScope(ps *PushContext, sidecarConfig *config.Config, config
 
 	sidecar := sidecarConfig.Spec.(*networking.Sidecar)
 	out := &SidecarScope{
-		Name:               sidecarConfig.Name,
-		Namespace:          configNamespace,
-		Sidecar:            sidecar,
-		configDependencies: make(map[uint64]struct{}),
-		RootNamespace:      ps.Mesh.RootNamespace,
-		Version:            ps.PushVersion,
+		Name:                 sidecarConfig.Name,
+		Namespace:            configNamespace,
+		Sidecar:              sidecar,
+		configDependencies:   make(map[uint64]struct{}),
+		RootNamespace:        ps.Mesh.RootNamespace,
+		Version:              ps.PushVersion,
+		OutboundTrafficAudit: features.OutboundTrafficAudit && sidecarConfig.Annotations[constants.SidecarOutboundTrafficAuditAnnotation] == "true",
 	}
 
 	out.AddConfigDependencies(ConfigKey{
@@ -401,16 +557,14 @@ func ConvertToSidecarScope(ps *PushContext, sidecarConfig *config.Config, config
 	// that these services need
 	out.servicesByHostname = make(map[host.Name]*Service, len(out.services))
 	out.destinationRules = make(map[host.Name]*config.Config)
+	out.destinationRuleSources = make(map[host.Name][]ConfigKey)
 	for _, s := range out.services {
 		out.servicesByHostname[s.Hostname] = s
 		dr := ps.destinationRule(configNamespace, s)
 		if dr != nil {
 			out.destinationRules[s.Hostname] = dr
-			out.AddConfigDependencies(ConfigKey{
-				Kind:      gvk.DestinationRule,
-				Name:      dr.Name,
-				Namespace: dr.Namespace,
-			})
+			out.destinationRuleSources[s.Hostname] = ps.destinationRuleSources(dr)
+			out.AddConfigDependencies(out.destinationRuleSources[s.Hostname]...)
 		}
 	}
 
@@ -556,6 +710,24 @@ func (sc *SidecarScope) DestinationRule(svc host.Name) *config.Config {
 	return sc.destinationRules[svc]
 }
 
+// DestinationRuleHosts returns the hosts of the services which the destination rule with the name and namespace
+// applies to, including through the destination rules it is merged with.
+func (sc *SidecarScope) DestinationRuleHosts(name, namespace string) []host.Name {
+	if sc == nil {
+		return nil
+	}
+	var hosts []host.Name
+	for h, sources := range sc.destinationRuleSources {
+		for _, key := range sources {
+			if key.Name == name && key.Namespace == namespace {
+				hosts = append(hosts, h)
+				break
+			}
+		}
+	}
+	return hosts
+}
+
 // Services returns the list of services that are visible to a sidecar.
 func (sc *SidecarScope) Services() []*Service {
 	return sc.services
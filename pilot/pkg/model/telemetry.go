@@ -15,7 +15,10 @@
 package model
 
 import (
+	"encoding/json"
+	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -36,6 +39,7 @@ import (
 	tpb "istio.io/api/telemetry/v1alpha1"
 	"istio.io/istio/pilot/pkg/networking"
 	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/util/protomarshal"
@@ -49,6 +53,43 @@ type Telemetry struct {
 	Name      string         `json:"name"`
 	Namespace string         `json:"namespace"`
 	Spec      *tpb.Telemetry `json:"spec"`
+	// Exemplars is set from the TelemetryExemplarsAnnotation.
+	Exemplars *bool `json:"exemplars,omitempty"`
+	// Compression is set from the TelemetryCompressionAnnotation.
+	Compression *CompressionConfig `json:"compression,omitempty"`
+}
+
+// CompressionConfig configures the compression and decompression filters of a proxy.
+type CompressionConfig struct {
+	// Algorithms compressing the responses, in order of preference when the client accepts several of them.
+	Algorithms []string `json:"algorithms,omitempty"`
+	// ContentTypes of the compressed responses. Envoy compresses common text types by default.
+	ContentTypes []string `json:"contentTypes,omitempty"`
+	// MinContentLength is the minimum size of the compressed responses. Envoy defaults to 30 bytes.
+	MinContentLength uint32 `json:"minContentLength,omitempty"`
+	// Decompression lists the algorithms decompressing the requests and responses.
+	Decompression []string `json:"decompression,omitempty"`
+}
+
+const (
+	CompressionGzip   = "gzip"
+	CompressionBrotli = "brotli"
+)
+
+// ParseCompressionConfig parses the value of the TelemetryCompressionAnnotation.
+func ParseCompressionConfig(v string) (*CompressionConfig, error) {
+	out := &CompressionConfig{}
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(out); err != nil {
+		return nil, err
+	}
+	for _, algorithm := range append(append([]string{}, out.Algorithms...), out.Decompression...) {
+		if algorithm != CompressionGzip && algorithm != CompressionBrotli {
+			return nil, fmt.Errorf("unsupported compression algorithm %q", algorithm)
+		}
+	}
+	return out, nil
 }
 
 // Telemetries organizes Telemetry configuration by namespace.
@@ -113,6 +154,22 @@ func getTelemetries(env *Environment) (*Telemetries, error) {
 			Namespace: config.Namespace,
 			Spec:      config.Spec.(*tpb.Telemetry),
 		}
+		if v, f := config.Annotations[constants.TelemetryExemplarsAnnotation]; f {
+			if enabled, err := strconv.ParseBool(v); err == nil {
+				telemetry.Exemplars = &enabled
+			} else {
+				telemetryLog.Warnf("invalid %s annotation on telemetry %s/%s: %v",
+					constants.TelemetryExemplarsAnnotation, config.Namespace, config.Name, err)
+			}
+		}
+		if v, f := config.Annotations[constants.TelemetryCompressionAnnotation]; f {
+			if compression, err := ParseCompressionConfig(v); err == nil {
+				telemetry.Compression = compression
+			} else {
+				telemetryLog.Warnf("invalid %s annotation on telemetry %s/%s: %v",
+					constants.TelemetryCompressionAnnotation, config.Namespace, config.Name, err)
+			}
+		}
 		telemetries.NamespaceToTelemetries[config.Namespace] = append(telemetries.NamespaceToTelemetries[config.Namespace], telemetry)
 	}
 
@@ -161,9 +218,11 @@ type tagOverride struct {
 // This can include the root namespace, namespace, and workload Telemetries combined
 type computedTelemetries struct {
 	telemetryKey
-	Metrics []*tpb.Metrics
-	Logging []*tpb.AccessLogging
-	Tracing []*tpb.Tracing
+	Metrics     []*tpb.Metrics
+	Logging     []*tpb.AccessLogging
+	Tracing     []*tpb.Tracing
+	Exemplars   bool
+	Compression *CompressionConfig
 }
 
 type TracingConfig struct {
@@ -271,6 +330,32 @@ func (t *Telemetries) Tracing(proxy *Proxy) *TracingConfig {
 	return &cfg
 }
 
+// Exemplars returns whether the request duration histograms of a sidecar carry exemplars, linking the
+// requests to their traces. Exemplars are only recorded when tracing is enabled for the proxy.
+func (t *Telemetries) Exemplars(proxy *Proxy) bool {
+	if t == nil || proxy.Type != SidecarProxy || !t.applicableTelemetries(proxy).Exemplars {
+		return false
+	}
+	if tracing := t.Tracing(proxy); tracing != nil {
+		return !tracing.Disabled
+	}
+	// No Telemetry config for tracing, fallback to legacy mesh config
+	return t.meshConfig.GetEnableTracing()
+}
+
+// Compression returns the compression configuration of a proxy, or nil if it neither compresses nor decompresses.
+// The configuration of the most specific Telemetry replaces the others.
+func (t *Telemetries) Compression(proxy *Proxy) *CompressionConfig {
+	if t == nil {
+		return nil
+	}
+	c := t.applicableTelemetries(proxy).Compression
+	if c == nil || (len(c.Algorithms) == 0 && len(c.Decompression) == 0) {
+		return nil
+	}
+	return c
+}
+
 // HTTPFilters computes the HttpFilter for a given proxy/class
 func (t *Telemetries) HTTPFilters(proxy *Proxy, class networking.ListenerClass) []*hcm.HttpFilter {
 	if res := t.telemetryFilters(proxy, class, networking.ListenerProtocolHTTP); res != nil {
@@ -299,6 +384,8 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 	ms := []*tpb.Metrics{}
 	ls := []*tpb.AccessLogging{}
 	ts := []*tpb.Tracing{}
+	exemplars := false
+	var compression *CompressionConfig
 	key := telemetryKey{}
 	if t.RootNamespace != "" {
 		telemetry := t.namespaceWideTelemetryConfig(t.RootNamespace)
@@ -307,6 +394,12 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 			ms = append(ms, telemetry.Spec.GetMetrics()...)
 			ls = append(ls, telemetry.Spec.GetAccessLogging()...)
 			ts = append(ts, telemetry.Spec.GetTracing()...)
+			if telemetry.Exemplars != nil {
+				exemplars = *telemetry.Exemplars
+			}
+			if telemetry.Compression != nil {
+				compression = telemetry.Compression
+			}
 		}
 	}
 
@@ -317,6 +410,12 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 			ms = append(ms, telemetry.Spec.GetMetrics()...)
 			ls = append(ls, telemetry.Spec.GetAccessLogging()...)
 			ts = append(ts, telemetry.Spec.GetTracing()...)
+			if telemetry.Exemplars != nil {
+				exemplars = *telemetry.Exemplars
+			}
+			if telemetry.Compression != nil {
+				compression = telemetry.Compression
+			}
 		}
 	}
 
@@ -331,6 +430,12 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 			ms = append(ms, spec.GetMetrics()...)
 			ls = append(ls, spec.GetAccessLogging()...)
 			ts = append(ts, spec.GetTracing()...)
+			if telemetry.Exemplars != nil {
+				exemplars = *telemetry.Exemplars
+			}
+			if telemetry.Compression != nil {
+				compression = telemetry.Compression
+			}
 			break
 		}
 	}
@@ -340,6 +445,8 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 		Metrics:      ms,
 		Logging:      ls,
 		Tracing:      ts,
+		Exemplars:    exemplars,
+		Compression:  compression,
 	}
 }
 
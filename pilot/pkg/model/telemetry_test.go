@@ -28,8 +28,10 @@ import (
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	tpb "istio.io/api/telemetry/v1alpha1"
+	"istio.io/api/type/v1beta1"
 	"istio.io/istio/pilot/pkg/networking"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/schema/collection"
 	"istio.io/istio/pkg/config/schema/collections"
@@ -542,6 +544,145 @@ func TestTracing(t *testing.T) {
 	}
 }
 
+func TestExemplars(t *testing.T) {
+	sidecar := &Proxy{Type: SidecarProxy, ConfigNamespace: "default", Metadata: &NodeMetadata{Labels: map[string]string{"app": "test"}}}
+	gateway := &Proxy{Type: Router, ConfigNamespace: "default", Metadata: &NodeMetadata{Labels: map[string]string{"app": "test"}}}
+	withExemplars := func(cfg config.Config, enabled string) config.Config {
+		cfg.Annotations = map[string]string{constants.TelemetryExemplarsAnnotation: enabled}
+		return cfg
+	}
+	workload := newTelemetry("default", &tpb.Telemetry{Selector: &v1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "test"}}})
+	workload.Name = "workload"
+	tests := []struct {
+		name           string
+		cfgs           []config.Config
+		proxy          *Proxy
+		disableTracing bool
+		want           bool
+	}{
+		{
+			"empty",
+			nil,
+			sidecar,
+			false,
+			false,
+		},
+		{
+			"root",
+			[]config.Config{withExemplars(newTelemetry("istio-system", &tpb.Telemetry{}), "true")},
+			sidecar,
+			false,
+			true,
+		},
+		{
+			"gateway",
+			[]config.Config{withExemplars(newTelemetry("istio-system", &tpb.Telemetry{}), "true")},
+			gateway,
+			false,
+			false,
+		},
+		{
+			"tracing disabled",
+			[]config.Config{withExemplars(newTelemetry("istio-system", &tpb.Telemetry{}), "true")},
+			sidecar,
+			true,
+			false,
+		},
+		{
+			"namespace override",
+			[]config.Config{
+				withExemplars(newTelemetry("istio-system", &tpb.Telemetry{}), "true"),
+				withExemplars(newTelemetry("default", &tpb.Telemetry{}), "false"),
+			},
+			sidecar,
+			false,
+			false,
+		},
+		{
+			"workload override",
+			[]config.Config{
+				withExemplars(newTelemetry("default", &tpb.Telemetry{}), "false"),
+				withExemplars(workload, "true"),
+			},
+			sidecar,
+			false,
+			true,
+		},
+		{
+			"invalid",
+			[]config.Config{withExemplars(newTelemetry("istio-system", &tpb.Telemetry{}), "yes")},
+			sidecar,
+			false,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telemetry := createTestTelemetries(tt.cfgs, t)
+			telemetry.meshConfig.EnableTracing = !tt.disableTracing
+			if got := telemetry.Exemplars(tt.proxy); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompression(t *testing.T) {
+	sidecar := &Proxy{Type: SidecarProxy, ConfigNamespace: "default", Metadata: &NodeMetadata{Labels: map[string]string{"app": "test"}}}
+	withCompression := func(cfg config.Config, compression string) config.Config {
+		cfg.Annotations = map[string]string{constants.TelemetryCompressionAnnotation: compression}
+		return cfg
+	}
+	workload := newTelemetry("default", &tpb.Telemetry{Selector: &v1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "test"}}})
+	workload.Name = "workload"
+	tests := []struct {
+		name string
+		cfgs []config.Config
+		want *CompressionConfig
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "root",
+			cfgs: []config.Config{withCompression(newTelemetry("istio-system", &tpb.Telemetry{}),
+				`{"algorithms": ["brotli", "gzip"], "contentTypes": ["application/json"], "minContentLength": 1024}`)},
+			want: &CompressionConfig{Algorithms: []string{"brotli", "gzip"}, ContentTypes: []string{"application/json"}, MinContentLength: 1024},
+		},
+		{
+			name: "workload override",
+			cfgs: []config.Config{
+				withCompression(newTelemetry("istio-system", &tpb.Telemetry{}), `{"algorithms": ["gzip"]}`),
+				withCompression(workload, `{"decompression": ["gzip"]}`),
+			},
+			want: &CompressionConfig{Decompression: []string{"gzip"}},
+		},
+		{
+			name: "disabled",
+			cfgs: []config.Config{
+				withCompression(newTelemetry("istio-system", &tpb.Telemetry{}), `{"algorithms": ["gzip"]}`),
+				withCompression(newTelemetry("default", &tpb.Telemetry{}), `{}`),
+			},
+		},
+		{
+			name: "unsupported algorithm",
+			cfgs: []config.Config{withCompression(newTelemetry("istio-system", &tpb.Telemetry{}), `{"algorithms": ["zstd"]}`)},
+		},
+		{
+			name: "unknown field",
+			cfgs: []config.Config{withCompression(newTelemetry("istio-system", &tpb.Telemetry{}), `{"algorithm": ["gzip"]}`)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telemetry := createTestTelemetries(tt.cfgs, t)
+			if diff := cmp.Diff(telemetry.Compression(sidecar), tt.want); diff != "" {
+				t.Fatalf("got diff %v", diff)
+			}
+		})
+	}
+}
+
 func TestTelemetryFilters(t *testing.T) {
 	overrides := []*tpb.MetricsOverrides{{
 		Match: &tpb.MetricSelector{
@@ -17,6 +17,7 @@ package core
 import (
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
@@ -41,9 +42,18 @@ type ConfigGenerator interface {
 	BuildDeltaClusters(proxy *model.Proxy, updates *model.PushRequest,
 		watched *model.WatchedResource) ([]*discovery.Resource, []string, model.XdsLogDetails, bool)
 
+	// BuildWatchedClusters returns the clusters of the services watched by name by a proxy, rather than all of its
+	// clusters, along with the removed clusters when deltas are used. This is Delta CDS output for proxies
+	// subscribing to clusters by name.
+	BuildWatchedClusters(proxy *model.Proxy, updates *model.PushRequest,
+		watched *model.WatchedResource) ([]*discovery.Resource, []string, model.XdsLogDetails, bool)
+
 	// BuildHTTPRoutes returns the list of HTTP routes for the given proxy. This is the RDS output
 	BuildHTTPRoutes(node *model.Proxy, req *model.PushRequest, routeNames []string) ([]*discovery.Resource, model.XdsLogDetails)
 
+	// BuildGatewayVirtualHosts returns the list of virtual hosts of the given gateway route. This is the VHDS output
+	BuildGatewayVirtualHosts(node *model.Proxy, push *model.PushContext, routeName string) []*route.VirtualHost
+
 	// BuildNameTable returns list of hostnames and the associated IPs
 	BuildNameTable(node *model.Proxy, push *model.PushContext) *dnsProto.NameTable
 
@@ -36,6 +36,7 @@ import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/xds"
 	"istio.io/istio/pkg/util/protomarshal"
 	"istio.io/pkg/log"
@@ -61,6 +62,7 @@ const (
 	tcpEnvoyAccessLogFriendlyName      = "tcp_envoy_accesslog"
 	otelEnvoyAccessLogFriendlyName     = "otel_envoy_accesslog"
 	listenerEnvoyAccessLogFriendlyName = "listener_envoy_accesslog"
+	exemplarsAccessLogFriendlyName     = "exemplars_accesslog"
 
 	tcpEnvoyALSName  = "envoy.tcp_grpc_access_log"
 	otelEnvoyALSName = "envoy.access_loggers.open_telemetry"
@@ -134,6 +136,8 @@ type AccessLogBuilder struct {
 	httpGrpcAccessLog *accesslog.AccessLog
 	// tcpGrpcListenerAccessLog is used when access log service is enabled in mesh config.
 	tcpGrpcListenerAccessLog *accesslog.AccessLog
+	// exemplarsAccessLog is used when exemplars are enabled through the Telemetry API.
+	exemplarsAccessLog *accesslog.AccessLog
 
 	// file accessLog which is cached and reset on MeshConfig change.
 	mutex                 sync.RWMutex
@@ -146,6 +150,7 @@ func newAccessLogBuilder() *AccessLogBuilder {
 		tcpGrpcAccessLog:         buildTCPGrpcAccessLog(false),
 		httpGrpcAccessLog:        buildHTTPGrpcAccessLog(),
 		tcpGrpcListenerAccessLog: buildTCPGrpcAccessLog(true),
+		exemplarsAccessLog:       buildExemplarsAccessLog(),
 	}
 }
 
@@ -225,6 +230,9 @@ func buildAccessLogFilterFromTelemetry(spec *model.LoggingConfig) *accesslog.Acc
 
 func (b *AccessLogBuilder) setHTTPAccessLog(opts buildListenerOpts, connectionManager *hcm.HttpConnectionManager) {
 	mesh := opts.push.Mesh
+	if opts.push.Telemetry.Exemplars(opts.proxy) {
+		connectionManager.AccessLog = append(connectionManager.AccessLog, b.exemplarsAccessLog)
+	}
 	cfg := opts.push.Telemetry.AccessLogging(opts.proxy)
 
 	if cfg == nil {
@@ -691,6 +699,34 @@ func buildHTTPGrpcAccessLog() *accesslog.AccessLog {
 	}
 }
 
+// buildExemplarsAccessLog builds the access log sending the traced requests to the agent, which records
+// them as exemplars of the request duration histograms.
+func buildExemplarsAccessLog() *accesslog.AccessLog {
+	fl := &grpcaccesslog.HttpGrpcAccessLogConfig{
+		CommonConfig: &grpcaccesslog.CommonGrpcAccessLogConfig{
+			LogName: exemplarsAccessLogFriendlyName,
+			GrpcService: &core.GrpcService{
+				TargetSpecifier: &core.GrpcService_GoogleGrpc_{
+					GoogleGrpc: &core.GrpcService_GoogleGrpc{
+						TargetUri:  "unix:" + constants.ExemplarsSocketPath,
+						StatPrefix: exemplarsAccessLogFriendlyName,
+					},
+				},
+			},
+			TransportApiVersion: core.ApiVersion_V3,
+		},
+		AdditionalRequestHeadersToLog: []string{"traceparent", "x-b3-traceid", "x-b3-sampled"},
+	}
+
+	return &accesslog.AccessLog{
+		Name:       wellknown.HTTPGRPCAccessLog,
+		ConfigType: &accesslog.AccessLog_TypedConfig{TypedConfig: util.MessageToAny(fl)},
+		Filter: &accesslog.AccessLogFilter{
+			FilterSpecifier: &accesslog.AccessLogFilter_TraceableFilter{TraceableFilter: &accesslog.TraceableFilter{}},
+		},
+	}
+}
+
 func (b *AccessLogBuilder) reset() {
 	b.mutex.Lock()
 	b.fileAccesslog = nil
@@ -15,6 +15,7 @@
 package v1alpha3
 
 import (
+	"fmt"
 	"testing"
 
 	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
@@ -901,3 +902,46 @@ func TestBuildOpenTelemetryAccessLogConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestExemplarsAccessLog(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		telemetry := fmt.Sprintf(`apiVersion: telemetry.istio.io/v1alpha1
+kind: Telemetry
+metadata:
+  name: default
+  namespace: istio-system
+  annotations:
+    telemetry.istio.io/exemplars: "%v"
+spec: {}
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: default
+spec:
+  hosts:
+  - example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: DNS
+`, enabled)
+		cg := NewConfigGenTest(t, TestOptions{ConfigString: telemetry})
+		found := false
+		for _, l := range cg.Listeners(cg.SetupProxy(nil)) {
+			for _, fc := range l.FilterChains {
+				hcm := xdstest.ExtractHTTPConnectionManager(t, fc)
+				for _, al := range hcm.GetAccessLog() {
+					if al.GetFilter().GetTraceableFilter() != nil && al.Name == wellknown.HTTPGRPCAccessLog {
+						found = true
+					}
+				}
+			}
+		}
+		if found != enabled {
+			t.Fatalf("expected exemplars access log %v, got %v", enabled, found)
+		}
+	}
+}
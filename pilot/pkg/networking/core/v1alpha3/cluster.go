@@ -39,10 +39,12 @@ import (
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/util/gogo"
+	"istio.io/pkg/log"
 )
 
 // defaultTransportSocketMatch applies to endpoints that have no security.istio.io/tlsMode label
@@ -57,7 +59,7 @@ var defaultTransportSocketMatch = &cluster.Cluster_TransportSocketMatch{
 
 // deltaConfigTypes are used to detect changes and trigger delta calculations. When config updates has ONLY entries
 // in this map, then delta calculation is triggered.
-var deltaConfigTypes = sets.NewSet(gvk.ServiceEntry.Kind)
+var deltaConfigTypes = sets.NewSet(gvk.ServiceEntry.Kind, gvk.DestinationRule.Kind)
 
 // getDefaultCircuitBreakerThresholds returns a copy of the default circuit breaker thresholds for the given traffic direction.
 func getDefaultCircuitBreakerThresholds() *cluster.CircuitBreakers_Thresholds {
@@ -81,17 +83,41 @@ func getDefaultCircuitBreakerThresholds() *cluster.CircuitBreakers_Thresholds {
 // For inbound (sidecar only): Cluster for each inbound endpoint port and for each service port
 func (configgen *ConfigGeneratorImpl) BuildClusters(proxy *model.Proxy, req *model.PushRequest) ([]*discovery.Resource, model.XdsLogDetails) {
 	// In Sotw, we care about all services.
-	var services []*model.Service
+	return configgen.buildClusters(proxy, req, proxyServices(proxy, req.Push))
+}
+
+// proxyServices returns the services whose clusters are sent to the proxy.
+func proxyServices(proxy *model.Proxy, push *model.PushContext) []*model.Service {
 	if features.FilterGatewayClusterConfig && proxy.Type == model.Router {
-		services = req.Push.GatewayServices(proxy)
-	} else {
-		services = proxy.SidecarScope.Services()
+		return push.GatewayServices(proxy)
+	}
+	return proxy.SidecarScope.Services()
+}
+
+// BuildWatchedClusters generates the clusters watched by name by a proxy, using deltas when possible. Only the
+// services of the watched clusters are built, rather than all the services of the proxy.
+func (configgen *ConfigGeneratorImpl) BuildWatchedClusters(proxy *model.Proxy, updates *model.PushRequest,
+	watched *model.WatchedResource) ([]*discovery.Resource, []string, model.XdsLogDetails, bool) {
+	if shouldUseDelta(updates) {
+		return configgen.BuildDeltaClusters(proxy, updates, watched)
 	}
-	return configgen.buildClusters(proxy, req, services)
+	hostnames := sets.NewSet()
+	for _, cluster := range watched.ResourceNames {
+		_, _, svcHost, _ := model.ParseSubsetKey(cluster)
+		hostnames.Insert(string(svcHost))
+	}
+	services := make([]*model.Service, 0, len(hostnames))
+	for _, service := range proxyServices(proxy, updates.Push) {
+		if hostnames.Contains(string(service.Hostname)) {
+			services = append(services, service)
+		}
+	}
+	clusters, log := configgen.buildClusters(proxy, updates, services)
+	return clusters, nil, log, false
 }
 
-// BuildDeltaClusters generates the deltas (add and delete) for a given proxy. Currently, only service changes are reflected with deltas.
-// Otherwise, we fall back onto generating everything.
+// BuildDeltaClusters generates the deltas (add and delete) for a given proxy. Currently, only service and destination
+// rule changes are reflected with deltas. Otherwise, we fall back onto generating everything.
 func (configgen *ConfigGeneratorImpl) BuildDeltaClusters(proxy *model.Proxy, updates *model.PushRequest,
 	watched *model.WatchedResource) ([]*discovery.Resource, []string, model.XdsLogDetails, bool) {
 	// if we can't use delta, fall back to generate all
@@ -102,6 +128,8 @@ func (configgen *ConfigGeneratorImpl) BuildDeltaClusters(proxy *model.Proxy, upd
 
 	deletedClusters := make([]string, 0)
 	services := make([]*model.Service, 0)
+	// holds the hostnames of the services to rebuild, as a service may be changed by several configs.
+	updatedServices := sets.NewSet()
 	// holds clusters per service, keyed by hostname.
 	serviceClusters := make(map[string]sets.Set)
 	// holds service ports, keyed by hostname.
@@ -122,8 +150,24 @@ func (configgen *ConfigGeneratorImpl) BuildDeltaClusters(proxy *model.Proxy, upd
 		servicePorts[string(svcHost)][port] = cluster
 	}
 
+	addService := func(service *model.Service) {
+		if updatedServices.Contains(service.Hostname.String()) {
+			return
+		}
+		updatedServices.Insert(service.Hostname.String())
+		services = append(services, service)
+	}
+
 	// In delta, we only care about the services that have changed.
 	for key := range updates.ConfigsUpdated {
+		if key.Kind == gvk.DestinationRule {
+			changed, deleted := deltaFromDestinationRule(key, proxy, updates.Push, serviceClusters)
+			for _, service := range changed {
+				addService(service)
+			}
+			deletedClusters = append(deletedClusters, deleted...)
+			continue
+		}
 		// get the service that has changed.
 		service := updates.Push.ServiceForHostname(proxy, host.Name(key.Name))
 		// if this service removed, we can conclude that it is a removed cluster.
@@ -132,7 +176,7 @@ func (configgen *ConfigGeneratorImpl) BuildDeltaClusters(proxy *model.Proxy, upd
 				deletedClusters = append(deletedClusters, cluster)
 			}
 		} else {
-			services = append(services, service)
+			addService(service)
 			// If servicePorts has this service, that means it is old service.
 			if servicePorts[service.Hostname.String()] != nil {
 				oldPorts := servicePorts[service.Hostname.String()]
@@ -149,6 +193,43 @@ func (configgen *ConfigGeneratorImpl) BuildDeltaClusters(proxy *model.Proxy, upd
 	return clusters, deletedClusters, log, true
 }
 
+// deltaFromDestinationRule returns the services whose clusters are changed by the destination rule, which applied to
+// them before or after the change, and the watched subset clusters of these services it no longer defines.
+func deltaFromDestinationRule(key model.ConfigKey, proxy *model.Proxy, push *model.PushContext,
+	serviceClusters map[string]sets.Set) ([]*model.Service, []string) {
+	hosts := proxy.PrevSidecarScope.DestinationRuleHosts(key.Name, key.Namespace)
+	hosts = append(hosts, proxy.SidecarScope.DestinationRuleHosts(key.Name, key.Namespace)...)
+
+	var services []*model.Service
+	var deleted []string
+	seen := sets.NewSet()
+	for _, h := range hosts {
+		if seen.Contains(string(h)) {
+			continue
+		}
+		seen.Insert(string(h))
+		service := push.ServiceForHostname(proxy, h)
+		if service == nil {
+			continue
+		}
+		services = append(services, service)
+
+		subsets := sets.NewSet()
+		if dr := proxy.SidecarScope.DestinationRule(h); dr != nil {
+			for _, subset := range dr.Spec.(*networking.DestinationRule).GetSubsets() {
+				subsets.Insert(subset.Name)
+			}
+		}
+		for _, cluster := range serviceClusters[string(h)].SortedList() {
+			// clusters of the subsets removed from the destination rule, or of all of its subsets if it was deleted.
+			if _, subset, _, _ := model.ParseSubsetKey(cluster); subset != "" && !subsets.Contains(subset) {
+				deleted = append(deleted, cluster)
+			}
+		}
+	}
+	return services, deleted
+}
+
 // buildClusters builds clusters for the proxy with the services passed.
 func (configgen *ConfigGeneratorImpl) buildClusters(proxy *model.Proxy, req *model.PushRequest,
 	services []*model.Service) ([]*discovery.Resource, model.XdsLogDetails) {
@@ -167,6 +248,10 @@ func (configgen *ConfigGeneratorImpl) buildClusters(proxy *model.Proxy, req *mod
 		resources = append(resources, ob...)
 		// Add a blackhole and passthrough cluster for catching traffic to unresolved routes
 		clusters = outboundPatcher.conditionallyAppend(clusters, nil, cb.buildBlackHoleCluster(), cb.buildDefaultPassthroughCluster())
+		if util.IsOutboundTrafficAudited(proxy) {
+			clusters = outboundPatcher.conditionallyAppend(clusters, nil, cb.buildAuditPassthroughCluster())
+		}
+		clusters = outboundPatcher.conditionallyAppend(clusters, nil, cb.buildCrossNetworkTunnelClusters(proxy)...)
 		clusters = append(clusters, outboundPatcher.insertedClusters()...)
 
 		// Setup inbound clusters
@@ -182,6 +267,7 @@ func (configgen *ConfigGeneratorImpl) buildClusters(proxy *model.Proxy, req *mod
 		resources = append(resources, ob...)
 		// Gateways do not require the default passthrough cluster as they do not have original dst listeners.
 		clusters = patcher.conditionallyAppend(clusters, nil, cb.buildBlackHoleCluster())
+		clusters = patcher.conditionallyAppend(clusters, nil, cb.buildCrossNetworkTunnelClusters(proxy)...)
 		if proxy.Type == model.Router && proxy.MergedGateway != nil && proxy.MergedGateway.ContainsAutoPassthroughGateways {
 			clusters = append(clusters, configgen.buildOutboundSniDnatClusters(proxy, req, patcher)...)
 		}
@@ -240,10 +326,14 @@ func buildClusterKey(service *model.Service, port *model.Port, cb *ClusterBuilde
 		http2:           port.Protocol.IsHTTP2(),
 		downstreamAuto:  cb.sidecarProxy() && util.IsProtocolSniffingEnabledForOutboundPort(port),
 		supportsIPv4:    cb.supportsIPv4,
+		supportsIPv6:    cb.supportsIPv6,
+		dualStack:       cb.dualStack,
 		service:         service,
 		destinationRule: proxy.SidecarScope.DestinationRule(service.Hostname),
 		envoyFilterKeys: efKeys,
 		metadataCerts:   cb.metadataCerts,
+		tlsPolicy:       cb.tlsPolicy.String(),
+		limits:          cb.connectionLimits.Key(),
 		peerAuthVersion: cb.req.Push.AuthnPolicies.GetVersion(),
 		serviceAccounts: cb.req.Push.ServiceAccounts[service.Hostname][port.Port],
 	}
@@ -589,6 +679,15 @@ const (
 	DefaultClusterMode ClusterMode = "outbound"
 )
 
+const (
+	// SlowStartAggressionAnnotation can be set on a DestinationRule to control how quickly traffic to new
+	// endpoints increases during the warmup window configured with warmupDurationSecs.
+	SlowStartAggressionAnnotation = "networking.istio.io/warmupAggression"
+
+	// slowStartAggressionRuntimeKey allows overriding the aggression at runtime in Envoy.
+	slowStartAggressionRuntimeKey = "upstream.slow_start_aggression"
+)
+
 type buildClusterOpts struct {
 	mesh             *meshconfig.MeshConfig
 	mutable          *MutableCluster
@@ -613,6 +712,8 @@ type buildClusterOpts struct {
 	// Indicates the service registry of the cluster being built.
 	serviceRegistry provider.ID
 	cache           model.XdsCache
+	// slowStartAggression overrides the Envoy default slow start aggression, if set.
+	slowStartAggression float64
 }
 
 type upgradeTuple struct {
@@ -769,7 +870,12 @@ func applyLoadBalancer(c *cluster.Cluster, lb *networking.LoadBalancerSettings,
 		c.LbPolicy = cluster.Cluster_CLUSTER_PROVIDED
 		c.ClusterDiscoveryType = &cluster.Cluster_Type{Type: cluster.Cluster_ORIGINAL_DST}
 	default:
-		c.LbPolicy = defaultLBAlgorithm()
+		// Apply the default algorithm explicitly, so slow start is honored when no algorithm is set.
+		if defaultLBAlgorithm() == cluster.Cluster_ROUND_ROBIN {
+			ApplyRoundRobinLoadBalancer(c, lb)
+		} else {
+			ApplyLeastRequestLoadBalancer(c, lb)
+		}
 	}
 
 	ApplyRingHashLoadBalancer(c, lb)
@@ -804,7 +910,49 @@ func ApplyLeastRequestLoadBalancer(c *cluster.Cluster, loadbalancer *networking.
 // setSlowStartConfig will set the warmupDurationSecs for LEAST_REQUEST and ROUND_ROBIN if provided in DestinationRule
 func setSlowStartConfig(warmupDurationSecs *types.Duration) *cluster.Cluster_SlowStartConfig {
 	return &cluster.Cluster_SlowStartConfig{
-		SlowStartWindow: &durationpb.Duration{Seconds: warmupDurationSecs.GetSeconds()},
+		SlowStartWindow: &durationpb.Duration{Seconds: warmupDurationSecs.GetSeconds(), Nanos: warmupDurationSecs.GetNanos()},
+	}
+}
+
+// slowStartAggression returns the slow start aggression configured on the DestinationRule with
+// SlowStartAggressionAnnotation, or 0 if it is not set or invalid.
+func slowStartAggression(destRule *config.Config) float64 {
+	if destRule == nil {
+		return 0
+	}
+	v, f := destRule.Annotations[SlowStartAggressionAnnotation]
+	if !f {
+		return 0
+	}
+	aggression, err := strconv.ParseFloat(v, 64)
+	if err != nil || aggression <= 0 {
+		log.Warnf("invalid %s annotation %q on destination rule %s/%s", SlowStartAggressionAnnotation, v, destRule.Namespace, destRule.Name)
+		return 0
+	}
+	return aggression
+}
+
+// applySlowStartAggression sets the aggression of the cluster's slow start config, if slow start is enabled.
+// Envoy scales the weight of new endpoints by time_factor^(1/aggression): an aggression of 1.0 (the Envoy default)
+// increases traffic linearly over the warmup window, larger values ramp traffic up faster and smaller values keep
+// traffic to new endpoints lower for longer.
+func applySlowStartAggression(c *cluster.Cluster, aggression float64) {
+	if aggression <= 0 {
+		return
+	}
+	var ssc *cluster.Cluster_SlowStartConfig
+	switch c.LbPolicy {
+	case cluster.Cluster_ROUND_ROBIN:
+		ssc = c.GetRoundRobinLbConfig().GetSlowStartConfig()
+	case cluster.Cluster_LEAST_REQUEST:
+		ssc = c.GetLeastRequestLbConfig().GetSlowStartConfig()
+	}
+	if ssc == nil {
+		return
+	}
+	ssc.Aggression = &core.RuntimeDouble{
+		DefaultValue: aggression,
+		RuntimeKey:   slowStartAggressionRuntimeKey,
 	}
 }
 
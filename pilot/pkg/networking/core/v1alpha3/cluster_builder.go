@@ -102,6 +102,7 @@ type metadataCerts struct {
 // ClusterBuilder interface provides an abstraction for building Envoy Clusters.
 type ClusterBuilder struct {
 	// Proxy related information used to build clusters.
+	proxy             *model.Proxy             // Proxy the clusters are built for.
 	serviceInstances  []*model.ServiceInstance // Service instances of Proxy.
 	metadataCerts     *metadataCerts           // Client certificates specified in metadata.
 	clusterID         string                   // Cluster in which proxy is running.
@@ -112,11 +113,14 @@ type ClusterBuilder struct {
 	passThroughBindIP string                   // Passthrough IP to be used while building clusters.
 	supportsIPv4      bool                     // Whether Proxy IPs has IPv4 address.
 	supportsIPv6      bool                     // Whether Proxy IPs has IPv6 address.
+	dualStack         bool                     // Whether Proxy uses both IP families of dual-stack services.
 	locality          *core.Locality           // Locality information of proxy.
 	proxyLabels       map[string]string        // Proxy labels.
 	networkView       map[network.ID]bool      // Proxy network view.
 	proxyIPAddresses  []string                 // IP addresses on which proxy is listening on.
 	configNamespace   string                   // Proxy config namespace.
+	tlsPolicy         util.TLSPolicy           // TLS policy enforced on upstream TLS.
+	connectionLimits  model.ConnectionLimits   // Limits of the connection buffers and HTTP/2 settings.
 	// PushRequest to look for updates.
 	req   *model.PushRequest
 	cache model.XdsCache
@@ -125,6 +129,7 @@ type ClusterBuilder struct {
 // NewClusterBuilder builds an instance of ClusterBuilder.
 func NewClusterBuilder(proxy *model.Proxy, req *model.PushRequest, cache model.XdsCache) *ClusterBuilder {
 	cb := &ClusterBuilder{
+		proxy:             proxy,
 		serviceInstances:  proxy.ServiceInstances,
 		proxyID:           proxy.ID,
 		proxyType:         proxy.Type,
@@ -133,6 +138,7 @@ func NewClusterBuilder(proxy *model.Proxy, req *model.PushRequest, cache model.X
 		passThroughBindIP: getPassthroughBindIP(proxy),
 		supportsIPv4:      proxy.SupportsIPv4(),
 		supportsIPv6:      proxy.SupportsIPv6(),
+		dualStack:         proxy.DualStackEnabled(),
 		locality:          proxy.Locality,
 		proxyLabels:       proxy.Metadata.Labels,
 		networkView:       proxy.GetNetworkView(),
@@ -151,6 +157,8 @@ func NewClusterBuilder(proxy *model.Proxy, req *model.PushRequest, cache model.X
 		}
 		cb.clusterID = string(proxy.Metadata.ClusterID)
 	}
+	cb.tlsPolicy = util.TLSPolicyForProxy(proxy)
+	cb.connectionLimits = model.ConnectionLimitsForProxy(proxy)
 	return cb
 }
 
@@ -249,6 +257,8 @@ func (cb *ClusterBuilder) applyDestinationRule(mc *MutableCluster, clusterMode C
 		clusterMode:      clusterMode,
 		direction:        model.TrafficDirectionOutbound,
 		cache:            cb.cache,
+
+		slowStartAggression: slowStartAggression(destRule),
 	}
 
 	if clusterMode == DefaultClusterMode {
@@ -349,10 +359,15 @@ func (cb *ClusterBuilder) buildDefaultCluster(name string, discoveryType cluster
 		Name:                 name,
 		ClusterDiscoveryType: &cluster.Cluster_Type{Type: discoveryType},
 	}
+	if cb.connectionLimits.PerConnectionBufferLimitBytes != 0 {
+		c.PerConnectionBufferLimitBytes = &wrappers.UInt32Value{Value: cb.connectionLimits.PerConnectionBufferLimitBytes}
+	}
 	ec := NewMutableCluster(c)
 	switch discoveryType {
 	case cluster.Cluster_STRICT_DNS, cluster.Cluster_LOGICAL_DNS:
-		if cb.supportsIPv4 {
+		if cb.dualStack && cb.supportsIPv4 && cb.supportsIPv6 {
+			c.DnsLookupFamily = cluster.Cluster_ALL
+		} else if cb.supportsIPv4 {
 			c.DnsLookupFamily = cluster.Cluster_V4_ONLY
 		} else {
 			c.DnsLookupFamily = cluster.Cluster_V6_ONLY
@@ -412,11 +427,15 @@ type clusterCache struct {
 	proxySidecar   bool           // identifies if this proxy is a Sidecar
 	networkView    map[network.ID]bool
 	metadataCerts  *metadataCerts // metadata certificates of proxy
+	tlsPolicy      string         // TLS policy enforced for the proxy
+	limits         string         // connection limits of the proxy
 
 	// service attributes
 	http2          bool // http2 identifies if the cluster is for an http2 service
 	downstreamAuto bool
 	supportsIPv4   bool
+	supportsIPv6   bool
+	dualStack      bool
 
 	// Dependent configs
 	service         *model.Service
@@ -431,6 +450,7 @@ func (t *clusterCache) Key() string {
 		t.clusterName, t.proxyVersion, util.LocalityToString(t.locality),
 		t.proxyClusterID, strconv.FormatBool(t.proxySidecar),
 		strconv.FormatBool(t.http2), strconv.FormatBool(t.downstreamAuto), strconv.FormatBool(t.supportsIPv4),
+		strconv.FormatBool(t.supportsIPv6), strconv.FormatBool(t.dualStack),
 	}
 	if t.networkView != nil {
 		nv := make([]string, 0, len(t.networkView))
@@ -443,6 +463,12 @@ func (t *clusterCache) Key() string {
 	if t.metadataCerts != nil {
 		params = append(params, t.metadataCerts.String())
 	}
+	if t.tlsPolicy != "" {
+		params = append(params, t.tlsPolicy)
+	}
+	if t.limits != "" {
+		params = append(params, t.limits)
+	}
 	if t.service != nil {
 		params = append(params, string(t.service.Hostname)+"/"+t.service.Attributes.Namespace)
 	}
@@ -585,6 +611,10 @@ func (cb *ClusterBuilder) buildLocalityLbEndpoints(proxyNetworkView map[network.
 		if !instance.Endpoint.IsDiscoverableFromProxy(&model.Proxy{Metadata: &model.NodeMetadata{ClusterID: istio_cluster.ID(cb.clusterID)}}) {
 			continue
 		}
+		// The secondary addresses of a workload are not load balanced to.
+		if instance.Endpoint.Secondary || (cb.dualStack && !cb.proxy.SupportsAddress(instance.Endpoint.Address)) {
+			continue
+		}
 		addr := util.BuildAddress(instance.Endpoint.Address, instance.Endpoint.EndpointPort)
 		ep := &endpoint.LbEndpoint{
 			HostIdentifier: &endpoint.LbEndpoint_Endpoint{
@@ -706,6 +736,14 @@ func (cb *ClusterBuilder) buildDefaultPassthroughCluster() *cluster.Cluster {
 	return cluster
 }
 
+// generates a cluster that sends traffic to the original destination, for the traffic REGISTRY_ONLY would block
+// when outbound traffic is audited.
+func (cb *ClusterBuilder) buildAuditPassthroughCluster() *cluster.Cluster {
+	c := cb.buildDefaultPassthroughCluster()
+	c.Name = util.AuditPassthroughCluster
+	return c
+}
+
 // applyH2Upgrade function will upgrade outbound cluster to http2 if specified by configuration.
 func (cb *ClusterBuilder) applyH2Upgrade(opts buildClusterOpts, connectionPool *networking.ConnectionPoolSettings) {
 	if cb.shouldH2Upgrade(opts.mutable.cluster.Name, opts.direction, opts.port, opts.mesh, connectionPool) {
@@ -765,7 +803,7 @@ func (cb *ClusterBuilder) setH2Options(mc *MutableCluster) {
 		options.UpstreamProtocolOptions = &http.HttpProtocolOptions_ExplicitHttpConfig_{
 			ExplicitHttpConfig: &http.HttpProtocolOptions_ExplicitHttpConfig{
 				ProtocolConfig: &http.HttpProtocolOptions_ExplicitHttpConfig_Http2ProtocolOptions{
-					Http2ProtocolOptions: http2ProtocolOptions(),
+					Http2ProtocolOptions: cb.http2ProtocolOptions(),
 				},
 			},
 		}
@@ -783,6 +821,7 @@ func (cb *ClusterBuilder) applyTrafficPolicy(opts buildClusterOpts) {
 		cb.applyH2Upgrade(opts, connectionPool)
 		applyOutlierDetection(opts.mutable.cluster, outlierDetection)
 		applyLoadBalancer(opts.mutable.cluster, loadBalancer, opts.port, cb.locality, cb.proxyLabels, opts.mesh)
+		applySlowStartAggression(opts.mutable.cluster, opts.slowStartAggression)
 		if opts.clusterMode != SniDnatClusterMode {
 			autoMTLSEnabled := opts.mesh.GetEnableAutoMtls().Value
 			tls, mtlsCtxType := cb.buildAutoMtlsSettings(tls, opts.serviceAccounts, opts.istioMtlsSni,
@@ -1135,6 +1174,9 @@ func (cb *ClusterBuilder) buildUpstreamClusterTLSContext(opts *buildClusterOpts,
 			tlsContext.CommonTlsContext.AlpnProtocols = util.ALPNH2Only
 		}
 	}
+	if tlsContext != nil {
+		cb.tlsPolicy.Apply(tlsContext.CommonTlsContext)
+	}
 	return tlsContext, nil
 }
 
@@ -1146,11 +1188,19 @@ func (cb *ClusterBuilder) setUseDownstreamProtocol(mc *MutableCluster) {
 	options.UpstreamProtocolOptions = &http.HttpProtocolOptions_UseDownstreamProtocolConfig{
 		UseDownstreamProtocolConfig: &http.HttpProtocolOptions_UseDownstreamHttpConfig{
 			HttpProtocolOptions:  &core.Http1ProtocolOptions{},
-			Http2ProtocolOptions: http2ProtocolOptions(),
+			Http2ProtocolOptions: cb.http2ProtocolOptions(),
 		},
 	}
 }
 
+// http2ProtocolOptions returns the HTTP/2 protocol options of the upstream connections, with the HTTP/2 settings of
+// the connection limits of the proxy.
+func (cb *ClusterBuilder) http2ProtocolOptions() *core.Http2ProtocolOptions {
+	options := http2ProtocolOptions()
+	applyHTTP2Limits(options, cb.connectionLimits)
+	return options
+}
+
 func http2ProtocolOptions() *core.Http2ProtocolOptions {
 	return &core.Http2ProtocolOptions{
 		// Envoy default value of 100 is too low for data path.
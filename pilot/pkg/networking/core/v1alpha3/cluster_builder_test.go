@@ -1060,6 +1060,33 @@ func TestBuildDefaultCluster(t *testing.T) {
 	}
 }
 
+func TestBuildDefaultClusterDualStack(t *testing.T) {
+	servicePort := &model.Port{Name: "default", Port: 8080, Protocol: protocol.HTTP}
+	service := &model.Service{
+		Ports:      model.PortList{servicePort},
+		Hostname:   "host",
+		Attributes: model.ServiceAttributes{Name: "svc", Namespace: "default"},
+	}
+	endpoints := []*endpoint.LocalityLbEndpoints{{LbEndpoints: []*endpoint.LbEndpoint{}}}
+	for _, tt := range []struct {
+		ips    []string
+		family cluster.Cluster_DnsLookupFamily
+	}{
+		{[]string{"1.1.1.1"}, cluster.Cluster_V4_ONLY},
+		{[]string{"2001:db8::1"}, cluster.Cluster_V6_ONLY},
+		{[]string{"1.1.1.1", "2001:db8::1"}, cluster.Cluster_ALL},
+	} {
+		cg := NewConfigGenTest(t, TestOptions{})
+		proxy := cg.SetupProxy(&model.Proxy{IPAddresses: tt.ips, Metadata: &model.NodeMetadata{DualStack: true}})
+		cb := NewClusterBuilder(proxy, &model.PushRequest{Push: cg.PushContext()}, nil)
+		c := cb.buildDefaultCluster("outbound|8080||host", cluster.Cluster_STRICT_DNS, endpoints,
+			model.TrafficDirectionOutbound, servicePort, service, nil)
+		if got := c.cluster.DnsLookupFamily; got != tt.family {
+			t.Errorf("proxy with IPs %v: got DNS lookup family %v, want %v", tt.ips, got, tt.family)
+		}
+	}
+}
+
 func TestBuildLocalityLbEndpoints(t *testing.T) {
 	proxy := &model.Proxy{
 		Metadata: &model.NodeMetadata{
@@ -254,6 +254,7 @@ type clusterTest struct {
 	locality          *core.Locality
 	mesh              meshconfig.MeshConfig
 	destRule          proto.Message
+	destRuleMeta      map[string]string
 	peerAuthn         *authn_beta.PeerAuthentication
 	externalService   bool
 
@@ -366,6 +367,7 @@ func buildTestClusters(c clusterTest) []*cluster.Cluster {
 			Meta: config.Meta{
 				GroupVersionKind: gvk.DestinationRule,
 				Name:             "acme",
+				Annotations:      c.destRuleMeta,
 			},
 			Spec: c.destRule,
 		})
@@ -1316,16 +1318,39 @@ func TestSlowStartConfig(t *testing.T) {
 		name             string
 		lbType           networking.LoadBalancerSettings_SimpleLB
 		slowStartEnabled bool
+		aggression       string
+		wantAggression   float64
 	}{
 		{name: "roundrobin", lbType: networking.LoadBalancerSettings_ROUND_ROBIN, slowStartEnabled: true},
 		{name: "leastrequest", lbType: networking.LoadBalancerSettings_LEAST_REQUEST, slowStartEnabled: true},
+		{name: "default", lbType: networking.LoadBalancerSettings_UNSPECIFIED, slowStartEnabled: true},
 		{name: "passthrough", lbType: networking.LoadBalancerSettings_PASSTHROUGH, slowStartEnabled: true},
 		{name: "roundrobin-without-warmup", lbType: networking.LoadBalancerSettings_ROUND_ROBIN, slowStartEnabled: false},
 		{name: "leastrequest-without-warmup", lbType: networking.LoadBalancerSettings_LEAST_REQUEST, slowStartEnabled: false},
+		{
+			name: "roundrobin-aggression", lbType: networking.LoadBalancerSettings_ROUND_ROBIN, slowStartEnabled: true,
+			aggression: "1.5", wantAggression: 1.5,
+		},
+		{
+			name: "leastrequest-aggression", lbType: networking.LoadBalancerSettings_LEAST_REQUEST, slowStartEnabled: true,
+			aggression: "2", wantAggression: 2,
+		},
+		{
+			name: "invalid-aggression", lbType: networking.LoadBalancerSettings_ROUND_ROBIN, slowStartEnabled: true,
+			aggression: "-1",
+		},
+		{
+			name: "aggression-without-warmup", lbType: networking.LoadBalancerSettings_ROUND_ROBIN, slowStartEnabled: false,
+			aggression: "1.5",
+		},
 	}
 
 	for _, test := range testcases {
 		t.Run(test.name, func(t *testing.T) {
+			var meta map[string]string
+			if test.aggression != "" {
+				meta = map[string]string{SlowStartAggressionAnnotation: test.aggression}
+			}
 			clusters := buildTestClusters(clusterTest{
 				t:               t,
 				serviceHostname: test.name,
@@ -1335,6 +1360,7 @@ func TestSlowStartConfig(t *testing.T) {
 					Host:          test.name,
 					TrafficPolicy: getSlowStartTrafficPolicy(test.slowStartEnabled, test.lbType),
 				},
+				destRuleMeta: meta,
 			})
 
 			c := xdstest.ExtractCluster("outbound|8080||"+test.name,
@@ -1343,13 +1369,21 @@ func TestSlowStartConfig(t *testing.T) {
 			if !test.slowStartEnabled {
 				g.Expect(c.GetLbConfig()).To(BeNil())
 			} else {
+				var ssc *cluster.Cluster_SlowStartConfig
 				switch c.LbPolicy {
 				case cluster.Cluster_ROUND_ROBIN:
-					g.Expect(c.GetRoundRobinLbConfig().GetSlowStartConfig().GetSlowStartWindow().Seconds).To(Equal(int64(15)))
+					ssc = c.GetRoundRobinLbConfig().GetSlowStartConfig()
 				case cluster.Cluster_LEAST_REQUEST:
-					g.Expect(c.GetLeastRequestLbConfig().GetSlowStartConfig().GetSlowStartWindow().Seconds).To(Equal(int64(15)))
+					ssc = c.GetLeastRequestLbConfig().GetSlowStartConfig()
 				default:
 					g.Expect(c.GetLbConfig()).To(BeNil())
+					return
+				}
+				g.Expect(ssc.GetSlowStartWindow().Seconds).To(Equal(int64(15)))
+				if test.wantAggression == 0 {
+					g.Expect(ssc.GetAggression()).To(BeNil())
+				} else {
+					g.Expect(ssc.GetAggression().GetDefaultValue()).To(Equal(test.wantAggression))
 				}
 			}
 		})
@@ -2518,10 +2552,36 @@ func TestBuildDeltaClusters(t *testing.T) {
 		},
 	}
 
+	destinationRule := func(subsets ...string) config.Config {
+		dr := &networking.DestinationRule{Host: "test.com"}
+		for _, subset := range subsets {
+			dr.Subsets = append(dr.Subsets, &networking.Subset{Name: subset, Labels: map[string]string{"version": subset}})
+		}
+		return config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: gvk.DestinationRule,
+				Name:             "test-dr",
+				Namespace:        TestServiceNamespace,
+			},
+			Spec: dr,
+		}
+	}
+	drKey := model.ConfigKey{Kind: gvk.DestinationRule, Name: "test-dr", Namespace: TestServiceNamespace}
+	// mergedDestinationRule is merged into test-dr, which keeps its name.
+	mergedDestinationRule := func(subsets ...string) config.Config {
+		dr := destinationRule(subsets...)
+		dr.Name = "test-dr-merged"
+		return dr
+	}
+	mergedDRKey := model.ConfigKey{Kind: gvk.DestinationRule, Name: "test-dr-merged", Namespace: TestServiceNamespace}
+
 	// TODO: Add more test cases.
 	testCases := []struct {
-		name                 string
-		services             []*model.Service
+		name     string
+		services []*model.Service
+		// prevConfigs are the configs of the previous SidecarScope of the proxy.
+		prevConfigs          []config.Config
+		configs              []config.Config
 		configUpdated        map[model.ConfigKey]struct{}
 		watchedResourceNames []string
 		usedDelta            bool
@@ -2555,10 +2615,61 @@ func TestBuildDeltaClusters(t *testing.T) {
 			removedClusters:      []string{"outbound|7070||test.com"},
 			expectedClusters:     []string{"BlackHoleCluster", "InboundPassthroughClusterIpv4", "PassthroughCluster", "outbound|8080||test.com"},
 		},
+		{
+			name:                 "destination rule is added",
+			services:             []*model.Service{testService1, testService2},
+			configs:              []config.Config{destinationRule("v1")},
+			configUpdated:        map[model.ConfigKey]struct{}{drKey: {}},
+			watchedResourceNames: []string{"outbound|8080||test.com", "outbound|8080||testnew.com"},
+			usedDelta:            true,
+			removedClusters:      []string{},
+			expectedClusters: []string{
+				"BlackHoleCluster", "InboundPassthroughClusterIpv4", "PassthroughCluster",
+				"outbound|8080|v1|test.com", "outbound|8080||test.com",
+			},
+		},
+		{
+			name:                 "destination rule subset is removed",
+			services:             []*model.Service{testService1, testService2},
+			prevConfigs:          []config.Config{destinationRule("v1", "v2")},
+			configs:              []config.Config{destinationRule("v1")},
+			configUpdated:        map[model.ConfigKey]struct{}{drKey: {}},
+			watchedResourceNames: []string{"outbound|8080||test.com", "outbound|8080|v1|test.com", "outbound|8080|v2|test.com"},
+			usedDelta:            true,
+			removedClusters:      []string{"outbound|8080|v2|test.com"},
+			expectedClusters: []string{
+				"BlackHoleCluster", "InboundPassthroughClusterIpv4", "PassthroughCluster",
+				"outbound|8080|v1|test.com", "outbound|8080||test.com",
+			},
+		},
+		{
+			name:                 "merged destination rule subset is removed",
+			services:             []*model.Service{testService1, testService2},
+			prevConfigs:          []config.Config{destinationRule("v1"), mergedDestinationRule("v2")},
+			configs:              []config.Config{destinationRule("v1"), mergedDestinationRule()},
+			configUpdated:        map[model.ConfigKey]struct{}{mergedDRKey: {}},
+			watchedResourceNames: []string{"outbound|8080||test.com", "outbound|8080|v1|test.com", "outbound|8080|v2|test.com"},
+			usedDelta:            true,
+			removedClusters:      []string{"outbound|8080|v2|test.com"},
+			expectedClusters: []string{
+				"BlackHoleCluster", "InboundPassthroughClusterIpv4", "PassthroughCluster",
+				"outbound|8080|v1|test.com", "outbound|8080||test.com",
+			},
+		},
+		{
+			name:                 "destination rule is removed",
+			services:             []*model.Service{testService1, testService2},
+			prevConfigs:          []config.Config{destinationRule("v1")},
+			configUpdated:        map[model.ConfigKey]struct{}{drKey: {}},
+			watchedResourceNames: []string{"outbound|8080||test.com", "outbound|8080|v1|test.com", "outbound|8080||testnew.com"},
+			usedDelta:            true,
+			removedClusters:      []string{"outbound|8080|v1|test.com"},
+			expectedClusters:     []string{"BlackHoleCluster", "InboundPassthroughClusterIpv4", "PassthroughCluster", "outbound|8080||test.com"},
+		},
 		{
 			name:                 "config update that is not delta aware",
 			services:             []*model.Service{testService1, testService2},
-			configUpdated:        map[model.ConfigKey]struct{}{{Kind: gvk.DestinationRule, Name: "test.com", Namespace: TestServiceNamespace}: {}},
+			configUpdated:        map[model.ConfigKey]struct{}{{Kind: gvk.VirtualService, Name: "test.com", Namespace: TestServiceNamespace}: {}},
 			watchedResourceNames: []string{"outbound|7070||test.com"},
 			usedDelta:            false,
 			removedClusters:      nil,
@@ -2571,10 +2682,17 @@ func TestBuildDeltaClusters(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
+			prev := NewConfigGenTest(t, TestOptions{
+				Services: tc.services,
+				Configs:  tc.prevConfigs,
+			})
 			cg := NewConfigGenTest(t, TestOptions{
 				Services: tc.services,
+				Configs:  tc.configs,
 			})
-			clusters, removed, delta := cg.DeltaClusters(cg.SetupProxy(nil), tc.configUpdated,
+			proxy := cg.SetupProxy(nil)
+			proxy.PrevSidecarScope = prev.SetupProxy(nil).SidecarScope
+			clusters, removed, delta := cg.DeltaClusters(proxy, tc.configUpdated,
 				&model.WatchedResource{ResourceNames: tc.watchedResourceNames})
 			if delta != tc.usedDelta {
 				t.Errorf("un expected delta, want %v got %v", tc.usedDelta, delta)
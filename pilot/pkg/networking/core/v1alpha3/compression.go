@@ -0,0 +1,93 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	brotlicompressor "github.com/envoyproxy/go-control-plane/envoy/extensions/compression/brotli/compressor/v3"
+	brotlidecompressor "github.com/envoyproxy/go-control-plane/envoy/extensions/compression/brotli/decompressor/v3"
+	gzipcompressor "github.com/envoyproxy/go-control-plane/envoy/extensions/compression/gzip/compressor/v3"
+	gzipdecompressor "github.com/envoyproxy/go-control-plane/envoy/extensions/compression/gzip/decompressor/v3"
+	compressor "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/compressor/v3"
+	decompressor "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/decompressor/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/proto"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/model"
+	istionetworking "istio.io/istio/pilot/pkg/networking"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+const (
+	compressorFilterPrefix   = "envoy.filters.http.compressor."
+	decompressorFilterPrefix = "envoy.filters.http.decompressor."
+)
+
+var (
+	compressorLibraries = map[string]proto.Message{
+		model.CompressionGzip:   &gzipcompressor.Gzip{},
+		model.CompressionBrotli: &brotlicompressor.Brotli{},
+	}
+	decompressorLibraries = map[string]proto.Message{
+		model.CompressionGzip:   &gzipdecompressor.Gzip{},
+		model.CompressionBrotli: &brotlidecompressor.Brotli{},
+	}
+)
+
+// buildCompressionFilters returns the compressor filters of the responses sent by the listener, and the
+// decompressor filters of the requests and responses it proxies, as configured by the Telemetry of the proxy.
+// Responses are only compressed by gateways and inbound sidecar listeners, as the application of an outbound
+// listener is on the same host.
+func buildCompressionFilters(opts buildListenerOpts) (compressors []*hcm.HttpFilter, decompressors []*hcm.HttpFilter) {
+	config := opts.push.Telemetry.Compression(opts.proxy)
+	if config == nil {
+		return nil, nil
+	}
+	if opts.class != istionetworking.ListenerClassSidecarOutbound {
+		for _, algorithm := range config.Algorithms {
+			compressors = append(compressors, buildCompressorFilter(algorithm, config))
+		}
+	}
+	for _, algorithm := range config.Decompression {
+		decompressors = append(decompressors, &hcm.HttpFilter{
+			Name: decompressorFilterPrefix + algorithm,
+			ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(&decompressor.Decompressor{
+				DecompressorLibrary: &core.TypedExtensionConfig{
+					Name:        algorithm,
+					TypedConfig: util.MessageToAny(decompressorLibraries[algorithm]),
+				},
+			})},
+		})
+	}
+	return compressors, decompressors
+}
+
+func buildCompressorFilter(algorithm string, config *model.CompressionConfig) *hcm.HttpFilter {
+	common := &compressor.Compressor_CommonDirectionConfig{ContentType: config.ContentTypes}
+	if config.MinContentLength > 0 {
+		common.MinContentLength = &wrappers.UInt32Value{Value: config.MinContentLength}
+	}
+	return &hcm.HttpFilter{
+		Name: compressorFilterPrefix + algorithm,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(&compressor.Compressor{
+			CompressorLibrary: &core.TypedExtensionConfig{
+				Name:        algorithm,
+				TypedConfig: util.MessageToAny(compressorLibraries[algorithm]),
+			},
+			ResponseDirectionConfig: &compressor.Compressor_ResponseDirectionConfig{CommonConfig: common},
+		})},
+	}
+}
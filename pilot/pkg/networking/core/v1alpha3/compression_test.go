@@ -0,0 +1,122 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"reflect"
+	"testing"
+
+	compressor "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/compressor/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/test/xdstest"
+)
+
+func TestCompressionFilters(t *testing.T) {
+	cg := NewConfigGenTest(t, TestOptions{ConfigString: `apiVersion: telemetry.istio.io/v1alpha1
+kind: Telemetry
+metadata:
+  name: default
+  namespace: istio-system
+  annotations:
+    telemetry.istio.io/compression: |
+      {"algorithms": ["brotli", "gzip"], "minContentLength": 1024, "decompression": ["gzip"]}
+spec: {}
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: default
+spec:
+  hosts:
+  - example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: DNS
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: gateway
+  namespace: istio-system
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+  - hosts:
+    - "*"
+    port:
+      name: http
+      number: 8080
+      protocol: HTTP
+`})
+	sidecar := cg.SetupProxy(nil)
+	gateway := getProxy()
+	gateway.Type = model.Router
+	gateway.ConfigNamespace = "istio-system"
+	gateway.Metadata.Labels = map[string]string{"istio": "ingressgateway"}
+	gateway = cg.SetupProxy(gateway)
+
+	cases := []struct {
+		name     string
+		proxy    *model.Proxy
+		listener string
+		want     []string
+	}{
+		{
+			name:     "sidecar outbound",
+			proxy:    sidecar,
+			listener: "0.0.0.0_80",
+			want:     []string{decompressorFilterPrefix + "gzip"},
+		},
+		{
+			name:     "gateway",
+			proxy:    gateway,
+			listener: "0.0.0.0_8080",
+			want:     []string{compressorFilterPrefix + "brotli", compressorFilterPrefix + "gzip", decompressorFilterPrefix + "gzip"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			l := xdstest.ExtractListener(tt.listener, cg.Listeners(tt.proxy))
+			if l == nil {
+				t.Fatalf("listener %s not found", tt.listener)
+			}
+			hcm := xdstest.ExtractHTTPConnectionManager(t, l.FilterChains[0])
+			var got []string
+			for _, f := range hcm.HttpFilters {
+				if f.Name == compressorFilterPrefix+"brotli" {
+					c := &compressor.Compressor{}
+					if err := f.GetTypedConfig().UnmarshalTo(c); err != nil {
+						t.Fatal(err)
+					}
+					if c.GetResponseDirectionConfig().GetCommonConfig().GetMinContentLength().GetValue() != 1024 {
+						t.Errorf("expected minimum content length 1024, got %v", c)
+					}
+				}
+				if f.Name == compressorFilterPrefix+"brotli" || f.Name == compressorFilterPrefix+"gzip" ||
+					f.Name == decompressorFilterPrefix+"gzip" {
+					got = append(got, f.Name)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected compression filters %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
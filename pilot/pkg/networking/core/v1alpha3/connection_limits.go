@@ -0,0 +1,63 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// applyHTTPConnectionManagerLimits sets the maximum request headers size and the HTTP/2 settings of the downstream
+// connections of the HTTP connection manager.
+func applyHTTPConnectionManagerLimits(connectionManager *hcm.HttpConnectionManager, limits model.ConnectionLimits) {
+	if limits.MaxRequestHeadersKb != 0 {
+		connectionManager.MaxRequestHeadersKb = &wrappers.UInt32Value{Value: limits.MaxRequestHeadersKb}
+	}
+	if limits.HasHTTP2Settings() {
+		if connectionManager.Http2ProtocolOptions == nil {
+			connectionManager.Http2ProtocolOptions = &core.Http2ProtocolOptions{}
+		}
+		applyHTTP2Limits(connectionManager.Http2ProtocolOptions, limits)
+	}
+}
+
+// applyHTTP2Limits sets the HTTP/2 settings of the limits on the protocol options.
+func applyHTTP2Limits(options *core.Http2ProtocolOptions, limits model.ConnectionLimits) {
+	if limits.HTTP2MaxConcurrentStreams != 0 {
+		options.MaxConcurrentStreams = &wrappers.UInt32Value{Value: limits.HTTP2MaxConcurrentStreams}
+	}
+	if limits.HTTP2InitialStreamWindowSize != 0 {
+		options.InitialStreamWindowSize = &wrappers.UInt32Value{Value: limits.HTTP2InitialStreamWindowSize}
+	}
+	if limits.HTTP2InitialConnectionWindowSize != 0 {
+		options.InitialConnectionWindowSize = &wrappers.UInt32Value{Value: limits.HTTP2InitialConnectionWindowSize}
+	}
+}
+
+// applyListenerLimits sets the connection buffer limit on the listeners, unless an EnvoyFilter set it already.
+func applyListenerLimits(listeners []*listener.Listener, limits model.ConnectionLimits) {
+	if limits.PerConnectionBufferLimitBytes == 0 {
+		return
+	}
+	for _, l := range listeners {
+		if l.PerConnectionBufferLimitBytes == nil {
+			l.PerConnectionBufferLimitBytes = &wrappers.UInt32Value{Value: limits.PerConnectionBufferLimitBytes}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"testing"
+
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	http "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pilot/test/xdstest"
+)
+
+func TestConnectionLimits(t *testing.T) {
+	defer func(v string) { features.ConnectionLimits = v }(features.ConnectionLimits)
+	features.ConnectionLimits = `{"perConnectionBufferLimitBytes": 1048576, "http2MaxConcurrentStreams": 100}`
+	cg := NewConfigGenTest(t, TestOptions{ConfigString: `apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: default
+spec:
+  hosts:
+  - a.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  - number: 8080
+    name: grpc
+    protocol: GRPC
+  resolution: STATIC
+  endpoints:
+  - address: 2.2.2.2
+`})
+	proxy := cg.SetupProxy(&model.Proxy{Metadata: &model.NodeMetadata{
+		ConnectionLimits: `{"maxRequestHeadersKb": 96, "http2MaxConcurrentStreams": 1000, "http2InitialStreamWindowSize": 65536}`,
+	}})
+
+	managers := 0
+	for _, l := range cg.Listeners(proxy) {
+		if got := l.GetPerConnectionBufferLimitBytes().GetValue(); got != 1048576 {
+			t.Errorf("listener %s: expected buffer limit 1048576, got %d", l.Name, got)
+		}
+		for _, fc := range l.FilterChains {
+			for _, f := range fc.Filters {
+				if f.Name != wellknown.HTTPConnectionManager {
+					continue
+				}
+				managers++
+				m := &hcm.HttpConnectionManager{}
+				if err := f.GetTypedConfig().UnmarshalTo(m); err != nil {
+					t.Fatal(err)
+				}
+				if got := m.GetMaxRequestHeadersKb().GetValue(); got != 96 {
+					t.Errorf("listener %s: expected max request headers 96KiB, got %d", l.Name, got)
+				}
+				if got := m.GetHttp2ProtocolOptions().GetMaxConcurrentStreams().GetValue(); got != 1000 {
+					t.Errorf("listener %s: expected 1000 max concurrent streams, got %d", l.Name, got)
+				}
+			}
+		}
+	}
+	if managers == 0 {
+		t.Fatalf("expected HTTP connection managers")
+	}
+
+	clusters := xdstest.ExtractClusters(cg.Clusters(proxy))
+	c := clusters["outbound|8080||a.example.com"]
+	if c == nil {
+		t.Fatalf("cluster not found, got %v", xdstest.MapKeys(clusters))
+	}
+	if got := c.GetPerConnectionBufferLimitBytes().GetValue(); got != 1048576 {
+		t.Errorf("expected buffer limit 1048576, got %d", got)
+	}
+	options := &http.HttpProtocolOptions{}
+	if err := c.TypedExtensionProtocolOptions[v3.HttpProtocolOptionsType].UnmarshalTo(options); err != nil {
+		t.Fatal(err)
+	}
+	h2 := options.GetExplicitHttpConfig().GetHttp2ProtocolOptions()
+	if got := h2.GetMaxConcurrentStreams().GetValue(); got != 1000 {
+		t.Errorf("expected 1000 max concurrent streams, got %d", got)
+	}
+	if got := h2.GetInitialStreamWindowSize().GetValue(); got != 65536 {
+		t.Errorf("expected initial stream window 65536, got %d", got)
+	}
+}
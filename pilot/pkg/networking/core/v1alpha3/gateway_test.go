@@ -37,6 +37,7 @@ import (
 	"istio.io/istio/pilot/pkg/networking/plugin"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/security/model"
+	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
 	"istio.io/istio/pilot/test/xdstest"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/host"
@@ -1788,6 +1789,95 @@ func TestGatewayHTTPRouteConfig(t *testing.T) {
 	}
 }
 
+func TestGatewayVHDS(t *testing.T) {
+	gateway := config.Config{
+		Meta: config.Meta{
+			Name:             "gateway",
+			Namespace:        "default",
+			GroupVersionKind: gvk.Gateway,
+		},
+		Spec: &networking.Gateway{
+			Selector: map[string]string{"istio": "ingressgateway"},
+			Servers: []*networking.Server{
+				{
+					Hosts: []string{"example.org"},
+					Port:  &networking.Port{Name: "http", Number: 80, Protocol: "HTTP"},
+				},
+			},
+		},
+	}
+	virtualService := config.Config{
+		Meta: config.Meta{
+			Name:             "virtual-service",
+			Namespace:        "default",
+			GroupVersionKind: gvk.VirtualService,
+		},
+		Spec: &networking.VirtualService{
+			Hosts:    []string{"example.org"},
+			Gateways: []string{"gateway"},
+			Http: []*networking.HTTPRoute{
+				{
+					Route: []*networking.HTTPRouteDestination{
+						{Destination: &networking.Destination{Host: "example.default.svc.cluster.local"}},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range []struct {
+		name       string
+		enableVHDS bool
+		delta      bool
+		vhds       bool
+	}{
+		{name: "disabled", delta: true},
+		{name: "enabled", enableVHDS: true, delta: true, vhds: true},
+		// VHDS is only served over delta xDS.
+		{name: "sotw", enableVHDS: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cg := NewConfigGenTest(t, TestOptions{
+				Configs: []config.Config{gateway, virtualService},
+			})
+			metadata := proxyGatewayMetadata
+			metadata.EnableVHDS = pilot_model.StringBool(tt.enableVHDS)
+			proxy := cg.SetupProxy(&pilot_model.Proxy{
+				Type:            pilot_model.Router,
+				IPAddresses:     []string{"1.1.1.1"},
+				ID:              "v0.default",
+				DNSDomain:       "default.example.org",
+				Metadata:        &metadata,
+				ConfigNamespace: "not-default",
+				DeltaXDS:        tt.delta,
+			})
+
+			l := xdstest.ExtractListener("0.0.0.0_80", cg.Listeners(proxy))
+			if l == nil {
+				t.Fatalf("expected a gateway listener")
+			}
+			onDemand := false
+			for _, f := range xdstest.ExtractHTTPConnectionManager(t, l.FilterChains[0]).HttpFilters {
+				onDemand = onDemand || f.Name == xdsfilters.OnDemandFilterName
+			}
+			if onDemand != tt.vhds {
+				t.Errorf("expected on demand filter %v, got %v", tt.vhds, onDemand)
+			}
+
+			rc := xdstest.ExtractRouteConfigurations(cg.Routes(proxy))["http.80"]
+			if rc == nil {
+				t.Fatalf("expected the http.80 route configuration")
+			}
+			if (rc.Vhds != nil) != tt.vhds || (len(rc.VirtualHosts) == 0) != tt.vhds {
+				t.Errorf("expected vhds %v, got vhds %v and %d virtual hosts", tt.vhds, rc.Vhds, len(rc.VirtualHosts))
+			}
+			vhosts := cg.ConfigGen.BuildGatewayVirtualHosts(proxy, cg.PushContext(), "http.80")
+			if len(vhosts) != 1 || vhosts[0].Name != "example.org:80" {
+				t.Errorf("unexpected virtual hosts %v", vhosts)
+			}
+		})
+	}
+}
+
 func TestBuildGatewayListeners(t *testing.T) {
 	cases := []struct {
 		name              string
@@ -20,6 +20,7 @@ import (
 	"strconv"
 	"strings"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	protobuf "google.golang.org/protobuf/proto"
@@ -83,6 +84,11 @@ func (configgen *ConfigGeneratorImpl) BuildHTTPRoutes(
 			rc := configgen.buildGatewayHTTPRouteConfig(node, req.Push, routeName)
 			if rc != nil {
 				rc = envoyfilter.ApplyRouteConfigurationPatches(networking.EnvoyFilter_GATEWAY, node, efw, rc)
+				if node.VHDSEnabled() {
+					// The virtual hosts are fetched on demand over VHDS, see BuildGatewayVirtualHosts.
+					rc.VirtualHosts = nil
+					rc.Vhds = vhds
+				}
 				resource := &discovery.Resource{
 					Name:     routeName,
 					Resource: util.MessageToAny(rc),
@@ -97,12 +103,36 @@ func (configgen *ConfigGeneratorImpl) BuildHTTPRoutes(
 	return routeConfigurations, model.XdsLogDetails{AdditionalInfo: fmt.Sprintf("cached:%v/%v", hit, hit+miss)}
 }
 
+// vhds is the VHDS configuration of the route configurations whose virtual hosts are fetched on demand.
+var vhds = &route.Vhds{
+	ConfigSource: &core.ConfigSource{
+		ConfigSourceSpecifier: &core.ConfigSource_Ads{
+			Ads: &core.AggregatedConfigSource{},
+		},
+		ResourceApiVersion: core.ApiVersion_V3,
+	},
+}
+
+// BuildGatewayVirtualHosts returns the virtual hosts of the given gateway route. This is the VHDS output for the
+// gateways fetching their virtual hosts on demand.
+func (configgen *ConfigGeneratorImpl) BuildGatewayVirtualHosts(node *model.Proxy, push *model.PushContext,
+	routeName string) []*route.VirtualHost {
+	rc := configgen.buildGatewayHTTPRouteConfig(node, push, routeName)
+	if rc == nil {
+		return nil
+	}
+	rc = envoyfilter.ApplyRouteConfigurationPatches(networking.EnvoyFilter_GATEWAY, node, push.EnvoyFilters(node), rc)
+	return rc.VirtualHosts
+}
+
 // buildSidecarInboundHTTPRouteConfig builds the route config with a single wildcard virtual host on the inbound path
 // TODO: trace decorators, inbound timeouts
 func (configgen *ConfigGeneratorImpl) buildSidecarInboundHTTPRouteConfig(
 	node *model.Proxy, push *model.PushContext, instance *model.ServiceInstance, clusterName string) *route.RouteConfiguration {
 	traceOperation := util.TraceOperation(string(instance.Service.Hostname), instance.ServicePort.Port)
 	defaultRoute := istio_route.BuildDefaultHTTPInboundRoute(clusterName, traceOperation)
+	// Generates the descriptors of the clients with their own local rate limit.
+	defaultRoute.GetRoute().RateLimits = buildLocalRateLimitActions(node)
 
 	inboundVHost := &route.VirtualHost{
 		Name:    inboundVirtualHostPrefix + strconv.Itoa(instance.ServicePort.Port), // Format: "inbound|http|%d"
@@ -472,9 +502,10 @@ func generateVirtualHostDomains(service *model.Service, port int, node *model.Pr
 		}
 	}
 
-	svcAddr := service.GetAddressForProxy(node)
-	if len(svcAddr) > 0 && svcAddr != constants.UnspecifiedIP {
-		domains = append(domains, util.IPv6Compliant(svcAddr), util.DomainName(svcAddr, port))
+	for _, svcAddr := range service.GetAllAddressesForProxy(node) {
+		if len(svcAddr) > 0 && svcAddr != constants.UnspecifiedIP {
+			domains = append(domains, util.IPv6Compliant(svcAddr), util.DomainName(svcAddr, port))
+		}
 	}
 	return domains, altHosts
 }
@@ -123,9 +123,12 @@ func (configgen *ConfigGeneratorImpl) BuildListeners(node *model.Proxy,
 	case model.Router:
 		builder = configgen.buildGatewayListeners(builder)
 	}
+	builder.buildCrossNetworkTunnelListeners()
 
 	builder.patchListeners()
-	return builder.getListeners()
+	listeners := builder.getListeners()
+	applyListenerLimits(listeners, model.ConnectionLimitsForProxy(node))
+	return listeners
 }
 
 func (configgen *ConfigGeneratorImpl) BuildListenerTLSContext(serverTLSSettings *networking.ServerTLSSettings,
@@ -197,6 +200,7 @@ func (configgen *ConfigGeneratorImpl) BuildListenerTLSContext(serverTLSSettings
 			CipherSuites:              serverTLSSettings.CipherSuites,
 		}
 	}
+	util.TLSPolicyForProxy(proxy).Apply(ctx.CommonTlsContext)
 
 	return ctx
 }
@@ -741,7 +745,8 @@ func (configgen *ConfigGeneratorImpl) buildSidecarOutboundListeners(node *model.
 							// Make sure each endpoint address is a valid address
 							// as service entries could have NONE resolution with label selectors for workload
 							// entries (which could technically have hostnames).
-							if net.ParseIP(instance.Endpoint.Address) == nil {
+							// The secondary addresses of a workload are not routable, so they get no listener.
+							if net.ParseIP(instance.Endpoint.Address) == nil || instance.Endpoint.Secondary {
 								continue
 							}
 							// Skip build outbound listener to the node itself,
@@ -756,6 +761,18 @@ func (configgen *ConfigGeneratorImpl) buildSidecarOutboundListeners(node *model.
 					} else {
 						// Standard logic for headless and non headless services
 						configgen.buildSidecarOutboundListenerForPortOrUDS(listenerOpts, listenerMap, virtualServices, actualWildcard)
+						// Listeners of non HTTP ports are bound to the service VIP, so a dual-stack service
+						// needs one for the VIP of its other IP family too.
+						if node.DualStackEnabled() && bind == "" && istionetworking.ModelProtocolToListenerProtocol(servicePort.Protocol,
+							core.TrafficDirection_OUTBOUND) != istionetworking.ListenerProtocolHTTP {
+							for _, address := range service.GetAllAddressesForProxy(node)[1:] {
+								if strings.Contains(address, "/") {
+									continue
+								}
+								listenerOpts.bind = address
+								configgen.buildSidecarOutboundListenerForPortOrUDS(listenerOpts, listenerMap, virtualServices, actualWildcard)
+							}
+						}
 					}
 				}
 			}
@@ -948,7 +965,7 @@ func (configgen *ConfigGeneratorImpl) buildSidecarOutboundTCPListenerOptsForPort
 	// ip:port. This will reduce the impact of a listener reload
 
 	if len(listenerOpts.bind) == 0 {
-		svcListenAddress := listenerOpts.service.GetAddressForProxy(listenerOpts.proxy)
+		svcListenAddress := getServiceListenAddress(listenerOpts.service, listenerOpts.proxy, actualWildcard)
 		// We should never get an empty address.
 		// This is a safety guard, in case some platform adapter isn't doing things
 		// properly
@@ -1333,6 +1350,10 @@ type httpListenerOpts struct {
 	// http3Only indicates that the HTTP codec used
 	// is HTTP/3 over QUIC transport (uses UDP)
 	http3Only bool
+
+	// peerAuthenticated indicates that the identity of the client
+	// is authenticated with mTLS by the filter chain
+	peerAuthenticated bool
 }
 
 // filterChainOpts describes a filter chain: a set of filters with the same TLS context
@@ -1419,6 +1440,8 @@ func buildHTTPConnectionManager(listenerOpts buildListenerOpts, httpOpts *httpLi
 	notimeout := durationpb.New(0 * time.Second)
 	connectionManager.StreamIdleTimeout = notimeout
 
+	applyHTTPConnectionManagerLimits(connectionManager, model.ConnectionLimitsForProxy(listenerOpts.proxy))
+
 	if httpOpts.rds != "" {
 		rds := &hcm.HttpConnectionManager_Rds{
 			Rds: &hcm.Rds{
@@ -1461,9 +1484,23 @@ func buildHTTPConnectionManager(listenerOpts buildListenerOpts, httpOpts *httpLi
 		filters = append(filters, xdsfilters.Alpn)
 	}
 
+	// Responses are compressed after all the other filters, and decompressed before them.
+	compressors, decompressors := buildCompressionFilters(listenerOpts)
+	filters = append(filters, compressors...)
+
 	// TypedPerFilterConfig in route needs these filters.
 	filters = append(filters, xdsfilters.Fault, xdsfilters.Cors)
 	filters = append(filters, listenerOpts.push.Telemetry.HTTPFilters(listenerOpts.proxy, listenerOpts.class)...)
+	// Load is shed after the telemetry filters, so that the rejected requests are recorded.
+	if lrl := buildLocalRateLimitFilter(listenerOpts, httpOpts.peerAuthenticated); lrl != nil {
+		filters = append(filters, lrl)
+	}
+	filters = append(filters, buildLoadSheddingFilters(listenerOpts)...)
+	filters = append(filters, decompressors...)
+	// The virtual hosts of the gateway routes are fetched on demand, as the requests for their hosts arrive.
+	if listenerOpts.class == istionetworking.ListenerClassGateway && listenerOpts.proxy.VHDSEnabled() {
+		filters = append(filters, xdsfilters.OnDemand)
+	}
 	filters = append(filters, xdsfilters.BuildRouterFilter(routerFilterCtx))
 
 	connectionManager.HttpFilters = filters
@@ -1599,7 +1636,7 @@ func buildListener(opts buildListenerOpts, trafficDirection core.TrafficDirectio
 		res = &listener.Listener{
 			// TODO: need to sanitize the opts.bind if its a UDS socket, as it could have colons, that envoy doesn't like
 			Name:                    getListenerName(opts.bind, opts.port.Port, istionetworking.TransportProtocolTCP),
-			Address:                 util.BuildAddress(opts.bind, uint32(opts.port.Port)),
+			Address:                 withIPv4Compat(opts.proxy, util.BuildAddress(opts.bind, uint32(opts.port.Port))),
 			TrafficDirection:        trafficDirection,
 			ListenerFilters:         listenerFilters,
 			FilterChains:            filterChains,
@@ -1621,7 +1658,7 @@ func buildListener(opts buildListenerOpts, trafficDirection core.TrafficDirectio
 		log.Debugf("buildListener: building UDP/QUIC listener %s", listenerName)
 		res = &listener.Listener{
 			Name:             listenerName,
-			Address:          util.BuildNetworkAddress(opts.bind, uint32(opts.port.Port), istionetworking.TransportProtocolQUIC),
+			Address:          withIPv4Compat(opts.proxy, util.BuildNetworkAddress(opts.bind, uint32(opts.port.Port), istionetworking.TransportProtocolQUIC)),
 			TrafficDirection: trafficDirection,
 			FilterChains:     filterChains,
 			UdpListenerConfig: &listener.UdpListenerConfig{
@@ -15,7 +15,10 @@
 package v1alpha3
 
 import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/constants"
 )
 
 const (
@@ -37,14 +40,42 @@ const (
 )
 
 // getActualWildcardAndLocalHost will return corresponding Wildcard and LocalHost
-// depending on value of proxy's IPAddresses.
+// depending on value of proxy's IPAddresses. Dual-stack proxies use the IPv6 wildcard
+// address, which also accepts IPv4 connections, and the IPv4 localhost.
 func getActualWildcardAndLocalHost(node *model.Proxy) (string, string) {
+	if isDualStack(node) {
+		return WildcardIPv6Address, LocalhostAddress
+	}
 	if node.SupportsIPv4() {
 		return WildcardAddress, LocalhostAddress
 	}
 	return WildcardIPv6Address, LocalhostIPv6Address
 }
 
+// isDualStack returns true if the proxy has dual stack enabled and both IPv4 and IPv6 addresses.
+func isDualStack(node *model.Proxy) bool {
+	return node.DualStackEnabled() && node.IsDualStack()
+}
+
+// withIPv4Compat makes a listener of a dual-stack proxy bound to the IPv6 wildcard address accept
+// IPv4 connections as well.
+func withIPv4Compat(node *model.Proxy, address *core.Address) *core.Address {
+	if sa := address.GetSocketAddress(); sa != nil && sa.Address == WildcardIPv6Address && isDualStack(node) {
+		sa.Ipv4Compat = true
+	}
+	return address
+}
+
+// getServiceListenAddress returns the address the proxy listens on for the service: its VIP, or
+// for a dual-stack proxy, the wildcard address of the proxy if the service has no VIP.
+func getServiceListenAddress(service *model.Service, node *model.Proxy, actualWildcard string) string {
+	address := service.GetAddressForProxy(node)
+	if address == constants.UnspecifiedIP && isDualStack(node) {
+		return actualWildcard
+	}
+	return address
+}
+
 func getPassthroughBindIP(node *model.Proxy) string {
 	if node.SupportsIPv4() {
 		return InboundPassthroughBindIpv4
@@ -821,6 +821,45 @@ func TestGetActualWildcardAndLocalHost(t *testing.T) {
 	}
 }
 
+func TestDualStackOutboundListeners(t *testing.T) {
+	tcp := buildService("tcp.com", "10.0.0.1", protocol.TCP, tnow)
+	tcp.ClusterVIPs.SetAddressesFor("Kubernetes", []string{"10.0.0.1", "fd00::1"})
+	http := buildService("http.com", "10.0.0.2", protocol.HTTP, tnow)
+	http.ClusterVIPs.SetAddressesFor("Kubernetes", []string{"10.0.0.2", "fd00::2"})
+
+	proxy := getProxy()
+	proxy.IPAddresses = []string{"1.1.1.1", "2001:db8::1"}
+	proxy.Metadata.ClusterID = "Kubernetes"
+	proxy.Metadata.DualStack = true
+	listeners := buildOutboundListeners(t, &fakePlugin{}, proxy, nil, nil, tcp, http)
+
+	names := map[string]*listener.Listener{}
+	for _, l := range listeners {
+		names[l.Name] = l
+	}
+	for _, name := range []string{"10.0.0.1_8080", "fd00::1_8080", "::_8080"} {
+		if names[name] == nil {
+			t.Errorf("expected listener %s, got %v", name, xdstest.MapKeys(names))
+		}
+	}
+	if l := names["::_8080"]; l != nil && !l.Address.GetSocketAddress().Ipv4Compat {
+		t.Errorf("expected the wildcard listener to accept IPv4 connections: %v", l.Address)
+	}
+	if names["fd00::2_8080"] != nil {
+		t.Errorf("HTTP services should share the wildcard listener")
+	}
+
+	// An IPv4 only proxy does not listen on the IPv6 VIP.
+	proxy = getProxy()
+	proxy.Metadata.ClusterID = "Kubernetes"
+	listeners = buildOutboundListeners(t, &fakePlugin{}, proxy, nil, nil, tcp, http)
+	for _, l := range listeners {
+		if l.Name == "fd00::1_8080" || l.Name == "::_8080" {
+			t.Errorf("unexpected listener %s for an IPv4 only proxy", l.Name)
+		}
+	}
+}
+
 // Test to catch new fields in FilterChainMatch message.
 func TestFilterChainMatchFields(t *testing.T) {
 	fcm := listener.FilterChainMatch{}
@@ -0,0 +1,193 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	adaptiveconcurrency "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/adaptive_concurrency/v3"
+	admissioncontrol "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/admission_control/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
+
+	istionetworking "istio.io/istio/pilot/pkg/networking"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/pkg/log"
+)
+
+const (
+	adaptiveConcurrencyFilterName = "envoy.filters.http.adaptive_concurrency"
+	admissionControlFilterName    = "envoy.filters.http.admission_control"
+)
+
+var loadSheddingLog = log.RegisterScope("loadshedding", "adaptive concurrency and admission control", 0)
+
+// adaptiveConcurrencyConfig is the value of the AdaptiveConcurrency metadata. Unset fields use the defaults
+// of Envoy.
+type adaptiveConcurrencyConfig struct {
+	SampleAggregatePercentile float64  `json:"sampleAggregatePercentile,omitempty"`
+	ConcurrencyUpdateInterval duration `json:"concurrencyUpdateInterval,omitempty"`
+	MaxConcurrencyLimit       uint32   `json:"maxConcurrencyLimit,omitempty"`
+	MinRTTCalcInterval        duration `json:"minRTTCalcInterval,omitempty"`
+	MinRTTRequestCount        uint32   `json:"minRTTRequestCount,omitempty"`
+	Jitter                    float64  `json:"jitter,omitempty"`
+	MinConcurrency            uint32   `json:"minConcurrency,omitempty"`
+	Buffer                    float64  `json:"buffer,omitempty"`
+}
+
+// admissionControlConfig is the value of the AdmissionControl metadata. Unset fields use the defaults of
+// Envoy.
+type admissionControlConfig struct {
+	SamplingWindow          duration `json:"samplingWindow,omitempty"`
+	Aggression              float64  `json:"aggression,omitempty"`
+	SuccessRateThreshold    float64  `json:"successRateThreshold,omitempty"`
+	RPSThreshold            uint32   `json:"rpsThreshold,omitempty"`
+	MaxRejectionProbability float64  `json:"maxRejectionProbability,omitempty"`
+}
+
+// duration is a time.Duration unmarshalled from a string such as "100ms".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if v <= 0 {
+		return fmt.Errorf("duration %s must be positive", s)
+	}
+	*d = duration(v)
+	return nil
+}
+
+func (d duration) orDefault(def time.Duration) *durationpb.Duration {
+	if d == 0 {
+		return durationpb.New(def)
+	}
+	return durationpb.New(time.Duration(d))
+}
+
+// parseStrictJSON unmarshals the JSON of the metadata, rejecting the unknown fields.
+func parseStrictJSON(v string, out interface{}) error {
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.DisallowUnknownFields()
+	return dec.Decode(out)
+}
+
+// buildLoadSheddingFilters returns the adaptive concurrency and admission control filters the workload opted in
+// with its AdaptiveConcurrency and AdmissionControl metadata. Load is only shed by gateways and inbound sidecar
+// listeners, protecting the workload itself.
+func buildLoadSheddingFilters(opts buildListenerOpts) []*hcm.HttpFilter {
+	if opts.class == istionetworking.ListenerClassSidecarOutbound || opts.proxy.Metadata == nil {
+		return nil
+	}
+	var filters []*hcm.HttpFilter
+	if v := opts.proxy.Metadata.AdaptiveConcurrency; v != "" {
+		cfg := adaptiveConcurrencyConfig{}
+		if err := parseStrictJSON(v, &cfg); err != nil {
+			loadSheddingLog.Warnf("invalid adaptive concurrency of proxy %s: %v", opts.proxy.ID, err)
+		} else {
+			filters = append(filters, buildAdaptiveConcurrencyFilter(cfg))
+		}
+	}
+	if v := opts.proxy.Metadata.AdmissionControl; v != "" {
+		cfg := admissionControlConfig{}
+		if err := parseStrictJSON(v, &cfg); err != nil {
+			loadSheddingLog.Warnf("invalid admission control of proxy %s: %v", opts.proxy.ID, err)
+		} else {
+			filters = append(filters, buildAdmissionControlFilter(cfg))
+		}
+	}
+	return filters
+}
+
+func buildAdaptiveConcurrencyFilter(cfg adaptiveConcurrencyConfig) *hcm.HttpFilter {
+	gradient := &adaptiveconcurrency.GradientControllerConfig{
+		ConcurrencyLimitParams: &adaptiveconcurrency.GradientControllerConfig_ConcurrencyLimitCalculationParams{
+			ConcurrencyUpdateInterval: cfg.ConcurrencyUpdateInterval.orDefault(100 * time.Millisecond),
+		},
+		MinRttCalcParams: &adaptiveconcurrency.GradientControllerConfig_MinimumRTTCalculationParams{
+			Interval: cfg.MinRTTCalcInterval.orDefault(60 * time.Second),
+		},
+	}
+	if cfg.SampleAggregatePercentile > 0 {
+		gradient.SampleAggregatePercentile = &xdstype.Percent{Value: cfg.SampleAggregatePercentile}
+	}
+	if cfg.MaxConcurrencyLimit > 0 {
+		gradient.ConcurrencyLimitParams.MaxConcurrencyLimit = &wrappers.UInt32Value{Value: cfg.MaxConcurrencyLimit}
+	}
+	if cfg.MinRTTRequestCount > 0 {
+		gradient.MinRttCalcParams.RequestCount = &wrappers.UInt32Value{Value: cfg.MinRTTRequestCount}
+	}
+	if cfg.Jitter > 0 {
+		gradient.MinRttCalcParams.Jitter = &xdstype.Percent{Value: cfg.Jitter}
+	}
+	if cfg.MinConcurrency > 0 {
+		gradient.MinRttCalcParams.MinConcurrency = &wrappers.UInt32Value{Value: cfg.MinConcurrency}
+	}
+	if cfg.Buffer > 0 {
+		gradient.MinRttCalcParams.Buffer = &xdstype.Percent{Value: cfg.Buffer}
+	}
+	return &hcm.HttpFilter{
+		Name: adaptiveConcurrencyFilterName,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(&adaptiveconcurrency.AdaptiveConcurrency{
+			ConcurrencyControllerConfig: &adaptiveconcurrency.AdaptiveConcurrency_GradientControllerConfig{
+				GradientControllerConfig: gradient,
+			},
+		})},
+	}
+}
+
+func buildAdmissionControlFilter(cfg admissionControlConfig) *hcm.HttpFilter {
+	ac := &admissioncontrol.AdmissionControl{
+		// Requests fail on 5xx responses and gRPC server errors, as by default.
+		EvaluationCriteria: &admissioncontrol.AdmissionControl_SuccessCriteria_{
+			SuccessCriteria: &admissioncontrol.AdmissionControl_SuccessCriteria{},
+		},
+		SamplingWindow: cfg.SamplingWindow.orDefault(30 * time.Second),
+	}
+	if cfg.Aggression > 0 {
+		ac.Aggression = &core.RuntimeDouble{DefaultValue: cfg.Aggression, RuntimeKey: "admission_control.aggression"}
+	}
+	if cfg.SuccessRateThreshold > 0 {
+		ac.SrThreshold = &core.RuntimePercent{
+			DefaultValue: &xdstype.Percent{Value: cfg.SuccessRateThreshold},
+			RuntimeKey:   "admission_control.sr_threshold",
+		}
+	}
+	if cfg.RPSThreshold > 0 {
+		ac.RpsThreshold = &core.RuntimeUInt32{DefaultValue: cfg.RPSThreshold, RuntimeKey: "admission_control.rps_threshold"}
+	}
+	if cfg.MaxRejectionProbability > 0 {
+		ac.MaxRejectionProbability = &core.RuntimePercent{
+			DefaultValue: &xdstype.Percent{Value: cfg.MaxRejectionProbability},
+			RuntimeKey:   "admission_control.max_rejection_probability",
+		}
+	}
+	return &hcm.HttpFilter{
+		Name:       admissionControlFilterName,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(ac)},
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	adaptiveconcurrency "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/adaptive_concurrency/v3"
+	admissioncontrol "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/admission_control/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	istionetworking "istio.io/istio/pilot/pkg/networking"
+)
+
+func TestLoadSheddingFilters(t *testing.T) {
+	cases := []struct {
+		name     string
+		class    istionetworking.ListenerClass
+		metadata model.NodeMetadata
+		want     []string
+	}{
+		{
+			name:  "none",
+			class: istionetworking.ListenerClassSidecarInbound,
+		},
+		{
+			name:  "inbound",
+			class: istionetworking.ListenerClassSidecarInbound,
+			metadata: model.NodeMetadata{
+				AdaptiveConcurrency: `{"maxConcurrencyLimit": 100, "minRTTCalcInterval": "30s"}`,
+				AdmissionControl:    `{"successRateThreshold": 95}`,
+			},
+			want: []string{adaptiveConcurrencyFilterName, admissionControlFilterName},
+		},
+		{
+			name:  "gateway",
+			class: istionetworking.ListenerClassGateway,
+			metadata: model.NodeMetadata{
+				AdmissionControl: `{}`,
+			},
+			want: []string{admissionControlFilterName},
+		},
+		{
+			name:  "outbound",
+			class: istionetworking.ListenerClassSidecarOutbound,
+			metadata: model.NodeMetadata{
+				AdaptiveConcurrency: `{}`,
+			},
+		},
+		{
+			name:  "invalid",
+			class: istionetworking.ListenerClassSidecarInbound,
+			metadata: model.NodeMetadata{
+				AdaptiveConcurrency: `{"unknown": 1}`,
+				AdmissionControl:    `{"samplingWindow": "-1s"}`,
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := &model.Proxy{Metadata: &tt.metadata}
+			filters := buildLoadSheddingFilters(buildListenerOpts{proxy: proxy, class: tt.class})
+			var got []string
+			for _, f := range filters {
+				got = append(got, f.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected filters %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAdaptiveConcurrencyFilter(t *testing.T) {
+	cfg := adaptiveConcurrencyConfig{MaxConcurrencyLimit: 100, MinRTTCalcInterval: duration(30 * time.Second)}
+	ac := &adaptiveconcurrency.AdaptiveConcurrency{}
+	if err := buildAdaptiveConcurrencyFilter(cfg).GetTypedConfig().UnmarshalTo(ac); err != nil {
+		t.Fatal(err)
+	}
+	gradient := ac.GetGradientControllerConfig()
+	if got := gradient.GetConcurrencyLimitParams().GetMaxConcurrencyLimit().GetValue(); got != 100 {
+		t.Errorf("expected max concurrency limit 100, got %v", got)
+	}
+	if got := gradient.GetConcurrencyLimitParams().GetConcurrencyUpdateInterval().AsDuration(); got != 100*time.Millisecond {
+		t.Errorf("expected default concurrency update interval, got %v", got)
+	}
+	if got := gradient.GetMinRttCalcParams().GetInterval().AsDuration(); got != 30*time.Second {
+		t.Errorf("expected min RTT interval 30s, got %v", got)
+	}
+}
+
+func TestAdmissionControlFilter(t *testing.T) {
+	ac := &admissioncontrol.AdmissionControl{}
+	if err := buildAdmissionControlFilter(admissionControlConfig{SuccessRateThreshold: 95}).GetTypedConfig().UnmarshalTo(ac); err != nil {
+		t.Fatal(err)
+	}
+	if got := ac.GetSrThreshold().GetDefaultValue().GetValue(); got != 95 {
+		t.Errorf("expected success rate threshold 95, got %v", got)
+	}
+	if got := ac.GetSamplingWindow().AsDuration(); got != 30*time.Second {
+		t.Errorf("expected default sampling window, got %v", got)
+	}
+	if ac.GetSuccessCriteria() == nil {
+		t.Errorf("expected default success criteria")
+	}
+}
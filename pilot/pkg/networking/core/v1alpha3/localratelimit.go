@@ -0,0 +1,225 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	ratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	localratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/model"
+	istionetworking "istio.io/istio/pilot/pkg/networking"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/pkg/log"
+)
+
+const (
+	localRateLimitFilterName = "envoy.filters.http.local_ratelimit"
+	localRateLimitStatPrefix = "inbound_local_rate_limit"
+
+	// The descriptor entry key of the HeaderValueMatch rate limit actions.
+	headerMatchDescriptorKey = "header_match"
+
+	defaultLocalRateLimitFillInterval = time.Second
+)
+
+// tokenBucketConfig is a token bucket of the LocalRateLimit metadata.
+type tokenBucketConfig struct {
+	MaxTokens uint32 `json:"maxTokens,omitempty"`
+	// TokensPerFill defaults to MaxTokens.
+	TokensPerFill uint32   `json:"tokensPerFill,omitempty"`
+	FillInterval  duration `json:"fillInterval,omitempty"`
+}
+
+// localRateLimitConfig is the value of the LocalRateLimit metadata. The token bucket shared by all the
+// requests is unlimited when unset, so that only the configured clients are limited.
+type localRateLimitConfig struct {
+	tokenBucketConfig
+	Clients []*clientRateLimitConfig `json:"clients,omitempty"`
+}
+
+// clientRateLimitConfig is the token bucket of the requests of the clients with a principal, or of all the
+// principals of a namespace. The bucket of the client with the highest priority is used by a request; at equal
+// priorities, principals are preferred over namespaces.
+type clientRateLimitConfig struct {
+	tokenBucketConfig
+	Principal string `json:"principal,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Priority  int    `json:"priority,omitempty"`
+}
+
+// descriptorValue is the value of the descriptor entry generated for the requests of the client.
+func (c *clientRateLimitConfig) descriptorValue() string {
+	if c.Principal != "" {
+		return "principal:" + c.Principal
+	}
+	return "namespace:" + c.Namespace
+}
+
+// peerIdentityRegex matches the x-forwarded-client-cert header of the requests of the client. The URI of the last
+// element is the one set by the proxy from the client certificate, the previous ones are forwarded from the client.
+func (c *clientRateLimitConfig) peerIdentityRegex() string {
+	identity := regexp.QuoteMeta(c.Principal)
+	if c.Principal == "" {
+		identity = "[^/;,]+/ns/" + regexp.QuoteMeta(c.Namespace) + "/sa/[^/;,]+"
+	}
+	return ".*URI=spiffe://" + identity + "(;DNS=[^;,]*)*"
+}
+
+// parseLocalRateLimit returns the validated LocalRateLimit metadata of the proxy, with its clients sorted
+// by priority, or nil if it is unset or invalid.
+func parseLocalRateLimit(proxy *model.Proxy) *localRateLimitConfig {
+	if proxy.Metadata == nil || proxy.Metadata.LocalRateLimit == "" {
+		return nil
+	}
+	cfg := &localRateLimitConfig{}
+	if err := parseStrictJSON(proxy.Metadata.LocalRateLimit, cfg); err != nil {
+		log.Warnf("invalid local rate limit of proxy %s: %v", proxy.ID, err)
+		return nil
+	}
+	if err := cfg.validate(); err != nil {
+		log.Warnf("invalid local rate limit of proxy %s: %v", proxy.ID, err)
+		return nil
+	}
+	sort.SliceStable(cfg.Clients, func(i, j int) bool {
+		if cfg.Clients[i].Priority != cfg.Clients[j].Priority {
+			return cfg.Clients[i].Priority > cfg.Clients[j].Priority
+		}
+		return cfg.Clients[i].Principal != "" && cfg.Clients[j].Principal == ""
+	})
+	return cfg
+}
+
+// validate checks the clients are identified, and that the fill interval of their buckets is a multiple of the
+// fill interval of the shared bucket, as required by Envoy.
+func (cfg *localRateLimitConfig) validate() error {
+	if cfg.MaxTokens == 0 {
+		cfg.MaxTokens = math.MaxUint32
+	}
+	if cfg.FillInterval == 0 {
+		cfg.FillInterval = duration(defaultLocalRateLimitFillInterval)
+	}
+	seen := map[string]bool{}
+	for i, c := range cfg.Clients {
+		if (c.Principal == "") == (c.Namespace == "") {
+			return fmt.Errorf("client %d must have either a principal or a namespace", i)
+		}
+		if seen[c.descriptorValue()] {
+			return fmt.Errorf("client %d is duplicated", i)
+		}
+		seen[c.descriptorValue()] = true
+		if c.MaxTokens == 0 {
+			return fmt.Errorf("client %d must have maxTokens", i)
+		}
+		if c.FillInterval == 0 {
+			c.FillInterval = cfg.FillInterval
+		}
+		if time.Duration(c.FillInterval)%time.Duration(cfg.FillInterval) != 0 {
+			return fmt.Errorf("fill interval of client %d must be a multiple of %v", i, time.Duration(cfg.FillInterval))
+		}
+	}
+	return nil
+}
+
+func (b tokenBucketConfig) tokenBucket() *xdstype.TokenBucket {
+	tokensPerFill := b.TokensPerFill
+	if tokensPerFill == 0 {
+		tokensPerFill = b.MaxTokens
+	}
+	return &xdstype.TokenBucket{
+		MaxTokens:     b.MaxTokens,
+		TokensPerFill: &wrappers.UInt32Value{Value: tokensPerFill},
+		FillInterval:  b.FillInterval.orDefault(defaultLocalRateLimitFillInterval),
+	}
+}
+
+// buildLocalRateLimitFilter returns the local rate limit filter of the inbound sidecar listeners of the workloads
+// with the LocalRateLimit metadata. The buckets of the clients are only used by the filter chains authenticating
+// them with mTLS; the other filter chains would otherwise trust the identity forwarded by the client.
+func buildLocalRateLimitFilter(opts buildListenerOpts, peerAuthenticated bool) *hcm.HttpFilter {
+	if opts.class != istionetworking.ListenerClassSidecarInbound {
+		return nil
+	}
+	cfg := parseLocalRateLimit(opts.proxy)
+	if cfg == nil {
+		return nil
+	}
+	lrl := &localratelimit.LocalRateLimit{
+		StatPrefix:  localRateLimitStatPrefix,
+		TokenBucket: cfg.tokenBucket(),
+		FilterEnabled: &core.RuntimeFractionalPercent{
+			DefaultValue: &xdstype.FractionalPercent{Numerator: 100, Denominator: xdstype.FractionalPercent_HUNDRED},
+			RuntimeKey:   "local_rate_limit_enabled",
+		},
+		FilterEnforced: &core.RuntimeFractionalPercent{
+			DefaultValue: &xdstype.FractionalPercent{Numerator: 100, Denominator: xdstype.FractionalPercent_HUNDRED},
+			RuntimeKey:   "local_rate_limit_enforced",
+		},
+	}
+	if peerAuthenticated {
+		for _, c := range cfg.Clients {
+			lrl.Descriptors = append(lrl.Descriptors, &ratelimit.LocalRateLimitDescriptor{
+				Entries:     []*ratelimit.RateLimitDescriptor_Entry{{Key: headerMatchDescriptorKey, Value: c.descriptorValue()}},
+				TokenBucket: c.tokenBucket(),
+			})
+		}
+	}
+	return &hcm.HttpFilter{
+		Name:       localRateLimitFilterName,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(lrl)},
+	}
+}
+
+// buildLocalRateLimitActions returns the rate limits generating the descriptors of the clients of the LocalRateLimit
+// metadata, in priority order: Envoy uses the bucket of the first descriptor generated for a request.
+func buildLocalRateLimitActions(node *model.Proxy) []*route.RateLimit {
+	cfg := parseLocalRateLimit(node)
+	if cfg == nil {
+		return nil
+	}
+	out := make([]*route.RateLimit, 0, len(cfg.Clients))
+	for _, c := range cfg.Clients {
+		out = append(out, &route.RateLimit{
+			Actions: []*route.RateLimit_Action{{
+				ActionSpecifier: &route.RateLimit_Action_HeaderValueMatch_{
+					HeaderValueMatch: &route.RateLimit_Action_HeaderValueMatch{
+						DescriptorValue: c.descriptorValue(),
+						Headers: []*route.HeaderMatcher{{
+							Name: "x-forwarded-client-cert",
+							HeaderMatchSpecifier: &route.HeaderMatcher_SafeRegexMatch{
+								SafeRegexMatch: &matcher.RegexMatcher{
+									EngineType: &matcher.RegexMatcher_GoogleRe2{GoogleRe2: &matcher.RegexMatcher_GoogleRE2{}},
+									Regex:      c.peerIdentityRegex(),
+								},
+							},
+						}},
+					},
+				},
+			}},
+		})
+	}
+	return out
+}
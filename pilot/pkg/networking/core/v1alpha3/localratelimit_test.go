@@ -0,0 +1,177 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"math"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	localratelimit "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	istionetworking "istio.io/istio/pilot/pkg/networking"
+)
+
+func TestParseLocalRateLimit(t *testing.T) {
+	cases := []struct {
+		name  string
+		limit string
+		// descriptor values of the clients, in priority order
+		want []string
+	}{
+		{
+			name: "priorities",
+			limit: `{"clients": [
+				{"namespace": "batch", "maxTokens": 100},
+				{"principal": "cluster.local/ns/batch/sa/loader", "maxTokens": 10},
+				{"namespace": "critical", "maxTokens": 1000, "priority": 1}]}`,
+			want: []string{"namespace:critical", "principal:cluster.local/ns/batch/sa/loader", "namespace:batch"},
+		},
+		{
+			name:  "missing identity",
+			limit: `{"clients": [{"maxTokens": 10}]}`,
+		},
+		{
+			name:  "principal and namespace",
+			limit: `{"clients": [{"principal": "cluster.local/ns/batch/sa/loader", "namespace": "batch", "maxTokens": 10}]}`,
+		},
+		{
+			name:  "duplicate",
+			limit: `{"clients": [{"namespace": "batch", "maxTokens": 10}, {"namespace": "batch", "maxTokens": 20}]}`,
+		},
+		{
+			name:  "missing tokens",
+			limit: `{"clients": [{"namespace": "batch"}]}`,
+		},
+		{
+			name:  "fill interval not a multiple",
+			limit: `{"fillInterval": "1s", "clients": [{"namespace": "batch", "maxTokens": 10, "fillInterval": "1500ms"}]}`,
+		},
+		{
+			name:  "unknown field",
+			limit: `{"client": []}`,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := &model.Proxy{Metadata: &model.NodeMetadata{LocalRateLimit: tt.limit}}
+			cfg := parseLocalRateLimit(proxy)
+			if tt.want == nil {
+				if cfg != nil {
+					t.Fatalf("expected invalid config, got %+v", cfg)
+				}
+				return
+			}
+			if cfg == nil {
+				t.Fatal("expected valid config")
+			}
+			var got []string
+			for _, c := range cfg.Clients {
+				got = append(got, c.descriptorValue())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected clients %v, got %v", tt.want, got)
+			}
+			if cfg.MaxTokens != math.MaxUint32 || time.Duration(cfg.FillInterval) != time.Second {
+				t.Fatalf("expected unlimited shared bucket, got %+v", cfg.tokenBucketConfig)
+			}
+		})
+	}
+}
+
+func TestLocalRateLimitFilter(t *testing.T) {
+	proxy := &model.Proxy{Metadata: &model.NodeMetadata{
+		LocalRateLimit: `{"maxTokens": 1000, "clients": [{"namespace": "batch", "maxTokens": 100, "fillInterval": "2s"}]}`,
+	}}
+	cases := []struct {
+		name              string
+		class             istionetworking.ListenerClass
+		peerAuthenticated bool
+		wantFilter        bool
+		wantDescriptors   int
+	}{
+		{name: "mtls", class: istionetworking.ListenerClassSidecarInbound, peerAuthenticated: true, wantFilter: true, wantDescriptors: 1},
+		{name: "plaintext", class: istionetworking.ListenerClassSidecarInbound, wantFilter: true},
+		{name: "outbound", class: istionetworking.ListenerClassSidecarOutbound, peerAuthenticated: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			f := buildLocalRateLimitFilter(buildListenerOpts{proxy: proxy, class: tt.class}, tt.peerAuthenticated)
+			if (f != nil) != tt.wantFilter {
+				t.Fatalf("expected filter %v, got %v", tt.wantFilter, f)
+			}
+			if f == nil {
+				return
+			}
+			lrl := &localratelimit.LocalRateLimit{}
+			if err := f.GetTypedConfig().UnmarshalTo(lrl); err != nil {
+				t.Fatal(err)
+			}
+			if lrl.GetTokenBucket().GetMaxTokens() != 1000 {
+				t.Errorf("expected shared bucket of 1000 tokens, got %v", lrl.GetTokenBucket())
+			}
+			if len(lrl.Descriptors) != tt.wantDescriptors {
+				t.Fatalf("expected %d descriptors, got %v", tt.wantDescriptors, lrl.Descriptors)
+			}
+			if tt.wantDescriptors > 0 {
+				bucket := lrl.Descriptors[0].GetTokenBucket()
+				if bucket.GetMaxTokens() != 100 || bucket.GetFillInterval().AsDuration() != 2*time.Second {
+					t.Errorf("unexpected client bucket %v", bucket)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalRateLimitActions(t *testing.T) {
+	proxy := &model.Proxy{Metadata: &model.NodeMetadata{
+		LocalRateLimit: `{"clients": [
+			{"namespace": "batch", "maxTokens": 100},
+			{"principal": "cluster.local/ns/batch/sa/loader", "maxTokens": 10}]}`,
+	}}
+	actions := buildLocalRateLimitActions(proxy)
+	if len(actions) != 2 {
+		t.Fatalf("expected 2 rate limits, got %v", actions)
+	}
+	xfcc := `By=spiffe://cluster.local/ns/default/sa/server;Hash=abc;Subject="";URI=spiffe://cluster.local/ns/batch/sa/loader`
+	cases := []struct {
+		name   string
+		action int
+		header string
+		want   bool
+	}{
+		{name: "principal", action: 0, header: xfcc, want: true},
+		{name: "principal with dns", action: 0, header: xfcc + ";DNS=loader.batch", want: true},
+		{name: "namespace", action: 1, header: xfcc, want: true},
+		{name: "other principal", action: 0, header: `By=a;URI=spiffe://cluster.local/ns/batch/sa/other`},
+		{name: "forwarded principal", action: 0, header: xfcc + `,By=a;URI=spiffe://cluster.local/ns/default/sa/other`},
+		{name: "other namespace", action: 1, header: `By=a;URI=spiffe://cluster.local/ns/batch2/sa/loader`},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			m := actions[tt.action].Actions[0].GetHeaderValueMatch().Headers[0]
+			if m.Name != "x-forwarded-client-cert" {
+				t.Fatalf("unexpected header %s", m.Name)
+			}
+			re := regexp.MustCompile("^(?:" + m.GetSafeRegexMatch().GetRegex() + ")$")
+			if got := re.MatchString(tt.header); got != tt.want {
+				t.Fatalf("expected match %v for %s, got %v", tt.want, tt.header, got)
+			}
+		})
+	}
+}
@@ -40,7 +40,9 @@ import (
 	authz "istio.io/istio/pilot/pkg/security/authz/model"
 	"istio.io/istio/pilot/pkg/util/constant"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/consistenthash"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/faultinjection"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/proto"
@@ -94,7 +96,7 @@ func BuildSidecarVirtualHostWrapper(routeCache *Cache, node *model.Proxy, push *
 	// dependentDestinationRules includes all the destinationrules referenced by the virtualservices, which have consistent hash policy.
 	dependentDestinationRules := []*config.Config{}
 	// consistent hash policies for the http route destinations
-	hashByDestination := map[*networking.HTTPRouteDestination]*networking.LoadBalancerSettings_ConsistentHashLB{}
+	hashByDestination := map[*networking.HTTPRouteDestination][]*route.RouteAction_HashPolicy{}
 	for _, virtualService := range virtualServices {
 		for _, httpRoute := range virtualService.Spec.(*networking.VirtualService).Http {
 			for _, destination := range httpRoute.Route {
@@ -107,7 +109,7 @@ func BuildSidecarVirtualHostWrapper(routeCache *Cache, node *model.Proxy, push *
 				}
 				hash, destinationRule := GetHashForHTTPDestination(push, node, destination, configNamespace)
 				if hash != nil {
-					hashByDestination[destination] = hash
+					hashByDestination[destination] = consistentHashToHashPolicies(hash, destinationRule)
 					dependentDestinationRules = append(dependentDestinationRules, destinationRule)
 				}
 			}
@@ -127,16 +129,16 @@ func BuildSidecarVirtualHostWrapper(routeCache *Cache, node *model.Proxy, push *
 		}
 	}
 
-	hashByService := map[host.Name]map[int]*networking.LoadBalancerSettings_ConsistentHashLB{}
+	hashByService := map[host.Name]map[int][]*route.RouteAction_HashPolicy{}
 	for _, svc := range serviceRegistry {
 		for _, port := range svc.Ports {
 			if port.Protocol.IsHTTP() || util.IsProtocolSniffingEnabledForPort(port) {
 				hash, destinationRule := getHashForService(node, push, svc, port)
 				if hash != nil {
 					if _, ok := hashByService[svc.Hostname]; !ok {
-						hashByService[svc.Hostname] = map[int]*networking.LoadBalancerSettings_ConsistentHashLB{}
+						hashByService[svc.Hostname] = map[int][]*route.RouteAction_HashPolicy{}
 					}
-					hashByService[svc.Hostname][port.Port] = hash
+					hashByService[svc.Hostname][port.Port] = consistentHashToHashPolicies(hash, destinationRule)
 					dependentDestinationRules = append(dependentDestinationRules, destinationRule)
 				}
 			}
@@ -203,7 +205,7 @@ func buildSidecarVirtualHostsForVirtualService(
 	node *model.Proxy,
 	virtualService config.Config,
 	serviceRegistry map[host.Name]*model.Service,
-	hashByDestination map[*networking.HTTPRouteDestination]*networking.LoadBalancerSettings_ConsistentHashLB,
+	hashByDestination map[*networking.HTTPRouteDestination][]*route.RouteAction_HashPolicy,
 	listenPort int,
 	mesh *meshconfig.MeshConfig,
 ) []VirtualHostWrapper {
@@ -253,7 +255,7 @@ func buildSidecarVirtualHostsForVirtualService(
 
 func buildSidecarVirtualHostsForService(
 	serviceRegistry map[host.Name]*model.Service,
-	hashByService map[host.Name]map[int]*networking.LoadBalancerSettings_ConsistentHashLB,
+	hashByService map[host.Name]map[int][]*route.RouteAction_HashPolicy,
 	mesh *meshconfig.MeshConfig,
 ) []VirtualHostWrapper {
 	out := make([]VirtualHostWrapper, 0)
@@ -266,9 +268,8 @@ func buildSidecarVirtualHostsForService(
 
 				// if this host has no virtualservice, the consistentHash on its destinationRule will be useless
 				if hashByPort, ok := hashByService[svc.Hostname]; ok {
-					hashPolicy := consistentHashToHashPolicy(hashByPort[port.Port])
-					if hashPolicy != nil {
-						httpRoute.GetRoute().HashPolicy = []*route.RouteAction_HashPolicy{hashPolicy}
+					if hashPolicies := hashByPort[port.Port]; len(hashPolicies) > 0 {
+						httpRoute.GetRoute().HashPolicy = hashPolicies
 					}
 				}
 				out = append(out, VirtualHostWrapper{
@@ -313,7 +314,7 @@ func BuildHTTPRoutesForVirtualService(
 	node *model.Proxy,
 	virtualService config.Config,
 	serviceRegistry map[host.Name]*model.Service,
-	hashByDestination map[*networking.HTTPRouteDestination]*networking.LoadBalancerSettings_ConsistentHashLB,
+	hashByDestination map[*networking.HTTPRouteDestination][]*route.RouteAction_HashPolicy,
 	listenPort int,
 	gatewayNames map[string]bool,
 	isHTTP3AltSvcHeaderNeeded bool,
@@ -326,18 +327,24 @@ func BuildHTTPRoutesForVirtualService(
 
 	out := make([]*route.Route, 0, len(vs.Http))
 
+	faultTargets, err := faultinjection.Parse(virtualService.Annotations)
+	if err != nil {
+		log.Warnf("ignoring fault targets of virtual service %s/%s: %v", virtualService.Namespace, virtualService.Name, err)
+	}
+
 	catchall := false
 	for _, http := range vs.Http {
+		faults := faultinjection.ForRoute(faultTargets, http)
 		if len(http.Match) == 0 {
 			if r := translateRoute(node, http, nil, listenPort, virtualService, serviceRegistry,
-				hashByDestination, gatewayNames, isHTTP3AltSvcHeaderNeeded, mesh); r != nil {
+				hashByDestination, faults, gatewayNames, isHTTP3AltSvcHeaderNeeded, mesh); r != nil {
 				out = append(out, r)
 			}
 			catchall = true
 		} else {
 			for _, match := range http.Match {
 				if r := translateRoute(node, http, match, listenPort, virtualService, serviceRegistry,
-					hashByDestination, gatewayNames, isHTTP3AltSvcHeaderNeeded, mesh); r != nil {
+					hashByDestination, faults, gatewayNames, isHTTP3AltSvcHeaderNeeded, mesh); r != nil {
 					out = append(out, r)
 					// This is a catch all path. Routes are matched in order, so we will never go beyond this match
 					// As an optimization, we can just top sending any more routes here.
@@ -388,7 +395,8 @@ func translateRoute(
 	listenPort int,
 	virtualService config.Config,
 	serviceRegistry map[host.Name]*model.Service,
-	hashByDestination map[*networking.HTTPRouteDestination]*networking.LoadBalancerSettings_ConsistentHashLB,
+	hashByDestination map[*networking.HTTPRouteDestination][]*route.RouteAction_HashPolicy,
+	faultTargets []*faultinjection.Target,
 	gatewayNames map[string]bool,
 	isHTTP3AltSvcHeaderNeeded bool,
 	mesh *meshconfig.MeshConfig,
@@ -438,6 +446,9 @@ func translateRoute(
 		out.TypedPerFilterConfig = make(map[string]*any.Any)
 		out.TypedPerFilterConfig[wellknown.Fault] = util.MessageToAny(translateFault(in.Fault))
 	}
+	if len(faultTargets) > 0 {
+		applyFaultTargets(out, faultTargets)
+	}
 
 	if isHTTP3AltSvcHeaderNeeded {
 		http3AltSvcHeader := buildHTTP3AltSvcHeader(listenPort, util.ALPNHttp3OverQUIC)
@@ -458,7 +469,7 @@ func applyHTTPRouteDestination(
 	authority string,
 	serviceRegistry map[host.Name]*model.Service,
 	listenerPort int,
-	hashByDestination map[*networking.HTTPRouteDestination]*networking.LoadBalancerSettings_ConsistentHashLB) {
+	hashByDestination map[*networking.HTTPRouteDestination][]*route.RouteAction_HashPolicy) {
 	policy := in.Retries
 	if policy == nil {
 		// No VS policy set, use mesh defaults
@@ -540,11 +551,7 @@ func applyHTTPRouteDestination(
 		}
 
 		weighted = append(weighted, clusterWeight)
-		hash := hashByDestination[dst]
-		hashPolicy := consistentHashToHashPolicy(hash)
-		if hashPolicy != nil {
-			action.HashPolicy = append(action.HashPolicy, hashPolicy)
-		}
+		action.HashPolicy = append(action.HashPolicy, hashByDestination[dst]...)
 	}
 
 	// rewrite to a single cluster if there is only weighted cluster
@@ -1155,6 +1162,59 @@ func translateFault(in *networking.HTTPFaultInjection) *xdshttpfault.HTTPFault {
 	return &out
 }
 
+// applyFaultTargets adds the targeted faults of the route. A fault without subset applies to the route like its own
+// fault, while the fault of a subset is set on the clusters of the subset, overriding the fault of the route.
+func applyFaultTargets(out *route.Route, targets []*faultinjection.Target) {
+	setFault := func(config map[string]*any.Any, fault *xdshttpfault.HTTPFault) map[string]*any.Any {
+		if config == nil {
+			config = make(map[string]*any.Any)
+		}
+		config[wellknown.Fault] = util.MessageToAny(fault)
+		return config
+	}
+	faults := make([]*xdshttpfault.HTTPFault, len(targets))
+	// Apply the faults of the route first, so that the faults of subsets override them.
+	for i, t := range targets {
+		faults[i] = translateFaultTarget(t)
+		if faults[i] != nil && t.Subset == "" {
+			out.TypedPerFilterConfig = setFault(out.TypedPerFilterConfig, faults[i])
+		}
+	}
+	action := out.GetRoute()
+	for i, t := range targets {
+		fault := faults[i]
+		if fault == nil || t.Subset == "" || action == nil {
+			continue
+		}
+		if weighted := action.GetWeightedClusters(); weighted != nil {
+			for _, c := range weighted.Clusters {
+				if _, subset, _, _ := model.ParseSubsetKey(c.Name); subset == t.Subset {
+					c.TypedPerFilterConfig = setFault(c.TypedPerFilterConfig, fault)
+				}
+			}
+		} else if _, subset, _, _ := model.ParseSubsetKey(action.GetCluster()); subset == t.Subset {
+			out.TypedPerFilterConfig = setFault(out.TypedPerFilterConfig, fault)
+		}
+	}
+}
+
+// translateFaultTarget translates a targeted fault, restricting it to the requests matching its headers.
+func translateFaultTarget(t *faultinjection.Target) *xdshttpfault.HTTPFault {
+	out := translateFault(t.Fault)
+	if out == nil {
+		return nil
+	}
+	names := make([]string, 0, len(t.Headers))
+	for name := range t.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		out.Headers = append(out.Headers, translateHeaderMatch(name, t.Headers[name]))
+	}
+	return out
+}
+
 func portLevelSettingsConsistentHash(dst *networking.Destination,
 	pls []*networking.TrafficPolicy_PortTrafficPolicy) *networking.LoadBalancerSettings_ConsistentHashLB {
 	if dst.Port != nil {
@@ -1170,16 +1230,50 @@ func portLevelSettingsConsistentHash(dst *networking.Destination,
 	return nil
 }
 
+// consistentHashToHashPolicies returns the hash policies for a consistent hash key, including any additional
+// keys configured on the destination rule with the consistenthash annotations.
+func consistentHashToHashPolicies(consistentHash *networking.LoadBalancerSettings_ConsistentHashLB,
+	destinationRule *config.Config) []*route.RouteAction_HashPolicy {
+	hashPolicy := consistentHashToHashPolicy(consistentHash)
+	if hashPolicy == nil {
+		return nil
+	}
+	hashPolicies := []*route.RouteAction_HashPolicy{hashPolicy}
+	if destinationRule == nil {
+		return hashPolicies
+	}
+	ext, err := consistenthash.Parse(destinationRule.Annotations)
+	if err != nil {
+		log.Warnf("ignoring consistent hash annotations on destination rule %s/%s: %v",
+			destinationRule.Namespace, destinationRule.Name, err)
+		return hashPolicies
+	}
+	// Envoy combines the hashes of all policies into a single hash.
+	for _, header := range ext.Headers {
+		hashPolicies = append(hashPolicies, headerToHashPolicy(header))
+	}
+	if ext.FallbackHeader != "" {
+		// If the primary key is present, stop there; otherwise hash on the fallback header.
+		hashPolicy.Terminal = true
+		hashPolicies = append(hashPolicies, headerToHashPolicy(ext.FallbackHeader))
+	}
+	return hashPolicies
+}
+
+func headerToHashPolicy(header string) *route.RouteAction_HashPolicy {
+	return &route.RouteAction_HashPolicy{
+		PolicySpecifier: &route.RouteAction_HashPolicy_Header_{
+			Header: &route.RouteAction_HashPolicy_Header{
+				HeaderName: header,
+			},
+		},
+	}
+}
+
 func consistentHashToHashPolicy(consistentHash *networking.LoadBalancerSettings_ConsistentHashLB) *route.RouteAction_HashPolicy {
 	switch consistentHash.GetHashKey().(type) {
 	case *networking.LoadBalancerSettings_ConsistentHashLB_HttpHeaderName:
-		return &route.RouteAction_HashPolicy{
-			PolicySpecifier: &route.RouteAction_HashPolicy_Header_{
-				Header: &route.RouteAction_HashPolicy_Header{
-					HeaderName: consistentHash.GetHttpHeaderName(),
-				},
-			},
-		}
+		return headerToHashPolicy(consistentHash.GetHttpHeaderName())
 	case *networking.LoadBalancerSettings_ConsistentHashLB_HttpCookie:
 		cookie := consistentHash.GetHttpCookie()
 		var ttl *durationpb.Duration
@@ -1242,8 +1336,8 @@ func getHashForService(node *model.Proxy, push *model.PushContext,
 
 func GetConsistentHashForVirtualService(push *model.PushContext, node *model.Proxy,
 	virtualService config.Config,
-	serviceRegistry map[host.Name]*model.Service) map[*networking.HTTPRouteDestination]*networking.LoadBalancerSettings_ConsistentHashLB {
-	hashByDestination := map[*networking.HTTPRouteDestination]*networking.LoadBalancerSettings_ConsistentHashLB{}
+	serviceRegistry map[host.Name]*model.Service) map[*networking.HTTPRouteDestination][]*route.RouteAction_HashPolicy {
+	hashByDestination := map[*networking.HTTPRouteDestination][]*route.RouteAction_HashPolicy{}
 	for _, httpRoute := range virtualService.Spec.(*networking.VirtualService).Http {
 		for _, destination := range httpRoute.Route {
 			hostName := destination.Destination.Host
@@ -1253,9 +1347,9 @@ func GetConsistentHashForVirtualService(push *model.PushContext, node *model.Pro
 			} else {
 				configNamespace = virtualService.Namespace
 			}
-			hash, _ := GetHashForHTTPDestination(push, node, destination, configNamespace)
+			hash, destinationRule := GetHashForHTTPDestination(push, node, destination, configNamespace)
 			if hash != nil {
-				hashByDestination[destination] = hash
+				hashByDestination[destination] = consistentHashToHashPolicies(hash, destinationRule)
 			}
 		}
 	}
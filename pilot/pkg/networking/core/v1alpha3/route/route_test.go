@@ -22,8 +22,11 @@ import (
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoyroute "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	xdshttpfault "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/fault/v3"
 	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/gogo/protobuf/types"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/onsi/gomega"
 	"google.golang.org/protobuf/types/known/durationpb"
 	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
@@ -36,6 +39,8 @@ import (
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/test/xdstest"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/consistenthash"
+	"istio.io/istio/pkg/config/faultinjection"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/config/schema/gvk"
@@ -177,6 +182,39 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		g.Expect(len(routes)).To(gomega.Equal(1))
 	})
 
+	t.Run("for virtual service with fault targets", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
+
+		routes, err := route.BuildHTTPRoutesForVirtualService(node(cg), virtualServiceWithFaultTargets,
+			serviceRegistry, nil, 8080, gatewayNames, false, nil)
+		xdstest.ValidateRoutes(t, routes)
+
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(len(routes)).To(gomega.Equal(1))
+		fault := func(config map[string]*any.Any) *xdshttpfault.HTTPFault {
+			if config[wellknown.Fault] == nil {
+				return nil
+			}
+			out := &xdshttpfault.HTTPFault{}
+			g.Expect(config[wellknown.Fault].UnmarshalTo(out)).To(gomega.Succeed())
+			return out
+		}
+		// The fault of the route applies to the requests of all subsets but v2.
+		routeFault := fault(routes[0].TypedPerFilterConfig)
+		g.Expect(routeFault.GetAbort().GetPercentage().GetNumerator()).To(gomega.Equal(uint32(100000)))
+		g.Expect(routeFault.GetHeaders()).To(gomega.BeEmpty())
+		clusters := routes[0].GetRoute().GetWeightedClusters().GetClusters()
+		g.Expect(len(clusters)).To(gomega.Equal(2))
+		g.Expect(fault(clusters[0].TypedPerFilterConfig)).To(gomega.BeNil())
+		subsetFault := fault(clusters[1].TypedPerFilterConfig)
+		g.Expect(subsetFault.GetAbort().GetPercentage().GetNumerator()).To(gomega.Equal(uint32(500000)))
+		g.Expect(subsetFault.GetHeaders()).To(gomega.Equal([]*envoyroute.HeaderMatcher{{
+			Name:                 "x-chaos",
+			HeaderMatchSpecifier: &envoyroute.HeaderMatcher_ExactMatch{ExactMatch: "on"},
+		}}))
+	})
+
 	t.Run("for virtual service with multi prefix catch all route", func(t *testing.T) {
 		g := gomega.NewWithT(t)
 		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{})
@@ -416,6 +454,121 @@ func TestBuildHTTPRoutes(t *testing.T) {
 		g.Expect(routes[0].GetRoute().GetHashPolicy()).To(gomega.ConsistOf(hashPolicy))
 	})
 
+	t.Run("for virtual service with query param based ring hash and header fallback", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{
+			Services: exampleService,
+			Configs: []config.Config{
+				{
+					Meta: config.Meta{
+						GroupVersionKind: gvk.DestinationRule,
+						Name:             "acme",
+						Namespace:        "istio-system",
+						Annotations:      map[string]string{consistenthash.FallbackHeaderAnnotation: "x-user"},
+					},
+					Spec: &networking.DestinationRule{
+						Host: "*.example.org",
+						TrafficPolicy: &networking.TrafficPolicy{
+							LoadBalancer: &networking.LoadBalancerSettings{
+								LbPolicy: &networking.LoadBalancerSettings_ConsistentHash{
+									ConsistentHash: &networking.LoadBalancerSettings_ConsistentHashLB{
+										HashKey: &networking.LoadBalancerSettings_ConsistentHashLB_HttpQueryParameterName{
+											HttpQueryParameterName: "query",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+
+		proxy := node(cg)
+		hashByDestination := route.GetConsistentHashForVirtualService(cg.PushContext(), proxy, virtualServicePlain, serviceRegistry)
+		routes, err := route.BuildHTTPRoutesForVirtualService(proxy, virtualServicePlain, serviceRegistry,
+			hashByDestination, 8080, gatewayNames, false, nil)
+		xdstest.ValidateRoutes(t, routes)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(len(routes)).To(gomega.Equal(1))
+
+		hashPolicy := &envoyroute.RouteAction_HashPolicy{
+			PolicySpecifier: &envoyroute.RouteAction_HashPolicy_QueryParameter_{
+				QueryParameter: &envoyroute.RouteAction_HashPolicy_QueryParameter{
+					Name: "query",
+				},
+			},
+			Terminal: true,
+		}
+		fallbackPolicy := &envoyroute.RouteAction_HashPolicy{
+			PolicySpecifier: &envoyroute.RouteAction_HashPolicy_Header_{
+				Header: &envoyroute.RouteAction_HashPolicy_Header{
+					HeaderName: "x-user",
+				},
+			},
+		}
+		g.Expect(routes[0].GetRoute().GetHashPolicy()).To(gomega.Equal([]*envoyroute.RouteAction_HashPolicy{hashPolicy, fallbackPolicy}))
+	})
+
+	t.Run("for virtual service with query param based ring hash and combined headers", func(t *testing.T) {
+		g := gomega.NewWithT(t)
+		cg := v1alpha3.NewConfigGenTest(t, v1alpha3.TestOptions{
+			Services: exampleService,
+			Configs: []config.Config{
+				{
+					Meta: config.Meta{
+						GroupVersionKind: gvk.DestinationRule,
+						Name:             "acme",
+						Namespace:        "istio-system",
+						Annotations:      map[string]string{consistenthash.HeadersAnnotation: "x-user,x-tenant"},
+					},
+					Spec: &networking.DestinationRule{
+						Host: "*.example.org",
+						TrafficPolicy: &networking.TrafficPolicy{
+							LoadBalancer: &networking.LoadBalancerSettings{
+								LbPolicy: &networking.LoadBalancerSettings_ConsistentHash{
+									ConsistentHash: &networking.LoadBalancerSettings_ConsistentHashLB{
+										HashKey: &networking.LoadBalancerSettings_ConsistentHashLB_HttpQueryParameterName{
+											HttpQueryParameterName: "query",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+
+		proxy := node(cg)
+		hashByDestination := route.GetConsistentHashForVirtualService(cg.PushContext(), proxy, virtualServicePlain, serviceRegistry)
+		routes, err := route.BuildHTTPRoutesForVirtualService(proxy, virtualServicePlain, serviceRegistry,
+			hashByDestination, 8080, gatewayNames, false, nil)
+		xdstest.ValidateRoutes(t, routes)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(len(routes)).To(gomega.Equal(1))
+
+		hashPolicy := &envoyroute.RouteAction_HashPolicy{
+			PolicySpecifier: &envoyroute.RouteAction_HashPolicy_QueryParameter_{
+				QueryParameter: &envoyroute.RouteAction_HashPolicy_QueryParameter{
+					Name: "query",
+				},
+			},
+		}
+		headerPolicy := func(name string) *envoyroute.RouteAction_HashPolicy {
+			return &envoyroute.RouteAction_HashPolicy{
+				PolicySpecifier: &envoyroute.RouteAction_HashPolicy_Header_{
+					Header: &envoyroute.RouteAction_HashPolicy_Header{
+						HeaderName: name,
+					},
+				},
+			}
+		}
+		g.Expect(routes[0].GetRoute().GetHashPolicy()).To(gomega.Equal([]*envoyroute.RouteAction_HashPolicy{
+			hashPolicy, headerPolicy("x-user"), headerPolicy("x-tenant"),
+		}))
+	})
+
 	t.Run("for virtual service with subsets with ring hash", func(t *testing.T) {
 		g := gomega.NewWithT(t)
 		virtualService := config.Config{
@@ -1140,6 +1293,41 @@ var virtualServiceWithCatchAllRouteWeightedDestination = config.Config{
 	},
 }
 
+var virtualServiceWithFaultTargets = config.Config{
+	Meta: config.Meta{
+		GroupVersionKind: gvk.VirtualService,
+		Name:             "acme",
+		Annotations: map[string]string{
+			faultinjection.TargetsAnnotation: `[{"route": "reviews", "subset": "v2", "headers": {"x-chaos": {"exact": "on"}},
+				"fault": {"abort": {"httpStatus": 503, "percentage": {"value": 50}}}}]`,
+		},
+	},
+	Spec: &networking.VirtualService{
+		Hosts: []string{"reviews.test.istio.io"},
+		Http: []*networking.HTTPRoute{
+			{
+				Name: "reviews",
+				Route: []*networking.HTTPRouteDestination{
+					{
+						Destination: &networking.Destination{Host: "reviews.test.istio.io", Subset: "v1"},
+						Weight:      50,
+					},
+					{
+						Destination: &networking.Destination{Host: "reviews.test.istio.io", Subset: "v2"},
+						Weight:      50,
+					},
+				},
+				Fault: &networking.HTTPFaultInjection{
+					Abort: &networking.HTTPFaultInjection_Abort{
+						ErrorType:  &networking.HTTPFaultInjection_Abort_HttpStatus{HttpStatus: 500},
+						Percentage: &networking.Percent{Value: 10},
+					},
+				},
+			},
+		},
+	},
+}
+
 var virtualServiceWithHeaderOperationsForSingleCluster = config.Config{
 	Meta: config.Meta{
 		GroupVersionKind: gvk.VirtualService,
@@ -1281,6 +1281,86 @@ spec:
 	}
 }
 
+func TestOutboundTrafficAudit(t *testing.T) {
+	old := features.OutboundTrafficAudit
+	features.OutboundTrafficAudit = true
+	t.Cleanup(func() { features.OutboundTrafficAudit = old })
+
+	cases := []struct {
+		name     string
+		mode     meshconfig.MeshConfig_OutboundTrafficPolicy_Mode
+		audit    string
+		expected string
+	}{
+		// Traffic REGISTRY_ONLY would block is forwarded separately from the traffic ALLOW_ANY allows.
+		{"registry only audited", meshconfig.MeshConfig_OutboundTrafficPolicy_REGISTRY_ONLY, "true", util.AuditPassthroughCluster},
+		// The audit is opt-in per Sidecar.
+		{"registry only", meshconfig.MeshConfig_OutboundTrafficPolicy_REGISTRY_ONLY, "false", util.BlackHoleCluster},
+		{"allow any audited", meshconfig.MeshConfig_OutboundTrafficPolicy_ALLOW_ANY, "true", util.PassthroughCluster},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			o := xds.FakeOptions{
+				MeshConfig: func() *meshconfig.MeshConfig {
+					m := mesh.DefaultMeshConfig()
+					m.OutboundTrafficPolicy.Mode = tt.mode
+					return &m
+				}(),
+			}
+			httpResult := simulation.Result{ClusterMatched: tt.expected, VirtualHostMatched: util.Passthrough}
+			if tt.expected == util.BlackHoleCluster {
+				httpResult = simulation.Result{VirtualHostMatched: util.BlackHole}
+			}
+			runSimulationTest(t, nil, o, simulationTest{
+				config: fmt.Sprintf(`
+apiVersion: networking.istio.io/v1alpha3
+kind: Sidecar
+metadata:
+  name: default
+  namespace: default
+  annotations:
+    sidecar.istio.io/outboundTrafficAudit: "%s"
+spec:
+  egress:
+  - hosts:
+    - "*/*"
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+spec:
+  hosts:
+  - istio.io
+  addresses: [1.2.3.4]
+  location: MESH_EXTERNAL
+  resolution: DNS
+  ports:
+  - name: http
+    number: 80
+    protocol: HTTP`, tt.audit),
+				calls: []simulation.Expect{
+					{
+						Name:   "http",
+						Call:   simulation.Call{Port: 80, Protocol: simulation.HTTP, HostHeader: "foo"},
+						Result: httpResult,
+					},
+					{
+						Name:   "tcp",
+						Call:   simulation.Call{Port: 90, Protocol: simulation.TCP},
+						Result: simulation.Result{ClusterMatched: tt.expected},
+					},
+					{
+						Name:   "registry",
+						Call:   simulation.Call{Address: "1.2.3.4", Port: 80, Protocol: simulation.HTTP, HostHeader: "istio.io"},
+						Result: simulation.Result{ClusterMatched: "outbound|80||istio.io"},
+					},
+				},
+			})
+		})
+	}
+}
+
 func TestLoop(t *testing.T) {
 	runSimulationTest(t, nil, xds.FakeOptions{}, simulationTest{
 		calls: []simulation.Expect{
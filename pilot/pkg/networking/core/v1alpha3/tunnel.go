@@ -0,0 +1,228 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"fmt"
+	"strings"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/util/gogo"
+	"istio.io/pkg/log"
+)
+
+// Cross-network traffic to the networks listed in features.CrossNetworkTunnelNetworks is tunneled
+// through HTTP CONNECT. The endpoints of the network gateways of these networks are replaced in EDS by a listener of the proxy
+// per gateway, which receives the mTLS connections of the outbound clusters and sends them in a CONNECT request to
+// the tunnel port of the gateway. The network gateway terminates the tunnels and forwards the mTLS connections to
+// its own SNI routing port, as if they had been sent to it directly.
+
+// crossNetworkTunnelCluster is the cluster of the network gateways forwarding the terminated tunnels to the
+// SNI routing port of the gateway.
+const crossNetworkTunnelCluster = "CrossNetworkTunnelCluster"
+
+// tunneledGateways returns the network gateways the proxy tunnels its cross-network traffic to.
+func tunneledGateways(node *model.Proxy, push *model.PushContext) []model.NetworkGateway {
+	if !push.NetworkManager().IsMultiNetworkEnabled() {
+		return nil
+	}
+	networkView := node.GetNetworkView()
+	var out []model.NetworkGateway
+	for _, gw := range push.NetworkManager().AllGateways() {
+		if !gw.Tunneled || node.InNetwork(gw.Network) {
+			continue
+		}
+		if networkView != nil && !networkView[gw.Network] {
+			continue
+		}
+		out = append(out, gw)
+	}
+	return out
+}
+
+// terminatedTunnelPort returns the SNI routing port of the network gateway the tunnels terminated by the proxy are
+// forwarded to, or 0 if the proxy does not terminate tunnels.
+func terminatedTunnelPort(node *model.Proxy, push *model.PushContext) uint32 {
+	if node.Type != model.Router || !push.NetworkManager().IsMultiNetworkEnabled() {
+		return 0
+	}
+	gateways := model.SortGateways(push.NetworkManager().GatewaysForNetwork(node.Metadata.Network))
+	if len(gateways) == 0 || !gateways[0].Tunneled {
+		return 0
+	}
+	return gateways[0].Port
+}
+
+// buildCrossNetworkTunnelListeners adds the listeners tunneling cross-network traffic to the network gateways, and
+// the listener of the network gateways terminating the tunnels.
+func (lb *ListenerBuilder) buildCrossNetworkTunnelListeners() *ListenerBuilder {
+	var listeners []*listener.Listener
+	for _, gw := range tunneledGateways(lb.node, lb.push) {
+		listeners = append(listeners, buildCrossNetworkTunnelListener(gw))
+	}
+	if terminatedTunnelPort(lb.node, lb.push) != 0 {
+		if l := lb.buildTunnelTerminationListener(); l != nil {
+			listeners = append(listeners, l)
+		}
+	}
+
+	if lb.node.Type == model.SidecarProxy {
+		lb.outboundListeners = append(lb.outboundListeners, listeners...)
+	} else {
+		lb.gatewayListeners = append(lb.gatewayListeners, listeners...)
+	}
+	return lb
+}
+
+// buildCrossNetworkTunnelListener builds the listener of the proxy sending the connections to the network gateway
+// through HTTP CONNECT. The connections are already encrypted by the outbound cluster using them.
+func buildCrossNetworkTunnelListener(gw model.NetworkGateway) *listener.Listener {
+	clusterName := crossNetworkTunnelClusterName(gw)
+	tcpProxy := &tcp.TcpProxy{
+		StatPrefix:       clusterName,
+		ClusterSpecifier: &tcp.TcpProxy_Cluster{Cluster: clusterName},
+		TunnelingConfig: &tcp.TcpProxy_TunnelingConfig{
+			// The authority is the SNI routing address of the gateway, so that the tunnel can be terminated by
+			// the gateway itself or by any HTTP proxy in between.
+			Hostname: fmt.Sprintf("%s:%d", gw.Addr, gw.Port),
+		},
+	}
+	address := gw.TunnelAddress()
+	return &listener.Listener{
+		Name:             strings.TrimPrefix(address, model.UnixAddressPrefix),
+		Address:          util.BuildAddress(address, 0),
+		TrafficDirection: core.TrafficDirection_OUTBOUND,
+		FilterChains: []*listener.FilterChain{{
+			Filters: []*listener.Filter{{
+				Name:       wellknown.TCPProxy,
+				ConfigType: &listener.Filter_TypedConfig{TypedConfig: util.MessageToAny(tcpProxy)},
+			}},
+		}},
+	}
+}
+
+// buildTunnelTerminationListener builds the listener of the network gateway terminating the HTTP CONNECT tunnels of
+// the proxies of other networks.
+func (lb *ListenerBuilder) buildTunnelTerminationListener() *listener.Listener {
+	actualWildcard, _ := getActualWildcardAndLocalHost(lb.node)
+	name := fmt.Sprintf("%s_%d", actualWildcard, model.CrossNetworkTunnelPort)
+	for _, l := range lb.gatewayListeners {
+		if l.Name == name {
+			log.Warnf("gateway %s already listens on the cross-network tunnel port %d", lb.node.ID, model.CrossNetworkTunnelPort)
+			return nil
+		}
+	}
+
+	connectionManager := &hcm.HttpConnectionManager{
+		StatPrefix: crossNetworkTunnelCluster,
+		CodecType:  hcm.HttpConnectionManager_AUTO,
+		RouteSpecifier: &hcm.HttpConnectionManager_RouteConfig{
+			RouteConfig: &route.RouteConfiguration{
+				Name: crossNetworkTunnelCluster,
+				VirtualHosts: []*route.VirtualHost{{
+					Name:    crossNetworkTunnelCluster,
+					Domains: []string{"*"},
+					Routes: []*route.Route{{
+						Match: &route.RouteMatch{
+							PathSpecifier: &route.RouteMatch_ConnectMatcher_{ConnectMatcher: &route.RouteMatch_ConnectMatcher{}},
+						},
+						Action: &route.Route_Route{
+							Route: &route.RouteAction{
+								ClusterSpecifier: &route.RouteAction_Cluster{Cluster: crossNetworkTunnelCluster},
+								UpgradeConfigs: []*route.RouteAction_UpgradeConfig{{
+									UpgradeType:   "CONNECT",
+									ConnectConfig: &route.RouteAction_UpgradeConfig_ConnectConfig{},
+								}},
+								// Tunnels last as long as the connections they carry.
+								Timeout: durationpb.New(0),
+							},
+						},
+					}},
+				}},
+			},
+		},
+		HttpFilters:          []*hcm.HttpFilter{xdsfilters.Router},
+		UpgradeConfigs:       []*hcm.HttpConnectionManager_UpgradeConfig{{UpgradeType: "CONNECT"}},
+		Http2ProtocolOptions: &core.Http2ProtocolOptions{AllowConnect: true},
+	}
+	return &listener.Listener{
+		Name:             name,
+		Address:          withIPv4Compat(lb.node, util.BuildAddress(actualWildcard, model.CrossNetworkTunnelPort)),
+		TrafficDirection: core.TrafficDirection_INBOUND,
+		FilterChains: []*listener.FilterChain{{
+			Filters: []*listener.Filter{{
+				Name:       wellknown.HTTPConnectionManager,
+				ConfigType: &listener.Filter_TypedConfig{TypedConfig: util.MessageToAny(connectionManager)},
+			}},
+		}},
+	}
+}
+
+// buildCrossNetworkTunnelClusters builds the clusters of the tunnels to the network gateways, and the cluster of the
+// network gateways forwarding the terminated tunnels to their SNI routing port.
+func (cb *ClusterBuilder) buildCrossNetworkTunnelClusters(node *model.Proxy) []*cluster.Cluster {
+	var clusters []*cluster.Cluster
+	seen := map[string]struct{}{}
+	for _, gw := range tunneledGateways(node, cb.req.Push) {
+		// Gateways sharing an address but not a SNI routing port share the cluster of the tunnel port.
+		name := crossNetworkTunnelClusterName(gw)
+		if _, f := seen[name]; f {
+			continue
+		}
+		seen[name] = struct{}{}
+		clusters = append(clusters, cb.buildStaticCluster(name, gw.Addr, model.CrossNetworkTunnelPort))
+	}
+	if port := terminatedTunnelPort(node, cb.req.Push); port != 0 {
+		_, localhost := getActualWildcardAndLocalHost(node)
+		clusters = append(clusters, cb.buildStaticCluster(crossNetworkTunnelCluster, localhost, port))
+	}
+	return clusters
+}
+
+func (cb *ClusterBuilder) buildStaticCluster(name, address string, port uint32) *cluster.Cluster {
+	return &cluster.Cluster{
+		Name:                 name,
+		ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_STATIC},
+		ConnectTimeout:       gogo.DurationToProtoDuration(cb.req.Push.Mesh.ConnectTimeout),
+		LoadAssignment: &endpoint.ClusterLoadAssignment{
+			ClusterName: name,
+			Endpoints: []*endpoint.LocalityLbEndpoints{{
+				LbEndpoints: []*endpoint.LbEndpoint{{
+					HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+						Endpoint: &endpoint.Endpoint{Address: util.BuildAddress(address, port)},
+					},
+				}},
+			}},
+		},
+	}
+}
+
+// crossNetworkTunnelClusterName returns the name of the cluster of the tunnel port of the network gateway.
+func crossNetworkTunnelClusterName(gw model.NetworkGateway) string {
+	return model.BuildSubsetKey(model.TrafficDirectionOutbound, "", host.Name(gw.Addr), model.CrossNetworkTunnelPort)
+}
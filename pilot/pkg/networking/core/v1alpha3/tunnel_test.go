@@ -0,0 +1,117 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha3
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/test/xdstest"
+	"istio.io/istio/pkg/network"
+)
+
+func TestCrossNetworkTunnel(t *testing.T) {
+	defer func(v string) { features.CrossNetworkTunnelNetworks = v }(features.CrossNetworkTunnelNetworks)
+	features.CrossNetworkTunnelNetworks = "network2"
+	cg := NewConfigGenTest(t, TestOptions{
+		Gateways: []model.NetworkGateway{
+			{Network: "network1", Cluster: "cluster1", Addr: "1.1.1.1", Port: 15443},
+			{Network: "network2", Cluster: "cluster2", Addr: "2.2.2.2", Port: 15443},
+			{Network: "network2", Cluster: "cluster2", Addr: "2.2.2.2", Port: 443},
+		},
+	})
+
+	cases := []struct {
+		name      string
+		proxyType model.NodeType
+		network   network.ID
+		// tunnels maps the expected tunnel listeners to their authority.
+		tunnels map[string]string
+		// clusters maps the expected tunnel clusters to their endpoints.
+		clusters map[string][]string
+	}{
+		{
+			name:      "sidecar of another network",
+			proxyType: model.SidecarProxy,
+			network:   "network1",
+			tunnels: map[string]string{
+				"@istio-tunnel/2.2.2.2:15443": "2.2.2.2:15443",
+				"@istio-tunnel/2.2.2.2:443":   "2.2.2.2:443",
+			},
+			clusters: map[string][]string{"outbound|15009||2.2.2.2": {"2.2.2.2:15009"}},
+		},
+		{
+			name:      "sidecar of the tunneled network",
+			proxyType: model.SidecarProxy,
+			network:   "network2",
+			tunnels:   map[string]string{},
+			clusters:  map[string][]string{},
+		},
+		{
+			name:      "gateway of another network",
+			proxyType: model.Router,
+			network:   "network1",
+			tunnels: map[string]string{
+				"@istio-tunnel/2.2.2.2:15443": "2.2.2.2:15443",
+				"@istio-tunnel/2.2.2.2:443":   "2.2.2.2:443",
+			},
+			clusters: map[string][]string{"outbound|15009||2.2.2.2": {"2.2.2.2:15009"}},
+		},
+		{
+			name:      "gateway of the tunneled network",
+			proxyType: model.Router,
+			network:   "network2",
+			tunnels:   map[string]string{},
+			// The tunnels are forwarded to the SNI routing port of the first gateway of the network.
+			clusters: map[string][]string{crossNetworkTunnelCluster: {"127.0.0.1:443"}},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := getProxy()
+			proxy.Type = tt.proxyType
+			proxy.Metadata.Network = tt.network
+			proxy = cg.SetupProxy(proxy)
+
+			listeners := cg.Listeners(proxy)
+			tunnels := map[string]string{}
+			for _, l := range listeners {
+				if l.Address.GetPipe() == nil || l.Address.GetPipe().Path != l.Name {
+					continue
+				}
+				tunnels[l.Name] = xdstest.ExtractTCPProxy(t, l.FilterChains[0]).GetTunnelingConfig().GetHostname()
+			}
+			if !reflect.DeepEqual(tunnels, tt.tunnels) {
+				t.Errorf("got tunnels %v, want %v", tunnels, tt.tunnels)
+			}
+			termination := xdstest.ExtractListener("0.0.0.0_15009", listeners)
+			if wantTermination := tt.clusters[crossNetworkTunnelCluster] != nil; (termination != nil) != wantTermination {
+				t.Errorf("got tunnel termination listener %v, want %v", termination != nil, wantTermination)
+			}
+
+			clusters := map[string][]string{}
+			for name, endpoints := range xdstest.ExtractClusterEndpoints(cg.Clusters(proxy)) {
+				if name == crossNetworkTunnelCluster || name == "outbound|15009||2.2.2.2" || name == "outbound|15009||1.1.1.1" {
+					clusters[name] = endpoints
+				}
+			}
+			if !reflect.DeepEqual(clusters, tt.clusters) {
+				t.Errorf("got tunnel clusters %v, want %v", clusters, tt.clusters)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package egressaudit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	prom "github.com/prometheus/common/model"
+
+	istionetworking "istio.io/istio/pilot/pkg/networking"
+)
+
+const (
+	sourceWorkloadLabel          = "source_workload"
+	sourceWorkloadNamespaceLabel = "source_workload_namespace"
+	destServiceLabel             = "destination_service"
+	destServiceNameLabel         = "destination_service_name"
+	unknownDestination           = "unknown"
+)
+
+// PrometheusSource reads the traffic to destinations outside of the registry from the standard Istio metrics,
+// as reported by the source proxies for the PassthroughCluster and AuditPassthroughCluster, in Prometheus.
+type PrometheusSource struct {
+	address string
+	api     promv1.API
+	window  time.Duration
+}
+
+var _ TrafficSource = &PrometheusSource{}
+
+// NewPrometheusSource returns a TrafficSource for the Prometheus server at address, looking at the traffic
+// over the last window.
+func NewPrometheusSource(address string, window time.Duration) (*PrometheusSource, error) {
+	client, err := api.NewClient(api.Config{Address: address})
+	if err != nil {
+		return nil, fmt.Errorf("could not build prometheus client: %v", err)
+	}
+	return &PrometheusSource{address: address, api: promv1.NewAPI(client), window: window}, nil
+}
+
+func (p *PrometheusSource) String() string {
+	return fmt.Sprintf("prometheus %s over %v", p.address, p.window)
+}
+
+// ExternalTraffic counts the HTTP requests and TCP connections sent by each workload to each destination outside
+// of the registry.
+func (p *PrometheusSource) ExternalTraffic(ctx context.Context) ([]Dependency, error) {
+	deps := map[Dependency]*Dependency{}
+	for _, metric := range []string{"istio_requests_total", "istio_tcp_connections_opened_total"} {
+		query := fmt.Sprintf(`sum by (%s, %s, %s, %s) (increase(%s{reporter="source",%s=~"%s|%s"}[%s]))`,
+			sourceWorkloadLabel, sourceWorkloadNamespaceLabel, destServiceLabel, destServiceNameLabel, metric,
+			destServiceNameLabel, istionetworking.PassthroughCluster, istionetworking.AuditPassthroughCluster, prom.Duration(p.window))
+		val, _, err := p.api.Query(ctx, query, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("query %q failed: %v", query, err)
+		}
+		vector, ok := val.(prom.Vector)
+		if !ok {
+			return nil, fmt.Errorf("unexpected result type %v for query %q", val.Type(), query)
+		}
+		addSamples(deps, vector, metric == "istio_tcp_connections_opened_total")
+	}
+	out := make([]Dependency, 0, len(deps))
+	for _, d := range deps {
+		out = append(out, *d)
+	}
+	Sort(out)
+	return out, nil
+}
+
+// addSamples adds the samples to the dependencies, keyed by the dependency without its traffic.
+func addSamples(deps map[Dependency]*Dependency, vector prom.Vector, connections bool) {
+	for _, sample := range vector {
+		key := Dependency{
+			SourceWorkload:  string(sample.Metric[sourceWorkloadLabel]),
+			SourceNamespace: string(sample.Metric[sourceWorkloadNamespaceLabel]),
+			Destination:     string(sample.Metric[destServiceLabel]),
+			Audited:         sample.Metric[destServiceNameLabel] == istionetworking.AuditPassthroughCluster,
+		}
+		if key.Destination == "" {
+			key.Destination = unknownDestination
+		}
+		dep, f := deps[key]
+		if !f {
+			dep = &Dependency{}
+			*dep = key
+			deps[key] = dep
+		}
+		if connections {
+			dep.Connections += float64(sample.Value)
+		} else {
+			dep.Requests += float64(sample.Value)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package egressaudit reports the external dependencies of workloads: the destinations outside of the registry
+// they send traffic to, so that they can be added to the registry before enforcing the REGISTRY_ONLY outbound
+// traffic policy.
+package egressaudit
+
+import (
+	"context"
+	"sort"
+)
+
+// Dependency is the traffic from a workload to a destination outside of the registry.
+type Dependency struct {
+	SourceWorkload  string `json:"sourceWorkload"`
+	SourceNamespace string `json:"sourceNamespace"`
+	// Destination is the host, or the address, the traffic was sent to.
+	Destination string `json:"destination"`
+	// Audited is true for the traffic REGISTRY_ONLY would have blocked, forwarded because outbound traffic is
+	// audited, and false for the traffic allowed by ALLOW_ANY.
+	Audited     bool    `json:"audited"`
+	Requests    float64 `json:"requests"`
+	Connections float64 `json:"connections"`
+}
+
+// TrafficSource provides the traffic observed to destinations outside of the registry.
+type TrafficSource interface {
+	// ExternalTraffic returns the dependencies with any traffic.
+	ExternalTraffic(ctx context.Context) ([]Dependency, error)
+	// String describes the source.
+	String() string
+}
+
+// Report is the external dependencies of all workloads.
+type Report struct {
+	// TrafficSource is empty if no traffic source is configured, in which case no dependency is reported.
+	TrafficSource string       `json:"trafficSource,omitempty"`
+	Error         string       `json:"error,omitempty"`
+	Dependencies  []Dependency `json:"dependencies"`
+}
+
+// Sort orders dependencies by source namespace, source workload and destination.
+func Sort(deps []Dependency) {
+	sort.Slice(deps, func(i, j int) bool {
+		a, b := deps[i], deps[j]
+		if a.SourceNamespace != b.SourceNamespace {
+			return a.SourceNamespace < b.SourceNamespace
+		}
+		if a.SourceWorkload != b.SourceWorkload {
+			return a.SourceWorkload < b.SourceWorkload
+		}
+		if a.Destination != b.Destination {
+			return a.Destination < b.Destination
+		}
+		return !a.Audited && b.Audited
+	})
+}
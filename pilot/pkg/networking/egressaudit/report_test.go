@@ -0,0 +1,61 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package egressaudit
+
+import (
+	"reflect"
+	"testing"
+
+	prom "github.com/prometheus/common/model"
+
+	istionetworking "istio.io/istio/pilot/pkg/networking"
+)
+
+func TestAddSamples(t *testing.T) {
+	sample := func(dest, cluster string, v float64) *prom.Sample {
+		return &prom.Sample{
+			Metric: prom.Metric{
+				sourceWorkloadLabel:          "reviews-v1",
+				sourceWorkloadNamespaceLabel: "default",
+				destServiceLabel:             prom.LabelValue(dest),
+				destServiceNameLabel:         prom.LabelValue(cluster),
+			},
+			Value: prom.SampleValue(v),
+		}
+	}
+	deps := map[Dependency]*Dependency{}
+	addSamples(deps, prom.Vector{
+		sample("api.github.com", istionetworking.PassthroughCluster, 5),
+		sample("api.stripe.com", istionetworking.AuditPassthroughCluster, 2),
+	}, false)
+	addSamples(deps, prom.Vector{
+		sample("api.github.com", istionetworking.PassthroughCluster, 1),
+		sample("", istionetworking.AuditPassthroughCluster, 3),
+	}, true)
+
+	var got []Dependency
+	for _, d := range deps {
+		got = append(got, *d)
+	}
+	Sort(got)
+	want := []Dependency{
+		{SourceWorkload: "reviews-v1", SourceNamespace: "default", Destination: "api.github.com", Requests: 5, Connections: 1},
+		{SourceWorkload: "reviews-v1", SourceNamespace: "default", Destination: "api.stripe.com", Audited: true, Requests: 2},
+		{SourceWorkload: "reviews-v1", SourceNamespace: "default", Destination: unknownDestination, Audited: true, Connections: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
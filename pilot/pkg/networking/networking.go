@@ -57,6 +57,9 @@ const (
 	// Passthrough is the name of the virtual host used to forward traffic to the
 	// PassthroughCluster
 	Passthrough = "allow_any"
+	// AuditPassthroughCluster forwards traffic to the original destination, like PassthroughCluster, for traffic
+	// that REGISTRY_ONLY would block when outbound traffic is audited. It separates that traffic in the metrics.
+	AuditPassthroughCluster = "AuditPassthroughCluster"
 )
 
 // ModelProtocolToListenerProtocol converts from a config.Protocol to its corresponding plugin.ListenerProtocol
@@ -67,6 +67,9 @@ func buildFilter(in *plugin.InputParams, mutable *networking.MutableObjects) err
 			if filter := applier.JwtFilter(); filter != nil {
 				mutable.FilterChains[i].HTTP = append(mutable.FilterChains[i].HTTP, filter)
 			}
+			if filter := applier.JwtSanitizerFilter(); filter != nil {
+				mutable.FilterChains[i].HTTP = append(mutable.FilterChains[i].HTTP, filter)
+			}
 			if filter := applier.AuthNFilter(forSidecar); filter != nil {
 				mutable.FilterChains[i].HTTP = append(mutable.FilterChains[i].HTTP, filter)
 			}
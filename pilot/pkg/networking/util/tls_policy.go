@@ -0,0 +1,199 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/security"
+	"istio.io/pkg/log"
+)
+
+// TLSPolicy is the minimum TLS version and the cipher suites allowed on all TLS contexts generated for a
+// proxy: inbound mTLS, gateway servers and upstream TLS origination.
+type TLSPolicy struct {
+	// MinProtocolVersion is the minimum TLS version. TLS_AUTO means no minimum is enforced.
+	MinProtocolVersion tls.TlsParameters_TlsProtocol
+	// CipherSuites are the allowed cipher suites. Empty means all cipher suites are allowed.
+	CipherSuites []string
+}
+
+// MeshTLSPolicy returns the mesh wide TLS policy of PILOT_TLS_MIN_PROTOCOL_VERSION and PILOT_TLS_CIPHER_SUITES.
+func MeshTLSPolicy() TLSPolicy {
+	return newTLSPolicy(features.TLSMinProtocolVersion, features.TLSCipherSuites)
+}
+
+// ValidateMeshTLSPolicy checks that the minimum TLS version and the cipher suites of the mesh wide TLS policy are
+// supported.
+func ValidateMeshTLSPolicy() error {
+	if v := features.TLSMinProtocolVersion; v != "" {
+		if _, f := networking.ServerTLSSettings_TLSProtocol_value[strings.ToUpper(v)]; !f {
+			return fmt.Errorf("invalid PILOT_TLS_MIN_PROTOCOL_VERSION %q", v)
+		}
+	}
+	for _, c := range features.TLSCipherSuites {
+		if c = strings.TrimSpace(c); c != "" && !security.IsValidCipherSuite(c) {
+			return fmt.Errorf("unsupported cipher suite %q in PILOT_TLS_CIPHER_SUITES", c)
+		}
+	}
+	return nil
+}
+
+// TLSPolicyForProxy returns the TLS policy for a proxy. The settings in the proxy metadata can only tighten the
+// mesh wide policy: a lower minimum version is ignored, and the cipher suites are restricted to the mesh wide ones.
+func TLSPolicyForProxy(node *model.Proxy) TLSPolicy {
+	p := MeshTLSPolicy()
+	if node == nil || node.Metadata == nil {
+		return p
+	}
+	override := newTLSPolicy(node.Metadata.TLSMinProtocolVersion, node.Metadata.TLSCipherSuites)
+	if override.MinProtocolVersion > p.MinProtocolVersion {
+		p.MinProtocolVersion = override.MinProtocolVersion
+	}
+	if len(override.CipherSuites) > 0 {
+		if len(p.CipherSuites) == 0 {
+			p.CipherSuites = override.CipherSuites
+		} else if ciphers := p.allowed(override.CipherSuites); len(ciphers) > 0 {
+			p.CipherSuites = ciphers
+		} else {
+			log.Warnf("ignoring the cipher suites %v of proxy %s, none is allowed by the mesh TLS policy",
+				override.CipherSuites, node.ID)
+		}
+	}
+	return p
+}
+
+func newTLSPolicy(minVersion string, ciphers []string) TLSPolicy {
+	p := TLSPolicy{}
+	if minVersion != "" {
+		v, f := networking.ServerTLSSettings_TLSProtocol_value[strings.ToUpper(minVersion)]
+		if f {
+			p.MinProtocolVersion = tls.TlsParameters_TlsProtocol(v)
+		} else {
+			log.Warnf("ignoring invalid minimum TLS version %q", minVersion)
+		}
+	}
+	for _, c := range ciphers {
+		c = strings.TrimSpace(c)
+		if security.IsValidCipherSuite(c) {
+			p.CipherSuites = append(p.CipherSuites, c)
+		} else if c != "" {
+			log.Warnf("ignoring unsupported cipher suite %q in TLS policy", c)
+		}
+	}
+	return p
+}
+
+// IsEmpty returns true if the policy does not enforce anything.
+func (p TLSPolicy) IsEmpty() bool {
+	return p.MinProtocolVersion == tls.TlsParameters_TLS_AUTO && len(p.CipherSuites) == 0
+}
+
+// String returns a compact representation of the policy, suitable for cache keys.
+func (p TLSPolicy) String() string {
+	if p.IsEmpty() {
+		return ""
+	}
+	return p.MinProtocolVersion.String() + "~" + strings.Join(p.CipherSuites, ",")
+}
+
+// Apply enforces the policy on the TLS parameters of ctx. The minimum version is raised to the policy
+// minimum if lower, and configured cipher suites are restricted to the ones allowed by the policy.
+func (p TLSPolicy) Apply(ctx *tls.CommonTlsContext) {
+	if ctx == nil || p.IsEmpty() {
+		return
+	}
+	if ctx.TlsParams == nil {
+		ctx.TlsParams = &tls.TlsParameters{}
+	}
+	params := ctx.TlsParams
+	if p.MinProtocolVersion != tls.TlsParameters_TLS_AUTO {
+		if params.TlsMinimumProtocolVersion < p.MinProtocolVersion {
+			params.TlsMinimumProtocolVersion = p.MinProtocolVersion
+		}
+		if params.TlsMaximumProtocolVersion != tls.TlsParameters_TLS_AUTO && params.TlsMaximumProtocolVersion < p.MinProtocolVersion {
+			params.TlsMaximumProtocolVersion = p.MinProtocolVersion
+		}
+	}
+	if len(p.CipherSuites) > 0 {
+		params.CipherSuites = p.allowed(params.CipherSuites)
+		if len(params.CipherSuites) == 0 {
+			params.CipherSuites = append([]string(nil), p.CipherSuites...)
+		}
+	}
+}
+
+// allowed returns the suites allowed by the policy.
+func (p TLSPolicy) allowed(suites []string) []string {
+	allowed := make(map[string]struct{}, len(p.CipherSuites))
+	for _, c := range p.CipherSuites {
+		allowed[c] = struct{}{}
+	}
+	out := make([]string, 0, len(suites))
+	for _, c := range suites {
+		if _, f := allowed[c]; f {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// ServerConflicts returns the reasons why the server TLS settings conflict with the policy, if any.
+// Conflicting settings are still accepted, but are overridden by the policy.
+func (p TLSPolicy) ServerConflicts(settings *networking.ServerTLSSettings) []string {
+	if settings == nil || p.IsEmpty() {
+		return nil
+	}
+	var conflicts []string
+	if p.MinProtocolVersion != tls.TlsParameters_TLS_AUTO {
+		minVersion := tls.TlsParameters_TlsProtocol(settings.MinProtocolVersion)
+		if minVersion != tls.TlsParameters_TLS_AUTO && minVersion < p.MinProtocolVersion {
+			conflicts = append(conflicts, fmt.Sprintf("minProtocolVersion %v is lower than the policy minimum %v",
+				settings.MinProtocolVersion, p.MinProtocolVersion))
+		}
+		maxVersion := tls.TlsParameters_TlsProtocol(settings.MaxProtocolVersion)
+		if maxVersion != tls.TlsParameters_TLS_AUTO && maxVersion < p.MinProtocolVersion {
+			conflicts = append(conflicts, fmt.Sprintf("maxProtocolVersion %v is lower than the policy minimum %v",
+				settings.MaxProtocolVersion, p.MinProtocolVersion))
+		}
+	}
+	if len(p.CipherSuites) > 0 && len(settings.CipherSuites) > 0 {
+		var disallowed []string
+		for _, c := range settings.CipherSuites {
+			if !contains(p.CipherSuites, c) {
+				disallowed = append(disallowed, c)
+			}
+		}
+		if len(disallowed) > 0 {
+			conflicts = append(conflicts, fmt.Sprintf("cipherSuites %v are not allowed by the policy", disallowed))
+		}
+	}
+	return conflicts
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestTLSPolicyForProxy(t *testing.T) {
+	defer func(v string) { features.TLSMinProtocolVersion = v }(features.TLSMinProtocolVersion)
+	defer func(v []string) { features.TLSCipherSuites = v }(features.TLSCipherSuites)
+	meshPolicy := TLSPolicy{
+		MinProtocolVersion: tls.TlsParameters_TLSv1_2,
+		CipherSuites:       []string{"ECDHE-RSA-AES256-GCM-SHA384", "ECDHE-RSA-AES128-GCM-SHA256"},
+	}
+	cases := []struct {
+		name     string
+		mesh     bool
+		metadata *model.NodeMetadata
+		want     TLSPolicy
+	}{
+		{
+			name:     "no policy",
+			metadata: &model.NodeMetadata{},
+			want:     TLSPolicy{},
+		},
+		{
+			name:     "mesh policy",
+			mesh:     true,
+			metadata: &model.NodeMetadata{},
+			want:     meshPolicy,
+		},
+		{
+			name:     "workload policy without mesh policy",
+			metadata: &model.NodeMetadata{TLSMinProtocolVersion: "TLSV1_2", TLSCipherSuites: []string{"AES128-GCM-SHA256"}},
+			want:     TLSPolicy{MinProtocolVersion: tls.TlsParameters_TLSv1_2, CipherSuites: []string{"AES128-GCM-SHA256"}},
+		},
+		{
+			name:     "workload tightens",
+			mesh:     true,
+			metadata: &model.NodeMetadata{TLSMinProtocolVersion: "tlsv1_3", TLSCipherSuites: []string{"ECDHE-RSA-AES128-GCM-SHA256"}},
+			want:     TLSPolicy{MinProtocolVersion: tls.TlsParameters_TLSv1_3, CipherSuites: []string{"ECDHE-RSA-AES128-GCM-SHA256"}},
+		},
+		{
+			name:     "workload cannot weaken",
+			mesh:     true,
+			metadata: &model.NodeMetadata{TLSMinProtocolVersion: "TLSV1_0", TLSCipherSuites: []string{"AES128-GCM-SHA256"}},
+			want:     meshPolicy,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			features.TLSMinProtocolVersion, features.TLSCipherSuites = "", nil
+			if tt.mesh {
+				features.TLSMinProtocolVersion = "TLSV1_2"
+				features.TLSCipherSuites = []string{"ECDHE-RSA-AES256-GCM-SHA384", "ECDHE-RSA-AES128-GCM-SHA256", "not-a-cipher"}
+			}
+			if got := TLSPolicyForProxy(&model.Proxy{Metadata: tt.metadata}); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected policy %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTLSPolicyApply(t *testing.T) {
+	policy := TLSPolicy{
+		MinProtocolVersion: tls.TlsParameters_TLSv1_2,
+		CipherSuites:       []string{"ECDHE-RSA-AES256-GCM-SHA384", "ECDHE-RSA-AES128-GCM-SHA256"},
+	}
+	cases := []struct {
+		name string
+		in   *tls.TlsParameters
+		want *tls.TlsParameters
+	}{
+		{
+			name: "unset",
+			in:   nil,
+			want: &tls.TlsParameters{
+				TlsMinimumProtocolVersion: tls.TlsParameters_TLSv1_2,
+				CipherSuites:              policy.CipherSuites,
+			},
+		},
+		{
+			name: "raise versions",
+			in: &tls.TlsParameters{
+				TlsMinimumProtocolVersion: tls.TlsParameters_TLSv1_0,
+				TlsMaximumProtocolVersion: tls.TlsParameters_TLSv1_1,
+			},
+			want: &tls.TlsParameters{
+				TlsMinimumProtocolVersion: tls.TlsParameters_TLSv1_2,
+				TlsMaximumProtocolVersion: tls.TlsParameters_TLSv1_2,
+				CipherSuites:              policy.CipherSuites,
+			},
+		},
+		{
+			name: "stricter config kept",
+			in: &tls.TlsParameters{
+				TlsMinimumProtocolVersion: tls.TlsParameters_TLSv1_3,
+				CipherSuites:              []string{"AES128-GCM-SHA256", "ECDHE-RSA-AES128-GCM-SHA256"},
+			},
+			want: &tls.TlsParameters{
+				TlsMinimumProtocolVersion: tls.TlsParameters_TLSv1_3,
+				CipherSuites:              []string{"ECDHE-RSA-AES128-GCM-SHA256"},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &tls.CommonTlsContext{TlsParams: tt.in}
+			policy.Apply(ctx)
+			if !reflect.DeepEqual(ctx.TlsParams, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, ctx.TlsParams)
+			}
+		})
+	}
+
+	ctx := &tls.CommonTlsContext{}
+	TLSPolicy{}.Apply(ctx)
+	if ctx.TlsParams != nil {
+		t.Fatalf("expected empty policy to be a no-op, got %v", ctx.TlsParams)
+	}
+}
+
+func TestTLSPolicyServerConflicts(t *testing.T) {
+	policy := TLSPolicy{
+		MinProtocolVersion: tls.TlsParameters_TLSv1_2,
+		CipherSuites:       []string{"ECDHE-RSA-AES256-GCM-SHA384"},
+	}
+	if got := policy.ServerConflicts(&networking.ServerTLSSettings{
+		MinProtocolVersion: networking.ServerTLSSettings_TLSV1_3,
+		CipherSuites:       []string{"ECDHE-RSA-AES256-GCM-SHA384"},
+	}); len(got) != 0 {
+		t.Fatalf("expected no conflicts, got %v", got)
+	}
+	if got := policy.ServerConflicts(&networking.ServerTLSSettings{
+		MinProtocolVersion: networking.ServerTLSSettings_TLSV1_0,
+		MaxProtocolVersion: networking.ServerTLSSettings_TLSV1_1,
+		CipherSuites:       []string{"AES128-SHA"},
+	}); len(got) != 3 {
+		t.Fatalf("expected 3 conflicts, got %v", got)
+	}
+}
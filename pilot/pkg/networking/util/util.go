@@ -61,6 +61,8 @@ const (
 	// Passthrough is the name of the virtual host used to forward traffic to the
 	// PassthroughCluster
 	Passthrough = istionetworking.Passthrough
+	// AuditPassthroughCluster forwards the traffic that REGISTRY_ONLY would block when outbound traffic is audited.
+	AuditPassthroughCluster = istionetworking.AuditPassthroughCluster
 	// PassthroughFilterChain to catch traffic that doesn't match other filter chains.
 	PassthroughFilterChain = "PassthroughFilterChain"
 
@@ -556,6 +558,12 @@ func IsAllowAnyOutbound(node *model.Proxy) bool {
 		node.SidecarScope.OutboundTrafficPolicy.Mode == networking.OutboundTrafficPolicy_ALLOW_ANY
 }
 
+// IsOutboundTrafficAudited checks if the traffic blocked by REGISTRY_ONLY is forwarded to the AuditPassthroughCluster
+// instead, because the Sidecar of the node opted into outbound traffic audit
+func IsOutboundTrafficAudited(node *model.Proxy) bool {
+	return node.SidecarScope != nil && node.SidecarScope.OutboundTrafficAudit && !IsAllowAnyOutbound(node)
+}
+
 // BuildStatPrefix builds a stat prefix based on the stat pattern.
 func BuildStatPrefix(statPattern string, host string, subset string, port *model.Port, attributes *model.ServiceAttributes) string {
 	prefix := strings.ReplaceAll(statPattern, serviceStatPattern, shortHostName(host, attributes))
@@ -0,0 +1,99 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtlsreport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	prom "github.com/prometheus/common/model"
+)
+
+const (
+	destWorkloadLabel          = "destination_workload"
+	destWorkloadNamespaceLabel = "destination_workload_namespace"
+	securityPolicyLabel        = "connection_security_policy"
+	mutualTLSPolicy            = "mutual_tls"
+)
+
+// TrafficSource provides the inbound traffic observed for workloads.
+type TrafficSource interface {
+	// InboundTraffic returns the traffic observed for each workload that received any.
+	InboundTraffic(ctx context.Context) (map[WorkloadKey]TrafficStats, error)
+	// String describes the source.
+	String() string
+}
+
+// PrometheusSource reads the inbound traffic of workloads from the standard Istio metrics, as reported by
+// the destination proxies, in Prometheus.
+type PrometheusSource struct {
+	address string
+	api     promv1.API
+	window  time.Duration
+}
+
+var _ TrafficSource = &PrometheusSource{}
+
+// NewPrometheusSource returns a TrafficSource for the Prometheus server at address, looking at the traffic
+// over the last window.
+func NewPrometheusSource(address string, window time.Duration) (*PrometheusSource, error) {
+	client, err := api.NewClient(api.Config{Address: address})
+	if err != nil {
+		return nil, fmt.Errorf("could not build prometheus client: %v", err)
+	}
+	return &PrometheusSource{address: address, api: promv1.NewAPI(client), window: window}, nil
+}
+
+func (p *PrometheusSource) String() string {
+	return fmt.Sprintf("prometheus %s over %v", p.address, p.window)
+}
+
+// InboundTraffic counts the HTTP requests and TCP connections received by each workload.
+func (p *PrometheusSource) InboundTraffic(ctx context.Context) (map[WorkloadKey]TrafficStats, error) {
+	out := map[WorkloadKey]TrafficStats{}
+	for _, metric := range []string{"istio_requests_total", "istio_tcp_connections_opened_total"} {
+		query := fmt.Sprintf(`sum by (%s, %s, %s) (increase(%s{reporter="destination"}[%s]))`,
+			destWorkloadLabel, destWorkloadNamespaceLabel, securityPolicyLabel, metric, prom.Duration(p.window))
+		val, _, err := p.api.Query(ctx, query, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("query %q failed: %v", query, err)
+		}
+		vector, ok := val.(prom.Vector)
+		if !ok {
+			return nil, fmt.Errorf("unexpected result type %v for query %q", val.Type(), query)
+		}
+		addSamples(out, vector)
+	}
+	return out, nil
+}
+
+func addSamples(out map[WorkloadKey]TrafficStats, vector prom.Vector) {
+	for _, sample := range vector {
+		key := WorkloadKey{
+			Name:      string(sample.Metric[destWorkloadLabel]),
+			Namespace: string(sample.Metric[destWorkloadNamespaceLabel]),
+		}
+		stats := out[key]
+		if sample.Metric[securityPolicyLabel] == mutualTLSPolicy {
+			stats.MTLS += float64(sample.Value)
+		} else {
+			stats.Plaintext += float64(sample.Value)
+		}
+		out[key] = stats
+	}
+}
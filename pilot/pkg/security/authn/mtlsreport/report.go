@@ -0,0 +1,120 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mtlsreport correlates the PeerAuthentication mode of workloads with the inbound traffic they
+// receive, to find the workloads that can safely be moved from PERMISSIVE to STRICT mTLS.
+package mtlsreport
+
+import (
+	"fmt"
+	"sort"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// WorkloadKey identifies a workload, as reported by the destination_workload metric labels.
+type WorkloadKey struct {
+	Name      string
+	Namespace string
+}
+
+// TrafficStats is the inbound traffic observed for a workload, split by whether it used mTLS.
+type TrafficStats struct {
+	MTLS      float64 `json:"mtls"`
+	Plaintext float64 `json:"plaintext"`
+}
+
+// Workload is a workload and the mTLS mode its PeerAuthentication policies resolve to.
+type Workload struct {
+	WorkloadKey
+	Mode model.MutualTLSMode
+}
+
+// Recommendation is the result of evaluating a workload.
+type Recommendation string
+
+const (
+	// AlreadyStrict means the workload already requires mTLS.
+	AlreadyStrict Recommendation = "STRICT"
+	// SafeForStrict means only mTLS traffic was observed, so the workload can be moved to STRICT.
+	SafeForStrict Recommendation = "SAFE"
+	// NotSafeForStrict means plaintext traffic was observed, or mTLS is disabled.
+	NotSafeForStrict Recommendation = "UNSAFE"
+	// Unknown means no traffic was observed, so compatibility cannot be determined.
+	Unknown Recommendation = "UNKNOWN"
+)
+
+// WorkloadStatus is the mTLS compatibility of a single workload.
+type WorkloadStatus struct {
+	Name           string         `json:"name"`
+	Namespace      string         `json:"namespace"`
+	Mode           string         `json:"mode"`
+	Traffic        TrafficStats   `json:"traffic"`
+	Recommendation Recommendation `json:"recommendation"`
+	Reason         string         `json:"reason"`
+}
+
+// Report is the mTLS compatibility of all workloads.
+type Report struct {
+	// TrafficSource is empty if no traffic source is configured, in which case only modes are reported.
+	TrafficSource string           `json:"trafficSource,omitempty"`
+	Error         string           `json:"error,omitempty"`
+	Workloads     []WorkloadStatus `json:"workloads"`
+}
+
+// Evaluate builds the report for workloads, given the traffic observed for them. A nil traffic map means
+// traffic is not known, either because no traffic source is configured or because it failed with trafficErr.
+func Evaluate(workloads []Workload, traffic map[WorkloadKey]TrafficStats, trafficErr error) []WorkloadStatus {
+	out := make([]WorkloadStatus, 0, len(workloads))
+	for _, w := range workloads {
+		status := WorkloadStatus{
+			Name:      w.Name,
+			Namespace: w.Namespace,
+			Mode:      w.Mode.String(),
+		}
+		stats, observed := traffic[w.WorkloadKey]
+		status.Traffic = stats
+		switch {
+		case w.Mode == model.MTLSStrict:
+			status.Recommendation = AlreadyStrict
+			status.Reason = "mTLS is already required"
+		case w.Mode == model.MTLSDisable:
+			status.Recommendation = NotSafeForStrict
+			status.Reason = "mTLS is disabled by PeerAuthentication"
+		case traffic == nil && trafficErr != nil:
+			status.Recommendation = Unknown
+			status.Reason = fmt.Sprintf("inbound traffic could not be read: %v", trafficErr)
+		case traffic == nil:
+			status.Recommendation = Unknown
+			status.Reason = "no traffic source configured"
+		case !observed || stats.MTLS+stats.Plaintext == 0:
+			status.Recommendation = Unknown
+			status.Reason = "no inbound traffic observed"
+		case stats.Plaintext > 0:
+			status.Recommendation = NotSafeForStrict
+			status.Reason = fmt.Sprintf("%.0f plaintext inbound requests or connections observed", stats.Plaintext)
+		default:
+			status.Recommendation = SafeForStrict
+			status.Reason = "only mTLS inbound traffic observed"
+		}
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Namespace != out[j].Namespace {
+			return out[i].Namespace < out[j].Namespace
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
@@ -0,0 +1,92 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mtlsreport
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	prom "github.com/prometheus/common/model"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestEvaluate(t *testing.T) {
+	workload := func(name string, mode model.MutualTLSMode) Workload {
+		return Workload{WorkloadKey: WorkloadKey{Name: name, Namespace: "default"}, Mode: mode}
+	}
+	workloads := []Workload{
+		workload("strict", model.MTLSStrict),
+		workload("disabled", model.MTLSDisable),
+		workload("safe", model.MTLSPermissive),
+		workload("plaintext", model.MTLSPermissive),
+		workload("idle", model.MTLSPermissive),
+	}
+	traffic := map[WorkloadKey]TrafficStats{
+		{Name: "safe", Namespace: "default"}:      {MTLS: 10},
+		{Name: "plaintext", Namespace: "default"}: {MTLS: 10, Plaintext: 1},
+	}
+	want := map[string]Recommendation{
+		"strict":    AlreadyStrict,
+		"disabled":  NotSafeForStrict,
+		"safe":      SafeForStrict,
+		"plaintext": NotSafeForStrict,
+		"idle":      Unknown,
+	}
+	got := Evaluate(workloads, traffic, nil)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d workloads, got %d", len(want), len(got))
+	}
+	for _, status := range got {
+		if status.Recommendation != want[status.Name] {
+			t.Errorf("%s: expected %v, got %v (%s)", status.Name, want[status.Name], status.Recommendation, status.Reason)
+		}
+	}
+	if got[0].Name != "disabled" || got[len(got)-1].Name != "strict" {
+		t.Errorf("expected workloads sorted by name, got %v", got)
+	}
+
+	for _, status := range Evaluate(workloads[2:3], nil, nil) {
+		if status.Recommendation != Unknown || status.Reason != "no traffic source configured" {
+			t.Errorf("expected unknown without traffic source, got %v (%s)", status.Recommendation, status.Reason)
+		}
+	}
+	for _, status := range Evaluate(workloads[2:3], nil, errors.New("connection refused")) {
+		if status.Recommendation != Unknown || !strings.Contains(status.Reason, "connection refused") {
+			t.Errorf("expected unknown with the traffic source error, got %v (%s)", status.Recommendation, status.Reason)
+		}
+	}
+}
+
+func TestAddSamples(t *testing.T) {
+	sample := func(policy string, v float64) *prom.Sample {
+		return &prom.Sample{
+			Metric: prom.Metric{
+				destWorkloadLabel:          "reviews-v1",
+				destWorkloadNamespaceLabel: "default",
+				securityPolicyLabel:        prom.LabelValue(policy),
+			},
+			Value: prom.SampleValue(v),
+		}
+	}
+	out := map[WorkloadKey]TrafficStats{}
+	addSamples(out, prom.Vector{sample(mutualTLSPolicy, 5), sample("none", 2)})
+	addSamples(out, prom.Vector{sample(mutualTLSPolicy, 1)})
+	got := out[WorkloadKey{Name: "reviews-v1", Namespace: "default"}]
+	if got.MTLS != 6 || got.Plaintext != 2 {
+		t.Fatalf("expected 6 mTLS and 2 plaintext, got %+v", got)
+	}
+}
@@ -32,6 +32,10 @@ type PolicyApplier interface {
 	// It may return nil, if no JWT validation is needed.
 	JwtFilter() *http_conn.HttpFilter
 
+	// JwtSanitizerFilter returns the HTTP filter removing the JWTs extracted from query parameters and cookies,
+	// once verified by the JWT filter. It may return nil, if no token needs to be removed.
+	JwtSanitizerFilter() *http_conn.HttpFilter
+
 	// AuthNFilter returns the (authn) HTTP filter to enforce the underlying authentication policy.
 	// It may return nil, if no authentication is needed.
 	AuthNFilter(forSidecar bool) *http_conn.HttpFilter
@@ -72,6 +72,8 @@ func BuildInboundTLS(mTLSMode model.MutualTLSMode, node *model.Proxy,
 		TlsMinimumProtocolVersion: tls.TlsParameters_TLSv1_2,
 		CipherSuites:              SupportedCiphers,
 	}
+	// Enforce the mesh wide, or workload, TLS policy.
+	util.TLSPolicyForProxy(node).Apply(ctx.CommonTlsContext)
 
 	authn_model.ApplyToCommonTLSContext(ctx.CommonTlsContext, node, []string{}, /*subjectAltNames*/
 		trustDomainAliases, ctx.RequireClientCertificate.Value)
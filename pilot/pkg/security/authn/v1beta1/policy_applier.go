@@ -24,6 +24,7 @@ import (
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_jwt "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
 	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -40,7 +41,9 @@ import (
 	"istio.io/istio/pilot/pkg/security/authn"
 	authn_utils "istio.io/istio/pilot/pkg/security/authn/utils"
 	authn_model "istio.io/istio/pilot/pkg/security/model"
+	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/pkg/log"
 )
 
@@ -55,6 +58,10 @@ type v1beta1PolicyApplier struct {
 	// processedJwtRules is the consolidate JWT rules from all jwtPolicies.
 	processedJwtRules []*v1beta1.JWTRule
 
+	// jwtCookies holds the cookies each JWT rule extracts the token from, set with the
+	// constants.JwtFromCookiesAnnotation annotation of its policy.
+	jwtCookies map[*v1beta1.JWTRule][]string
+
 	consolidatedPeerPolicy *v1beta1.PeerAuthentication
 
 	push *model.PushContext
@@ -65,7 +72,7 @@ func (a *v1beta1PolicyApplier) JwtFilter() *http_conn.HttpFilter {
 		return nil
 	}
 
-	filterConfigProto := convertToEnvoyJwtConfig(a.processedJwtRules, a.push)
+	filterConfigProto := convertToEnvoyJwtConfig(a.processedJwtRules, a.jwtCookies, a.push)
 
 	if filterConfigProto == nil {
 		return nil
@@ -76,6 +83,108 @@ func (a *v1beta1PolicyApplier) JwtFilter() *http_conn.HttpFilter {
 	}
 }
 
+// JwtSanitizerFilter returns a Lua filter removing the query parameters and cookies JWTs are extracted from,
+// for the rules not forwarding the original token, so tokens are not leaked to the application or its logs.
+// The filter is added after the JWT filter, so tokens are removed once verified.
+func (a *v1beta1PolicyApplier) JwtSanitizerFilter() *http_conn.HttpFilter {
+	params := sets.NewSet()
+	cookies := sets.NewSet()
+	for _, rule := range a.processedJwtRules {
+		if rule.ForwardOriginalToken {
+			continue
+		}
+		params.Insert(rule.FromParams...)
+		cookies.Insert(a.jwtCookies[rule]...)
+	}
+	if len(params) == 0 && len(cookies) == 0 {
+		return nil
+	}
+	return &http_conn.HttpFilter{
+		Name: authn_model.JwtSanitizerFilterName,
+		ConfigType: &http_conn.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(&lua.Lua{
+			InlineCode: jwtSanitizerLuaCode(params.SortedList(), cookies.SortedList()),
+		})},
+	}
+}
+
+const jwtSanitizerLua = `local params = {%s}
+local cookies = {%s}
+
+-- remove returns the items of the sep separated list whose name is not in names.
+local function remove(list, sep, names, name_of)
+  local kept = {}
+  for item in string.gmatch(list, "[^" .. sep .. "]+") do
+    local name = name_of(item)
+    if name == nil or not names[name] then
+      table.insert(kept, item)
+    end
+  end
+  return kept
+end
+
+function envoy_on_request(request_handle)
+  local headers = request_handle:headers()
+  local path = headers:get(":path")
+  if path ~= nil and next(params) ~= nil then
+    local base, query = string.match(path, "^([^?]*)%%?(.*)$")
+    if query ~= nil then
+      local kept = remove(query, "&", params, function(p) return string.match(p, "^([^=]*)") end)
+      if #kept == 0 then
+        headers:replace(":path", base)
+      else
+        headers:replace(":path", base .. "?" .. table.concat(kept, "&"))
+      end
+    end
+  end
+  local cookie = headers:get("cookie")
+  if cookie ~= nil and next(cookies) ~= nil then
+    local kept = remove(cookie, ";", cookies, function(c) return string.match(c, "^%%s*([^=]-)%%s*=") end)
+    if #kept == 0 then
+      headers:remove("cookie")
+    else
+      for i, c in ipairs(kept) do
+        kept[i] = string.match(c, "^%%s*(.-)%%s*$")
+      end
+      headers:replace("cookie", table.concat(kept, "; "))
+    end
+  end
+end
+`
+
+// jwtSanitizerLuaCode returns the Lua code removing the given query parameters and cookies from requests.
+func jwtSanitizerLuaCode(params, cookies []string) string {
+	return fmt.Sprintf(jwtSanitizerLua, luaSet(params), luaSet(cookies))
+}
+
+// luaSet returns the Lua table constructor of a set of strings.
+func luaSet(values []string) string {
+	entries := make([]string, 0, len(values))
+	for _, v := range values {
+		entries = append(entries, "["+luaQuote(v)+"] = true")
+	}
+	return strings.Join(entries, ", ")
+}
+
+// luaQuote returns v as a Lua string literal, escaping all characters that are not printable ASCII.
+func luaQuote(v string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c > 0x7e:
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
 func defaultAuthnFilter() *authn_filter.FilterConfig {
 	return &authn_filter.FilterConfig{
 		Policy: &authn_alpha.Policy{},
@@ -159,12 +268,18 @@ func NewPolicyApplier(rootNamespace string,
 	peerPolicies []*config.Config,
 	push *model.PushContext) authn.PolicyApplier {
 	processedJwtRules := []*v1beta1.JWTRule{}
+	jwtCookies := map[*v1beta1.JWTRule][]string{}
 
 	// TODO(diemtvu) should we need to deduplicate JWT with the same issuer.
 	// https://github.com/istio/istio/issues/19245
 	for idx := range jwtPolicies {
 		spec := jwtPolicies[idx].Spec.(*v1beta1.RequestAuthentication)
 		processedJwtRules = append(processedJwtRules, spec.JwtRules...)
+		if cookies := JwtCookies(jwtPolicies[idx]); len(cookies) > 0 {
+			for _, rule := range spec.JwtRules {
+				jwtCookies[rule] = cookies
+			}
+		}
 	}
 
 	// Sort the jwt rules by the issuer alphabetically to make the later-on generated filter
@@ -178,16 +293,30 @@ func NewPolicyApplier(rootNamespace string,
 		jwtPolicies:            jwtPolicies,
 		peerPolices:            peerPolicies,
 		processedJwtRules:      processedJwtRules,
+		jwtCookies:             jwtCookies,
 		consolidatedPeerPolicy: ComposePeerAuthentication(rootNamespace, peerPolicies),
 		push:                   push,
 	}
 }
 
+// JwtCookies returns the cookies the JWT rules of a RequestAuthentication extract the token from, as set with
+// the constants.JwtFromCookiesAnnotation annotation.
+func JwtCookies(policy *config.Config) []string {
+	var cookies []string
+	for _, c := range strings.Split(policy.Annotations[constants.JwtFromCookiesAnnotation], ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			cookies = append(cookies, c)
+		}
+	}
+	return cookies
+}
+
 // convertToEnvoyJwtConfig converts a list of JWT rules into Envoy JWT filter config to enforce it.
 // Each rule is expected corresponding to one JWT issuer (provider).
 // The behavior of the filter should reject all requests with invalid token. On the other hand,
 // if no token provided, the request is allowed.
-func convertToEnvoyJwtConfig(jwtRules []*v1beta1.JWTRule, push *model.PushContext) *envoy_jwt.JwtAuthentication {
+func convertToEnvoyJwtConfig(jwtRules []*v1beta1.JWTRule, jwtCookies map[*v1beta1.JWTRule][]string,
+	push *model.PushContext) *envoy_jwt.JwtAuthentication {
 	if len(jwtRules) == 0 {
 		return nil
 	}
@@ -218,6 +347,7 @@ func convertToEnvoyJwtConfig(jwtRules []*v1beta1.JWTRule, push *model.PushContex
 			})
 		}
 		provider.FromParams = jwtRule.FromParams
+		provider.FromCookies = jwtCookies[jwtRule]
 
 		if features.EnableRemoteJwks && jwtRule.JwksUri != "" {
 			// Use remote jwks if jwksUri is non empty. Parse the jwksUri to get the cluster name,
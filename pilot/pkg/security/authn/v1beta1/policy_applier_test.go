@@ -23,6 +23,7 @@ import (
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_jwt "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	lua "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/lua/v3"
 	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	"github.com/google/go-cmp/cmp"
@@ -41,6 +42,7 @@ import (
 	"istio.io/istio/pilot/pkg/networking/plugin"
 	pilotutil "istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	protovalue "istio.io/istio/pkg/proto"
 )
@@ -713,6 +715,114 @@ func TestJwtFilter(t *testing.T) {
 	}
 }
 
+func TestJwtSanitizerFilter(t *testing.T) {
+	cases := []struct {
+		name            string
+		in              []*config.Config
+		expectedCookies map[string][]string
+		expectedParams  []string
+		expectedCookie  []string
+	}{
+		{
+			name: "token from header",
+			in: []*config.Config{
+				{
+					Spec: &v1beta1.RequestAuthentication{
+						JwtRules: []*v1beta1.JWTRule{{Issuer: "https://secret.foo.com", Jwks: test.JwtPubKey1}},
+					},
+				},
+			},
+		},
+		{
+			name: "token from param and cookies",
+			in: []*config.Config{
+				{
+					Meta: config.Meta{
+						Annotations: map[string]string{constants.JwtFromCookiesAnnotation: "session, id_token"},
+					},
+					Spec: &v1beta1.RequestAuthentication{
+						JwtRules: []*v1beta1.JWTRule{{
+							Issuer:     "https://secret.foo.com",
+							Jwks:       test.JwtPubKey1,
+							FromParams: []string{"access_token"},
+						}},
+					},
+				},
+				{
+					Spec: &v1beta1.RequestAuthentication{
+						JwtRules: []*v1beta1.JWTRule{{
+							Issuer:               "https://forward.foo.com",
+							Jwks:                 test.JwtPubKey1,
+							FromParams:           []string{"forwarded_token"},
+							ForwardOriginalToken: true,
+						}},
+					},
+				},
+			},
+			expectedCookies: map[string][]string{
+				"https://secret.foo.com":  {"session", "id_token"},
+				"https://forward.foo.com": nil,
+			},
+			expectedParams: []string{"access_token"},
+			expectedCookie: []string{"id_token", "session"},
+		},
+	}
+
+	push := model.NewPushContext()
+	push.JwtKeyResolver = model.NewJwksResolver(
+		model.JwtPubKeyEvictionDuration, model.JwtPubKeyRefreshInterval,
+		model.JwtPubKeyRefreshIntervalOnFailure, model.JwtPubKeyRetryInterval)
+	defer push.JwtKeyResolver.Close()
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			applier := NewPolicyApplier("root-namespace", c.in, nil, push)
+
+			jwtConfig := &envoy_jwt.JwtAuthentication{}
+			if err := applier.JwtFilter().GetTypedConfig().UnmarshalTo(jwtConfig); err != nil {
+				t.Fatal(err)
+			}
+			for _, provider := range jwtConfig.Providers {
+				if !reflect.DeepEqual(provider.FromCookies, c.expectedCookies[provider.Issuer]) {
+					t.Errorf("issuer %s: got cookies %v, wanted %v", provider.Issuer, provider.FromCookies, c.expectedCookies[provider.Issuer])
+				}
+			}
+
+			got := applier.JwtSanitizerFilter()
+			if len(c.expectedParams) == 0 && len(c.expectedCookie) == 0 {
+				if got != nil {
+					t.Fatalf("expected no sanitizer filter, got %v", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("expected a sanitizer filter")
+			}
+			luaConfig := &lua.Lua{}
+			if err := got.GetTypedConfig().UnmarshalTo(luaConfig); err != nil {
+				t.Fatal(err)
+			}
+			want := jwtSanitizerLuaCode(c.expectedParams, c.expectedCookie)
+			if luaConfig.InlineCode != want {
+				t.Errorf("got:\n%s\nwanted:\n%s", luaConfig.InlineCode, want)
+			}
+		})
+	}
+}
+
+func TestLuaQuote(t *testing.T) {
+	cases := map[string]string{
+		"access_token": `"access_token"`,
+		`a"b\c`:        `"a\"b\\c"`,
+		"a\nb":         `"a\010b"`,
+	}
+	for in, want := range cases {
+		if got := luaQuote(in); got != want {
+			t.Errorf("luaQuote(%q) = %s, wanted %s", in, got, want)
+		}
+	}
+}
+
 func TestConvertToEnvoyJwtConfig(t *testing.T) {
 	ms, err := test.StartNewServer()
 	if err != nil {
@@ -1020,7 +1130,7 @@ func TestConvertToEnvoyJwtConfig(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			if got := convertToEnvoyJwtConfig(c.in, push); !reflect.DeepEqual(c.expected, got) {
+			if got := convertToEnvoyJwtConfig(c.in, nil, push); !reflect.DeepEqual(c.expected, got) {
 				t.Errorf("got:\n%s\nwanted:\n%s\n", spew.Sdump(got), spew.Sdump(c.expected))
 			}
 		})
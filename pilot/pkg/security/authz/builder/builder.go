@@ -27,6 +27,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 
 	"istio.io/api/annotation"
+	authzpb "istio.io/api/security/v1beta1"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/plugin"
 	"istio.io/istio/pilot/pkg/networking/util"
@@ -55,9 +56,11 @@ type Builder struct {
 	trustDomainBundle trustdomain.Bundle
 	option            Option
 
-	// populated when building for CUSTOM action.
-	customPolicies []model.AuthorizationPolicy
-	extensions     map[string]*builtExtAuthz
+	// populated when building for CUSTOM action. Emergency lockdown policies are built together with the
+	// CUSTOM action, as its filters are the first in the filter chain.
+	lockdownPolicies []model.AuthorizationPolicy
+	customPolicies   []model.AuthorizationPolicy
+	extensions       map[string]*builtExtAuthz
 
 	// populated when building for ALLOW/DENY/AUDIT action.
 	denyPolicies  []model.AuthorizationPolicy
@@ -70,11 +73,12 @@ type Builder struct {
 func New(trustDomainBundle trustdomain.Bundle, in *plugin.InputParams, option Option) *Builder {
 	policies := in.Push.AuthzPolicies.ListAuthorizationPolicies(in.Node.ConfigNamespace, labels.Collection{in.Node.Metadata.Labels})
 	if option.IsCustomBuilder {
-		option.Logger.AppendDebugf("found %d CUSTOM actions", len(policies.Custom))
-		if len(policies.Custom) == 0 {
+		option.Logger.AppendDebugf("found %d CUSTOM actions, %d emergency lockdown policies", len(policies.Custom), len(policies.Lockdown))
+		if len(policies.Custom) == 0 && len(policies.Lockdown) == 0 {
 			return nil
 		}
 		return &Builder{
+			lockdownPolicies:  policies.Lockdown,
 			customPolicies:    policies.Custom,
 			extensions:        processExtensionProvider(in),
 			trustDomainBundle: trustDomainBundle,
@@ -98,12 +102,17 @@ func New(trustDomainBundle trustdomain.Bundle, in *plugin.InputParams, option Op
 // BuildHTTP returns the HTTP filters built from the authorization policy.
 func (b Builder) BuildHTTP() []*httppb.HttpFilter {
 	if b.option.IsCustomBuilder {
+		var filters []*httppb.HttpFilter
+		for _, configs := range b.buildLockdown(false) {
+			b.option.Logger.AppendDebugf("built %d HTTP filters for emergency lockdown", len(configs.http))
+			filters = append(filters, configs.http...)
+		}
 		// Use the DENY action so that a HTTP rule is properly handled when generating for TCP filter chain.
 		if configs := b.build(b.customPolicies, rbacpb.RBAC_DENY, false); configs != nil {
 			b.option.Logger.AppendDebugf("built %d HTTP filters for CUSTOM action", len(configs.http))
-			return configs.http
+			filters = append(filters, configs.http...)
 		}
-		return nil
+		return filters
 	}
 
 	var filters []*httppb.HttpFilter
@@ -125,11 +134,16 @@ func (b Builder) BuildHTTP() []*httppb.HttpFilter {
 // BuildTCP returns the TCP filters built from the authorization policy.
 func (b Builder) BuildTCP() []*tcppb.Filter {
 	if b.option.IsCustomBuilder {
+		var filters []*tcppb.Filter
+		for _, configs := range b.buildLockdown(true) {
+			b.option.Logger.AppendDebugf("built %d TCP filters for emergency lockdown", len(configs.tcp))
+			filters = append(filters, configs.tcp...)
+		}
 		if configs := b.build(b.customPolicies, rbacpb.RBAC_DENY, true); configs != nil {
 			b.option.Logger.AppendDebugf("built %d TCP filters for CUSTOM action", len(configs.tcp))
-			return configs.tcp
+			filters = append(filters, configs.tcp...)
 		}
-		return nil
+		return filters
 	}
 
 	var filters []*tcppb.Filter
@@ -148,6 +162,33 @@ func (b Builder) BuildTCP() []*tcppb.Filter {
 	return filters
 }
 
+// buildLockdown builds the emergency lockdown policies, DENY first and then ALLOW. They are enforced by
+// the RBAC filter like the local ALLOW and DENY actions, so all other authorization policies only apply
+// to the requests allowed by the lockdown policies.
+func (b Builder) buildLockdown(forTCP bool) []*builtConfigs {
+	if len(b.lockdownPolicies) == 0 {
+		return nil
+	}
+	var deny, allow []model.AuthorizationPolicy
+	for _, policy := range b.lockdownPolicies {
+		if policy.Spec.GetAction() == authzpb.AuthorizationPolicy_DENY {
+			deny = append(deny, policy)
+		} else {
+			allow = append(allow, policy)
+		}
+	}
+	local := b
+	local.option.IsCustomBuilder = false
+	var ret []*builtConfigs
+	if configs := local.build(deny, rbacpb.RBAC_DENY, forTCP); configs != nil {
+		ret = append(ret, configs)
+	}
+	if configs := local.build(allow, rbacpb.RBAC_ALLOW, forTCP); configs != nil {
+		ret = append(ret, configs)
+	}
+	return ret
+}
+
 type builtConfigs struct {
 	http []*httppb.HttpFilter
 	tcp  []*tcppb.Filter
@@ -32,6 +32,7 @@ import (
 	"istio.io/istio/pilot/test/util"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/util/protomarshal"
 )
@@ -213,6 +214,18 @@ func TestGenerator_GenerateHTTP(t *testing.T) {
 			input: "dry-run-mix-in.yaml",
 			want:  []string{"dry-run-mix-out.yaml"},
 		},
+		{
+			name:       "lockdown-custom",
+			meshConfig: meshConfigGRPC,
+			input:      "lockdown-in.yaml",
+			want: []string{"lockdown-custom-out1.yaml", "lockdown-custom-out2.yaml",
+				"lockdown-custom-out3.yaml", "lockdown-custom-out4.yaml"},
+		},
+		{
+			name:  "lockdown-local",
+			input: "lockdown-in.yaml",
+			want:  []string{"lockdown-local-out.yaml"},
+		},
 		{
 			name:  "multiple-policies",
 			input: "multiple-policies-in.yaml",
@@ -424,6 +437,7 @@ func newAuthzPolicies(t *testing.T, policies []*config.Config) *model.Authorizat
 
 	authzPolicies, err := model.GetAuthorizationPolicies(&model.Environment{
 		IstioConfigStore: store,
+		Watcher:          mesh.NewFixedWatcher(&meshconfig.MeshConfig{RootNamespace: "istio-config"}),
 	})
 	if err != nil {
 		t.Fatalf("newAuthzPolicies: %v", err)
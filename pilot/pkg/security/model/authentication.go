@@ -60,6 +60,10 @@ const (
 	// as the name defined in
 	// https://github.com/istio/proxy/blob/master/src/envoy/http/authn/http_filter_factory.cc#L30
 	AuthnFilterName = "istio_authn"
+
+	// JwtSanitizerFilterName is the name of the Lua filter removing JWTs extracted from query parameters and
+	// cookies after they are verified.
+	JwtSanitizerFilterName = "istio.jwt_sanitizer"
 )
 
 var SDSAdsConfig = &core.ConfigSource{
@@ -151,6 +151,10 @@ type Options struct {
 
 	// If meshConfig.DiscoverySelectors are specified, the DiscoveryNamespacesFilter tracks the namespaces this controller watches.
 	DiscoveryNamespacesFilter filter.DiscoveryNamespacesFilter
+
+	// NetworkDetectors detect the network of the workloads without an explicit network, from their node. If nil,
+	// the detectors are the ones of PILOT_NETWORK_DETECTORS.
+	NetworkDetectors []NetworkDetector
 }
 
 func (o Options) GetSyncInterval() time.Duration {
@@ -307,6 +311,15 @@ func NewController(kubeClient kubelib.Client, options Options) *Controller {
 		multinetwork: initMultinetwork(),
 	}
 
+	c.networkDetectors = options.NetworkDetectors
+	if c.networkDetectors == nil {
+		detectors, err := NetworkDetectorsFromNames(features.NetworkDetectors)
+		if err != nil {
+			log.Errorf("ignoring PILOT_NETWORK_DETECTORS: %v", err)
+		}
+		c.networkDetectors = detectors
+	}
+
 	if features.EnableMCSHost {
 		c.hostNamesForNamespacedName = func(name types.NamespacedName) []host.Name {
 			return []host.Name{
@@ -497,6 +510,11 @@ func (c *Controller) Network(endpointIP string, labels labels.Instance) network.
 		return nw
 	}
 
+	// 4. check the network detected for the node of the pod
+	if nw := c.networkFromDetectors(endpointIP); nw != "" {
+		return nw
+	}
+
 	return ""
 }
 
@@ -621,6 +639,11 @@ func (c *Controller) onNodeEvent(obj interface{}, event model.Event) error {
 			return nil
 		}
 	}
+	if c.updateDetectedNetwork(node, event) {
+		// refresh the network of the endpoints of the pods of the node
+		c.onDetectedNetworkChange(node.Name)
+	}
+
 	var updatedNeeded bool
 	if event == model.EventDelete {
 		updatedNeeded = true
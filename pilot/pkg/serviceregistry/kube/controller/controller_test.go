@@ -38,6 +38,7 @@ import (
 	"istio.io/api/annotation"
 	"istio.io/api/label"
 	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry/kube"
 	"istio.io/istio/pilot/pkg/serviceregistry/kube/controller/filter"
@@ -2292,6 +2293,59 @@ func TestEndpointUpdate(t *testing.T) {
 	}
 }
 
+func TestEndpointAdditionalIPs(t *testing.T) {
+	defer func(v bool) { features.EnableAdditionalWorkloadIPs = v }(features.EnableAdditionalWorkloadIPs)
+	features.EnableAdditionalWorkloadIPs = true
+	for mode, name := range EndpointModeNames {
+		mode := mode
+		t.Run(name, func(t *testing.T) {
+			controller, fx := NewFakeControllerWithOptions(FakeControllerOptions{Mode: mode})
+			go controller.Run(controller.stop)
+			cache.WaitForCacheSync(controller.stop, controller.HasSynced)
+			defer controller.Stop()
+
+			pod := generatePod("128.0.0.1", "pod1", "nsa", "", "node1", map[string]string{"app": "prod-app"},
+				map[string]string{multusNetworkStatusAnnotation: `[
+{"name": "cbr0", "interface": "eth0", "ips": ["128.0.0.1"], "default": true},
+{"name": "ns/net1", "interface": "net1", "ips": ["10.10.0.1", "invalid"]},
+{"name": "ns/net2", "interface": "net2", "ips": ["128.0.0.1", "10.20.0.1"]}]`})
+			addPods(t, controller, fx, pod)
+
+			createService(controller, "svc1", "nsa", nil,
+				[]int32{8080}, map[string]string{"app": "prod-app"}, t)
+			if ev := fx.Wait("service"); ev == nil {
+				t.Fatal("Timeout creating service")
+			}
+			createEndpoints(t, controller, "svc1", "nsa", []string{"tcp-port"}, []string{"128.0.0.1"}, nil, nil)
+			ev := fx.Wait("eds")
+			if ev == nil {
+				t.Fatalf("Timeout incremental eds")
+			}
+			var got, secondary []string
+			for _, ep := range ev.Endpoints {
+				got = append(got, ep.Address)
+				if ep.Secondary {
+					secondary = append(secondary, ep.Address)
+				}
+			}
+			want := []string{"128.0.0.1", "10.10.0.1", "10.20.0.1"}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got endpoints %v, want %v", got, want)
+			}
+			// The secondary interfaces are registered as secondary endpoints, which are not load balanced to.
+			if want := want[1:]; !reflect.DeepEqual(secondary, want) {
+				t.Fatalf("got secondary endpoints %v, want %v", secondary, want)
+			}
+
+			svc := controller.GetService(kube.ServiceHostname("svc1", "nsa", controller.opts.DomainSuffix))
+			instances := controller.InstancesByPort(svc, 8080, nil)
+			if len(instances) != len(want) {
+				t.Fatalf("got %d instances, want %d", len(instances), len(want))
+			}
+		})
+	}
+}
+
 // Validates that when Pilot sees Endpoint before the corresponding Pod, it triggers endpoint event on pod event.
 func TestEndpointUpdateBeforePodUpdate(t *testing.T) {
 	for mode, name := range EndpointModeNames {
@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestEndpointsEqual(t *testing.T) {
@@ -121,3 +122,49 @@ func TestEndpointsEqual(t *testing.T) {
 		})
 	}
 }
+
+func TestIsDrainingPod(t *testing.T) {
+	now := metaV1.Now()
+	readinessGates := []coreV1.PodReadinessGate{{ConditionType: "example.com/gate"}}
+	cases := []struct {
+		name        string
+		pod         *coreV1.Pod
+		terminating bool
+		want        bool
+	}{
+		{"no pod", nil, false, false},
+		{"terminating endpoint", nil, true, true},
+		{"not ready pod", &coreV1.Pod{}, false, false},
+		{"deleted pod", &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{DeletionTimestamp: &now}}, false, true},
+		{
+			"failing readiness gate",
+			&coreV1.Pod{
+				Spec: coreV1.PodSpec{ReadinessGates: readinessGates},
+				Status: coreV1.PodStatus{Conditions: []coreV1.PodCondition{
+					{Type: coreV1.ContainersReady, Status: coreV1.ConditionTrue},
+					{Type: coreV1.PodReady, Status: coreV1.ConditionFalse},
+				}},
+			},
+			false,
+			true,
+		},
+		{
+			"containers not ready",
+			&coreV1.Pod{
+				Spec: coreV1.PodSpec{ReadinessGates: readinessGates},
+				Status: coreV1.PodStatus{Conditions: []coreV1.PodCondition{
+					{Type: coreV1.ContainersReady, Status: coreV1.ConditionFalse},
+				}},
+			},
+			false,
+			false,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDrainingPod(tt.pod, tt.terminating); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -140,6 +140,39 @@ func getPod(c *Controller, ip string, ep *metav1.ObjectMeta, targetRef *v1.Objec
 	return pod, expectPod
 }
 
+// drainingEndpoints returns true if endpoints of the service which are terminating or failing readiness gates
+// should be sent as draining.
+func drainingEndpoints(svc *model.Service) bool {
+	if svc == nil {
+		return features.EnableDrainingEndpoints
+	}
+	return svc.Attributes.DrainingEndpoints
+}
+
+// isDrainingPod returns true if a not ready endpoint should be considered draining rather than unhealthy.
+// This is the case if the endpoint is terminating, or if all of the pod's containers are ready but one of
+// its readiness gates is failing.
+func isDrainingPod(pod *v1.Pod, terminating bool) bool {
+	if terminating {
+		return true
+	}
+	if pod == nil {
+		return false
+	}
+	if pod.DeletionTimestamp != nil {
+		return true
+	}
+	if len(pod.Spec.ReadinessGates) == 0 {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.ContainersReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
 func (c *Controller) registerEndpointResync(ep *metav1.ObjectMeta, ip string, host host.Name) {
 	// This means, the endpoint event has arrived before pod event.
 	// This might happen because PodCache is eventually consistent.
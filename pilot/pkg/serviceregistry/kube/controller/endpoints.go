@@ -171,12 +171,14 @@ func (e *endpointsController) buildIstioEndpoints(endpoint interface{}, host hos
 	var endpoints []*model.IstioEndpoint
 	ep := endpoint.(*v1.Endpoints)
 
-	discoverabilityPolicy := e.c.exports.EndpointDiscoverabilityPolicy(e.c.GetService(host))
+	svc := e.c.GetService(host)
+	discoverabilityPolicy := e.c.exports.EndpointDiscoverabilityPolicy(svc)
+	draining := drainingEndpoints(svc)
 
 	for _, ss := range ep.Subsets {
-		endpoints = append(endpoints, e.buildIstioEndpointFromAddress(ep, ss, ss.Addresses, host, discoverabilityPolicy, model.Healthy)...)
-		if features.SendUnhealthyEndpoints {
-			endpoints = append(endpoints, e.buildIstioEndpointFromAddress(ep, ss, ss.NotReadyAddresses, host, discoverabilityPolicy, model.UnHealthy)...)
+		endpoints = append(endpoints, e.buildIstioEndpointFromAddress(ep, ss, ss.Addresses, host, discoverabilityPolicy, model.Healthy, draining)...)
+		if features.SendUnhealthyEndpoints || draining {
+			endpoints = append(endpoints, e.buildIstioEndpointFromAddress(ep, ss, ss.NotReadyAddresses, host, discoverabilityPolicy, model.UnHealthy, draining)...)
 		}
 	}
 	return endpoints
@@ -206,13 +208,16 @@ func (e *endpointsController) buildServiceInstances(ep *v1.Endpoints, ss v1.Endp
 		for _, port := range ss.Ports {
 			if port.Name == "" || // 'name optional if single port is defined'
 				svcPort.Name == port.Name {
-				istioEndpoint := builder.buildIstioEndpoint(ea.IP, port.Port, svcPort.Name, discoverabilityPolicy)
-				istioEndpoint.HealthStatus = health
-				out = append(out, &model.ServiceInstance{
-					Endpoint:    istioEndpoint,
-					ServicePort: svcPort,
-					Service:     svc,
-				})
+				for i, addr := range endpointAddresses(pod, ea.IP) {
+					istioEndpoint := builder.buildIstioEndpoint(addr, port.Port, svcPort.Name, discoverabilityPolicy)
+					istioEndpoint.Secondary = i > 0
+					istioEndpoint.HealthStatus = health
+					out = append(out, &model.ServiceInstance{
+						Endpoint:    istioEndpoint,
+						ServicePort: svcPort,
+						Service:     svc,
+					})
+				}
 			}
 		}
 	}
@@ -220,19 +225,30 @@ func (e *endpointsController) buildServiceInstances(ep *v1.Endpoints, ss v1.Endp
 }
 
 func (e *endpointsController) buildIstioEndpointFromAddress(ep *v1.Endpoints, ss v1.EndpointSubset, endpoints []v1.EndpointAddress,
-	host host.Name, discoverabilityPolicy model.EndpointDiscoverabilityPolicy, health model.HealthStatus) []*model.IstioEndpoint {
+	host host.Name, discoverabilityPolicy model.EndpointDiscoverabilityPolicy, health model.HealthStatus, draining bool) []*model.IstioEndpoint {
 	var istioEndpoints []*model.IstioEndpoint
 	for _, ea := range endpoints {
 		pod, expectedPod := getPod(e.c, ea.IP, &metav1.ObjectMeta{Name: ep.Name, Namespace: ep.Namespace}, ea.TargetRef, host)
 		if pod == nil && expectedPod {
 			continue
 		}
+		epHealth := health
+		if health == model.UnHealthy {
+			if draining && isDrainingPod(pod, false) {
+				epHealth = model.Draining
+			} else if !features.SendUnhealthyEndpoints {
+				continue
+			}
+		}
 		builder := NewEndpointBuilder(e.c, pod)
 		// EDS and ServiceEntry use name for service port - ADS will need to map to numbers.
 		for _, port := range ss.Ports {
-			istioEndpoint := builder.buildIstioEndpoint(ea.IP, port.Port, port.Name, discoverabilityPolicy)
-			istioEndpoint.HealthStatus = health
-			istioEndpoints = append(istioEndpoints, istioEndpoint)
+			for i, addr := range endpointAddresses(pod, ea.IP) {
+				istioEndpoint := builder.buildIstioEndpoint(addr, port.Port, port.Name, discoverabilityPolicy)
+				istioEndpoint.Secondary = i > 0
+				istioEndpoint.HealthStatus = epHealth
+				istioEndpoints = append(istioEndpoints, istioEndpoint)
+			}
 		}
 	}
 	return istioEndpoints
@@ -225,21 +225,34 @@ func (esc *endpointSliceController) updateEndpointCacheForSlice(hostName host.Na
 		// TODO(https://github.com/istio/istio/issues/34995) support FQDN endpointslice
 		return
 	}
-	discoverabilityPolicy := esc.c.exports.EndpointDiscoverabilityPolicy(esc.c.GetService(hostName))
+	svc := esc.c.GetService(hostName)
+	discoverabilityPolicy := esc.c.exports.EndpointDiscoverabilityPolicy(svc)
+	draining := drainingEndpoints(svc)
 
 	for _, e := range slice.Endpoints() {
-		if !features.SendUnhealthyEndpoints {
+		if !features.SendUnhealthyEndpoints && !draining {
 			if e.Conditions.Ready != nil && !*e.Conditions.Ready {
 				// Ignore not ready endpoints
 				continue
 			}
 		}
 		ready := e.Conditions.Ready == nil || *e.Conditions.Ready
+		terminating := e.Conditions.Terminating != nil && *e.Conditions.Terminating
 		for _, a := range e.Addresses {
 			pod, expectedPod := getPod(esc.c, a, &metav1.ObjectMeta{Name: slice.Name, Namespace: slice.Namespace}, e.TargetRef, hostName)
 			if pod == nil && expectedPod {
 				continue
 			}
+			health := model.Healthy
+			if !ready {
+				if draining && isDrainingPod(pod, terminating) {
+					health = model.Draining
+				} else if !features.SendUnhealthyEndpoints {
+					continue
+				} else {
+					health = model.UnHealthy
+				}
+			}
 			builder := esc.newEndpointBuilder(pod)
 			// EDS and ServiceEntry use name for service port - ADS will need to map to numbers.
 			for _, port := range slice.Ports() {
@@ -252,13 +265,12 @@ func (esc *endpointSliceController) updateEndpointCacheForSlice(hostName host.Na
 					portName = *port.Name
 				}
 
-				istioEndpoint := builder.buildIstioEndpoint(a, portNum, portName, discoverabilityPolicy)
-				if ready {
-					istioEndpoint.HealthStatus = model.Healthy
-				} else {
-					istioEndpoint.HealthStatus = model.UnHealthy
+				for i, addr := range endpointAddresses(pod, a) {
+					istioEndpoint := builder.buildIstioEndpoint(addr, portNum, portName, discoverabilityPolicy)
+					istioEndpoint.Secondary = i > 0
+					istioEndpoint.HealthStatus = health
+					endpoints = append(endpoints, istioEndpoint)
 				}
-				endpoints = append(endpoints, istioEndpoint)
 			}
 		}
 	}
@@ -342,12 +354,15 @@ func (esc *endpointSliceController) InstancesByPort(c *Controller, svc *model.Se
 
 					if port.Name == nil ||
 						svcPort.Name == *port.Name {
-						istioEndpoint := builder.buildIstioEndpoint(a, portNum, svcPort.Name, discoverabilityPolicy)
-						out = append(out, &model.ServiceInstance{
-							Endpoint:    istioEndpoint,
-							ServicePort: svcPort,
-							Service:     svc,
-						})
+						for i, addr := range endpointAddresses(pod, a) {
+							istioEndpoint := builder.buildIstioEndpoint(addr, portNum, svcPort.Name, discoverabilityPolicy)
+							istioEndpoint.Secondary = i > 0
+							out = append(out, &model.ServiceInstance{
+								Endpoint:    istioEndpoint,
+								ServicePort: svcPort,
+								Service:     svc,
+							})
+						}
 					}
 				}
 			}
@@ -521,7 +536,7 @@ func (esw *endpointSliceWrapper) Endpoints() []v1.Endpoint {
 			Conditions: v1.EndpointConditions{
 				Ready:       ep.Conditions.Ready,
 				Serving:     ep.Conditions.Serving,
-				Terminating: ep.Conditions.Serving,
+				Terminating: ep.Conditions.Terminating,
 			},
 			Hostname:           ep.Hostname,
 			TargetRef:          ep.TargetRef,
@@ -167,6 +167,7 @@ type FakeControllerOptions struct {
 	DomainSuffix              string
 	XDSUpdater                model.XDSUpdater
 	DiscoveryNamespacesFilter filter.DiscoveryNamespacesFilter
+	NetworkDetectors          []NetworkDetector
 	Stop                      chan struct{}
 }
 
@@ -204,6 +205,7 @@ func NewFakeControllerWithOptions(opts FakeControllerOptions) (*FakeController,
 		SyncInterval:              time.Microsecond,
 		DiscoveryNamespacesFilter: opts.DiscoveryNamespacesFilter,
 		MeshServiceController:     meshServiceController,
+		NetworkDetectors:          opts.NetworkDetectors,
 	}
 	c := NewController(opts.Client, options)
 	meshServiceController.AddRegistry(c)
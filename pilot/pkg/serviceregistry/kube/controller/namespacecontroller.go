@@ -16,6 +16,7 @@ package controller
 
 import (
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
@@ -23,6 +24,7 @@ import (
 	listerv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/keycertbundle"
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/controllers"
@@ -47,6 +49,9 @@ type NamespaceController struct {
 	configMapInformer  cache.SharedInformer
 	namespaceLister    listerv1.NamespaceLister
 	configmapLister    listerv1.ConfigMapLister
+
+	// rules control which namespaces receive the ConfigMap, and with which additional trust anchors.
+	rules []rootCertRule
 }
 
 // NewNamespaceController returns a pointer to a newly constructed NamespaceController instance.
@@ -57,6 +62,13 @@ func NewNamespaceController(kubeClient kube.Client, caBundleWatcher *keycertbund
 	}
 	c.queue = controllers.NewQueue("namespace controller", controllers.WithReconciler(c.insertDataForNamespace))
 
+	rules, err := parseRootCertRules(features.RootCertPropagationRules)
+	if err != nil {
+		// Fall back to propagating the root cert to all namespaces, rather than removing trust anchors.
+		log.Errorf("ignoring PILOT_ROOT_CERT_PROPAGATION_RULES: %v", err)
+	}
+	c.rules = rules
+
 	c.configMapInformer = kubeClient.KubeInformer().Core().V1().ConfigMaps().Informer()
 	c.configmapLister = kubeClient.KubeInformer().Core().V1().ConfigMaps().Lister()
 	c.namespacesInformer = kubeClient.KubeInformer().Core().V1().Namespaces().Informer()
@@ -116,12 +128,27 @@ func (nc *NamespaceController) insertDataForNamespace(o types.NamespacedName) er
 		// For Namespace object, it will not have o.Namespace field set
 		ns = o.Name
 	}
+	caBundle := nc.caBundleWatcher.GetCABundle()
+	if len(nc.rules) > 0 {
+		namespace, err := nc.namespaceLister.Get(ns)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		rule := matchRootCertRule(nc.rules, namespace)
+		if rule != nil && rule.Skip {
+			return nil
+		}
+		caBundle = rule.rootCertBundle(caBundle)
+	}
 	meta := metav1.ObjectMeta{
 		Name:      CACertNamespaceConfigMap,
 		Namespace: ns,
 		Labels:    configMapLabel,
 	}
-	return k8s.InsertDataToConfigMap(nc.client, nc.configmapLister, meta, nc.caBundleWatcher.GetCABundle())
+	return k8s.InsertDataToConfigMap(nc.client, nc.configmapLister, meta, caBundle)
 }
 
 // On namespace change, update the config map.
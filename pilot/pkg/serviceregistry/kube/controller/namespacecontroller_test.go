@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strconv"
 	"testing"
 	"time"
 
@@ -26,6 +27,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	listerv1 "k8s.io/client-go/listers/core/v1"
 
+	"istio.io/api/label"
 	"istio.io/istio/pilot/pkg/keycertbundle"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/kube"
@@ -77,6 +79,66 @@ func TestNamespaceController(t *testing.T) {
 	}
 }
 
+func TestNamespaceControllerRootCertRules(t *testing.T) {
+	anchor := "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+	rules, err := parseRootCertRules(`[
+		{"namespaceSelector": {"matchLabels": {"tenant": "none"}}, "skip": true},
+		{"revision": "canary", "trustAnchors": [` + strconv.Quote(anchor) + `]}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := kube.NewFakeClient()
+	watcher := keycertbundle.NewWatcher()
+	caBundle := []byte("caBundle\n")
+	watcher.SetAndNotify(nil, nil, caBundle)
+	nc := NewNamespaceController(client, watcher)
+	nc.rules = rules
+	stop := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+	})
+	client.RunAndWait(stop)
+	go nc.Run(stop)
+	retry.UntilOrFail(t, nc.queue.HasSynced)
+
+	rootCert := map[string]string{constants.CACertNamespaceConfigMapDataName: string(caBundle)}
+	withAnchor := map[string]string{constants.CACertNamespaceConfigMapDataName: string(caBundle) + anchor}
+
+	createNamespace(t, client, "plain", nil)
+	expectConfigMap(t, nc.configmapLister, CACertNamespaceConfigMap, "plain", rootCert)
+
+	createNamespace(t, client, "canary", map[string]string{label.IoIstioRev.Name: "canary"})
+	expectConfigMap(t, nc.configmapLister, CACertNamespaceConfigMap, "canary", withAnchor)
+
+	createNamespace(t, client, "skipped", map[string]string{"tenant": "none"})
+	expectConfigMapNotExist(t, nc.configmapLister, "skipped")
+
+	// Moving a namespace to the canary revision adds the trust anchor. The fake client does not set the
+	// resource version, which is needed for the update to be handled.
+	if _, err := client.CoreV1().Namespaces().Update(context.TODO(), &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "plain", Labels: map[string]string{label.IoIstioRev.Name: "canary"}, ResourceVersion: "2"},
+	}, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectConfigMap(t, nc.configmapLister, CACertNamespaceConfigMap, "plain", withAnchor)
+}
+
+func TestParseRootCertRules(t *testing.T) {
+	for _, invalid := range []string{
+		`{}`,
+		`[{"namespaceSelector": {"matchExpressions": [{"key": "a", "operator": "Bad"}]}}]`,
+		`[{"trustAnchors": ["not a certificate"]}]`,
+	} {
+		if _, err := parseRootCertRules(invalid); err == nil {
+			t.Errorf("expected error for %s", invalid)
+		}
+	}
+	if rules, err := parseRootCertRules(""); err != nil || rules != nil {
+		t.Errorf("expected no rules, got %v %v", rules, err)
+	}
+}
+
 func deleteConfigMap(t *testing.T, client kubernetes.Interface, ns string) {
 	t.Helper()
 	_, err := client.CoreV1().ConfigMaps(ns).Get(context.TODO(), CACertNamespaceConfigMap, metav1.GetOptions{})
@@ -16,12 +16,16 @@ package controller
 
 import (
 	"net"
+	"sort"
 	"strconv"
 
 	"github.com/yl2chen/cidranger"
+	v1 "k8s.io/api/core/v1"
 
 	"istio.io/api/label"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/network"
@@ -41,6 +45,11 @@ type multinetwork struct {
 	registryServiceNameGateways map[host.Name][]model.NetworkGateway
 	// gateways for each service
 	networkGatewaysBySvc map[host.Name]model.NetworkGatewaySet
+
+	// networkDetectors detect the network of the workloads of the nodes, in order of precedence.
+	networkDetectors []NetworkDetector
+	// detectedNetworks is the network detected for each node, by node name.
+	detectedNetworks map[string]model.DetectedNetwork
 	// implements NetworkGatewaysWatcher; we need to call c.NotifyGatewayHandlers when our gateways change
 	model.NetworkGatewaysHandler
 }
@@ -53,6 +62,7 @@ func initMultinetwork() multinetwork {
 		networkForRegistry:          "",
 		registryServiceNameGateways: make(map[host.Name][]model.NetworkGateway),
 		networkGatewaysBySvc:        make(map[host.Name]model.NetworkGatewaySet),
+		detectedNetworks:            make(map[string]model.DetectedNetwork),
 	}
 }
 
@@ -247,17 +257,25 @@ func (c *Controller) getGatewayDetails(svc *model.Service) []model.NetworkGatewa
 	if nw := svc.Attributes.Labels[label.TopologyNetwork.Name]; nw != "" {
 		if gwPortStr := svc.Attributes.Labels[IstioGatewayPortLabel]; gwPortStr != "" {
 			if gwPort, err := strconv.Atoi(gwPortStr); err == nil {
-				return []model.NetworkGateway{{Port: uint32(gwPort), Network: network.ID(nw)}}
+				return []model.NetworkGateway{{Port: uint32(gwPort), Network: network.ID(nw), Weight: svc.Attributes.NetworkGatewayWeight,
+					Drained: svc.Attributes.NetworkGatewayDrained}}
 			}
 			log.Warnf("could not parse %q for %s on %s/%s; defaulting to %d",
 				gwPortStr, IstioGatewayPortLabel, svc.Attributes.Namespace, svc.Attributes.Name, DefaultNetworkGatewayPort)
 		}
-		return []model.NetworkGateway{{Port: DefaultNetworkGatewayPort, Network: network.ID(nw)}}
+		return []model.NetworkGateway{{
+			Port: DefaultNetworkGatewayPort, Network: network.ID(nw), Weight: svc.Attributes.NetworkGatewayWeight,
+			Drained: svc.Attributes.NetworkGatewayDrained,
+		}}
 	}
 
 	// meshNetworks registryServiceName+fromRegistry
 	if gws, ok := c.registryServiceNameGateways[svc.Hostname]; ok {
 		out := append(make([]model.NetworkGateway, 0, len(gws)), gws...)
+		for i := range out {
+			out[i].Weight = svc.Attributes.NetworkGatewayWeight
+			out[i].Drained = svc.Attributes.NetworkGatewayDrained
+		}
 		return out
 	}
 
@@ -306,3 +324,93 @@ func (c *Controller) getNodePortGatewayServices() []*model.Service {
 
 	return out
 }
+
+// updateDetectedNetwork detects the network of the workloads of the node, and returns true if it changed.
+func (c *Controller) updateDetectedNetwork(node *v1.Node, event model.Event) bool {
+	if len(c.networkDetectors) == 0 {
+		return false
+	}
+	var nw network.ID
+	var detector string
+	if event != model.EventDelete {
+		nw, detector = detectNetwork(c.networkDetectors, node)
+	}
+	c.Lock()
+	defer c.Unlock()
+	prev := c.detectedNetworks[node.Name]
+	if nw == "" {
+		delete(c.detectedNetworks, node.Name)
+	} else {
+		c.detectedNetworks[node.Name] = model.DetectedNetwork{Node: node.Name, Network: nw, Detector: detector}
+	}
+	if prev.Network != nw {
+		log.Infof("detected network %q for the workloads of node %s", nw, node.Name)
+		return true
+	}
+	return false
+}
+
+// onDetectedNetworkChange refreshes the network of the endpoints of the pods of the node, once the network detected
+// for it changed. Only the services selecting these pods are updated, and none if the network of the system
+// namespace takes precedence over the detected networks.
+func (c *Controller) onDetectedNetworkChange(nodeName string) {
+	if c.networkFromSystemNamespace() != "" {
+		return
+	}
+	shard := model.ShardKeyFromRegistry(c)
+	updated := sets.NewSet()
+	for _, obj := range c.pods.informer.GetIndexer().List() {
+		pod := obj.(*v1.Pod)
+		if pod.Spec.NodeName != nodeName || pod.Status.PodIP == "" {
+			continue
+		}
+		// the network of the proxy of the pod changed as well
+		c.pods.proxyUpdates(pod.Status.PodIP)
+		services, err := getPodServices(c.serviceLister, pod)
+		if err != nil {
+			log.Errorf("failed to get the services of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+			continue
+		}
+		for _, k8sSvc := range services {
+			hostname := kube.ServiceHostname(k8sSvc.Name, k8sSvc.Namespace, c.opts.DomainSuffix)
+			if updated.Contains(string(hostname)) {
+				continue
+			}
+			updated.Insert(string(hostname))
+			svc := c.GetService(hostname)
+			if svc == nil {
+				continue
+			}
+			endpoints := c.buildEndpointsForService(svc, true)
+			c.opts.XDSUpdater.EDSUpdate(shard, string(hostname), k8sSvc.Namespace, endpoints)
+		}
+	}
+}
+
+// networkFromDetectors returns the network detected for the node of the pod with the IP.
+func (c *Controller) networkFromDetectors(endpointIP string) network.ID {
+	if len(c.networkDetectors) == 0 {
+		return ""
+	}
+	pod := c.pods.getPodByIP(endpointIP)
+	if pod == nil || pod.Spec.NodeName == "" {
+		return ""
+	}
+	c.RLock()
+	defer c.RUnlock()
+	return c.detectedNetworks[pod.Spec.NodeName].Network
+}
+
+// DetectedNetworks returns the network detected for the workloads of each node, sorted by node name.
+func (c *Controller) DetectedNetworks() []model.DetectedNetwork {
+	c.RLock()
+	defer c.RUnlock()
+	out := make([]model.DetectedNetwork, 0, len(c.detectedNetworks))
+	for _, d := range c.detectedNetworks {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Node < out[j].Node
+	})
+	return out
+}
@@ -0,0 +1,112 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"istio.io/api/label"
+	"istio.io/istio/pkg/network"
+)
+
+// NetworkDetector detects the network of the workloads of a node, from the topology of the cluster. Detected
+// networks only apply to workloads without an explicit network.
+type NetworkDetector interface {
+	// Name identifies the detector in the report of the detected networks.
+	Name() string
+	// Detect returns the network of the workloads of the node, or "" if the detector cannot tell.
+	Detect(node *v1.Node) network.ID
+}
+
+// NodeLabelNetworkDetector reads the network from a label of the node.
+type NodeLabelNetworkDetector struct {
+	// Label is the label holding the network, topology.istio.io/network if empty.
+	Label string
+}
+
+func (d NodeLabelNetworkDetector) Name() string {
+	return "node-label"
+}
+
+func (d NodeLabelNetworkDetector) Detect(node *v1.Node) network.ID {
+	key := d.Label
+	if key == "" {
+		key = label.TopologyNetwork.Name
+	}
+	return network.ID(node.Labels[key])
+}
+
+// CloudNetworkDetector derives the network from the cloud provider metadata of the node, approximating the scope
+// of its VPC: the project of GCP nodes, the region of AWS nodes and the subscription of Azure nodes.
+type CloudNetworkDetector struct{}
+
+func (d CloudNetworkDetector) Name() string {
+	return "cloud"
+}
+
+func (d CloudNetworkDetector) Detect(node *v1.Node) network.ID {
+	providerID := strings.SplitN(node.Spec.ProviderID, "://", 2)
+	if len(providerID) != 2 {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(providerID[1], "/"), "/")
+	switch providerID[0] {
+	case "gce":
+		// gce://<project>/<zone>/<instance>
+		if len(parts) == 3 {
+			return network.ID("gcp-" + parts[0])
+		}
+	case "aws":
+		// aws:///<zone>/<instance>
+		if region := getLabelValue(node, NodeRegionLabel, NodeRegionLabelGA); region != "" {
+			return network.ID("aws-" + region)
+		}
+	case "azure":
+		// azure:///subscriptions/<subscription>/resourceGroups/<group>/...
+		if len(parts) > 1 && strings.EqualFold(parts[0], "subscriptions") {
+			return network.ID("azure-" + strings.ToLower(parts[1]))
+		}
+	}
+	return ""
+}
+
+// NetworkDetectorsFromNames returns the detectors with the given names, in order of precedence.
+func NetworkDetectorsFromNames(names []string) ([]NetworkDetector, error) {
+	var out []NetworkDetector
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case "node-label":
+			out = append(out, NodeLabelNetworkDetector{})
+		case "cloud":
+			out = append(out, CloudNetworkDetector{})
+		default:
+			return nil, fmt.Errorf("unknown network detector %q", name)
+		}
+	}
+	return out, nil
+}
+
+// detectNetwork returns the network of the workloads of the node detected by the first detector detecting one.
+func detectNetwork(detectors []NetworkDetector, node *v1.Node) (network.ID, string) {
+	for _, d := range detectors {
+		if nw := d.Detect(node); nw != "" {
+			return nw, d.Name()
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,170 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+
+	"istio.io/api/label"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+	"istio.io/istio/pkg/network"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestCloudNetworkDetector(t *testing.T) {
+	cases := []struct {
+		name       string
+		providerID string
+		labels     map[string]string
+		want       network.ID
+	}{
+		{"gcp", "gce://my-project/us-central1-a/node-1", nil, "gcp-my-project"},
+		{"aws", "aws:///us-east-1a/i-0123", map[string]string{NodeRegionLabelGA: "us-east-1"}, "aws-us-east-1"},
+		{"aws without region", "aws:///us-east-1a/i-0123", nil, ""},
+		{
+			"azure",
+			"azure:///subscriptions/ABC-123/resourceGroups/mc_rg/providers/Microsoft.Compute/virtualMachines/node-1",
+			nil,
+			"azure-abc-123",
+		},
+		{"unknown provider", "kind://docker/kind/node-1", nil, ""},
+		{"no provider", "", nil, ""},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			node := generateNode("node", tt.labels)
+			node.Spec.ProviderID = tt.providerID
+			if got := (CloudNetworkDetector{}).Detect(node); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetworkDetectorsFromNames(t *testing.T) {
+	detectors, err := NetworkDetectorsFromNames([]string{"node-label", " cloud"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(detectors, []NetworkDetector{NodeLabelNetworkDetector{}, CloudNetworkDetector{}}) {
+		t.Fatalf("unexpected detectors %v", detectors)
+	}
+	if _, err := NetworkDetectorsFromNames([]string{"vpc"}); err == nil {
+		t.Fatal("expected an error for an unknown detector")
+	}
+}
+
+func TestNetworkDetection(t *testing.T) {
+	c, fx := NewFakeControllerWithOptions(FakeControllerOptions{
+		NetworkDetectors: []NetworkDetector{NodeLabelNetworkDetector{}, CloudNetworkDetector{}},
+	})
+	go c.Run(c.stop)
+	cache.WaitForCacheSync(c.stop, c.HasSynced)
+	defer c.Stop()
+	initTestEnv(t, c.client, fx)
+
+	labeled := generateNode("labeled", map[string]string{label.TopologyNetwork.Name: "nw-label"})
+	labeled.Spec.ProviderID = "gce://my-project/us-central1-a/labeled"
+	cloud := generateNode("cloud", nil)
+	cloud.Spec.ProviderID = "gce://my-project/us-central1-a/cloud"
+	addNodes(t, c, labeled, cloud, generateNode("unknown", nil))
+	addPods(t, c, fx,
+		generatePod("128.0.0.1", "a", "nsa", "", "labeled", map[string]string{"app": "a"}, nil),
+		generatePod("128.0.0.2", "b", "nsa", "", "cloud", nil, nil),
+		generatePod("128.0.0.3", "c", "nsa", "", "unknown", nil, nil),
+		generatePod("128.0.0.4", "d", "nsa", "", "cloud", map[string]string{label.TopologyNetwork.Name: "nw-pod"}, nil))
+
+	expectNetwork := func(ip string, want network.ID) {
+		t.Helper()
+		retry.UntilSuccessOrFail(t, func() error {
+			pod := c.pods.getPodByIP(ip)
+			if got := c.Network(ip, pod.Labels); got != want {
+				return fmt.Errorf("got network %q for %s, want %q", got, ip, want)
+			}
+			return nil
+		})
+	}
+	// The detector listed first takes precedence, and the label of the pod over detection.
+	expectNetwork("128.0.0.1", "nw-label")
+	expectNetwork("128.0.0.2", "gcp-my-project")
+	expectNetwork("128.0.0.3", "")
+	expectNetwork("128.0.0.4", "nw-pod")
+
+	want := []model.DetectedNetwork{
+		{Node: "cloud", Network: "gcp-my-project", Detector: "cloud"},
+		{Node: "labeled", Network: "nw-label", Detector: "node-label"},
+	}
+	if got := c.DetectedNetworks(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got detected networks %v, want %v", got, want)
+	}
+
+	// An explicit network takes precedence over detection.
+	c.Lock()
+	c.network = "nw-system"
+	c.Unlock()
+	expectNetwork("128.0.0.2", "nw-system")
+	c.Lock()
+	c.network = ""
+	c.Unlock()
+
+	createService(c, "svc-a", "nsa", nil, []int32{8080}, map[string]string{"app": "a"}, t)
+	createEndpoints(t, c, "svc-a", "nsa", []string{"tcp-port"}, []string{"128.0.0.1"}, nil, nil)
+	hostname := string(kube.ServiceHostname("svc-a", "nsa", c.opts.DomainSuffix))
+	expectEndpointsNetwork := func(want network.ID) {
+		t.Helper()
+		for {
+			ev := fx.WaitOrFail(t, "eds")
+			if ev.ID != hostname {
+				continue
+			}
+			if len(ev.Endpoints) != 1 || ev.Endpoints[0].Network != want {
+				t.Fatalf("expected the endpoint of %s to be updated to network %q, got %v", hostname, want, ev.Endpoints)
+			}
+			return
+		}
+	}
+
+	// Relabeling the node changes the detected network, and the network of the endpoints of its pods.
+	fx.Clear()
+	labeled.Labels[label.TopologyNetwork.Name] = "nw-relabeled"
+	addNodes(t, c, labeled)
+	expectNetwork("128.0.0.1", "nw-relabeled")
+	expectEndpointsNetwork("nw-relabeled")
+
+	// The endpoints are unchanged by the detection when the network of the system namespace is set.
+	c.Lock()
+	c.network = "nw-system"
+	c.Unlock()
+	fx.Clear()
+	labeled.Labels[label.TopologyNetwork.Name] = "nw-ignored"
+	addNodes(t, c, labeled)
+	if ev := fx.WaitForDuration("eds", 200*time.Millisecond); ev != nil {
+		t.Fatalf("expected no endpoint update, got %v", ev)
+	}
+	c.Lock()
+	c.network = ""
+	c.Unlock()
+	labeled.Labels[label.TopologyNetwork.Name] = "nw-relabeled"
+	addNodes(t, c, labeled)
+	delete(labeled.Labels, label.TopologyNetwork.Name)
+	addNodes(t, c, labeled)
+	expectNetwork("128.0.0.1", "gcp-my-project")
+}
@@ -175,6 +175,8 @@ func (pc *PodCache) notifyWorkloadHandlers(pod *v1.Pod, ev model.Event) {
 		Kind:      model.PodKind,
 		Endpoint:  ep,
 		PortMap:   getPortMap(pod),
+		// The secondary interfaces are registered as secondary endpoints of the ServiceEntries selecting the pod.
+		AdditionalIPs: podAdditionalIPs(pod, pod.Status.PodIP),
 	}
 	pc.c.handlers.NotifyWorkloadHandlers(workloadInstance, ev)
 }
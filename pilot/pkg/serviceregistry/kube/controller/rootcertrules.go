@@ -0,0 +1,119 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/label"
+)
+
+// RootCertRule controls the propagation of the istio-ca-root-cert ConfigMap to the namespaces it selects.
+// Rules are evaluated in order and the first rule selecting a namespace applies. Namespaces not selected by any
+// rule receive the ConfigMap with the root cert only.
+type RootCertRule struct {
+	// NamespaceSelector selects the namespaces by label. An empty selector selects all the namespaces.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// Revision selects the namespaces of a revision: those labeled istio.io/rev with the revision, or with
+	// istio-injection=enabled for the "default" revision.
+	Revision string `json:"revision,omitempty"`
+	// Skip excludes the selected namespaces from the propagation. Their existing ConfigMap is left untouched.
+	Skip bool `json:"skip,omitempty"`
+	// TrustAnchors are additional PEM encoded root certificates, appended to the root cert in the ConfigMap of
+	// the selected namespaces.
+	TrustAnchors []string `json:"trustAnchors,omitempty"`
+}
+
+// rootCertRule is a RootCertRule with its selector compiled.
+type rootCertRule struct {
+	RootCertRule
+	selector klabels.Selector
+}
+
+// parseRootCertRules parses the JSON list of rules of PILOT_ROOT_CERT_PROPAGATION_RULES.
+func parseRootCertRules(s string) ([]rootCertRule, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var rules []RootCertRule
+	if err := json.Unmarshal([]byte(s), &rules); err != nil {
+		return nil, fmt.Errorf("invalid root cert propagation rules: %v", err)
+	}
+	out := make([]rootCertRule, 0, len(rules))
+	for i, r := range rules {
+		selector := klabels.Everything()
+		if r.NamespaceSelector != nil {
+			var err error
+			if selector, err = metav1.LabelSelectorAsSelector(r.NamespaceSelector); err != nil {
+				return nil, fmt.Errorf("invalid namespace selector of root cert propagation rule %d: %v", i, err)
+			}
+		}
+		for _, anchor := range r.TrustAnchors {
+			if block, _ := pem.Decode([]byte(anchor)); block == nil || block.Type != "CERTIFICATE" {
+				return nil, fmt.Errorf("invalid trust anchor of root cert propagation rule %d: not a PEM certificate", i)
+			}
+		}
+		out = append(out, rootCertRule{RootCertRule: r, selector: selector})
+	}
+	return out, nil
+}
+
+// namespaceRevision returns the revision a namespace is labeled with, if any.
+func namespaceRevision(ns *v1.Namespace) string {
+	if rev, f := ns.Labels[label.IoIstioRev.Name]; f {
+		return rev
+	}
+	if ns.Labels["istio-injection"] == "enabled" {
+		return "default"
+	}
+	return ""
+}
+
+// matchRootCertRule returns the first rule selecting the namespace, or nil if there is none.
+func matchRootCertRule(rules []rootCertRule, ns *v1.Namespace) *rootCertRule {
+	for i := range rules {
+		r := &rules[i]
+		if r.Revision != "" && r.Revision != namespaceRevision(ns) {
+			continue
+		}
+		if !r.selector.Matches(klabels.Set(ns.Labels)) {
+			continue
+		}
+		return r
+	}
+	return nil
+}
+
+// rootCertBundle returns the root cert with the trust anchors of the rule appended.
+func (r *rootCertRule) rootCertBundle(caBundle []byte) []byte {
+	if r == nil || len(r.TrustAnchors) == 0 {
+		return caBundle
+	}
+	out := append([]byte{}, caBundle...)
+	for _, anchor := range r.TrustAnchors {
+		if len(out) > 0 && out[len(out)-1] != '\n' {
+			out = append(out, '\n')
+		}
+		out = append(out, anchor...)
+	}
+	return out
+}
@@ -45,6 +45,49 @@ func hasProxyIP(addresses []v1.EndpointAddress, proxyIP string) bool {
 	return false
 }
 
+// multusNetworkStatusAnnotation is the annotation in which Multus records the interfaces it attached to a pod.
+const multusNetworkStatusAnnotation = "k8s.v1.cni.cncf.io/network-status"
+
+// multusNetworkStatus is an interface recorded in the multusNetworkStatusAnnotation.
+type multusNetworkStatus struct {
+	Name    string   `json:"name"`
+	IPs     []string `json:"ips"`
+	Default bool     `json:"default"`
+}
+
+// podAdditionalIPs returns the IPs of the secondary interfaces attached to the pod by Multus, other than its
+// primary address. Only the network status written by Multus is trusted, as it reflects the interfaces actually
+// attached to the pod.
+func podAdditionalIPs(pod *v1.Pod, primary string) []string {
+	value, f := pod.Annotations[multusNetworkStatusAnnotation]
+	if !f {
+		return nil
+	}
+	var networks []multusNetworkStatus
+	if err := json.Unmarshal([]byte(value), &networks); err != nil {
+		log.Debugf("failed to parse the network status of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		return nil
+	}
+	var ips []string
+	for _, network := range networks {
+		if !network.Default {
+			ips = append(ips, network.IPs...)
+		}
+	}
+	return model.AdditionalWorkloadIPs(ips, primary)
+}
+
+// endpointAddresses returns the addresses an endpoint of the pod is registered with: the address listed in the
+// Kubernetes endpoints, followed by the IPs of the secondary interfaces of the pod, which are registered as
+// secondary endpoints. The additional IPs are only added to the endpoint of the primary pod IP, so that a
+// dual-stack pod listed in the endpoints of both IP families does not register them twice.
+func endpointAddresses(pod *v1.Pod, addr string) []string {
+	if pod == nil || (pod.Status.PodIP != "" && pod.Status.PodIP != addr) {
+		return []string{addr}
+	}
+	return append([]string{addr}, podAdditionalIPs(pod, addr)...)
+}
+
 func getLabelValue(metadata metav1.Object, label string, fallBackLabel string) string {
 	metaLabels := metadata.GetLabels()
 	val := metaLabels[label]
@@ -15,6 +15,7 @@
 package kube
 
 import (
+	"strconv"
 	"strings"
 
 	coreV1 "k8s.io/api/core/v1"
@@ -31,6 +32,7 @@ import (
 	"istio.io/istio/pkg/config/kube"
 	"istio.io/istio/pkg/config/visibility"
 	"istio.io/istio/pkg/spiffe"
+	"istio.io/pkg/log"
 )
 
 const (
@@ -43,6 +45,14 @@ const (
 	// It is used for multi-cluster scenario, and with nodePort type gateway service.
 	// TODO: move to API
 	NodeSelectorAnnotation = "traffic.istio.io/nodeSelector"
+
+	// DrainingEndpointsAnnotation controls whether endpoints of the service that are terminating or failing
+	// readiness gates are sent as draining, rather than removed. This overrides PILOT_ENABLE_DRAINING_ENDPOINTS.
+	DrainingEndpointsAnnotation = "networking.istio.io/drainingEndpoints"
+
+	// NetworkGatewayWeightAnnotation is the relative weight of the gateways of a network gateway service among the
+	// gateways of their network, spreading the cross-network traffic among them. Defaults to 1, 0 drains them.
+	NetworkGatewayWeightAnnotation = "networking.istio.io/gatewayWeight"
 )
 
 func convertPort(port coreV1.ServicePort) *model.Port {
@@ -69,6 +79,17 @@ func ConvertService(svc coreV1.Service, domainSuffix string, clusterID cluster.I
 		addr = svc.Spec.ClusterIP
 	}
 
+	addresses := []string{addr}
+	if resolution != model.Passthrough {
+		// The cluster IPs of a dual-stack service, starting with the primary one which is also its ClusterIP.
+		// Only the proxies with dual stack enabled use the others.
+		for _, ip := range svc.Spec.ClusterIPs {
+			if ip != addr && ip != coreV1.ClusterIPNone && ip != "" {
+				addresses = append(addresses, ip)
+			}
+		}
+	}
+
 	ports := make([]*model.Port, 0, len(svc.Spec.Ports))
 	for _, port := range svc.Spec.Ports {
 		ports = append(ports, convertPort(port))
@@ -92,11 +113,28 @@ func ConvertService(svc coreV1.Service, domainSuffix string, clusterID cluster.I
 		}
 	}
 
+	drainingEndpoints := features.EnableDrainingEndpoints
+	if v, f := svc.Annotations[DrainingEndpointsAnnotation]; f {
+		drainingEndpoints = strings.EqualFold(v, "true")
+	}
+
+	var gatewayWeight uint32
+	var gatewayDrained bool
+	if v, f := svc.Annotations[NetworkGatewayWeightAnnotation]; f {
+		if w, err := strconv.ParseUint(v, 10, 32); err == nil {
+			gatewayWeight = uint32(w)
+			gatewayDrained = w == 0
+		} else {
+			log.Warnf("invalid %s annotation %q on service %s/%s, must be a non-negative integer",
+				NetworkGatewayWeightAnnotation, v, svc.Namespace, svc.Name)
+		}
+	}
+
 	istioService := &model.Service{
 		Hostname: ServiceHostname(svc.Name, svc.Namespace, domainSuffix),
 		ClusterVIPs: model.AddressMap{
 			Addresses: map[cluster.ID][]string{
-				clusterID: {addr},
+				clusterID: addresses,
 			},
 		},
 		Ports:           ports,
@@ -107,12 +145,15 @@ func ConvertService(svc coreV1.Service, domainSuffix string, clusterID cluster.I
 		CreationTime:    svc.CreationTimestamp.Time,
 		ResourceVersion: svc.ResourceVersion,
 		Attributes: model.ServiceAttributes{
-			ServiceRegistry: provider.Kubernetes,
-			Name:            svc.Name,
-			Namespace:       svc.Namespace,
-			Labels:          svc.Labels,
-			ExportTo:        exportTo,
-			LabelSelectors:  svc.Spec.Selector,
+			ServiceRegistry:       provider.Kubernetes,
+			Name:                  svc.Name,
+			Namespace:             svc.Namespace,
+			Labels:                svc.Labels,
+			ExportTo:              exportTo,
+			LabelSelectors:        svc.Spec.Selector,
+			DrainingEndpoints:     drainingEndpoints,
+			NetworkGatewayWeight:  gatewayWeight,
+			NetworkGatewayDrained: gatewayDrained,
 		},
 	}
 
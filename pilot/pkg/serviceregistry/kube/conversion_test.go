@@ -268,6 +268,94 @@ func TestServiceConversionWithEmptyServiceAccountsAnnotation(t *testing.T) {
 	}
 }
 
+func TestServiceConversionWithDrainingEndpointsAnnotation(t *testing.T) {
+	for _, tt := range []struct {
+		annotations map[string]string
+		want        bool
+	}{
+		{nil, false},
+		{map[string]string{DrainingEndpointsAnnotation: "true"}, true},
+		{map[string]string{DrainingEndpointsAnnotation: "false"}, false},
+	} {
+		localSvc := coreV1.Service{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:        "service1",
+				Namespace:   "default",
+				Annotations: tt.annotations,
+			},
+			Spec: coreV1.ServiceSpec{
+				ClusterIP: "10.0.0.1",
+				Ports: []coreV1.ServicePort{{
+					Name:     "http",
+					Port:     8080,
+					Protocol: coreV1.ProtocolTCP,
+				}},
+			},
+		}
+		service := ConvertService(localSvc, domainSuffix, clusterID)
+		if service.Attributes.DrainingEndpoints != tt.want {
+			t.Fatalf("expected DrainingEndpoints %v for annotations %v, got %v", tt.want, tt.annotations, service.Attributes.DrainingEndpoints)
+		}
+	}
+}
+
+func TestServiceConversionWithGatewayWeightAnnotation(t *testing.T) {
+	for _, tt := range []struct {
+		annotations map[string]string
+		want        uint32
+	}{
+		{nil, 0},
+		{map[string]string{NetworkGatewayWeightAnnotation: "3"}, 3},
+		{map[string]string{NetworkGatewayWeightAnnotation: "0"}, 0},
+		{map[string]string{NetworkGatewayWeightAnnotation: "heavy"}, 0},
+	} {
+		localSvc := coreV1.Service{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:        "service1",
+				Namespace:   "default",
+				Annotations: tt.annotations,
+			},
+			Spec: coreV1.ServiceSpec{
+				ClusterIP: "10.0.0.1",
+				Ports: []coreV1.ServicePort{{
+					Name:     "tls",
+					Port:     15443,
+					Protocol: coreV1.ProtocolTCP,
+				}},
+			},
+		}
+		service := ConvertService(localSvc, domainSuffix, clusterID)
+		if service.Attributes.NetworkGatewayWeight != tt.want {
+			t.Fatalf("expected NetworkGatewayWeight %v for annotations %v, got %v", tt.want, tt.annotations, service.Attributes.NetworkGatewayWeight)
+		}
+	}
+}
+
+func TestDualStackServiceConversion(t *testing.T) {
+	localSvc := coreV1.Service{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "service1",
+			Namespace: "default",
+		},
+		Spec: coreV1.ServiceSpec{
+			ClusterIP:  "10.0.0.1",
+			ClusterIPs: []string{"10.0.0.1", "fd00::1"},
+			Ports: []coreV1.ServicePort{{
+				Name:     "http",
+				Port:     8080,
+				Protocol: coreV1.ProtocolTCP,
+			}},
+		},
+	}
+	service := ConvertService(localSvc, domainSuffix, clusterID)
+	if got, want := service.ClusterVIPs.GetAddressesFor(clusterID), []string{"10.0.0.1", "fd00::1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected cluster VIPs %v, got %v", want, got)
+	}
+	if service.DefaultAddress != "10.0.0.1" {
+		t.Fatalf("expected the default address to be the primary cluster IP, got %v", service.DefaultAddress)
+	}
+}
+
 func TestExternalServiceConversion(t *testing.T) {
 	serviceName := "service1"
 	namespace := "default"
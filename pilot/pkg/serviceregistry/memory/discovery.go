@@ -45,6 +45,15 @@ func (c *ServiceController) AppendServiceHandler(f func(*model.Service, model.Ev
 	c.Unlock()
 }
 
+func (c *ServiceController) notifyServiceHandlers(svc *model.Service, event model.Event) {
+	c.RLock()
+	handlers := c.svcHandlers
+	c.RUnlock()
+	for _, f := range handlers {
+		f(svc, event)
+	}
+}
+
 // Run will run the controller
 func (c *ServiceController) Run(<-chan struct{}) {}
 
@@ -73,7 +82,8 @@ type ServiceDiscovery struct {
 	// Used by GetProxyWorkloadLabels
 	ip2workloadLabels map[string]*labels.Instance
 
-	// XDSUpdater will push EDS changes to the ADS model.
+	// XDSUpdater will push EDS changes to the ADS model. Service, instance, health and label changes are
+	// notified to it, if set.
 	EDSUpdater model.XDSUpdater
 
 	// Single mutex for now - it's for debug only.
@@ -102,8 +112,26 @@ func (sd *ServiceDiscovery) shardKey() model.ShardKey {
 	return model.NewShardKey(cluster.ID(sd.ClusterID), provider.Mock)
 }
 
+// AddWorkload sets the labels of the workload with the IP. The labels of its instances are updated, and the
+// proxy of the workload and the endpoints of its services are pushed.
 func (sd *ServiceDiscovery) AddWorkload(ip string, labels labels.Instance) {
+	sd.mutex.Lock()
 	sd.ip2workloadLabels[ip] = &labels
+	services := map[host.Name]struct{}{}
+	sd.updateEndpointsLocked(ip, func(i *model.ServiceInstance) bool {
+		services[i.Service.Hostname] = struct{}{}
+		return true
+	}, func(ep *model.IstioEndpoint) {
+		ep.Labels = labels
+	})
+	sd.mutex.Unlock()
+	if sd.EDSUpdater == nil {
+		return
+	}
+	sd.EDSUpdater.ProxyUpdate(cluster.ID(sd.ClusterID), ip)
+	for svc := range services {
+		sd.notifyEndpoints(svc)
+	}
 }
 
 // AddHTTPService is a helper to add a service of type http, named 'http-main', with the
@@ -122,24 +150,65 @@ func (sd *ServiceDiscovery) AddHTTPService(name, vip string, port int) {
 	})
 }
 
-// AddService adds an in-memory service.
+// AddService adds an in-memory service, or updates it if it already exists.
 func (sd *ServiceDiscovery) AddService(svc *model.Service) {
 	sd.mutex.Lock()
 	svc.Attributes.ServiceRegistry = provider.Mock
+	event := model.EventAdd
+	if _, f := sd.services[svc.Hostname]; f {
+		event = model.EventUpdate
+	}
 	sd.services[svc.Hostname] = svc
 	sd.mutex.Unlock()
-	// TODO: notify listeners
+	sd.notifyService(svc, event)
 }
 
-// RemoveService removes an in-memory service.
+// RemoveService removes an in-memory service, along with its instances.
 func (sd *ServiceDiscovery) RemoveService(name host.Name) {
 	sd.mutex.Lock()
+	svc := sd.services[name]
 	delete(sd.services, name)
+	sd.removeInstancesLocked(name, func(*model.ServiceInstance) bool { return true })
 	sd.mutex.Unlock()
-	sd.EDSUpdater.SvcUpdate(sd.shardKey(), string(name), "", model.EventDelete)
+	if svc == nil {
+		svc = &model.Service{Hostname: name}
+	}
+	sd.notifyService(svc, model.EventDelete)
+}
+
+// notifyService notifies the XDSUpdater and the service handlers of the controller of a service event.
+func (sd *ServiceDiscovery) notifyService(svc *model.Service, event model.Event) {
+	if sd.EDSUpdater != nil {
+		sd.EDSUpdater.SvcUpdate(sd.shardKey(), string(svc.Hostname), svc.Attributes.Namespace, event)
+	}
+	if c, ok := sd.Controller.(*ServiceController); ok {
+		c.notifyServiceHandlers(svc, event)
+	}
 }
 
-// AddInstance adds an in-memory instance.
+// notifyEndpoints pushes the current endpoints of a service to the XDSUpdater.
+func (sd *ServiceDiscovery) notifyEndpoints(service host.Name) {
+	if sd.EDSUpdater == nil {
+		return
+	}
+	sd.mutex.Lock()
+	svc := sd.services[service]
+	if svc == nil {
+		sd.mutex.Unlock()
+		return
+	}
+	endpoints := make([]*model.IstioEndpoint, 0)
+	for _, port := range svc.Ports {
+		for _, instance := range sd.instancesByPortNum[fmt.Sprintf("%s:%d", service, port.Port)] {
+			endpoints = append(endpoints, instance.Endpoint)
+		}
+	}
+	sd.mutex.Unlock()
+	sd.EDSUpdater.EDSUpdate(sd.shardKey(), string(service), svc.Attributes.Namespace, endpoints)
+}
+
+// AddInstance adds an in-memory instance. The endpoints are not pushed, so that instances can be added before
+// the XDSUpdater is started; they are picked up by the next full push. Use SetEndpoints to push them instead.
 func (sd *ServiceDiscovery) AddInstance(service host.Name, instance *model.ServiceInstance) {
 	// WIP: add enough code to allow tests and load tests to work
 	sd.mutex.Lock()
@@ -160,6 +229,98 @@ func (sd *ServiceDiscovery) AddInstance(service host.Name, instance *model.Servi
 	sd.instancesByPortName[key] = append(instanceList, instance)
 }
 
+// RemoveInstance removes the instances of a service with the address, and pushes the endpoints of the service.
+func (sd *ServiceDiscovery) RemoveInstance(service host.Name, address string) {
+	sd.mutex.Lock()
+	sd.removeInstancesLocked(service, func(i *model.ServiceInstance) bool { return i.Endpoint.Address == address })
+	sd.mutex.Unlock()
+	sd.notifyEndpoints(service)
+}
+
+// SetHealthStatus sets the health status of the instances of a service with the address, and pushes the
+// endpoints of the service.
+func (sd *ServiceDiscovery) SetHealthStatus(service host.Name, address string, status model.HealthStatus) {
+	sd.mutex.Lock()
+	sd.updateEndpointsLocked(address, func(i *model.ServiceInstance) bool {
+		return i.Service.Hostname == service
+	}, func(ep *model.IstioEndpoint) {
+		ep.HealthStatus = status
+	})
+	sd.mutex.Unlock()
+	sd.notifyEndpoints(service)
+}
+
+// updateEndpointsLocked replaces the instances with the address matching the predicate with copies whose endpoint
+// is updated. The endpoints are never modified in place, as they are shared with the pushed endpoints, which
+// must keep their values for the endpoint changes to be detected. The mutex must be held.
+func (sd *ServiceDiscovery) updateEndpointsLocked(address string, match func(*model.ServiceInstance) bool,
+	update func(*model.IstioEndpoint)) {
+	updated := map[*model.ServiceInstance]*model.ServiceInstance{}
+	for _, instance := range sd.ip2instance[address] {
+		if !match(instance) {
+			continue
+		}
+		cpy := *instance
+		cpy.Endpoint = instance.Endpoint.DeepCopy()
+		update(cpy.Endpoint)
+		updated[instance] = &cpy
+	}
+	if len(updated) == 0 {
+		return
+	}
+	replace := func(instances []*model.ServiceInstance) []*model.ServiceInstance {
+		out := make([]*model.ServiceInstance, 0, len(instances))
+		for _, i := range instances {
+			if u, f := updated[i]; f {
+				i = u
+			}
+			out = append(out, i)
+		}
+		return out
+	}
+	sd.ip2instance[address] = replace(sd.ip2instance[address])
+	for k, v := range sd.instancesByPortNum {
+		sd.instancesByPortNum[k] = replace(v)
+	}
+	for k, v := range sd.instancesByPortName {
+		sd.instancesByPortName[k] = replace(v)
+	}
+}
+
+// removeInstancesLocked removes the instances of a service matching the predicate. The mutex must be held.
+func (sd *ServiceDiscovery) removeInstancesLocked(service host.Name, match func(*model.ServiceInstance) bool) {
+	keep := func(instances []*model.ServiceInstance) []*model.ServiceInstance {
+		out := make([]*model.ServiceInstance, 0, len(instances))
+		for _, i := range instances {
+			if i.Service.Hostname != service || !match(i) {
+				out = append(out, i)
+			}
+		}
+		return out
+	}
+	for k, v := range sd.ip2instance {
+		if v = keep(v); len(v) == 0 {
+			delete(sd.ip2instance, k)
+		} else {
+			sd.ip2instance[k] = v
+		}
+	}
+	for k, v := range sd.instancesByPortNum {
+		if v = keep(v); len(v) == 0 {
+			delete(sd.instancesByPortNum, k)
+		} else {
+			sd.instancesByPortNum[k] = v
+		}
+	}
+	for k, v := range sd.instancesByPortName {
+		if v = keep(v); len(v) == 0 {
+			delete(sd.instancesByPortName, k)
+		} else {
+			sd.instancesByPortName[k] = v
+		}
+	}
+}
+
 // AddEndpoint adds an endpoint to a service.
 func (sd *ServiceDiscovery) AddEndpoint(service host.Name, servicePortName string, servicePort int, address string, port int) *model.ServiceInstance {
 	instance := &model.ServiceInstance{
@@ -185,6 +346,7 @@ func (sd *ServiceDiscovery) SetEndpoints(service string, namespace string, endpo
 	sd.mutex.Lock()
 	svc := sd.services[sh]
 	if svc == nil {
+		sd.mutex.Unlock()
 		return
 	}
 
@@ -0,0 +1,111 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+)
+
+// recordingUpdater records the notifications of the registry as strings.
+type recordingUpdater struct {
+	model.XDSUpdater
+	events []string
+	// endpoints are the last pushed endpoints
+	endpoints []*model.IstioEndpoint
+}
+
+func (r *recordingUpdater) EDSUpdate(_ model.ShardKey, hostname string, _ string, entry []*model.IstioEndpoint) {
+	eps := make([]string, 0, len(entry))
+	for _, e := range entry {
+		eps = append(eps, fmt.Sprintf("%s/%d/%v", e.Address, e.HealthStatus, e.Labels))
+	}
+	r.events = append(r.events, fmt.Sprintf("eds %s %v", hostname, eps))
+	r.endpoints = entry
+}
+
+func (r *recordingUpdater) SvcUpdate(_ model.ShardKey, hostname string, _ string, event model.Event) {
+	r.events = append(r.events, fmt.Sprintf("service %s %s", hostname, event))
+}
+
+func (r *recordingUpdater) ProxyUpdate(_ cluster.ID, ip string) {
+	r.events = append(r.events, "proxy "+ip)
+}
+
+func (r *recordingUpdater) expect(t *testing.T, want ...string) {
+	t.Helper()
+	if len(r.events) != len(want) || len(want) > 0 && !reflect.DeepEqual(r.events, want) {
+		t.Fatalf("got events %v, want %v", r.events, want)
+	}
+	r.events = nil
+}
+
+func TestServiceDiscoveryEvents(t *testing.T) {
+	updater := &recordingUpdater{}
+	sd := NewServiceDiscovery()
+	sd.EDSUpdater = updater
+	var handled []string
+	sd.Controller.AppendServiceHandler(func(svc *model.Service, event model.Event) {
+		handled = append(handled, fmt.Sprintf("%s %s", svc.Hostname, event))
+	})
+
+	sd.AddHTTPService("a.default.svc.cluster.local", "10.0.0.1", 80)
+	updater.expect(t, "service a.default.svc.cluster.local add")
+	sd.AddHTTPService("a.default.svc.cluster.local", "10.0.0.1", 80)
+	updater.expect(t, "service a.default.svc.cluster.local update")
+
+	svc := host.Name("a.default.svc.cluster.local")
+	sd.AddEndpoint(svc, "http-main", 80, "1.1.1.1", 8080)
+	sd.AddEndpoint(svc, "http-main", 80, "1.1.1.2", 8080)
+	updater.expect(t)
+
+	sd.SetHealthStatus(svc, "1.1.1.2", model.UnHealthy)
+	updater.expect(t, "eds a.default.svc.cluster.local [1.1.1.1/0/ 1.1.1.2/1/]")
+
+	pushed := updater.endpoints
+	sd.AddWorkload("1.1.1.1", labels.Instance{"version": "v2"})
+	updater.expect(t, "proxy 1.1.1.1", "eds a.default.svc.cluster.local [1.1.1.1/0/version=v2 1.1.1.2/1/]")
+	// The pushed endpoints are not modified, so that the changes can be detected.
+	if pushed[0].Labels != nil {
+		t.Fatalf("expected the pushed endpoint to be unchanged, got labels %v", pushed[0].Labels)
+	}
+
+	sd.RemoveInstance(svc, "1.1.1.2")
+	updater.expect(t, "eds a.default.svc.cluster.local [1.1.1.1/0/version=v2]")
+	if got := sd.InstancesByPort(sd.GetService(svc), 80, nil); len(got) != 1 {
+		t.Fatalf("expected 1 instance left, got %d", len(got))
+	}
+
+	sd.RemoveService(svc)
+	updater.expect(t, "service a.default.svc.cluster.local delete")
+	if got := sd.GetProxyServiceInstances(&model.Proxy{IPAddresses: []string{"1.1.1.1"}}); len(got) != 0 {
+		t.Fatalf("expected the instances of the removed service to be removed, got %v", got)
+	}
+
+	want := []string{
+		"a.default.svc.cluster.local add",
+		"a.default.svc.cluster.local update",
+		"a.default.svc.cluster.local delete",
+	}
+	if !reflect.DeepEqual(handled, want) {
+		t.Fatalf("got handled events %v, want %v", handled, want)
+	}
+}
@@ -290,6 +290,40 @@ func (s *ServiceEntryStore) convertWorkloadEntryToServiceInstances(wle *networki
 	return out
 }
 
+// workloadEntryAdditionalIPs returns the additional IPs declared by a WorkloadEntry through the
+// model.AdditionalIPsAnnotation. Entries reached over a unix domain socket have none.
+func workloadEntryAdditionalIPs(cfg config.Config) []string {
+	wle, ok := cfg.Spec.(*networking.WorkloadEntry)
+	if !ok || strings.HasPrefix(wle.Address, model.UnixAddressPrefix) {
+		return nil
+	}
+	value, f := cfg.Annotations[model.AdditionalIPsAnnotation]
+	if !f {
+		return nil
+	}
+	return model.AdditionalWorkloadIPs(strings.Split(value, ","), wle.Address)
+}
+
+// convertAdditionalIPsToServiceInstances copies the ServiceInstances of a workload for each of its additional IPs,
+// as secondary endpoints.
+func convertAdditionalIPsToServiceInstances(instances []*model.ServiceInstance, ips []string) []*model.ServiceInstance {
+	out := make([]*model.ServiceInstance, 0, len(instances)*len(ips))
+	for _, ip := range ips {
+		for _, instance := range instances {
+			ep := *instance.Endpoint
+			ep.Address = ip
+			ep.Secondary = true
+			ep.EnvoyEndpoint = nil
+			out = append(out, &model.ServiceInstance{
+				Endpoint:    &ep,
+				Service:     instance.Service,
+				ServicePort: instance.ServicePort,
+			})
+		}
+	}
+	return out
+}
+
 func (s *ServiceEntryStore) convertServiceEntryToInstances(cfg config.Config, services []*model.Service) []*model.ServiceInstance {
 	out := make([]*model.ServiceInstance, 0)
 	serviceEntry := cfg.Spec.(*networking.ServiceEntry)
@@ -425,5 +459,6 @@ func (s *ServiceEntryStore) convertWorkloadEntryToWorkloadInstance(cfg config.Co
 		Name:                cfg.Name,
 		Kind:                model.WorkloadEntryKind,
 		DNSServiceEntryOnly: dnsServiceEntryOnly,
+		AdditionalIPs:       workloadEntryAdditionalIPs(cfg),
 	}
 }
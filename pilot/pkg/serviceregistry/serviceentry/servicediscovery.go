@@ -31,6 +31,7 @@ import (
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pilot/pkg/serviceregistry/util/workloadinstances"
 	"istio.io/istio/pilot/pkg/util/informermetric"
+	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
@@ -173,17 +174,28 @@ func (s *ServiceEntryStore) workloadEntryHandler(old, curr config.Config, event
 		namespace: curr.Namespace,
 	}
 
-	// If an entry is unhealthy, we will mark this as a delete instead
+	// If an entry is unhealthy and unhealthy endpoints are sent, we keep its endpoints with an unhealthy status, so
+	// that health flips only produce incremental EDS pushes. Otherwise we will mark this as a delete instead.
 	// This ensures we do not track unhealthy endpoints
-	if features.WorkloadEntryHealthChecks && !isHealthy(curr) {
+	unhealthy := features.WorkloadEntryHealthChecks && !isHealthy(curr)
+	if unhealthy && !features.SendUnhealthyEndpoints {
 		event = model.EventDelete
+		unhealthy = false
 	}
 
 	wi := s.convertWorkloadEntryToWorkloadInstance(curr, s.Cluster())
+	if wi != nil && unhealthy {
+		wi.Endpoint.HealthStatus = model.UnHealthy
+	}
 	if wi != nil && !wi.DNSServiceEntryOnly {
+		// Kubernetes services do not select unhealthy workload entries.
+		wiEvent := event
+		if unhealthy {
+			wiEvent = model.EventDelete
+		}
 		// fire off the k8s handlers
 		for _, h := range s.workloadHandlers {
-			h(wi, event)
+			h(wi, wiEvent)
 		}
 	}
 
@@ -215,6 +227,12 @@ func (s *ServiceEntryStore) workloadEntryHandler(old, curr config.Config, event
 		}
 	}
 	unSelected := difference(oldSes, currSes)
+	additionalIPs := wi.AdditionalIPs
+	// The instances of the additional IPs the entry no longer declares have to be removed as well.
+	var staleIPs []string
+	if oldWle != nil {
+		staleIPs = removedIPs(workloadEntryAdditionalIPs(old), additionalIPs)
+	}
 	log.Debugf("workloadEntry %s/%s selected %v, unSelected %v serviceEntry", curr.Namespace, curr.Name, currSes, unSelected)
 	s.mutex.Lock()
 	for namespacedName, cfg := range currSes {
@@ -226,6 +244,19 @@ func (s *ServiceEntryStore) workloadEntryHandler(old, curr config.Config, event
 			continue
 		}
 		instance := s.convertWorkloadEntryToServiceInstances(wle, services, se, &key, s.Cluster())
+		instancesDeleted = append(instancesDeleted, convertAdditionalIPsToServiceInstances(instance, staleIPs)...)
+		instance = append(instance, convertAdditionalIPsToServiceInstances(instance, additionalIPs)...)
+		if unhealthy {
+			if se.Resolution == networking.ServiceEntry_DNS || se.Resolution == networking.ServiceEntry_DNS_ROUND_ROBIN {
+				// DNS clusters carry their endpoints, without health status.
+				instancesDeleted = append(instancesDeleted, instance...)
+				addConfigs(se, services)
+				continue
+			}
+			for _, i := range instance {
+				i.Endpoint.HealthStatus = model.UnHealthy
+			}
+		}
 		instancesUpdated = append(instancesUpdated, instance...)
 		addConfigs(se, services)
 	}
@@ -241,6 +272,8 @@ func (s *ServiceEntryStore) workloadEntryHandler(old, curr config.Config, event
 		}
 		instance := s.convertWorkloadEntryToServiceInstances(wle, services, se, &key, s.Cluster())
 		instancesDeleted = append(instancesDeleted, instance...)
+		instancesDeleted = append(instancesDeleted, convertAdditionalIPsToServiceInstances(instance, additionalIPs)...)
+		instancesDeleted = append(instancesDeleted, convertAdditionalIPsToServiceInstances(instance, staleIPs)...)
 		addConfigs(se, services)
 	}
 
@@ -409,6 +442,7 @@ func (s *ServiceEntryStore) WorkloadInstanceHandler(wi *model.WorkloadInstance,
 	redundantEventForPod := false
 
 	var addressToDelete string
+	var staleIPs []string
 
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -423,6 +457,7 @@ func (s *ServiceEntryStore) WorkloadInstanceHandler(wi *model.WorkloadInstance,
 			if old.Endpoint.Address != wi.Endpoint.Address {
 				addressToDelete = old.Endpoint.Address
 			}
+			staleIPs = removedIPs(old.AdditionalIPs, wi.AdditionalIPs)
 			// If multiple k8s services select the same pod or a service has multiple ports,
 			// we may be getting multiple events ignore them as we only care about the Endpoint IP itself.
 			if model.WorkloadInstancesEqual(old, wi) {
@@ -454,6 +489,8 @@ func (s *ServiceEntryStore) WorkloadInstanceHandler(wi *model.WorkloadInstance,
 		seNamespacedName := types.NamespacedName{Namespace: cfg.Namespace, Name: cfg.Name}
 		services := s.services.getServices(seNamespacedName)
 		instance := convertWorkloadInstanceToServiceInstance(wi.Endpoint, services, se)
+		instancesDeleted = append(instancesDeleted, convertAdditionalIPsToServiceInstances(instance, staleIPs)...)
+		instance = append(instance, convertAdditionalIPsToServiceInstances(instance, wi.AdditionalIPs)...)
 		instances = append(instances, instance...)
 		if addressToDelete != "" {
 			for _, i := range instance {
@@ -637,6 +674,7 @@ func (s *ServiceEntryStore) edsUpdateByKeys(keys map[instancesKey]struct{}, push
 				TLSMode:         instance.Endpoint.TLSMode,
 				WorkloadName:    instance.Endpoint.WorkloadName,
 				Namespace:       instance.Endpoint.Namespace,
+				HealthStatus:    instance.Endpoint.HealthStatus,
 			})
 	}
 
@@ -831,6 +869,21 @@ func parseHealthAnnotation(s string) bool {
 	return p
 }
 
+// removedIPs returns the IPs of old which are not in cur.
+func removedIPs(old, cur []string) []string {
+	if len(old) == 0 {
+		return nil
+	}
+	current := sets.NewSet(cur...)
+	var out []string
+	for _, ip := range old {
+		if !current.Contains(ip) {
+			out = append(out, ip)
+		}
+	}
+	return out
+}
+
 func (s *ServiceEntryStore) buildServiceInstancesForSE(
 	curr config.Config,
 	services []*model.Service,
@@ -850,6 +903,7 @@ func (s *ServiceEntryStore) buildServiceInstancesForSE(
 				continue
 			}
 			instances := convertWorkloadInstanceToServiceInstance(wi.Endpoint, services, currentServiceEntry)
+			instances = append(instances, convertAdditionalIPsToServiceInstances(instances, wi.AdditionalIPs)...)
 			serviceInstances = append(serviceInstances, instances...)
 			ckey := configKey{namespace: wi.Namespace, name: wi.Name}
 			if wi.Kind == model.PodKind {
@@ -25,9 +25,12 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 
 	"istio.io/api/label"
+	"istio.io/api/meta/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/model/status"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
@@ -78,6 +81,7 @@ type Event struct {
 	namespace string
 	proxyIP   string
 	endpoints int
+	unhealthy int
 	pushReq   *model.PushRequest
 }
 
@@ -89,7 +93,13 @@ type FakeXdsUpdater struct {
 var _ model.XDSUpdater = &FakeXdsUpdater{}
 
 func (fx *FakeXdsUpdater) EDSUpdate(_ model.ShardKey, hostname string, namespace string, entry []*model.IstioEndpoint) {
-	fx.Events <- Event{kind: "eds", host: hostname, namespace: namespace, endpoints: len(entry)}
+	unhealthy := 0
+	for _, ep := range entry {
+		if ep.HealthStatus == model.UnHealthy {
+			unhealthy++
+		}
+	}
+	fx.Events <- Event{kind: "eds", host: hostname, namespace: namespace, endpoints: len(entry), unhealthy: unhealthy}
 }
 
 func (fx *FakeXdsUpdater) EDSCacheUpdate(_ model.ShardKey, _, _ string, _ []*model.IstioEndpoint) {
@@ -748,6 +758,121 @@ func TestServiceDiscoveryWorkloadUpdate(t *testing.T) {
 	})
 }
 
+func TestServiceDiscoveryWorkloadHealth(t *testing.T) {
+	defer func(v bool) { features.WorkloadEntryHealthChecks = v }(features.WorkloadEntryHealthChecks)
+	features.WorkloadEntryHealthChecks = true
+	store, _, events, stopFn := initServiceDiscovery()
+	defer stopFn()
+
+	createConfigs([]*config.Config{selector}, store, t)
+	expectEvents(t, events,
+		Event{kind: "svcupdate", host: "selector.com", namespace: selector.Namespace},
+		Event{kind: "xds"})
+
+	wle := createWorkloadEntry("wl", selector.Name,
+		&networking.WorkloadEntry{
+			Address:        "2.2.2.2",
+			Labels:         map[string]string{"app": "wle"},
+			ServiceAccount: "default",
+		})
+	createConfigs([]*config.Config{setHealth(wle, true)}, store, t)
+	expectEvents(t, events,
+		Event{kind: "xds", proxyIP: "2.2.2.2"},
+		Event{kind: "eds", host: "selector.com", namespace: selector.Namespace, endpoints: 2})
+
+	// Health flips keep the endpoints, with their health status, so that they only trigger incremental EDS updates.
+	createConfigs([]*config.Config{setHealth(wle, false)}, store, t)
+	expectEvents(t, events,
+		Event{kind: "eds", host: "selector.com", namespace: selector.Namespace, endpoints: 2, unhealthy: 2})
+
+	createConfigs([]*config.Config{setHealth(wle, true)}, store, t)
+	expectEvents(t, events,
+		Event{kind: "eds", host: "selector.com", namespace: selector.Namespace, endpoints: 2})
+}
+
+func TestServiceDiscoveryWorkloadAdditionalIPs(t *testing.T) {
+	defer func(v bool) { features.EnableAdditionalWorkloadIPs = v }(features.EnableAdditionalWorkloadIPs)
+	features.EnableAdditionalWorkloadIPs = true
+	store, sd, events, stopFn := initServiceDiscovery()
+	defer stopFn()
+
+	createConfigs([]*config.Config{selector}, store, t)
+	expectEvents(t, events,
+		Event{kind: "svcupdate", host: "selector.com", namespace: selector.Namespace},
+		Event{kind: "xds"})
+
+	wle := createWorkloadEntry("wl", selector.Name,
+		&networking.WorkloadEntry{
+			Address:        "2.2.2.2",
+			Labels:         map[string]string{"app": "wle"},
+			ServiceAccount: "default",
+		})
+	wle.Annotations = map[string]string{model.AdditionalIPsAnnotation: "3.3.3.3"}
+	createConfigs([]*config.Config{wle}, store, t)
+	expectEvents(t, events,
+		Event{kind: "xds", proxyIP: "2.2.2.2"},
+		Event{kind: "eds", host: "selector.com", namespace: selector.Namespace, endpoints: 4})
+	proxy := &model.Proxy{IPAddresses: []string{"3.3.3.3"}, Metadata: &model.NodeMetadata{}}
+	if got := sd.GetProxyServiceInstances(proxy); len(got) != 2 {
+		t.Fatalf("got %d instances for the additional IP, want 2", len(got))
+	}
+
+	// Removing the additional IP removes its instances.
+	updated := wle.DeepCopy()
+	updated.Annotations = nil
+	createConfigs([]*config.Config{&updated}, store, t)
+	expectEvents(t, events,
+		Event{kind: "eds", host: "selector.com", namespace: selector.Namespace, endpoints: 2})
+	if got := sd.GetProxyServiceInstances(proxy); len(got) != 0 {
+		t.Fatalf("got %d instances for the removed additional IP, want 0", len(got))
+	}
+}
+
+func TestServiceDiscoveryServiceEntryAdditionalIPs(t *testing.T) {
+	defer func(v bool) { features.EnableAdditionalWorkloadIPs = v }(features.EnableAdditionalWorkloadIPs)
+	features.EnableAdditionalWorkloadIPs = true
+	store, sd, _, stopFn := initServiceDiscovery()
+	defer stopFn()
+
+	// The additional IPs of the workload entries are kept when the ServiceEntry selecting them is created after them.
+	wle := createWorkloadEntry("wl", selector.Name,
+		&networking.WorkloadEntry{
+			Address:        "2.2.2.2",
+			Labels:         map[string]string{"app": "wle"},
+			ServiceAccount: "default",
+		})
+	wle.Annotations = map[string]string{model.AdditionalIPsAnnotation: "3.3.3.3"}
+	createConfigs([]*config.Config{wle, selector}, store, t)
+	retry.UntilSuccessOrFail(t, func() error {
+		var primary, secondary []string
+		for _, instance := range sd.InstancesByPort(convertServices(*selector)[0], 444, nil) {
+			if instance.Endpoint.Secondary {
+				secondary = append(secondary, instance.Endpoint.Address)
+			} else {
+				primary = append(primary, instance.Endpoint.Address)
+			}
+		}
+		if !reflect.DeepEqual(primary, []string{"2.2.2.2"}) || !reflect.DeepEqual(secondary, []string{"3.3.3.3"}) {
+			return fmt.Errorf("got primary endpoints %v and secondary endpoints %v", primary, secondary)
+		}
+		return nil
+	}, retry.Timeout(time.Second))
+}
+
+func setHealth(cfg *config.Config, healthy bool) *config.Config {
+	c := cfg.DeepCopy()
+	c.Annotations = map[string]string{status.WorkloadEntryHealthCheckAnnotation: "true"}
+	condition := status.StatusFalse
+	if healthy {
+		condition = status.StatusTrue
+	}
+	c = status.UpdateConfigCondition(c, &v1alpha1.IstioCondition{
+		Type:   status.ConditionHealthy,
+		Status: condition,
+	})
+	return &c
+}
+
 func TestServiceDiscoveryWorkloadChangeLabel(t *testing.T) {
 	store, sd, events, stopFn := initServiceDiscovery()
 	defer stopFn()
@@ -290,6 +290,15 @@ func (r *Reporter) RegisterEvent(conID string, distributionType xds.EventType, n
 	}
 }
 
+// RegisterDeltaEvent registers that a dataplane has acknowledged a new version of the config over delta xDS. Requests
+// without a nonce, which only change the subscriptions, and NACKs do not change the version of the dataplane.
+func (r *Reporter) RegisterDeltaEvent(conID string, distributionType xds.EventType, nonce string, nack bool) {
+	if nonce == "" || nack {
+		return
+	}
+	r.RegisterEvent(conID, distributionType, nonce)
+}
+
 func (r *Reporter) readFromEventQueue(stop <-chan struct{}) {
 	for {
 		select {
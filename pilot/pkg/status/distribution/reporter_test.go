@@ -23,6 +23,7 @@ import (
 
 	"istio.io/istio/pilot/pkg/status"
 	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/pkg/ledger"
@@ -47,6 +48,41 @@ func TestStatusMaps(t *testing.T) {
 	Expect(r.reverseStatus).To(Equal(map[string]map[string]struct{}{"a": {"conB~": x}, "c": {"conC~": x}, "d": {"conD~": x}}))
 }
 
+func TestRegisterDeltaEvent(t *testing.T) {
+	RegisterTestingT(t)
+	r := initReporterWithoutStarting()
+	r.distributionEventQueue = make(chan distributionEvent, 10)
+	process := func() {
+		for {
+			select {
+			case ev := <-r.distributionEventQueue:
+				r.processEvent(ev.conID, ev.distributionType, ev.nonce)
+			default:
+				return
+			}
+		}
+	}
+	typ := v3.ClusterType
+	r.RegisterDeltaEvent("conA", typ, "", false)
+	process()
+	Expect(r.status).To(BeEmpty())
+
+	r.RegisterDeltaEvent("conA", typ, "a", false)
+	process()
+	Expect(r.status).To(Equal(map[string]string{"conA~" + typ: "a"}))
+
+	// Subscription changes and NACKs keep the version acknowledged previously.
+	r.RegisterDeltaEvent("conA", typ, "", false)
+	r.RegisterDeltaEvent("conA", typ, "b", true)
+	process()
+	Expect(r.status).To(Equal(map[string]string{"conA~" + typ: "a"}))
+
+	// The ACK of a response only removing resources is a new version.
+	r.RegisterDeltaEvent("conA", typ, "c", false)
+	process()
+	Expect(r.status).To(Equal(map[string]string{"conA~" + typ: "c"}))
+}
+
 func initReporterWithoutStarting() (out Reporter) {
 	out.PodName = "tespod"
 	out.inProgressResources = map[string]*inProgressEntry{}
@@ -0,0 +1,206 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gatewaycredentials reports in the status of the Gateways whether the credentials referenced by their
+// servers exist, and how the missing ones are handled.
+package gatewaycredentials
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/meta/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/status"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/pkg/log"
+)
+
+var scope = log.RegisterScope("gatewaycredentials", "Gateway credentials status debugging", 0)
+
+const (
+	// ConditionType is the type of the condition of the Gateway status reporting the credentials.
+	ConditionType = "CredentialsAvailable"
+	// ReasonMissingCredential is the reason of the condition when a referenced credential does not exist.
+	ReasonMissingCredential = "MissingCredential"
+
+	defaultInterval = 10 * time.Second
+)
+
+// Controller periodically checks the credentials referenced by the servers of the Gateways, and sets the
+// CredentialsAvailable condition of their status. The condition is removed from the Gateways which no longer
+// reference any credential.
+type Controller struct {
+	store       model.ConfigStore
+	credentials model.GatewayCredentials
+	interval    time.Duration
+	enqueue     func(context interface{}, target status.Resource)
+
+	// reported is the condition last enqueued for each Gateway referencing credentials, keyed by namespace/name.
+	reported map[string]*v1alpha1.IstioCondition
+}
+
+func NewController(store model.ConfigStore, credentials model.GatewayCredentials, m *status.Manager) *Controller {
+	c := &Controller{
+		store:       store,
+		credentials: credentials,
+		interval:    defaultInterval,
+		reported:    map[string]*v1alpha1.IstioCondition{},
+	}
+	c.enqueue = m.CreateIstioStatusController(func(current *v1alpha1.IstioStatus, context interface{}) *v1alpha1.IstioStatus {
+		return setCondition(current, context.(*v1alpha1.IstioCondition))
+	}).EnqueueStatusUpdateResource
+	return c
+}
+
+// Run reconciles the status of the Gateways until stop is closed.
+func (c *Controller) Run(stop <-chan struct{}) {
+	scope.Info("Starting Gateway credentials status controller")
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+	for {
+		c.reconcile()
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Controller) reconcile() {
+	gateways, err := c.store.List(gvk.Gateway, model.NamespaceAll)
+	if err != nil {
+		scope.Errorf("failed to list the gateways: %v", err)
+		return
+	}
+	seen := make(map[string]struct{}, len(gateways))
+	for _, gw := range gateways {
+		key := gw.Namespace + "/" + gw.Name
+		seen[key] = struct{}{}
+		desired := c.condition(gw)
+		last, f := c.reported[key]
+		if desired == nil {
+			if f {
+				// the Gateway no longer references credentials, remove the condition
+				delete(c.reported, key)
+				c.enqueue(desired, status.ResourceFromModelConfig(gw))
+			}
+			continue
+		}
+		if f && last.Status == desired.Status && last.Message == desired.Message {
+			continue
+		}
+		c.reported[key] = desired
+		c.enqueue(desired, status.ResourceFromModelConfig(gw))
+	}
+	for key := range c.reported {
+		if _, f := seen[key]; !f {
+			delete(c.reported, key)
+		}
+	}
+}
+
+// condition returns the CredentialsAvailable condition of the Gateway, nil if it does not reference any credential.
+func (c *Controller) condition(gw config.Config) *v1alpha1.IstioCondition {
+	var referenced, missing []string
+	for _, s := range gw.Spec.(*networking.Gateway).Servers {
+		cn := s.GetTls().GetCredentialName()
+		if cn == "" || contains(referenced, cn) {
+			continue
+		}
+		referenced = append(referenced, cn)
+		if !c.credentials.ConfigCredentialExists(gw.Namespace, cn) {
+			missing = append(missing, cn)
+		}
+	}
+	if len(referenced) == 0 {
+		return nil
+	}
+	now := types.TimestampNow()
+	if len(missing) == 0 {
+		return &v1alpha1.IstioCondition{
+			Type:               ConditionType,
+			Status:             "True",
+			LastProbeTime:      now,
+			LastTransitionTime: now,
+			Message:            "All the credentials referenced by the servers exist.",
+		}
+	}
+	sort.Strings(missing)
+	return &v1alpha1.IstioCondition{
+		Type:               ConditionType,
+		Status:             "False",
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+		Reason:             ReasonMissingCredential,
+		Message: fmt.Sprintf("Credentials %s not found: %s.", strings.Join(missing, ", "),
+			policyEffect(model.GetMissingCredentialPolicy(gw))),
+	}
+}
+
+func policyEffect(policy model.MissingCredentialPolicy) string {
+	switch policy {
+	case model.MissingCredentialKeepLastGood:
+		return "the servers keep serving their last good certificates, if any"
+	case model.MissingCredentialDefaultCertificate:
+		return "the servers serve the default certificate of the gateway"
+	case model.MissingCredentialRejectServer:
+		return "the servers are removed from the gateway"
+	default:
+		return "the listeners of the servers do not become ready"
+	}
+}
+
+// setCondition returns the status with the condition set, replacing the condition of the same type. The condition
+// is removed if nil.
+func setCondition(current *v1alpha1.IstioStatus, condition *v1alpha1.IstioCondition) *v1alpha1.IstioStatus {
+	if current == nil {
+		current = &v1alpha1.IstioStatus{}
+	} else {
+		current = current.DeepCopy()
+	}
+	conditions := make([]*v1alpha1.IstioCondition, 0, len(current.Conditions)+1)
+	for _, existing := range current.Conditions {
+		if existing.Type != ConditionType {
+			conditions = append(conditions, existing)
+			continue
+		}
+		if condition != nil && existing.Status == condition.Status {
+			// the condition did not transition
+			condition = condition.DeepCopy()
+			condition.LastTransitionTime = existing.LastTransitionTime
+		}
+	}
+	if condition != nil {
+		conditions = append(conditions, condition)
+	}
+	current.Conditions = conditions
+	return current
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gatewaycredentials
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/meta/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/status"
+	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+type fakeCredentials struct {
+	existing sets.Set
+}
+
+func (f fakeCredentials) CredentialExists(*model.Proxy, string) bool {
+	return false
+}
+
+func (f fakeCredentials) ConfigCredentialExists(namespace, credentialName string) bool {
+	return f.existing.Contains(namespace + "/" + credentialName)
+}
+
+func gateway(name, policy string, credentialNames ...string) config.Config {
+	gw := &networking.Gateway{Servers: []*networking.Server{{
+		Port:  &networking.Port{Number: 80, Protocol: "HTTP", Name: "http"},
+		Hosts: []string{"*"},
+	}}}
+	for _, cn := range credentialNames {
+		gw.Servers = append(gw.Servers, &networking.Server{
+			Port:  &networking.Port{Number: 443, Protocol: "HTTPS", Name: cn},
+			Hosts: []string{"*"},
+			Tls:   &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_SIMPLE, CredentialName: cn},
+		})
+	}
+	cfg := config.Config{
+		Meta: config.Meta{GroupVersionKind: gvk.Gateway, Name: name, Namespace: "ns"},
+		Spec: gw,
+	}
+	if policy != "" {
+		cfg.Annotations = map[string]string{constants.GatewayMissingCredentialPolicyAnnotation: policy}
+	}
+	return cfg
+}
+
+func TestReconcile(t *testing.T) {
+	store := memory.Make(collections.Pilot)
+	creds := fakeCredentials{existing: sets.NewSet("ns/present")}
+	enqueued := map[string]*v1alpha1.IstioCondition{}
+	c := &Controller{
+		store:       store,
+		credentials: creds,
+		reported:    map[string]*v1alpha1.IstioCondition{},
+		enqueue: func(context interface{}, target status.Resource) {
+			enqueued[target.Name] = context.(*v1alpha1.IstioCondition)
+		},
+	}
+	reconcile := func() {
+		t.Helper()
+		for k := range enqueued {
+			delete(enqueued, k)
+		}
+		c.reconcile()
+	}
+	for _, gw := range []config.Config{
+		gateway("available", "", "present"),
+		gateway("missing", "KEEP_LAST_GOOD", "present", "absent"),
+		gateway("plaintext", ""),
+	} {
+		if _, err := store.Create(gw); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reconcile()
+	if len(enqueued) != 2 {
+		t.Fatalf("expected the status of 2 gateways to be updated, got %v", enqueued)
+	}
+	if got := enqueued["available"]; got.Status != "True" || got.Type != ConditionType {
+		t.Fatalf("unexpected condition of available: %v", got)
+	}
+	got := enqueued["missing"]
+	if got.Status != "False" || got.Reason != ReasonMissingCredential ||
+		!strings.Contains(got.Message, "absent") || !strings.Contains(got.Message, "last good certificates") {
+		t.Fatalf("unexpected condition of missing: %v", got)
+	}
+
+	// unchanged conditions are not enqueued again
+	reconcile()
+	if len(enqueued) != 0 {
+		t.Fatalf("expected no status update, got %v", enqueued)
+	}
+
+	// the credential is created
+	creds.existing.Insert("ns/absent")
+	reconcile()
+	if got := enqueued["missing"]; len(enqueued) != 1 || got.Status != "True" {
+		t.Fatalf("expected the condition of missing to be available, got %v", enqueued)
+	}
+
+	// the gateway no longer references credentials
+	if _, err := store.Update(gateway("available", "")); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	if got, f := enqueued["available"]; len(enqueued) != 1 || !f || got != nil {
+		t.Fatalf("expected the condition of available to be removed, got %v", enqueued)
+	}
+
+	// deleted gateways are evicted
+	if err := store.Delete(gvk.Gateway, "missing", "ns", nil); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	if len(c.reported) != 0 {
+		t.Fatalf("expected no reported gateway, got %v", c.reported)
+	}
+}
+
+func TestSetCondition(t *testing.T) {
+	other := &v1alpha1.IstioCondition{Type: "Reconciled", Status: "True"}
+	missing := &v1alpha1.IstioCondition{
+		Type: ConditionType, Status: "False", Message: "a", LastTransitionTime: &types.Timestamp{Seconds: 1},
+	}
+	current := setCondition(&v1alpha1.IstioStatus{Conditions: []*v1alpha1.IstioCondition{other}}, missing)
+	if len(current.Conditions) != 2 || current.Conditions[1] != missing {
+		t.Fatalf("expected the condition to be added, got %v", current.Conditions)
+	}
+
+	// a condition with the same status keeps its transition time
+	updated := &v1alpha1.IstioCondition{Type: ConditionType, Status: "False", Message: "b"}
+	current = setCondition(current, updated)
+	if len(current.Conditions) != 2 || current.Conditions[1].Message != "b" ||
+		current.Conditions[1].LastTransitionTime.GetSeconds() != 1 {
+		t.Fatalf("expected the condition to be replaced, got %v", current.Conditions)
+	}
+
+	current = setCondition(current, nil)
+	if len(current.Conditions) != 1 || current.Conditions[0].Type != other.Type {
+		t.Fatalf("expected the condition to be removed, got %v", current.Conditions)
+	}
+	if got := setCondition(nil, missing); len(got.Conditions) != 1 {
+		t.Fatalf("expected the condition to be added to an empty status, got %v", got)
+	}
+}
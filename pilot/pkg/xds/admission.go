@@ -0,0 +1,88 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+
+	uatomic "go.uber.org/atomic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"istio.io/pkg/env"
+)
+
+var knativeEnv = env.RegisterStringVar("K_REVISION", "",
+	"KNative revision, set if running in knative").Get()
+
+// ConnectionAdmitter is consulted at the start of every xDS stream, both SotW and delta, before any
+// other processing is done. This allows platforms with special scaling or warmup semantics (for
+// example, serverless runtimes) to reject streams until they are ready to serve them.
+type ConnectionAdmitter interface {
+	// Admit returns nil if the stream may proceed. Otherwise, the returned error is sent to the
+	// client, which is expected to retry. Implementations should return gRPC status errors.
+	Admit(ctx context.Context) error
+}
+
+// ConnectionAdmitterFunc is an adapter to allow the use of ordinary functions as ConnectionAdmitters.
+type ConnectionAdmitterFunc func(ctx context.Context) error
+
+// Admit calls f(ctx).
+func (f ConnectionAdmitterFunc) Admit(ctx context.Context) error {
+	return f(ctx)
+}
+
+// KnativeAdmitter rejects the first stream the server receives.
+// How scaling works in knative is the first request is the "loading" request. During
+// loading request, concurrency=1. Once that request is done, concurrency is enabled.
+// However, the XDS stream is long lived, so the first request would block all others. As a
+// result, we should exit the first request immediately; clients will retry.
+type KnativeAdmitter struct {
+	firstRequest *uatomic.Bool
+}
+
+var _ ConnectionAdmitter = &KnativeAdmitter{}
+
+// NewKnativeAdmitter returns a KnativeAdmitter that will reject the first stream.
+func NewKnativeAdmitter() *KnativeAdmitter {
+	return &KnativeAdmitter{firstRequest: uatomic.NewBool(true)}
+}
+
+func (k *KnativeAdmitter) Admit(context.Context) error {
+	if k.firstRequest.CAS(true, false) {
+		return status.Error(codes.Unavailable, "server warmup not complete; try again")
+	}
+	return nil
+}
+
+// defaultConnectionAdmitters returns the admitters that should be enabled based on the environment
+// istiod is running in.
+func defaultConnectionAdmitters() []ConnectionAdmitter {
+	var admitters []ConnectionAdmitter
+	if knativeEnv != "" {
+		admitters = append(admitters, NewKnativeAdmitter())
+	}
+	return admitters
+}
+
+// admitConnection runs all registered ConnectionAdmitters, returning the first rejection.
+func (s *DiscoveryServer) admitConnection(ctx context.Context) error {
+	for _, a := range s.ConnectionAdmitters {
+		if err := a.Admit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
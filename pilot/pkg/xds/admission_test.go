@@ -0,0 +1,73 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/atomic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func TestKnativeAdmitter(t *testing.T) {
+	k := NewKnativeAdmitter()
+	if err := k.Admit(context.Background()); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected first stream to be rejected with Unavailable, got %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := k.Admit(context.Background()); err != nil {
+			t.Fatalf("expected stream %d to be admitted, got %v", i+1, err)
+		}
+	}
+}
+
+func TestConnectionAdmitters(t *testing.T) {
+	reject := atomic.NewBool(true)
+	s := NewFakeDiscoveryServer(t, FakeOptions{
+		DiscoveryServerModifier: func(s *DiscoveryServer) {
+			s.ConnectionAdmitters = append(s.ConnectionAdmitters, ConnectionAdmitterFunc(func(context.Context) error {
+				if reject.Load() {
+					return status.Error(codes.Unavailable, "not ready")
+				}
+				return nil
+			}))
+		},
+	})
+
+	t.Run("sotw", func(t *testing.T) {
+		reject.Store(true)
+		ads := s.ConnectADS().WithType(v3.ClusterType)
+		ads.Request(t, nil)
+		if err := ads.ExpectError(t); status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected Unavailable, got %v", err)
+		}
+		reject.Store(false)
+		s.ConnectADS().WithType(v3.ClusterType).RequestResponseAck(t, nil)
+	})
+	t.Run("delta", func(t *testing.T) {
+		reject.Store(true)
+		ads := s.ConnectDeltaADS().WithType(v3.ClusterType)
+		ads.Request(nil)
+		if err := ads.ExpectError(); status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected Unavailable, got %v", err)
+		}
+		reject.Store(false)
+		s.ConnectDeltaADS().WithType(v3.ClusterType).RequestResponseAck(nil)
+	})
+}
@@ -17,17 +17,19 @@ package xds
 import (
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
-	uatomic "go.uber.org/atomic"
+	"golang.org/x/time/rate"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
 	"istio.io/istio/pilot/pkg/controller/workloadentry"
+	"istio.io/istio/pilot/pkg/events"
 	"istio.io/istio/pilot/pkg/features"
 	istiogrpc "istio.io/istio/pilot/pkg/grpc"
 	"istio.io/istio/pilot/pkg/model"
@@ -37,7 +39,6 @@ import (
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config/schema/gvk"
-	"istio.io/pkg/env"
 	istiolog "istio.io/pkg/log"
 )
 
@@ -48,12 +49,6 @@ var (
 	connectionNumber = int64(0)
 )
 
-// Used only when running in KNative, to handle the load balancing behavior.
-var firstRequest = uatomic.NewBool(true)
-
-var knativeEnv = env.RegisterStringVar("K_REVISION", "",
-	"KNative revision, set if running in knative").Get()
-
 // DiscoveryStream is a server interface for XDS.
 type DiscoveryStream = discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer
 
@@ -68,6 +63,10 @@ type DeltaDiscoveryClient = discovery.AggregatedDiscoveryService_DeltaAggregated
 
 // Connection holds information about connected client.
 type Connection struct {
+	// lastRequest is the time of the last request received, in nanoseconds since the epoch. It is accessed
+	// atomically, and is the first field for 64-bit alignment.
+	lastRequest int64
+
 	// PeerAddr is the address of the client, from network layer.
 	PeerAddr string
 
@@ -97,8 +96,9 @@ type Connection struct {
 	// the proxy, should not be started until this channel is closed.
 	initialized chan struct{}
 
-	// stop can be used to end the connection manually via debug endpoints. Only to be used for testing.
-	stop chan struct{}
+	// stop can be used to end the connection manually via debug endpoints, or by the reaper of stale connections.
+	stop     chan struct{}
+	stopOnce sync.Once
 
 	// reqChan is used to receive discovery requests for this connection.
 	reqChan      chan *discovery.DiscoveryRequest
@@ -111,6 +111,45 @@ type Connection struct {
 	// (last push not ACKed). When we get an ACK from Envoy, if the type is populated here, we will trigger
 	// the push.
 	blockedPushes map[string]*model.PushRequest
+
+	// throttledPushes is a map of TypeUrl to push request. This is set when we attempt to push a type sooner than
+	// its minimum push interval after the previous push over delta xDS. When the interval has elapsed, the type is
+	// sent to throttledPushChan and the push is triggered.
+	throttledPushes   map[string]*model.PushRequest
+	throttledPushChan chan string
+
+	// nackCircuits is a map of TypeUrl to the NACK circuit breaker of the type, over delta xDS.
+	nackCircuits map[string]*nackCircuit
+
+	// heldInitialFetches is a map of TypeUrl to the first request of the type, while its response is held by the
+	// initial fetch ordering of delta xDS. When the deadline of its stage elapses, the type is sent to
+	// heldInitialFetchChan and the response is sent.
+	heldInitialFetches   map[string]*discovery.DeltaDiscoveryRequest
+	heldInitialFetchChan chan string
+
+	// deltaSubscribed and deltaUnsubscribed are maps of TypeUrl to the resource names subscribed and unsubscribed
+	// by the last delta request of the type, for debugging.
+	deltaSubscribed   map[string][]string
+	deltaUnsubscribed map[string][]string
+
+	// pushDebounce is the push debounce requested by the proxy, or 0. heldPush is the push held until it elapses.
+	pushDebounce time.Duration
+	heldPush     heldPush
+
+	// deltaExplicit is the set of TypeUrls of wildcard types the connection subscribed to by resource names, rather
+	// than by wildcard, over delta xDS.
+	deltaExplicit map[string]bool
+
+	// sendLimiter limits the bytes per second sent to the connection, or is nil if unlimited.
+	sendLimiter *rate.Limiter
+	// globalSendLimiter is the limiter of the bytes per second sent by the server, or nil if unlimited.
+	globalSendLimiter *rate.Limiter
+
+	// releasePushSlot releases the slot in the push throttle held by the push in progress, if any.
+	releasePushSlot func()
+
+	// versions is a map of TypeUrl to the version of the last response of the type, with MonotonicVersionScheme.
+	versions map[string]uint64
 }
 
 // Event represents a config or registry event that results in a push.
@@ -120,10 +159,15 @@ type Event struct {
 
 	// function to call once a push is finished. This must be called or future changes may be blocked.
 	done func()
+
+	// releaseSlot releases the slot of the push in the push throttle before it is finished. It is safe to call
+	// it more than once, and along with done.
+	releaseSlot func()
 }
 
 func newConnection(peerAddr string, stream DiscoveryStream) *Connection {
 	return &Connection{
+		lastRequest:   time.Now().UnixNano(),
 		pushChannel:   make(chan *Event),
 		initialized:   make(chan struct{}),
 		stop:          make(chan struct{}),
@@ -133,6 +177,7 @@ func newConnection(peerAddr string, stream DiscoveryStream) *Connection {
 		Connect:       time.Now(),
 		stream:        stream,
 		blockedPushes: map[string]*model.PushRequest{},
+		sendLimiter:   newSendLimiter(),
 	}
 }
 
@@ -151,6 +196,7 @@ func (s *DiscoveryServer) receive(con *Connection, identities []string) {
 	firstRequest := true
 	for {
 		req, err := con.stream.Recv()
+		atomic.StoreInt64(&con.lastRequest, time.Now().UnixNano())
 		if err != nil {
 			if istiogrpc.IsExpectedGRPCError(err) {
 				log.Infof("ADS: %q %s terminated %v", con.PeerAddr, con.ConID, err)
@@ -197,6 +243,7 @@ func (s *DiscoveryServer) processRequest(req *discovery.DiscoveryRequest, con *C
 	if !s.shouldProcessRequest(con.proxy, req) {
 		return nil
 	}
+	s.audit.request(con, auditStreamSotW, req.TypeUrl, req.ResponseNonce, req.VersionInfo, len(req.ResourceNames), 0, req.ErrorDetail)
 
 	// For now, don't let xDS piggyback debug requests start watchers.
 	if strings.HasPrefix(req.TypeUrl, v3.DebugType) {
@@ -240,6 +287,7 @@ func (s *DiscoveryServer) processRequest(req *discovery.DiscoveryRequest, con *C
 	// but before proxy's SidecarScope has been updated(s.updateProxy).
 	if con.proxy.SidecarScope != nil && con.proxy.SidecarScope.Version != push.PushVersion {
 		s.computeProxyState(con.proxy, request)
+		s.trackWildcardScope(con)
 	}
 	return s.pushXds(con, push, con.Watched(req.TypeUrl), request)
 }
@@ -250,13 +298,9 @@ func (s *DiscoveryServer) StreamAggregatedResources(stream discovery.AggregatedD
 }
 
 func (s *DiscoveryServer) Stream(stream DiscoveryStream) error {
-	if knativeEnv != "" && firstRequest.Load() {
-		// How scaling works in knative is the first request is the "loading" request. During
-		// loading request, concurrency=1. Once that request is done, concurrency is enabled.
-		// However, the XDS stream is long lived, so the first request would block all others. As a
-		// result, we should exit the first request immediately; clients will retry.
-		firstRequest.Store(false)
-		return status.Error(codes.Unavailable, "server warmup not complete; try again")
+	// Allow platform specific hooks, such as Knative warmup handling, to reject the stream.
+	if err := s.admitConnection(stream.Context()); err != nil {
+		return err
 	}
 	// Check if server is ready to accept clients and process new requests.
 	// Currently ready means caches have been synced and hence can build
@@ -290,6 +334,7 @@ func (s *DiscoveryServer) Stream(stream DiscoveryStream) error {
 	} else {
 		log.Debugf("Unauthenticated XDS: %s", peerAddr)
 	}
+	advertiseCompression(ctx)
 
 	// InitContext returns immediately if the context was already initialized.
 	if err = s.globalPushContext().InitContext(s.Env, nil, nil); err != nil {
@@ -299,6 +344,7 @@ func (s *DiscoveryServer) Stream(stream DiscoveryStream) error {
 		return status.Error(codes.Unavailable, "error reading config")
 	}
 	con := newConnection(peerAddr, stream)
+	con.globalSendLimiter = s.globalSendLimiter
 
 	// Do not call: defer close(con.pushChannel). The push channel will be garbage collected
 	// when the connection is no longer used. Closing the channel can cause subtle race conditions
@@ -328,13 +374,15 @@ func (s *DiscoveryServer) Stream(stream DiscoveryStream) error {
 				return <-con.errorChan
 			}
 		case pushEv := <-con.pushChannel:
+			con.releasePushSlot = pushEv.releaseSlot
 			err := s.pushConnection(con, pushEv)
+			con.releasePushSlot = nil
 			pushEv.done()
 			if err != nil {
 				return err
 			}
 		case <-con.stop:
-			return nil
+			return s.stoppedStatus()
 		}
 	}
 }
@@ -351,6 +399,7 @@ func (s *DiscoveryServer) shouldRespond(con *Connection, request *discovery.Disc
 		errCode := codes.Code(request.ErrorDetail.Code)
 		log.Warnf("ADS:%s: ACK ERROR %s %s:%s", stype, con.ConID, errCode.String(), request.ErrorDetail.GetMessage())
 		incrementXDSRejects(request.TypeUrl, con.proxy.ID, errCode.String())
+		s.emitConfigRejected(con, request.TypeUrl, request.ErrorDetail.GetMessage())
 		if s.StatusGen != nil {
 			s.StatusGen.OnNack(con.proxy, request)
 		}
@@ -393,7 +442,7 @@ func (s *DiscoveryServer) shouldRespond(con *Connection, request *discovery.Disc
 	if request.ResponseNonce != previousInfo.NonceSent {
 		log.Debugf("ADS:%s: REQ %s Expired nonce received %s, sent %s", stype,
 			con.ConID, request.ResponseNonce, previousInfo.NonceSent)
-		xdsExpiredNonce.With(typeTag.Value(v3.GetMetricType(request.TypeUrl))).Increment()
+		xdsExpiredNonce.With(typeValue(xdsExpiredNonce, request.TypeUrl)).Increment()
 		con.proxy.Lock()
 		con.proxy.WatchedResources[request.TypeUrl].NonceNacked = ""
 		con.proxy.Unlock()
@@ -439,7 +488,7 @@ func shouldUnsubscribe(request *discovery.DiscoveryRequest) bool {
 // resource names.
 func isWildcardTypeURL(typeURL string) bool {
 	switch typeURL {
-	case v3.SecretType, v3.EndpointType, v3.RouteType, v3.ExtensionConfigurationType:
+	case v3.SecretType, v3.EndpointType, v3.RouteType, v3.ExtensionConfigurationType, v3.VirtualHostType:
 		// By XDS spec, these are not wildcard
 		return false
 	case v3.ClusterType, v3.ListenerType:
@@ -485,12 +534,20 @@ func (s *DiscoveryServer) initConnection(node *core.Node, con *Connection, ident
 	con.ConID = connectionID(proxy.ID)
 	con.node = node
 	con.proxy = proxy
+	proxy.DeltaXDS = con.deltaStream != nil
+	con.pushDebounce = proxyPushDebounce(proxy)
 
 	// Authorize xds clients
 	if err := s.authorize(con, identities); err != nil {
 		return err
 	}
 
+	// The version is only checked once authorized, so that the version skew events and metrics report
+	// authenticated proxies.
+	if err := s.checkProxyVersion(con); err != nil {
+		return err
+	}
+
 	// Register the connection. this allows pushes to be triggered for the proxy. Note: the timing of
 	// this and initializeProxy important. While registering for pushes *after* initialization is complete seems like
 	// a better choice, it introduces a race condition; If we complete initialization of a new push
@@ -506,10 +563,13 @@ func (s *DiscoveryServer) initConnection(node *core.Node, con *Connection, ident
 		s.closeConnection(con)
 		return err
 	}
+	s.trackWildcardScope(con)
 
 	if s.StatusGen != nil {
 		s.StatusGen.OnConnect(con)
 	}
+	s.emitProxyEvent(events.ProxyConnected, false, con, "proxy %s connected to %s, version %s",
+		con.proxy.ID, s.instanceID, con.proxy.Metadata.IstioVersion)
 	return nil
 }
 
@@ -518,6 +578,8 @@ func (s *DiscoveryServer) closeConnection(con *Connection) {
 		return
 	}
 	s.removeCon(con.ConID)
+	con.heldPush.stop()
+	s.untrackDeltaWatches(con)
 	if s.StatusGen != nil {
 		s.StatusGen.OnDisconnect(con)
 	}
@@ -525,6 +587,7 @@ func (s *DiscoveryServer) closeConnection(con *Connection) {
 		s.StatusReporter.RegisterDisconnect(con.ConID, AllEventTypesList)
 	}
 	s.WorkloadEntryController.QueueUnregisterWorkload(con.proxy, con.Connect)
+	s.emitProxyEvent(events.ProxyDisconnected, false, con, "proxy %s disconnected from %s", con.proxy.ID, s.instanceID)
 }
 
 func connectionID(node string) string {
@@ -540,6 +603,9 @@ func (s *DiscoveryServer) initProxyMetadata(node *core.Node) (*model.Proxy, erro
 	if err != nil {
 		return nil, err
 	}
+	if err := validateNodeMetadata(node.Id, meta); err != nil {
+		return nil, err
+	}
 	proxy, err := model.ParseServiceNodeWithMetadata(node.Id, meta)
 	if err != nil {
 		return nil, err
@@ -550,6 +616,27 @@ func (s *DiscoveryServer) initProxyMetadata(node *core.Node) (*model.Proxy, erro
 	return proxy, nil
 }
 
+// validateNodeMetadata validates the metadata of a proxy according to PILOT_NODE_METADATA_VALIDATION.
+// An error is only returned in reject mode, for agents which report a schema version.
+func validateNodeMetadata(id string, meta *model.NodeMetadata) error {
+	if features.NodeMetadataValidation == "none" {
+		return nil
+	}
+	v := model.ValidateNodeMetadata(meta)
+	if len(v.Unknown) > 0 {
+		log.Debugf("ADS: node %s sent metadata keys outside of the schema: %v", id, v.Unknown)
+	}
+	err := v.Err()
+	if err == nil {
+		return nil
+	}
+	if features.NodeMetadataValidation == "reject" && !v.Legacy() {
+		return err
+	}
+	log.Warnf("ADS: node %s: %v", id, err)
+	return nil
+}
+
 // initializeProxy completes the initialization of a proxy. It is expected to be called only after
 // initProxyMetadata.
 func (s *DiscoveryServer) initializeProxy(node *core.Node, con *Connection) error {
@@ -559,6 +646,13 @@ func (s *DiscoveryServer) initializeProxy(node *core.Node, con *Connection) erro
 	if err := s.WorkloadEntryController.RegisterWorkload(proxy, con.Connect); err != nil {
 		return err
 	}
+	s.initializeProxyState(node, proxy)
+	return nil
+}
+
+// initializeProxyState computes the state of a proxy from the registry and the config, and associates its
+// generator, without registering it. It is expected to be called only after initProxyMetadata.
+func (s *DiscoveryServer) initializeProxyState(node *core.Node, proxy *model.Proxy) {
 	s.computeProxyState(proxy, nil)
 
 	// Get the locality from the proxy's service instances.
@@ -590,8 +684,6 @@ func (s *DiscoveryServer) initializeProxy(node *core.Node, con *Connection) erro
 	if proxy.Metadata.Generator != "" {
 		proxy.XdsResourceGenerator = s.Generators[proxy.Metadata.Generator]
 	}
-
-	return nil
 }
 
 func (s *DiscoveryServer) updateProxy(proxy *model.Proxy, request *model.PushRequest) {
@@ -686,12 +778,14 @@ func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 	if pushRequest.Full {
 		// Update Proxy with current information.
 		s.updateProxy(con.proxy, pushRequest)
+		s.trackWildcardScope(con)
 	}
 
-	if !s.ProxyNeedsPush(con.proxy, pushRequest) {
-		log.Debugf("Skipping push to %v, no updates required", con.ConID)
-		if pushRequest.Full {
-			// Only report for full versions, incremental pushes do not have a new version.
+	if needsPush, skippedBy := s.proxyNeedsPush(con.proxy, pushRequest); !needsPush {
+		log.Debugf("Skipping push to %v, skipped by the %s predicate", con.ConID, skippedBy)
+		if pushRequest.Full && skippedBy == defaultPushPredicate {
+			// Only report for full versions, incremental pushes do not have a new version. The pushes skipped by a
+			// PushPredicate are not reported, as the proxy did not get the version although it may be relevant to it.
 			reportAllEvents(s.StatusReporter, con.ConID, pushRequest.Push.LedgerVersion, nil)
 		}
 		return nil
@@ -714,7 +808,7 @@ func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 			// We are not synced, but we have been stuck for too long. We will trigger the push anyways to
 			// avoid any scenario where this may deadlock.
 			// This can possibly be removed in the future if we find this never causes issues
-			totalDelayedPushes.With(typeTag.Value(v3.GetMetricType(w.TypeUrl))).Increment()
+			totalDelayedPushes.With(typeValue(totalDelayedPushes, w.TypeUrl)).Increment()
 			log.Warnf("%s: QUEUE TIMEOUT for node:%s", v3.GetShortType(w.TypeUrl), con.proxy.ID)
 		}
 		if synced || timeout {
@@ -727,7 +821,7 @@ func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 			// we will wait until the last push is ACKed and trigger the push. See
 			// https://github.com/istio/istio/issues/25685 for details on the performance
 			// impact of sending pushes before Envoy ACKs.
-			totalDelayedPushes.With(typeTag.Value(v3.GetMetricType(w.TypeUrl))).Increment()
+			totalDelayedPushes.With(typeValue(totalDelayedPushes, w.TypeUrl)).Increment()
 			log.Debugf("%s: QUEUE for node:%s", v3.GetShortType(w.TypeUrl), con.proxy.ID)
 			con.proxy.Lock()
 			con.blockedPushes[w.TypeUrl] = con.blockedPushes[w.TypeUrl].CopyMerge(pushEv.pushRequest)
@@ -739,21 +833,22 @@ func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 		reportAllEvents(s.StatusReporter, con.ConID, pushRequest.Push.LedgerVersion, ignoreEvents)
 	}
 
-	proxiesConvergeDelay.Record(time.Since(pushRequest.Start).Seconds())
+	recordConvergeDelay(con.proxy, time.Since(pushRequest.Start))
 	return nil
 }
 
 // PushOrder defines the order that updates will be pushed in. Any types not listed here will be pushed in random
 // order after the types listed here
-var PushOrder = []string{v3.ClusterType, v3.EndpointType, v3.ListenerType, v3.RouteType, v3.SecretType}
+var PushOrder = []string{v3.ClusterType, v3.EndpointType, v3.ListenerType, v3.RouteType, v3.VirtualHostType, v3.SecretType}
 
 // KnownOrderedTypeUrls has typeUrls for which we know the order of push.
 var KnownOrderedTypeUrls = map[string]struct{}{
-	v3.ClusterType:  {},
-	v3.EndpointType: {},
-	v3.ListenerType: {},
-	v3.RouteType:    {},
-	v3.SecretType:   {},
+	v3.ClusterType:     {},
+	v3.EndpointType:    {},
+	v3.ListenerType:    {},
+	v3.RouteType:       {},
+	v3.VirtualHostType: {},
+	v3.SecretType:      {},
 }
 
 func reportAllEvents(s DistributionStatusCache, id, version string, ignored sets.Set) {
@@ -848,7 +943,7 @@ func (s *DiscoveryServer) startPush(req *model.PushRequest) {
 	}
 	req.Start = time.Now()
 	for _, p := range s.AllClients() {
-		s.pushQueue.Enqueue(p, req)
+		s.enqueuePush(p, req)
 	}
 }
 
@@ -856,6 +951,9 @@ func (s *DiscoveryServer) addCon(conID string, con *Connection) {
 	s.adsClientsMutex.Lock()
 	defer s.adsClientsMutex.Unlock()
 	s.adsClients[conID] = con
+	if con.pushDebounce > 0 {
+		s.proxyDebounces[conID] = con.pushDebounce
+	}
 	recordXDSClients(con.proxy.Metadata.IstioVersion, 1)
 }
 
@@ -868,23 +966,29 @@ func (s *DiscoveryServer) removeCon(conID string) {
 		totalXDSInternalErrors.Increment()
 	} else {
 		delete(s.adsClients, conID)
+		delete(s.proxyDebounces, conID)
 		recordXDSClients(con.proxy.Metadata.IstioVersion, -1)
 	}
 }
 
 // Send with timeout if configured.
 func (conn *Connection) send(res *discovery.DiscoveryResponse) error {
-	sendHandler := func() error {
-		start := time.Now()
-		defer func() { recordSendTime(time.Since(start)) }()
-		return conn.stream.Send(res)
+	sz := 0
+	for _, rc := range res.Resources {
+		sz += len(rc.Value)
 	}
-	err := istiogrpc.Send(conn.stream.Context(), sendHandler)
+	ctx, cancel := sendContext(conn.stream.Context())
+	defer cancel()
+	err := conn.waitToSend(ctx, res.TypeUrl, sz)
 	if err == nil {
-		sz := 0
-		for _, rc := range res.Resources {
-			sz += len(rc.Value)
+		sendHandler := func() error {
+			start := time.Now()
+			defer func() { recordSendTime(time.Since(start)) }()
+			return conn.stream.Send(res)
 		}
+		err = istiogrpc.Send(ctx, sendHandler)
+	}
+	if err == nil {
 		if res.Nonce != "" && !strings.HasPrefix(res.TypeUrl, v3.DebugType) {
 			conn.proxy.Lock()
 			if conn.proxy.WatchedResources[res.TypeUrl] == nil {
@@ -911,7 +1015,35 @@ func (conn *Connection) Synced(typeUrl string) (bool, bool) {
 	sent := conn.proxy.WatchedResources[typeUrl].NonceSent
 	nacked := conn.proxy.WatchedResources[typeUrl].NonceNacked != ""
 	sendTime := conn.proxy.WatchedResources[typeUrl].LastSent
-	return nacked || acked == sent, time.Since(sendTime) > features.FlowControlTimeout
+	return nacked || acked == sent, time.Since(sendTime) > flowControlTimeout(typeUrl)
+}
+
+// flowControlTimeout returns the max amount of time to delay a push of the type by.
+func flowControlTimeout(typeURL string) time.Duration {
+	if timeout, f := features.FlowControlTypeTimeouts[v3.GetMetricType(typeURL)]; f {
+		return timeout
+	}
+	return features.FlowControlTimeout
+}
+
+// pushDelay returns how long a push of the type must be delayed by, for the previous push of the type to be older
+// than its minimum push interval, and for the backoff of its NACK circuit breaker to elapse.
+func (conn *Connection) pushDelay(typeURL string) time.Duration {
+	conn.proxy.RLock()
+	defer conn.proxy.RUnlock()
+	delay := conn.nackCircuitDelay(typeURL)
+	interval := features.PushTypeMinIntervals[v3.GetMetricType(typeURL)]
+	if interval == 0 {
+		return delay
+	}
+	w := conn.proxy.WatchedResources[typeURL]
+	if w == nil || w.LastSent.IsZero() {
+		return delay
+	}
+	if d := interval - time.Since(w.LastSent); d > delay {
+		return d
+	}
+	return delay
 }
 
 // nolint
@@ -1004,5 +1136,7 @@ func orderWatchedResources(resources map[string]*model.WatchedResource) []*model
 }
 
 func (conn *Connection) Stop() {
-	close(conn.stop)
+	conn.stopOnce.Do(func() {
+		close(conn.stop)
+	})
 }
@@ -16,6 +16,7 @@ package xds_test
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -1020,3 +1021,30 @@ func TestEdsCache(t *testing.T) {
 	assertEndpoints(ads)
 	t.Logf("endpoints: %+v", ads.GetEndpoints())
 }
+
+func TestNodeMetadataValidation(t *testing.T) {
+	original := features.NodeMetadataValidation
+	t.Cleanup(func() {
+		features.NodeMetadataValidation = original
+	})
+	features.NodeMetadataValidation = "reject"
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+
+	invalid := model.NodeMetadata{
+		SchemaVersion:    model.NodeMetadataSchemaVersion,
+		InterceptionMode: "IPVS",
+	}
+	ads := s.ConnectADS().WithType(v3.ClusterType).WithMetadata(invalid)
+	ads.Request(t, nil)
+	if err := ads.ExpectError(t); err == nil || !strings.Contains(err.Error(), "INTERCEPTION_MODE") {
+		t.Fatalf("expected invalid INTERCEPTION_MODE error, got %v", err)
+	}
+
+	// Older agents without a schema version are only warned.
+	invalid.SchemaVersion = ""
+	s.ConnectADS().WithType(v3.ClusterType).WithMetadata(invalid).RequestResponseAck(t, nil)
+
+	features.NodeMetadataValidation = "warn"
+	invalid.SchemaVersion = model.NodeMetadataSchemaVersion
+	s.ConnectADS().WithType(v3.ClusterType).WithMetadata(invalid).RequestResponseAck(t, nil)
+}
@@ -0,0 +1,69 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"math"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+)
+
+// AffinityHint tells the proxies how loaded the istiod instance they are connected to is, so that they can
+// rebalance their connections across the replicas without external tooling.
+type AffinityHint struct {
+	// Load is the number of connected proxies divided by the capacity of the instance, rounded to two decimals.
+	Load float64
+	// Preferred is false when the instance is over capacity, and proxies should prefer another instance.
+	Preferred bool
+}
+
+type affinityControlPlane struct {
+	load float64
+	cp   *corev3.ControlPlane
+}
+
+// controlPlane returns the control plane identifier to set in the xDS responses. If PILOT_XDS_AFFINITY_HINT_CAPACITY
+// is set, the identifier carries the current affinity hint of the instance.
+func (s *DiscoveryServer) controlPlane() *corev3.ControlPlane {
+	if features.XDSAffinityHintCapacity <= 0 {
+		return ControlPlane()
+	}
+	load := affinityLoad(s.adsClientCount(), features.XDSAffinityHintCapacity)
+	if cached, ok := s.affinityControlPlane.Load().(affinityControlPlane); ok && cached.load == load {
+		return cached.cp
+	}
+	instance := controlPlaneInstance
+	instance.AffinityHint = &AffinityHint{
+		Load:      load,
+		Preferred: load <= 1,
+	}
+	b, err := json.Marshal(instance)
+	if err != nil {
+		log.Warnf("XDS: Could not serialize control plane id with affinity hint: %v", err)
+		return ControlPlane()
+	}
+	cp := &corev3.ControlPlane{Identifier: string(b)}
+	s.affinityControlPlane.Store(affinityControlPlane{load: load, cp: cp})
+	return cp
+}
+
+// affinityLoad returns the load score for the number of connections, rounded to two decimals so that the
+// identifier, and the responses, do not change on every connection.
+func affinityLoad(connections, capacity int) float64 {
+	return math.Round(float64(connections)/float64(capacity)*100) / 100
+}
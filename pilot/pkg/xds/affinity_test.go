@@ -0,0 +1,75 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func TestAffinityHint(t *testing.T) {
+	hint := func(t *testing.T, a *AdsTest) *AffinityHint {
+		t.Helper()
+		resp := a.RequestResponseAck(t, nil)
+		instance := IstioControlPlaneInstance{}
+		if err := json.Unmarshal([]byte(resp.ControlPlane.GetIdentifier()), &instance); err != nil {
+			t.Fatal(err)
+		}
+		return instance.AffinityHint
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		s := NewFakeDiscoveryServer(t, FakeOptions{})
+		if h := hint(t, s.ConnectADS().WithType(v3.ClusterType)); h != nil {
+			t.Fatalf("expected no affinity hint, got %+v", h)
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		old := features.XDSAffinityHintCapacity
+		features.XDSAffinityHintCapacity = 1
+		t.Cleanup(func() { features.XDSAffinityHintCapacity = old })
+
+		s := NewFakeDiscoveryServer(t, FakeOptions{})
+		h := hint(t, s.ConnectADS().WithType(v3.ClusterType))
+		if h == nil || h.Load != 1 || !h.Preferred {
+			t.Fatalf("expected the instance at capacity to be preferred, got %+v", h)
+		}
+		h = hint(t, s.ConnectADS().WithType(v3.ClusterType))
+		if h == nil || h.Load != 2 || h.Preferred {
+			t.Fatalf("expected the instance over capacity to not be preferred, got %+v", h)
+		}
+	})
+}
+
+func TestAffinityLoad(t *testing.T) {
+	cases := []struct {
+		connections, capacity int
+		want                  float64
+	}{
+		{0, 100, 0},
+		{50, 100, 0.5},
+		{1, 3, 0.33},
+		{250, 100, 2.5},
+	}
+	for _, tt := range cases {
+		if got := affinityLoad(tt.connections, tt.capacity); got != tt.want {
+			t.Errorf("affinityLoad(%d, %d) = %v, want %v", tt.connections, tt.capacity, got, tt.want)
+		}
+	}
+}
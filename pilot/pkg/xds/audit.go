@@ -0,0 +1,193 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/status"
+)
+
+const (
+	auditStreamSotW  = "sotw"
+	auditStreamDelta = "delta"
+)
+
+// AuditRecord is the record of an xDS request or response written to the audit log.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// Event is "request" or "response".
+	Event string `json:"event"`
+	// Stream is "sotw" or "delta".
+	Stream       string `json:"stream"`
+	ConnectionID string `json:"connectionID"`
+	ProxyID      string `json:"proxyID"`
+	// Identity is the verified SPIFFE identity of the proxy, if any.
+	Identity    string `json:"identity,omitempty"`
+	PeerAddress string `json:"peerAddress,omitempty"`
+	TypeURL     string `json:"typeUrl"`
+	// Nonce is the nonce of the response, or the nonce the request responds to.
+	Nonce   string `json:"nonce,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Resources is the number of resources sent, or of resource names requested or subscribed.
+	Resources int `json:"resources"`
+	// Removed is the number of resources removed, or of resource names unsubscribed.
+	Removed int `json:"removed,omitempty"`
+	// Error is the error detail of a NACK, or the error sending a response.
+	Error string `json:"error,omitempty"`
+	// GenerationLatency is the time spent generating the response, in milliseconds.
+	GenerationLatency float64 `json:"generationLatencyMs,omitempty"`
+}
+
+// auditLogQueueSize is the number of records waiting to be written before new records are dropped.
+const auditLogQueueSize = 10000
+
+// auditLog writes an AuditRecord as a JSON line for each xDS request and response. Records are queued, and
+// written by run, so that the xDS streams never wait for the sink. A nil auditLog is disabled.
+type auditLog struct {
+	out     io.Writer
+	records chan AuditRecord
+}
+
+// InitAuditLog enables the audit log, writing to the sink, which is "stdout" or the path of a file to append to.
+// An empty sink leaves the audit log disabled.
+func (s *DiscoveryServer) InitAuditLog(sink string) error {
+	audit, err := newAuditLog(sink)
+	if err != nil {
+		return err
+	}
+	s.audit = audit
+	return nil
+}
+
+// newAuditLog creates an audit log writing to the sink, which is "stdout" or the path of a file to append to.
+// An empty sink disables the audit log.
+func newAuditLog(sink string) (*auditLog, error) {
+	switch sink {
+	case "":
+		return nil, nil
+	case "stdout":
+		return newAuditLogWriter(os.Stdout), nil
+	}
+	f, err := os.OpenFile(sink, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open xDS audit log %q: %v", sink, err)
+	}
+	return newAuditLogWriter(f), nil
+}
+
+func newAuditLogWriter(out io.Writer) *auditLog {
+	return &auditLog{out: out, records: make(chan AuditRecord, auditLogQueueSize)}
+}
+
+// run writes the queued records until stop is closed, then writes the remaining ones and closes the sink.
+func (a *auditLog) run(stop <-chan struct{}) {
+	for {
+		select {
+		case r := <-a.records:
+			a.write(r)
+		case <-stop:
+			for {
+				select {
+				case r := <-a.records:
+					a.write(r)
+				default:
+					if c, ok := a.out.(io.Closer); ok && a.out != os.Stdout {
+						_ = c.Close()
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// enqueue queues the record to be written, dropping it if the queue is full.
+func (a *auditLog) enqueue(r AuditRecord) {
+	select {
+	case a.records <- r:
+	default:
+		auditRecordsDropped.Increment()
+	}
+}
+
+func (a *auditLog) write(r AuditRecord) {
+	line, err := json.Marshal(r)
+	if err != nil {
+		log.Warnf("failed to marshal xDS audit record: %v", err)
+		return
+	}
+	line = append(line, '\n')
+	if _, err := a.out.Write(line); err != nil {
+		log.Warnf("failed to write xDS audit record: %v", err)
+	}
+}
+
+func auditRecordOf(con *Connection, event, stream, typeURL string) AuditRecord {
+	r := AuditRecord{
+		Time:         time.Now(),
+		Event:        event,
+		Stream:       stream,
+		ConnectionID: con.ConID,
+		PeerAddress:  con.PeerAddr,
+		TypeURL:      typeURL,
+	}
+	if con.proxy != nil {
+		r.ProxyID = con.proxy.ID
+		if con.proxy.VerifiedIdentity != nil {
+			r.Identity = con.proxy.VerifiedIdentity.String()
+		}
+	}
+	return r
+}
+
+// request records a request of the proxy.
+func (a *auditLog) request(con *Connection, stream, typeURL, nonce, version string, subscribed, unsubscribed int,
+	errorDetail *status.Status) {
+	if a == nil {
+		return
+	}
+	r := auditRecordOf(con, "request", stream, typeURL)
+	r.Nonce = nonce
+	r.Version = version
+	r.Resources = subscribed
+	r.Removed = unsubscribed
+	if errorDetail != nil {
+		r.Error = errorDetail.GetMessage()
+	}
+	a.enqueue(r)
+}
+
+// response records a response sent to the proxy, or failed to be sent.
+func (a *auditLog) response(con *Connection, stream, typeURL, nonce, version string, resources, removed int,
+	generation time.Duration, err error) {
+	if a == nil {
+		return
+	}
+	r := auditRecordOf(con, "response", stream, typeURL)
+	r.Nonce = nonce
+	r.Version = version
+	r.Resources = resources
+	r.Removed = removed
+	r.GenerationLatency = float64(generation) / float64(time.Millisecond)
+	if err != nil {
+		r.Error = err.Error()
+	}
+	a.enqueue(r)
+}
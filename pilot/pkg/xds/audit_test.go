@@ -0,0 +1,131 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) records(t *testing.T) []AuditRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []AuditRecord
+	scanner := bufio.NewScanner(bytes.NewReader(b.buf.Bytes()))
+	for scanner.Scan() {
+		r := AuditRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("invalid audit record %q: %v", scanner.Text(), err)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func expectAuditRecords(t *testing.T, b *syncBuffer, stream string) {
+	t.Helper()
+	retry.UntilSuccessOrFail(t, func() error {
+		var request, response *AuditRecord
+		for _, r := range b.records(t) {
+			r := r
+			if r.Stream != stream || r.TypeURL != v3.ClusterType {
+				continue
+			}
+			switch r.Event {
+			case "request":
+				request = &r
+			case "response":
+				response = &r
+			}
+		}
+		if request == nil || response == nil {
+			return fmt.Errorf("expected a request and a response, got %+v", b.records(t))
+		}
+		if request.ProxyID == "" || request.ConnectionID == "" {
+			return fmt.Errorf("expected the proxy of the request, got %+v", request)
+		}
+		if response.Nonce == "" || response.Resources == 0 || response.GenerationLatency <= 0 {
+			return fmt.Errorf("expected the nonce, resources and latency of the response, got %+v", response)
+		}
+		return nil
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	out := &syncBuffer{}
+	s.Discovery.audit = newAuditLogWriter(out)
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	go s.Discovery.audit.run(stop)
+
+	t.Run("sotw", func(t *testing.T) {
+		s.ConnectADS().WithType(v3.ClusterType).RequestResponseAck(t, nil)
+		expectAuditRecords(t, out, auditStreamSotW)
+	})
+	t.Run("delta", func(t *testing.T) {
+		s.ConnectDeltaADS().WithType(v3.ClusterType).RequestResponseAck(&discovery.DeltaDiscoveryRequest{})
+		expectAuditRecords(t, out, auditStreamDelta)
+	})
+}
+
+func TestNewAuditLog(t *testing.T) {
+	if a, err := newAuditLog(""); a != nil || err != nil {
+		t.Fatalf("expected a disabled audit log, got %v, %v", a, err)
+	}
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a, err := newAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.enqueue(AuditRecord{Event: "request", TypeURL: v3.ClusterType})
+	// Stopping the audit log writes the queued records.
+	stop := make(chan struct{})
+	close(stop)
+	a.run(stop)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := AuditRecord{}
+	if err := json.Unmarshal(data, &r); err != nil || r.TypeURL != v3.ClusterType {
+		t.Fatalf("unexpected audit log %q: %v", data, err)
+	}
+	if _, err := newAuditLog(filepath.Join(t.TempDir(), "missing", "audit.log")); err == nil {
+		t.Fatal("expected an error opening the audit log in a missing directory")
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"go.uber.org/atomic"
+)
+
+// overflowLabelValue replaces the label values beyond the cardinality limit.
+const overflowLabelValue = "other"
+
+// topLabelValues is the number of most recorded values listed for each label.
+const topLabelValues = 10
+
+// cardinalityGuard bounds the number of series of the istiod metrics, which would otherwise grow with
+// values such as proxy IDs, proxy versions or custom type URLs.
+type cardinalityGuard struct {
+	// limit is the maximum number of distinct values of a label of a metric, 0 for no limit.
+	limit int
+	// maxLength is the maximum length of a label value, 0 for no limit.
+	maxLength int
+
+	// labels holds the *labelCardinality of each labelKey. Each label has its own lock, so that the pushes
+	// recording different metrics do not contend.
+	labels sync.Map
+}
+
+type labelKey struct {
+	metric string
+	label  string
+}
+
+type labelCardinality struct {
+	// mu guards values. The counters of the known values are incremented under the read lock.
+	mu sync.RWMutex
+	// values are the number of times each accepted value was recorded.
+	values    map[string]*atomic.Int64
+	overflow  atomic.Int64
+	truncated atomic.Int64
+}
+
+// CardinalityContributor describes the values recorded for a label of a metric.
+type CardinalityContributor struct {
+	Metric string `json:"metric"`
+	Label  string `json:"label"`
+	// Values is the number of distinct values of the label.
+	Values int `json:"values"`
+	// Overflow is the number of times a value beyond the limit was recorded as "other".
+	Overflow int64 `json:"overflow,omitempty"`
+	// Truncated is the number of times a value was truncated.
+	Truncated int64 `json:"truncated,omitempty"`
+	// TopValues are the most recorded values.
+	TopValues []string `json:"topValues"`
+}
+
+func newCardinalityGuard(limit, maxLength int) *cardinalityGuard {
+	return &cardinalityGuard{
+		limit:     limit,
+		maxLength: maxLength,
+	}
+}
+
+// guard returns the value to record for the label of the metric. Long values are truncated, and new values
+// beyond the limit are replaced by "other". Each is logged the first time it happens for a label.
+func (g *cardinalityGuard) guard(metric, label, value string) string {
+	k := labelKey{metric: metric, label: label}
+	v, f := g.labels.Load(k)
+	if !f {
+		v, _ = g.labels.LoadOrStore(k, &labelCardinality{values: map[string]*atomic.Int64{}})
+	}
+	lc := v.(*labelCardinality)
+	if g.maxLength > 0 && len(value) > g.maxLength {
+		if lc.truncated.Inc() == 1 {
+			log.Warnf("truncating the values of label %s of metric %s longer than %d characters, such as %q",
+				label, metric, g.maxLength, value)
+		}
+		value = value[:g.maxLength]
+	}
+
+	lc.mu.RLock()
+	count, f := lc.values[value]
+	lc.mu.RUnlock()
+	if f {
+		count.Inc()
+		return value
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if count, f = lc.values[value]; !f {
+		if g.limit > 0 && len(lc.values) >= g.limit {
+			if lc.overflow.Inc() == 1 {
+				log.Warnf("label %s of metric %s has more than %d values, recording new values such as %q as %q",
+					label, metric, g.limit, value, overflowLabelValue)
+			}
+			return overflowLabelValue
+		}
+		count = atomic.NewInt64(0)
+		lc.values[value] = count
+	}
+	count.Inc()
+	return value
+}
+
+// top returns all the labels, the ones with the most distinct values first.
+func (g *cardinalityGuard) top() []CardinalityContributor {
+	out := []CardinalityContributor{}
+	g.labels.Range(func(key, v interface{}) bool {
+		k, lc := key.(labelKey), v.(*labelCardinality)
+		lc.mu.RLock()
+		counts := make(map[string]int64, len(lc.values))
+		for value, count := range lc.values {
+			counts[value] = count.Load()
+		}
+		lc.mu.RUnlock()
+		values := make([]string, 0, len(counts))
+		for value := range counts {
+			values = append(values, value)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if counts[values[i]] != counts[values[j]] {
+				return counts[values[i]] > counts[values[j]]
+			}
+			return values[i] < values[j]
+		})
+		if len(values) > topLabelValues {
+			values = values[:topLabelValues]
+		}
+		out = append(out, CardinalityContributor{
+			Metric:    k.metric,
+			Label:     k.label,
+			Values:    len(counts),
+			Overflow:  lc.overflow.Load(),
+			Truncated: lc.truncated.Load(),
+			TopValues: values,
+		})
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Values != out[j].Values {
+			return out[i].Values > out[j].Values
+		}
+		if out[i].Metric != out[j].Metric {
+			return out[i].Metric < out[j].Metric
+		}
+		return out[i].Label < out[j].Label
+	})
+	return out
+}
+
+// metricCardinalityz lists the labels of the istiod metrics with the most distinct values, paginated with
+// the offset and limit query parameters.
+// It is mapped to /debug/metric_cardinality
+func (s *DiscoveryServer) metricCardinalityz(w http.ResponseWriter, req *http.Request) {
+	page, err := parseDebugPage(req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error() + "\n"))
+		return
+	}
+	top := metricCardinality.top()
+	start, end := page.bounds(len(top))
+	writeJSON(w, top[start:end])
+}
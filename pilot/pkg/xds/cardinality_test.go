@@ -0,0 +1,171 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.opencensus.io/tag"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/pkg/monitoring"
+)
+
+func TestCardinalityGuard(t *testing.T) {
+	g := newCardinalityGuard(2, 5)
+	for _, tt := range []struct {
+		metric string
+		value  string
+		want   string
+	}{
+		{"pilot_xds", "1.13.0", "1.13."},
+		{"pilot_xds", "1.12", "1.12"},
+		{"pilot_xds", "1.12", "1.12"},
+		{"pilot_xds", "1.11", overflowLabelValue},
+		// Truncated values are matched against the known values.
+		{"pilot_xds", "1.13.1", "1.13."},
+		// The limit applies to each metric.
+		{"pilot_xds_pushes", "1.11", "1.11"},
+	} {
+		if got := g.guard(tt.metric, "version", tt.value); got != tt.want {
+			t.Fatalf("guard(%s, %s) = %s, want %s", tt.metric, tt.value, got, tt.want)
+		}
+	}
+
+	want := []CardinalityContributor{
+		{Metric: "pilot_xds", Label: "version", Values: 2, Overflow: 1, Truncated: 2, TopValues: []string{"1.12", "1.13."}},
+		{Metric: "pilot_xds_pushes", Label: "version", Values: 1, TopValues: []string{"1.11"}},
+	}
+	if got := g.top(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCardinalityGuardUnlimited(t *testing.T) {
+	g := newCardinalityGuard(0, 0)
+	long := string(make([]byte, 1000))
+	if got := g.guard("pilot_xds", "version", long); got != long {
+		t.Fatal("expected the value to not be truncated")
+	}
+	for _, v := range []string{"a", "b", "c"} {
+		if got := g.guard("pilot_xds", "version", v); got != v {
+			t.Fatalf("expected %s to not be replaced, got %s", v, got)
+		}
+	}
+}
+
+func TestCardinalityGuardConcurrent(t *testing.T) {
+	g := newCardinalityGuard(2, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				g.guard("pilot_xds", "version", fmt.Sprint(j%3))
+				g.guard("pilot_xds_pushes", "type", fmt.Sprint(i))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, c := range g.top() {
+		if c.Values != 2 {
+			t.Fatalf("expected 2 values of %s, got %+v", c.Metric, c)
+		}
+	}
+	var recorded int64
+	v, _ := g.labels.Load(labelKey{metric: "pilot_xds", label: "version"})
+	lc := v.(*labelCardinality)
+	for _, count := range lc.values {
+		recorded += count.Load()
+	}
+	if total := recorded + lc.overflow.Load(); total != 800 {
+		t.Fatalf("expected 800 recorded values, got %d", total)
+	}
+}
+
+func TestMetricCardinalityz(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	incrementXDSRejects("type.googleapis.com/envoy.config.listener.v3.Listener", "sidecar~1.1.1.1~a.b~b.svc.cluster.local", "13")
+
+	req, err := http.NewRequest("GET", "/debug/metric_cardinality?limit=1000", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(s.Discovery.metricCardinalityz).ServeHTTP(rr, req)
+	var top []CardinalityContributor
+	if err := json.Unmarshal(rr.Body.Bytes(), &top); err != nil {
+		t.Fatalf("invalid json %v: %s", err, rr.Body.String())
+	}
+	found := false
+	for _, c := range top {
+		if c.Metric == "pilot_xds_lds_reject" && c.Label == "node" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected the node label of pilot_xds_lds_reject, got %+v", top)
+	}
+
+	req, err = http.NewRequest("GET", "/debug/metric_cardinality?limit=x", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(s.Discovery.metricCardinalityz).ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid limit to be rejected, got %d", rr.Code)
+	}
+}
+
+func TestWithProxyValues(t *testing.T) {
+	proxy := &model.Proxy{Metadata: &model.NodeMetadata{IstioRevision: "canary", IstioVersion: "1.14.0"}}
+	typ := typeTag.Value("cds")
+	if got := withProxyValues(pushes, proxy, typ); len(got) != 1 {
+		t.Fatalf("expected no proxy labels by default, got %d labels", len(got))
+	}
+
+	features.EnableXDSPushMetricsProxyLabels = true
+	defer func() { features.EnableXDSPushMetricsProxyLabels = false }()
+	if got := withProxyLabels(typeTag); !reflect.DeepEqual(got, []monitoring.Label{typeTag, revisionTag, proxyVersionTag}) {
+		t.Fatalf("unexpected labels %v", got)
+	}
+	var mutators []tag.Mutator
+	for _, v := range withProxyValues(pushes, proxy, typ) {
+		mutators = append(mutators, tag.Mutator(v))
+	}
+	ctx, err := tag.New(context.Background(), mutators...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for l, want := range map[monitoring.Label]string{typeTag: "cds", revisionTag: "canary", proxyVersionTag: "1.14.0"} {
+		if got, _ := tag.FromContext(ctx).Value(tag.Key(l)); got != want {
+			t.Fatalf("label %s: got %q, want %q", tag.Key(l).Name(), got, want)
+		}
+	}
+	if got := withProxyValues(pushes, &model.Proxy{}, typ); len(got) != 1 {
+		t.Fatalf("expected no proxy labels without metadata, got %d labels", len(got))
+	}
+}
@@ -80,7 +80,7 @@ func (c CdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *m
 	return clusters, logs, nil
 }
 
-// GenerateDeltas for CDS currently only builds deltas when services change. todo implement changes for DestinationRule, etc
+// GenerateDeltas for CDS builds deltas when only services or destination rules change, and falls back to all clusters otherwise.
 func (c CdsGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
 	w *model.WatchedResource) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
 	if !cdsNeedsPush(updates, proxy) {
@@ -89,3 +89,13 @@ func (c CdsGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext
 	updatedClusters, removedClusters, logs, usedDelta := c.Server.ConfigGenerator.BuildDeltaClusters(proxy, updates, w)
 	return updatedClusters, removedClusters, logs, usedDelta, nil
 }
+
+// GenerateWatched builds the clusters of the services watched by a proxy subscribing to clusters by name.
+func (c CdsGenerator) GenerateWatched(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
+	w *model.WatchedResource) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
+	if !cdsNeedsPush(updates, proxy) {
+		return nil, nil, model.DefaultXdsLogDetails, false, nil
+	}
+	updatedClusters, removedClusters, logs, usedDelta := c.Server.ConfigGenerator.BuildWatchedClusters(proxy, updates, w)
+	return updatedClusters, removedClusters, logs, usedDelta, nil
+}
@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"strings"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+
+	istiogrpc "istio.io/istio/pilot/pkg/grpc"
+)
+
+// advertiseCompression advertises the supported compressions of xDS payloads in the headers of the stream, sent
+// with its first response, so that the agents only compress their requests when Istiod supports it.
+func advertiseCompression(ctx context.Context) {
+	md := metadata.Pairs(istiogrpc.CompressionHeader, strings.Join(istiogrpc.SupportedCompressions, ","))
+	if err := grpc.SetHeader(ctx, md); err != nil {
+		log.Debugf("failed to advertise the xDS compressions: %v", err)
+	}
+}
+
+// compressionStatsHandler records the size of the xDS responses before and after the compression negotiated by
+// the proxy, as reported by gRPC.
+type compressionStatsHandler struct{}
+
+// NewCompressionStatsHandler returns a gRPC stats handler recording the size of the xDS responses, compressed
+// and uncompressed, to be installed on the gRPC servers serving xDS.
+func NewCompressionStatsHandler() stats.Handler {
+	return compressionStatsHandler{}
+}
+
+type compressionKey struct{}
+
+// rpcCompression holds the compression of a stream, known from the headers of the client.
+type rpcCompression struct {
+	name string
+}
+
+func (compressionStatsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, compressionKey{}, &rpcCompression{})
+}
+
+func (compressionStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	c, ok := ctx.Value(compressionKey{}).(*rpcCompression)
+	if !ok {
+		return
+	}
+	switch s := s.(type) {
+	case *stats.InHeader:
+		// The headers are received before the stream is handed to the server, and so before any response.
+		c.name = s.Compression
+	case *stats.OutPayload:
+		var typeURL string
+		switch resp := s.Payload.(type) {
+		case *discovery.DiscoveryResponse:
+			typeURL = resp.TypeUrl
+		case *discovery.DeltaDiscoveryResponse:
+			typeURL = resp.TypeUrl
+		default:
+			return
+		}
+		recordResponseSize(typeURL, c.name, s.Length, s.WireLength)
+	}
+}
+
+func (compressionStatsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (compressionStatsHandler) HandleConn(context.Context, stats.ConnStats) {}
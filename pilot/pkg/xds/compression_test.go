@@ -0,0 +1,73 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	istiogrpc "istio.io/istio/pilot/pkg/grpc"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func TestCompression(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	for _, compression := range []string{istiogrpc.CompressionGzip, istiogrpc.CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			before := responseBytesFor("pilot_xds_response_wire_bytes", compression)
+			conn, err := grpc.Dial("buffcon",
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithBlock(),
+				grpc.WithDefaultCallOptions(grpc.UseCompressor(compression)),
+				grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+					return s.BufListener.Dial()
+				}))
+			if err != nil {
+				t.Fatalf("failed to connect: %v", err)
+			}
+			res := NewAdsTest(t, conn).WithType(v3.ClusterType).RequestResponseAck(t, nil)
+			if len(res.Resources) == 0 {
+				t.Fatalf("expected clusters in the compressed response")
+			}
+			if after := responseBytesFor("pilot_xds_response_wire_bytes", compression); after <= before {
+				t.Fatalf("expected compressed response size recorded for %s, got %v then %v", compression, before, after)
+			}
+			if got := responseBytesFor("pilot_xds_response_bytes", compression); got == 0 {
+				t.Fatalf("expected uncompressed response size recorded for %s", compression)
+			}
+		})
+	}
+}
+
+func responseBytesFor(metric, compression string) float64 {
+	rows, err := view.RetrieveData(metric)
+	if err != nil {
+		return 0
+	}
+	total := 0.0
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key.Name() == "compression" && tag.Value == compression {
+				total += row.Data.(*view.SumData).Value
+			}
+		}
+	}
+	return total
+}
@@ -35,6 +35,7 @@ import (
 
 	"istio.io/istio/pilot/pkg/config/kube/crd"
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/leaderelection"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/serviceregistry"
@@ -42,6 +43,7 @@ import (
 	"istio.io/istio/pilot/pkg/serviceregistry/memory"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/collection"
 	"istio.io/istio/pkg/network"
@@ -120,6 +122,17 @@ type SyncStatus struct {
 	RouteAcked    string `json:"route_acked,omitempty"`
 	EndpointSent  string `json:"endpoint_sent,omitempty"`
 	EndpointAcked string `json:"endpoint_acked,omitempty"`
+	// Conditions are the abnormal conditions of the connection of the proxy.
+	Conditions []ProxyCondition `json:"conditions,omitempty"`
+}
+
+// ProxyCondition is a condition of the connection of a proxy, reported in its sync status.
+type ProxyCondition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+	LastTransitionTime time.Time `json:"lastTransitionTime,omitempty"`
 }
 
 // SyncedVersions shows what resourceVersion of a given resource has been acked by Envoy.
@@ -189,22 +202,41 @@ func (s *DiscoveryServer) AddDebugHandlers(mux, internalMux *http.ServeMux, enab
 	s.addDebugHandler(mux, internalMux, "/debug/cachez?sizes=true", "Info about the size of the internal XDS caches", s.cachez)
 	s.addDebugHandler(mux, internalMux, "/debug/cachez?clear=true", "Clear the XDS caches", s.cachez)
 	s.addDebugHandler(mux, internalMux, "/debug/configz", "Debug support for config", s.configz)
+	s.addDebugHandler(mux, internalMux, "/debug/configz?offset=0&limit=100", "Paginated debug support for config", s.configz)
 	s.addDebugHandler(mux, internalMux, "/debug/sidecarz", "Debug sidecar scope for a proxy", s.sidecarz)
+	s.addDebugHandler(mux, internalMux, "/debug/affected_proxies", "Connected proxies affected by a config, by kind, name and namespace",
+		s.affectedProxiesz)
 	s.addDebugHandler(mux, internalMux, "/debug/resourcesz", "Debug support for watched resources", s.resourcez)
 	s.addDebugHandler(mux, internalMux, "/debug/instancesz", "Debug support for service instances", s.instancesz)
 
 	s.addDebugHandler(mux, internalMux, "/debug/authorizationz", "Internal authorization policies", s.authorizationz)
 	s.addDebugHandler(mux, internalMux, "/debug/telemetryz", "Debug Telemetry configuration", s.telemetryz)
 	s.addDebugHandler(mux, internalMux, "/debug/config_dump", "ConfigDump in the form of the Envoy admin config dump API for passed in proxyID", s.ConfigDump)
+	s.addDebugHandler(mux, internalMux, "/debug/generate",
+		"Resources of a type generated for the passed in proxyID, or for the POSTed node, without sending them", s.generatez)
 	s.addDebugHandler(mux, internalMux, "/debug/push_status", "Last PushContext Details", s.pushStatusHandler)
 	s.addDebugHandler(mux, internalMux, "/debug/pushcontext", "Debug support for current push context", s.pushContextHandler)
+	s.addDebugHandler(mux, internalMux, "/debug/pushcontext_size", "Estimated memory retained by the indexes of the current push context",
+		s.pushContextSizez)
 	s.addDebugHandler(mux, internalMux, "/debug/connections", "Info about the connected XDS clients", s.connectionsHandler)
+	s.addDebugHandler(mux, internalMux, "/debug/connection_snapshot", "Export a snapshot of the state of a connection, for replay", s.connectionSnapshotz)
+	s.addDebugHandler(mux, internalMux, "/debug/deltaz", "State of the delta XDS connections: subscriptions, nonces and pending pushes", s.deltaz)
 
 	s.addDebugHandler(mux, internalMux, "/debug/inject", "Active inject template", s.injectTemplateHandler(webhook))
 	s.addDebugHandler(mux, internalMux, "/debug/mesh", "Active mesh config", s.meshHandler)
 	s.addDebugHandler(mux, internalMux, "/debug/clusterz", "List remote clusters where istiod reads endpoints", s.clusterz)
 	s.addDebugHandler(mux, internalMux, "/debug/networkz", "List cross-network gateways", s.networkz)
+	s.addDebugHandler(mux, internalMux, "/debug/network_detection", "Networks detected for the workloads of each node, by cluster",
+		s.networkDetectionz)
+	s.addDebugHandler(mux, internalMux, "/debug/leaderz", "List leader elections and their current leaders", s.leaderz)
 	s.addDebugHandler(mux, internalMux, "/debug/mcsz", "List information about Kubernetes MCS services", s.mcsz)
+	s.addDebugHandler(mux, internalMux, "/debug/tls_policy", "List resources conflicting with the TLS policy", s.tlsPolicyz)
+	s.addDebugHandler(mux, internalMux, "/debug/mtls_compatibility", "Workloads that can safely move to STRICT mTLS", s.mtlsCompatibilityz)
+	s.addDebugHandler(mux, internalMux, "/debug/external_dependencies", "Destinations outside of the registry workloads send traffic to",
+		s.externalDependenciesz)
+	s.addDebugHandler(mux, internalMux, "/debug/jwksz", "Last fetch time, key IDs and errors of the JWKS of each JWT issuer", s.jwksz)
+	s.addDebugHandler(mux, internalMux, "/debug/metric_cardinality", "Labels of the istiod metrics with the most distinct values",
+		s.metricCardinalityz)
 
 	s.addDebugHandler(mux, internalMux, "/debug/list", "List all supported debug commands in json", s.List)
 }
@@ -279,6 +311,7 @@ func (s *DiscoveryServer) Syncz(w http.ResponseWriter, _ *http.Request) {
 				RouteAcked:    con.NonceAcked(v3.RouteType),
 				EndpointSent:  con.NonceSent(v3.EndpointType),
 				EndpointAcked: con.NonceAcked(v3.EndpointType),
+				Conditions:    con.nackCircuitConditions(),
 			})
 		}
 	}
@@ -365,18 +398,25 @@ func (s *DiscoveryServer) endpointz(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	type servicePort struct {
+		svc  *model.Service
+		port *model.Port
+	}
 	svc := s.Env.ServiceDiscovery.Services()
-	resp := make([]endpointzResponse, 0)
+	ports := make([]servicePort, 0, len(svc))
 	for _, ss := range svc {
 		for _, p := range ss.Ports {
-			all := s.Env.ServiceDiscovery.InstancesByPort(ss, p.Port, nil)
-			resp = append(resp, endpointzResponse{
-				Service:   fmt.Sprintf("%s:%s", ss.Hostname, p.Name),
-				Endpoints: all,
-			})
+			ports = append(ports, servicePort{ss, p})
 		}
 	}
-	writeJSON(w, resp)
+	// Endpoints are looked up lazily, so only the requested page is held in memory.
+	writeJSONList(w, req, len(ports), func(i int) interface{} {
+		sp := ports[i]
+		return endpointzResponse{
+			Service:   fmt.Sprintf("%s:%s", sp.svc.Hostname, sp.port.Name),
+			Endpoints: s.Env.ServiceDiscovery.InstancesByPort(sp.svc, sp.port.Port, nil),
+		}
+	})
 }
 
 func (s *DiscoveryServer) distributedVersions(w http.ResponseWriter, req *http.Request) {
@@ -457,15 +497,15 @@ func (k kubernetesConfig) MarshalJSON() ([]byte, error) {
 
 // Config debugging.
 func (s *DiscoveryServer) configz(w http.ResponseWriter, req *http.Request) {
-	configs := make([]kubernetesConfig, 0)
+	configs := make([]config.Config, 0)
 	s.Env.IstioConfigStore.Schemas().ForEach(func(schema collection.Schema) bool {
 		cfg, _ := s.Env.IstioConfigStore.List(schema.Resource().GroupVersionKind(), "")
-		for _, c := range cfg {
-			configs = append(configs, kubernetesConfig{c})
-		}
+		configs = append(configs, cfg...)
 		return false
 	})
-	writeJSON(w, configs)
+	writeJSONList(w, req, len(configs), func(i int) interface{} {
+		return kubernetesConfig{configs[i]}
+	})
 }
 
 // SidecarScope debugging
@@ -552,10 +592,21 @@ func (s *DiscoveryServer) adsz(w http.ResponseWriter, req *http.Request) {
 	} else {
 		connections = s.Clients()
 	}
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].ConID < connections[j].ConID
+	})
+	page, err := parseDebugPage(req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error() + "\n"))
+		return
+	}
+	start, end := page.bounds(len(connections))
 
-	adsClients := &AdsClients{}
-	adsClients.Total = len(connections)
-	for _, c := range connections {
+	// Clients are streamed in the AdsClients format, to avoid holding all of them in memory.
+	lw := newDebugListWriter(w, req, len(connections))
+	lw.raw(fmt.Sprintf(`{"totalClients":%d,"clients":[`, len(connections)))
+	for _, c := range connections[start:end] {
 		adsClient := AdsClient{
 			ConnectionID: c.ConID,
 			ConnectedAt:  c.Connect,
@@ -572,12 +623,10 @@ func (s *DiscoveryServer) adsz(w http.ResponseWriter, req *http.Request) {
 			adsClient.Watches[k] = r
 		}
 		c.proxy.RUnlock()
-		adsClients.Connected = append(adsClients.Connected, adsClient)
+		lw.item(adsClient)
 	}
-	sort.Slice(adsClients.Connected, func(i, j int) bool {
-		return adsClients.Connected[i].ConnectionID < adsClients.Connected[j].ConnectionID
-	})
-	writeJSON(w, adsClients)
+	lw.raw("]}")
+	lw.close()
 }
 
 // ecdsz implements a status and debug interface for ECDS.
@@ -805,6 +854,38 @@ func (s *DiscoveryServer) pushContextHandler(w http.ResponseWriter, _ *http.Requ
 	writeJSON(w, push)
 }
 
+// pushContextIndexSize is the estimated size of an index of the push context.
+type pushContextIndexSize struct {
+	model.IndexSize
+	// Size is the human readable estimate of the memory retained by the index.
+	Size string `json:"size"`
+}
+
+// pushContextSizeResponse is the response of /debug/pushcontext_size.
+type pushContextSizeResponse struct {
+	PushVersion string                          `json:"pushVersion"`
+	Indexes     map[string]pushContextIndexSize `json:"indexes"`
+	// Total is the human readable estimate of the memory retained by all the indexes.
+	Total string `json:"total"`
+}
+
+// pushContextSizez reports the estimated memory retained by the indexes of the current push context. Memory shared
+// between indexes is only counted once, see PushContext.SizeEstimate.
+func (s *DiscoveryServer) pushContextSizez(w http.ResponseWriter, _ *http.Request) {
+	push := s.globalPushContext()
+	res := pushContextSizeResponse{
+		PushVersion: push.PushVersion,
+		Indexes:     map[string]pushContextIndexSize{},
+	}
+	var total int64
+	for index, size := range push.SizeEstimate() {
+		res.Indexes[index] = pushContextIndexSize{IndexSize: size, Size: util.ByteCount(int(size.Bytes))}
+		total += size.Bytes
+	}
+	res.Total = util.ByteCount(int(total))
+	writeJSON(w, res)
+}
+
 // Debug lists all the supported debug endpoints.
 func (s *DiscoveryServer) Debug(w http.ResponseWriter, req *http.Request) {
 	type debugEndpoint struct {
@@ -930,10 +1011,30 @@ func (s *DiscoveryServer) instancesz(w http.ResponseWriter, req *http.Request) {
 	writeJSON(w, instances)
 }
 
+// leaderz lists the leader elections run by this instance, with their current leaders.
+func (s *DiscoveryServer) leaderz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, leaderelection.Elections())
+}
+
 func (s *DiscoveryServer) networkz(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, s.Env.NetworkManager.AllGateways())
 }
 
+// networkDetectionz lists the networks detected for the workloads of the nodes of each cluster.
+func (s *DiscoveryServer) networkDetectionz(w http.ResponseWriter, _ *http.Request) {
+	out := map[cluster.ID][]model.DetectedNetwork{}
+	if agg, ok := s.Env.ServiceDiscovery.(*aggregate.Controller); ok {
+		for _, registry := range agg.GetRegistries() {
+			if detector, ok := registry.(interface {
+				DetectedNetworks() []model.DetectedNetwork
+			}); ok {
+				out[registry.Cluster()] = detector.DetectedNetworks()
+			}
+		}
+	}
+	writeJSON(w, out)
+}
+
 func (s *DiscoveryServer) mcsz(w http.ResponseWriter, _ *http.Request) {
 	svcs := sortMCSServices(s.Env.MCSServices())
 	writeJSON(w, svcs)
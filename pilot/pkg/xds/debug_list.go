@@ -0,0 +1,180 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"istio.io/istio/pkg/config"
+)
+
+const (
+	// ndjsonContentType is requested via the Accept header to get one JSON object per line, rather than
+	// a single JSON document.
+	ndjsonContentType = "application/x-ndjson"
+
+	// totalItemsHeader is set on paginated debug responses to the total number of items, before pagination.
+	totalItemsHeader = "X-Total-Items"
+)
+
+// debugPage holds the pagination parameters of a debug request, set with the "offset" and "limit" query
+// parameters. A zero limit means no limit.
+type debugPage struct {
+	offset int
+	limit  int
+}
+
+func parseDebugPage(req *http.Request) (debugPage, error) {
+	page := debugPage{}
+	q := req.URL.Query()
+	if o := q.Get("offset"); o != "" {
+		v, err := strconv.Atoi(o)
+		if err != nil || v < 0 {
+			return page, fmt.Errorf("invalid offset %q", o)
+		}
+		page.offset = v
+	}
+	if l := q.Get("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil || v < 0 {
+			return page, fmt.Errorf("invalid limit %q", l)
+		}
+		page.limit = v
+	}
+	return page, nil
+}
+
+// bounds returns the [start, end) range of a list with total items to serve for this page.
+func (p debugPage) bounds(total int) (int, int) {
+	start := p.offset
+	if start > total {
+		start = total
+	}
+	end := total
+	if p.limit > 0 && start+p.limit < total {
+		end = start + p.limit
+	}
+	return start, end
+}
+
+// debugListWriter streams a list of JSON items to a debug response, rather than building the entire
+// response in memory. This is important for endpoints such as configz and endpointz, which can be very
+// large in big meshes. Based on the request headers, the response can be gzip compressed and either a
+// JSON document or newline delimited JSON.
+type debugListWriter struct {
+	out     io.Writer
+	gz      *gzip.Writer
+	flusher http.Flusher
+	ndjson  bool
+	count   int
+	err     error
+}
+
+func newDebugListWriter(w http.ResponseWriter, req *http.Request, total int) *debugListWriter {
+	lw := &debugListWriter{
+		out:    w,
+		ndjson: strings.Contains(req.Header.Get("Accept"), ndjsonContentType),
+	}
+	lw.flusher, _ = w.(http.Flusher)
+	if lw.ndjson {
+		w.Header().Set("Content-Type", ndjsonContentType)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set(totalItemsHeader, strconv.Itoa(total))
+	if strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		lw.gz = gzip.NewWriter(w)
+		lw.out = lw.gz
+	}
+	return lw
+}
+
+// raw writes s as-is, except in ndjson mode where only items are written.
+func (lw *debugListWriter) raw(s string) {
+	if lw.ndjson || lw.err != nil {
+		return
+	}
+	_, lw.err = io.WriteString(lw.out, s)
+}
+
+// item writes a single list item.
+func (lw *debugListWriter) item(obj interface{}) {
+	if lw.err != nil {
+		return
+	}
+	b, err := config.ToJSON(obj)
+	if err != nil {
+		lw.err = err
+		return
+	}
+	if lw.ndjson {
+		b = append(b, '\n')
+	} else if lw.count > 0 {
+		lw.raw(",")
+	}
+	lw.count++
+	if _, lw.err = lw.out.Write(b); lw.err != nil {
+		return
+	}
+	// Periodically flush so clients start receiving data, and we do not buffer the entire response.
+	if lw.count%100 == 0 {
+		lw.flush()
+	}
+}
+
+func (lw *debugListWriter) flush() {
+	if lw.gz != nil {
+		_ = lw.gz.Flush()
+	}
+	if lw.flusher != nil {
+		lw.flusher.Flush()
+	}
+}
+
+// close finishes the response. Errors are logged, as the status code has already been written.
+func (lw *debugListWriter) close() {
+	if lw.gz != nil {
+		if err := lw.gz.Close(); err != nil && lw.err == nil {
+			lw.err = err
+		}
+	}
+	if lw.err != nil {
+		log.Warnf("failed to write debug response: %v", lw.err)
+	}
+}
+
+// writeJSONList writes total items, fetched with get, as a paginated and streamed JSON list.
+func writeJSONList(w http.ResponseWriter, req *http.Request, total int, get func(i int) interface{}) {
+	page, err := parseDebugPage(req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error() + "\n"))
+		return
+	}
+	start, end := page.bounds(total)
+	lw := newDebugListWriter(w, req, total)
+	lw.raw("[")
+	for i := start; i < end; i++ {
+		lw.item(get(i))
+	}
+	lw.raw("]")
+	lw.close()
+}
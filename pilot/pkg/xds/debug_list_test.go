@@ -0,0 +1,153 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugPageBounds(t *testing.T) {
+	cases := []struct {
+		name       string
+		page       debugPage
+		total      int
+		start, end int
+	}{
+		{"no pagination", debugPage{}, 10, 0, 10},
+		{"limit", debugPage{limit: 3}, 10, 0, 3},
+		{"offset and limit", debugPage{offset: 4, limit: 3}, 10, 4, 7},
+		{"limit past end", debugPage{offset: 8, limit: 5}, 10, 8, 10},
+		{"offset past end", debugPage{offset: 20}, 10, 10, 10},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := tt.page.bounds(tt.total)
+			if start != tt.start || end != tt.end {
+				t.Fatalf("expected [%d, %d), got [%d, %d)", tt.start, tt.end, start, end)
+			}
+		})
+	}
+}
+
+const debugListConfigs = `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se-a
+  namespace: default
+spec:
+  hosts: [a.example.com]
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: DNS
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se-b
+  namespace: default
+spec:
+  hosts: [b.example.com]
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: DNS
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se-c
+  namespace: default
+spec:
+  hosts: [c.example.com]
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: DNS
+`
+
+func TestConfigzEncoding(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: debugListConfigs})
+	get := func(t *testing.T, url string, headers map[string]string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(s.Discovery.configz).ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("json", func(t *testing.T) {
+		rr := get(t, "/debug/configz", nil)
+		var got []map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("invalid json %v: %s", err, rr.Body.String())
+		}
+		if len(got) != 3 || rr.Header().Get(totalItemsHeader) != "3" {
+			t.Fatalf("expected 3 configs, got %d (header %q)", len(got), rr.Header().Get(totalItemsHeader))
+		}
+	})
+	t.Run("paginated", func(t *testing.T) {
+		rr := get(t, "/debug/configz?offset=1&limit=1", nil)
+		var got []map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("invalid json %v: %s", err, rr.Body.String())
+		}
+		if len(got) != 1 || rr.Header().Get(totalItemsHeader) != "3" {
+			t.Fatalf("expected 1 of 3 configs, got %d (header %q)", len(got), rr.Header().Get(totalItemsHeader))
+		}
+	})
+	t.Run("invalid page", func(t *testing.T) {
+		rr := get(t, "/debug/configz?limit=-1", nil)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected bad request, got %d", rr.Code)
+		}
+	})
+	t.Run("gzip ndjson", func(t *testing.T) {
+		rr := get(t, "/debug/configz", map[string]string{"Accept": ndjsonContentType, "Accept-Encoding": "gzip"})
+		if rr.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("expected gzip encoding, got %v", rr.Header())
+		}
+		gz, err := gzip.NewReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := 0
+		scanner := bufio.NewScanner(gz)
+		for scanner.Scan() {
+			var got map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+				t.Fatalf("invalid json line %v: %s", err, scanner.Text())
+			}
+			lines++
+		}
+		if lines != 3 {
+			t.Fatalf("expected 3 lines, got %d", lines)
+		}
+	})
+}
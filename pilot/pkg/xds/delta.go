@@ -18,6 +18,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
@@ -37,13 +38,9 @@ import (
 var deltaLog = istiolog.RegisterScope("delta", "delta xds debugging", 0)
 
 func (s *DiscoveryServer) StreamDeltas(stream DeltaDiscoveryStream) error {
-	if knativeEnv != "" && firstRequest.Load() {
-		// How scaling works in knative is the first request is the "loading" request. During
-		// loading request, concurrency=1. Once that request is done, concurrency is enabled.
-		// However, the XDS stream is long lived, so the first request would block all others. As a
-		// result, we should exit the first request immediately; clients will retry.
-		firstRequest.Store(false)
-		return status.Error(codes.Unavailable, "server warmup not complete; try again")
+	// Allow platform specific hooks, such as Knative warmup handling, to reject the stream.
+	if err := s.admitConnection(stream.Context()); err != nil {
+		return err
 	}
 	// Check if server is ready to accept clients and process new requests.
 	// Currently ready means caches have been synced and hence can build
@@ -77,6 +74,7 @@ func (s *DiscoveryServer) StreamDeltas(stream DeltaDiscoveryStream) error {
 	} else {
 		deltaLog.Debugf("Unauthenticated XDS: %v", peerAddr)
 	}
+	advertiseCompression(ctx)
 
 	// InitContext returns immediately if the context was already initialized.
 	if err = s.globalPushContext().InitContext(s.Env, nil, nil); err != nil {
@@ -86,6 +84,7 @@ func (s *DiscoveryServer) StreamDeltas(stream DeltaDiscoveryStream) error {
 		return status.Error(codes.Unavailable, "error reading config")
 	}
 	con := newDeltaConnection(peerAddr, stream)
+	con.globalSendLimiter = s.globalSendLimiter
 
 	// Do not call: defer close(con.pushChannel). The push channel will be garbage collected
 	// when the connection is no longer used. Closing the channel can cause subtle race conditions
@@ -116,13 +115,23 @@ func (s *DiscoveryServer) StreamDeltas(stream DeltaDiscoveryStream) error {
 				return <-con.errorChan
 			}
 		case pushEv := <-con.pushChannel:
+			con.releasePushSlot = pushEv.releaseSlot
 			err := s.pushConnectionDelta(con, pushEv)
+			con.releasePushSlot = nil
 			pushEv.done()
 			if err != nil {
 				return err
 			}
+		case typeURL := <-con.throttledPushChan:
+			if err := s.pushThrottledDelta(con, typeURL); err != nil {
+				return err
+			}
+		case typeURL := <-con.heldInitialFetchChan:
+			if err := s.releaseInitialDeltaTimeout(con, typeURL); err != nil {
+				return err
+			}
 		case <-con.stop:
-			return nil
+			return s.stoppedStatus()
 		}
 	}
 }
@@ -135,12 +144,14 @@ func (s *DiscoveryServer) pushConnectionDelta(con *Connection, pushEv *Event) er
 	if pushRequest.Full {
 		// Update Proxy with current information.
 		s.updateProxy(con.proxy, pushRequest)
+		s.trackWildcardScope(con)
 	}
 
-	if !s.ProxyNeedsPush(con.proxy, pushRequest) {
-		deltaLog.Debugf("Skipping push to %v, no updates required", con.ConID)
-		if pushRequest.Full {
-			// Only report for full versions, incremental pushes do not have a new version
+	if needsPush, skippedBy := s.proxyNeedsPush(con.proxy, pushRequest); !needsPush {
+		deltaLog.Debugf("Skipping push to %v, skipped by the %s predicate", con.ConID, skippedBy)
+		if pushRequest.Full && skippedBy == defaultPushPredicate {
+			// Only report for full versions, incremental pushes do not have a new version. The pushes skipped by a
+			// PushPredicate are not reported, as the proxy did not get the version although it may be relevant to it.
 			reportAllEvents(s.StatusReporter, con.ConID, pushRequest.Push.LedgerVersion, nil)
 		}
 		return nil
@@ -150,37 +161,18 @@ func (s *DiscoveryServer) pushConnectionDelta(con *Connection, pushEv *Event) er
 	// Each Generator is responsible for determining if the push event requires a push
 	wrl, ignoreEvents := con.pushDetails()
 	for _, w := range wrl {
-		if !features.EnableFlowControl {
-			// Always send the push if flow control disabled
-			if err := s.pushDeltaXds(con, pushRequest.Push, w, nil, pushRequest); err != nil {
-				return err
-			}
+		if con.initialFetchHeld(w.TypeUrl) {
+			// The held first response of the type is computed with the latest push context when released.
 			continue
 		}
-		// If flow control is enabled, we will only push if we got an ACK for the previous response
-		synced, timeout := con.Synced(w.TypeUrl)
-		if !synced && timeout {
-			// We are not synced, but we have been stuck for too long. We will trigger the push anyways to
-			// avoid any scenario where this may deadlock.
-			// This can possibly be removed in the future if we find this never causes issues
-			totalDelayedPushes.With(typeTag.Value(v3.GetMetricType(w.TypeUrl))).Increment()
-			deltaLog.Warnf("%s: QUEUE TIMEOUT for node:%s", v3.GetShortType(w.TypeUrl), con.proxy.ID)
+		if delay := con.pushDelay(w.TypeUrl); delay > 0 {
+			// The type was pushed too recently, the push is merged with the other pushes received until the
+			// minimum push interval of the type has elapsed.
+			s.throttleDelta(con, w.TypeUrl, pushRequest, delay)
+			continue
 		}
-		if synced || timeout {
-			// Send the push now
-			if err := s.pushDeltaXds(con, pushRequest.Push, w, nil, pushRequest); err != nil {
-				return err
-			}
-		} else {
-			// The type is not yet synced. Instead of pushing now, which may overload Envoy,
-			// we will wait until the last push is ACKed and trigger the push. See
-			// https://github.com/istio/istio/issues/25685 for details on the performance
-			// impact of sending pushes before Envoy ACKs.
-			totalDelayedPushes.With(typeTag.Value(v3.GetMetricType(w.TypeUrl))).Increment()
-			deltaLog.Debugf("%s: QUEUE for node:%s", v3.GetShortType(w.TypeUrl), con.proxy.ID)
-			con.proxy.Lock()
-			con.blockedPushes[w.TypeUrl] = con.blockedPushes[w.TypeUrl].CopyMerge(pushEv.pushRequest)
-			con.proxy.Unlock()
+		if err := s.pushDeltaType(con, w, pushRequest); err != nil {
+			return err
 		}
 	}
 	if pushRequest.Full {
@@ -188,10 +180,81 @@ func (s *DiscoveryServer) pushConnectionDelta(con *Connection, pushEv *Event) er
 		reportAllEvents(s.StatusReporter, con.ConID, pushRequest.Push.LedgerVersion, ignoreEvents)
 	}
 
-	proxiesConvergeDelay.Record(time.Since(pushRequest.Start).Seconds())
+	recordConvergeDelay(con.proxy, time.Since(pushRequest.Start))
+	return nil
+}
+
+// pushDeltaType sends the push of the type, or queues it until the last push of the type is ACKed if flow control
+// is enabled.
+func (s *DiscoveryServer) pushDeltaType(con *Connection, w *model.WatchedResource, pushRequest *model.PushRequest) error {
+	if !features.EnableFlowControl {
+		// Always send the push if flow control disabled
+		return s.pushDeltaXds(con, pushRequest.Push, w, nil, pushRequest)
+	}
+	// If flow control is enabled, we will only push if we got an ACK for the previous response
+	synced, timeout := con.Synced(w.TypeUrl)
+	if !synced && timeout {
+		// We are not synced, but we have been stuck for too long. We will trigger the push anyways to
+		// avoid any scenario where this may deadlock.
+		// This can possibly be removed in the future if we find this never causes issues
+		totalDelayedPushes.With(typeValue(totalDelayedPushes, w.TypeUrl)).Increment()
+		deltaLog.Warnf("%s: QUEUE TIMEOUT for node:%s", v3.GetShortType(w.TypeUrl), con.proxy.ID)
+	}
+	if synced || timeout {
+		// Send the push now
+		return s.pushDeltaXds(con, pushRequest.Push, w, nil, pushRequest)
+	}
+	// The type is not yet synced. Instead of pushing now, which may overload Envoy,
+	// we will wait until the last push is ACKed and trigger the push. See
+	// https://github.com/istio/istio/issues/25685 for details on the performance
+	// impact of sending pushes before Envoy ACKs.
+	totalDelayedPushes.With(typeValue(totalDelayedPushes, w.TypeUrl)).Increment()
+	deltaLog.Debugf("%s: QUEUE for node:%s", v3.GetShortType(w.TypeUrl), con.proxy.ID)
+	con.proxy.Lock()
+	con.blockedPushes[w.TypeUrl] = con.blockedPushes[w.TypeUrl].CopyMerge(pushRequest)
+	con.proxy.Unlock()
 	return nil
 }
 
+// throttleDelta merges the push of the type with its throttled push, and schedules the throttled push after the
+// delay if it is the first one.
+func (s *DiscoveryServer) throttleDelta(con *Connection, typeURL string, pushRequest *model.PushRequest, delay time.Duration) {
+	totalDelayedPushes.With(typeValue(totalDelayedPushes, typeURL)).Increment()
+	deltaLog.Debugf("%s: THROTTLE for node:%s for %v", v3.GetShortType(typeURL), con.proxy.ID, delay)
+	con.proxy.Lock()
+	_, scheduled := con.throttledPushes[typeURL]
+	con.throttledPushes[typeURL] = con.throttledPushes[typeURL].CopyMerge(pushRequest)
+	con.proxy.Unlock()
+	if scheduled {
+		return
+	}
+	time.AfterFunc(delay, func() {
+		select {
+		case con.throttledPushChan <- typeURL:
+		case <-con.stop:
+		}
+	})
+}
+
+// pushThrottledDelta sends the throttled push of the type, once its minimum push interval has elapsed.
+func (s *DiscoveryServer) pushThrottledDelta(con *Connection, typeURL string) error {
+	con.proxy.Lock()
+	pushRequest := con.throttledPushes[typeURL]
+	delete(con.throttledPushes, typeURL)
+	con.proxy.Unlock()
+	w := con.Watched(typeURL)
+	if pushRequest == nil || w == nil {
+		return nil
+	}
+	if delay := con.pushDelay(typeURL); delay > 0 {
+		// The NACK circuit breaker of the type opened since the push was throttled.
+		s.throttleDelta(con, typeURL, pushRequest, delay)
+		return nil
+	}
+	deltaLog.Debugf("%s: UNTHROTTLE for node:%s", v3.GetShortType(typeURL), con.proxy.ID)
+	return s.pushDeltaType(con, w, pushRequest)
+}
+
 func (s *DiscoveryServer) receiveDelta(con *Connection, identities []string) {
 	defer func() {
 		close(con.deltaReqChan)
@@ -206,6 +269,7 @@ func (s *DiscoveryServer) receiveDelta(con *Connection, identities []string) {
 	firstRequest := true
 	for {
 		req, err := con.deltaStream.Recv()
+		atomic.StoreInt64(&con.lastRequest, time.Now().UnixNano())
 		if err != nil {
 			if istiogrpc.IsExpectedGRPCError(err) {
 				deltaLog.Infof("ADS: %q %s terminated %v", con.PeerAddr, con.ConID, err)
@@ -241,17 +305,22 @@ func (s *DiscoveryServer) receiveDelta(con *Connection, identities []string) {
 }
 
 func (conn *Connection) sendDelta(res *discovery.DeltaDiscoveryResponse) error {
-	sendHandler := func() error {
-		start := time.Now()
-		defer func() { recordSendTime(time.Since(start)) }()
-		return conn.deltaStream.Send(res)
+	sz := 0
+	for _, rc := range res.Resources {
+		sz += len(rc.Resource.GetValue())
 	}
-	err := istiogrpc.Send(conn.deltaStream.Context(), sendHandler)
+	ctx, cancel := sendContext(conn.deltaStream.Context())
+	defer cancel()
+	err := conn.waitToSend(ctx, res.TypeUrl, sz)
 	if err == nil {
-		sz := 0
-		for _, rc := range res.Resources {
-			sz += len(rc.Resource.Value)
+		sendHandler := func() error {
+			start := time.Now()
+			defer func() { recordSendTime(time.Since(start)) }()
+			return conn.deltaStream.Send(res)
 		}
+		err = istiogrpc.Send(ctx, sendHandler)
+	}
+	if err == nil {
 		if res.Nonce != "" && !strings.HasPrefix(res.TypeUrl, v3.DebugType) {
 			conn.proxy.Lock()
 			if conn.proxy.WatchedResources[res.TypeUrl] == nil {
@@ -276,39 +345,68 @@ func (s *DiscoveryServer) processDeltaRequest(req *discovery.DeltaDiscoveryReque
 	if !s.shouldProcessRequest(con.proxy, deltaToSotwRequest(req)) {
 		return nil
 	}
+	s.audit.request(con, auditStreamDelta, req.TypeUrl, req.ResponseNonce, "",
+		len(req.ResourceNamesSubscribe), len(req.ResourceNamesUnsubscribe), req.ErrorDetail)
 	if strings.HasPrefix(req.TypeUrl, v3.DebugType) {
 		return s.pushXds(con, s.globalPushContext(), &model.WatchedResource{
 			TypeUrl: req.TypeUrl, ResourceNames: req.ResourceNamesSubscribe,
 		}, &model.PushRequest{Full: true})
 	}
 	if s.StatusReporter != nil {
-		s.StatusReporter.RegisterEvent(con.ConID, req.TypeUrl, req.ResponseNonce)
+		s.StatusReporter.RegisterDeltaEvent(con.ConID, req.TypeUrl, req.ResponseNonce, req.ErrorDetail != nil)
+	}
+	con.proxy.Lock()
+	con.deltaSubscribed[req.TypeUrl] = req.ResourceNamesSubscribe
+	con.deltaUnsubscribed[req.TypeUrl] = req.ResourceNamesUnsubscribe
+	if con.proxy.WatchedResources[req.TypeUrl] == nil && isWildcardTypeURL(req.TypeUrl) {
+		con.deltaExplicit[req.TypeUrl] = isExplicitSubscription(req.ResourceNamesSubscribe)
 	}
+	con.proxy.Unlock()
 	shouldRespond := s.shouldRespondDelta(con, req)
-	var request *model.PushRequest
-	push := s.globalPushContext()
+	s.trackDeltaWatches(con, req.TypeUrl)
+	if req.TypeUrl == v3.ClusterType {
+		s.trackWildcardScope(con)
+	}
 	if shouldRespond {
-		// This is a request, trigger a full push for this type. Override the blocked push (if it exists),
-		// as this full push is guaranteed to be a superset of what we would have pushed from the blocked push.
-		request = &model.PushRequest{Full: true, Push: push}
-	} else {
-		// Check if we have a blocked push. If this was an ACK, we will send it.
-		// Either way we remove the blocked push as we will send a push.
-		haveBlockedPush := false
-		con.proxy.Lock()
-		request, haveBlockedPush = con.blockedPushes[req.TypeUrl]
-		delete(con.blockedPushes, req.TypeUrl)
-		con.proxy.Unlock()
-		if haveBlockedPush {
-			// we have a blocked push which we will use
-			deltaLog.Debugf("%s: DEQUEUE for node:%s", v3.GetShortType(req.TypeUrl), con.proxy.ID)
-		} else {
-			// This is an ACK, no delayed push
-			// Return immediately, no action needed
-			return nil
+		// The first response of the type is sent once the previous types are ACKed.
+		if !s.holdInitialDelta(con, req) {
+			if err := s.respondDelta(con, req); err != nil {
+				return err
+			}
 		}
+		// The unsubscriptions may complete a stage of the initial fetch ordering.
+		return s.releaseInitialDeltas(con)
+	}
+	// The ACK may complete a stage of the initial fetch ordering.
+	if err := s.releaseInitialDeltas(con); err != nil {
+		return err
+	}
+	// Check if we have a blocked push. If this was an ACK, we will send it.
+	// Either way we remove the blocked push as we will send a push.
+	con.proxy.Lock()
+	request, haveBlockedPush := con.blockedPushes[req.TypeUrl]
+	delete(con.blockedPushes, req.TypeUrl)
+	con.proxy.Unlock()
+	if !haveBlockedPush {
+		// This is an ACK, no delayed push
+		// Return immediately, no action needed
+		return nil
 	}
+	// we have a blocked push which we will use
+	deltaLog.Debugf("%s: DEQUEUE for node:%s", v3.GetShortType(req.TypeUrl), con.proxy.ID)
+	return s.pushDeltaRequest(con, s.globalPushContext(), req, request)
+}
 
+// respondDelta responds to a request, with a full push for its type. This overrides the blocked push (if it exists),
+// as this full push is guaranteed to be a superset of what we would have pushed from the blocked push.
+func (s *DiscoveryServer) respondDelta(con *Connection, req *discovery.DeltaDiscoveryRequest) error {
+	push := s.globalPushContext()
+	return s.pushDeltaRequest(con, push, req, &model.PushRequest{Full: true, Push: push})
+}
+
+// pushDeltaRequest sends the push triggered by a request of the proxy.
+func (s *DiscoveryServer) pushDeltaRequest(con *Connection, push *model.PushContext, req *discovery.DeltaDiscoveryRequest,
+	request *model.PushRequest) error {
 	request.Reason = append(request.Reason, model.ProxyRequest)
 	request.Start = time.Now()
 	// SidecarScope for the proxy may has not been updated based on this pushContext.
@@ -316,6 +414,7 @@ func (s *DiscoveryServer) processDeltaRequest(req *discovery.DeltaDiscoveryReque
 	// but before proxy's SidecarScope has been updated(s.updateProxy).
 	if con.proxy.SidecarScope != nil && con.proxy.SidecarScope.Version != push.PushVersion {
 		s.computeProxyState(con.proxy, request)
+		s.trackWildcardScope(con)
 	}
 	return s.pushDeltaXds(con, push, con.Watched(req.TypeUrl), req.ResourceNamesSubscribe, request)
 }
@@ -332,6 +431,8 @@ func (s *DiscoveryServer) shouldRespondDelta(con *Connection, request *discovery
 		errCode := codes.Code(request.ErrorDetail.Code)
 		deltaLog.Warnf("ADS:%s: ACK ERROR %s %s:%s", stype, con.ConID, errCode.String(), request.ErrorDetail.GetMessage())
 		incrementXDSRejects(request.TypeUrl, con.proxy.ID, errCode.String())
+		s.emitConfigRejected(con, request.TypeUrl, request.ErrorDetail.GetMessage())
+		s.recordDeltaNack(con, request.TypeUrl, request.ErrorDetail.GetMessage())
 		if s.StatusGen != nil {
 			s.StatusGen.OnNack(con.proxy, deltaToSotwRequest(request))
 		}
@@ -371,7 +472,7 @@ func (s *DiscoveryServer) shouldRespondDelta(con *Connection, request *discovery
 	if request.ResponseNonce != "" && request.ResponseNonce != previousInfo.NonceSent {
 		deltaLog.Debugf("ADS:%s: REQ %s Expired nonce received %s, sent %s", stype,
 			con.ConID, request.ResponseNonce, previousInfo.NonceSent)
-		xdsExpiredNonce.With(typeTag.Value(v3.GetMetricType(request.TypeUrl))).Increment()
+		xdsExpiredNonce.With(typeValue(xdsExpiredNonce, request.TypeUrl)).Increment()
 		con.proxy.Lock()
 		con.proxy.WatchedResources[request.TypeUrl].NonceNacked = ""
 		con.proxy.Unlock()
@@ -387,6 +488,9 @@ func (s *DiscoveryServer) shouldRespondDelta(con *Connection, request *discovery
 	con.proxy.WatchedResources[request.TypeUrl].NonceNacked = ""
 	con.proxy.WatchedResources[request.TypeUrl].ResourceNames = deltaResources
 	con.proxy.Unlock()
+	if request.ResponseNonce != "" {
+		s.recordDeltaAck(con, request.TypeUrl)
+	}
 
 	oldAck := listEqualUnordered(previousResources, deltaResources)
 	// Spontaneous DeltaDiscoveryRequests from the client.
@@ -424,6 +528,7 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection, push *model.PushContext,
 	if gen == nil {
 		return nil
 	}
+	releaseTypePush := s.acquireTypePush(con, w.TypeUrl)
 	t0 := time.Now()
 
 	// If subscribe is set, client is requesting specific resources. We should just generate the
@@ -440,12 +545,28 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection, push *model.PushContext,
 	var logdata model.XdsLogDetails
 	var usedDelta bool
 	var err error
+	explicit := features.EnableDeltaOnDemand && con.isExplicitDelta(w.TypeUrl)
 	switch g := gen.(type) {
 	case model.XdsDeltaResourceGenerator:
-		res, deletedRes, logdata, usedDelta, err = g.GenerateDeltas(con.proxy, push, req, w)
+		if wg, ok := gen.(watchedResourceGenerator); ok && explicit {
+			// Only the watched resources are generated, rather than all the resources of the type.
+			res, deletedRes, logdata, usedDelta, err = wg.GenerateWatched(con.proxy, push, req, w)
+		} else {
+			res, deletedRes, logdata, usedDelta, err = g.GenerateDeltas(con.proxy, push, req, w)
+		}
 	case model.XdsResourceGenerator:
 		res, logdata, err = g.Generate(con.proxy, push, w, req)
 	}
+	generation := time.Since(t0)
+	// The slot of the type only limits the generation, the responses are limited by the send rate limit.
+	releaseTypePush()
+	if explicit && err == nil {
+		res, deletedRes = filterWatched(w.ResourceNames, res, deletedRes)
+		if usedDelta && len(res) == 0 && len(deletedRes) == 0 {
+			// None of the changes concern the watched resources.
+			res, deletedRes = nil, nil
+		}
+	}
 	if err != nil || (res == nil && deletedRes == nil) {
 		// If we have nothing to send, report that we got an ACK for this version.
 		if s.StatusReporter != nil {
@@ -453,12 +574,11 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection, push *model.PushContext,
 		}
 		return err
 	}
-	defer func() { recordPushTime(w.TypeUrl, time.Since(t0)) }()
+	defer func() { recordPushTime(w.TypeUrl, con.proxy, time.Since(t0)) }()
 	resp := &discovery.DeltaDiscoveryResponse{
-		ControlPlane: ControlPlane(),
-		TypeUrl:      w.TypeUrl,
-		// TODO: send different version for incremental eds
-		SystemVersionInfo: push.PushVersion,
+		ControlPlane:      s.controlPlane(),
+		TypeUrl:           w.TypeUrl,
+		SystemVersionInfo: s.responseVersion(con, w.TypeUrl, push),
 		Nonce:             nonce(push.LedgerVersion),
 		Resources:         res,
 	}
@@ -475,7 +595,7 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection, push *model.PushContext,
 		deltaLog.Debugf("ADS:%v %s REMOVE %v", v3.GetShortType(w.TypeUrl), con.ConID, resp.RemovedResources)
 	}
 	// normally wildcard xds `subscribe` is always nil, just in case there are some extended type not handled correctly.
-	if subscribe == nil && isWildcardTypeURL(w.TypeUrl) {
+	if subscribe == nil && isWildcardTypeURL(w.TypeUrl) && !explicit {
 		// this is probably a bad idea...
 		con.proxy.Lock()
 		w.ResourceNames = currentResources
@@ -483,7 +603,7 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection, push *model.PushContext,
 	}
 
 	configSize := ResourceSize(res)
-	configSizeBytes.With(typeTag.Value(w.TypeUrl)).Record(float64(configSize))
+	recordConfigSize(w.TypeUrl, con.proxy, configSize)
 
 	ptype := "PUSH"
 	info := ""
@@ -494,8 +614,12 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection, push *model.PushContext,
 		info = " " + logdata.AdditionalInfo
 	}
 
-	if err := con.sendDelta(resp); err != nil {
+	err = con.sendDelta(resp)
+	s.audit.response(con, auditStreamDelta, resp.TypeUrl, resp.Nonce, resp.SystemVersionInfo, len(res),
+		len(resp.RemovedResources), generation, err)
+	if err != nil {
 		if recordSendError(w.TypeUrl, err) {
+			s.emitPushFailed(con, w.TypeUrl, err)
 			deltaLog.Warnf("%s: Send failure for node:%s resources:%d size:%s%s: %v",
 				v3.GetShortType(w.TypeUrl), con.proxy.ID, len(res), util.ByteCount(configSize), info, err)
 		}
@@ -523,6 +647,7 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection, push *model.PushContext,
 
 func newDeltaConnection(peerAddr string, stream DeltaDiscoveryStream) *Connection {
 	return &Connection{
+		lastRequest:   time.Now().UnixNano(),
 		pushChannel:   make(chan *Event),
 		initialized:   make(chan struct{}),
 		stop:          make(chan struct{}),
@@ -532,6 +657,18 @@ func newDeltaConnection(peerAddr string, stream DeltaDiscoveryStream) *Connectio
 		deltaReqChan:  make(chan *discovery.DeltaDiscoveryRequest, 1),
 		errorChan:     make(chan error, 1),
 		blockedPushes: map[string]*model.PushRequest{},
+		sendLimiter:   newSendLimiter(),
+
+		throttledPushes:   map[string]*model.PushRequest{},
+		throttledPushChan: make(chan string),
+		nackCircuits:      map[string]*nackCircuit{},
+
+		heldInitialFetches:   map[string]*discovery.DeltaDiscoveryRequest{},
+		heldInitialFetchChan: make(chan string),
+
+		deltaSubscribed:   map[string][]string{},
+		deltaUnsubscribed: map[string][]string{},
+		deltaExplicit:     map[string]bool{},
 	}
 }
 
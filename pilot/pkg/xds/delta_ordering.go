@@ -0,0 +1,164 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/util/sets"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// initialFetchOrder is the order the first responses of the types are sent in, with
+// PILOT_DELTA_INITIAL_FETCH_ORDERING: listeners must not reference clusters, nor routes reference
+// endpoints, which were not sent yet.
+var initialFetchOrder = []string{v3.ClusterType, v3.EndpointType, v3.ListenerType, v3.RouteType}
+
+// defaultInitialFetchStageTimeout is the maximum time the first response of a type is held for.
+const defaultInitialFetchStageTimeout = 5 * time.Second
+
+func initialFetchStageTimeout(typeURL string) time.Duration {
+	if timeout, f := features.DeltaInitialFetchStageTimeouts[v3.GetMetricType(typeURL)]; f {
+		return timeout
+	}
+	return defaultInitialFetchStageTimeout
+}
+
+// initialFetchBlocked returns the type of the previous stage the first response of the type waits for, if any.
+// The caller must hold the proxy lock.
+func (conn *Connection) initialFetchBlocked(typeURL string) string {
+	for _, previous := range initialFetchOrder {
+		if previous == typeURL {
+			return ""
+		}
+		// Only the types requested by the proxy are waited for, unless it unsubscribed from all their resources.
+		w := conn.proxy.WatchedResources[previous]
+		if w == nil || w.NonceAcked != "" || (!isWildcardTypeURL(previous) && len(w.ResourceNames) == 0) {
+			continue
+		}
+		return previous
+	}
+	// The type is not ordered.
+	return ""
+}
+
+// holdInitialDelta holds the first response of the type if a previous stage is not ACKed yet, and schedules
+// its release at the deadline of the stage. It returns true if the response is held.
+func (s *DiscoveryServer) holdInitialDelta(con *Connection, req *discovery.DeltaDiscoveryRequest) bool {
+	if !features.DeltaInitialFetchOrdering {
+		return false
+	}
+	con.proxy.Lock()
+	if held, f := con.heldInitialFetches[req.TypeUrl]; f {
+		// The proxy updated its subscriptions while the first response is held.
+		updateHeldSubscriptions(held, req)
+		if held.ResourceNamesSubscribe != nil && len(held.ResourceNamesSubscribe) == 0 {
+			// The proxy unsubscribed from all the resources it requested, there is nothing to respond.
+			delete(con.heldInitialFetches, req.TypeUrl)
+		}
+		con.proxy.Unlock()
+		return true
+	}
+	if w := con.proxy.WatchedResources[req.TypeUrl]; w != nil && w.NonceSent != "" {
+		// Not the first response.
+		con.proxy.Unlock()
+		return false
+	}
+	blocking := con.initialFetchBlocked(req.TypeUrl)
+	if blocking == "" {
+		con.proxy.Unlock()
+		return false
+	}
+	con.heldInitialFetches[req.TypeUrl] = req
+	con.proxy.Unlock()
+
+	deltaLog.Debugf("%s: HOLD initial response for node:%s until %s is ACKed", v3.GetShortType(req.TypeUrl),
+		con.proxy.ID, v3.GetShortType(blocking))
+	time.AfterFunc(initialFetchStageTimeout(req.TypeUrl), func() {
+		select {
+		case con.heldInitialFetchChan <- req.TypeUrl:
+		case <-con.stop:
+		}
+	})
+	return true
+}
+
+// updateHeldSubscriptions applies the subscriptions and unsubscriptions of a request received while the first
+// response of the type is held, so that the response only contains the resources still subscribed. A held wildcard
+// request is left as is, as its response contains the watched resources, which are already updated.
+func updateHeldSubscriptions(held, req *discovery.DeltaDiscoveryRequest) {
+	if held.ResourceNamesSubscribe == nil {
+		return
+	}
+	subscribed := sets.NewSet(held.ResourceNamesSubscribe...)
+	subscribed.Insert(req.ResourceNamesSubscribe...)
+	subscribed.Delete(req.ResourceNamesUnsubscribe...)
+	held.ResourceNamesSubscribe = subscribed.SortedList()
+	unsubscribed := sets.NewSet(held.ResourceNamesUnsubscribe...)
+	unsubscribed.Insert(req.ResourceNamesUnsubscribe...)
+	unsubscribed.Delete(req.ResourceNamesSubscribe...)
+	held.ResourceNamesUnsubscribe = unsubscribed.SortedList()
+}
+
+// releaseInitialDeltas sends the held first responses whose previous stages are now ACKed, or no longer
+// subscribed, in order.
+func (s *DiscoveryServer) releaseInitialDeltas(con *Connection) error {
+	if !features.DeltaInitialFetchOrdering {
+		return nil
+	}
+	for _, typeURL := range initialFetchOrder {
+		con.proxy.Lock()
+		req, f := con.heldInitialFetches[typeURL]
+		if !f || con.initialFetchBlocked(typeURL) != "" {
+			con.proxy.Unlock()
+			continue
+		}
+		delete(con.heldInitialFetches, typeURL)
+		con.proxy.Unlock()
+		deltaLog.Debugf("%s: RELEASE initial response for node:%s", v3.GetShortType(typeURL), con.proxy.ID)
+		if err := s.respondDelta(con, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseInitialDeltaTimeout sends the held first response of the type once the deadline of its stage has elapsed.
+func (s *DiscoveryServer) releaseInitialDeltaTimeout(con *Connection, typeURL string) error {
+	con.proxy.Lock()
+	req, f := con.heldInitialFetches[typeURL]
+	blocking := con.initialFetchBlocked(typeURL)
+	delete(con.heldInitialFetches, typeURL)
+	con.proxy.Unlock()
+	if !f {
+		// Already released.
+		return nil
+	}
+	initialFetchTimeouts.With(typeValue(initialFetchTimeouts, typeURL)).Increment()
+	deltaLog.Warnf("%s: initial response for node:%s sent before %s was ACKed: deadline of %v exceeded",
+		v3.GetShortType(typeURL), con.proxy.ID, v3.GetShortType(blocking), initialFetchStageTimeout(typeURL))
+	return s.respondDelta(con, req)
+}
+
+// initialFetchHeld returns true if the first response of the type is held.
+func (conn *Connection) initialFetchHeld(typeURL string) bool {
+	conn.proxy.RLock()
+	defer conn.proxy.RUnlock()
+	_, f := conn.heldInitialFetches[typeURL]
+	return f
+}
@@ -15,16 +15,22 @@
 package xds_test
 
 import (
+	"fmt"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/xds"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pilot/test/xdstest"
 	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/test/util/retry"
 )
 
 func TestDeltaAds(t *testing.T) {
@@ -135,3 +141,283 @@ func TestDeltaEDS(t *testing.T) {
 		t.Fatalf("received unexpected eds resource %v", resp.Resources)
 	}
 }
+
+func TestDeltaEDSEndpointDiff(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		ConfigString: fmt.Sprintf(`apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: subsets
+  namespace: default
+spec:
+  host: %s
+  subsets:
+  - name: v1
+    labels:
+      version: v1
+  - name: v2
+    labels:
+      version: v2
+`, edsIncSvc),
+		DiscoveryServerModifier: func(s *xds.DiscoveryServer) {
+			s.MemRegistry.AddHTTPService(edsIncSvc, edsIncVip, 8080)
+			s.MemRegistry.SetEndpoints(edsIncSvc, "", append(
+				newEndpointWithAccount("127.0.0.1", "hello-sa", "v1"),
+				newEndpointWithAccount("127.0.0.2", "hello-sa", "v2")...))
+		},
+	})
+	v1 := "outbound|8080|v1|" + edsIncSvc
+	v2 := "outbound|8080|v2|" + edsIncSvc
+
+	ads := s.ConnectDeltaADS().WithType(v3.EndpointType)
+	resp := ads.RequestResponseAck(&discovery.DeltaDiscoveryRequest{ResourceNamesSubscribe: []string{v1, v2}})
+	if len(resp.Resources) != 2 {
+		t.Fatalf("received unexpected eds resources %v", resp.Resources)
+	}
+
+	// Only the cluster of the subset of the updated endpoint is pushed.
+	s.MemRegistry.SetEndpoints(edsIncSvc, "", append(
+		newEndpointWithAccount("127.0.0.3", "hello-sa", "v1"),
+		newEndpointWithAccount("127.0.0.2", "hello-sa", "v2")...))
+	resp = ads.ExpectResponse()
+	if len(resp.Resources) != 1 || resp.Resources[0].Name != v1 {
+		t.Fatalf("received unexpected eds resources %v", resp.Resources)
+	}
+
+	// Nothing is pushed when the endpoints did not change.
+	s.MemRegistry.SetEndpoints(edsIncSvc, "", append(
+		newEndpointWithAccount("127.0.0.3", "hello-sa", "v1"),
+		newEndpointWithAccount("127.0.0.2", "hello-sa", "v2")...))
+	ads.ExpectNoResponse()
+}
+
+func TestDeltaVHDS(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		ConfigString: `apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: gateway
+  namespace: istio-system
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+  - port:
+      number: 80
+      name: http
+      protocol: HTTP
+    hosts:
+    - "*.example.com"
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: a
+  namespace: istio-system
+spec:
+  hosts:
+  - a.example.com
+  gateways:
+  - gateway
+  http:
+  - route:
+    - destination:
+        host: a.default.svc.cluster.local
+`,
+	})
+	ads := s.ConnectDeltaADS().WithType(v3.VirtualHostType).WithID(gatewayID(gatewayIP)).
+		WithMetadata(model.NodeMetadata{EnableVHDS: true, Labels: map[string]string{"istio": "ingressgateway"}})
+
+	// The route configuration name is subscribed to along with the aliases of the requested hosts.
+	resp := ads.RequestResponseAck(&discovery.DeltaDiscoveryRequest{
+		ResourceNamesSubscribe: []string{"http.80", "http.80/a.example.com", "http.80/b.example.com"},
+	})
+	if len(resp.Resources) != 2 {
+		t.Fatalf("received unexpected vhds resources %v", resp.Resources)
+	}
+	resolved, unresolved := resp.Resources[0], resp.Resources[1]
+	if resolved.Name != "http.80/a.example.com:80" || resolved.Resource == nil ||
+		!reflect.DeepEqual(resolved.Aliases, []string{"http.80/a.example.com"}) {
+		t.Fatalf("unexpected resolved virtual host %v", resolved)
+	}
+	if unresolved.Name != "http.80/b.example.com" || unresolved.Resource != nil ||
+		!reflect.DeepEqual(unresolved.Aliases, []string{"http.80/b.example.com"}) {
+		t.Fatalf("unexpected unresolved virtual host %v", unresolved)
+	}
+
+	// The virtual host no longer resolved is removed.
+	s.Store().Delete(gvk.VirtualService, "a", "istio-system", nil)
+	resp = ads.ExpectResponse()
+	if !reflect.DeepEqual(resp.RemovedResources, []string{"http.80/a.example.com:80"}) {
+		t.Fatalf("unexpected removed virtual hosts %v", resp.RemovedResources)
+	}
+}
+
+func TestDeltaThrottledPush(t *testing.T) {
+	original := features.PushTypeMinIntervals
+	t.Cleanup(func() {
+		features.PushTypeMinIntervals = original
+	})
+	features.PushTypeMinIntervals = map[string]time.Duration{"cds": 500 * time.Millisecond}
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	ads := s.ConnectDeltaADS().WithType(v3.ClusterType)
+	ads.RequestResponseAck(nil)
+
+	// Pushes sooner than the interval after the previous push are delayed and merged
+	xds.AdsPushAll(s.Discovery)
+	xds.AdsPushAll(s.Discovery)
+	ads.ExpectNoResponse()
+	ads.ExpectResponse()
+	ads.ExpectNoResponse()
+}
+
+func TestDeltaInitialFetchOrdering(t *testing.T) {
+	originalOrdering, originalTimeouts := features.DeltaInitialFetchOrdering, features.DeltaInitialFetchStageTimeouts
+	t.Cleanup(func() {
+		features.DeltaInitialFetchOrdering, features.DeltaInitialFetchStageTimeouts = originalOrdering, originalTimeouts
+	})
+	features.DeltaInitialFetchOrdering = true
+	features.DeltaInitialFetchStageTimeouts = map[string]time.Duration{"lds": 300 * time.Millisecond}
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: `apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: default
+spec:
+  hosts:
+  - a.example.com
+  - b.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: STATIC
+  endpoints:
+  - address: 2.2.2.2
+`})
+	clusterA, clusterB := "outbound|80||a.example.com", "outbound|80||b.example.com"
+
+	expectType := func(resp *discovery.DeltaDiscoveryResponse, typeURL string) {
+		t.Helper()
+		if resp.TypeUrl != typeURL {
+			t.Fatalf("expected %s response, got %s", v3.GetShortType(typeURL), v3.GetShortType(resp.TypeUrl))
+		}
+	}
+
+	t.Run("held until ack", func(t *testing.T) {
+		ads := s.ConnectDeltaADS().WithType(v3.ClusterType)
+		ads.Request(nil)
+		cds := ads.ExpectResponse()
+		expectType(cds, v3.ClusterType)
+
+		// Listeners are held while clusters are not ACKed.
+		ads.Request(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.ListenerType})
+		ads.ExpectNoResponse()
+		ads.Request(&discovery.DeltaDiscoveryRequest{ResponseNonce: cds.Nonce})
+		expectType(ads.ExpectResponse(), v3.ListenerType)
+	})
+
+	t.Run("held until deadline", func(t *testing.T) {
+		ads := s.ConnectDeltaADS().WithType(v3.ClusterType)
+		ads.Request(nil)
+		expectType(ads.ExpectResponse(), v3.ClusterType)
+
+		ads.Request(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.ListenerType})
+		ads.ExpectNoResponse()
+		expectType(ads.ExpectResponse(), v3.ListenerType)
+	})
+
+	t.Run("unordered types", func(t *testing.T) {
+		ads := s.ConnectDeltaADS().WithType(v3.ListenerType)
+		// Listeners are not held when clusters were not requested.
+		expectType(ads.RequestResponseAck(nil), v3.ListenerType)
+	})
+
+	t.Run("unsubscribed while held", func(t *testing.T) {
+		ads := s.ConnectDeltaADS().WithType(v3.ClusterType)
+		ads.Request(nil)
+		cds := ads.ExpectResponse()
+
+		ads.Request(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.EndpointType, ResourceNamesSubscribe: []string{clusterA, clusterB}})
+		ads.Request(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.EndpointType, ResourceNamesUnsubscribe: []string{clusterB}})
+		ads.ExpectNoResponse()
+		ads.Request(&discovery.DeltaDiscoveryRequest{ResponseNonce: cds.Nonce})
+		eds := ads.ExpectResponse()
+		expectType(eds, v3.EndpointType)
+		// The held response only contains the resources still subscribed.
+		if len(eds.Resources) != 1 || eds.Resources[0].Name != clusterA || len(eds.RemovedResources) != 0 {
+			t.Fatalf("expected only %s, got %v removing %v", clusterA, eds.Resources, eds.RemovedResources)
+		}
+	})
+
+	t.Run("stage unsubscribed", func(t *testing.T) {
+		features.DeltaInitialFetchStageTimeouts = map[string]time.Duration{"eds": time.Minute, "lds": time.Minute}
+		ads := s.ConnectDeltaADS().WithType(v3.ClusterType)
+		ads.Request(nil)
+		cds := ads.ExpectResponse()
+
+		// Endpoints and listeners are held while clusters are not ACKed.
+		ads.Request(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.EndpointType, ResourceNamesSubscribe: []string{clusterA}})
+		ads.Request(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.ListenerType})
+		ads.ExpectNoResponse()
+		// The listeners no longer wait for the endpoints once they are all unsubscribed.
+		ads.Request(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.EndpointType, ResourceNamesUnsubscribe: []string{clusterA}})
+		ads.ExpectNoResponse()
+		ads.Request(&discovery.DeltaDiscoveryRequest{ResponseNonce: cds.Nonce})
+		expectType(ads.ExpectResponse(), v3.ListenerType)
+		ads.ExpectNoResponse()
+	})
+}
+
+type deltaStatusEvent struct {
+	nonce string
+	nack  bool
+}
+
+// deltaStatusReporter records the delta requests reported to the distribution status.
+type deltaStatusReporter struct {
+	mu     sync.Mutex
+	events []deltaStatusEvent
+}
+
+func (r *deltaStatusReporter) RegisterEvent(string, xds.EventType, string) {}
+
+func (r *deltaStatusReporter) RegisterDeltaEvent(_ string, _ xds.EventType, nonce string, nack bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, deltaStatusEvent{nonce: nonce, nack: nack})
+}
+
+func (r *deltaStatusReporter) RegisterDisconnect(string, []xds.EventType) {}
+
+func (r *deltaStatusReporter) QueryLastNonce(string, xds.EventType) string {
+	return ""
+}
+
+func (r *deltaStatusReporter) expect(t *testing.T, want ...deltaStatusEvent) {
+	t.Helper()
+	retry.UntilSuccessOrFail(t, func() error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if !reflect.DeepEqual(r.events, want) {
+			return fmt.Errorf("got events %v, want %v", r.events, want)
+		}
+		return nil
+	}, retry.Timeout(time.Second*5))
+}
+
+func TestDeltaStatusReporter(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	reporter := &deltaStatusReporter{}
+	s.Discovery.StatusReporter = reporter
+	ads := s.ConnectDeltaADS().WithType(v3.ClusterType)
+
+	resp := ads.RequestResponseAck(nil)
+	reporter.expect(t, deltaStatusEvent{}, deltaStatusEvent{nonce: resp.Nonce})
+
+	ads.Request(&discovery.DeltaDiscoveryRequest{
+		ResponseNonce: resp.Nonce,
+		ErrorDetail:   &status.Status{Message: "rejected"},
+	})
+	reporter.expect(t, deltaStatusEvent{}, deltaStatusEvent{nonce: resp.Nonce}, deltaStatusEvent{nonce: resp.Nonce, nack: true})
+}
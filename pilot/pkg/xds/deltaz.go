@@ -0,0 +1,168 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// DeltaConnectionDebug is the state of a delta xDS connection, as exported by /debug/deltaz.
+type DeltaConnectionDebug struct {
+	ConnectionID string    `json:"connectionId"`
+	ProxyID      string    `json:"proxyId"`
+	PeerAddress  string    `json:"address"`
+	ConnectedAt  time.Time `json:"connectedAt"`
+	// Types is the state of each type, keyed by type URL.
+	Types map[string]*DeltaTypeDebug `json:"types,omitempty"`
+}
+
+// DeltaTypeDebug is the state of a type of a delta xDS connection.
+type DeltaTypeDebug struct {
+	// Subscribed are all the resource names the client subscribed to.
+	Subscribed []string `json:"subscribed,omitempty"`
+	// LastSubscribed and LastUnsubscribed are the resource names subscribed and unsubscribed by the last request.
+	LastSubscribed   []string  `json:"lastSubscribed,omitempty"`
+	LastUnsubscribed []string  `json:"lastUnsubscribed,omitempty"`
+	NonceSent        string    `json:"nonceSent,omitempty"`
+	NonceAcked       string    `json:"nonceAcked,omitempty"`
+	NonceNacked      string    `json:"nonceNacked,omitempty"`
+	LastSent         time.Time `json:"lastSent,omitempty"`
+	// BlockedPush is the push waiting for the ACK of the last response, with flow control.
+	BlockedPush *PendingPushDebug `json:"blockedPush,omitempty"`
+	// ThrottledPush is the push waiting for the minimum push interval of the type to elapse.
+	ThrottledPush *PendingPushDebug `json:"throttledPush,omitempty"`
+	// InitialFetchHeld is set while the first response of the type is held by the initial fetch ordering.
+	InitialFetchHeld bool `json:"initialFetchHeld,omitempty"`
+	// NackCircuit is the state of the NACK circuit breaker of the type, if the type was NACKed since its last ACK.
+	NackCircuit *NackCircuitDebug `json:"nackCircuit,omitempty"`
+}
+
+// NackCircuitDebug is the state of the NACK circuit breaker of a type of a delta xDS connection.
+type NackCircuitDebug struct {
+	ConsecutiveNacks int `json:"consecutiveNacks"`
+	// Open is set while the pushes of the type are suspended, until RetryAt.
+	Open      bool          `json:"open"`
+	OpenedAt  time.Time     `json:"openedAt,omitempty"`
+	RetryAt   time.Time     `json:"retryAt,omitempty"`
+	Backoff   time.Duration `json:"backoff,omitempty"`
+	LastError string        `json:"lastError,omitempty"`
+}
+
+// PendingPushDebug summarizes the pushes merged while waiting to be sent.
+type PendingPushDebug struct {
+	Full bool `json:"full"`
+	// Depth is the number of push triggers merged in the pending push.
+	Depth          int                   `json:"depth"`
+	Reasons        []model.TriggerReason `json:"reasons,omitempty"`
+	ConfigsUpdated int                   `json:"configsUpdated"`
+	Start          time.Time             `json:"start,omitempty"`
+}
+
+// DeltaDebug is the output of /debug/deltaz.
+type DeltaDebug struct {
+	// PushQueueDepth is the number of connections waiting in the push queue, delta or not.
+	PushQueueDepth int                     `json:"pushQueueDepth"`
+	Connections    []*DeltaConnectionDebug `json:"connections"`
+}
+
+// deltaz dumps the state of the delta xDS connections, or of the requested proxy.
+// It is mapped to /debug/deltaz
+func (s *DiscoveryServer) deltaz(w http.ResponseWriter, req *http.Request) {
+	proxyID := req.URL.Query().Get("proxyID")
+	out := DeltaDebug{PushQueueDepth: s.pushQueue.Pending(), Connections: []*DeltaConnectionDebug{}}
+	for _, con := range s.Clients() {
+		if con.deltaStream == nil || (proxyID != "" && con.proxy.ID != proxyID) {
+			continue
+		}
+		out.Connections = append(out.Connections, deltaConnectionDebug(con))
+	}
+	sort.Slice(out.Connections, func(i, j int) bool {
+		return out.Connections[i].ConnectionID < out.Connections[j].ConnectionID
+	})
+	writeJSON(w, out)
+}
+
+func deltaConnectionDebug(con *Connection) *DeltaConnectionDebug {
+	con.proxy.RLock()
+	defer con.proxy.RUnlock()
+	out := &DeltaConnectionDebug{
+		ConnectionID: con.ConID,
+		ProxyID:      con.proxy.ID,
+		PeerAddress:  con.PeerAddr,
+		ConnectedAt:  con.Connect,
+		Types:        map[string]*DeltaTypeDebug{},
+	}
+	typeDebug := func(typeURL string) *DeltaTypeDebug {
+		if out.Types[typeURL] == nil {
+			out.Types[typeURL] = &DeltaTypeDebug{}
+		}
+		return out.Types[typeURL]
+	}
+	for typeURL, w := range con.proxy.WatchedResources {
+		t := typeDebug(typeURL)
+		t.Subscribed = append([]string{}, w.ResourceNames...)
+		t.NonceSent = w.NonceSent
+		t.NonceAcked = w.NonceAcked
+		t.NonceNacked = w.NonceNacked
+		t.LastSent = w.LastSent
+	}
+	for typeURL, names := range con.deltaSubscribed {
+		typeDebug(typeURL).LastSubscribed = names
+	}
+	for typeURL, names := range con.deltaUnsubscribed {
+		typeDebug(typeURL).LastUnsubscribed = names
+	}
+	for typeURL, push := range con.blockedPushes {
+		typeDebug(typeURL).BlockedPush = pendingPushDebug(push)
+	}
+	for typeURL, push := range con.throttledPushes {
+		typeDebug(typeURL).ThrottledPush = pendingPushDebug(push)
+	}
+	for typeURL := range con.heldInitialFetches {
+		typeDebug(typeURL).InitialFetchHeld = true
+	}
+	for typeURL, c := range con.nackCircuits {
+		typeDebug(typeURL).NackCircuit = &NackCircuitDebug{
+			ConsecutiveNacks: c.consecutiveNacks,
+			Open:             c.open,
+			OpenedAt:         c.openedAt,
+			RetryAt:          c.retryAt,
+			Backoff:          c.backoff,
+			LastError:        c.lastError,
+		}
+	}
+	return out
+}
+
+func pendingPushDebug(push *model.PushRequest) *PendingPushDebug {
+	if push == nil {
+		return nil
+	}
+	depth := len(push.Reason)
+	if depth == 0 {
+		depth = 1
+	}
+	return &PendingPushDebug{
+		Full:           push.Full,
+		Depth:          depth,
+		Reasons:        push.Reason,
+		ConfigsUpdated: len(push.ConfigsUpdated),
+		Start:          push.Start,
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestDeltaz(t *testing.T) {
+	original := features.EnableFlowControl
+	t.Cleanup(func() {
+		features.EnableFlowControl = original
+	})
+	features.EnableFlowControl = true
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	ads := s.ConnectDeltaADS().WithType(v3.EndpointType)
+	ads.RequestResponseAck(&discovery.DeltaDiscoveryRequest{
+		ResourceNamesSubscribe: []string{"outbound|80||local.default.svc.cluster.local"},
+	})
+	// Send a push without ACKing it, the next push is blocked.
+	AdsPushAll(s.Discovery)
+	ads.ExpectResponse()
+	AdsPushAll(s.Discovery)
+	ads.ExpectNoResponse()
+
+	retry.UntilSuccessOrFail(t, func() error {
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(s.Discovery.deltaz).ServeHTTP(rr, httptest.NewRequest("GET", "/debug/deltaz", nil))
+		out := DeltaDebug{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+			return err
+		}
+		if len(out.Connections) != 1 {
+			return fmt.Errorf("expected 1 connection, got %d", len(out.Connections))
+		}
+		eds := out.Connections[0].Types[v3.EndpointType]
+		if eds == nil {
+			return fmt.Errorf("expected EDS state, got %v", out.Connections[0].Types)
+		}
+		if len(eds.Subscribed) != 1 || eds.Subscribed[0] != "outbound|80||local.default.svc.cluster.local" {
+			return fmt.Errorf("unexpected subscribed resources %v", eds.Subscribed)
+		}
+		if eds.NonceSent == "" || eds.NonceSent == eds.NonceAcked {
+			return fmt.Errorf("expected last push not acked, got sent %q acked %q", eds.NonceSent, eds.NonceAcked)
+		}
+		if eds.BlockedPush == nil || !eds.BlockedPush.Full {
+			return fmt.Errorf("expected blocked full push, got %v", eds.BlockedPush)
+		}
+		return nil
+	}, retry.Timeout(time.Second*5))
+}
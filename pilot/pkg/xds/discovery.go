@@ -22,18 +22,22 @@ import (
 	"time"
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	lrs "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v3"
 	"github.com/google/uuid"
 	"go.uber.org/atomic"
 	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
 
 	"istio.io/istio/pilot/pkg/controller/workloadentry"
+	"istio.io/istio/pilot/pkg/events"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/apigen"
 	"istio.io/istio/pilot/pkg/networking/core"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/envoyfilter"
+	"istio.io/istio/pilot/pkg/networking/egressaudit"
 	"istio.io/istio/pilot/pkg/networking/grpcgen"
+	"istio.io/istio/pilot/pkg/security/authn/mtlsreport"
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
 	"istio.io/istio/pilot/pkg/serviceregistry/memory"
@@ -70,6 +74,20 @@ type debounceOptions struct {
 
 	// enableEDSDebounce indicates whether EDS pushes should be debounced.
 	enableEDSDebounce bool
+
+	// proxyDebounceAfter returns the shortest debounce requested by the connected proxies, if any. It replaces
+	// debounceAfter when shorter.
+	proxyDebounceAfter func() (time.Duration, bool)
+}
+
+// after returns the quiet period of the debounce.
+func (o debounceOptions) after() time.Duration {
+	if o.proxyDebounceAfter != nil {
+		if d, ok := o.proxyDebounceAfter(); ok && d < o.debounceAfter {
+			return d
+		}
+	}
+	return o.debounceAfter
 }
 
 // DiscoveryServer is Pilot's gRPC implementation for Envoy's xds APIs
@@ -95,8 +113,26 @@ type DiscoveryServer struct {
 	// may also choose to not send any updates.
 	ProxyNeedsPush func(proxy *model.Proxy, req *model.PushRequest) bool
 
+	// PushPredicates are consulted, in order, after ProxyNeedsPush. Any predicate may skip the push to a proxy.
+	// They must be registered before the server is started.
+	PushPredicates []PushPredicate
+
+	// ConnectionAdmitters are consulted, in order, before accepting a new xDS stream. Any admitter may
+	// reject the stream, for example to implement platform specific warmup semantics.
+	ConnectionAdmitters []ConnectionAdmitter
+
+	// monotonicVersions is true if the responses are versioned with MonotonicVersionScheme.
+	monotonicVersions bool
+
 	// concurrentPushLimit is a semaphore that limits the amount of concurrent XDS pushes.
 	concurrentPushLimit chan struct{}
+	// globalSendLimiter limits the bytes per second of the XDS responses sent to all connections, or is nil if
+	// unlimited.
+	globalSendLimiter *rate.Limiter
+
+	// typePushLimits are semaphores limiting the amount of concurrent XDS pushes of a type, by type URL.
+	// Types without a limit are not in the map.
+	typePushLimits map[string]chan struct{}
 	// requestRateLimit limits the number of new XDS requests allowed. This helps prevent thundering hurd of incoming requests.
 	requestRateLimit *rate.Limiter
 
@@ -115,6 +151,12 @@ type DiscoveryServer struct {
 	// incremental updates. This is keyed by service and namespace
 	EndpointShardsByService map[string]map[string]*EndpointShards
 
+	// audit writes the xDS requests and responses to the audit log, if enabled.
+	audit *auditLog
+
+	// deltaWatches counts the delta xDS subscriptions to each service, when features.EnableDeltaOnDemand is set.
+	deltaWatches deltaWatches
+
 	// pushChannel is the buffer used for debouncing.
 	// after debouncing the pushRequest will be sent to pushQueue
 	pushChannel chan *model.PushRequest
@@ -131,6 +173,9 @@ type DiscoveryServer struct {
 	// adsClients reflect active gRPC channels, for both ADS and EDS.
 	adsClients      map[string]*Connection
 	adsClientsMutex sync.RWMutex
+	// proxyDebounces is the push debounce requested by each connection with one, keyed by connection ID. It is
+	// protected by adsClientsMutex.
+	proxyDebounces map[string]time.Duration
 
 	StatusReporter DistributionStatusCache
 
@@ -144,6 +189,12 @@ type DiscoveryServer struct {
 	// serverReady indicates caches have been synced up and server is ready to process requests.
 	serverReady atomic.Bool
 
+	// draining indicates the server is closing its connections before shutting down.
+	draining atomic.Bool
+
+	// affinityControlPlane caches the control plane identifier carrying the last computed affinity hint.
+	affinityControlPlane atomic.Value
+
 	debounceOptions debounceOptions
 
 	instanceID string
@@ -157,6 +208,23 @@ type DiscoveryServer struct {
 	// ListRemoteClusters collects debug information about other clusters this istiod reads from.
 	ListRemoteClusters func() []cluster.DebugInfo
 
+	// MTLSTrafficSource provides the inbound traffic of workloads for the mTLS compatibility report.
+	// If nil, the report only includes the PeerAuthentication mode of workloads.
+	MTLSTrafficSource mtlsreport.TrafficSource
+
+	// ExternalTrafficSource provides the traffic to destinations outside of the registry for the external
+	// dependencies report, if configured.
+	ExternalTrafficSource egressaudit.TrafficSource
+
+	// proxyVersions is the range of proxy versions supported by this istiod.
+	proxyVersions proxyVersionRange
+
+	// loadReports holds the load reported by the proxies over LRS.
+	loadReports *loadReports
+
+	// Events receives the lifecycle events of the proxies. If nil, no events are emitted.
+	Events *events.Emitter
+
 	// ClusterAliases are aliase names for cluster. When a proxy connects with a cluster ID
 	// and if it has a different alias we should use that a cluster ID for proxy.
 	ClusterAliases map[cluster.ID]cluster.ID
@@ -180,6 +248,10 @@ type EndpointShards struct {
 	// Due to the larger time, it is still possible that connection errors will occur while
 	// CDS is updated.
 	ServiceAccounts sets.Set
+
+	// LocalityLoad is the load of the service reported over LRS, in requests per second by locality label.
+	// It is nil if no load was reported.
+	LocalityLoad map[string]float64
 }
 
 // NewDiscoveryServer creates DiscoveryServer that sources data from Pilot's internal mesh data structures
@@ -189,8 +261,12 @@ func NewDiscoveryServer(env *model.Environment, plugins []string, instanceID str
 		Env:                     env,
 		Generators:              map[string]model.XdsResourceGenerator{},
 		ProxyNeedsPush:          DefaultProxyNeedsPush,
+		ConnectionAdmitters:     defaultConnectionAdmitters(),
+		monotonicVersions:       isMonotonicVersionScheme(features.XDSVersionScheme),
 		EndpointShardsByService: map[string]map[string]*EndpointShards{},
 		concurrentPushLimit:     make(chan struct{}, features.PushThrottle),
+		typePushLimits:          newTypePushLimits(),
+		globalSendLimiter:       newGlobalSendLimiter(),
 		requestRateLimit:        rate.NewLimiter(rate.Limit(features.RequestLimit), 1),
 		InboundUpdates:          atomic.NewInt64(0),
 		CommittedUpdates:        atomic.NewInt64(0),
@@ -198,15 +274,20 @@ func NewDiscoveryServer(env *model.Environment, plugins []string, instanceID str
 		pushQueue:               NewPushQueue(),
 		debugHandlers:           map[string]string{},
 		adsClients:              map[string]*Connection{},
+		proxyDebounces:          map[string]time.Duration{},
 		debounceOptions: debounceOptions{
 			debounceAfter:     features.DebounceAfter,
 			debounceMax:       features.DebounceMax,
 			enableEDSDebounce: features.EnableEDSDebounce,
 		},
-		Cache:      model.DisabledCache{},
-		instanceID: instanceID,
+		Cache:         model.DisabledCache{},
+		instanceID:    instanceID,
+		proxyVersions: newProxyVersionRange(features.MinProxyVersion, features.MaxProxyVersion),
+		loadReports:   newLoadReports(),
 	}
 
+	out.debounceOptions.proxyDebounceAfter = out.shortestProxyDebounce
+
 	out.ClusterAliases = make(map[cluster.ID]cluster.ID)
 	for alias := range clusterAliases {
 		out.ClusterAliases[cluster.ID(alias)] = cluster.ID(clusterAliases[alias])
@@ -250,6 +331,9 @@ func (s *DiscoveryServer) closeJwksResolver() {
 func (s *DiscoveryServer) Register(rpcs *grpc.Server) {
 	// Register v3 server
 	discovery.RegisterAggregatedDiscoveryServiceServer(rpcs, s)
+	if features.EnableLoadReporting {
+		lrs.RegisterLoadReportingServiceServer(rpcs, s)
+	}
 }
 
 var processStartTime = time.Now()
@@ -261,14 +345,24 @@ func (s *DiscoveryServer) CachesSynced() {
 }
 
 func (s *DiscoveryServer) IsServerReady() bool {
-	return s.serverReady.Load()
+	return s.serverReady.Load() && !s.draining.Load()
 }
 
 func (s *DiscoveryServer) Start(stopCh <-chan struct{}) {
 	go s.WorkloadEntryController.Run(stopCh)
 	go s.handleUpdates(stopCh)
 	go s.periodicRefreshMetrics(stopCh)
+	if features.PushContextSizeInterval > 0 {
+		go s.periodicRecordPushContextSize(stopCh)
+	}
 	go s.sendPushes(stopCh)
+	go s.reapStaleConnections(stopCh)
+	if s.audit != nil {
+		go s.audit.run(stopCh)
+	}
+	if features.EnableLoadReporting {
+		go s.aggregateLoadReports(stopCh)
+	}
 }
 
 func (s *DiscoveryServer) getNonK8sRegistries() []serviceregistry.Instance {
@@ -317,6 +411,26 @@ func (s *DiscoveryServer) periodicRefreshMetrics(stopCh <-chan struct{}) {
 	}
 }
 
+// periodicRecordPushContextSize records the estimated size of the indexes of the push context, when it changed since
+// the last estimate.
+func (s *DiscoveryServer) periodicRecordPushContextSize(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(features.PushContextSizeInterval)
+	defer ticker.Stop()
+	lastVersion := ""
+	for {
+		select {
+		case <-ticker.C:
+			push := s.globalPushContext()
+			if push.PushVersion != lastVersion {
+				lastVersion = push.PushVersion
+				push.RecordSizeMetrics()
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
 // dropCacheForRequest clears the cache in response to a push request
 func (s *DiscoveryServer) dropCacheForRequest(req *model.PushRequest) {
 	// If we don't know what updated, cannot safely cache. Clear the whole cache
@@ -423,8 +537,9 @@ func debounce(ch chan *model.PushRequest, stopCh <-chan struct{}, opts debounceO
 	pushWorker := func() {
 		eventDelay := time.Since(startDebounce)
 		quietTime := time.Since(lastConfigUpdateTime)
-		// it has been too long or quiet enough
-		if eventDelay >= opts.debounceMax || quietTime >= opts.debounceAfter {
+		// it has been too long or quiet enough, or the push cannot wait
+		debounceAfter := opts.after()
+		if eventDelay >= opts.debounceMax || quietTime >= debounceAfter || (req != nil && req.Priority) {
 			if req != nil {
 				pushCounter++
 				if req.ConfigsUpdated == nil {
@@ -442,7 +557,7 @@ func debounce(ch chan *model.PushRequest, stopCh <-chan struct{}, opts debounceO
 				debouncedEvents = 0
 			}
 		} else {
-			timeChan = time.After(opts.debounceAfter - quietTime)
+			timeChan = time.After(debounceAfter - quietTime)
 		}
 	}
 
@@ -467,12 +582,15 @@ func debounce(ch chan *model.PushRequest, stopCh <-chan struct{}, opts debounceO
 
 			lastConfigUpdateTime = time.Now()
 			if debouncedEvents == 0 {
-				timeChan = time.After(opts.debounceAfter)
+				timeChan = time.After(opts.after())
 				startDebounce = lastConfigUpdateTime
 			}
 			debouncedEvents++
 
 			req = req.Merge(r)
+			if req.Priority && free {
+				pushWorker()
+			}
 		case <-timeChan:
 			if free {
 				pushWorker()
@@ -496,7 +614,44 @@ func configsUpdated(req *model.PushRequest) string {
 	return configs
 }
 
-func doSendPushes(stopCh <-chan struct{}, semaphore chan struct{}, queue *PushQueue) {
+// newTypePushLimits returns the semaphores limiting the concurrent pushes of each type, as configured by
+// PILOT_PUSH_CONCURRENCY_{CDS,EDS,LDS,RDS}.
+func newTypePushLimits() map[string]chan struct{} {
+	limits := map[string]chan struct{}{}
+	for typeURL, limit := range map[string]int{
+		v3.ClusterType:  features.PushConcurrencyCDS,
+		v3.EndpointType: features.PushConcurrencyEDS,
+		v3.ListenerType: features.PushConcurrencyLDS,
+		v3.RouteType:    features.PushConcurrencyRDS,
+	} {
+		if limit > 0 {
+			limits[typeURL] = make(chan struct{}, limit)
+		}
+	}
+	return limits
+}
+
+// acquireTypePush blocks until a push of typeURL is allowed by the concurrency limit of the type, and
+// returns the function to call once the generation is done. The slots are acquired in a fixed order, the push
+// throttle first and then the type: a push which has to wait for the slot of its type gives up its slot in the
+// push throttle, so that pushes blocked on one type do not hold the slots of the pushes of the other types.
+func (s *DiscoveryServer) acquireTypePush(con *Connection, typeURL string) func() {
+	sem := s.typePushLimits[typeURL]
+	if sem == nil {
+		return func() {}
+	}
+	select {
+	case sem <- struct{}{}:
+	default:
+		if con.releasePushSlot != nil {
+			con.releasePushSlot()
+		}
+		sem <- struct{}{}
+	}
+	return func() { <-sem }
+}
+
+func doSendPushes(stopCh <-chan struct{}, semaphore chan struct{}, queue *PushQueue, typePushLimits map[string]chan struct{}) {
 	for {
 		select {
 		case <-stopCh:
@@ -512,10 +667,17 @@ func doSendPushes(stopCh <-chan struct{}, semaphore chan struct{}, queue *PushQu
 				return
 			}
 			recordPushTriggers(push.Reason...)
+			var releaseOnce sync.Once
+			release := func() { releaseOnce.Do(func() { <-semaphore }) }
+			if !push.Full && typePushLimits[v3.EndpointType] != nil {
+				// Incremental pushes only push endpoints, and are limited by the EDS limit instead. They do not
+				// hold a slot while waiting for it, so that endpoint churn cannot starve full pushes.
+				release()
+			}
 			// Signals that a push is done by reading from the semaphore, allowing another send on it.
 			doneFunc := func() {
 				queue.MarkDone(client)
-				<-semaphore
+				release()
 			}
 
 			proxiesQueueTime.Record(time.Since(push.Start).Seconds())
@@ -529,6 +691,7 @@ func doSendPushes(stopCh <-chan struct{}, semaphore chan struct{}, queue *PushQu
 				pushEv := &Event{
 					pushRequest: push,
 					done:        doneFunc,
+					releaseSlot: release,
 				}
 
 				select {
@@ -572,7 +735,7 @@ func (s *DiscoveryServer) initPushContext(req *model.PushRequest, oldPushContext
 }
 
 func (s *DiscoveryServer) sendPushes(stopCh <-chan struct{}) {
-	doSendPushes(stopCh, s.concurrentPushLimit, s.pushQueue)
+	doSendPushes(stopCh, s.concurrentPushLimit, s.pushQueue, s.typePushLimits)
 }
 
 // InitGenerators initializes generators to be used by XdsServer.
@@ -582,6 +745,7 @@ func (s *DiscoveryServer) InitGenerators(env *model.Environment, systemNameSpace
 	s.Generators[v3.ClusterType] = &CdsGenerator{Server: s}
 	s.Generators[v3.ListenerType] = &LdsGenerator{Server: s}
 	s.Generators[v3.RouteType] = &RdsGenerator{Server: s}
+	s.Generators[v3.VirtualHostType] = &VhdsGenerator{Server: s}
 	s.Generators[v3.EndpointType] = edsGen
 	s.Generators[v3.NameTableType] = &NdsGenerator{Server: s}
 	s.Generators[v3.ExtensionConfigurationType] = &EcdsGenerator{Server: s}
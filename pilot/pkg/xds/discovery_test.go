@@ -88,7 +88,7 @@ func TestSendPushesManyPushes(t *testing.T) {
 			}
 		}()
 	}
-	go doSendPushes(stopCh, semaphore, queue)
+	go doSendPushes(stopCh, semaphore, queue, nil)
 
 	for push := 0; push < 100; push++ {
 		for _, proxy := range proxies {
@@ -142,7 +142,7 @@ func TestSendPushesSinglePush(t *testing.T) {
 			}
 		}()
 	}
-	go doSendPushes(stopCh, semaphore, queue)
+	go doSendPushes(stopCh, semaphore, queue, nil)
 
 	for _, proxy := range proxies {
 		queue.Enqueue(proxy, &model.PushRequest{Push: &model.PushContext{}})
@@ -163,6 +163,65 @@ func TestSendPushesSinglePush(t *testing.T) {
 	}
 }
 
+func TestAcquireTypePush(t *testing.T) {
+	s := &DiscoveryServer{typePushLimits: map[string]chan struct{}{v3.EndpointType: make(chan struct{}, 1)}}
+	released := 0
+	con := &Connection{releasePushSlot: func() { released++ }}
+
+	// Types without a limit are not throttled.
+	s.acquireTypePush(con, v3.ClusterType)()
+
+	release := s.acquireTypePush(con, v3.EndpointType)
+	if released != 0 {
+		t.Fatalf("expected the push slot to be kept while the type slot is free")
+	}
+	acquired := make(chan func())
+	go func() { acquired <- s.acquireTypePush(con, v3.EndpointType) }()
+	select {
+	case <-acquired:
+		t.Fatal("expected the push to wait for the slot of its type")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	select {
+	case r := <-acquired:
+		r()
+	case <-time.After(time.Second):
+		t.Fatal("expected the push to get the slot of its type once released")
+	}
+	if released != 1 {
+		t.Fatalf("expected the push slot to be released while waiting for the type slot, released %d times", released)
+	}
+}
+
+func TestSendPushesTypePushLimits(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	semaphore := make(chan struct{}, 1)
+	limits := map[string]chan struct{}{v3.EndpointType: make(chan struct{}, 1)}
+	queue := NewPushQueue()
+	defer queue.ShutDown()
+
+	proxies := createProxies(2)
+	go doSendPushes(stopCh, semaphore, queue, limits)
+
+	// The incremental push is never completed, but does not hold the only slot.
+	queue.Enqueue(proxies[0], &model.PushRequest{Push: &model.PushContext{}})
+	select {
+	case <-proxies[0].pushChannel:
+	case <-time.After(time.Second):
+		t.Fatal("expected an incremental push")
+	}
+	queue.Enqueue(proxies[1], &model.PushRequest{Full: true, Push: &model.PushContext{}})
+	select {
+	case p := <-proxies[1].pushChannel:
+		p.done()
+	case <-time.After(time.Second):
+		t.Fatal("expected the full push not to be blocked by the incremental push")
+	}
+}
+
 type fakeStream struct {
 	grpc.ServerStream
 }
@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errDraining is returned to the connections closed by Drain. Proxies reconnect, to another replica, on Unavailable.
+var errDraining = status.Error(codes.Unavailable, "istiod is shutting down")
+
+// Drain closes the connections of the server at random times spread over the duration, so that the proxies do not
+// all reconnect to the remaining replicas at the same time, as they would when the gRPC servers are stopped. The
+// server reports as not ready and rejects new connections while draining. Drain returns once all the connections
+// are closed.
+func (s *DiscoveryServer) Drain(duration time.Duration) {
+	if !s.draining.CAS(false, true) {
+		return
+	}
+	clients := s.AllClients()
+	log.Infof("ADS: draining %d connections over %v", len(clients), duration)
+	wg := sync.WaitGroup{}
+	for _, con := range clients {
+		con := con
+		wg.Add(1)
+		var delay time.Duration
+		if duration > 0 {
+			delay = time.Duration(rand.Int63n(int64(duration)))
+		}
+		time.AfterFunc(delay, func() {
+			defer wg.Done()
+			drainedConnections.Increment()
+			con.Stop()
+		})
+	}
+	wg.Wait()
+}
+
+// stoppedStatus is the status returned to a connection closed by the server.
+func (s *DiscoveryServer) stoppedStatus() error {
+	if s.draining.Load() {
+		return errDraining
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestDrain(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	ads := s.ConnectADS().WithType(v3.ClusterType)
+	ads.RequestResponseAck(t, nil)
+	delta := s.ConnectDeltaADS().WithType(v3.ClusterType)
+	delta.RequestResponseAck(nil)
+	retry.UntilSuccessOrFail(t, func() error {
+		if n := len(s.Discovery.Clients()); n != 2 {
+			return fmt.Errorf("expected 2 connections, got %d", n)
+		}
+		return nil
+	}, retry.Timeout(time.Second*5))
+
+	start := time.Now()
+	s.Discovery.Drain(100 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("drain took %v, expected it to be bounded by the duration", elapsed)
+	}
+	if s.Discovery.IsServerReady() {
+		t.Fatal("expected a draining server to not be ready")
+	}
+	if err := ads.ExpectError(t); err == nil || !strings.Contains(err.Error(), "shutting down") {
+		t.Fatalf("expected the ADS connection to be closed as draining, got %v", err)
+	}
+	if err := delta.ExpectError(); err == nil || !strings.Contains(err.Error(), "shutting down") {
+		t.Fatalf("expected the delta connection to be closed as draining, got %v", err)
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		if n := len(s.Discovery.AllClients()); n != 0 {
+			return fmt.Errorf("expected drained connections to be removed, got %d connections", n)
+		}
+		return nil
+	}, retry.Timeout(time.Second*5))
+}
@@ -0,0 +1,167 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+// generateTypes are the types /debug/generate accepts by their short name, as reported in the logs, such as CDS.
+var generateTypes = []string{
+	v3.ClusterType,
+	v3.ListenerType,
+	v3.RouteType,
+	v3.EndpointType,
+	v3.SecretType,
+	v3.NameTableType,
+	v3.ProxyConfigType,
+	v3.ExtensionConfigurationType,
+}
+
+// resolveTypeURL returns the type URL of a type, given either as a type URL or by its short name.
+func resolveTypeURL(typ string) string {
+	for _, typeURL := range generateTypes {
+		if strings.EqualFold(typ, v3.GetShortType(typeURL)) {
+			return typeURL
+		}
+	}
+	return typ
+}
+
+// generatez runs the generator of a type for a proxy, and returns the resources as a discovery response, without
+// sending them. The proxy is either the connected proxy requested by proxyID, or a proxy synthesized from the
+// Envoy node POSTed, such as the node of /debug/connection_snapshot, so that the config of a proxy can be previewed
+// before it connects or before a change is applied.
+// The resources default to those watched by the connected proxy, and may be set with resourceNames. The connected
+// proxy itself is left untouched.
+// It is mapped to /debug/generate
+func (s *DiscoveryServer) generatez(w http.ResponseWriter, req *http.Request) {
+	typeURL := resolveTypeURL(req.URL.Query().Get("typeURL"))
+	if typeURL == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("You must provide a typeURL in the query string\n"))
+		return
+	}
+	if typeURL == v3.SecretType {
+		// Synthesized proxies are not authenticated, and could read any secret.
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("Secrets are not generated by /debug/generate\n"))
+		return
+	}
+
+	var con *Connection
+	watched := &model.WatchedResource{TypeUrl: typeURL}
+	if req.Method == http.MethodPost {
+		node, err := readNode(req.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error() + "\n"))
+			return
+		}
+		proxy, err := s.synthesizeProxy(node)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error() + "\n"))
+			return
+		}
+		con = &Connection{proxy: proxy, node: node}
+	} else {
+		proxyID, live := s.getDebugConnection(req)
+		if live == nil {
+			s.errorHandler(w, proxyID, live)
+			return
+		}
+		if wr := live.Watched(typeURL); wr != nil {
+			// The names are updated by the requests of the proxy.
+			live.proxy.RLock()
+			watched.ResourceNames = wr.ResourceNames
+			live.proxy.RUnlock()
+		}
+		// The generators update the state of the proxy they generate for, so they run on a proxy synthesized
+		// from the node of the connected one rather than on the connected proxy itself.
+		proxy, err := s.synthesizeProxy(live.node)
+		if err != nil {
+			handleHTTPError(w, err)
+			return
+		}
+		proxy.DeltaXDS = live.proxy.DeltaXDS
+		con = &Connection{proxy: proxy, node: live.node}
+	}
+	if names := req.URL.Query().Get("resourceNames"); names != "" {
+		watched.ResourceNames = strings.Split(names, ",")
+	}
+
+	gen := s.findGenerator(typeURL, con)
+	if gen == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprintf(w, "No generator for %s\n", typeURL)
+		return
+	}
+	push := s.globalPushContext()
+	// Generate the resources as for a full push to a new connection.
+	res, _, err := gen.Generate(con.proxy, push, watched, &model.PushRequest{Full: true, Push: push, Start: time.Now()})
+	if err != nil {
+		handleHTTPError(w, err)
+		return
+	}
+	resp := &discovery.DiscoveryResponse{
+		TypeUrl:     typeURL,
+		VersionInfo: push.PushVersion,
+	}
+	for _, r := range res {
+		resp.Resources = append(resp.Resources, r.Resource)
+	}
+	writeJSON(w, resp)
+}
+
+// readNode reads an Envoy node in JSON.
+func readNode(body io.Reader) (*core.Node, error) {
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	node := &core.Node{}
+	if err := protomarshal.UnmarshalAllowUnknown(b, node); err != nil {
+		return nil, fmt.Errorf("invalid node: %v", err)
+	}
+	if node.Id == "" {
+		return nil, fmt.Errorf("invalid node: missing id")
+	}
+	return node, nil
+}
+
+// synthesizeProxy builds a proxy from an Envoy node as if it connected, without registering it.
+func (s *DiscoveryServer) synthesizeProxy(node *core.Node) (*model.Proxy, error) {
+	proxy, err := s.initProxyMetadata(node)
+	if err != nil {
+		return nil, err
+	}
+	if alias, exists := s.ClusterAliases[proxy.Metadata.ClusterID]; exists {
+		proxy.Metadata.ClusterID = alias
+	}
+	s.initializeProxyState(node, proxy)
+	return proxy, nil
+}
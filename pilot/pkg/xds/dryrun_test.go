@@ -0,0 +1,87 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+func TestGeneratez(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	ads := s.ConnectADS().WithType(v3.ClusterType)
+	clusters := ads.RequestResponseAck(t, nil)
+
+	generate := func(t *testing.T, method, query string, body io.Reader, wantCode int) *discovery.DiscoveryResponse {
+		t.Helper()
+		req, err := http.NewRequest(method, "/debug/generate?"+query, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(s.Discovery.generatez).ServeHTTP(rr, req)
+		if rr.Code != wantCode {
+			t.Fatalf("wanted response code %v, got %v: %s", wantCode, rr.Code, rr.Body.String())
+		}
+		if wantCode != http.StatusOK {
+			return nil
+		}
+		resp := &discovery.DiscoveryResponse{}
+		if err := protomarshal.Unmarshal(rr.Body.Bytes(), resp); err != nil {
+			t.Fatalf("invalid response %v: %s", err, rr.Body.String())
+		}
+		return resp
+	}
+
+	t.Run("connected proxy", func(t *testing.T) {
+		resp := generate(t, "GET", "proxyID=test.default&typeURL=cds", nil, http.StatusOK)
+		if resp.TypeUrl != v3.ClusterType || len(resp.Resources) != len(clusters.Resources) {
+			t.Fatalf("expected the %d clusters sent to the proxy, got %d of %s",
+				len(clusters.Resources), len(resp.Resources), resp.TypeUrl)
+		}
+		// Generating does not update the state of the connected proxy.
+		proxy := s.Discovery.Clients()[0].proxy
+		proxy.WasmPluginPlacement = "pushed"
+		generate(t, "GET", "proxyID=test.default&typeURL=lds", nil, http.StatusOK)
+		if proxy.WasmPluginPlacement != "pushed" {
+			t.Fatalf("expected the connected proxy to be unchanged, got placement %q", proxy.WasmPluginPlacement)
+		}
+	})
+	t.Run("synthesized proxy", func(t *testing.T) {
+		node := `{"id": "sidecar~1.1.1.1~other.default~default.svc.cluster.local", "metadata": {"CLUSTER_ID": "Kubernetes"}}`
+		resp := generate(t, "POST", "typeURL="+v3.ListenerType, strings.NewReader(node), http.StatusOK)
+		if len(resp.Resources) == 0 {
+			t.Fatalf("expected listeners for the synthesized proxy")
+		}
+		if len(s.Discovery.Clients()) != 1 {
+			t.Fatalf("expected the synthesized proxy not to be registered")
+		}
+	})
+	t.Run("invalid requests", func(t *testing.T) {
+		generate(t, "GET", "proxyID=test.default", nil, http.StatusBadRequest)
+		generate(t, "GET", "proxyID=test.default&typeURL=sds", nil, http.StatusBadRequest)
+		generate(t, "GET", "typeURL=cds", nil, http.StatusBadRequest)
+		generate(t, "GET", "proxyID=not-found&typeURL=cds", nil, http.StatusNotFound)
+		generate(t, "POST", "typeURL=cds", strings.NewReader(`{}`), http.StatusBadRequest)
+	})
+}
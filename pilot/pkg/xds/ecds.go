@@ -19,6 +19,7 @@ import (
 
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/config/schema/gvk"
 )
 
@@ -27,7 +28,7 @@ type EcdsGenerator struct {
 	Server *DiscoveryServer
 }
 
-var _ model.XdsResourceGenerator = &EcdsGenerator{}
+var _ model.XdsDeltaResourceGenerator = &EcdsGenerator{}
 
 func ecdsNeedsPush(req *model.PushRequest) bool {
 	if req == nil {
@@ -59,9 +60,49 @@ func (e *EcdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w
 	if !ecdsNeedsPush(req) {
 		return nil, model.DefaultXdsLogDetails, nil
 	}
-	ec := e.Server.ConfigGenerator.BuildExtensionConfiguration(proxy, push, w.ResourceNames)
+	return e.build(proxy, push, w.ResourceNames), model.DefaultXdsLogDetails, nil
+}
+
+// GenerateDeltas returns the ECDS resources that changed for a given proxy. When only WasmPlugins were updated, only
+// the extension configs of those plugins are regenerated, and the ones the proxy subscribed to that no longer apply
+// to it are removed. Other updates, such as EnvoyFilters, fall back to generating all the subscribed resources.
+func (e *EcdsGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
+	w *model.WatchedResource) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
+	if !ecdsNeedsPush(updates) {
+		return nil, nil, model.DefaultXdsLogDetails, false, nil
+	}
+	updated, ok := updatedWasmPluginExtensionConfigs(updates)
+	if !ok {
+		res, logs, err := e.Generate(proxy, push, w, updates)
+		return res, nil, logs, false, err
+	}
+	names := make([]string, 0, len(updated))
+	for _, name := range w.ResourceNames {
+		if updated.Contains(name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil, model.DefaultXdsLogDetails, true, nil
+	}
+	resources := e.build(proxy, push, names)
+	generated := sets.NewSet()
+	for _, r := range resources {
+		generated.Insert(r.Name)
+	}
+	var deleted model.DeletedResources
+	for _, name := range names {
+		if !generated.Contains(name) {
+			deleted = append(deleted, name)
+		}
+	}
+	return resources, deleted, model.XdsLogDetails{Incremental: true}, true, nil
+}
+
+func (e *EcdsGenerator) build(proxy *model.Proxy, push *model.PushContext, names []string) model.Resources {
+	ec := e.Server.ConfigGenerator.BuildExtensionConfiguration(proxy, push, names)
 	if ec == nil {
-		return nil, model.DefaultXdsLogDetails, nil
+		return nil
 	}
 
 	resources := make(model.Resources, 0, len(ec))
@@ -71,5 +112,21 @@ func (e *EcdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w
 			Resource: util.MessageToAny(c),
 		})
 	}
-	return resources, model.DefaultXdsLogDetails, nil
+	return resources
+}
+
+// updatedWasmPluginExtensionConfigs returns the names of the extension configs of the WasmPlugins updated by the
+// push request, and false if the request updated any other kind of config.
+func updatedWasmPluginExtensionConfigs(req *model.PushRequest) (sets.Set, bool) {
+	if req == nil || len(req.ConfigsUpdated) == 0 {
+		return nil, false
+	}
+	names := sets.NewSet()
+	for config := range req.ConfigsUpdated {
+		if config.Kind != gvk.WasmPlugin {
+			return nil, false
+		}
+		names.Insert(config.Namespace + "." + config.Name)
+	}
+	return names, true
 }
@@ -19,8 +19,10 @@ import (
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 
+	extensions "istio.io/api/extensions/v1alpha1"
 	"istio.io/istio/pilot/pkg/xds"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/schema/gvk"
 )
 
 func TestECDS(t *testing.T) {
@@ -47,3 +49,94 @@ func TestECDS(t *testing.T) {
 		t.Errorf("extension config name got %v want %v", ec.Name, wantExtensionConfigName)
 	}
 }
+
+const ecdsWasmPlugins = `
+apiVersion: extensions.istio.io/v1alpha1
+kind: WasmPlugin
+metadata:
+  name: a
+  namespace: istio-system
+spec:
+  url: file:///etc/a.wasm
+  phase: AUTHN
+---
+apiVersion: extensions.istio.io/v1alpha1
+kind: WasmPlugin
+metadata:
+  name: b
+  namespace: istio-system
+spec:
+  url: file:///etc/b.wasm
+  phase: AUTHN
+`
+
+func resourceNames(res []*discovery.Resource) []string {
+	names := make([]string, 0, len(res))
+	for _, r := range res {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func updateWasmPlugin(t *testing.T, s *xds.FakeDiscoveryServer, name string, update func(*extensions.WasmPlugin)) {
+	t.Helper()
+	cfg := s.Store().Get(gvk.WasmPlugin, name, "istio-system")
+	if cfg == nil {
+		t.Fatalf("WasmPlugin %s not found", name)
+	}
+	// Update a copy, as the stored config may be read concurrently by the pushes.
+	updated := cfg.DeepCopy()
+	update(updated.Spec.(*extensions.WasmPlugin))
+	if _, err := s.Store().Update(updated); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestECDSDelta(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: ecdsWasmPlugins})
+	ads := s.ConnectDeltaADS().WithType(v3.ExtensionConfigurationType)
+	res := ads.RequestResponseAck(&discovery.DeltaDiscoveryRequest{
+		ResourceNamesSubscribe: []string{"istio-system.a", "istio-system.b"},
+	})
+	if len(res.Resources) != 2 {
+		t.Fatalf("expected the 2 subscribed extension configs, got %v", resourceNames(res.Resources))
+	}
+
+	// Only the updated plugin is pushed.
+	updateWasmPlugin(t, s, "a", func(p *extensions.WasmPlugin) {
+		p.Url = "file:///etc/a-v2.wasm"
+	})
+	res = ads.ExpectResponse()
+	if len(res.Resources) != 1 || res.Resources[0].Name != "istio-system.a" || len(res.RemovedResources) != 0 {
+		t.Fatalf("expected only istio-system.a to be pushed, got %v removed %v",
+			resourceNames(res.Resources), res.RemovedResources)
+	}
+
+	// A deleted plugin is removed.
+	if err := s.Store().Delete(gvk.WasmPlugin, "b", "istio-system", nil); err != nil {
+		t.Fatal(err)
+	}
+	res = ads.ExpectResponse()
+	if len(res.Resources) != 0 || len(res.RemovedResources) != 1 || res.RemovedResources[0] != "istio-system.b" {
+		t.Fatalf("expected istio-system.b to be removed, got %v removed %v",
+			resourceNames(res.Resources), res.RemovedResources)
+	}
+}
+
+func TestECDSWasmPluginSkipsLDS(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: ecdsWasmPlugins})
+	ads := s.ConnectADS().WithType(v3.ListenerType)
+	ads.RequestResponseAck(t, nil)
+
+	// The listeners only reference the plugins, so a configuration change does not push LDS.
+	updateWasmPlugin(t, s, "a", func(p *extensions.WasmPlugin) {
+		p.Url = "file:///etc/a-v2.wasm"
+	})
+	ads.ExpectNoResponse(t)
+
+	// Moving a plugin to another phase changes the filters of the listeners.
+	updateWasmPlugin(t, s, "a", func(p *extensions.WasmPlugin) {
+		p.Phase = extensions.PluginPhase_STATS
+	})
+	ads.ExpectResponse(t)
+}
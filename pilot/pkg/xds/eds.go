@@ -106,18 +106,28 @@ func (s *DiscoveryServer) EDSUpdate(shard model.ShardKey, serviceName string, na
 	istioEndpoints []*model.IstioEndpoint) {
 	inboundEDSUpdates.Increment()
 	// Update the endpoint shards
-	pushType := s.edsCacheUpdate(shard, serviceName, namespace, istioEndpoints)
+	pushType, diff := s.edsCacheUpdate(shard, serviceName, namespace, istioEndpoints)
+	if pushType == IncrementalPush && features.EnableEDSEndpointDiff && diff.Empty() {
+		log.Debugf("No push, endpoints of %s/%s at shard %v did not change", namespace, serviceName, shard)
+		return
+	}
 	if pushType == IncrementalPush || pushType == FullPush {
+		key := model.ConfigKey{
+			Kind:      gvk.ServiceEntry,
+			Name:      serviceName,
+			Namespace: namespace,
+		}
+		req := &model.PushRequest{
+			Full:           pushType == FullPush,
+			ConfigsUpdated: map[model.ConfigKey]struct{}{key: {}},
+			Reason:         []model.TriggerReason{model.EndpointUpdate},
+		}
+		if pushType == IncrementalPush && features.EnableEDSEndpointDiff {
+			// Let the incremental push skip the clusters whose endpoints did not change.
+			req.EndpointsUpdated = map[model.ConfigKey]*model.EndpointChanges{key: diff.Changes()}
+		}
 		// Trigger a push
-		s.ConfigUpdate(&model.PushRequest{
-			Full: pushType == FullPush,
-			ConfigsUpdated: map[model.ConfigKey]struct{}{{
-				Kind:      gvk.ServiceEntry,
-				Name:      serviceName,
-				Namespace: namespace,
-			}: {}},
-			Reason: []model.TriggerReason{model.EndpointUpdate},
-		})
+		s.ConfigUpdate(req)
 	}
 }
 
@@ -138,16 +148,17 @@ func (s *DiscoveryServer) EDSCacheUpdate(shard model.ShardKey, serviceName strin
 // edsCacheUpdate updates EndpointShards data by clusterID, hostname, IstioEndpoints.
 // It also tracks the changes to ServiceAccounts. It returns whether endpoints need to be pushed and
 // it also returns if they need to be pushed whether a full push is needed or incremental push is sufficient.
+// The returned diff holds the endpoints of the shard that were added, removed or updated.
 func (s *DiscoveryServer) edsCacheUpdate(shard model.ShardKey, hostname string, namespace string,
-	istioEndpoints []*model.IstioEndpoint) PushType {
+	istioEndpoints []*model.IstioEndpoint) (PushType, EndpointDiff) {
 	if len(istioEndpoints) == 0 {
 		// Should delete the service EndpointShards when endpoints become zero to prevent memory leak,
 		// but we should not delete the keys from EndpointShardsByService map - that will trigger
 		// unnecessary full push which can become a real problem if a pod is in crashloop and thus endpoints
 		// flip flopping between 1 and 0.
-		s.deleteEndpointShards(shard, hostname, namespace)
+		removed := s.deleteEndpointShards(shard, hostname, namespace)
 		log.Infof("Incremental push, service %s at shard %v has no endpoints", hostname, shard)
-		return IncrementalPush
+		return IncrementalPush, EndpointDiff{Removed: removed}
 	}
 
 	pushType := IncrementalPush
@@ -162,6 +173,10 @@ func (s *DiscoveryServer) edsCacheUpdate(shard model.ShardKey, hostname string,
 	ep.mutex.Lock()
 	oldIstioEndpoints := ep.Shards[shard]
 	ep.Shards[shard] = istioEndpoints
+	var diff EndpointDiff
+	if features.EnableEDSEndpointDiff {
+		diff = DiffEndpoints(oldIstioEndpoints, istioEndpoints)
+	}
 	// Check if ServiceAccounts have changed. We should do a full push if they have changed.
 	saUpdated := s.UpdateServiceAccount(ep, hostname)
 
@@ -215,7 +230,7 @@ func (s *DiscoveryServer) edsCacheUpdate(shard model.ShardKey, hostname string,
 		}
 	}
 
-	return pushType
+	return pushType, diff
 }
 
 func (s *DiscoveryServer) RemoveShard(shardKey model.ShardKey) {
@@ -255,14 +270,16 @@ func (s *DiscoveryServer) getOrCreateEndpointShard(serviceName, namespace string
 }
 
 // deleteEndpointShards deletes matching endpoint shards from EndpointShardsByService map. This is called when
-// endpoints are deleted.
-func (s *DiscoveryServer) deleteEndpointShards(shard model.ShardKey, serviceName, namespace string) {
+// endpoints are deleted. It returns the endpoints of the deleted shard.
+func (s *DiscoveryServer) deleteEndpointShards(shard model.ShardKey, serviceName, namespace string) []*model.IstioEndpoint {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	var removed []*model.IstioEndpoint
 	if s.EndpointShardsByService[serviceName] != nil &&
 		s.EndpointShardsByService[serviceName][namespace] != nil {
 		epShards := s.EndpointShardsByService[serviceName][namespace]
 		epShards.mutex.Lock()
+		removed = epShards.Shards[shard]
 		delete(epShards.Shards, shard)
 		epShards.ServiceAccounts = sets.Set{}
 		for _, shard := range epShards.Shards {
@@ -280,6 +297,7 @@ func (s *DiscoveryServer) deleteEndpointShards(shard model.ShardKey, serviceName
 		}: {}})
 		epShards.mutex.Unlock()
 	}
+	return removed
 }
 
 // deleteService deletes all service related references from EndpointShardsByService. This is called
@@ -532,7 +550,8 @@ func (eds *EdsGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushCont
 var deltaConfigTypes = sets.NewSet(gvk.ServiceEntry.Kind)
 
 func shouldUseDeltaEds(req *model.PushRequest) bool {
-	if !req.Full {
+	// Incremental pushes are sent as deltas when their changed endpoints are known.
+	if !req.Full && !features.EnableEDSEndpointDiff {
 		return false
 	}
 	if len(req.ConfigsUpdated) > 0 {
@@ -568,6 +587,10 @@ func (eds *EdsGenerator) buildEndpoints(proxy *model.Proxy,
 			}
 		}
 		builder := NewEndpointBuilder(clusterName, proxy, push)
+		if !endpointsChanged(builder, req) {
+			// None of the updated endpoints belong to this cluster.
+			continue
+		}
 		if marshalledEndpoint, f := eds.Server.Cache.Get(builder); f && !features.EnableUnsafeAssertions {
 			// We skip cache if assertions are enabled, so that the cache will assert our eviction logic is correct
 			resources = append(resources, marshalledEndpoint)
@@ -616,6 +639,10 @@ func (eds *EdsGenerator) buildDeltaEndpoints(proxy *model.Proxy,
 		}
 
 		builder := NewEndpointBuilder(clusterName, proxy, push)
+		if builder.service != nil && !endpointsChanged(builder, req) {
+			// None of the updated endpoints belong to this cluster.
+			continue
+		}
 		if marshalledEndpoint, f := eds.Server.Cache.Get(builder); f && !features.EnableUnsafeAssertions {
 			// We skip cache if assertions are enabled, so that the cache will assert our eviction logic is correct
 			resources = append(resources, marshalledEndpoint)
@@ -0,0 +1,127 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package xds
+
+import (
+	"strconv"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+// EndpointDiff is the difference between two versions of the endpoints of a shard.
+type EndpointDiff struct {
+	// Added are the endpoints only present in the new version.
+	Added []*model.IstioEndpoint
+	// Removed are the endpoints only present in the old version.
+	Removed []*model.IstioEndpoint
+	// Updated are the endpoints present in both versions but with different attributes, in their new version.
+	Updated []*model.IstioEndpoint
+	// Previous are the old versions of the Updated endpoints, in the same order.
+	Previous []*model.IstioEndpoint
+}
+
+// endpointKey identifies an endpoint within the endpoints of a shard.
+func endpointKey(ep *model.IstioEndpoint) string {
+	return ep.Address + "/" + strconv.Itoa(int(ep.EndpointPort)) + "/" + ep.ServicePortName
+}
+
+// DiffEndpoints returns the endpoints added, removed and updated from oldEndpoints to newEndpoints.
+func DiffEndpoints(oldEndpoints, newEndpoints []*model.IstioEndpoint) EndpointDiff {
+	diff := EndpointDiff{}
+	old := make(map[string]*model.IstioEndpoint, len(oldEndpoints))
+	for _, ep := range oldEndpoints {
+		old[endpointKey(ep)] = ep
+	}
+	for _, ep := range newEndpoints {
+		key := endpointKey(ep)
+		prev, f := old[key]
+		if !f {
+			diff.Added = append(diff.Added, ep)
+			continue
+		}
+		delete(old, key)
+		if !endpointEquals(prev, ep) {
+			diff.Updated = append(diff.Updated, ep)
+			diff.Previous = append(diff.Previous, prev)
+		}
+	}
+	// Iterate over the old endpoints rather than the map to keep the order stable.
+	for _, ep := range oldEndpoints {
+		if _, f := old[endpointKey(ep)]; f {
+			diff.Removed = append(diff.Removed, ep)
+		}
+	}
+	return diff
+}
+
+// endpointEquals returns whether two endpoints with the same key are pushed identically to the proxies.
+func endpointEquals(a, b *model.IstioEndpoint) bool {
+	return a.Labels.Equals(b.Labels) &&
+		a.ServiceAccount == b.ServiceAccount &&
+		a.Network == b.Network &&
+		a.Locality == b.Locality &&
+		a.LbWeight == b.LbWeight &&
+		a.TLSMode == b.TLSMode &&
+		a.Namespace == b.Namespace &&
+		a.WorkloadName == b.WorkloadName &&
+		a.HostName == b.HostName &&
+		a.SubDomain == b.SubDomain &&
+		a.TunnelAbility == b.TunnelAbility &&
+		a.DiscoverabilityPolicy == b.DiscoverabilityPolicy &&
+		a.HealthStatus == b.HealthStatus
+}
+
+// Empty returns whether no endpoint was added, removed or updated.
+func (d EndpointDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Updated) == 0
+}
+
+// Changes returns the service ports and labels of the endpoints in the diff.
+func (d EndpointDiff) Changes() *model.EndpointChanges {
+	changes := &model.EndpointChanges{Ports: sets.NewSet()}
+	add := func(eps []*model.IstioEndpoint) {
+		for _, ep := range eps {
+			changes.Ports.Insert(ep.ServicePortName)
+			changes.Labels = append(changes.Labels, ep.Labels)
+		}
+	}
+	add(d.Added)
+	add(d.Removed)
+	add(d.Updated)
+	add(d.Previous)
+	return changes
+}
+
+// endpointsChanged returns whether the endpoints of the cluster built by b may have changed, according to the
+// endpoint changes of the request. Clusters of services whose changes are unknown are always considered changed.
+func endpointsChanged(b EndpointBuilder, req *model.PushRequest) bool {
+	if req.Full || b.service == nil {
+		return true
+	}
+	changes, f := req.EndpointsUpdated[model.ConfigKey{
+		Kind:      gvk.ServiceEntry,
+		Name:      string(b.hostname),
+		Namespace: b.service.Attributes.Namespace,
+	}]
+	if !f {
+		return true
+	}
+	svcPort, f := b.service.Ports.GetByPort(b.port)
+	if !f {
+		return true
+	}
+	return changes.Affects(svcPort.Name, getSubSetLabels(b.DestinationRule(), b.subsetName))
+}
@@ -0,0 +1,130 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package xds
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config/labels"
+)
+
+func TestDiffEndpoints(t *testing.T) {
+	ep := func(address, port, version string) *model.IstioEndpoint {
+		return &model.IstioEndpoint{
+			Address:         address,
+			ServicePortName: port,
+			EndpointPort:    8080,
+			Labels:          labels.Instance{"version": version},
+		}
+	}
+	addresses := func(eps []*model.IstioEndpoint) []string {
+		var out []string
+		for _, ep := range eps {
+			out = append(out, ep.Address+"/"+ep.ServicePortName)
+		}
+		return out
+	}
+	cases := []struct {
+		name    string
+		old     []*model.IstioEndpoint
+		new     []*model.IstioEndpoint
+		added   []string
+		removed []string
+		updated []string
+		ports   sets.Set
+	}{
+		{
+			name:  "unchanged",
+			old:   []*model.IstioEndpoint{ep("1.1.1.1", "http", "v1")},
+			new:   []*model.IstioEndpoint{ep("1.1.1.1", "http", "v1")},
+			ports: sets.NewSet(),
+		},
+		{
+			name:  "added",
+			old:   []*model.IstioEndpoint{ep("1.1.1.1", "http", "v1")},
+			new:   []*model.IstioEndpoint{ep("1.1.1.1", "http", "v1"), ep("1.1.1.2", "grpc", "v1")},
+			added: []string{"1.1.1.2/grpc"},
+			ports: sets.NewSet("grpc"),
+		},
+		{
+			name:    "removed",
+			old:     []*model.IstioEndpoint{ep("1.1.1.1", "http", "v1"), ep("1.1.1.1", "grpc", "v1")},
+			new:     []*model.IstioEndpoint{ep("1.1.1.1", "http", "v1")},
+			removed: []string{"1.1.1.1/grpc"},
+			ports:   sets.NewSet("grpc"),
+		},
+		{
+			name:    "updated",
+			old:     []*model.IstioEndpoint{ep("1.1.1.1", "http", "v1"), ep("1.1.1.2", "http", "v1")},
+			new:     []*model.IstioEndpoint{ep("1.1.1.1", "http", "v2"), ep("1.1.1.2", "http", "v1")},
+			updated: []string{"1.1.1.1/http"},
+			ports:   sets.NewSet("http"),
+		},
+		{
+			name:    "replaced",
+			old:     []*model.IstioEndpoint{ep("1.1.1.1", "http", "v1")},
+			new:     []*model.IstioEndpoint{ep("1.1.1.2", "http", "v1")},
+			added:   []string{"1.1.1.2/http"},
+			removed: []string{"1.1.1.1/http"},
+			ports:   sets.NewSet("http"),
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffEndpoints(tt.old, tt.new)
+			if got := addresses(diff.Added); !reflect.DeepEqual(got, tt.added) {
+				t.Errorf("got added %v, want %v", got, tt.added)
+			}
+			if got := addresses(diff.Removed); !reflect.DeepEqual(got, tt.removed) {
+				t.Errorf("got removed %v, want %v", got, tt.removed)
+			}
+			if got := addresses(diff.Updated); !reflect.DeepEqual(got, tt.updated) {
+				t.Errorf("got updated %v, want %v", got, tt.updated)
+			}
+			if diff.Empty() != (len(tt.added)+len(tt.removed)+len(tt.updated) == 0) {
+				t.Errorf("got empty %v for diff %+v", diff.Empty(), diff)
+			}
+			if got := diff.Changes().Ports; !got.Equals(tt.ports) {
+				t.Errorf("got ports %v, want %v", got, tt.ports)
+			}
+		})
+	}
+}
+
+func TestEndpointChangesAffects(t *testing.T) {
+	// The endpoint moved from v1 to v2, so the clusters of both subsets are affected.
+	changes := DiffEndpoints(
+		[]*model.IstioEndpoint{{Address: "1.1.1.1", ServicePortName: "http", Labels: labels.Instance{"version": "v1"}}},
+		[]*model.IstioEndpoint{{Address: "1.1.1.1", ServicePortName: "http", Labels: labels.Instance{"version": "v2"}}},
+	).Changes()
+	cases := []struct {
+		port   string
+		subset labels.Collection
+		want   bool
+	}{
+		{port: "http", want: true},
+		{port: "grpc", want: false},
+		{port: "http", subset: labels.Collection{{"version": "v1"}}, want: true},
+		{port: "http", subset: labels.Collection{{"version": "v2"}}, want: true},
+		{port: "http", subset: labels.Collection{{"version": "v3"}}, want: false},
+	}
+	for _, tt := range cases {
+		if got := changes.Affects(tt.port, tt.subset); got != tt.want {
+			t.Errorf("Affects(%v, %v) = %v, want %v", tt.port, tt.subset, got, tt.want)
+		}
+	}
+}
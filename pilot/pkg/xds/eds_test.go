@@ -25,6 +25,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -39,6 +40,7 @@ import (
 	"istio.io/istio/pilot/pkg/networking"
 	"istio.io/istio/pilot/pkg/xds"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pilot/test/xdstest"
 	"istio.io/istio/pkg/adsc"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
@@ -653,6 +655,43 @@ func TestZeroEndpointShardSA(t *testing.T) {
 	}
 }
 
+func TestEndpointsDualStack(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: dual
+  namespace: default
+spec:
+  hosts:
+  - dual.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: STATIC
+  endpoints:
+  - address: 10.0.0.1
+  - address: fd00::1
+`})
+	cluster := "outbound|80||dual.example.com"
+	for _, tt := range []struct {
+		ips  []string
+		want []string
+	}{
+		{[]string{"1.1.1.1"}, []string{"10.0.0.1:80"}},
+		{[]string{"2001:db8::1"}, []string{"fd00::1:80"}},
+		{[]string{"1.1.1.1", "2001:db8::1"}, []string{"10.0.0.1:80", "fd00::1:80"}},
+	} {
+		proxy := s.SetupProxy(&model.Proxy{IPAddresses: tt.ips, Metadata: &model.NodeMetadata{DualStack: true}})
+		got := xdstest.ExtractLoadAssignments(s.Endpoints(proxy))[cluster]
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("proxy with IPs %v: got endpoints %v, want %v", tt.ips, got, tt.want)
+		}
+	}
+}
+
 func fullPush(s *xds.FakeDiscoveryServer) {
 	s.Discovery.Push(&model.PushRequest{Full: true})
 }
@@ -0,0 +1,38 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net/http"
+
+	"istio.io/istio/pilot/pkg/networking/egressaudit"
+)
+
+// externalDependenciesz reports the destinations outside of the registry each workload sends traffic to, through
+// the PassthroughCluster or the AuditPassthroughCluster, to migrate to the REGISTRY_ONLY outbound traffic policy.
+// It is mapped to /debug/external_dependencies
+func (s *DiscoveryServer) externalDependenciesz(w http.ResponseWriter, req *http.Request) {
+	report := &egressaudit.Report{Dependencies: []egressaudit.Dependency{}}
+	if s.ExternalTrafficSource != nil {
+		report.TrafficSource = s.ExternalTrafficSource.String()
+		deps, err := s.ExternalTrafficSource.ExternalTraffic(req.Context())
+		if err != nil {
+			report.Error = err.Error()
+		} else {
+			report.Dependencies = deps
+		}
+	}
+	writeJSON(w, report)
+}
@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/networking/egressaudit"
+)
+
+type fakeExternalTrafficSource []egressaudit.Dependency
+
+func (f fakeExternalTrafficSource) ExternalTraffic(context.Context) ([]egressaudit.Dependency, error) {
+	return f, nil
+}
+
+func (f fakeExternalTrafficSource) String() string {
+	return "fake"
+}
+
+func TestExternalDependenciesz(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	report := func() *egressaudit.Report {
+		req, err := http.NewRequest("GET", "/debug/external_dependencies", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(s.Discovery.externalDependenciesz).ServeHTTP(rr, req)
+		report := &egressaudit.Report{}
+		if err := json.Unmarshal(rr.Body.Bytes(), report); err != nil {
+			t.Fatalf("invalid json %v: %s", err, rr.Body.String())
+		}
+		return report
+	}
+
+	if r := report(); r.TrafficSource != "" || len(r.Dependencies) != 0 {
+		t.Fatalf("expected no dependencies without a traffic source, got %+v", r)
+	}
+
+	github := egressaudit.Dependency{SourceWorkload: "reviews-v1", SourceNamespace: "default", Destination: "api.github.com", Requests: 3}
+	s.Discovery.ExternalTrafficSource = fakeExternalTrafficSource{github}
+	if r := report(); r.TrafficSource != "fake" || len(r.Dependencies) != 1 || r.Dependencies[0] != github {
+		t.Fatalf("expected the dependency of the fake source, got %+v", r)
+	}
+}
@@ -70,6 +70,10 @@ type EndpointBuilder struct {
 	service         *model.Service
 	clusterLocal    bool
 	tunnelType      networking.TunnelType
+	// IP families of the proxy, used to filter the endpoints when it has dual stack enabled.
+	dualStack    bool
+	supportsIPv4 bool
+	supportsIPv6 bool
 
 	// These fields are provided for convenience only
 	subsetName string
@@ -99,6 +103,9 @@ func NewEndpointBuilder(clusterName string, proxy *model.Proxy, push *model.Push
 		clusterLocal:    push.IsClusterLocal(svc),
 		destinationRule: dr,
 		tunnelType:      GetTunnelBuilderType(clusterName, proxy, push),
+		dualStack:       proxy.DualStackEnabled(),
+		supportsIPv4:    proxy.SupportsIPv4(),
+		supportsIPv6:    proxy.SupportsIPv6(),
 
 		push:       push,
 		proxy:      proxy,
@@ -132,6 +139,9 @@ func (b EndpointBuilder) Key() string {
 		util.LocalityToString(b.locality),
 		b.tunnelType.ToString(),
 	}
+	if b.dualStack {
+		params = append(params, strconv.FormatBool(b.supportsIPv4), strconv.FormatBool(b.supportsIPv6))
+	}
 	if b.push != nil && b.push.AuthnPolicies != nil {
 		params = append(params, b.push.AuthnPolicies.GetVersion())
 	}
@@ -307,6 +317,15 @@ func (b *EndpointBuilder) buildLocalityLbEndpointsFromShards(
 			if svcPort.Name != ep.ServicePortName {
 				continue
 			}
+			// The secondary addresses of a workload are not load balanced to.
+			if ep.Secondary {
+				continue
+			}
+			// Skip the endpoints of the IP family the proxy does not support, such as the IPv6 endpoints of a
+			// dual-stack service for an IPv4 only proxy.
+			if b.dualStack && !b.proxy.SupportsAddress(ep.Address) {
+				continue
+			}
 			// Port labels
 			if !epLabels.HasSubsetOf(ep.Labels) {
 				continue
@@ -343,6 +362,7 @@ func (b *EndpointBuilder) buildLocalityLbEndpointsFromShards(
 			locLbEps.append(ep, ep.EnvoyEndpoint, ep.TunnelAbility)
 		}
 	}
+	localityLoad := shards.LocalityLoad
 	shards.mutex.Unlock()
 
 	locEps := make([]*LocLbEndpointsAndOptions, 0, len(localityEpMap))
@@ -364,6 +384,9 @@ func (b *EndpointBuilder) buildLocalityLbEndpointsFromShards(
 		}
 		locEps = append(locEps, locLbEps)
 	}
+	if features.EnableLoadAwareLocalityWeights {
+		applyLocalityLoad(locEps, localityLoad)
+	}
 
 	if len(locEps) == 0 {
 		b.push.AddMetric(model.ProxyStatusClusterNoInstances, b.clusterName, "", "")
@@ -403,8 +426,11 @@ func (b *EndpointBuilder) createClusterLoadAssignment(llbOpts []*LocLbEndpointsA
 func buildEnvoyLbEndpoint(e *model.IstioEndpoint) *endpoint.LbEndpoint {
 	addr := util.BuildAddress(e.Address, e.EndpointPort)
 	healthStatus := core.HealthStatus_HEALTHY
-	if e.HealthStatus == model.UnHealthy {
+	switch e.HealthStatus {
+	case model.UnHealthy:
 		healthStatus = core.HealthStatus_UNHEALTHY
+	case model.Draining:
+		healthStatus = core.HealthStatus_DRAINING
 	}
 
 	ep := &endpoint.LbEndpoint{
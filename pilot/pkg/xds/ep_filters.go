@@ -45,7 +45,7 @@ func (b *EndpointBuilder) EndpointsByNetworkFilter(endpoints []*LocLbEndpointsAn
 	// remote gateways (if any)
 	filtered := make([]*LocLbEndpointsAndOptions, 0)
 
-	// Scale all weights by the lcm of gateways per network and gateways per cluster.
+	// Scale all weights by the lcm of the total gateway weight per network and per cluster.
 	// This will allow us to more easily spread traffic to the endpoint across multiple
 	// network gateways, increasing reliability of the endpoint.
 	scaleFactor := b.push.NetworkManager().GetLBWeightScaleFactor()
@@ -83,6 +83,18 @@ func (b *EndpointBuilder) EndpointsByNetworkFilter(endpoints []*LocLbEndpointsAn
 			epCluster := istioEndpoint.Locality.ClusterID
 			gateways := b.selectNetworkGateways(epNetwork, epCluster)
 
+			// Endpoints of remote networks with a 1:1 NAT are reached directly at their translated address.
+			if !b.proxy.InNetwork(epNetwork) {
+				if translated, ok := b.push.NetworkManager().TranslateAddress(epNetwork, istioEndpoint.Address); ok {
+					if !b.canViewNetwork(epNetwork) {
+						continue
+					}
+					lbEp = translateEndpointAddress(lbEp, translated)
+					lbEndpoints.append(ep.istioEndpoints[i], lbEp, ep.istioEndpoints[i].TunnelAbility)
+					continue
+				}
+			}
+
 			// Check if the endpoint is directly reachable. It's considered directly reachable if
 			// the endpoint is either on the local network or on a remote network that can be reached
 			// directly from the local network.
@@ -122,6 +134,10 @@ func (b *EndpointBuilder) EndpointsByNetworkFilter(endpoints []*LocLbEndpointsAn
 				epWeight = 1
 			}
 			epAddr := util.BuildAddress(gw.Addr, gw.Port)
+			if gw.Tunneled {
+				// The traffic is sent to the local listener tunneling it to the gateway through HTTP CONNECT.
+				epAddr = util.BuildAddress(gw.TunnelAddress(), 0)
+			}
 
 			// Generate a fake IstioEndpoint to carry network and cluster information.
 			gwIstioEp := &model.IstioEndpoint{
@@ -189,12 +205,31 @@ func (b *EndpointBuilder) scaleEndpointLBWeight(ep *endpoint.LbEndpoint, scaleFa
 	return weight
 }
 
+// translateEndpointAddress returns a copy of the endpoint with its address replaced by the translated address,
+// keeping its port.
+func translateEndpointAddress(ep *endpoint.LbEndpoint, address string) *endpoint.LbEndpoint {
+	ep = proto.Clone(ep).(*endpoint.LbEndpoint)
+	port := ep.GetEndpoint().GetAddress().GetSocketAddress().GetPortValue()
+	ep.GetEndpoint().Address = util.BuildAddress(address, port)
+	return ep
+}
+
 // Apply the weight for this endpoint to the network gateways.
 func splitWeightAmongGateways(weight uint32, gateways []model.NetworkGateway, gatewayWeights map[model.NetworkGateway]uint32) {
-	// Spread the weight across the gateways.
-	weightPerGateway := weight / uint32(len(gateways))
+	// Spread the weight across the gateways, in proportion to their weights. The weight was scaled by the total
+	// weight of the gateways, so that it is split exactly. Drained gateways get no traffic.
+	total := uint64(0)
 	for _, gateway := range gateways {
-		gatewayWeights[gateway] += weightPerGateway
+		total += uint64(gateway.LBWeight())
+	}
+	if total == 0 {
+		return
+	}
+	for _, gateway := range gateways {
+		if gateway.LBWeight() == 0 {
+			continue
+		}
+		gatewayWeights[gateway] += uint32(uint64(weight) * uint64(gateway.LBWeight()) / total)
 	}
 }
 
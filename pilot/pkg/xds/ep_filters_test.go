@@ -24,6 +24,7 @@ import (
 	networking "istio.io/api/networking/v1alpha3"
 	security "istio.io/api/security/v1beta1"
 	"istio.io/api/type/v1beta1"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config"
@@ -183,6 +184,167 @@ func TestEndpointsByNetworkFilter(t *testing.T) {
 	runNetworkFilterTest(t, env, networkFiltered)
 }
 
+func TestEndpointsByNetworkFilter_Tunnel(t *testing.T) {
+	defer func(v string) { features.CrossNetworkTunnelNetworks = v }(features.CrossNetworkTunnelNetworks)
+	features.CrossNetworkTunnelNetworks = "network2"
+	env := environment(t)
+	env.Env().InitNetworksManager(env.Discovery)
+	cases := []struct {
+		name string
+		conn *Connection
+		want []string
+	}{
+		{
+			name: "from_network1",
+			conn: xdsConnection("network1", "cluster1a"),
+			want: []string{
+				"10.0.0.1",
+				"10.0.0.2",
+				"@istio-tunnel/2.2.2.2:80",
+				"@istio-tunnel/2.2.2.20:80",
+				"@istio-tunnel/2.2.2.21:80",
+				"40.0.0.1",
+			},
+		},
+		{
+			name: "from_network2",
+			conn: xdsConnection("network2", "cluster2a"),
+			want: []string{"20.0.0.1", "20.0.0.2", "20.0.0.3", "1.1.1.1", "40.0.0.1"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := env.SetupProxy(tt.conn.proxy)
+			b := NewEndpointBuilder("outbound|80||example.ns.svc.cluster.local", proxy, env.PushContext())
+			testEndpoints := b.buildLocalityLbEndpointsFromShards(testShards(), &model.Port{Name: "http", Port: 80, Protocol: protocol.HTTP})
+			var got []string
+			for _, ep := range b.EndpointsByNetworkFilter(testEndpoints) {
+				for _, lbEp := range ep.llbEndpoints.LbEndpoints {
+					if pipe := lbEp.GetEndpoint().Address.GetPipe(); pipe != nil {
+						got = append(got, pipe.Path)
+					} else {
+						got = append(got, lbEp.GetEndpoint().Address.GetSocketAddress().Address)
+					}
+				}
+			}
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got endpoints %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpointsByNetworkFilter_AddressTranslation(t *testing.T) {
+	defer func(v string) { features.NetworkAddressTranslation = v }(features.NetworkAddressTranslation)
+	features.NetworkAddressTranslation = "network2:20.0.0.0/16=172.20.0.0/16"
+	env := environment(t)
+	env.Env().InitNetworksManager(env.Discovery)
+	cases := []struct {
+		name string
+		conn *Connection
+		want []string
+	}{
+		{
+			name: "from_network1",
+			conn: xdsConnection("network1", "cluster1a"),
+			// The endpoints of network2 are reached at their translated address instead of through its gateways.
+			want: []string{"10.0.0.1", "10.0.0.2", "172.20.0.1", "172.20.0.2", "172.20.0.3", "40.0.0.1"},
+		},
+		{
+			name: "from_network2",
+			conn: xdsConnection("network2", "cluster2a"),
+			want: []string{"20.0.0.1", "20.0.0.2", "20.0.0.3", "1.1.1.1", "40.0.0.1"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := env.SetupProxy(tt.conn.proxy)
+			b := NewEndpointBuilder("outbound|80||example.ns.svc.cluster.local", proxy, env.PushContext())
+			testEndpoints := b.buildLocalityLbEndpointsFromShards(testShards(), &model.Port{Name: "http", Port: 80, Protocol: protocol.HTTP})
+			var got []string
+			for _, ep := range b.EndpointsByNetworkFilter(testEndpoints) {
+				for _, lbEp := range ep.llbEndpoints.LbEndpoints {
+					got = append(got, lbEp.GetEndpoint().Address.GetSocketAddress().Address)
+				}
+			}
+			sort.Strings(got)
+			sort.Strings(tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got endpoints %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpointsByNetworkFilter_GatewayWeights(t *testing.T) {
+	cases := []struct {
+		name    string
+		weights string
+		want    map[string]uint32
+	}{
+		{
+			// The weight of cluster2b is split between its gateways in proportion to their weights.
+			name:    "weighted",
+			weights: "2.2.2.21=2",
+			want: map[string]uint32{
+				"10.0.0.1": 12,
+				"10.0.0.2": 12,
+				"2.2.2.2":  12,
+				"2.2.2.20": 8,
+				"2.2.2.21": 16,
+				"40.0.0.1": 12,
+			},
+		},
+		{
+			// The weights are scaled by the total weight of the gateways, so that they are split exactly.
+			name:    "uneven",
+			weights: "2.2.2.21=4",
+			want: map[string]uint32{
+				"10.0.0.1": 30,
+				"10.0.0.2": 30,
+				"2.2.2.2":  30,
+				"2.2.2.20": 12,
+				"2.2.2.21": 48,
+				"40.0.0.1": 30,
+			},
+		},
+		{
+			// No traffic is sent to a drained gateway.
+			name:    "drained",
+			weights: "2.2.2.21=0",
+			want: map[string]uint32{
+				"10.0.0.1": 2,
+				"10.0.0.2": 2,
+				"2.2.2.2":  2,
+				"2.2.2.20": 4,
+				"40.0.0.1": 2,
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(v string) { features.NetworkGatewayWeights = v }(features.NetworkGatewayWeights)
+			features.NetworkGatewayWeights = tt.weights
+			env := environment(t)
+			env.Env().InitNetworksManager(env.Discovery)
+			proxy := env.SetupProxy(xdsConnection("network1", "cluster1a").proxy)
+			b := NewEndpointBuilder("outbound|80||example.ns.svc.cluster.local", proxy, env.PushContext())
+			testEndpoints := b.buildLocalityLbEndpointsFromShards(testShards(), &model.Port{Name: "http", Port: 80, Protocol: protocol.HTTP})
+			got := map[string]uint32{}
+			for _, ep := range b.EndpointsByNetworkFilter(testEndpoints) {
+				for _, lbEp := range ep.llbEndpoints.LbEndpoints {
+					got[lbEp.GetEndpoint().Address.GetSocketAddress().Address] = lbEp.GetLoadBalancingWeight().GetValue()
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got weights %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestEndpointsByNetworkFilter_WithConfig(t *testing.T) {
 	noCrossNetwork := []networkFilterCase{
 		{
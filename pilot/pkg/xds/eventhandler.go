@@ -34,6 +34,11 @@ var AllEventTypesList = []EventType{v3.ClusterType, v3.ListenerType, v3.RouteTyp
 type DistributionStatusCache interface {
 	// RegisterEvent notifies the implementer of an xDS ACK, and must be non-blocking
 	RegisterEvent(conID string, eventType EventType, nonce string)
+	// RegisterDeltaEvent notifies the implementer of a delta xDS request, and must be non-blocking. Unlike SotW
+	// requests, delta requests without a nonce only change the subscriptions, and a NACK leaves the version
+	// previously acknowledged in place, so only the ACKs of responses, including the responses only removing
+	// resources, update the distributed version.
+	RegisterDeltaEvent(conID string, eventType EventType, nonce string, nack bool)
 	RegisterDisconnect(s string, types []EventType)
 	QueryLastNonce(conID string, eventType EventType) (noncePrefix string)
 }
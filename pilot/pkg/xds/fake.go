@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
@@ -40,6 +41,7 @@ import (
 	kubesecrets "istio.io/istio/pilot/pkg/credentials/kube"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/apigen"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3"
 	"istio.io/istio/pilot/pkg/networking/plugin"
 	"istio.io/istio/pilot/pkg/serviceregistry"
@@ -173,7 +175,8 @@ func NewFakeDiscoveryServer(t test.Failer, opts FakeOptions) *FakeDiscoveryServe
 		}
 	}
 	creds := kubesecrets.NewMulticluster(opts.DefaultClusterName)
-	s.Generators[v3.SecretType] = NewSecretGen(creds, s.Cache, opts.DefaultClusterName)
+	secretGen := NewSecretGen(creds, s.Cache, opts.DefaultClusterName)
+	s.Generators[v3.SecretType] = secretGen
 	for k8sCluster, objs := range k8sObjects {
 		client := kubelib.NewFakeClientWithVersion(opts.KubernetesVersion, objs...)
 		if opts.KubeClientModifier != nil {
@@ -227,9 +230,12 @@ func NewFakeDiscoveryServer(t test.Failer, opts FakeOptions) *FakeDiscoveryServe
 	cg.ServiceEntryRegistry.AppendServiceHandler(serviceHandler)
 	s.updateMutex.Lock()
 	s.Env = cg.Env()
+	s.Env.GatewayCredentials = secretGen
 	if err := s.Env.InitNetworksManager(s); err != nil {
 		t.Fatal(err)
 	}
+	// The generators were initialized with the dummy environment, without config store.
+	s.Generators["api"] = apigen.NewGenerator(s.Env.IstioConfigStore)
 	// Disable debounce to reduce test times
 	s.debounceOptions.debounceAfter = opts.DebounceTime
 	s.MemRegistry = cg.MemRegistry
@@ -294,7 +300,7 @@ func NewFakeDiscoveryServer(t test.Failer, opts FakeOptions) *FakeDiscoveryServe
 		listener = bufconn.Listen(buffer)
 	}
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(grpc.StatsHandler(NewCompressionStatsHandler()))
 	s.Register(grpcServer)
 	go func() {
 		if err := grpcServer.Serve(listener); err != nil && !(err == grpc.ErrServerStopped || err.Error() == "closed") {
@@ -383,6 +389,23 @@ func (f *FakeDiscoveryServer) ConnectDeltaADS() *DeltaAdsTest {
 	return NewDeltaAdsTest(f.t, conn)
 }
 
+// ReplayConnectionSnapshot connects to the server as the proxy captured in the snapshot, using the original
+// node, and requests each of the resources the proxy was watching, in push order. The responses are returned keyed
+// by type URL. This allows reproducing push issues from production against a local server, for offline debugging.
+func (f *FakeDiscoveryServer) ReplayConnectionSnapshot(snap *ConnectionSnapshot) map[string]*discovery.DiscoveryResponse {
+	f.t.Helper()
+	ads := f.ConnectADS()
+	responses := map[string]*discovery.DiscoveryResponse{}
+	for _, w := range orderWatchedResources(snap.WatchedResources) {
+		responses[w.TypeUrl] = ads.RequestResponseAck(f.t, &discovery.DiscoveryRequest{
+			Node:          snap.Node.Node,
+			TypeUrl:       w.TypeUrl,
+			ResourceNames: w.ResourceNames,
+		})
+	}
+	return responses
+}
+
 // Connect starts an ADS connection to the server using adsc. It will automatically be cleaned up when the test ends
 // watch can be configured to determine the resources to watch initially, and wait can be configured to determine what
 // resources we should initially wait for.
@@ -493,6 +516,8 @@ type FakeXdsEvent struct {
 	Kind      string
 	Host      string
 	Namespace string
+	// Shard is the registry shard of EDS and service events.
+	Shard     model.ShardKey
 	Endpoints int
 	PushReq   *model.PushRequest
 }
@@ -501,25 +526,34 @@ type FakeXdsUpdater struct {
 	// Events tracks notifications received by the updater
 	Events   chan FakeXdsEvent
 	Delegate model.XDSUpdater
+	// Timeout bounds how long ExpectPushFor waits for a push. Defaults to 1s.
+	Timeout time.Duration
+
+	mu sync.Mutex
+	// pushes records every push request received by the updater, in order.
+	pushes []*model.PushRequest
 }
 
 var _ model.XDSUpdater = &FakeXdsUpdater{}
 
 func (fx *FakeXdsUpdater) EDSUpdate(s model.ShardKey, hostname string, namespace string, entry []*model.IstioEndpoint) {
-	fx.Events <- FakeXdsEvent{Kind: "eds", Host: hostname, Namespace: namespace, Endpoints: len(entry)}
+	fx.Events <- FakeXdsEvent{Kind: "eds", Host: hostname, Namespace: namespace, Shard: s, Endpoints: len(entry)}
 	if fx.Delegate != nil {
 		fx.Delegate.EDSUpdate(s, hostname, namespace, entry)
 	}
 }
 
 func (fx *FakeXdsUpdater) EDSCacheUpdate(s model.ShardKey, hostname string, namespace string, entry []*model.IstioEndpoint) {
-	fx.Events <- FakeXdsEvent{Kind: "edscache", Host: hostname, Namespace: namespace, Endpoints: len(entry)}
+	fx.Events <- FakeXdsEvent{Kind: "edscache", Host: hostname, Namespace: namespace, Shard: s, Endpoints: len(entry)}
 	if fx.Delegate != nil {
 		fx.Delegate.EDSCacheUpdate(s, hostname, namespace, entry)
 	}
 }
 
 func (fx *FakeXdsUpdater) ConfigUpdate(req *model.PushRequest) {
+	fx.mu.Lock()
+	fx.pushes = append(fx.pushes, req)
+	fx.mu.Unlock()
 	fx.Events <- FakeXdsEvent{Kind: "xds", PushReq: req}
 	if fx.Delegate != nil {
 		fx.Delegate.ConfigUpdate(req)
@@ -533,17 +567,84 @@ func (fx *FakeXdsUpdater) ProxyUpdate(c cluster.ID, p string) {
 }
 
 func (fx *FakeXdsUpdater) SvcUpdate(s model.ShardKey, hostname string, namespace string, e model.Event) {
-	fx.Events <- FakeXdsEvent{Kind: "svcupdate", Host: hostname, Namespace: namespace}
+	fx.Events <- FakeXdsEvent{Kind: "svcupdate", Host: hostname, Namespace: namespace, Shard: s}
 	if fx.Delegate != nil {
 		fx.Delegate.SvcUpdate(s, hostname, namespace, e)
 	}
 }
 
-func (fx *FakeXdsUpdater) RemoveShard(_ model.ShardKey) {
-	fx.Events <- FakeXdsEvent{Kind: "removeshard"}
+func (fx *FakeXdsUpdater) RemoveShard(s model.ShardKey) {
+	fx.Events <- FakeXdsEvent{Kind: "removeshard", Shard: s}
 	fx.ConfigUpdate(&model.PushRequest{Full: true})
 }
 
+// Pushes returns all push requests received by the updater so far, in order. Unlike Events, reading them does
+// not consume them.
+func (fx *FakeXdsUpdater) Pushes() []*model.PushRequest {
+	fx.mu.Lock()
+	defer fx.mu.Unlock()
+	return append([]*model.PushRequest(nil), fx.pushes...)
+}
+
+// ExpectPushFor waits for a push request triggered by the reason that updates configs of all the kinds, and
+// returns it. An empty reason matches any reason. Other events received in the meantime are discarded.
+func (fx *FakeXdsUpdater) ExpectPushFor(t test.Failer, reason model.TriggerReason, kinds ...config.GroupVersionKind) *model.PushRequest {
+	t.Helper()
+	timeout := fx.Timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	deadline := time.After(timeout)
+	for {
+		select {
+		case e := <-fx.Events:
+			if e.Kind == "xds" && pushMatches(e.PushReq, reason, kinds) {
+				return e.PushReq
+			}
+		case <-deadline:
+			t.Fatalf("no push for reason %q and kinds %v within %v, got %v", reason, kinds, timeout, fx.Pushes())
+			return nil
+		}
+	}
+}
+
+// ExpectNoPushWithin fails if a push request is received within the duration. Other events are discarded.
+func (fx *FakeXdsUpdater) ExpectNoPushWithin(t test.Failer, duration time.Duration) {
+	t.Helper()
+	deadline := time.After(duration)
+	for {
+		select {
+		case e := <-fx.Events:
+			if e.Kind == "xds" {
+				t.Fatalf("unexpected push: reason %v, configs %v", e.PushReq.Reason, e.PushReq.ConfigsUpdated)
+			}
+		case <-deadline:
+			return
+		}
+	}
+}
+
+func pushMatches(req *model.PushRequest, reason model.TriggerReason, kinds []config.GroupVersionKind) bool {
+	if reason != "" {
+		found := false
+		for _, r := range req.Reason {
+			if r == reason {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, kind := range kinds {
+		if len(model.ConfigsOfKind(req.ConfigsUpdated, kind)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func (fx *FakeXdsUpdater) WaitDurationOrFail(t test.Failer, duration time.Duration, types ...string) *FakeXdsEvent {
 	t.Helper()
 	got := fx.WaitDuration(duration, types...)
@@ -0,0 +1,32 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net/http"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// jwksz reports the last fetch time, key IDs and last fetch error of the JWKS of each JWT issuer
+// known to istiod.
+// It is mapped to /debug/jwksz
+func (s *DiscoveryServer) jwksz(w http.ResponseWriter, _ *http.Request) {
+	status := []model.JwksStatus{}
+	if s.JwtKeyResolver != nil {
+		status = s.JwtKeyResolver.Status()
+	}
+	writeJSON(w, status)
+}
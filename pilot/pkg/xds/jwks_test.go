@@ -0,0 +1,49 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestJwksz(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	// Nothing listens on port 1, so the fetch fails.
+	if _, err := s.Discovery.JwtKeyResolver.GetPublicKey("https://example.com", "http://127.0.0.1:1/jwks"); err == nil {
+		t.Fatal("expected the JWKS fetch to fail")
+	}
+
+	req, err := http.NewRequest("GET", "/debug/jwksz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(s.Discovery.jwksz).ServeHTTP(rr, req)
+	var status []model.JwksStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("invalid json %v: %s", err, rr.Body.String())
+	}
+	if len(status) != 1 {
+		t.Fatalf("expected a single issuer, got %+v", status)
+	}
+	if st := status[0]; st.Issuer != "https://example.com" || st.Error == "" || st.LastFetched.IsZero() || len(st.KeyIDs) != 0 {
+		t.Fatalf("expected a failed fetch of https://example.com, got %+v", st)
+	}
+}
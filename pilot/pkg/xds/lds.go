@@ -15,8 +15,13 @@
 package xds
 
 import (
+	"sort"
+	"strings"
+
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 
+	extensions "istio.io/api/extensions/v1alpha1"
+
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pkg/config"
@@ -73,7 +78,13 @@ func (l LdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *m
 	if !ldsNeedsPush(proxy, req) {
 		return nil, model.DefaultXdsLogDetails, nil
 	}
+	placement := wasmPluginPlacement(push.WasmPlugins(proxy))
+	if onlyWasmPluginsUpdated(req) && placement == proxy.WasmPluginPlacement {
+		// The listeners only reference the plugins by name, their configuration is pushed over ECDS.
+		return nil, model.DefaultXdsLogDetails, nil
+	}
 	listeners := l.Server.ConfigGenerator.BuildListeners(proxy, push)
+	proxy.WasmPluginPlacement = placement
 	resources := model.Resources{}
 	for _, c := range listeners {
 		resources = append(resources, &discovery.Resource{
@@ -83,3 +94,39 @@ func (l LdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *m
 	}
 	return resources, model.DefaultXdsLogDetails, nil
 }
+
+// onlyWasmPluginsUpdated returns true if the push request only updated WasmPlugins.
+func onlyWasmPluginsUpdated(req *model.PushRequest) bool {
+	if req == nil || len(req.ConfigsUpdated) == 0 {
+		return false
+	}
+	for config := range req.ConfigsUpdated {
+		if config.Kind != gvk.WasmPlugin {
+			return false
+		}
+	}
+	return true
+}
+
+// wasmPluginPlacement returns the names of the WasmPlugins in the order they are inserted in the HTTP filters.
+func wasmPluginPlacement(plugins map[extensions.PluginPhase][]*model.WasmPluginWrapper) string {
+	if len(plugins) == 0 {
+		return ""
+	}
+	phases := make([]extensions.PluginPhase, 0, len(plugins))
+	for phase := range plugins {
+		phases = append(phases, phase)
+	}
+	sort.Slice(phases, func(i, j int) bool { return phases[i] < phases[j] })
+	b := strings.Builder{}
+	for _, phase := range phases {
+		b.WriteString(phase.String())
+		b.WriteByte(':')
+		for _, p := range plugins[phase] {
+			b.WriteString(p.ExtensionConfiguration.Name)
+			b.WriteByte(',')
+		}
+		b.WriteByte(';')
+	}
+	return b.String()
+}
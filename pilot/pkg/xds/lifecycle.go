@@ -0,0 +1,53 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"strings"
+
+	"istio.io/istio/pilot/pkg/events"
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// proxyObject returns the pod of a proxy, or nil if the proxy does not run in a pod. The ID of the
+// proxies running in pods is the pod name followed by the namespace.
+func proxyObject(proxy *model.Proxy) *events.Object {
+	suffix := "." + proxy.ConfigNamespace
+	if proxy.ConfigNamespace == "" || proxy.IsVM() || !strings.HasSuffix(proxy.ID, suffix) {
+		return nil
+	}
+	return &events.Object{Kind: "Pod", Namespace: proxy.ConfigNamespace, Name: strings.TrimSuffix(proxy.ID, suffix)}
+}
+
+// emitProxyEvent emits a lifecycle event about the proxy of a connection.
+func (s *DiscoveryServer) emitProxyEvent(t events.Type, warning bool, con *Connection, format string, args ...interface{}) {
+	if s.Events == nil {
+		return
+	}
+	s.Events.Emitf(t, warning, proxyObject(con.proxy), con.proxy.ID, format, args...)
+}
+
+// emitConfigRejected emits the event of a proxy rejecting the configuration of an xDS type.
+func (s *DiscoveryServer) emitConfigRejected(con *Connection, typeURL string, message string) {
+	s.emitProxyEvent(events.ConfigRejected, true, con, "proxy %s rejected %s configuration: %s",
+		con.proxy.ID, v3.GetShortType(typeURL), message)
+}
+
+// emitPushFailed emits the event of a failure to push the configuration of an xDS type to a proxy.
+func (s *DiscoveryServer) emitPushFailed(con *Connection, typeURL string, err error) {
+	s.emitProxyEvent(events.PushFailed, true, con, "failed to push %s configuration to proxy %s: %v",
+		v3.GetShortType(typeURL), con.proxy.ID, err)
+}
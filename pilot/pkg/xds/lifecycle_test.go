@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/events"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (r *recordingSink) Name() string {
+	return "recording"
+}
+
+func (r *recordingSink) Send(e events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *recordingSink) types() []events.Type {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []events.Type
+	for _, e := range r.events {
+		out = append(out, e.Type)
+	}
+	return out
+}
+
+func TestLifecycleEvents(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	sink := &recordingSink{}
+	s.Discovery.Events = events.NewEmitter(100, 100, sink)
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.Discovery.Events.Run(stop)
+
+	expectEvents := func(want ...events.Type) {
+		t.Helper()
+		retry.UntilSuccessOrFail(t, func() error {
+			if got := sink.types(); !reflect.DeepEqual(got, want) {
+				return fmt.Errorf("got events %v, want %v", got, want)
+			}
+			return nil
+		}, retry.Timeout(time.Second*5))
+	}
+
+	ads := s.ConnectADS().WithType(v3.ClusterType)
+	ads.RequestResponseNack(t, nil)
+	expectEvents(events.ProxyConnected, events.ConfigRejected)
+	ads.Cleanup()
+	expectEvents(events.ProxyConnected, events.ConfigRejected, events.ProxyDisconnected)
+
+	rejected := sink.events[1]
+	if !rejected.Warning || rejected.Proxy != "test.default" || rejected.Message != "proxy test.default rejected CDS configuration: Test request NACK" {
+		t.Fatalf("unexpected event %+v", rejected)
+	}
+	if want := (events.Object{Kind: "Pod", Namespace: "default", Name: "test"}); rejected.Object == nil || *rejected.Object != want {
+		t.Fatalf("expected the event to be about the pod of the proxy, got %+v", rejected.Object)
+	}
+}
@@ -0,0 +1,422 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package xds
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	lrs "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v3"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/features"
+	istiogrpc "istio.io/istio/pilot/pkg/grpc"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+const (
+	// loadChangeThreshold is the relative change of the load of a locality above which the load of the
+	// service is updated in its EndpointShards, triggering an EDS push if load aware weights are enabled.
+	loadChangeThreshold = 0.1
+
+	// maxLoadWeightFactor bounds the factor by which the weight of a locality is scaled by its load.
+	maxLoadWeightFactor = 4.0
+)
+
+var lrsStreamIDs = atomic.NewInt64(0)
+
+// localityLoad is the load of an upstream locality, reported over LRS.
+type localityLoad struct {
+	requestRate float64
+	errorRate   float64
+	inProgress  uint64
+}
+
+func (l localityLoad) add(o localityLoad) localityLoad {
+	return localityLoad{
+		requestRate: l.requestRate + o.requestRate,
+		errorRate:   l.errorRate + o.errorRate,
+		inProgress:  l.inProgress + o.inProgress,
+	}
+}
+
+// clusterLoad is the load of the localities of an upstream cluster, by locality label.
+type clusterLoad map[string]localityLoad
+
+// loadSeries identifies a series of the LRS metrics.
+type loadSeries struct {
+	cluster  string
+	locality string
+}
+
+// loadReports aggregates the load reported by the proxies over LRS.
+type loadReports struct {
+	mu sync.Mutex
+	// streams is the last load reported on each LRS stream, by stream ID and cluster name.
+	streams map[int64]map[string]clusterLoad
+	// applied is the request rate of the services last set in their EndpointShards, by hostname and locality.
+	applied map[host.Name]map[string]float64
+	// recorded are the series of the metrics recorded by the last aggregation.
+	recorded map[loadSeries]struct{}
+}
+
+func newLoadReports() *loadReports {
+	return &loadReports{
+		streams:  map[int64]map[string]clusterLoad{},
+		applied:  map[host.Name]map[string]float64{},
+		recorded: map[loadSeries]struct{}{},
+	}
+}
+
+// report replaces the load of a stream with the load of the clusters of a LoadStatsRequest.
+func (r *loadReports) report(id int64, stats []*endpoint.ClusterStats) {
+	clusters := map[string]clusterLoad{}
+	for _, cs := range stats {
+		interval := cs.GetLoadReportInterval().AsDuration().Seconds()
+		if interval <= 0 {
+			continue
+		}
+		load := clusters[cs.ClusterName]
+		if load == nil {
+			load = clusterLoad{}
+			clusters[cs.ClusterName] = load
+		}
+		for _, ls := range cs.UpstreamLocalityStats {
+			locality := util.LocalityToString(ls.Locality)
+			load[locality] = load[locality].add(localityLoad{
+				requestRate: float64(ls.TotalIssuedRequests) / interval,
+				errorRate:   float64(ls.TotalErrorRequests) / interval,
+				inProgress:  ls.TotalRequestsInProgress,
+			})
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.streams[id] = clusters
+}
+
+// remove drops the load of a closed stream.
+func (r *loadReports) remove(id int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.streams, id)
+}
+
+// aggregate sums the load reported by all the streams, by cluster name.
+func (r *loadReports) aggregate() map[string]clusterLoad {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := map[string]clusterLoad{}
+	for _, clusters := range r.streams {
+		for name, load := range clusters {
+			total := out[name]
+			if total == nil {
+				total = clusterLoad{}
+				out[name] = total
+			}
+			for locality, l := range load {
+				total[locality] = total[locality].add(l)
+			}
+		}
+	}
+	return out
+}
+
+// record records the load of the clusters as metrics, resetting the series of the clusters and localities which
+// are no longer reported.
+func (r *loadReports) record(clusters map[string]clusterLoad) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recorded := map[loadSeries]struct{}{}
+	for name, load := range clusters {
+		for locality, l := range load {
+			series := loadSeries{cluster: name, locality: locality}
+			recorded[series] = struct{}{}
+			recordLoadSeries(series, l)
+		}
+	}
+	for series := range r.recorded {
+		if _, f := recorded[series]; !f {
+			recordLoadSeries(series, localityLoad{})
+		}
+	}
+	r.recorded = recorded
+}
+
+func recordLoadSeries(series loadSeries, l localityLoad) {
+	lrsRequestRate.With(
+		guardedValue(lrsRequestRate, clusterTag, series.cluster),
+		guardedValue(lrsRequestRate, localityTag, series.locality)).Record(l.requestRate)
+	lrsErrorRate.With(
+		guardedValue(lrsErrorRate, clusterTag, series.cluster),
+		guardedValue(lrsErrorRate, localityTag, series.locality)).Record(l.errorRate)
+	lrsRequestsInProgress.With(
+		guardedValue(lrsRequestsInProgress, clusterTag, series.cluster),
+		guardedValue(lrsRequestsInProgress, localityTag, series.locality)).Record(float64(l.inProgress))
+}
+
+// serviceLoad sums the request rate of the outbound clusters by service hostname and locality, across their
+// ports and subsets.
+func serviceLoad(clusters map[string]clusterLoad) map[host.Name]map[string]float64 {
+	out := map[host.Name]map[string]float64{}
+	for name, load := range clusters {
+		direction, _, hostname, _ := model.ParseSubsetKey(name)
+		if direction != model.TrafficDirectionOutbound || hostname == "" {
+			continue
+		}
+		rates := out[hostname]
+		if rates == nil {
+			rates = map[string]float64{}
+			out[hostname] = rates
+		}
+		for locality, l := range load {
+			rates[locality] += l.requestRate
+		}
+	}
+	return out
+}
+
+// changed returns the services whose load changed by more than loadChangeThreshold since it was last applied,
+// with a nil load for the services no longer reported, and marks their load as applied.
+func (r *loadReports) changed(services map[host.Name]map[string]float64) map[host.Name]map[string]float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := map[host.Name]map[string]float64{}
+	for hostname, load := range services {
+		if loadChanged(r.applied[hostname], load) {
+			out[hostname] = load
+			r.applied[hostname] = load
+		}
+	}
+	for hostname := range r.applied {
+		if _, f := services[hostname]; !f {
+			out[hostname] = nil
+			delete(r.applied, hostname)
+		}
+	}
+	return out
+}
+
+func loadChanged(old, cur map[string]float64) bool {
+	if len(old) != len(cur) {
+		return true
+	}
+	for locality, rate := range cur {
+		prev, f := old[locality]
+		if !f {
+			return true
+		}
+		if math.Abs(rate-prev) > loadChangeThreshold*math.Max(rate, prev) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadReportingProxy returns the proxy of the ADS connection of a node reporting load, or nil if the node is not
+// connected. The identities of the LRS stream must match the identity of the proxy.
+func (s *DiscoveryServer) loadReportingProxy(node string, identities []string) (*model.Proxy, error) {
+	for _, con := range s.Clients() {
+		if con.node.GetId() != node {
+			continue
+		}
+		if identities != nil {
+			if _, err := checkConnectionIdentity(con.proxy, identities); err != nil {
+				return nil, status.Newf(codes.PermissionDenied, "authorization failed: %v", err).Err()
+			}
+		}
+		return con.proxy, nil
+	}
+	return nil, nil
+}
+
+// ownedClusterStats returns the stats of the outbound clusters of the services visible to the proxy. The load of
+// the other clusters, which the proxy does not send traffic to, is dropped.
+func (s *DiscoveryServer) ownedClusterStats(proxy *model.Proxy, stats []*endpoint.ClusterStats) []*endpoint.ClusterStats {
+	push := s.globalPushContext()
+	proxy.RLock()
+	defer proxy.RUnlock()
+	out := make([]*endpoint.ClusterStats, 0, len(stats))
+	for _, cs := range stats {
+		direction, _, hostname, _ := model.ParseSubsetKey(cs.ClusterName)
+		if direction != model.TrafficDirectionOutbound || push.ServiceForHostname(proxy, hostname) == nil {
+			continue
+		}
+		out = append(out, cs)
+	}
+	return out
+}
+
+// StreamLoadStats implements the Envoy Load Reporting Service. The proxies report the load of the clusters of
+// the services visible to them, which is aggregated across the proxies. The node reporting load must be connected
+// over ADS with the same identity.
+func (s *DiscoveryServer) StreamLoadStats(stream lrs.LoadReportingService_StreamLoadStatsServer) error {
+	ctx := stream.Context()
+	peerAddr := "0.0.0.0"
+	if peerInfo, ok := peer.FromContext(ctx); ok {
+		peerAddr = peerInfo.Addr.String()
+	}
+	identities, err := s.authenticate(ctx)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	id := lrsStreamIDs.Inc()
+	defer s.loadReports.remove(id)
+	var node string
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			if istiogrpc.IsExpectedGRPCError(err) {
+				log.Debugf("LRS: %q %s terminated", node, peerAddr)
+				return nil
+			}
+			log.Warnf("LRS: %q %s terminated with error: %v", node, peerAddr, err)
+			return err
+		}
+		if node == "" {
+			// The node is only set on the first request of the stream.
+			if req.Node.GetId() == "" {
+				return status.Error(codes.InvalidArgument, "missing node in the first load stats request")
+			}
+			node = req.Node.Id
+			log.Debugf("LRS: %q %s connected", node, peerAddr)
+			if err := stream.Send(&lrs.LoadStatsResponse{
+				SendAllClusters:       true,
+				LoadReportingInterval: durationpb.New(features.LoadReportingInterval),
+			}); err != nil {
+				return err
+			}
+		}
+		proxy, err := s.loadReportingProxy(node, identities)
+		if err != nil {
+			log.Warnf("LRS: %q %s with identity %v: %v", node, peerAddr, identities, err)
+			return err
+		}
+		if proxy == nil {
+			// The load of a node without ADS connection cannot be scoped, and is dropped until it connects.
+			log.Debugf("LRS: %q %s is not connected over ADS, dropping its load", node, peerAddr)
+			s.loadReports.remove(id)
+			continue
+		}
+		s.loadReports.report(id, s.ownedClusterStats(proxy, req.ClusterStats))
+	}
+}
+
+// aggregateLoadReports periodically aggregates the load reported over LRS.
+func (s *DiscoveryServer) aggregateLoadReports(stopCh <-chan struct{}) {
+	interval := features.LoadReportingInterval
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			s.applyLoadReports()
+		}
+	}
+}
+
+// applyLoadReports records the aggregated load as metrics, and updates the load of the services whose load
+// changed in their EndpointShards.
+func (s *DiscoveryServer) applyLoadReports() {
+	clusters := s.loadReports.aggregate()
+	s.loadReports.record(clusters)
+	changed := s.loadReports.changed(serviceLoad(clusters))
+	hostnames := make([]string, 0, len(changed))
+	for hostname := range changed {
+		hostnames = append(hostnames, string(hostname))
+	}
+	sort.Strings(hostnames)
+	for _, hostname := range hostnames {
+		s.updateLocalityLoad(hostname, changed[host.Name(hostname)])
+	}
+}
+
+// updateLocalityLoad sets the load of a service in its EndpointShards, in all namespaces. If load aware
+// locality weights are enabled, an EDS push of the service is triggered.
+func (s *DiscoveryServer) updateLocalityLoad(hostname string, load map[string]float64) {
+	s.mutex.RLock()
+	namespaces := make([]string, 0, len(s.EndpointShardsByService[hostname]))
+	for namespace, shards := range s.EndpointShardsByService[hostname] {
+		shards.mutex.Lock()
+		shards.LocalityLoad = load
+		shards.mutex.Unlock()
+		namespaces = append(namespaces, namespace)
+	}
+	s.mutex.RUnlock()
+	if !features.EnableLoadAwareLocalityWeights {
+		return
+	}
+	for _, namespace := range namespaces {
+		s.ConfigUpdate(&model.PushRequest{
+			Full: false,
+			ConfigsUpdated: map[model.ConfigKey]struct{}{{
+				Kind:      gvk.ServiceEntry,
+				Name:      hostname,
+				Namespace: namespace,
+			}: {}},
+			Reason: []model.TriggerReason{model.EndpointUpdate},
+		})
+	}
+}
+
+// applyLocalityLoad scales the weight of each locality with reported load by the ratio of the mean load per unit
+// of weight to its own load per unit of weight, bounded by maxLoadWeightFactor, so that the localities loaded
+// below their share receive more traffic. The localities without reported load keep their weight.
+func applyLocalityLoad(locEps []*LocLbEndpointsAndOptions, load map[string]float64) {
+	if len(load) == 0 {
+		return
+	}
+	var totalLoad, totalWeight float64
+	for _, ep := range locEps {
+		if rate, f := load[util.LocalityToString(ep.llbEndpoints.Locality)]; f {
+			totalLoad += rate
+			totalWeight += float64(ep.llbEndpoints.LoadBalancingWeight.GetValue())
+		}
+	}
+	if totalLoad == 0 || totalWeight == 0 {
+		return
+	}
+	mean := totalLoad / totalWeight
+	for _, ep := range locEps {
+		rate, f := load[util.LocalityToString(ep.llbEndpoints.Locality)]
+		weight := float64(ep.llbEndpoints.LoadBalancingWeight.GetValue())
+		if !f || weight == 0 {
+			continue
+		}
+		factor := maxLoadWeightFactor
+		if rate > 0 {
+			factor = math.Min(maxLoadWeightFactor, math.Max(1/maxLoadWeightFactor, mean*weight/rate))
+		}
+		ep.llbEndpoints.LoadBalancingWeight = &wrappers.UInt32Value{
+			Value: uint32(math.Max(1, math.Round(weight*factor))),
+		}
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package xds
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	lrs "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/durationpb"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func clusterStats(name string, interval time.Duration, issued map[string]uint64) *endpoint.ClusterStats {
+	cs := &endpoint.ClusterStats{
+		ClusterName:        name,
+		LoadReportInterval: durationpb.New(interval),
+	}
+	for locality, n := range issued {
+		cs.UpstreamLocalityStats = append(cs.UpstreamLocalityStats, &endpoint.UpstreamLocalityStats{
+			Locality:            util.ConvertLocality(locality),
+			TotalIssuedRequests: n,
+			TotalErrorRequests:  n / 10,
+		})
+	}
+	return cs
+}
+
+func TestLoadReportsAggregate(t *testing.T) {
+	r := newLoadReports()
+	r.report(1, []*endpoint.ClusterStats{
+		clusterStats("outbound|80||a.example.com", 10*time.Second, map[string]uint64{"us/east": 100, "us/west": 50}),
+		clusterStats("outbound|80|v1|a.example.com", 10*time.Second, map[string]uint64{"us/east": 100}),
+		clusterStats("inbound|80||", 10*time.Second, map[string]uint64{"": 100}),
+	})
+	r.report(2, []*endpoint.ClusterStats{
+		clusterStats("outbound|80||a.example.com", 5*time.Second, map[string]uint64{"us/east": 50}),
+	})
+
+	clusters := r.aggregate()
+	if got := clusters["outbound|80||a.example.com"]["us/east"]; got.requestRate != 20 || got.errorRate != 2 {
+		t.Fatalf("unexpected load of us/east: %+v", got)
+	}
+	want := map[host.Name]map[string]float64{
+		"a.example.com": {"us/east": 30, "us/west": 5},
+	}
+	if got := serviceLoad(clusters); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected service load %v, got %v", want, got)
+	}
+
+	// The load of a closed stream is dropped.
+	r.remove(2)
+	if got := serviceLoad(r.aggregate())["a.example.com"]["us/east"]; got != 20 {
+		t.Fatalf("expected a request rate of 20 after the stream is removed, got %v", got)
+	}
+}
+
+func TestLoadReportsChanged(t *testing.T) {
+	r := newLoadReports()
+	load := map[host.Name]map[string]float64{"a.example.com": {"us/east": 100}}
+	if got := r.changed(load); len(got) != 1 {
+		t.Fatalf("expected the initial load to be changed, got %v", got)
+	}
+	// Changes below the threshold are ignored.
+	if got := r.changed(map[host.Name]map[string]float64{"a.example.com": {"us/east": 105}}); len(got) != 0 {
+		t.Fatalf("expected no change, got %v", got)
+	}
+	if got := r.changed(map[host.Name]map[string]float64{"a.example.com": {"us/east": 150}}); len(got) != 1 {
+		t.Fatalf("expected a change, got %v", got)
+	}
+	// Services no longer reported are reset.
+	got := r.changed(map[host.Name]map[string]float64{})
+	if l, f := got["a.example.com"]; !f || l != nil {
+		t.Fatalf("expected the load to be reset, got %v", got)
+	}
+}
+
+func TestApplyLocalityLoad(t *testing.T) {
+	locEps := func(weights ...uint32) []*LocLbEndpointsAndOptions {
+		out := make([]*LocLbEndpointsAndOptions, 0, len(weights))
+		for i, w := range weights {
+			out = append(out, &LocLbEndpointsAndOptions{
+				llbEndpoints: endpoint.LocalityLbEndpoints{
+					Locality:            &core.Locality{Region: "r", Zone: fmt.Sprintf("z%d", i)},
+					LoadBalancingWeight: &wrappers.UInt32Value{Value: w},
+				},
+			})
+		}
+		return out
+	}
+	weights := func(eps []*LocLbEndpointsAndOptions) []uint32 {
+		out := make([]uint32, 0, len(eps))
+		for _, ep := range eps {
+			out = append(out, ep.llbEndpoints.LoadBalancingWeight.GetValue())
+		}
+		return out
+	}
+	cases := []struct {
+		name    string
+		weights []uint32
+		load    map[string]float64
+		want    []uint32
+	}{
+		{
+			name:    "no load",
+			weights: []uint32{10, 10},
+			want:    []uint32{10, 10},
+		},
+		{
+			name:    "balanced",
+			weights: []uint32{10, 20},
+			load:    map[string]float64{"r/z0": 100, "r/z1": 200},
+			want:    []uint32{10, 20},
+		},
+		{
+			name:    "unbalanced",
+			weights: []uint32{10, 10},
+			load:    map[string]float64{"r/z0": 300, "r/z1": 100},
+			want:    []uint32{7, 20},
+		},
+		{
+			name:    "idle locality",
+			weights: []uint32{10, 10},
+			load:    map[string]float64{"r/z0": 100, "r/z1": 0},
+			want:    []uint32{5, 40},
+		},
+		{
+			name:    "locality without load",
+			weights: []uint32{10, 10, 10},
+			load:    map[string]float64{"r/z0": 100, "r/z1": 300},
+			want:    []uint32{20, 7, 10},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			eps := locEps(tt.weights...)
+			applyLocalityLoad(eps, tt.load)
+			if got := weights(eps); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected weights %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStreamLoadStats(t *testing.T) {
+	original := features.EnableLoadReporting
+	t.Cleanup(func() {
+		features.EnableLoadReporting = original
+	})
+	features.EnableLoadReporting = true
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: a
+  namespace: ns
+spec:
+  hosts:
+  - a.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: STATIC
+  endpoints:
+  - address: 2.2.2.2
+`})
+	shards, _ := s.Discovery.getOrCreateEndpointShard("a.example.com", "ns")
+	s.Connect(nil, nil, nil)
+
+	conn, err := grpc.Dial("buffcon",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return s.BufListener.Dial()
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	stream, err := lrs.NewLoadReportingServiceClient(conn).StreamLoadStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := &core.Node{Id: "sidecar~1.1.1.1~test-1.default~default.svc.cluster.local"}
+	if err := stream.Send(&lrs.LoadStatsRequest{Node: node}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.SendAllClusters || resp.LoadReportingInterval.AsDuration() != features.LoadReportingInterval {
+		t.Fatalf("unexpected response: %v", resp)
+	}
+
+	if err := stream.Send(&lrs.LoadStatsRequest{ClusterStats: []*endpoint.ClusterStats{
+		clusterStats("outbound|80||a.example.com", 10*time.Second, map[string]uint64{"us/east": 100}),
+		// The load of the clusters of services not visible to the proxy is dropped.
+		clusterStats("outbound|80||b.example.com", 10*time.Second, map[string]uint64{"us/east": 100}),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		s.Discovery.applyLoadReports()
+		shards.mutex.RLock()
+		defer shards.mutex.RUnlock()
+		if got := shards.LocalityLoad["us/east"]; got != 10 {
+			return fmt.Errorf("expected a load of 10 for us/east, got %v", shards.LocalityLoad)
+		}
+		if _, f := s.Discovery.loadReports.aggregate()["outbound|80||b.example.com"]; f {
+			return fmt.Errorf("expected no load for b.example.com")
+		}
+		return nil
+	}, retry.Timeout(5*time.Second))
+
+	// The load is reset once the stream is closed.
+	cancel()
+	retry.UntilSuccessOrFail(t, func() error {
+		s.Discovery.applyLoadReports()
+		shards.mutex.RLock()
+		defer shards.mutex.RUnlock()
+		if shards.LocalityLoad != nil {
+			return fmt.Errorf("expected no load, got %v", shards.LocalityLoad)
+		}
+		return nil
+	}, retry.Timeout(5*time.Second))
+}
@@ -17,19 +17,37 @@ import (
 	"sync"
 	"time"
 
+	"go.opencensus.io/tag"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/pkg/monitoring"
 )
 
 var (
-	errTag     = monitoring.MustCreateLabel("err")
-	nodeTag    = monitoring.MustCreateLabel("node")
-	typeTag    = monitoring.MustCreateLabel("type")
-	versionTag = monitoring.MustCreateLabel("version")
+	errTag       = monitoring.MustCreateLabel("err")
+	nodeTag      = monitoring.MustCreateLabel("node")
+	predicateTag = monitoring.MustCreateLabel("predicate")
+	typeTag      = monitoring.MustCreateLabel("type")
+	versionTag   = monitoring.MustCreateLabel("version")
+
+	// The compression of the xDS responses, negotiated by the proxy.
+	compressionTag = monitoring.MustCreateLabel("compression")
+	policyTag      = monitoring.MustCreateLabel("policy")
+
+	// The upstream cluster and locality of the load reported over LRS.
+	clusterTag  = monitoring.MustCreateLabel("cluster")
+	localityTag = monitoring.MustCreateLabel("locality")
+
+	// The labels of the proxy a push is sent to, enabled by PILOT_XDS_PUSH_METRICS_PROXY_LABELS.
+	revisionTag     = monitoring.MustCreateLabel("revision")
+	proxyVersionTag = monitoring.MustCreateLabel("proxy_version")
+
+	// metricCardinality guards the labels whose values are not known in advance.
+	metricCardinality = newCardinalityGuard(features.MetricLabelCardinalityLimit, features.MetricLabelMaxLength)
 
 	// pilot_total_xds_rejects should be used instead. This is for backwards compatibility
 	cdsReject = monitoring.NewGauge(
@@ -80,6 +98,12 @@ var (
 		monitoring.WithLabels(typeTag),
 	)
 
+	skippedPushes = monitoring.NewSum(
+		"pilot_xds_skipped_pushes_total",
+		"Total number of pushes to proxies skipped, by the predicate which skipped them.",
+		monitoring.WithLabels(predicateTag),
+	)
+
 	xdsExpiredNonce = monitoring.NewSum(
 		"pilot_xds_expired_nonce",
 		"Total number of XDS requests with an expired nonce.",
@@ -110,7 +134,7 @@ var (
 	pushes = monitoring.NewSum(
 		"pilot_xds_pushes",
 		"Pilot build and send errors for lds, rds, cds and eds.",
-		monitoring.WithLabels(typeTag),
+		monitoring.WithLabels(withProxyLabels(typeTag)...),
 	)
 
 	cdsSendErrPushes = pushes.With(typeTag.Value("cds_senderr"))
@@ -137,11 +161,34 @@ var (
 		[]float64{.01, .1, 1, 3, 5, 10, 20, 30},
 	)
 
+	sendThrottleTime = monitoring.NewDistribution(
+		"pilot_xds_send_throttle_time",
+		"Total time in seconds responses waited before being sent, according to PILOT_XDS_SEND_RATE_LIMIT.",
+		[]float64{.01, .1, 1, 3, 5, 10, 20, 30},
+		monitoring.WithLabels(typeTag),
+	)
+
+	vhdsResolutionTime = monitoring.NewDistribution(
+		"pilot_vhds_resolution_time",
+		"Total time in seconds Pilot takes to resolve the virtual hosts requested on demand by the gateways.",
+		[]float64{.001, .01, .1, .5, 1, 3, 5},
+	)
+
+	vhdsCacheReads = monitoring.NewSum(
+		"pilot_vhds_cache_reads",
+		"Total number of virtual host index reads resolving on demand requests, by whether the index was cached.",
+		monitoring.WithLabels(typeTag),
+	)
+
+	vhdsCacheHits   = vhdsCacheReads.With(typeTag.Value("hit"))
+	vhdsCacheMisses = vhdsCacheReads.With(typeTag.Value("miss"))
+
 	// only supported dimension is millis, unfortunately. default to unitdimensionless.
 	proxiesQueueTime = monitoring.NewDistribution(
 		"pilot_proxy_queue_time",
 		"Time in seconds, a proxy is in the push queue before being dequeued.",
 		[]float64{.1, .5, 1, 3, 5, 10, 20, 30},
+		monitoring.WithLabels(withProxyLabels()...),
 	)
 
 	pushTriggers = monitoring.NewSum(
@@ -162,6 +209,36 @@ var (
 		"Number of errors (timeouts) initiating push context.",
 	)
 
+	reapedConnections = monitoring.NewSum(
+		"pilot_xds_reaped_connections",
+		"Total number of XDS connections closed by pilot as stale, because the proxy did not send any request for "+
+			"PILOT_XDS_IDLE_TIMEOUT.",
+	)
+
+	drainedConnections = monitoring.NewSum(
+		"pilot_xds_drained_connections",
+		"Total number of XDS connections closed by pilot on shutdown, according to PILOT_XDS_DRAIN_DURATION.",
+	)
+
+	auditRecordsDropped = monitoring.NewSum(
+		"pilot_xds_audit_records_dropped",
+		"Total number of xDS audit records dropped because the audit log could not keep up.",
+	)
+
+	nackCircuitOpened = monitoring.NewSum(
+		"pilot_xds_nack_circuit_opened",
+		"Total number of times the pushes of a type to a proxy were suspended after repeated NACKs, "+
+			"according to PILOT_DELTA_NACK_CIRCUIT_THRESHOLD.",
+		monitoring.WithLabels(typeTag),
+	)
+
+	initialFetchTimeouts = monitoring.NewSum(
+		"pilot_xds_delta_initial_fetch_timeouts",
+		"Total number of first responses of a type sent to a proxy over delta xDS before the previous types were ACKed, "+
+			"because the deadline of the stage of PILOT_DELTA_INITIAL_FETCH_ORDERING elapsed.",
+		monitoring.WithLabels(typeTag),
+	)
+
 	totalXDSInternalErrors = monitoring.NewSum(
 		"pilot_total_xds_internal_errors",
 		"Total number of internal XDS errors in pilot.",
@@ -178,6 +255,12 @@ var (
 		"Total number of failures to fetch SDS key and certificate.",
 	)
 
+	pilotSDSCertificateFallbacks = monitoring.NewSum(
+		"pilot_sds_certificate_fallbacks_total",
+		"Total number of missing SDS certificates replaced according to the missing credential policy of the gateway.",
+		monitoring.WithLabels(typeTag),
+	)
+
 	inboundConfigUpdates  = inboundUpdates.With(typeTag.Value("config"))
 	inboundEDSUpdates     = inboundUpdates.With(typeTag.Value("eds"))
 	inboundServiceUpdates = inboundUpdates.With(typeTag.Value("svc"))
@@ -190,14 +273,83 @@ var (
 		// 4M default limit for gRPC, 10M config will start to strain system,
 		// 40M is likely upper-bound on config sizes supported.
 		[]float64{1, 10000, 1000000, 4000000, 10000000, 40000000},
-		monitoring.WithLabels(typeTag),
+		monitoring.WithLabels(withProxyLabels(typeTag)...),
 		monitoring.WithUnit(monitoring.Bytes),
 	)
+
+	responseBytes = monitoring.NewSum(
+		"pilot_xds_response_bytes",
+		"Total size of the xDS responses sent to clients, before compression.",
+		monitoring.WithLabels(typeTag, compressionTag),
+		monitoring.WithUnit(monitoring.Bytes),
+	)
+
+	responseWireBytes = monitoring.NewSum(
+		"pilot_xds_response_wire_bytes",
+		"Total size of the xDS responses sent to clients on the wire, after compression.",
+		monitoring.WithLabels(typeTag, compressionTag),
+		monitoring.WithUnit(monitoring.Bytes),
+	)
+
+	proxyVersionSkew = monitoring.NewSum(
+		"pilot_xds_proxy_version_skew",
+		"Total number of connections from proxies outside of the supported versions.",
+		monitoring.WithLabels(proxyVersionTag, policyTag),
+	)
+
+	lrsRequestRate = monitoring.NewGauge(
+		"pilot_lrs_requests_per_second",
+		"Rate of requests issued to an upstream cluster and locality, reported by the proxies over LRS.",
+		monitoring.WithLabels(clusterTag, localityTag),
+	)
+
+	lrsErrorRate = monitoring.NewGauge(
+		"pilot_lrs_errors_per_second",
+		"Rate of failed requests to an upstream cluster and locality, reported by the proxies over LRS.",
+		monitoring.WithLabels(clusterTag, localityTag),
+	)
+
+	lrsRequestsInProgress = monitoring.NewGauge(
+		"pilot_lrs_requests_in_progress",
+		"Number of requests in progress to an upstream cluster and locality, reported by the proxies over LRS.",
+		monitoring.WithLabels(clusterTag, localityTag),
+	)
 )
 
+// guardedValue returns the value of a label of a metric, bounded by the metric cardinality guard.
+func guardedValue(m monitoring.Metric, l monitoring.Label, value string) monitoring.LabelValue {
+	return l.Value(metricCardinality.guard(m.Name(), tag.Key(l).Name(), value))
+}
+
+// withProxyLabels appends the proxy labels to the labels of a metric, if PILOT_XDS_PUSH_METRICS_PROXY_LABELS is enabled.
+func withProxyLabels(labels ...monitoring.Label) []monitoring.Label {
+	if !features.EnableXDSPushMetricsProxyLabels {
+		return labels
+	}
+	return append(labels, revisionTag, proxyVersionTag)
+}
+
+// withProxyValues appends the values of the proxy labels of a metric for the proxy, if
+// PILOT_XDS_PUSH_METRICS_PROXY_LABELS is enabled.
+func withProxyValues(m monitoring.Metric, proxy *model.Proxy, values ...monitoring.LabelValue) []monitoring.LabelValue {
+	if !features.EnableXDSPushMetricsProxyLabels || proxy == nil || proxy.Metadata == nil {
+		return values
+	}
+	return append(values,
+		guardedValue(m, revisionTag, proxy.Metadata.IstioRevision),
+		guardedValue(m, proxyVersionTag, proxy.Metadata.IstioVersion))
+}
+
+// typeValue returns the value of the type label of a metric for an xDS type URL.
+func typeValue(m monitoring.Metric, typeURL string) monitoring.LabelValue {
+	return guardedValue(m, typeTag, v3.GetMetricType(typeURL))
+}
+
 func recordXDSClients(version string, delta float64) {
 	xdsClientTrackerMutex.Lock()
 	defer xdsClientTrackerMutex.Unlock()
+	// Track the guarded version, so that all the versions beyond the limit add up in the same series.
+	version = metricCardinality.guard(xdsClients.Name(), tag.Key(versionTag).Name(), version)
 	xdsClientTracker[version] += delta
 	xdsClients.With(versionTag.Value(version)).Record(xdsClientTracker[version])
 }
@@ -224,7 +376,7 @@ func recordPushTriggers(reasons ...model.TriggerReason) {
 		if f {
 			t.Increment()
 		} else {
-			pushTriggers.With(typeTag.Value(string(r))).Increment()
+			pushTriggers.With(guardedValue(pushTriggers, typeTag, string(r))).Increment()
 		}
 	}
 }
@@ -258,26 +410,57 @@ func recordSendError(xdsType string, err error) bool {
 }
 
 func incrementXDSRejects(xdsType string, node, errCode string) {
-	totalXDSRejects.With(typeTag.Value(v3.GetMetricType(xdsType))).Increment()
+	totalXDSRejects.With(typeValue(totalXDSRejects, xdsType)).Increment()
+	var reject monitoring.Metric
 	switch xdsType {
 	case v3.ListenerType:
-		ldsReject.With(nodeTag.Value(node), errTag.Value(errCode)).Increment()
+		reject = ldsReject
 	case v3.ClusterType:
-		cdsReject.With(nodeTag.Value(node), errTag.Value(errCode)).Increment()
+		reject = cdsReject
 	case v3.EndpointType:
-		edsReject.With(nodeTag.Value(node), errTag.Value(errCode)).Increment()
+		reject = edsReject
 	case v3.RouteType:
-		rdsReject.With(nodeTag.Value(node), errTag.Value(errCode)).Increment()
+		reject = rdsReject
+	default:
+		return
 	}
+	reject.With(guardedValue(reject, nodeTag, node), guardedValue(reject, errTag, errCode)).Increment()
 }
 
 func recordSendTime(duration time.Duration) {
 	sendTime.Record(duration.Seconds())
 }
 
-func recordPushTime(xdsType string, duration time.Duration) {
-	pushTime.With(typeTag.Value(v3.GetMetricType(xdsType))).Record(duration.Seconds())
-	pushes.With(typeTag.Value(v3.GetMetricType(xdsType))).Increment()
+func recordSendThrottleTime(xdsType string, duration time.Duration) {
+	sendThrottleTime.With(typeValue(sendThrottleTime, xdsType)).Record(duration.Seconds())
+}
+
+func recordPushTime(xdsType string, proxy *model.Proxy, duration time.Duration) {
+	pushTime.With(typeValue(pushTime, xdsType)).Record(duration.Seconds())
+	pushes.With(withProxyValues(pushes, proxy, typeValue(pushes, xdsType))...).Increment()
+}
+
+func recordConvergeDelay(proxy *model.Proxy, delay time.Duration) {
+	if values := withProxyValues(proxiesConvergeDelay, proxy); len(values) > 0 {
+		proxiesConvergeDelay.With(values...).Record(delay.Seconds())
+		return
+	}
+	proxiesConvergeDelay.Record(delay.Seconds())
+}
+
+func recordConfigSize(xdsType string, proxy *model.Proxy, size int) {
+	configSizeBytes.With(withProxyValues(configSizeBytes, proxy, guardedValue(configSizeBytes, typeTag, xdsType))...).Record(float64(size))
+}
+
+// recordResponseSize records the size of an xDS response before and after compression. Responses sent
+// uncompressed are recorded with the "identity" compression, like gRPC does.
+func recordResponseSize(xdsType, compression string, size, wireSize int) {
+	if compression == "" {
+		compression = "identity"
+	}
+	compressionValue := guardedValue(responseBytes, compressionTag, compression)
+	responseBytes.With(typeValue(responseBytes, xdsType), compressionValue).RecordInt(int64(size))
+	responseWireBytes.With(typeValue(responseWireBytes, xdsType), compressionValue).RecordInt(int64(wireSize))
 }
 
 func init() {
@@ -297,12 +480,28 @@ func init() {
 		proxiesQueueTime,
 		pushContextErrors,
 		totalXDSInternalErrors,
+		reapedConnections,
+		drainedConnections,
+		nackCircuitOpened,
+		auditRecordsDropped,
+		initialFetchTimeouts,
 		inboundUpdates,
 		pushTriggers,
 		sendTime,
+		sendThrottleTime,
+		vhdsResolutionTime,
+		vhdsCacheReads,
 		totalDelayedPushes,
 		totalDelayedPushTimeouts,
+		skippedPushes,
 		pilotSDSCertificateErrors,
+		pilotSDSCertificateFallbacks,
 		configSizeBytes,
+		responseBytes,
+		responseWireBytes,
+		proxyVersionSkew,
+		lrsRequestRate,
+		lrsErrorRate,
+		lrsRequestsInProgress,
 	)
 }
@@ -0,0 +1,70 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net/http"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/security/authn/mtlsreport"
+	"istio.io/istio/pilot/pkg/security/authn/v1beta1"
+	"istio.io/istio/pkg/config/labels"
+)
+
+// mtlsWorkloads returns the workloads of the connected sidecars, with their effective PeerAuthentication mode.
+func (s *DiscoveryServer) mtlsWorkloads(push *model.PushContext) []mtlsreport.Workload {
+	seen := map[mtlsreport.WorkloadKey]struct{}{}
+	var workloads []mtlsreport.Workload
+	for _, con := range s.Clients() {
+		proxy := con.proxy
+		if proxy.Type != model.SidecarProxy || proxy.Metadata == nil {
+			continue
+		}
+		// The workload name is only part of the bootstrap metadata, matching the destination_workload label.
+		name, _ := proxy.Metadata.Raw["WORKLOAD_NAME"].(string)
+		if name == "" {
+			continue
+		}
+		key := mtlsreport.WorkloadKey{Name: name, Namespace: proxy.ConfigNamespace}
+		if _, f := seen[key]; f {
+			continue
+		}
+		seen[key] = struct{}{}
+		policies := push.AuthnPolicies.GetPeerAuthenticationsForWorkload(key.Namespace, labels.Collection{proxy.Metadata.Labels})
+		peer := v1beta1.ComposePeerAuthentication(push.AuthnPolicies.GetRootNamespace(), policies)
+		workloads = append(workloads, mtlsreport.Workload{
+			WorkloadKey: key,
+			Mode:        model.ConvertToMutualTLSMode(peer.GetMtls().GetMode()),
+		})
+	}
+	return workloads
+}
+
+// mtlsCompatibilityz reports which workloads can safely move from PERMISSIVE to STRICT mTLS, based on
+// the inbound traffic they received.
+// It is mapped to /debug/mtls_compatibility
+func (s *DiscoveryServer) mtlsCompatibilityz(w http.ResponseWriter, req *http.Request) {
+	report := &mtlsreport.Report{}
+	var traffic map[mtlsreport.WorkloadKey]mtlsreport.TrafficStats
+	var err error
+	if s.MTLSTrafficSource != nil {
+		report.TrafficSource = s.MTLSTrafficSource.String()
+		if traffic, err = s.MTLSTrafficSource.InboundTraffic(req.Context()); err != nil {
+			report.Error = err.Error()
+		}
+	}
+	report.Workloads = mtlsreport.Evaluate(s.mtlsWorkloads(s.globalPushContext()), traffic, err)
+	writeJSON(w, report)
+}
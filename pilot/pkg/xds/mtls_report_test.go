@@ -0,0 +1,70 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"istio.io/istio/pilot/pkg/security/authn/mtlsreport"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+type fakeTrafficSource map[mtlsreport.WorkloadKey]mtlsreport.TrafficStats
+
+func (f fakeTrafficSource) InboundTraffic(context.Context) (map[mtlsreport.WorkloadKey]mtlsreport.TrafficStats, error) {
+	return f, nil
+}
+
+func (f fakeTrafficSource) String() string {
+	return "fake"
+}
+
+func TestMTLSCompatibilityz(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	s.Discovery.MTLSTrafficSource = fakeTrafficSource{
+		{Name: "reviews-v1", Namespace: "default"}: {MTLS: 10},
+	}
+	ads := s.ConnectADS().WithType(v3.ClusterType)
+	meta, err := structpb.NewStruct(map[string]interface{}{"WORKLOAD_NAME": "reviews-v1", "NAMESPACE": "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ads.RequestResponseAck(t, &discovery.DiscoveryRequest{Node: &core.Node{Id: ads.ID, Metadata: meta}})
+
+	req, err := http.NewRequest("GET", "/debug/mtls_compatibility", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(s.Discovery.mtlsCompatibilityz).ServeHTTP(rr, req)
+	report := &mtlsreport.Report{}
+	if err := json.Unmarshal(rr.Body.Bytes(), report); err != nil {
+		t.Fatalf("invalid json %v: %s", err, rr.Body.String())
+	}
+	if report.TrafficSource != "fake" || len(report.Workloads) != 1 {
+		t.Fatalf("expected a single workload from the fake source, got %+v", report)
+	}
+	if w := report.Workloads[0]; w.Name != "reviews-v1" || w.Mode != "PERMISSIVE" || w.Recommendation != mtlsreport.SafeForStrict {
+		t.Fatalf("expected reviews-v1 to be safe for STRICT, got %+v", w)
+	}
+}
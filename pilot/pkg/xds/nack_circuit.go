@@ -0,0 +1,142 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"istio.io/istio/pilot/pkg/events"
+	"istio.io/istio/pilot/pkg/features"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// nackCircuit is the NACK circuit breaker of a type of a delta xDS connection. After
+// PILOT_DELTA_NACK_CIRCUIT_THRESHOLD consecutive NACKs of the type, the circuit opens: the pushes of the type
+// are held and merged until the backoff has elapsed, when a single push is attempted. A NACK of that push
+// opens the circuit again with twice the backoff, while an ACK closes it.
+type nackCircuit struct {
+	// consecutiveNacks is the number of NACKs received since the last ACK.
+	consecutiveNacks int
+	open             bool
+	openedAt         time.Time
+	retryAt          time.Time
+	backoff          time.Duration
+	lastError        string
+}
+
+const (
+	// PushSuspendedCondition is the condition reported in the sync status of a proxy while the pushes of a type are
+	// suspended by its NACK circuit breaker.
+	PushSuspendedCondition = "PushSuspended"
+	// RepeatedRejectionsReason is the reason of the PushSuspendedCondition.
+	RepeatedRejectionsReason = "RepeatedRejections"
+)
+
+// nackCircuitMaxBackoff caps the backoff of the NACK circuit breaker, as a multiple of the initial backoff.
+const nackCircuitMaxBackoff = 32
+
+// recordDeltaNack records a NACK of the type, opening its circuit if the threshold is reached.
+func (s *DiscoveryServer) recordDeltaNack(con *Connection, typeURL string, message string) {
+	threshold := features.DeltaNackCircuitThreshold
+	if threshold <= 0 {
+		return
+	}
+	now := time.Now()
+	con.proxy.Lock()
+	c := con.nackCircuits[typeURL]
+	if c == nil {
+		c = &nackCircuit{}
+		con.nackCircuits[typeURL] = c
+	}
+	c.consecutiveNacks++
+	c.lastError = message
+	opened := false
+	if c.consecutiveNacks >= threshold && !now.Before(c.retryAt) {
+		if !c.open {
+			c.open = true
+			c.openedAt = now
+			c.backoff = features.DeltaNackCircuitBackoff
+		} else if c.backoff < nackCircuitMaxBackoff*features.DeltaNackCircuitBackoff {
+			// The push attempted after the backoff was rejected again.
+			c.backoff *= 2
+		}
+		c.retryAt = now.Add(c.backoff)
+		opened = true
+	}
+	nacks, backoff := c.consecutiveNacks, c.backoff
+	con.proxy.Unlock()
+	if !opened {
+		return
+	}
+	nackCircuitOpened.With(typeValue(nackCircuitOpened, typeURL)).Increment()
+	deltaLog.Warnf("ADS:%s: suspending pushes to %s for %v after %d consecutive NACKs",
+		v3.GetShortType(typeURL), con.ConID, backoff, nacks)
+	s.emitProxyEvent(events.PushSuspended, true, con, "pushes of %s configuration to proxy %s suspended for %v after %d rejections: %s",
+		v3.GetShortType(typeURL), con.proxy.ID, backoff, nacks, message)
+}
+
+// recordDeltaAck records an ACK of the type, closing its circuit.
+func (s *DiscoveryServer) recordDeltaAck(con *Connection, typeURL string) {
+	con.proxy.Lock()
+	c := con.nackCircuits[typeURL]
+	delete(con.nackCircuits, typeURL)
+	con.proxy.Unlock()
+	if c != nil && c.open {
+		deltaLog.Infof("ADS:%s: resuming pushes to %s", v3.GetShortType(typeURL), con.ConID)
+	}
+}
+
+// nackCircuitDelay returns the time until the next push of the type is allowed by its circuit, 0 if the circuit is
+// closed or its backoff has elapsed. The caller must hold the proxy lock.
+func (conn *Connection) nackCircuitDelay(typeURL string) time.Duration {
+	c := conn.nackCircuits[typeURL]
+	if c == nil || !c.open {
+		return 0
+	}
+	return time.Until(c.retryAt)
+}
+
+// nackCircuitConditions returns the PushSuspendedCondition of the connection if the circuit of a type is open,
+// describing every suspended type.
+func (conn *Connection) nackCircuitConditions() []ProxyCondition {
+	conn.proxy.RLock()
+	defer conn.proxy.RUnlock()
+	var since time.Time
+	var suspended []string
+	for typeURL, c := range conn.nackCircuits {
+		if !c.open {
+			continue
+		}
+		if since.IsZero() || c.openedAt.Before(since) {
+			since = c.openedAt
+		}
+		suspended = append(suspended, fmt.Sprintf("%s suspended until %s after %d consecutive rejections: %s",
+			v3.GetShortType(typeURL), c.retryAt.Format(time.RFC3339), c.consecutiveNacks, c.lastError))
+	}
+	if len(suspended) == 0 {
+		return nil
+	}
+	sort.Strings(suspended)
+	return []ProxyCondition{{
+		Type:               PushSuspendedCondition,
+		Status:             "True",
+		Reason:             RepeatedRejectionsReason,
+		Message:            strings.Join(suspended, "; "),
+		LastTransitionTime: since,
+	}}
+}
@@ -0,0 +1,133 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+
+	"istio.io/istio/pilot/pkg/features"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestDeltaNackCircuit(t *testing.T) {
+	originalThreshold, originalBackoff := features.DeltaNackCircuitThreshold, features.DeltaNackCircuitBackoff
+	t.Cleanup(func() {
+		features.DeltaNackCircuitThreshold, features.DeltaNackCircuitBackoff = originalThreshold, originalBackoff
+	})
+	features.DeltaNackCircuitThreshold = 2
+	features.DeltaNackCircuitBackoff = 300 * time.Millisecond
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	ads := s.ConnectDeltaADS().WithType(v3.ClusterType)
+	ads.RequestResponseAck(nil)
+
+	circuit := func() *nackCircuit {
+		clients := s.Discovery.Clients()
+		if len(clients) != 1 {
+			return nil
+		}
+		con := clients[0]
+		con.proxy.RLock()
+		defer con.proxy.RUnlock()
+		if c := con.nackCircuits[v3.ClusterType]; c != nil {
+			cp := *c
+			return &cp
+		}
+		return nil
+	}
+	pushNack := func(nacks int) {
+		t.Helper()
+		AdsPushAll(s.Discovery)
+		resp := ads.ExpectResponse()
+		ads.Request(&discovery.DeltaDiscoveryRequest{
+			ResponseNonce: resp.Nonce,
+			ErrorDetail:   &status.Status{Message: "Test request NACK"},
+		})
+		retry.UntilSuccessOrFail(t, func() error {
+			if c := circuit(); c == nil || c.consecutiveNacks != nacks {
+				return fmt.Errorf("NACK %d not recorded yet", nacks)
+			}
+			return nil
+		}, retry.Timeout(time.Second*5))
+	}
+	pushNack(1)
+	if circuit().open {
+		t.Fatalf("circuit must not open below the threshold")
+	}
+	pushNack(2)
+	if c := circuit(); !c.open || c.backoff != features.DeltaNackCircuitBackoff {
+		t.Fatalf("circuit must open at the threshold, got %+v", c)
+	}
+	conditions := s.Discovery.Clients()[0].nackCircuitConditions()
+	if len(conditions) != 1 || conditions[0].Type != PushSuspendedCondition || conditions[0].Reason != RepeatedRejectionsReason ||
+		!strings.Contains(conditions[0].Message, "Test request NACK") {
+		t.Fatalf("expected a %s condition, got %+v", PushSuspendedCondition, conditions)
+	}
+
+	// The circuit is open: pushes are held until the backoff has elapsed, then merged in a single push.
+	AdsPushAll(s.Discovery)
+	AdsPushAll(s.Discovery)
+	ads.ExpectNoResponse()
+	resp := ads.ExpectResponse()
+	ads.ExpectNoResponse()
+
+	// An ACK closes the circuit.
+	ads.Request(&discovery.DeltaDiscoveryRequest{ResponseNonce: resp.Nonce})
+	retry.UntilSuccessOrFail(t, func() error {
+		if c := circuit(); c != nil {
+			return fmt.Errorf("circuit not closed yet: %+v", c)
+		}
+		return nil
+	}, retry.Timeout(time.Second*5))
+	AdsPushAll(s.Discovery)
+	if conditions := s.Discovery.Clients()[0].nackCircuitConditions(); len(conditions) != 0 {
+		t.Fatalf("expected no condition once the circuit is closed, got %+v", conditions)
+	}
+	ads.ExpectResponse()
+}
+
+func TestDeltaNackCircuitBackoff(t *testing.T) {
+	originalThreshold, originalBackoff := features.DeltaNackCircuitThreshold, features.DeltaNackCircuitBackoff
+	t.Cleanup(func() {
+		features.DeltaNackCircuitThreshold, features.DeltaNackCircuitBackoff = originalThreshold, originalBackoff
+	})
+	features.DeltaNackCircuitThreshold = 1
+	features.DeltaNackCircuitBackoff = time.Millisecond
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	con := newDeltaConnection("", nil)
+	con.proxy = s.SetupProxy(nil)
+
+	for i, want := range []time.Duration{1, 2, 4} {
+		s.Discovery.recordDeltaNack(con, v3.ClusterType, "rejected")
+		c := con.nackCircuits[v3.ClusterType]
+		if !c.open || c.backoff != want*time.Millisecond {
+			t.Fatalf("NACK %d: expected open circuit with backoff %v, got %+v", i, want*time.Millisecond, c)
+		}
+		if d := con.pushDelay(v3.ClusterType); d <= 0 || d > c.backoff {
+			t.Fatalf("NACK %d: expected push delay up to %v, got %v", i, c.backoff, d)
+		}
+		time.Sleep(c.backoff)
+	}
+	s.Discovery.recordDeltaAck(con, v3.ClusterType)
+	if d := con.pushDelay(v3.ClusterType); d != 0 {
+		t.Fatalf("expected no push delay after ACK, got %v", d)
+	}
+}
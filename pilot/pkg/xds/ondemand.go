@@ -0,0 +1,269 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"sync"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/sets"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+// onDemandTypes are the types whose subscriptions are counted by deltaWatches.
+var onDemandTypes = sets.NewSet(v3.ClusterType, v3.EndpointType)
+
+// deltaWatches counts the cluster and endpoint names subscribed over delta xDS for each service hostname, across
+// all connections. Wildcard subscriptions are not counted.
+type deltaWatches struct {
+	mu sync.Mutex
+	// hosts is the number of subscribed names of each hostname.
+	hosts map[string]int
+	// byCon is the set of names counted for each connection, keyed by connection ID and TypeUrl.
+	byCon map[string]map[string]sets.Set
+	// scopeHosts is the number of connections not counted by hosts, i.e. connected over SotW or subscribing to
+	// clusters by wildcard, with each hostname in their scope.
+	scopeHosts map[string]int
+	// scopes is the scope counted by scopeHosts for each connection, keyed by connection ID.
+	scopes map[string]*model.SidecarScope
+}
+
+// watchedResourceGenerator is implemented by the generators of wildcard types which can generate only the resources
+// watched by name, for the proxies subscribing to the type by names.
+type watchedResourceGenerator interface {
+	GenerateWatched(proxy *model.Proxy, push *model.PushContext, updates *model.PushRequest,
+		w *model.WatchedResource) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error)
+}
+
+var _ watchedResourceGenerator = &CdsGenerator{}
+
+// isExplicitSubscription returns whether a delta subscription of a wildcard type is by resource names.
+func isExplicitSubscription(names []string) bool {
+	if len(names) == 0 {
+		return false
+	}
+	for _, n := range names {
+		if n == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// isExplicitDelta returns whether the connection subscribed to the type by resource names.
+func (conn *Connection) isExplicitDelta(typeURL string) bool {
+	if !isWildcardTypeURL(typeURL) {
+		return false
+	}
+	conn.proxy.RLock()
+	defer conn.proxy.RUnlock()
+	return conn.deltaExplicit[typeURL]
+}
+
+// trackDeltaWatches counts the names of the type currently watched by the connection, and releases the state of
+// the services no connection watches anymore.
+func (s *DiscoveryServer) trackDeltaWatches(con *Connection, typeURL string) {
+	if !features.EnableDeltaOnDemand || !onDemandTypes.Contains(typeURL) {
+		return
+	}
+	if isWildcardTypeURL(typeURL) && !con.isExplicitDelta(typeURL) {
+		return
+	}
+	var names sets.Set
+	if w := con.Watched(typeURL); w != nil {
+		con.proxy.RLock()
+		names = sets.NewSet(w.ResourceNames...)
+		con.proxy.RUnlock()
+	}
+
+	s.deltaWatches.mu.Lock()
+	// The connection may have been closed concurrently, in which case its names must not be counted again.
+	s.adsClientsMutex.RLock()
+	_, connected := s.adsClients[con.ConID]
+	s.adsClientsMutex.RUnlock()
+	if !connected {
+		s.deltaWatches.mu.Unlock()
+		return
+	}
+	if s.deltaWatches.byCon == nil {
+		s.deltaWatches.byCon = map[string]map[string]sets.Set{}
+		s.deltaWatches.hosts = map[string]int{}
+	}
+	if s.deltaWatches.byCon[con.ConID] == nil {
+		s.deltaWatches.byCon[con.ConID] = map[string]sets.Set{}
+	}
+	previous := s.deltaWatches.byCon[con.ConID][typeURL]
+	s.deltaWatches.byCon[con.ConID][typeURL] = names
+	released := s.deltaWatches.update(previous.Difference(names), -1)
+	s.deltaWatches.update(names.Difference(previous), 1)
+	s.deltaWatches.mu.Unlock()
+
+	s.releaseUnwatched(released)
+}
+
+// trackWildcardScope counts the services in the scope of the connection, if it is not counted by deltaWatches. It
+// is expected to be called by the goroutine updating the proxy state, once the SidecarScope may have changed.
+func (s *DiscoveryServer) trackWildcardScope(con *Connection) {
+	if !features.EnableDeltaOnDemand {
+		return
+	}
+	con.proxy.RLock()
+	scope := con.proxy.SidecarScope
+	if con.deltaStream != nil && con.deltaExplicit[v3.ClusterType] {
+		scope = nil
+	}
+	con.proxy.RUnlock()
+
+	s.deltaWatches.mu.Lock()
+	defer s.deltaWatches.mu.Unlock()
+	previous := s.deltaWatches.scopes[con.ConID]
+	if previous == scope {
+		return
+	}
+	// The connection may have been closed concurrently, in which case its scope must not be counted again.
+	s.adsClientsMutex.RLock()
+	_, connected := s.adsClients[con.ConID]
+	s.adsClientsMutex.RUnlock()
+	if !connected {
+		return
+	}
+	if s.deltaWatches.scopes == nil {
+		s.deltaWatches.scopes = map[string]*model.SidecarScope{}
+		s.deltaWatches.scopeHosts = map[string]int{}
+	}
+	s.deltaWatches.updateScope(previous, -1)
+	s.deltaWatches.updateScope(scope, 1)
+	if scope == nil {
+		delete(s.deltaWatches.scopes, con.ConID)
+	} else {
+		s.deltaWatches.scopes[con.ConID] = scope
+	}
+}
+
+// untrackDeltaWatches removes the names and the scope counted for a closed connection.
+func (s *DiscoveryServer) untrackDeltaWatches(con *Connection) {
+	s.deltaWatches.mu.Lock()
+	var released []string
+	for _, names := range s.deltaWatches.byCon[con.ConID] {
+		released = append(released, s.deltaWatches.update(names, -1)...)
+	}
+	delete(s.deltaWatches.byCon, con.ConID)
+	s.deltaWatches.updateScope(s.deltaWatches.scopes[con.ConID], -1)
+	delete(s.deltaWatches.scopes, con.ConID)
+	s.deltaWatches.mu.Unlock()
+
+	s.releaseUnwatched(released)
+}
+
+// update adds delta to the count of the hostname of each name, and returns the hostnames no longer watched.
+// The mutex must be held.
+func (w *deltaWatches) update(names sets.Set, delta int) []string {
+	var released []string
+	for name := range names {
+		_, _, hostname, _ := model.ParseSubsetKey(name)
+		if hostname == "" {
+			continue
+		}
+		h := string(hostname)
+		w.hosts[h] += delta
+		if w.hosts[h] <= 0 {
+			delete(w.hosts, h)
+			released = append(released, h)
+		}
+	}
+	return released
+}
+
+// updateScope adds delta to the count of the hostname of each service of the scope. The mutex must be held.
+func (w *deltaWatches) updateScope(scope *model.SidecarScope, delta int) {
+	if scope == nil {
+		return
+	}
+	for _, svc := range scope.Services() {
+		h := string(svc.Hostname)
+		w.scopeHosts[h] += delta
+		if w.scopeHosts[h] <= 0 {
+			delete(w.scopeHosts, h)
+		}
+	}
+}
+
+// watched returns the number of subscribed names of the hostname.
+func (w *deltaWatches) watched(hostname string) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.hosts[hostname]
+}
+
+// releaseUnwatched clears the cached configuration of the services, which is regenerated on demand once they are
+// watched again. Their endpoint shards are kept, as they hold the state of the registries. The services still in
+// the scope of a wildcard subscriber are kept.
+func (s *DiscoveryServer) releaseUnwatched(hostnames []string) {
+	hostnames = s.outOfWildcardScope(hostnames)
+	if len(hostnames) == 0 {
+		return
+	}
+	keys := map[model.ConfigKey]struct{}{}
+	s.mutex.RLock()
+	for _, hostname := range hostnames {
+		for namespace := range s.EndpointShardsByService[hostname] {
+			keys[model.ConfigKey{Kind: gvk.ServiceEntry, Name: hostname, Namespace: namespace}] = struct{}{}
+		}
+	}
+	s.mutex.RUnlock()
+	if len(keys) > 0 {
+		deltaLog.Debugf("releasing unwatched services %v", hostnames)
+		s.Cache.Clear(keys)
+	}
+}
+
+// outOfWildcardScope returns the hostnames which are not in the scope of a connection that is not counted by
+// deltaWatches, i.e. connected over SotW or subscribing to clusters by wildcard, as such connections receive the
+// configuration of all the services of their scope from the cache.
+func (s *DiscoveryServer) outOfWildcardScope(hostnames []string) []string {
+	s.deltaWatches.mu.Lock()
+	defer s.deltaWatches.mu.Unlock()
+	var out []string
+	for _, hostname := range hostnames {
+		if s.deltaWatches.scopeHosts[hostname] == 0 {
+			out = append(out, hostname)
+		}
+	}
+	return out
+}
+
+// filterWatched keeps the resources and removals of the watched names, for proxies subscribing to a wildcard
+// type by names.
+func filterWatched(watched []string, res model.Resources, removed model.DeletedResources) (model.Resources, model.DeletedResources) {
+	names := sets.NewSet(watched...)
+	var outRes model.Resources
+	for _, r := range res {
+		if names.Contains(r.Name) {
+			outRes = append(outRes, r)
+		}
+	}
+	var outRemoved model.DeletedResources
+	for _, r := range removed {
+		if names.Contains(r) {
+			outRemoved = append(outRemoved, r)
+		}
+	}
+	if outRes == nil && res != nil {
+		outRes = model.Resources{}
+	}
+	return outRes, outRemoved
+}
@@ -0,0 +1,172 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func setDeltaOnDemand(t *testing.T) {
+	original := features.EnableDeltaOnDemand
+	t.Cleanup(func() {
+		features.EnableDeltaOnDemand = original
+	})
+	features.EnableDeltaOnDemand = true
+}
+
+func TestDeltaOnDemandWatches(t *testing.T) {
+	setDeltaOnDemand(t)
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	const (
+		hostname = "local.default.svc.cluster.local"
+		cluster  = "outbound|80||" + hostname
+	)
+	expectWatched := func(want int) {
+		t.Helper()
+		retry.UntilSuccessOrFail(t, func() error {
+			if got := s.Discovery.deltaWatches.watched(hostname); got != want {
+				return fmt.Errorf("expected %d watches, got %d", want, got)
+			}
+			return nil
+		})
+	}
+
+	a := s.ConnectDeltaADS().WithType(v3.EndpointType)
+	b := s.ConnectDeltaADS().WithType(v3.EndpointType)
+	res := a.RequestResponseAck(&discovery.DeltaDiscoveryRequest{ResourceNamesSubscribe: []string{cluster}})
+	b.RequestResponseAck(&discovery.DeltaDiscoveryRequest{ResourceNamesSubscribe: []string{cluster}})
+	expectWatched(2)
+
+	// Unsubscribing one proxy keeps the service watched by the other.
+	a.Request(&discovery.DeltaDiscoveryRequest{ResponseNonce: res.Nonce, ResourceNamesUnsubscribe: []string{cluster}})
+	expectWatched(1)
+
+	// Disconnecting the last proxy releases the service.
+	b.Cleanup()
+	expectWatched(0)
+}
+
+func TestDeltaOnDemandExplicitClusters(t *testing.T) {
+	setDeltaOnDemand(t)
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: example
+  namespace: default
+spec:
+  hosts:
+  - example.com
+  - other.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: DNS
+`})
+	const cluster = "outbound|80||example.com"
+
+	wildcard := s.ConnectDeltaADS().WithType(v3.ClusterType).RequestResponseAck(nil)
+	if len(wildcard.Resources) <= 1 {
+		t.Fatalf("expected all clusters for a wildcard subscription, got %v", extractNames(wildcard.Resources))
+	}
+
+	explicit := s.ConnectDeltaADS().WithType(v3.ClusterType).RequestResponseAck(
+		&discovery.DeltaDiscoveryRequest{ResourceNamesSubscribe: []string{cluster}})
+	if got := extractNames(explicit.Resources); !reflect.DeepEqual(got, []string{cluster}) {
+		t.Fatalf("expected only the subscribed cluster, got %v", got)
+	}
+	if got := s.Discovery.deltaWatches.watched("example.com"); got != 1 {
+		t.Fatalf("expected 1 watch, got %d", got)
+	}
+}
+
+func TestDeltaOnDemandGeneratesWatchedClusters(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: example
+  namespace: default
+spec:
+  hosts:
+  - example.com
+  - other.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: DNS
+`})
+	proxy := s.SetupProxy(nil)
+	w := &model.WatchedResource{TypeUrl: v3.ClusterType, ResourceNames: []string{"outbound|80||example.com"}}
+	clusters, _, _, _ := s.Discovery.ConfigGenerator.BuildWatchedClusters(proxy, &model.PushRequest{Push: s.PushContext(), Full: true}, w)
+	for _, name := range extractNames(clusters) {
+		if _, _, hostname, _ := model.ParseSubsetKey(name); hostname == "other.example.com" {
+			t.Fatalf("expected only the clusters of the watched services to be built, got %v", name)
+		}
+	}
+}
+
+func TestDeltaOnDemandKeepsWildcardScope(t *testing.T) {
+	setDeltaOnDemand(t)
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: example
+  namespace: default
+spec:
+  hosts:
+  - example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: DNS
+`})
+	const hostname = "example.com"
+
+	explicit := s.ConnectDeltaADS().WithType(v3.ClusterType)
+	explicit.RequestResponseAck(&discovery.DeltaDiscoveryRequest{ResourceNamesSubscribe: []string{"outbound|80||" + hostname}})
+	if got := s.Discovery.outOfWildcardScope([]string{hostname}); !reflect.DeepEqual(got, []string{hostname}) {
+		t.Fatalf("expected the service to be released without wildcard subscribers, got %v", got)
+	}
+
+	// The configuration of the services in the scope of a wildcard subscriber is still in use.
+	wildcard := s.ConnectDeltaADS().WithType(v3.ClusterType)
+	wildcard.RequestResponseAck(nil)
+	if got := s.Discovery.outOfWildcardScope([]string{hostname}); len(got) != 0 {
+		t.Fatalf("expected the service to be kept for the wildcard subscriber, got %v", got)
+	}
+
+	// Disconnecting the wildcard subscriber removes its scope.
+	wildcard.Cleanup()
+	retry.UntilSuccessOrFail(t, func() error {
+		if got := s.Discovery.outOfWildcardScope([]string{hostname}); !reflect.DeepEqual(got, []string{hostname}) {
+			return fmt.Errorf("expected the service to be released once the wildcard subscriber disconnected, got %v", got)
+		}
+		return nil
+	})
+}
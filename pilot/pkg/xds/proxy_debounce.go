@@ -0,0 +1,125 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/constants"
+)
+
+// heldPush is a push to a connection held until its quiet period elapses, when the proxy requested a push
+// debounce longer than the debounce of istiod.
+type heldPush struct {
+	mu    sync.Mutex
+	req   *model.PushRequest
+	since time.Time
+	timer *time.Timer
+	// stopped is set once the connection closed, so no push is held or queued anymore.
+	stopped bool
+}
+
+// stop drops the held push, once the connection closed.
+func (h *heldPush) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopped = true
+	h.req = nil
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+}
+
+// proxyPushDebounce returns the push debounce requested by the proxy with the proxy.istio.io/push-debounce
+// annotation, or 0. The debounce is raised to PILOT_PROXY_DEBOUNCE_MIN, so that proxies cannot make istiod
+// compute pushes more often than the mesh allows.
+func proxyPushDebounce(proxy *model.Proxy) time.Duration {
+	if proxy.Metadata == nil {
+		return 0
+	}
+	v, f := proxy.Metadata.Annotations[constants.ProxyPushDebounceAnnotation]
+	if !f {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Warnf("ignoring invalid %s annotation %q of %s", constants.ProxyPushDebounceAnnotation, v, proxy.ID)
+		return 0
+	}
+	if d < features.ProxyDebounceMin {
+		return features.ProxyDebounceMin
+	}
+	return d
+}
+
+// shortestProxyDebounce returns the shortest push debounce requested by the connected proxies, if any.
+func (s *DiscoveryServer) shortestProxyDebounce() (time.Duration, bool) {
+	s.adsClientsMutex.RLock()
+	defer s.adsClientsMutex.RUnlock()
+	var shortest time.Duration
+	for _, d := range s.proxyDebounces {
+		if shortest == 0 || d < shortest {
+			shortest = d
+		}
+	}
+	return shortest, shortest > 0
+}
+
+// enqueuePush queues a push to the connection. When the debounce of the proxy is longer than the current debounce
+// of istiod, which is shortened by other proxies, the push is held and merged with the following ones until the
+// proxy debounce elapses without pushes, or until the maximum debounce elapses.
+func (s *DiscoveryServer) enqueuePush(con *Connection, req *model.PushRequest) {
+	debounce := con.pushDebounce
+	if debounce == 0 {
+		debounce = s.debounceOptions.debounceAfter
+	}
+	hold := debounce - s.debounceOptions.after()
+	if hold <= 0 {
+		s.pushQueue.Enqueue(con, req)
+		return
+	}
+
+	h := &con.heldPush
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped {
+		return
+	}
+	if h.req == nil {
+		h.since = time.Now()
+	}
+	h.req = h.req.CopyMerge(req)
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+	if remaining := s.debounceOptions.debounceMax - time.Since(h.since); remaining < hold {
+		hold = remaining
+	}
+	h.timer = time.AfterFunc(hold, func() {
+		h.mu.Lock()
+		held := h.req
+		h.req = nil
+		h.timer = nil
+		stopped := h.stopped
+		h.mu.Unlock()
+		if held != nil && !stopped {
+			s.pushQueue.Enqueue(con, held)
+		}
+	})
+}
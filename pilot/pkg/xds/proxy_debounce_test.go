@@ -0,0 +1,122 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/sets"
+	"istio.io/istio/pkg/config/constants"
+)
+
+func TestProxyPushDebounce(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		want        time.Duration
+	}{
+		{"unset", nil, 0},
+		{"valid", map[string]string{constants.ProxyPushDebounceAnnotation: "200ms"}, 200 * time.Millisecond},
+		{"clamped", map[string]string{constants.ProxyPushDebounceAnnotation: "1ms"}, features.ProxyDebounceMin},
+		{"invalid", map[string]string{constants.ProxyPushDebounceAnnotation: "soon"}, 0},
+		{"negative", map[string]string{constants.ProxyPushDebounceAnnotation: "-1s"}, 0},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := &model.Proxy{ID: "app.default", Metadata: &model.NodeMetadata{Annotations: tt.annotations}}
+			if got := proxyPushDebounce(proxy); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDebounceOptionsAfter(t *testing.T) {
+	opts := debounceOptions{debounceAfter: 100 * time.Millisecond}
+	if got := opts.after(); got != 100*time.Millisecond {
+		t.Fatalf("got %v without overrides", got)
+	}
+	s := &DiscoveryServer{proxyDebounces: map[string]time.Duration{}}
+	opts.proxyDebounceAfter = s.shortestProxyDebounce
+	if got := opts.after(); got != 100*time.Millisecond {
+		t.Fatalf("got %v without connections with overrides", got)
+	}
+	s.proxyDebounces["a"] = 20 * time.Millisecond
+	s.proxyDebounces["b"] = 10 * time.Millisecond
+	s.proxyDebounces["c"] = time.Second
+	if got := opts.after(); got != 10*time.Millisecond {
+		t.Fatalf("got %v, want the shortest override", got)
+	}
+}
+
+func TestEnqueuePushHold(t *testing.T) {
+	s := &DiscoveryServer{
+		pushQueue:      NewPushQueue(),
+		proxyDebounces: map[string]time.Duration{"fast": 10 * time.Millisecond},
+		debounceOptions: debounceOptions{
+			debounceAfter: 50 * time.Millisecond,
+			debounceMax:   time.Second,
+		},
+	}
+	s.debounceOptions.proxyDebounceAfter = s.shortestProxyDebounce
+	fast := &Connection{ConID: "fast", pushDebounce: 10 * time.Millisecond}
+	slow := &Connection{ConID: "slow"}
+
+	// The connection requesting the shortest debounce is not held.
+	s.enqueuePush(fast, &model.PushRequest{Full: true})
+	if got := s.pushQueue.Pending(); got != 1 {
+		t.Fatalf("expected the push to be queued, got %d pending", got)
+	}
+
+	// Other connections keep the global debounce, merging the pushes in the meantime.
+	s.enqueuePush(slow, &model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{{Name: "a"}: {}}})
+	s.enqueuePush(slow, &model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{{Name: "b"}: {}}})
+	if got := s.pushQueue.Pending(); got != 1 {
+		t.Fatalf("expected the push to be held, got %d pending", got)
+	}
+	s.pushQueue.Dequeue()
+	con, req, _ := s.pushQueue.Dequeue()
+	if con != slow {
+		t.Fatalf("expected the held push, got one for %v", con.ConID)
+	}
+	names := sets.NewSet()
+	for k := range req.ConfigsUpdated {
+		names.Insert(k.Name)
+	}
+	if !names.Equals(sets.NewSet("a", "b")) {
+		t.Fatalf("expected the held pushes to be merged, got %v", names)
+	}
+}
+
+func TestHeldPushStop(t *testing.T) {
+	s := &DiscoveryServer{
+		pushQueue: NewPushQueue(),
+		debounceOptions: debounceOptions{
+			debounceAfter: 10 * time.Millisecond,
+			debounceMax:   time.Second,
+		},
+	}
+	con := &Connection{ConID: "slow", pushDebounce: 50 * time.Millisecond}
+	s.enqueuePush(con, &model.PushRequest{Full: true})
+	con.heldPush.stop()
+	s.enqueuePush(con, &model.PushRequest{Full: true})
+	time.Sleep(100 * time.Millisecond)
+	if got := s.pushQueue.Pending(); got != 0 {
+		t.Fatalf("expected no push to a closed connection, got %d pending", got)
+	}
+}
@@ -15,8 +15,12 @@
 package xds
 
 import (
+	"fmt"
+	"net/http"
+
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collection"
 	"istio.io/istio/pkg/config/schema/gvk"
 )
 
@@ -93,3 +97,60 @@ func DefaultProxyNeedsPush(proxy *model.Proxy, req *model.PushRequest) bool {
 
 	return false
 }
+
+// ProxiesAffectedBy returns the connected proxies whose configuration depends on the config, as computed by
+// model.ProxyDependsOnConfig.
+func (s *DiscoveryServer) ProxiesAffectedBy(cfg config.Config) []*model.Proxy {
+	var proxies []*model.Proxy
+	for _, con := range s.Clients() {
+		if model.ProxyDependsOnConfig(con.proxy, cfg) {
+			proxies = append(proxies, con.proxy)
+		}
+	}
+	return proxies
+}
+
+// AffectedProxies lists the connected proxies affected by a config.
+type AffectedProxies struct {
+	Kind      string   `json:"kind"`
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Proxies   []string `json:"proxies"`
+}
+
+// affectedProxiesz lists the proxies connected to this istiod whose configuration depends on the config identified
+// by the kind, name and namespace query parameters. The group parameter disambiguates kinds defined in several
+// groups, such as Gateway.
+func (s *DiscoveryServer) affectedProxiesz(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	kind, group, name, namespace := q.Get("kind"), q.Get("group"), q.Get("name"), q.Get("namespace")
+	if kind == "" || name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("You must provide a kind, name and namespace in the query string\n"))
+		return
+	}
+	var matches []config.GroupVersionKind
+	s.Env.IstioConfigStore.Schemas().ForEach(func(schema collection.Schema) bool {
+		r := schema.Resource()
+		if r.Kind() == kind && (group == "" || r.Group() == group) {
+			matches = append(matches, r.GroupVersionKind())
+		}
+		return false
+	})
+	if len(matches) != 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(fmt.Sprintf("%d config kinds match %s, set a valid group\n", len(matches), kind)))
+		return
+	}
+	cfg := s.Env.IstioConfigStore.Get(matches[0], name, namespace)
+	if cfg == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(fmt.Sprintf("%s %s/%s not found\n", kind, namespace, name)))
+		return
+	}
+	out := AffectedProxies{Kind: kind, Name: name, Namespace: namespace, Proxies: []string{}}
+	for _, proxy := range s.ProxiesAffectedBy(*cfg) {
+		out.Proxies = append(out.Proxies, proxy.ID)
+	}
+	writeJSON(w, out)
+}
@@ -15,14 +15,21 @@
 package xds
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"testing"
 
 	model "istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/test/util/retry"
 )
 
 func TestProxyNeedsPush(t *testing.T) {
@@ -257,3 +264,119 @@ func TestCheckConnectionIdentity(t *testing.T) {
 		})
 	}
 }
+
+const affectedProxiesConfig = `
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: ingress
+  namespace: istio-system
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+  - port:
+      number: 80
+      name: http
+      protocol: HTTP
+    hosts: ["*"]
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: ingress
+  namespace: default
+spec:
+  hosts: ["example.com"]
+  gateways: ["istio-system/ingress"]
+  http:
+  - route:
+    - destination:
+        host: a.default.svc.cluster.local
+---
+apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: a
+  namespace: default
+spec:
+  selector:
+    matchLabels:
+      app: a
+`
+
+func TestAffectedProxiesz(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: affectedProxiesConfig})
+	s.Connect(&model.Proxy{
+		Type:            model.Router,
+		IPAddresses:     []string{"10.0.0.1"},
+		ConfigNamespace: "istio-system",
+		Metadata:        &model.NodeMetadata{Labels: map[string]string{"istio": "ingressgateway"}},
+	}, nil, nil)
+	s.Connect(&model.Proxy{
+		IPAddresses: []string{"10.0.0.2"},
+		Metadata:    &model.NodeMetadata{Labels: map[string]string{"app": "a"}},
+	}, nil, nil)
+	s.Connect(&model.Proxy{
+		IPAddresses: []string{"10.0.0.3"},
+		Metadata:    &model.NodeMetadata{Labels: map[string]string{"app": "b"}},
+	}, nil, nil)
+	retry.UntilSuccessOrFail(t, func() error {
+		if n := len(s.Discovery.Clients()); n != 3 {
+			return fmt.Errorf("expected 3 clients, got %d", n)
+		}
+		return nil
+	})
+
+	cases := []struct {
+		query string
+		code  int
+		want  []string
+	}{
+		{query: "kind=Gateway&group=networking.istio.io&name=ingress&namespace=istio-system", code: http.StatusOK, want: []string{"10.0.0.1"}},
+		{query: "kind=VirtualService&name=ingress&namespace=default", code: http.StatusOK, want: []string{"10.0.0.1"}},
+		{query: "kind=AuthorizationPolicy&name=a&namespace=default", code: http.StatusOK, want: []string{"10.0.0.2"}},
+		{query: "kind=AuthorizationPolicy&name=missing&namespace=default", code: http.StatusNotFound},
+		{query: "kind=Unknown&name=a&namespace=default", code: http.StatusBadRequest},
+		{query: "name=a", code: http.StatusBadRequest},
+	}
+	for _, tt := range cases {
+		t.Run(tt.query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(s.Discovery.affectedProxiesz).ServeHTTP(rr, httptest.NewRequest("GET", "/debug/affected_proxies?"+tt.query, nil))
+			if rr.Code != tt.code {
+				t.Fatalf("expected code %d, got %d: %s", tt.code, rr.Code, rr.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			out := AffectedProxies{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+				t.Fatalf("invalid json %v: %s", err, rr.Body.String())
+			}
+			if len(out.Proxies) != len(tt.want) {
+				t.Fatalf("expected %d proxies, got %v", len(tt.want), out.Proxies)
+			}
+			// The proxies connected by the test share their ID, so they are told apart by IP.
+			cfg := s.Discovery.Env.IstioConfigStore.Get(gvkForKind(t, s, out.Kind), out.Name, out.Namespace)
+			var got []string
+			for _, proxy := range s.Discovery.ProxiesAffectedBy(*cfg) {
+				got = append(got, proxy.IPAddresses[0])
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected proxies %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func gvkForKind(t *testing.T, s *FakeDiscoveryServer, kind string) config.GroupVersionKind {
+	for _, schema := range s.Discovery.Env.IstioConfigStore.Schemas().All() {
+		if r := schema.Resource(); r.Kind() == kind && strings.HasSuffix(r.Group(), "istio.io") {
+			return r.GroupVersionKind()
+		}
+	}
+	t.Fatalf("unknown kind %s", kind)
+	return config.GroupVersionKind{}
+}
@@ -0,0 +1,67 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// defaultPushPredicate is the name of DiscoveryServer.ProxyNeedsPush in the skipped pushes metric.
+const defaultPushPredicate = "default"
+
+// PushPredicate is consulted, after DiscoveryServer.ProxyNeedsPush, before pushing to a proxy. This allows skipping
+// pushes based on platform or operational state, for example to proxies labeled as frozen or to a namespace under
+// maintenance.
+type PushPredicate interface {
+	// Name identifies the predicate in the pilot_xds_skipped_pushes_total metric.
+	Name() string
+	// NeedsPush returns false if the push to the proxy must be skipped.
+	NeedsPush(proxy *model.Proxy, req *model.PushRequest) bool
+}
+
+// PushPredicateFunc returns a PushPredicate with the name, calling f.
+func PushPredicateFunc(name string, f func(proxy *model.Proxy, req *model.PushRequest) bool) PushPredicate {
+	return pushPredicateFunc{name: name, f: f}
+}
+
+type pushPredicateFunc struct {
+	name string
+	f    func(proxy *model.Proxy, req *model.PushRequest) bool
+}
+
+func (p pushPredicateFunc) Name() string {
+	return p.name
+}
+
+func (p pushPredicateFunc) NeedsPush(proxy *model.Proxy, req *model.PushRequest) bool {
+	return p.f(proxy, req)
+}
+
+// proxyNeedsPush returns true if ProxyNeedsPush and all the registered PushPredicates require the push. Otherwise,
+// it returns the name of the predicate which skipped the push, defaultPushPredicate if the push is not relevant to
+// the proxy. Skipped pushes are counted by the predicate which skipped them.
+func (s *DiscoveryServer) proxyNeedsPush(proxy *model.Proxy, req *model.PushRequest) (bool, string) {
+	if !s.ProxyNeedsPush(proxy, req) {
+		skippedPushes.With(predicateTag.Value(defaultPushPredicate)).Increment()
+		return false, defaultPushPredicate
+	}
+	for _, p := range s.PushPredicates {
+		if !p.NeedsPush(proxy, req) {
+			skippedPushes.With(predicateTag.Value(p.Name())).Increment()
+			return false, p.Name()
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	"go.opencensus.io/stats/view"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestPushPredicates(t *testing.T) {
+	frozen := PushPredicateFunc("frozen", func(proxy *model.Proxy, _ *model.PushRequest) bool {
+		return proxy.Metadata.Labels["frozen"] != "true"
+	})
+	maintenance := PushPredicateFunc("maintenance", func(proxy *model.Proxy, _ *model.PushRequest) bool {
+		return proxy.ConfigNamespace != "maintenance"
+	})
+	s := &DiscoveryServer{
+		ProxyNeedsPush: func(proxy *model.Proxy, _ *model.PushRequest) bool {
+			return proxy.ConfigNamespace != "unaffected"
+		},
+		PushPredicates: []PushPredicate{frozen, maintenance},
+	}
+	cases := []struct {
+		name      string
+		namespace string
+		labels    map[string]string
+		want      bool
+		predicate string
+	}{
+		{name: "push", namespace: "default", want: true},
+		{name: "default", namespace: "unaffected", labels: map[string]string{"frozen": "true"}, predicate: defaultPushPredicate},
+		{name: "frozen", namespace: "maintenance", labels: map[string]string{"frozen": "true"}, predicate: "frozen"},
+		{name: "maintenance", namespace: "maintenance", predicate: "maintenance"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			before := skippedPushesFor(tt.predicate)
+			proxy := &model.Proxy{ConfigNamespace: tt.namespace, Metadata: &model.NodeMetadata{Labels: tt.labels}}
+			got, skippedBy := s.proxyNeedsPush(proxy, &model.PushRequest{Full: true})
+			if got != tt.want || skippedBy != tt.predicate {
+				t.Fatalf("expected push %v skipped by %q, got %v skipped by %q", tt.want, tt.predicate, got, skippedBy)
+			}
+			if tt.predicate == "" {
+				return
+			}
+			if after := skippedPushesFor(tt.predicate); after != before+1 {
+				t.Fatalf("expected skipped push recorded for %s, got %v then %v", tt.predicate, before, after)
+			}
+		})
+	}
+}
+
+func skippedPushesFor(predicate string) float64 {
+	rows, err := view.RetrieveData("pilot_xds_skipped_pushes_total")
+	if err != nil {
+		return 0
+	}
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key.Name() == "predicate" && tag.Value == predicate {
+				return row.Data.(*view.SumData).Value
+			}
+		}
+	}
+	return 0
+}
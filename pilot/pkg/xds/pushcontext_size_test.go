@@ -0,0 +1,59 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestPushContextSizez(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{
+		ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: vs
+  namespace: default
+spec:
+  hosts:
+  - example.com
+  http:
+  - route:
+    - destination:
+        host: example.com
+`,
+	})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(s.Discovery.pushContextSizez).ServeHTTP(rr, httptest.NewRequest("GET", "/debug/pushcontext_size", nil))
+	out := pushContextSizeResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.PushVersion != s.PushContext().PushVersion {
+		t.Errorf("got push version %q, want %q", out.PushVersion, s.PushContext().PushVersion)
+	}
+	vs := out.Indexes[model.VirtualServicesIndexName]
+	if vs.Entries != 1 || vs.Bytes <= 0 || vs.Size == "" {
+		t.Errorf("unexpected virtual service index size %+v", vs)
+	}
+	if _, f := out.Indexes[model.ServicesIndexName]; !f || out.Total == "" {
+		t.Errorf("unexpected response %+v", out)
+	}
+}
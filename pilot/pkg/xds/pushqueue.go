@@ -64,11 +64,18 @@ func (p *PushQueue) Enqueue(con *Connection, pushRequest *model.PushRequest) {
 
 	if request, f := p.pending[con]; f {
 		p.pending[con] = request.CopyMerge(pushRequest)
+		if pushRequest.Priority && !request.Priority {
+			p.moveToFront(con)
+		}
 		return
 	}
 
 	p.pending[con] = pushRequest
-	p.queue = append(p.queue, con)
+	if pushRequest.Priority {
+		p.queue = append([]*Connection{con}, p.queue...)
+	} else {
+		p.queue = append(p.queue, con)
+	}
 	// Signal waiters on Dequeue that a new item is available
 	p.cond.Signal()
 }
@@ -113,11 +120,27 @@ func (p *PushQueue) MarkDone(con *Connection) {
 	// This means we need to add it back to the queue.
 	if request != nil {
 		p.pending[con] = request
-		p.queue = append(p.queue, con)
+		if request.Priority {
+			p.queue = append([]*Connection{con}, p.queue...)
+		} else {
+			p.queue = append(p.queue, con)
+		}
 		p.cond.Signal()
 	}
 }
 
+// moveToFront moves a pending connection to the front of the queue, so it is pushed next.
+// Callers must hold the lock.
+func (p *PushQueue) moveToFront(con *Connection) {
+	for i, c := range p.queue {
+		if c == con {
+			copy(p.queue[1:i+1], p.queue[:i])
+			p.queue[0] = con
+			return
+		}
+	}
+}
+
 // Get number of pending proxies
 func (p *PushQueue) Pending() int {
 	p.cond.L.Lock()
@@ -117,6 +117,23 @@ func TestProxyQueue(t *testing.T) {
 		ExpectTimeout(t, p)
 	})
 
+	t.Run("priority push goes first", func(t *testing.T) {
+		t.Parallel()
+		p := NewPushQueue()
+		defer p.ShutDown()
+
+		p.Enqueue(proxies[0], &model.PushRequest{})
+		p.Enqueue(proxies[1], &model.PushRequest{})
+		p.Enqueue(proxies[2], &model.PushRequest{Priority: true})
+		// Merging a priority request into a pending one moves it to the front as well
+		p.Enqueue(proxies[1], &model.PushRequest{Priority: true})
+
+		ExpectDequeue(t, p, proxies[1])
+		ExpectDequeue(t, p, proxies[2])
+		ExpectDequeue(t, p, proxies[0])
+		ExpectTimeout(t, p)
+	})
+
 	t.Run("add and remove and markdone", func(t *testing.T) {
 		t.Parallel()
 		p := NewPushQueue()
@@ -0,0 +1,88 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"sync/atomic"
+	"time"
+
+	"istio.io/istio/pilot/pkg/features"
+)
+
+const (
+	// idlePolicyUnacked closes the connections without requests with a response not ACKed.
+	idlePolicyUnacked = "unacked"
+	// idlePolicyInactive closes all the connections without requests.
+	idlePolicyInactive = "inactive"
+)
+
+// reapStaleConnections periodically closes the stale connections, if PILOT_XDS_IDLE_TIMEOUT is set. Closing the
+// connections frees their state, as if the proxies disconnected.
+func (s *DiscoveryServer) reapStaleConnections(stopCh <-chan struct{}) {
+	timeout := features.XDSIdleTimeout
+	if timeout <= 0 {
+		return
+	}
+	if features.XDSIdlePolicy != idlePolicyUnacked && features.XDSIdlePolicy != idlePolicyInactive {
+		log.Warnf("invalid PILOT_XDS_IDLE_POLICY %q, using %q", features.XDSIdlePolicy, idlePolicyUnacked)
+	}
+	interval := timeout / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case now := <-ticker.C:
+			s.reapStale(now, timeout, features.XDSIdlePolicy)
+		}
+	}
+}
+
+// reapStale closes the connections stale at the time.
+func (s *DiscoveryServer) reapStale(now time.Time, timeout time.Duration, policy string) {
+	for _, con := range s.Clients() {
+		if !con.stale(now, timeout, policy) {
+			continue
+		}
+		log.Warnf("ADS: closing stale connection %s: no request received for %v", con.ConID,
+			now.Sub(time.Unix(0, atomic.LoadInt64(&con.lastRequest))))
+		reapedConnections.Increment()
+		con.Stop()
+	}
+}
+
+// stale returns true if the proxy did not send any request for the timeout and, unless the policy is inactive,
+// did not ACK a response sent more than the timeout ago.
+func (conn *Connection) stale(now time.Time, timeout time.Duration, policy string) bool {
+	if now.Sub(time.Unix(0, atomic.LoadInt64(&conn.lastRequest))) <= timeout {
+		return false
+	}
+	if policy == idlePolicyInactive {
+		return true
+	}
+	conn.proxy.RLock()
+	defer conn.proxy.RUnlock()
+	for _, w := range conn.proxy.WatchedResources {
+		pending := w.NonceSent != "" && w.NonceSent != w.NonceAcked && w.NonceSent != w.NonceNacked
+		if pending && now.Sub(w.LastSent) > timeout {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestReapStaleConnections(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	ads := s.ConnectADS().WithType(v3.ClusterType)
+	res := ads.RequestResponseAck(t, nil)
+	ads.Request(t, &discovery.DiscoveryRequest{ResponseNonce: res.Nonce})
+
+	var con *Connection
+	retry.UntilSuccessOrFail(t, func() error {
+		clients := s.Discovery.Clients()
+		if len(clients) != 1 {
+			return fmt.Errorf("expected 1 connection, got %d", len(clients))
+		}
+		con = clients[0]
+		con.proxy.RLock()
+		defer con.proxy.RUnlock()
+		if w := con.proxy.WatchedResources[v3.ClusterType]; w == nil || w.NonceAcked != res.Nonce {
+			return fmt.Errorf("response not acked yet")
+		}
+		return nil
+	}, retry.Timeout(time.Second*5))
+
+	timeout := time.Minute
+	later := time.Now().Add(2 * timeout)
+	if con.stale(time.Now(), timeout, idlePolicyInactive) {
+		t.Fatalf("connection with a recent request must not be stale")
+	}
+	if con.stale(later, timeout, idlePolicyUnacked) {
+		t.Fatalf("connection with all responses acked must not be stale with the unacked policy")
+	}
+	if !con.stale(later, timeout, idlePolicyInactive) {
+		t.Fatalf("connection without requests must be stale with the inactive policy")
+	}
+
+	// Send a push, which is not ACKed.
+	AdsPushAll(s.Discovery)
+	ads.ExpectResponse(t)
+	if !con.stale(later, timeout, idlePolicyUnacked) {
+		t.Fatalf("connection with a response not acked must be stale with the unacked policy")
+	}
+
+	s.Discovery.reapStale(later, timeout, idlePolicyUnacked)
+	ads.ExpectError(t)
+	retry.UntilSuccessOrFail(t, func() error {
+		if n := len(s.Discovery.Clients()); n != 0 {
+			return fmt.Errorf("expected reaped connection to be removed, got %d connections", n)
+		}
+		return nil
+	}, retry.Timeout(time.Second*5))
+}
@@ -19,6 +19,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"strings"
+	"sync"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoytls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
@@ -117,6 +118,7 @@ func (s *SecretGen) Generate(proxy *model.Proxy, push *model.PushContext, w *mod
 	// SDS flow. The pilotSDSCertificateErrors metric and logs handle visibility into invalid references.
 	resources := filterAuthorizedResources(s.parseResources(w.ResourceNames, proxy), proxy, proxyClusterSecrets)
 
+	s.evictLastGood(push)
 	results := model.Resources{}
 	cached, regenerated := 0, 0
 	for _, sr := range resources {
@@ -136,11 +138,22 @@ func (s *SecretGen) Generate(proxy *model.Proxy, push *model.PushContext, w *mod
 			continue
 		}
 		regenerated++
+		policy := missingCredentialPolicy(proxy, sr)
 		res := s.generate(sr, configClusterSecrets, proxyClusterSecrets)
-		if res != nil {
-			s.cache.Add(sr, req, res)
-			results = append(results, res)
+		if res == nil {
+			// The fallback is not cached, so the credential is read again on the next push.
+			if res = s.fallback(sr, policy, configClusterSecrets); res != nil {
+				results = append(results, res)
+			}
+			continue
 		}
+		if policy == model.MissingCredentialKeepLastGood {
+			s.lastGoodMu.Lock()
+			s.lastGood[sr] = res
+			s.lastGoodMu.Unlock()
+		}
+		s.cache.Add(sr, req, res)
+		results = append(results, res)
 	}
 	return results, model.XdsLogDetails{AdditionalInfo: fmt.Sprintf("cached:%v/%v", cached, cached+regenerated)}, nil
 }
@@ -189,6 +202,120 @@ func (s *SecretGen) generate(sr SecretResource, configClusterSecrets, proxyClust
 	return res
 }
 
+// missingCredentialPolicy returns the policy applied when the credential of a resource requested by a gateway is missing.
+func missingCredentialPolicy(proxy *model.Proxy, sr SecretResource) model.MissingCredentialPolicy {
+	if proxy.MergedGateway == nil {
+		return model.MissingCredentialNone
+	}
+	// The CA certificate of a credential shares its policy.
+	if policy, f := proxy.MergedGateway.MissingCredentialPolicies[strings.TrimSuffix(sr.ResourceName, securitymodel.SdsCaSuffix)]; f {
+		return policy
+	}
+	return model.MissingCredentialNone
+}
+
+// fallback returns the resource served in place of a missing or invalid credential, according to the policy.
+func (s *SecretGen) fallback(sr SecretResource, policy model.MissingCredentialPolicy, configClusterSecrets credscontroller.Controller) *discovery.Resource {
+	var res *discovery.Resource
+	switch policy {
+	case model.MissingCredentialKeepLastGood:
+		s.lastGoodMu.RLock()
+		res = s.lastGood[sr]
+		s.lastGoodMu.RUnlock()
+	case model.MissingCredentialDefaultCertificate:
+		res = defaultCertificate(sr, configClusterSecrets)
+	}
+	if res != nil {
+		pilotSDSCertificateFallbacks.With(typeTag.Value(string(policy))).Increment()
+		log.Infof("serving %s certificate in place of missing credential %s", policy, sr.ResourceName)
+	}
+	return res
+}
+
+// evictLastGood drops the last good resources of the secrets no longer referenced by a gateway with the
+// KEEP_LAST_GOOD missing credential policy, once per push.
+func (s *SecretGen) evictLastGood(push *model.PushContext) {
+	s.lastGoodMu.Lock()
+	defer s.lastGoodMu.Unlock()
+	if push.PushVersion == s.lastGoodPushVersion {
+		return
+	}
+	s.lastGoodPushVersion = push.PushVersion
+	for sr := range s.lastGood {
+		if !push.GatewayKeepsLastGoodCredential(strings.TrimSuffix(sr.Name, securitymodel.SdsCaSuffix)) {
+			delete(s.lastGood, sr)
+		}
+	}
+}
+
+// defaultCertificate returns the PILOT_GATEWAY_DEFAULT_CREDENTIAL certificate, served under the name of the resource.
+// There is no default CA certificate.
+func defaultCertificate(sr SecretResource, configClusterSecrets credscontroller.Controller) *discovery.Resource {
+	if features.GatewayDefaultCredential == "" || strings.HasSuffix(sr.Name, securitymodel.SdsCaSuffix) {
+		return nil
+	}
+	parts := strings.Split(features.GatewayDefaultCredential, "/")
+	if len(parts) != 2 {
+		log.Warnf("invalid PILOT_GATEWAY_DEFAULT_CREDENTIAL %q, expected namespace/name", features.GatewayDefaultCredential)
+		return nil
+	}
+	key, cert, err := configClusterSecrets.GetKeyAndCert(parts[1], parts[0])
+	if err != nil {
+		log.Warnf("failed to fetch default key and certificate %s: %v", features.GatewayDefaultCredential, err)
+		return nil
+	}
+	return toEnvoyKeyCertSecret(sr.ResourceName, key, cert)
+}
+
+// CredentialExists implements model.GatewayCredentials.
+func (s *SecretGen) CredentialExists(proxy *model.Proxy, resourceName string) bool {
+	var clusterID cluster.ID
+	if proxy.Metadata != nil {
+		clusterID = proxy.Metadata.ClusterID
+	}
+	sr, err := credentials.ParseResourceName(resourceName, proxy.VerifiedIdentity.Namespace, clusterID, s.configCluster)
+	if err != nil {
+		return false
+	}
+	secrets, err := s.secrets.ForCluster(sr.Cluster)
+	if err != nil {
+		return false
+	}
+	_, _, err = secrets.GetKeyAndCert(sr.Name, sr.Namespace)
+	return err == nil
+}
+
+// ConfigCredentialExists implements model.GatewayCredentials.
+func (s *SecretGen) ConfigCredentialExists(namespace, credentialName string) bool {
+	sr, err := credentials.ParseResourceName(credentials.ToResourceName(credentialName), namespace, s.configCluster, s.configCluster)
+	if err != nil {
+		return false
+	}
+	secrets, err := s.secrets.ForCluster(sr.Cluster)
+	if err != nil {
+		return false
+	}
+	_, _, err = secrets.GetKeyAndCert(sr.Name, sr.Namespace)
+	return err == nil
+}
+
+// SecretUpdated pushes the change of a secret to the gateways. The servers of the gateways rejecting missing credentials
+// are added or removed with the secret, which requires a full push.
+func (s *DiscoveryServer) SecretUpdated(name, namespace string) {
+	configsUpdated := map[model.ConfigKey]struct{}{
+		{Kind: gvk.Secret, Name: name, Namespace: namespace}: {},
+	}
+	gateways := s.globalPushContext().GatewaysRejectingMissingCredential(name)
+	for _, gw := range gateways {
+		configsUpdated[gw] = struct{}{}
+	}
+	s.ConfigUpdate(&model.PushRequest{
+		Full:           len(gateways) > 0,
+		ConfigsUpdated: configsUpdated,
+		Reason:         []model.TriggerReason{model.SecretTrigger},
+	})
+}
+
 func validateCertificate(data []byte) error {
 	block, _ := pem.Decode(data)
 	if block == nil {
@@ -361,9 +488,18 @@ type SecretGen struct {
 	// Cache for XDS resources
 	cache         model.XdsCache
 	configCluster cluster.ID
+
+	// lastGood holds the last resources generated for the credentials with the KEEP_LAST_GOOD missing credential policy.
+	lastGood   map[SecretResource]*discovery.Resource
+	lastGoodMu sync.RWMutex
+	// lastGoodPushVersion is the version of the push whose gateways were used to evict the lastGood resources.
+	lastGoodPushVersion string
 }
 
-var _ model.XdsResourceGenerator = &SecretGen{}
+var (
+	_ model.XdsResourceGenerator = &SecretGen{}
+	_ model.GatewayCredentials   = &SecretGen{}
+)
 
 func NewSecretGen(sc credscontroller.MulticlusterController, cache model.XdsCache, configCluster cluster.ID) *SecretGen {
 	// TODO: Currently we only have a single credentials controller (Kubernetes). In the future, we will need a mapping
@@ -372,5 +508,6 @@ func NewSecretGen(sc credscontroller.MulticlusterController, cache model.XdsCach
 		secrets:       sc,
 		cache:         cache,
 		configCluster: configCluster,
+		lastGood:      map[SecretResource]*discovery.Resource{},
 	}
 }
@@ -15,6 +15,7 @@
 package xds
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -31,6 +32,7 @@ import (
 	k8stesting "k8s.io/client-go/testing"
 
 	credentials "istio.io/istio/pilot/pkg/credentials/kube"
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pilot/test/xdstest"
@@ -38,6 +40,7 @@ import (
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/util/retry"
 )
 
 func makeSecret(name string, data map[string]string) *corev1.Secret {
@@ -344,6 +347,131 @@ func TestCaching(t *testing.T) {
 	}
 }
 
+func TestMissingCredentialPolicy(t *testing.T) {
+	original := features.GatewayDefaultCredential
+	features.GatewayDefaultCredential = "istio-system/generic-mtls"
+	t.Cleanup(func() {
+		features.GatewayDefaultCredential = original
+	})
+	s := NewFakeDiscoveryServer(t, FakeOptions{
+		KubernetesObjects: []runtime.Object{genericCert, genericMtlsCert},
+		KubeClientModifier: func(c kube.Client) {
+			cc := c.Kube().(*fake.Clientset)
+			credentials.DisableAuthorizationForTest(cc)
+		},
+		ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: reject
+  namespace: istio-system
+  annotations:
+    networking.istio.io/missing-credential-policy: REJECT_SERVER
+spec:
+  servers:
+  - port:
+      number: 443
+      name: https
+      protocol: HTTPS
+    hosts:
+    - "*.example.com"
+    tls:
+      mode: SIMPLE
+      credentialName: generic
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: keep
+  namespace: istio-system
+  annotations:
+    networking.istio.io/missing-credential-policy: KEEP_LAST_GOOD
+spec:
+  servers:
+  - port:
+      number: 443
+      name: https
+      protocol: HTTPS
+    hosts:
+    - "*.example.org"
+    tls:
+      mode: SIMPLE
+      credentialName: generic
+`,
+	})
+	gen := s.Discovery.Generators[v3.SecretType].(*SecretGen)
+	// Changes of the secret add or remove the server of the gateway.
+	want := []model.ConfigKey{{Kind: gvk.Gateway, Name: "reject", Namespace: "istio-system"}}
+	if got := s.PushContext().GatewaysRejectingMissingCredential("generic"); !cmp.Equal(got, want) {
+		t.Fatalf("expected gateways %v, got %v", want, got)
+	}
+	proxy := s.SetupProxy(&model.Proxy{
+		Metadata:         &model.NodeMetadata{ClusterID: "Kubernetes"},
+		VerifiedIdentity: &spiffe.Identity{Namespace: "istio-system"},
+		Type:             model.Router,
+		ConfigNamespace:  "istio-system",
+	})
+	proxy.MergedGateway = &model.MergedGateway{
+		MissingCredentialPolicies: map[string]model.MissingCredentialPolicy{
+			"kubernetes://generic": model.MissingCredentialKeepLastGood,
+			"kubernetes://missing": model.MissingCredentialDefaultCertificate,
+		},
+	}
+	generate := func() map[string]string {
+		secrets, _, _ := gen.Generate(proxy, s.PushContext(),
+			&model.WatchedResource{ResourceNames: []string{"kubernetes://generic", "kubernetes://missing", "kubernetes://other"}},
+			&model.PushRequest{Full: true, Start: time.Now()})
+		got := map[string]string{}
+		for _, scrt := range xdstest.ExtractTLSSecrets(t, model.ResourcesToAny(secrets)) {
+			got[scrt.Name] = string(scrt.GetTlsCertificate().GetCertificateChain().GetInlineBytes())
+		}
+		return got
+	}
+
+	certs := map[string]string{
+		"kubernetes://generic": string(genericCert.Data[credentials.GenericScrtCert]),
+		"kubernetes://missing": string(genericMtlsCert.Data[credentials.GenericScrtCert]),
+	}
+	if diff := cmp.Diff(generate(), certs); diff != "" {
+		t.Fatal(diff)
+	}
+	if !gen.CredentialExists(proxy, "kubernetes://generic") || gen.CredentialExists(proxy, "kubernetes://missing") {
+		t.Fatal("expected only the generic credential to exist")
+	}
+	if !gen.ConfigCredentialExists("istio-system", "generic") || gen.ConfigCredentialExists("istio-system", "missing") {
+		t.Fatal("expected only the generic credential to exist in the config cluster")
+	}
+
+	// The last certificate read from the deleted secret is still served.
+	if err := s.KubeClient().Kube().CoreV1().Secrets("istio-system").Delete(context.TODO(), "generic", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		if gen.CredentialExists(proxy, "kubernetes://generic") {
+			return errors.New("secret not deleted yet")
+		}
+		return nil
+	}, retry.Timeout(time.Second*5))
+	s.Discovery.Cache.ClearAll()
+	if diff := cmp.Diff(generate(), certs); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// The last good certificate is dropped once no gateway keeps it.
+	push := model.NewPushContext()
+	push.PushVersion = "without-gateways"
+	_, _, _ = gen.Generate(proxy, push, &model.WatchedResource{}, &model.PushRequest{Full: true, Start: time.Now()})
+	if diff := cmp.Diff(generate(), map[string]string{"kubernetes://missing": certs["kubernetes://missing"]}); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// Without policy, missing credentials are not served.
+	proxy.MergedGateway = nil
+	if diff := cmp.Diff(generate(), map[string]string{}); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
 func TestAtMostNJoin(t *testing.T) {
 	tests := []struct {
 		data  []string
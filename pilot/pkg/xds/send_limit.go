@@ -0,0 +1,103 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package xds
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/pilot/pkg/features"
+)
+
+// newSendLimiter returns the limiter of the bytes per second sent to a connection, according to
+// PILOT_XDS_SEND_RATE_LIMIT, or nil if the responses are not limited.
+func newSendLimiter() *rate.Limiter {
+	return sendLimiter(features.XdsSendRateLimit, features.XdsSendRateBurst)
+}
+
+// newGlobalSendLimiter returns the limiter of the bytes per second sent to all connections, according to
+// PILOT_XDS_GLOBAL_SEND_RATE_LIMIT, or nil if the responses are not limited.
+func newGlobalSendLimiter() *rate.Limiter {
+	return sendLimiter(features.XdsGlobalSendRateLimit, 0)
+}
+
+func sendLimiter(limit, burst int) *rate.Limiter {
+	if limit <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = limit
+	}
+	return rate.NewLimiter(rate.Limit(limit), burst)
+}
+
+// sendContext returns the context bounding both the wait for the send rate limit and the send of a response,
+// according to PILOT_XDS_SEND_TIMEOUT.
+func sendContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if features.XdsPushSendTimeout > 0 {
+		return context.WithTimeout(ctx, features.XdsPushSendTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// waitToSend blocks until a response of the given size can be sent without exceeding the send rate limit of the
+// connection, and then the optional one of istiod shared by all the connections.
+func (conn *Connection) waitToSend(ctx context.Context, typeURL string, size int) error {
+	if (conn.sendLimiter == nil && conn.globalSendLimiter == nil) || size <= 0 {
+		return nil
+	}
+	start := time.Now()
+	for _, limiter := range []*rate.Limiter{conn.sendLimiter, conn.globalSendLimiter} {
+		if limiter == nil {
+			continue
+		}
+		if err := conn.waitForLimiter(ctx, limiter, typeURL, size); err != nil {
+			return err
+		}
+	}
+	recordSendThrottleTime(typeURL, time.Since(start))
+	return nil
+}
+
+// waitForLimiter blocks until limiter allows size bytes. Responses larger than the burst wait for the limiter in
+// chunks of the burst size. A push which has to wait gives up its slot in the push throttle first, as it is
+// throttled by the rate limit instead, so that the pushes waiting for the limit do not block the other pushes.
+func (conn *Connection) waitForLimiter(ctx context.Context, limiter *rate.Limiter, typeURL string, size int) error {
+	burst := limiter.Burst()
+	for size > 0 {
+		n := size
+		if n > burst {
+			n = burst
+		}
+		size -= n
+		if limiter.AllowN(time.Now(), n) {
+			continue
+		}
+		if conn.releasePushSlot != nil {
+			conn.releasePushSlot()
+		}
+		if err := limiter.WaitN(ctx, n); err != nil {
+			if ctx.Err() == context.Canceled {
+				return err
+			}
+			// The limiter fails early if the wait would exceed the deadline.
+			return status.Errorf(codes.DeadlineExceeded, "timeout waiting to send %s: %v", typeURL, err)
+		}
+	}
+	return nil
+}
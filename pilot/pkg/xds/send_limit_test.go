@@ -0,0 +1,105 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package xds
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func TestWaitToSend(t *testing.T) {
+	if sendLimiter(0, 100) != nil {
+		t.Fatalf("expected no limiter without a limit")
+	}
+	if l := sendLimiter(1000, 0); l.Burst() != 1000 {
+		t.Fatalf("expected the burst to default to the limit, got %d", l.Burst())
+	}
+
+	// Unlimited connections never wait.
+	unlimited := &Connection{}
+	if err := unlimited.waitToSend(context.Background(), v3.ClusterType, 1<<30); err != nil {
+		t.Fatal(err)
+	}
+
+	con := &Connection{sendLimiter: sendLimiter(1000, 100)}
+	start := time.Now()
+	if err := con.waitToSend(context.Background(), v3.ClusterType, 100); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("expected a response within the burst to be sent immediately, waited %v", elapsed)
+	}
+	// A response larger than the burst is throttled in chunks.
+	start = time.Now()
+	if err := con.waitToSend(context.Background(), v3.ClusterType, 250); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("expected a response of 250 bytes to wait for 250ms at 1000 bytes per second, waited %v", elapsed)
+	}
+
+	// A push which has to wait gives up its slot in the push throttle.
+	released := false
+	con.releasePushSlot = func() { released = true }
+	if err := con.waitToSend(context.Background(), v3.ClusterType, 100); err != nil {
+		t.Fatal(err)
+	}
+	if !released {
+		t.Fatalf("expected the push slot to be released while waiting")
+	}
+	con.releasePushSlot = nil
+
+	// Waiting is bounded by the deadline of the send.
+	deadline, cancelDeadline := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelDeadline()
+	if err := con.waitToSend(deadline, v3.ClusterType, 1000); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected the wait to exceed the deadline, got %v", err)
+	}
+
+	// The connections have their own limiters, and the optional global limiter is shared by all of them.
+	global := sendLimiter(1000, 0)
+	first := &Connection{sendLimiter: sendLimiter(1000, 1000), globalSendLimiter: global}
+	second := &Connection{sendLimiter: sendLimiter(1000, 1000), globalSendLimiter: global}
+	if err := first.waitToSend(context.Background(), v3.ClusterType, 1000); err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	if err := second.waitToSend(context.Background(), v3.ClusterType, 200); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("expected the second connection to wait for the global limit, waited %v", elapsed)
+	}
+	unshared := &Connection{sendLimiter: sendLimiter(1000, 1000)}
+	start = time.Now()
+	if err := unshared.waitToSend(context.Background(), v3.ClusterType, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("expected a connection without the global limit not to wait for the others, waited %v", elapsed)
+	}
+
+	// Waiting ends with the stream.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := con.waitToSend(ctx, v3.ClusterType, 1000); err == nil {
+		t.Fatalf("expected an error once the stream is closed")
+	}
+}
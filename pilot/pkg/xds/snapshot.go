@@ -0,0 +1,153 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+// ConnectionSnapshot is a serialized snapshot of the state of a single xDS connection. It is intended
+// to be attached to support bundles, and later replayed against a local istiod to reproduce push issues
+// seen in production.
+type ConnectionSnapshot struct {
+	ConnectionID string    `json:"connectionId"`
+	ConnectedAt  time.Time `json:"connectedAt"`
+	PeerAddress  string    `json:"address"`
+	// Delta is true if the connection uses the delta xDS protocol.
+	Delta bool `json:"delta"`
+
+	// Node is the original node sent by the client. Replaying a snapshot uses this node as-is.
+	Node *SnapshotNode `json:"node,omitempty"`
+
+	ProxyID         string              `json:"proxyId"`
+	ProxyType       model.NodeType      `json:"proxyType"`
+	IPAddresses     []string            `json:"ipAddresses,omitempty"`
+	ConfigNamespace string              `json:"configNamespace,omitempty"`
+	DNSDomain       string              `json:"dnsDomain,omitempty"`
+	Metadata        *model.NodeMetadata `json:"metadata,omitempty"`
+
+	// WatchedResources, keyed by type URL, including the last nonces sent, ACKed and NACKed.
+	WatchedResources map[string]*model.WatchedResource `json:"watchedResources,omitempty"`
+	// BlockedPushes lists the type URLs with a push waiting for an ACK of the previous response.
+	BlockedPushes []string `json:"blockedPushes,omitempty"`
+
+	SidecarScope *SidecarScopeSummary `json:"sidecarScope,omitempty"`
+}
+
+// SidecarScopeSummary is a summary of the SidecarScope computed for a proxy. The full scope is not
+// included, as it can be reconstructed from the config at replay time.
+type SidecarScopeSummary struct {
+	Name      string   `json:"name,omitempty"`
+	Namespace string   `json:"namespace,omitempty"`
+	Version   string   `json:"version,omitempty"`
+	Services  []string `json:"services,omitempty"`
+}
+
+// SnapshotNode wraps core.Node so it can be round tripped through encoding/json.
+type SnapshotNode struct {
+	*core.Node
+}
+
+func (n *SnapshotNode) MarshalJSON() ([]byte, error) {
+	return protomarshal.Marshal(n.Node)
+}
+
+func (n *SnapshotNode) UnmarshalJSON(b []byte) error {
+	n.Node = &core.Node{}
+	return protomarshal.UnmarshalAllowUnknown(b, n.Node)
+}
+
+// connectionSnapshot builds a ConnectionSnapshot for the given connection.
+func connectionSnapshot(con *Connection) *ConnectionSnapshot {
+	con.proxy.RLock()
+	defer con.proxy.RUnlock()
+	snap := &ConnectionSnapshot{
+		ConnectionID:     con.ConID,
+		ConnectedAt:      con.Connect,
+		PeerAddress:      con.PeerAddr,
+		Delta:            con.deltaStream != nil,
+		ProxyID:          con.proxy.ID,
+		ProxyType:        con.proxy.Type,
+		IPAddresses:      con.proxy.IPAddresses,
+		ConfigNamespace:  con.proxy.ConfigNamespace,
+		DNSDomain:        con.proxy.DNSDomain,
+		Metadata:         con.proxy.Metadata,
+		WatchedResources: make(map[string]*model.WatchedResource, len(con.proxy.WatchedResources)),
+	}
+	if con.node != nil {
+		snap.Node = &SnapshotNode{Node: con.node}
+	}
+	for typeURL, w := range con.proxy.WatchedResources {
+		// Copy, as the WatchedResource may be mutated after we release the lock
+		wr := *w
+		snap.WatchedResources[typeURL] = &wr
+	}
+	for typeURL := range con.blockedPushes {
+		snap.BlockedPushes = append(snap.BlockedPushes, typeURL)
+	}
+	if sc := con.proxy.SidecarScope; sc != nil {
+		summary := &SidecarScopeSummary{
+			Name:      sc.Name,
+			Namespace: sc.Namespace,
+			Version:   sc.Version,
+		}
+		for _, svc := range sc.Services() {
+			summary.Services = append(summary.Services, string(svc.Hostname))
+		}
+		snap.SidecarScope = summary
+	}
+	return snap
+}
+
+// connectionSnapshotz exports a ConnectionSnapshot for the requested proxy.
+// It is mapped to /debug/connection_snapshot
+func (s *DiscoveryServer) connectionSnapshotz(w http.ResponseWriter, req *http.Request) {
+	proxyID, con := s.getDebugConnection(req)
+	if con == nil {
+		s.errorHandler(w, proxyID, con)
+		return
+	}
+	writeJSON(w, connectionSnapshot(con))
+}
+
+// LoadConnectionSnapshot reads a ConnectionSnapshot, as exported by /debug/connection_snapshot, from a file.
+func LoadConnectionSnapshot(path string) (*ConnectionSnapshot, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConnectionSnapshot(b)
+}
+
+// ParseConnectionSnapshot parses a ConnectionSnapshot, as exported by /debug/connection_snapshot.
+func ParseConnectionSnapshot(b []byte) (*ConnectionSnapshot, error) {
+	snap := &ConnectionSnapshot{}
+	if err := json.Unmarshal(b, snap); err != nil {
+		return nil, fmt.Errorf("failed to parse connection snapshot: %v", err)
+	}
+	if snap.Node == nil || snap.Node.Node == nil || snap.Node.Id == "" {
+		return nil, fmt.Errorf("connection snapshot is missing node information")
+	}
+	return snap, nil
+}
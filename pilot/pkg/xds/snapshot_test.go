@@ -0,0 +1,103 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestConnectionSnapshotReplay(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	ads := s.ConnectADS()
+	cds := ads.RequestResponseAck(t, &discovery.DiscoveryRequest{TypeUrl: v3.ClusterType})
+	ads.RequestResponseAck(t, &discovery.DiscoveryRequest{TypeUrl: v3.ListenerType})
+	ads.RequestResponseAck(t, &discovery.DiscoveryRequest{
+		TypeUrl:       v3.RouteType,
+		ResourceNames: []string{"80", "8080"},
+	})
+
+	node, _ := model.ParseServiceNodeWithMetadata(ads.ID, &model.NodeMetadata{})
+	var snap *ConnectionSnapshot
+	// The final ACK is processed asynchronously, so retry until the snapshot reflects it
+	retry.UntilSuccessOrFail(t, func() error {
+		req, err := http.NewRequest("GET", "/debug/connection_snapshot?proxyID="+node.ID, nil)
+		if err != nil {
+			return err
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(s.Discovery.connectionSnapshotz).ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			return fmt.Errorf("unexpected status %d: %s", rr.Code, rr.Body.String())
+		}
+		snap, err = ParseConnectionSnapshot(rr.Body.Bytes())
+		if err != nil {
+			return err
+		}
+		for _, typeURL := range []string{v3.ClusterType, v3.ListenerType, v3.RouteType} {
+			w := snap.WatchedResources[typeURL]
+			if w == nil {
+				return fmt.Errorf("expected %v to be watched", typeURL)
+			}
+			if w.NonceSent == "" || w.NonceSent != w.NonceAcked {
+				return fmt.Errorf("expected %v to be acked, got sent %q acked %q", typeURL, w.NonceSent, w.NonceAcked)
+			}
+		}
+		return nil
+	}, retry.Timeout(time.Second*5))
+
+	if snap.ProxyID != node.ID {
+		t.Fatalf("expected proxy %v, got %v", node.ID, snap.ProxyID)
+	}
+	if snap.Node.Id != ads.ID {
+		t.Fatalf("expected node %v, got %v", ads.ID, snap.Node.Id)
+	}
+	if snap.SidecarScope == nil || snap.SidecarScope.Namespace != "default" {
+		t.Fatalf("expected sidecar scope summary, got %v", snap.SidecarScope)
+	}
+
+	responses := s.ReplayConnectionSnapshot(snap)
+	if len(responses) != 3 {
+		t.Fatalf("expected 3 responses, got %d", len(responses))
+	}
+	if got, want := len(responses[v3.ClusterType].Resources), len(cds.Resources); got != want {
+		t.Fatalf("expected %d clusters on replay, got %d", want, got)
+	}
+}
+
+func TestConnectionSnapshotNotConnected(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	req, err := http.NewRequest("GET", "/debug/connection_snapshot?proxyID=missing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(s.Discovery.connectionSnapshotz).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+	if _, err := ParseConnectionSnapshot([]byte(`{"proxyId": "foo"}`)); err == nil {
+		t.Fatalf("expected error parsing snapshot without node")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"net/http"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+// TLSPolicyConflict is a resource with TLS settings that conflict with the TLS policy. These settings are
+// overridden by the policy when generating configuration.
+type TLSPolicyConflict struct {
+	Kind      string   `json:"kind"`
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Location  string   `json:"location"`
+	Reasons   []string `json:"reasons"`
+}
+
+// TLSPolicyReport lists the resources that conflict with the TLS policy.
+type TLSPolicyReport struct {
+	MinProtocolVersion string              `json:"minProtocolVersion,omitempty"`
+	CipherSuites       []string            `json:"cipherSuites,omitempty"`
+	Conflicts          []TLSPolicyConflict `json:"conflicts"`
+}
+
+// tlsPolicyReport checks the Gateways and Sidecars against policy.
+func (s *DiscoveryServer) tlsPolicyReport(policy util.TLSPolicy) *TLSPolicyReport {
+	report := &TLSPolicyReport{
+		CipherSuites: policy.CipherSuites,
+		Conflicts:    []TLSPolicyConflict{},
+	}
+	if !policy.IsEmpty() {
+		report.MinProtocolVersion = policy.MinProtocolVersion.String()
+	}
+	gateways, _ := s.Env.IstioConfigStore.List(gvk.Gateway, "")
+	for _, cfg := range gateways {
+		for i, server := range cfg.Spec.(*networking.Gateway).Servers {
+			if reasons := policy.ServerConflicts(server.GetTls()); len(reasons) > 0 {
+				report.Conflicts = append(report.Conflicts, TLSPolicyConflict{
+					Kind:      gvk.Gateway.Kind,
+					Name:      cfg.Name,
+					Namespace: cfg.Namespace,
+					Location:  fmt.Sprintf("servers[%d]", i),
+					Reasons:   reasons,
+				})
+			}
+		}
+	}
+	sidecars, _ := s.Env.IstioConfigStore.List(gvk.Sidecar, "")
+	for _, cfg := range sidecars {
+		for i, ingress := range cfg.Spec.(*networking.Sidecar).Ingress {
+			if reasons := policy.ServerConflicts(ingress.GetTls()); len(reasons) > 0 {
+				report.Conflicts = append(report.Conflicts, TLSPolicyConflict{
+					Kind:      gvk.Sidecar.Kind,
+					Name:      cfg.Name,
+					Namespace: cfg.Namespace,
+					Location:  fmt.Sprintf("ingress[%d]", i),
+					Reasons:   reasons,
+				})
+			}
+		}
+	}
+	return report
+}
+
+// tlsPolicyz reports the resources that conflict with the mesh wide TLS policy, or with the policy of
+// a proxy if proxyID is set.
+// It is mapped to /debug/tls_policy
+func (s *DiscoveryServer) tlsPolicyz(w http.ResponseWriter, req *http.Request) {
+	policy := util.MeshTLSPolicy()
+	if req.URL.Query().Get("proxyID") != "" {
+		proxyID, con := s.getDebugConnection(req)
+		if con == nil {
+			s.errorHandler(w, proxyID, con)
+			return
+		}
+		policy = util.TLSPolicyForProxy(con.proxy)
+	}
+	writeJSON(w, s.tlsPolicyReport(policy))
+}
@@ -0,0 +1,75 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/features"
+)
+
+const tlsPolicyGateway = `
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: legacy
+  namespace: default
+spec:
+  servers:
+  - port:
+      number: 443
+      name: https
+      protocol: HTTPS
+    hosts: ["*"]
+    tls:
+      mode: SIMPLE
+      credentialName: cert
+      minProtocolVersion: TLSV1_0
+  - port:
+      number: 8443
+      name: https-strict
+      protocol: HTTPS
+    hosts: ["*"]
+    tls:
+      mode: SIMPLE
+      credentialName: cert
+      minProtocolVersion: TLSV1_3
+`
+
+func TestTLSPolicyz(t *testing.T) {
+	defer func(v string) { features.TLSMinProtocolVersion = v }(features.TLSMinProtocolVersion)
+	features.TLSMinProtocolVersion = "TLSV1_2"
+
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: tlsPolicyGateway})
+	req, err := http.NewRequest("GET", "/debug/tls_policy", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(s.Discovery.tlsPolicyz).ServeHTTP(rr, req)
+	report := &TLSPolicyReport{}
+	if err := json.Unmarshal(rr.Body.Bytes(), report); err != nil {
+		t.Fatalf("invalid json %v: %s", err, rr.Body.String())
+	}
+	if report.MinProtocolVersion != "TLSv1_2" {
+		t.Fatalf("expected policy minimum TLSv1_2, got %q", report.MinProtocolVersion)
+	}
+	if len(report.Conflicts) != 1 || report.Conflicts[0].Name != "legacy" || report.Conflicts[0].Location != "servers[0]" {
+		t.Fatalf("expected a single conflict on servers[0], got %+v", report.Conflicts)
+	}
+}
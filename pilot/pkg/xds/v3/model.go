@@ -30,6 +30,7 @@ const (
 	RouteType                  = resource.RouteType
 	SecretType                 = resource.SecretType
 	ExtensionConfigurationType = resource.ExtensionConfigType
+	VirtualHostType            = apiTypePrefix + "envoy.config.route.v3.VirtualHost"
 
 	NameTableType   = apiTypePrefix + "istio.networking.nds.v1.NameTable"
 	HealthInfoType  = apiTypePrefix + "istio.v1.HealthInformation"
@@ -61,6 +62,8 @@ func GetShortType(typeURL string) string {
 		return "PCDS"
 	case ExtensionConfigurationType:
 		return "ECDS"
+	case VirtualHostType:
+		return "VHDS"
 	default:
 		return typeURL
 	}
@@ -85,6 +88,8 @@ func GetMetricType(typeURL string) string {
 		return "pcds"
 	case ExtensionConfigurationType:
 		return "ecds"
+	case VirtualHostType:
+		return "vhds"
 	case BootstrapType:
 		return "bds"
 	default:
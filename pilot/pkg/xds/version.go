@@ -0,0 +1,50 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"strconv"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+const (
+	// PushVersionScheme versions the responses with the version of the push which generated them.
+	PushVersionScheme = "push"
+	// MonotonicVersionScheme versions the responses with a counter per type, incremented for each response to the
+	// connection.
+	MonotonicVersionScheme = "monotonic"
+)
+
+// isMonotonicVersionScheme returns whether the scheme is MonotonicVersionScheme, warning about unknown schemes.
+func isMonotonicVersionScheme(scheme string) bool {
+	if scheme != PushVersionScheme && scheme != MonotonicVersionScheme {
+		log.Warnf("unknown xDS version scheme %q, using %q", scheme, PushVersionScheme)
+	}
+	return scheme == MonotonicVersionScheme
+}
+
+// responseVersion returns the version of the next response of the type to the connection. The responses to a
+// connection are sent from its stream goroutine, so its counters are not locked.
+func (s *DiscoveryServer) responseVersion(con *Connection, typeURL string, push *model.PushContext) string {
+	if !s.monotonicVersions {
+		return push.PushVersion
+	}
+	if con.versions == nil {
+		con.versions = map[string]uint64{}
+	}
+	con.versions[typeURL]++
+	return strconv.FormatUint(con.versions[typeURL], 10)
+}
@@ -0,0 +1,67 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func TestResponseVersion(t *testing.T) {
+	push := &model.PushContext{PushVersion: "push-1"}
+	t.Run("push", func(t *testing.T) {
+		s := &DiscoveryServer{monotonicVersions: isMonotonicVersionScheme(PushVersionScheme)}
+		con := &Connection{}
+		if version := s.responseVersion(con, v3.ClusterType, push); version != "push-1" {
+			t.Fatalf("expected the push version, got %q", version)
+		}
+		if con.versions != nil {
+			t.Fatalf("expected no versions to be tracked, got %v", con.versions)
+		}
+	})
+	t.Run("monotonic", func(t *testing.T) {
+		s := &DiscoveryServer{monotonicVersions: isMonotonicVersionScheme(MonotonicVersionScheme)}
+		con1, con2 := &Connection{}, &Connection{}
+		for i, c := range []struct {
+			con     *Connection
+			typeURL string
+			want    string
+		}{
+			{con1, v3.ClusterType, "1"},
+			{con1, v3.ClusterType, "2"},
+			{con1, v3.ListenerType, "1"},
+			{con2, v3.ClusterType, "1"},
+		} {
+			if version := s.responseVersion(c.con, c.typeURL, push); version != c.want {
+				t.Fatalf("response %d: expected version %s, got %s", i, c.want, version)
+			}
+		}
+	})
+}
+
+func TestMonotonicVersions(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{
+		DiscoveryServerModifier: func(s *DiscoveryServer) {
+			s.monotonicVersions = true
+		},
+	})
+	first := s.ConnectADS().WithType(v3.ClusterType).RequestResponseAck(t, nil)
+	second := s.ConnectADS().WithType(v3.ClusterType).RequestResponseAck(t, nil)
+	if first.VersionInfo != "1" || second.VersionInfo != "1" {
+		t.Fatalf("expected versions per connection, got %q and %q", first.VersionInfo, second.VersionInfo)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package xds
+
+import (
+	"fmt"
+	"strings"
+
+	"istio.io/istio/pilot/pkg/events"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// proxyVersionRange is the range of the minor versions of the proxies supported by istiod. Nil bounds are unbounded.
+type proxyVersionRange struct {
+	min, max *model.IstioVersion
+}
+
+// newProxyVersionRange returns the range of proxy versions between min and max, such as 1.11 and 1.13. Invalid
+// bounds are ignored.
+func newProxyVersionRange(min, max string) proxyVersionRange {
+	return proxyVersionRange{min: parseMinorVersion(min), max: parseMinorVersion(max)}
+}
+
+// parseMinorVersion parses the minor version of v, ignoring the patch, or returns nil if it is not a version.
+func parseMinorVersion(v string) *model.IstioVersion {
+	if v == "" {
+		return nil
+	}
+	parsed := model.ParseIstioVersion(v)
+	if parsed == model.MaxIstioVersion {
+		log.Errorf("ADS: ignoring invalid supported proxy version %q", v)
+		return nil
+	}
+	// Compare only on major and minor.
+	return &model.IstioVersion{Major: parsed.Major, Minor: parsed.Minor, Patch: -1}
+}
+
+// compare returns -1 if v is older than the range, 1 if it is newer, and 0 if it is within the range.
+func (r proxyVersionRange) compare(v *model.IstioVersion) int {
+	if r.min != nil && v.Compare(r.min) < 0 {
+		return -1
+	}
+	if r.max != nil && v.Compare(r.max) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func (r proxyVersionRange) String() string {
+	var bounds []string
+	if r.min != nil {
+		bounds = append(bounds, fmt.Sprintf(">= %d.%d", r.min.Major, r.min.Minor))
+	}
+	if r.max != nil {
+		bounds = append(bounds, fmt.Sprintf("<= %d.%d", r.max.Major, r.max.Minor))
+	}
+	return strings.Join(bounds, ", ")
+}
+
+// checkProxyVersion applies PILOT_PROXY_VERSION_SKEW_POLICY to the proxy of a connection outside of the supported
+// versions. Proxies not reporting a version are always accepted. An error is only returned in reject mode.
+func (s *DiscoveryServer) checkProxyVersion(con *Connection) error {
+	proxy := con.proxy
+	if proxy.Metadata.IstioVersion == "" || proxy.IstioVersion == nil {
+		return nil
+	}
+	skew := s.proxyVersions.compare(proxy.IstioVersion)
+	if skew == 0 {
+		return nil
+	}
+	policy := features.ProxyVersionSkewPolicy
+	version := fmt.Sprintf("%d.%d", proxy.IstioVersion.Major, proxy.IstioVersion.Minor)
+	proxyVersionSkew.With(guardedValue(proxyVersionSkew, proxyVersionTag, version), policyTag.Value(policy)).Increment()
+	msg := fmt.Sprintf("proxy %s version %s is outside of the versions supported by %s (%v)",
+		proxy.ID, proxy.Metadata.IstioVersion, s.instanceID, s.proxyVersions)
+	s.emitProxyEvent(events.ProxyVersionSkew, true, con, "%s", msg)
+	switch policy {
+	case "reject":
+		return fmt.Errorf("%s", msg)
+	case "degrade":
+		if skew > 0 {
+			// Features are gated on the proxy version, so the proxy does not get those introduced after the maximum.
+			max := s.proxyVersions.max
+			proxy.IstioVersion = &model.IstioVersion{Major: max.Major, Minor: max.Minor, Patch: 65535}
+		}
+	}
+	log.Warnf("ADS: %s", msg)
+	return nil
+}
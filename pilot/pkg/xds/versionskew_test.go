@@ -0,0 +1,114 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package xds
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/events"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestProxyVersionRange(t *testing.T) {
+	r := newProxyVersionRange("1.11", "1.13.2")
+	cases := []struct {
+		version string
+		want    int
+	}{
+		{"1.10.5", -1},
+		{"1.11.0", 0},
+		{"1.13.9", 0},
+		{"1.14.0", 1},
+		{"2.0.0", 1},
+	}
+	for _, tt := range cases {
+		if got := r.compare(model.ParseIstioVersion(tt.version)); got != tt.want {
+			t.Errorf("compare(%s): got %d, want %d", tt.version, got, tt.want)
+		}
+	}
+	if got := newProxyVersionRange("", "invalid").compare(model.ParseIstioVersion("1.2.3")); got != 0 {
+		t.Errorf("expected an unbounded range, got %d", got)
+	}
+}
+
+func TestProxyVersionSkew(t *testing.T) {
+	original := features.ProxyVersionSkewPolicy
+	t.Cleanup(func() {
+		features.ProxyVersionSkewPolicy = original
+	})
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	s.Discovery.proxyVersions = newProxyVersionRange("1.11", "1.13")
+	sink := &recordingSink{}
+	s.Discovery.Events = events.NewEmitter(100, 100, sink)
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.Discovery.Events.Run(stop)
+
+	connect := func(id, version string) *AdsTest {
+		return s.ConnectADS().WithType(v3.ClusterType).
+			WithID("sidecar~1.1.1.1~" + id + "~default.svc.cluster.local").
+			WithMetadata(model.NodeMetadata{IstioVersion: version})
+	}
+	proxyVersion := func(id string) *model.IstioVersion {
+		t.Helper()
+		for _, con := range s.Discovery.Clients() {
+			if con.proxy.ID == id {
+				return con.proxy.IstioVersion
+			}
+		}
+		t.Fatalf("proxy %s is not connected", id)
+		return nil
+	}
+
+	features.ProxyVersionSkewPolicy = "warn"
+	connect("supported.default", "1.12.0").RequestResponseAck(t, nil)
+	connect("unknown.default", "").RequestResponseAck(t, nil)
+	connect("new.default", "1.15.0").RequestResponseAck(t, nil)
+	if got := proxyVersion("new.default"); got.Minor != 15 {
+		t.Fatalf("expected the version of the proxy to be kept, got %+v", got)
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		want := []events.Type{events.ProxyConnected, events.ProxyConnected, events.ProxyVersionSkew, events.ProxyConnected}
+		if got := sink.types(); !reflect.DeepEqual(got, want) {
+			return fmt.Errorf("got events %v, want %v", got, want)
+		}
+		return nil
+	}, retry.Timeout(time.Second*5))
+
+	features.ProxyVersionSkewPolicy = "degrade"
+	connect("degraded.default", "1.15.0").RequestResponseAck(t, nil)
+	if got, want := proxyVersion("degraded.default"), (&model.IstioVersion{Major: 1, Minor: 13, Patch: 65535}); *got != *want {
+		t.Fatalf("expected the proxy to be treated as %+v, got %+v", want, got)
+	}
+	// Older proxies already get no newer features.
+	connect("old.default", "1.9.0").RequestResponseAck(t, nil)
+	if got := proxyVersion("old.default"); got.Minor != 9 {
+		t.Fatalf("expected the version of the proxy to be kept, got %+v", got)
+	}
+
+	features.ProxyVersionSkewPolicy = "reject"
+	ads := connect("rejected.default", "1.9.0")
+	ads.Request(t, nil)
+	if err := ads.ExpectError(t); err == nil || !strings.Contains(err.Error(), "outside of the versions supported") {
+		t.Fatalf("expected the connection to be rejected, got %v", err)
+	}
+	connect("accepted.default", "1.13.1").RequestResponseAck(t, nil)
+}
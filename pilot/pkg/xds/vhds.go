@@ -0,0 +1,230 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package xds
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/util/sets"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// VhdsGenerator generates the virtual hosts requested on demand by the gateways, as the requests for their hosts
+// arrive. The gateways subscribe to the name of the route configuration, then to the aliases
+// "<route configuration name>/<host>" of the hosts they need. The virtual host matching an alias is sent as the
+// resource "<route configuration name>/<virtual host name>", while an alias matching no virtual host is sent with no
+// resource, so that the gateway stops waiting for it.
+type VhdsGenerator struct {
+	Server *DiscoveryServer
+
+	mu sync.Mutex
+	// version is the push version of the cached indexes.
+	version string
+	// indexes caches the virtual host index of the routes, by proxy and route name.
+	indexes map[string]*vhostIndex
+}
+
+var _ model.XdsDeltaResourceGenerator = &VhdsGenerator{}
+
+// Generate returns no resources, VHDS is only served over delta xDS.
+func (g *VhdsGenerator) Generate(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource,
+	req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
+	return nil, model.DefaultXdsLogDetails, nil
+}
+
+// GenerateDeltas resolves the aliases of the watched resource, and removes the virtual hosts previously sent for
+// the watched aliases that no longer resolve to them.
+func (g *VhdsGenerator) GenerateDeltas(proxy *model.Proxy, push *model.PushContext, req *model.PushRequest,
+	w *model.WatchedResource) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
+	if !rdsNeedsPush(req) {
+		return nil, nil, model.DefaultXdsLogDetails, true, nil
+	}
+	// The pushes triggered by the requests of the gateway resolve the aliases it requested on demand.
+	onDemand := req != nil && req.PushReason() != ""
+	t0 := time.Now()
+
+	resolved := map[string]string{}
+	byName := map[string]*discovery.Resource{}
+	for _, alias := range w.ResourceNames {
+		i := strings.Index(alias, "/")
+		if i < 0 {
+			// The gateway subscribes to the route configuration name when it starts watching its virtual hosts.
+			continue
+		}
+		routeName, host := alias[:i], alias[i+1:]
+		vh := g.index(proxy, push, routeName).match(host)
+		if vh == nil {
+			byName[alias] = &discovery.Resource{Name: alias, Aliases: []string{alias}}
+			continue
+		}
+		name := routeName + "/" + vh.Name
+		resolved[alias] = name
+		if r, f := byName[name]; f {
+			r.Aliases = append(r.Aliases, alias)
+			continue
+		}
+		vh = proto.Clone(vh).(*route.VirtualHost)
+		vh.Name = name
+		byName[name] = &discovery.Resource{Name: name, Aliases: []string{alias}, Resource: util.MessageToAny(vh)}
+	}
+
+	removed := g.updateResolved(proxy, w.ResourceNames, resolved)
+	res := make(model.Resources, 0, len(byName))
+	for _, r := range byName {
+		res = append(res, r)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	if onDemand {
+		vhdsResolutionTime.Record(time.Since(t0).Seconds())
+	} else if len(res) == 0 && len(removed) == 0 {
+		return nil, nil, model.DefaultXdsLogDetails, true, nil
+	}
+	return res, removed, model.DefaultXdsLogDetails, true, nil
+}
+
+// updateResolved records the resources resolved for the generated aliases, and returns the resources no longer
+// resolved for any of the watched aliases.
+func (g *VhdsGenerator) updateResolved(proxy *model.Proxy, generated []string,
+	resolved map[string]string) model.DeletedResources {
+	proxy.Lock()
+	defer proxy.Unlock()
+	w := proxy.WatchedResources[v3.VirtualHostType]
+	if w == nil {
+		return nil
+	}
+	previous := sets.NewSet()
+	for _, name := range w.AliasesResolved {
+		previous.Insert(name)
+	}
+	// The aliases that are still watched but were not generated, on demand requests, keep their resolution.
+	watched := sets.NewSet(w.ResourceNames...)
+	current := make(map[string]string, len(w.AliasesResolved)+len(resolved))
+	for alias, name := range w.AliasesResolved {
+		if watched.Contains(alias) {
+			current[alias] = name
+		}
+	}
+	for _, alias := range generated {
+		delete(current, alias)
+	}
+	for alias, name := range resolved {
+		current[alias] = name
+	}
+	w.AliasesResolved = current
+	for _, name := range current {
+		previous.Delete(name)
+	}
+	return previous.SortedList()
+}
+
+// index returns the virtual host index of the route of the proxy, cached until the proxy gateways change or the
+// next push.
+func (g *VhdsGenerator) index(proxy *model.Proxy, push *model.PushContext, routeName string) *vhostIndex {
+	key := proxy.ID + "~" + routeName
+	g.mu.Lock()
+	if g.version != push.PushVersion {
+		g.version = push.PushVersion
+		g.indexes = map[string]*vhostIndex{}
+	}
+	idx := g.indexes[key]
+	g.mu.Unlock()
+	if idx != nil && idx.gateway == proxy.MergedGateway {
+		vhdsCacheHits.Increment()
+		return idx
+	}
+	vhdsCacheMisses.Increment()
+	idx = newVhostIndex(proxy.MergedGateway, g.Server.ConfigGenerator.BuildGatewayVirtualHosts(proxy, push, routeName))
+	g.mu.Lock()
+	if g.version == push.PushVersion {
+		g.indexes[key] = idx
+	}
+	g.mu.Unlock()
+	return idx
+}
+
+// vhostIndex indexes the virtual hosts of a route by their domains, to match the hosts as Envoy does: exact
+// domains first, then the longest suffix wildcards, then the longest prefix wildcards, then "*".
+type vhostIndex struct {
+	// gateway is the merged gateway the index was built for.
+	gateway *model.MergedGateway
+
+	exact  map[string]*route.VirtualHost
+	suffix []wildcardDomain
+	prefix []wildcardDomain
+	any    *route.VirtualHost
+}
+
+// wildcardDomain is a wildcard domain of a virtual host, without the "*".
+type wildcardDomain struct {
+	domain string
+	vhost  *route.VirtualHost
+}
+
+func newVhostIndex(gateway *model.MergedGateway, vhosts []*route.VirtualHost) *vhostIndex {
+	idx := &vhostIndex{gateway: gateway, exact: map[string]*route.VirtualHost{}}
+	for _, vh := range vhosts {
+		for _, d := range vh.Domains {
+			d = strings.ToLower(d)
+			switch {
+			case d == "*":
+				if idx.any == nil {
+					idx.any = vh
+				}
+			case strings.HasPrefix(d, "*"):
+				idx.suffix = append(idx.suffix, wildcardDomain{domain: d[1:], vhost: vh})
+			case strings.HasSuffix(d, "*"):
+				idx.prefix = append(idx.prefix, wildcardDomain{domain: d[:len(d)-1], vhost: vh})
+			default:
+				if _, f := idx.exact[d]; !f {
+					idx.exact[d] = vh
+				}
+			}
+		}
+	}
+	longestFirst := func(domains []wildcardDomain) func(i, j int) bool {
+		return func(i, j int) bool { return len(domains[i].domain) > len(domains[j].domain) }
+	}
+	sort.SliceStable(idx.suffix, longestFirst(idx.suffix))
+	sort.SliceStable(idx.prefix, longestFirst(idx.prefix))
+	return idx
+}
+
+// match returns the virtual host serving the host, or nil if there is none.
+func (idx *vhostIndex) match(host string) *route.VirtualHost {
+	host = strings.ToLower(host)
+	if vh, f := idx.exact[host]; f {
+		return vh
+	}
+	// The wildcards match at least one character.
+	for _, w := range idx.suffix {
+		if len(host) > len(w.domain) && strings.HasSuffix(host, w.domain) {
+			return w.vhost
+		}
+	}
+	for _, w := range idx.prefix {
+		if len(host) > len(w.domain) && strings.HasPrefix(host, w.domain) {
+			return w.vhost
+		}
+	}
+	return idx.any
+}
@@ -0,0 +1,60 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package xds
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+)
+
+func TestVhostIndexMatch(t *testing.T) {
+	vhost := func(name string, domains ...string) *route.VirtualHost {
+		return &route.VirtualHost{Name: name, Domains: domains}
+	}
+	idx := newVhostIndex(nil, []*route.VirtualHost{
+		vhost("exact", "a.example.com", "a.example.com:*"),
+		vhost("suffix", "*.example.com"),
+		vhost("longer-suffix", "*.b.example.com"),
+		vhost("prefix", "foo.*"),
+		vhost("any", "*"),
+	})
+	cases := []struct {
+		host string
+		want string
+	}{
+		{"a.example.com", "exact"},
+		{"A.Example.com", "exact"},
+		{"a.example.com:8080", "exact"},
+		{"c.example.com", "suffix"},
+		{"c.b.example.com", "longer-suffix"},
+		{"foo.org", "prefix"},
+		{"bar.org", "any"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := idx.match(tt.host); got == nil || got.Name != tt.want {
+				t.Fatalf("expected %s, got %v", tt.want, got)
+			}
+		})
+	}
+
+	idx = newVhostIndex(nil, []*route.VirtualHost{vhost("suffix", "*.example.com")})
+	if got := idx.match(".example.com"); got != nil {
+		t.Fatalf("expected the wildcard to match at least one character, got %v", got)
+	}
+	if got := idx.match("example.org"); got != nil {
+		t.Fatalf("expected no match, got %v", got)
+	}
+}
@@ -37,9 +37,14 @@ type IstioControlPlaneInstance struct {
 	ID string
 	// The Istio version
 	Info istioversion.BuildInfo
+	// Affinity hint of the instance, if enabled.
+	AffinityHint *AffinityHint `json:",omitempty"`
 }
 
-var controlPlane *corev3.ControlPlane
+var (
+	controlPlaneInstance IstioControlPlaneInstance
+	controlPlane         *corev3.ControlPlane
+)
 
 // ControlPlane identifies the instance and Istio version.
 func ControlPlane() *corev3.ControlPlane {
@@ -49,11 +54,12 @@ func ControlPlane() *corev3.ControlPlane {
 func init() {
 	// The Pod Name (instance identity) is in PilotArgs, but not reachable globally nor from DiscoveryServer
 	podName := env.RegisterStringVar("POD_NAME", "", "").Get()
-	byVersion, err := json.Marshal(IstioControlPlaneInstance{
+	controlPlaneInstance = IstioControlPlaneInstance{
 		Component: "istiod",
 		ID:        podName,
 		Info:      istioversion.Info,
-	})
+	}
+	byVersion, err := json.Marshal(controlPlaneInstance)
 	if err != nil {
 		log.Warnf("XDS: Could not serialize control plane id: %v", err)
 	}
@@ -96,9 +102,13 @@ func (s *DiscoveryServer) pushXds(con *Connection, push *model.PushContext,
 		return nil
 	}
 
+	releaseTypePush := s.acquireTypePush(con, w.TypeUrl)
 	t0 := time.Now()
 
 	res, logdata, err := gen.Generate(con.proxy, push, w, req)
+	generation := time.Since(t0)
+	// The slot of the type only limits the generation, the responses are limited by the send rate limit.
+	releaseTypePush()
 	if err != nil || res == nil {
 		// If we have nothing to send, report that we got an ACK for this version.
 		if s.StatusReporter != nil {
@@ -106,19 +116,18 @@ func (s *DiscoveryServer) pushXds(con *Connection, push *model.PushContext,
 		}
 		return err
 	}
-	defer func() { recordPushTime(w.TypeUrl, time.Since(t0)) }()
+	defer func() { recordPushTime(w.TypeUrl, con.proxy, time.Since(t0)) }()
 
 	resp := &discovery.DiscoveryResponse{
-		ControlPlane: ControlPlane(),
+		ControlPlane: s.controlPlane(),
 		TypeUrl:      w.TypeUrl,
-		// TODO: send different version for incremental eds
-		VersionInfo: push.PushVersion,
-		Nonce:       nonce(push.LedgerVersion),
-		Resources:   model.ResourcesToAny(res),
+		VersionInfo:  s.responseVersion(con, w.TypeUrl, push),
+		Nonce:        nonce(push.LedgerVersion),
+		Resources:    model.ResourcesToAny(res),
 	}
 
 	configSize := ResourceSize(res)
-	configSizeBytes.With(typeTag.Value(w.TypeUrl)).Record(float64(configSize))
+	recordConfigSize(w.TypeUrl, con.proxy, configSize)
 
 	ptype := "PUSH"
 	info := ""
@@ -129,8 +138,11 @@ func (s *DiscoveryServer) pushXds(con *Connection, push *model.PushContext,
 		info = " " + logdata.AdditionalInfo
 	}
 
-	if err := con.send(resp); err != nil {
+	err = con.send(resp)
+	s.audit.response(con, auditStreamSotW, resp.TypeUrl, resp.Nonce, resp.VersionInfo, len(res), 0, generation, err)
+	if err != nil {
 		if recordSendError(w.TypeUrl, err) {
+			s.emitPushFailed(con, w.TypeUrl, err)
 			log.Warnf("%s: Send failure for node:%s resources:%d size:%s%s: %v",
 				v3.GetShortType(w.TypeUrl), con.proxy.ID, len(res), util.ByteCount(configSize), info, err)
 		}
@@ -161,7 +173,7 @@ func ResourceSize(r model.Resources) int {
 	// proto.Size, at the expense of slightly under counting.
 	size := 0
 	for _, r := range r {
-		size += len(r.Resource.Value)
+		size += len(r.Resource.GetValue())
 	}
 	return size
 }
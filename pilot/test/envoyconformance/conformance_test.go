@@ -0,0 +1,51 @@
+//go:build conformance
+// +build conformance
+
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoyconformance
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestConformance(t *testing.T) {
+	gateway := Proxy{Type: model.Router, Namespace: "istio-system", Labels: map[string]string{"istio": "ingressgateway"}}
+	cases := []Case{
+		{
+			Name:       "gateway routing",
+			ConfigFile: "testdata/gateway-routing.yaml",
+			Proxy:      gateway,
+			Requests: []Request{
+				{Port: 18080, Host: "reviews.example.com", Path: "/reviews", Backend: "v1"},
+				{Port: 18080, Host: "reviews.example.com", Path: "/reviews", Headers: map[string]string{"end-user": "jason"}, Backend: "v2"},
+				{Port: 18080, Host: "reviews.example.com", Path: "/legacy/reviews", Backend: "v1", BackendPath: "/reviews"},
+				{Port: 18080, Host: "reviews.example.com", Path: "/unavailable", Code: 503},
+				{Port: 18080, Host: "unknown.example.com", Path: "/", Code: 404},
+			},
+		},
+		{
+			Name:       "sidecar outbound",
+			ConfigFile: "testdata/sidecar-outbound.yaml",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			Run(t, c)
+		})
+	}
+}
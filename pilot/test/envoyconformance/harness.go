@@ -0,0 +1,313 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package envoyconformance replays a corpus of Istio configurations against a real Envoy binary, connected to an
+// in-memory istiod with the bootstrap generated for the proxies, to catch the configurations a new Envoy version
+// rejects or routes differently.
+package envoyconformance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/bootstrap"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/envoy"
+	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/util/retry"
+	pkgenv "istio.io/pkg/env"
+)
+
+const (
+	// BackendHeader is the response header with the name of the backend which received the request.
+	BackendHeader = "x-conformance-backend"
+	// PathHeader is the response header with the path of the request received by the backend.
+	PathHeader = "x-conformance-path"
+)
+
+var envoyPath = pkgenv.RegisterStringVar("ENVOY_PATH", "", "Specifies the path to an Envoy binary.")
+
+// Case is a configuration of the corpus, and the requests it must route.
+type Case struct {
+	Name string
+	// ConfigFile is the Istio configuration, as a Go template executed with the backends: {{ .Backend "v1" }} is
+	// the port of a local HTTP server answering as the backend "v1".
+	ConfigFile string
+	Proxy      Proxy
+	// Requests are sent to the listeners bound by the proxy. Sidecar listeners are not bound without traffic
+	// capture, so only the acceptance of their configuration is checked.
+	Requests []Request
+}
+
+// Proxy is the proxy the configuration is generated for.
+type Proxy struct {
+	// Type defaults to model.SidecarProxy.
+	Type model.NodeType
+	// Namespace defaults to "default".
+	Namespace string
+	Labels    map[string]string
+}
+
+// Request is a sample request sent to Envoy, and its expected response.
+type Request struct {
+	Port    int
+	Host    string
+	Path    string
+	Headers map[string]string
+	// Backend is the name of the backend expected to receive the request. Empty if Envoy responds itself.
+	Backend string
+	// Code defaults to 200.
+	Code int
+	// BackendPath is the path expected to be received by the backend. Defaults to Path.
+	BackendPath string
+}
+
+// Backends are the local HTTP servers referenced by the configurations.
+type Backends struct {
+	t       *testing.T
+	mu      sync.Mutex
+	servers map[string]*httptest.Server
+}
+
+// Backend returns the port of the backend with the name, starting it if needed.
+func (b *Backends) Backend(name string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, f := b.servers[name]
+	if !f {
+		s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(BackendHeader, name)
+			w.Header().Set(PathHeader, r.URL.RequestURI())
+		}))
+		b.t.Cleanup(s.Close)
+		b.servers[name] = s
+	}
+	return s.Listener.Addr().(*net.TCPAddr).Port
+}
+
+// Run replays the Case: Envoy must accept all of its configuration, and route its requests as expected.
+func Run(t *testing.T, c Case) {
+	t.Helper()
+	cfg, err := os.ReadFile(c.ConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Unix socket paths are limited to ~100 characters, too short for t.TempDir().
+	dir, err := os.MkdirTemp("", "conformance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	// The bootstrap connects to the XDS socket of the config path, which is usually served by the agent.
+	xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		ConfigString:        string(cfg),
+		ConfigTemplateInput: &Backends{t: t, servers: map[string]*httptest.Server{}},
+		ListenerBuilder: func() (net.Listener, error) {
+			return net.Listen("unix", filepath.Join(dir, "XDS"))
+		},
+	})
+
+	adminPort := startEnvoy(t, dir, c.Proxy)
+	checkAccepted(t, adminPort)
+	for _, r := range c.Requests {
+		r := r
+		retry.UntilSuccessOrFail(t, func() error {
+			return checkRequest(r)
+		}, retry.Timeout(10*time.Second), retry.Delay(100*time.Millisecond))
+	}
+}
+
+func startEnvoy(t *testing.T, dir string, p Proxy) uint32 {
+	if p.Type == "" {
+		p.Type = model.SidecarProxy
+	}
+	if p.Namespace == "" {
+		p.Namespace = "default"
+	}
+	labels, err := json.Marshal(p.Labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminPort := freePort(t)
+	pc := mesh.DefaultProxyConfig()
+	pc.ConfigPath = dir
+	pc.ProxyAdminPort = int32(adminPort)
+	pc.ProxyBootstrapTemplatePath = filepath.Join(env.IstioSrc, "tools/packaging/common/envoy_bootstrap.json")
+	pc.TerminationDrainDuration = nil
+	node, err := bootstrap.GetNodeMetaData(bootstrap.MetadataOptions{
+		// The proxy IP does not match the endpoints of the backends, which would otherwise be its instances.
+		ID: fmt.Sprintf("%s~10.10.0.1~conformance.%s~%s.svc.cluster.local", p.Type, p.Namespace, p.Namespace),
+		Envs: []string{
+			"ISTIO_META_NAMESPACE=" + p.Namespace,
+			"ISTIO_META_CLUSTER_ID=Kubernetes",
+			"ISTIO_METAJSON_LABELS=" + string(labels),
+		},
+		InstanceIPs:         []string{"10.10.0.1"},
+		ProxyConfig:         &pc,
+		EnvoyStatusPort:     freePort(t),
+		EnvoyPrometheusPort: freePort(t),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath, err := bootstrap.New(bootstrap.Config{Node: node}).CreateFileForEpoch(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	binaryPath := filepath.Join(env.LocalOut, "envoy")
+	if path, f := envoyPath.Lookup(); f {
+		binaryPath = path
+	}
+	if _, err := os.Stat(binaryPath); err != nil {
+		t.Fatalf("envoy binary not found, set ENVOY_PATH: %v", err)
+	}
+	e, err := envoy.New(envoy.Config{
+		Name:       "conformance",
+		AdminPort:  uint32(adminPort),
+		BinaryPath: binaryPath,
+		WorkingDir: dir,
+		Options: []envoy.Option{
+			envoy.ConfigPath(configPath),
+			envoy.Concurrency(1),
+			envoy.DisableHotRestart(true),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Start(context.Background())
+	t.Cleanup(func() {
+		if err := e.ShutdownAndWait().WithTimeout(5 * time.Second).Do(); err != nil {
+			_ = e.KillAndWait().WithTimeout(5 * time.Second).Do()
+		}
+	})
+	return uint32(adminPort)
+}
+
+// checkAccepted waits until Envoy applied or rejected every xDS update it subscribed to, and fails on rejections.
+// Envoy does not become live when the configuration is waiting for secrets, so its subscriptions are checked instead.
+func checkAccepted(t *testing.T, adminPort uint32) {
+	var stats map[string]uint64
+	retry.UntilSuccessOrFail(t, func() error {
+		var err error
+		if stats, err = xdsStats(adminPort); err != nil {
+			return err
+		}
+		for _, s := range []string{"cluster_manager.cds", "listener_manager.lds"} {
+			if stats[s+".update_success"]+stats[s+".update_rejected"] == 0 {
+				return fmt.Errorf("no update of %s yet", s)
+			}
+		}
+		for name, v := range stats {
+			if !strings.HasSuffix(name, ".update_attempt") || v == 0 {
+				continue
+			}
+			s := strings.TrimSuffix(name, ".update_attempt")
+			if stats[s+".update_success"]+stats[s+".update_rejected"] == 0 {
+				return fmt.Errorf("no update of %s yet", s)
+			}
+		}
+		return nil
+	}, retry.Timeout(30*time.Second), retry.Delay(100*time.Millisecond))
+	for name, v := range stats {
+		if strings.HasSuffix(name, ".update_rejected") && v > 0 {
+			t.Errorf("envoy rejected the configuration: %s = %d", name, v)
+		}
+	}
+}
+
+// xdsStats returns the stats of the xDS subscriptions of Envoy.
+func xdsStats(adminPort uint32) (map[string]uint64, error) {
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/stats?filter=update_(attempt|success|rejected)$", adminPort))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	stats := map[string]uint64{}
+	for _, line := range strings.Split(string(body), "\n") {
+		parts := strings.SplitN(line, ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		stats[parts[0]] = v
+	}
+	return stats, nil
+}
+
+func checkRequest(r Request) error {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d%s", r.Port, r.Path), nil)
+	if err != nil {
+		return err
+	}
+	req.Host = r.Host
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	code := r.Code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	if resp.StatusCode != code {
+		return fmt.Errorf("request %s%s: expected code %d, got %d", r.Host, r.Path, code, resp.StatusCode)
+	}
+	if got := resp.Header.Get(BackendHeader); got != r.Backend {
+		return fmt.Errorf("request %s%s: expected backend %q, got %q", r.Host, r.Path, r.Backend, got)
+	}
+	path := r.BackendPath
+	if path == "" {
+		path = r.Path
+	}
+	if got := resp.Header.Get(PathHeader); r.Backend != "" && got != path {
+		return fmt.Errorf("request %s%s: expected backend path %q, got %q", r.Host, r.Path, path, got)
+	}
+	return nil
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
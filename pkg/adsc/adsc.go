@@ -287,7 +287,7 @@ func New(discoveryAddr string, opts *Config) (*ADSC, error) {
 		opts.NodeType = "sidecar"
 	}
 	if opts.IP == "" {
-		opts.IP = getPrivateIPIfAvailable().String()
+		opts.IP = PrivateIPIfAvailable().String()
 	}
 	if opts.Workload == "" {
 		opts.Workload = "test-1"
@@ -337,8 +337,8 @@ func (a *ADSC) Dial() error {
 	return nil
 }
 
-// Returns a private IP address, or unspecified IP (0.0.0.0) if no IP is available
-func getPrivateIPIfAvailable() net.IP {
+// PrivateIPIfAvailable returns a private IP address, or unspecified IP (0.0.0.0) if no IP is available
+func PrivateIPIfAvailable() net.IP {
 	addrs, _ := net.InterfaceAddrs()
 	for _, addr := range addrs {
 		var ip net.IP
@@ -600,6 +600,11 @@ func (a *ADSC) handleRecv() {
 }
 
 func (a *ADSC) mcpToPilot(m *mcp.Resource) (*config.Config, error) {
+	return MCPToConfig(m, a.cfg.Revision)
+}
+
+// MCPToConfig converts a MCP resource to a config. It returns nil if the resource is not in the revision.
+func MCPToConfig(m *mcp.Resource, revision string) (*config.Config, error) {
 	if m == nil || m.Metadata == nil {
 		return &config.Config{}, nil
 	}
@@ -611,7 +616,7 @@ func (a *ADSC) mcpToPilot(m *mcp.Resource) (*config.Config, error) {
 		},
 	}
 
-	if !config.ObjectInRevision(c, a.cfg.Revision) { // In case upstream does not support rev in node meta.
+	if !config.ObjectInRevision(c, revision) { // In case upstream does not support rev in node meta.
 		return nil, nil
 	}
 
@@ -58,6 +58,10 @@ const (
 
 	requiredEnvoyStatsMatcherInclusionSuffixes = rbacEnvoyStatsMatcherInclusionSuffix + ",downstream_cx_active" // Needed for draining.
 
+	// Stats of the load shedding filters, such as the computed concurrency limit, included when they are enabled.
+	adaptiveConcurrencyStatsMatcherInclusionRegexp = `http\..*\.adaptive_concurrency\..*`
+	admissionControlStatsMatcherInclusionRegexp    = `http\..*\.admission_control\..*`
+
 	// Prefixes of V2 metrics.
 	// "reporter" prefix is for istio standard metrics.
 	// "component" suffix is for istio_build metric.
@@ -86,6 +90,7 @@ func (cfg Config) toTemplateParams() (map[string]interface{}, error) {
 		option.NodeType(cfg.ID),
 		option.PilotSubjectAltName(cfg.Metadata.PilotSubjectAltName),
 		option.OutlierLogPath(cfg.Metadata.OutlierLogPath),
+		option.LoadReporting(bool(cfg.Metadata.EnableLoadReporting)),
 		option.ProvCert(cfg.Metadata.ProvCert),
 		option.DiscoveryHost(discHost),
 		option.Metadata(cfg.Metadata),
@@ -243,13 +248,20 @@ func getStatsOptions(meta *model.BootstrapNodeMetadata) []option.Instance {
 	if meta.ExitOnZeroActiveConnections {
 		inclusionSuffixes = requiredEnvoyStatsMatcherInclusionSuffixes
 	}
+	var inclusionRegexps []string
+	if meta.AdaptiveConcurrency != "" {
+		inclusionRegexps = append(inclusionRegexps, adaptiveConcurrencyStatsMatcherInclusionRegexp)
+	}
+	if meta.AdmissionControl != "" {
+		inclusionRegexps = append(inclusionRegexps, admissionControlStatsMatcherInclusionRegexp)
+	}
 
 	return []option.Instance{
 		option.EnvoyStatsMatcherInclusionPrefix(parseOption(prefixAnno,
 			requiredEnvoyStatsMatcherInclusionPrefixes, proxyConfigPrefixes)),
 		option.EnvoyStatsMatcherInclusionSuffix(parseOption(suffixAnno,
 			inclusionSuffixes, proxyConfigSuffixes)),
-		option.EnvoyStatsMatcherInclusionRegexp(parseOption(RegexAnno, "", proxyConfigRegexps)),
+		option.EnvoyStatsMatcherInclusionRegexp(parseOption(RegexAnno, strings.Join(inclusionRegexps, ","), proxyConfigRegexps)),
 		option.EnvoyExtraStatTags(extraStatTags),
 	}
 }
@@ -554,6 +566,7 @@ func GetNodeMetaData(options MetadataOptions) (*model.Node, error) {
 	meta.ExitOnZeroActiveConnections = model.StringBool(options.ExitOnZeroActiveConnections)
 
 	meta.ProxyConfig = (*model.NodeMetaProxyConfig)(options.ProxyConfig)
+	meta.SchemaVersion = model.NodeMetadataSchemaVersion
 
 	// Add all instance labels with lower precedence than pod labels
 	extractInstanceLabels(options.Platform, meta)
@@ -273,3 +273,21 @@ func TestGetStatOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestGetStatOptionsLoadShedding(t *testing.T) {
+	node, err := GetNodeMetaData(MetadataOptions{
+		ID:          "test",
+		Envs:        os.Environ(),
+		ProxyConfig: &v1alpha1.ProxyConfig{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.Metadata.AdaptiveConcurrency = "{}"
+	node.Metadata.AdmissionControl = "{}"
+	templateParams, _ := option.NewTemplateParams(getStatsOptions(node.Metadata)...)
+	want := []string{adaptiveConcurrencyStatsMatcherInclusionRegexp, admissionControlStatsMatcherInclusionRegexp}
+	if got := templateParams["inclusionRegexps"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected inclusion regexps. want: %v, got: %v", want, got)
+	}
+}
@@ -151,6 +151,21 @@ func TestGolden(t *testing.T) {
 		{
 			base: "metrics_no_statsd",
 		},
+		{
+			base: "load_reporting",
+			envVars: map[string]string{
+				"ISTIO_META_ENABLE_LOAD_REPORTING": "true",
+			},
+			check: func(got *bootstrap.Bootstrap, t *testing.T) {
+				lsc := got.GetClusterManager().GetLoadStatsConfig()
+				if got := lsc.GetGrpcServices()[0].GetEnvoyGrpc().GetClusterName(); got != "xds-grpc" {
+					t.Fatalf("expected load stats to be reported to xds-grpc, got %q", got)
+				}
+				if got := got.GetClusterManager().GetOutlierDetection().GetEventLogPath(); got != "/dev/stdout" {
+					t.Fatalf("expected outlier event log path /dev/stdout, got %q", got)
+				}
+			},
+		},
 		{
 			base:    "tracing_stackdriver",
 			stsPort: 15463,
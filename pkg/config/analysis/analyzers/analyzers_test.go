@@ -26,6 +26,7 @@ import (
 
 	"istio.io/istio/pkg/config/analysis"
 	"istio.io/istio/pkg/config/analysis/analyzers/annotations"
+	"istio.io/istio/pkg/config/analysis/analyzers/authn"
 	"istio.io/istio/pkg/config/analysis/analyzers/authz"
 	"istio.io/istio/pkg/config/analysis/analyzers/deployment"
 	"istio.io/istio/pkg/config/analysis/analyzers/deprecation"
@@ -162,6 +163,7 @@ var testGrid = []testCase{
 			{msg.ReferencedResourceNotFound, "Gateway defaultgateway-bogusCredentialName"},
 			{msg.ReferencedResourceNotFound, "Gateway customgateway-wrongnamespace"},
 			{msg.ReferencedResourceNotFound, "Gateway bogusgateway"},
+			{msg.GatewayMissingCredentialFallback, "Gateway defaultgateway-fallback"},
 		},
 	},
 	{
@@ -377,6 +379,22 @@ var testGrid = []testCase{
 			{msg.UnknownMeshNetworksServiceRegistry, "MeshNetworks istio-system/meshnetworks"},
 		},
 	},
+	{
+		name:           "jwt from query params with access log",
+		inputFiles:     []string{"testdata/jwt-query-params.yaml"},
+		meshConfigFile: "testdata/jwt-query-params-meshconfig.yaml",
+		analyzer:       &authn.JwtQueryParamsAnalyzer{},
+		expected: []message{
+			{msg.JwtFromQueryParamsWithAccessLog, "RequestAuthentication default/from-params"},
+		},
+	},
+	{
+		name:           "jwt from query params without path in access log",
+		inputFiles:     []string{"testdata/jwt-query-params.yaml"},
+		meshConfigFile: "testdata/jwt-query-params-meshconfig-nopath.yaml",
+		analyzer:       &authn.JwtQueryParamsAnalyzer{},
+		expected:       []message{},
+	},
 	{
 		name: "authorizationpolicies",
 		inputFiles: []string{
@@ -0,0 +1,116 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authn
+
+import (
+	"sort"
+	"strings"
+
+	"istio.io/api/mesh/v1alpha1"
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/pkg/config/analysis"
+	"istio.io/istio/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// JwtQueryParamsAnalyzer checks for RequestAuthentications extracting JWTs from query parameters while
+// proxies access log the request path. The token is removed by the proxy verifying it, but proxies forwarding
+// the request before, such as the client sidecar, log it.
+type JwtQueryParamsAnalyzer struct{}
+
+var _ analysis.Analyzer = &JwtQueryParamsAnalyzer{}
+
+// Metadata implements Analyzer
+func (a *JwtQueryParamsAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "authn.JwtQueryParamsAnalyzer",
+		Description: "Checks JWTs extracted from query parameters are not written to access logs",
+		Inputs: collection.Names{
+			collections.IstioSecurityV1Beta1Requestauthentications.Name(),
+			collections.IstioMeshV1Alpha1MeshConfig.Name(),
+		},
+	}
+}
+
+// Analyze implements Analyzer
+func (a *JwtQueryParamsAnalyzer) Analyze(c analysis.Context) {
+	if !accessLogsPath(fetchMeshConfig(c)) {
+		return
+	}
+	c.ForEach(collections.IstioSecurityV1Beta1Requestauthentications.Name(), func(r *resource.Instance) bool {
+		ra := r.Message.(*v1beta1.RequestAuthentication)
+		var params []string
+		for _, rule := range ra.GetJwtRules() {
+			params = append(params, rule.GetFromParams()...)
+		}
+		if len(params) > 0 {
+			sort.Strings(params)
+			c.Report(collections.IstioSecurityV1Beta1Requestauthentications.Name(),
+				msg.NewJwtFromQueryParamsWithAccessLog(r, strings.Join(params, ", ")))
+		}
+		return true
+	})
+}
+
+func fetchMeshConfig(c analysis.Context) *v1alpha1.MeshConfig {
+	var meshConfig *v1alpha1.MeshConfig
+	c.ForEach(collections.IstioMeshV1Alpha1MeshConfig.Name(), func(r *resource.Instance) bool {
+		meshConfig = r.Message.(*v1alpha1.MeshConfig)
+		return r.Metadata.FullName.Name != util.MeshConfigName
+	})
+	return meshConfig
+}
+
+// accessLogsPath returns whether the access logs configured in mesh config include the request path.
+func accessLogsPath(meshConfig *v1alpha1.MeshConfig) bool {
+	if meshConfig == nil {
+		return false
+	}
+	// The default format includes the path.
+	if meshConfig.GetAccessLogFile() != "" &&
+		(meshConfig.GetAccessLogFormat() == "" || formatIncludesPath(meshConfig.GetAccessLogFormat())) {
+		return true
+	}
+	defaults := map[string]bool{}
+	for _, name := range meshConfig.GetDefaultProviders().GetAccessLogging() {
+		defaults[name] = true
+	}
+	for _, p := range meshConfig.GetExtensionProviders() {
+		fileLog := p.GetEnvoyFileAccessLog()
+		if !defaults[p.GetName()] || fileLog == nil {
+			continue
+		}
+		format := fileLog.GetLogFormat()
+		if format.GetText() == "" && format.GetLabels() == nil {
+			return true
+		}
+		if formatIncludesPath(format.GetText()) {
+			return true
+		}
+		for _, v := range format.GetLabels().GetFields() {
+			if formatIncludesPath(v.GetStringValue()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formatIncludesPath(format string) bool {
+	return strings.Contains(strings.ToUpper(format), "PATH)%")
+}
@@ -16,6 +16,7 @@ package gateway
 
 import (
 	"fmt"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/labels"
 
@@ -23,6 +24,7 @@ import (
 	"istio.io/istio/pkg/config/analysis"
 	"istio.io/istio/pkg/config/analysis/analyzers/util"
 	"istio.io/istio/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/config/schema/collection"
 	"istio.io/istio/pkg/config/schema/collections"
@@ -81,6 +83,9 @@ func (a *SecretAnalyzer) Analyze(ctx analysis.Context) {
 
 			if !ctx.Exists(collections.K8SCoreV1Secrets.Name(), resource.NewShortOrFullName(gwNs, cn)) {
 				m := msg.NewReferencedResourceNotFound(r, "credentialName", cn)
+				if policy := missingCredentialPolicy(r); policy != "" {
+					m = msg.NewGatewayMissingCredentialFallback(r, cn, policy)
+				}
 
 				if line, ok := util.ErrorLine(r, fmt.Sprintf(util.CredentialName, i)); ok {
 					m.Line = line
@@ -93,6 +98,18 @@ func (a *SecretAnalyzer) Analyze(ctx analysis.Context) {
 	})
 }
 
+// missingCredentialPolicy returns the missing credential policy set on the gateway, if it serves a fallback for
+// the missing credentials or rejects their servers.
+func missingCredentialPolicy(r *resource.Instance) string {
+	policy := strings.ToUpper(r.Metadata.Annotations[constants.GatewayMissingCredentialPolicyAnnotation])
+	switch policy {
+	case "KEEP_LAST_GOOD", "DEFAULT_CERTIFICATE", "REJECT_SERVER":
+		return policy
+	default:
+		return ""
+	}
+}
+
 // Gets the namespace for the gateway (in terms of the actual workload selected by the gateway, NOT the namespace of the Gateway CRD)
 // Assumes that all selected workloads are in the same namespace, if this is not the case which one's namespace gets returned is undefined.
 func getGatewayNamespace(ctx analysis.Context, gw *v1alpha3.Gateway) resource.Namespace {
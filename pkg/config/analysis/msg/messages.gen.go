@@ -196,6 +196,14 @@ var (
 	// ExternalNameServiceTypeInvalidPortName defines a diag.MessageType for message "ExternalNameServiceTypeInvalidPortName".
 	// Description: Proxy may prevent tcp named ports and unmatched traffic for ports serving TCP protocol from being forwarded correctly for ExternalName services.
 	ExternalNameServiceTypeInvalidPortName = diag.NewMessageType(diag.Warning, "IST0150", "Port name for ExternalName service is invalid. Proxy may prevent tcp named ports and unmatched traffic for ports serving TCP protocol from being forwarded correctly")
+
+	// JwtFromQueryParamsWithAccessLog defines a diag.MessageType for message "JwtFromQueryParamsWithAccessLog".
+	// Description: JWTs extracted from query parameters may be written to access logs.
+	JwtFromQueryParamsWithAccessLog = diag.NewMessageType(diag.Warning, "IST0151", "The request authentication extracts JWTs from the query parameters %s, and proxies access log the request path. Proxies forwarding the request before the token is verified and removed will log the token. Extract the token from a header or cookie, or remove the path from the access log format.")
+
+	// GatewayMissingCredentialFallback defines a diag.MessageType for message "GatewayMissingCredentialFallback".
+	// Description: The secret referenced by a gateway server does not exist, and the missing credential policy of the gateway applies.
+	GatewayMissingCredentialFallback = diag.NewMessageType(diag.Warning, "IST0152", "Referenced credentialName not found: %q. The server is handled according to the %s missing credential policy.")
 )
 
 // All returns a list of all known message types.
@@ -248,6 +256,8 @@ func All() []*diag.MessageType {
 		NamespaceInjectionEnabledByDefault,
 		JwtClaimBasedRoutingWithoutRequestAuthN,
 		ExternalNameServiceTypeInvalidPortName,
+		JwtFromQueryParamsWithAccessLog,
+		GatewayMissingCredentialFallback,
 	}
 }
 
@@ -719,3 +729,22 @@ func NewExternalNameServiceTypeInvalidPortName(r *resource.Instance) diag.Messag
 		r,
 	)
 }
+
+// NewJwtFromQueryParamsWithAccessLog returns a new diag.Message based on JwtFromQueryParamsWithAccessLog.
+func NewJwtFromQueryParamsWithAccessLog(r *resource.Instance, params string) diag.Message {
+	return diag.NewMessage(
+		JwtFromQueryParamsWithAccessLog,
+		r,
+		params,
+	)
+}
+
+// NewGatewayMissingCredentialFallback returns a new diag.Message based on GatewayMissingCredentialFallback.
+func NewGatewayMissingCredentialFallback(r *resource.Instance, credentialName string, policy string) diag.Message {
+	return diag.NewMessage(
+		GatewayMissingCredentialFallback,
+		r,
+		credentialName,
+		policy,
+	)
+}
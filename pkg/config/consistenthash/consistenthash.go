@@ -0,0 +1,113 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package consistenthash holds the DestinationRule annotations that extend the consistent hash
+// load balancer beyond a single hash key.
+package consistenthash
+
+import (
+	"fmt"
+	"strings"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+const (
+	// HeadersAnnotation is a comma separated list of additional request headers to hash on. They are combined
+	// with the hash key of the DestinationRule into a single hash.
+	HeadersAnnotation = "networking.istio.io/consistentHashHeaders"
+
+	// FallbackHeaderAnnotation is a request header to hash on only if the hash key of the DestinationRule is
+	// not present in the request, for example hashing on a query parameter and falling back to a header.
+	FallbackHeaderAnnotation = "networking.istio.io/consistentHashFallbackHeader"
+)
+
+// Extensions are the additional hash keys configured on a DestinationRule.
+type Extensions struct {
+	Headers        []string
+	FallbackHeader string
+}
+
+// IsEmpty returns true if no extensions are configured.
+func (e *Extensions) IsEmpty() bool {
+	return e == nil || (len(e.Headers) == 0 && e.FallbackHeader == "")
+}
+
+// Parse reads the Extensions from the annotations of a DestinationRule.
+func Parse(annotations map[string]string) (*Extensions, error) {
+	e := &Extensions{}
+	if v, f := annotations[HeadersAnnotation]; f {
+		for _, h := range strings.Split(v, ",") {
+			h = strings.TrimSpace(h)
+			if h == "" {
+				return nil, fmt.Errorf("%s has an empty header name", HeadersAnnotation)
+			}
+			e.Headers = append(e.Headers, strings.ToLower(h))
+		}
+	}
+	if v, f := annotations[FallbackHeaderAnnotation]; f {
+		v = strings.TrimSpace(v)
+		if v == "" || strings.Contains(v, ",") {
+			return nil, fmt.Errorf("%s must be a single header name, got %q", FallbackHeaderAnnotation, v)
+		}
+		e.FallbackHeader = strings.ToLower(v)
+	}
+	if len(e.Headers) > 0 && e.FallbackHeader != "" {
+		return nil, fmt.Errorf("%s cannot be combined with %s", FallbackHeaderAnnotation, HeadersAnnotation)
+	}
+	return e, nil
+}
+
+// Validate checks the Extensions configured on a DestinationRule are valid for its consistent hash settings.
+func Validate(annotations map[string]string, rule *networking.DestinationRule) error {
+	e, err := Parse(annotations)
+	if err != nil {
+		return err
+	}
+	if e.IsEmpty() {
+		return nil
+	}
+	hashes := consistentHashes(rule)
+	if len(hashes) == 0 {
+		return fmt.Errorf("consistent hash annotations require a consistentHash load balancer")
+	}
+	if e.FallbackHeader != "" {
+		for _, h := range hashes {
+			if h.GetUseSourceIp() {
+				return fmt.Errorf("%s cannot be used with useSourceIp, which is always present", FallbackHeaderAnnotation)
+			}
+		}
+	}
+	return nil
+}
+
+// consistentHashes returns all the consistent hash settings of a DestinationRule.
+func consistentHashes(rule *networking.DestinationRule) []*networking.LoadBalancerSettings_ConsistentHashLB {
+	var out []*networking.LoadBalancerSettings_ConsistentHashLB
+	add := func(tp *networking.TrafficPolicy) {
+		if h := tp.GetLoadBalancer().GetConsistentHash(); h != nil {
+			out = append(out, h)
+		}
+		for _, pls := range tp.GetPortLevelSettings() {
+			if h := pls.GetLoadBalancer().GetConsistentHash(); h != nil {
+				out = append(out, h)
+			}
+		}
+	}
+	add(rule.GetTrafficPolicy())
+	for _, subset := range rule.GetSubsets() {
+		add(subset.GetTrafficPolicy())
+	}
+	return out
+}
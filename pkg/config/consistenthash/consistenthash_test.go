@@ -0,0 +1,128 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consistenthash
+
+import (
+	"reflect"
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		want        *Extensions
+		wantErr     bool
+	}{
+		{name: "none", want: &Extensions{}},
+		{
+			name:        "headers",
+			annotations: map[string]string{HeadersAnnotation: "X-User, x-tenant"},
+			want:        &Extensions{Headers: []string{"x-user", "x-tenant"}},
+		},
+		{
+			name:        "fallback",
+			annotations: map[string]string{FallbackHeaderAnnotation: "x-user"},
+			want:        &Extensions{FallbackHeader: "x-user"},
+		},
+		{
+			name:        "empty header",
+			annotations: map[string]string{HeadersAnnotation: "x-user,"},
+			wantErr:     true,
+		},
+		{
+			name:        "multiple fallback",
+			annotations: map[string]string{FallbackHeaderAnnotation: "x-user,x-tenant"},
+			wantErr:     true,
+		},
+		{
+			name:        "headers and fallback",
+			annotations: map[string]string{HeadersAnnotation: "x-user", FallbackHeaderAnnotation: "x-tenant"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	hashRule := func(hash *networking.LoadBalancerSettings_ConsistentHashLB) *networking.DestinationRule {
+		return &networking.DestinationRule{
+			Host: "reviews",
+			Subsets: []*networking.Subset{{
+				Name: "v1",
+				TrafficPolicy: &networking.TrafficPolicy{
+					LoadBalancer: &networking.LoadBalancerSettings{
+						LbPolicy: &networking.LoadBalancerSettings_ConsistentHash{ConsistentHash: hash},
+					},
+				},
+			}},
+		}
+	}
+	queryHash := &networking.LoadBalancerSettings_ConsistentHashLB{
+		HashKey: &networking.LoadBalancerSettings_ConsistentHashLB_HttpQueryParameterName{HttpQueryParameterName: "user"},
+	}
+	sourceIPHash := &networking.LoadBalancerSettings_ConsistentHashLB{
+		HashKey: &networking.LoadBalancerSettings_ConsistentHashLB_UseSourceIp{UseSourceIp: true},
+	}
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		rule        *networking.DestinationRule
+		wantErr     bool
+	}{
+		{name: "no annotations", rule: &networking.DestinationRule{Host: "reviews"}},
+		{
+			name:        "headers with subset hash",
+			annotations: map[string]string{HeadersAnnotation: "x-user"},
+			rule:        hashRule(queryHash),
+		},
+		{
+			name:        "fallback with query hash",
+			annotations: map[string]string{FallbackHeaderAnnotation: "x-user"},
+			rule:        hashRule(queryHash),
+		},
+		{
+			name:        "without consistent hash",
+			annotations: map[string]string{HeadersAnnotation: "x-user"},
+			rule:        &networking.DestinationRule{Host: "reviews"},
+			wantErr:     true,
+		},
+		{
+			name:        "fallback with source ip",
+			annotations: map[string]string{FallbackHeaderAnnotation: "x-user"},
+			rule:        hashRule(sourceIPHash),
+			wantErr:     true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.annotations, tt.rule); (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -96,6 +96,10 @@ const (
 	// This is typically set by the downward API
 	PodInfoAnnotationsPath = "./etc/istio/pod/annotations"
 
+	// ExemplarsSocketPath is the path of the socket on which the agent receives the access logs of traced
+	// requests from Envoy, to record the exemplars of the request duration histograms.
+	ExemplarsSocketPath = "./etc/istio/proxy/EXEMPLARS"
+
 	// DefaultServiceAccountName is the default service account to use for remote cluster access.
 	DefaultServiceAccountName = "istio-reader-service-account"
 
@@ -125,6 +129,48 @@ const (
 	// InternalParentName declares the original resource of an internally-generate config. This is used by the gateway-api.
 	InternalParentName = "internal.istio.io/parent"
 
+	// JwtFromCookiesAnnotation lists, comma separated, the cookies the JWT rules of a RequestAuthentication extract
+	// the token from, in addition to the locations in the spec.
+	JwtFromCookiesAnnotation = "security.istio.io/jwt-from-cookies"
+
+	// GatewayACMEChallengeAnnotation, on a Gateway, enables the provisioning of the certificates of its SIMPLE
+	// TLS servers through ACME, using the challenge type set as value: "http-01" or "dns-01".
+	GatewayACMEChallengeAnnotation = "networking.istio.io/acme-challenge"
+
+	// GatewayMissingCredentialPolicyAnnotation, on a Gateway, sets how its TLS servers are handled when the
+	// secret referenced by their credentialName is missing: NONE, KEEP_LAST_GOOD, DEFAULT_CERTIFICATE or REJECT_SERVER.
+	GatewayMissingCredentialPolicyAnnotation = "networking.istio.io/missing-credential-policy"
+
+	// TelemetryExemplarsAnnotation, on a Telemetry, enables ("true") or disables ("false") exemplars linking the
+	// request duration histograms of the selected sidecars to the traces of the requests.
+	TelemetryExemplarsAnnotation = "telemetry.istio.io/exemplars"
+
+	// TelemetryCompressionAnnotation, on a Telemetry, configures as JSON the compression of the responses of the
+	// selected gateways and sidecars, and the decompression of the requests and responses they proxy. For example:
+	// {"algorithms": ["brotli", "gzip"], "contentTypes": ["application/json"], "minContentLength": 1024,
+	// "decompression": ["gzip"]}.
+	TelemetryCompressionAnnotation = "telemetry.istio.io/compression"
+
+	// SidecarTrafficRedirectDNSAnnotation, on a pod, enables ("true") or disables ("false") the capture of its DNS
+	// traffic by the sidecar, overriding the ISTIO_META_DNS_CAPTURE proxy metadata.
+	SidecarTrafficRedirectDNSAnnotation = "traffic.sidecar.istio.io/redirectDNS"
+
+	// SidecarTrafficRedirectIPv6Annotation, on a pod, enables ("true") or disables ("false") the redirection of its
+	// IPv6 traffic to the sidecar, which is otherwise only enabled when the pod IP, or one of the IPs of its
+	// networking.istio.io/additionalIPs annotation, is an IPv6 address.
+	SidecarTrafficRedirectIPv6Annotation = "traffic.sidecar.istio.io/redirectIPv6"
+
+	// SidecarOutboundTrafficAuditAnnotation, on a Sidecar, opts the workloads it selects into the outbound traffic
+	// audit enabled with PILOT_OUTBOUND_TRAFFIC_AUDIT, when their outbound traffic policy is REGISTRY_ONLY: the
+	// traffic to hosts outside of the registry is forwarded and reported rather than blocked. Set to "true".
+	SidecarOutboundTrafficAuditAnnotation = "sidecar.istio.io/outboundTrafficAudit"
+
+	// ProxyPushDebounceAnnotation, on a pod, overrides the debounce of the pushes to its proxy, as a duration. A
+	// debounce shorter than PILOT_DEBOUNCE_AFTER, down to PILOT_PROXY_DEBOUNCE_MIN, makes istiod compute the
+	// configuration sooner after changes, for faster convergence of latency sensitive proxies such as gateways,
+	// while a longer one delays the pushes to the proxy. For example: "50ms".
+	ProxyPushDebounceAnnotation = "proxy.istio.io/push-debounce"
+
 	// TrustworthyJWTPath is the default 3P token to authenticate with third party services
 	TrustworthyJWTPath = "./var/run/secrets/tokens/istio-token"
 
@@ -0,0 +1,145 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faultinjection
+
+import (
+	"encoding/json"
+	"fmt"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/util/gogoprotomarshal"
+)
+
+// TargetsAnnotation is a JSON list of targeted faults injected by the HTTP routes of a VirtualService, in addition
+// to their fault. For example:
+//
+//	[{"route": "reviews", "subset": "v2", "headers": {"x-chaos": {"exact": "on"}},
+//	  "fault": {"abort": {"httpStatus": 503, "percentage": {"value": 50}}}}]
+//
+const TargetsAnnotation = "networking.istio.io/faultTargets"
+
+// Target is a fault injected by the HTTP routes of a VirtualService only for some of their requests.
+type Target struct {
+	// Route is the name of the HTTP routes injecting the fault. Empty for all the HTTP routes.
+	Route string
+	// Subset restricts the fault to the requests sent to the destinations with this subset. Empty for all the
+	// requests of the routes. The fault of a subset replaces the fault of the route for the requests sent to it,
+	// so that subsets have independent percentages.
+	Subset string
+	// Headers restricts the fault to the requests matching all of these headers.
+	Headers map[string]*networking.StringMatch
+	Fault   *networking.HTTPFaultInjection
+}
+
+type rawTarget struct {
+	Route   string                     `json:"route"`
+	Subset  string                     `json:"subset"`
+	Headers map[string]json.RawMessage `json:"headers"`
+	Fault   json.RawMessage            `json:"fault"`
+}
+
+// Parse reads the Targets from the annotations of a VirtualService.
+func Parse(annotations map[string]string) ([]*Target, error) {
+	v, f := annotations[TargetsAnnotation]
+	if !f {
+		return nil, nil
+	}
+	var raw []rawTarget
+	if err := json.Unmarshal([]byte(v), &raw); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", TargetsAnnotation, err)
+	}
+	out := make([]*Target, 0, len(raw))
+	for i, r := range raw {
+		t := &Target{Route: r.Route, Subset: r.Subset, Fault: &networking.HTTPFaultInjection{}}
+		if len(r.Fault) == 0 {
+			return nil, fmt.Errorf("%s target %d has no fault", TargetsAnnotation, i)
+		}
+		if err := gogoprotomarshal.ApplyJSONStrict(string(r.Fault), t.Fault); err != nil {
+			return nil, fmt.Errorf("%s target %d has an invalid fault: %v", TargetsAnnotation, i, err)
+		}
+		for name, m := range r.Headers {
+			if t.Headers == nil {
+				t.Headers = map[string]*networking.StringMatch{}
+			}
+			t.Headers[name] = &networking.StringMatch{}
+			if err := gogoprotomarshal.ApplyJSONStrict(string(m), t.Headers[name]); err != nil {
+				return nil, fmt.Errorf("%s target %d has an invalid match of header %s: %v", TargetsAnnotation, i, name, err)
+			}
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// ForRoute returns the Targets of the HTTP route.
+func ForRoute(targets []*Target, route *networking.HTTPRoute) []*Target {
+	var out []*Target
+	for _, t := range targets {
+		if t.Route == "" || t.Route == route.GetName() {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Validate checks the Targets configured on a VirtualService apply to its HTTP routes, and that each request is
+// subject to a single fault: a route has at most one fault without subset, and one fault per subset.
+func Validate(annotations map[string]string, vs *networking.VirtualService) error {
+	targets, err := Parse(annotations)
+	if err != nil || len(targets) == 0 {
+		return err
+	}
+	for i, t := range targets {
+		if t.Route != "" && !hasRoute(vs, t.Route) {
+			return fmt.Errorf("%s target %d references unknown HTTP route %q", TargetsAnnotation, i, t.Route)
+		}
+	}
+	for _, route := range vs.Http {
+		if route == nil {
+			continue
+		}
+		subsets := map[string]bool{}
+		for _, dst := range route.Route {
+			subsets[dst.GetDestination().GetSubset()] = true
+		}
+		seen := map[string]bool{}
+		if route.Fault != nil {
+			seen[""] = true
+		}
+		for _, t := range ForRoute(targets, route) {
+			if t.Subset != "" && !subsets[t.Subset] && t.Route != "" {
+				return fmt.Errorf("%s target for HTTP route %q references subset %q, which it does not route to",
+					TargetsAnnotation, route.Name, t.Subset)
+			}
+			if seen[t.Subset] {
+				if t.Subset == "" {
+					return fmt.Errorf("%s has multiple faults for HTTP route %q", TargetsAnnotation, route.Name)
+				}
+				return fmt.Errorf("%s has multiple faults for subset %q of HTTP route %q", TargetsAnnotation, t.Subset, route.Name)
+			}
+			seen[t.Subset] = true
+		}
+	}
+	return nil
+}
+
+func hasRoute(vs *networking.VirtualService, name string) bool {
+	for _, route := range vs.Http {
+		if route.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faultinjection
+
+import (
+	"reflect"
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+func TestParse(t *testing.T) {
+	abort := &networking.HTTPFaultInjection{
+		Abort: &networking.HTTPFaultInjection_Abort{
+			ErrorType:  &networking.HTTPFaultInjection_Abort_HttpStatus{HttpStatus: 503},
+			Percentage: &networking.Percent{Value: 50},
+		},
+	}
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		want        []*Target
+		wantErr     bool
+	}{
+		{name: "none"},
+		{
+			name: "subset",
+			annotations: map[string]string{
+				TargetsAnnotation: `[{"route": "reviews", "subset": "v2", "fault": {"abort": {"httpStatus": 503, "percentage": {"value": 50}}}}]`,
+			},
+			want: []*Target{{Route: "reviews", Subset: "v2", Fault: abort}},
+		},
+		{
+			name: "headers",
+			annotations: map[string]string{
+				TargetsAnnotation: `[{"headers": {"x-chaos": {"exact": "on"}}, "fault": {"abort": {"httpStatus": 503, "percentage": {"value": 50}}}}]`,
+			},
+			want: []*Target{{
+				Headers: map[string]*networking.StringMatch{"x-chaos": {MatchType: &networking.StringMatch_Exact{Exact: "on"}}},
+				Fault:   abort,
+			}},
+		},
+		{
+			name:        "invalid json",
+			annotations: map[string]string{TargetsAnnotation: `{"route": "reviews"}`},
+			wantErr:     true,
+		},
+		{
+			name:        "missing fault",
+			annotations: map[string]string{TargetsAnnotation: `[{"route": "reviews"}]`},
+			wantErr:     true,
+		},
+		{
+			name:        "unknown fault field",
+			annotations: map[string]string{TargetsAnnotation: `[{"fault": {"unknown": {}}}]`},
+			wantErr:     true,
+		},
+		{
+			name:        "invalid header match",
+			annotations: map[string]string{TargetsAnnotation: `[{"headers": {"x-chaos": "on"}, "fault": {"abort": {"httpStatus": 503}}}]`},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	vs := &networking.VirtualService{
+		Hosts: []string{"reviews"},
+		Http: []*networking.HTTPRoute{{
+			Name: "reviews",
+			Route: []*networking.HTTPRouteDestination{
+				{Destination: &networking.Destination{Host: "reviews", Subset: "v1"}, Weight: 50},
+				{Destination: &networking.Destination{Host: "reviews", Subset: "v2"}, Weight: 50},
+			},
+		}},
+	}
+	fault := `"fault": {"abort": {"httpStatus": 503}}`
+	cases := []struct {
+		name    string
+		targets string
+		wantErr bool
+	}{
+		{name: "route and subsets", targets: `[{"route": "reviews", ` + fault + `}, {"subset": "v1", ` + fault + `}, {"subset": "v2", ` + fault + `}]`},
+		{name: "unknown route", targets: `[{"route": "ratings", ` + fault + `}]`, wantErr: true},
+		{name: "unknown subset", targets: `[{"route": "reviews", "subset": "v3", ` + fault + `}]`, wantErr: true},
+		{name: "duplicate route fault", targets: `[{` + fault + `}, {"route": "reviews", ` + fault + `}]`, wantErr: true},
+		{name: "duplicate subset fault", targets: `[{"subset": "v2", ` + fault + `}, {"route": "reviews", "subset": "v2", ` + fault + `}]`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(map[string]string{TargetsAnnotation: tt.targets}, vs); (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
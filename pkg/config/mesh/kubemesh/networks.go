@@ -0,0 +1,316 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubemesh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/controllers"
+	"istio.io/pkg/log"
+)
+
+// MeshNetworksResource is the resource holding mesh networks in its spec, with the same schema as the meshNetworks
+// of the mesh networks ConfigMap. Istiod reports the status of each resource in its status.
+var MeshNetworksResource = schema.GroupVersionResource{Group: "install.istio.io", Version: "v1alpha1", Resource: "meshnetworks"}
+
+// NetworksStatus is the status of a MeshNetworks resource.
+type NetworksStatus struct {
+	// Error is the reason the networks of the resource, or some of them, are not applied.
+	Error string `json:"error,omitempty"`
+	// Gateways are the resolved addresses of the gateways of each network of the resource.
+	Gateways map[string][]string `json:"gateways,omitempty"`
+}
+
+var _ mesh.NetworksWatcher = &NetworksResourceWatcher{}
+
+// NetworksResourceWatcher merges the mesh networks of the MeshNetworks resources of the Istio namespace into the
+// networks of another watcher. Only the Istio namespace is watched, since the networks define the gateways all the
+// cross-network traffic of the mesh goes through. The resources are validated and reloaded on change, and the last
+// valid networks of a resource are kept when it becomes invalid. A network defined by several sources is taken from
+// the other watcher first, then from the resources ordered by name.
+type NetworksResourceWatcher struct {
+	base      mesh.NetworksWatcher
+	client    kube.Client
+	namespace string
+	informer  informers.GenericInformer
+	queue     controllers.Queue
+
+	mutex     sync.RWMutex
+	resources map[types.NamespacedName]*meshconfig.MeshNetworks
+	errors    map[types.NamespacedName]string
+	networks  *meshconfig.MeshNetworks
+	// owners is the resource each merged network is taken from.
+	owners   map[string]types.NamespacedName
+	gateways map[string][]string
+	handlers []func()
+	// statusWrite is whether the status of the resources is written, i.e. this instance is the status leader.
+	statusWrite bool
+}
+
+// NewNetworksResourceWatcher creates a watcher merging the mesh networks of the MeshNetworks resources of the given
+// namespace into the networks of base, and waits for the resources to be loaded.
+func NewNetworksResourceWatcher(client kube.Client, namespace string, base mesh.NetworksWatcher, stop <-chan struct{}) *NetworksResourceWatcher {
+	w := &NetworksResourceWatcher{
+		base:      base,
+		client:    client,
+		namespace: namespace,
+		resources: map[types.NamespacedName]*meshconfig.MeshNetworks{},
+		errors:    map[types.NamespacedName]string{},
+	}
+	w.informer = dynamicinformer.NewFilteredDynamicSharedInformerFactory(client.Dynamic(), 0, namespace, nil).
+		ForResource(MeshNetworksResource)
+	w.queue = controllers.NewQueue("mesh networks", controllers.WithReconciler(w.reconcile))
+	w.informer.Informer().AddEventHandler(controllers.ObjectHandler(w.queue.AddObject))
+	base.AddNetworksHandler(w.merge)
+	w.merge()
+
+	go w.informer.Informer().Run(stop)
+	if !cache.WaitForCacheSync(stop, w.informer.Informer().HasSynced) {
+		log.Error("failed to wait for mesh networks cache sync")
+		return w
+	}
+	go w.queue.Run(stop)
+	if !cache.WaitForCacheSync(stop, w.queue.HasSynced) {
+		log.Error("failed to wait for mesh networks sync")
+	}
+	return w
+}
+
+// Networks returns the merged mesh networks.
+func (w *NetworksResourceWatcher) Networks() *meshconfig.MeshNetworks {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.networks
+}
+
+// SetNetworks sets the networks of the underlying watcher, which the networks of the resources are merged into.
+func (w *NetworksResourceWatcher) SetNetworks(networks *meshconfig.MeshNetworks) {
+	w.base.SetNetworks(networks)
+}
+
+// AddNetworksHandler registers a callback handler for changes to the merged mesh networks.
+func (w *NetworksResourceWatcher) AddNetworksHandler(h func()) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	// Like the other networks watchers, the last handler added runs first.
+	w.handlers = append([]func(){h}, w.handlers...)
+}
+
+// SetResolvedGateways records the resolved addresses of the gateways of each network, and reports them in the
+// status of the resources defining the networks.
+func (w *NetworksResourceWatcher) SetResolvedGateways(gateways map[string][]string) {
+	w.mutex.Lock()
+	w.gateways = gateways
+	w.mutex.Unlock()
+	for _, key := range w.keys() {
+		w.writeStatus(key)
+	}
+}
+
+// SetStatusWrite sets whether the status of the resources is written. The status of all the resources is written
+// once enabled.
+func (w *NetworksResourceWatcher) SetStatusWrite(enabled bool) {
+	w.mutex.Lock()
+	w.statusWrite = enabled
+	w.mutex.Unlock()
+	if !enabled {
+		return
+	}
+	for _, key := range w.keys() {
+		w.writeStatus(key)
+	}
+}
+
+func (w *NetworksResourceWatcher) keys() []types.NamespacedName {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	out := make([]types.NamespacedName, 0, len(w.resources)+len(w.errors))
+	for key := range w.resources {
+		out = append(out, key)
+	}
+	for key := range w.errors {
+		if _, f := w.resources[key]; !f {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+func (w *NetworksResourceWatcher) get(key types.NamespacedName) (*unstructured.Unstructured, error) {
+	obj, err := w.informer.Lister().ByNamespace(key.Namespace).Get(key.Name)
+	if err != nil {
+		return nil, err
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object %T", obj)
+	}
+	return u, nil
+}
+
+func (w *NetworksResourceWatcher) reconcile(key types.NamespacedName) error {
+	u, err := w.get(key)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error fetching mesh networks %s: %v", key, err)
+	}
+	w.mutex.Lock()
+	if u == nil {
+		delete(w.resources, key)
+		delete(w.errors, key)
+	} else if networks, err := readNetworksResource(u); err != nil {
+		// Keep the last valid networks of the resource.
+		log.Warnf("invalid mesh networks %s: %v", key, err)
+		w.errors[key] = err.Error()
+	} else {
+		w.resources[key] = networks
+		delete(w.errors, key)
+	}
+	w.mutex.Unlock()
+
+	w.merge()
+	if u != nil {
+		w.writeStatus(key)
+	}
+	return nil
+}
+
+// readNetworksResource returns the mesh networks of the spec of a MeshNetworks resource.
+func readNetworksResource(u *unstructured.Unstructured) (*meshconfig.MeshNetworks, error) {
+	spec, found, err := unstructured.NestedMap(u.Object, "spec")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("missing spec")
+	}
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	return mesh.ParseMeshNetworks(string(b))
+}
+
+// merge computes the merged networks, and notifies the handlers if they changed.
+func (w *NetworksResourceWatcher) merge() {
+	merged := mesh.EmptyMeshNetworks()
+	owners := map[string]types.NamespacedName{}
+	if base := w.base.Networks(); base != nil {
+		for name, nw := range base.Networks {
+			merged.Networks[name] = nw
+		}
+	}
+
+	w.mutex.Lock()
+	keys := make([]types.NamespacedName, 0, len(w.resources))
+	for key := range w.resources {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	for _, key := range keys {
+		for name, nw := range w.resources[key].Networks {
+			if _, f := merged.Networks[name]; f {
+				continue
+			}
+			merged.Networks[name] = nw
+			owners[name] = key
+		}
+	}
+	var handlers []func()
+	if !reflect.DeepEqual(&merged, w.networks) {
+		w.networks = &merged
+		handlers = append(handlers, w.handlers...)
+	}
+	w.owners = owners
+	w.mutex.Unlock()
+
+	for _, h := range handlers {
+		h()
+	}
+}
+
+// status returns the status of a resource.
+func (w *NetworksResourceWatcher) status(key types.NamespacedName) NetworksStatus {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	status := NetworksStatus{Error: w.errors[key]}
+	var conflicts []string
+	if resource := w.resources[key]; resource != nil {
+		for name := range resource.Networks {
+			if w.owners[name] != key {
+				conflicts = append(conflicts, name)
+				continue
+			}
+			if status.Gateways == nil {
+				status.Gateways = map[string][]string{}
+			}
+			status.Gateways[name] = append([]string{}, w.gateways[name]...)
+		}
+	}
+	if len(conflicts) > 0 && status.Error == "" {
+		sort.Strings(conflicts)
+		status.Error = fmt.Sprintf("networks %v are already defined by another source", conflicts)
+	}
+	return status
+}
+
+// writeStatus sets the status of a resource, if it changed and the status is written.
+func (w *NetworksResourceWatcher) writeStatus(key types.NamespacedName) {
+	w.mutex.RLock()
+	enabled := w.statusWrite
+	w.mutex.RUnlock()
+	if !enabled {
+		return
+	}
+	u, err := w.get(key)
+	if err != nil {
+		return
+	}
+	networksStatus := w.status(key)
+	status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&networksStatus)
+	if err != nil {
+		return
+	}
+	if current, _, _ := unstructured.NestedMap(u.Object, "status"); reflect.DeepEqual(current, status) {
+		return
+	}
+	u = u.DeepCopy()
+	if err := unstructured.SetNestedMap(u.Object, status, "status"); err != nil {
+		return
+	}
+	if _, err := w.client.Dynamic().Resource(MeshNetworksResource).Namespace(key.Namespace).
+		UpdateStatus(context.TODO(), u, metav1.UpdateOptions{}); err != nil {
+		log.Warnf("failed to update the status of mesh networks %s: %v", key, err)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubemesh
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func makeNetworksResource(t *testing.T, ns, name, networks string) *unstructured.Unstructured {
+	t.Helper()
+	spec := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(networks), &spec); err != nil {
+		t.Fatal(err)
+	}
+	u := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	u.SetAPIVersion(MeshNetworksResource.GroupVersion().String())
+	u.SetKind("MeshNetworks")
+	u.SetNamespace(ns)
+	u.SetName(name)
+	return u
+}
+
+func networksYAML(network, address string) string {
+	return fmt.Sprintf(`
+networks:
+  %s:
+    endpoints:
+    - fromRegistry: %s
+    gateways:
+    - address: %s
+      port: 15443
+`, network, network, address)
+}
+
+func networkNames(networks *meshconfig.MeshNetworks) []string {
+	names := []string{}
+	for name := range networks.GetNetworks() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestNetworksResourceWatcher(t *testing.T) {
+	client := kube.NewFakeClient()
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	baseNetworks, err := mesh.ParseMeshNetworks(networksYAML("base", "1.1.1.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := mesh.NewFixedNetworksWatcher(baseNetworks)
+	w := NewNetworksResourceWatcher(client, "istio-system", base, stop)
+	notified := make(chan struct{}, 10)
+	w.AddNetworksHandler(func() { notified <- struct{}{} })
+
+	resources := client.Dynamic().Resource(MeshNetworksResource).Namespace("istio-system")
+	expectNetworks := func(want ...string) {
+		t.Helper()
+		retry.UntilSuccessOrFail(t, func() error {
+			if got := networkNames(w.Networks()); !reflect.DeepEqual(got, want) {
+				return fmt.Errorf("expected networks %v, got %v", want, got)
+			}
+			return nil
+		})
+	}
+	expectStatus := func(name string, want NetworksStatus) {
+		t.Helper()
+		retry.UntilSuccessOrFail(t, func() error {
+			got, err := getStatus(resources.Get(context.TODO(), name, metav1.GetOptions{}))
+			if err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(got, want) {
+				return fmt.Errorf("expected status %+v, got %+v", want, got)
+			}
+			return nil
+		})
+	}
+	expectNetworks("base")
+
+	if _, err := resources.Create(context.TODO(), makeNetworksResource(t, "istio-system", "a", networksYAML("a", "2.2.2.2")), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectNetworks("a", "base")
+	<-notified
+	w.SetResolvedGateways(map[string][]string{"a": {"2.2.2.2:15443"}, "base": {"1.1.1.1:15443"}})
+	// The status is only written by the status leader.
+	if got, err := getStatus(resources.Get(context.TODO(), "a", metav1.GetOptions{})); err != nil || !reflect.DeepEqual(got, NetworksStatus{}) {
+		t.Fatalf("expected no status before leading, got %+v, %v", got, err)
+	}
+	w.SetStatusWrite(true)
+	expectStatus("a", NetworksStatus{Gateways: map[string][]string{"a": {"2.2.2.2:15443"}}})
+
+	// An invalid update keeps the last valid networks.
+	if _, err := resources.Update(context.TODO(), makeNetworksResource(t, "istio-system", "a", "networks: {a: {gateways: [{port: 0}]}}"),
+		metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		got, err := getStatus(resources.Get(context.TODO(), "a", metav1.GetOptions{}))
+		if err != nil {
+			return err
+		}
+		if got.Error == "" {
+			return fmt.Errorf("expected a validation error")
+		}
+		return nil
+	})
+	expectNetworks("a", "base")
+
+	// A network already defined by another source is not applied.
+	if _, err := resources.Create(context.TODO(), makeNetworksResource(t, "istio-system", "b", networksYAML("base", "3.3.3.3")), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus("b", NetworksStatus{Error: "networks [base] are already defined by another source"})
+	if got := w.Networks().Networks["base"].Gateways[0].GetAddress(); got != "1.1.1.1" {
+		t.Fatalf("expected the base network to be kept, got gateway %v", got)
+	}
+
+	// Resources outside of the Istio namespace are ignored.
+	if _, err := client.Dynamic().Resource(MeshNetworksResource).Namespace("tenant").Create(context.TODO(),
+		makeNetworksResource(t, "tenant", "c", networksYAML("c", "4.4.4.4")), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := resources.Delete(context.TODO(), "a", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectNetworks("base")
+}
+
+func getStatus(u *unstructured.Unstructured, err error) (NetworksStatus, error) {
+	got := NetworksStatus{}
+	if err != nil {
+		return got, err
+	}
+	status, _, err := unstructured.NestedMap(u.Object, "status")
+	if err != nil {
+		return got, err
+	}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(status, &got)
+	return got, err
+}
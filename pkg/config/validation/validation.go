@@ -50,7 +50,9 @@ import (
 	"istio.io/istio/pilot/pkg/util/constant"
 	"istio.io/istio/pilot/pkg/util/sets"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/consistenthash"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/faultinjection"
 	"istio.io/istio/pkg/config/gateway"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
@@ -670,6 +672,7 @@ var ValidateDestinationRule = registerValidateFunc("ValidateDestinationRule",
 		}
 
 		v = appendValidation(v, validateTrafficPolicy(rule.TrafficPolicy))
+		v = appendValidation(v, consistenthash.Validate(cfg.Annotations, rule))
 
 		for _, subset := range rule.Subsets {
 			if subset == nil {
@@ -767,6 +770,7 @@ var ValidateEnvoyFilter = registerValidateFunc("ValidateEnvoyFilter",
 			return nil, err
 		}
 
+		addedFilters := addedFilterNames(rule)
 		for _, cp := range rule.ConfigPatches {
 			if cp == nil {
 				errs = appendValidation(errs, fmt.Errorf("Envoy filter: null config patch")) // nolint: golint,stylecheck
@@ -840,6 +844,8 @@ var ValidateEnvoyFilter = registerValidateFunc("ValidateEnvoyFilter",
 
 							errs = appendValidation(errs, validateListenerMatchName(listenerMatch.FilterChain.Filter.GetName()))
 							errs = appendValidation(errs, validateListenerMatchName(listenerMatch.FilterChain.Filter.GetSubFilter().GetName()))
+							errs = appendValidation(errs, validateFilterMatchExists(listenerMatch.FilterChain.Filter.GetName(), addedFilters))
+							errs = appendValidation(errs, validateFilterMatchExists(listenerMatch.FilterChain.Filter.GetSubFilter().GetName(), addedFilters))
 						}
 					}
 				}
@@ -858,14 +864,34 @@ var ValidateEnvoyFilter = registerValidateFunc("ValidateEnvoyFilter",
 					}
 				}
 			}
+			// Check the struct against the Envoy schema, to report the path of the invalid fields. Unknown fields
+			// are only warnings, as we do not want to reject in case the proto is valid but our libraries are outdated
+			fieldErrs, err := xds.ValidatePatchValue(cp.ApplyTo, cp.Patch.Value)
+			if err != nil {
+				errs = appendValidation(errs, err)
+				continue
+			}
+			invalid, unknown := false, false
+			for _, fe := range fieldErrs {
+				if fe.Unknown {
+					unknown = true
+					errs = appendValidation(errs, WrapWarning(fmt.Errorf("Envoy filter: %v", fe))) // nolint: golint,stylecheck
+				} else {
+					invalid = true
+					errs = appendValidation(errs, fmt.Errorf("Envoy filter: %v", fe)) // nolint: golint,stylecheck
+				}
+			}
+			if invalid {
+				continue
+			}
 			// ensure that the struct is valid
 			if _, err := xds.BuildXDSObjectFromStruct(cp.ApplyTo, cp.Patch.Value, false); err != nil {
 				errs = appendValidation(errs, err)
 			} else {
 				// Run with strict validation, and emit warnings. This helps capture cases like unknown fields
-				// We do not want to reject in case the proto is valid but our libraries are outdated
+				// not already reported.
 				obj, err := xds.BuildXDSObjectFromStruct(cp.ApplyTo, cp.Patch.Value, true)
-				if err != nil {
+				if err != nil && !unknown {
 					errs = appendValidation(errs, WrapWarning(err))
 				}
 
@@ -886,6 +912,39 @@ func validateListenerMatchName(name string) error {
 	return nil
 }
 
+// addedFilterNames returns the names of the network and HTTP filters added by the patches of an EnvoyFilter.
+func addedFilterNames(rule *networking.EnvoyFilter) sets.Set {
+	added := sets.NewSet()
+	for _, cp := range rule.ConfigPatches {
+		if cp == nil || cp.Patch == nil || cp.Patch.Operation == networking.EnvoyFilter_Patch_REMOVE {
+			continue
+		}
+		if cp.ApplyTo != networking.EnvoyFilter_NETWORK_FILTER && cp.ApplyTo != networking.EnvoyFilter_HTTP_FILTER {
+			continue
+		}
+		if name := cp.Patch.Value.GetFields()["name"].GetStringValue(); name != "" {
+			added.Insert(name)
+		}
+	}
+	return added
+}
+
+// validateFilterMatchExists warns if a filter match targets a filter istiod does not generate, and which is not
+// added by the EnvoyFilter itself, as the patch would have no effect unless the filter is added elsewhere.
+func validateFilterMatchExists(name string, added sets.Set) error {
+	if name == "" {
+		return nil
+	}
+	if newName, f := xds.ReverseDeprecatedFilterNames[name]; f {
+		name = newName
+	}
+	if xds.GeneratedFilterNames.Contains(name) || added.Contains(name) {
+		return nil
+	}
+	return WrapWarning(fmt.Errorf("Envoy filter: filter %q is not generated by istiod; "+ // nolint: golint,stylecheck
+		"the patch only applies if another EnvoyFilter or a WasmPlugin adds it", name))
+}
+
 func recurseDeprecatedTypes(message protoreflect.Message) ([]string, error) {
 	var topError error
 	var deprecatedTypes []string
@@ -1319,7 +1378,7 @@ func validateConnectionPool(settings *networking.ConnectionPoolSettings) (errs e
 	return
 }
 
-func validateLoadBalancer(settings *networking.LoadBalancerSettings) (errs error) {
+func validateLoadBalancer(settings *networking.LoadBalancerSettings) (errs Validation) {
 	if settings == nil {
 		return
 	}
@@ -1331,16 +1390,17 @@ func validateLoadBalancer(settings *networking.LoadBalancerSettings) (errs error
 		httpCookie := consistentHash.GetHttpCookie()
 		if httpCookie != nil {
 			if httpCookie.Name == "" {
-				errs = appendErrors(errs, fmt.Errorf("name required for HttpCookie"))
+				errs = appendErrorf(errs, "name required for HttpCookie")
 			}
 			if httpCookie.Ttl == nil {
-				errs = appendErrors(errs, fmt.Errorf("ttl required for HttpCookie"))
+				errs = appendErrorf(errs, "ttl required for HttpCookie")
+			}
+			if httpCookie.Path != "" && !strings.HasPrefix(httpCookie.Path, "/") {
+				errs = appendWarningf(errs, "path for HttpCookie should start with '/'")
 			}
 		}
 	}
-	if err := validateLocalityLbSetting(settings.LocalityLbSetting); err != nil {
-		errs = multierror.Append(errs, err)
-	}
+	errs = appendValidation(errs, validateLocalityLbSetting(settings.LocalityLbSetting))
 	return
 }
 
@@ -1719,9 +1779,23 @@ func ValidateMeshConfigProxyConfig(config *meshconfig.ProxyConfig) (errs error)
 		errs = multierror.Append(errs, multierror.Prefix(err, "invalid status port:"))
 	}
 
+	if err := validateBootstrapCustomization(config.ProxyMetadata); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+
 	return
 }
 
+// validateBootstrapCustomization validates the bootstrap customization in the proxy metadata, if any.
+func validateBootstrapCustomization(metadata map[string]string) error {
+	v, f := metadata[xds.BootstrapCustomizationKey]
+	if !f {
+		return nil
+	}
+	_, err := xds.ParseBootstrapCustomization(v)
+	return err
+}
+
 func ValidateControlPlaneAuthPolicy(policy meshconfig.AuthenticationPolicy) error {
 	if policy == meshconfig.AuthenticationPolicy_NONE || policy == meshconfig.AuthenticationPolicy_MUTUAL_TLS {
 		return nil
@@ -1905,9 +1979,26 @@ var ValidateRequestAuthentication = registerValidateFunc("ValidateRequestAuthent
 		for _, rule := range in.JwtRules {
 			errs = appendErrors(errs, validateJwtRule(rule))
 		}
+		if cookies, f := cfg.Annotations[constants.JwtFromCookiesAnnotation]; f {
+			errs = appendErrors(errs, validateJwtCookies(cookies))
+		}
 		return nil, errs
 	})
 
+// cookieNameRegexp matches the cookie names allowed by RFC 6265, which are HTTP tokens.
+var cookieNameRegexp = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// validateJwtCookies validates the cookie names of the constants.JwtFromCookiesAnnotation annotation.
+func validateJwtCookies(cookies string) (errs error) {
+	for _, c := range strings.Split(cookies, ",") {
+		if c = strings.TrimSpace(c); !cookieNameRegexp.MatchString(c) {
+			errs = multierror.Append(errs, fmt.Errorf("invalid cookie name %q in annotation %s",
+				c, constants.JwtFromCookiesAnnotation))
+		}
+	}
+	return
+}
+
 func validateJwtRule(rule *security_beta.JWTRule) (errs error) {
 	if rule == nil {
 		return nil
@@ -2079,6 +2170,15 @@ var ValidateVirtualService = registerValidateFunc("ValidateVirtualService",
 			}
 			errs = appendValidation(errs, validateHTTPRoute(httpRoute, len(virtualService.Hosts) == 0))
 		}
+		errs = appendValidation(errs, faultinjection.Validate(cfg.Annotations, virtualService))
+		if targets, err := faultinjection.Parse(cfg.Annotations); err == nil {
+			for _, t := range targets {
+				errs = appendValidation(errs, validateHTTPFaultInjection(t.Fault))
+				for name, m := range t.Headers {
+					errs = appendValidation(errs, ValidateHTTPHeaderName(name), validateStringMatchRegexp(m, "headers"))
+				}
+			}
+		}
 		for _, tlsRoute := range virtualService.Tls {
 			errs = appendValidation(errs, validateTLSRoute(tlsRoute, virtualService))
 		}
@@ -3412,6 +3512,12 @@ func validateNetwork(network *meshconfig.Network) (errs error) {
 				if !features.ResolveHostnameGateways {
 					err := fmt.Errorf("%v (hostname is allowed if RESOLVE_HOSTNAME_GATEWAYS is enabled)", ipErr)
 					errs = multierror.Append(errs, err)
+				} else if isSRVName(g.Address) {
+					if srvErr := validateSRVName(g.Address); srvErr != nil {
+						errs = multierror.Append(errs, srvErr)
+					}
+					// the port of SRV gateways is learned from DNS
+					continue
 				} else if fqdnErr := ValidateFQDN(g.Address); fqdnErr != nil {
 					errs = multierror.Append(fmt.Errorf("%v is not a valid IP address or DNS name", g.Address))
 				}
@@ -3424,6 +3530,30 @@ func validateNetwork(network *meshconfig.Network) (errs error) {
 	return
 }
 
+// isSRVName returns whether the name is the name of SRV records, i.e. _service._proto.name.
+// This must agree with the check pilot uses to decide whether to resolve the gateway address as SRV records.
+func isSRVName(name string) bool {
+	parts := strings.SplitN(name, ".", 3)
+	return len(parts) == 3 && strings.HasPrefix(parts[0], "_") && strings.HasPrefix(parts[1], "_")
+}
+
+// validateSRVName validates the name of SRV records, i.e. _service._proto.name.
+func validateSRVName(name string) error {
+	parts := strings.SplitN(name, ".", 3)
+	if len(parts) != 3 {
+		return fmt.Errorf("%v is not a valid SRV name, expected _service._proto.name", name)
+	}
+	for _, label := range parts[:2] {
+		if !strings.HasPrefix(label, "_") || !labels.IsDNS1123Label(label[1:]) {
+			return fmt.Errorf("%v is not a valid SRV name, expected _service._proto.name", name)
+		}
+	}
+	if parts[1] != "_tcp" && parts[1] != "_udp" {
+		return fmt.Errorf("%v is not a valid SRV name, unsupported protocol %v", name, parts[1])
+	}
+	return ValidateFQDN(parts[2])
+}
+
 func (aae *AnalysisAwareError) Error() string {
 	return aae.Msg
 }
@@ -3441,6 +3571,7 @@ var ValidateProxyConfig = registerValidateFunc("ValidateProxyConfig",
 		errs = appendValidation(errs,
 			validateWorkloadSelector(spec.Selector),
 			validateConcurrency(spec.Concurrency.GetValue()),
+			WrapError(validateBootstrapCustomization(spec.EnvironmentVariables)),
 		)
 		return errs.Unwrap()
 	})
@@ -517,6 +517,13 @@ func TestValidateMeshConfigProxyConfig(t *testing.T) {
 			in:      valid,
 			isValid: true,
 		},
+		{
+			name: "bootstrap customization invalid",
+			in: modify(valid, func(c *meshconfig.ProxyConfig) {
+				c.ProxyMetadata = map[string]string{"ISTIO_BOOTSTRAP_CUSTOMIZATION": `{"admin": {}}`}
+			}),
+			isValid: false,
+		},
 		{
 			name:    "config path invalid",
 			in:      modify(valid, func(c *meshconfig.ProxyConfig) { c.ConfigPath = "" }),
@@ -3399,6 +3406,7 @@ func TestValidateLoadBalancer(t *testing.T) {
 		name  string
 		in    networking.LoadBalancerSettings
 		valid bool
+		warn  bool
 	}{
 		{
 			name: "valid load balancer with simple load balancing", in: networking.LoadBalancerSettings{
@@ -3457,12 +3465,35 @@ func TestValidateLoadBalancer(t *testing.T) {
 			},
 			valid: false,
 		},
+
+		{
+			name: "load balancer with consistentHash load balancing, relative cookie path", in: networking.LoadBalancerSettings{
+				LbPolicy: &networking.LoadBalancerSettings_ConsistentHash{
+					ConsistentHash: &networking.LoadBalancerSettings_ConsistentHashLB{
+						HashKey: &networking.LoadBalancerSettings_ConsistentHashLB_HttpCookie{
+							HttpCookie: &networking.LoadBalancerSettings_ConsistentHashLB_HTTPCookie{
+								Name: "test",
+								Ttl:  &duration,
+								Path: "session",
+							},
+						},
+					},
+				},
+			},
+			valid: true,
+			warn:  true,
+		},
 	}
 
 	for _, c := range cases {
-		if got := validateLoadBalancer(&c.in); (got == nil) != c.valid {
+		got := validateLoadBalancer(&c.in)
+		if (got.Err == nil) != c.valid {
 			t.Errorf("validateLoadBalancer failed on %v: got valid=%v but wanted valid=%v: %v",
-				c.name, got == nil, c.valid, got)
+				c.name, got.Err == nil, c.valid, got.Err)
+		}
+		if (got.Warning != nil) != c.warn {
+			t.Errorf("validateLoadBalancer failed on %v: got warn=%v but wanted warn=%v: %v",
+				c.name, got.Warning != nil, c.warn, got.Warning)
 		}
 	}
 }
@@ -3764,7 +3795,7 @@ func TestValidateEnvoyFilter(t *testing.T) {
 					},
 				},
 			},
-		}, error: `Envoy filter: json: cannot unmarshal bool into Go value of type string`},
+		}, error: `Envoy filter: name: expected a string, got a boolean`},
 		{name: "happy config", in: &networking.EnvoyFilter{
 			ConfigPatches: []*networking.EnvoyFilter_EnvoyConfigObjectPatch{
 				{
@@ -3903,6 +3934,159 @@ func TestValidateEnvoyFilter(t *testing.T) {
 				},
 			},
 		}, error: "", warning: "using deprecated filter name"},
+		{name: "patch value with invalid nested field", in: &networking.EnvoyFilter{
+			ConfigPatches: []*networking.EnvoyFilter_EnvoyConfigObjectPatch{
+				{
+					ApplyTo: networking.EnvoyFilter_HTTP_FILTER,
+					Match: &networking.EnvoyFilter_EnvoyConfigObjectMatch{
+						ObjectTypes: &networking.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+							Listener: &networking.EnvoyFilter_ListenerMatch{
+								FilterChain: &networking.EnvoyFilter_ListenerMatch_FilterChainMatch{
+									Filter: &networking.EnvoyFilter_ListenerMatch_FilterMatch{
+										Name: wellknown.HTTPConnectionManager,
+									},
+								},
+							},
+						},
+					},
+					Patch: &networking.EnvoyFilter_Patch{
+						Operation: networking.EnvoyFilter_Patch_INSERT_BEFORE,
+						Value: &types.Struct{
+							Fields: map[string]*types.Value{
+								"name": {Kind: &types.Value_StringValue{StringValue: "envoy.filters.http.lua"}},
+								"typed_config": {
+									Kind: &types.Value_StructValue{StructValue: &types.Struct{
+										Fields: map[string]*types.Value{
+											"@type": {
+												Kind: &types.Value_StringValue{
+													StringValue: "type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua",
+												},
+											},
+											"inline_code": {Kind: &types.Value_NumberValue{NumberValue: 1}},
+										},
+									}},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, error: "Envoy filter: typed_config.inline_code: expected a string, got a number", warning: ""},
+		{name: "patch value with unknown nested field", in: &networking.EnvoyFilter{
+			ConfigPatches: []*networking.EnvoyFilter_EnvoyConfigObjectPatch{
+				{
+					ApplyTo: networking.EnvoyFilter_HTTP_FILTER,
+					Match: &networking.EnvoyFilter_EnvoyConfigObjectMatch{
+						ObjectTypes: &networking.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+							Listener: &networking.EnvoyFilter_ListenerMatch{
+								FilterChain: &networking.EnvoyFilter_ListenerMatch_FilterChainMatch{
+									Filter: &networking.EnvoyFilter_ListenerMatch_FilterMatch{
+										Name: wellknown.HTTPConnectionManager,
+									},
+								},
+							},
+						},
+					},
+					Patch: &networking.EnvoyFilter_Patch{
+						Operation: networking.EnvoyFilter_Patch_INSERT_BEFORE,
+						Value: &types.Struct{
+							Fields: map[string]*types.Value{
+								"name": {Kind: &types.Value_StringValue{StringValue: "envoy.filters.http.lua"}},
+								"typed_config": {
+									Kind: &types.Value_StructValue{StructValue: &types.Struct{
+										Fields: map[string]*types.Value{
+											"@type": {
+												Kind: &types.Value_StringValue{
+													StringValue: "type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua",
+												},
+											},
+											"inline_code": {Kind: &types.Value_StringValue{StringValue: "function envoy_on_request(h) end"}},
+											"inlineCodez": {Kind: &types.Value_StringValue{StringValue: ""}},
+										},
+									}},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, error: "", warning: "Envoy filter: typed_config.inlineCodez: unknown field in envoy.extensions.filters.http.lua.v3.Lua"},
+		{name: "match on a filter istiod does not generate", in: &networking.EnvoyFilter{
+			ConfigPatches: []*networking.EnvoyFilter_EnvoyConfigObjectPatch{
+				{
+					ApplyTo: networking.EnvoyFilter_HTTP_FILTER,
+					Match: &networking.EnvoyFilter_EnvoyConfigObjectMatch{
+						ObjectTypes: &networking.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+							Listener: &networking.EnvoyFilter_ListenerMatch{
+								FilterChain: &networking.EnvoyFilter_ListenerMatch_FilterChainMatch{
+									Filter: &networking.EnvoyFilter_ListenerMatch_FilterMatch{
+										Name:      wellknown.HTTPConnectionManager,
+										SubFilter: &networking.EnvoyFilter_ListenerMatch_SubFilterMatch{Name: "envoy.filters.http.custom"},
+									},
+								},
+							},
+						},
+					},
+					Patch: &networking.EnvoyFilter_Patch{
+						Operation: networking.EnvoyFilter_Patch_INSERT_BEFORE,
+						Value: &types.Struct{
+							Fields: map[string]*types.Value{
+								"name": {Kind: &types.Value_StringValue{StringValue: "envoy.filters.http.lua"}},
+								"typed_config": {
+									Kind: &types.Value_StructValue{StructValue: &types.Struct{
+										Fields: map[string]*types.Value{
+											"@type": {
+												Kind: &types.Value_StringValue{
+													StringValue: "type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua",
+												},
+											},
+										},
+									}},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, error: "", warning: `Envoy filter: filter "envoy.filters.http.custom" is not generated by istiod`},
+		{name: "match on a filter added by the envoy filter", in: &networking.EnvoyFilter{
+			ConfigPatches: []*networking.EnvoyFilter_EnvoyConfigObjectPatch{
+				{
+					ApplyTo: networking.EnvoyFilter_HTTP_FILTER,
+					Match: &networking.EnvoyFilter_EnvoyConfigObjectMatch{
+						ObjectTypes: &networking.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+							Listener: &networking.EnvoyFilter_ListenerMatch{
+								FilterChain: &networking.EnvoyFilter_ListenerMatch_FilterChainMatch{
+									Filter: &networking.EnvoyFilter_ListenerMatch_FilterMatch{
+										Name:      wellknown.HTTPConnectionManager,
+										SubFilter: &networking.EnvoyFilter_ListenerMatch_SubFilterMatch{Name: "envoy.filters.http.lua"},
+									},
+								},
+							},
+						},
+					},
+					Patch: &networking.EnvoyFilter_Patch{
+						Operation: networking.EnvoyFilter_Patch_INSERT_BEFORE,
+						Value: &types.Struct{
+							Fields: map[string]*types.Value{
+								"name": {Kind: &types.Value_StringValue{StringValue: "envoy.filters.http.lua"}},
+								"typed_config": {
+									Kind: &types.Value_StructValue{StructValue: &types.Struct{
+										Fields: map[string]*types.Value{
+											"@type": {
+												Kind: &types.Value_StringValue{
+													StringValue: "type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua",
+												},
+											},
+										},
+									}},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, error: "", warning: ""},
 		// Regression test for https://github.com/golang/protobuf/issues/1374
 		{name: "duration marshal", in: &networking.EnvoyFilter{
 			ConfigPatches: []*networking.EnvoyFilter_EnvoyConfigObjectPatch{
@@ -6257,6 +6441,27 @@ func TestValidateRequestAuthentication(t *testing.T) {
 			in:          &security_beta.RequestAuthentication{},
 			valid:       false,
 		},
+		{
+			name:        "jwt from cookies",
+			configName:  someName,
+			annotations: map[string]string{constants.JwtFromCookiesAnnotation: "session, id_token"},
+			in:          &security_beta.RequestAuthentication{},
+			valid:       true,
+		},
+		{
+			name:        "jwt from invalid cookie",
+			configName:  someName,
+			annotations: map[string]string{constants.JwtFromCookiesAnnotation: "session,bad cookie"},
+			in:          &security_beta.RequestAuthentication{},
+			valid:       false,
+		},
+		{
+			name:        "jwt from empty cookie",
+			configName:  someName,
+			annotations: map[string]string{constants.JwtFromCookiesAnnotation: ""},
+			in:          &security_beta.RequestAuthentication{},
+			valid:       false,
+		},
 		{
 			name:       "default name with non empty selector",
 			configName: constants.DefaultAuthenticationPolicyName,
@@ -6701,6 +6906,41 @@ func TestValidateMeshNetworks(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			name: "Valid SRV Gateway Address",
+			mn: &meshconfig.MeshNetworks{
+				Networks: map[string]*meshconfig.Network{
+					"n1": {
+						Gateways: []*meshconfig.Network_IstioNetworkGateway{
+							{
+								Gw: &meshconfig.Network_IstioNetworkGateway_Address{
+									Address: "_istio-gw._tcp.example.com",
+								},
+							},
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "Invalid SRV Gateway Address",
+			mn: &meshconfig.MeshNetworks{
+				Networks: map[string]*meshconfig.Network{
+					"n1": {
+						Gateways: []*meshconfig.Network_IstioNetworkGateway{
+							{
+								Gw: &meshconfig.Network_IstioNetworkGateway_Address{
+									Address: "_istio-gw.example.com",
+								},
+								Port: 15443,
+							},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
 		{
 			name: "Invalid registry name",
 			mn: &meshconfig.MeshNetworks{
@@ -6938,6 +7178,38 @@ func TestValidateProxyConfig(t *testing.T) {
 		{name: "invalid concurrency", in: &networkingv1beta1.ProxyConfig{
 			Concurrency: &types.Int32Value{Value: -1},
 		}, out: "concurrency must be greater than or equal to 0"},
+		{name: "valid bootstrap customization", in: &networkingv1beta1.ProxyConfig{
+			EnvironmentVariables: map[string]string{
+				"ISTIO_BOOTSTRAP_CUSTOMIZATION": `
+overload_manager:
+  refresh_interval: 0.25s
+  resource_monitors:
+  - name: envoy.resource_monitors.fixed_heap
+    typed_config:
+      "@type": type.googleapis.com/envoy.extensions.resource_monitors.fixed_heap.v3.FixedHeapConfig
+      max_heap_size_bytes: 1073741824
+statsSinks:
+- name: envoy.stat_sinks.statsd
+  typed_config:
+    "@type": type.googleapis.com/envoy.config.metrics.v3.StatsdSink
+    tcp_cluster_name: statsd`,
+			},
+		}},
+		{name: "bootstrap customization of an unsupported section", in: &networkingv1beta1.ProxyConfig{
+			EnvironmentVariables: map[string]string{
+				"ISTIO_BOOTSTRAP_CUSTOMIZATION": `{"static_resources": {}}`,
+			},
+		}, out: `the "static_resources" section of the bootstrap can not be customized`},
+		{name: "bootstrap customization with an unknown field", in: &networkingv1beta1.ProxyConfig{
+			EnvironmentVariables: map[string]string{
+				"ISTIO_BOOTSTRAP_CUSTOMIZATION": `{"overload_manager": {"refresh": "1s"}}`,
+			},
+		}, out: `invalid bootstrap customization: unknown field "refresh"`},
+		{name: "bootstrap customization violating the schema", in: &networkingv1beta1.ProxyConfig{
+			EnvironmentVariables: map[string]string{
+				"ISTIO_BOOTSTRAP_CUSTOMIZATION": `{"tracing": {"http": {"name": ""}}}`,
+			},
+		}, out: "invalid bootstrap customization: invalid Bootstrap.Tracing"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,95 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package xds
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bootstrap "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+// BootstrapCustomizationKey is the key of the proxy metadata holding the customization of the Envoy bootstrap of
+// the proxy, in JSON or YAML. The customizations of the mesh config, the ProxyConfig resources and the
+// proxy.istio.io/config annotation are merged by section by istiod, and the result is passed to Envoy by the
+// istio-agent.
+const BootstrapCustomizationKey = "ISTIO_BOOTSTRAP_CUSTOMIZATION"
+
+// bootstrapSections are the sections of the Envoy bootstrap which can be customized, by their JSON names.
+var bootstrapSections = map[string]string{
+	"tracing":          "tracing",
+	"stats_sinks":      "stats_sinks",
+	"statsSinks":       "stats_sinks",
+	"overload_manager": "overload_manager",
+	"overloadManager":  "overload_manager",
+}
+
+// BootstrapCustomization is a customization of the Envoy bootstrap, with the JSON value of each customized
+// section by its name.
+type BootstrapCustomization map[string]json.RawMessage
+
+// ParseBootstrapCustomization parses a customization of the Envoy bootstrap in JSON or YAML. Only the tracing,
+// stats_sinks and overload_manager sections may be set, and they must be valid against the Envoy schema.
+func ParseBootstrapCustomization(s string) (BootstrapCustomization, error) {
+	js, err := yaml.YAMLToJSON([]byte(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bootstrap customization: %v", err)
+	}
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(js, &raw); err != nil {
+		return nil, fmt.Errorf("invalid bootstrap customization: %v", err)
+	}
+	out := make(BootstrapCustomization, len(raw))
+	for name, value := range raw {
+		section, f := bootstrapSections[name]
+		if !f {
+			return nil, fmt.Errorf("the %q section of the bootstrap can not be customized, only tracing, "+
+				"stats_sinks and overload_manager can", name)
+		}
+		if _, f := out[section]; f {
+			return nil, fmt.Errorf("the %q section of the bootstrap is set twice", section)
+		}
+		out[section] = value
+	}
+	b := &bootstrap.Bootstrap{}
+	if err := protomarshal.Unmarshal([]byte(out.String()), b); err != nil {
+		return nil, fmt.Errorf("invalid bootstrap customization: %v", err)
+	}
+	if err := b.ValidateAll(); err != nil {
+		return nil, fmt.Errorf("invalid bootstrap customization: %v", err)
+	}
+	return out, nil
+}
+
+// Merge returns the customization with its sections overridden by the sections set in o.
+func (c BootstrapCustomization) Merge(o BootstrapCustomization) BootstrapCustomization {
+	out := make(BootstrapCustomization, len(c)+len(o))
+	for section, value := range c {
+		out[section] = value
+	}
+	for section, value := range o {
+		out[section] = value
+	}
+	return out
+}
+
+// String returns the customization in JSON, with the sections in a stable order.
+func (c BootstrapCustomization) String() string {
+	// The sections are valid JSON values, and maps are marshaled with sorted keys.
+	js, _ := json.Marshal(map[string]json.RawMessage(c))
+	return string(js)
+}
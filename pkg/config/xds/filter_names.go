@@ -0,0 +1,50 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package xds
+
+import (
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+
+	"istio.io/istio/pilot/pkg/util/sets"
+)
+
+// GeneratedFilterNames are the names of the network and HTTP filters istiod generates. EnvoyFilter patches
+// matching other filters only apply if the filter is added by another EnvoyFilter or a WasmPlugin.
+var GeneratedFilterNames = sets.NewSet(
+	// Network filters
+	wellknown.HTTPConnectionManager,
+	wellknown.TCPProxy,
+	wellknown.RoleBasedAccessControl,
+	wellknown.ExternalAuthorization,
+	wellknown.MongoProxy,
+	wellknown.RedisProxy,
+	wellknown.MySQLProxy,
+	"istio.metadata_exchange",
+	"istio.stats",
+	"istio.stackdriver",
+	// HTTP filters
+	wellknown.Router,
+	wellknown.CORS,
+	wellknown.Fault,
+	wellknown.GRPCWeb,
+	wellknown.HTTPGRPCStats,
+	wellknown.HTTPRoleBasedAccessControl,
+	wellknown.HTTPExternalAuthorization,
+	"envoy.filters.http.jwt_authn",
+	"envoy.filters.http.local_ratelimit",
+	"envoy.filters.http.adaptive_concurrency",
+	"envoy.filters.http.admission_control",
+	"istio_authn",
+	"istio.alpn",
+)
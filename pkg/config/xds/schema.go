@@ -0,0 +1,316 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package xds
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+// FieldError is a field of an EnvoyFilter patch value which does not match the Envoy schema.
+type FieldError struct {
+	// Path to the field, such as filter_chains[0].filters[1].typed_config.stat_prefix.
+	Path string
+	// Unknown is set if the field, or the type of an Any, does not exist in the schema. This may be because
+	// the vendored Envoy protos are older than the proxy, unlike a value of the wrong type.
+	Unknown bool
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidatePatchValue checks the patch value of an EnvoyFilter against the schema of the Envoy object it
+// applies to, and returns the fields which are unknown or have a value of the wrong type.
+func ValidatePatchValue(applyTo networking.EnvoyFilter_ApplyTo, value *types.Struct) ([]FieldError, error) {
+	if value == nil {
+		return nil, nil
+	}
+	obj, err := newXDSObject(applyTo)
+	if err != nil {
+		return nil, err
+	}
+	v := &schemaValidator{}
+	v.message("", obj.ProtoReflect().Descriptor(), value)
+	return v.errs, nil
+}
+
+type schemaValidator struct {
+	errs []FieldError
+}
+
+func (v *schemaValidator) errorf(path string, format string, args ...interface{}) {
+	v.errs = append(v.errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *schemaValidator) unknownf(path string, format string, args ...interface{}) {
+	v.errs = append(v.errs, FieldError{Path: path, Unknown: true, Message: fmt.Sprintf(format, args...)})
+}
+
+// message checks the fields of a message, given as a JSON object.
+func (v *schemaValidator) message(path string, md protoreflect.MessageDescriptor, s *types.Struct) {
+	if md.FullName() == "google.protobuf.Any" {
+		v.any(path, s)
+		return
+	}
+	keys := make([]string, 0, len(s.Fields))
+	for k := range s.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	oneofs := map[protoreflect.FullName]string{}
+	for _, k := range keys {
+		fieldPath := joinPath(path, k)
+		fd := md.Fields().ByJSONName(k)
+		if fd == nil {
+			fd = md.Fields().ByName(protoreflect.Name(k))
+		}
+		if fd == nil {
+			v.unknownf(fieldPath, "unknown field in %s", md.FullName())
+			continue
+		}
+		val := s.Fields[k]
+		if _, null := val.GetKind().(*types.Value_NullValue); null {
+			continue
+		}
+		if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+			if other, f := oneofs[od.FullName()]; f {
+				v.errorf(fieldPath, "only one of %s and %s may be set", other, k)
+			}
+			oneofs[od.FullName()] = k
+		}
+		v.field(fieldPath, fd, val)
+	}
+}
+
+// any checks an Any, given as a JSON object with its type URL in "@type" and the fields of the type.
+func (v *schemaValidator) any(path string, s *types.Struct) {
+	typeURL := s.Fields["@type"].GetStringValue()
+	if typeURL == "" {
+		v.errorf(joinPath(path, "@type"), "missing type of Any")
+		return
+	}
+	mt, err := protoregistry.GlobalTypes.FindMessageByURL(typeURL)
+	if err != nil {
+		v.unknownf(joinPath(path, "@type"), "unknown type %s", typeURL)
+		return
+	}
+	md := mt.Descriptor()
+	if isWellKnownType(md) {
+		// Types with a special JSON mapping hold it in "value".
+		if val, f := s.Fields["value"]; f {
+			v.messageValue(joinPath(path, "value"), md, val)
+		}
+		return
+	}
+	fields := make(map[string]*types.Value, len(s.Fields))
+	for k, val := range s.Fields {
+		if k != "@type" {
+			fields[k] = val
+		}
+	}
+	v.message(path, md, &types.Struct{Fields: fields})
+}
+
+// field checks the value of a field, which may be a list or a map.
+func (v *schemaValidator) field(path string, fd protoreflect.FieldDescriptor, val *types.Value) {
+	switch {
+	case fd.IsList():
+		l, ok := val.GetKind().(*types.Value_ListValue)
+		if !ok {
+			v.errorf(path, "expected a list, got %s", describe(val))
+			return
+		}
+		for i, e := range l.ListValue.GetValues() {
+			v.singular(fmt.Sprintf("%s[%d]", path, i), fd, e)
+		}
+	case fd.IsMap():
+		m, ok := val.GetKind().(*types.Value_StructValue)
+		if !ok {
+			v.errorf(path, "expected an object, got %s", describe(val))
+			return
+		}
+		for k, e := range m.StructValue.GetFields() {
+			v.singular(fmt.Sprintf("%s[%s]", path, k), fd.MapValue(), e)
+		}
+	default:
+		v.singular(path, fd, val)
+	}
+}
+
+// singular checks a single value of a field.
+func (v *schemaValidator) singular(path string, fd protoreflect.FieldDescriptor, val *types.Value) {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		v.messageValue(path, fd.Message(), val)
+	case protoreflect.EnumKind:
+		v.enum(path, fd.Enum(), val)
+	default:
+		if msg := checkScalar(fd.Kind(), val); msg != "" {
+			v.errorf(path, "%s", msg)
+		}
+	}
+}
+
+func (v *schemaValidator) enum(path string, ed protoreflect.EnumDescriptor, val *types.Value) {
+	switch k := val.GetKind().(type) {
+	case *types.Value_StringValue:
+		if ed.Values().ByName(protoreflect.Name(k.StringValue)) == nil {
+			v.errorf(path, "unknown value %q of enum %s", k.StringValue, ed.FullName())
+		}
+	case *types.Value_NumberValue:
+		if k.NumberValue != math.Trunc(k.NumberValue) {
+			v.errorf(path, "expected an integer value of enum %s, got %v", ed.FullName(), k.NumberValue)
+		}
+	case *types.Value_NullValue:
+	default:
+		v.errorf(path, "expected a value of enum %s, got %s", ed.FullName(), describe(val))
+	}
+}
+
+// messageValue checks a message value, handling the types with a special JSON mapping.
+func (v *schemaValidator) messageValue(path string, md protoreflect.MessageDescriptor, val *types.Value) {
+	switch md.FullName() {
+	case "google.protobuf.Value":
+		return
+	case "google.protobuf.Struct":
+		if _, ok := val.GetKind().(*types.Value_StructValue); !ok {
+			v.errorf(path, "expected an object, got %s", describe(val))
+		}
+		return
+	case "google.protobuf.ListValue":
+		if _, ok := val.GetKind().(*types.Value_ListValue); !ok {
+			v.errorf(path, "expected a list, got %s", describe(val))
+		}
+		return
+	case "google.protobuf.Duration":
+		s, ok := val.GetKind().(*types.Value_StringValue)
+		if !ok {
+			v.errorf(path, "expected a duration, got %s", describe(val))
+		} else if _, err := time.ParseDuration(s.StringValue); err != nil {
+			v.errorf(path, "invalid duration %q", s.StringValue)
+		}
+		return
+	case "google.protobuf.Timestamp":
+		s, ok := val.GetKind().(*types.Value_StringValue)
+		if !ok {
+			v.errorf(path, "expected a timestamp, got %s", describe(val))
+		} else if _, err := time.Parse(time.RFC3339Nano, s.StringValue); err != nil {
+			v.errorf(path, "invalid timestamp %q", s.StringValue)
+		}
+		return
+	case "google.protobuf.FieldMask":
+		if _, ok := val.GetKind().(*types.Value_StringValue); !ok {
+			v.errorf(path, "expected a field mask, got %s", describe(val))
+		}
+		return
+	}
+	if isWrapperType(md) {
+		v.singular(path, md.Fields().ByName("value"), val)
+		return
+	}
+	s, ok := val.GetKind().(*types.Value_StructValue)
+	if !ok {
+		v.errorf(path, "expected an object of %s, got %s", md.FullName(), describe(val))
+		return
+	}
+	v.message(path, md, s.StructValue)
+}
+
+// checkScalar returns why val is not a valid JSON value for a scalar field of the kind, if it is not.
+func checkScalar(kind protoreflect.Kind, val *types.Value) string {
+	switch kind {
+	case protoreflect.BoolKind:
+		if _, ok := val.GetKind().(*types.Value_BoolValue); !ok {
+			return "expected a boolean, got " + describe(val)
+		}
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		if _, ok := val.GetKind().(*types.Value_StringValue); !ok {
+			return "expected a string, got " + describe(val)
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		switch k := val.GetKind().(type) {
+		case *types.Value_NumberValue:
+		case *types.Value_StringValue:
+			if _, err := strconv.ParseFloat(k.StringValue, 64); err != nil {
+				return fmt.Sprintf("invalid number %q", k.StringValue)
+			}
+		default:
+			return "expected a number, got " + describe(val)
+		}
+	default:
+		// All the integer kinds, which may be given as strings, as 64 bit integers are in JSON.
+		switch k := val.GetKind().(type) {
+		case *types.Value_NumberValue:
+			if k.NumberValue != math.Trunc(k.NumberValue) {
+				return fmt.Sprintf("expected an integer, got %v", k.NumberValue)
+			}
+		case *types.Value_StringValue:
+			if _, err := strconv.ParseFloat(k.StringValue, 64); err != nil {
+				return fmt.Sprintf("invalid integer %q", k.StringValue)
+			}
+		default:
+			return "expected an integer, got " + describe(val)
+		}
+	}
+	return ""
+}
+
+func isWrapperType(md protoreflect.MessageDescriptor) bool {
+	return md.ParentFile().Path() == "google/protobuf/wrappers.proto"
+}
+
+// isWellKnownType returns whether the message has a special JSON mapping, which is not an object of its fields.
+func isWellKnownType(md protoreflect.MessageDescriptor) bool {
+	switch md.FullName() {
+	case "google.protobuf.Value", "google.protobuf.Struct", "google.protobuf.ListValue",
+		"google.protobuf.Duration", "google.protobuf.Timestamp", "google.protobuf.FieldMask":
+		return true
+	}
+	return isWrapperType(md)
+}
+
+func describe(val *types.Value) string {
+	switch val.GetKind().(type) {
+	case *types.Value_BoolValue:
+		return "a boolean"
+	case *types.Value_StringValue:
+		return "a string"
+	case *types.Value_NumberValue:
+		return "a number"
+	case *types.Value_ListValue:
+		return "a list"
+	case *types.Value_StructValue:
+		return "an object"
+	default:
+		return "null"
+	}
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
@@ -39,6 +39,19 @@ func BuildXDSObjectFromStruct(applyTo networking.EnvoyFilter_ApplyTo, value *typ
 		// for remove ops
 		return nil, nil
 	}
+	obj, err := newXDSObject(applyTo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := GogoStructToMessage(value, obj, strict); err != nil {
+		return nil, fmt.Errorf("Envoy filter: %v", err) // nolint: golint,stylecheck
+	}
+	return obj, nil
+}
+
+// newXDSObject returns an empty Envoy object of the type an EnvoyFilter patch applies to.
+func newXDSObject(applyTo networking.EnvoyFilter_ApplyTo) (proto.Message, error) {
 	var obj proto.Message
 	switch applyTo {
 	case networking.EnvoyFilter_CLUSTER:
@@ -64,10 +77,6 @@ func BuildXDSObjectFromStruct(applyTo networking.EnvoyFilter_ApplyTo, value *typ
 	default:
 		return nil, fmt.Errorf("Envoy filter: unknown object type for applyTo %s", applyTo.String()) // nolint: golint,stylecheck
 	}
-
-	if err := GogoStructToMessage(value, obj, strict); err != nil {
-		return nil, fmt.Errorf("Envoy filter: %v", err) // nolint: golint,stylecheck
-	}
 	return obj, nil
 }
 
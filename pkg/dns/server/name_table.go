@@ -56,12 +56,21 @@ func BuildNameTable(cfg Config) *dnsProto.NameTable {
 			if addr := net.ParseIP(svcAddress); addr == nil {
 				continue
 			}
-			addressList = append(addressList, svcAddress)
+			// A dual-stack service resolves to its cluster IPs of both families.
+			for _, address := range svc.GetAllAddressesForProxy(cfg.Node) {
+				if net.ParseIP(address) != nil {
+					addressList = append(addressList, address)
+				}
+			}
 		} else {
 			// The IP will be unspecified here if its headless service or if the auto
 			// IP allocation logic for service entry was unable to allocate an IP.
 			if svc.Resolution == model.Passthrough && len(svc.Ports) > 0 {
 				for _, instance := range cfg.Push.ServiceInstancesByPort(svc, svc.Ports[0].Port, nil) {
+					// The secondary addresses of a workload are not routable, so they are not resolved.
+					if instance.Endpoint.Secondary {
+						continue
+					}
 					sameNetwork := cfg.Node.InNetwork(instance.Endpoint.Network)
 					sameCluster := cfg.Node.InCluster(instance.Endpoint.Locality.ClusterID)
 					// For all k8s headless services, populate the dns table with the endpoint IPs as k8s does.
@@ -112,7 +112,7 @@ func (e *envoy) UpdateConfig(config []byte) error {
 	return os.WriteFile(e.ConfigPath, config, 0o666)
 }
 
-func (e *envoy) args(fname string, epoch int, bootstrapConfig string) []string {
+func (e *envoy) args(fname string, epoch int, bootstrapConfig, bootstrapCustomization string) []string {
 	proxyLocalAddressType := "v4"
 	if network.IsIPv6Proxy(e.NodeIPs) {
 		proxyLocalAddressType = "v6"
@@ -146,7 +146,15 @@ func (e *envoy) args(fname string, epoch int, bootstrapConfig string) []string {
 
 	startupArgs = append(startupArgs, e.extraArgs...)
 
-	if bootstrapConfig != "" {
+	// The bootstrap customization is validated and merged by istiod, and replaces the raw bootstrap override.
+	if bootstrapCustomization != "" {
+		if bootstrapConfig != "" {
+			log.Warnf("Ignoring bootstrap override %s, as a bootstrap customization is set", bootstrapConfig)
+		}
+		startupArgs = append(startupArgs, "--config-yaml", bootstrapCustomization)
+	} else if bootstrapConfig != "" {
+		log.Warnf("Bootstrap override %s is deprecated, use the ISTIO_BOOTSTRAP_CUSTOMIZATION proxy metadata instead",
+			bootstrapConfig)
 		bytes, err := os.ReadFile(bootstrapConfig)
 		if err != nil {
 			log.Warnf("Failed to read bootstrap override %s, %v", bootstrapConfig, err)
@@ -164,9 +172,13 @@ func (e *envoy) args(fname string, epoch int, bootstrapConfig string) []string {
 
 var istioBootstrapOverrideVar = env.RegisterStringVar("ISTIO_BOOTSTRAP_OVERRIDE", "", "")
 
+var istioBootstrapCustomizationVar = env.RegisterStringVar("ISTIO_BOOTSTRAP_CUSTOMIZATION", "",
+	"The customization of the tracing, stats_sinks and overload_manager sections of the Envoy bootstrap, in JSON. "+
+		"It is set by the injector from the ISTIO_BOOTSTRAP_CUSTOMIZATION proxy metadata, merged across the proxy configs.")
+
 func (e *envoy) Run(epoch int, abort <-chan error) error {
 	// spin up a new Envoy process
-	args := e.args(e.ConfigPath, epoch, istioBootstrapOverrideVar.Get())
+	args := e.args(e.ConfigPath, epoch, istioBootstrapOverrideVar.Get(), istioBootstrapCustomizationVar.Get())
 	log.Infof("Envoy command: %v", args)
 
 	/* #nosec */
@@ -53,7 +53,7 @@ func TestEnvoyArgs(t *testing.T) {
 		t.Errorf("unexpected struct got\n%v\nwant\n%v", testProxy, test)
 	}
 
-	got := test.args("test.json", 5, "testdata/bootstrap.json")
+	got := test.args("test.json", 5, "testdata/bootstrap.json", "")
 	want := []string{
 		"-c", "test.json",
 		"--restart-epoch", "5",
@@ -74,6 +74,22 @@ func TestEnvoyArgs(t *testing.T) {
 	}
 }
 
+func TestEnvoyArgsBootstrapCustomization(t *testing.T) {
+	test := &envoy{ProxyConfig: ProxyConfig{NodeIPs: []string{"10.75.2.9"}}}
+	customization := `{"tracing":{}}`
+	// The bootstrap customization replaces the deprecated bootstrap override.
+	got := test.args("test.json", 0, "testdata/bootstrap.json", customization)
+	for i, arg := range got {
+		if arg == "--config-yaml" {
+			if got[i+1] != customization {
+				t.Fatalf("expected the bootstrap customization as --config-yaml, got %q", got[i+1])
+			}
+			return
+		}
+	}
+	t.Fatalf("missing --config-yaml in %v", got)
+}
+
 func TestSplitComponentLog(t *testing.T) {
 	cases := []struct {
 		input      string
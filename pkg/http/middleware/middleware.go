@@ -0,0 +1,134 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package middleware provides the middleware shared by the HTTP servers of istiod: request logging, panic
+// recovery, timeouts and per-handler metrics.
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"istio.io/pkg/log"
+	"istio.io/pkg/monitoring"
+)
+
+// SlowRequestThreshold is the duration above which requests are logged as warnings.
+const SlowRequestThreshold = time.Second
+
+var (
+	httpLog = log.RegisterScope("http", "istiod HTTP servers", 0)
+
+	serverTag  = monitoring.MustCreateLabel("server")
+	handlerTag = monitoring.MustCreateLabel("handler")
+	codeTag    = monitoring.MustCreateLabel("code")
+
+	requestDuration = monitoring.NewDistribution(
+		"istiod_http_request_duration_seconds",
+		"Time in seconds istiod takes to serve HTTP requests, by server and handler.",
+		[]float64{.005, .01, .05, .1, .5, 1, 3, 5, 10, 30},
+		monitoring.WithLabels(serverTag, handlerTag, codeTag),
+	)
+
+	panics = monitoring.NewSum(
+		"istiod_http_panics_total",
+		"Total number of HTTP requests whose handler panicked, by server and handler.",
+		monitoring.WithLabels(serverTag, handlerTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(requestDuration, panics)
+}
+
+// Wrap returns the handler of the mux for the server: requests are logged, and measured by the pattern of their
+// handler; handler panics are recovered with an internal server error; and requests taking longer than the timeout,
+// if set, are answered with a service unavailable error.
+func Wrap(server string, mux *http.ServeMux, timeout time.Duration) http.Handler {
+	var h http.Handler = mux
+	if timeout > 0 {
+		h = http.TimeoutHandler(h, timeout, fmt.Sprintf("request timed out after %v", timeout))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			pattern = "none"
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		serve(server, pattern, h, rec, r)
+		d := time.Since(start)
+
+		code := rec.code
+		if code == 0 {
+			code = http.StatusOK
+		}
+		requestDuration.With(serverTag.Value(server), handlerTag.Value(pattern), codeTag.Value(strconv.Itoa(code))).
+			Record(d.Seconds())
+		if d > SlowRequestThreshold {
+			httpLog.Warnf("slow request on %s server: %s %s (handler %s) returned %d in %v", server, r.Method, r.URL.Path, pattern, code, d)
+		} else if httpLog.DebugEnabled() {
+			httpLog.Debugf("request on %s server: %s %s (handler %s) returned %d in %v", server, r.Method, r.URL.Path, pattern, code, d)
+		}
+	})
+}
+
+// serve calls the handler, recovering its panics.
+func serve(server, pattern string, h http.Handler, w *statusRecorder, r *http.Request) {
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+		if p == http.ErrAbortHandler {
+			// The handler aborted the response on purpose.
+			panic(p)
+		}
+		panics.With(serverTag.Value(server), handlerTag.Value(pattern)).Increment()
+		httpLog.Errorf("panic serving %s %s on %s server: %v\n%s", r.Method, r.URL.Path, server, p, debug.Stack())
+		if w.code == 0 {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}()
+	h.ServeHTTP(w, r)
+}
+
+// statusRecorder records the status code of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.code == 0 {
+		s.code = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.code == 0 {
+		s.code = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+)
+
+func TestWrap(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/inject/", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	mux.HandleFunc("/panic", func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	h := Wrap("test", mux, 100*time.Millisecond)
+
+	cases := []struct {
+		path    string
+		code    int
+		handler string
+	}{
+		{path: "/ok", code: http.StatusOK, handler: "/ok"},
+		{path: "/inject/foo", code: http.StatusBadRequest, handler: "/inject/"},
+		{path: "/panic", code: http.StatusInternalServerError, handler: "/panic"},
+		{path: "/slow", code: http.StatusServiceUnavailable, handler: "/slow"},
+		{path: "/unknown", code: http.StatusNotFound, handler: "none"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.code {
+				t.Fatalf("expected code %d, got %d", tt.code, w.Code)
+			}
+			rows, err := view.RetrieveData("istiod_http_request_duration_seconds")
+			if err != nil {
+				t.Fatal(err)
+			}
+			found := false
+			for _, row := range rows {
+				tags := map[string]string{}
+				for _, tag := range row.Tags {
+					tags[tag.Key.Name()] = tag.Value
+				}
+				if tags["server"] == "test" && tags["handler"] == tt.handler && tags["code"] == strconv.Itoa(tt.code) {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("no request duration recorded for handler %s with code %d: %v", tt.handler, tt.code, rows)
+			}
+		})
+	}
+}
@@ -52,6 +52,7 @@ import (
 	gca "istio.io/istio/security/pkg/nodeagent/caclient/providers/google"
 	cas "istio.io/istio/security/pkg/nodeagent/caclient/providers/google-cas"
 	"istio.io/istio/security/pkg/nodeagent/sds"
+	"istio.io/istio/security/pkg/nodeagent/spire"
 	"istio.io/pkg/filewatcher"
 	"istio.io/pkg/log"
 )
@@ -108,7 +109,7 @@ type Agent struct {
 	envoyWaitCh chan error
 
 	sdsServer   *sds.Server
-	secretCache *cache.SecretManagerClient
+	secretCache workloadSecretManager
 
 	// Used when proxying envoy xds via istio-agent is enabled.
 	xdsProxy      *XdsProxy
@@ -121,6 +122,15 @@ type Agent struct {
 	wg sync.WaitGroup
 }
 
+// workloadSecretManager is the SecretManager serving workload secrets to the SDS server: either the
+// secret cache backed by a CA, or the SPIRE agent.
+type workloadSecretManager interface {
+	security.SecretManager
+	SetUpdateCallback(f func(resourceName string))
+	UpdateConfigTrustBundle(trustBundle []byte) error
+	Close()
+}
+
 // AgentOptions contains additional config for the agent, not included in ProxyConfig.
 // Most are from env variables ( still experimental ) or for testing only.
 // Eventually most non-test settings should graduate to ProxyConfig
@@ -157,6 +167,20 @@ type AgentOptions struct {
 	// Extra headers to add to the XDS connection.
 	XDSHeaders map[string]string
 
+	// XDSNodeCacheAddress is the address of the node-local xDS cache. If set, the XDS proxy connects to it instead
+	// of Istiod over mTLS, presenting the workload certificate, and the cache forwards the token to Istiod.
+	XDSNodeCacheAddress string
+	// XDSNodeCacheSAN is the name used to verify the certificate of the node cache.
+	XDSNodeCacheSAN string
+
+	// XDSAffinityRebalance enables honoring the affinity hints of Istiod: connections to an instance over its
+	// capacity are closed, in proportion of the excess, so that Envoy reconnects to another instance.
+	XDSAffinityRebalance bool
+
+	// XDSCompression is the compression, gzip or zstd, of the xDS payloads exchanged with Istiod. It is only used
+	// with the Istiod instances advertising it, which compress their responses the same way as the requests.
+	XDSCompression string
+
 	// Is the proxy an IPv6 proxy
 	IsIPv6 bool
 
@@ -406,10 +430,11 @@ func (a *Agent) Run(ctx context.Context) (func(), error) {
 		return nil, fmt.Errorf("failed to start local DNS server: %v", err)
 	}
 
-	a.secretCache, err = a.newSecretManager()
+	secretCache, err := a.newSecretManager()
 	if err != nil {
 		return nil, fmt.Errorf("failed to start workload secret manager %v", err)
 	}
+	a.secretCache = secretCache
 
 	a.sdsServer = sds.NewServer(a.secOpts, a.secretCache)
 	a.secretCache.SetUpdateCallback(a.sdsServer.UpdateCallback)
@@ -682,7 +707,12 @@ func getKeyCertInner(certPath string) (string, string) {
 }
 
 // newSecretManager creates the SecretManager for workload secrets
-func (a *Agent) newSecretManager() (*cache.SecretManagerClient, error) {
+func (a *Agent) newSecretManager() (workloadSecretManager, error) {
+	// If a SPIRE agent is configured, it manages the workload secrets and we do not have to connect to CA.
+	if a.secOpts.SpireSocketPath != "" {
+		log.Infof("Workload is using SPIRE agent %s. Skipping connecting to CA", a.secOpts.SpireSocketPath)
+		return spire.NewSecretManager(a.secOpts.SpireSocketPath, a.secOpts.SpireSVIDName, a.secOpts.SpireBundleName)
+	}
 	// If proxy is using file mounted certs, we do not have to connect to CA.
 	if a.secOpts.FileMountedCerts {
 		log.Info("Workload is using file mounted certificates. Skipping connecting to CA")
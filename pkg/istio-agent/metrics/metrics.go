@@ -55,6 +55,12 @@ var (
 		"The total number of Xds Proxy Responses",
 	)
 
+	// XdsProxyRebalances records total number of connections to Istiod closed to honor an affinity hint.
+	XdsProxyRebalances = monitoring.NewSum(
+		"xds_proxy_rebalances",
+		"The total number of connections to Istiod closed to rebalance them to another Istiod",
+	)
+
 	IstiodConnectionCancellations = istiodDisconnections.With(disconnectionTypeTag.Value(Cancel))
 	IstiodConnectionErrors        = istiodDisconnections.With(disconnectionTypeTag.Value(Error))
 	EnvoyConnectionCancellations  = envoyDisconnections.With(disconnectionTypeTag.Value(Cancel))
@@ -72,5 +78,6 @@ func init() {
 		IstiodConnectionErrors,
 		istiodDisconnections,
 		envoyDisconnections,
+		XdsProxyRebalances,
 	)
 }
@@ -31,6 +31,7 @@ import (
 	"time"
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	lrs "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v3"
 	gogotypes "github.com/gogo/protobuf/types"
 	"go.uber.org/atomic"
 	google_rpc "google.golang.org/genproto/googleapis/rpc/status"
@@ -120,6 +121,13 @@ type XdsProxy struct {
 	ecdsLastNonce         atomic.String
 	downstreamGrpcOptions []grpc.ServerOption
 	istiodSAN             string
+	affinityRebalance     bool
+	// rebalancedFrom is the Istiod instance the last rebalanced connection was closed on, excluded when reconnecting.
+	rebalancedFrom rebalanceExclusion
+	// xdsCompression is the configured compression of the xDS payloads exchanged with Istiod.
+	xdsCompression string
+	// xdsCompressionSupported records whether the last Istiod the agent connected to advertised xdsCompression.
+	xdsCompressionSupported atomic.Bool
 }
 
 var proxyLog = log.RegisterScope("xdsproxy", "XDS Proxy in Istio Agent", 0)
@@ -144,8 +152,12 @@ func initXdsProxy(ia *Agent) (*XdsProxy, error) {
 	}
 
 	cache := wasm.NewLocalFileCache(constants.IstioDataDir, wasm.DefaultWasmModulePurgeInterval, wasm.DefaultWasmModuleExpiry, ia.cfg.WASMInsecureRegistries)
+	istiodAddress := ia.proxyConfig.DiscoveryAddress
+	if ia.cfg.XDSNodeCacheAddress != "" {
+		istiodAddress = ia.cfg.XDSNodeCacheAddress
+	}
 	proxy := &XdsProxy{
-		istiodAddress:         ia.proxyConfig.DiscoveryAddress,
+		istiodAddress:         istiodAddress,
 		istiodSAN:             ia.cfg.IstiodSAN,
 		clusterID:             ia.secOpts.ClusterID,
 		handlers:              map[string]ResponseHandler{},
@@ -156,6 +168,8 @@ func initXdsProxy(ia *Agent) (*XdsProxy, error) {
 		wasmCache:             cache,
 		proxyAddresses:        ia.cfg.ProxyIPAddresses,
 		downstreamGrpcOptions: ia.cfg.DownstreamGrpcOptions,
+		affinityRebalance:     ia.cfg.XDSAffinityRebalance,
+		xdsCompression:        ia.cfg.XDSCompression,
 	}
 
 	if ia.localDNSServer != nil {
@@ -290,6 +304,10 @@ type ProxyConnection struct {
 	upstream           discovery.AggregatedDiscoveryService_StreamAggregatedResourcesClient
 	downstreamDeltas   discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer
 	upstreamDeltas     discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesClient
+	// connectedAt, lastAffinityCheck and affinityChecked are used to honor the affinity hints of Istiod.
+	connectedAt       time.Time
+	lastAffinityCheck time.Time
+	affinityChecked   bool
 }
 
 // sendRequest is a small wrapper around sending to con.requestsChan. This ensures that we do not
@@ -324,6 +342,7 @@ func (p *XdsProxy) handleStream(downstream adsStream) error {
 		responsesChan:   make(chan *discovery.DiscoveryResponse, 10),
 		stopChan:        make(chan struct{}),
 		downstream:      downstream,
+		connectedAt:     time.Now(),
 	}
 
 	p.RegisterStream(con)
@@ -359,8 +378,7 @@ func (p *XdsProxy) buildUpstreamConn(ctx context.Context) (*grpc.ClientConn, err
 }
 
 func (p *XdsProxy) HandleUpstream(ctx context.Context, con *ProxyConnection, xds discovery.AggregatedDiscoveryServiceClient) error {
-	upstream, err := xds.StreamAggregatedResources(ctx,
-		grpc.MaxCallRecvMsgSize(defaultClientMaxReceiveMessageSize))
+	upstream, err := xds.StreamAggregatedResources(ctx, p.upstreamCallOptions()...)
 	if err != nil {
 		// Envoy logs errors again, so no need to log beyond debug level
 		proxyLog.Debugf("failed to create upstream grpc client: %v", err)
@@ -372,6 +390,7 @@ func (p *XdsProxy) HandleUpstream(ctx context.Context, con *ProxyConnection, xds
 	defer proxyLog.Debugf("disconnected from XDS server: %s", p.istiodAddress)
 
 	con.upstream = upstream
+	go p.negotiateCompression(upstream)
 
 	// Handle upstream xds recv
 	go func() {
@@ -501,6 +520,7 @@ func (p *XdsProxy) handleUpstreamResponse(con *ProxyConnection) {
 			// TODO: separate upstream response handling from requests sending, which are both time costly
 			proxyLog.Debugf("response for type url %s", resp.TypeUrl)
 			metrics.XdsProxyResponses.Increment()
+			p.maybeRebalance(con, resp.ControlPlane)
 			if h, f := p.handlers[resp.TypeUrl]; f {
 				if len(resp.Resources) == 0 {
 					// Empty response, nothing to do
@@ -621,6 +641,7 @@ func (p *XdsProxy) initDownstreamServer() error {
 	opts = append(opts, istiogrpc.ServerOptions(istiokeepalive.DefaultOption())...)
 	grpcs := grpc.NewServer(opts...)
 	discovery.RegisterAggregatedDiscoveryServiceServer(grpcs, p)
+	lrs.RegisterLoadReportingServiceServer(grpcs, p)
 	reflection.Register(grpcs)
 	p.downstreamGrpcServer = grpcs
 	p.downstreamListener = l
@@ -660,6 +681,42 @@ func (p *XdsProxy) InitIstiodDialOptions(agent *Agent) error {
 	return nil
 }
 
+// upstreamCallOptions returns the call options of the upstream streams. The configured xDS compression is only used
+// once Istiod advertised it, so that the agent falls back to no compression with the instances not supporting it.
+func (p *XdsProxy) upstreamCallOptions() []grpc.CallOption {
+	opts := []grpc.CallOption{grpc.MaxCallRecvMsgSize(defaultClientMaxReceiveMessageSize)}
+	if p.xdsCompression != "" && p.xdsCompressionSupported.Load() {
+		opts = append(opts, grpc.UseCompressor(p.xdsCompression))
+	}
+	return opts
+}
+
+// negotiateCompression records whether the upstream advertises the configured xDS compression in the headers of the
+// stream. The compression of a stream cannot change, so it applies from the next connection, which Istiod
+// periodically recycles.
+func (p *XdsProxy) negotiateCompression(upstream grpc.ClientStream) {
+	if p.xdsCompression == "" {
+		return
+	}
+	// Blocks until the first response, and returns no headers if the stream ends without any, such as when an
+	// Istiod not supporting the compression rejects the compressed requests.
+	md, err := upstream.Header()
+	if err != nil {
+		return
+	}
+	supported := false
+	for _, v := range md.Get(istiogrpc.CompressionHeader) {
+		for _, c := range strings.Split(v, ",") {
+			if strings.TrimSpace(c) == p.xdsCompression {
+				supported = true
+			}
+		}
+	}
+	if p.xdsCompressionSupported.Swap(supported) != supported {
+		proxyLog.Infof("xDS compression %s supported by upstream %s: %v", p.xdsCompression, p.istiodAddress, supported)
+	}
+}
+
 func (p *XdsProxy) buildUpstreamClientDialOpts(sa *Agent) ([]grpc.DialOption, error) {
 	tlsOpts, err := p.getTLSDialOption(sa)
 	if err != nil {
@@ -673,12 +730,19 @@ func (p *XdsProxy) buildUpstreamClientDialOpts(sa *Agent) ([]grpc.DialOption, er
 
 	initialWindowSizeOption := grpc.WithInitialWindowSize(int32(defaultInitialWindowSize))
 	initialConnWindowSizeOption := grpc.WithInitialConnWindowSize(int32(defaultInitialConnWindowSize))
-	msgSizeOption := grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(defaultClientMaxReceiveMessageSize))
+	callOptions := []grpc.CallOption{grpc.MaxCallRecvMsgSize(defaultClientMaxReceiveMessageSize)}
+	if sa.cfg.XDSCompression != "" {
+		if err := istiogrpc.ValidateCompression(sa.cfg.XDSCompression); err != nil {
+			return nil, err
+		}
+		istiogrpc.SetMaxDecompressedSize(defaultClientMaxReceiveMessageSize)
+	}
+	callOption := grpc.WithDefaultCallOptions(callOptions...)
 	// Make sure the dial is blocking as we dont want any other operation to resume until the
 	// connection to upstream has been made.
 	dialOptions := []grpc.DialOption{
 		tlsOpts,
-		keepaliveOption, initialWindowSizeOption, initialConnWindowSizeOption, msgSizeOption,
+		keepaliveOption, initialWindowSizeOption, initialConnWindowSizeOption, callOption,
 	}
 
 	dialOptions = append(dialOptions, grpc.WithPerRPCCredentials(caclient.NewXDSTokenProvider(sa.secOpts)))
@@ -690,7 +754,7 @@ func (p *XdsProxy) buildUpstreamClientDialOpts(sa *Agent) ([]grpc.DialOption, er
 // Else it will return a one-way TLS related config with the assumption
 // that the consumer code will use tokens to authenticate the upstream.
 func (p *XdsProxy) getTLSDialOption(agent *Agent) (grpc.DialOption, error) {
-	if agent.proxyConfig.ControlPlaneAuthPolicy == meshconfig.AuthenticationPolicy_NONE {
+	if agent.proxyConfig.ControlPlaneAuthPolicy == meshconfig.AuthenticationPolicy_NONE && agent.cfg.XDSNodeCacheAddress == "" {
 		return grpc.WithTransportCredentials(insecure.NewCredentials()), nil
 	}
 	rootCert, err := p.getRootCertificate(agent)
@@ -700,6 +764,10 @@ func (p *XdsProxy) getTLSDialOption(agent *Agent) (grpc.DialOption, error) {
 
 	config := tls.Config{
 		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if agent.secOpts.SpireSocketPath != "" || agent.cfg.XDSNodeCacheAddress != "" {
+				// The node cache checks that the identity of the workload certificate matches the proxy.
+				return workloadClientCertificate(agent)
+			}
 			var certificate tls.Certificate
 			key, cert := agent.GetKeyCertsForXDS()
 			if key != "" && cert != "" {
@@ -727,6 +795,9 @@ func (p *XdsProxy) getTLSDialOption(agent *Agent) (grpc.DialOption, error) {
 	if p.istiodSAN != "" {
 		config.ServerName = p.istiodSAN
 	}
+	if agent.cfg.XDSNodeCacheAddress != "" {
+		config.ServerName = agent.cfg.XDSNodeCacheSAN
+	}
 	// TODO: if istiodSAN starts with spiffe://, use custom validation.
 
 	config.MinVersion = tls.VersionTLS12
@@ -734,6 +805,20 @@ func (p *XdsProxy) getTLSDialOption(agent *Agent) (grpc.DialOption, error) {
 	return grpc.WithTransportCredentials(transportCreds), nil
 }
 
+// workloadClientCertificate returns the workload certificate, issued by SPIRE or the Istio CA, so the upstream
+// authenticates the proxy with its workload identity.
+func workloadClientCertificate(agent *Agent) (*tls.Certificate, error) {
+	secret, err := agent.secretCache.GenerateSecret(security.WorkloadKeyCertResourceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workload certificate: %v", err)
+	}
+	certificate, err := tls.X509KeyPair(secret.CertificateChain, secret.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid workload certificate: %v", err)
+	}
+	return &certificate, nil
+}
+
 func (p *XdsProxy) getRootCertificate(agent *Agent) (*x509.CertPool, error) {
 	var certPool *x509.CertPool
 	var rootCert []byte
@@ -0,0 +1,136 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioagent
+
+import (
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/pkg/istio-agent/metrics"
+)
+
+// affinityRebalanceInterval is both the minimum age of a connection before it may be rebalanced, and the minimum
+// interval between two rebalancing decisions on a connection, so that the proxies do not flap between instances
+// before the load scores reported by Istiod reflect the previous moves.
+var affinityRebalanceInterval = time.Minute
+
+// affinityRebalanceAttempts is the number of times a reconnection landing on the Istiod instance the connection was
+// rebalanced away from is retried, so that a proxy does not loop when no other instance is reachable.
+const affinityRebalanceAttempts = 3
+
+// errRebalance terminates the upstream connection, which Envoy reconnects, usually to another Istiod.
+var errRebalance = status.Error(codes.Canceled, "rebalancing to another istiod")
+
+// rebalanceExclusion is the Istiod instance a connection was rebalanced away from. Istiod is dialed through its
+// Service, which may pick the same instance again: the new connection is then closed at its first response,
+// up to affinityRebalanceAttempts times within affinityRebalanceInterval.
+type rebalanceExclusion struct {
+	mu       sync.Mutex
+	id       string
+	until    time.Time
+	attempts int
+}
+
+// exclude records that the connection to the Istiod instance id was closed to rebalance it.
+func (e *rebalanceExclusion) exclude(id string, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.id = id
+	e.until = now.Add(affinityRebalanceInterval)
+	e.attempts = 0
+}
+
+// excluded returns whether a new connection to the Istiod instance id must be closed to reconnect elsewhere.
+func (e *rebalanceExclusion) excluded(id string, now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.id == "" {
+		return false
+	}
+	if e.id != id || now.After(e.until) || e.attempts >= affinityRebalanceAttempts {
+		// Reconnected to another instance, or gave up on finding one.
+		e.id = ""
+		return false
+	}
+	e.attempts++
+	return true
+}
+
+// controlPlaneAffinity is the part of the Istiod control plane identifier carrying the affinity hint.
+type controlPlaneAffinity struct {
+	ID           string
+	AffinityHint *struct {
+		Load      float64
+		Preferred bool
+	}
+}
+
+// maybeRebalance honors the affinity hint in the control plane identifier of a response. When the Istiod instance
+// is not preferred, the connection is closed with a probability of the share of the load in excess of the capacity,
+// so that across the proxies connected to the instance only the excess moves away.
+//
+// The first response of a connection is checked against the instance the previous connection was rebalanced away
+// from, and the connection is closed again if it landed on the same instance.
+func (p *XdsProxy) maybeRebalance(con *ProxyConnection, cp *core.ControlPlane) {
+	if !p.affinityRebalance || cp == nil {
+		return
+	}
+	id := controlPlaneAffinity{}
+	if err := json.Unmarshal([]byte(cp.Identifier), &id); err != nil {
+		return
+	}
+	now := time.Now()
+	if !con.affinityChecked {
+		con.affinityChecked = true
+		if p.rebalancedFrom.excluded(id.ID, now) {
+			proxyLog.Infof("upstream [%d] reconnected to istiod %s it was rebalanced away from, reconnecting", con.conID, id.ID)
+			terminateToRebalance(con)
+			return
+		}
+	}
+	if id.AffinityHint == nil {
+		return
+	}
+	if now.Sub(con.connectedAt) < affinityRebalanceInterval || now.Sub(con.lastAffinityCheck) < affinityRebalanceInterval {
+		return
+	}
+	con.lastAffinityCheck = now
+	hint := id.AffinityHint
+	if hint.Preferred || hint.Load <= 1 {
+		return
+	}
+	if rand.Float64() >= 1-1/hint.Load {
+		return
+	}
+	proxyLog.Infof("upstream [%d] rebalancing: istiod %s is over capacity with load %v", con.conID, id.ID, hint.Load)
+	metrics.XdsProxyRebalances.Increment()
+	p.rebalancedFrom.exclude(id.ID, now)
+	terminateToRebalance(con)
+}
+
+// terminateToRebalance closes the upstream connection, so that Envoy reconnects.
+func terminateToRebalance(con *ProxyConnection) {
+	select {
+	case con.upstreamError <- errRebalance:
+	default:
+		// The connection is already terminating.
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istioagent
+
+import (
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+)
+
+func TestMaybeRebalance(t *testing.T) {
+	// A huge load makes the rebalancing of a connection to a non preferred instance practically certain.
+	overloaded := &core.ControlPlane{Identifier: `{"Component":"istiod","ID":"istiod-1","AffinityHint":{"Load":1e9,"Preferred":false}}`}
+	cases := []struct {
+		name      string
+		enabled   bool
+		age       time.Duration
+		checked   time.Duration
+		cp        *core.ControlPlane
+		rebalance bool
+	}{
+		{"disabled", false, time.Hour, time.Hour, overloaded, false},
+		{"overloaded", true, time.Hour, time.Hour, overloaded, true},
+		{"young connection", true, time.Second, time.Hour, overloaded, false},
+		{"recently checked", true, time.Hour, time.Second, overloaded, false},
+		{"no hint", true, time.Hour, time.Hour, &core.ControlPlane{Identifier: `{"Component":"istiod","ID":"istiod-1"}`}, false},
+		{
+			"preferred", true, time.Hour, time.Hour,
+			&core.ControlPlane{Identifier: `{"Component":"istiod","ID":"istiod-1","AffinityHint":{"Load":0.5,"Preferred":true}}`}, false,
+		},
+		{"no control plane", true, time.Hour, time.Hour, nil, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &XdsProxy{affinityRebalance: tt.enabled}
+			now := time.Now()
+			con := &ProxyConnection{
+				upstreamError:     make(chan error, 2),
+				connectedAt:       now.Add(-tt.age),
+				lastAffinityCheck: now.Add(-tt.checked),
+			}
+			p.maybeRebalance(con, tt.cp)
+			select {
+			case err := <-con.upstreamError:
+				if !tt.rebalance {
+					t.Fatalf("unexpected rebalance: %v", err)
+				}
+			default:
+				if tt.rebalance {
+					t.Fatal("expected the connection to be rebalanced")
+				}
+			}
+		})
+	}
+}
+
+func TestRebalanceExcludesCurrentInstance(t *testing.T) {
+	overloaded := &core.ControlPlane{Identifier: `{"Component":"istiod","ID":"istiod-1","AffinityHint":{"Load":1e9,"Preferred":false}}`}
+	other := &core.ControlPlane{Identifier: `{"Component":"istiod","ID":"istiod-2","AffinityHint":{"Load":0.5,"Preferred":true}}`}
+	p := &XdsProxy{affinityRebalance: true}
+	connect := func(age time.Duration, cp *core.ControlPlane) bool {
+		con := &ProxyConnection{
+			upstreamError: make(chan error, 2),
+			connectedAt:   time.Now().Add(-age),
+		}
+		p.maybeRebalance(con, cp)
+		select {
+		case <-con.upstreamError:
+			return true
+		default:
+			return false
+		}
+	}
+	if !connect(time.Hour, overloaded) {
+		t.Fatal("expected the connection to be rebalanced")
+	}
+	// Reconnections landing on the same instance are closed, until the attempts are exhausted.
+	for i := 0; i < affinityRebalanceAttempts; i++ {
+		if !connect(0, overloaded) {
+			t.Fatalf("attempt %d: expected the reconnection to the same istiod to be closed", i)
+		}
+	}
+	if connect(0, overloaded) {
+		t.Fatal("expected the reconnection to be kept once the attempts are exhausted")
+	}
+
+	if !connect(time.Hour, overloaded) {
+		t.Fatal("expected the connection to be rebalanced")
+	}
+	if connect(0, other) {
+		t.Fatal("expected the reconnection to another istiod to be kept")
+	}
+	if connect(0, overloaded) {
+		t.Fatal("expected the exclusion to be cleared once connected to another istiod")
+	}
+}
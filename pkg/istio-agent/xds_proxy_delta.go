@@ -20,7 +20,6 @@ import (
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	google_rpc "google.golang.org/genproto/googleapis/rpc/status"
-	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	any "google.golang.org/protobuf/types/known/anypb"
@@ -55,6 +54,7 @@ func (p *XdsProxy) DeltaAggregatedResources(downstream discovery.AggregatedDisco
 		deltaResponsesChan: make(chan *discovery.DeltaDiscoveryResponse, 10),
 		stopChan:           make(chan struct{}),
 		downstreamDeltas:   downstream,
+		connectedAt:        time.Now(),
 	}
 	p.RegisterStream(con)
 	defer p.UnregisterStream(con)
@@ -121,8 +121,7 @@ func (p *XdsProxy) DeltaAggregatedResources(downstream discovery.AggregatedDisco
 }
 
 func (p *XdsProxy) HandleDeltaUpstream(ctx context.Context, con *ProxyConnection, xds discovery.AggregatedDiscoveryServiceClient) error {
-	deltaUpstream, err := xds.DeltaAggregatedResources(ctx,
-		grpc.MaxCallRecvMsgSize(defaultClientMaxReceiveMessageSize))
+	deltaUpstream, err := xds.DeltaAggregatedResources(ctx, p.upstreamCallOptions()...)
 	if err != nil {
 		// Envoy logs errors again, so no need to log beyond debug level
 		proxyLog.Debugf("failed to create delta upstream grpc client: %v", err)
@@ -134,6 +133,7 @@ func (p *XdsProxy) HandleDeltaUpstream(ctx context.Context, con *ProxyConnection
 	defer proxyLog.Debugf("disconnected from delta XDS server: %s", p.istiodAddress)
 
 	con.upstreamDeltas = deltaUpstream
+	go p.negotiateCompression(deltaUpstream)
 
 	// handle responses from istiod
 	go func() {
@@ -217,6 +217,7 @@ func (p *XdsProxy) handleUpstreamDeltaResponse(con *ProxyConnection) {
 			// TODO: separate upstream response handling from requests sending, which are both time costly
 			proxyLog.Debugf("response for type url %s", resp.TypeUrl)
 			metrics.XdsProxyResponses.Increment()
+			p.maybeRebalance(con, resp.ControlPlane)
 			if h, f := p.handlers[resp.TypeUrl]; f {
 				if len(resp.Resources) == 0 {
 					// Empty response, nothing to do
@@ -0,0 +1,88 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package istioagent
+
+import (
+	"context"
+	"time"
+
+	lrs "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v3"
+	"google.golang.org/grpc/metadata"
+
+	istiogrpc "istio.io/istio/pilot/pkg/grpc"
+	"istio.io/istio/pkg/istio-agent/metrics"
+)
+
+// StreamLoadStats forwards the load reports of Envoy to the upstream LRS server. As for the xDS streams, a new
+// upstream stream is established for every stream from Envoy.
+func (p *XdsProxy) StreamLoadStats(downstream lrs.LoadReportingService_StreamLoadStatsServer) error {
+	proxyLog.Debugf("accepted LRS connection from Envoy, forwarding to upstream LRS server")
+
+	dialCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	upstreamConn, err := p.buildUpstreamConn(dialCtx)
+	if err != nil {
+		proxyLog.Errorf("failed to connect to upstream %s: %v", p.istiodAddress, err)
+		metrics.IstiodConnectionFailures.Increment()
+		return err
+	}
+	defer upstreamConn.Close()
+
+	// The upstream stream is canceled when the downstream stream terminates.
+	ctx := metadata.AppendToOutgoingContext(downstream.Context(), "ClusterID", p.clusterID)
+	for k, v := range p.xdsHeaders {
+		ctx = metadata.AppendToOutgoingContext(ctx, k, v)
+	}
+	upstream, err := lrs.NewLoadReportingServiceClient(upstreamConn).StreamLoadStats(ctx)
+	if err != nil {
+		proxyLog.Debugf("failed to create upstream LRS stream: %v", err)
+		return err
+	}
+
+	errCh := make(chan error, 2)
+	go func() {
+		for {
+			req, err := downstream.Recv()
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if err := upstream.Send(req); err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+	go func() {
+		for {
+			resp, err := upstream.Recv()
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if err := downstream.Send(resp); err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+
+	err = <-errCh
+	if istiogrpc.IsExpectedGRPCError(err) {
+		proxyLog.Debugf("LRS stream terminated: %v", err)
+		return nil
+	}
+	proxyLog.Warnf("LRS stream terminated with unexpected error: %v", err)
+	return err
+}
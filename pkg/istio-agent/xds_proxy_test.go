@@ -38,6 +38,7 @@ import (
 
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/features"
+	istiogrpc "istio.io/istio/pilot/pkg/grpc"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/model/status"
 	"istio.io/istio/pilot/pkg/networking/util"
@@ -102,6 +103,37 @@ func TestXdsProxyBasicFlow(t *testing.T) {
 	})
 }
 
+// Validates that the xDS compression is only used once Istiod advertised it.
+func TestXdsProxyCompressionNegotiation(t *testing.T) {
+	proxy := setupXdsProxy(t)
+	proxy.xdsCompression = istiogrpc.CompressionZstd
+	if got := len(proxy.upstreamCallOptions()); got != 1 {
+		t.Fatalf("expected no compression before negotiation, got %d call options", got)
+	}
+	f := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	setDialOptions(proxy, f.BufListener)
+	conn := setupDownstreamConnection(t, proxy)
+	downstream := stream(t, conn)
+	sendDownstreamWithNode(t, downstream, model.NodeMetadata{
+		Namespace:   "default",
+		InstanceIPs: []string{"1.1.1.1"},
+	})
+	retry.UntilOrFail(t, proxy.xdsCompressionSupported.Load, retry.Timeout(time.Second), retry.Delay(time.Millisecond))
+	if got := len(proxy.upstreamCallOptions()); got != 2 {
+		t.Fatalf("expected the compression after negotiation, got %d call options", got)
+	}
+
+	// An upstream not advertising the compression, reached on the next connection, falls back to no compression.
+	downstream.CloseSend()
+	mock := xdstest.NewMockServer(t)
+	setDialOptions(proxy, mock.Listener)
+	downstream = stream(t, conn)
+	sendDownstreamWithoutResponse(t, downstream)
+	mock.SendResponse(&discovery.DiscoveryResponse{TypeUrl: v3.ClusterType})
+	retry.UntilOrFail(t, func() bool { return !proxy.xdsCompressionSupported.Load() },
+		retry.Timeout(time.Second), retry.Delay(time.Millisecond))
+}
+
 // Validates the proxy health checking updates
 func TestXdsProxyHealthCheck(t *testing.T) {
 	healthy := &discovery.DiscoveryRequest{TypeUrl: v3.HealthInfoType}
@@ -0,0 +1,384 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package istiodctl provides a typed client for the debug and admin endpoints of istiod, such as
+// /debug/syncz and /debug/configz, and for the logging scopes of its ControlZ interface.
+//
+// The debug endpoints are read with the generated ADS client, as the istio.io/debug type of the
+// xDS-over-TLS server of istiod, so that the token authenticating the client is never sent in clear.
+package istiodctl
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/resource"
+	"istio.io/istio/pkg/security"
+)
+
+const (
+	// clusterIDHeader is the header holding the cluster of the token, used by istiod to validate it.
+	clusterIDHeader = "clusterid"
+
+	// defaultNamespace is the default namespace of the identity of the client.
+	defaultNamespace = "istio-system"
+)
+
+// Options configure a Client.
+type Options struct {
+	// XDSAddress is the host:port of the xDS-over-TLS server of istiod, serving the debug endpoints, such as
+	// istiod.istio-system.svc:15012.
+	XDSAddress string
+
+	// TLSConfig verifies the certificate of istiod. TLS is always used. Defaults to the system roots, with the
+	// host of XDSAddress as server name.
+	TLSConfig *tls.Config
+
+	// ControlZAddress is the host:port of the ControlZ interface, used to manage log scopes, such as
+	// localhost:9876. Log scopes are not available if unset.
+	ControlZAddress string
+
+	// Token returns the JWT used to authenticate to the debug endpoints. It is called for each request,
+	// so that rotated tokens are picked up. The debug endpoints are restricted to the identities of the
+	// istiod namespace.
+	Token func() (string, error)
+
+	// ClusterID is the cluster of the Token, if it is not the cluster of istiod.
+	ClusterID cluster.ID
+
+	// Namespace is the namespace of the identity of the Token, which istiod checks against the namespace the
+	// client connects as. Defaults to istio-system.
+	Namespace string
+
+	// DialOptions are added to the options used to dial XDSAddress.
+	DialOptions []grpc.DialOption
+
+	// HTTPClient is used to send requests to ControlZ. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Client is a typed client for the debug and admin endpoints of a single istiod instance.
+type Client struct {
+	opts       Options
+	httpClient *http.Client
+}
+
+// NewClient returns a Client with the given options.
+func NewClient(opts Options) *Client {
+	c := &Client{
+		opts:       opts,
+		httpClient: opts.HTTPClient,
+	}
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	return c
+}
+
+// TokenFromFile returns a token function which reads the token from path, such as the token of a mounted
+// service account.
+func TokenFromFile(path string) func() (string, error) {
+	return func() (string, error) {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token: %v", err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+}
+
+// StatusError is returned when ControlZ responds with an unexpected status code.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Message)
+}
+
+// Syncz returns the synchronization status of the proxies connected to istiod.
+func (c *Client) Syncz(ctx context.Context) ([]xds.SyncStatus, error) {
+	var out []xds.SyncStatus
+	if err := c.debugJSON(ctx, "syncz", &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Configz returns the configs istiod has, across all types. Configs of a type unknown to this
+// client, such as a type added in a newer istiod, are skipped.
+func (c *Client) Configz(ctx context.Context) ([]config.Config, error) {
+	var objs []crd.IstioKind
+	if err := c.debugJSON(ctx, "configz", &objs); err != nil {
+		return nil, err
+	}
+	var configs []config.Config
+	for i := range objs {
+		obj := &objs[i]
+		gvk := obj.GroupVersionKind()
+		s, exists := collections.All.FindByGroupVersionKind(resource.FromKubernetesGVK(&gvk))
+		if !exists {
+			continue
+		}
+		cfg, err := crd.ConvertObject(s, obj, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s %s/%s: %v", obj.Kind, obj.Namespace, obj.Name, err)
+		}
+		configs = append(configs, *cfg)
+	}
+	return configs, nil
+}
+
+// Push triggers a full push to all the proxies connected to istiod, and returns their number, not counting the
+// connection of the client.
+func (c *Client) Push(ctx context.Context) (int, error) {
+	b, err := c.debug(ctx, "adsz?push=true")
+	if err != nil {
+		return 0, err
+	}
+	var pushed int
+	if _, err := fmt.Sscanf(string(b), "Pushed to %d servers", &pushed); err != nil {
+		return 0, fmt.Errorf("unexpected push response %q", string(b))
+	}
+	return pushed - 1, nil
+}
+
+// debugJSON reads a debug endpoint and decodes its JSON response into out.
+func (c *Client) debugJSON(ctx context.Context, endpoint string, out interface{}) error {
+	b, err := c.debug(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", endpoint, err)
+	}
+	return nil
+}
+
+// debug reads a debug endpoint, with its query, over an authenticated ADS stream.
+func (c *Client) debug(ctx context.Context, endpoint string) ([]byte, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := discovery.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", c.opts.XDSAddress, err)
+	}
+	err = stream.Send(&discovery.DiscoveryRequest{
+		Node:          c.node(),
+		TypeUrl:       xds.TypeDebug,
+		ResourceNames: []string{endpoint},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request %s: %v", endpoint, err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", endpoint, err)
+	}
+	if resp.TypeUrl != xds.TypeDebug || len(resp.Resources) != 1 {
+		return nil, fmt.Errorf("unexpected response for %s: type %s with %d resources", endpoint, resp.TypeUrl, len(resp.Resources))
+	}
+	return resp.Resources[0].GetValue(), nil
+}
+
+// node identifies the client to istiod, as a proxy in the namespace of its identity.
+func (c *Client) node() *core.Node {
+	ns := c.opts.Namespace
+	if ns == "" {
+		ns = defaultNamespace
+	}
+	return &core.Node{
+		Id:       fmt.Sprintf("%s~0.0.0.0~istiodctl.%s~%s.svc.cluster.local", model.SidecarProxy, ns, ns),
+		Metadata: model.NodeMetadata{Namespace: ns}.ToStruct(),
+	}
+}
+
+func (c *Client) dial(ctx context.Context) (*grpc.ClientConn, error) {
+	if c.opts.XDSAddress == "" {
+		return nil, fmt.Errorf("no xDS address configured")
+	}
+	tlsConfig := c.opts.TLSConfig
+	if tlsConfig == nil {
+		host, _, err := net.SplitHostPort(c.opts.XDSAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid xDS address %q: %v", c.opts.XDSAddress, err)
+		}
+		tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	if c.opts.Token != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(&tokenCredentials{token: c.opts.Token, clusterID: c.opts.ClusterID}))
+	}
+	opts = append(opts, c.opts.DialOptions...)
+	return grpc.DialContext(ctx, c.opts.XDSAddress, opts...)
+}
+
+// tokenCredentials sends the token of the client with each request. It requires TLS, so that grpc refuses to
+// send the token over a plaintext connection.
+type tokenCredentials struct {
+	token     func() (string, error)
+	clusterID cluster.ID
+}
+
+var _ credentials.PerRPCCredentials = &tokenCredentials{}
+
+func (t *tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	token, err := t.token()
+	if err != nil {
+		return nil, err
+	}
+	md := map[string]string{"authorization": security.BearerTokenPrefix + token}
+	if t.clusterID != "" {
+		md[clusterIDHeader] = t.clusterID.String()
+	}
+	return md, nil
+}
+
+func (t *tokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+// do sends req, and returns an error if the response status is not want.
+func (c *Client) do(req *http.Request, want int) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != want {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
+
+// ScopeInfo is the state of a logging scope, as reported by ControlZ.
+type ScopeInfo struct {
+	Name            string `json:"name"`
+	Description     string `json:"description,omitempty"`
+	OutputLevel     string `json:"output_level,omitempty"`
+	StackTraceLevel string `json:"stack_trace_level,omitempty"`
+	LogCallers      bool   `json:"log_callers,omitempty"`
+}
+
+// Scopes returns all the logging scopes.
+func (c *Client) Scopes(ctx context.Context) ([]*ScopeInfo, error) {
+	var scopes []*ScopeInfo
+	if err := c.scopeJSON(ctx, "", &scopes); err != nil {
+		return nil, err
+	}
+	return scopes, nil
+}
+
+// Scope returns a single logging scope.
+func (c *Client) Scope(ctx context.Context, name string) (*ScopeInfo, error) {
+	scope := &ScopeInfo{}
+	if err := c.scopeJSON(ctx, name, scope); err != nil {
+		return nil, err
+	}
+	return scope, nil
+}
+
+// SetScope updates the levels of a logging scope. Empty levels are left unchanged.
+func (c *Client) SetScope(ctx context.Context, scope *ScopeInfo) error {
+	b, err := json.Marshal(scope)
+	if err != nil {
+		return fmt.Errorf("cannot serialize scope %+v", *scope)
+	}
+	req, err := c.scopeRequest(ctx, http.MethodPut, scope.Name, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req, http.StatusAccepted)
+	if err != nil {
+		return fmt.Errorf("cannot update scope %s: %v", scope.Name, err)
+	}
+	return resp.Body.Close()
+}
+
+// SetScopes updates multiple logging scopes, concurrently.
+func (c *Client) SetScopes(ctx context.Context, scopes []*ScopeInfo) error {
+	errs := make([]error, len(scopes))
+	var wg sync.WaitGroup
+	for i, scope := range scopes {
+		wg.Add(1)
+		go func(i int, scope *ScopeInfo) {
+			defer wg.Done()
+			errs[i] = c.SetScope(ctx, scope)
+		}(i, scope)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) scopeJSON(ctx context.Context, name string, out interface{}) error {
+	req, err := c.scopeRequest(ctx, http.MethodGet, name, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("cannot deserialize scopes: %v", err)
+	}
+	return nil
+}
+
+// scopeRequest builds a request to the ControlZ scopes, or to a single scope if name is set. ControlZ is not
+// authenticated, as it only listens on localhost by default.
+func (c *Client) scopeRequest(ctx context.Context, method, name string, body io.Reader) (*http.Request, error) {
+	if c.opts.ControlZAddress == "" {
+		return nil, fmt.Errorf("no ControlZ address configured")
+	}
+	u := url.URL{Scheme: "http", Host: c.opts.ControlZAddress, Path: "/scopej/" + name}
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
+}
@@ -0,0 +1,276 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package istiodctl
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/security/pkg/pki/util"
+	"istio.io/pkg/ctrlz"
+	"istio.io/pkg/log"
+)
+
+const configs = `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: se
+  namespace: default
+spec:
+  hosts: [a.example.com]
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: DNS
+`
+
+const (
+	adminToken    = "admin"
+	istiodHost    = "istiod.istio-system.svc"
+	remoteCluster = "remote"
+)
+
+// tokenAuthenticator authenticates the admin token as an identity of the istio-system namespace, and
+// connections without token as the default namespace, as the proxies of the tests.
+type tokenAuthenticator struct {
+	clusters chan string
+}
+
+func (a *tokenAuthenticator) Authenticate(ctx context.Context) (*security.Caller, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if c := md.Get(clusterIDHeader); len(c) > 0 {
+		select {
+		case a.clusters <- c[0]:
+		default:
+		}
+	}
+	token, err := security.ExtractBearerToken(ctx)
+	if err != nil {
+		return &security.Caller{Identities: []string{"spiffe://cluster.local/ns/default/sa/default"}}, nil
+	}
+	if token != adminToken {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return &security.Caller{Identities: []string{"spiffe://cluster.local/ns/istio-system/sa/istiodctl"}}, nil
+}
+
+func (a *tokenAuthenticator) AuthenticatorType() string {
+	return "token"
+}
+
+func (a *tokenAuthenticator) AuthenticateRequest(*http.Request) (*security.Caller, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// newDebugServer starts a discovery server serving the debug endpoints over TLS, and returns it with the TLS
+// config trusting it and a dialer to it.
+func newDebugServer(t *testing.T, authn *tokenAuthenticator) (*xds.FakeDiscoveryServer, *tls.Config, grpc.DialOption) {
+	original := xds.AuthPlaintext
+	// The fake discovery server has no transport credentials, TLS is terminated by its listener.
+	xds.AuthPlaintext = true
+	t.Cleanup(func() { xds.AuthPlaintext = original })
+
+	certPEM, keyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         istiodHost,
+		NotBefore:    time.Now(),
+		TTL:          time.Hour,
+		IsSelfSigned: true,
+		IsServer:     true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+
+	buf := bufconn.Listen(1024 * 1024)
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		ConfigString: configs,
+		ListenerBuilder: func() (net.Listener, error) {
+			return tls.NewListener(buf, &tls.Config{Certificates: []tls.Certificate{cert}}), nil
+		},
+		DiscoveryServerModifier: func(s *xds.DiscoveryServer) {
+			s.Authenticators = []security.Authenticator{authn}
+			internal := http.NewServeMux()
+			s.AddDebugHandlers(http.NewServeMux(), internal, false, nil)
+			s.Generators[xds.TypeDebug].(*xds.DebugGen).DebugMux = internal
+		},
+	})
+	dialer := grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return buf.Dial()
+	})
+	return s, &tls.Config{RootCAs: roots, ServerName: istiodHost}, dialer
+}
+
+func TestDebug(t *testing.T) {
+	_, tlsConfig, dialer := newDebugServer(t, &tokenAuthenticator{})
+	c := NewClient(Options{
+		XDSAddress:  istiodHost + ":15012",
+		TLSConfig:   tlsConfig,
+		Token:       func() (string, error) { return adminToken, nil },
+		DialOptions: []grpc.DialOption{dialer},
+	})
+	conn, err := grpc.Dial("bufconn", dialer, grpc.WithBlock(), grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	xds.NewAdsTest(t, conn).RequestResponseAck(t, nil)
+	ctx := context.Background()
+
+	t.Run("syncz", func(t *testing.T) {
+		syncz, err := c.Syncz(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, ss := range syncz {
+			found = found || strings.HasPrefix(ss.ProxyID, "test.default")
+		}
+		if !found {
+			t.Fatalf("expected the connected proxy, got %+v", syncz)
+		}
+	})
+	t.Run("configz", func(t *testing.T) {
+		cfgs, err := c.Configz(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cfgs) != 1 || cfgs[0].GroupVersionKind != gvk.ServiceEntry || cfgs[0].Name != "se" {
+			t.Fatalf("expected the service entry, got %+v", cfgs)
+		}
+	})
+	t.Run("push", func(t *testing.T) {
+		pushed, err := c.Push(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pushed != 1 {
+			t.Fatalf("expected a push to 1 proxy, got %d", pushed)
+		}
+	})
+	t.Run("invalid token", func(t *testing.T) {
+		c := NewClient(Options{
+			XDSAddress:  istiodHost + ":15012",
+			TLSConfig:   tlsConfig,
+			Token:       func() (string, error) { return "invalid", nil },
+			DialOptions: []grpc.DialOption{dialer},
+		})
+		if _, err := c.Syncz(ctx); err == nil {
+			t.Fatal("expected an invalid token to be rejected")
+		}
+	})
+}
+
+func TestAuthentication(t *testing.T) {
+	authn := &tokenAuthenticator{clusters: make(chan string, 1)}
+	_, tlsConfig, dialer := newDebugServer(t, authn)
+	token := adminToken
+	calls := 0
+	c := NewClient(Options{
+		XDSAddress: istiodHost + ":15012",
+		TLSConfig:  tlsConfig,
+		Token: func() (string, error) {
+			calls++
+			return token, nil
+		},
+		ClusterID:   remoteCluster,
+		DialOptions: []grpc.DialOption{dialer},
+	})
+	for i := 1; i <= 2; i++ {
+		if _, err := c.Syncz(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if calls < i {
+			t.Fatalf("expected the token to be read for each request, got %d reads", calls)
+		}
+		if got := <-authn.clusters; got != remoteCluster {
+			t.Fatalf("expected cluster %s, got %q", remoteCluster, got)
+		}
+	}
+
+	// The token is never sent without TLS.
+	plaintext := NewClient(Options{
+		XDSAddress:  istiodHost + ":15012",
+		Token:       func() (string, error) { return token, nil },
+		DialOptions: []grpc.DialOption{dialer, grpc.WithTransportCredentials(insecure.NewCredentials())},
+	})
+	if _, err := plaintext.Syncz(context.Background()); err == nil ||
+		!strings.Contains(err.Error(), "require transport level security") {
+		t.Fatalf("expected the token to require TLS, got %v", err)
+	}
+}
+
+func TestScopes(t *testing.T) {
+	scope := log.RegisterScope("istiodctl", "istiodctl test scope", 0)
+	server, err := ctrlz.Run(&ctrlz.Options{Address: "localhost", Port: 0}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	c := NewClient(Options{ControlZAddress: server.Address()})
+	ctx := context.Background()
+
+	scopes, err := c.Scopes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, s := range scopes {
+		found = found || s.Name == "istiodctl"
+	}
+	if !found {
+		t.Fatalf("expected the istiodctl scope, got %+v", scopes)
+	}
+
+	if err := c.SetScopes(ctx, []*ScopeInfo{{Name: "istiodctl", OutputLevel: "debug"}}); err != nil {
+		t.Fatal(err)
+	}
+	if scope.GetOutputLevel() != log.DebugLevel {
+		t.Fatalf("expected debug level, got %v", scope.GetOutputLevel())
+	}
+	got, err := c.Scope(ctx, "istiodctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.OutputLevel != "debug" {
+		t.Fatalf("expected debug level, got %+v", got)
+	}
+
+	if err := c.SetScope(ctx, &ScopeInfo{Name: "unknown", OutputLevel: "debug"}); err == nil {
+		t.Fatal("expected an error for an unknown scope")
+	}
+}
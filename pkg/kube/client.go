@@ -222,7 +222,8 @@ func NewFakeClient(objects ...runtime.Object) ExtendedClient {
 	// Support some galley tests using basicmetadata
 	// If you are adding something to this list, consider other options like adding to the scheme.
 	gvrToListKind := map[schema.GroupVersionResource]string{
-		{Group: "testdata.istio.io", Version: "v1alpha1", Resource: "Kind1s"}: "Kind1List",
+		{Group: "testdata.istio.io", Version: "v1alpha1", Resource: "Kind1s"}:      "Kind1List",
+		{Group: "install.istio.io", Version: "v1alpha1", Resource: "meshnetworks"}: "MeshNetworksList",
 	}
 	c.dynamic = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(s, gvrToListKind)
 	c.dynamicInformer = dynamicinformer.NewDynamicSharedInformerFactory(c.dynamic, resyncInterval)
@@ -29,6 +29,7 @@ import (
 
 	"github.com/Masterminds/sprig/v3"
 	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gogo/protobuf/proto"
 	"github.com/hashicorp/go-multierror"
 	appsv1 "k8s.io/api/apps/v1"
 	batch "k8s.io/api/batch/v1"
@@ -46,7 +47,9 @@ import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	proxyConfig "istio.io/api/networking/v1beta1"
 	opconfig "istio.io/istio/operator/pkg/apis/istio/v1alpha1"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/xds"
 	"istio.io/istio/pkg/util/gogoprotomarshal"
 	"istio.io/pkg/log"
 )
@@ -108,6 +111,9 @@ type SidecarTemplateData struct {
 	Revision             string
 	EstimatedConcurrency int
 	ProxyImage           string
+	// BootstrapCustomization is the validated customization of the Envoy bootstrap of the proxy, in JSON. It is
+	// removed from the proxy metadata of ProxyConfig, as it can not be rendered as a quoted environment variable.
+	BootstrapCustomization string
 }
 
 type (
@@ -348,6 +354,52 @@ func updateImageTypeIfPresent(tag string, imageType string) string {
 	return tag + "-" + imageType
 }
 
+// applyTrafficAnnotations returns the proxy config with the per-pod traffic annotations applied to its metadata, which
+// is read by the agent, the init container and the CNI plugin, so that they agree on the traffic captured.
+func applyTrafficAnnotations(pc *meshconfig.ProxyConfig, annotations map[string]string) *meshconfig.ProxyConfig {
+	v, f := annotations[constants.SidecarTrafficRedirectDNSAnnotation]
+	if !f {
+		return pc
+	}
+	// The value is checked by validateAnnotations.
+	redirectDNS, _ := strconv.ParseBool(v)
+	pc = proto.Clone(pc).(*meshconfig.ProxyConfig)
+	if pc.ProxyMetadata == nil {
+		pc.ProxyMetadata = map[string]string{}
+	}
+	pc.ProxyMetadata["ISTIO_META_DNS_CAPTURE"] = strconv.FormatBool(redirectDNS)
+	return pc
+}
+
+// extractBootstrapCustomization returns the proxy config without its bootstrap customization, and the
+// customization. The customization of the proxy.istio.io/config annotation is validated on its own, since an
+// invalid one is otherwise ignored when merged with the other layers of the proxy config.
+func extractBootstrapCustomization(pc *meshconfig.ProxyConfig,
+	annotations map[string]string) (*meshconfig.ProxyConfig, string, error) {
+	if v, f := annotations[annotation.ProxyConfig.Name]; f {
+		apc := &meshconfig.ProxyConfig{}
+		// Errors parsing the annotation are reported when it is applied.
+		if err := gogoprotomarshal.ApplyYAML(v, apc); err == nil {
+			if c, f := apc.ProxyMetadata[xds.BootstrapCustomizationKey]; f {
+				if _, err := xds.ParseBootstrapCustomization(c); err != nil {
+					return nil, "", fmt.Errorf("invalid %s annotation: %v", annotation.ProxyConfig.Name, err)
+				}
+			}
+		}
+	}
+	v, f := pc.GetProxyMetadata()[xds.BootstrapCustomizationKey]
+	if !f {
+		return pc, "", nil
+	}
+	c, err := xds.ParseBootstrapCustomization(v)
+	if err != nil {
+		return nil, "", err
+	}
+	pc = proto.Clone(pc).(*meshconfig.ProxyConfig)
+	delete(pc.ProxyMetadata, xds.BootstrapCustomizationKey)
+	return pc, c.String(), nil
+}
+
 // RunTemplate renders the sidecar template
 // Returns the raw string template, as well as the parse pod form
 func RunTemplate(params InjectionParameters) (mergedPod *corev1.Pod, templatePod *corev1.Pod, err error) {
@@ -358,6 +410,13 @@ func RunTemplate(params InjectionParameters) (mergedPod *corev1.Pod, templatePod
 		log.Errorf("Injection failed due to invalid annotations: %v", err)
 		return nil, nil, err
 	}
+	params.proxyConfig = applyTrafficAnnotations(params.proxyConfig, metadata.GetAnnotations())
+	proxyConfig, bootstrapCustomization, err := extractBootstrapCustomization(params.proxyConfig, metadata.GetAnnotations())
+	if err != nil {
+		log.Errorf("Injection failed due to invalid bootstrap customization: %v", err)
+		return nil, nil, err
+	}
+	params.proxyConfig = proxyConfig
 
 	valuesStruct := &opconfig.Values{}
 	if err := gogoprotomarshal.ApplyYAML(params.valuesConfig, valuesStruct); err != nil {
@@ -410,6 +469,8 @@ func RunTemplate(params InjectionParameters) (mergedPod *corev1.Pod, templatePod
 		Revision:             params.revision,
 		EstimatedConcurrency: estimateConcurrency(params.proxyConfig, metadata.Annotations, valuesStruct),
 		ProxyImage:           ProxyImage(valuesStruct, params.proxyConfig.Image, strippedPod.Annotations),
+
+		BootstrapCustomization: bootstrapCustomization,
 	}
 	funcMap := CreateInjectionFuncmap()
 
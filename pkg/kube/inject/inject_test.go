@@ -247,10 +247,23 @@ func TestInjection(t *testing.T) {
 			in:            "traffic-annotations-bad-excludeinboundports.yaml",
 			expectedError: "excludeinboundports",
 		},
+		{
+			in:            "bootstrap-customization-invalid.yaml",
+			expectedError: `the "admin" section of the bootstrap can not be customized`,
+		},
 		{
 			in:            "traffic-annotations-bad-excludeoutboundports.yaml",
 			expectedError: "excludeoutboundports",
 		},
+		{
+			in:            "traffic-annotations-bad-redirectdns.yaml",
+			expectedError: "redirectdns",
+		},
+		{
+			// Verifies that the DNS capture annotation is applied to the proxy metadata, and the IPv6 one to istio-init.
+			in:   "traffic-annotations-redirect.yaml",
+			want: "traffic-annotations-redirect.yaml.injected",
+		},
 		{
 			in:   "hello.yaml",
 			want: "hello-no-seccontext.yaml.injected",
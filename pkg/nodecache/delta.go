@@ -0,0 +1,98 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodecache
+
+import (
+	"context"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	istiogrpc "istio.io/istio/pilot/pkg/grpc"
+)
+
+// DeltaAggregatedResources forwards the delta xDS stream of a proxy on the node to Istiod. Delta streams share the
+// connection to Istiod, but are neither deduplicated nor cached.
+func (s *Server) DeltaAggregatedResources(downstream discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
+	identities, err := s.authenticate(downstream.Context())
+	if err != nil {
+		return err
+	}
+	// The identity of the proxy is checked against its node before opening the stream to Istiod.
+	first, err := downstream.Recv()
+	if err != nil {
+		return err
+	}
+	if first.Node == nil || first.Node.Id == "" {
+		return status.Error(codes.InvalidArgument, "missing node information")
+	}
+	if err := checkIdentity(first.Node, identities); err != nil {
+		return err
+	}
+	md, _ := metadata.FromIncomingContext(downstream.Context())
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(downstream.Context(), forwardedMetadata(md)))
+	defer cancel()
+	upstream, err := s.client.DeltaAggregatedResources(ctx, grpc.MaxCallRecvMsgSize(maxReceiveMessageSize))
+	if err != nil {
+		return err
+	}
+	s.recordDeltaStream(1)
+	defer s.recordDeltaStream(-1)
+
+	errs := make(chan error, 2)
+	go func() {
+		req := first
+		for {
+			if err := istiogrpc.Send(ctx, func() error { return upstream.Send(req) }); err != nil {
+				errs <- err
+				return
+			}
+			var err error
+			if req, err = downstream.Recv(); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	go func() {
+		for {
+			resp, err := upstream.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if err := istiogrpc.Send(ctx, func() error { return downstream.Send(resp) }); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	if err := <-errs; !istiogrpc.IsExpectedGRPCError(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) recordDeltaStream(delta int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.downstreams += delta
+	s.upstreams += delta
+	downstreamStreams.Record(float64(s.downstreams))
+	upstreamStreams.Record(float64(s.upstreams))
+}
@@ -0,0 +1,50 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodecache
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	downstreamStreams = monitoring.NewGauge(
+		"node_cache_downstream_streams",
+		"The number of xDS streams of the proxies on the node.",
+	)
+
+	upstreamStreams = monitoring.NewGauge(
+		"node_cache_upstream_streams",
+		"The number of xDS streams to Istiod.",
+	)
+
+	deduplicatedResponses = monitoring.NewSum(
+		"node_cache_deduplicated_responses",
+		"The total number of responses sent to proxies sharing the stream to Istiod of another proxy.",
+	)
+
+	cachedResponses = monitoring.NewSum(
+		"node_cache_cached_responses",
+		"The total number of responses served from the cache.",
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		downstreamStreams,
+		upstreamStreams,
+		deduplicatedResponses,
+		cachedResponses,
+	)
+}
@@ -0,0 +1,786 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nodecache implements the node-local xDS cache. The cache runs on each node and forwards the xDS streams
+// of the proxies on the node to Istiod over a single connection, authenticating each stream with the credentials
+// of its proxy. Proxies Istiod sends the same configuration to share a single stream to Istiod, and the
+// configuration of a proxy is served from the cache when it reconnects while Istiod is unavailable.
+package nodecache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	istiogrpc "istio.io/istio/pilot/pkg/grpc"
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/spiffe"
+	istiolog "istio.io/pkg/log"
+)
+
+var log = istiolog.RegisterScope("nodecache", "xDS node cache", 0)
+
+const (
+	authorizationHeader   = "authorization"
+	maxReceiveMessageSize = math.MaxInt32
+	defaultRetryInterval  = time.Second
+)
+
+// podScopedMetadata are the node metadata fields which differ between the pods of a workload. They are left out of
+// the group key, so that the pods of a workload can share a stream once Istiod sent them the same configuration.
+var podScopedMetadata = []string{"NAME", "INSTANCE_IPS"}
+
+var streamNumber = atomic.NewUint32(0)
+
+// Options configure the node cache.
+type Options struct {
+	// IstiodAddress is the address of the Istiod xDS server.
+	IstiodAddress string
+	// DialOptions are used to connect to Istiod. The streams are authenticated with the credentials of their proxy,
+	// so they should not include per RPC credentials.
+	DialOptions []grpc.DialOption
+	// CacheTTL is how long the configuration of a proxy is kept after it disconnects, to be served to it if it
+	// reconnects while Istiod is unavailable.
+	CacheTTL time.Duration
+	// RetryInterval is the interval between the attempts to reopen a stream to Istiod.
+	RetryInterval time.Duration
+	// Authenticator authenticates the proxies connecting to the node cache, typically with their workload
+	// certificate. A proxy is only served if one of its identities matches the namespace and service account of its
+	// node, so it cannot have its stream forwarded with the credentials of another workload.
+	Authenticator security.Authenticator
+}
+
+// Server is the node cache. It serves the aggregated discovery service to the proxies on the node.
+type Server struct {
+	opts   Options
+	conn   *grpc.ClientConn
+	client discovery.AggregatedDiscoveryServiceClient
+
+	mu sync.Mutex
+	// groups holds the streams of the proxies expected to receive the same configuration, by group key.
+	groups map[string]*group
+	// verified holds the proxies Istiod sent configuration to, by node ID.
+	verified    map[string]*verifiedProxy
+	downstreams int
+	upstreams   int
+}
+
+var _ discovery.AggregatedDiscoveryServiceServer = &Server{}
+
+// group is the set of streams expected to receive the same configuration. Only the leader has a stream to Istiod:
+// the responses it receives are cached and sent to the followers.
+type group struct {
+	leader    *stream
+	followers map[*stream]struct{}
+	// responses holds the last response of each type.
+	responses map[string]*cachedResponse
+	// expiry is when the group is removed, once it has no stream left.
+	expiry time.Time
+}
+
+type cachedResponse struct {
+	response *discovery.DiscoveryResponse
+	hash     string
+	// names are the resource names the leader subscribed to when it received the response.
+	names []string
+}
+
+// verifiedProxy records the credentials of a proxy Istiod sent configuration to.
+type verifiedProxy struct {
+	auth string
+	key  string
+	// streams is the number of verified streams of the proxy. The record expires once the last one ended.
+	streams int
+	expiry  time.Time
+}
+
+// stream is the xDS stream of a proxy on the node.
+type stream struct {
+	id         uint32
+	downstream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer
+	// md is the metadata of the stream forwarded to Istiod, which holds the credentials of the proxy.
+	md metadata.MD
+	// identities are the identities the proxy authenticated to the node cache with.
+	identities []string
+	responses  *queue
+	errors     chan error
+
+	// The fields below are guarded by Server.mu.
+	node *core.Node
+	key  string
+	// subscriptions holds the last request of each type, in the order the types were first requested.
+	subscriptions map[string]*discovery.DiscoveryRequest
+	order         []string
+	// sent holds the hash of the last response sent for each type.
+	sent map[string]string
+	// upstream is the stream to Istiod. It is nil while following the leader of the group, and while Istiod is
+	// unavailable.
+	upstream   *upstream
+	connecting bool
+	retry      *time.Timer
+	group      *group
+	verified   bool
+	closed     bool
+}
+
+// upstream is a stream to Istiod.
+type upstream struct {
+	client   discovery.AggregatedDiscoveryService_StreamAggregatedResourcesClient
+	cancel   context.CancelFunc
+	requests *queue
+	// responded is set once Istiod sent a response, accepting the credentials of the proxy.
+	responded bool
+}
+
+// NewServer creates a node cache forwarding the streams to Istiod with the given options. The connection to Istiod
+// is established in the background.
+func NewServer(opts Options) (*Server, error) {
+	if opts.RetryInterval == 0 {
+		opts.RetryInterval = defaultRetryInterval
+	}
+	if opts.Authenticator == nil {
+		return nil, fmt.Errorf("an authenticator of the proxies is required")
+	}
+	conn, err := grpc.Dial(opts.IstiodAddress, opts.DialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to istiod at %s: %v", opts.IstiodAddress, err)
+	}
+	return &Server{
+		opts:     opts,
+		conn:     conn,
+		client:   discovery.NewAggregatedDiscoveryServiceClient(conn),
+		groups:   map[string]*group{},
+		verified: map[string]*verifiedProxy{},
+	}, nil
+}
+
+// Register registers the node cache as the discovery service of the given gRPC server.
+func (s *Server) Register(gs *grpc.Server) {
+	discovery.RegisterAggregatedDiscoveryServiceServer(gs, s)
+}
+
+// Run removes the expired configuration from the cache until stop is closed, then closes the connection to Istiod.
+func (s *Server) Run(stop <-chan struct{}) {
+	interval := s.opts.CacheTTL / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.removeExpired(now)
+		case <-stop:
+			if err := s.conn.Close(); err != nil {
+				log.Warnf("failed to close the connection to istiod: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// StreamAggregatedResources serves the xDS stream of a proxy on the node.
+func (s *Server) StreamAggregatedResources(downstream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	identities, err := s.authenticate(downstream.Context())
+	if err != nil {
+		return err
+	}
+	md, _ := metadata.FromIncomingContext(downstream.Context())
+	st := &stream{
+		id:            streamNumber.Inc(),
+		downstream:    downstream,
+		md:            forwardedMetadata(md),
+		identities:    identities,
+		responses:     newQueue(),
+		errors:        make(chan error, 1),
+		subscriptions: map[string]*discovery.DiscoveryRequest{},
+		sent:          map[string]string{},
+	}
+	s.mu.Lock()
+	s.downstreams++
+	downstreamStreams.Record(float64(s.downstreams))
+	s.mu.Unlock()
+	defer s.closeStream(st)
+
+	done := make(chan struct{})
+	defer close(done)
+	requests := make(chan *discovery.DiscoveryRequest)
+	go func() {
+		for {
+			req, err := downstream.Recv()
+			if err != nil {
+				st.fail(err)
+				return
+			}
+			select {
+			case requests <- req:
+			case <-done:
+				return
+			}
+		}
+	}()
+	go s.sendDownstream(st, done)
+
+	for {
+		select {
+		case req := <-requests:
+			s.handleRequest(st, req)
+		case err := <-st.errors:
+			if istiogrpc.IsExpectedGRPCError(err) {
+				log.Debugf("stream %d terminated: %v", st.id, err)
+				return nil
+			}
+			log.Warnf("stream %d terminated with unexpected error: %v", st.id, err)
+			return err
+		}
+	}
+}
+
+func (s *Server) sendDownstream(st *stream, done <-chan struct{}) {
+	for {
+		item, ok := st.responses.pop(done)
+		if !ok {
+			return
+		}
+		resp := item.(*discovery.DiscoveryResponse)
+		if err := istiogrpc.Send(st.downstream.Context(), func() error { return st.downstream.Send(resp) }); err != nil {
+			st.fail(err)
+			return
+		}
+	}
+}
+
+func (s *Server) handleRequest(st *stream, req *discovery.DiscoveryRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st.node == nil {
+		if req.Node == nil || req.Node.Id == "" {
+			st.fail(status.Error(codes.InvalidArgument, "missing node information"))
+			return
+		}
+		if err := checkIdentity(req.Node, st.identities); err != nil {
+			st.fail(err)
+			return
+		}
+		st.node = req.Node
+		st.key = groupKey(req.Node, st.md)
+	}
+	st.subscribe(req)
+
+	switch {
+	case st.upstream != nil:
+		st.upstream.requests.push(req)
+	case st.group == nil:
+		// The subscriptions are sent once the stream to Istiod is open.
+		s.connect(st)
+	case st.following() && (req.ErrorDetail != nil || !st.group.leader.subscribedTo(req)):
+		// The proxy rejected the configuration of the group, or diverged from it: Istiod has to serve it directly.
+		log.Infof("stream %d of %s stops following stream %d", st.id, st.node.Id, st.group.leader.id)
+		s.unfollow(st)
+	default:
+		s.sendCached(st, req.TypeUrl)
+	}
+}
+
+// connect opens the stream to Istiod of st in the background, unless it follows its group or is already connecting.
+func (s *Server) connect(st *stream) {
+	if st.closed || st.upstream != nil || st.connecting || st.retry != nil || st.following() {
+		return
+	}
+	st.connecting = true
+	go s.openUpstream(st)
+}
+
+func (s *Server) openUpstream(st *stream) {
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(context.Background(), st.md))
+	client, err := s.client.StreamAggregatedResources(ctx, grpc.MaxCallRecvMsgSize(maxReceiveMessageSize))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st.connecting = false
+	if err != nil {
+		cancel()
+		s.upstreamFailed(st, false, err)
+		return
+	}
+	if st.closed || st.following() {
+		cancel()
+		return
+	}
+	up := &upstream{client: client, cancel: cancel, requests: newQueue()}
+	st.upstream = up
+	s.upstreams++
+	upstreamStreams.Record(float64(s.upstreams))
+
+	// Resume the subscriptions of the proxy, as if it reconnected.
+	for i, typeURL := range st.order {
+		req := proto.Clone(st.subscriptions[typeURL]).(*discovery.DiscoveryRequest)
+		req.ResponseNonce = ""
+		req.ErrorDetail = nil
+		req.Node = nil
+		if i == 0 {
+			req.Node = st.node
+		}
+		up.requests.push(req)
+	}
+	go s.sendUpstream(st, up, ctx.Done())
+	go s.receiveUpstream(st, up)
+}
+
+func (s *Server) sendUpstream(st *stream, up *upstream, done <-chan struct{}) {
+	for {
+		item, ok := up.requests.pop(done)
+		if !ok {
+			return
+		}
+		req := item.(*discovery.DiscoveryRequest)
+		if err := istiogrpc.Send(up.client.Context(), func() error { return up.client.Send(req) }); err != nil {
+			s.upstreamClosed(st, up, err)
+			return
+		}
+	}
+}
+
+func (s *Server) receiveUpstream(st *stream, up *upstream) {
+	for {
+		resp, err := up.client.Recv()
+		if err != nil {
+			s.upstreamClosed(st, up, err)
+			return
+		}
+		s.handleResponse(st, up, resp)
+	}
+}
+
+func (s *Server) upstreamClosed(st *stream, up *upstream, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st.upstream != up {
+		return
+	}
+	s.closeUpstream(st)
+	s.upstreamFailed(st, up.responded, err)
+}
+
+func (s *Server) closeUpstream(st *stream) {
+	if st.upstream == nil {
+		return
+	}
+	st.upstream.cancel()
+	st.upstream = nil
+	s.upstreams--
+	upstreamStreams.Record(float64(s.upstreams))
+}
+
+// upstreamFailed handles the failure of the stream to Istiod of st. Rejections by Istiod end the stream of the proxy,
+// other failures are retried while the proxy keeps its configuration.
+func (s *Server) upstreamFailed(st *stream, responded bool, err error) {
+	if st.closed {
+		return
+	}
+	if rejected(err, responded) {
+		st.fail(err)
+		return
+	}
+	log.Debugf("stream %d to istiod closed, retrying in %v: %v", st.id, s.opts.RetryInterval, err)
+	if !st.verified && st.group == nil && s.followCache(st) {
+		log.Infof("serving the cached configuration of %s while istiod is unavailable", st.node.Id)
+	}
+	if st.following() {
+		return
+	}
+	st.retry = time.AfterFunc(s.opts.RetryInterval, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		st.retry = nil
+		s.connect(st)
+	})
+}
+
+func (s *Server) handleResponse(st *stream, up *upstream, resp *discovery.DiscoveryResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st.upstream != up {
+		return
+	}
+	if !up.responded {
+		up.responded = true
+		s.verify(st)
+	}
+	hash := responseHash(resp)
+	st.send(resp, hash)
+
+	g := s.groups[st.key]
+	if g == nil {
+		g = &group{followers: map[*stream]struct{}{}, responses: map[string]*cachedResponse{}}
+		s.groups[st.key] = g
+	}
+	if g.leader == nil {
+		s.lead(g, st)
+	} else if g.leader != st && sameConfiguration(st, g) {
+		if g.leader.upstream != nil {
+			log.Infof("stream %d of %s follows stream %d", st.id, st.node.Id, g.leader.id)
+			s.follow(g, st)
+			return
+		}
+		// The leader lost its stream to Istiod: take over, so that the group is updated again.
+		previous := g.leader
+		s.lead(g, st)
+		s.follow(g, previous)
+	}
+	if g.leader != st {
+		return
+	}
+
+	names, _ := st.names(resp.TypeUrl)
+	g.responses[resp.TypeUrl] = &cachedResponse{response: resp, hash: hash, names: names}
+	for f := range g.followers {
+		if fnames, ok := f.names(resp.TypeUrl); ok && sameNames(fnames, names) && f.sent[resp.TypeUrl] != hash {
+			f.send(resp, hash)
+			deduplicatedResponses.Increment()
+		}
+	}
+}
+
+// verify records that Istiod accepted the credentials of the proxy of st.
+func (s *Server) verify(st *stream) {
+	v := s.verified[st.node.Id]
+	if v == nil {
+		v = &verifiedProxy{}
+		s.verified[st.node.Id] = v
+	}
+	v.auth = authorization(st.md)
+	v.key = st.key
+	v.expiry = time.Time{}
+	if !st.verified {
+		st.verified = true
+		v.streams++
+	}
+}
+
+func (s *Server) lead(g *group, st *stream) {
+	delete(g.followers, st)
+	g.leader = st
+	g.expiry = time.Time{}
+	st.group = g
+}
+
+// follow makes st follow the leader of the group, closing its own stream to Istiod.
+func (s *Server) follow(g *group, st *stream) {
+	s.closeUpstream(st)
+	if st.retry != nil {
+		st.retry.Stop()
+		st.retry = nil
+	}
+	st.group = g
+	g.followers[st] = struct{}{}
+	for _, typeURL := range st.order {
+		s.sendCached(st, typeURL)
+	}
+}
+
+func (s *Server) unfollow(st *stream) {
+	delete(st.group.followers, st)
+	st.group = nil
+	s.connect(st)
+}
+
+// followCache makes st follow the cached configuration of its group, if Istiod sent configuration to the same proxy
+// with the same credentials before.
+func (s *Server) followCache(st *stream) bool {
+	auth := authorization(st.md)
+	v := s.verified[st.node.Id]
+	g := s.groups[st.key]
+	if auth == "" || v == nil || v.auth != auth || v.key != st.key || g == nil || len(g.responses) == 0 {
+		return false
+	}
+	if v.streams == 0 && time.Now().After(v.expiry) {
+		return false
+	}
+	if g.leader == nil {
+		s.lead(g, st)
+		for _, typeURL := range st.order {
+			s.sendCached(st, typeURL)
+		}
+		return true
+	}
+	s.follow(g, st)
+	return true
+}
+
+// sendCached sends the cached response of the given type to st, if st subscribed to the same resources and was not
+// sent it yet.
+func (s *Server) sendCached(st *stream, typeURL string) {
+	cached := st.group.responses[typeURL]
+	if cached == nil || st.sent[typeURL] == cached.hash {
+		return
+	}
+	if names, ok := st.names(typeURL); !ok || !sameNames(names, cached.names) {
+		return
+	}
+	st.send(cached.response, cached.hash)
+	cachedResponses.Increment()
+}
+
+func (s *Server) closeStream(st *stream) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st.closed = true
+	s.downstreams--
+	downstreamStreams.Record(float64(s.downstreams))
+	s.closeUpstream(st)
+	if st.retry != nil {
+		st.retry.Stop()
+		st.retry = nil
+	}
+	if st.verified {
+		if v := s.verified[st.node.Id]; v != nil {
+			v.streams--
+			if v.streams == 0 {
+				v.expiry = time.Now().Add(s.opts.CacheTTL)
+			}
+		}
+	}
+	g := st.group
+	if g == nil {
+		return
+	}
+	delete(g.followers, st)
+	if g.leader != st {
+		return
+	}
+	g.leader = nil
+	for f := range g.followers {
+		log.Infof("stream %d of %s leads in place of stream %d", f.id, f.node.Id, st.id)
+		s.lead(g, f)
+		s.connect(f)
+		return
+	}
+	g.expiry = time.Now().Add(s.opts.CacheTTL)
+}
+
+func (s *Server) removeExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, v := range s.verified {
+		if v.streams == 0 && now.After(v.expiry) {
+			delete(s.verified, id)
+		}
+	}
+	for key, g := range s.groups {
+		if g.leader == nil && len(g.followers) == 0 && now.After(g.expiry) {
+			delete(s.groups, key)
+		}
+	}
+}
+
+// following returns whether st follows the leader of its group.
+func (st *stream) following() bool {
+	return st.group != nil && st.group.leader != st
+}
+
+func (st *stream) subscribe(req *discovery.DiscoveryRequest) {
+	if _, f := st.subscriptions[req.TypeUrl]; !f {
+		st.order = append(st.order, req.TypeUrl)
+	}
+	st.subscriptions[req.TypeUrl] = req
+}
+
+// names returns the resource names st subscribed to for the given type, and whether it subscribed to the type.
+func (st *stream) names(typeURL string) ([]string, bool) {
+	if req := st.subscriptions[typeURL]; req != nil {
+		return req.ResourceNames, true
+	}
+	return nil, false
+}
+
+// subscribedTo returns whether st subscribed to the resources of the given request.
+func (st *stream) subscribedTo(req *discovery.DiscoveryRequest) bool {
+	names, ok := st.names(req.TypeUrl)
+	return ok && sameNames(names, req.ResourceNames)
+}
+
+func (st *stream) send(resp *discovery.DiscoveryResponse, hash string) {
+	st.sent[resp.TypeUrl] = hash
+	st.responses.push(resp)
+}
+
+// fail terminates st with the given error.
+func (st *stream) fail(err error) {
+	select {
+	case st.errors <- err:
+	default:
+	}
+}
+
+// sameConfiguration returns whether st subscribed to the same resources as the leader of the group, and was sent the
+// configuration cached for the group for all of them. Istiod sending the same configuration to both proxies is what
+// allows them to share a stream. A proxy that rejected configuration keeps its own stream until it accepts it again.
+func sameConfiguration(st *stream, g *group) bool {
+	if len(st.order) != len(g.leader.order) {
+		return false
+	}
+	for _, typeURL := range st.order {
+		if st.subscriptions[typeURL].ErrorDetail != nil {
+			return false
+		}
+		cached := g.responses[typeURL]
+		if cached == nil || st.sent[typeURL] != cached.hash || !g.leader.subscribedTo(st.subscriptions[typeURL]) {
+			return false
+		}
+	}
+	return true
+}
+
+// authenticate returns the identities of the proxy of a stream.
+func (s *Server) authenticate(ctx context.Context) ([]string, error) {
+	caller, err := s.opts.Authenticator.Authenticate(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "authentication failure: %v", err)
+	}
+	return caller.Identities, nil
+}
+
+// checkIdentity returns an error unless one of the identities of the proxy matches the namespace and service account
+// of its node. Istiod checks that the token forwarded with the stream matches the same node.
+func checkIdentity(node *core.Node, identities []string) error {
+	namespace := nodeMetadata(node, "NAMESPACE")
+	if namespace == "" {
+		return status.Error(codes.InvalidArgument, "missing node namespace")
+	}
+	serviceAccount := nodeMetadata(node, "SERVICE_ACCOUNT")
+	for _, raw := range identities {
+		id, err := spiffe.ParseIdentity(raw)
+		if err != nil {
+			continue
+		}
+		if id.Namespace == namespace && (serviceAccount == "" || id.ServiceAccount == serviceAccount) {
+			return nil
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "no identities (%v) matched %v/%v", identities, namespace, serviceAccount)
+}
+
+func nodeMetadata(node *core.Node, field string) string {
+	if node.Metadata == nil {
+		return ""
+	}
+	return node.Metadata.Fields[field].GetStringValue()
+}
+
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// rejected returns whether Istiod rejected the stream, rather than being unavailable. Istiod reports the failure of
+// the authorization of a proxy with an unknown error code, before sending any response.
+func rejected(err error, responded bool) bool {
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied, codes.InvalidArgument:
+		return true
+	case codes.Unknown:
+		return !responded && err != io.EOF
+	}
+	return false
+}
+
+// responseHash returns the hash of the resources of resp. Istiod marshals the resources deterministically, so
+// proxies with the same configuration are sent the same bytes.
+func responseHash(resp *discovery.DiscoveryResponse) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:%s", len(resp.TypeUrl), resp.TypeUrl)
+	for _, r := range resp.Resources {
+		fmt.Fprintf(h, "%d:%s%d:", len(r.TypeUrl), r.TypeUrl, len(r.Value))
+		h.Write(r.Value)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// groupKey returns the key of the streams expected to receive the same configuration: those with the same node and
+// stream metadata, ignoring the identifiers of the pod and its credentials.
+func groupKey(node *core.Node, md metadata.MD) string {
+	n := proto.Clone(node).(*core.Node)
+	n.Id = ""
+	if n.Metadata != nil {
+		for _, f := range podScopedMetadata {
+			delete(n.Metadata.Fields, f)
+		}
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(n)
+	if err != nil {
+		// The stream is not grouped with any other.
+		return node.Id
+	}
+	h := sha256.New()
+	h.Write(b)
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		if k != authorizationHeader {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%d:%s", len(k), k)
+		for _, v := range md[k] {
+			fmt.Fprintf(h, "%d:%s", len(v), v)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// forwardedMetadata returns the metadata of a stream of a proxy to forward to Istiod: its credentials and the headers
+// set by the agent, such as its cluster ID.
+func forwardedMetadata(md metadata.MD) metadata.MD {
+	out := metadata.MD{}
+	for k, v := range md {
+		if strings.HasPrefix(k, ":") || strings.HasPrefix(k, "grpc-") || k == "content-type" || k == "user-agent" {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func authorization(md metadata.MD) string {
+	if v := md.Get(authorizationHeader); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,629 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodecache
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	google_rpc "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// fakeIstiod is an xDS server responding to each subscription with the configuration version, and the node ID when
+// perNode is set.
+type fakeIstiod struct {
+	t       *testing.T
+	perNode bool
+
+	mu          sync.Mutex
+	listener    *bufconn.Listener
+	server      *grpc.Server
+	version     int
+	connections int
+	streams     map[*fakeStream]struct{}
+	requests    []*discovery.DiscoveryRequest
+}
+
+type fakeStream struct {
+	mu            sync.Mutex
+	stream        discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer
+	node          *core.Node
+	subscriptions map[string][]string
+}
+
+func newFakeIstiod(t *testing.T, perNode bool) *fakeIstiod {
+	f := &fakeIstiod{t: t, perNode: perNode, version: 1, streams: map[*fakeStream]struct{}{}}
+	f.start()
+	t.Cleanup(f.stop)
+	return f
+}
+
+func (f *fakeIstiod) start() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listener = bufconn.Listen(1024 * 1024)
+	f.server = grpc.NewServer()
+	discovery.RegisterAggregatedDiscoveryServiceServer(f.server, f)
+	go func() {
+		_ = f.server.Serve(f.listener)
+	}()
+}
+
+// stop stops the server, closing all the streams, until it is started again.
+func (f *fakeIstiod) stop() {
+	f.mu.Lock()
+	server := f.server
+	f.mu.Unlock()
+	server.Stop()
+}
+
+func (f *fakeIstiod) dial(context.Context, string) (net.Conn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	conn, err := f.listener.Dial()
+	if err == nil {
+		f.connections++
+	}
+	return conn, err
+}
+
+func (f *fakeIstiod) StreamAggregatedResources(downstream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	md, _ := metadata.FromIncomingContext(downstream.Context())
+	if auth := md.Get("authorization"); len(auth) == 0 || auth[0] == "Bearer rejected" {
+		return status.Error(codes.Unauthenticated, "authentication failure")
+	}
+	s := &fakeStream{stream: downstream, subscriptions: map[string][]string{}}
+	f.mu.Lock()
+	f.streams[s] = struct{}{}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		delete(f.streams, s)
+		f.mu.Unlock()
+	}()
+	for {
+		req, err := downstream.Recv()
+		if err != nil {
+			return nil
+		}
+		f.mu.Lock()
+		f.requests = append(f.requests, req)
+		version := f.version
+		f.mu.Unlock()
+		s.mu.Lock()
+		if s.node == nil {
+			s.node = req.Node
+		}
+		names, subscribed := s.subscriptions[req.TypeUrl]
+		s.subscriptions[req.TypeUrl] = req.ResourceNames
+		s.mu.Unlock()
+		if req.ResponseNonce == "" || !subscribed || !sameNames(names, req.ResourceNames) {
+			f.respond(s, req.TypeUrl, version)
+		}
+	}
+}
+
+func (f *fakeIstiod) DeltaAggregatedResources(downstream discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
+	md, _ := metadata.FromIncomingContext(downstream.Context())
+	for {
+		req, err := downstream.Recv()
+		if err != nil {
+			return nil
+		}
+		if err := downstream.Send(&discovery.DeltaDiscoveryResponse{
+			TypeUrl:           req.TypeUrl,
+			SystemVersionInfo: md.Get("authorization")[0],
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *fakeIstiod) respond(s *fakeStream, typeURL string, version int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value := fmt.Sprintf("%s v%d", typeURL, version)
+	if f.perNode {
+		value += " " + s.node.Id
+	}
+	for _, n := range s.subscriptions[typeURL] {
+		value += " " + n
+	}
+	resource, err := anypb.New(wrapperspb.String(value))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	_ = s.stream.Send(&discovery.DiscoveryResponse{
+		TypeUrl:     typeURL,
+		VersionInfo: strconv.Itoa(version),
+		Nonce:       fmt.Sprintf("%p-%d", s, version),
+		Resources:   []*anypb.Any{resource},
+	})
+}
+
+// push sends a new version of the configuration to all the streams.
+func (f *fakeIstiod) push() {
+	f.mu.Lock()
+	f.version++
+	version := f.version
+	streams := make([]*fakeStream, 0, len(f.streams))
+	for s := range f.streams {
+		streams = append(streams, s)
+	}
+	f.mu.Unlock()
+	for _, s := range streams {
+		s.mu.Lock()
+		types := make([]string, 0, len(s.subscriptions))
+		for typeURL := range s.subscriptions {
+			types = append(types, typeURL)
+		}
+		s.mu.Unlock()
+		for _, typeURL := range types {
+			f.respond(s, typeURL, version)
+		}
+	}
+}
+
+func (f *fakeIstiod) expectStreams(n int) {
+	f.t.Helper()
+	retry.UntilSuccessOrFail(f.t, func() error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if len(f.streams) != n {
+			return fmt.Errorf("expected %d streams to istiod, got %d", n, len(f.streams))
+		}
+		return nil
+	}, retry.Timeout(5*time.Second), retry.Delay(10*time.Millisecond))
+}
+
+func setupNodeCache(t *testing.T, f *fakeIstiod) *grpc.ClientConn {
+	s, err := NewServer(Options{
+		IstiodAddress: "buffcon",
+		DialOptions: []grpc.DialOption{
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(f.dial),
+		},
+		CacheTTL:      time.Minute,
+		RetryInterval: 10 * time.Millisecond,
+		Authenticator: fakeAuthenticator{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs := grpc.NewServer()
+	s.Register(gs)
+	l := bufconn.Listen(1024 * 1024)
+	go func() {
+		_ = gs.Serve(l)
+	}()
+	stop := make(chan struct{})
+	go s.Run(stop)
+	t.Cleanup(func() {
+		gs.Stop()
+		close(stop)
+	})
+
+	conn, err := grpc.Dial("buffcon",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return l.Dial()
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	return conn
+}
+
+// fakeAuthenticator authenticates the proxies with the identity set in their "identity" metadata, defaulting to the
+// default service account of the default namespace.
+type fakeAuthenticator struct{}
+
+func (fakeAuthenticator) Authenticate(ctx context.Context) (*security.Caller, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := "spiffe://cluster.local/ns/default/sa/default"
+	if v := md.Get("identity"); len(v) > 0 {
+		id = v[0]
+	}
+	return &security.Caller{AuthSource: security.AuthSourceClientCertificate, Identities: []string{id}}, nil
+}
+
+func (fakeAuthenticator) AuthenticatorType() string {
+	return "fake"
+}
+
+func (fakeAuthenticator) AuthenticateRequest(*http.Request) (*security.Caller, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// testProxy is a proxy on the node, connected to the node cache.
+type testProxy struct {
+	t         *testing.T
+	name      string
+	stream    discovery.AggregatedDiscoveryService_StreamAggregatedResourcesClient
+	cancel    context.CancelFunc
+	responses chan *discovery.DiscoveryResponse
+	errors    chan error
+	sentNode  bool
+}
+
+// connectProxy connects the pod with the given name of the test workload to the node cache.
+func connectProxy(t *testing.T, conn *grpc.ClientConn, name, token string) *testProxy {
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(context.Background(),
+		"authorization", "Bearer "+token, "ClusterID", "Kubernetes"))
+	stream, err := discovery.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testProxy{
+		t:         t,
+		name:      name,
+		stream:    stream,
+		cancel:    cancel,
+		responses: make(chan *discovery.DiscoveryResponse, 10),
+		errors:    make(chan error, 1),
+	}
+	t.Cleanup(cancel)
+	go func() {
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				p.errors <- err
+				return
+			}
+			p.responses <- resp
+		}
+	}()
+	return p
+}
+
+func (p *testProxy) send(req *discovery.DiscoveryRequest) {
+	p.t.Helper()
+	if !p.sentNode {
+		req.Node = testNode(p.name, "default")
+		p.sentNode = true
+	}
+	if err := p.stream.Send(req); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+func (p *testProxy) subscribe(typeURL string, names ...string) {
+	p.t.Helper()
+	p.send(&discovery.DiscoveryRequest{TypeUrl: typeURL, ResourceNames: names})
+}
+
+func (p *testProxy) ack(resp *discovery.DiscoveryResponse, names ...string) {
+	p.t.Helper()
+	p.send(&discovery.DiscoveryRequest{
+		TypeUrl:       resp.TypeUrl,
+		VersionInfo:   resp.VersionInfo,
+		ResponseNonce: resp.Nonce,
+		ResourceNames: names,
+	})
+}
+
+// expectResponse waits for a response of the given type and returns it after checking its resource.
+func (p *testProxy) expectResponse(typeURL, want string) *discovery.DiscoveryResponse {
+	p.t.Helper()
+	select {
+	case resp := <-p.responses:
+		if resp.TypeUrl != typeURL || len(resp.Resources) != 1 {
+			p.t.Fatalf("%s: expected a %s response, got %v", p.name, typeURL, resp)
+		}
+		got := &wrapperspb.StringValue{}
+		if err := resp.Resources[0].UnmarshalTo(got); err != nil {
+			p.t.Fatal(err)
+		}
+		if got.Value != want {
+			p.t.Fatalf("%s: expected resource %q, got %q", p.name, want, got.Value)
+		}
+		return resp
+	case err := <-p.errors:
+		p.t.Fatalf("%s: stream terminated: %v", p.name, err)
+	case <-time.After(5 * time.Second):
+		p.t.Fatalf("%s: timed out waiting for a %s response", p.name, typeURL)
+	}
+	return nil
+}
+
+func (p *testProxy) expectNoResponse() {
+	p.t.Helper()
+	select {
+	case resp := <-p.responses:
+		p.t.Fatalf("%s: unexpected response %v", p.name, resp)
+	case err := <-p.errors:
+		p.t.Fatalf("%s: stream terminated: %v", p.name, err)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func testNode(name, namespace string) *core.Node {
+	ip := fmt.Sprintf("10.0.0.%d", len(name))
+	return &core.Node{
+		Id: fmt.Sprintf("sidecar~%s~%s.%s~%s.svc.cluster.local", ip, name, namespace, namespace),
+		Metadata: &structpb.Struct{Fields: map[string]*structpb.Value{
+			"NAME":         structpb.NewStringValue(name),
+			"NAMESPACE":    structpb.NewStringValue(namespace),
+			"INSTANCE_IPS": structpb.NewStringValue(ip),
+			"LABELS": structpb.NewStructValue(&structpb.Struct{Fields: map[string]*structpb.Value{
+				"app": structpb.NewStringValue("test"),
+			}}),
+		}},
+	}
+}
+
+func TestSharedConnection(t *testing.T) {
+	f := newFakeIstiod(t, true)
+	conn := setupNodeCache(t, f)
+	for _, name := range []string{"a", "bb", "ccc"} {
+		p := connectProxy(t, conn, name, name)
+		p.subscribe(v3.ClusterType)
+		p.expectResponse(v3.ClusterType, fmt.Sprintf("%s v1 %s", v3.ClusterType, testNode(name, "default").Id))
+	}
+	f.expectStreams(3)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.connections != 1 {
+		t.Fatalf("expected the streams to share a connection to istiod, got %d connections", f.connections)
+	}
+}
+
+func TestDeduplication(t *testing.T) {
+	f := newFakeIstiod(t, false)
+	conn := setupNodeCache(t, f)
+
+	a := connectProxy(t, conn, "a", "a")
+	a.subscribe(v3.ClusterType)
+	a.ack(a.expectResponse(v3.ClusterType, v3.ClusterType+" v1"))
+	b := connectProxy(t, conn, "bb", "bb")
+	b.subscribe(v3.ClusterType)
+	b.ack(b.expectResponse(v3.ClusterType, v3.ClusterType+" v1"))
+	// Istiod sent the same configuration to both proxies, which now share the stream of the first one.
+	f.expectStreams(1)
+
+	f.push()
+	a.ack(a.expectResponse(v3.ClusterType, v3.ClusterType+" v2"))
+	b.ack(b.expectResponse(v3.ClusterType, v3.ClusterType+" v2"))
+
+	// Subscribing to the resources of the leader is served from the cache.
+	a.subscribe(v3.EndpointType, "outbound|80||foo")
+	a.expectResponse(v3.EndpointType, v3.EndpointType+" v2 outbound|80||foo")
+	b.subscribe(v3.EndpointType, "outbound|80||foo")
+	b.expectResponse(v3.EndpointType, v3.EndpointType+" v2 outbound|80||foo")
+	f.expectStreams(1)
+
+	// Diverging from the leader reopens a stream to Istiod.
+	b.subscribe(v3.EndpointType, "outbound|80||bar")
+	f.expectStreams(2)
+	b.expectResponse(v3.ClusterType, v3.ClusterType+" v2")
+	b.expectResponse(v3.EndpointType, v3.EndpointType+" v2 outbound|80||bar")
+}
+
+func TestDeduplicationNack(t *testing.T) {
+	f := newFakeIstiod(t, false)
+	conn := setupNodeCache(t, f)
+
+	a := connectProxy(t, conn, "a", "a")
+	a.subscribe(v3.ClusterType)
+	a.ack(a.expectResponse(v3.ClusterType, v3.ClusterType+" v1"))
+	b := connectProxy(t, conn, "bb", "bb")
+	b.subscribe(v3.ClusterType)
+	b.ack(b.expectResponse(v3.ClusterType, v3.ClusterType+" v1"))
+	f.expectStreams(1)
+
+	f.push()
+	a.ack(a.expectResponse(v3.ClusterType, v3.ClusterType+" v2"))
+	resp := b.expectResponse(v3.ClusterType, v3.ClusterType+" v2")
+	// The rejection of the configuration by the follower reaches Istiod on a stream of its own.
+	b.send(&discovery.DiscoveryRequest{
+		TypeUrl:       resp.TypeUrl,
+		VersionInfo:   "1",
+		ResponseNonce: resp.Nonce,
+		ErrorDetail:   &google_rpc.Status{Code: int32(codes.InvalidArgument), Message: "rejected"},
+	})
+	f.expectStreams(2)
+	b.expectResponse(v3.ClusterType, v3.ClusterType+" v2")
+}
+
+func TestNoDeduplicationOfDifferentConfiguration(t *testing.T) {
+	f := newFakeIstiod(t, true)
+	conn := setupNodeCache(t, f)
+
+	a := connectProxy(t, conn, "a", "a")
+	a.subscribe(v3.ClusterType)
+	a.ack(a.expectResponse(v3.ClusterType, v3.ClusterType+" v1 "+testNode("a", "default").Id))
+	b := connectProxy(t, conn, "bb", "bb")
+	b.subscribe(v3.ClusterType)
+	b.ack(b.expectResponse(v3.ClusterType, v3.ClusterType+" v1 "+testNode("bb", "default").Id))
+	b.expectNoResponse()
+	f.expectStreams(2)
+}
+
+func TestLeaderDisconnects(t *testing.T) {
+	f := newFakeIstiod(t, false)
+	conn := setupNodeCache(t, f)
+
+	a := connectProxy(t, conn, "a", "a")
+	a.subscribe(v3.ClusterType)
+	a.ack(a.expectResponse(v3.ClusterType, v3.ClusterType+" v1"))
+	b := connectProxy(t, conn, "bb", "bb")
+	b.subscribe(v3.ClusterType)
+	b.ack(b.expectResponse(v3.ClusterType, v3.ClusterType+" v1"))
+	f.expectStreams(1)
+
+	// The follower takes over the stream to Istiod.
+	a.cancel()
+	f.expectStreams(1)
+	retry.UntilSuccessOrFail(t, func() error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for s := range f.streams {
+			s.mu.Lock()
+			id := s.node.GetId()
+			s.mu.Unlock()
+			if id != testNode("bb", "default").Id {
+				return fmt.Errorf("unexpected stream of %s", id)
+			}
+		}
+		return nil
+	}, retry.Timeout(5*time.Second), retry.Delay(10*time.Millisecond))
+	b.ack(b.expectResponse(v3.ClusterType, v3.ClusterType+" v1"))
+	f.push()
+	b.expectResponse(v3.ClusterType, v3.ClusterType+" v2")
+}
+
+func TestIstiodOutage(t *testing.T) {
+	f := newFakeIstiod(t, false)
+	conn := setupNodeCache(t, f)
+
+	a := connectProxy(t, conn, "a", "a")
+	a.subscribe(v3.ClusterType)
+	a.ack(a.expectResponse(v3.ClusterType, v3.ClusterType+" v1"))
+
+	// The proxy stays connected while Istiod is unavailable, and its subscriptions are resumed once it is back.
+	f.stop()
+	a.expectNoResponse()
+	f.mu.Lock()
+	f.version++
+	f.mu.Unlock()
+	f.start()
+	a.expectResponse(v3.ClusterType, v3.ClusterType+" v2")
+}
+
+func TestServeCachedConfiguration(t *testing.T) {
+	f := newFakeIstiod(t, false)
+	conn := setupNodeCache(t, f)
+
+	a := connectProxy(t, conn, "a", "a")
+	a.subscribe(v3.ClusterType)
+	a.ack(a.expectResponse(v3.ClusterType, v3.ClusterType+" v1"))
+	a.cancel()
+	f.expectStreams(0)
+	f.stop()
+
+	// The proxy reconnecting with the same credentials is served its last configuration.
+	a = connectProxy(t, conn, "a", "a")
+	a.subscribe(v3.ClusterType)
+	a.expectResponse(v3.ClusterType, v3.ClusterType+" v1")
+
+	// Other credentials are not.
+	other := connectProxy(t, conn, "a", "other")
+	other.subscribe(v3.ClusterType)
+	other.expectNoResponse()
+
+	f.mu.Lock()
+	f.version++
+	f.mu.Unlock()
+	f.start()
+	a.expectResponse(v3.ClusterType, v3.ClusterType+" v2")
+	other.expectResponse(v3.ClusterType, v3.ClusterType+" v2")
+}
+
+func TestRejected(t *testing.T) {
+	f := newFakeIstiod(t, false)
+	conn := setupNodeCache(t, f)
+
+	p := connectProxy(t, conn, "a", "rejected")
+	p.subscribe(v3.ClusterType)
+	select {
+	case err := <-p.errors:
+		if status.Code(err) != codes.Unauthenticated {
+			t.Fatalf("expected the stream to be rejected, got %v", err)
+		}
+	case resp := <-p.responses:
+		t.Fatalf("unexpected response %v", resp)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the stream to be rejected")
+	}
+}
+
+func TestIdentityMismatch(t *testing.T) {
+	f := newFakeIstiod(t, false)
+	conn := setupNodeCache(t, f)
+
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(context.Background(),
+		"authorization", "Bearer a", "identity", "spiffe://cluster.local/ns/other/sa/default"))
+	t.Cleanup(cancel)
+	stream, err := discovery.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, Node: testNode("a", "default")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected the stream of another workload to be denied, got %v", err)
+	}
+	f.expectStreams(0)
+}
+
+func TestDeltaAggregatedResources(t *testing.T) {
+	f := newFakeIstiod(t, false)
+	conn := setupNodeCache(t, f)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer a")
+	stream, err := discovery.NewAggregatedDiscoveryServiceClient(conn).DeltaAggregatedResources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.ClusterType, Node: testNode("a", "default")}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TypeUrl != v3.ClusterType || resp.SystemVersionInfo != "Bearer a" {
+		t.Fatalf("expected the stream to be forwarded with its credentials, got %v", resp)
+	}
+}
+
+func TestGroupKey(t *testing.T) {
+	md := metadata.Pairs("clusterid", "Kubernetes", "authorization", "Bearer a")
+	key := groupKey(testNode("a", "default"), md)
+	if got := groupKey(testNode("bb", "default"), metadata.Pairs("clusterid", "Kubernetes", "authorization", "Bearer b")); got != key {
+		t.Fatalf("expected the pods of a workload to have the same key")
+	}
+	if got := groupKey(testNode("a", "other"), md); got == key {
+		t.Fatalf("expected pods of different namespaces to have different keys")
+	}
+	if got := groupKey(testNode("a", "default"), metadata.Pairs("clusterid", "other")); got == key {
+		t.Fatalf("expected pods of different clusters to have different keys")
+	}
+}
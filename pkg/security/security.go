@@ -154,6 +154,10 @@ type Options struct {
 	// when generating private keys. Currently only ECDSA is supported.
 	ECCSigAlg string
 
+	// The size of the RSA private key to generate, when ECCSigAlg is not set.
+	// Defaults to 2048 if not set.
+	RSAKeySize int
+
 	// FileMountedCerts indicates whether the proxy is using file
 	// mounted certs created by a foreign CA. Refresh is managed by the external
 	// CA, by updating the Secret or VM file. We will watch the file for changes
@@ -219,6 +223,14 @@ type Options struct {
 	KeyFilePath string
 	// The path for an existing root certificate bundle
 	RootCertFilePath string
+
+	// SpireSocketPath is the path of the SPIRE agent socket. If set, workload certificates and the trust
+	// bundle are fetched from the SPIRE agent instead of the CA.
+	SpireSocketPath string
+	// SpireSVIDName is the name of the SVID in the SPIRE agent served as the workload certificate.
+	SpireSVIDName string
+	// SpireBundleName is the name of the trust bundle in the SPIRE agent served as the root certificate.
+	SpireBundleName string
 }
 
 // TokenManager contains methods for generating token.
@@ -15,9 +15,12 @@
 package check
 
 import (
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -249,6 +252,148 @@ func ResponseHeaders(expected map[string]string) Checker {
 	})
 }
 
+// ViaProxy checks that the requests were received through the proxy, which identifies itself with the expected
+// value in the Via header. Requests tunneled with CONNECT cannot be checked, the proxy does not see their headers.
+func ViaProxy(expected string) Checker {
+	return Each(func(r echo.Response) error {
+		via := r.RequestHeaders.Values("Via")
+		for _, v := range via {
+			for _, hop := range strings.Split(v, ",") {
+				if strings.Contains(strings.TrimSpace(hop), expected) {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("expected request via proxy %s, received Via %v", expected, via)
+	})
+}
+
+// NotViaProxy checks that the requests were not received through a proxy adding the Via header.
+func NotViaProxy() Checker {
+	return Each(func(r echo.Response) error {
+		if via := r.RequestHeaders.Values("Via"); len(via) > 0 {
+			return fmt.Errorf("expected request not via proxy, received Via %v", via)
+		}
+		return nil
+	})
+}
+
+// IPv4 checks that the requests were received from IPv4 addresses, and sent to IPv4 addresses if the requested
+// URLs have IP literals.
+func IPv4() Checker {
+	return ipFamily(false)
+}
+
+// IPv6 checks that the requests were received from IPv6 addresses, and sent to IPv6 addresses if the requested
+// URLs have IP literals.
+func IPv6() Checker {
+	return ipFamily(true)
+}
+
+func ipFamily(ipv6 bool) Checker {
+	family := "IPv4"
+	if ipv6 {
+		family = "IPv6"
+	}
+	isFamily := func(ip net.IP) bool {
+		return ip != nil && (ip.To4() == nil) == ipv6
+	}
+	return Each(func(r echo.Response) error {
+		if !isFamily(net.ParseIP(r.IP)) {
+			return fmt.Errorf("expected request received from an %s address, received from %q", family, r.IP)
+		}
+		if u, err := url.Parse(r.RequestURL); err == nil {
+			if ip := net.ParseIP(u.Hostname()); ip != nil && !isFamily(ip) {
+				return fmt.Errorf("expected request sent to an %s address, sent to %s", family, u.Hostname())
+			}
+		}
+		return nil
+	})
+}
+
+// PeerCertIssuer checks that the leaf certificates presented by the requesters to the servers terminating TLS were
+// issued by the expected issuer, matched against the common name or the distinguished name of the issuer.
+func PeerCertIssuer(expected string) Checker {
+	return eachPeerCertChain(func(chain []*x509.Certificate) error {
+		issuer := chain[0].Issuer
+		if issuer.CommonName != expected && issuer.String() != expected {
+			return fmt.Errorf("expected peer certificate issued by %s, issued by %s", expected, issuer)
+		}
+		return nil
+	})
+}
+
+// PeerCertSAN checks that the leaf certificates presented by the requesters to the servers terminating TLS have the
+// expected URI or DNS subject alternative name, such as spiffe://cluster.local/ns/foo/sa/bar.
+func PeerCertSAN(expected string) Checker {
+	return eachPeerCertChain(func(chain []*x509.Certificate) error {
+		leaf := chain[0]
+		sans := append([]string{}, leaf.DNSNames...)
+		for _, uri := range leaf.URIs {
+			sans = append(sans, uri.String())
+		}
+		for _, san := range sans {
+			if san == expected {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected peer certificate with SAN %s, received %v", expected, sans)
+	})
+}
+
+// PeerCertChainDepth checks that the certificate chains presented by the requesters to the servers terminating TLS
+// have the expected number of certificates, including the leaf.
+func PeerCertChainDepth(expected int) Checker {
+	return eachPeerCertChain(func(chain []*x509.Certificate) error {
+		if len(chain) != expected {
+			return fmt.Errorf("expected peer certificate chain of depth %d, received %d", expected, len(chain))
+		}
+		return nil
+	})
+}
+
+// PeerCertChainValid checks that each certificate of the chains presented by the requesters to the servers
+// terminating TLS is signed by the next one. If roots are set, the chains must also be verified by one of them.
+func PeerCertChainValid(roots *x509.CertPool) Checker {
+	return eachPeerCertChain(func(chain []*x509.Certificate) error {
+		for i := 0; i < len(chain)-1; i++ {
+			if err := chain[i].CheckSignatureFrom(chain[i+1]); err != nil {
+				return fmt.Errorf("peer certificate %d (%s) is not signed by the next certificate of the chain (%s): %v",
+					i, chain[i].Subject, chain[i+1].Subject, err)
+			}
+		}
+		if roots == nil {
+			return nil
+		}
+		intermediates := x509.NewCertPool()
+		for _, c := range chain[1:] {
+			intermediates.AddCert(c)
+		}
+		_, err := chain[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			return fmt.Errorf("peer certificate chain is not verified by the roots: %v", err)
+		}
+		return nil
+	})
+}
+
+func eachPeerCertChain(c func(chain []*x509.Certificate) error) Checker {
+	return Each(func(r echo.Response) error {
+		chain, err := r.PeerCertificateChain()
+		if err != nil {
+			return err
+		}
+		if len(chain) == 0 {
+			return errors.New("no peer certificate received")
+		}
+		return c(chain)
+	})
+}
+
 func Cluster(expected string) Checker {
 	return Each(func(r echo.Response) error {
 		if r.Cluster != expected {
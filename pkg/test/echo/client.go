@@ -119,6 +119,20 @@ func (c *Client) ForwardEcho(ctx context.Context, request *proto.ForwardEchoRequ
 	return ParseResponses(request, resp), nil
 }
 
+// ForwardEchoLoad sends the given forward request in load mode, with LoadDurationMicros set, and returns the
+// latency distribution of the requests.
+func (c *Client) ForwardEchoLoad(ctx context.Context, request *proto.ForwardEchoRequest) (*proto.LoadResult, error) {
+	if request.LoadDurationMicros <= 0 {
+		return nil, fmt.Errorf("load duration must be set for load requests")
+	}
+	resp, err := c.client.ForwardEcho(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	GlobalEchoRequests.Add(uint64(resp.GetLoad().GetRequests() + resp.GetLoad().GetErrors()))
+	return resp.GetLoad(), nil
+}
+
 // GlobalEchoRequests records how many echo calls we have made total, from all sources.
 // Note: go tests are distinct binaries per test suite, so this is the suite level number of calls
 var GlobalEchoRequests = atomic.NewUint64(0)
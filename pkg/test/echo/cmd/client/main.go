@@ -56,6 +56,9 @@ var (
 	followRedirects    bool
 	clientCert         string
 	clientKey          string
+	proxy              string
+	loadDuration       time.Duration
+	concurrency        int
 
 	caFile string
 
@@ -105,6 +108,9 @@ where the network configuration doesn't support gRPC to the source pod.'
 			for _, line := range response.Output {
 				fmt.Println(line)
 			}
+			if load := response.GetLoad(); load != nil {
+				printLoadResult(load)
+			}
 
 			log.Infof("All requests succeeded")
 		},
@@ -146,6 +152,11 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&clientKey, "client-key", "", "client certificate key file to use for request")
 	rootCmd.PersistentFlags().StringSliceVarP(&alpn, "alpn", "", nil, "alpn to set")
 	rootCmd.PersistentFlags().StringVarP(&serverName, "server-name", "", serverName, "server name to set")
+	rootCmd.PersistentFlags().StringVarP(&proxy, "proxy", "x", "",
+		"send http requests through the HTTP or SOCKS proxy with this URL, following curl syntax")
+	rootCmd.PersistentFlags().DurationVar(&loadDuration, "load-duration", 0,
+		"If set, send requests for this duration at the --qps rate and report their latency distribution, rather than --count requests")
+	rootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", 1, "Number of requests in flight with --load-duration")
 
 	loggingOptions.AttachCobraFlags(rootCmd)
 
@@ -178,6 +189,9 @@ func getRequest(url string) (*proto.ForwardEchoRequest, error) {
 		Method:             method,
 		ServerName:         serverName,
 		InsecureSkipVerify: insecureSkipVerify,
+		Proxy:              proxy,
+		LoadDurationMicros: common.DurationToMicros(loadDuration),
+		Concurrency:        int32(concurrency),
 	}
 
 	if expectSet {
@@ -212,6 +226,16 @@ func getRequest(url string) (*proto.ForwardEchoRequest, error) {
 	return request, nil
 }
 
+func printLoadResult(load *proto.LoadResult) {
+	fmt.Printf("Requests: %d, errors: %d, duration: %v, qps: %.1f\n", load.Requests, load.Errors,
+		common.MicrosToDuration(load.DurationMicros), load.Qps)
+	fmt.Printf("Latency p50: %v, p90: %v, p99: %v, max: %v\n", common.MicrosToDuration(load.P50Micros),
+		common.MicrosToDuration(load.P90Micros), common.MicrosToDuration(load.P99Micros), common.MicrosToDuration(load.MaxMicros))
+	for _, b := range load.Histogram {
+		fmt.Printf("  <= %-10v %d\n", common.MicrosToDuration(b.UpperBoundMicros), b.Count)
+	}
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(-1)
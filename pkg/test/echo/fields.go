@@ -37,4 +37,7 @@ const (
 	ClusterField        Field = "Cluster"
 	IstioVersionField   Field = "IstioVersion"
 	IPField             Field = "IP" // The Requester’s IP Address.
+	// PeerCertificateField is a certificate presented by the requester over TLS, base64 encoded in DER form. The
+	// field is repeated for each certificate of the chain, in order, starting with the leaf.
+	PeerCertificateField Field = "PeerCertificate"
 )
@@ -38,6 +38,7 @@ var (
 	methodFieldRegex         = regexp.MustCompile(string(MethodField) + "=(.*)")
 	protocolFieldRegex       = regexp.MustCompile(string(ProtocolField) + "=(.*)")
 	alpnFieldRegex           = regexp.MustCompile(string(AlpnField) + "=(.*)")
+	peerCertificateRegex     = regexp.MustCompile(string(PeerCertificateField) + "=(.*)")
 )
 
 func ParseResponses(req *proto.ForwardEchoRequest, resp *proto.ForwardEchoResponse) Responses {
@@ -121,6 +122,10 @@ func parseResponse(output string) Response {
 		out.IP = match[1]
 	}
 
+	for _, m := range peerCertificateRegex.FindAllStringSubmatch(output, -1) {
+		out.PeerCertificates = append(out.PeerCertificates, m[1])
+	}
+
 	out.rawBody = map[string]string{}
 
 	matches := requestHeaderFieldRegex.FindAllStringSubmatch(output, -1)
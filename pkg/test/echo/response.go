@@ -15,6 +15,8 @@
 package echo
 
 import (
+	"crypto/x509"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"sort"
@@ -62,6 +64,9 @@ type Response struct {
 	IstioVersion string
 	// IP is the requester's ip address
 	IP string
+	// PeerCertificates is the certificate chain presented by the requester over TLS, if the server terminated TLS.
+	// The certificates are base64 encoded in DER form, starting with the leaf.
+	PeerCertificates []string
 	// rawBody gives a map of all key/values in the body of the response.
 	rawBody         map[string]string
 	RequestHeaders  http.Header
@@ -105,6 +110,23 @@ func (r Response) Body() []string {
 	return resp
 }
 
+// PeerCertificateChain returns the parsed certificate chain presented by the requester, starting with the leaf.
+func (r Response) PeerCertificateChain() ([]*x509.Certificate, error) {
+	chain := make([]*x509.Certificate, 0, len(r.PeerCertificates))
+	for i, c := range r.PeerCertificates {
+		der, err := base64.StdEncoding.DecodeString(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer certificate %d: %v", i, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer certificate %d: %v", i, err)
+		}
+		chain = append(chain, cert)
+	}
+	return chain, nil
+}
+
 func (r Response) String() string {
 	out := ""
 	out += fmt.Sprintf("RawContent:       %s\n", r.RawContent)
@@ -121,6 +143,7 @@ func (r Response) String() string {
 	out += fmt.Sprintf("Cluster:          %s\n", r.Cluster)
 	out += fmt.Sprintf("IstioVersion:     %s\n", r.IstioVersion)
 	out += fmt.Sprintf("IP:               %s\n", r.IP)
+	out += fmt.Sprintf("PeerCertificates: %d\n", len(r.PeerCertificates))
 	out += fmt.Sprintf("Request Headers:  %v\n", r.RequestHeaders)
 	out += fmt.Sprintf("Response Headers: %v\n", r.ResponseHeaders)
 
@@ -17,6 +17,7 @@ package endpoint
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net"
@@ -96,11 +97,13 @@ func (s *grpcInstance) Start(onReady OnReadyFunc) error {
 	var opts []grpc.ServerOption
 	if s.Port.TLS {
 		epLog.Infof("Listening GRPC (over TLS) on %v", p)
-		// Create the TLS credentials
-		creds, errCreds := credentials.NewServerTLSFromFile(s.TLSCert, s.TLSKey)
+		// Create the TLS credentials. Client certificates are requested, but not verified, to report the chain in
+		// the response.
+		cert, errCreds := tls.LoadX509KeyPair(s.TLSCert, s.TLSKey)
 		if errCreds != nil {
 			epLog.Errorf("could not load TLS keys: %s", errCreds)
 		}
+		creds := credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}, ClientAuth: tls.RequestClientCert})
 		opts = append(opts, grpc.Creds(creds))
 	} else if s.Port.XDSServer {
 		epLog.Infof("Listening GRPC (over xDS-configured mTLS) on %v", p)
@@ -269,6 +272,9 @@ func (h *grpcHandler) Echo(ctx context.Context, req *proto.EchoRequest) (*proto.
 	ip := "0.0.0.0"
 	if peerInfo, ok := peer.FromContext(ctx); ok {
 		ip, _, _ = net.SplitHostPort(peerInfo.Addr.String())
+		if tlsInfo, ok := peerInfo.AuthInfo.(credentials.TLSInfo); ok {
+			writePeerCertificates(&body, tlsInfo.State.PeerCertificates)
+		}
 	}
 
 	writeField(&body, echo.StatusCodeField, strconv.Itoa(http.StatusOK))
@@ -93,6 +93,8 @@ func (s *httpInstance) Start(onReady OnReadyFunc) error {
 		config := &tls.Config{
 			Certificates: []tls.Certificate{cert},
 			NextProtos:   nextProtos,
+			// Client certificates are requested, but not verified, to report the chain in the response.
+			ClientAuth: tls.RequestClientCert,
 			GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
 				// There isn't a way to pass through all ALPNs presented by the client down to the
 				// HTTP server to return in the response. However, for debugging, we can at least log
@@ -246,6 +248,13 @@ func (h *httpHandler) echo(w http.ResponseWriter, r *http.Request, id uuid.UUID)
 		writeError(&body, "error delaying response error: "+err.Error())
 	}
 
+	// If the request has form ?reset=true abort the request without responding, after any delay.
+	// HTTP/1 connections are closed and HTTP/2 streams are reset.
+	if r.FormValue("reset") == "true" {
+		epLog.WithLabels("id", id).Infof("HTTP Response reset")
+		panic(http.ErrAbortHandler)
+	}
+
 	// If the request has form ?headers=name:value[,name:value]* return those headers in response
 	if err := setHeaderResponseFromHeaders(r, w); err != nil {
 		writeError(&body, "response headers error: "+err.Error())
@@ -261,8 +270,22 @@ func (h *httpHandler) echo(w http.ResponseWriter, r *http.Request, id uuid.UUID)
 
 	h.addResponsePayload(r, &body)
 
+	// If the request has form ?size=[:bytes] pad the body to at least that many bytes
+	// For example, ?size=1048576 will return a response of 1MiB
+	if err := padResponse(r, &body); err != nil {
+		writeError(&body, "size error: "+err.Error())
+	}
+
+	// If the request has form ?chunks=[:count] stream the body in that many chunks, flushing each one.
+	// ?chunkDelay=[:duration] waits for duration between chunks.
+	// For example, ?chunks=5&chunkDelay=1s will stream the response over 4s
+	chunks, chunkDelay, err := parseChunks(r)
+	if err != nil {
+		writeError(&body, "chunks error: "+err.Error())
+	}
+
 	w.Header().Set("Content-Type", "application/text")
-	if _, err := w.Write(body.Bytes()); err != nil {
+	if err := writeChunks(w, body.Bytes(), chunks, chunkDelay); err != nil {
 		epLog.Warn(err)
 	}
 	epLog.WithLabels("code", code, "headers", w.Header(), "id", id).Infof("HTTP Response")
@@ -325,6 +348,7 @@ func (h *httpHandler) addResponsePayload(r *http.Request, body *bytes.Buffer) {
 	var alpn string
 	if r.TLS != nil {
 		alpn = r.TLS.NegotiatedProtocol
+		writePeerCertificates(body, r.TLS.PeerCertificates)
 	}
 	writeField(body, echo.AlpnField, alpn)
 
@@ -359,6 +383,81 @@ func delayResponse(request *http.Request) error {
 	return nil
 }
 
+// maxResponseSize bounds the size a request can ask the response to be padded to.
+const maxResponseSize = 64 * 1024 * 1024
+
+// nolint: interfacer
+func padResponse(request *http.Request, body *bytes.Buffer) error {
+	s := request.FormValue("size")
+	if len(s) == 0 {
+		return nil
+	}
+	size, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	if size < 0 || size > maxResponseSize {
+		return fmt.Errorf("invalid size %v (want 0-%d)", size, maxResponseSize)
+	}
+	// Pad with a single line that does not match any response field.
+	if pad := size - body.Len(); pad > 0 {
+		body.Write(bytes.Repeat([]byte{'-'}, pad-1))
+		body.WriteByte('\n')
+	}
+	return nil
+}
+
+func parseChunks(request *http.Request) (int, time.Duration, error) {
+	s := request.FormValue("chunks")
+	if len(s) == 0 {
+		return 1, 0, nil
+	}
+	chunks, err := strconv.Atoi(s)
+	if err != nil {
+		return 1, 0, err
+	}
+	if chunks < 1 {
+		return 1, 0, fmt.Errorf("invalid chunks %v", chunks)
+	}
+	var delay time.Duration
+	if d := request.FormValue("chunkDelay"); len(d) > 0 {
+		if delay, err = time.ParseDuration(d); err != nil {
+			return chunks, 0, err
+		}
+	}
+	return chunks, delay, nil
+}
+
+// writeChunks writes the body in the given number of chunks, flushing after each one so that
+// they are sent to the client separately.
+func writeChunks(w http.ResponseWriter, body []byte, chunks int, delay time.Duration) error {
+	if chunks <= 1 {
+		_, err := w.Write(body)
+		return err
+	}
+	flusher, _ := w.(http.Flusher)
+	size := (len(body) + chunks - 1) / chunks
+	for i := 0; i < chunks; i++ {
+		if i > 0 && delay > 0 {
+			time.Sleep(delay)
+		}
+		start, end := i*size, (i+1)*size
+		if start > len(body) {
+			start = len(body)
+		}
+		if end > len(body) {
+			end = len(body)
+		}
+		if _, err := w.Write(body[start:end]); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	return nil
+}
+
 func setHeaderResponseFromHeaders(request *http.Request, response http.ResponseWriter) error {
 	s := request.FormValue("headers")
 	if len(s) == 0 {
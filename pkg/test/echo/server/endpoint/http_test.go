@@ -0,0 +1,85 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoint
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/echo"
+)
+
+func TestHTTPResponseBehaviors(t *testing.T) {
+	srv := httptest.NewServer(&httpHandler{Config: Config{IsServerReady: func() bool { return true }}})
+	defer srv.Close()
+
+	get := func(t *testing.T, query string) (*http.Response, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(b)
+	}
+
+	t.Run("size", func(t *testing.T) {
+		_, body := get(t, "size=4096")
+		if len(body) != 4096 {
+			t.Fatalf("expected 4096 bytes, got %d", len(body))
+		}
+		if !strings.Contains(body, string(echo.URLField)+"=/?size=4096") {
+			t.Fatalf("padding replaced response fields: %q", body[:200])
+		}
+	})
+	t.Run("invalid size", func(t *testing.T) {
+		_, body := get(t, "size=-1")
+		if !strings.Contains(body, "size error") {
+			t.Fatalf("expected size error, got %q", body)
+		}
+	})
+	t.Run("chunks", func(t *testing.T) {
+		start := time.Now()
+		resp, body := get(t, "chunks=3&chunkDelay=50ms")
+		if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+			t.Fatalf("expected chunk delays to be applied, took %v", elapsed)
+		}
+		if len(resp.TransferEncoding) == 0 || resp.TransferEncoding[0] != "chunked" {
+			t.Fatalf("expected chunked response, got %v", resp.TransferEncoding)
+		}
+		if !strings.Contains(body, string(echo.URLField)+"=/?chunks=3&chunkDelay=50ms") {
+			t.Fatalf("unexpected body %q", body)
+		}
+	})
+	t.Run("codes", func(t *testing.T) {
+		resp, _ := get(t, "codes=503")
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected 503, got %d", resp.StatusCode)
+		}
+	})
+	t.Run("reset", func(t *testing.T) {
+		if _, err := http.Get(srv.URL + "/?reset=true"); err == nil {
+			t.Fatal("expected request to be reset")
+		}
+	})
+}
@@ -15,6 +15,7 @@
 package endpoint
 
 import (
+	"bytes"
 	"crypto/tls"
 	"fmt"
 	"io"
@@ -55,7 +56,8 @@ func (s *tcpInstance) Start(onReady OnReadyFunc) error {
 		if cerr != nil {
 			return fmt.Errorf("could not load TLS keys: %v", cerr)
 		}
-		config := &tls.Config{Certificates: []tls.Certificate{cert}}
+		// Client certificates are requested, but not verified, to report the chain in the response.
+		config := &tls.Config{Certificates: []tls.Certificate{cert}, ClientAuth: tls.RequestClientCert}
 		// Listen on the given port and update the port if it changed from what was passed in.
 		listener, port, err = listenOnAddressTLS(s.ListenerIP, s.Port.Port, config)
 		// Store the actual listening port back to the argument.
@@ -162,7 +164,15 @@ func (s *tcpInstance) writeResponse(conn net.Conn) {
 		_, err := conn.Write([]byte(val))
 		if err != nil {
 			epLog.Warnf("TCP write failed %q: %v", val, err)
-			break
+			return
+		}
+	}
+	// The handshake is complete once the request is read.
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		body := bytes.Buffer{}
+		writePeerCertificates(&body, tlsConn.ConnectionState().PeerCertificates)
+		if _, err := conn.Write(body.Bytes()); err != nil {
+			epLog.Warnf("TCP write failed: %v", err)
 		}
 	}
 }
@@ -17,6 +17,8 @@ package endpoint
 import (
 	"bytes"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"net"
 	"os"
 	"strconv"
@@ -62,6 +64,13 @@ func writeField(out *bytes.Buffer, field echo.Field, value string) {
 	_, _ = out.WriteString(string(field) + "=" + value + "\n")
 }
 
+// writePeerCertificates writes the certificate chain presented by the requester.
+func writePeerCertificates(out *bytes.Buffer, certs []*x509.Certificate) {
+	for _, cert := range certs {
+		writeField(out, echo.PeerCertificateField, base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+}
+
 // nolint: interfacer
 func writeRequestHeader(out *bytes.Buffer, key, value string) {
 	writeField(out, echo.RequestHeaderField, key+":"+value)
@@ -42,7 +42,7 @@ type Config struct {
 
 	// XDSTestBootstrap, for gRPC forwarders, is used to set the bootstrap without using a global one defined in the env
 	XDSTestBootstrap []byte
-	// Http proxy used for connection
+	// Http proxy used for connection, unless the request has its own proxy.
 	Proxy string
 }
 
@@ -65,6 +65,9 @@ type Instance struct {
 	// Method for the request. Only valid for HTTP
 	method           string
 	expectedResponse *wrappers.StringValue
+	// loadDuration, if set, is the duration requests are sent for in load mode, by concurrency workers.
+	loadDuration time.Duration
+	concurrency  int
 }
 
 // New creates a new forwarder Instance.
@@ -76,6 +79,14 @@ func New(cfg Config) (*Instance, error) {
 		return nil, err
 	}
 
+	concurrency := int(cfg.Request.Concurrency)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > maxLoadConcurrency {
+		return nil, fmt.Errorf("concurrency %d exceeds the maximum of %d", concurrency, maxLoadConcurrency)
+	}
+
 	return &Instance{
 		p:                p,
 		url:              cfg.Request.Url,
@@ -87,11 +98,16 @@ func New(cfg Config) (*Instance, error) {
 		header:           common.GetHeaders(cfg.Request),
 		message:          cfg.Request.Message,
 		expectedResponse: cfg.Request.ExpectedResponse,
+		loadDuration:     common.MicrosToDuration(cfg.Request.LoadDurationMicros),
+		concurrency:      concurrency,
 	}, nil
 }
 
 // Run the forwarder and collect the responses.
 func (i *Instance) Run(ctx context.Context) (*proto.ForwardEchoResponse, error) {
+	if i.loadDuration > 0 {
+		return i.runLoad(ctx)
+	}
 	g := multierror.Group{}
 	responsesMu := sync.RWMutex{}
 	responses, responseTimes := make([]string, i.count), make([]time.Duration, i.count)
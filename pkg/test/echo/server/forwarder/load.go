@@ -0,0 +1,170 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forwarder
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/common"
+	"istio.io/istio/pkg/test/echo/proto"
+)
+
+// maxLoadConcurrency bounds the number of requests in flight in load mode.
+const maxLoadConcurrency = 256
+
+// latencyBuckets are the upper bounds of the buckets of the latency histogram reported in load mode. Latencies above
+// the last bound are counted in a final bucket bounded by the maximum latency.
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// runLoad sends requests until the load duration elapses, at the target qps if set, and reports their latency
+// distribution rather than their output.
+func (i *Instance) runLoad(ctx context.Context) (*proto.ForwardEchoResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, i.loadDuration+i.timeout)
+	defer cancel()
+
+	var throttle <-chan time.Time
+	if i.qps > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(i.qps))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	mu := sync.Mutex{}
+	var (
+		latencies []time.Duration
+		errs      int64
+		firstErr  error
+		next      int
+	)
+	start := time.Now()
+	deadline := start.Add(i.loadDuration)
+	wg := sync.WaitGroup{}
+	for w := 0; w < i.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if throttle != nil {
+					select {
+					case <-throttle:
+					case <-ctx.Done():
+						return
+					}
+				}
+				if time.Now().After(deadline) {
+					return
+				}
+				mu.Lock()
+				id := next
+				next++
+				mu.Unlock()
+				r := request{
+					RequestID:        id,
+					URL:              i.url,
+					Message:          i.message,
+					ExpectedResponse: i.expectedResponse,
+					Header:           i.header,
+					Timeout:          i.timeout,
+					ServerFirst:      i.serverFirst,
+					Method:           i.method,
+				}
+				st := time.Now()
+				_, err := i.p.makeRequest(ctx, &r)
+				rt := time.Since(st)
+				mu.Lock()
+				if err != nil {
+					errs++
+					if firstErr == nil {
+						firstErr = err
+					}
+				} else {
+					latencies = append(latencies, rt)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	result := loadResult(latencies, time.Since(start))
+	result.Errors = errs
+	if len(latencies) == 0 && firstErr != nil {
+		return nil, fmt.Errorf("all %d requests had errors; first error: %v", errs, firstErr)
+	}
+	return &proto.ForwardEchoResponse{Load: result}, nil
+}
+
+// loadResult computes the latency distribution of the successful requests.
+func loadResult(latencies []time.Duration, elapsed time.Duration) *proto.LoadResult {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	res := &proto.LoadResult{
+		Requests:       int64(len(latencies)),
+		DurationMicros: common.DurationToMicros(elapsed),
+	}
+	if elapsed > 0 {
+		res.Qps = float64(len(latencies)) / elapsed.Seconds()
+	}
+	if len(latencies) == 0 {
+		return res
+	}
+	percentile := func(p float64) int64 {
+		idx := int(p*float64(len(latencies))+0.5) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		if idx >= len(latencies) {
+			idx = len(latencies) - 1
+		}
+		return common.DurationToMicros(latencies[idx])
+	}
+	res.P50Micros = percentile(0.5)
+	res.P90Micros = percentile(0.9)
+	res.P99Micros = percentile(0.99)
+	res.MaxMicros = common.DurationToMicros(latencies[len(latencies)-1])
+
+	j := 0
+	for _, bound := range latencyBuckets {
+		n := int64(0)
+		for j < len(latencies) && latencies[j] <= bound {
+			n++
+			j++
+		}
+		if n > 0 {
+			res.Histogram = append(res.Histogram, &proto.LatencyBucket{UpperBoundMicros: common.DurationToMicros(bound), Count: n})
+		}
+	}
+	if rest := len(latencies) - j; rest > 0 {
+		res.Histogram = append(res.Histogram, &proto.LatencyBucket{UpperBoundMicros: res.MaxMicros, Count: int64(rest)})
+	}
+	return res
+}
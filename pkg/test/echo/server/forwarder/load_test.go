@@ -0,0 +1,87 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forwarder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/common"
+	"istio.io/istio/pkg/test/echo/proto"
+)
+
+func TestLoadResult(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	latencies = append(latencies, 20*time.Second)
+	res := loadResult(latencies, 2*time.Second)
+	if res.Requests != 101 || res.Qps != 50.5 {
+		t.Fatalf("unexpected requests %d and qps %v", res.Requests, res.Qps)
+	}
+	if res.P50Micros != 51000 || res.P90Micros != 91000 || res.P99Micros != 100000 || res.MaxMicros != 20000000 {
+		t.Fatalf("unexpected percentiles %+v", res)
+	}
+	var total int64
+	for _, b := range res.Histogram {
+		total += b.Count
+	}
+	if total != 101 {
+		t.Fatalf("expected the histogram to count all requests, got %d", total)
+	}
+	first, last := res.Histogram[0], res.Histogram[len(res.Histogram)-1]
+	if first.UpperBoundMicros != 1000 || first.Count != 1 {
+		t.Fatalf("unexpected first bucket %+v", first)
+	}
+	if last.UpperBoundMicros != res.MaxMicros || last.Count != 1 {
+		t.Fatalf("unexpected overflow bucket %+v", last)
+	}
+}
+
+func TestRunLoad(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("StatusCode=200\n"))
+	}))
+	defer srv.Close()
+
+	i, err := New(Config{Request: &proto.ForwardEchoRequest{
+		Url:                srv.URL,
+		Qps:                50,
+		Concurrency:        2,
+		LoadDurationMicros: common.DurationToMicros(500 * time.Millisecond),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer i.Close()
+	resp, err := i.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	load := resp.GetLoad()
+	if load == nil || len(resp.Output) != 0 {
+		t.Fatalf("expected only a load result, got %v", resp)
+	}
+	if load.Errors != 0 || load.Requests < 10 || load.Requests > 30 {
+		t.Fatalf("expected about 25 successful requests, got %+v", load)
+	}
+	if len(load.Histogram) == 0 || load.P99Micros == 0 {
+		t.Fatalf("expected a latency distribution, got %+v", load)
+	}
+}
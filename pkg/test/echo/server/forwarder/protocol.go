@@ -183,7 +183,13 @@ func newProtocol(cfg Config) (protocol, error) {
 			},
 			do: cfg.Dialer.HTTP,
 		}
+		if len(cfg.Request.Proxy) > 0 {
+			// The proxy of the request takes precedence over the proxy of the forwarder.
+			cfg.Proxy = cfg.Request.Proxy
+		}
 		if len(cfg.Proxy) > 0 {
+			// HTTPS requests are tunneled with CONNECT through HTTP proxies, HTTP requests are sent to the proxy
+			// with an absolute URL. socks5:// proxies are supported as well.
 			proxyURL, err := url.Parse(cfg.Proxy)
 			if err != nil {
 				return nil, err
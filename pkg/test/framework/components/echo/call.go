@@ -15,24 +15,48 @@
 package echo
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
 	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
 
+	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/test/echo/check"
+	"istio.io/istio/pkg/test/echo/common"
 	"istio.io/istio/pkg/test/echo/common/scheme"
 )
 
+// Target is the destination of a call. It is either a single Instance, or the Instances of a single service, in
+// which case the call is made to the service.
+type Target interface {
+	// Config returns the configuration of the target.
+	Config() Config
+}
+
+var (
+	_ Target = Instance(nil)
+	_ Target = Instances{}
+)
+
 // CallOptions defines options for calling a Endpoint.
 type CallOptions struct {
-	// Target instance of the call. Required.
-	Target Instance
+	// To is the target of the call. If not set, Port.ServicePort, Address and either Port.Protocol or Scheme
+	// must be set.
+	To Target
 
-	// Port on the target Instance. Either Port or PortName must be specified.
+	// Port to call. When To is set, the port of the target matching the Name, Protocol and ServicePort fields
+	// that are set is used, so setting only one of them is enough if it identifies a single port.
 	Port *Port
 
-	// PortName of the port on the target Instance. Either Port or PortName must be specified.
+	// Target instance of the call. Kept for the suites that have not moved to To yet; new calls should set To.
+	// Setting both is an error unless Target is one of the instances of To. Once the defaults are filled in, it
+	// is an instance of To, the first one if To is the Instances of a service.
+	Target Instance
+
+	// PortName of the port on the target. Kept for the suites that have not moved to Port.Name yet; new calls
+	// should set Port.Name.
 	PortName string
 
 	// Scheme to be used when making the call. If not provided, an appropriate default for the
@@ -91,7 +115,9 @@ type CallOptions struct {
 	// will be checked.
 	Check check.Checker
 
-	// HTTProxy used for making ingress echo call via proxy
+	// HTTPProxy is the URL of the proxy the HTTP requests are sent through, such as the egress gateway or an
+	// external proxy: http:// URLs for HTTP proxies, which tunnel HTTPS requests with CONNECT, or socks5:// URLs
+	// for SOCKS proxies. Ignored for HTTP/2, HTTP/3 and non-HTTP calls.
 	HTTPProxy string
 
 	Alpn       []string
@@ -106,9 +132,11 @@ func (o CallOptions) GetHost() string {
 		return o.Headers["Host"][0]
 	}
 
+	to := o.target()
+
 	// Next use the target's default, if specified.
-	if o.Target != nil && len(o.Target.Config().DefaultHostHeader) > 0 {
-		return o.Target.Config().DefaultHostHeader
+	if to != nil && len(to.Config().DefaultHostHeader) > 0 {
+		return to.Config().DefaultHostHeader
 	}
 
 	// Next, if the Address was manually specified use it as the Host.
@@ -117,8 +145,8 @@ func (o CallOptions) GetHost() string {
 	}
 
 	// Finally, use the target's FQDN.
-	if o.Target != nil {
-		return o.Target.Config().ClusterLocalFQDN()
+	if to != nil {
+		return to.Config().ClusterLocalFQDN()
 	}
 
 	return ""
@@ -136,3 +164,170 @@ func (o CallOptions) DeepCopy() CallOptions {
 	}
 	return clone
 }
+
+// target returns To, or the deprecated Target if To is not set.
+func (o CallOptions) target() Target {
+	if o.To != nil {
+		return o.To
+	}
+	if o.Target != nil {
+		return o.Target
+	}
+	return nil
+}
+
+// FillDefaults validates the options and fills in the defaults of the fields that are not set. The deprecated
+// Target and PortName fields are converted to To and Port.
+func (o *CallOptions) FillDefaults() error {
+	if err := o.fillTarget(); err != nil {
+		return err
+	}
+	if err := o.fillPort(); err != nil {
+		return err
+	}
+
+	if o.Scheme == "" {
+		// No protocol, fill it in.
+		var err error
+		if o.Scheme, err = schemeForPort(o.Port); err != nil {
+			return err
+		}
+	}
+
+	if o.Address == "" {
+		// No host specified, use the fully qualified domain name for the service.
+		o.Address = o.To.Config().ClusterLocalFQDN()
+	}
+
+	// Initialize the headers and add a default Host header if none provided.
+	if o.Headers == nil {
+		o.Headers = make(http.Header)
+	} else {
+		// Avoid mutating input, which can lead to concurrent writes
+		o.Headers = o.Headers.Clone()
+	}
+
+	if h := o.GetHost(); len(h) > 0 {
+		o.Headers["Host"] = []string{h}
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = common.DefaultRequestTimeout
+	}
+
+	if o.Count <= 0 {
+		o.Count = common.DefaultCount
+	}
+
+	// If no Check was specified, assume no error.
+	if o.Check == nil {
+		o.Check = check.None()
+	}
+	return nil
+}
+
+func (o *CallOptions) fillTarget() error {
+	if o.To == nil {
+		o.To = o.Target
+	}
+
+	// Keep the deprecated field in sync for code still reading it.
+	switch to := o.To.(type) {
+	case Instance:
+		if o.Target != nil && o.Target != to {
+			return errors.New("callOptions: To and Target refer to different targets")
+		}
+		o.Target = to
+	case Instances:
+		if len(to) == 0 {
+			return errors.New("callOptions: To has no instances")
+		}
+		if !to.IsDeployment() {
+			return fmt.Errorf("callOptions: To must be the instances of a single service, got %v",
+				to.Services().FQDNs())
+		}
+		if o.Target != nil && !to.Contains(o.Target) {
+			return errors.New("callOptions: Target is not one of the instances of To")
+		}
+		if o.Target == nil {
+			o.Target = to[0]
+		}
+	}
+	return nil
+}
+
+func (o *CallOptions) fillPort() error {
+	if o.PortName != "" {
+		if o.Port != nil && o.Port.Name != "" && o.Port.Name != o.PortName {
+			return fmt.Errorf("callOptions: PortName %s does not match Port.Name %s", o.PortName, o.Port.Name)
+		}
+		// Avoid mutating the port of the caller.
+		p := Port{}
+		if o.Port != nil {
+			p = *o.Port
+		}
+		p.Name = o.PortName
+		o.Port = &p
+	}
+
+	if o.To == nil {
+		if o.Scheme == scheme.DNS {
+			// Just need address
+			if o.Address == "" {
+				return fmt.Errorf("for DNS, address must be set")
+			}
+			o.Port = &Port{}
+			return nil
+		}
+		if o.Port == nil || o.Port.ServicePort == 0 || (o.Port.Protocol == "" && o.Scheme == "") || o.Address == "" {
+			return fmt.Errorf("if target is not set, then port.servicePort, port.protocol or schema, and address must be set")
+		}
+		return nil
+	}
+
+	if o.Port == nil || (o.Port.Name == "" && o.Port.Protocol == "" && o.Port.ServicePort == 0) {
+		return errors.New("callOptions: Port.Name, Port.Protocol or Port.ServicePort must be provided")
+	}
+	ports := o.To.Config().Ports
+	var match *Port
+	for i, p := range ports {
+		if !o.Port.matches(p) {
+			continue
+		}
+		if match != nil {
+			return fmt.Errorf("callOptions: ports %s and %s of %s both match name=%q protocol=%q servicePort=%d",
+				match.Name, p.Name, o.To.Config().Service, o.Port.Name, o.Port.Protocol, o.Port.ServicePort)
+		}
+		match = &ports[i]
+	}
+	if match == nil {
+		return fmt.Errorf("callOptions: no port of %s matches name=%q protocol=%q servicePort=%d",
+			o.To.Config().Service, o.Port.Name, o.Port.Protocol, o.Port.ServicePort)
+	}
+	o.Port = match
+	o.PortName = match.Name
+	return nil
+}
+
+// matches returns true if the Name, Protocol and ServicePort fields of p that are set equal those of port.
+func (p Port) matches(port Port) bool {
+	return (p.Name == "" || p.Name == port.Name) &&
+		(p.Protocol == "" || p.Protocol == port.Protocol) &&
+		(p.ServicePort == 0 || p.ServicePort == port.ServicePort)
+}
+
+func schemeForPort(port *Port) (scheme.Instance, error) {
+	switch port.Protocol {
+	case protocol.GRPC, protocol.GRPCWeb, protocol.HTTP2:
+		return scheme.GRPC, nil
+	case protocol.HTTP:
+		return scheme.HTTP, nil
+	case protocol.HTTPS:
+		return scheme.HTTPS, nil
+	case protocol.TCP:
+		return scheme.TCP, nil
+	default:
+		return "", fmt.Errorf("failed creating call for port %s: unsupported protocol %s",
+			port.Name, port.Protocol)
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package echo
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test/echo/common/scheme"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+)
+
+type fakeInstance struct {
+	Instance
+	cfg Config
+}
+
+func (f *fakeInstance) Config() Config {
+	return f.cfg
+}
+
+func newFakeInstance(service string) Instance {
+	return &fakeInstance{cfg: Config{
+		Service:   service,
+		Namespace: namespace.Static("ns"),
+		Ports: []Port{
+			{Name: "http", Protocol: protocol.HTTP, ServicePort: 80, InstancePort: 8080},
+			{Name: "http-alt", Protocol: protocol.HTTP, ServicePort: 8081, InstancePort: 8081},
+			{Name: "grpc", Protocol: protocol.GRPC, ServicePort: 7070, InstancePort: 7070},
+			{Name: "tcp", Protocol: protocol.TCP, ServicePort: 9090, InstancePort: 9090},
+		},
+	}}
+}
+
+func TestCallOptionsFillDefaults(t *testing.T) {
+	a := newFakeInstance("a")
+	a2 := newFakeInstance("a")
+	b := newFakeInstance("b")
+
+	cases := []struct {
+		name       string
+		opts       CallOptions
+		wantErr    bool
+		wantPort   string
+		wantScheme scheme.Instance
+	}{
+		{
+			name:       "to instance by port name",
+			opts:       CallOptions{To: a, Port: &Port{Name: "http"}},
+			wantPort:   "http",
+			wantScheme: scheme.HTTP,
+		},
+		{
+			name:       "to instance by protocol",
+			opts:       CallOptions{To: a, Port: &Port{Protocol: protocol.GRPC}},
+			wantPort:   "grpc",
+			wantScheme: scheme.GRPC,
+		},
+		{
+			name:       "to instance by service port",
+			opts:       CallOptions{To: a, Port: &Port{ServicePort: 9090}},
+			wantPort:   "tcp",
+			wantScheme: scheme.TCP,
+		},
+		{
+			name:       "to service",
+			opts:       CallOptions{To: Instances{a, a2}, Port: &Port{Name: "http"}},
+			wantPort:   "http",
+			wantScheme: scheme.HTTP,
+		},
+		{
+			name:       "deprecated target and port name",
+			opts:       CallOptions{Target: a, PortName: "grpc"},
+			wantPort:   "grpc",
+			wantScheme: scheme.GRPC,
+		},
+		{
+			name:       "deprecated target with same to",
+			opts:       CallOptions{To: a, Target: a, Port: &Port{Name: "http"}},
+			wantPort:   "http",
+			wantScheme: scheme.HTTP,
+		},
+		{
+			name:       "deprecated target in to service",
+			opts:       CallOptions{To: Instances{a, a2}, Target: a2, Port: &Port{Name: "http"}},
+			wantPort:   "http",
+			wantScheme: scheme.HTTP,
+		},
+		{
+			name:    "deprecated target not in to service",
+			opts:    CallOptions{To: Instances{a, a2}, Target: b, Port: &Port{Name: "http"}},
+			wantErr: true,
+		},
+		{
+			name:    "ambiguous port",
+			opts:    CallOptions{To: a, Port: &Port{Protocol: protocol.HTTP}},
+			wantErr: true,
+		},
+		{
+			name:    "different to and target",
+			opts:    CallOptions{To: a, Target: b, Port: &Port{Name: "http"}},
+			wantErr: true,
+		},
+		{
+			name:    "port name conflicts with port",
+			opts:    CallOptions{To: a, Port: &Port{Name: "http"}, PortName: "grpc"},
+			wantErr: true,
+		},
+		{
+			name:    "no port",
+			opts:    CallOptions{To: a},
+			wantErr: true,
+		},
+		{
+			name:    "unknown port",
+			opts:    CallOptions{To: a, Port: &Port{Name: "http", ServicePort: 9090}},
+			wantErr: true,
+		},
+		{
+			name:    "multiple services",
+			opts:    CallOptions{To: Instances{a, b}, Port: &Port{Name: "http"}},
+			wantErr: true,
+		},
+		{
+			name:    "no instances",
+			opts:    CallOptions{To: Instances{}, Port: &Port{Name: "http"}},
+			wantErr: true,
+		},
+		{
+			name:       "no target",
+			opts:       CallOptions{Address: "1.2.3.4", Port: &Port{ServicePort: 80, Protocol: protocol.HTTP}},
+			wantScheme: scheme.HTTP,
+		},
+		{
+			name:    "no target without address",
+			opts:    CallOptions{Port: &Port{ServicePort: 80, Protocol: protocol.HTTP}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			err := opts.FillDefaults()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", opts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts.Port.Name != tt.wantPort {
+				t.Errorf("got port %q, want %q", opts.Port.Name, tt.wantPort)
+			}
+			if opts.Scheme != tt.wantScheme {
+				t.Errorf("got scheme %q, want %q", opts.Scheme, tt.wantScheme)
+			}
+			if opts.To != nil {
+				if opts.Target == nil {
+					t.Errorf("expected Target to be set")
+				}
+				if want := "a.ns.svc"; opts.Address != want || opts.Headers.Get("Host") != want {
+					t.Errorf("got address %q and host %q, want %q", opts.Address, opts.Headers.Get("Host"), want)
+				}
+			}
+			if opts.Timeout <= 0 || opts.Count <= 0 || opts.Check == nil {
+				t.Errorf("defaults not filled in: %+v", opts)
+			}
+		})
+	}
+}
+
+func TestCallOptionsFillDefaultsDoesNotMutatePort(t *testing.T) {
+	port := &Port{Protocol: protocol.HTTP}
+	opts := CallOptions{To: newFakeInstance("a"), Port: port, PortName: "http"}
+	if err := opts.FillDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	if port.Name != "" {
+		t.Fatalf("caller's port was modified: %+v", port)
+	}
+}
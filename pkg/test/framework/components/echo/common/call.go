@@ -16,18 +16,13 @@ package common
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net"
-	"net/http"
-	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
-	"istio.io/istio/pkg/config/protocol"
 	echoclient "istio.io/istio/pkg/test/echo"
-	"istio.io/istio/pkg/test/echo/check"
 	"istio.io/istio/pkg/test/echo/common"
 	"istio.io/istio/pkg/test/echo/common/scheme"
 	"istio.io/istio/pkg/test/echo/proto"
@@ -41,7 +36,7 @@ type sendFunc func(req *proto.ForwardEchoRequest) (echoclient.Responses, error)
 
 func callInternal(srcName string, opts *echo.CallOptions, send sendFunc,
 	doRetry bool, retryOptions ...retry.Option) (echoclient.Responses, error) {
-	if err := fillInCallOptions(opts); err != nil {
+	if err := opts.FillDefaults(); err != nil {
 		return nil, err
 	}
 
@@ -86,6 +81,7 @@ func callInternal(srcName string, opts *echo.CallOptions, send sendFunc,
 		InsecureSkipVerify: opts.InsecureSkipVerify,
 		FollowRedirects:    opts.FollowRedirects,
 		ServerName:         opts.ServerName,
+		Proxy:              opts.HTTPProxy,
 	}
 	if opts.Alpn != nil {
 		req.Alpn = &proto.Alpn{
@@ -180,104 +176,3 @@ func ForwardEcho(srcName string, clientProvider EchoClientProvider, opts *echo.C
 	}
 	return res, nil
 }
-
-func fillInCallOptions(opts *echo.CallOptions) error {
-	if opts.Target != nil {
-		targetPorts := opts.Target.Config().Ports
-		if opts.PortName == "" {
-			// Validate the Port value.
-
-			if opts.Port == nil {
-				return errors.New("callOptions: PortName or Port must be provided")
-			}
-
-			// Check the specified port for a match against the Target Instance
-			found := false
-			for _, port := range targetPorts {
-				if reflect.DeepEqual(port, *opts.Port) {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return fmt.Errorf("callOptions: Port does not match any Target port")
-			}
-		} else {
-			// Look up the port.
-			found := false
-			for i, port := range targetPorts {
-				if opts.PortName == port.Name {
-					found = true
-					opts.Port = &targetPorts[i]
-					break
-				}
-			}
-			if !found {
-				return fmt.Errorf("callOptions: no port named %s available in Target Instance", opts.PortName)
-			}
-		}
-	} else if opts.Scheme == scheme.DNS {
-		// Just need address
-		if opts.Address == "" {
-			return fmt.Errorf("for DNS, address must be set")
-		}
-		opts.Port = &echo.Port{}
-	} else if opts.Port == nil || opts.Port.ServicePort == 0 || (opts.Port.Protocol == "" && opts.Scheme == "") || opts.Address == "" {
-		return fmt.Errorf("if target is not set, then port.servicePort, port.protocol or schema, and address must be set")
-	}
-
-	if opts.Scheme == "" {
-		// No protocol, fill it in.
-		var err error
-		if opts.Scheme, err = schemeForPort(opts.Port); err != nil {
-			return err
-		}
-	}
-
-	if opts.Address == "" {
-		// No host specified, use the fully qualified domain name for the service.
-		opts.Address = opts.Target.Config().ClusterLocalFQDN()
-	}
-
-	// Initialize the headers and add a default Host header if none provided.
-	if opts.Headers == nil {
-		opts.Headers = make(http.Header)
-	} else {
-		// Avoid mutating input, which can lead to concurrent writes
-		opts.Headers = opts.Headers.Clone()
-	}
-
-	if h := opts.GetHost(); len(h) > 0 {
-		opts.Headers["Host"] = []string{h}
-	}
-
-	if opts.Timeout <= 0 {
-		opts.Timeout = common.DefaultRequestTimeout
-	}
-
-	if opts.Count <= 0 {
-		opts.Count = common.DefaultCount
-	}
-
-	// If no Check was specified, assume no error.
-	if opts.Check == nil {
-		opts.Check = check.None()
-	}
-	return nil
-}
-
-func schemeForPort(port *echo.Port) (scheme.Instance, error) {
-	switch port.Protocol {
-	case protocol.GRPC, protocol.GRPCWeb, protocol.HTTP2:
-		return scheme.GRPC, nil
-	case protocol.HTTP:
-		return scheme.HTTP, nil
-	case protocol.HTTPS:
-		return scheme.HTTPS, nil
-	case protocol.TCP:
-		return scheme.TCP, nil
-	default:
-		return "", fmt.Errorf("failed creating call for port %s: unsupported protocol %s",
-			port.Name, port.Protocol)
-	}
-}
@@ -52,6 +52,18 @@ const (
 	DefaultVMDistro = UbuntuBionic
 )
 
+// IPFamily selects the IP families of the addresses of an echo Instance.
+type IPFamily string
+
+const (
+	// DefaultIPFamily uses the IP families of the cluster.
+	DefaultIPFamily IPFamily = ""
+	IPv4Only        IPFamily = "IPv4"
+	IPv6Only        IPFamily = "IPv6"
+	// DualStack uses both IPv4 and IPv6 addresses, IPv4 first. The cluster must support dual-stack.
+	DualStack IPFamily = "DualStack"
+)
+
 // Config defines the options for creating an Echo component.
 // nolint: maligned
 type Config struct {
@@ -76,6 +88,9 @@ type Config struct {
 	// Headless (k8s only) indicates that no ClusterIP should be specified.
 	Headless bool
 
+	// IPFamily (k8s only) selects the IP families of the service. If not set, the families of the cluster are used.
+	IPFamily IPFamily
+
 	// StatefulSet indicates that the pod should be backed by a StatefulSet. This implies Headless=true
 	// as well.
 	StatefulSet bool
@@ -166,6 +166,9 @@ type Workload interface {
 	// ForwardEcho executes specific call from this workload.
 	ForwardEcho(context.Context, *proto.ForwardEchoRequest) (echo.Responses, error)
 
+	// ForwardEchoLoad executes a call in load mode from this workload, returning the latency distribution.
+	ForwardEchoLoad(context.Context, *proto.ForwardEchoRequest) (*proto.LoadResult, error)
+
 	// Logs returns the logs for the app container
 	Logs() (string, error)
 	// LogsOrFail returns the logs for the app container, or aborts if an error is found
@@ -53,6 +53,15 @@ func (i Instances) IsDeployment() bool {
 	return len(i.Services()) == 1
 }
 
+// Config returns the configuration of the first instance, so that the Instances of a service can be the Target
+// of a call. Returns the zero Config if there are no instances.
+func (i Instances) Config() Config {
+	if len(i) == 0 {
+		return Config{}
+	}
+	return i[0].Config()
+}
+
 // Matcher is used to filter matching instances
 type Matcher func(Instance) bool
 
@@ -78,6 +78,13 @@ metadata:
 spec:
 {{- if .Headless }}
   clusterIP: None
+{{- end }}
+{{- if .IPFamilies }}
+  ipFamilyPolicy: {{ .IPFamilyPolicy }}
+  ipFamilies:
+{{- range $family := .IPFamilies }}
+  - {{ $family }}
+{{- end }}
 {{- end }}
   ports:
 {{- range $i, $p := .Ports }}
@@ -674,6 +681,10 @@ func templateParams(cfg echo.Config, imgSettings *image.Settings, settings *reso
 	if err != nil {
 		return nil, err
 	}
+	ipFamilyPolicy, ipFamilies, err := serviceIPFamilies(cfg.IPFamily)
+	if err != nil {
+		return nil, err
+	}
 	params := map[string]interface{}{
 		"Hub":                imgSettings.Hub,
 		"Tag":                strings.TrimSuffix(imgSettings.Tag, "-distroless"),
@@ -681,6 +692,8 @@ func templateParams(cfg echo.Config, imgSettings *image.Settings, settings *reso
 		"Service":            cfg.Service,
 		"Version":            cfg.Version,
 		"Headless":           cfg.Headless,
+		"IPFamilyPolicy":     ipFamilyPolicy,
+		"IPFamilies":         ipFamilies,
 		"StatefulSet":        cfg.StatefulSet,
 		"ProxylessGRPC":      cfg.IsProxylessGRPC(),
 		"GRPCMagicPort":      grpcMagicPort,
@@ -710,6 +723,19 @@ func templateParams(cfg echo.Config, imgSettings *image.Settings, settings *reso
 	return params, nil
 }
 
+// serviceIPFamilies returns the ipFamilyPolicy and the ipFamilies of the service for the IP family.
+func serviceIPFamilies(family echo.IPFamily) (string, []string, error) {
+	switch family {
+	case echo.DefaultIPFamily:
+		return "", nil, nil
+	case echo.IPv4Only, echo.IPv6Only:
+		return "SingleStack", []string{string(family)}, nil
+	case echo.DualStack:
+		return "RequireDualStack", []string{string(echo.IPv4Only), string(echo.IPv6Only)}, nil
+	}
+	return "", nil, fmt.Errorf("unsupported IP family %q", family)
+}
+
 func lines(input string) []string {
 	out := make([]string, 0)
 	scanner := bufio.NewScanner(strings.NewReader(input))
@@ -58,6 +58,23 @@ func TestDeploymentYAML(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:         "dual-stack",
+			wantFilePath: "testdata/dual-stack.yaml",
+			config: echo.Config{
+				Service:  "foo",
+				Version:  "bar",
+				IPFamily: echo.DualStack,
+				Ports: []echo.Port{
+					{
+						Name:         "http",
+						Protocol:     protocol.HTTP,
+						InstancePort: 8090,
+						ServicePort:  8090,
+					},
+				},
+			},
+		},
 		{
 			name:         "two-workloads-one-nosidecar",
 			wantFilePath: "testdata/two-workloads-one-nosidecar.yaml",
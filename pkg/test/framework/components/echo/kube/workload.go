@@ -147,6 +147,19 @@ func (w *workload) ForwardEcho(ctx context.Context, request *proto.ForwardEchoRe
 	return c.ForwardEcho(ctx, request)
 }
 
+func (w *workload) ForwardEchoLoad(ctx context.Context, request *proto.ForwardEchoRequest) (*proto.LoadResult, error) {
+	w.mutex.Lock()
+	c := w.client
+	if c == nil {
+		w.mutex.Unlock()
+		return nil, fmt.Errorf("failed forwarding echo for disconnected pod %s/%s",
+			w.pod.Namespace, w.pod.Name)
+	}
+	w.mutex.Unlock()
+
+	return c.ForwardEchoLoad(ctx, request)
+}
+
 func (w *workload) Sidecar() echo.Sidecar {
 	w.mutex.Lock()
 	s := w.sidecar
@@ -0,0 +1,185 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package churn continuously updates configs while traffic is sent, to catch the requests failing while proxies
+// apply the updates, such as 503s during cluster warming or 403s during authorization policy updates.
+package churn
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo/util/traffic"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+)
+
+const (
+	defaultInterval = 5 * time.Second
+	defaultTimeout  = 30 * time.Second
+)
+
+type Config struct {
+	// Namespace of the configs.
+	Namespace string
+
+	// Steps are the configs applied in turn, starting over after the last one. Each step is the YAML text of
+	// AuthorizationPolicies, DestinationRules or any other configs, usually updating the same resources with specs
+	// which must all allow the traffic.
+	Steps []string
+
+	// Interval between successive steps. If not set, defaults to 5 seconds.
+	Interval time.Duration
+
+	// Maximum time to wait for the step in progress to complete after stopping. If not set, defaults to 30 seconds.
+	StopTimeout time.Duration
+}
+
+type Churner interface {
+	// Start applying the steps.
+	Start() Churner
+
+	// Stop applying the steps and wait for the step in progress to complete.
+	// Returns the Result
+	Stop() Result
+}
+
+// Result of the churn.
+type Result struct {
+	// Updates is the number of steps applied.
+	Updates int
+	// Error holds the errors applying the steps.
+	Error error
+}
+
+func NewChurner(t test.Failer, ctx resource.Context, cfg Config) Churner {
+	if len(cfg.Steps) == 0 {
+		t.Fatal("no steps to apply")
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.StopTimeout == 0 {
+		cfg.StopTimeout = defaultTimeout
+	}
+	c := &churner{
+		Config:  cfg,
+		t:       t,
+		ctx:     ctx,
+		applied: map[int]struct{}{},
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	ctx.ConditionalCleanup(c.cleanup)
+	return c
+}
+
+var _ Churner = &churner{}
+
+type churner struct {
+	Config
+	t   test.Failer
+	ctx resource.Context
+
+	mu      sync.Mutex
+	applied map[int]struct{}
+	result  Result
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func (c *churner) Start() Churner {
+	go func() {
+		step := 0
+		t := time.NewTimer(0)
+		for {
+			select {
+			case <-c.stop:
+				t.Stop()
+				close(c.stopped)
+				return
+			case <-t.C:
+				c.apply(step)
+				step = (step + 1) % len(c.Steps)
+				t.Reset(c.Interval)
+			}
+		}
+	}()
+	return c
+}
+
+func (c *churner) apply(step int) {
+	err := c.ctx.ConfigIstio().ApplyYAMLNoCleanup(c.Namespace, c.Steps[step])
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applied[step] = struct{}{}
+	c.result.Updates++
+	if err != nil {
+		c.result.Error = multierror.Append(c.result.Error, fmt.Errorf("update %d (step %d): %v", c.result.Updates, step, err))
+	}
+}
+
+func (c *churner) Stop() Result {
+	// Trigger the churner to stop.
+	close(c.stop)
+
+	// Wait for the churner to exit.
+	t := time.NewTimer(c.StopTimeout)
+	select {
+	case <-c.stopped:
+		t.Stop()
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.result
+	case <-t.C:
+		c.t.Fatal("timed out waiting for the config churn to stop")
+	}
+	// Can never happen, but the compiler doesn't know that Fatal terminates
+	return Result{}
+}
+
+// cleanup deletes the configs of the steps applied. The steps may define the same resources, so failures are
+// expected.
+func (c *churner) cleanup() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for step := range c.applied {
+		if err := c.ctx.ConfigIstio().DeleteYAML(c.Namespace, c.Steps[step]); err != nil {
+			scopes.Framework.Debugf("failed deleting the configs of churn step %d: %v", step, err)
+		}
+	}
+}
+
+// Run applies the steps of the churn while the traffic is sent for the duration, and fails the test if any request
+// failed, such as a 503 or 403 returned while the proxies applied an update, or if any step failed to apply. The
+// traffic check defaults to check.OK().
+func Run(t framework.TestContext, churn Config, trafficCfg traffic.Config, duration time.Duration) {
+	t.Helper()
+	g := traffic.NewGenerator(t, trafficCfg).Start()
+	c := NewChurner(t, t, churn).Start()
+	time.Sleep(duration)
+	churnResult := c.Stop()
+	trafficResult := g.Stop()
+	if churnResult.Error != nil {
+		t.Fatalf("failed applying the configs after %d updates: %v", churnResult.Updates, churnResult.Error)
+	}
+	t.Logf("applied %d config updates", churnResult.Updates)
+	trafficResult.CheckSuccessRate(t, 1)
+}
@@ -22,7 +22,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/hashicorp/go-multierror"
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
@@ -37,6 +39,9 @@ import (
 
 const (
 	istiodLabel = "pilot"
+
+	// meshConfigPropagationTimeout bounds how long a mesh config patch, or its revert, may take to be loaded by istiod.
+	meshConfigPropagationTimeout = 2 * time.Minute
 )
 
 var dummyValidationVirtualServiceTemplate = `
@@ -177,18 +182,41 @@ func (i *operatorComponent) isExternalControlPlane() bool {
 	return false
 }
 
+// PatchMeshConfig patches the mesh config of the given clusters for the duration of the test. The patch is
+// transactional: it does not return until every istiod reading the patched config map serves the patched mesh
+// config, and on cleanup the original config maps are restored and istiod is waited on to serve the mesh config
+// it had before the patch, so the change cannot leak into subsequent tests. If patching any cluster fails, the
+// clusters that were already patched are reverted as part of the same cleanup.
 func PatchMeshConfig(t framework.TestContext, ns string, clusters cluster.Clusters, patch string) {
 	errG := multierror.Group{}
 	origCfg := map[string]string{}
+	origMesh := map[string]*meshconfig.MeshConfig{}
 	mu := sync.RWMutex{}
 
+	rev := t.Settings().Revisions.Default()
+	if rev == "default" {
+		rev = ""
+	}
 	cmName := "istio"
-	if rev := t.Settings().Revisions.Default(); rev != "default" && rev != "" {
+	if rev != "" {
 		cmName += "-" + rev
 	}
 	for _, c := range clusters.Kube() {
 		c := c
 		errG.Go(func() error {
+			if c.IsPrimary() {
+				// Record what istiod serves before the patch, this is what the revert waits for.
+				meshes, err := istiodMeshConfigs(c, ns, rev)
+				if err != nil {
+					return err
+				}
+				for _, mc := range meshes {
+					mu.Lock()
+					origMesh[c.Name()] = mc
+					mu.Unlock()
+					break
+				}
+			}
 			cm, err := c.CoreV1().ConfigMaps(ns).Get(context.TODO(), cmName, v1.GetOptions{})
 			if err != nil {
 				return err
@@ -216,7 +244,21 @@ func PatchMeshConfig(t framework.TestContext, ns string, clusters cluster.Cluste
 				return err
 			}
 			scopes.Framework.Infof("patched %s meshconfig:\n%s", c.Name(), cm.Data["mesh"])
-			return nil
+			if !c.IsPrimary() {
+				return nil
+			}
+			return retry.UntilSuccess(func() error {
+				return checkIstiodMeshConfig(c, ns, rev, func(got *meshconfig.MeshConfig) error {
+					want := proto.Clone(got).(*meshconfig.MeshConfig)
+					if err := gogoprotomarshal.ApplyYAML(patch, want); err != nil {
+						return err
+					}
+					if !proto.Equal(got, want) {
+						return fmt.Errorf("patch not loaded yet")
+					}
+					return nil
+				})
+			}, retry.Timeout(meshConfigPropagationTimeout))
 		})
 	}
 	t.Cleanup(func() {
@@ -233,14 +275,74 @@ func PatchMeshConfig(t framework.TestContext, ns string, clusters cluster.Cluste
 				}
 				cm.Data["mesh"] = mcYaml
 				_, err = c.CoreV1().ConfigMaps(ns).Update(context.TODO(), cm, v1.UpdateOptions{})
-				return err
+				if err != nil {
+					return err
+				}
+				orig, ok := origMesh[cn]
+				if !ok {
+					return nil
+				}
+				return retry.UntilSuccess(func() error {
+					return checkIstiodMeshConfig(c, ns, rev, func(got *meshconfig.MeshConfig) error {
+						if !proto.Equal(got, orig) {
+							return fmt.Errorf("original mesh config not restored yet")
+						}
+						return nil
+					})
+				}, retry.Timeout(meshConfigPropagationTimeout))
 			})
 		}
 		if err := errG.Wait().ErrorOrNil(); err != nil {
-			scopes.Framework.Errorf("failed cleaning up cluster-local config: %v", err)
+			t.Errorf("failed reverting mesh config patch: %v", err)
 		}
 	})
 	if err := errG.Wait().ErrorOrNil(); err != nil {
 		t.Fatal(err)
 	}
 }
+
+// checkIstiodMeshConfig runs check against the mesh config served by every istiod of the revision in the cluster.
+func checkIstiodMeshConfig(c cluster.Cluster, ns, rev string, check func(*meshconfig.MeshConfig) error) error {
+	meshes, err := istiodMeshConfigs(c, ns, rev)
+	if err != nil {
+		return err
+	}
+	for pod, mc := range meshes {
+		if err := check(mc); err != nil {
+			return fmt.Errorf("%s/%s: %v", c.Name(), pod, err)
+		}
+	}
+	return nil
+}
+
+// istiodMeshConfigs returns the mesh config currently served by each running istiod pod of the revision in the
+// cluster, keyed by pod name.
+func istiodMeshConfigs(c cluster.Cluster, ns, rev string) (map[string]*meshconfig.MeshConfig, error) {
+	selectors := []string{"app=istiod"}
+	if rev != "" {
+		selectors = append(selectors, "istio.io/rev="+rev)
+	}
+	pods, err := c.PodsForSelector(context.TODO(), ns, selectors...)
+	if err != nil {
+		return nil, err
+	}
+	meshes := map[string]*meshconfig.MeshConfig{}
+	for _, p := range pods.Items {
+		if p.Status.Phase != corev1.PodRunning || p.DeletionTimestamp != nil {
+			continue
+		}
+		out, _, err := c.PodExec(p.Name, p.Namespace, "discovery", "pilot-discovery request GET /debug/mesh")
+		if err != nil {
+			return nil, fmt.Errorf("failed reading mesh config of %s: %v", p.Name, err)
+		}
+		mc := &meshconfig.MeshConfig{}
+		if err := gogoprotomarshal.ApplyJSON(out, mc); err != nil {
+			return nil, fmt.Errorf("failed parsing mesh config of %s: %v", p.Name, err)
+		}
+		meshes[p.Name] = mc
+	}
+	if len(meshes) == 0 {
+		return nil, fmt.Errorf("no running istiod pods found in %s", c.Name())
+	}
+	return meshes, nil
+}
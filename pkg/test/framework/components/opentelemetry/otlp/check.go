@@ -0,0 +1,137 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test/echo"
+	"istio.io/istio/pkg/test/echo/check"
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/pkg/util/istiomultierror"
+)
+
+// spanRetryOptions bound how long the checkers wait for the proxies to flush their spans to the collector.
+var spanRetryOptions = []retry.Option{retry.Timeout(time.Minute), retry.Delay(time.Second)}
+
+// SpanMatch describes a span expected in the trace of an echo call. Empty fields match any value.
+type SpanMatch struct {
+	Name        string
+	Kind        string
+	ServiceName string
+	// Attributes that the span must have, with their expected values.
+	Attributes map[string]string
+}
+
+func (m SpanMatch) matches(s Span) bool {
+	if m.Name != "" && m.Name != s.Name {
+		return false
+	}
+	if m.Kind != "" && m.Kind != s.Kind {
+		return false
+	}
+	if m.ServiceName != "" && m.ServiceName != s.ServiceName {
+		return false
+	}
+	for k, v := range m.Attributes {
+		if actual, f := s.Attributes[k]; !f || actual != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (m SpanMatch) String() string {
+	return fmt.Sprintf("{name: %q, kind: %q, service: %q, attributes: %v}", m.Name, m.Kind, m.ServiceName, m.Attributes)
+}
+
+// TraceSpans returns a Checker that requires the collector to receive spans matching all of expected
+// for each of the echo calls. The trace of a call is identified from the tracing headers the server
+// received, so the call must go through a proxy with tracing enabled.
+func TraceSpans(c Instance, expected ...SpanMatch) check.Checker {
+	return func(rs echo.Responses, _ error) error {
+		if rs.IsEmpty() {
+			return fmt.Errorf("no responses received")
+		}
+		traceIDs := make([]string, 0, len(rs))
+		for i, r := range rs {
+			id, err := traceID(r)
+			if err != nil {
+				return fmt.Errorf("response[%d]: %v", i, err)
+			}
+			traceIDs = append(traceIDs, id)
+		}
+		return retry.UntilSuccess(func() error {
+			spans, err := c.ListSpans()
+			if err != nil {
+				return err
+			}
+			return checkTraces(spans, traceIDs, expected)
+		}, spanRetryOptions...)
+	}
+}
+
+func checkTraces(spans []Span, traceIDs []string, expected []SpanMatch) error {
+	byTrace := map[string][]Span{}
+	for _, s := range spans {
+		byTrace[s.TraceID] = append(byTrace[s.TraceID], s)
+	}
+	outErr := istiomultierror.New()
+	for i, id := range traceIDs {
+		trace := byTrace[id]
+		if len(trace) == 0 {
+			outErr = multierror.Append(outErr, fmt.Errorf("response[%d]: no spans received for trace %s", i, id))
+			continue
+		}
+	expectedLoop:
+		for _, m := range expected {
+			for _, s := range trace {
+				if m.matches(s) {
+					continue expectedLoop
+				}
+			}
+			outErr = multierror.Append(outErr, fmt.Errorf("response[%d]: no span matching %v in trace %s, received %v",
+				i, m, id, trace))
+		}
+	}
+	return outErr.ErrorOrNil()
+}
+
+// traceID returns the trace ID the server received, from either the W3C or the B3 headers, in the form
+// used by OTLP. 64-bit B3 IDs are widened to 128 bits like the collector does.
+func traceID(r echo.Response) (string, error) {
+	if tp := r.RequestHeaders.Get("Traceparent"); tp != "" {
+		// version-traceid-parentid-flags
+		parts := strings.Split(tp, "-")
+		if len(parts) != 4 || len(parts[1]) != 32 {
+			return "", fmt.Errorf("invalid traceparent header %q", tp)
+		}
+		return strings.ToLower(parts[1]), nil
+	}
+	if id := r.RequestHeaders.Get("X-B3-Traceid"); id != "" {
+		switch len(id) {
+		case 16:
+			return strings.Repeat("0", 16) + strings.ToLower(id), nil
+		case 32:
+			return strings.ToLower(id), nil
+		}
+		return "", fmt.Errorf("invalid x-b3-traceid header %q", id)
+	}
+	return "", fmt.Errorf("no tracing headers received by the server")
+}
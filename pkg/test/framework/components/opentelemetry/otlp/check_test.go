@@ -0,0 +1,111 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/echo"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+type fakeCollector struct {
+	Instance
+	spans []Span
+}
+
+func (f fakeCollector) ListSpans() ([]Span, error) {
+	return f.spans, nil
+}
+
+func TestTraceSpans(t *testing.T) {
+	defer func(opts []retry.Option) { spanRetryOptions = opts }(spanRetryOptions)
+	spanRetryOptions = []retry.Option{retry.Timeout(50 * time.Millisecond), retry.Delay(10 * time.Millisecond)}
+
+	collector := fakeCollector{spans: []Span{
+		{
+			TraceID:     "0af7651916cd43dd8448eb211c80319c",
+			Name:        "b.ns.svc.cluster.local:80/*",
+			Kind:        "SPAN_KIND_CLIENT",
+			ServiceName: "a.ns",
+			Attributes:  map[string]string{"http.method": "GET", "http.status_code": "200"},
+		},
+		{
+			TraceID:     "00000000000000008448eb211c80319c",
+			Name:        "b.ns.svc.cluster.local:80/*",
+			Kind:        "SPAN_KIND_SERVER",
+			ServiceName: "b.ns",
+		},
+	}}
+	response := func(k, v string) echo.Response {
+		return echo.Response{RequestHeaders: http.Header{k: []string{v}}}
+	}
+	w3c := response("Traceparent", "00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01")
+	b3 := response("X-B3-Traceid", "8448eb211c80319c")
+
+	cases := []struct {
+		name     string
+		rs       echo.Responses
+		expected []SpanMatch
+		wantErr  bool
+	}{
+		{
+			name:     "w3c trace",
+			rs:       echo.Responses{w3c},
+			expected: []SpanMatch{{Kind: "SPAN_KIND_CLIENT", ServiceName: "a.ns", Attributes: map[string]string{"http.method": "GET"}}},
+		},
+		{
+			name:     "b3 trace",
+			rs:       echo.Responses{b3},
+			expected: []SpanMatch{{Kind: "SPAN_KIND_SERVER", ServiceName: "b.ns"}},
+		},
+		{
+			name:     "attribute mismatch",
+			rs:       echo.Responses{w3c},
+			expected: []SpanMatch{{Attributes: map[string]string{"http.status_code": "503"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "span of another trace",
+			rs:       echo.Responses{b3},
+			expected: []SpanMatch{{Kind: "SPAN_KIND_CLIENT"}},
+			wantErr:  true,
+		},
+		{
+			name:    "unknown trace",
+			rs:      echo.Responses{response("X-B3-Traceid", "463ac35c9f6413ad48485a3953bb6124")},
+			wantErr: true,
+		},
+		{
+			name:    "no tracing headers",
+			rs:      echo.Responses{{}},
+			wantErr: true,
+		},
+		{
+			name:    "no responses",
+			wantErr: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := TraceSpans(collector, tt.expected...).Check(tt.rs, nil)
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
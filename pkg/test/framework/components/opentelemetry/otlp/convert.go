@@ -0,0 +1,115 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"encoding/hex"
+	"strconv"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// spansFromProto returns the spans of the traces kept by the sink.
+func spansFromProto(td *trace.TracesData) []Span {
+	var spans []Span
+	for _, rs := range td.GetResourceSpans() {
+		svc := serviceName(rs.GetResource())
+		for _, ss := range rs.GetInstrumentationLibrarySpans() {
+			for _, s := range ss.GetSpans() {
+				spans = append(spans, Span{
+					TraceID:      hex.EncodeToString(s.GetTraceId()),
+					SpanID:       hex.EncodeToString(s.GetSpanId()),
+					ParentSpanID: hex.EncodeToString(s.GetParentSpanId()),
+					Name:         s.GetName(),
+					Kind:         s.GetKind().String(),
+					ServiceName:  svc,
+					Attributes:   attributes(s.GetAttributes()),
+				})
+			}
+		}
+	}
+	return spans
+}
+
+// metricsFromProto returns the data points of the gauges, sums and histograms kept by the sink.
+func metricsFromProto(md *metrics.MetricsData) []Metric {
+	var out []Metric
+	for _, rm := range md.GetResourceMetrics() {
+		svc := serviceName(rm.GetResource())
+		for _, sm := range rm.GetInstrumentationLibraryMetrics() {
+			for _, m := range sm.GetMetrics() {
+				add := func(attrs []*common.KeyValue, v float64) {
+					out = append(out, Metric{Name: m.GetName(), ServiceName: svc, Attributes: attributes(attrs), Value: v})
+				}
+				var points []*metrics.NumberDataPoint
+				switch {
+				case m.GetGauge() != nil:
+					points = m.GetGauge().GetDataPoints()
+				case m.GetSum() != nil:
+					points = m.GetSum().GetDataPoints()
+				case m.GetHistogram() != nil:
+					for _, dp := range m.GetHistogram().GetDataPoints() {
+						add(dp.GetAttributes(), float64(dp.GetCount()))
+					}
+				}
+				for _, dp := range points {
+					v := dp.GetAsDouble()
+					if _, ok := dp.GetValue().(*metrics.NumberDataPoint_AsInt); ok {
+						v = float64(dp.GetAsInt())
+					}
+					add(dp.GetAttributes(), v)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func serviceName(r *resource.Resource) string {
+	for _, kv := range r.GetAttributes() {
+		if kv.GetKey() == "service.name" {
+			return valueString(kv.GetValue())
+		}
+	}
+	return ""
+}
+
+func attributes(kvs []*common.KeyValue) map[string]string {
+	if len(kvs) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		out[kv.GetKey()] = valueString(kv.GetValue())
+	}
+	return out
+}
+
+func valueString(v *common.AnyValue) string {
+	switch val := v.GetValue().(type) {
+	case *common.AnyValue_StringValue:
+		return val.StringValue
+	case *common.AnyValue_IntValue:
+		return strconv.FormatInt(val.IntValue, 10)
+	case *common.AnyValue_DoubleValue:
+		return strconv.FormatFloat(val.DoubleValue, 'g', -1, 64)
+	case *common.AnyValue_BoolValue:
+		return strconv.FormatBool(val.BoolValue)
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"reflect"
+	"testing"
+
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resource "go.opentelemetry.io/proto/otlp/resource/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func stringAttr(k, v string) *common.KeyValue {
+	return &common.KeyValue{Key: k, Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: v}}}
+}
+
+func serviceResource(name string) *resource.Resource {
+	return &resource.Resource{Attributes: []*common.KeyValue{stringAttr("service.name", name)}}
+}
+
+func TestSpansFromProto(t *testing.T) {
+	td := &trace.TracesData{ResourceSpans: []*trace.ResourceSpans{
+		{
+			Resource: serviceResource("a.ns"),
+			InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{Spans: []*trace.Span{{
+				TraceId: []byte{0x0a, 0xf7, 0x65, 0x19, 0x16, 0xcd, 0x43, 0xdd, 0x84, 0x48, 0xeb, 0x21, 0x1c, 0x80, 0x31, 0x9c},
+				SpanId:  []byte{0xb7, 0xad, 0x6b, 0x71, 0x69, 0x20, 0x33, 0x31},
+				Name:    "b.ns.svc.cluster.local:80/*",
+				Kind:    trace.Span_SPAN_KIND_CLIENT,
+				Attributes: []*common.KeyValue{
+					stringAttr("http.method", "GET"),
+					{Key: "http.status_code", Value: &common.AnyValue{Value: &common.AnyValue_IntValue{IntValue: 200}}},
+				},
+			}}}},
+		},
+		{
+			Resource: serviceResource("b.ns"),
+			InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{Spans: []*trace.Span{{
+				TraceId:      []byte{0x0a, 0xf7, 0x65, 0x19, 0x16, 0xcd, 0x43, 0xdd, 0x84, 0x48, 0xeb, 0x21, 0x1c, 0x80, 0x31, 0x9c},
+				SpanId:       []byte{0xc7, 0xad, 0x6b, 0x71, 0x69, 0x20, 0x33, 0x31},
+				ParentSpanId: []byte{0xb7, 0xad, 0x6b, 0x71, 0x69, 0x20, 0x33, 0x31},
+				Name:         "b.ns.svc.cluster.local:80/*",
+				Kind:         trace.Span_SPAN_KIND_SERVER,
+				Attributes: []*common.KeyValue{
+					{Key: "sampled", Value: &common.AnyValue{Value: &common.AnyValue_BoolValue{BoolValue: true}}},
+				},
+			}}}},
+		},
+	}}
+	want := []Span{
+		{
+			TraceID:     "0af7651916cd43dd8448eb211c80319c",
+			SpanID:      "b7ad6b7169203331",
+			Name:        "b.ns.svc.cluster.local:80/*",
+			Kind:        "SPAN_KIND_CLIENT",
+			ServiceName: "a.ns",
+			Attributes:  map[string]string{"http.method": "GET", "http.status_code": "200"},
+		},
+		{
+			TraceID:      "0af7651916cd43dd8448eb211c80319c",
+			SpanID:       "c7ad6b7169203331",
+			ParentSpanID: "b7ad6b7169203331",
+			Name:         "b.ns.svc.cluster.local:80/*",
+			Kind:         "SPAN_KIND_SERVER",
+			ServiceName:  "b.ns",
+			Attributes:   map[string]string{"sampled": "true"},
+		},
+	}
+	if got := spansFromProto(td); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if spans := spansFromProto(&trace.TracesData{}); len(spans) != 0 {
+		t.Fatalf("expected no spans, got %v", spans)
+	}
+}
+
+func TestMetricsFromProto(t *testing.T) {
+	md := &metrics.MetricsData{ResourceMetrics: []*metrics.ResourceMetrics{{
+		Resource: serviceResource("a.ns"),
+		InstrumentationLibraryMetrics: []*metrics.InstrumentationLibraryMetrics{{Metrics: []*metrics.Metric{
+			{Name: "requests_total", Data: &metrics.Metric_Sum{Sum: &metrics.Sum{DataPoints: []*metrics.NumberDataPoint{{
+				Attributes: []*common.KeyValue{stringAttr("response_code", "200")},
+				Value:      &metrics.NumberDataPoint_AsInt{AsInt: 3},
+			}}}}},
+			{Name: "cpu", Data: &metrics.Metric_Gauge{Gauge: &metrics.Gauge{DataPoints: []*metrics.NumberDataPoint{{
+				Value: &metrics.NumberDataPoint_AsDouble{AsDouble: 0.5},
+			}}}}},
+			{Name: "request_duration", Data: &metrics.Metric_Histogram{Histogram: &metrics.Histogram{
+				DataPoints: []*metrics.HistogramDataPoint{{Count: 4, Sum: 12.5}},
+			}}},
+		}}},
+	}}}
+	want := []Metric{
+		{Name: "requests_total", ServiceName: "a.ns", Attributes: map[string]string{"response_code": "200"}, Value: 3},
+		{Name: "cpu", ServiceName: "a.ns", Value: 0.5},
+		{Name: "request_duration", ServiceName: "a.ns", Value: 4},
+	}
+	if got := metricsFromProto(md); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
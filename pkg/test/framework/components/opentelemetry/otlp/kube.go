@@ -0,0 +1,293 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	istioKube "istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/components/cluster"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/image"
+	"istio.io/istio/pkg/test/framework/resource"
+	testKube "istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/otlp/sink"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/tmpl"
+)
+
+const (
+	appName = "opentelemetry-collector-otlp"
+	// sinkPort serves the spans and metrics kept by the sink.
+	sinkPort = 8080
+
+	// The collector receives the spans and metrics of the proxies in all the formats they use, and exports them
+	// over OTLP to the sink running next to it, which keeps them in memory and serves them back to the tests.
+	collectorYaml = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: opentelemetry-collector-otlp
+  labels:
+    app: opentelemetry-collector-otlp
+data:
+  config: |
+    receivers:
+      otlp:
+        protocols:
+          grpc:
+            endpoint: 0.0.0.0:4317
+          http:
+            endpoint: 0.0.0.0:4318
+      opencensus:
+        endpoint: 0.0.0.0:55678
+      zipkin:
+        endpoint: 0.0.0.0:9411
+    processors:
+      batch:
+        timeout: 1s
+    exporters:
+      otlp/sink:
+        endpoint: localhost:4319
+        tls:
+          insecure: true
+    extensions:
+      health_check:
+        port: 13133
+    service:
+      extensions:
+      - health_check
+      pipelines:
+        traces:
+          receivers:
+          - otlp
+          - opencensus
+          - zipkin
+          processors:
+          - batch
+          exporters:
+          - otlp/sink
+        metrics:
+          receivers:
+          - otlp
+          - opencensus
+          processors:
+          - batch
+          exporters:
+          - otlp/sink
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: opentelemetry-collector-otlp
+  labels:
+    app: opentelemetry-collector-otlp
+spec:
+  type: ClusterIP
+  selector:
+    app: opentelemetry-collector-otlp
+  ports:
+  - name: grpc-otlp
+    port: 4317
+    targetPort: 4317
+  - name: http-otlp
+    port: 4318
+    targetPort: 4318
+  - name: grpc-opencensus
+    port: 55678
+    targetPort: 55678
+  - name: http-zipkin
+    port: 9411
+    targetPort: 9411
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: opentelemetry-collector-otlp
+  labels:
+    app: opentelemetry-collector-otlp
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: opentelemetry-collector-otlp
+  template:
+    metadata:
+      labels:
+        app: opentelemetry-collector-otlp
+      annotations:
+        sidecar.istio.io/inject: "false"
+    spec:
+      containers:
+      - name: opentelemetry-collector
+        image: otel/opentelemetry-collector-contrib:0.48.0
+        imagePullPolicy: IfNotPresent
+        args:
+        - --config=/conf/config.yaml
+        ports:
+        - containerPort: 4317
+        - containerPort: 4318
+        - containerPort: 55678
+        - containerPort: 9411
+        volumeMounts:
+        - name: config
+          mountPath: /conf
+        readinessProbe:
+          httpGet:
+            path: /
+            port: 13133
+        resources:
+          requests:
+            cpu: 40m
+            memory: 100Mi
+      - name: sink
+        image: {{ .Hub }}/app:{{ .Tag }}
+        imagePullPolicy: {{ .PullPolicy }}
+        command: ["/usr/local/bin/otlp-sink", "--grpc=4319", "--port=8080"]
+        ports:
+        - containerPort: 8080
+        readinessProbe:
+          httpGet:
+            path: /traces
+            port: 8080
+      volumes:
+      - name: config
+        configMap:
+          name: opentelemetry-collector-otlp
+          items:
+          - key: config
+            path: config.yaml
+`
+)
+
+var (
+	_ Instance  = &kubeComponent{}
+	_ io.Closer = &kubeComponent{}
+)
+
+type kubeComponent struct {
+	id        resource.ID
+	host      string
+	forwarder istioKube.PortForwarder
+	cluster   cluster.Cluster
+}
+
+func newKube(ctx resource.Context, cfgIn Config) (Instance, error) {
+	c := &kubeComponent{
+		cluster: ctx.Clusters().GetOrDefault(cfgIn.Cluster),
+	}
+	c.id = ctx.TrackResource(c)
+
+	cfg, err := istio.DefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ns := cfg.TelemetryNamespace
+	imgSettings, err := image.SettingsFromCommandLine()
+	if err != nil {
+		return nil, err
+	}
+	yaml, err := tmpl.Evaluate(collectorYaml, map[string]string{
+		"Hub":        imgSettings.Hub,
+		"Tag":        strings.TrimSuffix(imgSettings.Tag, "-distroless"),
+		"PullPolicy": imgSettings.PullPolicy,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.ConfigKube(c.cluster).ApplyYAML(ns, yaml); err != nil {
+		return nil, err
+	}
+
+	fetchFn := testKube.NewSinglePodFetch(c.cluster, ns, fmt.Sprintf("app=%s", appName))
+	pods, err := testKube.WaitUntilPodsAreReady(fetchFn)
+	if err != nil {
+		return nil, err
+	}
+	pod := pods[0]
+
+	forwarder, err := c.cluster.NewPortForwarder(pod.Name, pod.Namespace, "", 0, sinkPort)
+	if err != nil {
+		return nil, err
+	}
+	if err := forwarder.Start(); err != nil {
+		return nil, err
+	}
+	c.forwarder = forwarder
+	scopes.Framework.Debugf("initialized otlp collector port forwarder: %v", forwarder.Address())
+
+	c.host = fmt.Sprintf("%s.%s.svc.cluster.local", appName, ns)
+	return c, nil
+}
+
+func (c *kubeComponent) ID() resource.ID {
+	return c.id
+}
+
+func (c *kubeComponent) Host() string {
+	return c.host
+}
+
+func (c *kubeComponent) ListSpans() ([]Span, error) {
+	td := &trace.TracesData{}
+	if err := c.query(sink.TracesPath, td); err != nil {
+		return nil, err
+	}
+	return spansFromProto(td), nil
+}
+
+func (c *kubeComponent) ListMetrics() ([]Metric, error) {
+	md := &metrics.MetricsData{}
+	if err := c.query(sink.MetricsPath, md); err != nil {
+		return nil, err
+	}
+	return metricsFromProto(md), nil
+}
+
+// query reads the data received so far by the sink from the given path.
+func (c *kubeComponent) query(path string, out proto.Message) error {
+	client := http.Client{
+		Timeout: 5 * time.Second,
+	}
+	resp, err := client.Get(fmt.Sprintf("http://%s%s", c.forwarder.Address(), path))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("otlp sink returns non-ok for %s: %v", path, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(body, out)
+}
+
+// Close implements io.Closer.
+func (c *kubeComponent) Close() error {
+	if c.forwarder != nil {
+		c.forwarder.Close()
+	}
+	return nil
+}
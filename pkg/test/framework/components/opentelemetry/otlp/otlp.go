@@ -0,0 +1,94 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otlp provides an OpenTelemetry collector component that keeps everything it receives in memory,
+// so that tests can query the exported spans and metrics directly instead of going through a tracing backend.
+package otlp
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/test/framework/components/cluster"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+const (
+	// GRPCPort is the port of the OTLP gRPC receiver.
+	GRPCPort = 4317
+	// HTTPPort is the port of the OTLP HTTP receiver.
+	HTTPPort = 4318
+	// OpenCensusPort is the port of the OpenCensus receiver, for proxies using the opencensus tracer.
+	OpenCensusPort = 55678
+	// ZipkinPort is the port of the Zipkin receiver, for proxies using the zipkin tracer.
+	ZipkinPort = 9411
+)
+
+// Config represents the configuration for setting up an OTLP collector.
+type Config struct {
+	// Cluster to be used in a multicluster environment
+	Cluster cluster.Cluster
+}
+
+// Instance represents an OpenTelemetry collector deployment on kubernetes, exporting to memory.
+type Instance interface {
+	resource.Resource
+
+	// Host returns the in-cluster hostname of the collector service, to be used as the address
+	// of a tracing provider. See the *Port constants for the receivers it listens on.
+	Host() string
+
+	// ListSpans returns all the spans received by the collector so far.
+	ListSpans() ([]Span, error)
+
+	// ListMetrics returns all the metric data points received by the collector so far.
+	ListMetrics() ([]Metric, error)
+}
+
+// Span represents a single span received by the collector.
+type Span struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	// Kind is the OTLP span kind, for example SPAN_KIND_CLIENT.
+	Kind string
+	// ServiceName is the service.name attribute of the resource that reported the span.
+	ServiceName string
+	Attributes  map[string]string
+}
+
+// Metric represents a single data point of a metric received by the collector.
+type Metric struct {
+	Name string
+	// ServiceName is the service.name attribute of the resource that reported the metric.
+	ServiceName string
+	Attributes  map[string]string
+	// Value of the data point. For histograms, this is the number of recorded values.
+	Value float64
+}
+
+// New returns a new instance of the OTLP collector.
+func New(ctx resource.Context, c Config) (Instance, error) {
+	return newKube(ctx, c)
+}
+
+// NewOrFail returns a new OTLP collector instance or fails the test.
+func NewOrFail(t *testing.T, ctx resource.Context, c Config) Instance {
+	t.Helper()
+	i, err := New(ctx, c)
+	if err != nil {
+		t.Fatalf("otlp.NewOrFail: %v", err)
+	}
+	return i
+}
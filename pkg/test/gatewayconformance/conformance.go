@@ -0,0 +1,301 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gatewayconformance runs Gateway API conformance cases against the in-repo
+// gateway controller and reports the result of each conformance feature.
+//
+// The upstream conformance suite is not available in the version of sigs.k8s.io/gateway-api
+// vendored by this repo, so the cases are defined alongside the integration tests and grouped
+// into the same profiles upstream uses. Profiles are selected with
+// --istio.test.gatewayconformance.profiles.
+package gatewayconformance
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/scopes"
+)
+
+// Profile is a named group of conformance features.
+type Profile string
+
+const (
+	// ProfileCore covers the features every implementation must support.
+	ProfileCore Profile = "core"
+	// ProfileExtended covers optional features that are portable across implementations.
+	ProfileExtended Profile = "extended"
+	// ProfileMesh covers routes attached to the mesh rather than to a Gateway.
+	ProfileMesh Profile = "mesh"
+)
+
+// AllProfiles lists every known profile, in reporting order.
+var AllProfiles = []Profile{ProfileCore, ProfileExtended, ProfileMesh}
+
+// Feature is a single conformance feature. Results are reported per feature.
+type Feature string
+
+const (
+	GatewayClassStatus         Feature = "GatewayClassStatus"
+	GatewayCrossNamespaceRoute Feature = "GatewayCrossNamespaceRoute"
+	HTTPRoutePathMatching      Feature = "HTTPRoutePathMatching"
+	HTTPRouteHeaderMatching    Feature = "HTTPRouteHeaderMatching"
+	HTTPRouteRequestHeaders    Feature = "HTTPRouteRequestHeaderModification"
+	HTTPRouteWeightedBackends  Feature = "HTTPRouteWeightedBackends"
+	HTTPRouteRedirect          Feature = "HTTPRouteRequestRedirect"
+	TCPRoute                   Feature = "TCPRoute"
+	MeshHTTPRoute              Feature = "MeshHTTPRoute"
+)
+
+// featureProfiles maps each feature to the profile that owns it.
+var featureProfiles = map[Feature]Profile{
+	GatewayClassStatus:         ProfileCore,
+	GatewayCrossNamespaceRoute: ProfileCore,
+	HTTPRoutePathMatching:      ProfileCore,
+	HTTPRouteHeaderMatching:    ProfileCore,
+	HTTPRouteRequestHeaders:    ProfileCore,
+	HTTPRouteWeightedBackends:  ProfileCore,
+	HTTPRouteRedirect:          ProfileExtended,
+	TCPRoute:                   ProfileExtended,
+	MeshHTTPRoute:              ProfileMesh,
+}
+
+// ProfileOf returns the profile that owns the feature.
+func ProfileOf(f Feature) (Profile, bool) {
+	p, ok := featureProfiles[f]
+	return p, ok
+}
+
+var profilesFlag = string(ProfileCore)
+
+// init registers the command-line flags exposed for "go test".
+func init() {
+	flag.StringVar(&profilesFlag, "istio.test.gatewayconformance.profiles", profilesFlag,
+		"Comma separated list of Gateway API conformance profiles to run: core, extended, mesh or all.")
+}
+
+// ParseProfiles parses a comma separated list of profiles. "all" selects every profile.
+func ParseProfiles(s string) ([]Profile, error) {
+	seen := map[Profile]bool{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if p == "all" {
+			return append([]Profile(nil), AllProfiles...), nil
+		}
+		known := false
+		for _, k := range AllProfiles {
+			if Profile(p) == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown conformance profile %q", p)
+		}
+		seen[Profile(p)] = true
+	}
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("no conformance profile selected")
+	}
+	var out []Profile
+	for _, p := range AllProfiles {
+		if seen[p] {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// TestCase is a single conformance check. A case runs only if every feature it exercises
+// belongs to a selected profile, and its outcome is attributed to each of those features.
+type TestCase struct {
+	Name     string
+	Features []Feature
+	Run      func(t framework.TestContext)
+}
+
+// Result of a feature or test case.
+type Result string
+
+const (
+	Passed  Result = "Passed"
+	Failed  Result = "Failed"
+	Skipped Result = "Skipped"
+)
+
+// FeatureResult is the conformance outcome of a single feature.
+type FeatureResult struct {
+	Feature Feature  `json:"feature"`
+	Profile Profile  `json:"profile"`
+	Result  Result   `json:"result"`
+	Passed  []string `json:"passed,omitempty"`
+	Failed  []string `json:"failed,omitempty"`
+}
+
+// Report summarizes a conformance run.
+type Report struct {
+	Profiles []Profile       `json:"profiles"`
+	Features []FeatureResult `json:"features"`
+}
+
+// FailedFeatures returns the features that did not pass.
+func (r Report) FailedFeatures() []Feature {
+	var out []Feature
+	for _, f := range r.Features {
+		if f.Result == Failed {
+			out = append(out, f.Feature)
+		}
+	}
+	return out
+}
+
+// ReportFile is the name of the report written to the test work directory.
+const ReportFile = "gateway-conformance-report.json"
+
+// Run executes the cases selected by --istio.test.gatewayconformance.profiles as sub tests,
+// then writes a per-feature report to the work directory and logs a summary.
+func Run(t framework.TestContext, cases []TestCase) Report {
+	profiles, err := ParseProfiles(profilesFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newRecorder(profiles)
+	for _, c := range cases {
+		c := c
+		if err := r.validate(c); err != nil {
+			t.Fatal(err)
+		}
+		if !r.selected(c) {
+			r.record(c, Skipped)
+			continue
+		}
+		t.NewSubTest(c.Name).Run(func(t framework.TestContext) {
+			defer func() {
+				if t.Failed() {
+					r.record(c, Failed)
+				} else {
+					r.record(c, Passed)
+				}
+			}()
+			c.Run(t)
+		})
+	}
+	report := r.report()
+	writeReport(t, report)
+	return report
+}
+
+type recorder struct {
+	profiles map[Profile]bool
+	ordered  []Profile
+	features map[Feature]*FeatureResult
+}
+
+func newRecorder(profiles []Profile) *recorder {
+	r := &recorder{
+		profiles: map[Profile]bool{},
+		ordered:  profiles,
+		features: map[Feature]*FeatureResult{},
+	}
+	for _, p := range profiles {
+		r.profiles[p] = true
+	}
+	return r
+}
+
+func (r *recorder) validate(c TestCase) error {
+	if len(c.Features) == 0 {
+		return fmt.Errorf("conformance case %q exercises no feature", c.Name)
+	}
+	for _, f := range c.Features {
+		if _, ok := featureProfiles[f]; !ok {
+			return fmt.Errorf("conformance case %q uses unknown feature %q", c.Name, f)
+		}
+	}
+	return nil
+}
+
+func (r *recorder) selected(c TestCase) bool {
+	for _, f := range c.Features {
+		if !r.profiles[featureProfiles[f]] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *recorder) record(c TestCase, res Result) {
+	for _, f := range c.Features {
+		fr, ok := r.features[f]
+		if !ok {
+			fr = &FeatureResult{Feature: f, Profile: featureProfiles[f], Result: Skipped}
+			r.features[f] = fr
+		}
+		switch res {
+		case Passed:
+			fr.Passed = append(fr.Passed, c.Name)
+			if fr.Result == Skipped {
+				fr.Result = Passed
+			}
+		case Failed:
+			fr.Failed = append(fr.Failed, c.Name)
+			fr.Result = Failed
+		}
+	}
+}
+
+func (r *recorder) report() Report {
+	rep := Report{Profiles: r.ordered}
+	for _, fr := range r.features {
+		rep.Features = append(rep.Features, *fr)
+	}
+	rank := map[Profile]int{}
+	for i, p := range AllProfiles {
+		rank[p] = i
+	}
+	sort.Slice(rep.Features, func(i, j int) bool {
+		a, b := rep.Features[i], rep.Features[j]
+		if a.Profile != b.Profile {
+			return rank[a.Profile] < rank[b.Profile]
+		}
+		return a.Feature < b.Feature
+	})
+	return rep
+}
+
+func writeReport(t framework.TestContext, r Report) {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.WorkDir(), ReportFile)
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatalf("failed to write conformance report: %v", err)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Gateway API conformance (profiles %v), report written to %s:\n", r.Profiles, path)
+	for _, f := range r.Features {
+		fmt.Fprintf(&sb, "  %-10s %-36s %s\n", f.Profile, f.Feature, f.Result)
+	}
+	scopes.Framework.Info(sb.String())
+}
@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gatewayconformance
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProfiles(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    []Profile
+		wantErr bool
+	}{
+		{in: "core", want: []Profile{ProfileCore}},
+		{in: "mesh, CORE", want: []Profile{ProfileCore, ProfileMesh}},
+		{in: "extended,extended", want: []Profile{ProfileExtended}},
+		{in: "all", want: AllProfiles},
+		{in: "", wantErr: true},
+		{in: "core,experimental", wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseProfiles(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wanted error %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	r := newRecorder([]Profile{ProfileCore})
+	paths := TestCase{Name: "paths", Features: []Feature{HTTPRoutePathMatching}}
+	headers := TestCase{Name: "headers", Features: []Feature{HTTPRoutePathMatching, HTTPRouteHeaderMatching}}
+	tcp := TestCase{Name: "tcp", Features: []Feature{TCPRoute}}
+	if !r.selected(paths) || !r.selected(headers) {
+		t.Fatal("expected core cases to be selected")
+	}
+	if r.selected(tcp) {
+		t.Fatal("expected extended case to be skipped")
+	}
+	if err := r.validate(TestCase{Name: "unknown", Features: []Feature{"Unknown"}}); err == nil {
+		t.Fatal("expected unknown feature to be rejected")
+	}
+	r.record(paths, Passed)
+	r.record(headers, Failed)
+	r.record(tcp, Skipped)
+
+	got := r.report()
+	want := Report{
+		Profiles: []Profile{ProfileCore},
+		Features: []FeatureResult{
+			{Feature: HTTPRouteHeaderMatching, Profile: ProfileCore, Result: Failed, Failed: []string{"headers"}},
+			{Feature: HTTPRoutePathMatching, Profile: ProfileCore, Result: Failed, Passed: []string{"paths"}, Failed: []string{"headers"}},
+			{Feature: TCPRoute, Profile: ProfileExtended, Result: Skipped},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if f := got.FailedFeatures(); !reflect.DeepEqual(f, []Feature{HTTPRouteHeaderMatching, HTTPRoutePathMatching}) {
+		t.Fatalf("unexpected failed features %v", f)
+	}
+}
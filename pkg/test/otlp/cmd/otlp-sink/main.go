@@ -0,0 +1,97 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+
+	"istio.io/istio/pkg/cmd"
+	"istio.io/istio/pkg/test/otlp/sink"
+	"istio.io/pkg/log"
+)
+
+var (
+	grpcPort int
+	httpPort int
+
+	loggingOptions = log.DefaultOptions()
+
+	rootCmd = &cobra.Command{
+		Use:               "otlp-sink",
+		Short:             "In-memory OTLP receiver.",
+		SilenceUsage:      true,
+		Long:              `OTLP receiver keeping the exported spans and metrics in memory, to be queried by the tests`,
+		PersistentPreRunE: configureLogging,
+		RunE: func(c *cobra.Command, args []string) error {
+			s := sink.New()
+
+			grpcListener, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+			if err != nil {
+				return err
+			}
+			grpcServer := grpc.NewServer()
+			s.Register(grpcServer)
+			go func() {
+				if err := grpcServer.Serve(grpcListener); err != nil {
+					log.Errorf("failed serving OTLP: %v", err)
+				}
+			}()
+			defer grpcServer.Stop()
+
+			httpServer := &http.Server{Addr: fmt.Sprintf(":%d", httpPort), Handler: s}
+			go func() {
+				if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Errorf("failed serving the exported data: %v", err)
+				}
+			}()
+			defer func() {
+				_ = httpServer.Close()
+			}()
+
+			// Wait for the process to be shutdown.
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+			<-sigs
+			return nil
+		},
+	}
+)
+
+func configureLogging(_ *cobra.Command, _ []string) error {
+	return log.Configure(loggingOptions)
+}
+
+func init() {
+	rootCmd.PersistentFlags().IntVar(&grpcPort, "grpc", 4319, "OTLP gRPC port")
+	rootCmd.PersistentFlags().IntVar(&httpPort, "port", 8080, "HTTP port serving the exported spans and metrics")
+
+	loggingOptions.AttachCobraFlags(rootCmd)
+
+	cmd.AddFlags(rootCmd)
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(-1)
+	}
+}
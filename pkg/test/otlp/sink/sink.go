@@ -0,0 +1,162 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sink implements an OTLP receiver which keeps the spans and metrics it receives in memory, and serves
+// them back over HTTP so that the tests can query what was exported.
+package sink
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	metrics "go.opentelemetry.io/proto/otlp/metrics/v1"
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	// TracesPath serves the received spans, as a protobuf encoded TracesData.
+	TracesPath = "/traces"
+	// MetricsPath serves the received metrics, as a protobuf encoded MetricsData.
+	MetricsPath = "/metrics"
+
+	// maxResources bounds the number of resource spans and resource metrics kept, the oldest are dropped first.
+	maxResources = 10000
+)
+
+// Sink keeps the spans and metrics exported to it in memory.
+type Sink struct {
+	mu      sync.RWMutex
+	spans   []*trace.ResourceSpans
+	metrics []*metrics.ResourceMetrics
+}
+
+func New() *Sink {
+	return &Sink{}
+}
+
+// Register registers the OTLP trace and metrics services of the sink on the gRPC server.
+func (s *Sink) Register(server *grpc.Server) {
+	server.RegisterService(&traceServiceDesc, s)
+	server.RegisterService(&metricsServiceDesc, s)
+}
+
+// ServeHTTP serves the spans on TracesPath and the metrics on MetricsPath.
+func (s *Sink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var msg proto.Message
+	s.mu.RLock()
+	switch r.URL.Path {
+	case TracesPath:
+		msg = &trace.TracesData{ResourceSpans: s.spans}
+	case MetricsPath:
+		msg = &metrics.MetricsData{ResourceMetrics: s.metrics}
+	}
+	// the stored messages are never modified, only replaced, so they can be marshaled while the lock is held
+	var b []byte
+	var err error
+	if msg != nil {
+		b, err = proto.Marshal(msg)
+	}
+	s.mu.RUnlock()
+	if msg == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(b)
+}
+
+func (s *Sink) addSpans(spans []*trace.ResourceSpans) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spans = append(s.spans, spans...)
+	if n := len(s.spans) - maxResources; n > 0 {
+		s.spans = append([]*trace.ResourceSpans(nil), s.spans[n:]...)
+	}
+}
+
+func (s *Sink) addMetrics(ms []*metrics.ResourceMetrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = append(s.metrics, ms...)
+	if n := len(s.metrics) - maxResources; n > 0 {
+		s.metrics = append([]*metrics.ResourceMetrics(nil), s.metrics[n:]...)
+	}
+}
+
+// The services are described by hand rather than registered from the OTLP collector packages, which would pull
+// grpc-gateway in. The export requests hold the resources in their first field, as TracesData and MetricsData do, so
+// they are decoded as such, and their responses are empty.
+const (
+	traceService   = "opentelemetry.proto.collector.trace.v1.TraceService"
+	metricsService = "opentelemetry.proto.collector.metrics.v1.MetricsService"
+)
+
+var (
+	traceServiceDesc = grpc.ServiceDesc{
+		ServiceName: traceService,
+		HandlerType: (*interface{})(nil),
+		Methods:     []grpc.MethodDesc{{MethodName: "Export", Handler: exportTraces}},
+	}
+	metricsServiceDesc = grpc.ServiceDesc{
+		ServiceName: metricsService,
+		HandlerType: (*interface{})(nil),
+		Methods:     []grpc.MethodDesc{{MethodName: "Export", Handler: exportMetrics}},
+	}
+)
+
+func exportTraces(srv interface{}, ctx context.Context, dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &trace.TracesData{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	handler := func(_ context.Context, req interface{}) (interface{}, error) {
+		srv.(*Sink).addSpans(req.(*trace.TracesData).GetResourceSpans())
+		return &emptypb.Empty{}, nil
+	}
+	if interceptor == nil {
+		return handler(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + traceService + "/Export"}
+	return interceptor(ctx, req, info, handler)
+}
+
+func exportMetrics(srv interface{}, ctx context.Context, dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &metrics.MetricsData{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	handler := func(_ context.Context, req interface{}) (interface{}, error) {
+		srv.(*Sink).addMetrics(req.(*metrics.MetricsData).GetResourceMetrics())
+		return &emptypb.Empty{}, nil
+	}
+	if interceptor == nil {
+		return handler(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + metricsService + "/Export"}
+	return interceptor(ctx, req, info, handler)
+}
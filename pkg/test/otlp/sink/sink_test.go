@@ -0,0 +1,101 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	trace "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestSink(t *testing.T) {
+	s := New()
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	s.Register(grpcServer)
+	go func() {
+		_ = grpcServer.Serve(l)
+	}()
+	defer grpcServer.Stop()
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	get := func(path string) (int, []byte) {
+		t.Helper()
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, b
+	}
+
+	if code, _ := get("/unknown"); code != http.StatusNotFound {
+		t.Fatalf("expected %d for an unknown path, got %d", http.StatusNotFound, code)
+	}
+
+	for i := 0; i < maxResources+1; i++ {
+		span := &trace.Span{Name: "span"}
+		if i == maxResources {
+			span.Name = "last"
+		}
+		// the export request is encoded as TracesData, which it is wire compatible with
+		req := &trace.TracesData{ResourceSpans: []*trace.ResourceSpans{{
+			InstrumentationLibrarySpans: []*trace.InstrumentationLibrarySpans{{Spans: []*trace.Span{span}}},
+		}}}
+		err := conn.Invoke(context.Background(), "/"+traceService+"/Export",
+			req, &emptypb.Empty{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, b := get(TracesPath)
+	if code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, code)
+	}
+	td := &trace.TracesData{}
+	if err := proto.Unmarshal(b, td); err != nil {
+		t.Fatal(err)
+	}
+	if len(td.ResourceSpans) != maxResources {
+		t.Fatalf("expected %d resource spans to be kept, got %d", maxResources, len(td.ResourceSpans))
+	}
+	if got := td.ResourceSpans[maxResources-1].InstrumentationLibrarySpans[0].Spans[0].Name; got != "last" {
+		t.Fatalf("expected the latest span to be kept, got %q", got)
+	}
+}
@@ -18,6 +18,7 @@ set -euo pipefail
 
 SINGLE_CLUSTER=0
 REVISION=""
+TUNNEL=0
 while (( "$#" )); do
   case "$1" in
     --single-cluster)
@@ -40,6 +41,11 @@ while (( "$#" )); do
       REVISION=$2
       shift 2
     ;;
+    --tunnel)
+      # Expose the port terminating the HTTP CONNECT tunnels of the networks in the PILOT_CROSS_NETWORK_TUNNEL_NETWORKS of istiod
+      TUNNEL=1
+      shift
+    ;;
     -*)
       echo "Error: Unsupported flag $1" >&2
       exit 1
@@ -123,6 +129,16 @@ $IOP
 EOF
 )
 
+if [[ "${TUNNEL}" -eq 1 ]]; then
+  IOP=$(cat <<EOF
+$IOP
+              - name: tcp-tunnel
+                port: 15009
+                targetPort: 15009
+EOF
+)
+fi
+
 # Gateway injection template
 IOP=$(cat <<EOF
 $IOP
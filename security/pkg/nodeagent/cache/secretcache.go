@@ -45,7 +45,7 @@ var (
 )
 
 const (
-	// The size of a private key for a leaf certificate.
+	// The default size of a private key for a leaf certificate.
 	keySize = 2048
 
 	// firstRetryBackOffInMilliSec is the initial backoff time interval when hitting
@@ -558,9 +558,13 @@ func (sc *SecretManagerClient) generateNewSecret(resourceName string) (*security
 	}
 
 	cacheLog.Debugf("constructed host name for CSR: %s", csrHostName.String())
+	rsaKeySize := sc.configOptions.RSAKeySize
+	if rsaKeySize <= 0 {
+		rsaKeySize = keySize
+	}
 	options := pkiutil.CertOptions{
 		Host:       csrHostName.String(),
-		RSAKeySize: keySize,
+		RSAKeySize: rsaKeySize,
 		PKCS8Key:   sc.configOptions.Pkcs8Keys,
 		ECSigAlg:   pkiutil.SupportedECSignatureAlgorithms(sc.configOptions.ECCSigAlg),
 	}
@@ -0,0 +1,273 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package spire implements a SecretManager that obtains workload SVIDs and trust bundles from a SPIRE agent,
+// over the Envoy SDS API the SPIRE agent exposes on its workload API socket.
+package spire
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	sds "github.com/envoyproxy/go-control-plane/envoy/service/secret/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/security"
+	nodeagentutil "istio.io/istio/security/pkg/nodeagent/util"
+	"istio.io/pkg/log"
+)
+
+var spireLog = log.RegisterScope("spire", "SPIRE secret manager debugging", 0)
+
+// secretWaitTimeout is how long GenerateSecret waits for the first secret from the SPIRE agent.
+const secretWaitTimeout = 10 * time.Second
+
+// SecretManager passes through the secrets of a SPIRE agent. The Istio resource names requested by Envoy
+// ("default" and "ROOTCA") are mapped to the names of the SVID and trust bundle configured in the SPIRE
+// agent. Updates streamed by the SPIRE agent, such as trust bundle rotations, are notified with the
+// update callback.
+type SecretManager struct {
+	conn   *grpc.ClientConn
+	client sds.SecretDiscoveryServiceClient
+	cancel context.CancelFunc
+
+	// names maps Istio resource names to SPIRE secret names.
+	names map[string]string
+
+	mu sync.RWMutex
+	// secrets holds the latest secrets received from SPIRE, keyed by SPIRE secret name.
+	secrets map[string]*tls.Secret
+	// updated is closed, and replaced, whenever new secrets are received.
+	updated          chan struct{}
+	notifyCallback   func(resourceName string)
+	configTrustRoots []byte
+}
+
+var _ security.SecretManager = &SecretManager{}
+
+// NewSecretManager connects to the SPIRE agent on the given unix socket, and starts watching the SVID
+// and trust bundle with the given SPIRE names.
+func NewSecretManager(socketPath, svidName, bundleName string) (*SecretManager, error) {
+	conn, err := grpc.Dial("unix://"+socketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SPIRE agent at %s: %v", socketPath, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sm := &SecretManager{
+		conn:   conn,
+		client: sds.NewSecretDiscoveryServiceClient(conn),
+		cancel: cancel,
+		names: map[string]string{
+			security.WorkloadKeyCertResourceName: svidName,
+			security.RootCertReqResourceName:     bundleName,
+		},
+		secrets: map[string]*tls.Secret{},
+		updated: make(chan struct{}),
+	}
+	spireLog.Infof("fetching workload secrets from SPIRE agent at %s (SVID %q, bundle %q)", socketPath, svidName, bundleName)
+	go sm.run(ctx)
+	return sm, nil
+}
+
+// run keeps a stream open to the SPIRE agent, reconnecting with backoff when it fails.
+func (sm *SecretManager) run(ctx context.Context) {
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = 0
+	for {
+		err := sm.stream(ctx, b.Reset)
+		if ctx.Err() != nil {
+			return
+		}
+		delay := b.NextBackOff()
+		spireLog.Warnf("SPIRE agent stream failed, retrying in %v: %v", delay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (sm *SecretManager) stream(ctx context.Context, connected func()) error {
+	stream, err := sm.client.StreamSecrets(ctx)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(sm.names))
+	for _, name := range sm.names {
+		names = append(names, name)
+	}
+	req := &discovery.DiscoveryRequest{TypeUrl: v3.SecretType, ResourceNames: names}
+	for {
+		if err := stream.Send(req); err != nil {
+			return err
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		connected()
+		secrets := make([]*tls.Secret, 0, len(resp.Resources))
+		for _, r := range resp.Resources {
+			secret := &tls.Secret{}
+			if err := r.UnmarshalTo(secret); err != nil {
+				return fmt.Errorf("invalid secret from SPIRE agent: %v", err)
+			}
+			secrets = append(secrets, secret)
+		}
+		sm.update(secrets)
+		// ACK the response. SPIRE pushes further updates on the same stream.
+		req = &discovery.DiscoveryRequest{
+			TypeUrl:       v3.SecretType,
+			ResourceNames: names,
+			VersionInfo:   resp.VersionInfo,
+			ResponseNonce: resp.Nonce,
+		}
+	}
+}
+
+// update stores the received secrets and notifies the Istio resources whose secret changed.
+func (sm *SecretManager) update(secrets []*tls.Secret) {
+	sm.mu.Lock()
+	var changed []string
+	for _, secret := range secrets {
+		if old, f := sm.secrets[secret.Name]; f && secretEqual(old, secret) {
+			continue
+		}
+		sm.secrets[secret.Name] = secret
+		for resourceName, spireName := range sm.names {
+			if spireName == secret.Name {
+				changed = append(changed, resourceName)
+			}
+		}
+	}
+	close(sm.updated)
+	sm.updated = make(chan struct{})
+	notify := sm.notifyCallback
+	sm.mu.Unlock()
+
+	for _, resourceName := range changed {
+		spireLog.Infof("received updated %s secret from SPIRE agent", resourceName)
+		if notify != nil {
+			notify(resourceName)
+		}
+	}
+}
+
+func secretEqual(a, b *tls.Secret) bool {
+	return bytes.Equal(secretBytes(a), secretBytes(b))
+}
+
+func secretBytes(s *tls.Secret) []byte {
+	if vc := s.GetValidationContext(); vc != nil {
+		return vc.GetTrustedCa().GetInlineBytes()
+	}
+	cert := s.GetTlsCertificate()
+	return append(append([]byte{}, cert.GetCertificateChain().GetInlineBytes()...), cert.GetPrivateKey().GetInlineBytes()...)
+}
+
+// GenerateSecret returns the SPIRE secret mapped to the given Istio resource name, waiting for the SPIRE
+// agent to send it if needed.
+func (sm *SecretManager) GenerateSecret(resourceName string) (*security.SecretItem, error) {
+	spireName, f := sm.names[resourceName]
+	if !f {
+		return nil, fmt.Errorf("resource %q is not supported with SPIRE", resourceName)
+	}
+	timeout := time.After(secretWaitTimeout)
+	for {
+		sm.mu.RLock()
+		secret, updated, extraRoots := sm.secrets[spireName], sm.updated, sm.configTrustRoots
+		sm.mu.RUnlock()
+		if secret != nil {
+			return secretItem(resourceName, secret, extraRoots)
+		}
+		select {
+		case <-updated:
+		case <-timeout:
+			return nil, fmt.Errorf("timed out waiting for secret %q from SPIRE agent", spireName)
+		}
+	}
+}
+
+func secretItem(resourceName string, secret *tls.Secret, extraRoots []byte) (*security.SecretItem, error) {
+	now := time.Now()
+	if resourceName == security.RootCertReqResourceName {
+		root := secret.GetValidationContext().GetTrustedCa().GetInlineBytes()
+		if len(root) == 0 {
+			return nil, fmt.Errorf("SPIRE secret %q has no trust bundle", secret.Name)
+		}
+		if len(extraRoots) > 0 {
+			root = append(append(append([]byte{}, root...), '\n'), extraRoots...)
+		}
+		return &security.SecretItem{
+			RootCert:     root,
+			ResourceName: resourceName,
+			CreatedTime:  now,
+		}, nil
+	}
+	cert := secret.GetTlsCertificate()
+	chain, key := cert.GetCertificateChain().GetInlineBytes(), cert.GetPrivateKey().GetInlineBytes()
+	if len(chain) == 0 || len(key) == 0 {
+		return nil, fmt.Errorf("SPIRE secret %q has no certificate or key", secret.Name)
+	}
+	expire, err := nodeagentutil.ParseCertAndGetExpiryTimestamp(chain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SVID from SPIRE: %v", err)
+	}
+	return &security.SecretItem{
+		CertificateChain: chain,
+		PrivateKey:       key,
+		ResourceName:     resourceName,
+		CreatedTime:      now,
+		ExpireTime:       expire,
+	}, nil
+}
+
+// SetUpdateCallback sets the callback called when a secret changes.
+func (sm *SecretManager) SetUpdateCallback(f func(resourceName string)) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.notifyCallback = f
+}
+
+// UpdateConfigTrustBundle sets additional trust anchors, from the proxy config, to append to the SPIRE
+// trust bundle.
+func (sm *SecretManager) UpdateConfigTrustBundle(trustBundle []byte) error {
+	sm.mu.Lock()
+	if bytes.Equal(sm.configTrustRoots, trustBundle) {
+		sm.mu.Unlock()
+		return nil
+	}
+	sm.configTrustRoots = trustBundle
+	notify := sm.notifyCallback
+	sm.mu.Unlock()
+	if notify != nil {
+		notify(security.RootCertReqResourceName)
+	}
+	return nil
+}
+
+// Close stops watching the SPIRE agent.
+func (sm *SecretManager) Close() {
+	sm.cancel()
+	_ = sm.conn.Close()
+}
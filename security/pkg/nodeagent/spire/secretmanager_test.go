@@ -0,0 +1,216 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spire
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	sds "github.com/envoyproxy/go-control-plane/envoy/service/secret/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// fakeSpireAgent serves secrets over the SDS API, like the SPIRE agent does. Secrets set with push are
+// sent to all connected streams.
+type fakeSpireAgent struct {
+	sds.UnimplementedSecretDiscoveryServiceServer
+
+	mu      sync.Mutex
+	secrets []*tls.Secret
+	version int
+	streams []chan struct{}
+}
+
+func (f *fakeSpireAgent) push(secrets ...*tls.Secret) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secrets = secrets
+	f.version++
+	for _, ch := range f.streams {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (f *fakeSpireAgent) StreamSecrets(stream sds.SecretDiscoveryService_StreamSecretsServer) error {
+	ch := make(chan struct{}, 1)
+	ch <- struct{}{}
+	f.mu.Lock()
+	f.streams = append(f.streams, ch)
+	f.mu.Unlock()
+
+	reqs := make(chan error, 1)
+	go func() {
+		for {
+			if _, err := stream.Recv(); err != nil {
+				reqs <- err
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case err := <-reqs:
+			return err
+		case <-ch:
+		}
+		f.mu.Lock()
+		resp := &discovery.DiscoveryResponse{VersionInfo: strconv.Itoa(f.version), Nonce: strconv.Itoa(f.version)}
+		for _, s := range f.secrets {
+			a, err := anypb.New(s)
+			if err != nil {
+				f.mu.Unlock()
+				return err
+			}
+			resp.Resources = append(resp.Resources, a)
+		}
+		f.mu.Unlock()
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func bundleSecret(name string, bundle []byte) *tls.Secret {
+	return &tls.Secret{
+		Name: name,
+		Type: &tls.Secret_ValidationContext{ValidationContext: &tls.CertificateValidationContext{
+			TrustedCa: &core.DataSource{Specifier: &core.DataSource_InlineBytes{InlineBytes: bundle}},
+		}},
+	}
+}
+
+func svidSecret(t *testing.T, name string) *tls.Secret {
+	cert, key, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Host:         "spiffe://example.org/ns/default/sa/default",
+		NotBefore:    time.Now(),
+		TTL:          time.Hour,
+		Org:          "SPIRE",
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Secret{
+		Name: name,
+		Type: &tls.Secret_TlsCertificate{TlsCertificate: &tls.TlsCertificate{
+			CertificateChain: &core.DataSource{Specifier: &core.DataSource_InlineBytes{InlineBytes: cert}},
+			PrivateKey:       &core.DataSource{Specifier: &core.DataSource_InlineBytes{InlineBytes: key}},
+		}},
+	}
+}
+
+func TestSecretManager(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "spire.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := &fakeSpireAgent{}
+	server := grpc.NewServer()
+	sds.RegisterSecretDiscoveryServiceServer(server, agent)
+	go func() {
+		_ = server.Serve(l)
+	}()
+	defer server.Stop()
+
+	sm, err := NewSecretManager(socket, "spiffe://example.org/ns/default/sa/default", "spiffe://example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Close()
+
+	var updatesMu sync.Mutex
+	updates := map[string]int{}
+	sm.SetUpdateCallback(func(resourceName string) {
+		updatesMu.Lock()
+		defer updatesMu.Unlock()
+		updates[resourceName]++
+	})
+	// Only send secrets once the callback is set, so all updates are counted
+	svid := svidSecret(t, "spiffe://example.org/ns/default/sa/default")
+	agent.push(svid, bundleSecret("spiffe://example.org", []byte("bundle-1")))
+
+	item, err := sm.GenerateSecret(security.WorkloadKeyCertResourceName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(item.CertificateChain, svid.GetTlsCertificate().CertificateChain.GetInlineBytes()) || item.ExpireTime.IsZero() {
+		t.Fatalf("unexpected workload secret %+v", item)
+	}
+	root, err := sm.GenerateSecret(security.RootCertReqResourceName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(root.RootCert) != "bundle-1" {
+		t.Fatalf("unexpected trust bundle %q", root.RootCert)
+	}
+	if _, err := sm.GenerateSecret("file-root:/etc/certs/root-cert.pem"); err == nil {
+		t.Fatalf("expected unmapped resource to be rejected")
+	}
+
+	t.Run("trust bundle update", func(t *testing.T) {
+		agent.push(svid, bundleSecret("spiffe://example.org", []byte("bundle-2")))
+		retry.UntilSuccessOrFail(t, func() error {
+			root, err := sm.GenerateSecret(security.RootCertReqResourceName)
+			if err != nil {
+				return err
+			}
+			if string(root.RootCert) != "bundle-2" {
+				return fmt.Errorf("expected updated trust bundle, got %q", root.RootCert)
+			}
+			return nil
+		}, retry.Timeout(5*time.Second))
+		retry.UntilSuccessOrFail(t, func() error {
+			updatesMu.Lock()
+			defer updatesMu.Unlock()
+			// The SVID is unchanged, so it should only have been notified for the initial secrets
+			if updates[security.RootCertReqResourceName] != 2 || updates[security.WorkloadKeyCertResourceName] != 1 {
+				return fmt.Errorf("expected only the root cert to be updated, got %v", updates)
+			}
+			return nil
+		}, retry.Timeout(5*time.Second))
+	})
+
+	t.Run("config trust bundle", func(t *testing.T) {
+		if err := sm.UpdateConfigTrustBundle([]byte("extra")); err != nil {
+			t.Fatal(err)
+		}
+		root, err := sm.GenerateSecret(security.RootCertReqResourceName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(root.RootCert) != "bundle-2\nextra" {
+			t.Fatalf("expected merged trust bundle, got %q", root.RootCert)
+		}
+	})
+}
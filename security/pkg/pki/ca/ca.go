@@ -16,6 +16,8 @@ package ca
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -92,6 +94,10 @@ type IstioCAOptions struct {
 
 	KeyCertBundle *util.KeyCertBundle
 
+	// Signer, if set, signs certificates in place of the private key of the KeyCertBundle, for CA keys held
+	// in a KMS or HSM.
+	Signer crypto.Signer
+
 	// Config for creating self-signed root cert rotator.
 	RotatorConfig *SelfSignedCARootCertRotatorConfig
 }
@@ -261,6 +267,46 @@ func NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, signingKeyFile
 	return caOpts, nil
 }
 
+// NewPluggedCertSignerIstioCAOptions returns a new IstioCAOptions instance using the given certificate, whose
+// private key is held by the signer, such as a KMS or HSM, instead of a file.
+func NewPluggedCertSignerIstioCAOptions(certChainFile, signingCertFile, rootCertFile string, signer crypto.Signer,
+	defaultCertTTL, maxCertTTL time.Duration) (caOpts *IstioCAOptions, err error) {
+	certBytes, err := os.ReadFile(signingCertFile)
+	if err != nil {
+		return nil, err
+	}
+	certChainBytes := []byte{}
+	if _, err := os.Stat(certChainFile); err == nil {
+		if certChainBytes, err = os.ReadFile(certChainFile); err != nil {
+			return nil, err
+		}
+	}
+	rootCertBytes, err := os.ReadFile(rootCertFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := util.ParsePemEncodedCertificate(certBytes)
+	if err != nil {
+		return nil, err
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("certificate is not authorized to sign other certificates")
+	}
+	pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(cert.PublicKey) {
+		return nil, fmt.Errorf("the public key of the CA signer does not match the signing certificate")
+	}
+
+	return &IstioCAOptions{
+		CAType:         pluggedCertCA,
+		DefaultCertTTL: defaultCertTTL,
+		MaxCertTTL:     maxCertTTL,
+		KeyCertBundle:  util.NewKeyCertBundleFromPem(certBytes, nil, certChainBytes, rootCertBytes),
+		Signer:         signer,
+	}, nil
+}
+
 // IstioCA generates keys and certificates for Istio identities.
 type IstioCA struct {
 	defaultCertTTL time.Duration
@@ -268,6 +314,8 @@ type IstioCA struct {
 	caRSAKeySize   int
 
 	keyCertBundle *util.KeyCertBundle
+	// signer signs certificates in place of the private key of keyCertBundle, if set.
+	signer crypto.Signer
 
 	// rootCertRotator periodically rotates self-signed root cert for CA. It is nil
 	// if CA is not self-signed CA.
@@ -280,6 +328,7 @@ func NewIstioCA(opts *IstioCAOptions) (*IstioCA, error) {
 		maxCertTTL:    opts.MaxCertTTL,
 		keyCertBundle: opts.KeyCertBundle,
 		caRSAKeySize:  opts.CARSAKeySize,
+		signer:        opts.Signer,
 	}
 
 	if opts.CAType == selfSignedCA && opts.RotatorConfig != nil && opts.RotatorConfig.CheckInterval > time.Duration(0) {
@@ -336,7 +385,11 @@ func (ca *IstioCA) GenKeyCert(hostnames []string, certTTL time.Duration, checkLi
 	// use the type of private key the CA uses to generate an intermediate CA of that type (e.g. CA cert using RSA will
 	// cause intermediate CAs using RSA to be generated)
 	_, signingKey, _, _ := ca.keyCertBundle.GetAll()
-	if util.IsSupportedECPrivateKey(signingKey) {
+	if ca.signer != nil {
+		if _, ok := ca.signer.Public().(*ecdsa.PublicKey); ok {
+			opts.ECSigAlg = util.EcdsaSigAlg
+		}
+	} else if util.IsSupportedECPrivateKey(signingKey) {
 		opts.ECSigAlg = util.EcdsaSigAlg
 	}
 
@@ -397,7 +450,11 @@ func (ca *IstioCA) sign(csrPEM []byte, subjectIDs []string, requestedLifetime ti
 			"requested TTL %s is greater than the max allowed TTL %s", requestedLifetime, ca.maxCertTTL))
 	}
 
-	certBytes, err := util.GenCertFromCSR(csr, signingCert, csr.PublicKey, *signingKey, subjectIDs, lifetime, forCA)
+	var key crypto.PrivateKey = *signingKey
+	if ca.signer != nil {
+		key = ca.signer
+	}
+	certBytes, err := util.GenCertFromCSR(csr, signingCert, csr.PublicKey, key, subjectIDs, lifetime, forCA)
 	if err != nil {
 		return nil, caerror.NewError(caerror.CertGenError, err)
 	}
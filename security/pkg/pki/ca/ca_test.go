@@ -17,6 +17,7 @@ package ca
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/tls"
 	"crypto/x509"
 	"os"
@@ -739,3 +740,56 @@ func comparePem(expectedBytes []byte, file string) bool {
 	}
 	return true
 }
+
+func TestCreatePluggedCertSignerCA(t *testing.T) {
+	rootCertFile := "../testdata/multilevelpki/root-cert.pem"
+	certChainFile := "../testdata/multilevelpki/int2-cert-chain.pem"
+	signingCertFile := "../testdata/multilevelpki/int2-cert.pem"
+	signingKeyFile := "../testdata/multilevelpki/int2-key.pem"
+
+	keyPem, err := os.ReadFile(signingKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := util.ParsePemEncodedKey(keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := key.(crypto.Signer)
+
+	if _, err := NewPluggedCertSignerIstioCAOptions(certChainFile, "../testdata/multilevelpki/int-cert.pem",
+		rootCertFile, signer, time.Hour, time.Hour); err == nil {
+		t.Fatalf("expected a signer not matching the signing cert to be rejected")
+	}
+
+	caopts, err := NewPluggedCertSignerIstioCAOptions(certChainFile, signingCertFile, rootCertFile, signer,
+		time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create a plugged-cert CA Options: %v", err)
+	}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Got error while creating plugged-cert CA: %v", err)
+	}
+	_, signingKeyBytes, _, _ := ca.GetCAKeyCertBundle().GetAllPem()
+	if len(signingKeyBytes) != 0 {
+		t.Fatalf("expected no signing key in the CA bundle")
+	}
+
+	csrPEM, _, err := util.GenCSR(util.CertOptions{Host: "spiffe://example.com/ns/foo/sa/bar", RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := ca.Sign(csrPEM, CertOpts{SubjectIDs: []string{"spiffe://example.com/ns/foo/sa/bar"}, TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to sign with the CA signer: %v", err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingCert, _, _, _ := ca.GetCAKeyCertBundle().GetAll()
+	if err := cert.CheckSignatureFrom(signingCert); err != nil {
+		t.Fatalf("certificate is not signed by the CA signing cert: %v", err)
+	}
+}
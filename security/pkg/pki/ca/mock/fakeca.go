@@ -15,6 +15,8 @@
 package mock
 
 import (
+	"time"
+
 	"istio.io/istio/security/pkg/pki/ca"
 	caerror "istio.io/istio/security/pkg/pki/error"
 	"istio.io/istio/security/pkg/pki/util"
@@ -26,11 +28,13 @@ type FakeCA struct {
 	SignErr       *caerror.Error
 	KeyCertBundle *util.KeyCertBundle
 	ReceivedIDs   []string
+	ReceivedTTL   time.Duration
 }
 
 // Sign returns the SignErr if SignErr is not nil, otherwise, it returns SignedCert.
 func (ca *FakeCA) Sign(csr []byte, certOpts ca.CertOpts) ([]byte, error) {
 	ca.ReceivedIDs = certOpts.SubjectIDs
+	ca.ReceivedTTL = certOpts.TTL
 	if ca.SignErr != nil {
 		return nil, ca.SignErr
 	}
@@ -0,0 +1,166 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package awskms registers the "aws-kms" CA signer backend, signing with an asymmetric AWS KMS key.
+package awskms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+
+	"istio.io/istio/security/pkg/pki/signer"
+)
+
+const (
+	// Name is the name of the backend, to be set in CA_SIGNER_BACKEND.
+	Name = "aws-kms"
+
+	// KeyID is the configuration of the ID, ARN or alias of the KMS key. It is required.
+	KeyID = "keyId"
+	// Region is the configuration of the region of the KMS key. It defaults to the region of the environment.
+	Region = "region"
+)
+
+func init() {
+	signer.RegisterBackend(Name, newBackend)
+}
+
+type backend struct {
+	client kmsiface.KMSAPI
+	keyID  string
+	public crypto.PublicKey
+}
+
+var _ signer.Backend = &backend{}
+
+func newBackend(config map[string]string) (signer.Backend, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(config[Region])})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %v", err)
+	}
+	return newBackendWithClient(kms.New(sess), config[KeyID])
+}
+
+func newBackendWithClient(client kmsiface.KMSAPI, keyID string) (*backend, error) {
+	if keyID == "" {
+		return nil, fmt.Errorf("%s is required by the %s CA signer backend", KeyID, Name)
+	}
+	resp, err := client.GetPublicKeyWithContext(context.Background(), &kms.GetPublicKeyInput{KeyId: aws.String(keyID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the public key of %s: %v", keyID, err)
+	}
+	if usage := aws.StringValue(resp.KeyUsage); usage != kms.KeyUsageTypeSignVerify {
+		return nil, fmt.Errorf("KMS key %s has usage %s, %s is required", keyID, usage, kms.KeyUsageTypeSignVerify)
+	}
+	public, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the public key of %s: %v", keyID, err)
+	}
+	return &backend{client: client, keyID: keyID, public: public}, nil
+}
+
+// Public implements signer.Backend.
+func (b *backend) Public() crypto.PublicKey {
+	return b.public
+}
+
+// SignBatch implements signer.Backend. KMS signs a single digest per call, so the digests of the batch are signed
+// concurrently.
+func (b *backend) SignBatch(ctx context.Context, digests [][]byte, opts crypto.SignerOpts) ([][]byte, error) {
+	algorithm, err := signingAlgorithm(b.public, opts)
+	if err != nil {
+		return nil, err
+	}
+	signatures := make([][]byte, len(digests))
+	errs := make([]error, len(digests))
+	wg := sync.WaitGroup{}
+	for i, digest := range digests {
+		wg.Add(1)
+		go func(i int, digest []byte) {
+			defer wg.Done()
+			resp, err := b.client.SignWithContext(ctx, &kms.SignInput{
+				KeyId:            aws.String(b.keyID),
+				Message:          digest,
+				MessageType:      aws.String(kms.MessageTypeDigest),
+				SigningAlgorithm: aws.String(algorithm),
+			})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			signatures[i] = resp.Signature
+		}(i, digest)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign with %s: %v", b.keyID, err)
+		}
+	}
+	return signatures, nil
+}
+
+// Close implements signer.Backend.
+func (b *backend) Close() error {
+	return nil
+}
+
+// signingAlgorithm returns the KMS signing algorithm of the key for the options.
+func signingAlgorithm(public crypto.PublicKey, opts crypto.SignerOpts) (string, error) {
+	hash := opts.HashFunc()
+	switch public.(type) {
+	case *ecdsa.PublicKey:
+		switch hash {
+		case crypto.SHA256:
+			return kms.SigningAlgorithmSpecEcdsaSha256, nil
+		case crypto.SHA384:
+			return kms.SigningAlgorithmSpecEcdsaSha384, nil
+		case crypto.SHA512:
+			return kms.SigningAlgorithmSpecEcdsaSha512, nil
+		}
+	case *rsa.PublicKey:
+		if _, pss := opts.(*rsa.PSSOptions); pss {
+			switch hash {
+			case crypto.SHA256:
+				return kms.SigningAlgorithmSpecRsassaPssSha256, nil
+			case crypto.SHA384:
+				return kms.SigningAlgorithmSpecRsassaPssSha384, nil
+			case crypto.SHA512:
+				return kms.SigningAlgorithmSpecRsassaPssSha512, nil
+			}
+			break
+		}
+		switch hash {
+		case crypto.SHA256:
+			return kms.SigningAlgorithmSpecRsassaPkcs1V15Sha256, nil
+		case crypto.SHA384:
+			return kms.SigningAlgorithmSpecRsassaPkcs1V15Sha384, nil
+		case crypto.SHA512:
+			return kms.SigningAlgorithmSpecRsassaPkcs1V15Sha512, nil
+		}
+	default:
+		return "", fmt.Errorf("unsupported KMS key type %T", public)
+	}
+	return "", fmt.Errorf("unsupported hash %v for a KMS key of type %T", hash, public)
+}
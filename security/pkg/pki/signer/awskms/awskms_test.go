@@ -0,0 +1,140 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awskms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+
+	"istio.io/istio/security/pkg/pki/signer"
+)
+
+// fakeKMS signs with an in memory ECDSA key.
+type fakeKMS struct {
+	kmsiface.KMSAPI
+	key   *ecdsa.PrivateKey
+	usage string
+}
+
+func (f *fakeKMS) GetPublicKeyWithContext(_ aws.Context, in *kms.GetPublicKeyInput,
+	_ ...request.Option) (*kms.GetPublicKeyOutput, error) {
+	der, err := x509.MarshalPKIXPublicKey(f.key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &kms.GetPublicKeyOutput{KeyId: in.KeyId, KeyUsage: aws.String(f.usage), PublicKey: der}, nil
+}
+
+func (f *fakeKMS) SignWithContext(_ aws.Context, in *kms.SignInput, _ ...request.Option) (*kms.SignOutput, error) {
+	if aws.StringValue(in.MessageType) != kms.MessageTypeDigest {
+		return nil, fmt.Errorf("unexpected message type %v", aws.StringValue(in.MessageType))
+	}
+	if alg := aws.StringValue(in.SigningAlgorithm); alg != kms.SigningAlgorithmSpecEcdsaSha256 {
+		return nil, fmt.Errorf("unexpected signing algorithm %v", alg)
+	}
+	sig, err := f.key.Sign(rand.Reader, in.Message, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	return &kms.SignOutput{KeyId: in.KeyId, Signature: sig, SigningAlgorithm: in.SigningAlgorithm}, nil
+}
+
+func TestBackend(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newBackendWithClient(&fakeKMS{key: key, usage: kms.KeyUsageTypeSignVerify}, ""); err == nil {
+		t.Fatal("expected an error without key ID")
+	}
+	_, err = newBackendWithClient(&fakeKMS{key: key, usage: kms.KeyUsageTypeEncryptDecrypt}, "alias/istio-ca")
+	if err == nil || !strings.Contains(err.Error(), kms.KeyUsageTypeSignVerify) {
+		t.Fatalf("expected an error for an encryption key, got %v", err)
+	}
+
+	b, err := newBackendWithClient(&fakeKMS{key: key, usage: kms.KeyUsageTypeSignVerify}, "alias/istio-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.PublicKey.Equal(b.Public()) {
+		t.Fatal("unexpected public key")
+	}
+	digests := make([][]byte, 0, 3)
+	for i := 0; i < 3; i++ {
+		d := sha256.Sum256([]byte(fmt.Sprint(i)))
+		digests = append(digests, d[:])
+	}
+	signatures, err := b.SignBatch(context.Background(), digests, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, d := range digests {
+		if !ecdsa.VerifyASN1(&key.PublicKey, d, signatures[i]) {
+			t.Fatalf("invalid signature of digest %d", i)
+		}
+	}
+	if _, err := b.SignBatch(context.Background(), digests, crypto.SHA1); err == nil {
+		t.Fatal("expected an error for an unsupported hash")
+	}
+}
+
+func TestSigningAlgorithm(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	cases := []struct {
+		public crypto.PublicKey
+		opts   crypto.SignerOpts
+		want   string
+	}{
+		{ecKey.Public(), crypto.SHA384, kms.SigningAlgorithmSpecEcdsaSha384},
+		{rsaKey.Public(), crypto.SHA256, kms.SigningAlgorithmSpecRsassaPkcs1V15Sha256},
+		{rsaKey.Public(), &rsa.PSSOptions{Hash: crypto.SHA512}, kms.SigningAlgorithmSpecRsassaPssSha512},
+		{rsaKey.Public(), crypto.MD5, ""},
+	}
+	for _, c := range cases {
+		got, err := signingAlgorithm(c.public, c.opts)
+		if c.want == "" {
+			if err == nil {
+				t.Errorf("expected an error for %T with %v, got %v", c.public, c.opts.HashFunc(), got)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("expected %v for %T with %v, got %v, %v", c.want, c.public, c.opts.HashFunc(), got, err)
+		}
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	_, err := signer.NewBackend(Name, map[string]string{Region: "us-east-1"})
+	if err == nil || !strings.Contains(err.Error(), KeyID) {
+		t.Fatalf("expected the backend to be registered and require %s, got %v", KeyID, err)
+	}
+}
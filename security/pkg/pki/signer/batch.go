@@ -0,0 +1,186 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"time"
+
+	"istio.io/pkg/log"
+)
+
+var signerLog = log.RegisterScope("casigner", "CA signer debugging", 0)
+
+// BatchOptions configures the batching of signing operations.
+type BatchOptions struct {
+	// MaxBatchSize is the maximum number of digests sent to the backend in a single batch.
+	MaxBatchSize int
+	// MaxWait is how long a signing operation waits for more operations to batch with.
+	MaxWait time.Duration
+	// Timeout is the timeout of a backend call.
+	Timeout time.Duration
+}
+
+type signRequest struct {
+	digest []byte
+	opts   crypto.SignerOpts
+	result chan signResult
+}
+
+type signResult struct {
+	signature []byte
+	err       error
+}
+
+// BatchSigner is a crypto.Signer that signs with a Backend, batching concurrent signing operations.
+type BatchSigner struct {
+	backend  Backend
+	opts     BatchOptions
+	requests chan *signRequest
+	stop     chan struct{}
+}
+
+var _ crypto.Signer = &BatchSigner{}
+
+// NewBatchSigner returns a signer sending the signing operations to the backend in batches.
+func NewBatchSigner(backend Backend, opts BatchOptions) *BatchSigner {
+	if opts.MaxBatchSize <= 0 {
+		opts.MaxBatchSize = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	s := &BatchSigner{
+		backend:  backend,
+		opts:     opts,
+		requests: make(chan *signRequest, opts.MaxBatchSize),
+		stop:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Public returns the public key of the backend.
+func (s *BatchSigner) Public() crypto.PublicKey {
+	return s.backend.Public()
+}
+
+// Sign signs the digest with the backend. The rand argument is ignored, the backend uses its own source
+// of randomness.
+func (s *BatchSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	req := &signRequest{digest: digest, opts: opts, result: make(chan signResult, 1)}
+	select {
+	case s.requests <- req:
+	case <-s.stop:
+		return nil, fmt.Errorf("CA signer is closed")
+	}
+	select {
+	case res := <-req.result:
+		return res.signature, res.err
+	case <-s.stop:
+		return nil, fmt.Errorf("CA signer is closed")
+	}
+}
+
+// Close stops the signer and closes the backend.
+func (s *BatchSigner) Close() error {
+	close(s.stop)
+	return s.backend.Close()
+}
+
+func (s *BatchSigner) run() {
+	for {
+		var first *signRequest
+		select {
+		case first = <-s.requests:
+		case <-s.stop:
+			return
+		}
+		batch := []*signRequest{first}
+		if s.opts.MaxWait > 0 {
+			timer := time.NewTimer(s.opts.MaxWait)
+		collect:
+			for len(batch) < s.opts.MaxBatchSize {
+				select {
+				case req := <-s.requests:
+					batch = append(batch, req)
+				case <-timer.C:
+					break collect
+				}
+			}
+			timer.Stop()
+		}
+		s.signBatches(batch)
+	}
+}
+
+// signBatches sends the requests to the backend, in one batch per signer opts, as a batch is signed with
+// a single set of opts.
+func (s *BatchSigner) signBatches(reqs []*signRequest) {
+	for len(reqs) > 0 {
+		opts := reqs[0].opts
+		var batch, rest []*signRequest
+		for _, req := range reqs {
+			if sameOpts(req.opts, opts) {
+				batch = append(batch, req)
+			} else {
+				rest = append(rest, req)
+			}
+		}
+		go s.signBatch(batch, opts)
+		reqs = rest
+	}
+}
+
+func sameOpts(a, b crypto.SignerOpts) bool {
+	ha, aHash := a.(crypto.Hash)
+	hb, bHash := b.(crypto.Hash)
+	if aHash && bHash {
+		return ha == hb
+	}
+	// Other options, such as rsa.PSSOptions, are compared by identity.
+	return a == b
+}
+
+func (s *BatchSigner) signBatch(batch []*signRequest, opts crypto.SignerOpts) {
+	digests := make([][]byte, 0, len(batch))
+	for _, req := range batch {
+		digests = append(digests, req.digest)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+
+	t0 := time.Now()
+	signatures, err := s.backend.SignBatch(ctx, digests, opts)
+	signLatency.Record(time.Since(t0).Seconds())
+	batchSize.Record(float64(len(batch)))
+	if err == nil && len(signatures) != len(batch) {
+		err = fmt.Errorf("CA signer backend returned %d signatures for %d digests", len(signatures), len(batch))
+	}
+	if err != nil {
+		signErrors.Increment()
+		signerLog.Errorf("failed to sign batch of %d digests: %v", len(batch), err)
+	}
+	for i, req := range batch {
+		if err != nil {
+			req.result <- signResult{err: err}
+			continue
+		}
+		req.result <- signResult{signature: signatures[i]}
+	}
+}
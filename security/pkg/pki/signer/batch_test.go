@@ -0,0 +1,146 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBackend signs with an in memory key, recording the size of the batches.
+type fakeBackend struct {
+	key *ecdsa.PrivateKey
+	err error
+
+	mu      sync.Mutex
+	batches []int
+}
+
+func (f *fakeBackend) Public() crypto.PublicKey {
+	return f.key.Public()
+}
+
+func (f *fakeBackend) SignBatch(_ context.Context, digests [][]byte, opts crypto.SignerOpts) ([][]byte, error) {
+	f.mu.Lock()
+	f.batches = append(f.batches, len(digests))
+	f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	signatures := make([][]byte, 0, len(digests))
+	for _, d := range digests {
+		sig, err := f.key.Sign(rand.Reader, d, opts)
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, sig)
+	}
+	return signatures, nil
+}
+
+func (f *fakeBackend) Close() error {
+	return nil
+}
+
+func newFakeBackend(t *testing.T) *fakeBackend {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeBackend{key: key}
+}
+
+func TestBatchSigner(t *testing.T) {
+	backend := newFakeBackend(t)
+	s := NewBatchSigner(backend, BatchOptions{MaxBatchSize: 4, MaxWait: 100 * time.Millisecond})
+	defer s.Close()
+
+	const requests = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			digest := sha256.Sum256([]byte{byte(i)})
+			sig, err := s.Sign(rand.Reader, digest[:], crypto.SHA256)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !ecdsa.VerifyASN1(s.Public().(*ecdsa.PublicKey), digest[:], sig) {
+				errs <- errors.New("invalid signature")
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	total := 0
+	for _, size := range backend.batches {
+		if size > 4 {
+			t.Fatalf("batch of %d digests exceeds the max batch size", size)
+		}
+		total += size
+	}
+	if total != requests || len(backend.batches) >= requests {
+		t.Fatalf("expected %d digests signed in batches, got batches %v", requests, backend.batches)
+	}
+}
+
+func TestBatchSignerError(t *testing.T) {
+	backend := newFakeBackend(t)
+	backend.err = errors.New("KMS unavailable")
+	s := NewBatchSigner(backend, BatchOptions{})
+	defer s.Close()
+
+	digest := sha256.Sum256([]byte("foo"))
+	if _, err := s.Sign(rand.Reader, digest[:], crypto.SHA256); err == nil {
+		t.Fatalf("expected backend error")
+	}
+}
+
+func TestNewBackend(t *testing.T) {
+	if _, err := NewBackend("test-unknown", nil); err == nil {
+		t.Fatalf("expected unknown backend to be rejected")
+	}
+	backend := newFakeBackend(t)
+	RegisterBackend("test-fake", func(config map[string]string) (Backend, error) {
+		if config["key"] != "ca" {
+			return nil, errors.New("unknown key")
+		}
+		return backend, nil
+	})
+	got, err := NewBackend("test-fake", map[string]string{"key": "ca"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != backend {
+		t.Fatalf("expected the registered backend")
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	signLatency = monitoring.NewDistribution(
+		"citadel_signer_batch_latency_seconds",
+		"The latency of signing a batch with the CA signer backend.",
+		[]float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	)
+
+	batchSize = monitoring.NewDistribution(
+		"citadel_signer_batch_size",
+		"The number of digests in a batch signed with the CA signer backend.",
+		[]float64{1, 2, 4, 8, 16, 32, 64},
+	)
+
+	signErrors = monitoring.NewSum(
+		"citadel_signer_batch_err_count",
+		"The number of batches the CA signer backend failed to sign.",
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		signLatency,
+		batchSize,
+		signErrors,
+	)
+}
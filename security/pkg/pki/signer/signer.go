@@ -0,0 +1,78 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signer provides the abstraction for CA signing keys held outside of Istiod, such as in a
+// cloud KMS or a PKCS#11 HSM, so the CA private key never has to be stored in the cluster.
+package signer
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Backend is a signing key held by a KMS or HSM.
+//
+// Signing operations are sent to the backend in batches, so backends with a high per call latency, such
+// as a cloud KMS, can sign the digests of a batch concurrently, and PKCS#11 backends can sign them in a
+// single session.
+type Backend interface {
+	// Public returns the public key of the signing key.
+	Public() crypto.PublicKey
+	// SignBatch signs each digest with the signing key, returning the signatures in the same order.
+	SignBatch(ctx context.Context, digests [][]byte, opts crypto.SignerOpts) ([][]byte, error)
+	// Close releases the resources held by the backend.
+	Close() error
+}
+
+// BackendFactory creates a Backend from its configuration, such as the key name in the KMS or the PKCS#11
+// module, slot and key label.
+type BackendFactory func(config map[string]string) (Backend, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]BackendFactory{}
+)
+
+// RegisterBackend registers a backend implementation under the given name. KMS and HSM implementations
+// register themselves from an init function, so they are only linked into the builds that need them.
+func RegisterBackend(name string, factory BackendFactory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[name] = factory
+}
+
+// NewBackend creates the backend registered under the given name.
+func NewBackend(name string, config map[string]string) (Backend, error) {
+	backendsMu.RLock()
+	factory, f := backends[name]
+	backendsMu.RUnlock()
+	if !f {
+		return nil, fmt.Errorf("unknown CA signer backend %q, registered backends: %v", name, registeredBackends())
+	}
+	return factory(config)
+}
+
+func registeredBackends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,228 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const (
+	// KeyTypeRSA requires workload certificates to use an RSA key.
+	KeyTypeRSA = "RSA"
+	// KeyTypeECDSA requires workload certificates to use an ECDSA key.
+	KeyTypeECDSA = "ECDSA"
+
+	// minRSAKeySize is the smallest RSA key size a policy may allow.
+	minRSAKeySize = 2048
+	// minECDSAKeySize is the smallest ECDSA curve size a policy may allow.
+	minECDSAKeySize = 256
+)
+
+// WorkloadCertPolicy overrides the mesh wide defaults for the certificates issued to a set of workloads.
+type WorkloadCertPolicy struct {
+	// TTL is the lifetime of issued certificates. If set, it replaces the lifetime requested by the workload.
+	TTL time.Duration
+	// KeyType is the key type workloads must use in their CSR, RSA or ECDSA. Any type is accepted if unset.
+	KeyType string
+	// MinKeySize is the minimum size, in bits, of the key in the CSR: the modulus size for RSA keys, the
+	// curve size for ECDSA keys.
+	MinKeySize int
+}
+
+// WorkloadCertPolicies holds the certificate policies of namespaces and WorkloadGroups. The policy of a
+// WorkloadGroup applies to the service account of its template, and takes precedence over the namespace
+// policy.
+type WorkloadCertPolicies struct {
+	// Namespaces maps a namespace to its policy.
+	Namespaces map[string]*WorkloadCertPolicy
+	// WorkloadGroups maps a WorkloadGroup, as namespace/name, to its policy.
+	WorkloadGroups map[string]*WorkloadCertPolicy
+
+	// workloadGroupNames indexes the names of the WorkloadGroups with a policy by namespace, sorted so the
+	// policy is stable if several WorkloadGroups share a service account.
+	workloadGroupNames map[string][]string
+}
+
+type workloadCertPolicyConfig struct {
+	TTL        string `json:"ttl,omitempty"`
+	KeyType    string `json:"keyType,omitempty"`
+	MinKeySize int    `json:"minKeySize,omitempty"`
+}
+
+type workloadCertPoliciesConfig struct {
+	Namespaces     map[string]workloadCertPolicyConfig `json:"namespaces,omitempty"`
+	WorkloadGroups map[string]workloadCertPolicyConfig `json:"workloadGroups,omitempty"`
+}
+
+// ParseWorkloadCertPolicies parses the certificate policies from YAML, such as:
+//
+//	namespaces:
+//	  payments:
+//	    ttl: 6h
+//	    keyType: ECDSA
+//	workloadGroups:
+//	  legacy/vm-group:
+//	    ttl: 48h
+//	    keyType: RSA
+//	    minKeySize: 3072
+//
+// Policies are validated against the max workload cert TTL of the CA.
+func ParseWorkloadCertPolicies(data []byte, maxTTL time.Duration) (*WorkloadCertPolicies, error) {
+	cfg := workloadCertPoliciesConfig{}
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse workload cert policies: %v", err)
+	}
+	policies := &WorkloadCertPolicies{
+		Namespaces:     make(map[string]*WorkloadCertPolicy, len(cfg.Namespaces)),
+		WorkloadGroups: make(map[string]*WorkloadCertPolicy, len(cfg.WorkloadGroups)),
+
+		workloadGroupNames: map[string][]string{},
+	}
+	for ns, c := range cfg.Namespaces {
+		p, err := c.toPolicy(maxTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy for namespace %s: %v", ns, err)
+		}
+		policies.Namespaces[ns] = p
+	}
+	for name, c := range cfg.WorkloadGroups {
+		ns, n, f := cutNamespacedName(name)
+		if !f || ns == "" || n == "" {
+			return nil, fmt.Errorf("invalid WorkloadGroup %q, expected namespace/name", name)
+		}
+		p, err := c.toPolicy(maxTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy for WorkloadGroup %s: %v", name, err)
+		}
+		policies.WorkloadGroups[name] = p
+		policies.workloadGroupNames[ns] = append(policies.workloadGroupNames[ns], n)
+	}
+	for _, names := range policies.workloadGroupNames {
+		sort.Strings(names)
+	}
+	return policies, nil
+}
+
+func (c workloadCertPolicyConfig) toPolicy(maxTTL time.Duration) (*WorkloadCertPolicy, error) {
+	p := &WorkloadCertPolicy{KeyType: c.KeyType, MinKeySize: c.MinKeySize}
+	if c.TTL != "" {
+		ttl, err := time.ParseDuration(c.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ttl: %v", err)
+		}
+		if ttl <= 0 {
+			return nil, fmt.Errorf("ttl must be positive, got %v", ttl)
+		}
+		if maxTTL > 0 && ttl > maxTTL {
+			return nil, fmt.Errorf("ttl %v is greater than the max workload cert TTL %v", ttl, maxTTL)
+		}
+		p.TTL = ttl
+	}
+	switch c.KeyType {
+	case "":
+		if c.MinKeySize != 0 {
+			return nil, fmt.Errorf("minKeySize requires a keyType")
+		}
+	case KeyTypeRSA:
+		if c.MinKeySize != 0 && c.MinKeySize < minRSAKeySize {
+			return nil, fmt.Errorf("minKeySize %d is less than the minimum RSA key size %d", c.MinKeySize, minRSAKeySize)
+		}
+	case KeyTypeECDSA:
+		if c.MinKeySize != 0 && c.MinKeySize < minECDSAKeySize {
+			return nil, fmt.Errorf("minKeySize %d is less than the minimum ECDSA key size %d", c.MinKeySize, minECDSAKeySize)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported keyType %q, expected %s or %s", c.KeyType, KeyTypeRSA, KeyTypeECDSA)
+	}
+	return p, nil
+}
+
+func cutNamespacedName(s string) (string, string, bool) {
+	i := strings.Index(s, "/")
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+1:], true
+}
+
+// policyFor returns the policy applying to the given SPIFFE identities, or nil if there is none. The policy of
+// a WorkloadGroup matching any of the identities is returned first, then the policy of the namespace of the
+// first identity with one.
+//
+// workloadGroupServiceAccount returns the service account of a WorkloadGroup, or "" if it does not exist.
+func (p *WorkloadCertPolicies) policyFor(identities []string,
+	workloadGroupServiceAccount func(namespace, name string) string) *WorkloadCertPolicy {
+	if p == nil {
+		return nil
+	}
+	var nsPolicy *WorkloadCertPolicy
+	for _, id := range identities {
+		identity, err := spiffe.ParseIdentity(id)
+		if err != nil {
+			continue
+		}
+		if workloadGroupServiceAccount != nil {
+			// Only the WorkloadGroups of the namespace of the identity can match its service account.
+			for _, n := range p.workloadGroupNames[identity.Namespace] {
+				if workloadGroupServiceAccount(identity.Namespace, n) == identity.ServiceAccount {
+					return p.WorkloadGroups[identity.Namespace+"/"+n]
+				}
+			}
+		}
+		if nsPolicy == nil {
+			nsPolicy = p.Namespaces[identity.Namespace]
+		}
+	}
+	return nsPolicy
+}
+
+// validateCSRKey checks the public key of the CSR against the key type and size of the policy.
+func (p *WorkloadCertPolicy) validateCSRKey(csrPEM []byte) error {
+	if p.KeyType == "" {
+		return nil
+	}
+	csr, err := util.ParsePemEncodedCSR(csrPEM)
+	if err != nil {
+		return err
+	}
+	var keyType string
+	var keySize int
+	switch key := csr.PublicKey.(type) {
+	case *rsa.PublicKey:
+		keyType, keySize = KeyTypeRSA, key.N.BitLen()
+	case *ecdsa.PublicKey:
+		keyType, keySize = KeyTypeECDSA, key.Curve.Params().BitSize
+	default:
+		return fmt.Errorf("unsupported public key type %T", csr.PublicKey)
+	}
+	if keyType != p.KeyType {
+		return fmt.Errorf("key type %s is not allowed, the workload cert policy requires %s", keyType, p.KeyType)
+	}
+	if keySize < p.MinKeySize {
+		return fmt.Errorf("%s key size %d is less than the minimum %d required by the workload cert policy",
+			keyType, keySize, p.MinKeySize)
+	}
+	return nil
+}
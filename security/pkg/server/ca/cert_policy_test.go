@@ -0,0 +1,240 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ca
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "istio.io/api/security/v1alpha1"
+	"istio.io/istio/pkg/security"
+	mockca "istio.io/istio/security/pkg/pki/ca/mock"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+func TestParseWorkloadCertPolicies(t *testing.T) {
+	cases := []struct {
+		name  string
+		data  string
+		valid bool
+	}{
+		{
+			name:  "empty",
+			data:  "",
+			valid: true,
+		},
+		{
+			name: "valid",
+			data: `
+namespaces:
+  payments:
+    ttl: 6h
+    keyType: ECDSA
+workloadGroups:
+  legacy/vm:
+    ttl: 48h
+    keyType: RSA
+    minKeySize: 3072
+`,
+			valid: true,
+		},
+		{
+			name:  "ttl above max",
+			data:  "namespaces: {foo: {ttl: 3000h}}",
+			valid: false,
+		},
+		{
+			name:  "negative ttl",
+			data:  "namespaces: {foo: {ttl: -1h}}",
+			valid: false,
+		},
+		{
+			name:  "unknown key type",
+			data:  "namespaces: {foo: {keyType: DSA}}",
+			valid: false,
+		},
+		{
+			name:  "weak RSA key",
+			data:  "namespaces: {foo: {keyType: RSA, minKeySize: 1024}}",
+			valid: false,
+		},
+		{
+			name:  "key size without type",
+			data:  "namespaces: {foo: {minKeySize: 4096}}",
+			valid: false,
+		},
+		{
+			name:  "invalid WorkloadGroup name",
+			data:  "workloadGroups: {vm: {ttl: 1h}}",
+			valid: false,
+		},
+		{
+			name:  "unknown field",
+			data:  "namespaces: {foo: {lifetime: 1h}}",
+			valid: false,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWorkloadCertPolicies([]byte(tt.data), 90*24*time.Hour)
+			if tt.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func genCSR(t *testing.T, ecdsa bool, rsaKeySize int) string {
+	t.Helper()
+	opts := util.CertOptions{Host: "spiffe://cluster.local/ns/foo/sa/bar", RSAKeySize: rsaKeySize}
+	if ecdsa {
+		opts.ECSigAlg = util.EcdsaSigAlg
+	}
+	csr, _, err := util.GenCSR(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(csr)
+}
+
+func TestCreateCertificateWithWorkloadCertPolicy(t *testing.T) {
+	policies, err := ParseWorkloadCertPolicies([]byte(`
+namespaces:
+  foo:
+    ttl: 6h
+    keyType: ECDSA
+workloadGroups:
+  foo/vm:
+    ttl: 48h
+    keyType: RSA
+    minKeySize: 3072
+`), 90*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaCSR := genCSR(t, false, 2048)
+	ecdsaCSR := genCSR(t, true, 0)
+
+	cases := []struct {
+		name     string
+		identity string
+		csr      string
+		ttl      time.Duration
+		code     codes.Code
+	}{
+		{
+			name:     "namespace policy",
+			identity: "spiffe://cluster.local/ns/foo/sa/bar",
+			csr:      ecdsaCSR,
+			ttl:      6 * time.Hour,
+			code:     codes.OK,
+		},
+		{
+			name:     "namespace policy key type",
+			identity: "spiffe://cluster.local/ns/foo/sa/bar",
+			csr:      rsaCSR,
+			code:     codes.InvalidArgument,
+		},
+		{
+			name:     "WorkloadGroup policy key size",
+			identity: "spiffe://cluster.local/ns/foo/sa/vm-sa",
+			csr:      rsaCSR,
+			code:     codes.InvalidArgument,
+		},
+		{
+			name:     "WorkloadGroup policy",
+			identity: "spiffe://cluster.local/ns/foo/sa/vm-sa",
+			csr:      genCSR(t, false, 3072),
+			ttl:      48 * time.Hour,
+			code:     codes.OK,
+		},
+		{
+			name:     "no policy",
+			identity: "spiffe://cluster.local/ns/other/sa/bar",
+			csr:      rsaCSR,
+			ttl:      24 * time.Hour,
+			code:     codes.OK,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeCA := &mockca.FakeCA{SignedCert: []byte("cert")}
+			server := &Server{
+				ca: fakeCA,
+				Authenticators: []security.Authenticator{
+					&mockAuthenticator{identities: []string{tt.identity}},
+				},
+				monitoring: newMonitoringMetrics(),
+				WorkloadGroupServiceAccount: func(namespace, name string) string {
+					if namespace == "foo" && name == "vm" {
+						return "vm-sa"
+					}
+					return ""
+				},
+			}
+			server.SetWorkloadCertPolicies(policies)
+			request := &pb.IstioCertificateRequest{Csr: tt.csr, ValidityDuration: int64((24 * time.Hour).Seconds())}
+			_, err := server.CreateCertificate(context.Background(), request)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("expected code %v, got %v: %v", tt.code, code, err)
+			}
+			if tt.code == codes.OK && fakeCA.ReceivedTTL != tt.ttl {
+				t.Fatalf("expected TTL %v, got %v", tt.ttl, fakeCA.ReceivedTTL)
+			}
+		})
+	}
+}
+
+func TestWorkloadCertPolicyLookup(t *testing.T) {
+	policies, err := ParseWorkloadCertPolicies([]byte(`
+workloadGroups:
+  foo/vm-b:
+    ttl: 2h
+  foo/vm-a:
+    ttl: 1h
+  bar/vm:
+    ttl: 3h
+`), 90*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lookups []string
+	serviceAccount := func(namespace, name string) string {
+		lookups = append(lookups, namespace+"/"+name)
+		return "vm-sa"
+	}
+	policy := policies.policyFor([]string{"spiffe://cluster.local/ns/foo/sa/vm-sa"}, serviceAccount)
+	if policy == nil || policy.TTL != time.Hour {
+		t.Fatalf("expected the policy of foo/vm-a, got %+v", policy)
+	}
+	if !reflect.DeepEqual(lookups, []string{"foo/vm-a"}) {
+		t.Fatalf("expected only the WorkloadGroups of the namespace to be looked up, got %v", lookups)
+	}
+	lookups = nil
+	if policy := policies.policyFor([]string{"spiffe://cluster.local/ns/other/sa/vm-sa"}, serviceAccount); policy != nil {
+		t.Fatalf("expected no policy, got %+v", policy)
+	}
+	if len(lookups) != 0 {
+		t.Fatalf("expected no WorkloadGroup lookup, got %v", lookups)
+	}
+}
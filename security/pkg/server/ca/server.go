@@ -16,6 +16,7 @@ package ca
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
@@ -51,6 +52,16 @@ type Server struct {
 	Authenticators []security.Authenticator
 	ca             CertificateAuthority
 	serverCertTTL  time.Duration
+
+	// WorkloadGroupServiceAccount returns the service account of a WorkloadGroup, used to apply
+	// WorkloadGroup cert policies. If nil, only namespace policies apply.
+	WorkloadGroupServiceAccount func(namespace, name string) string
+
+	// CertificateIssued, if set, is called with the identities of each signed workload certificate.
+	CertificateIssued func(identities []string)
+
+	certPoliciesMu sync.RWMutex
+	certPolicies   *WorkloadCertPolicies
 }
 
 func getConnectionAddress(ctx context.Context) string {
@@ -87,6 +98,17 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 		ForCA:      false,
 		CertSigner: certSigner,
 	}
+	if policy := s.getWorkloadCertPolicies().policyFor(caller.Identities, s.WorkloadGroupServiceAccount); policy != nil {
+		if err := policy.validateCSRKey([]byte(request.Csr)); err != nil {
+			policyErr := caerror.NewError(caerror.CSRError, err)
+			serverCaLog.Warnf("CSR from %v rejected by workload cert policy (%v)", caller.Identities, err)
+			s.monitoring.GetCertSignError(policyErr.ErrorType()).Increment()
+			return nil, status.Errorf(policyErr.HTTPErrorCode(), "CSR rejected by workload cert policy (%v)", err)
+		}
+		if policy.TTL > 0 {
+			certOpts.TTL = policy.TTL
+		}
+	}
 	var signErr error
 	var cert []byte
 	var respCertChain []string
@@ -114,9 +136,25 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 	}
 	s.monitoring.Success.Increment()
 	serverCaLog.Debug("CSR successfully signed.")
+	if s.CertificateIssued != nil {
+		s.CertificateIssued(caller.Identities)
+	}
 	return response, nil
 }
 
+// SetWorkloadCertPolicies replaces the workload cert policies. A nil value removes all policies.
+func (s *Server) SetWorkloadCertPolicies(policies *WorkloadCertPolicies) {
+	s.certPoliciesMu.Lock()
+	defer s.certPoliciesMu.Unlock()
+	s.certPolicies = policies
+}
+
+func (s *Server) getWorkloadCertPolicies() *WorkloadCertPolicies {
+	s.certPoliciesMu.RLock()
+	defer s.certPoliciesMu.RUnlock()
+	return s.certPolicies
+}
+
 func recordCertsExpiry(keyCertBundle *util.KeyCertBundle) {
 	rootCertExpiry, err := keyCertBundle.ExtractRootCertExpiryTimestamp()
 	if err != nil {
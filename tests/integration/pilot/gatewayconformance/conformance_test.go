@@ -0,0 +1,405 @@
+//go:build integ
+// +build integ
+
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gatewayconformance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "sigs.k8s.io/gateway-api/apis/v1alpha2"
+
+	"istio.io/istio/pilot/pkg/model/kstatus"
+	"istio.io/istio/pkg/config/protocol"
+	echoClient "istio.io/istio/pkg/test/echo"
+	"istio.io/istio/pkg/test/echo/check"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/gatewayconformance"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestGatewayConformance(t *testing.T) {
+	framework.
+		NewTest(t).
+		Run(func(t framework.TestContext) {
+			for _, c := range t.Clusters() {
+				if !c.MinKubeVersion(16) {
+					t.Skip("Not supported; requires CRDv1 support.")
+				}
+			}
+			crd, err := os.ReadFile("../testdata/gateway-api-crd.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := t.ConfigIstio().ApplyYAMLNoCleanup("", string(crd)); err != nil {
+				t.Fatal(err)
+			}
+			routes := namespace.NewOrFail(t, t, namespace.Config{Prefix: "gateway-conformance-routes", Inject: true})
+			retry.UntilSuccessOrFail(t, func() error {
+				return t.ConfigIstio().ApplyYAML("", fmt.Sprintf(`
+apiVersion: gateway.networking.k8s.io/v1alpha2
+kind: GatewayClass
+metadata:
+  name: istio
+spec:
+  controllerName: istio.io/gateway-controller
+---
+apiVersion: gateway.networking.k8s.io/v1alpha2
+kind: Gateway
+metadata:
+  name: conformance
+  namespace: %s
+spec:
+  addresses:
+  - value: istio-ingressgateway
+    type: Hostname
+  gatewayClassName: istio
+  listeners:
+  - name: http
+    hostname: "*.conformance.example"
+    port: 80
+    protocol: HTTP
+    allowedRoutes:
+      namespaces:
+        from: Selector
+        selector:
+          matchLabels:
+            kubernetes.io/metadata.name: %s
+  - name: tcp
+    port: 31400
+    protocol: TCP
+    allowedRoutes:
+      namespaces:
+        from: All
+`, i.Settings().SystemNamespace, apps.Namespace.Name()))
+			}, retry.Delay(time.Second*10), retry.Timeout(time.Second*90))
+
+			report := gatewayconformance.Run(t, conformanceCases(routes))
+			if failed := report.FailedFeatures(); len(failed) > 0 {
+				t.Logf("failed conformance features: %v", failed)
+			}
+		})
+}
+
+// conformanceCases returns the conformance cases. Routes are attached to the "conformance"
+// Gateway in the system namespace, which only admits routes from the echo namespace; routes is
+// another namespace used to verify that attachment is refused.
+func conformanceCases(routes namespace.Instance) []gatewayconformance.TestCase {
+	return []gatewayconformance.TestCase{
+		{
+			Name:     "gatewayclass-accepted",
+			Features: []gatewayconformance.Feature{gatewayconformance.GatewayClassStatus},
+			Run: func(t framework.TestContext) {
+				retry.UntilSuccessOrFail(t, func() error {
+					gwc, err := t.Clusters().Kube().Default().GatewayAPI().GatewayV1alpha2().GatewayClasses().Get(context.Background(), "istio", metav1.GetOptions{})
+					if err != nil {
+						return err
+					}
+					if s := kstatus.GetCondition(gwc.Status.Conditions, string(k8s.GatewayClassConditionStatusAccepted)).Status; s != metav1.ConditionTrue {
+						return fmt.Errorf("expected status %q, got %q", metav1.ConditionTrue, s)
+					}
+					return nil
+				})
+			},
+		},
+		{
+			Name:     "httproute-path-match",
+			Features: []gatewayconformance.Feature{gatewayconformance.HTTPRoutePathMatching},
+			Run: func(t framework.TestContext) {
+				applyRoute(t, apps.Namespace.Name(), `
+apiVersion: gateway.networking.k8s.io/v1alpha2
+kind: HTTPRoute
+metadata:
+  name: path
+spec:
+  hostnames: ["path.conformance.example"]
+  parentRefs:
+  - name: conformance
+    namespace: {{.SystemNamespace}}
+  rules:
+  - matches:
+    - path:
+        type: Exact
+        value: /exact
+    backendRefs:
+    - name: c
+      port: 80
+  - matches:
+    - path:
+        type: PathPrefix
+        value: /prefix
+    backendRefs:
+    - name: b
+      port: 80
+`)
+				callIngress(t, "path.conformance.example", "/exact", nil, check.And(check.OK(), reachedService("c")))
+				callIngress(t, "path.conformance.example", "/prefix/sub", nil, check.And(check.OK(), reachedService("b")))
+				callIngress(t, "path.conformance.example", "/prefixed", nil, check.Status(http.StatusNotFound))
+			},
+		},
+		{
+			Name:     "httproute-header-match",
+			Features: []gatewayconformance.Feature{gatewayconformance.HTTPRouteHeaderMatching},
+			Run: func(t framework.TestContext) {
+				applyRoute(t, apps.Namespace.Name(), `
+apiVersion: gateway.networking.k8s.io/v1alpha2
+kind: HTTPRoute
+metadata:
+  name: header
+spec:
+  hostnames: ["header.conformance.example"]
+  parentRefs:
+  - name: conformance
+    namespace: {{.SystemNamespace}}
+  rules:
+  - matches:
+    - headers:
+      - name: version
+        value: two
+    backendRefs:
+    - name: c
+      port: 80
+  - backendRefs:
+    - name: b
+      port: 80
+`)
+				callIngress(t, "header.conformance.example", "/", http.Header{"Version": {"two"}}, check.And(check.OK(), reachedService("c")))
+				callIngress(t, "header.conformance.example", "/", nil, check.And(check.OK(), reachedService("b")))
+			},
+		},
+		{
+			Name:     "httproute-request-header-modifier",
+			Features: []gatewayconformance.Feature{gatewayconformance.HTTPRouteRequestHeaders},
+			Run: func(t framework.TestContext) {
+				applyRoute(t, apps.Namespace.Name(), `
+apiVersion: gateway.networking.k8s.io/v1alpha2
+kind: HTTPRoute
+metadata:
+  name: request-headers
+spec:
+  hostnames: ["headers.conformance.example"]
+  parentRefs:
+  - name: conformance
+    namespace: {{.SystemNamespace}}
+  rules:
+  - filters:
+    - type: RequestHeaderModifier
+      requestHeaderModifier:
+        add:
+        - name: x-added
+          value: added
+        set:
+        - name: x-set
+          value: set
+    backendRefs:
+    - name: b
+      port: 80
+`)
+				callIngress(t, "headers.conformance.example", "/", http.Header{"X-Set": {"original"}}, check.And(
+					check.OK(),
+					check.RequestHeader("X-Added", "added"),
+					check.RequestHeader("X-Set", "set")))
+			},
+		},
+		{
+			Name:     "httproute-weighted-backends",
+			Features: []gatewayconformance.Feature{gatewayconformance.HTTPRouteWeightedBackends},
+			Run: func(t framework.TestContext) {
+				applyRoute(t, apps.Namespace.Name(), `
+apiVersion: gateway.networking.k8s.io/v1alpha2
+kind: HTTPRoute
+metadata:
+  name: weighted
+spec:
+  hostnames: ["weighted.conformance.example"]
+  parentRefs:
+  - name: conformance
+    namespace: {{.SystemNamespace}}
+  rules:
+  - backendRefs:
+    - name: b
+      port: 80
+      weight: 0
+    - name: c
+      port: 80
+      weight: 1
+`)
+				callIngress(t, "weighted.conformance.example", "/", nil, check.And(check.OK(), reachedService("c")))
+			},
+		},
+		{
+			Name:     "gateway-cross-namespace-refused",
+			Features: []gatewayconformance.Feature{gatewayconformance.GatewayCrossNamespaceRoute},
+			Run: func(t framework.TestContext) {
+				applyRoute(t, routes.Name(), `
+apiVersion: gateway.networking.k8s.io/v1alpha2
+kind: HTTPRoute
+metadata:
+  name: not-allowed
+spec:
+  hostnames: ["refused.conformance.example"]
+  parentRefs:
+  - name: conformance
+    namespace: {{.SystemNamespace}}
+  rules:
+  - backendRefs:
+    - name: b
+      port: 80
+`)
+				// Give the controller time to (incorrectly) program the route before checking it is absent.
+				time.Sleep(time.Second * 10)
+				callIngress(t, "refused.conformance.example", "/", nil, check.Status(http.StatusNotFound))
+			},
+		},
+		{
+			Name:     "httproute-request-redirect",
+			Features: []gatewayconformance.Feature{gatewayconformance.HTTPRouteRedirect},
+			Run: func(t framework.TestContext) {
+				applyRoute(t, apps.Namespace.Name(), `
+apiVersion: gateway.networking.k8s.io/v1alpha2
+kind: HTTPRoute
+metadata:
+  name: redirect
+spec:
+  hostnames: ["redirect.conformance.example"]
+  parentRefs:
+  - name: conformance
+    namespace: {{.SystemNamespace}}
+  rules:
+  - filters:
+    - type: RequestRedirect
+      requestRedirect:
+        hostname: redirected.conformance.example
+        statusCode: 301
+`)
+				callIngress(t, "redirect.conformance.example", "/", nil, check.And(
+					check.Status(http.StatusMovedPermanently),
+					check.Each(func(r echoClient.Response) error {
+						if loc := r.ResponseHeaders.Get("Location"); !strings.Contains(loc, "redirected.conformance.example") {
+							return fmt.Errorf("unexpected redirect location %q", loc)
+						}
+						return nil
+					})))
+			},
+		},
+		{
+			Name:     "tcproute",
+			Features: []gatewayconformance.Feature{gatewayconformance.TCPRoute},
+			Run: func(t framework.TestContext) {
+				applyRoute(t, apps.Namespace.Name(), `
+apiVersion: gateway.networking.k8s.io/v1alpha2
+kind: TCPRoute
+metadata:
+  name: tcp
+spec:
+  parentRefs:
+  - name: conformance
+    namespace: {{.SystemNamespace}}
+  rules:
+  - backendRefs:
+    - name: b
+      port: 80
+`)
+				apps.Ingress.CallWithRetryOrFail(t, echo.CallOptions{
+					Port: &echo.Port{
+						Protocol:    protocol.HTTP,
+						ServicePort: 31400,
+					},
+					Path:  "/",
+					Check: check.And(check.OK(), reachedService("b")),
+				})
+			},
+		},
+		{
+			Name:     "mesh-httproute",
+			Features: []gatewayconformance.Feature{gatewayconformance.MeshHTTPRoute},
+			Run: func(t framework.TestContext) {
+				applyRoute(t, apps.Namespace.Name(), `
+apiVersion: gateway.networking.k8s.io/v1alpha2
+kind: HTTPRoute
+metadata:
+  name: mesh
+spec:
+  parentRefs:
+  - kind: Mesh
+    name: istio
+  hostnames: ["b"]
+  rules:
+  - matches:
+    - path:
+        type: PathPrefix
+        value: /conformance
+    filters:
+    - type: RequestHeaderModifier
+      requestHeaderModifier:
+        add:
+        - name: x-mesh-route
+          value: applied
+    backendRefs:
+    - name: b
+      port: 80
+`)
+				apps.PodA[0].CallWithRetryOrFail(t, echo.CallOptions{
+					Target:   apps.PodB[0],
+					PortName: "http",
+					Path:     "/conformance",
+					Check: check.And(
+						check.OK(),
+						check.RequestHeader("X-Mesh-Route", "applied")),
+				})
+			},
+		},
+	}
+}
+
+func applyRoute(t framework.TestContext, ns, tmpl string) {
+	cfg := strings.ReplaceAll(tmpl, "{{.SystemNamespace}}", i.Settings().SystemNamespace)
+	t.ConfigIstio().ApplyYAMLOrFail(t, ns, cfg)
+}
+
+func callIngress(t framework.TestContext, host, path string, headers http.Header, c check.Checker) {
+	h := http.Header{"Host": {host}}
+	for k, v := range headers {
+		h[k] = v
+	}
+	apps.Ingress.CallWithRetryOrFail(t, echo.CallOptions{
+		Port: &echo.Port{
+			Protocol: protocol.HTTP,
+		},
+		Path:    path,
+		Headers: h,
+		Check:   c,
+	}, retry.Timeout(time.Minute))
+}
+
+// reachedService verifies every response was served by a workload of the named echo service.
+func reachedService(svc string) check.Checker {
+	return check.Each(func(r echoClient.Response) error {
+		if !strings.HasPrefix(r.Hostname, svc+"-") {
+			return fmt.Errorf("expected response from %q, got hostname %q", svc, r.Hostname)
+		}
+		return nil
+	})
+}
@@ -649,7 +649,7 @@ spec:
 										"Host": {host},
 									},
 									Message: "HelloWorld",
-									// Do not set Target to dest, otherwise fillInCallOptions() will
+									// Do not set To to dest, otherwise CallOptions.FillDefaults() will
 									// complain with port does not match.
 									Address: getWorkload(dest[0], t).Address(),
 									Check: func(responses echoClient.Responses, err error) error {
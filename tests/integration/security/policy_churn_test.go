@@ -0,0 +1,102 @@
+//go:build integ
+// +build integ
+
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package security
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/common/scheme"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/util/churn"
+	"istio.io/istio/pkg/test/framework/components/echo/util/traffic"
+)
+
+// TestAuthorization_PolicyChurn checks that no request is denied or fails while the allowing AuthorizationPolicies
+// and DestinationRules of the destination are continuously updated.
+func TestAuthorization_PolicyChurn(t *testing.T) {
+	framework.NewTest(t).
+		Features("security.authorization.churn").
+		Run(func(t framework.TestContext) {
+			ns := apps.Namespace1.Name()
+			a := apps.A.Match(echo.Namespace(ns))
+			b := apps.B.Match(echo.Namespace(ns))
+			dst := b[0].Config().Service
+
+			// Every step allows the traffic from a to b, with a different policy and connection pool.
+			step := func(policy, rule string, maxRequests int) string {
+				return fmt.Sprintf(`
+apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: churn-allow
+spec:
+  selector:
+    matchLabels:
+      app: %[1]s
+  action: ALLOW
+  rules:
+  - %[2]s
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: churn
+spec:
+  host: %[1]s
+  trafficPolicy:
+    tls:
+      mode: ISTIO_MUTUAL
+    connectionPool:
+      http:
+        http2MaxRequests: %[4]d
+    %[3]s
+`, dst, policy, rule, maxRequests)
+			}
+			steps := []string{
+				step(`to:
+    - operation:
+        methods: ["GET"]`, "", 1000),
+				step(`from:
+    - source:
+        namespaces: ["`+ns+`"]`, `loadBalancer:
+      simple: ROUND_ROBIN`, 2000),
+				step(`from:
+    - source:
+        principals: ["*"]`, `loadBalancer:
+      simple: LEAST_CONN`, 3000),
+			}
+
+			churn.Run(t, churn.Config{
+				Namespace: ns,
+				Steps:     steps,
+				Interval:  2 * time.Second,
+			}, traffic.Config{
+				Source: a[0],
+				Options: echo.CallOptions{
+					Target:   b[0],
+					PortName: "http",
+					Scheme:   scheme.HTTP,
+					Count:    5,
+				},
+				Interval: 100 * time.Millisecond,
+			}, 30*time.Second)
+		})
+}
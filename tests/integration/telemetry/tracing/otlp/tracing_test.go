@@ -0,0 +1,99 @@
+//go:build integ
+// +build integ
+
+// Copyright Istio Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"fmt"
+	"testing"
+
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/components/opentelemetry/otlp"
+	"istio.io/istio/pkg/test/framework/label"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/tests/integration/telemetry/tracing"
+)
+
+var collector otlp.Instance
+
+// TestProxyTracing exercises the trace generation features of Istio, based on the Envoy Trace driver for
+// OpenCensusAgent. The spans are sent to an OpenTelemetry collector which keeps them in memory, and the test
+// verifies that the client and server spans of each call are reported in its trace.
+func TestProxyTracing(t *testing.T) {
+	framework.NewTest(t).
+		Features("observability.telemetry.tracing.server").
+		Run(func(ctx framework.TestContext) {
+			ns := tracing.GetAppNamespace().Name()
+			// TODO fix tracing tests in multi-network https://github.com/istio/istio/issues/28890
+			for _, cluster := range ctx.Clusters().ByNetwork()[ctx.Clusters().Default().NetworkName()] {
+				cluster := cluster
+				ctx.NewSubTest(cluster.StableName()).Run(func(ctx framework.TestContext) {
+					for _, src := range tracing.GetClientInstances() {
+						if src.Config().Cluster != cluster {
+							continue
+						}
+						src.CallWithRetryOrFail(ctx, echo.CallOptions{
+							To:   tracing.GetServerInstances(),
+							Port: &echo.Port{Name: "http"},
+							Check: otlp.TraceSpans(collector,
+								otlp.SpanMatch{
+									Name:        fmt.Sprintf("server.%s.svc.cluster.local:80/*", ns),
+									ServiceName: fmt.Sprintf("client-%s.%s", cluster.Name(), ns),
+								},
+								otlp.SpanMatch{
+									Name:        fmt.Sprintf("server.%s.svc.cluster.local:80/*", ns),
+									ServiceName: fmt.Sprintf("server.%s", ns),
+								}),
+						})
+					}
+				})
+			}
+		})
+}
+
+func TestMain(m *testing.M) {
+	framework.NewSuite(m).
+		Label(label.CustomSetup).
+		Setup(istio.Setup(tracing.GetIstioInstance(), setupConfig)).
+		Setup(tracing.TestSetup).
+		Setup(testSetup).
+		Run()
+}
+
+func setupConfig(ctx resource.Context, cfg *istio.Config) {
+	if cfg == nil {
+		return
+	}
+	cfg.ControlPlaneValues = fmt.Sprintf(`
+meshConfig:
+  enableTracing: true
+  defaultConfig:
+    tracing:
+      openCensusAgent:
+        address: "dns:opentelemetry-collector-otlp.%s.svc:%d"
+        context: [B3]
+`, cfg.TelemetryNamespace, otlp.OpenCensusPort)
+	cfg.Values["pilot.traceSampling"] = "100.0"
+	cfg.Values["global.proxy.tracer"] = "openCensusAgent"
+}
+
+func testSetup(ctx resource.Context) (err error) {
+	collector, err = otlp.New(ctx, otlp.Config{Cluster: ctx.Clusters().Default()})
+	return
+}
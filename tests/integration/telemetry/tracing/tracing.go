@@ -62,6 +62,14 @@ func GetZipkinInstance() zipkin.Instance {
 	return zipkinInst
 }
 
+func GetClientInstances() echo.Instances {
+	return client
+}
+
+func GetServerInstances() echo.Instances {
+	return server
+}
+
 func TestSetup(ctx resource.Context) (err error) {
 	appNsInst, err = namespace.New(ctx, namespace.Config{
 		Prefix: "echo",
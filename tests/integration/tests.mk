@@ -114,6 +114,16 @@ test.integration.kube.reachability: | $(JUNIT_REPORT) check-go-tag
 	--test.run=TestReachability \
 	2>&1 | tee >($(JUNIT_REPORT) > $(JUNIT_OUT))
 
+# Runs the Gateway API conformance cases against the in-repo gateway controller. The profiles to run
+# can be selected with GATEWAY_CONFORMANCE_PROFILES, e.g. "core,extended" or "all".
+GATEWAY_CONFORMANCE_PROFILES ?= core
+.PHONY: test.integration.kube.gatewayconformance
+test.integration.kube.gatewayconformance: | $(JUNIT_REPORT) check-go-tag
+	$(GO) test -p 1 -vet=off ${T} -tags=integ ./tests/integration/pilot/gatewayconformance/... -timeout 30m \
+	${_INTEGRATION_TEST_FLAGS} ${_INTEGRATION_TEST_SELECT_FLAGS} \
+	--istio.test.gatewayconformance.profiles=$(GATEWAY_CONFORMANCE_PROFILES) \
+	2>&1 | tee >($(JUNIT_REPORT) > $(JUNIT_OUT))
+
 # Defines a target to run a standard set of tests in various different environments (IPv6, distroless, ARM, etc)
 # In presubmit, this target runs a minimal set. In postsubmit, all tests are run
 .PHONY: test.integration.kube.environment
@@ -71,6 +71,10 @@ func removeOldChains(cfg *config.Config, ext dep.Dependencies, cmd string) {
 	// Flush and delete the istio chains from MANGLE table.
 	chains = []string{constants.ISTIOINBOUND, constants.ISTIODIVERT, constants.ISTIOTPROXY}
 	flushAndDeleteChains(ext, cmd, constants.MANGLE, chains)
+	// Flush and delete the chains of the runtime exclusions, which are only referred to by the chains above.
+	for _, table := range []string{constants.NAT, constants.MANGLE} {
+		flushAndDeleteChains(ext, cmd, table, []string{constants.ISTIODYNAMICINBOUND, constants.ISTIODYNAMICOUTPUT})
+	}
 
 	// Must be last, the others refer to it
 	chains = []string{constants.ISTIOREDIRECT, constants.ISTIOINREDIRECT}
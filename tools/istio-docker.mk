@@ -157,6 +157,7 @@ build.docker.pilot: pilot/docker/Dockerfile.pilot
 build.docker.app: $(ECHO_DOCKER)/Dockerfile.app
 build.docker.app: $(ISTIO_OUT_LINUX)/client
 build.docker.app: $(ISTIO_OUT_LINUX)/server
+build.docker.app: $(ISTIO_OUT_LINUX)/otlp-sink
 build.docker.app: $(ISTIO_DOCKER)/certs
 	$(DOCKER_RULE)
 
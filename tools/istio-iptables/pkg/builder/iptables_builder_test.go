@@ -312,3 +312,30 @@ func TestBuildV4V6MultipleRulesWithNewChain(t *testing.T) {
 		t.Errorf("Actual and expected output mismatch; but instead got Actual: %#v ; Expected: %#v", actualV6, expectedV6)
 	}
 }
+
+func TestPlan(t *testing.T) {
+	iptables := NewIptablesBuilder(&config.Config{EnableInboundIPv6: true})
+	iptables.AppendRule(iptableslog.UndefinedCommand, "chain", "table", "-f", "foo", "-b", "bar")
+	iptables.InsertRuleV4(iptableslog.UndefinedCommand, "chain", "table", 2, "-f", "foo")
+	iptables.AppendRuleV6(iptableslog.UndefinedCommand, constants.OUTPUT, "table", "-j", "chain")
+	actual := iptables.Plan()
+	expected := Plan{
+		IPv4: FamilyPlan{
+			Chains: []PlannedChain{{Table: "table", Chain: "chain"}},
+			Rules: []PlannedRule{
+				{Table: "table", Chain: "chain", Params: []string{"-f", "foo", "-b", "bar"}},
+				{Table: "table", Chain: "chain", Position: 2, Params: []string{"-f", "foo"}},
+			},
+		},
+		IPv6: FamilyPlan{
+			Chains: []PlannedChain{{Table: "table", Chain: "chain"}},
+			Rules: []PlannedRule{
+				{Table: "table", Chain: "chain", Params: []string{"-f", "foo", "-b", "bar"}},
+				{Table: "table", Chain: constants.OUTPUT, Params: []string{"-j", "chain"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Actual and expected output mismatch; but instead got Actual: %#v ; Expected: %#v", actual, expected)
+	}
+}
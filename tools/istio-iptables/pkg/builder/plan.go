@@ -0,0 +1,88 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"strconv"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// Plan is a machine-readable description of the iptables rules built by an IptablesBuilder, so that the rules
+// installed by the init container and CNI can be audited and diffed.
+type Plan struct {
+	IPv4 FamilyPlan `json:"ipv4"`
+	IPv6 FamilyPlan `json:"ipv6"`
+}
+
+// FamilyPlan is the plan of the rules of an IP family.
+type FamilyPlan struct {
+	// Chains are the chains created, built-in chains are omitted.
+	Chains []PlannedChain `json:"chains,omitempty"`
+	// Rules are the rules installed, in order.
+	Rules []PlannedRule `json:"rules,omitempty"`
+}
+
+// PlannedChain is a chain created by a Plan.
+type PlannedChain struct {
+	Table string `json:"table"`
+	Chain string `json:"chain"`
+}
+
+// PlannedRule is a rule installed by a Plan.
+type PlannedRule struct {
+	Table string `json:"table"`
+	Chain string `json:"chain"`
+	// Position is the position the rule is inserted at, or 0 if it is appended.
+	Position int `json:"position,omitempty"`
+	// Params are the matches and the target of the rule.
+	Params []string `json:"params"`
+}
+
+// Plan returns the plan of the rules built so far.
+func (rb *IptablesBuilder) Plan() Plan {
+	return Plan{
+		IPv4: buildPlan(rb.rules.rulesv4),
+		IPv6: buildPlan(rb.rules.rulesv6),
+	}
+}
+
+func buildPlan(rules []*Rule) FamilyPlan {
+	plan := FamilyPlan{}
+	chainTableLookupMap := make(map[PlannedChain]struct{})
+	for _, r := range rules {
+		chain := PlannedChain{Table: r.table, Chain: r.chain}
+		if _, present := chainTableLookupMap[chain]; present {
+			continue
+		}
+		// Ignore chain creation for built-in chains for iptables
+		if _, present := constants.BuiltInChainsMap[r.chain]; !present {
+			plan.Chains = append(plan.Chains, chain)
+			chainTableLookupMap[chain] = struct{}{}
+		}
+	}
+	for _, r := range rules {
+		rule := PlannedRule{Table: r.table, Chain: r.chain}
+		// params are either -A <chain> <params...> or -I <chain> <position> <params...>
+		if r.params[0] == "-I" {
+			rule.Position, _ = strconv.Atoi(r.params[2])
+			rule.Params = append([]string{}, r.params[3:]...)
+		} else {
+			rule.Params = append([]string{}, r.params[2:]...)
+		}
+		plan.Rules = append(plan.Rules, rule)
+	}
+	return plan
+}
@@ -54,6 +54,8 @@ type IptablesConfigurator struct {
 	// TODO(abhide): Fix dep.Dependencies with better interface
 	ext dep.Dependencies
 	cfg *config.Config
+	// built is whether the rules have been built
+	built bool
 }
 
 func NewIptablesConfigurator(cfg *config.Config, ext dep.Dependencies) *IptablesConfigurator {
@@ -317,6 +319,48 @@ func (cfg *IptablesConfigurator) Run() {
 		}
 	}()
 
+	cfg.build()
+	cfg.executeCommands()
+}
+
+// Plan builds the iptables rules without installing them, and returns their plan.
+func (cfg *IptablesConfigurator) Plan() builder.Plan {
+	cfg.build()
+	return cfg.iptables.Plan()
+}
+
+// Verify builds the iptables rules without installing them, and checks that all the chains and rules are
+// currently installed. The returned error lists the ones which are missing.
+func (cfg *IptablesConfigurator) Verify() error {
+	plan := cfg.Plan()
+	var missing []string
+	check := func(cmd string, family builder.FamilyPlan) {
+		for _, c := range family.Chains {
+			if err := cfg.ext.Run(cmd, "-t", c.Table, "-S", c.Chain); err != nil {
+				missing = append(missing, fmt.Sprintf("%s -t %s -N %s", cmd, c.Table, c.Chain))
+			}
+		}
+		for _, r := range family.Rules {
+			if err := cfg.ext.Run(cmd, append([]string{"-t", r.Table, "-C", r.Chain}, r.Params...)...); err != nil {
+				missing = append(missing, fmt.Sprintf("%s -t %s -A %s %s", cmd, r.Table, r.Chain, strings.Join(r.Params, " ")))
+			}
+		}
+	}
+	check(constants.IPTABLES, plan.IPv4)
+	check(constants.IP6TABLES, plan.IPv6)
+	if len(missing) > 0 {
+		return fmt.Errorf("%d iptables chains and rules are missing:\n%s", len(missing), strings.Join(missing, "\n"))
+	}
+	return nil
+}
+
+// build builds the iptables rules from the config, once.
+func (cfg *IptablesConfigurator) build() {
+	if cfg.built {
+		return
+	}
+	cfg.built = true
+
 	// Since OUTBOUND_IP_RANGES_EXCLUDE could carry ipv4 and ipv6 ranges
 	// need to split them in different arrays one for ipv4 and one for ipv6
 	// in order to not to fail
@@ -564,7 +608,6 @@ func (cfg *IptablesConfigurator) Run() {
 		cfg.iptables.InsertRule(iptableslog.UndefinedCommand, constants.ISTIOINBOUND, constants.MANGLE, 3,
 			"-p", constants.TCP, "-i", "lo", "-m", "mark", "!", "--mark", outboundMark, "-j", constants.RETURN)
 	}
-	cfg.executeCommands()
 }
 
 type UDPRuleApplier struct {
@@ -15,6 +15,7 @@
 package capture
 
 import (
+	"fmt"
 	"net"
 	"path/filepath"
 	"reflect"
@@ -22,6 +23,7 @@ import (
 	"testing"
 
 	testutil "istio.io/istio/pilot/test/util"
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
 	"istio.io/istio/tools/istio-iptables/pkg/config"
 	"istio.io/istio/tools/istio-iptables/pkg/constants"
 	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
@@ -332,6 +334,79 @@ func TestSeparateV4V6(t *testing.T) {
 	}
 }
 
+func TestIptablesPlan(t *testing.T) {
+	cfg := constructTestConfig()
+	cfg.InboundPortsInclude = "*"
+	cfg.EnableInboundIPv6 = true
+	iptConfigurator := NewIptablesConfigurator(cfg, &dep.StdoutStubDependencies{})
+	plan := iptConfigurator.Plan()
+
+	// the plan describes the same rules as the commands which are run
+	toCommands := func(cmd string, family builder.FamilyPlan) []string {
+		var out []string
+		for _, c := range family.Chains {
+			out = append(out, fmt.Sprintf("%s -t %s -N %s", cmd, c.Table, c.Chain))
+		}
+		for _, r := range family.Rules {
+			op := "-A " + r.Chain
+			if r.Position > 0 {
+				op = fmt.Sprintf("-I %s %d", r.Chain, r.Position)
+			}
+			out = append(out, fmt.Sprintf("%s -t %s %s %s", cmd, r.Table, op, strings.Join(r.Params, " ")))
+		}
+		return out
+	}
+	actual := append(toCommands(constants.IPTABLES, plan.IPv4), toCommands(constants.IP6TABLES, plan.IPv6)...)
+	expected := FormatIptablesCommands(append(iptConfigurator.iptables.BuildV4(), iptConfigurator.iptables.BuildV6()...))
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+
+	// the rules are only built once
+	if again := iptConfigurator.Plan(); !reflect.DeepEqual(plan, again) {
+		t.Fatalf("expected the same plan, got %v", again)
+	}
+}
+
+// checkDependencies fails the iptables checks of the rules containing one of the missing params.
+type checkDependencies struct {
+	dep.StdoutStubDependencies
+	missing []string
+}
+
+func (c *checkDependencies) Run(cmd string, args ...string) error {
+	command := cmd + " " + strings.Join(args, " ")
+	for _, m := range c.missing {
+		if strings.Contains(command, m) {
+			return fmt.Errorf("exit status 1")
+		}
+	}
+	return nil
+}
+
+func TestIptablesVerify(t *testing.T) {
+	cfg := constructTestConfig()
+	cfg.InboundPortsInclude = "*"
+
+	if err := NewIptablesConfigurator(cfg, &checkDependencies{}).Verify(); err != nil {
+		t.Fatalf("expected all rules to be installed, got %v", err)
+	}
+
+	ext := &checkDependencies{missing: []string{"-S ISTIO_REDIRECT", "--to-ports 15006"}}
+	err := NewIptablesConfigurator(cfg, ext).Verify()
+	if err == nil {
+		t.Fatalf("expected missing rules")
+	}
+	for _, expected := range []string{
+		"iptables -t nat -N ISTIO_REDIRECT",
+		"iptables -t nat -A ISTIO_IN_REDIRECT -p tcp -j REDIRECT --to-ports 15006",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q to be missing, got %v", expected, err)
+		}
+	}
+}
+
 func compareToGolden(t *testing.T, name string, actual []string) {
 	t.Helper()
 	gotBytes := []byte(strings.Join(actual, "\n"))
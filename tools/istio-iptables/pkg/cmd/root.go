@@ -15,16 +15,19 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"os"
 	"os/user"
+	"strconv"
 	"strings"
 
 	"github.com/miekg/dns"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"istio.io/istio/tools/istio-iptables/pkg/builder"
 	"istio.io/istio/tools/istio-iptables/pkg/capture"
 	"istio.io/istio/tools/istio-iptables/pkg/config"
 	"istio.io/istio/tools/istio-iptables/pkg/constants"
@@ -65,6 +68,18 @@ var rootCmd = &cobra.Command{
 		}
 
 		iptConfigurator := capture.NewIptablesConfigurator(cfg, ext)
+		if cfg.PlanOutputPath != "" {
+			if err := writePlan(cfg.PlanOutputPath, iptConfigurator.Plan()); err != nil {
+				handleErrorWithCode(err, 1)
+			}
+		}
+		if cfg.VerifyRules {
+			if err := iptConfigurator.Verify(); err != nil {
+				handleErrorWithCode(err, 1)
+			}
+			log.Infof("all iptables rules are installed")
+			return
+		}
 		if !cfg.SkipRuleApply {
 			iptConfigurator.Run()
 			if err := capture.ConfigureRoutes(cfg, ext); err != nil {
@@ -137,6 +152,8 @@ func constructConfig() *config.Config {
 		OutputPath:              viper.GetString(constants.OutputPath),
 		NetworkNamespace:        viper.GetString(constants.NetworkNamespace),
 		CNIMode:                 viper.GetBool(constants.CNIMode),
+		PlanOutputPath:          viper.GetString(constants.PlanOutputPath),
+		VerifyRules:             viper.GetBool(constants.VerifyRules),
 	}
 
 	// TODO: Make this more configurable, maybe with an allowlist of users to be captured for output instead of a denylist.
@@ -156,12 +173,27 @@ func constructConfig() *config.Config {
 		cfg.ProxyGID = cfg.ProxyUID
 	}
 
-	// Detect whether IPv6 is enabled by checking if the pod's IP address is IPv4 or IPv6.
+	// Detect whether IPv6 is enabled by checking if the pod's IP address is IPv4 or IPv6, or if one of the declared
+	// additional IPs of its secondary interfaces is an IPv6 address, so that their inbound traffic is captured even if
+	// their IP family differs from the one of the pod IP.
 	podIP, err := getLocalIP()
-	if err != nil {
+	redirectIPv6 := viper.GetString(constants.RedirectIPv6)
+	if err != nil && redirectIPv6 == "" {
 		panic(err)
 	}
-	cfg.EnableInboundIPv6 = podIP.To4() == nil
+	detectIPv6 := podIP.To4() == nil || hasIPv6(parseAdditionalIPs(viper.GetString(constants.AdditionalIPs)))
+	if redirectIPv6 == "" {
+		cfg.EnableInboundIPv6 = detectIPv6
+	} else {
+		enable, err := strconv.ParseBool(redirectIPv6)
+		if err != nil {
+			panic(fmt.Sprintf("invalid value %q for --%s: %v", redirectIPv6, constants.RedirectIPv6, err))
+		}
+		if podIP != nil && enable != detectIPv6 {
+			log.Warnf("IPv6 redirection is forced to %v, although the pod IP is %s", enable, podIP)
+		}
+		cfg.EnableInboundIPv6 = enable
+	}
 
 	// Lookup DNS nameservers. We only do this if DNS is enabled in case of some obscure theoretical
 	// case where reading /etc/resolv.conf could fail.
@@ -192,6 +224,50 @@ func getLocalIP() (net.IP, error) {
 	return nil, fmt.Errorf("no valid local IP address found")
 }
 
+// parseAdditionalIPs parses the comma separated additional IPs of the secondary interfaces of the workload. Invalid
+// IPs are ignored.
+func parseAdditionalIPs(s string) []net.IP {
+	var ips []net.IP
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if ip := net.ParseIP(v); ip != nil {
+			ips = append(ips, ip)
+		} else {
+			log.Warnf("ignoring invalid additional IP %q", v)
+		}
+	}
+	return ips
+}
+
+// hasIPv6 returns true if any of the IP addresses is an IPv6 address.
+func hasIPv6(ips []net.IP) bool {
+	for _, ip := range ips {
+		if ip.To4() == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// writePlan writes the plan of the iptables rules as JSON to the given file path, or stdout if it is "-".
+func writePlan(path string, plan builder.Plan) error {
+	out, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal iptables plan: %v", err)
+	}
+	out = append(out, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		return fmt.Errorf("failed to write iptables plan to %v: %v", path, err)
+	}
+	return nil
+}
+
 func handleError(err error) {
 	handleErrorWithCode(err, 1)
 }
@@ -353,6 +429,16 @@ func bindFlags(cmd *cobra.Command, args []string) {
 	}
 	viper.SetDefault(constants.CaptureAllDNS, false)
 
+	if err := viper.BindPFlag(constants.RedirectIPv6, cmd.Flags().Lookup(constants.RedirectIPv6)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.RedirectIPv6, "")
+
+	if err := viper.BindPFlag(constants.AdditionalIPs, cmd.Flags().Lookup(constants.AdditionalIPs)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.AdditionalIPs, "")
+
 	if err := viper.BindPFlag(constants.OutputPath, cmd.Flags().Lookup(constants.OutputPath)); err != nil {
 		handleError(err)
 	}
@@ -367,6 +453,16 @@ func bindFlags(cmd *cobra.Command, args []string) {
 		handleError(err)
 	}
 	viper.SetDefault(constants.CNIMode, false)
+
+	if err := viper.BindPFlag(constants.PlanOutputPath, cmd.Flags().Lookup(constants.PlanOutputPath)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.PlanOutputPath, "")
+
+	if err := viper.BindPFlag(constants.VerifyRules, cmd.Flags().Lookup(constants.VerifyRules)); err != nil {
+		handleError(err)
+	}
+	viper.SetDefault(constants.VerifyRules, false)
 }
 
 // https://github.com/spf13/viper/issues/233.
@@ -448,11 +544,23 @@ func bindCmdlineFlags(rootCmd *cobra.Command) {
 	rootCmd.Flags().Bool(constants.CaptureAllDNS, false,
 		"Instead of only capturing DNS traffic to DNS server IP, capture all DNS traffic at port 53. This setting is only effective when redirect dns is enabled.")
 
+	rootCmd.Flags().String(constants.RedirectIPv6, "",
+		"Force the redirection of IPv6 traffic on (true) or off (false). By default, it is only enabled when the pod IP or one of the additional IPs is an IPv6 address.")
+
+	rootCmd.Flags().String(constants.AdditionalIPs, "",
+		"Comma separated list of the IPs of the secondary interfaces of the workload, declared with the networking.istio.io/additionalIPs annotation.")
+
 	rootCmd.Flags().String(constants.OutputPath, "", "A file path to write the applied iptables rules to.")
 
 	rootCmd.Flags().String(constants.NetworkNamespace, "", "The network namespace that iptables rules should be applied to.")
 
 	rootCmd.Flags().Bool(constants.CNIMode, false, "Whether to run as CNI plugin.")
+
+	rootCmd.Flags().String(constants.PlanOutputPath, "",
+		"A file path to write the plan of the iptables rules to, as JSON. Use \"-\" to write it to stdout.")
+
+	rootCmd.Flags().Bool(constants.VerifyRules, false,
+		"Instead of applying the iptables rules, verify that they are currently installed.")
 }
 
 func GetCommand() *cobra.Command {
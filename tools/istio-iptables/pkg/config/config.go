@@ -61,6 +61,8 @@ type Config struct {
 	NetworkNamespace        string        `json:"NETWORK_NAMESPACE"`
 	CNIMode                 bool          `json:"CNI_MODE"`
 	TraceLogging            bool          `json:"IPTABLES_TRACE_LOGGING"`
+	PlanOutputPath          string        `json:"PLAN_OUTPUT_PATH"`
+	VerifyRules             bool          `json:"VERIFY_RULES"`
 }
 
 func (c *Config) String() string {
@@ -99,6 +101,8 @@ func (c *Config) Print() {
 	b.WriteString(fmt.Sprintf("NETWORK_NAMESPACE=%s\n", c.NetworkNamespace))
 	b.WriteString(fmt.Sprintf("CNI_MODE=%s\n", strconv.FormatBool(c.CNIMode)))
 	b.WriteString(fmt.Sprintf("EXCLUDE_INTERFACES=%s\n", c.ExcludeInterfaces))
+	b.WriteString(fmt.Sprintf("PLAN_OUTPUT_PATH=%s\n", c.PlanOutputPath))
+	b.WriteString(fmt.Sprintf("VERIFY_RULES=%t\n", c.VerifyRules))
 	log.Infof("Istio iptables variables:\n%s", b.String())
 }
 
@@ -74,6 +74,10 @@ const (
 	ISTIOTPROXY     = "ISTIO_TPROXY"
 	ISTIOREDIRECT   = "ISTIO_REDIRECT"
 	ISTIOINREDIRECT = "ISTIO_IN_REDIRECT"
+
+	// Chains holding the exclusions updated at runtime by the agent.
+	ISTIODYNAMICINBOUND = "ISTIO_DYNAMIC_INBOUND"
+	ISTIODYNAMICOUTPUT  = "ISTIO_DYNAMIC_OUTPUT"
 )
 
 // Constants used in cobra/viper CLI
@@ -105,9 +109,13 @@ const (
 	RedirectDNS               = "redirect-dns"
 	DropInvalid               = "drop-invalid"
 	CaptureAllDNS             = "capture-all-dns"
+	RedirectIPv6              = "redirect-ipv6"
+	AdditionalIPs             = "additional-ips"
 	OutputPath                = "output-paths"
 	NetworkNamespace          = "network-namespace"
 	CNIMode                   = "cni-mode"
+	PlanOutputPath            = "plan-output-path"
+	VerifyRules               = "verify-rules"
 )
 
 // Environment variables that deliberately have no equivalent command-line flags.
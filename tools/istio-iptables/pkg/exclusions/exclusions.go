@@ -0,0 +1,290 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package exclusions reconciles traffic interception exclusions at runtime, so that ports and
+// IP ranges can bypass the proxy without restarting the pod.
+//
+// The exclusions are programmed in dedicated chains which are jumped to from the start of the
+// ISTIO_INBOUND and ISTIO_OUTPUT chains set up by istio-iptables. Each reconciliation atomically
+// replaces the contents of these chains with iptables-restore, leaving all other rules untouched.
+package exclusions
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/api/annotation"
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+	dep "istio.io/istio/tools/istio-iptables/pkg/dependencies"
+	"istio.io/pkg/log"
+)
+
+// Sources of exclusions. The applied exclusions are the union of all sources.
+const (
+	// SourceAnnotations holds the exclusions read from the traffic.sidecar.istio.io pod annotations.
+	SourceAnnotations = "annotations"
+	// SourceAPI holds the exclusions set through the agent local API.
+	SourceAPI = "api"
+)
+
+// Exclusions is a set of traffic that bypasses the proxy.
+type Exclusions struct {
+	// InboundPorts are the inbound TCP ports that are not redirected to the proxy.
+	InboundPorts []string `json:"inboundPorts,omitempty"`
+	// OutboundPorts are the outbound TCP ports that are not redirected to the proxy.
+	OutboundPorts []string `json:"outboundPorts,omitempty"`
+	// OutboundIPRanges are the CIDRs of outbound traffic that is not redirected to the proxy.
+	OutboundIPRanges []string `json:"outboundIPRanges,omitempty"`
+}
+
+// FromAnnotations reads the exclusions from the traffic.sidecar.istio.io pod annotations.
+func FromAnnotations(annotations map[string]string) Exclusions {
+	return Exclusions{
+		InboundPorts:     split(annotations[annotation.SidecarTrafficExcludeInboundPorts.Name]),
+		OutboundPorts:    split(annotations[annotation.SidecarTrafficExcludeOutboundPorts.Name]),
+		OutboundIPRanges: split(annotations[annotation.SidecarTrafficExcludeOutboundIPRanges.Name]),
+	}
+}
+
+// Validate checks that all ports and IP ranges are well formed.
+func (e Exclusions) Validate() error {
+	for _, ports := range [][]string{e.InboundPorts, e.OutboundPorts} {
+		for _, port := range ports {
+			if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+				return fmt.Errorf("invalid port %q", port)
+			}
+		}
+	}
+	for _, cidr := range e.OutboundIPRanges {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid IP range %q: %v", cidr, err)
+		}
+	}
+	return nil
+}
+
+// merge returns the sorted union of the given exclusions.
+func merge(all ...Exclusions) Exclusions {
+	inbound, outbound, ranges := map[string]struct{}{}, map[string]struct{}{}, map[string]struct{}{}
+	for _, e := range all {
+		for _, p := range e.InboundPorts {
+			inbound[p] = struct{}{}
+		}
+		for _, p := range e.OutboundPorts {
+			outbound[p] = struct{}{}
+		}
+		for _, cidr := range e.OutboundIPRanges {
+			ranges[cidr] = struct{}{}
+		}
+	}
+	return Exclusions{
+		InboundPorts:     sortedKeys(inbound),
+		OutboundPorts:    sortedKeys(outbound),
+		OutboundIPRanges: sortedKeys(ranges),
+	}
+}
+
+// Reconciler programs the union of the exclusions of all sources into iptables.
+type Reconciler struct {
+	ext          dep.Dependencies
+	inboundTable string
+	ipv6         bool
+
+	mu      sync.Mutex
+	sources map[string]Exclusions
+	applied *Exclusions
+}
+
+// NewReconciler creates a reconciler. tproxy must match the inbound interception mode used by
+// istio-iptables, and ipv6 whether the ip6tables rules were set up as well.
+func NewReconciler(ext dep.Dependencies, tproxy, ipv6 bool) *Reconciler {
+	inboundTable := constants.NAT
+	if tproxy {
+		inboundTable = constants.MANGLE
+	}
+	return &Reconciler{
+		ext:          ext,
+		inboundTable: inboundTable,
+		ipv6:         ipv6,
+		sources:      map[string]Exclusions{},
+	}
+}
+
+// Get returns the exclusions of all sources.
+func (r *Reconciler) Get() Exclusions {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.desired(r.sources)
+}
+
+// Set replaces the exclusions of a source and reconciles iptables. The exclusions of the source are
+// only updated if the reconciliation succeeds.
+func (r *Reconciler) Set(source string, e Exclusions) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sources := make(map[string]Exclusions, len(r.sources)+1)
+	for k, v := range r.sources {
+		sources[k] = v
+	}
+	sources[source] = e
+	desired := r.desired(sources)
+	if r.applied != nil && reflect.DeepEqual(*r.applied, desired) {
+		r.sources = sources
+		return nil
+	}
+	if err := r.apply(desired); err != nil {
+		return err
+	}
+	log.Infof("updated traffic exclusions from %s: inbound ports %v, outbound ports %v, outbound IP ranges %v",
+		source, desired.InboundPorts, desired.OutboundPorts, desired.OutboundIPRanges)
+	r.sources = sources
+	r.applied = &desired
+	return nil
+}
+
+func (r *Reconciler) desired(sources map[string]Exclusions) Exclusions {
+	all := make([]Exclusions, 0, len(sources))
+	for _, e := range sources {
+		all = append(all, e)
+	}
+	return merge(all...)
+}
+
+func (r *Reconciler) apply(e Exclusions) error {
+	if err := r.restore(constants.IPTABLESRESTORE, r.buildRestore(e, false)); err != nil {
+		return err
+	}
+	if r.ipv6 {
+		if err := r.restore(constants.IP6TABLESRESTORE, r.buildRestore(e, true)); err != nil {
+			return err
+		}
+	}
+	if r.applied == nil {
+		// The chains exist now, jump to them before any of the istio-iptables rules.
+		// Deleting first keeps this idempotent across agent restarts.
+		cmds := []string{constants.IPTABLES}
+		if r.ipv6 {
+			cmds = append(cmds, constants.IP6TABLES)
+		}
+		for _, cmd := range cmds {
+			for _, jump := range r.jumps() {
+				r.ext.RunQuietlyAndIgnore(cmd, append([]string{"-t", jump.table, "-D"}, jump.rule...)...)
+				args := append([]string{"-t", jump.table, "-I", jump.rule[0], "1"}, jump.rule[1:]...)
+				if err := r.ext.Run(cmd, args...); err != nil {
+					return fmt.Errorf("failed to jump to %s: %v", jump.rule[len(jump.rule)-1], err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type jump struct {
+	table string
+	rule  []string
+}
+
+func (r *Reconciler) jumps() []jump {
+	return []jump{
+		{r.inboundTable, []string{constants.ISTIOINBOUND, "-p", constants.TCP, "-j", constants.ISTIODYNAMICINBOUND}},
+		{constants.NAT, []string{constants.ISTIOOUTPUT, "-p", constants.TCP, "-j", constants.ISTIODYNAMICOUTPUT}},
+	}
+}
+
+// buildRestore builds the iptables-restore input replacing the contents of the exclusion chains.
+// Excluded traffic is accepted, which ends the traversal of the table before any redirection.
+func (r *Reconciler) buildRestore(e Exclusions, ipv6 bool) string {
+	rules := map[string][]string{
+		r.inboundTable: {fmt.Sprintf(":%s - [0:0]", constants.ISTIODYNAMICINBOUND)},
+	}
+	rules[constants.NAT] = append(rules[constants.NAT], fmt.Sprintf(":%s - [0:0]", constants.ISTIODYNAMICOUTPUT))
+	for _, port := range e.InboundPorts {
+		rules[r.inboundTable] = append(rules[r.inboundTable],
+			fmt.Sprintf("-A %s -p %s --dport %s -j %s", constants.ISTIODYNAMICINBOUND, constants.TCP, port, constants.ACCEPT))
+	}
+	for _, port := range e.OutboundPorts {
+		rules[constants.NAT] = append(rules[constants.NAT],
+			fmt.Sprintf("-A %s -p %s --dport %s -j %s", constants.ISTIODYNAMICOUTPUT, constants.TCP, port, constants.ACCEPT))
+	}
+	for _, cidr := range e.OutboundIPRanges {
+		ip, _, _ := net.ParseCIDR(cidr)
+		if (ip.To4() == nil) != ipv6 {
+			continue
+		}
+		rules[constants.NAT] = append(rules[constants.NAT],
+			fmt.Sprintf("-A %s -d %s -j %s", constants.ISTIODYNAMICOUTPUT, cidr, constants.ACCEPT))
+	}
+
+	var b strings.Builder
+	for _, table := range []string{constants.MANGLE, constants.NAT} {
+		if len(rules[table]) == 0 {
+			continue
+		}
+		fmt.Fprintln(&b, "*", table)
+		for _, rule := range rules[table] {
+			fmt.Fprintln(&b, rule)
+		}
+		fmt.Fprintln(&b, "COMMIT")
+	}
+	return b.String()
+}
+
+func (r *Reconciler) restore(cmd, data string) error {
+	rulesFile, err := os.CreateTemp("", fmt.Sprintf("%s-exclusions-%d.txt", cmd, time.Now().UnixNano()))
+	if err != nil {
+		return fmt.Errorf("unable to create %s file: %v", cmd, err)
+	}
+	defer os.Remove(rulesFile.Name())
+	if _, err := rulesFile.WriteString(data); err != nil {
+		rulesFile.Close()
+		return fmt.Errorf("unable to write %s file: %v", cmd, err)
+	}
+	if err := rulesFile.Close(); err != nil {
+		return err
+	}
+	// --noflush only replaces the chains declared in the file.
+	if err := r.ext.Run(cmd, "--noflush", rulesFile.Name()); err != nil {
+		return fmt.Errorf("failed to update traffic exclusions: %v", err)
+	}
+	return nil
+}
+
+func split(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
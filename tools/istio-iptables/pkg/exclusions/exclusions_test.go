@@ -0,0 +1,146 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exclusions
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"istio.io/istio/tools/istio-iptables/pkg/constants"
+)
+
+// recordingDependencies records the commands and the content of the iptables-restore files.
+type recordingDependencies struct {
+	commands []string
+	restores map[string]string
+	fail     bool
+}
+
+func (r *recordingDependencies) RunOrFail(cmd string, args ...string) {
+	_ = r.Run(cmd, args...)
+}
+
+func (r *recordingDependencies) Run(cmd string, args ...string) error {
+	if r.fail {
+		return errors.New("exit status 1")
+	}
+	if cmd == constants.IPTABLESRESTORE || cmd == constants.IP6TABLESRESTORE {
+		data, err := os.ReadFile(args[len(args)-1])
+		if err != nil {
+			return err
+		}
+		r.restores[cmd] = string(data)
+		return nil
+	}
+	r.commands = append(r.commands, cmd+" "+strings.Join(args, " "))
+	return nil
+}
+
+func (r *recordingDependencies) RunQuietlyAndIgnore(cmd string, args ...string) {
+	_ = r.Run(cmd, args...)
+}
+
+func TestReconciler(t *testing.T) {
+	ext := &recordingDependencies{restores: map[string]string{}}
+	r := NewReconciler(ext, false, true)
+
+	if err := r.Set(SourceAnnotations, FromAnnotations(map[string]string{
+		"traffic.sidecar.istio.io/excludeInboundPorts":      "9090, 8080",
+		"traffic.sidecar.istio.io/excludeOutboundIPRanges": "10.0.0.0/8,fd00::/8",
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Set(SourceAPI, Exclusions{InboundPorts: []string{"8080"}, OutboundPorts: []string{"5432"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := Exclusions{
+		InboundPorts:     []string{"8080", "9090"},
+		OutboundPorts:    []string{"5432"},
+		OutboundIPRanges: []string{"10.0.0.0/8", "fd00::/8"},
+	}
+	if got := r.Get(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	wantV4 := `* nat
+:ISTIO_DYNAMIC_INBOUND - [0:0]
+:ISTIO_DYNAMIC_OUTPUT - [0:0]
+-A ISTIO_DYNAMIC_INBOUND -p tcp --dport 8080 -j ACCEPT
+-A ISTIO_DYNAMIC_INBOUND -p tcp --dport 9090 -j ACCEPT
+-A ISTIO_DYNAMIC_OUTPUT -p tcp --dport 5432 -j ACCEPT
+-A ISTIO_DYNAMIC_OUTPUT -d 10.0.0.0/8 -j ACCEPT
+COMMIT
+`
+	if got := ext.restores[constants.IPTABLESRESTORE]; got != wantV4 {
+		t.Fatalf("got iptables rules:\n%s\nwant:\n%s", got, wantV4)
+	}
+	if got := ext.restores[constants.IP6TABLESRESTORE]; !strings.Contains(got, "-d fd00::/8") || strings.Contains(got, "10.0.0.0/8") {
+		t.Fatalf("unexpected ip6tables rules:\n%s", got)
+	}
+	// The jumps are only set up once.
+	if len(ext.commands) != 8 {
+		t.Fatalf("expected the jumps to be set up once, got %v", ext.commands)
+	}
+	if ext.commands[1] != "iptables -t nat -I ISTIO_INBOUND 1 -p tcp -j ISTIO_DYNAMIC_INBOUND" {
+		t.Fatalf("unexpected jump %q", ext.commands[1])
+	}
+
+	// Removing the API exclusions keeps the annotation ones.
+	if err := r.Set(SourceAPI, Exclusions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ext.restores[constants.IPTABLESRESTORE]; strings.Contains(got, "5432") || !strings.Contains(got, "--dport 8080") {
+		t.Fatalf("unexpected iptables rules:\n%s", got)
+	}
+}
+
+func TestReconcilerTProxy(t *testing.T) {
+	ext := &recordingDependencies{restores: map[string]string{}}
+	r := NewReconciler(ext, true, false)
+	if err := r.Set(SourceAPI, Exclusions{InboundPorts: []string{"8080"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ext.restores[constants.IPTABLESRESTORE]; !strings.HasPrefix(got, "* mangle\n:ISTIO_DYNAMIC_INBOUND - [0:0]\n-A ISTIO_DYNAMIC_INBOUND") {
+		t.Fatalf("expected inbound exclusions in the mangle table, got:\n%s", got)
+	}
+	if _, f := ext.restores[constants.IP6TABLESRESTORE]; f {
+		t.Fatal("unexpected ip6tables rules")
+	}
+}
+
+func TestReconcilerErrors(t *testing.T) {
+	ext := &recordingDependencies{restores: map[string]string{}}
+	r := NewReconciler(ext, false, false)
+	for _, e := range []Exclusions{
+		{InboundPorts: []string{"http"}},
+		{OutboundPorts: []string{"70000"}},
+		{OutboundIPRanges: []string{"10.0.0.1"}},
+	} {
+		if err := r.Set(SourceAPI, e); err == nil {
+			t.Fatalf("expected %+v to be invalid", e)
+		}
+	}
+
+	ext.fail = true
+	if err := r.Set(SourceAPI, Exclusions{InboundPorts: []string{"8080"}}); err == nil {
+		t.Fatal("expected the reconciliation to fail")
+	}
+	if got := r.Get(); len(got.InboundPorts) != 0 {
+		t.Fatalf("expected the failed exclusions to not be recorded, got %+v", got)
+	}
+}